@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// testExpectations are the assertions of a case file on the rendered
+// document, so a test states what the document must say instead of
+// comparing it as a whole:
+//
+//	"expect": {
+//	  "contains": "Total: €1,234.56",
+//	  "notContains": ["{{", "TODO"],
+//	  "matches": "Invoice INV-\\d{4}",
+//	  "tables": 2,
+//	  "tableRows": {"Items": 3}
+//	}
+type testExpectations struct {
+	// Contains lists texts the document must contain and NotContains
+	// texts it must not contain.
+	Contains    stringList `json:"contains"`
+	NotContains stringList `json:"notContains"`
+	// Matches lists regular expressions that must match the text of the
+	// document.
+	Matches stringList `json:"matches"`
+	// Tables is the number of tables of the document.
+	Tables *int `json:"tables"`
+	// TableRows maps tables to their number of rows, not counting header
+	// rows (w:tblHeader). A table is named by its number, counting from 1,
+	// by the text of the paragraph right before it, such as its heading,
+	// or by the text of its first cell.
+	TableRows map[string]int `json:"tableRows"`
+}
+
+// stringList is a list of strings that may be given as a single string.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*l = list
+	return nil
+}
+
+// check returns a description of each assertion the document fails.
+func (e *testExpectations) check(doc *renderedDocument) []string {
+	var failures []string
+	for _, text := range e.Contains {
+		if !strings.Contains(doc.Text, text) {
+			failures = append(failures, fmt.Sprintf("expected text %q", text))
+		}
+	}
+	for _, text := range e.NotContains {
+		if strings.Contains(doc.Text, text) {
+			failures = append(failures, fmt.Sprintf("unexpected text %q", text))
+		}
+	}
+	for _, pattern := range e.Matches {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+			continue
+		}
+		if !re.MatchString(doc.Text) {
+			failures = append(failures, fmt.Sprintf("expected text matching %q", pattern))
+		}
+	}
+	if e.Tables != nil && len(doc.Tables) != *e.Tables {
+		failures = append(failures, fmt.Sprintf("expected %d tables, got %d", *e.Tables, len(doc.Tables)))
+	}
+
+	names := make([]string, 0, len(e.TableRows))
+	for name := range e.TableRows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		table := doc.findTable(name)
+		if table == nil {
+			failures = append(failures, fmt.Sprintf("no table %q", name))
+			continue
+		}
+		if rows := table.Rows - table.HeaderRows; rows != e.TableRows[name] {
+			failures = append(failures, fmt.Sprintf("expected %d rows in table %q, got %d", e.TableRows[name], name, rows))
+		}
+	}
+	return failures
+}
+
+// findTable returns the table named by its number, counting from 1, by the
+// text of the paragraph before it or by the text of its first cell, or nil.
+func (d *renderedDocument) findTable(name string) *renderedTable {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n >= 1 && n <= len(d.Tables) {
+			return d.Tables[n-1]
+		}
+		return nil
+	}
+	for _, table := range d.Tables {
+		if table.Heading == name {
+			return table
+		}
+	}
+	for _, table := range d.Tables {
+		if table.FirstCell == name {
+			return table
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTestAssertions(t *testing.T) {
+	dir := t.TempDir()
+	cell := func(text string) string {
+		return `<w:tc><w:p><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p></w:tc>`
+	}
+	writeTestDOCX(t, filepath.Join(dir, "invoice.docx"), `<w:p><w:r><w:t xml:space="preserve">Invoice {{number}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>Items</w:t></w:r></w:p><w:tbl><w:tblGrid><w:gridCol w:w="4000"/><w:gridCol w:w="2000"/></w:tblGrid>`+
+		`<w:tr><w:trPr><w:tblHeader/></w:trPr>`+cell("Item")+cell("Price")+`</w:tr>`+
+		`<w:tr>`+cell("{{for line in lines}}")+`</w:tr>`+
+		`<w:tr>`+cell("{{line.name}}")+cell("{{line.price}}")+`</w:tr>`+
+		`<w:tr>`+cell("{{end}}")+`</w:tr></w:tbl>`+
+		`<w:p/><w:tbl><w:tblGrid><w:gridCol w:w="6000"/></w:tblGrid><w:tr>`+cell("Notes")+`</w:tr><w:tr>`+cell("{{note}}")+`</w:tr></w:tbl>`+
+		`<w:p><w:r><w:t xml:space="preserve">Total: {{total}}</w:t></w:r></w:p>`)
+
+	writeCase := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	data := `"data": {"number": "INV-0042", "total": "€1,234.56", "note": "Thanks",
+		"lines": [{"name": "Bolts", "price": 1}, {"name": "Nuts", "price": 2}, {"name": "Washers", "price": 3}]}`
+	passing := writeCase("invoice.json", `{`+data+`, "expect": {
+		"contains": "Total: €1,234.56", "notContains": ["{{", "TODO"], "matches": "Invoice INV-\\d{4}",
+		"tables": 2, "tableRows": {"Items": 3, "Notes": 2, "2": 2}}}`)
+	failing := writeCase("invoice.wrong.json", `{`+data+`, "expect": {
+		"contains": ["Total: €9.99"], "notContains": "Bolts", "tables": 1, "tableRows": {"Items": 4, "Summary": 1}}}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"test", dir, "--cases", passing}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"test", dir, "--cases", failing}, &stdout, &stderr); code != 1 {
+		t.Fatalf("run() exit code = %d, want 1:\n%s", code, stdout.String())
+	}
+	want := `expected text "Total: €9.99"; unexpected text "Bolts"; expected 1 tables, got 2; ` +
+		`expected 4 rows in table "Items", got 3; no table "Summary"`
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, stdout.String())
+	}
+
+	writeCase("invoice.invalid.json", `{"expect": {"contains": 42}}`)
+	stdout.Reset()
+	run([]string{"test", dir, "--cases", filepath.Join(dir, "invoice.invalid.json")}, &stdout, &stderr)
+	if !strings.Contains(stdout.String(), "expected a string or a list of strings") {
+		t.Errorf("expected an error for an invalid assertion:\n%s", stdout.String())
+	}
+}
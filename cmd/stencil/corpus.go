@@ -0,0 +1,447 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// testCase is a case file of "stencil test": the data a template is
+// rendered with and what the rendered document is checked against.
+type testCase struct {
+	// Template is the path of the template relative to the templates
+	// directory. When empty, the case file name up to its first dot names
+	// the template, so invoice.paid.json tests invoice.docx.
+	Template string `json:"template"`
+	// Data is the render data.
+	Data map[string]interface{} `json:"data"`
+	// Golden is the path, relative to the case file, of the expected text
+	// of the rendered document.
+	Golden string `json:"golden"`
+	// ExpectError is a part of the error message the render must fail
+	// with.
+	ExpectError string `json:"expectError"`
+	// Layout is the layout budget of the rendered document.
+	Layout *stencil.LayoutBudget `json:"layout"`
+	// Expect holds assertions on the text and tables of the rendered
+	// document.
+	Expect *testExpectations `json:"expect"`
+}
+
+// patternsFlag collects the repeated --cases flag.
+type patternsFlag []string
+
+func (f *patternsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *patternsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runTest implements "stencil test <templates> [--cases pattern] [--update]".
+func runTest(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var patterns patternsFlag
+	fs.Var(&patterns, "cases", "case files or glob pattern (repeatable, default: <templates>/*.json)")
+	update := fs.Bool("update", false, "write the golden files instead of comparing against them")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil test <templates> [--cases ./cases/*.json] [--update]")
+		fs.PrintDefaults()
+	}
+
+	// Flags may follow the templates directory. The shell expands an
+	// unquoted --cases pattern into several paths; the paths after the
+	// first are case files as well.
+	var templatesDir string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if templatesDir == "" {
+			templatesDir = fs.Arg(0)
+		} else {
+			patterns = append(patterns, fs.Arg(0))
+		}
+		args = fs.Args()[1:]
+	}
+	if templatesDir == "" {
+		fs.Usage()
+		return 2
+	}
+	if len(patterns) == 0 {
+		patterns = append(patterns, filepath.Join(templatesDir, "*.json"))
+	}
+
+	templates, err := findTemplates(templatesDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "test: %v\n", err)
+		return 1
+	}
+	casePaths, err := expandCasePatterns(patterns)
+	if err != nil {
+		fmt.Fprintf(stderr, "test: %v\n", err)
+		return 2
+	}
+	if len(casePaths) == 0 {
+		fmt.Fprintf(stderr, "test: no case files match %s\n", strings.Join(patterns, ", "))
+		return 1
+	}
+
+	tested := make(map[string]bool)
+	passed, failed := 0, 0
+	for _, casePath := range casePaths {
+		templateName, warnings, err := runTestCase(templatesDir, templates, casePath, *update)
+		if templateName != "" {
+			tested[templateName] = true
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(stdout, "FAIL  %s: %v\n", casePath, err)
+			continue
+		}
+		passed++
+		fmt.Fprintf(stdout, "ok    %s (%s)\n", casePath, templateName)
+		for _, warning := range warnings {
+			fmt.Fprintf(stdout, "      warning: %s\n", warning)
+		}
+	}
+
+	var untested []string
+	for _, name := range templates {
+		if !tested[name] {
+			untested = append(untested, name)
+		}
+	}
+	fmt.Fprintf(stdout, "\n%d cases: %d passed, %d failed\n", passed+failed, passed, failed)
+	if len(untested) > 0 {
+		fmt.Fprintf(stdout, "%d templates without cases: %s\n", len(untested), strings.Join(untested, ", "))
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// findTemplates returns the paths of the DOCX templates in dir and its
+// subdirectories, relative to dir, sorted.
+func findTemplates(dir string) ([]string, error) {
+	var templates []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the lock files Word keeps next to open documents
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".docx") || strings.HasPrefix(entry.Name(), "~$") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(templates)
+	return templates, err
+}
+
+// expandCasePatterns returns the case files matched by the patterns, in
+// order and without duplicates.
+func expandCasePatterns(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid case pattern %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// runTestCase renders the template of the case file at casePath and checks
+// the result. It returns the name of the template, or "" when it is not
+// known, and the repair hints of problems that do not fail the render.
+func runTestCase(templatesDir string, templates []string, casePath string, update bool) (string, []string, error) {
+	content, err := os.ReadFile(casePath)
+	if err != nil {
+		return "", nil, err
+	}
+	var tc testCase
+	if err := json.Unmarshal(content, &tc); err != nil {
+		return "", nil, fmt.Errorf("invalid case file: %w", err)
+	}
+
+	templateName := filepath.ToSlash(tc.Template)
+	if templateName == "" {
+		stem, _, _ := strings.Cut(filepath.Base(casePath), ".")
+		for _, name := range templates {
+			if strings.EqualFold(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)), stem) {
+				templateName = name
+				break
+			}
+		}
+		if templateName == "" {
+			return "", nil, fmt.Errorf("no template named %s.docx in %s", stem, templatesDir)
+		}
+	}
+	docx, err := os.ReadFile(filepath.Join(templatesDir, filepath.FromSlash(templateName)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	output, warnings, err := renderTestCase(docx, tc.Data)
+	if tc.ExpectError != "" {
+		switch {
+		case err == nil:
+			return templateName, nil, fmt.Errorf("expected an error containing %q", tc.ExpectError)
+		case !strings.Contains(err.Error(), tc.ExpectError):
+			return templateName, nil, fmt.Errorf("expected an error containing %q, got: %v", tc.ExpectError, err)
+		}
+		return templateName, warnings, nil
+	}
+	if err != nil {
+		return templateName, nil, err
+	}
+
+	if tc.Layout != nil {
+		report, err := stencil.CheckLayout(output, *tc.Layout)
+		if err != nil {
+			return templateName, nil, err
+		}
+		if !report.OK() {
+			violations := make([]string, len(report.Violations))
+			for i, violation := range report.Violations {
+				violations[i] = violation.String()
+			}
+			return templateName, nil, fmt.Errorf("layout budget exceeded: %s", strings.Join(violations, "; "))
+		}
+	}
+
+	doc, err := readRenderedDocument(output)
+	if err != nil {
+		return templateName, nil, err
+	}
+	if tc.Expect != nil {
+		if failures := tc.Expect.check(doc); len(failures) > 0 {
+			return templateName, nil, fmt.Errorf("%s", strings.Join(failures, "; "))
+		}
+	}
+
+	if tc.Golden != "" {
+		text := doc.Text
+		goldenPath := filepath.Join(filepath.Dir(casePath), filepath.FromSlash(tc.Golden))
+		if update {
+			return templateName, warnings, os.WriteFile(goldenPath, []byte(text), 0o644)
+		}
+		golden, err := os.ReadFile(goldenPath)
+		if err != nil {
+			return templateName, nil, fmt.Errorf("%w (run with --update to create it)", err)
+		}
+		if want := strings.ReplaceAll(string(golden), "\r\n", "\n"); text != want {
+			return templateName, nil, fmt.Errorf("text differs from %s: %s", goldenPath, firstDifference(text, want))
+		}
+	}
+	return templateName, warnings, nil
+}
+
+// renderTestCase renders a template with the data of a case, validating the
+// rendered package and checking the assertions of the template. It returns
+// the repair hints of problems that do not fail the render.
+func renderTestCase(docx []byte, data map[string]interface{}) ([]byte, []string, error) {
+	tmpl, err := stencil.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("prepare: %w", err)
+	}
+	defer tmpl.Close()
+
+	var hints []string
+	reader, err := tmpl.RenderWithOptions(stencil.TemplateData(data), stencil.RenderOptions{
+		ValidateOutput:  true,
+		CheckAssertions: true,
+		OnRepairHint:    func(hint stencil.RepairHint) { hints = append(hints, hint.String()) },
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+	return output, hints, nil
+}
+
+// renderedDocument is the text and structure of the main document of a
+// rendered DOCX package.
+type renderedDocument struct {
+	// Text is the text of the document, one line per paragraph, with tabs
+	// and line breaks as they are.
+	Text string
+	// Tables are the tables of the document, including nested tables, in
+	// document order.
+	Tables []*renderedTable
+}
+
+// renderedTable is a table of a rendered document.
+type renderedTable struct {
+	Heading    string // the text of the paragraph right before the table
+	FirstCell  string // the text of its first cell
+	Rows       int
+	HeaderRows int // rows repeated as header rows (w:tblHeader)
+}
+
+// readRenderedDocument reads the text and the tables of the main document of
+// a DOCX package.
+func readRenderedDocument(docx []byte) (*renderedDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(docx), int64(len(docx)))
+	if err != nil {
+		return nil, err
+	}
+	file, err := zr.Open("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	doc := &renderedDocument{}
+	var text strings.Builder
+	type openTable struct {
+		table     *renderedTable
+		cells     int // cells of the current row
+		cellStart int // offset of the text of the first cell
+	}
+	var tables []*openTable
+	decoder := xml.NewDecoder(file)
+	inRun, inText := false, false
+	// The text of the paragraph of the body just before the current
+	// element, and the offset of the text of the current paragraph
+	previousParagraph, paragraphStart := "", 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			doc.Text = text.String()
+			return doc, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("word/document.xml: %w", err)
+		}
+		var current *openTable
+		if len(tables) > 0 {
+			current = tables[len(tables)-1]
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "r":
+				inRun = true
+			case "t":
+				inText = inRun
+			case "tab":
+				if inRun {
+					text.WriteByte('\t')
+				}
+			case "br", "cr":
+				text.WriteByte('\n')
+			case "p":
+				paragraphStart = text.Len()
+			case "tbl":
+				table := &renderedTable{}
+				if current == nil {
+					table.Heading = previousParagraph
+				}
+				doc.Tables = append(doc.Tables, table)
+				tables = append(tables, &openTable{table: table})
+			case "tr":
+				if current != nil {
+					current.table.Rows++
+					current.cells = 0
+				}
+			case "tblHeader":
+				if value := attrValue(t, "val"); current != nil && value != "0" && value != "false" {
+					current.table.HeaderRows++
+				}
+			case "tc":
+				if current != nil {
+					current.cells++
+					current.cellStart = text.Len()
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "r":
+				inRun = false
+			case "t":
+				inText = false
+			case "p":
+				if current == nil {
+					previousParagraph = strings.TrimSpace(text.String()[paragraphStart:])
+				}
+				text.WriteByte('\n')
+			case "tc":
+				if current != nil && current.table.Rows == 1 && current.cells == 1 {
+					current.table.FirstCell = strings.TrimSpace(text.String()[current.cellStart:])
+				}
+			case "tbl":
+				if current != nil {
+					tables = tables[:len(tables)-1]
+				}
+				previousParagraph = ""
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// attrValue returns the value of the attribute of the element with the
+// given local name, or "".
+func attrValue(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// firstDifference describes the first line in which got differs from want.
+func firstDifference(got, want string) string {
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Sprintf("line %d is %q, want %q", i+1, g, w)
+		}
+	}
+	return "line endings differ"
+}
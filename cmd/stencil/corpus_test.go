@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTest(t *testing.T) {
+	dir := t.TempDir()
+	templates := filepath.Join(dir, "templates")
+	cases := filepath.Join(dir, "cases")
+	for _, path := range []string{templates, cases} {
+		if err := os.Mkdir(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestDOCX(t, filepath.Join(templates, "invoice.docx"), `<w:p><w:r><w:t xml:space="preserve">Invoice for {{customer}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t xml:space="preserve">{{for line in lines}}{{line}}, {{end}}</w:t></w:r></w:p>`)
+	writeTestDOCX(t, filepath.Join(templates, "letter.docx"), `<w:p><w:r><w:t>Dear {{name}}{{assert name != ""}}</w:t></w:r></w:p>`)
+	writeTestDOCX(t, filepath.Join(templates, "broken.docx"), `<w:p><w:r><w:t>{{for x in}}</w:t></w:r></w:p>`)
+
+	writeCase := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(cases, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCase("invoice.json", `{"data": {"customer": "Acme", "lines": ["Bolts", "Nuts"]}, "golden": "invoice.txt", "layout": {"maxPages": 1}}`)
+	writeCase("invoice.empty.json", `{"data": {"customer": "Nobody"}}`)
+	writeCase("syntax.json", `{"template": "broken.docx", "expectError": "for"}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"test", templates, "--cases", filepath.Join(cases, "*.json"), "--update"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+	golden, err := os.ReadFile(filepath.Join(cases, "invoice.txt"))
+	if err != nil {
+		t.Fatalf("golden file not written: %v", err)
+	}
+	if want := "Invoice for Acme\nBolts, Nuts, \n"; string(golden) != want {
+		t.Errorf("golden = %q, want %q", golden, want)
+	}
+	for _, want := range []string{"3 cases: 3 passed, 0 failed", "1 templates without cases: letter.docx"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	// Paths expanded by the shell are case files as well
+	writeCase("invoice.json", `{"data": {"customer": "Globex", "lines": []}, "golden": "invoice.txt"}`)
+	writeCase("letter.json", `{"data": {"name": "Ada"}, "layout": {"fonts": ["Arial"]}}`)
+	stdout.Reset()
+	code := run([]string{"test", templates, "--cases", filepath.Join(cases, "invoice.json"), filepath.Join(cases, "letter.json")}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("run() exit code = %d, want 1:\n%s", code, stdout.String())
+	}
+	for _, want := range []string{
+		`FAIL  ` + filepath.Join(cases, "invoice.json") + `: text differs from ` + filepath.Join(cases, "invoice.txt") + `: line 1 is "Invoice for Globex", want "Invoice for Acme"`,
+		"ok    " + filepath.Join(cases, "letter.json") + " (letter.docx)",
+		"2 cases: 1 passed, 1 failed",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	// Assertions of the template are checked
+	writeCase("letter.json", `{"data": {"name": ""}}`)
+	stdout.Reset()
+	if code := run([]string{"test", templates, "--cases", filepath.Join(cases, "letter.json")}, &stdout, &stderr); code != 1 {
+		t.Fatalf("run() exit code = %d, want 1:\n%s", code, stdout.String())
+	}
+	if want := `assertion failed: name != ""`; !strings.Contains(stdout.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, stdout.String())
+	}
+
+	if code := run([]string{"test"}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing templates directory: exit code = %d, want 2", code)
+	}
+}
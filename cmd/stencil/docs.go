@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// storyPartPattern matches the header and footer parts of a document.
+var storyPartPattern = regexp.MustCompile(`^word/(header|footer)(\d+)\.xml$`)
+
+// dictionaryColumns are the columns of the data dictionary, in order.
+var dictionaryColumns = []string{"Field", "Type", "Description", "Example", "Appears in"}
+
+// runDocs implements "stencil docs <template> [--schema file] [--out file]".
+func runDocs(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("docs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "JSON schema with field descriptions and examples")
+	outPath := fs.String("out", "", "output file, .md or .xlsx (default: Markdown to stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil docs <template> [--schema schema.json] [--out fields.md|fields.xlsx]")
+		fs.PrintDefaults()
+	}
+
+	// Flags may follow the template path.
+	var templatePath string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if templatePath != "" {
+			fmt.Fprintf(stderr, "docs: unexpected argument %q\n", fs.Arg(0))
+			return 2
+		}
+		templatePath = fs.Arg(0)
+		args = fs.Args()[1:]
+	}
+	if templatePath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	docx, err := os.ReadFile(templatePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "docs: %v\n", err)
+		return 1
+	}
+	var schema stencil.ValidationSchema
+	if *schemaPath != "" {
+		content, err := os.ReadFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "docs: %v\n", err)
+			return 1
+		}
+		if err := json.Unmarshal(content, &schema); err != nil {
+			fmt.Fprintf(stderr, "docs: invalid schema %s: %v\n", *schemaPath, err)
+			return 1
+		}
+	}
+
+	dictionary, err := stencil.BuildDataDictionary(stencil.DataDictionaryInput{DocxBytes: docx, Schema: schema})
+	if err != nil {
+		fmt.Fprintf(stderr, "docs: %s: %v\n", templatePath, err)
+		return 1
+	}
+	rows := dictionaryRows(dictionary, *schemaPath != "")
+	title := filepath.Base(templatePath)
+
+	switch ext := strings.ToLower(filepath.Ext(*outPath)); {
+	case *outPath == "":
+		writeMarkdownDictionary(stdout, title, rows)
+		return 0
+	case ext == ".md":
+		var b strings.Builder
+		writeMarkdownDictionary(&b, title, rows)
+		err = os.WriteFile(*outPath, []byte(b.String()), 0o644)
+	case ext == ".xlsx":
+		var content []byte
+		content, err = buildXLSX("Fields", append([][]string{dictionaryColumns}, rows...))
+		if err == nil {
+			err = os.WriteFile(*outPath, content, 0o644)
+		}
+	default:
+		fmt.Fprintf(stderr, "docs: unsupported output format %q, use .md or .xlsx\n", ext)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "docs: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Wrote %d fields to %s\n", len(rows), *outPath)
+	return 0
+}
+
+// dictionaryRows returns one row of cells per field. With a schema, fields
+// it does not describe are marked.
+func dictionaryRows(dictionary stencil.DataDictionaryResult, hasSchema bool) [][]string {
+	rows := make([][]string, 0, len(dictionary.Fields))
+	for _, field := range dictionary.Fields {
+		typ := field.Type
+		switch {
+		case !field.InSchema && hasSchema:
+			typ = "(not in schema)"
+		case field.Collection:
+			typ = "list of " + typ
+		}
+		var example string
+		switch v := field.Example.(type) {
+		case nil:
+		case string:
+			example = v
+		default:
+			encoded, _ := json.Marshal(v)
+			example = string(encoded)
+		}
+		locations := make([]string, 0, len(field.Locations))
+		for _, location := range field.Locations {
+			locations = append(locations, describeLocation(location))
+		}
+		rows = append(rows, []string{field.Path, typ, field.Description, example, strings.Join(locations, "; ")})
+	}
+	return rows
+}
+
+// describeLocation names a template location for readers of the document,
+// such as "Header 1, paragraph 2".
+func describeLocation(location stencil.TemplateLocation) string {
+	part := location.Part
+	if part == "word/document.xml" {
+		part = "Body"
+	} else if matches := storyPartPattern.FindStringSubmatch(part); matches != nil {
+		part = strings.ToUpper(matches[1][:1]) + matches[1][1:] + " " + matches[2]
+	}
+	return fmt.Sprintf("%s, paragraph %d", part, location.ParagraphIndex+1)
+}
+
+func writeMarkdownDictionary(w io.Writer, title string, rows [][]string) {
+	fmt.Fprintf(w, "# Data dictionary: %s\n\n", title)
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "The template has no placeholders.")
+		return
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(dictionaryColumns, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(dictionaryColumns)))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cell = strings.NewReplacer("|", `\|`, "\n", " ").Replace(cell)
+			if i == 0 {
+				cell = "`" + cell + "`"
+			}
+			cells[i] = cell
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDocs(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "letter.docx")
+	writeTestDOCX(t, templatePath, `<w:p><w:r><w:t>Dear {{customer.name}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{for item in items}}{{item.price}}{{end}}</w:t></w:r></w:p>`)
+	schemaPath := filepath.Join(dir, "schema.json")
+	schema := `{"fields": [{"path": "customer.name", "type": "string", "description": "Name | title", "example": "Ada"},` +
+		`{"path": "items.price", "type": "number", "example": 9.5}]}`
+	if err := os.WriteFile(schemaPath, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"docs", templatePath, "--schema", schemaPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	for _, want := range []string{
+		"# Data dictionary: letter.docx",
+		"| `customer.name` | string | Name \\| title | Ada | Body, paragraph 1 |",
+		"| `items` | (not in schema) |  |  | Body, paragraph 2 |",
+		"| `items.price` | number |  | 9.5 | Body, paragraph 2 |",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("markdown output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	xlsxPath := filepath.Join(dir, "fields.xlsx")
+	stdout.Reset()
+	if code := run([]string{"docs", "--out", xlsxPath, templatePath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	reader, err := zip.OpenReader(xlsxPath)
+	if err != nil {
+		t.Fatalf("output is not a zip package: %v", err)
+	}
+	defer reader.Close()
+	var sheet string
+	for _, f := range reader.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			b.ReadFrom(rc)
+			rc.Close()
+			sheet = b.String()
+		}
+	}
+	for _, want := range []string{`<c r="A1" t="inlineStr" s="1">`, `>customer.name<`, `<c r="E4"`} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %q: %s", want, sheet)
+		}
+	}
+
+	stderr.Reset()
+	if code := run([]string{"docs", templatePath, "--out", filepath.Join(dir, "fields.pdf")}, &stdout, &stderr); code != 2 {
+		t.Errorf("unsupported format: exit code = %d, want 2", code)
+	}
+	if code := run([]string{"docs"}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing template: exit code = %d, want 2", code)
+	}
+}
+
+func writeTestDOCX(t *testing.T, path, bodyXML string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships>`,
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + bodyXML + `</w:body></w:document>`,
+	}
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
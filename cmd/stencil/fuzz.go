@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// runFuzz implements "stencil fuzz <template> [--schema file] [--runs n]
+// [--seed n] [--save dir]".
+func runFuzz(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fuzz", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "JSON schema of the data to generate (default: inferred from the template)")
+	runs := fs.Int("runs", 100, "number of renders")
+	seed := fs.Int64("seed", 1, "seed of the data of the first render")
+	saveDir := fs.String("save", "", "directory to write a case file for each failure to, for stencil test")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil fuzz <template> [--schema schema.json] [--runs n] [--seed n] [--save dir]")
+		fs.PrintDefaults()
+	}
+
+	// Flags may follow the template path.
+	var templatePath string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if templatePath != "" {
+			fmt.Fprintf(stderr, "fuzz: unexpected argument %q\n", fs.Arg(0))
+			return 2
+		}
+		templatePath = fs.Arg(0)
+		args = fs.Args()[1:]
+	}
+	if templatePath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	var schema stencil.ValidationSchema
+	if *schemaPath != "" {
+		content, err := os.ReadFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "fuzz: %v\n", err)
+			return 1
+		}
+		if err := json.Unmarshal(content, &schema); err != nil {
+			fmt.Fprintf(stderr, "fuzz: invalid schema %s: %v\n", *schemaPath, err)
+			return 1
+		}
+	}
+
+	tmpl, err := stencil.PrepareFile(templatePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "fuzz: %s: %v\n", templatePath, err)
+		return 1
+	}
+	defer tmpl.Close()
+	report, err := tmpl.Fuzz(stencil.FuzzOptions{Schema: schema, Runs: *runs, Seed: *seed})
+	if err != nil {
+		fmt.Fprintf(stderr, "fuzz: %s: %v\n", templatePath, err)
+		return 1
+	}
+
+	// Failures of the same kind are reported once, with the first seed
+	// that caused it
+	var first []stencil.FuzzFailure
+	counts := make(map[string]int)
+	for _, failure := range report.Failures {
+		if counts[failure.Kind] == 0 {
+			first = append(first, failure)
+		}
+		counts[failure.Kind]++
+	}
+	for _, failure := range first {
+		fmt.Fprintf(stdout, "FAIL  seed %d: %s (%d of %d runs)\n", failure.Seed, failure.Error, counts[failure.Kind], report.Runs)
+		if *saveDir == "" {
+			continue
+		}
+		path, err := saveFuzzCase(*saveDir, templatePath, failure)
+		if err != nil {
+			fmt.Fprintf(stderr, "fuzz: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "      wrote %s\n", path)
+	}
+	fmt.Fprintf(stdout, "%d runs: %d passed, %d failed\n", report.Runs, report.Runs-len(report.Failures), len(report.Failures))
+	if !report.OK() {
+		if *schemaPath == "" {
+			fmt.Fprintln(stdout, "The data was generated from a schema inferred from the template; pass --schema to generate data of the right types.")
+		}
+		return 1
+	}
+	return 0
+}
+
+// saveFuzzCase writes the data of a failure as a case file of the
+// template, named so stencil test finds its template.
+func saveFuzzCase(dir, templatePath string, failure stencil.FuzzFailure) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(templatePath), filepath.Ext(templatePath))
+	path := filepath.Join(dir, fmt.Sprintf("%s.fuzz-%d.json", name, failure.Seed))
+	content, err := json.MarshalIndent(map[string]interface{}{"data": failure.Data}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(content, '\n'), 0o644)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFuzz(t *testing.T) {
+	dir := t.TempDir()
+	letter := filepath.Join(dir, "letter.docx")
+	writeTestDOCX(t, letter, `<w:p><w:r><w:t xml:space="preserve">Dear {{name}}, {{for line in lines}}{{line}} {{end}}</w:t></w:r></w:p>`)
+	report := filepath.Join(dir, "report.docx")
+	writeTestDOCX(t, report, `<w:p><w:r><w:t xml:space="preserve">Average: {{total / count}}</w:t></w:r></w:p>`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fuzz", letter, "--runs", "20"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "20 runs: 20 passed, 0 failed") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	cases := filepath.Join(dir, "cases")
+	stdout.Reset()
+	if code := run([]string{"fuzz", report, "--seed", "1000", "--save", cases}, &stdout, &stderr); code != 1 {
+		t.Fatalf("run() exit code = %d, want 1:\n%s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "division by zero") {
+		t.Errorf("output missing the division by zero:\n%s", stdout.String())
+	}
+	saved, err := filepath.Glob(filepath.Join(cases, "report.fuzz-*.json"))
+	if err != nil || len(saved) != 1 {
+		t.Fatalf("saved cases = %v, %v; want one", saved, err)
+	}
+
+	// The saved case reproduces the failure with stencil test
+	if err := os.Remove(letter); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if code := run([]string{"test", dir, "--cases", saved[0]}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), "division by zero") {
+		t.Errorf("stencil test on the saved case: exit code = %d:\n%s", code, stdout.String())
+	}
+
+	if code := run([]string{"fuzz"}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing template: exit code = %d, want 2", code)
+	}
+}
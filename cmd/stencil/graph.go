@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// runGraph implements "stencil graph <template> [--fragments dir]
+// [--format dot|json] [--out file]".
+func runGraph(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	fragmentsDir := flags.String("fragments", "", "directory of .docx and text fragments, named by their path without extension")
+	format := flags.String("format", "", "output format, dot or json (default: from --out, else dot)")
+	outPath := flags.String("out", "", "output file (default: stdout)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil graph <template> [--fragments dir] [--format dot|json] [--out file]")
+		flags.PrintDefaults()
+	}
+
+	// Flags may follow the template path.
+	var templatePath string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return 2
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		if templatePath != "" {
+			fmt.Fprintf(stderr, "graph: unexpected argument %q\n", flags.Arg(0))
+			return 2
+		}
+		templatePath = flags.Arg(0)
+		args = flags.Args()[1:]
+	}
+	if templatePath == "" {
+		flags.Usage()
+		return 2
+	}
+	if *format == "" {
+		*format = "dot"
+		if strings.EqualFold(filepath.Ext(*outPath), ".json") {
+			*format = "json"
+		}
+	}
+	if *format != "dot" && *format != "json" {
+		fmt.Fprintf(stderr, "graph: unsupported format %q, use dot or json\n", *format)
+		return 2
+	}
+
+	docx, err := os.ReadFile(templatePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "graph: %v\n", err)
+		return 1
+	}
+	var fragments map[string][]byte
+	if *fragmentsDir != "" {
+		if fragments, err = readFragmentsDir(*fragmentsDir); err != nil {
+			fmt.Fprintf(stderr, "graph: %v\n", err)
+			return 1
+		}
+	}
+	graph, err := stencil.BuildDependencyGraph(stencil.DependencyGraphInput{
+		DocxBytes: docx,
+		Name:      filepath.Base(templatePath),
+		Fragments: fragments,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "graph: %s: %v\n", templatePath, err)
+		return 1
+	}
+
+	var output []byte
+	if *format == "json" {
+		if output, err = json.MarshalIndent(graph, "", "  "); err != nil {
+			fmt.Fprintf(stderr, "graph: %v\n", err)
+			return 1
+		}
+		output = append(output, '\n')
+	} else {
+		output = []byte(graph.DOT())
+	}
+	if *outPath == "" {
+		stdout.Write(output)
+		return 0
+	}
+	if err := os.WriteFile(*outPath, output, 0o644); err != nil {
+		fmt.Fprintf(stderr, "graph: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Wrote %d nodes and %d edges to %s\n", len(graph.Nodes), len(graph.Edges), *outPath)
+	return 0
+}
+
+// readFragmentsDir reads the fragments in dir and its subdirectories. A
+// fragment is named by its path relative to dir without extension, as in
+// "terms/short" for terms/short.docx. Hidden files are skipped.
+func readFragmentsDir(dir string) (map[string][]byte, error) {
+	fragments := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != dir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fragments[filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))] = content
+		return nil
+	})
+	return fragments, err
+}
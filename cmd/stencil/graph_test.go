@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+func TestRunGraph(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "contract.docx")
+	writeTestDOCX(t, templatePath, `<w:p><w:r><w:t>{{party.name}} {{include "terms/short"}} {{include "annex"}}</w:t></w:r></w:p>`)
+	fragmentsDir := filepath.Join(dir, "fragments")
+	if err := os.MkdirAll(filepath.Join(fragmentsDir, "terms"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestDOCX(t, filepath.Join(fragmentsDir, "terms", "short.docx"), `<w:p><w:r><w:t>Due {{formatDate(dueDate, "2006-01-02")}}</w:t></w:r></w:p>`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"graph", templatePath, "--fragments", fragmentsDir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+	for _, want := range []string{
+		`"template" [label="contract.docx"`,
+		`"template" -> "fragment:terms/short" [label="includes"];`,
+		`"fragment:terms/short" -> "field:dueDate" [label="reads", style=dashed];`,
+		`"fragment:annex" [label="annex", shape=component, style=dashed, color=red];`,
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("DOT output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	outPath := filepath.Join(dir, "graph.json")
+	stdout.Reset()
+	if code := run([]string{"graph", templatePath, "--fragments", fragmentsDir, "--out", outPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+	var graph stencil.DependencyGraph
+	if err := readJSONFile(outPath, &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 6 || !strings.Contains(stdout.String(), "Wrote 6 nodes") {
+		encoded, _ := json.Marshal(graph)
+		t.Errorf("unexpected graph %s, output %q", encoded, stdout.String())
+	}
+
+	if code := run([]string{"graph", templatePath, "--format", "svg"}, &stdout, &stderr); code != 2 {
+		t.Errorf("unsupported format: exit code = %d, want 2", code)
+	}
+}
@@ -23,9 +23,24 @@ func run(args []string, stdout, stderr io.Writer) int {
 	case "version":
 		fmt.Fprintf(stdout, "go-stencil version %s\n", version.Details())
 		return 0
+	case "docs":
+		return runDocs(args[1:], stdout, stderr)
+	case "fuzz":
+		return runFuzz(args[1:], stdout, stderr)
+	case "graph":
+		return runGraph(args[1:], stdout, stderr)
+	case "mergefields":
+		return runMergeFields(args[1:], stdout, stderr)
+	case "migrate":
+		return runMigrate(args[1:], stdout, stderr)
+	case "optimize":
+		return runOptimize(args[1:], stdout, stderr)
+	case "pack":
+		return runPack(args[1:], stdout, stderr)
+	case "test":
+		return runTest(args[1:], stdout, stderr)
 	case "render":
-		fmt.Fprintln(stdout, "Render command not yet implemented")
-		return 0
+		return runRender(args[1:], stdout, stderr)
 	default:
 		fmt.Fprintf(stderr, "unknown command: %s\n\n", args[0])
 		printUsage(stderr)
@@ -42,6 +57,14 @@ func printUsage(w io.Writer) {
 	fmt.Fprintln(w, "Usage: stencil <command> [arguments]")
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  docs <template>             Export a data dictionary of the placeholders")
+	fmt.Fprintln(w, "  fuzz <template>             Render a template with generated edge-case data")
+	fmt.Fprintln(w, "  graph <template>            Export the includes, fields and functions a template depends on")
+	fmt.Fprintln(w, "  mergefields export|import   Convert placeholders to Word MERGEFIELD fields and back")
+	fmt.Fprintln(w, "  migrate <from> <in> <out>   Convert a docxtpl or docxtemplater template to go-stencil")
+	fmt.Fprintln(w, "  optimize <in> <out>         Write a smaller, faster-to-render copy of a template")
+	fmt.Fprintln(w, "  pack build|inspect          Bundle a template with its fragments and data into a .stencilpack")
 	fmt.Fprintln(w, "  render <template> <data>    Render a template with data")
+	fmt.Fprintln(w, "  test <templates>            Render templates against their case files")
 	fmt.Fprintln(w, "  version                     Show version information")
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// runMergeFields implements "stencil mergefields <export|import> <in.docx>
+// <out.docx>".
+func runMergeFields(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mergefields", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil mergefields <export|import> <in.docx> <out.docx>")
+		fmt.Fprintln(stderr, "  export  convert placeholders into Word MERGEFIELD fields")
+		fmt.Fprintln(stderr, "  import  convert Word MERGEFIELD fields into placeholders")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return 2
+	}
+	convert := stencil.ExportMergeFields
+	switch fs.Arg(0) {
+	case "export":
+	case "import":
+		convert = stencil.ImportMergeFields
+	default:
+		fs.Usage()
+		return 2
+	}
+	inPath, outPath := fs.Arg(1), fs.Arg(2)
+
+	docx, err := os.ReadFile(inPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "mergefields: %v\n", err)
+		return 1
+	}
+	converted, report, err := convert(docx)
+	if err != nil {
+		fmt.Fprintf(stderr, "mergefields: %s: %v\n", inPath, err)
+		return 1
+	}
+	if err := os.WriteFile(outPath, converted, 0o644); err != nil {
+		fmt.Fprintf(stderr, "mergefields: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Wrote %s: %d fields converted\n", outPath, report.Converted)
+	for _, name := range report.Fields {
+		fmt.Fprintf(stdout, "  %s\n", name)
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(stdout, "Left unchanged (%d):\n", len(report.Skipped))
+		for _, skipped := range report.Skipped {
+			fmt.Fprintf(stdout, "  %s\n", skipped)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunMergeFields(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "letter.docx")
+	mailMergePath := filepath.Join(dir, "letter.mailmerge.docx")
+	roundTripPath := filepath.Join(dir, "letter.roundtrip.docx")
+	writeTestDOCX(t, templatePath, `<w:p><w:r><w:t xml:space="preserve">Dear {{name}}, {{if vip}}welcome back{{end}}</w:t></w:r></w:p>`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"mergefields", "export", templatePath, mailMergePath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	for _, want := range []string{"1 fields converted", "  name", "Left unchanged (2):", "  {{if vip}}"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("export output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"mergefields", "import", mailMergePath, roundTripPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 fields converted") {
+		t.Errorf("import output = %q", stdout.String())
+	}
+
+	if code := run([]string{"mergefields", "convert", templatePath, mailMergePath}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown direction: exit code = %d, want 2", code)
+	}
+	if code := run([]string{"mergefields", "export", templatePath}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing output path: exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// runMigrate implements "stencil migrate <docxtpl|docxtemplater> <in.docx>
+// <out.docx>".
+func runMigrate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil migrate <docxtpl|docxtemplater> <in.docx> <out.docx>")
+		fmt.Fprintln(stderr, "  docxtpl        convert a docxtpl (Jinja) template into go-stencil syntax")
+		fmt.Fprintln(stderr, "  docxtemplater  convert a docxtemplater template into go-stencil syntax")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return 2
+	}
+	syntax := stencil.TemplateSyntax(fs.Arg(0))
+	if syntax != stencil.SyntaxDocxtpl && syntax != stencil.SyntaxDocxtemplater {
+		fs.Usage()
+		return 2
+	}
+	inPath, outPath := fs.Arg(1), fs.Arg(2)
+
+	docx, err := os.ReadFile(inPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate: %v\n", err)
+		return 1
+	}
+	converted, report, err := stencil.ConvertTemplateSyntax(docx, syntax)
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate: %s: %v\n", inPath, err)
+		return 1
+	}
+	if err := os.WriteFile(outPath, converted, 0o644); err != nil {
+		fmt.Fprintf(stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Wrote %s: %d tags converted\n", outPath, report.Converted)
+	if len(report.Warnings) > 0 {
+		fmt.Fprintf(stdout, "Review (%d):\n", len(report.Warnings))
+		for _, warning := range report.Warnings {
+			fmt.Fprintf(stdout, "  %s\n", warning)
+		}
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(stdout, "Left unchanged (%d):\n", len(report.Skipped))
+		for _, skipped := range report.Skipped {
+			fmt.Fprintf(stdout, "  %s\n", skipped)
+		}
+	}
+	return 0
+}
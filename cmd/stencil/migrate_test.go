@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunMigrate(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "letter.docx")
+	outPath := filepath.Join(dir, "letter.stencil.docx")
+	writeTestDOCX(t, templatePath, `<w:p><w:r><w:t xml:space="preserve">Dear {{ name|upper }}, {%r if vip %}welcome back{%r endif %} {{ loop.index }}</w:t></w:r></w:p>`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"migrate", "docxtpl", templatePath, outPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	for _, want := range []string{"3 tags converted", "Left unchanged (1):", "  {{ loop.index }}"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	if code := run([]string{"migrate", "mustache", templatePath, outPath}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown syntax: exit code = %d, want 2", code)
+	}
+	if code := run([]string{"migrate", "docxtpl", templatePath}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing output path: exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// keepStylesFlag collects the repeated --keep-style flag.
+type keepStylesFlag []string
+
+func (f *keepStylesFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *keepStylesFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runOptimize implements "stencil optimize <in.docx> <out.docx> [--keep-style id]".
+func runOptimize(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("optimize", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var keepStyles keepStylesFlag
+	fs.Var(&keepStyles, "keep-style", "style ID to keep although the template does not use it (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil optimize <in.docx> <out.docx> [--keep-style id]")
+		fs.PrintDefaults()
+	}
+
+	// Flags may follow the paths.
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	docx, err := os.ReadFile(paths[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "optimize: %v\n", err)
+		return 1
+	}
+	optimized, report, err := stencil.OptimizeTemplate(docx, stencil.OptimizeOptions{KeepStyles: keepStyles})
+	if err != nil {
+		fmt.Fprintf(stderr, "optimize: %s: %v\n", paths[0], err)
+		return 1
+	}
+	if err := os.WriteFile(paths[1], optimized, 0o644); err != nil {
+		fmt.Fprintf(stderr, "optimize: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Wrote %s: %d -> %d bytes\n", paths[1], report.InputSize, report.OutputSize)
+	fmt.Fprintf(stdout, "  merged runs:           %d\n", report.MergedRuns)
+	fmt.Fprintf(stdout, "  removed rsids:         %d\n", report.RemovedRsids)
+	fmt.Fprintf(stdout, "  removed proof errors:  %d\n", report.RemovedProofErrors)
+	fmt.Fprintf(stdout, "  normalized tags:       %d\n", report.NormalizedTags)
+	fmt.Fprintf(stdout, "  removed styles:        %d\n", len(report.RemovedStyles))
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunOptimize(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "letter.docx")
+	outPath := filepath.Join(dir, "letter.min.docx")
+	writeTestDOCX(t, inPath, `<w:p w:rsidR="00A1B2C3"><w:r><w:t>Dear {{</w:t></w:r><w:proofErr w:type="spellStart"/>`+
+		`<w:r w:rsidRPr="00445566"><w:t>name</w:t></w:r><w:proofErr w:type="spellEnd"/><w:r><w:t>}}</w:t></w:r></w:p>`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"optimize", inPath, outPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	for _, want := range []string{"Wrote " + outPath, "merged runs:           2", "removed proof errors:  2"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, stdout.String())
+		}
+	}
+	if _, err := os.Stat(outPath); err != nil {
+		t.Errorf("optimized template not written: %v", err)
+	}
+
+	if code := run([]string{"optimize", inPath}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing output path: exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// keyValueFlag collects a repeated key=value flag.
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[key] = val
+	return nil
+}
+
+// runPack implements "stencil pack <build|inspect> ...".
+func runPack(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "build":
+			return runPackBuild(args[1:], stdout, stderr)
+		case "inspect":
+			return runPackInspect(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintln(stderr, "Usage: stencil pack <build|inspect> [arguments]")
+	fmt.Fprintln(stderr, "  build <template.docx> --out <file.stencilpack>  bundle a template with its fragments, schema and sample data")
+	fmt.Fprintln(stderr, "  inspect <file.stencilpack>                      show the content of a pack and check it")
+	return 2
+}
+
+// runPackBuild implements "stencil pack build <template.docx> --out file
+// [--fragment name=path] [--schema file] [--data file] [--name name]
+// [--version version] [--description text] [--meta key=value]".
+func runPackBuild(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pack build", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	outPath := fs.String("out", "", "pack file to write (default: <template>.stencilpack)")
+	schemaPath := fs.String("schema", "", "JSON schema of the template data")
+	dataPath := fs.String("data", "", "JSON sample data")
+	name := fs.String("name", "", "name of the pack")
+	version := fs.String("version", "", "version of the pack")
+	description := fs.String("description", "", "description of the pack")
+	fragments := keyValueFlag{}
+	fs.Var(fragments, "fragment", "fragment as name=path to a .docx or text file (repeatable)")
+	metadata := keyValueFlag{}
+	fs.Var(metadata, "meta", "metadata as key=value (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil pack build <template.docx> [--out file] [--fragment name=path] [--schema file] [--data file] [--name name] [--version version] [--meta key=value]")
+		fs.PrintDefaults()
+	}
+
+	// Flags may follow the template path.
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 1 {
+		fs.Usage()
+		return 2
+	}
+	templatePath := paths[0]
+	if *outPath == "" {
+		*outPath = strings.TrimSuffix(templatePath, ".docx") + ".stencilpack"
+	}
+
+	pack := &stencil.TemplatePack{
+		Manifest: stencil.PackManifest{
+			Name:        *name,
+			Version:     *version,
+			Description: *description,
+		},
+		Fragments: make(map[string][]byte, len(fragments)),
+	}
+	if len(metadata) > 0 {
+		pack.Manifest.Metadata = metadata
+	}
+	var err error
+	if pack.Template, err = os.ReadFile(templatePath); err != nil {
+		fmt.Fprintf(stderr, "pack: %v\n", err)
+		return 1
+	}
+	for fragmentName, path := range fragments {
+		if pack.Fragments[fragmentName], err = os.ReadFile(path); err != nil {
+			fmt.Fprintf(stderr, "pack: %v\n", err)
+			return 1
+		}
+	}
+	if *schemaPath != "" {
+		pack.Schema = &stencil.ValidationSchema{}
+		if err := readJSONFile(*schemaPath, pack.Schema); err != nil {
+			fmt.Fprintf(stderr, "pack: %v\n", err)
+			return 1
+		}
+	}
+	if *dataPath != "" {
+		if err := readJSONFile(*dataPath, &pack.SampleData); err != nil {
+			fmt.Fprintf(stderr, "pack: %v\n", err)
+			return 1
+		}
+	}
+
+	output, err := pack.Bytes()
+	if err != nil {
+		fmt.Fprintf(stderr, "pack: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*outPath, output, 0o644); err != nil {
+		fmt.Fprintf(stderr, "pack: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Wrote %s (%d bytes): template, %d fragments\n", *outPath, len(output), len(pack.Fragments))
+	return 0
+}
+
+// runPackInspect implements "stencil pack inspect <file.stencilpack>". It
+// prints the manifest and content of the pack, prepares its template and
+// checks the sample data against the schema.
+func runPackInspect(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pack inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil pack inspect <file.stencilpack>")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	pack, err := stencil.ReadPackFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "pack: %s: %v\n", path, err)
+		return 1
+	}
+	m := pack.Manifest
+	fmt.Fprintf(stdout, "Pack:         %s\n", strings.TrimSpace(m.Name+" "+m.Version))
+	if m.Description != "" {
+		fmt.Fprintf(stdout, "Description:  %s\n", m.Description)
+	}
+	fmt.Fprintf(stdout, "Format:       %d\n", m.FormatVersion)
+	fmt.Fprintf(stdout, "Template:     %s (%d bytes)\n", m.Template, len(pack.Template))
+	fmt.Fprintf(stdout, "Fragments:    %d\n", len(pack.Fragments))
+	for _, name := range sortedKeys(m.Fragments) {
+		fmt.Fprintf(stdout, "  %-20s %s (%d bytes)\n", name, m.Fragments[name], len(pack.Fragments[name]))
+	}
+	if pack.Schema != nil {
+		fmt.Fprintf(stdout, "Schema:       %d fields\n", len(pack.Schema.Fields))
+	}
+	if pack.SampleData != nil {
+		fmt.Fprintf(stdout, "Sample data:  %d top-level fields\n", len(pack.SampleData))
+	}
+	if len(m.Metadata) > 0 {
+		fmt.Fprintln(stdout, "Metadata:")
+		for _, key := range sortedKeys(m.Metadata) {
+			fmt.Fprintf(stdout, "  %-20s %s\n", key, m.Metadata[key])
+		}
+	}
+
+	tmpl, err := stencil.PreparePack(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "pack: %s: %v\n", path, err)
+		return 1
+	}
+	tmpl.Close()
+
+	if pack.Schema != nil && pack.SampleData != nil {
+		if issues := pack.Schema.ValidateData(pack.SampleData); len(issues) > 0 {
+			fmt.Fprintf(stdout, "Sample data does not match the schema (%d issues):\n", len(issues))
+			for _, issue := range issues {
+				fmt.Fprintf(stdout, "  %s: %s\n", issue.Path, issue.Message)
+			}
+			return 1
+		}
+	}
+	fmt.Fprintln(stdout, "OK")
+	return 0
+}
+
+// readJSONFile decodes the JSON file at path into v.
+func readJSONFile(path string, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
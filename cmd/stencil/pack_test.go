@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPackBuildAndInspect(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "letter.docx")
+	writeTestDOCX(t, templatePath, `<w:p><w:r><w:t>Dear {{name}}, {{include "closing"}}</w:t></w:r></w:p>`)
+	closingPath := filepath.Join(dir, "closing.txt")
+	schemaPath := filepath.Join(dir, "schema.json")
+	dataPath := filepath.Join(dir, "data.json")
+	for path, content := range map[string]string{
+		closingPath: "Kind regards",
+		schemaPath:  `{"fields": [{"path": "name", "type": "string", "required": true}]}`,
+		dataPath:    `{"name": "Ada"}`,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	packPath := filepath.Join(dir, "letter.stencilpack")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"pack", "build", templatePath, "--fragment", "closing=" + closingPath, "--schema", schemaPath,
+		"--data", dataPath, "--name", "letter", "--version", "2.0.1", "--meta", "owner=legal"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("build exit code = %d, stderr = %q", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Wrote "+packPath) {
+		t.Errorf("expected the default pack path, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"pack", "inspect", packPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("inspect exit code = %d, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{"Pack:         letter 2.0.1", "closing", "fragments/closing.txt", "Schema:       1 fields", "owner", "OK"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("inspect output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	if err := os.WriteFile(dataPath, []byte(`{"name": 42}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"pack", "build", templatePath, "--out", packPath, "--schema", schemaPath, "--data", dataPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("build exit code = %d, stderr = %q", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"pack", "inspect", packPath}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected sample data that does not match the schema to fail, got exit code %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Sample data does not match the schema") {
+		t.Errorf("expected the schema issues, got %q", stdout.String())
+	}
+
+	if code := run([]string{"pack", "unpack"}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown subcommand: exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// heapSampleInterval is how often a profiled render samples the heap for
+// its peak size.
+const heapSampleInterval = 2 * time.Millisecond
+
+// runRender implements "stencil render <template> <data.json> [--out file]
+// [--profile]".
+func runRender(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	outPath := fs.String("out", "", "output file (default: <template>-rendered.docx)")
+	profile := fs.Bool("profile", false, "report the time, CPU and memory the render takes, by phase")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: stencil render <template> <data.json> [--out file] [--profile]")
+		fs.PrintDefaults()
+	}
+
+	// Flags may follow the paths.
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 2 {
+		fs.Usage()
+		return 2
+	}
+	templatePath, dataPath := paths[0], paths[1]
+	if *outPath == "" {
+		*outPath = strings.TrimSuffix(templatePath, filepath.Ext(templatePath)) + "-rendered.docx"
+	}
+
+	content, err := os.ReadFile(dataPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "render: %v\n", err)
+		return 1
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		fmt.Fprintf(stderr, "render: invalid data %s: %v\n", dataPath, err)
+		return 1
+	}
+
+	tracer := &profileTracer{durations: make(map[string]time.Duration), counts: make(map[string]int)}
+	var usage *resourceUsage
+	if *profile {
+		usage = startResourceUsage()
+	}
+	output, err := renderFile(stencil.NewWithOptions(stencil.WithTracer(tracer)), templatePath, data)
+	if usage != nil {
+		usage.stop()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "render: %s: %v\n", templatePath, err)
+		return 1
+	}
+	if err := os.WriteFile(*outPath, output, 0o644); err != nil {
+		fmt.Fprintf(stderr, "render: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Wrote %s (%d bytes)\n", *outPath, len(output))
+	if *profile {
+		writeProfile(stdout, tracer, usage)
+	}
+	return 0
+}
+
+// renderFile prepares the template at path with engine and renders data.
+func renderFile(engine *stencil.Engine, path string, data map[string]interface{}) ([]byte, error) {
+	defer engine.Close()
+	tmpl, err := engine.PrepareFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer tmpl.Close()
+	reader, err := tmpl.Render(stencil.TemplateData(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// writeProfile prints the phases of a render and its resource usage.
+func writeProfile(w io.Writer, tracer *profileTracer, usage *resourceUsage) {
+	d := tracer.durations
+	row := func(indent int, label string, duration time.Duration, note string) {
+		fmt.Fprintf(w, "%s%-*s %10s", strings.Repeat("  ", indent), 30-2*indent, label, formatDuration(duration))
+		if note != "" {
+			fmt.Fprintf(w, "  %s", note)
+		}
+		fmt.Fprintln(w)
+	}
+	// Phases are what their span covers without the spans inside it
+	document := d["stencil.render.document"]
+	parts := d["stencil.render.part"] + d["stencil.render.header_footer"]
+	render := d["stencil.render"]
+
+	fmt.Fprintln(w, "Profile:")
+	row(1, "parse", d["stencil.prepare"], "")
+	row(1, "render", render, "")
+	var includes string
+	if n := tracer.counts["stencil.include"]; n > 0 {
+		includes = fmt.Sprintf("including %d fragment includes", n)
+	}
+	row(2, "control structures", d["stencil.render.body"], includes)
+	row(2, "tables", d["stencil.render.tables"], "")
+	row(2, "document processing", document-d["stencil.render.body"]-d["stencil.render.tables"], "")
+	row(2, "headers, footers, notes", parts, "")
+	row(2, "zip", d["stencil.assemble"], "")
+	row(2, "other", render-document-parts-d["stencil.assemble"], "")
+	row(1, "total", d["stencil.prepare"]+render, "")
+
+	fmt.Fprintln(w, "Resources:")
+	if usage.cpu >= 0 {
+		row(1, "CPU time", usage.cpu, "")
+	}
+	fmt.Fprintf(w, "  %-30s %10s\n", "peak heap", formatBytes(usage.peakHeap))
+	fmt.Fprintf(w, "  %-30s %10d  (%s)\n", "allocations", usage.mallocs, formatBytes(usage.allocated))
+	fmt.Fprintf(w, "  %-30s %10d\n", "garbage collections", usage.gcs)
+}
+
+// formatDuration formats d in milliseconds with a precision that suits
+// render phases.
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// formatBytes formats a byte count in KB or MB.
+func formatBytes(n uint64) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+}
+
+// profileTracer is a stencil.Tracer that sums the durations of spans by
+// name.
+type profileTracer struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+	counts    map[string]int
+}
+
+func (t *profileTracer) Start(ctx context.Context, name string) (context.Context, stencil.Span) {
+	return ctx, &profileSpan{tracer: t, name: name, start: time.Now()}
+}
+
+type profileSpan struct {
+	tracer *profileTracer
+	name   string
+	start  time.Time
+}
+
+func (s *profileSpan) SetAttribute(string, interface{}) {}
+
+func (s *profileSpan) End(error) {
+	elapsed := time.Since(s.start)
+	s.tracer.mu.Lock()
+	s.tracer.durations[s.name] += elapsed
+	s.tracer.counts[s.name]++
+	s.tracer.mu.Unlock()
+}
+
+// resourceUsage measures the CPU time, allocations and peak heap size of
+// the process between startResourceUsage and stop.
+type resourceUsage struct {
+	cpu       time.Duration // -1 where the platform does not report it
+	peakHeap  uint64
+	mallocs   uint64
+	allocated uint64
+	gcs       uint32
+
+	before   runtime.MemStats
+	cpuStart time.Duration
+	done     chan struct{}
+	sampled  chan struct{}
+}
+
+func startResourceUsage() *resourceUsage {
+	u := &resourceUsage{done: make(chan struct{}), sampled: make(chan struct{})}
+	runtime.GC()
+	runtime.ReadMemStats(&u.before)
+	u.peakHeap = u.before.HeapAlloc
+	u.cpuStart = processCPUTime()
+	go func() {
+		defer close(u.sampled)
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-u.done:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > u.peakHeap {
+					u.peakHeap = stats.HeapAlloc
+				}
+			}
+		}
+	}()
+	return u
+}
+
+func (u *resourceUsage) stop() {
+	cpu := processCPUTime()
+	close(u.done)
+	<-u.sampled
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > u.peakHeap {
+		u.peakHeap = after.HeapAlloc
+	}
+	u.cpu = -1
+	if cpu >= 0 {
+		u.cpu = cpu - u.cpuStart
+	}
+	u.mallocs = after.Mallocs - u.before.Mallocs
+	u.allocated = after.TotalAlloc - u.before.TotalAlloc
+	u.gcs = after.NumGC - u.before.NumGC
+}
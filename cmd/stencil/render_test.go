@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunRender(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "invoice.docx")
+	writeTestDOCX(t, templatePath, `<w:p><w:r><w:t xml:space="preserve">Invoice for {{customer}}</w:t></w:r></w:p>`+
+		`<w:tbl><w:tblGrid><w:gridCol w:w="4000"/></w:tblGrid>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for line in lines}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{line}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl><w:p/>`)
+	dataPath := filepath.Join(dir, "invoice.json")
+	if err := os.WriteFile(dataPath, []byte(`{"customer": "Acme", "lines": ["Bolts", "Nuts"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"render", templatePath, dataPath, "--profile"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+	output, err := os.ReadFile(filepath.Join(dir, "invoice-rendered.docx"))
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	doc, err := readRenderedDocument(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc.Text, "Invoice for Acme") || len(doc.Tables) != 1 || doc.Tables[0].Rows != 2 {
+		t.Errorf("rendered document = %+v", doc)
+	}
+	for _, want := range []string{"parse", "control structures", "tables", "zip", "total", "peak heap", "allocations"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("profile missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	out := filepath.Join(dir, "out.docx")
+	stdout.Reset()
+	if code := run([]string{"render", templatePath, dataPath, "--out", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() exit code = %d, stderr = %q", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Profile") {
+		t.Errorf("profile printed without --profile:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("output not written to --out: %v", err)
+	}
+
+	if code := run([]string{"render", templatePath}, &stdout, &stderr); code != 2 {
+		t.Errorf("missing data file: exit code = %d, want 2", code)
+	}
+}
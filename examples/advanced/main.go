@@ -641,7 +641,8 @@ func productionExample(engine *stencil.Engine) {
 	}
 
 	// The template contains a {{customText}} variable that can be replaced
-	// with any custom text or clause
+	// with any custom text or clause; {{paragraphs(customText)}} renders each
+	// block separated by a blank line as a paragraph of the same style
 	data := stencil.TemplateData{
 		"customText": `Sample text demonstrating template variable substitution.
 
@@ -0,0 +1,149 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addressPart is a field of an address line. The separator is written
+// before the field when the line already has text.
+type addressPart struct {
+	field     string
+	separator string
+}
+
+// addressLine is a line of an address block. Lines whose fields are all
+// empty are omitted.
+type addressLine []addressPart
+
+var (
+	addressCityStatePostal = addressLine{{"city", ""}, {"state", ", "}, {"postalCode", " "}}
+	addressCityStateSpaced = addressLine{{"city", ""}, {"state", " "}, {"postalCode", " "}}
+	addressPostalCity      = addressLine{{"postalCode", ""}, {"city", " "}}
+	addressPostalCityState = addressLine{{"postalCode", ""}, {"city", " "}, {"state", " "}}
+)
+
+// addressFormats holds the lines after the street of the countries whose
+// conventions differ from the default, keyed by ISO 3166 alpha-2 code.
+var addressFormats = map[string][]addressLine{
+	"AU": {addressCityStateSpaced},
+	"GB": {{{"city", ""}}, {{"postalCode", ""}}},
+	"IE": {{{"city", ""}}, {{"state", ""}}, {{"postalCode", ""}}},
+	"IT": {addressPostalCityState},
+	"ES": {addressPostalCityState},
+	"DE": {addressPostalCity},
+	"AT": {addressPostalCity},
+	"CH": {addressPostalCity},
+	"FR": {addressPostalCity},
+	"BE": {addressPostalCity},
+	"NL": {addressPostalCity},
+	"LU": {addressPostalCity},
+	"DK": {addressPostalCity},
+	"SE": {addressPostalCity},
+	"NO": {addressPostalCity},
+	"FI": {addressPostalCity},
+	"PL": {addressPostalCity},
+	"CZ": {addressPostalCity},
+	"PT": {addressPostalCity},
+	"HU": {{{"city", ""}}, {{"postalCode", ""}}},
+}
+
+// addressFieldAliases lists other keys accepted for address fields.
+var addressFieldAliases = map[string][]string{
+	"postalCode": {"zip", "postcode"},
+	"state":      {"region", "province"},
+	"street2":    {"line2"},
+}
+
+// addressBlock formats an address for addressBlock(addr, homeCountry): the
+// name, company and street lines followed by the city, state and postal
+// code as is customary in the address's country. Empty lines are omitted,
+// and so is the country when it is homeCountry. The country is read from
+// "countryCode", or from "country" when that is a two-letter code.
+func addressBlock(args ...interface{}) (interface{}, error) {
+	addr := args[0]
+	if addr == nil {
+		return nil, nil
+	}
+	switch addr.(type) {
+	case map[string]interface{}, map[string]string, TemplateData:
+	default:
+		return nil, fmt.Errorf("addressBlock: address must be a map, got %T", addr)
+	}
+
+	country := strings.ToUpper(addressField(addr, "countryCode"))
+	countryName := addressField(addr, "country")
+	if country == "" && len(countryName) == 2 {
+		country = strings.ToUpper(countryName)
+	}
+	home := ""
+	if len(args) > 1 && args[1] != nil {
+		home = strings.ToUpper(strings.TrimSpace(FormatValue(args[1])))
+	}
+
+	lines := []addressLine{{{"name", ""}}, {{"company", ""}}, {{"street", ""}}, {{"street2", ""}}}
+	if format, ok := addressFormats[country]; ok {
+		lines = append(lines, format...)
+	} else {
+		lines = append(lines, addressCityStatePostal)
+	}
+
+	var text []string
+	for _, line := range lines {
+		var b strings.Builder
+		for _, part := range line {
+			value := addressField(addr, part.field)
+			if value == "" {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString(part.separator)
+			}
+			b.WriteString(value)
+		}
+		if b.Len() > 0 {
+			text = append(text, b.String())
+		}
+	}
+	if countryName != "" && (home == "" || (home != country && !strings.EqualFold(home, countryName))) {
+		text = append(text, countryName)
+	}
+
+	if len(text) == 0 {
+		return nil, nil
+	}
+	return textLinesFragment(text), nil
+}
+
+// addressField returns the trimmed text of an address field, trying its
+// aliases when the field is missing.
+func addressField(addr interface{}, field string) string {
+	value := strings.TrimSpace(FormatValue(accessMapField(addr, field)))
+	for _, alias := range addressFieldAliases[field] {
+		if value != "" {
+			break
+		}
+		value = strings.TrimSpace(FormatValue(accessMapField(addr, alias)))
+	}
+	return value
+}
+
+// textLinesFragment returns lines of text separated by line breaks as a
+// run-level fragment that keeps the formatting of the expression.
+func textLinesFragment(lines []string) *OOXMLFragment {
+	parts := make([]interface{}, 0, 2*len(lines)-1)
+	for i, line := range lines {
+		if i > 0 {
+			parts = append(parts, &Break{})
+		}
+		parts = append(parts, NewRun(line, RunProps{}))
+	}
+	return Fragments(parts...)
+}
+
+// registerAddressBlockFunction registers the addressBlock() function
+func registerAddressBlockFunction(registry *DefaultFunctionRegistry) {
+	// addressBlock() function - formats an address by country conventions
+	addressBlockFn := NewSimpleFunction("addressBlock", 1, 2, addressBlock)
+	registry.RegisterFunction(addressBlockFn)
+}
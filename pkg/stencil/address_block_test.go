@@ -0,0 +1,101 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// fragmentLines returns the text of a run-level fragment with line breaks
+// as "|".
+func fragmentLines(t *testing.T, result interface{}) string {
+	t.Helper()
+	fragment, ok := result.(*OOXMLFragment)
+	if !ok {
+		t.Fatalf("expected a fragment, got %T", result)
+	}
+	var b strings.Builder
+	for _, part := range fragment.Content.(*FragmentGroup).Parts {
+		switch p := part.(type) {
+		case *Run:
+			b.WriteString(p.Text.Content)
+		case *Break:
+			b.WriteString("|")
+		}
+	}
+	return b.String()
+}
+
+func TestAddressBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		addr map[string]interface{}
+		home interface{}
+		want string
+	}{
+		{
+			name: "United States",
+			addr: map[string]interface{}{"name": "Jane Doe", "street": "1 Main St", "street2": "", "city": "Springfield", "state": "IL", "zip": "62701", "country": "US"},
+			want: "Jane Doe|1 Main St|Springfield, IL 62701|US",
+		},
+		{
+			name: "Germany without its country at home",
+			addr: map[string]interface{}{"company": "Acme GmbH", "name": "Max Müller", "street": "Hauptstr. 5", "postalCode": "10115", "city": "Berlin", "countryCode": "de", "country": "Deutschland"},
+			home: "DE",
+			want: "Max Müller|Acme GmbH|Hauptstr. 5|10115 Berlin",
+		},
+		{
+			name: "Germany from abroad",
+			addr: map[string]interface{}{"name": "Max Müller", "postalCode": "10115", "city": "Berlin", "countryCode": "DE", "country": "GERMANY"},
+			home: "US",
+			want: "Max Müller|10115 Berlin|GERMANY",
+		},
+		{
+			name: "United Kingdom",
+			addr: map[string]interface{}{"name": "Ann Smith", "street": "10 High St", "city": "London", "postcode": "SW1A 1AA", "country": "GB"},
+			home: "GB",
+			want: "Ann Smith|10 High St|London|SW1A 1AA",
+		},
+		{
+			name: "missing state",
+			addr: map[string]interface{}{"name": "Joe", "city": "Denver", "postalCode": "80202"},
+			want: "Joe|Denver 80202",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := addressBlock(tt.addr, tt.home)
+			if err != nil {
+				t.Fatalf("addressBlock() error = %v", err)
+			}
+			if got := fragmentLines(t, result); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if result, err := addressBlock(map[string]interface{}{"street2": " "}); result != nil || err != nil {
+		t.Errorf("empty address = %v, %v, want nothing", result, err)
+	}
+	if _, err := addressBlock("Main St"); err == nil {
+		t.Error("expected an error for an address that is not a map")
+	}
+}
+
+func TestAddressBlockInTemplate(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:rPr><w:b/></w:rPr><w:t>{{addressBlock(customer.address, "DE")}}</w:t></w:r></w:p>`)
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"customer": map[string]interface{}{"address": map[string]interface{}{"name": "Max", "postalCode": "10115", "city": "Berlin", "country": "DE"}},
+	}))
+	assertInOrder(t, documentXML, `<w:b/>`, `>Max<`, `<w:br>`, `<w:b/>`, `>10115 Berlin<`)
+	if strings.Contains(documentXML, ">DE<") {
+		t.Errorf("country printed for a domestic address: %s", documentXML)
+	}
+}
@@ -0,0 +1,57 @@
+package stencil
+
+import (
+	"strings"
+)
+
+// renderAlternateContent renders the branches of an mc:AlternateContent
+// element that contain template tokens. The other branches keep their
+// original XML.
+func renderAlternateContent(alternate *AlternateContent, data TemplateData, ctx *renderContext) (*AlternateContent, error) {
+	rendered := &AlternateContent{
+		Attrs:    alternate.Attrs,
+		Branches: make([]AlternateContentBranch, len(alternate.Branches)),
+		Selected: alternate.Selected,
+	}
+	for i, branch := range alternate.Branches {
+		rendered.Branches[i] = branch
+		branchPara := &Paragraph{Content: branch.Content}
+		if !strings.Contains(branchPara.GetText(), "{{") {
+			continue
+		}
+
+		renderedPara, err := RenderParagraphWithContext(branchPara, data, ctx)
+		if err != nil {
+			return nil, err
+		}
+		content := renderedPara.Content
+		if len(content) == 0 {
+			// Inline control structures produce runs only
+			for j := range renderedPara.Runs {
+				content = append(content, &renderedPara.Runs[j])
+			}
+		}
+		rendered.Branches[i].Content = content
+		rendered.Branches[i].RawXML = nil
+		rendered.Branches[i].RawXMLMarker = ""
+	}
+	return rendered, nil
+}
+
+// hasAlternateContentTokens reports whether a branch of an
+// mc:AlternateContent element in para contains template tokens.
+func hasAlternateContentTokens(para *Paragraph) bool {
+	for _, content := range para.Content {
+		alternate, ok := content.(*AlternateContent)
+		if !ok {
+			continue
+		}
+		for _, branch := range alternate.Branches {
+			branchPara := &Paragraph{Content: branch.Content}
+			if strings.Contains(branchPara.GetText(), "{{") {
+				return true
+			}
+		}
+	}
+	return false
+}
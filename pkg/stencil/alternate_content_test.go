@@ -0,0 +1,67 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlternateContentPreservesBranches(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006" mc:Ignorable="w14">
+  <w:body>
+    <w:p><w:r><w:t xml:space="preserve">Dear </w:t></w:r><mc:AlternateContent><mc:Choice Requires="w14"><w:r><w:rPr><w:b/></w:rPr><w:t>{{name}}</w:t></w:r></mc:Choice><mc:Fallback><w:bookmarkStart w:id="0" w:name="legacy"/><w:r><w:t>Customer &amp; Co</w:t></w:r><w:bookmarkEnd w:id="0"/></mc:Fallback></mc:AlternateContent><w:r><w:t>!</w:t></w:r></w:p>
+  </w:body>
+</w:document>`,
+	})
+
+	for _, name := range []string{"Ada", "Grace"} {
+		documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, TemplateData{"name": name}, RenderOptions{}))
+		assertInOrder(t, documentXML,
+			"Dear ",
+			`<mc:AlternateContent><mc:Choice Requires="w14">`,
+			`<w:b/>`,
+			name,
+			`</mc:Choice><mc:Fallback><w:bookmarkStart w:id="0" w:name="legacy"></w:bookmarkStart><w:r><w:t>Customer &amp; Co</w:t></w:r><w:bookmarkEnd w:id="0"></w:bookmarkEnd></mc:Fallback></mc:AlternateContent>`,
+			"!",
+		)
+		if strings.Contains(documentXML, "{{") || strings.Contains(documentXML, "RAW_XML_MARKER") {
+			t.Fatalf("unexpected template or marker text in output: %s", documentXML)
+		}
+	}
+}
+
+func TestParseAlternateContentKeepsAllBranches(t *testing.T) {
+	doc, err := ParseDocument(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006">
+	<w:body>
+		<w:p>
+			<mc:AlternateContent>
+				<mc:Choice Requires="x16"><w:r><w:t>choice</w:t></w:r></mc:Choice>
+				<mc:Fallback><w:r><w:t>fallback</w:t></w:r></mc:Fallback>
+			</mc:AlternateContent>
+		</w:p>
+	</w:body>
+</w:document>`))
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	para := doc.Body.Elements[0].(*Paragraph)
+	if len(para.Content) != 1 {
+		t.Fatalf("expected a single content item, got %d", len(para.Content))
+	}
+	alternate, ok := para.Content[0].(*AlternateContent)
+	if !ok {
+		t.Fatalf("expected *AlternateContent, got %T", para.Content[0])
+	}
+	if len(alternate.Branches) != 2 || alternate.Branches[0].Name != "Choice" || alternate.Branches[1].Name != "Fallback" {
+		t.Fatalf("expected Choice and Fallback branches, got %+v", alternate.Branches)
+	}
+	if alternate.Selected != 1 {
+		t.Errorf("expected the fallback to be selected, got %d", alternate.Selected)
+	}
+	if raw := string(alternate.Branches[0].RawXML); raw != "<w:r><w:t>choice</w:t></w:r>" {
+		t.Errorf("unexpected choice XML %q", raw)
+	}
+}
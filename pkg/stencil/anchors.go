@@ -0,0 +1,225 @@
+package stencil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AnchorStrategy selects how the AnchorID of a template token is derived.
+type AnchorStrategy string
+
+const (
+	// AnchorStrategyPosition, the default, derives the AnchorID from the
+	// part, the paragraph and character offsets and the text of the token,
+	// so any edit before a token in its paragraph, or a paragraph added
+	// before it, gives it a new AnchorID.
+	AnchorStrategyPosition AnchorStrategy = "position"
+	// AnchorStrategyContent derives the AnchorID from the part and the text
+	// of the token, with the whitespace inside the tag normalized, and from
+	// the number of tokens with the same text before it in the part. Edits
+	// to the text around the tags and reformatting of the tags keep the
+	// AnchorIDs; use MatchAnchors to follow tokens whose text was edited.
+	AnchorStrategyContent AnchorStrategy = "content"
+)
+
+// contentAnchorPrefix starts the AnchorIDs of AnchorStrategyContent, so they
+// are never mistaken for position anchors.
+const contentAnchorPrefix = "canchor_"
+
+// anchorMatchThreshold is the similarity, from 0 to 1, the text of two
+// tokens needs for MatchAnchors to treat one as an edit of the other.
+const anchorMatchThreshold = 0.5
+
+// applyAnchorStrategy sets the AnchorIDs of spans by strategy. Spans are
+// scanned with position anchors, so only other strategies rewrite them.
+func applyAnchorStrategy(spans []tokenSpan, strategy AnchorStrategy) error {
+	switch strategy {
+	case "", AnchorStrategyPosition:
+		return nil
+	case AnchorStrategyContent:
+		occurrences := make(map[string]int)
+		for i := range spans {
+			content := spans[i].Part + "|" + normalizeAnchorText(spans[i].Raw)
+			spans[i].AnchorID = buildContentAnchorID(content, occurrences[content])
+			occurrences[content]++
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown anchor strategy %q", strategy)
+	}
+}
+
+func buildContentAnchorID(content string, occurrence int) string {
+	sum := sha256.Sum256([]byte(content + "|" + strconv.Itoa(occurrence)))
+	return contentAnchorPrefix + hex.EncodeToString(sum[:8])
+}
+
+// normalizeAnchorText collapses the whitespace of a token's text, including
+// the whitespace just inside its braces.
+func normalizeAnchorText(raw string) string {
+	if strings.HasPrefix(raw, "{{") && strings.HasSuffix(raw, "}}") && len(raw) >= 4 {
+		return "{{" + strings.Join(strings.Fields(raw[2:len(raw)-2]), " ") + "}}"
+	}
+	return strings.Join(strings.Fields(raw), " ")
+}
+
+// anchoredToken is a token of MatchAnchors: the first reference with an
+// AnchorID and the position of the token among the tokens of its part.
+type anchoredToken struct {
+	id    string
+	part  string
+	text  string
+	index int
+}
+
+// MatchAnchors maps the AnchorIDs of the tokens of a previous revision of a
+// template to the AnchorIDs of the same tokens in the current revision, so
+// annotations and issue suppressions stored by AnchorID can follow the
+// tokens across template edits. previous and current are the references or
+// issue tokens of each revision, such as from ExtractReferences or TokenMap,
+// made with the same AnchorStrategy.
+//
+// An AnchorID found in both revisions maps to itself. The other tokens are
+// matched within their part by the similarity of their text, so a tag whose
+// expression was edited, such as {{customer.name}} becoming
+// {{customer.fullName}}, keeps its annotations; equally similar tokens are
+// matched in document order, each to the one closest to its previous
+// position. Tokens that were
+// removed, or edited beyond recognition, have no entry.
+func MatchAnchors(previous, current []TemplateTokenRef) map[string]string {
+	prevTokens := anchoredTokens(previous)
+	currTokens := anchoredTokens(current)
+
+	matches := make(map[string]string, len(prevTokens))
+	used := make(map[string]bool, len(currTokens))
+	for _, token := range currTokens {
+		used[token.id] = false
+	}
+	for _, token := range prevTokens {
+		if taken, ok := used[token.id]; ok && !taken {
+			matches[token.id] = token.id
+			used[token.id] = true
+		}
+	}
+
+	type candidate struct {
+		prev, curr int
+		similarity float64
+		distance   int
+	}
+	var candidates []candidate
+	for i, prev := range prevTokens {
+		if _, ok := matches[prev.id]; ok {
+			continue
+		}
+		for j, curr := range currTokens {
+			if used[curr.id] || curr.part != prev.part {
+				continue
+			}
+			similarity := textSimilarity(anchorExpression(prev.text), anchorExpression(curr.text))
+			if similarity < anchorMatchThreshold {
+				continue
+			}
+			distance := curr.index - prev.index
+			if distance < 0 {
+				distance = -distance
+			}
+			candidates = append(candidates, candidate{prev: i, curr: j, similarity: similarity, distance: distance})
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].similarity != candidates[b].similarity {
+			return candidates[a].similarity > candidates[b].similarity
+		}
+		if candidates[a].prev != candidates[b].prev {
+			return candidates[a].prev < candidates[b].prev
+		}
+		return candidates[a].distance < candidates[b].distance
+	})
+	for _, c := range candidates {
+		prev, curr := prevTokens[c.prev], currTokens[c.curr]
+		if _, ok := matches[prev.id]; ok || used[curr.id] {
+			continue
+		}
+		matches[prev.id] = curr.id
+		used[curr.id] = true
+	}
+	return matches
+}
+
+// anchoredTokens returns the tokens of refs in order, one per AnchorID.
+func anchoredTokens(refs []TemplateTokenRef) []anchoredToken {
+	tokens := make([]anchoredToken, 0, len(refs))
+	seen := make(map[string]bool, len(refs))
+	partCounts := make(map[string]int)
+	for _, ref := range refs {
+		id := ref.Location.AnchorID
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		part := ref.Location.Part
+		tokens = append(tokens, anchoredToken{
+			id:    id,
+			part:  part,
+			text:  normalizeAnchorText(ref.Raw),
+			index: partCounts[part],
+		})
+		partCounts[part]++
+	}
+	return tokens
+}
+
+// anchorExpression returns the text of a token without its braces, which
+// would make any two tags look alike.
+func anchorExpression(text string) string {
+	if strings.HasPrefix(text, "{{") && strings.HasSuffix(text, "}}") && len(text) >= 4 {
+		return text[2 : len(text)-2]
+	}
+	return text
+}
+
+// textSimilarity returns 1 minus the edit distance of a and b relative to
+// the length of the longer one.
+func textSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
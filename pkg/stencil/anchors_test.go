@@ -0,0 +1,119 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func extractAnchors(t *testing.T, paragraphs []string, strategy AnchorStrategy) map[string][]string {
+	t.Helper()
+	result, err := ExtractReferences(ExtractReferencesInput{
+		DocxBytes:      createDOCXWithParagraphs(t, paragraphs),
+		AnchorStrategy: strategy,
+	})
+	if err != nil {
+		t.Fatalf("ExtractReferences failed: %v", err)
+	}
+	anchors := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, ref := range result.References {
+		if !seen[ref.Location.AnchorID] {
+			seen[ref.Location.AnchorID] = true
+			anchors[ref.Raw] = append(anchors[ref.Raw], ref.Location.AnchorID)
+		}
+	}
+	return anchors
+}
+
+func TestContentAnchorsSurviveEditsAroundTokens(t *testing.T) {
+	before := []string{"Dear {{name}},", "Total: {{total}} and {{total}}"}
+	after := []string{"Intro paragraph.", "Dear Mr. {{ name }},", "The total is {{total}} and {{total}}"}
+
+	position := extractAnchors(t, before, AnchorStrategyPosition)
+	positionAfter := extractAnchors(t, after, "")
+	if position["{{name}}"][0] == positionAfter["{{ name }}"][0] {
+		t.Errorf("position anchor of {{name}} did not change with its position")
+	}
+
+	content := extractAnchors(t, before, AnchorStrategyContent)
+	contentAfter := extractAnchors(t, after, AnchorStrategyContent)
+	if got, want := contentAfter["{{ name }}"][0], content["{{name}}"][0]; got != want {
+		t.Errorf("content anchor of {{name}} = %s, want %s", got, want)
+	}
+	totals := content["{{total}}"]
+	if len(totals) != 2 || totals[0] == totals[1] {
+		t.Fatalf("repeated tokens should have distinct anchors, got %v", totals)
+	}
+	if got := contentAfter["{{total}}"]; strings.Join(got, ",") != strings.Join(totals, ",") {
+		t.Errorf("content anchors of {{total}} = %v, want %v", got, totals)
+	}
+	for _, anchor := range totals {
+		if !strings.HasPrefix(anchor, contentAnchorPrefix) {
+			t.Errorf("content anchor %s lacks prefix %s", anchor, contentAnchorPrefix)
+		}
+	}
+}
+
+func TestAnchorStrategyAppliesToValidationIssues(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{"{{if ready}}", "Text"})
+	result, err := ValidateTemplateSyntax(ValidateTemplateSyntaxInput{DocxBytes: docx, AnchorStrategy: AnchorStrategyContent})
+	if err != nil {
+		t.Fatalf("ValidateTemplateSyntax failed: %v", err)
+	}
+	if len(result.Issues) == 0 || !strings.HasPrefix(result.Issues[0].Location.AnchorID, contentAnchorPrefix) {
+		t.Fatalf("expected an issue with a content anchor, got %+v", result.Issues)
+	}
+
+	report, err := ValidateTemplateResult{Issues: result.Issues}.Render(ValidationReportSARIF)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(string(report), `"stencilContentAnchor/v1"`) {
+		t.Errorf("SARIF report lacks the content anchor fingerprint: %s", report)
+	}
+
+	_, err = ValidateTemplate(ValidateTemplateInput{DocxBytes: docx, AnchorStrategy: "fuzzy"})
+	if err == nil || !strings.Contains(err.Error(), `unknown anchor strategy "fuzzy"`) {
+		t.Errorf("expected unknown anchor strategy error, got %v", err)
+	}
+}
+
+func TestMatchAnchors(t *testing.T) {
+	ref := func(raw, part, anchor string) TemplateTokenRef {
+		return TemplateTokenRef{Raw: raw, Location: TemplateLocation{Part: part, AnchorID: anchor}}
+	}
+	const body, header = "word/document.xml", "word/header1.xml"
+	previous := []TemplateTokenRef{
+		ref("{{customer.name}}", body, "a1"),
+		ref("{{customer.name}}", body, "a1"),
+		ref("{{total}}", body, "a2"),
+		ref("{{footnote}}", body, "a3"),
+		ref("{{title}}", header, "a4"),
+		ref("{{item}}", body, "a5"),
+		ref("{{item}}", body, "a6"),
+	}
+	current := []TemplateTokenRef{
+		ref("{{customer.fullName}}", body, "b1"),
+		ref("{{total}}", body, "a2"),
+		ref("{{title}}", body, "b4"),
+		ref("{{items}}", body, "b5"),
+		ref("{{item}}", body, "b6"),
+		ref("{{item}}", body, "b7"),
+	}
+
+	got := MatchAnchors(previous, current)
+	want := map[string]string{
+		"a1": "b1",
+		"a2": "a2",
+		"a5": "b6",
+		"a6": "b7",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("MatchAnchors = %v, want %v", got, want)
+	}
+	for prev, curr := range want {
+		if got[prev] != curr {
+			t.Errorf("MatchAnchors[%s] = %q, want %q (all: %v)", prev, got[prev], curr, got)
+		}
+	}
+}
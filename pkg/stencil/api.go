@@ -51,6 +51,9 @@
 package stencil
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -63,6 +66,19 @@ type Engine struct {
 	config   *Config
 	cache    *TemplateCache
 	registry FunctionRegistry
+	data     *engineData
+	// trustedKeys are the keys templates must be signed with. Templates
+	// are not verified when empty.
+	trustedKeys []ed25519.PublicKey
+	// remote holds the templates prepared from the remote template source.
+	remote *remoteTemplates
+	// variants holds the template files registered with
+	// RegisterTemplateVariant.
+	variants templateVariants
+	// root is the engine a clone was made from, which prepares the
+	// templates of the clone's PrepareFile; views holds them.
+	root  *Engine
+	views tenantViews
 }
 
 // New creates a new template engine with default configuration.
@@ -71,6 +87,7 @@ func New() *Engine {
 		config:   GetGlobalConfig(),
 		cache:    defaultCache,
 		registry: GetDefaultFunctionRegistry(),
+		data:     newEngineData(),
 	}
 }
 
@@ -80,12 +97,17 @@ func NewWithConfig(config *Config) *Engine {
 		config:   config,
 		cache:    NewTemplateCache(),
 		registry: NewFunctionRegistry(),
+		data:     newEngineData(),
 	}
 }
 
 // PrepareFile loads and compiles a template from a file path.
 // The template is cached if caching is enabled in the configuration.
 func (e *Engine) PrepareFile(path string) (*PreparedTemplate, error) {
+	if e.root != nil {
+		return e.prepareTenantFile(path)
+	}
+
 	// Check cache first if enabled
 	if e.config.CacheMaxSize > 0 && e.cache != nil {
 		if tmpl, ok := e.cache.Get(path); ok {
@@ -93,18 +115,35 @@ func (e *Engine) PrepareFile(path string) (*PreparedTemplate, error) {
 		}
 	}
 
-	// Open and prepare the file
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open template file: %w", err)
-	}
-	defer file.Close()
+	var tmpl *PreparedTemplate
+	if len(e.trustedKeys) > 0 {
+		var err error
+		tmpl, err = e.prepareFileSigned(path)
+		if err != nil {
+			return nil, err
+		}
+	} else if e.config.MemoryMapTemplates {
+		var err error
+		tmpl, err = e.prepareMappedFile(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Open and prepare the file
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open template file: %w", err)
+		}
+		defer file.Close()
 
-	tmpl, err := e.Prepare(file)
-	if err != nil {
-		return nil, err
+		tmpl, err = e.prepare(file)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	tmpl.template.name = path
+
 	// Store in cache if enabled
 	if e.config.CacheMaxSize > 0 && e.cache != nil {
 		e.cache.Set(path, tmpl)
@@ -113,16 +152,53 @@ func (e *Engine) PrepareFile(path string) (*PreparedTemplate, error) {
 	return tmpl, nil
 }
 
-// Prepare loads and compiles a template from an io.Reader.
+// Prepare loads and compiles a template from an io.Reader. An engine with
+// trusted template keys rejects it; use PrepareSigned instead.
 func (e *Engine) Prepare(r io.Reader) (*PreparedTemplate, error) {
-	// Use the global prepare function
-	tmpl, err := prepare(r)
+	if len(e.trustedKeys) > 0 {
+		return nil, withErrorCode(ErrorCodeUntrustedTemplate, errors.New("engine requires signed templates; use PrepareSigned"))
+	}
+	return e.prepare(r)
+}
+
+// prepare loads and compiles a template with the engine's settings.
+func (e *Engine) prepare(r io.Reader) (*PreparedTemplate, error) {
+	return e.prepareWith(func() (*PreparedTemplate, error) {
+		return prepare(r)
+	})
+}
+
+// prepareWith compiles a template with load and applies the engine's
+// settings to it.
+func (e *Engine) prepareWith(load func() (*PreparedTemplate, error)) (_ *PreparedTemplate, err error) {
+	_, span := e.data.startSpan(context.Background(), spanPrepare)
+	defer func() { span.End(err) }()
+
+	tmpl, err := load()
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttribute(attrTemplateHash, tmpl.template.sourceDigest())
+	span.SetAttribute(attrTemplateSize, len(tmpl.template.source))
+	if doc := tmpl.template.document; doc != nil && doc.Body != nil {
+		setElementCountAttributes(span, doc.Body.Elements)
+	}
 	
-	// Set the engine's function registry on the template
+	// Set the engine's function registry and default data on the template
 	tmpl.registry = e.registry
+	tmpl.data = e.data
+	if e.config != nil {
+		tmpl.template.arithmeticPolicy = e.config.ArithmeticPolicy
+		tmpl.template.concatenationPolicy = e.config.ConcatenationPolicy
+		tmpl.template.maxIncludeDepth = e.config.includeDepthLimit()
+	}
+
+	if e.config != nil && e.config.ValidateOnPrepare {
+		if err := tmpl.validateSyntax(); err != nil {
+			tmpl.Close()
+			return nil, err
+		}
+	}
 	
 	return tmpl, nil
 }
@@ -145,6 +221,84 @@ func (e *Engine) RegisterFunctionsFromProvider(provider FunctionProvider) error
 	return nil
 }
 
+// SetGlobalData sets data that is available to every render of templates
+// prepared by this engine, such as company information or feature flags.
+// Values passed to Render take precedence over global data with the same key.
+// Calling SetGlobalData again replaces the previous global data.
+//
+// Example:
+//
+//	engine.SetGlobalData(stencil.TemplateData{
+//	    "company": map[string]interface{}{"name": "Acme Corp"},
+//	})
+func (e *Engine) SetGlobalData(data TemplateData) {
+	e.data.setGlobal(data)
+}
+
+// RegisterConstants makes values available to every template prepared by
+// this engine as fields of the "constants" variable, such as VAT rates or
+// maps from status codes to labels. Constants registered again replace the
+// earlier values of the same name. RenderOptions.Constants overrides
+// constants for a single render.
+//
+// Example:
+//
+//	engine.RegisterConstants(map[string]interface{}{
+//	    "vatRate":      0.19,
+//	    "statusLabels": map[string]string{"P": "Paid", "O": "Open"},
+//	})
+//
+// Templates use them as {{constants.vatRate}} and
+// {{constants.statusLabels[invoice.status]}}.
+func (e *Engine) RegisterConstants(constants map[string]interface{}) {
+	e.data.addConstants(constants)
+}
+
+// AddPreProcessor adds a function that normalizes the data of every render of
+// templates prepared by this engine, such as trimming strings, adding
+// computed fields or checking that required values are present. Processors
+// run in the order they were added, each receiving the data returned by the
+// previous one; the first gets a shallow copy of the render data, so the
+// caller's map is left unchanged. An error fails the render.
+//
+// Example:
+//
+//	engine.AddPreProcessor(func(data stencil.TemplateData) (stencil.TemplateData, error) {
+//	    name, ok := data["customer"].(string)
+//	    if !ok {
+//	        return nil, errors.New("customer is required")
+//	    }
+//	    data["customer"] = strings.TrimSpace(name)
+//	    return data, nil
+//	})
+func (e *Engine) AddPreProcessor(processor PreProcessor) {
+	e.data.addPreProcessor(processor)
+}
+
+// AddPostProcessor adds a function that transforms every document rendered
+// from templates prepared by this engine, after control structures and
+// built-in table processing are applied and before the document is written.
+// Processors run in the order they were added; an error fails the render.
+// They receive the main document body only, not headers or footers.
+//
+// Example:
+//
+//	engine.AddPostProcessor(func(doc *stencil.Document, data stencil.TemplateData) error {
+//	    if data["brand"] != "acme" {
+//	        return nil
+//	    }
+//	    for _, table := range doc.Tables() {
+//	        if table.Properties == nil {
+//	            table.Properties = &stencil.TableProperties{}
+//	        }
+//	        table.Properties.Style = &stencil.Style{Val: "AcmeTable"}
+//	    }
+//	    return nil
+//	})
+func (e *Engine) AddPostProcessor(processor PostProcessor) {
+	e.data.addPostProcessor(processor)
+}
+
 // Config returns the engine's configuration.
 func (e *Engine) Config() *Config {
 	return e.config
@@ -156,16 +310,28 @@ func (e *Engine) SetConfig(config *Config) {
 	e.config = config
 }
 
-// ClearCache removes all templates from the cache.
+// ClearCache removes all templates from the cache, and the rendered
+// documents from the render cache (WithRenderCache).
 func (e *Engine) ClearCache() {
 	if e.cache != nil {
 		e.cache.Clear()
 	}
+	e.closeViews()
+	e.data.mu.RLock()
+	renders := e.data.renderCache
+	e.data.mu.RUnlock()
+	if renders != nil {
+		renders.clear()
+	}
 }
 
-// Close releases any resources held by the engine.
+// Close releases any resources held by the engine, such as the templates
+// prepared from its remote template source.
 func (e *Engine) Close() error {
-	// Currently no resources to release, but kept for future use
+	if e.remote != nil {
+		e.remote.closeAll()
+	}
+	e.closeViews()
 	return nil
 }
 
@@ -200,6 +366,36 @@ func WithFunctionProvider(provider FunctionProvider) Option {
 	}
 }
 
+// WithValueProvider returns an option that adds a value provider. Providers
+// are asked, in the order they were added, for top-level variables that are
+// neither in the render data nor in the global data.
+//
+// Example:
+//
+//	engine := stencil.NewWithOptions(stencil.WithValueProvider(func(key string) (interface{}, bool) {
+//	    return os.LookupEnv("APP_" + strings.ToUpper(key))
+//	}))
+func WithValueProvider(provider ValueProvider) Option {
+	return func(e *Engine) {
+		e.data.addProvider(provider)
+	}
+}
+
+// WithFunctionPolicy returns an option that restricts the functions templates
+// prepared by the engine may call and bounds their execution time.
+//
+// Example:
+//
+//	engine := stencil.NewWithOptions(stencil.WithFunctionPolicy(stencil.FunctionPolicy{
+//	    Deny:    []string{"html", "xml", "replaceLink"},
+//	    Timeout: 100 * time.Millisecond,
+//	}))
+func WithFunctionPolicy(policy FunctionPolicy) Option {
+	return func(e *Engine) {
+		e.data.setFunctionPolicy(&policy)
+	}
+}
+
 // NewWithOptions creates a new engine with the specified options.
 func NewWithOptions(opts ...Option) *Engine {
 	engine := New()
@@ -225,6 +421,12 @@ func Prepare(r io.Reader) (*PreparedTemplate, error) {
 	return DefaultEngine.Prepare(r)
 }
 
+// PreparePack prepares the template of a template pack (.stencilpack) and
+// adds its fragments using the default engine.
+func PreparePack(path string) (*PreparedTemplate, error) {
+	return DefaultEngine.PreparePack(path)
+}
+
 
 // RegisterGlobalFunction adds a custom function to the global function registry.
 func RegisterGlobalFunction(name string, fn Function) error {
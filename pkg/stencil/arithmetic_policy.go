@@ -0,0 +1,111 @@
+package stencil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ArithmeticPolicy controls the result of arithmetic that has no numeric
+// result: division or modulo by zero, arithmetic on null and results that are
+// not finite numbers, such as NaN.
+type ArithmeticPolicy string
+
+const (
+	// ArithmeticPolicyError fails the render. It is the default.
+	ArithmeticPolicyError ArithmeticPolicy = "error"
+	// ArithmeticPolicyNil makes the result null, which renders as nothing.
+	ArithmeticPolicyNil ArithmeticPolicy = "nil"
+	// ArithmeticPolicyZero makes the result 0.
+	ArithmeticPolicyZero ArithmeticPolicy = "zero"
+)
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errModuloByZero   = errors.New("modulo by zero")
+)
+
+// parseArithmeticPolicy returns the policy named s and whether it is known.
+func parseArithmeticPolicy(s string) (ArithmeticPolicy, bool) {
+	switch policy := ArithmeticPolicy(s); policy {
+	case ArithmeticPolicyError, ArithmeticPolicyNil, ArithmeticPolicyZero:
+		return policy, true
+	default:
+		return "", false
+	}
+}
+
+// arithmeticPolicyFromData returns the arithmetic policy of the render.
+func arithmeticPolicyFromData(data TemplateData) ArithmeticPolicy {
+	return attachedRenderHelpers(data).effectiveArithmeticPolicy()
+}
+
+// effectiveArithmeticPolicy returns the arithmetic policy of the render h
+// belongs to; h may be nil outside a render.
+func (h *renderHelpers) effectiveArithmeticPolicy() ArithmeticPolicy {
+	if h != nil && h.arithmeticPolicy != "" {
+		return h.arithmeticPolicy
+	}
+	return ArithmeticPolicyError
+}
+
+// fallback returns the value that replaces an undefined result, or err for
+// ArithmeticPolicyError.
+func (p ArithmeticPolicy) fallback(err error) (interface{}, error) {
+	switch p {
+	case ArithmeticPolicyNil:
+		return nil, nil
+	case ArithmeticPolicyZero:
+		return 0, nil
+	default:
+		return nil, err
+	}
+}
+
+// isArithmeticOperator reports whether the policy applies to operator.
+func isArithmeticOperator(operator string) bool {
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		return true
+	default:
+		return false
+	}
+}
+
+// apply checks the result of an arithmetic operation on
+// operands. Division or modulo by zero, failures caused by a null operand and
+// non-finite results are resolved by the policy; other errors are returned
+// unchanged.
+func (p ArithmeticPolicy) apply(result interface{}, err error, operands ...interface{}) (interface{}, error) {
+	if err != nil {
+		if errors.Is(err, errDivisionByZero) || errors.Is(err, errModuloByZero) || hasNilOperand(operands) {
+			return p.fallback(err)
+		}
+		return nil, err
+	}
+	if isNonFiniteNumber(result) {
+		return p.fallback(fmt.Errorf("arithmetic result %v is not a finite number", result))
+	}
+	return result, nil
+}
+
+func hasNilOperand(operands []interface{}) bool {
+	for _, operand := range operands {
+		if operand == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isNonFiniteNumber reports whether value is a NaN or infinite float.
+func isNonFiniteNumber(value interface{}) bool {
+	switch v := value.(type) {
+	case float64:
+		return math.IsNaN(v) || math.IsInf(v, 0)
+	case float32:
+		return math.IsNaN(float64(v)) || math.IsInf(float64(v), 0)
+	default:
+		return false
+	}
+}
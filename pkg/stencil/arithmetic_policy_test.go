@@ -0,0 +1,130 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestArithmeticPolicy(t *testing.T) {
+	data := TemplateData{"zero": 0, "amount": 10, "missing": nil, "nan": nanValue()}
+
+	tests := []struct {
+		expr     string
+		wantNil  interface{}
+		wantZero interface{}
+		wantErr  string
+	}{
+		{expr: "amount / zero", wantErr: "division by zero"},
+		{expr: "amount % zero", wantErr: "modulo by zero"},
+		{expr: "amount * missing", wantErr: "cannot multiply"},
+		{expr: "missing - amount", wantErr: "cannot subtract"},
+		{expr: "-missing", wantErr: "cannot apply unary minus"},
+		{expr: "nan * amount", wantErr: "not a finite number"},
+		{expr: "sum(list(nan, amount))", wantErr: "not a finite number"},
+		{expr: "round(nan)", wantErr: "not a finite number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+
+			if _, err := node.Evaluate(data); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error policy: error = %v, want %q", err, tt.wantErr)
+			}
+
+			for _, policy := range []ArithmeticPolicy{ArithmeticPolicyNil, ArithmeticPolicyZero} {
+				scoped := newChildTemplateData(data, 1)
+				scoped[renderHelpersKey] = &renderHelpers{arithmeticPolicy: policy}
+				got, err := node.Evaluate(scoped)
+				if err != nil {
+					t.Fatalf("%s policy: error = %v", policy, err)
+				}
+				want := interface{}(nil)
+				if policy == ArithmeticPolicyZero {
+					want = 0
+				}
+				if got != want {
+					t.Errorf("%s policy: got %v, want %v", policy, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestArithmeticPolicyKeepsOtherErrors(t *testing.T) {
+	node, err := ParseExpression("amount * items")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	data := TemplateData{
+		"amount":         2,
+		"items":          []interface{}{1},
+		renderHelpersKey: &renderHelpers{arithmeticPolicy: ArithmeticPolicyZero},
+	}
+	if _, err := node.Evaluate(data); err == nil || !strings.Contains(err.Error(), "cannot multiply") {
+		t.Fatalf("error = %v, want a type error", err)
+	}
+
+	// String concatenation with null is not arithmetic.
+	node, err = ParseExpression(`"total: " + missing`)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	got, err := node.Evaluate(TemplateData{renderHelpersKey: &renderHelpers{arithmeticPolicy: ArithmeticPolicyZero}})
+	if err != nil || got != "total: " {
+		t.Fatalf("got %v, %v, want %q", got, err, "total: ")
+	}
+}
+
+func TestArithmeticPolicyFromConfig(t *testing.T) {
+	docx := createSimpleDOCX(t, "Share: {{amount / count}}")
+
+	config := DefaultConfig()
+	config.ArithmeticPolicy = ArithmeticPolicyZero
+	engine := NewWithConfig(config)
+
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	reader, err := tmpl.Render(TemplateData{"amount": 100, "count": 0})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Share: 0") {
+		t.Errorf("text = %q, want it to contain %q", text, "Share: 0")
+	}
+
+	frozen, err := tmpl.Freeze()
+	if err != nil {
+		t.Fatalf("Freeze failed: %v", err)
+	}
+	if _, err := frozen.Render(TemplateData{"amount": 100, "count": 0}); err != nil {
+		t.Errorf("frozen Render failed: %v", err)
+	}
+
+	plain, err := NewWithConfig(DefaultConfig()).Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer plain.Close()
+	if _, err := plain.Render(TemplateData{"amount": 100, "count": 0}); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("default policy: error = %v, want division by zero", err)
+	}
+}
+
+func nanValue() float64 {
+	zero := 0.0
+	return zero / zero
+}
@@ -0,0 +1,150 @@
+package stencil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ClauseSpec is one clause of a document built with Assemble.
+type ClauseSpec struct {
+	// ID is the name of the fragment that holds the clause, registered
+	// with AddFragment and related methods or found by the fragment
+	// resolver.
+	ID string
+	// Condition is an optional template expression, such as
+	// `jurisdiction == "DE"`. The clause is included only when it is
+	// truthy for the render data; an empty condition always includes it.
+	Condition string
+	// Params are variables visible only inside the clause. They hide
+	// render data of the same name.
+	Params TemplateData
+}
+
+// assemblyClause is a ClauseSpec with its condition parsed.
+type assemblyClause struct {
+	spec      ClauseSpec
+	condition ExpressionNode
+}
+
+// clauseDirectiveName names the block directive Assemble wraps each clause
+// in. Its argument is the index of the clause.
+const clauseDirectiveName = "assembledClause"
+
+func init() {
+	blockDirectives.directives[clauseDirectiveName] = clauseBlockDirective
+}
+
+// Assemble returns a new template whose body is the given clauses in order,
+// in place of the body of pt. Each clause is a fragment of the clause
+// library registered on pt, included when its condition holds for the
+// render data. Styles, headers, footers and page setup are taken from pt,
+// so pt is usually a letterhead or an empty document.
+//
+// Every clause ID must resolve to a fragment and every condition must
+// parse; otherwise Assemble reports all problems at once.
+//
+// Example:
+//
+//	contract, err := library.Assemble([]stencil.ClauseSpec{
+//	    {ID: "parties"},
+//	    {ID: "payment", Params: stencil.TemplateData{"days": 30}},
+//	    {ID: "governing-law-de", Condition: `jurisdiction == "DE"`},
+//	    {ID: "governing-law-us", Condition: `jurisdiction == "US"`},
+//	    {ID: "signatures"},
+//	})
+//	output, err := contract.Render(data)
+func (pt *PreparedTemplate) Assemble(clauses []ClauseSpec) (*PreparedTemplate, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("invalid template")
+	}
+	doc := pt.Document()
+	if doc == nil || doc.Body == nil {
+		return nil, errTemplateClosed
+	}
+
+	errs := NewMultiError()
+	compiled := make([]assemblyClause, len(clauses))
+	elements := make([]BodyElement, 0, 3*len(clauses))
+	for i, spec := range clauses {
+		compiled[i] = assemblyClause{spec: spec}
+		if spec.ID == "" {
+			errs.Add(fmt.Errorf("clause %d: missing fragment ID", i+1))
+			continue
+		}
+		if condition := strings.TrimSpace(spec.Condition); condition != "" {
+			node, err := ParseExpressionStrict(condition)
+			if err != nil {
+				errs.Add(fmt.Errorf("clause %d (%s): invalid condition %q: %w", i+1, spec.ID, condition, err))
+				continue
+			}
+			compiled[i].condition = node
+		}
+		elements = append(elements,
+			clauseTagParagraph(fmt.Sprintf("{{%s \"%d\"}}", clauseDirectiveName, i)),
+			clauseTagParagraph(fmt.Sprintf("{{include %s}}", strconv.Quote(spec.ID))),
+			clauseTagParagraph("{{end}}"),
+		)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	doc.Body.Elements = elements
+
+	assembled, err := pt.withDocument(doc, compiled)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve the clauses now, so that a missing clause fails here rather
+	// than in every render.
+	for i, spec := range clauses {
+		frag, err := assembled.template.resolveFragment(spec.ID)
+		switch {
+		case err != nil:
+			errs.Add(fmt.Errorf("clause %d (%s): %w", i+1, spec.ID, err))
+		case frag == nil:
+			errs.Add(fmt.Errorf("clause %d: fragment not found: %s", i+1, spec.ID))
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return assembled, nil
+}
+
+func clauseTagParagraph(tag string) *Paragraph {
+	return &Paragraph{Runs: []Run{{Text: &Text{Content: tag}}}}
+}
+
+// clauseBlockDirective renders a clause of an assembled document when its
+// condition holds, with its parameters in scope.
+func clauseBlockDirective(block *Block) ([]BodyElement, error) {
+	var clauses []assemblyClause
+	if block.ctx != nil && block.ctx.template != nil {
+		clauses = block.ctx.template.clauses
+	}
+	index, err := strconv.Atoi(strings.Trim(block.Args, `"`))
+	if err != nil || index < 0 || index >= len(clauses) {
+		return nil, fmt.Errorf("%s blocks are only available in assembled documents", clauseDirectiveName)
+	}
+	clause := clauses[index]
+
+	if clause.condition != nil {
+		value, err := clause.condition.Evaluate(block.Data)
+		if err != nil {
+			return nil, fmt.Errorf("clause %s: condition %q: %w", clause.spec.ID, clause.spec.Condition, err)
+		}
+		if !isTruthy(value) {
+			return nil, nil
+		}
+	}
+
+	data := block.Data
+	if len(clause.spec.Params) > 0 {
+		data = newChildTemplateData(block.Data, len(clause.spec.Params))
+		for name, value := range clause.spec.Params {
+			data[name] = value
+		}
+	}
+	return block.Render(data)
+}
@@ -0,0 +1,100 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	library, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "Letterhead placeholder")))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer library.Close()
+	for name, content := range map[string]string{
+		"parties":  "Agreement between {{seller}} and {{buyer}}.",
+		"payment":  "Payment is due within {{days}} days.",
+		"law-de":   "German law applies.",
+		"law-us":   "The laws of {{state}} apply.",
+		"retainer": "{{if retainer}}A retainer of {{retainer}} is payable.{{end}}",
+	} {
+		if err := library.AddFragment(name, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contract, err := library.Assemble([]ClauseSpec{
+		{ID: "parties"},
+		{ID: "payment", Params: TemplateData{"days": 30}},
+		{ID: "law-de", Condition: `jurisdiction == "DE"`},
+		{ID: "law-us", Condition: `jurisdiction == "US"`, Params: TemplateData{"state": "Delaware"}},
+		{ID: "retainer"},
+		{ID: "payment", Condition: "rush", Params: TemplateData{"days": 7}},
+	})
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	defer contract.Close()
+
+	output, err := contract.Render(TemplateData{"seller": "ACME", "buyer": "Globex", "jurisdiction": "US", "days": 90})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	text := extractTextFromDOCX(t, content)
+	want := "Agreement between ACME and Globex.Payment is due within 30 days.The laws of Delaware apply."
+	if strings.ReplaceAll(text, "\n", "") != want {
+		t.Errorf("assembled text = %q, want %q", text, want)
+	}
+	if strings.Contains(text, "Letterhead") {
+		t.Error("the body of the library template was not replaced")
+	}
+
+	output, err = contract.Render(TemplateData{"jurisdiction": "DE", "rush": true, "retainer": "500 EUR"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ = io.ReadAll(output)
+	text = extractTextFromDOCX(t, content)
+	for _, want := range []string{"German law applies.", "A retainer of 500 EUR", "within 7 days"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in %q", want, text)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	library, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "")))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer library.Close()
+	library.AddFragment("known", "Known clause")
+
+	_, err = library.Assemble([]ClauseSpec{{ID: "known"}, {ID: "missing"}, {ID: "gone"}})
+	if err == nil || !strings.Contains(err.Error(), "missing") || !strings.Contains(err.Error(), "gone") {
+		t.Errorf("expected both missing clauses to be reported, got %v", err)
+	}
+	if _, err := library.Assemble([]ClauseSpec{{ID: "known", Condition: "a =="}}); err == nil {
+		t.Error("expected an error for an invalid condition")
+	}
+	if _, err := library.Assemble([]ClauseSpec{{}}); err == nil {
+		t.Error("expected an error for a clause without ID")
+	}
+
+	tmpl, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "Value: {{assembledClause}}")))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(TemplateData{"assembledClause": "plain"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	if text := extractTextFromDOCX(t, content); !strings.Contains(text, "Value: plain") {
+		t.Errorf("variable named like the clause directive not rendered: %q", text)
+	}
+}
@@ -0,0 +1,74 @@
+package stencil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The built-in {{audience "internal"}}...{{end}} block directive renders
+// its content only for the audiences selected with RenderOptions.Audiences,
+// so one template can produce an internal and a customer-facing variant.
+// A block may name several audiences: {{audience "internal", "partner"}}.
+
+const audienceDirectiveName = "audience"
+
+func init() {
+	blockDirectives.directives[audienceDirectiveName] = audienceBlockDirective
+}
+
+func audienceBlockDirective(block *Block) ([]BodyElement, error) {
+	audiences, err := parseAudienceBlockArgs(block)
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	if block.ctx != nil && block.ctx.options != nil {
+		selected = block.ctx.options.Audiences
+	}
+	for _, audience := range audiences {
+		for _, name := range selected {
+			if audience == name {
+				return block.Render(block.Data)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// parseAudienceBlockArgs evaluates the comma-separated audience names of an
+// audience block.
+func parseAudienceBlockArgs(block *Block) ([]string, error) {
+	node, err := ParseExpressionStrict(audienceDirectiveName + "(" + block.Args + ")")
+	if err != nil {
+		return nil, fmt.Errorf("invalid audience list %q: %w", strings.TrimSpace(block.Args), err)
+	}
+	call, ok := node.(*FunctionCallNode)
+	if !ok || len(call.Args) == 0 {
+		return nil, fmt.Errorf("invalid audience list %q", strings.TrimSpace(block.Args))
+	}
+	audiences := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		value, err := arg.Evaluate(block.Data)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := value.(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("audience must be a non-empty string, got %v", value)
+		}
+		audiences = append(audiences, name)
+	}
+	return audiences, nil
+}
+
+// selectedAudiencesKey identifies the audiences selected for a render, for
+// caches of rendered content.
+func selectedAudiencesKey(opts *RenderOptions) string {
+	if opts == nil || len(opts.Audiences) == 0 {
+		return ""
+	}
+	audiences := append([]string(nil), opts.Audiences...)
+	sort.Strings(audiences)
+	return fmt.Sprintf("%q", audiences)
+}
@@ -0,0 +1,96 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAudienceBlocks(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Report for {{client}}`,
+		`{{audience "internal"}}`,
+		`Margin: {{margin}}`,
+		`{{end}}`,
+		`{{audience "customer", "partner"}}`,
+		`Thank you for your business.`,
+		`{{end}}`,
+		`Audience: {{audience}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	data := TemplateData{"client": "ACME", "margin": "42%", "audience": "plain"}
+	tests := []struct {
+		name      string
+		audiences []string
+		want      []string
+		notWant   []string
+	}{
+		{"none selected", nil, []string{"Report for ACME", "Audience: plain"}, []string{"Margin", "Thank you"}},
+		{"internal", []string{"internal"}, []string{"Margin: 42%"}, []string{"Thank you"}},
+		{"partner", []string{"partner"}, []string{"Thank you"}, []string{"Margin"}},
+		{"both", []string{"customer", "internal"}, []string{"Margin: 42%", "Thank you"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := tmpl.RenderWithOptions(data, RenderOptions{Audiences: tt.audiences})
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			content, _ := io.ReadAll(output)
+			text := extractTextFromDOCX(t, content)
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("missing %q in %q", want, text)
+				}
+			}
+			for _, notWant := range append(tt.notWant, "{{") {
+				if strings.Contains(text, notWant) {
+					t.Errorf("unexpected %q in %q", notWant, text)
+				}
+			}
+		})
+	}
+
+	bad, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{audience ""}}`, `x`, `{{end}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer bad.Close()
+	if _, err := bad.Render(TemplateData{}); err == nil {
+		t.Error("expected an error for an empty audience")
+	}
+}
+
+func TestCacheBlockKeysOnAudiences(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{cache "body"}}`,
+		`{{audience "internal"}}`,
+		`Internal notes`,
+		`{{end}}`,
+		`Shared text`,
+		`{{end}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	for _, audiences := range [][]string{{"internal"}, nil} {
+		output, err := tmpl.RenderWithOptions(TemplateData{}, RenderOptions{Audiences: audiences})
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, _ := io.ReadAll(output)
+		text := extractTextFromDOCX(t, content)
+		if got, want := strings.Contains(text, "Internal notes"), audiences != nil; got != want {
+			t.Errorf("audiences %v: internal notes rendered = %v, want %v (%q)", audiences, got, want, text)
+		}
+	}
+}
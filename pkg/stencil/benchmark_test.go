@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"fmt"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -456,3 +457,69 @@ func BenchmarkCache_Miss(b *testing.B) {
 		cache.Clear()
 	}
 }
+
+func prepareFragmentBenchmarkTemplate(b *testing.B) *PreparedTemplate {
+	b.Helper()
+	tmpl, err := Prepare(createBenchDocx(b, `Hello {{name}}! {{include "terms"}}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	tmpl.SetFragmentResolver(FragmentResolverFunc(func(name string) ([]byte, error) {
+		return []byte("Terms for {{name}} apply."), nil
+	}))
+	return tmpl
+}
+
+// Benchmark parallel rendering of a prepared template with a resolved fragment
+func BenchmarkRender_ParallelPrepared(b *testing.B) {
+	tmpl := prepareFragmentBenchmarkTemplate(b)
+	defer tmpl.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := tmpl.Render(benchmarkSimpleData); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Benchmark parallel rendering of the same template after Freeze
+func BenchmarkRender_ParallelFrozen(b *testing.B) {
+	tmpl := prepareFragmentBenchmarkTemplate(b)
+	defer tmpl.Close()
+	frozen, err := tmpl.Freeze()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := frozen.Render(benchmarkSimpleData); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Benchmark parallel rendering with one frozen template copy per goroutine
+func BenchmarkRender_ParallelPool(b *testing.B) {
+	pool, err := NewTemplatePool(createBenchDocx(b, `Hello {{name}}! Terms for {{name}} apply.`).Bytes(), runtime.GOMAXPROCS(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := pool.Warmup(benchmarkSimpleData); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := pool.Render(benchmarkSimpleData); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// BlockDirective renders a custom block control structure such as
+// {{landscape}}...{{end}}. It returns the elements that replace the block,
+// usually built from block.Render.
+type BlockDirective func(block *Block) ([]BodyElement, error)
+
+// Block is an occurrence of a custom block directive in a template.
+type Block struct {
+	// Name is the directive name.
+	Name string
+	// Args is the text after the name in the opening tag, such as
+	// `"terms", 3600` in {{cache "terms", 3600}}.
+	Args string
+	// Elements are copies of the unrendered template elements between the
+	// opening tag and {{end}}.
+	Elements []BodyElement
+	// Data is the render data in scope at the opening tag.
+	Data TemplateData
+
+	render func(data TemplateData) ([]BodyElement, error)
+	ctx    *renderContext
+}
+
+// Render renders the enclosed elements with data, which is usually
+// block.Data or a child scope of it. Control structures, fragments and
+// other directives inside the block work as anywhere else.
+func (b *Block) Render(data TemplateData) ([]BodyElement, error) {
+	return b.render(data)
+}
+
+// Evaluate evaluates a template expression, such as Args, with the data of
+// the block.
+func (b *Block) Evaluate(expression string) (interface{}, error) {
+	node, err := ParseExpression(expression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression %s: %w", expression, err)
+	}
+	return node.Evaluate(b.Data)
+}
+
+// reservedBlockNames are the keywords that cannot name a block directive.
+var reservedBlockNames = map[string]bool{
+	"if": true, "else": true, "elsif": true, "elseif": true, "elif": true,
+	"unless": true, "for": true, "end": true, "include": true, "pageBreak": true,
+	cacheDirectiveName: true, audienceDirectiveName: true, clauseDirectiveName: true,
+	compactDirectiveName: true, columnsDirectiveName: true, scopeDirectiveName: true,
+}
+
+// builtinBlockDirectives are the directives defined by this package. Their
+// names stay usable as variables: a tag only opens one of these blocks when
+// its first argument is a quoted string, as in {{cache "terms"}}, or, for
+// {{compact}}, when it has no arguments, for {{columns 2}} when it is a
+// number, and for {{scope chapter}} when there is one.
+var builtinBlockDirectives = map[string]bool{
+	cacheDirectiveName: true, audienceDirectiveName: true, clauseDirectiveName: true,
+	compactDirectiveName: true, columnsDirectiveName: true, scopeDirectiveName: true,
+}
+
+var blockDirectives = struct {
+	sync.RWMutex
+	directives map[string]BlockDirective
+}{directives: make(map[string]BlockDirective)}
+
+// RegisterBlockDirective registers a custom block directive for all
+// templates. In a template, the opening tag {{name}} or {{name args}} and
+// the closing {{end}} must each stand in a paragraph of their own, in the
+// body, a table cell, a header, a footer or a fragment.
+//
+// Directives are recognized when a template is prepared, so register them
+// before preparing templates that use them. A directive hides variables of
+// the same name.
+//
+// Example:
+//
+//	err := stencil.RegisterBlockDirective("repeat", func(block *stencil.Block) ([]stencil.BodyElement, error) {
+//	    count, err := block.Evaluate(block.Args)
+//	    ...
+//	})
+func RegisterBlockDirective(name string, directive BlockDirective) error {
+	if identifierRegex.FindString(name) != name || name == "" {
+		return fmt.Errorf("invalid block directive name %q", name)
+	}
+	if reservedBlockNames[name] {
+		return fmt.Errorf("block directive name %q is a reserved keyword", name)
+	}
+	if directive == nil {
+		return fmt.Errorf("block directive %s is nil", name)
+	}
+
+	blockDirectives.Lock()
+	defer blockDirectives.Unlock()
+	blockDirectives.directives[name] = directive
+	return nil
+}
+
+// lookupBlockDirective returns the directive registered under name.
+func lookupBlockDirective(name string) (BlockDirective, bool) {
+	blockDirectives.RLock()
+	defer blockDirectives.RUnlock()
+	directive, ok := blockDirectives.directives[name]
+	return directive, ok
+}
+
+// opensBlockDirective reports whether a tag starting with the name of a
+// registered directive opens a block.
+func opensBlockDirective(keyword, content string) bool {
+	if !builtinBlockDirectives[keyword] {
+		return true
+	}
+	if keyword == compactDirectiveName {
+		return strings.TrimSpace(content) == compactDirectiveName
+	}
+	args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content), keyword))
+	if keyword == columnsDirectiveName {
+		return args != "" && args[0] >= '0' && args[0] <= '9'
+	}
+	if keyword == scopeDirectiveName {
+		return args != ""
+	}
+	return startsWithQuote(args)
+}
+
+// detectBlockDirective reports whether para consists of a block directive
+// opening tag and returns the tag content.
+func detectBlockDirective(para *Paragraph) (string, bool) {
+	blockDirectives.RLock()
+	registered := len(blockDirectives.directives) > 0
+	blockDirectives.RUnlock()
+	if !registered {
+		return "", false
+	}
+
+	tokens := Tokenize(strings.TrimSpace(para.GetText()))
+	if len(tokens) != 1 || tokens[0].Type != TokenBlock {
+		return "", false
+	}
+	return tokens[0].Value, true
+}
+
+// renderBlockDirective renders the block opened at index start of body and
+// closed at endIdx.
+func renderBlockDirective(body *Body, plan *bodyRenderPlan, start, endIdx int, content string, data TemplateData, ctx *renderContext) ([]BodyElement, error) {
+	name := strings.Fields(content)[0]
+	args := strings.TrimPrefix(content, name)
+	directive, ok := lookupBlockDirective(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown block directive: %s", name)
+	}
+
+	elements := cloneBody(&Body{Elements: body.Elements[start+1 : endIdx]}).Elements
+	block := &Block{
+		Name:     name,
+		Args:     strings.TrimSpace(args),
+		Elements: elements,
+		Data:     data,
+		render: func(data TemplateData) ([]BodyElement, error) {
+			return renderBodyElementRange(body, plan, start+1, endIdx, data, ctx)
+		},
+		ctx: ctx,
+	}
+	rendered, err := directive(block)
+	if err != nil {
+		return nil, fmt.Errorf("block directive %s: %w", name, err)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,149 @@
+package stencil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// Directives are global, so the test directives are registered once.
+	mustRegisterBlockDirective("testRepeat", func(block *Block) ([]BodyElement, error) {
+		count, err := block.Evaluate(block.Args)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := count.(int)
+		if !ok {
+			return nil, fmt.Errorf("count must be an integer, got %T", count)
+		}
+		var result []BodyElement
+		for i := 0; i < n; i++ {
+			scope := newChildTemplateData(block.Data, 1)
+			scope["copy"] = i + 1
+			rendered, err := block.Render(scope)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, rendered...)
+		}
+		return result, nil
+	})
+	mustRegisterBlockDirective("testShout", func(block *Block) ([]BodyElement, error) {
+		rendered, err := block.Render(block.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range rendered {
+			if para, ok := elem.(*Paragraph); ok {
+				for i := range para.Runs {
+					if para.Runs[i].Text != nil {
+						para.Runs[i].Text.Content = strings.ToUpper(para.Runs[i].Text.Content)
+					}
+				}
+			}
+		}
+		return rendered, nil
+	})
+	mustRegisterBlockDirective("testCount", func(block *Block) ([]BodyElement, error) {
+		text := fmt.Sprintf("%d enclosed", len(block.Elements))
+		return []BodyElement{&Paragraph{Runs: []Run{{Text: &Text{Content: text}}}}}, nil
+	})
+}
+
+func mustRegisterBlockDirective(name string, directive BlockDirective) {
+	if err := RegisterBlockDirective(name, directive); err != nil {
+		panic(err)
+	}
+}
+
+func TestBlockDirectives(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Before</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{testRepeat times}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t xml:space="preserve">Copy {{copy}} for {{name}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{testShout}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{item}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`<w:tbl><w:tr><w:tc>`+
+		`<w:p><w:r><w:t>{{testCount}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>hidden first</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>hidden second</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`</w:tc></w:tr></w:tbl>`+
+		`<w:p><w:r><w:t>After</w:t></w:r></w:p>`)
+
+	output := renderWithOptionsToBytes(t, docx, TemplateData{
+		"times": 2,
+		"name":  "Ada",
+		"items": []interface{}{"pen", "ink"},
+	}, RenderOptions{})
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+
+	assertInOrder(t, documentXML,
+		"Before",
+		"Copy 1 for Ada",
+		"Copy 2 for Ada",
+		"PEN",
+		"INK",
+		"2 enclosed",
+		"After",
+	)
+	for _, leftover := range []string{"{{", "hidden"} {
+		if strings.Contains(documentXML, leftover) {
+			t.Errorf("unexpected %q in output: %s", leftover, documentXML)
+		}
+	}
+}
+
+func TestBlockDirectiveErrors(t *testing.T) {
+	noop := func(block *Block) ([]BodyElement, error) { return nil, nil }
+	for _, name := range []string{"", "for", "end", "cache", "audience", "assembledClause", "bad-name", "1st"} {
+		if err := RegisterBlockDirective(name, noop); err == nil {
+			t.Errorf("expected an error registering %q", name)
+		}
+	}
+	if err := RegisterBlockDirective("testNil", nil); err == nil {
+		t.Error("expected an error registering a nil directive")
+	}
+
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>{{testRepeat "x"}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>text</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`)
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), "block directive testRepeat: count must be an integer") {
+		t.Fatalf("expected the directive error, got %v", err)
+	}
+}
+
+func TestValidateTemplate_BlockDirectives(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p><w:r><w:t>{{testRepeat 2}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{name}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>
+		`),
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes: docx,
+		Strict:    true,
+		Schema: ValidationSchema{
+			Fields:    []FieldDefinition{{Path: "name", Type: "string"}},
+			Functions: functionDefinitionsFromRegistry(nil),
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+	if len(result.Issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", result.Issues)
+	}
+}
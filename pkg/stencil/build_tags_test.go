@@ -0,0 +1,63 @@
+package stencil
+
+import (
+	"go/build"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// skipWithoutFunction skips a test that needs a built-in function the build
+// tags leave out, such as html() with stencil_lite.
+func skipWithoutFunction(t *testing.T, name string) {
+	t.Helper()
+	if _, ok := GetDefaultFunctionRegistry().GetFunction(name); !ok {
+		t.Skipf("%s() is not part of this build", name)
+	}
+}
+
+// TestBuildTagsLeaveOutPackages checks that the tags for the optional
+// subsystems keep their standard library packages out of the library and
+// the packages it imports from the module.
+func TestBuildTagsLeaveOutPackages(t *testing.T) {
+	const modulePath = "github.com/benjaminschreck/go-stencil/"
+
+	tests := []struct {
+		tag       string
+		forbidden []string
+	}{
+		{tag: "stencil_nohttp", forbidden: []string{"net/http"}},
+		{tag: "stencil_noimages", forbidden: []string{"image"}},
+		{tag: "stencil_lite", forbidden: []string{"net/http", "image"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			context := build.Default
+			context.BuildTags = []string{tt.tag}
+
+			seen := make(map[string]bool)
+			var visit func(dir string)
+			visit = func(dir string) {
+				if seen[dir] {
+					return
+				}
+				seen[dir] = true
+				pkg, err := context.ImportDir(dir, 0)
+				if err != nil {
+					t.Fatalf("failed to read %s: %v", dir, err)
+				}
+				for _, path := range pkg.Imports {
+					for _, forbidden := range tt.forbidden {
+						if path == forbidden || strings.HasPrefix(path, forbidden+"/") {
+							t.Errorf("%s imports %s with the %s tag", dir, path, tt.tag)
+						}
+					}
+					if rest, ok := strings.CutPrefix(path, modulePath+"pkg/stencil/"); ok {
+						visit(filepath.Join(".", filepath.FromSlash(rest)))
+					}
+				}
+			}
+			visit(".")
+		})
+	}
+}
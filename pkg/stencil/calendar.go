@@ -0,0 +1,85 @@
+package stencil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendar builds a month-grid table for calendar(year, month, events,
+// locale). Each event is a map with a "date" and a "title"; its title is
+// listed in the cell of its day, in the order of the events. Events outside
+// the month are skipped.
+func calendar(args ...interface{}) (interface{}, error) {
+	year, ok := toInt(args[0])
+	if !ok {
+		return nil, fmt.Errorf("calendar: year must be an integer, got %v", args[0])
+	}
+	month, ok := toInt(args[1])
+	if !ok || month < 1 || month > 12 {
+		return nil, fmt.Errorf("calendar: month must be an integer from 1 to 12, got %v", args[1])
+	}
+	locale := ""
+	if len(args) > 3 && args[3] != nil {
+		locale = FormatValue(args[3])
+	}
+
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	days := first.AddDate(0, 1, -1).Day()
+
+	events := make(map[int][]string)
+	if len(args) > 2 && args[2] != nil {
+		items, err := toSlice(args[2])
+		if err != nil {
+			return nil, fmt.Errorf("calendar: events must be a list, got %T", args[2])
+		}
+		for i, item := range items {
+			date, err := parseDate(accessMapField(item, "date"))
+			if err != nil {
+				return nil, fmt.Errorf("calendar: event %d: %w", i, err)
+			}
+			if date.Year() != year || int(date.Month()) != month {
+				continue
+			}
+			events[date.Day()] = append(events[date.Day()], FormatValue(accessMapField(item, "title")))
+		}
+	}
+
+	weekStart := calendarWeekStart(locale)
+	offset := (int(first.Weekday()) - int(weekStart) + 7) % 7
+	weeks := (offset + days + 6) / 7
+
+	table := NewTable(weeks+1, 7)
+	for col := 0; col < 7; col++ {
+		weekday := first.AddDate(0, 0, col-offset)
+		table.Cell(0, col).SetText(localizedWeekday(weekday, locale, true), ParagraphOptions{
+			Alignment: "center",
+			Run:       RunProps{Bold: true},
+		})
+	}
+	for day := 1; day <= days; day++ {
+		index := offset + day - 1
+		cell := table.Cell(index/7+1, index%7)
+		cell.SetText(strconv.Itoa(day), ParagraphOptions{Run: RunProps{Bold: true}})
+		for _, title := range events[day] {
+			cell.Paragraphs = append(cell.Paragraphs, *NewParagraph(title, ParagraphOptions{}))
+		}
+	}
+
+	return &OOXMLFragment{Content: table}, nil
+}
+
+// calendarWeekStart returns the first day of the week in locale: Sunday in
+// the United States, Canada and Japan, Monday elsewhere.
+func calendarWeekStart(locale string) time.Weekday {
+	lang := localeLanguage(locale)
+	region := ""
+	if idx := strings.IndexAny(locale, "-_"); idx > 0 {
+		region = strings.ToUpper(locale[idx+1:])
+	}
+	if lang == "ja" || (lang == "en" && (region == "US" || region == "CA")) {
+		return time.Sunday
+	}
+	return time.Monday
+}
@@ -0,0 +1,83 @@
+//go:build !stencil_nocharts && !stencil_lite
+
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar(t *testing.T) {
+	events := []interface{}{
+		map[string]interface{}{"date": "2026-03-02", "title": "Kickoff"},
+		map[string]interface{}{"date": time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC), "title": "Review"},
+		map[string]interface{}{"date": "2026-04-01", "title": "Next month"},
+	}
+
+	result, err := calendar(2026, 3, events, "de-DE")
+	if err != nil {
+		t.Fatalf("calendar() error = %v", err)
+	}
+	table := result.(*OOXMLFragment).Content.(*Table)
+
+	// March 2026 starts on a Sunday, so a Monday-first grid needs 6 weeks
+	if len(table.Rows) != 7 {
+		t.Fatalf("expected a header row and 6 weeks, got %d rows", len(table.Rows))
+	}
+	if got := table.Cell(0, 0).Paragraphs[0].GetText(); got != "Mo" {
+		t.Errorf("first weekday = %q, want Mo", got)
+	}
+	if got := table.Cell(1, 5).Paragraphs[0].GetText(); got != "" {
+		t.Errorf("cell before the first day = %q, want empty", got)
+	}
+	if got := table.Cell(1, 6).Paragraphs[0].GetText(); got != "1" {
+		t.Errorf("first day cell = %q, want 1", got)
+	}
+	cell := table.Cell(2, 0)
+	var texts []string
+	for _, para := range cell.Paragraphs {
+		texts = append(texts, para.GetText())
+	}
+	if strings.Join(texts, "|") != "2|Kickoff|Review" {
+		t.Errorf("event cell = %v", texts)
+	}
+	if got := table.Cell(6, 1).Paragraphs[0].GetText(); got != "31" {
+		t.Errorf("last day cell = %q, want 31", got)
+	}
+
+	// Weeks start on Sunday in the United States
+	result, err = calendar(2026, 3, nil, "en-US")
+	if err != nil {
+		t.Fatalf("calendar() error = %v", err)
+	}
+	table = result.(*OOXMLFragment).Content.(*Table)
+	if len(table.Rows) != 6 || table.Cell(0, 0).Paragraphs[0].GetText() != "Sun" || table.Cell(1, 0).Paragraphs[0].GetText() != "1" {
+		t.Errorf("unexpected en-US grid: %d rows, %q", len(table.Rows), table.Cell(0, 0).Paragraphs[0].GetText())
+	}
+
+	if _, err := calendar(2026, 13); err == nil {
+		t.Error("expected an error for month 13")
+	}
+	if _, err := calendar(2026, 3, []interface{}{map[string]interface{}{"date": "soon"}}); err == nil {
+		t.Error("expected an error for an unparsable event date")
+	}
+}
+
+func TestCalendarInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{calendar(2026, 2, events)}}`})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"events": []interface{}{map[string]interface{}{"date": "2026-02-14", "title": "Offsite"}},
+	}))
+	for _, want := range []string{"<w:tbl>", ">Mon<", ">28<", ">Offsite<"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("missing %s in %s", want, documentXML)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Captions number the figures and tables of a document with SEQ fields:
+// {{caption("Figure", "Quarterly revenue", "revenue")}} renders
+// "Figure 2: Quarterly revenue" and {{figRef("revenue")}} anywhere in the
+// document renders "Figure 2". Numbers are assigned after rendering, so
+// captions left out by conditionals shift the later numbers and every
+// reference follows.
+
+// captionStyle is the name of the built-in paragraph style of captions.
+const captionStyle = "Caption"
+
+// captionMarkerRegex matches the placeholders rendered by caption() and
+// figRef(): {{CAPTION_MARKER:number::<id>}}, the result of the SEQ field of
+// a caption, and {{CAPTION_MARKER:ref::<id>}}. The empty group keeps the
+// layout of the clause and exhibit markers.
+var captionMarkerRegex = regexp.MustCompile(`\{\{CAPTION_MARKER:(number|ref):([^:}]*):([^}]*)\}\}`)
+
+// captionLabelRegex matches labels usable as the identifier of a SEQ field.
+var captionLabelRegex = regexp.MustCompile(`^\pL[\pL\pN]*$`)
+
+// captionField is the caption rendered by caption().
+type captionField struct {
+	Label string
+	Text  string
+	ID    string
+}
+
+// caption implements caption(label, text[, id]). The id is only needed to
+// refer to the caption with figRef().
+func caption(args ...interface{}) (interface{}, error) {
+	label, ok := args[0].(string)
+	if !ok || !captionLabelRegex.MatchString(label) {
+		return nil, fmt.Errorf("caption: label must be a single word such as \"Figure\" or \"Table\", got %v", args[0])
+	}
+	field := &captionField{Label: label}
+	if args[1] != nil {
+		field.Text = FormatValue(args[1])
+	}
+	if len(args) > 2 && args[2] != nil {
+		id, err := referenceID("caption", args[2])
+		if err != nil {
+			return nil, err
+		}
+		field.ID = id
+	}
+	return &OOXMLFragment{Content: field}, nil
+}
+
+// figureReference renders the placeholder of figRef(id).
+func figureReference(args ...interface{}) (interface{}, error) {
+	id, err := referenceID("figRef", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("{{CAPTION_MARKER:ref::%s}}", id), nil
+}
+
+// runs returns the runs of the caption: the label, a SEQ field numbering
+// the captions with that label and the text.
+func (c *captionField) runs(templateRun *Run, ctx *renderContext) []Run {
+	textRun := func(content string) Run {
+		return Run{
+			Properties: templateRun.Properties,
+			Attrs:      templateRun.Attrs,
+			Text:       &Text{Space: "preserve", Content: content},
+		}
+	}
+
+	field := &FieldCode{
+		Instruction: `SEQ ` + c.Label + ` \* ARABIC`,
+		Result:      fmt.Sprintf("{{CAPTION_MARKER:number::%s}}", c.ID),
+	}
+	runs := []Run{textRun(c.Label + " ")}
+	runs = append(runs, fieldCodeRuns(field, templateRun, ctx)...)
+	if c.Text != "" {
+		runs = append(runs, textRun(": "+c.Text))
+	}
+	return runs
+}
+
+// recordCaption records the label and number of the caption whose SEQ
+// field result is runs.
+func (state *seqFieldRenumberState) recordCaption(runs []*Run, number string) {
+	var result strings.Builder
+	for _, run := range runs {
+		if run != nil && run.Text != nil {
+			result.WriteString(run.Text.Content)
+		}
+	}
+	match := captionMarkerRegex.FindStringSubmatch(result.String())
+	if match == nil || match[1] != "number" || match[3] == "" {
+		return
+	}
+	id := match[3]
+	if _, exists := state.captions[id]; exists && state.duplicateCaption == "" {
+		state.duplicateCaption = id
+	}
+	state.captions[id] = number
+}
+
+// processCaptionNumbers numbers the caption() captions of a document in
+// order of appearance, per label, and replaces the figRef() markers with
+// the label and number of their caption. Caption paragraphs without a
+// style of their own get the paragraph style styleID. A caption id may be
+// used once; a reference to an id without a caption is an error.
+func processCaptionNumbers(doc *Document, styleID string) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	paragraphs := documentParagraphs(doc)
+	found := false
+	for _, para := range paragraphs {
+		text := para.GetText()
+		if !strings.Contains(text, "CAPTION_MARKER:") {
+			continue
+		}
+		found = true
+		if styleID != "" && strings.Contains(text, "CAPTION_MARKER:number:") && (para.Properties == nil || para.Properties.Style == nil) {
+			props := &ParagraphProperties{}
+			if para.Properties != nil {
+				copied := *para.Properties
+				props = &copied
+			}
+			props.Style = &Style{Val: styleID}
+			para.Properties = props
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	// Number the SEQ fields as renumberSEQFieldsInElements does, so
+	// references count the same captions as the numbers shown.
+	state := &seqFieldRenumberState{
+		counters: make(map[string]int),
+		captions: make(map[string]string),
+	}
+	for _, elem := range doc.Body.Elements {
+		renumberSEQFieldsInBodyElement(elem, state)
+	}
+	if state.duplicateCaption != "" {
+		return fmt.Errorf("caption %q is numbered more than once", state.duplicateCaption)
+	}
+
+	return replaceReferenceMarkers(paragraphs, captionMarkerRegex, "CAPTION_MARKER:", state.captions, "figRef: unknown caption")
+}
+
+// registerCaptionFunctions registers the caption() and figRef() functions
+func registerCaptionFunctions(registry *DefaultFunctionRegistry) {
+	// caption() function - numbers a figure or table caption
+	captionFn := NewSimpleFunction("caption", 2, 3, caption)
+	registry.RegisterFunction(captionFn)
+
+	// figRef() function - refers to the label and number of a caption
+	figRefFn := NewSimpleFunction("figRef", 1, 1, figureReference)
+	registry.RegisterFunction(figRefFn)
+}
@@ -0,0 +1,64 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCaptionNumbering(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Revenue is shown in {{figRef("revenue")}}, costs in {{figRef("costs")}}.`,
+		`{{if withChart}}{{caption("Figure", "Market share")}}{{end}}`,
+		`{{caption("Figure", "Quarterly revenue", "revenue")}}`,
+		`{{caption("Table", "Costs by region", "costs")}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	tests := []struct {
+		withChart bool
+		want      []string
+	}{
+		{true, []string{"Revenue is shown in Figure 2, costs in Table 1.", "Figure 1: Market share", "Figure 2: Quarterly revenue", "Table 1: Costs by region"}},
+		{false, []string{"Revenue is shown in Figure 1, costs in Table 1.", "Figure 1: Quarterly revenue", "Table 1: Costs by region"}},
+	}
+	for _, tt := range tests {
+		output := renderPreparedToBytes(t, tmpl, TemplateData{"withChart": tt.withChart})
+		text := extractTextFromDOCX(t, output)
+		assertInOrder(t, text, tt.want...)
+		if strings.Contains(text, "CAPTION_MARKER") {
+			t.Errorf("unresolved marker in %q", text)
+		}
+
+		documentXML := extractDocumentXMLFromDOCX(t, output)
+		assertInOrder(t, documentXML, `<w:pStyle w:val="Caption">`, `> SEQ Figure \* ARABIC </w:instrText>`, `w:fldCharType="separate"`, `Quarterly revenue`)
+	}
+}
+
+func TestCaptionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"unknown reference", `{{figRef("missing")}}`, `figRef: unknown caption "missing"`},
+		{"duplicate id", `{{caption("Figure", "A", "x")}} {{caption("Figure", "B", "x")}}`, `caption "x" is numbered more than once`},
+		{"invalid label", `{{caption("Fig ure", "A")}}`, "caption: label must be a single word"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Prepare(bytes.NewReader(createSimpleDOCX(t, tt.template)))
+			if err != nil {
+				t.Fatalf("Prepare failed: %v", err)
+			}
+			defer tmpl.Close()
+			if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Render error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
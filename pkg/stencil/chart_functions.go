@@ -0,0 +1,24 @@
+//go:build !stencil_nocharts && !stencil_lite
+
+package stencil
+
+// registerCalendarFunction registers the calendar() function
+func registerCalendarFunction(registry *DefaultFunctionRegistry) {
+	// calendar() function - emits a month-grid table with events
+	calendarFn := NewSimpleFunction("calendar", 2, 4, calendar)
+	registry.RegisterFunction(calendarFn)
+}
+
+// registerTimelineFunction registers the timeline() function
+func registerTimelineFunction(registry *DefaultFunctionRegistry) {
+	// timeline() function - emits a Gantt-style table of task spans
+	timelineFn := NewSimpleFunction("timeline", 3, 4, timeline)
+	registry.RegisterFunction(timelineFn)
+}
+
+// registerOrgChartFunction registers the orgChart() function
+func registerOrgChartFunction(registry *DefaultFunctionRegistry) {
+	// orgChart() function - renders a hierarchy as a nested list
+	orgChartFn := NewSimpleFunction("orgChart", 1, 2, orgChart)
+	registry.RegisterFunction(orgChartFn)
+}
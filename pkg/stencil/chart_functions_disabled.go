@@ -0,0 +1,12 @@
+//go:build stencil_nocharts || stencil_lite
+
+package stencil
+
+// The chart functions calendar(), timeline() and orgChart() are left out
+// when the library is built with the stencil_nocharts or stencil_lite tag.
+
+func registerCalendarFunction(registry *DefaultFunctionRegistry) {}
+
+func registerTimelineFunction(registry *DefaultFunctionRegistry) {}
+
+func registerOrgChartFunction(registry *DefaultFunctionRegistry) {}
@@ -0,0 +1,79 @@
+package stencil
+
+import "fmt"
+
+const (
+	// checklistChecked and checklistUnchecked are the ballot box symbols of
+	// completed and open items.
+	checklistChecked   = "☑"
+	checklistUnchecked = "☐"
+	// checklistSymbolFont is a font with both ballot box symbols.
+	checklistSymbolFont = "Segoe UI Symbol"
+	// checklistSymbolWidth is the width of the symbol column in twips.
+	checklistSymbolWidth = 500
+	// checklistTableWidth is the total width of a checklist table in twips.
+	checklistTableWidth = 9000
+)
+
+// checklist builds a table for checklist(items, strikeDone): one row per
+// item with a checked or unchecked box and the label of the item. Each
+// item is a map with a "label" (or "name" or "title") and a "done" flag,
+// or a string, which is an open item. With strikeDone, the labels of done
+// items are struck through.
+func checklist(args ...interface{}) (interface{}, error) {
+	var items []interface{}
+	if args[0] != nil {
+		var err error
+		items, err = toSlice(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("checklist: items must be a list, got %T", args[0])
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	strikeDone := len(args) > 1 && isTruthy(args[1])
+
+	table := NewTable(len(items), 2)
+	table.Grid.Columns[0].Width = checklistSymbolWidth
+	table.Grid.Columns[1].Width = checklistTableWidth - checklistSymbolWidth
+	for row := range table.Rows {
+		table.Rows[row].Cells[0].Properties.Width.Val = checklistSymbolWidth
+		table.Rows[row].Cells[1].Properties.Width.Val = checklistTableWidth - checklistSymbolWidth
+	}
+
+	for i, item := range items {
+		label, done := checklistItem(item)
+		symbol := checklistUnchecked
+		if done {
+			symbol = checklistChecked
+		}
+		table.Cell(i, 0).SetText(symbol, ParagraphOptions{Alignment: "center", Run: RunProps{Font: checklistSymbolFont}})
+		table.Cell(i, 1).SetText(label, ParagraphOptions{Run: RunProps{Strike: done && strikeDone}})
+	}
+
+	return &OOXMLFragment{Content: table}, nil
+}
+
+// checklistItem returns the label of a checklist item and whether it is
+// done.
+func checklistItem(item interface{}) (string, bool) {
+	if text, ok := item.(string); ok {
+		return text, false
+	}
+	label := accessMapField(item, "label")
+	for _, key := range []string{"name", "title"} {
+		if label != nil {
+			break
+		}
+		label = accessMapField(item, key)
+	}
+	return FormatValue(label), isTruthy(accessMapField(item, "done"))
+}
+
+// registerChecklistFunction registers the checklist() function
+func registerChecklistFunction(registry *DefaultFunctionRegistry) {
+	// checklist() function - emits a table of items with checkboxes
+	checklistFn := NewSimpleFunction("checklist", 1, 2, checklist)
+	registry.RegisterFunction(checklistFn)
+}
@@ -0,0 +1,71 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestChecklist(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"label": "Sign NDA", "done": true},
+		map[string]interface{}{"name": "Set up laptop", "done": false},
+		"Meet the team",
+	}
+
+	result, err := checklist(items, true)
+	if err != nil {
+		t.Fatalf("checklist() error = %v", err)
+	}
+	table := result.(*OOXMLFragment).Content.(*Table)
+	if len(table.Rows) != 3 || len(table.Rows[0].Cells) != 2 {
+		t.Fatalf("expected 3 rows of 2 cells, got %d rows of %d", len(table.Rows), len(table.Rows[0].Cells))
+	}
+	for row, want := range []string{"☑Sign NDA", "☐Set up laptop", "☐Meet the team"} {
+		got := table.Cell(row, 0).Paragraphs[0].GetText() + table.Cell(row, 1).Paragraphs[0].GetText()
+		if got != want {
+			t.Errorf("row %d = %q, want %q", row, got, want)
+		}
+	}
+
+	struck := func(row int) bool {
+		props := table.Cell(row, 1).Paragraphs[0].Runs[0].Properties
+		return props != nil && props.Strike != nil
+	}
+	if !struck(0) || struck(1) || struck(2) {
+		t.Errorf("expected only the done item struck through")
+	}
+
+	result, _ = checklist(items)
+	if props := result.(*OOXMLFragment).Content.(*Table).Cell(0, 1).Paragraphs[0].Runs[0].Properties; props != nil && props.Strike != nil {
+		t.Error("done item struck through without strikeDone")
+	}
+
+	if result, err := checklist(nil); result != nil || err != nil {
+		t.Errorf("checklist(nil) = %v, %v, want nil", result, err)
+	}
+	if _, err := checklist(42); err == nil {
+		t.Error("expected an error for items that are not a list")
+	}
+}
+
+func TestChecklistInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{checklist(steps, true)}}`})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"steps": []interface{}{
+			map[string]interface{}{"label": "Collect badge", "done": true},
+			map[string]interface{}{"label": "Read handbook"},
+		},
+	}))
+	if !strings.Contains(documentXML, "<w:tbl>") || !strings.Contains(documentXML, ">☑<") || !strings.Contains(documentXML, ">☐<") {
+		t.Errorf("expected a checklist table: %s", documentXML)
+	}
+	if strings.Count(documentXML, "<w:strike") != 1 {
+		t.Errorf("expected one struck-through label: %s", documentXML)
+	}
+}
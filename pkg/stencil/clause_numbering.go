@@ -0,0 +1,242 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Clause numbering gives the clauses of a contract stable identifiers:
+// {{clause "limitation-of-liability"}} renders the next hierarchical number,
+// such as 12.3, and {{clauseRef "limitation-of-liability"}} anywhere in the
+// document renders "Section 12.3". Both render placeholders that
+// ProcessClauseNumbers resolves once the whole body is rendered, so
+// references may come before the clause and numbers skip clauses left out
+// by conditionals.
+
+// maxClauseLevel is the deepest clause level, as in 1.2.3.4.5.6.7.8.9.
+const maxClauseLevel = 9
+
+// defaultClauseRefLabel is the word clauseRef() puts before the number.
+const defaultClauseRefLabel = "Section"
+
+// clauseMarkerRegex matches the placeholders rendered by clause() and
+// clauseRef(): {{CLAUSE_MARKER:number:<level>:<id>}} and
+// {{CLAUSE_MARKER:ref:<label>:<id>}}.
+var clauseMarkerRegex = regexp.MustCompile(`\{\{CLAUSE_MARKER:(number|ref):([^:}]*):([^}]*)\}\}`)
+
+// clauseNumber renders the placeholder of clause(id, level). The level is 1
+// for top-level clauses, 2 for their subclauses, and so on.
+func clauseNumber(args ...interface{}) (interface{}, error) {
+	id, err := referenceID("clause", args[0])
+	if err != nil {
+		return nil, err
+	}
+	level := 1
+	if len(args) > 1 && args[1] != nil {
+		var ok bool
+		level, ok = toInt(args[1])
+		if !ok || level < 1 || level > maxClauseLevel {
+			return nil, fmt.Errorf("clause: level must be an integer from 1 to %d, got %v", maxClauseLevel, args[1])
+		}
+	}
+	return fmt.Sprintf("{{CLAUSE_MARKER:number:%d:%s}}", level, id), nil
+}
+
+// clauseReference renders the placeholder of clauseRef(id, label). The label
+// defaults to "Section"; an empty label renders the bare number.
+func clauseReference(args ...interface{}) (interface{}, error) {
+	id, err := referenceID("clauseRef", args[0])
+	if err != nil {
+		return nil, err
+	}
+	label := defaultClauseRefLabel
+	if len(args) > 1 && args[1] != nil {
+		label = FormatValue(args[1])
+		if strings.ContainsAny(label, ":{}") {
+			return nil, fmt.Errorf("clauseRef: label %q must not contain ':', '{' or '}'", label)
+		}
+	}
+	return fmt.Sprintf("{{CLAUSE_MARKER:ref:%s:%s}}", label, id), nil
+}
+
+// referenceID validates the identifier of a clause or exhibit.
+func referenceID(name string, value interface{}) (string, error) {
+	id, ok := value.(string)
+	if !ok || strings.TrimSpace(id) == "" {
+		return "", fmt.Errorf("%s: id must be a non-empty string, got %v", name, value)
+	}
+	if strings.ContainsAny(id, "{}") {
+		return "", fmt.Errorf("%s: id %q must not contain '{' or '}'", name, id)
+	}
+	return id, nil
+}
+
+// ProcessClauseNumbers numbers the clause() markers of a document in order
+// of appearance and replaces the clauseRef() markers with the number of
+// their clause. A clause id may be used once; a reference to an id without
+// a clause is an error.
+func ProcessClauseNumbers(doc *Document) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	paragraphs := documentParagraphs(doc)
+	numbers := make(map[string]string)
+	var counters []int
+	found := false
+	for _, para := range paragraphs {
+		text := para.GetText()
+		if !strings.Contains(text, "CLAUSE_MARKER:") {
+			continue
+		}
+		found = true
+		for _, match := range clauseMarkerRegex.FindAllStringSubmatch(text, -1) {
+			if match[1] != "number" {
+				continue
+			}
+			level, _ := strconv.Atoi(match[2])
+			id := match[3]
+			if _, exists := numbers[id]; exists {
+				return fmt.Errorf("clause %q is numbered more than once", id)
+			}
+			for len(counters) < level {
+				counters = append(counters, 0)
+			}
+			counters = counters[:level]
+			counters[level-1]++
+			parts := make([]string, level)
+			for i, counter := range counters {
+				parts[i] = strconv.Itoa(counter)
+			}
+			numbers[id] = strings.Join(parts, ".")
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	return replaceReferenceMarkers(paragraphs, clauseMarkerRegex, "CLAUSE_MARKER:", numbers, "clauseRef: unknown clause")
+}
+
+// replaceReferenceMarkers replaces the markers matched by pattern in
+// paragraphs with the value of their id, preceded by the label of
+// references. The groups of pattern are the kind ("ref" for references),
+// the label and the id. Markers with an unknown id are left in place and
+// reported with unknownMessage.
+func replaceReferenceMarkers(paragraphs []*Paragraph, pattern *regexp.Regexp, prefix string, values map[string]string, unknownMessage string) error {
+	var resolveErr error
+	replace := func(marker string) string {
+		match := pattern.FindStringSubmatch(marker)
+		value, ok := values[match[3]]
+		if !ok {
+			if resolveErr == nil {
+				resolveErr = fmt.Errorf("%s %q", unknownMessage, match[3])
+			}
+			return marker
+		}
+		if match[1] == "ref" && match[2] != "" {
+			return match[2] + " " + value
+		}
+		return value
+	}
+	for _, para := range paragraphs {
+		replaceParagraphRunText(para, func(text string) string {
+			if !strings.Contains(text, prefix) {
+				return text
+			}
+			return pattern.ReplaceAllStringFunc(text, replace)
+		})
+	}
+	return resolveErr
+}
+
+// documentParagraphs returns the paragraphs of the body of doc, including
+// those in table cells, in document order.
+func documentParagraphs(doc *Document) []*Paragraph {
+	return bodyElementParagraphs(doc.Body.Elements)
+}
+
+// bodyElementParagraphs returns the paragraphs of elements, including those
+// in table cells, in document order.
+func bodyElementParagraphs(elements []BodyElement) []*Paragraph {
+	var paragraphs []*Paragraph
+	for _, elem := range elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			paragraphs = append(paragraphs, e)
+		case *Table:
+			for i := range e.Rows {
+				for j := range e.Rows[i].Cells {
+					for k := range e.Rows[i].Cells[j].Paragraphs {
+						paragraphs = append(paragraphs, &e.Rows[i].Cells[j].Paragraphs[k])
+					}
+				}
+			}
+		}
+	}
+	return paragraphs
+}
+
+// replaceParagraphRunText replaces the text of each run of para, including
+// the runs of hyperlinks, with replace(text). Runs whose text changes are
+// copied, as they may be shared with the template.
+func replaceParagraphRunText(para *Paragraph, replace func(string) string) {
+	replaceRun := func(run *Run) {
+		if run.Text == nil {
+			return
+		}
+		if content := replace(run.Text.Content); content != run.Text.Content {
+			text := *run.Text
+			text.Content = content
+			run.Text = &text
+		}
+	}
+	replaceRuns := func(runs []Run) []Run {
+		copied := append([]Run(nil), runs...)
+		for i := range copied {
+			replaceRun(&copied[i])
+		}
+		return copied
+	}
+
+	if para.Runs != nil {
+		para.Runs = replaceRuns(para.Runs)
+	}
+	if para.Hyperlinks != nil {
+		links := append([]Hyperlink(nil), para.Hyperlinks...)
+		for i := range links {
+			links[i].Runs = replaceRuns(links[i].Runs)
+		}
+		para.Hyperlinks = links
+	}
+	if para.Content != nil {
+		content := make([]ParagraphContent, len(para.Content))
+		for i, item := range para.Content {
+			switch c := item.(type) {
+			case *Run:
+				run := *c
+				replaceRun(&run)
+				item = &run
+			case *Hyperlink:
+				link := *c
+				link.Runs = replaceRuns(c.Runs)
+				item = &link
+			}
+			content[i] = item
+		}
+		para.Content = content
+	}
+}
+
+// registerClauseFunctions registers the clause() and clauseRef() functions
+func registerClauseFunctions(registry *DefaultFunctionRegistry) {
+	// clause() function - numbers a clause with a stable identifier
+	clauseFn := NewSimpleFunction("clause", 1, 2, clauseNumber)
+	registry.RegisterFunction(clauseFn)
+
+	// clauseRef() function - refers to the number of a clause
+	clauseRefFn := NewSimpleFunction("clauseRef", 1, 2, clauseReference)
+	registry.RegisterFunction(clauseRefFn)
+}
@@ -0,0 +1,62 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestClauseNumbering(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`See {{clauseRef "liability"}} and {{clauseRef("fees", "Clause")}}.`,
+		`{{clause "scope"}} Scope`,
+		`{{clause("fees", 2)}} Fees`,
+		`{{if optional}}`,
+		`{{clause "optional", 2}} Optional`,
+		`{{end}}`,
+		`{{clause "liability", 2}} Liability`,
+		`{{clause "term"}} Term`,
+		`{{clause "notice", 2}} Notice`,
+		`Number only: {{clauseRef("notice", "")}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	text := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{"optional": false}))
+	assertInOrder(t, text,
+		"See Section 1.2 and Clause 1.1.",
+		"1 Scope", "1.1 Fees", "1.2 Liability", "2 Term", "2.1 Notice",
+		"Number only: 2.1",
+	)
+	if strings.Contains(text, "Optional") || strings.Contains(text, "CLAUSE_MARKER") {
+		t.Errorf("unexpected output: %q", text)
+	}
+}
+
+func TestClauseNumberingErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		paragraphs []string
+		want       string
+	}{
+		{"unknown reference", []string{`{{clauseRef "missing"}}`}, `unknown clause "missing"`},
+		{"duplicate id", []string{`{{clause "a"}}`, `{{clause "a"}}`}, `numbered more than once`},
+		{"invalid level", []string{`{{clause "a", 10}}`}, `level must be an integer`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, tt.paragraphs)))
+			if err != nil {
+				t.Fatalf("Prepare failed: %v", err)
+			}
+			defer tmpl.Close()
+			_, err = tmpl.Render(TemplateData{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Render error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package stencil
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Text sorted by sortBy(), groupBy(), the order by clause of {{for}} and
+// sortTable() follows a collation rather than the byte order of the text:
+// letters compare first, ignoring accents and case, then accents and last
+// case, so "Ärger" sorts next to "Arbeit" rather than after "Zoo". Without a
+// locale (RenderOptions.Language) this root order applies. The library
+// depends on the standard library only, so this is not the full Unicode
+// Collation Algorithm of golang.org/x/text/collate: it knows the accented
+// Latin letters below and a few expansions such as ß, and tailors the
+// languages of collationTailorings, which sort letters on their own, such as
+// Swedish å, ä and ö after z or Spanish ñ after n. Other letters, such as
+// those of other scripts, sort by their code point after Latin letters with
+// a lower one. Spaces and punctuation sort before digits, and digits before
+// letters.
+
+// collationAccents lists the letters with diacritics by the letter they
+// sort as; the position in the list sets their order when the letters are
+// otherwise equal.
+var collationAccents = map[rune]string{
+	'a': "áàâǎăãåąāä",
+	'c': "ćĉčċç",
+	'd': "ďđð",
+	'e': "éèêěĕẽėęēë",
+	'g': "ğĝġģ",
+	'h': "ĥħ",
+	'i': "íìîǐĭĩįīïı",
+	'j': "ĵ",
+	'k': "ķ",
+	'l': "ĺľļŀł",
+	'n': "ńǹňñņ",
+	'o': "óòôǒŏõőøōö",
+	'r': "ŕřŗ",
+	's': "śŝšşș",
+	't': "ťţțŧ",
+	'u': "úùûǔŭũůűųūü",
+	'w': "ŵ",
+	'y': "ýỳŷÿ",
+	'z': "źžż",
+}
+
+// collationExpansions lists the letters that sort as two letters.
+var collationExpansions = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'þ': "th",
+	'ĳ': "ij",
+}
+
+// collationTailorings lists, by language, the letters that sort as letters
+// of their own, with the letter they follow.
+var collationTailorings = map[string]map[rune]collationTailoring{
+	"sv": swedishCollation,
+	"fi": swedishCollation,
+	"da": danishCollation,
+	"nb": danishCollation,
+	"nn": danishCollation,
+	"no": danishCollation,
+	"es": {'ñ': {after: 'n', rank: 1}},
+}
+
+// collationTailoring sorts a letter as the rank-th letter after the letter
+// after.
+type collationTailoring struct {
+	after rune
+	rank  int
+}
+
+var swedishCollation = map[rune]collationTailoring{
+	'å': {after: 'z', rank: 1},
+	'ä': {after: 'z', rank: 2},
+	'æ': {after: 'z', rank: 2},
+	'ö': {after: 'z', rank: 3},
+	'ø': {after: 'z', rank: 3},
+}
+
+var danishCollation = map[rune]collationTailoring{
+	'æ': {after: 'z', rank: 1},
+	'ä': {after: 'z', rank: 1},
+	'ø': {after: 'z', rank: 2},
+	'ö': {after: 'z', rank: 2},
+	'å': {after: 'z', rank: 3},
+}
+
+// collationElement is the sort weight of a letter without its accent and
+// the order of its accent.
+type collationElement struct {
+	base   rune
+	accent int
+}
+
+// collationElements maps each letter with a diacritic to its base letter
+// and accent order.
+var collationElements = func() map[rune]collationElement {
+	elements := make(map[rune]collationElement)
+	for base, letters := range collationAccents {
+		for i, letter := range []rune(letters) {
+			elements[letter] = collationElement{base: base, accent: i + 1}
+		}
+	}
+	return elements
+}()
+
+// Primary weights of the kinds of characters: spaces and punctuation sort
+// before digits, and digits before letters.
+const (
+	collationDigitWeight  = 0x200000
+	collationLetterWeight = 0x400000
+)
+
+// collationKey holds the weights a string is compared by: its letters,
+// then their accents, then their case.
+type collationKey struct {
+	primary, secondary, tertiary []int
+}
+
+// newCollationKey returns the collation key of s in the collation of
+// language.
+func newCollationKey(s, language string) collationKey {
+	tailoring := collationTailorings[language]
+	key := collationKey{
+		primary:   make([]int, 0, len(s)),
+		secondary: make([]int, 0, len(s)),
+		tertiary:  make([]int, 0, len(s)),
+	}
+	add := func(primary, secondary, tertiary int) {
+		key.primary = append(key.primary, primary)
+		key.secondary = append(key.secondary, secondary)
+		key.tertiary = append(key.tertiary, tertiary)
+	}
+	for _, r := range s {
+		upper := 0
+		if unicode.IsUpper(r) {
+			upper = 1
+		}
+		lower := unicode.ToLower(r)
+
+		if t, ok := tailoring[lower]; ok {
+			add(collationLetterWeight+4*int(t.after)+t.rank, 0, upper)
+			continue
+		}
+		if expansion, ok := collationExpansions[lower]; ok {
+			for _, letter := range expansion {
+				add(collationLetterWeight+4*int(letter), 1, upper)
+			}
+			continue
+		}
+		switch {
+		case unicode.IsLetter(lower):
+			element, ok := collationElements[lower]
+			if !ok {
+				element = collationElement{base: lower}
+			}
+			add(collationLetterWeight+4*int(element.base), element.accent, upper)
+		case unicode.IsDigit(lower):
+			add(collationDigitWeight+int(lower), 0, 0)
+		default:
+			add(int(lower), 0, 0)
+		}
+	}
+	return key
+}
+
+// compareCollated compares a and b in the collation of the language of
+// locale. Strings that collate equally are ordered by their bytes, so the
+// order is total.
+func compareCollated(a, b, locale string) int {
+	language := localeLanguage(locale)
+	keyA, keyB := newCollationKey(a, language), newCollationKey(b, language)
+	if c := slices.Compare(keyA.primary, keyB.primary); c != 0 {
+		return c
+	}
+	if c := slices.Compare(keyA.secondary, keyB.secondary); c != 0 {
+		return c
+	}
+	if c := slices.Compare(keyA.tertiary, keyB.tertiary); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,132 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompareCollated(t *testing.T) {
+	tests := []struct {
+		locale string
+		sorted []string
+	}{
+		{"de-DE", []string{"Arbeit", "Ärger", "Brot", "Zoo"}},
+		{"de", []string{"Muller", "Müller", "Mutter"}},
+		{"de", []string{"Strasse", "Straße", "Strassen"}},
+		{"de", []string{"apple", "Apple", "äpple", "Äpple"}},
+		{"en", []string{"resume", "Resume", "résumé", "Résumé"}},
+		{"en", []string{"cote", "coté", "côte", "côté", "crème"}},
+		{"en", []string{" x", "-x", "1x", "9x", "a"}},
+		{"fr", []string{"Émile", "Eve", "Zoé"}},
+		{"sv-SE", []string{"Arbeit", "Zoo", "Åsa", "Ärlig", "Östen"}},
+		{"fi", []string{"Zacharias", "Äiti", "Öljy"}},
+		{"da", []string{"Zoo", "Æble", "Øl", "Ål"}},
+		{"nb-NO", []string{"Zebra", "Ærlig", "Ørn", "Åse"}},
+		{"es", []string{"nube", "ñandú", "oro"}},
+		{"ru", []string{"Zoo", "Анна", "Борис"}},
+		{"", []string{"Arbeit", "Ärger", "brot", "Zoo"}},
+		{"", []string{"Strasse", "Straße", "Strassen"}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < len(tt.sorted)-1; i++ {
+			a, b := tt.sorted[i], tt.sorted[i+1]
+			if c := compareCollated(a, b, tt.locale); c >= 0 {
+				t.Errorf("%s: compareCollated(%q, %q) = %d, want < 0", tt.locale, a, b, c)
+			}
+			if c := compareCollated(b, a, tt.locale); c <= 0 {
+				t.Errorf("%s: compareCollated(%q, %q) = %d, want > 0", tt.locale, b, a, c)
+			}
+		}
+	}
+	if c := compareCollated("Ärger", "Ärger", "de"); c != 0 {
+		t.Errorf("compareCollated of equal strings = %d, want 0", c)
+	}
+}
+
+func TestSortingFollowsRenderLocale(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for name in sortBy(names, n -> n)}}{{name}},{{end}}`,
+		`{{for name in names order by name}}{{name}};{{end}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	data := TemplateData{"names": []interface{}{"Zander", "Özil", "Ahrens", "Ärmel", "Oswald"}}
+
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", "Ahrens,Ärmel,Oswald,Özil,Zander,Ahrens;Ärmel;Oswald;Özil;Zander;"},
+		{"de-DE", "Ahrens,Ärmel,Oswald,Özil,Zander,Ahrens;Ärmel;Oswald;Özil;Zander;"},
+		{"sv", "Ahrens,Oswald,Zander,Ärmel,Özil,Ahrens;Oswald;Zander;Ärmel;Özil;"},
+	}
+	for _, tt := range tests {
+		output := renderPreparedWithOptionsToBytes(t, tmpl, data, RenderOptions{Language: tt.language})
+		if got := extractTextFromDOCX(t, output); got != tt.want {
+			t.Errorf("language %q: got %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestSortTableFollowsRenderLocale(t *testing.T) {
+	row := func(cells ...string) string {
+		var b strings.Builder
+		b.WriteString(`<w:tr>`)
+		for _, cell := range cells {
+			b.WriteString(`<w:tc><w:p><w:r><w:t xml:space="preserve">` + cell + `</w:t></w:r></w:p></w:tc>`)
+		}
+		b.WriteString(`</w:tr>`)
+		return b.String()
+	}
+	docx := createDOCXWithBodyXML(t, `<w:tbl>`+
+		row("Name{{sortTable()}}")+
+		row("{{for name in names}}")+
+		row("{{name}}")+
+		row("{{end}}")+
+		`</w:tbl>`)
+	data := TemplateData{"names": []interface{}{"zeta", "Ärmel", "beta", "alpha"}}
+
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{Language: "de"})
+	assertInOrder(t, extractDocumentXMLFromDOCX(t, output), ">alpha<", ">Ärmel<", ">beta<", ">zeta<")
+
+	output = renderWithOptionsToBytes(t, docx, data, RenderOptions{Language: "sv"})
+	assertInOrder(t, extractDocumentXMLFromDOCX(t, output), ">alpha<", ">beta<", ">zeta<", ">Ärmel<")
+
+	output = renderWithOptionsToBytes(t, docx, data, RenderOptions{})
+	assertInOrder(t, extractDocumentXMLFromDOCX(t, output), ">alpha<", ">Ärmel<", ">beta<", ">zeta<")
+}
+
+func TestGroupByFollowsRenderLocale(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for g in groupBy(people, p -> p.city)}}{{g.key}}:{{for p in g.items}}{{p.name}} {{end}}|{{end}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	person := func(name, city string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "city": city}
+	}
+	data := TemplateData{"people": []interface{}{
+		person("Ada", "Zürich"), person("Ben", "Örebro"), person("Cleo", "Zürich"), person("Dan", "Berlin"),
+	}}
+
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", "Berlin:Dan |Örebro:Ben |Zürich:Ada Cleo |"},
+		{"sv", "Berlin:Dan |Zürich:Ada Cleo |Örebro:Ben |"},
+	}
+	for _, tt := range tests {
+		output := renderPreparedWithOptionsToBytes(t, tmpl, data, RenderOptions{Language: tt.language})
+		if got := extractTextFromDOCX(t, output); got != tt.want {
+			t.Errorf("language %q: got %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
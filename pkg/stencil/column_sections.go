@@ -0,0 +1,294 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The built-in {{columns 2}}...{{end}} block directive sets its content in
+// columns, such as the text of an executive summary. The content becomes a
+// continuous section, and the text after it continues in a continuous
+// section as well, which makes Word balance the columns to equal heights
+// without manual column breaks. An optional second argument sets the space
+// between the columns, in points or with a unit: {{columns 3, "1cm"}}.
+//
+// The tag only opens the block when its first argument is a number, so a
+// variable named columns can still be used in other expressions.
+
+const columnsDirectiveName = "columns"
+
+const (
+	maxColumns = 12
+
+	// defaultColumnSpacing is the space between columns Word uses, in
+	// twips (0.5in).
+	defaultColumnSpacing = 720
+)
+
+var (
+	// columnsMarkerRegex matches the paragraphs the columns directive puts
+	// around its content: {{COLUMNS_MARKER:start}} and
+	// {{COLUMNS_MARKER:end:<columns>:<spacing>}}.
+	columnsMarkerRegex = regexp.MustCompile(`^\{\{COLUMNS_MARKER:(start|end(?::(\d+):(\d+))?)\}\}$`)
+
+	sectionTypeRegex    = regexp.MustCompile(`(?s)<w:type\b[^>]*?(?:/>|>.*?</w:type>)`)
+	sectionColumnsRegex = regexp.MustCompile(`(?s)<w:cols\b[^>]*?(?:/>|>.*?</w:cols>)`)
+
+	// sectionAfterTypeRegex and sectionAfterColumnsRegex match the section
+	// properties that follow w:type and w:cols in the schema order.
+	sectionAfterTypeRegex    = regexp.MustCompile(`<w:(pgSz|pgMar|paperSrc|pgBorders|lnNumType|pgNumType|cols|formProt|vAlign|noEndnote|titlePg|textDirection|bidi|rtlGutter|docGrid|printerSettings|sectPrChange)\b`)
+	sectionAfterColumnsRegex = regexp.MustCompile(`<w:(formProt|vAlign|noEndnote|titlePg|textDirection|bidi|rtlGutter|docGrid|printerSettings|sectPrChange)\b`)
+)
+
+func init() {
+	blockDirectives.directives[columnsDirectiveName] = columnsBlockDirective
+}
+
+func columnsBlockDirective(block *Block) ([]BodyElement, error) {
+	if block.ctx != nil && block.ctx.storyPart != "" {
+		return nil, fmt.Errorf("columns are only supported in the main document, not in %s", block.ctx.storyPart)
+	}
+	columns, spacing, err := parseColumnsBlockArgs(block)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := block.Render(block.Data)
+	if err != nil || len(rendered) == 0 {
+		return rendered, err
+	}
+
+	marker := func(text string) BodyElement {
+		return &Paragraph{Runs: []Run{{Text: &Text{Content: text}}}}
+	}
+	elements := append([]BodyElement{marker("{{COLUMNS_MARKER:start}}")}, rendered...)
+	return append(elements, marker(fmt.Sprintf("{{COLUMNS_MARKER:end:%d:%d}}", columns, spacing))), nil
+}
+
+// parseColumnsBlockArgs evaluates the column count and the optional space
+// between the columns of a columns block.
+func parseColumnsBlockArgs(block *Block) (int, int, error) {
+	node, err := ParseExpressionStrict(columnsDirectiveName + "(" + block.Args + ")")
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid columns arguments %q: %w", strings.TrimSpace(block.Args), err)
+	}
+	call, ok := node.(*FunctionCallNode)
+	if !ok || len(call.Args) == 0 || len(call.Args) > 2 {
+		return 0, 0, fmt.Errorf("invalid columns arguments %q", strings.TrimSpace(block.Args))
+	}
+	values := make([]interface{}, len(call.Args))
+	for i, arg := range call.Args {
+		if values[i], err = arg.Evaluate(block.Data); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	columns, ok := toInt(values[0])
+	if !ok || columns < 2 || columns > maxColumns {
+		return 0, 0, fmt.Errorf("column count must be an integer from 2 to %d, got %v", maxColumns, values[0])
+	}
+	spacing := defaultColumnSpacing
+	if len(values) > 1 && values[1] != nil {
+		if spacing, err = parseParagraphLength(values[1]); err != nil {
+			return 0, 0, fmt.Errorf("column spacing: %w", err)
+		}
+		if spacing < 0 {
+			return 0, 0, fmt.Errorf("column spacing must not be negative, got %v", values[1])
+		}
+	}
+	return columns, spacing, nil
+}
+
+// processColumnSections turns the markers of columns blocks in the body
+// into section breaks: the text before a block ends its section, the block
+// ends a continuous section with columns, and the section holding the text
+// after the block starts continuously. The new sections copy the page
+// setup of the section the block is in.
+func processColumnSections(body *Body) error {
+	if body == nil {
+		return nil
+	}
+	found := false
+	for _, para := range bodyElementParagraphs(body.Elements) {
+		if strings.Contains(para.GetText(), "COLUMNS_MARKER:") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	elements := body.Elements
+	var result []BodyElement
+	for i, elem := range elements {
+		if table, ok := elem.(*Table); ok {
+			for _, para := range bodyElementParagraphs([]BodyElement{table}) {
+				if strings.Contains(para.GetText(), "COLUMNS_MARKER:") {
+					return fmt.Errorf("columns blocks must not be inside a table")
+				}
+			}
+		}
+		para, ok := elem.(*Paragraph)
+		var match []string
+		if ok {
+			match = columnsMarkerRegex.FindStringSubmatch(para.GetText())
+		}
+		if match == nil {
+			result = append(result, elem)
+			continue
+		}
+
+		governing := sectionPropertiesAfter(body, elements[i+1:])
+		if match[1] == "start" {
+			// A block at the start of a section needs no break before it
+			if len(result) == 0 || paragraphSectionProperties(result[len(result)-1]) != nil {
+				continue
+			}
+			result = endSectionWith(result, governing)
+			continue
+		}
+
+		columns, _ := strconv.Atoi(match[2])
+		spacing, _ := strconv.Atoi(match[3])
+		result = endSectionWith(result, setSectionColumns(setSectionType(governing, "continuous"), columns, spacing))
+		updateSectionPropertiesAfter(body, elements[i+1:], setSectionType(governing, "continuous"))
+	}
+	body.Elements = result
+	return nil
+}
+
+// endSectionWith ends a section after the last of elements with the section
+// properties sectPr, given by their child elements. The last paragraph
+// takes them unless it is a table or already ends a section.
+func endSectionWith(elements []BodyElement, sectPr string) []BodyElement {
+	var buf bytes.Buffer
+	writeSectionPropertiesXML(&buf, &RawXMLElement{Content: []byte(sectPr)})
+	raw := RawXMLElement{XMLName: xml.Name{Local: "sectPr"}, Content: buf.Bytes()}
+
+	if len(elements) > 0 {
+		if para, ok := elements[len(elements)-1].(*Paragraph); ok && paragraphSectionProperties(para) == nil {
+			props := &ParagraphProperties{}
+			if para.Properties != nil {
+				copied := *para.Properties
+				props = &copied
+			}
+			props.RawXML = append(append([]RawXMLElement(nil), props.RawXML...), raw)
+			copied := *para
+			copied.Properties = props
+			elements[len(elements)-1] = &copied
+			return elements
+		}
+	}
+	return append(elements, &Paragraph{Properties: &ParagraphProperties{RawXML: []RawXMLElement{raw}}})
+}
+
+// paragraphSectionProperties returns the section properties of a paragraph
+// that ends a section, or nil.
+func paragraphSectionProperties(elem BodyElement) *RawXMLElement {
+	para, ok := elem.(*Paragraph)
+	if !ok || para.Properties == nil {
+		return nil
+	}
+	for i := range para.Properties.RawXML {
+		if para.Properties.RawXML[i].XMLName.Local == "sectPr" {
+			return &para.Properties.RawXML[i]
+		}
+	}
+	return nil
+}
+
+// sectionPropertiesAfter returns the child elements of the properties of
+// the section that elements, the rest of body, start in.
+func sectionPropertiesAfter(body *Body, elements []BodyElement) string {
+	for _, elem := range elements {
+		if raw := paragraphSectionProperties(elem); raw != nil {
+			content := convertNamespaceURIsToPrefix(string(raw.Content))
+			loc := sectPrStartTagRegex.FindStringSubmatchIndex(content)
+			if loc == nil || loc[3] > loc[2] {
+				return ""
+			}
+			return strings.TrimSuffix(content[loc[1]:], "</w:sectPr>")
+		}
+	}
+	if body.SectionProperties == nil {
+		return defaultSectionProperties()
+	}
+	return convertNamespaceURIsToPrefix(string(body.SectionProperties.Content))
+}
+
+// updateSectionPropertiesAfter replaces the child elements of the properties
+// of the section that elements, the rest of body, start in. The properties
+// may be shared with the template, so they are replaced, not modified.
+func updateSectionPropertiesAfter(body *Body, elements []BodyElement, sectPr string) {
+	for i, elem := range elements {
+		raw := paragraphSectionProperties(elem)
+		if raw == nil {
+			continue
+		}
+		para := *elem.(*Paragraph)
+		props := *para.Properties
+		props.RawXML = append([]RawXMLElement(nil), props.RawXML...)
+		for j := range props.RawXML {
+			if props.RawXML[j].XMLName.Local != "sectPr" {
+				continue
+			}
+			content := convertNamespaceURIsToPrefix(string(raw.Content))
+			startTag := strings.TrimSuffix(strings.TrimSuffix(sectPrStartTagRegex.FindString(content), "/>"), ">")
+			props.RawXML[j] = RawXMLElement{
+				XMLName: raw.XMLName,
+				Attrs:   raw.Attrs,
+				Content: []byte(startTag + ">" + sectPr + "</w:sectPr>"),
+			}
+			break
+		}
+		para.Properties = &props
+		elements[i] = &para
+		return
+	}
+
+	updated := &RawXMLElement{XMLName: xml.Name{Local: "sectPr"}, Content: []byte(sectPr)}
+	if body.SectionProperties != nil {
+		updated.XMLName = body.SectionProperties.XMLName
+		updated.Attrs = append([]xml.Attr(nil), body.SectionProperties.Attrs...)
+	}
+	body.SectionProperties = updated
+}
+
+// setSectionType returns the section properties sectPr with the given
+// section type, such as "continuous".
+func setSectionType(sectPr, sectionType string) string {
+	head, change := splitSectionPropertiesChange(sectPr)
+	head = sectionTypeRegex.ReplaceAllString(head, "")
+	return insertSectionProperty(head, sectionAfterTypeRegex, `<w:type w:val="`+sectionType+`"/>`) + change
+}
+
+// setSectionColumns returns the section properties sectPr with the given
+// number of equally wide columns and space between them in twips.
+func setSectionColumns(sectPr string, columns, spacing int) string {
+	head, change := splitSectionPropertiesChange(sectPr)
+	head = sectionColumnsRegex.ReplaceAllString(head, "")
+	cols := fmt.Sprintf(`<w:cols w:num="%d" w:space="%d"/>`, columns, spacing)
+	return insertSectionProperty(head, sectionAfterColumnsRegex, cols) + change
+}
+
+// splitSectionPropertiesChange splits off the tracked change of section
+// properties, which holds earlier properties of its own.
+func splitSectionPropertiesChange(sectPr string) (string, string) {
+	if idx := strings.Index(sectPr, "<w:sectPrChange"); idx != -1 {
+		return sectPr[:idx], sectPr[idx:]
+	}
+	return sectPr, ""
+}
+
+// insertSectionProperty inserts element into the section properties sectPr
+// before the first property matched by after.
+func insertSectionProperty(sectPr string, after *regexp.Regexp, element string) string {
+	if loc := after.FindStringIndex(sectPr); loc != nil {
+		return sectPr[:loc[0]] + element + sectPr[loc[0]:]
+	}
+	return sectPr + element
+}
@@ -0,0 +1,80 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestColumnsBlocks(t *testing.T) {
+	paragraph := func(text string) string {
+		return `<w:p><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p>`
+	}
+	docx := createDOCXWithBodyXML(t, paragraph("Executive summary")+
+		paragraph("{{columns 2}}")+
+		paragraph("{{for p in points}}")+
+		paragraph("{{p}}")+
+		paragraph("{{end}}")+
+		paragraph("{{end}}")+
+		paragraph("Details")+
+		paragraph(`{{columns 3, "1cm"}}`)+
+		paragraph("Notes")+
+		paragraph("{{end}}")+
+		paragraph("Columns: {{columns}}")+
+		`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1417" w:right="1417" w:bottom="1134" w:left="1417" w:header="708" w:footer="708" w:gutter="0"/><w:docGrid w:linePitch="360"/></w:sectPr>`)
+
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"points": []interface{}{"Revenue grew", "Costs fell"}, "columns": "n/a"}, RenderOptions{})
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	assertInOrder(t, documentXML,
+		`<w:sectPr><w:pgSz w:w="11906"`,
+		`Executive summary`,
+		`Revenue grew`,
+		`<w:sectPr><w:type w:val="continuous"/><w:pgSz w:w="11906"`,
+		`<w:cols w:num="2" w:space="720"/><w:docGrid`,
+		`Costs fell`,
+		`<w:sectPr><w:type w:val="continuous"/><w:pgSz`,
+		`Details`,
+		`<w:cols w:num="3" w:space="567"/>`,
+		`Notes`,
+		`Columns: n/a`,
+		`<w:sectPr><w:type w:val="continuous"/><w:pgSz w:w="11906"`,
+	)
+	if got := strings.Count(documentXML, "<w:sectPr>"); got != 5 {
+		t.Errorf("got %d section properties, want 5:\n%s", got, documentXML)
+	}
+	if strings.Contains(documentXML, "COLUMNS_MARKER") {
+		t.Errorf("unresolved marker in %s", documentXML)
+	}
+}
+
+func TestColumnsBlockErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		paragraphs []string
+		want       string
+	}{
+		{"one column", []string{"{{columns 1}}", "Text", "{{end}}"}, "column count must be an integer from 2 to 12"},
+		{"invalid spacing", []string{`{{columns 2, "wide"}}`, "Text", "{{end}}"}, "column spacing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, tt.paragraphs)))
+			if err != nil {
+				t.Fatalf("Prepare failed: %v", err)
+			}
+			defer tmpl.Close()
+			if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Render error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetSectionColumns(t *testing.T) {
+	sectPr := `<w:type w:val="nextPage"/><w:pgSz w:w="12240" w:h="15840"/><w:cols w:space="708"/><w:titlePg/><w:sectPrChange w:id="1"><w:sectPr><w:cols w:space="1"/></w:sectPr></w:sectPrChange>`
+	got := setSectionColumns(setSectionType(sectPr, "continuous"), 2, 360)
+	want := `<w:type w:val="continuous"/><w:pgSz w:w="12240" w:h="15840"/><w:cols w:num="2" w:space="360"/><w:titlePg/><w:sectPrChange w:id="1"><w:sectPr><w:cols w:space="1"/></w:sectPr></w:sectPrChange>`
+	if got != want {
+		t.Errorf("section properties =\n%s\nwant\n%s", got, want)
+	}
+}
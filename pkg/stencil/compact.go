@@ -0,0 +1,73 @@
+package stencil
+
+import "strings"
+
+// The built-in {{compact}}...{{end}} block directive removes the paragraphs
+// of its content that are empty after rendering, such as the line of a
+// missing second address line, so letters stay tight without a conditional
+// around every line. Paragraphs with only whitespace count as empty;
+// paragraphs with breaks, drawings, links or a section break are kept.
+//
+// Unlike the other built-in directives the tag takes no arguments, so a
+// bare {{compact}} is always the directive; a variable named compact can
+// still be used in other expressions, such as {{uppercase(compact)}}.
+
+const compactDirectiveName = "compact"
+
+func init() {
+	blockDirectives.directives[compactDirectiveName] = compactBlockDirective
+}
+
+func compactBlockDirective(block *Block) ([]BodyElement, error) {
+	rendered, err := block.Render(block.Data)
+	if err != nil {
+		return nil, err
+	}
+	kept := rendered[:0]
+	for _, elem := range rendered {
+		if para, ok := elem.(*Paragraph); ok && paragraphIsBlank(para) {
+			continue
+		}
+		kept = append(kept, elem)
+	}
+	return kept, nil
+}
+
+// paragraphIsBlank reports whether para shows nothing but whitespace.
+func paragraphIsBlank(para *Paragraph) bool {
+	if para.Properties != nil {
+		for _, raw := range para.Properties.RawXML {
+			if raw.XMLName.Local == "sectPr" {
+				return false
+			}
+		}
+	}
+	if len(para.Hyperlinks) > 0 || !runsAreBlank(para.Runs) {
+		return false
+	}
+	for _, item := range para.Content {
+		switch c := item.(type) {
+		case *Run:
+			if !runsAreBlank([]Run{*c}) {
+				return false
+			}
+		case *Hyperlink, *AlternateContent:
+			return false
+		}
+	}
+	return true
+}
+
+// runsAreBlank reports whether runs hold no text other than whitespace and
+// no breaks or other elements.
+func runsAreBlank(runs []Run) bool {
+	for _, run := range runs {
+		if run.Break != nil || len(run.RawXML) > 0 {
+			return false
+		}
+		if run.Text != nil && strings.TrimSpace(run.Text.Content) != "" {
+			return false
+		}
+	}
+	return true
+}
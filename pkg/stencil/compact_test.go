@@ -0,0 +1,65 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestCompactBlocks(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{compact}}`,
+		`{{name}}`,
+		`{{street}}`,
+		`{{street2}}`,
+		`{{city}}`,
+		`{{end}}`,
+		`{{blank}}`,
+		`Compact: {{uppercase(compact)}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"name":    "Jane Doe",
+		"street":  "1 Main St",
+		"street2": " ",
+		"city":    "Springfield",
+		"blank":   "",
+		"compact": "yes",
+	}))
+
+	assertInOrder(t, output, "Jane Doe", "1 Main St", "Springfield", "Compact: YES")
+	// The empty street2 paragraph is removed; the blank one outside the
+	// block is kept.
+	if got := strings.Count(output, "<w:p>") + strings.Count(output, "<w:p "); got != 5 {
+		t.Errorf("got %d paragraphs, want 5:\n%s", got, output)
+	}
+	if strings.Contains(output, "{{") {
+		t.Errorf("unrendered tags in output:\n%s", output)
+	}
+}
+
+func TestParagraphIsBlank(t *testing.T) {
+	tests := []struct {
+		name string
+		para *Paragraph
+		want bool
+	}{
+		{"empty", &Paragraph{}, true},
+		{"whitespace", &Paragraph{Runs: []Run{{Text: &Text{Content: " \t"}}}}, true},
+		{"text", &Paragraph{Runs: []Run{{Text: &Text{Content: "x"}}}}, false},
+		{"break", &Paragraph{Runs: []Run{{Break: &Break{}}}}, false},
+		{"content text", &Paragraph{Content: []ParagraphContent{&Run{Text: &Text{Content: "x"}}}}, false},
+		{"section break", &Paragraph{Properties: &ParagraphProperties{RawXML: []RawXMLElement{{XMLName: xml.Name{Local: "sectPr"}}}}}, false},
+	}
+	for _, tt := range tests {
+		if got := paragraphIsBlank(tt.para); got != tt.want {
+			t.Errorf("%s: paragraphIsBlank = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
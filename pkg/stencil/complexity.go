@@ -0,0 +1,233 @@
+package stencil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// complexityLoopIterations is the number of items AnalyzeComplexity
+	// assumes for each loop when estimating the render cost.
+	complexityLoopIterations = 10
+	// complexityIncludeCost is the estimated cost of rendering an included
+	// fragment, whose content is not part of the analyzed template.
+	complexityIncludeCost = 25
+)
+
+// ComplexityReport describes the complexity of a template, such as to
+// enforce complexity budgets in CI for templates submitted by other teams.
+type ComplexityReport struct {
+	// Tokens is the number of template tags, such as {{name}} or {{end}}.
+	Tokens int `json:"tokens"`
+	// Expressions is the number of expressions evaluated by the tags.
+	Expressions int `json:"expressions"`
+	// Loops, Conditionals and Includes count the {{for}} loops, the
+	// {{if}}, {{elsif}} and {{unless}} conditions and the {{include}} tags.
+	Loops        int `json:"loops"`
+	Conditionals int `json:"conditionals"`
+	Includes     int `json:"includes"`
+	// MaxLoopDepth is the deepest nesting of {{for}} loops; a loop inside
+	// a loop has depth 2.
+	MaxLoopDepth int `json:"maxLoopDepth"`
+	// MaxBlockDepth is the deepest nesting of control blocks of any kind.
+	MaxBlockDepth int `json:"maxBlockDepth"`
+	// MaxExpressionDepth is the depth of the deepest expression tree: 1 for
+	// a variable or literal, and one more for each field access, operator
+	// or function call around it.
+	MaxExpressionDepth int `json:"maxExpressionDepth"`
+	// Fragments lists the distinct fragments named by {{include}} tags,
+	// sorted. FragmentFanOut is their number.
+	Fragments      []string `json:"fragments"`
+	FragmentFanOut int      `json:"fragmentFanOut"`
+	// DynamicIncludes counts the includes whose fragment name is computed
+	// at render time and cannot be listed in Fragments.
+	DynamicIncludes int `json:"dynamicIncludes"`
+	// EstimatedCost estimates the work of a render in abstract units: each
+	// tag and expression node costs one unit, each include 25 units, and
+	// everything inside a loop is counted 10 times per enclosing loop. It
+	// compares templates with each other; it is not a duration.
+	EstimatedCost int `json:"estimatedCost"`
+}
+
+// AnalyzeComplexity measures the complexity of a DOCX template without
+// rendering it. Tags that cannot be parsed are counted as tokens only; use
+// ValidateTemplateSyntax to find them.
+//
+// Example:
+//
+//	report, err := stencil.AnalyzeComplexity(docxBytes)
+//	if err != nil {
+//	    return err
+//	}
+//	if report.MaxLoopDepth > 2 || report.EstimatedCost > 5000 {
+//	    return fmt.Errorf("template exceeds the complexity budget: %+v", report)
+//	}
+func AnalyzeComplexity(docx []byte) (ComplexityReport, error) {
+	if len(docx) == 0 {
+		return ComplexityReport{}, fmt.Errorf("docx bytes are required")
+	}
+	spans, err := scanDOCXTokenSpans(docx)
+	if err != nil {
+		return ComplexityReport{}, err
+	}
+
+	var report ComplexityReport
+	fragments := make(map[string]bool)
+	var blocks []TokenType
+	loopDepth := 0
+	part := ""
+
+	countExpression := func(node ExpressionNode, multiplier int) {
+		if node == nil {
+			return
+		}
+		report.Expressions++
+		depth, size := expressionComplexity(node)
+		if depth > report.MaxExpressionDepth {
+			report.MaxExpressionDepth = depth
+		}
+		report.EstimatedCost += size * multiplier
+	}
+
+	for _, span := range spans {
+		// Blocks cannot span parts; start each part at the top level
+		if span.Part != part {
+			part = span.Part
+			blocks = blocks[:0]
+			loopDepth = 0
+		}
+		report.Tokens++
+		multiplier := complexityLoopMultiplier(loopDepth)
+		report.EstimatedCost += multiplier
+		if span.Malformed {
+			continue
+		}
+
+		switch span.Token.Type {
+		case TokenVariable:
+			if node, err := ParseExpressionStrict(span.Token.Value); err == nil {
+				countExpression(node, multiplier)
+			}
+		case TokenIf, TokenUnless, TokenElsif:
+			report.Conditionals++
+			if node, err := ParseExpressionStrict(span.Token.Value); err == nil {
+				countExpression(node, multiplier)
+			}
+			if span.Token.Type != TokenElsif {
+				blocks = append(blocks, span.Token.Type)
+			}
+		case TokenFor:
+			report.Loops++
+			if forNode, err := parseForSyntaxWithExpressionParser(span.Token.Value, ParseExpressionStrict); err == nil {
+				countExpression(forNode.Collection, multiplier)
+			}
+			blocks = append(blocks, TokenFor)
+			loopDepth++
+			if loopDepth > report.MaxLoopDepth {
+				report.MaxLoopDepth = loopDepth
+			}
+		case TokenInclude:
+			report.Includes++
+			report.EstimatedCost += complexityIncludeCost * multiplier
+			if name, ok := includedFragmentName(span.Token.Value); ok {
+				fragments[name] = true
+			} else {
+				report.DynamicIncludes++
+			}
+		case TokenBlock, TokenMacro:
+			blocks = append(blocks, span.Token.Type)
+		case TokenEnd, TokenEndMacro:
+			if len(blocks) == 0 {
+				continue
+			}
+			if blocks[len(blocks)-1] == TokenFor {
+				loopDepth--
+			}
+			blocks = blocks[:len(blocks)-1]
+		}
+		if len(blocks) > report.MaxBlockDepth {
+			report.MaxBlockDepth = len(blocks)
+		}
+	}
+
+	report.Fragments = make([]string, 0, len(fragments))
+	for name := range fragments {
+		report.Fragments = append(report.Fragments, name)
+	}
+	sort.Strings(report.Fragments)
+	report.FragmentFanOut = len(report.Fragments)
+	return report, nil
+}
+
+// complexityLoopMultiplier returns how often content at a loop depth is
+// assumed to be rendered.
+func complexityLoopMultiplier(loopDepth int) int {
+	multiplier := 1
+	for i := 0; i < loopDepth; i++ {
+		multiplier *= complexityLoopIterations
+	}
+	return multiplier
+}
+
+// expressionComplexity returns the depth and the number of nodes of an
+// expression tree.
+func expressionComplexity(node ExpressionNode) (depth, size int) {
+	var children []ExpressionNode
+	switch n := node.(type) {
+	case nil:
+		return 0, 0
+	case *FunctionCallNode:
+		children = n.Args
+	case *BinaryOpNode:
+		children = []ExpressionNode{n.Left, n.Right}
+	case *UnaryOpNode:
+		children = []ExpressionNode{n.Operand}
+	case *LambdaNode:
+		children = []ExpressionNode{n.Body}
+	case *FieldAccessNode:
+		children = []ExpressionNode{n.Object}
+	case *IndexAccessNode:
+		children = []ExpressionNode{n.Object, n.Index}
+	case *ForCollectionNode:
+		children = []ExpressionNode{n.Collection, n.Where, n.OrderBy, n.Limit}
+	}
+
+	size = 1
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		childDepth, childSize := expressionComplexity(child)
+		if childDepth > depth {
+			depth = childDepth
+		}
+		size += childSize
+	}
+	return depth + 1, size
+}
+
+// includedFragmentName returns the fragment name of an {{include}} tag when
+// it is a string literal.
+func includedFragmentName(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if node, err := ParseExpressionStrict(value); err == nil {
+		if literal, ok := node.(*LiteralNode); ok {
+			name, ok := literal.Value.(string)
+			return name, ok
+		}
+		return "", false
+	}
+	// Include modifiers follow the name, as in {{include "terms" as "quote"}}
+	if !startsWithQuote(value) {
+		return "", false
+	}
+	_, size := utf8.DecodeRuneInString(value)
+	rest := value[size:]
+	end := strings.IndexFunc(rest, func(r rune) bool { return startsWithQuote(string(r)) })
+	if end < 0 {
+		return "", false
+	}
+	return rest[:end], true
+}
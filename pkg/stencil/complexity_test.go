@@ -0,0 +1,55 @@
+package stencil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeComplexity(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		"Dear {{customer.name}},",
+		"{{for order in orders}}",
+		"{{if order.total > 100}}{{for line in order.lines}}{{uppercase(line.name)}}{{end}}{{end}}",
+		"{{end}}",
+		`{{include "terms"}}`,
+		`{{include "terms"}}{{include "privacy"}}`,
+		"{{include fragmentName}}",
+	})
+
+	report, err := AnalyzeComplexity(docx)
+	if err != nil {
+		t.Fatalf("AnalyzeComplexity failed: %v", err)
+	}
+
+	if report.Tokens != 12 {
+		t.Errorf("Tokens = %d, want 12", report.Tokens)
+	}
+	if report.Loops != 2 || report.Conditionals != 1 || report.Includes != 4 {
+		t.Errorf("Loops, Conditionals, Includes = %d, %d, %d, want 2, 1, 4", report.Loops, report.Conditionals, report.Includes)
+	}
+	if report.MaxLoopDepth != 2 || report.MaxBlockDepth != 3 {
+		t.Errorf("MaxLoopDepth, MaxBlockDepth = %d, %d, want 2, 3", report.MaxLoopDepth, report.MaxBlockDepth)
+	}
+	// uppercase(line.name): function call, field access, variable
+	if report.MaxExpressionDepth != 3 {
+		t.Errorf("MaxExpressionDepth = %d, want 3", report.MaxExpressionDepth)
+	}
+	if !reflect.DeepEqual(report.Fragments, []string{"privacy", "terms"}) || report.FragmentFanOut != 2 || report.DynamicIncludes != 1 {
+		t.Errorf("Fragments = %v, FragmentFanOut = %d, DynamicIncludes = %d", report.Fragments, report.FragmentFanOut, report.DynamicIncludes)
+	}
+
+	flat, err := AnalyzeComplexity(createDOCXWithParagraphs(t, []string{
+		"{{for order in orders}}{{order.id}}{{end}}",
+		"{{for line in lines}}{{line.name}}{{end}}",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flat.MaxLoopDepth != 1 || flat.EstimatedCost >= report.EstimatedCost {
+		t.Errorf("flat loops: MaxLoopDepth = %d, EstimatedCost = %d, want depth 1 and a cost below %d", flat.MaxLoopDepth, flat.EstimatedCost, report.EstimatedCost)
+	}
+
+	if _, err := AnalyzeComplexity(nil); err == nil {
+		t.Error("expected an error for empty input")
+	}
+}
@@ -0,0 +1,76 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// applyComputedFields returns data with the Compute expressions of the
+// fields of schema evaluated and their results set at the paths of their
+// fields (RenderOptions.Schema). The expressions are evaluated in the order
+// of the fields, so a computed field can use those computed before it.
+// Objects missing on the way to a computed field are created; the maps on
+// the way are copied, so data is left unchanged. A computed field inside a
+// collection is an error, since it would have no single value.
+func applyComputedFields(data TemplateData, schema *ValidationSchema) (TemplateData, error) {
+	if schema == nil {
+		return data, nil
+	}
+	collections := schemaCollections(*schema)
+	for _, field := range schema.Fields {
+		if field.Compute == "" {
+			continue
+		}
+		path := stripLiteralIndices(normalizeFieldPath(field.Path))
+		if path == "" {
+			return nil, fmt.Errorf("computed field has no path: %s", field.Compute)
+		}
+		segments := strings.Split(path, ".")
+		for i := 1; i < len(segments); i++ {
+			if parent := strings.Join(segments[:i], "."); collections[parent] {
+				return nil, fmt.Errorf("computed field %s: %s is a collection", path, parent)
+			}
+		}
+
+		expr, err := ParseExpression(field.Compute)
+		if err != nil {
+			return nil, fmt.Errorf("computed field %s: %w", path, err)
+		}
+		value, err := expr.Evaluate(data)
+		if err != nil {
+			return nil, fmt.Errorf("computed field %s: %w", path, err)
+		}
+		updated, err := withComputedValue(data, segments, value)
+		if err != nil {
+			return nil, fmt.Errorf("computed field %s: %w", path, err)
+		}
+		data = updated.(TemplateData)
+	}
+	return data, nil
+}
+
+// withComputedValue returns a copy of object with value set at the field
+// path segments below it, creating the objects that are missing.
+func withComputedValue(object interface{}, segments []string, value interface{}) (interface{}, error) {
+	var fields map[string]interface{}
+	switch m := object.(type) {
+	case TemplateData:
+		fields = m
+	case map[string]interface{}:
+		fields = m
+	case nil:
+		fields = map[string]interface{}{}
+		object = fields
+	default:
+		return nil, fmt.Errorf("cannot set field %s of %T", segments[0], object)
+	}
+
+	if len(segments) == 1 {
+		return copyWithField(object, fields, segments[0], value), nil
+	}
+	child, err := withComputedValue(accessMapField(object, segments[0]), segments[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return copyWithField(object, fields, segments[0], child), nil
+}
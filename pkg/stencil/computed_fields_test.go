@@ -0,0 +1,97 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyComputedFields(t *testing.T) {
+	schema := &ValidationSchema{Fields: []FieldDefinition{
+		{Path: "lines", Type: "object", Collection: true},
+		{Path: "totals.net", Type: "number", Compute: `sum(map("amount", lines))`},
+		{Path: "totals.gross", Type: "number", Compute: `totals.net * 1.5`},
+		{Path: "customer", Type: "object"},
+	}}
+	lines := []interface{}{
+		map[string]interface{}{"amount": 10},
+		map[string]interface{}{"amount": 30},
+	}
+	data := TemplateData{"lines": lines, "__functions__": GetDefaultFunctionRegistry()}
+
+	got, err := applyComputedFields(data, schema)
+	if err != nil {
+		t.Fatalf("applyComputedFields() error = %v", err)
+	}
+	totals, _ := got["totals"].(map[string]interface{})
+	if net, _ := toFloat64(totals["net"]); net != 40 {
+		t.Errorf("totals.net = %v, want 40", totals["net"])
+	}
+	if gross, _ := toFloat64(totals["gross"]); gross != 60 {
+		t.Errorf("totals.gross = %v, want 60", totals["gross"])
+	}
+	if _, ok := data["totals"]; ok {
+		t.Error("data was modified: totals added")
+	}
+	if !reflect.DeepEqual(got["lines"], lines) {
+		t.Errorf("lines = %v", got["lines"])
+	}
+}
+
+func TestApplyComputedFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []FieldDefinition
+		data   TemplateData
+		want   string
+	}{
+		{
+			name:   "invalid expression",
+			fields: []FieldDefinition{{Path: "total", Compute: "1 +"}},
+			want:   "computed field total",
+		},
+		{
+			name: "inside a collection",
+			fields: []FieldDefinition{
+				{Path: "items", Collection: true},
+				{Path: "items.total", Compute: "1"},
+			},
+			want: "items is a collection",
+		},
+		{
+			name:   "parent is not an object",
+			fields: []FieldDefinition{{Path: "title.length", Compute: "1"}},
+			data:   TemplateData{"title": "Invoice"},
+			want:   "cannot set field length of string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyComputedFields(tt.data, &ValidationSchema{Fields: tt.fields})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("applyComputedFields() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWithComputedFields(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{`Total: {{total}} ({{count}} lines)`})
+	schema := &ValidationSchema{Fields: []FieldDefinition{
+		{Path: "lines", Type: "object", Collection: true, Required: true},
+		{Path: "total", Type: "number", Compute: `sum(map("amount", lines))`},
+		{Path: "count", Type: "number", Compute: `length(lines)`},
+	}}
+	data := TemplateData{"lines": []interface{}{
+		map[string]interface{}{"amount": 5},
+		map[string]interface{}{"amount": 7},
+	}, "total": 1}
+
+	if issues := schema.ValidateData(data); len(issues) != 0 {
+		t.Errorf("ValidateData() = %+v, want no issues for computed fields", issues)
+	}
+	text := extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{Schema: schema}))
+	if !strings.Contains(text, "Total: 12 (2 lines)") {
+		t.Errorf("expected computed fields in %q", text)
+	}
+}
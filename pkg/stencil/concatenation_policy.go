@@ -0,0 +1,50 @@
+package stencil
+
+import "fmt"
+
+// ConcatenationPolicy controls the + operator when one operand is a string
+// and the other is not.
+type ConcatenationPolicy string
+
+const (
+	// ConcatenationCoerce converts the other operand to a string the way the
+	// template renders it, so "Total: " + 3 is "Total: 3". It is the default.
+	ConcatenationCoerce ConcatenationPolicy = "coerce"
+	// ConcatenationStrict fails the render instead. Templates convert values
+	// explicitly with str() or format().
+	ConcatenationStrict ConcatenationPolicy = "strict"
+)
+
+// parseConcatenationPolicy returns the policy named s and whether it is known.
+func parseConcatenationPolicy(s string) (ConcatenationPolicy, bool) {
+	switch policy := ConcatenationPolicy(s); policy {
+	case ConcatenationCoerce, ConcatenationStrict:
+		return policy, true
+	default:
+		return "", false
+	}
+}
+
+// concatenationPolicyFromData returns the concatenation policy of the render.
+func concatenationPolicyFromData(data TemplateData) ConcatenationPolicy {
+	if helpers := attachedRenderHelpers(data); helpers != nil && helpers.concatenationPolicy != "" {
+		return helpers.concatenationPolicy
+	}
+	return ConcatenationCoerce
+}
+
+// isMixedConcatenation reports whether exactly one of the operands of + is
+// a string.
+func isMixedConcatenation(left, right interface{}) bool {
+	_, leftString := left.(string)
+	_, rightString := right.(string)
+	return leftString != rightString
+}
+
+// check returns an error when the policy forbids adding left and right.
+func (p ConcatenationPolicy) check(left, right interface{}) error {
+	if p != ConcatenationStrict || !isMixedConcatenation(left, right) {
+		return nil
+	}
+	return fmt.Errorf("cannot concatenate %T and %T; convert the value with str() or format()", left, right)
+}
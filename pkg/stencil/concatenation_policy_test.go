@@ -0,0 +1,87 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConcatenationPolicy(t *testing.T) {
+	data := TemplateData{"count": 3, "price": 2.5, "name": "Ada", "missing": nil}
+
+	tests := []struct {
+		expr       string
+		wantCoerce interface{}
+		strictOK   bool
+	}{
+		{expr: `"Total: " + count`, wantCoerce: "Total: 3"},
+		{expr: `price + " EUR"`, wantCoerce: "2.5 EUR"},
+		{expr: `"Name: " + missing`, wantCoerce: "Name: "},
+		{expr: `"Hello " + name`, wantCoerce: "Hello Ada", strictOK: true},
+		{expr: `"Total: " + str(count)`, wantCoerce: "Total: 3", strictOK: true},
+		{expr: `count + 1`, wantCoerce: 4, strictOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+
+			got, err := node.Evaluate(data)
+			if err != nil || got != tt.wantCoerce {
+				t.Errorf("coerce policy: got %v, %v, want %v", got, err, tt.wantCoerce)
+			}
+
+			strict := newChildTemplateData(data, 1)
+			strict[renderHelpersKey] = &renderHelpers{concatenationPolicy: ConcatenationStrict}
+			got, err = node.Evaluate(strict)
+			if tt.strictOK {
+				if err != nil || got != tt.wantCoerce {
+					t.Errorf("strict policy: got %v, %v, want %v", got, err, tt.wantCoerce)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "cannot concatenate") {
+				t.Errorf("strict policy: error = %v, want a concatenation error", err)
+			}
+		})
+	}
+}
+
+func TestConcatenationPolicyFromConfig(t *testing.T) {
+	docx := createSimpleDOCX(t, "{{\"Items: \" + count}}")
+
+	config := DefaultConfig()
+	config.ConcatenationPolicy = ConcatenationStrict
+	engine := NewWithConfig(config)
+
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	if _, err := tmpl.Render(TemplateData{"count": 3}); err == nil || !strings.Contains(err.Error(), "cannot concatenate string and int") {
+		t.Fatalf("strict policy: error = %v, want a concatenation error", err)
+	}
+
+	plain, err := NewWithConfig(DefaultConfig()).Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer plain.Close()
+	reader, err := plain.Render(TemplateData{"count": 3})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Items: 3") {
+		t.Errorf("text = %q, want it to contain %q", text, "Items: 3")
+	}
+}
@@ -18,9 +18,30 @@ type Config struct {
 	// LogLevel controls the verbosity of logging (debug, info, warn, error)
 	LogLevel string
 	// MaxRenderDepth controls the maximum depth of nested template includes/fragments
+	// when MaxIncludeDepth is not set
 	MaxRenderDepth int
+	// MaxIncludeDepth is the maximum number of nested fragment includes. 0 falls
+	// back to MaxRenderDepth.
+	MaxIncludeDepth int
 	// StrictMode enables strict template validation and error handling
 	StrictMode bool
+	// ValidateOnPrepare runs the syntax validator when a template is prepared.
+	// The issues it finds are returned by PreparedTemplate.Warnings.
+	ValidateOnPrepare bool
+	// ArithmeticPolicy controls division by zero, arithmetic on null and
+	// NaN results in templates prepared by the engine. Empty means
+	// ArithmeticPolicyError.
+	ArithmeticPolicy ArithmeticPolicy
+	// ConcatenationPolicy controls + with a string and a non-string operand
+	// in templates prepared by the engine. Empty means ConcatenationCoerce.
+	ConcatenationPolicy ConcatenationPolicy
+	// MemoryMapTemplates maps template files into memory in PrepareFile
+	// rather than reading them, and copies their unchanged parts into
+	// rendered documents without recompressing them. Files under 64 KiB
+	// are read as before. Template files must not be truncated or rewritten
+	// in place while their templates are in use: the process then crashes
+	// with SIGBUS. Rename new files over them instead.
+	MemoryMapTemplates bool
 }
 
 var (
@@ -77,11 +98,42 @@ func ConfigFromEnvironment() *Config {
 		}
 	}
 
+	// STENCIL_MAX_INCLUDE_DEPTH
+	if val := os.Getenv("STENCIL_MAX_INCLUDE_DEPTH"); val != "" {
+		if depth, err := strconv.Atoi(val); err == nil {
+			config.MaxIncludeDepth = depth
+		}
+	}
+
 	// STENCIL_STRICT_MODE
 	if val := os.Getenv("STENCIL_STRICT_MODE"); val != "" {
 		config.StrictMode = parseBool(val)
 	}
 
+	// STENCIL_VALIDATE_ON_PREPARE
+	if val := os.Getenv("STENCIL_VALIDATE_ON_PREPARE"); val != "" {
+		config.ValidateOnPrepare = parseBool(val)
+	}
+
+	// STENCIL_ARITHMETIC_POLICY
+	if val := os.Getenv("STENCIL_ARITHMETIC_POLICY"); val != "" {
+		if policy, ok := parseArithmeticPolicy(strings.ToLower(strings.TrimSpace(val))); ok {
+			config.ArithmeticPolicy = policy
+		}
+	}
+
+	// STENCIL_CONCATENATION_POLICY
+	if val := os.Getenv("STENCIL_CONCATENATION_POLICY"); val != "" {
+		if policy, ok := parseConcatenationPolicy(strings.ToLower(strings.TrimSpace(val))); ok {
+			config.ConcatenationPolicy = policy
+		}
+	}
+
+	// STENCIL_MEMORY_MAP_TEMPLATES
+	if val := os.Getenv("STENCIL_MEMORY_MAP_TEMPLATES"); val != "" {
+		config.MemoryMapTemplates = parseBool(val)
+	}
+
 	return config
 }
 
@@ -137,9 +189,36 @@ func (c *Config) Validate() error {
 		return errors.New("max render depth must be positive")
 	}
 
+	if c.MaxIncludeDepth < 0 {
+		return errors.New("max include depth cannot be negative")
+	}
+
+	if c.ArithmeticPolicy != "" {
+		if _, ok := parseArithmeticPolicy(string(c.ArithmeticPolicy)); !ok {
+			return errors.New("invalid arithmetic policy: " + string(c.ArithmeticPolicy))
+		}
+	}
+
+	if c.ConcatenationPolicy != "" {
+		if _, ok := parseConcatenationPolicy(string(c.ConcatenationPolicy)); !ok {
+			return errors.New("invalid concatenation policy: " + string(c.ConcatenationPolicy))
+		}
+	}
+
 	return nil
 }
 
+// includeDepthLimit returns the effective maximum include nesting depth.
+func (c *Config) includeDepthLimit() int {
+	if c.MaxIncludeDepth > 0 {
+		return c.MaxIncludeDepth
+	}
+	if c.MaxRenderDepth > 0 {
+		return c.MaxRenderDepth
+	}
+	return DefaultConfig().MaxRenderDepth
+}
+
 // GetGlobalConfig returns the global configuration
 func GetGlobalConfig() *Config {
 	globalConfigMutex.RLock()
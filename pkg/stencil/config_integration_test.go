@@ -70,7 +70,7 @@ func TestConfigurationIntegration(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error for deep nesting, got result: %s", result)
 		}
-		if err != nil && !contains(err.Error(), "maximum render depth exceeded") {
+		if err != nil && !contains(err.Error(), "maximum include depth exceeded") {
 			t.Errorf("Expected max depth error, got: %v", err)
 		}
 	})
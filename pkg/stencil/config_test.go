@@ -80,6 +80,17 @@ func TestConfigFromEnvironment(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "max include depth",
+			envVars: map[string]string{
+				"STENCIL_MAX_INCLUDE_DEPTH": "8",
+			},
+			check: func(t *testing.T, config *Config) {
+				if config.MaxIncludeDepth != 8 {
+					t.Errorf("MaxIncludeDepth = %d, want 8", config.MaxIncludeDepth)
+				}
+			},
+		},
 		{
 			name: "strict mode",
 			envVars: map[string]string{
@@ -91,6 +102,50 @@ func TestConfigFromEnvironment(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "validate on prepare",
+			envVars: map[string]string{
+				"STENCIL_VALIDATE_ON_PREPARE": "true",
+			},
+			check: func(t *testing.T, config *Config) {
+				if !config.ValidateOnPrepare {
+					t.Errorf("ValidateOnPrepare = false, want true")
+				}
+			},
+		},
+		{
+			name: "arithmetic policy",
+			envVars: map[string]string{
+				"STENCIL_ARITHMETIC_POLICY": "Zero",
+			},
+			check: func(t *testing.T, config *Config) {
+				if config.ArithmeticPolicy != ArithmeticPolicyZero {
+					t.Errorf("ArithmeticPolicy = %q, want %q", config.ArithmeticPolicy, ArithmeticPolicyZero)
+				}
+			},
+		},
+		{
+			name: "invalid arithmetic policy",
+			envVars: map[string]string{
+				"STENCIL_ARITHMETIC_POLICY": "ignore",
+			},
+			check: func(t *testing.T, config *Config) {
+				if config.ArithmeticPolicy != "" {
+					t.Errorf("ArithmeticPolicy = %q, want empty (default)", config.ArithmeticPolicy)
+				}
+			},
+		},
+		{
+			name: "concatenation policy",
+			envVars: map[string]string{
+				"STENCIL_CONCATENATION_POLICY": "STRICT",
+			},
+			check: func(t *testing.T, config *Config) {
+				if config.ConcatenationPolicy != ConcatenationStrict {
+					t.Errorf("ConcatenationPolicy = %q, want %q", config.ConcatenationPolicy, ConcatenationStrict)
+				}
+			},
+		},
 		{
 			name: "multiple environment variables",
 			envVars: map[string]string{
@@ -258,6 +313,36 @@ func TestConfigValidation(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "negative max include depth",
+			config: &Config{
+				CacheMaxSize:    100,
+				LogLevel:        "info",
+				MaxRenderDepth:  100,
+				MaxIncludeDepth: -1,
+			},
+			valid: false,
+		},
+		{
+			name: "invalid arithmetic policy",
+			config: &Config{
+				CacheMaxSize:     100,
+				LogLevel:         "info",
+				MaxRenderDepth:   100,
+				ArithmeticPolicy: "ignore",
+			},
+			valid: false,
+		},
+		{
+			name: "invalid concatenation policy",
+			config: &Config{
+				CacheMaxSize:        100,
+				LogLevel:            "info",
+				MaxRenderDepth:      100,
+				ConcatenationPolicy: "loose",
+			},
+			valid: false,
+		},
 		{
 			name: "zero max render depth",
 			config: &Config{
@@ -0,0 +1,158 @@
+package stencil
+
+import (
+	"fmt"
+	"math"
+)
+
+// foldConstants returns node with its subtrees that depend on nothing but
+// literals replaced by literals of their values, such as 0.19 * 100 by 19.
+// With constants, fields of the constants variable, such as
+// constants.vatRate, are treated as literals too. node is not modified, as
+// parsed expressions are shared through the parse cache.
+//
+// Subtrees whose value depends on the arithmetic or concatenation policy of
+// a render, such as 1 / 0 or "Rev " + 3, are left alone. Function calls are
+// never evaluated, as functions may return a different value on each call,
+// but their arguments are folded.
+func foldConstants(node ExpressionNode, constants map[string]interface{}) ExpressionNode {
+	switch n := node.(type) {
+	case *BinaryOpNode:
+		left := foldConstants(n.Left, constants)
+		right := foldConstants(n.Right, constants)
+		foldable := isLiteralNode(left) && isLiteralNode(right)
+		if foldable && n.Operator == "+" {
+			foldable = !isMixedConcatenation(left.(*LiteralNode).Value, right.(*LiteralNode).Value)
+		}
+		if !foldable && left == n.Left && right == n.Right {
+			return n
+		}
+		folded := &BinaryOpNode{Left: left, Operator: n.Operator, Right: right}
+		if !foldable {
+			return folded
+		}
+		return foldedLiteral(folded)
+	case *UnaryOpNode:
+		operand := foldConstants(n.Operand, constants)
+		if !isLiteralNode(operand) {
+			if operand == n.Operand {
+				return n
+			}
+			return &UnaryOpNode{Operator: n.Operator, Operand: operand}
+		}
+		return foldedLiteral(&UnaryOpNode{Operator: n.Operator, Operand: operand})
+	case *FunctionCallNode:
+		var args []ExpressionNode
+		for i, arg := range n.Args {
+			folded := foldConstants(arg, constants)
+			if folded != arg && args == nil {
+				args = append([]ExpressionNode(nil), n.Args...)
+			}
+			if args != nil {
+				args[i] = folded
+			}
+		}
+		if args == nil {
+			return n
+		}
+		return &FunctionCallNode{Name: n.Name, Args: args}
+	case *FieldAccessNode:
+		if constants == nil {
+			return n
+		}
+		if variable, ok := n.Object.(*VariableNode); ok && variable.Name == constantsName {
+			if value, ok := constants[n.Field]; ok {
+				return &LiteralNode{Value: value}
+			}
+			return n
+		}
+		object := foldConstants(n.Object, constants)
+		if !isLiteralNode(object) {
+			return n
+		}
+		return foldedLiteral(&FieldAccessNode{Object: object, Field: n.Field})
+	case *IndexAccessNode:
+		if constants == nil {
+			return n
+		}
+		object := foldConstants(n.Object, constants)
+		index := foldConstants(n.Index, constants)
+		if !isLiteralNode(object) || !isLiteralNode(index) {
+			return n
+		}
+		return foldedLiteral(&IndexAccessNode{Object: object, Index: index})
+	}
+	return node
+}
+
+// foldedLiteral evaluates node, whose operands are literals, into a literal.
+// It returns node itself if the evaluation fails or the value depends on
+// the arithmetic policy.
+func foldedLiteral(node ExpressionNode) ExpressionNode {
+	value, err := node.Evaluate(TemplateData{})
+	if err != nil {
+		return node
+	}
+	if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return node
+	}
+	return &LiteralNode{Value: value}
+}
+
+func isLiteralNode(node ExpressionNode) bool {
+	_, ok := node.(*LiteralNode)
+	return ok
+}
+
+// constantCondition returns the truth value of a condition expression that
+// does not depend on the render data.
+func constantCondition(expr string, constants map[string]interface{}) (bool, bool) {
+	node, err := ParseExpressionStrict(expr)
+	if err != nil {
+		return false, false
+	}
+	literal, ok := foldConstants(node, constants).(*LiteralNode)
+	if !ok {
+		return false, false
+	}
+	return isTruthy(literal.Value), true
+}
+
+// constantConditionIssues returns a warning for each if, elsif and unless
+// tag of spans whose condition is always true or always false, so that a
+// branch is dead.
+func constantConditionIssues(spans []tokenSpan, constants map[string]interface{}) []StencilValidationIssue {
+	var issues []StencilValidationIssue
+	for _, span := range spans {
+		if span.Malformed {
+			continue
+		}
+		var tag string
+		switch span.Token.Type {
+		case TokenIf:
+			tag = "if"
+		case TokenElsif:
+			tag = "elsif"
+		case TokenUnless:
+			tag = "unless"
+		default:
+			continue
+		}
+		value, ok := constantCondition(span.Token.Value, constants)
+		if !ok {
+			continue
+		}
+		rendered := value
+		if tag == "unless" {
+			rendered = !value
+		}
+		message := fmt.Sprintf("{{%s}} condition %q is always %t, so the block is never rendered", tag, span.Token.Value, value)
+		suggestion := "Remove the block, or make the condition depend on the render data."
+		if rendered {
+			message = fmt.Sprintf("{{%s}} condition %q is always %t, so the branches after it are never rendered", tag, span.Token.Value, value)
+			suggestion = "Remove the condition and the branches after it, keeping the block content."
+		}
+		appendValidationIssueWithSuggestions(&issues, IssueSeverityWarning, IssueCodeConstantCondition, message, span, TokenKindControl, span.Token.Value, []string{suggestion})
+	}
+	return issues
+}
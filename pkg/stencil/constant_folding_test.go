@@ -0,0 +1,80 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExpressionFoldsConstants(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`"Rev " + "3"`, `Literal("Rev 3")`},
+		{`2 * 3 + 1`, `Literal(7)`},
+		{`!(1 > 2)`, `Literal(true)`},
+		{`price * (2 + 3)`, `BinaryOp(Variable(price) * Literal(5))`},
+		{`-(4 - 1)`, `Literal(-3)`},
+		// Left to the render, as the outcome depends on its policies
+		{`"Rev " + 3`, `BinaryOp(Literal("Rev ") + Literal(3))`},
+		{`1 / 0`, `BinaryOp(Literal(1) / Literal(0))`},
+		// Function calls are never evaluated
+		{`uppercase("a" + "b")`, `FunctionCall(uppercase, [Literal("ab")])`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression failed: %v", err)
+			}
+			if got := node.String(); got != tt.want {
+				t.Errorf("ParseExpression(%q) = %s, want %s", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstantConditionWarnings(t *testing.T) {
+	text := `{{if 1 > 2}}never{{end}}{{unless "a" == "a"}}never{{end}}` +
+		`{{if constants.showLogo}}logo{{else}}no logo{{end}}{{if user.active}}active{{end}}`
+
+	result, err := ValidateTemplateSyntax(ValidateTemplateSyntaxInput{
+		DocxBytes: createDOCXWithParagraphs(t, []string{text}),
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplateSyntax failed: %v", err)
+	}
+	var expressions []string
+	for _, issue := range result.Issues {
+		if issue.Code == IssueCodeConstantCondition {
+			expressions = append(expressions, issue.Token.Expression)
+		}
+	}
+	if got := strings.Join(expressions, "; "); got != `1 > 2; "a" == "a"` {
+		t.Errorf("constant conditions = %s", got)
+	}
+
+	config := DefaultConfig()
+	config.ValidateOnPrepare = true
+	engine := NewWithConfig(config)
+	engine.RegisterConstants(map[string]interface{}{"showLogo": true})
+	tmpl := prepareTemplateWithEngine(t, engine, text)
+
+	var messages []string
+	for _, warning := range tmpl.Warnings() {
+		if warning.Code == IssueCodeConstantCondition {
+			messages = append(messages, warning.Message)
+		}
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 constant condition warnings, got %q", messages)
+	}
+	if want := `{{if}} condition "constants.showLogo" is always true, so the branches after it are never rendered`; messages[2] != want {
+		t.Errorf("warning = %q, want %q", messages[2], want)
+	}
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{"user": map[string]interface{}{"active": true}})
+	if text := extractTextFromDOCX(t, output); text != "logoactive" {
+		t.Errorf("rendered text = %q", text)
+	}
+}
@@ -113,6 +113,7 @@ type ForNode struct {
 	IndexVar   string // Optional index variable for indexed loops
 	Collection ExpressionNode
 	Body       []ControlStructure
+	ElseBody   []ControlStructure // Rendered when the collection is empty
 }
 
 func (n *ForNode) String() string {
@@ -123,6 +124,10 @@ func (n *ForNode) String() string {
 }
 
 func (n *ForNode) Render(data TemplateData) (string, error) {
+	if err := n.checkShadowing(data); err != nil {
+		return "", err
+	}
+
 	// Evaluate the collection
 	collectionVal, err := n.Collection.Evaluate(data)
 	if err != nil {
@@ -130,7 +135,7 @@ func (n *ForNode) Render(data TemplateData) (string, error) {
 	}
 
 	// Convert to slice
-	items, err := toSlice(collectionVal)
+	items, err := collectionItems(n.Collection, collectionVal)
 	if err != nil {
 		return "", fmt.Errorf("collection is not iterable: %w", err)
 	}
@@ -139,11 +144,7 @@ func (n *ForNode) Render(data TemplateData) (string, error) {
 
 	// Iterate over items
 	for i, item := range items {
-		loopData := newChildTemplateData(data, 2)
-		loopData[n.Variable] = item
-		if n.IndexVar != "" {
-			loopData[n.IndexVar] = i
-		}
+		loopData := n.iterationData(data, i, item)
 
 		// Render the body with loop context
 		bodyResult, err := renderControlBody(n.Body, loopData)
@@ -154,6 +155,11 @@ func (n *ForNode) Render(data TemplateData) (string, error) {
 		result.WriteString(bodyResult)
 	}
 
+	// Fall back to else body
+	if len(items) == 0 && len(n.ElseBody) > 0 {
+		return renderControlBody(n.ElseBody, data)
+	}
+
 	return result.String(), nil
 }
 
@@ -173,6 +179,7 @@ func (n *TextNode) Render(data TemplateData) (string, error) {
 // IncludeNode represents an include statement
 type IncludeNode struct {
 	FragmentName ExpressionNode
+	modifiers    *includeModifiers
 }
 
 func (n *IncludeNode) String() string {
@@ -434,13 +441,23 @@ func (p *ControlParser) parseFor() (*ForNode, error) {
 	}
 	p.advance()
 
-	// Parse body until end
-	body, err := p.parseBodyUntil(TokenEnd)
+	// Parse body until else or end
+	body, err := p.parseBodyUntil(TokenElse, TokenEnd)
 	if err != nil {
 		return nil, err
 	}
 	forNode.Body = body
 
+	// Handle else clause, rendered when the collection is empty
+	if p.current().Type == TokenElse {
+		p.advance() // consume else token
+		elseBody, err := p.parseBodyUntil(TokenEnd)
+		if err != nil {
+			return nil, err
+		}
+		forNode.ElseBody = elseBody
+	}
+
 	// Consume end token
 	if p.current().Type != TokenEnd {
 		return nil, fmt.Errorf("expected end token to close for loop")
@@ -457,7 +474,7 @@ func (p *ControlParser) parseInclude() (*IncludeNode, error) {
 
 	// Parse fragment name expression
 	fragmentNameStr := p.current().Value
-	fragmentName, err := ParseExpression(fragmentNameStr)
+	fragmentName, modifiers, err := parseIncludeDirective(fragmentNameStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse include fragment name: %w", err)
 	}
@@ -465,6 +482,7 @@ func (p *ControlParser) parseInclude() (*IncludeNode, error) {
 
 	return &IncludeNode{
 		FragmentName: fragmentName,
+		modifiers:    modifiers,
 	}, nil
 }
 
@@ -541,6 +559,17 @@ func parseForSyntax(forStr string) (*ForNode, error) {
 func parseForSyntaxWithExpressionParser(
 	forStr string,
 	parseExpression func(string) (ExpressionNode, error),
+) (*ForNode, error) {
+	forNode, err := parseForHeader(forStr, parseExpression)
+	if err != nil {
+		return nil, withErrorCode(ErrorCodeBadForSyntax, err)
+	}
+	return forNode, nil
+}
+
+func parseForHeader(
+	forStr string,
+	parseExpression func(string) (ExpressionNode, error),
 ) (*ForNode, error) {
 	// Remove extra whitespace
 	forStr = strings.TrimSpace(forStr)
@@ -552,10 +581,13 @@ func parseForSyntaxWithExpressionParser(
 	}
 
 	varsStr := strings.TrimSpace(forStr[:inIndex])
-	collectionStr := strings.TrimSpace(forStr[inIndex+4:])
+	clauses, err := splitForClauses(strings.TrimSpace(forStr[inIndex+4:]))
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse collection expression
-	collection, err := parseExpression(collectionStr)
+	collection, err := parseExpression(clauses.collection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse collection expression: %w", err)
 	}
@@ -577,6 +609,10 @@ func parseForSyntaxWithExpressionParser(
 			return nil, fmt.Errorf("invalid for loop variable: %w", err)
 		}
 
+		collection, err = applyForClauses(collection, variable, clauses, parseExpression)
+		if err != nil {
+			return nil, err
+		}
 		return &ForNode{
 			IndexVar:   indexVar,
 			Variable:   variable,
@@ -587,6 +623,10 @@ func parseForSyntaxWithExpressionParser(
 		if err := validateForVariableName(varsStr); err != nil {
 			return nil, fmt.Errorf("invalid for loop variable: %w", err)
 		}
+		collection, err = applyForClauses(collection, varsStr, clauses, parseExpression)
+		if err != nil {
+			return nil, err
+		}
 		return &ForNode{
 			Variable:   varsStr,
 			Collection: collection,
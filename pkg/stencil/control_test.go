@@ -3,6 +3,7 @@ package stencil
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseControlStructures(t *testing.T) {
@@ -484,6 +485,63 @@ func TestParseForSyntax(t *testing.T) {
 			wantIdx:  "",
 			wantColl: "FieldAccess(FunctionCall(getData, []).items)",
 		},
+		{
+			name:     "for with where clause",
+			forStr:   "item in items where item.qty > 0",
+			wantVar:  "item",
+			wantIdx:  "",
+			wantColl: "Variable(items) where BinaryOp(FieldAccess(Variable(item).qty) > Literal(0))",
+		},
+		{
+			name:     "indexed for with where clause",
+			forStr:   "i, item in items where item.name != \" where \"",
+			wantVar:  "item",
+			wantIdx:  "i",
+			wantColl: "Variable(items) where BinaryOp(FieldAccess(Variable(item).name) != Literal(\" where \"))",
+		},
+		{
+			name:     "where as field name",
+			forStr:   "item in data.where",
+			wantVar:  "item",
+			wantIdx:  "",
+			wantColl: "FieldAccess(Variable(data).where)",
+		},
+		{
+			name:     "for with order by and limit",
+			forStr:   "o in orders order by o.date desc limit 10",
+			wantVar:  "o",
+			wantIdx:  "",
+			wantColl: "Variable(orders) order by FieldAccess(Variable(o).date) desc limit Literal(10)",
+		},
+		{
+			name:     "for with all clauses",
+			forStr:   "o in orders where o.paid order  by o.total asc limit max",
+			wantVar:  "o",
+			wantIdx:  "",
+			wantColl: "Variable(orders) where FieldAccess(Variable(o).paid) order by FieldAccess(Variable(o).total) limit Variable(max)",
+		},
+		{
+			name:    "invalid syntax - clauses out of order",
+			forStr:  "o in orders limit 3 where o.paid",
+			wantErr: true,
+		},
+		{
+			name:     "order by a variable named desc",
+			forStr:   "o in orders order by desc limit 3",
+			wantVar:  "o",
+			wantIdx:  "",
+			wantColl: "Variable(orders) order by Variable(desc) limit Literal(3)",
+		},
+		{
+			name:    "invalid syntax - empty limit",
+			forStr:  "o in orders limit",
+			wantErr: true,
+		},
+		{
+			name:    "invalid syntax - empty where clause",
+			forStr:  "item in items where ",
+			wantErr: true,
+		},
 		{
 			name:    "invalid syntax - no in",
 			forStr:  "item items",
@@ -852,3 +910,210 @@ func TestRangeInLoops(t *testing.T) {
 		})
 	}
 }
+
+func TestForWhereClause(t *testing.T) {
+	bodyXML := `<w:p><w:r><w:t>{{for item in items where item.qty > 0}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Line {{item.name}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Inline:{{for i, item in items where item.qty > 0}} {{i}}={{item.name}}{{end}}</w:t></w:r></w:p>` +
+		`<w:tbl>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for item in items where item.qty > 0}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>Row {{item.name}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`</w:tbl>`
+	docx := createDOCXWithBodyXML(t, bodyXML)
+
+	data := TemplateData{
+		"items": []interface{}{
+			map[string]interface{}{"name": "A", "qty": 2},
+			map[string]interface{}{"name": "B", "qty": 0},
+			map[string]interface{}{"name": "C", "qty": 1},
+		},
+	}
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{})
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+
+	for _, want := range []string{">Line A<", ">Line C<", "Inline: 0=A 1=C", ">Row A<", ">Row C<"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %q in output: %s", want, documentXML)
+		}
+	}
+	if strings.Contains(documentXML, "B<") {
+		t.Errorf("expected filtered item to be skipped: %s", documentXML)
+	}
+	if rows := strings.Count(documentXML, "<w:tr>"); rows != 2 {
+		t.Errorf("expected 2 table rows, got %d: %s", rows, documentXML)
+	}
+}
+
+func TestForElse(t *testing.T) {
+	bodyXML := `<w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Line {{item.name}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{if item.note}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Note {{item.note}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{else}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>No note</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{else}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>No lines</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Inline:{{for item in items}} {{if item.note}}{{item.note}}{{else}}-{{end}}{{else}} none{{end}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Runs:</w:t></w:r><w:r><w:t>{{for item in items}}</w:t></w:r><w:r><w:t>[{{item.name}}]</w:t></w:r>` +
+		`<w:r><w:t>{{else}}</w:t></w:r><w:r><w:t>[empty]</w:t></w:r><w:r><w:t>{{end}}</w:t></w:r></w:p>` +
+		`<w:tbl>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>Row {{item.name}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>{{else}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>No data for {{customer}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr>` +
+		`</w:tbl>`
+	docx := createDOCXWithBodyXML(t, bodyXML)
+
+	output := renderWithOptionsToBytes(t, docx, TemplateData{
+		"customer": "Acme",
+		"items": []interface{}{
+			map[string]interface{}{"name": "A", "note": "urgent"},
+			map[string]interface{}{"name": "B"},
+		},
+	}, RenderOptions{})
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	for _, want := range []string{">Line A<", ">Note urgent<", ">Line B<", ">No note<", "Inline: urgent -", "Runs:[A][B]<", ">Row A<", ">Row B<"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %q in output: %s", want, documentXML)
+		}
+	}
+	for _, unwanted := range []string{"No lines", "none", "[empty]", "No data"} {
+		if strings.Contains(documentXML, unwanted) {
+			t.Errorf("expected no %q for a collection with items: %s", unwanted, documentXML)
+		}
+	}
+
+	for _, items := range []interface{}{[]interface{}{}, nil} {
+		output = renderWithOptionsToBytes(t, docx, TemplateData{"customer": "Acme", "items": items}, RenderOptions{})
+		documentXML = extractDocumentXMLFromDOCX(t, output)
+		for _, want := range []string{">No lines<", "Inline: none", "Runs:[empty]<", ">No data for Acme<"} {
+			if !strings.Contains(documentXML, want) {
+				t.Errorf("expected %q for items %v in output: %s", want, items, documentXML)
+			}
+		}
+		if strings.Contains(documentXML, "Line") || strings.Contains(documentXML, "Row") {
+			t.Errorf("expected no loop content for items %v: %s", items, documentXML)
+		}
+		if rows := strings.Count(documentXML, "<w:tr>"); rows != 1 {
+			t.Errorf("expected 1 table row for items %v, got %d: %s", items, rows, documentXML)
+		}
+	}
+
+	nodes, err := ParseControlStructures("{{for item in items}}{{item}},{{else}}empty{{end}}")
+	if err != nil {
+		t.Fatalf("ParseControlStructures() error = %v", err)
+	}
+	for _, tt := range []struct {
+		items interface{}
+		want  string
+	}{
+		{items: []string{"a", "b"}, want: "a,b,"},
+		{items: []string{}, want: "empty"},
+	} {
+		got, err := renderControlBody(nodes, TemplateData{"items": tt.items})
+		if err != nil {
+			t.Fatalf("renderControlBody() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestForOrderByAndLimit(t *testing.T) {
+	orders := []interface{}{
+		map[string]interface{}{"id": "A", "date": "2024-03-01", "total": 30, "paid": true},
+		map[string]interface{}{"id": "B", "date": "2024-01-15", "total": 10, "paid": false},
+		map[string]interface{}{"id": "C", "date": "2024-02-10", "total": 20, "paid": true},
+		map[string]interface{}{"id": "D", "date": "2024-02-10", "total": 5, "paid": true},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		data    TemplateData
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "order by descending with limit",
+			content: "{{for o in orders order by o.date desc limit 2}}{{o.id}} {{end}}",
+			want:    "A C ",
+		},
+		{
+			name:    "order by is stable",
+			content: "{{for o in orders order by o.date}}{{o.id}} {{end}}",
+			want:    "B C D A ",
+		},
+		{
+			name:    "where with numeric order and variable limit",
+			content: "{{for i, o in orders where o.paid order by o.total limit top}}{{i}}:{{o.id}} {{end}}",
+			data:    TemplateData{"top": 2},
+			want:    "0:D 1:C ",
+		},
+		{
+			name:    "limit larger than collection",
+			content: "{{for o in orders limit 10}}{{o.id}}{{end}}",
+			want:    "ABCD",
+		},
+		{
+			name:    "negative limit",
+			content: "{{for o in orders limit -1}}{{o.id}}{{end}}",
+			wantErr: "limit must not be negative",
+		},
+		{
+			name:    "non-integer limit",
+			content: "{{for o in orders limit \"x\"}}{{o.id}}{{end}}",
+			wantErr: "limit must be an integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := TemplateData{"orders": orders}
+			for k, v := range tt.data {
+				data[k] = v
+			}
+
+			nodes, err := ParseControlStructures(tt.content)
+			if err != nil {
+				t.Fatalf("ParseControlStructures() error = %v", err)
+			}
+			got, err := renderControlBody(nodes, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderControlBody() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareSortKeys(t *testing.T) {
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	ordered := []interface{}{nil, false, true, -1, 2.5, int64(3), early, late, "a", "b"}
+	for i := 0; i < len(ordered)-1; i++ {
+		if cmp := compareSortKeys(ordered[i], ordered[i+1], ""); cmp >= 0 {
+			t.Errorf("compareSortKeys(%v, %v) = %d, want < 0", ordered[i], ordered[i+1], cmp)
+		}
+		if cmp := compareSortKeys(ordered[i+1], ordered[i], ""); cmp <= 0 {
+			t.Errorf("compareSortKeys(%v, %v) = %d, want > 0", ordered[i+1], ordered[i], cmp)
+		}
+	}
+	if cmp := compareSortKeys(2, 2.0, ""); cmp != 0 {
+		t.Errorf("compareSortKeys(2, 2.0) = %d, want 0", cmp)
+	}
+}
@@ -0,0 +1,301 @@
+package stencil
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DataDictionaryInput controls data dictionary generation.
+type DataDictionaryInput struct {
+	DocxBytes          []byte           `json:"-"`
+	TemplateRevisionID string           `json:"templateRevisionId,omitempty"`
+	Schema             ValidationSchema `json:"schema"`
+}
+
+// DataDictionaryField describes one data field a template reads.
+type DataDictionaryField struct {
+	// Path is the schema path of the field. Fields read through a loop
+	// variable use the path of the collection, as in "items.name" for
+	// {{item.name}} inside {{for item in items}}.
+	Path        string      `json:"path"`
+	Type        string      `json:"type,omitempty"`
+	Collection  bool        `json:"collection,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Example     interface{} `json:"example,omitempty"`
+	// InSchema reports whether the schema defines the field.
+	InSchema bool `json:"inSchema"`
+	// Locations lists every place the field is read, in document order.
+	Locations []TemplateLocation `json:"locations"`
+}
+
+// DataDictionaryResult is a catalog of the data fields of a template.
+type DataDictionaryResult struct {
+	Fields   []DataDictionaryField `json:"fields"`
+	Metadata StencilMetadata       `json:"metadata"`
+}
+
+// BuildDataDictionary lists the data fields a DOCX template reads, with
+// their type, description and example value from the schema and the places
+// they appear, for handing a template over to the people who maintain it.
+// Fields are sorted by path.
+func BuildDataDictionary(input DataDictionaryInput) (DataDictionaryResult, error) {
+	if len(input.DocxBytes) == 0 {
+		return DataDictionaryResult{}, fmt.Errorf("docx bytes are required")
+	}
+
+	spans, err := scanDOCXTokenSpans(input.DocxBytes)
+	if err != nil {
+		return DataDictionaryResult{}, err
+	}
+
+	fieldIndex := indexFieldDefinitions(input.Schema.Fields)
+	fields := make(map[string]*DataDictionaryField)
+	addField := func(path string, span tokenSpan) {
+		path = stripLiteralIndices(normalizeFieldPath(path))
+		if path == "" {
+			return
+		}
+		field, ok := fields[path]
+		if !ok {
+			field = &DataDictionaryField{Path: path}
+			if def, found := fieldIndex[path]; found {
+				field.Type = def.Type
+				field.Collection = def.Collection
+				field.Description = def.Description
+				field.Example = def.Example
+				field.InSchema = true
+			}
+			fields[path] = field
+		}
+		location := locationFromSpan(span)
+		if n := len(field.Locations); n > 0 && field.Locations[n-1] == location {
+			return
+		}
+		field.Locations = append(field.Locations, location)
+	}
+
+	walkDataFields(spans, fieldIndex, func(path string, span tokenSpan, _ bool) {
+		addField(path, span)
+	}, nil)
+
+	result := DataDictionaryResult{
+		Fields:   make([]DataDictionaryField, 0, len(fields)),
+		Metadata: newValidationMetadata(input.DocxBytes, input.TemplateRevisionID),
+	}
+	for _, field := range fields {
+		result.Fields = append(result.Fields, *field)
+	}
+	sort.Slice(result.Fields, func(i, j int) bool {
+		return result.Fields[i].Path < result.Fields[j].Path
+	})
+	return result, nil
+}
+
+// walkDataFields calls visit for every reference to a data field in spans
+// with the data path of the field, as listed by BuildDataDictionary, and
+// whether the reference is an operand of arithmetic or an ordering
+// comparison. It calls loop, when not nil, with the data path of every
+// collection a for loop iterates.
+func walkDataFields(spans []tokenSpan, fieldIndex map[string]FieldDefinition, visit func(path string, span tokenSpan, numeric bool), loop func(path string)) {
+	scopeStack := []map[string]semanticScopedVar{{}}
+	scopeFrames := make([]bool, 0)
+	addExpression := func(node ExpressionNode, span tokenSpan) {
+		params := make(map[string]bool)
+		collectLambdaParams(node, params)
+		numeric := make(map[string]bool)
+		collectNumericOperands(node, numeric)
+		collectExpressionReferences(node, func(kind TokenKind, expression string) {
+			if kind != TokenKindVariable {
+				return
+			}
+			root, _ := splitReferencePath(normalizeFieldPath(expression))
+			if params[root] {
+				return
+			}
+			if path, ok := dataDictionaryPath(expression, scopeStack); ok {
+				visit(path, span, numeric[expression])
+			}
+		})
+	}
+
+	for _, span := range spans {
+		if span.Malformed {
+			continue
+		}
+
+		switch span.Token.Type {
+		case TokenVariable, TokenIf, TokenUnless, TokenElsif, TokenInclude:
+			node, err := ParseExpressionStrict(span.Token.Value)
+			if err != nil {
+				continue
+			}
+			addExpression(node, span)
+			if span.Token.Type == TokenIf || span.Token.Type == TokenUnless {
+				scopeFrames = append(scopeFrames, false)
+			}
+		case TokenBlock:
+			object, ok := scopeBlockObject(span.Token)
+			if !ok {
+				scopeFrames = append(scopeFrames, false)
+				continue
+			}
+			node, err := ParseExpressionStrict(object)
+			if err != nil {
+				scopeFrames = append(scopeFrames, false)
+				continue
+			}
+			addExpression(node, span)
+			// The names in a scope block are taken as fields of its object
+			localScope := make(map[string]semanticScopedVar)
+			if objectPath, ok := referencePathFromNode(node); ok {
+				if prefix, ok := dataDictionaryPath(objectPath, scopeStack); ok {
+					localScope[scopeObjectVariable] = semanticScopedVar{SchemaPrefix: prefix}
+				}
+			}
+			scopeStack = append(scopeStack, localScope)
+			scopeFrames = append(scopeFrames, true)
+		case TokenMacro:
+			// Macro parameters are not fields of the data
+			localScope := make(map[string]semanticScopedVar)
+			if _, params, err := parseMacroHeader(span.Token.Value); err == nil {
+				for _, param := range params {
+					localScope[param] = semanticScopedVar{}
+				}
+			}
+			scopeStack = append(scopeStack, localScope)
+			scopeFrames = append(scopeFrames, true)
+		case TokenFor:
+			forNode, err := parseForSyntaxWithExpressionParser(span.Token.Value, ParseExpressionStrict)
+			if err != nil {
+				scopeFrames = append(scopeFrames, false)
+				continue
+			}
+			collection, itemExprs, limit := splitForCollection(forNode.Collection)
+			addExpression(collection, span)
+			addExpression(limit, span)
+
+			localScope := map[string]semanticScopedVar{
+				forNode.Variable: {},
+			}
+			if prefix, ok := dataDictionaryPath(forLoopSchemaPrefix(collection, scopeStack, fieldIndex), scopeStack); ok {
+				localScope[forNode.Variable] = semanticScopedVar{SchemaPrefix: prefix}
+				if loop != nil {
+					loop(prefix)
+				}
+			}
+			if forNode.IndexVar != "" {
+				localScope[forNode.IndexVar] = semanticScopedVar{}
+			}
+			scopeStack = append(scopeStack, localScope)
+			scopeFrames = append(scopeFrames, true)
+			for _, expr := range itemExprs {
+				addExpression(expr, span)
+			}
+		case TokenElse:
+			// Only the {{else}} of a for loop closes a scope, rendering
+			// without the loop variables
+			if len(scopeFrames) > 0 && scopeFrames[len(scopeFrames)-1] && len(scopeStack) > 1 {
+				scopeStack = scopeStack[:len(scopeStack)-1]
+				scopeFrames[len(scopeFrames)-1] = false
+			}
+		case TokenEnd, TokenEndMacro:
+			if len(scopeFrames) == 0 {
+				continue
+			}
+			hasScope := scopeFrames[len(scopeFrames)-1]
+			scopeFrames = scopeFrames[:len(scopeFrames)-1]
+			if hasScope && len(scopeStack) > 1 {
+				scopeStack = scopeStack[:len(scopeStack)-1]
+			}
+		}
+	}
+}
+
+// scopeObjectVariable holds the data path of the object of a scope block
+// in the scope of the block.
+const scopeObjectVariable = "\x00object"
+
+// dataDictionaryPath returns the data path of a reference, replacing a
+// loop variable root with the path of its collection and prefixing the
+// names in a scope block with the path of its object. Loop indexes and
+// loop variables over computed collections do not name data fields.
+func dataDictionaryPath(path string, scopeStack []map[string]semanticScopedVar) (string, bool) {
+	root, remainder := splitReferencePath(normalizeFieldPath(path))
+	if root == "" {
+		return "", false
+	}
+	for i := len(scopeStack) - 1; i >= 0; i-- {
+		if scopedVar, ok := scopeStack[i][root]; ok {
+			if scopedVar.SchemaPrefix == "" {
+				return "", false
+			}
+			return joinReferencePath(scopedVar.SchemaPrefix, remainder), true
+		}
+		if object, ok := scopeStack[i][scopeObjectVariable]; ok {
+			return joinReferencePath(object.SchemaPrefix, normalizeFieldPath(path)), true
+		}
+	}
+	return path, true
+}
+
+// numericOperators are the binary operators whose operands must be numbers.
+var numericOperators = map[string]bool{"-": true, "*": true, "/": true, "%": true, "<": true, ">": true, "<=": true, ">=": true}
+
+// collectNumericOperands adds the reference paths of node that are operands
+// of numeric operators to paths.
+func collectNumericOperands(node ExpressionNode, paths map[string]bool) {
+	addOperand := func(operand ExpressionNode) {
+		if path, ok := referencePathFromNode(operand); ok {
+			paths[path] = true
+		}
+	}
+	switch n := node.(type) {
+	case *BinaryOpNode:
+		if numericOperators[n.Operator] {
+			addOperand(n.Left)
+			addOperand(n.Right)
+		}
+		collectNumericOperands(n.Left, paths)
+		collectNumericOperands(n.Right, paths)
+	case *UnaryOpNode:
+		if n.Operator == "-" {
+			addOperand(n.Operand)
+		}
+		collectNumericOperands(n.Operand, paths)
+	case *FunctionCallNode:
+		for _, arg := range n.Args {
+			collectNumericOperands(arg, paths)
+		}
+	case *LambdaNode:
+		collectNumericOperands(n.Body, paths)
+	case *FieldAccessNode:
+		collectNumericOperands(n.Object, paths)
+	case *IndexAccessNode:
+		collectNumericOperands(n.Object, paths)
+		collectNumericOperands(n.Index, paths)
+	}
+}
+
+// collectLambdaParams adds the parameter names of the lambdas in node to
+// params.
+func collectLambdaParams(node ExpressionNode, params map[string]bool) {
+	switch n := node.(type) {
+	case *LambdaNode:
+		params[n.Param] = true
+		collectLambdaParams(n.Body, params)
+	case *FunctionCallNode:
+		for _, arg := range n.Args {
+			collectLambdaParams(arg, params)
+		}
+	case *BinaryOpNode:
+		collectLambdaParams(n.Left, params)
+		collectLambdaParams(n.Right, params)
+	case *UnaryOpNode:
+		collectLambdaParams(n.Operand, params)
+	case *FieldAccessNode:
+		collectLambdaParams(n.Object, params)
+	case *IndexAccessNode:
+		collectLambdaParams(n.Object, params)
+		collectLambdaParams(n.Index, params)
+	}
+}
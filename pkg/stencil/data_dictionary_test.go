@@ -0,0 +1,59 @@
+package stencil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildDataDictionary(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Dear {{customer.name}},`,
+		`{{if customer.vip}}Thank you, {{customer.name}}!{{end}}`,
+		`{{for order in orders}}`,
+		`{{order.number}}: {{for line in order.lines}}{{line.sku}} x{{line.qty}}{{end}}`,
+		`{{end}}`,
+		`{{for i, tag in filter(tags, t -> t.visible)}}{{i}} {{tag.label}}{{end}}`,
+		`{{for n in range(1, 3)}}{{n}}{{end}}`,
+		`{{uppercase(footer[0])}}`,
+	})
+
+	result, err := BuildDataDictionary(DataDictionaryInput{
+		DocxBytes: docx,
+		Schema: ValidationSchema{Fields: []FieldDefinition{
+			{Path: "customer.name", Type: "string", Description: "Full name of the customer", Example: "Ada Lovelace"},
+			{Path: "orders", Type: "object", Collection: true},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("BuildDataDictionary failed: %v", err)
+	}
+
+	var paths []string
+	byPath := make(map[string]DataDictionaryField)
+	for _, field := range result.Fields {
+		paths = append(paths, field.Path)
+		byPath[field.Path] = field
+	}
+	want := []string{
+		"customer.name", "customer.vip", "footer", "orders", "orders.lines", "orders.lines.qty",
+		"orders.lines.sku", "orders.number", "tags", "tags.label",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+
+	name := byPath["customer.name"]
+	if !name.InSchema || name.Type != "string" || name.Description != "Full name of the customer" || name.Example != "Ada Lovelace" {
+		t.Errorf("customer.name = %+v", name)
+	}
+	if len(name.Locations) != 2 || name.Locations[0].ParagraphIndex != 0 || name.Locations[1].ParagraphIndex != 1 {
+		t.Errorf("customer.name locations = %+v", name.Locations)
+	}
+	if byPath["orders"].Collection != true || byPath["customer.vip"].InSchema {
+		t.Errorf("unexpected schema data: %+v %+v", byPath["orders"], byPath["customer.vip"])
+	}
+
+	if _, err := BuildDataDictionary(DataDictionaryInput{}); err == nil {
+		t.Error("expected an error without a template")
+	}
+}
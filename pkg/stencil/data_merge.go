@@ -0,0 +1,98 @@
+package stencil
+
+import "reflect"
+
+// MergePolicy controls how MergeData combines a list in the overrides with
+// a list in the base data.
+type MergePolicy string
+
+const (
+	// MergeReplaceLists replaces the list of the base data with the list of
+	// the overrides. It is the default.
+	MergeReplaceLists MergePolicy = "replace"
+	// MergeAppendLists appends the items of the list of the overrides to
+	// the items of the list of the base data.
+	MergeAppendLists MergePolicy = "append"
+)
+
+// MergeData returns the deep merge of overrides into base, for layering
+// data such as tenant defaults, document data and ad-hoc overrides. Maps
+// present in both are merged key by key; lists present in both are
+// combined as policy says; any other value of overrides, including nil,
+// replaces the value of base. Neither base nor overrides is modified; the
+// maps and lists the merge combines are new, other values are shared.
+//
+// Example:
+//
+//	data := stencil.MergeData(tenantDefaults, documentData, stencil.MergeReplaceLists)
+//	data = stencil.MergeData(data, overrides, stencil.MergeAppendLists)
+func MergeData(base, overrides TemplateData, policy MergePolicy) TemplateData {
+	merged := make(TemplateData, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if existing, ok := merged[key]; ok {
+			value = mergeValue(existing, value, policy)
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeValue returns the merge of override into base.
+func mergeValue(base, override interface{}, policy MergePolicy) interface{} {
+	if baseMap, ok := mergeableMap(base); ok {
+		if overrideMap, ok := mergeableMap(override); ok {
+			merged := MergeData(baseMap, overrideMap, policy)
+			if _, ok := base.(TemplateData); ok {
+				return merged
+			}
+			return map[string]interface{}(merged)
+		}
+		return override
+	}
+
+	if policy != MergeAppendLists || base == nil || override == nil {
+		return override
+	}
+	baseList, overrideList := reflect.ValueOf(base), reflect.ValueOf(override)
+	if !isMergeableList(baseList) || !isMergeableList(overrideList) {
+		return override
+	}
+	merged := make([]interface{}, 0, baseList.Len()+overrideList.Len())
+	for _, list := range []reflect.Value{baseList, overrideList} {
+		for i := 0; i < list.Len(); i++ {
+			merged = append(merged, list.Index(i).Interface())
+		}
+	}
+	return merged
+}
+
+// mergeableMap returns value as a map when it is a map with string keys.
+func mergeableMap(value interface{}) (TemplateData, bool) {
+	switch m := value.(type) {
+	case TemplateData:
+		return m, true
+	case map[string]interface{}:
+		return TemplateData(m), true
+	case nil:
+		return nil, false
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	m := make(TemplateData, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		m[iter.Key().String()] = iter.Value().Interface()
+	}
+	return m, true
+}
+
+// isMergeableList reports whether v is a slice or an array.
+func isMergeableList(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
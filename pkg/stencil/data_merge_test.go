@@ -0,0 +1,75 @@
+package stencil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeData(t *testing.T) {
+	base := TemplateData{
+		"company": map[string]interface{}{
+			"name":    "Acme",
+			"address": map[string]interface{}{"city": "Berlin", "zip": "10115"},
+		},
+		"currency": "EUR",
+		"tags":     []string{"default"},
+		"footer":   map[string]string{"left": "Confidential", "right": "Page"},
+	}
+	overrides := TemplateData{
+		"company": map[string]interface{}{
+			"address": map[string]interface{}{"city": "Munich"},
+			"vatId":   "DE123",
+		},
+		"tags":   []interface{}{"contract"},
+		"footer": map[string]interface{}{"right": nil},
+		"title":  "Agreement",
+	}
+
+	tests := []struct {
+		policy MergePolicy
+		tags   []interface{}
+	}{
+		{MergeReplaceLists, []interface{}{"contract"}},
+		{"", []interface{}{"contract"}},
+		{MergeAppendLists, []interface{}{"default", "contract"}},
+	}
+	for _, tt := range tests {
+		want := TemplateData{
+			"company": map[string]interface{}{
+				"name":    "Acme",
+				"address": map[string]interface{}{"city": "Munich", "zip": "10115"},
+				"vatId":   "DE123",
+			},
+			"currency": "EUR",
+			"tags":     tt.tags,
+			"footer":   map[string]interface{}{"left": "Confidential", "right": nil},
+			"title":    "Agreement",
+		}
+		if got := MergeData(base, overrides, tt.policy); !reflect.DeepEqual(got, want) {
+			t.Errorf("MergeData(%q) = %v, want %v", tt.policy, got, want)
+		}
+	}
+
+	// The inputs are left unchanged
+	if city := base["company"].(map[string]interface{})["address"].(map[string]interface{})["city"]; city != "Berlin" {
+		t.Errorf("base was modified: city = %v", city)
+	}
+	if _, ok := base["title"]; ok {
+		t.Error("base was modified: title added")
+	}
+}
+
+func TestMergeDataConflicts(t *testing.T) {
+	base := TemplateData{"a": map[string]interface{}{"x": 1}, "b": "text", "c": []interface{}{1}, "d": TemplateData{"x": 1}}
+	overrides := TemplateData{"a": "scalar", "b": map[string]interface{}{"y": 2}, "c": nil, "d": TemplateData{"y": 2}}
+
+	got := MergeData(base, overrides, MergeAppendLists)
+	want := TemplateData{"a": "scalar", "b": map[string]interface{}{"y": 2}, "c": nil, "d": TemplateData{"x": 1, "y": 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeData() = %v, want %v", got, want)
+	}
+
+	if got := MergeData(nil, nil, MergeReplaceLists); got == nil || len(got) != 0 {
+		t.Errorf("MergeData(nil, nil) = %v, want an empty map", got)
+	}
+}
@@ -0,0 +1,213 @@
+package stencil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// With RenderOptions.DataSnapshot, the rendered document carries the data
+// it was rendered with as a custom XML part,
+//
+//	<dataSnapshot xmlns="urn:go-stencil:data-snapshot" version="1">{"customer": ...}</dataSnapshot>
+//
+// so it can be rendered again with a newer revision of its template, such
+// as to regenerate a contract after the terms were updated. With a key the
+// JSON is encrypted with AES-GCM and stored base64-encoded, with
+// encryption="aes-gcm". Word keeps custom XML parts when a document is
+// edited and saved.
+
+const (
+	dataSnapshotNamespace  = "urn:go-stencil:data-snapshot"
+	dataSnapshotVersion    = "1"
+	dataSnapshotEncryption = "aes-gcm"
+)
+
+var (
+	// ErrNoDataSnapshot is returned when a document carries no data
+	// snapshot.
+	ErrNoDataSnapshot = errors.New("document has no data snapshot")
+	// ErrDataSnapshotEncrypted is returned when a data snapshot is
+	// encrypted and no key is given.
+	ErrDataSnapshotEncrypted = errors.New("data snapshot is encrypted")
+)
+
+// DataSnapshotOptions embeds the render data in the rendered document.
+type DataSnapshotOptions struct {
+	// Key encrypts the snapshot with AES-GCM; it must be 16, 24 or 32 bytes
+	// long for AES-128, AES-192 or AES-256. Without a key the data is stored
+	// as plain JSON, readable by anyone who has the document.
+	Key []byte
+}
+
+// dataSnapshotXML is the root element of a data snapshot part.
+type dataSnapshotXML struct {
+	XMLName    xml.Name `xml:"urn:go-stencil:data-snapshot dataSnapshot"`
+	Version    string   `xml:"version,attr"`
+	Encryption string   `xml:"encryption,attr,omitempty"`
+	Content    string   `xml:",chardata"`
+}
+
+// applyDataSnapshot adds the snapshot of data to pkg, replacing a snapshot
+// the package already carries, such as one of the template.
+func applyDataSnapshot(pkg *docxPackage, data TemplateData, opts *DataSnapshotOptions) error {
+	encoded, err := json.Marshal(map[string]interface{}(data))
+	if err != nil {
+		return fmt.Errorf("failed to encode data snapshot: %w", err)
+	}
+	snapshot := dataSnapshotXML{Version: dataSnapshotVersion, Content: string(encoded)}
+	if opts.Key != nil {
+		gcm, err := dataSnapshotCipher(opts.Key)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return fmt.Errorf("failed to encrypt data snapshot: %w", err)
+		}
+		snapshot.Encryption = dataSnapshotEncryption
+		snapshot.Content = base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, encoded, nil))
+	}
+
+	var dataXML bytes.Buffer
+	dataXML.WriteString(xmlDeclaration)
+	fmt.Fprintf(&dataXML, `<dataSnapshot xmlns="%s" version="%s"`, dataSnapshotNamespace, snapshot.Version)
+	if snapshot.Encryption != "" {
+		fmt.Fprintf(&dataXML, ` encryption="%s"`, snapshot.Encryption)
+	}
+	dataXML.WriteString(">" + escapeXMLText(snapshot.Content) + "</dataSnapshot>")
+
+	if name, _, found := findDataSnapshot(pkg); found {
+		pkg.set(name, dataXML.Bytes())
+		return nil
+	}
+	_, err = pkg.addCustomXMLPart(dataXML.Bytes(), customXMLItemID(dataXML.Bytes()), dataSnapshotNamespace)
+	return err
+}
+
+// findDataSnapshot returns the name and content of the data snapshot part
+// of pkg.
+func findDataSnapshot(pkg *docxPackage) (string, dataSnapshotXML, bool) {
+	for _, name := range pkg.names {
+		if !strings.HasPrefix(name, "customXml/item") || strings.HasPrefix(name, "customXml/itemProps") {
+			continue
+		}
+		content, _ := pkg.get(name)
+		if !bytes.Contains(content, []byte(dataSnapshotNamespace)) {
+			continue
+		}
+		var snapshot dataSnapshotXML
+		if err := xml.Unmarshal(content, &snapshot); err == nil {
+			return name, snapshot, true
+		}
+	}
+	return "", dataSnapshotXML{}, false
+}
+
+func dataSnapshotCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data snapshot key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadDataSnapshot returns the data a document rendered with
+// RenderOptions.DataSnapshot was rendered with. key decrypts an encrypted
+// snapshot; it is ignored for plain ones. The data is decoded from JSON, so
+// numbers are float64 and dates the strings they were encoded as.
+//
+// It returns ErrNoDataSnapshot for documents without a snapshot and
+// ErrDataSnapshotEncrypted for encrypted snapshots when key is nil.
+func ReadDataSnapshot(docx []byte, key []byte) (TemplateData, error) {
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		return nil, NewDocumentError("read", "document", err)
+	}
+	_, snapshot, found := findDataSnapshot(pkg)
+	if !found {
+		return nil, ErrNoDataSnapshot
+	}
+	if snapshot.Version != dataSnapshotVersion {
+		return nil, fmt.Errorf("unsupported data snapshot version %q", snapshot.Version)
+	}
+
+	encoded := []byte(snapshot.Content)
+	switch snapshot.Encryption {
+	case "":
+	case dataSnapshotEncryption:
+		if key == nil {
+			return nil, ErrDataSnapshotEncrypted
+		}
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(snapshot.Content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid data snapshot: %w", err)
+		}
+		gcm, err := dataSnapshotCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < gcm.NonceSize() {
+			return nil, fmt.Errorf("invalid data snapshot: too short")
+		}
+		encoded, err = gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt data snapshot: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported data snapshot encryption %q", snapshot.Encryption)
+	}
+
+	var data TemplateData
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, fmt.Errorf("invalid data snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// ReRenderFromDocument renders newTemplate with the data snapshot of docx,
+// a document rendered with RenderOptions.DataSnapshot, such as to
+// regenerate a document with the latest revision of its template. The new
+// document carries the snapshot too, unencrypted like the one it was read
+// from; encrypted snapshots need ReRenderFromDocumentWithOptions.
+func ReRenderFromDocument(docx []byte, newTemplate []byte) ([]byte, error) {
+	return ReRenderFromDocumentWithOptions(docx, newTemplate, RenderOptions{DataSnapshot: &DataSnapshotOptions{}})
+}
+
+// ReRenderFromDocumentWithOptions is ReRenderFromDocument with render
+// options. The key of opts.DataSnapshot decrypts the snapshot of docx, and
+// the new document carries a snapshot only if opts.DataSnapshot is set.
+//
+// Example:
+//
+//	opts := stencil.RenderOptions{DataSnapshot: &stencil.DataSnapshotOptions{Key: key}}
+//	updated, err := stencil.ReRenderFromDocumentWithOptions(contract, latestTemplate, opts)
+func ReRenderFromDocumentWithOptions(docx []byte, newTemplate []byte, opts RenderOptions) ([]byte, error) {
+	var key []byte
+	if opts.DataSnapshot != nil {
+		key = opts.DataSnapshot.Key
+	}
+	data, err := ReadDataSnapshot(docx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := Prepare(bytes.NewReader(newTemplate))
+	if err != nil {
+		return nil, err
+	}
+	defer tmpl.Close()
+	output, err := tmpl.RenderWithOptions(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(output)
+}
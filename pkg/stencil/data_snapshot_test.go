@@ -0,0 +1,97 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDataSnapshotReRender(t *testing.T) {
+	template := createDOCXWithParagraphs(t, []string{"Dear {{customer.name}},", "{{for item in items}}{{item.name}};{{end}}"})
+	data := TemplateData{
+		"customer": map[string]interface{}{"name": "Ada <Lovelace> & Co"},
+		"items":    []interface{}{map[string]interface{}{"name": "Gear"}, map[string]interface{}{"name": "Lever"}},
+	}
+	output := renderWithOptionsToBytes(t, template, data, RenderOptions{DataSnapshot: &DataSnapshotOptions{}})
+
+	snapshot, err := ReadDataSnapshot(output, nil)
+	if err != nil {
+		t.Fatalf("ReadDataSnapshot failed: %v", err)
+	}
+	if got := snapshot["customer"].(map[string]interface{})["name"]; got != "Ada <Lovelace> & Co" {
+		t.Errorf("snapshot customer name = %v", got)
+	}
+	if rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels"); !strings.Contains(rels, "../customXml/item1.xml") {
+		t.Errorf("document relationships lack the snapshot part: %s", rels)
+	}
+
+	newTemplate := createDOCXWithParagraphs(t, []string{"Hello {{customer.name}}!", "Items: {{for item in items}}{{item.name}} {{end}}"})
+	updated, err := ReRenderFromDocument(output, newTemplate)
+	if err != nil {
+		t.Fatalf("ReRenderFromDocument failed: %v", err)
+	}
+	if got, want := extractTextFromDOCX(t, updated), "Hello Ada &lt;Lovelace&gt; &amp; Co!Items: Gear Lever "; got != want {
+		t.Errorf("re-rendered text = %q, want %q", got, want)
+	}
+
+	// The re-rendered document carries the snapshot again, in one part
+	again, err := ReRenderFromDocument(updated, template)
+	if err != nil {
+		t.Fatalf("second ReRenderFromDocument failed: %v", err)
+	}
+	if got := extractTextFromDOCX(t, again); !strings.HasPrefix(got, "Dear Ada &lt;Lovelace&gt; &amp; Co,") {
+		t.Errorf("second re-render text = %q", got)
+	}
+	pkg, err := readDocxPackage(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := pkg.get("customXml/item2.xml"); exists {
+		t.Error("re-rendered document carries more than one snapshot part")
+	}
+
+	plain := renderWithOptionsToBytes(t, template, data, RenderOptions{})
+	if _, err := ReRenderFromDocument(plain, newTemplate); !errors.Is(err, ErrNoDataSnapshot) {
+		t.Errorf("expected ErrNoDataSnapshot, got %v", err)
+	}
+}
+
+func TestEncryptedDataSnapshot(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	template := createDOCXWithParagraphs(t, []string{"Account {{iban}}"})
+	opts := RenderOptions{DataSnapshot: &DataSnapshotOptions{Key: key}}
+	output := renderWithOptionsToBytes(t, template, TemplateData{"iban": "DE89370400440532013000"}, opts)
+
+	part := extractPartFromDOCX(t, output, "customXml/item1.xml")
+	if strings.Contains(part, "DE89370400440532013000") || !strings.Contains(part, `encryption="aes-gcm"`) {
+		t.Errorf("snapshot part is not encrypted: %s", part)
+	}
+	if _, err := ReRenderFromDocument(output, template); !errors.Is(err, ErrDataSnapshotEncrypted) {
+		t.Errorf("expected ErrDataSnapshotEncrypted, got %v", err)
+	}
+	if _, err := ReadDataSnapshot(output, []byte("fedcba9876543210fedcba9876543210")); err == nil {
+		t.Error("expected an error for the wrong key")
+	}
+
+	updated, err := ReRenderFromDocumentWithOptions(output, template, opts)
+	if err != nil {
+		t.Fatalf("ReRenderFromDocumentWithOptions failed: %v", err)
+	}
+	if got := extractTextFromDOCX(t, updated); got != "Account DE89370400440532013000" {
+		t.Errorf("re-rendered text = %q", got)
+	}
+	if data, err := ReadDataSnapshot(updated, key); err != nil || data["iban"] != "DE89370400440532013000" {
+		t.Errorf("ReadDataSnapshot = %v, %v", data, err)
+	}
+
+	tmpl, err := Prepare(bytes.NewReader(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+	_, err = tmpl.RenderWithOptions(TemplateData{"iban": "x"}, RenderOptions{DataSnapshot: &DataSnapshotOptions{Key: []byte("short")}})
+	if err == nil || !strings.Contains(err.Error(), "invalid data snapshot key") {
+		t.Errorf("expected an invalid key error, got %v", err)
+	}
+}
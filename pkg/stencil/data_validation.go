@@ -0,0 +1,279 @@
+package stencil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	IssueCodeMissingField StencilIssueCode = "MISSING_FIELD"
+	IssueCodeNullValue    StencilIssueCode = "NULL_VALUE"
+)
+
+// DataIssue is a problem of render data found by ValidateData.
+type DataIssue struct {
+	// Path is the path of the value, with the index of each list item,
+	// such as "items[2].price".
+	Path    string           `json:"path"`
+	Code    StencilIssueCode `json:"code"`
+	Message string           `json:"message"`
+}
+
+// ValidateData checks data against the fields of the schema before it is
+// rendered: fields marked Required must be present, values must not be
+// null unless the field is Nullable, and values must have the type of
+// their field. Fields with a RequiredIf condition that holds must be
+// present and not null. The fields of a collection apply to each of its
+// items.
+// Fields below a missing or null value are not checked. The issues are
+// ordered by field path and item index; none means the data is valid.
+func (s ValidationSchema) ValidateData(data TemplateData) []DataIssue {
+	fields := make([]FieldDefinition, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		field.Path = stripLiteralIndices(normalizeFieldPath(field.Path))
+		if field.Path != "" && field.Compute == "" {
+			fields = append(fields, field)
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Path < fields[j].Path
+	})
+	collections := schemaCollections(s)
+
+	var issues []DataIssue
+	for _, field := range fields {
+		for _, value := range resolveDataValues(data, field.Path, collections) {
+			if field.RequiredIf != "" && (!value.present || value.value == nil) {
+				issues = append(issues, checkRequiredIf(field, value, data)...)
+				continue
+			}
+			issues = append(issues, checkDataValue(field, value)...)
+		}
+	}
+	return issues
+}
+
+// ValidateData checks data against the schema; see
+// ValidationSchema.ValidateData.
+func (s TemplateSchema) ValidateData(data TemplateData) []DataIssue {
+	return validationSchemaFromTemplateSchema(s).ValidateData(data)
+}
+
+// schemaCollections returns the normalized paths of the collection fields of
+// schema.
+func schemaCollections(s ValidationSchema) map[string]bool {
+	collections := make(map[string]bool)
+	for _, field := range s.Fields {
+		if field.Collection {
+			collections[stripLiteralIndices(normalizeFieldPath(field.Path))] = true
+		}
+	}
+	return collections
+}
+
+// dataValue is a value of render data at a schema path. Present is false
+// when the last field is absent from its parent, the object holding it.
+type dataValue struct {
+	path    string
+	value   interface{}
+	present bool
+	parent  interface{}
+}
+
+// resolveDataValues returns the values at path in data. A collection on
+// the way fans out to each of its items; a parent that is missing, null or
+// not an object yields no values.
+func resolveDataValues(data TemplateData, path string, collections map[string]bool) []dataValue {
+	segments := strings.Split(path, ".")
+	current := []dataValue{{value: data, present: true}}
+	for i, segment := range segments {
+		var next []dataValue
+		for _, parent := range current {
+			if !parent.present || parent.value == nil {
+				continue
+			}
+			value, present, ok := dataField(parent.value, segment)
+			if !ok {
+				continue
+			}
+			childPath := segment
+			if parent.path != "" {
+				childPath = parent.path + "." + segment
+			}
+			child := dataValue{path: childPath, value: value, present: present, parent: parent.value}
+			if i < len(segments)-1 && collections[strings.Join(segments[:i+1], ".")] {
+				// Fields below a collection apply to each of its items
+				list := reflect.ValueOf(value)
+				if value == nil || !isMergeableList(list) {
+					continue
+				}
+				for j := 0; j < list.Len(); j++ {
+					next = append(next, dataValue{
+						path:    childPath + "[" + strconv.Itoa(j) + "]",
+						value:   list.Index(j).Interface(),
+						present: true,
+					})
+				}
+				continue
+			}
+			next = append(next, child)
+		}
+		current = next
+	}
+	return current
+}
+
+// dataField returns the field of an object and whether it is present. It
+// reports false when object is not a map with string keys.
+func dataField(object interface{}, field string) (interface{}, bool, bool) {
+	switch m := object.(type) {
+	case TemplateData:
+		value, present := m[field]
+		return value, present, true
+	case map[string]interface{}:
+		value, present := m[field]
+		return value, present, true
+	}
+
+	v := reflect.ValueOf(object)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false, false
+	}
+	value := v.MapIndex(reflect.ValueOf(field).Convert(v.Type().Key()))
+	if !value.IsValid() {
+		return nil, false, true
+	}
+	return value.Interface(), true, true
+}
+
+// checkRequiredIf returns the issues of a missing or null value of a field
+// with a RequiredIf condition: none when the condition does not hold, and
+// those of a required, non-nullable field when it does.
+func checkRequiredIf(field FieldDefinition, value dataValue, data TemplateData) []DataIssue {
+	// The condition sees the fields of the object holding the field first
+	scope := data
+	switch fields := value.parent.(type) {
+	case TemplateData:
+		scope = fields
+	case map[string]interface{}:
+		scope = TemplateData{parentDataKey: data}
+		for k, v := range fields {
+			scope[k] = v
+		}
+	}
+
+	expr, err := ParseExpression(field.RequiredIf)
+	var result interface{}
+	if err == nil {
+		result, err = expr.Evaluate(scope)
+	}
+	if err != nil {
+		return []DataIssue{{
+			Path:    value.path,
+			Code:    IssueCodeUnsupportedExpr,
+			Message: fmt.Sprintf("requiredIf of %s: %v", value.path, err),
+		}}
+	}
+	if !isTruthy(result) {
+		return nil
+	}
+
+	field.Required = true
+	field.Nullable = false
+	issues := checkDataValue(field, value)
+	for i := range issues {
+		issues[i].Message += " when " + field.RequiredIf
+	}
+	return issues
+}
+
+// checkDataValue returns the issues of a value of field.
+func checkDataValue(field FieldDefinition, value dataValue) []DataIssue {
+	if !value.present {
+		if field.Required {
+			return []DataIssue{{Path: value.path, Code: IssueCodeMissingField, Message: fmt.Sprintf("%s is required", value.path)}}
+		}
+		return nil
+	}
+	if value.value == nil {
+		if field.Nullable {
+			return nil
+		}
+		return []DataIssue{{Path: value.path, Code: IssueCodeNullValue, Message: fmt.Sprintf("%s must not be null", value.path)}}
+	}
+
+	kind := normalizeSchemaKind(field.Type)
+	if !field.Collection {
+		if !dataValueHasKind(value.value, kind) {
+			return []DataIssue{dataTypeIssue(value.path, kind, value.value)}
+		}
+		return nil
+	}
+
+	list := reflect.ValueOf(value.value)
+	if !isMergeableList(list) {
+		return []DataIssue{dataTypeIssue(value.path, semanticKindArray, value.value)}
+	}
+	if kind == semanticKindArray {
+		// A collection without an element type
+		return nil
+	}
+	var issues []DataIssue
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i).Interface()
+		itemPath := value.path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case item == nil && !field.Nullable:
+			issues = append(issues, DataIssue{Path: itemPath, Code: IssueCodeNullValue, Message: fmt.Sprintf("%s must not be null", itemPath)})
+		case item != nil && !dataValueHasKind(item, kind):
+			issues = append(issues, dataTypeIssue(itemPath, kind, item))
+		}
+	}
+	return issues
+}
+
+// dataTypeIssue returns the issue of a value at path that is not of kind.
+func dataTypeIssue(path, kind string, value interface{}) DataIssue {
+	return DataIssue{
+		Path:    path,
+		Code:    IssueCodeTypeMismatch,
+		Message: fmt.Sprintf("%s must be %s %s, got %T", path, articleFor(kind), kind, value),
+	}
+}
+
+// articleFor returns the indefinite article of kind.
+func articleFor(kind string) string {
+	if strings.IndexByte("aeiou", kind[0]) >= 0 {
+		return "an"
+	}
+	return "a"
+}
+
+// dataValueHasKind reports whether a non-null value has the schema kind.
+// Kinds the schema does not know accept any value.
+func dataValueHasKind(value interface{}, kind string) bool {
+	switch kind {
+	case semanticKindString:
+		_, ok := value.(string)
+		return ok
+	case semanticKindNumber:
+		if _, ok := value.(Money); ok {
+			return true
+		}
+		_, ok := toFloat64(value)
+		return ok
+	case semanticKindBool:
+		_, ok := value.(bool)
+		return ok
+	case semanticKindObject:
+		_, _, ok := dataField(value, "")
+		return ok
+	case semanticKindArray:
+		return isMergeableList(reflect.ValueOf(value))
+	default:
+		return true
+	}
+}
@@ -0,0 +1,128 @@
+package stencil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidationSchemaValidateData(t *testing.T) {
+	schema := ValidationSchema{Fields: []FieldDefinition{
+		{Path: "customer", Type: "object", Required: true},
+		{Path: "customer.name", Type: "string", Required: true},
+		{Path: "customer.vatId", Type: "string", Nullable: true},
+		{Path: "items", Type: "object", Collection: true, Required: true},
+		{Path: "items.qty", Type: "number", Required: true},
+		{Path: "items.name", Type: "string"},
+		{Path: "tags", Type: "string", Collection: true},
+		{Path: "paid", Type: "bool"},
+	}}
+
+	valid := TemplateData{
+		"customer": map[string]interface{}{"name": "Acme", "vatId": nil},
+		"items": []map[string]interface{}{
+			{"qty": 2, "name": "Widget"},
+			{"qty": 1.5},
+		},
+		"tags": []string{"vip"},
+		"paid": true,
+	}
+	if issues := schema.ValidateData(valid); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+
+	invalid := TemplateData{
+		"customer": map[string]interface{}{"vatId": 42},
+		"items": []interface{}{
+			map[string]interface{}{"qty": "two"},
+			map[string]interface{}{"name": nil},
+			"not an object",
+		},
+		"tags": []interface{}{"vip", 3, nil},
+		"paid": "yes",
+	}
+	want := []DataIssue{
+		{Path: "customer.name", Code: IssueCodeMissingField, Message: "customer.name is required"},
+		{Path: "customer.vatId", Code: IssueCodeTypeMismatch, Message: "customer.vatId must be a string, got int"},
+		{Path: "items[2]", Code: IssueCodeTypeMismatch, Message: "items[2] must be an object, got string"},
+		{Path: "items[1].name", Code: IssueCodeNullValue, Message: "items[1].name must not be null"},
+		{Path: "items[0].qty", Code: IssueCodeTypeMismatch, Message: "items[0].qty must be a number, got string"},
+		{Path: "items[1].qty", Code: IssueCodeMissingField, Message: "items[1].qty is required"},
+		{Path: "paid", Code: IssueCodeTypeMismatch, Message: "paid must be a bool, got string"},
+		{Path: "tags[1]", Code: IssueCodeTypeMismatch, Message: "tags[1] must be a string, got int"},
+		{Path: "tags[2]", Code: IssueCodeNullValue, Message: "tags[2] must not be null"},
+	}
+	if got := schema.ValidateData(invalid); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateData() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Fields below a missing value are not checked
+	want = []DataIssue{
+		{Path: "customer", Code: IssueCodeMissingField, Message: "customer is required"},
+		{Path: "items", Code: IssueCodeNullValue, Message: "items must not be null"},
+	}
+	if got := schema.ValidateData(TemplateData{"items": nil}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateData() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTemplateSchemaValidateData(t *testing.T) {
+	schema := TemplateSchema{
+		"user": Required(Object(TemplateSchema{
+			"name": Required(String),
+			"age":  Nullable(Number),
+		})),
+		"items": List(Object(TemplateSchema{"price": Number})),
+	}
+	data := TemplateData{
+		"user":  map[string]interface{}{"age": nil},
+		"items": []interface{}{map[string]interface{}{"price": "free"}},
+	}
+	want := []DataIssue{
+		{Path: "items[0].price", Code: IssueCodeTypeMismatch, Message: "items[0].price must be a number, got string"},
+		{Path: "user.name", Code: IssueCodeMissingField, Message: "user.name is required"},
+	}
+	if got := schema.ValidateData(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateData() = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateDataRequiredIf(t *testing.T) {
+	schema := ValidationSchema{Fields: []FieldDefinition{
+		{Path: "paymentMethod", Type: "string"},
+		{Path: "iban", Type: "string", Nullable: true, RequiredIf: `paymentMethod == "SEPA"`},
+		{Path: "items", Type: "object", Collection: true},
+		{Path: "items.discountReason", Type: "string", RequiredIf: `discount > 0 & paymentMethod != "cash"`},
+		{Path: "note", Type: "string", RequiredIf: `paymentMethod ==`},
+	}}
+
+	data := TemplateData{
+		"paymentMethod": "SEPA",
+		"iban":          nil,
+		"items": []interface{}{
+			map[string]interface{}{"discount": 5},
+			map[string]interface{}{"discount": 0},
+			map[string]interface{}{"discount": 5, "discountReason": "loyalty"},
+		},
+		"note": "",
+	}
+	want := []DataIssue{
+		{Path: "iban", Code: IssueCodeNullValue, Message: `iban must not be null when paymentMethod == "SEPA"`},
+		{Path: "items[0].discountReason", Code: IssueCodeMissingField, Message: `items[0].discountReason is required when discount > 0 & paymentMethod != "cash"`},
+	}
+	if got := schema.ValidateData(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateData() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// The fields are optional when the conditions do not hold
+	data["paymentMethod"] = "cash"
+	if got := schema.ValidateData(data); len(got) != 0 {
+		t.Errorf("ValidateData() = %+v, want no issues", got)
+	}
+
+	// An invalid condition is reported where it applies
+	delete(data, "note")
+	got := schema.ValidateData(data)
+	if len(got) != 1 || got[0].Path != "note" || got[0].Code != IssueCodeUnsupportedExpr {
+		t.Errorf("ValidateData() = %+v, want an issue for the condition of note", got)
+	}
+}
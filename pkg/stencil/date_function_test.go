@@ -452,4 +452,46 @@ func TestDateFunctionLocaleFormats(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestDateFunctionJavaPatterns(t *testing.T) {
+	testDate := time.Date(2024, time.March, 5, 9, 7, 3, 120000000, time.UTC)
+
+	tests := []struct {
+		name    string
+		locale  string
+		pattern string
+		want    string
+	}{
+		{name: "numeric date", pattern: "dd.MM.yyyy", want: "05.03.2024"},
+		{name: "unpadded fields", pattern: "d.M.yy", want: "5.3.24"},
+		{name: "quoted literal", pattern: "yyyy-MM-dd'T'HH:mm:ss", want: "2024-03-05T09:07:03"},
+		{name: "escaped quote", pattern: "''yy", want: "'24"},
+		{name: "quoted words are not fields", pattern: "'day' d 'at' h a", want: "day 5 at 9 AM"},
+		{name: "month name with letters after it", pattern: "MMMM d, yyyy", want: "March 5, 2024"},
+		{name: "weekday and milliseconds", pattern: "EEE HH:mm:ss.SSS", want: "Tue 09:07:03.120"},
+		{name: "offset", pattern: "HH:mmXXX", want: "09:07Z"},
+		{name: "localized names", locale: "de", pattern: "EEEE, d. MMMM yyyy 'um' HH:mm 'Uhr'", want: "Dienstag, 5. März 2024 um 09:07 Uhr"},
+		{name: "localized short names", locale: "fr_FR", pattern: "EEE d MMM", want: "mar 5 mar"},
+	}
+
+	fn, exists := GetDefaultFunctionRegistry().GetFunction("date")
+	if !exists {
+		t.Fatal("date() is not registered")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []interface{}{tt.pattern, testDate}
+			if tt.locale != "" {
+				args = append([]interface{}{tt.locale}, args...)
+			}
+			got, err := fn.Call(args...)
+			if err != nil {
+				t.Fatalf("date() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("date() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
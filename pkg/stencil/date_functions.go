@@ -2,6 +2,7 @@ package stencil
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -78,94 +79,270 @@ func parseDate(value interface{}) (time.Time, error) {
 	}
 }
 
-// translateDateFormat converts Java SimpleDateFormat patterns to Go time format
-func translateDateFormat(javaFormat string, _ string) string {
-	// For now, we'll do a simple translation of common patterns
-	// This is not exhaustive but covers most common cases
-	// Note: locale parameter reserved for future locale-specific format translations
-	
-	format := javaFormat
-	
-	// Year
-	format = strings.ReplaceAll(format, "yyyy", "2006")
-	format = strings.ReplaceAll(format, "yy", "06")
-	
-	// Month
-	format = strings.ReplaceAll(format, "MMMM", "January")
-	format = strings.ReplaceAll(format, "MMM", "Jan")
-	format = strings.ReplaceAll(format, "MM", "01")
-	format = strings.ReplaceAll(format, "M", "1")
-	
-	// Day
-	format = strings.ReplaceAll(format, "dd", "02")
-	format = strings.ReplaceAll(format, "d", "2")
-	
-	// Hour (24-hour)
-	format = strings.ReplaceAll(format, "HH", "15")
-	format = strings.ReplaceAll(format, "H", "15")
-	
-	// Hour (12-hour)
-	format = strings.ReplaceAll(format, "hh", "03")
-	format = strings.ReplaceAll(format, "h", "3")
-	
-	// Minute
-	format = strings.ReplaceAll(format, "mm", "04")
-	format = strings.ReplaceAll(format, "m", "4")
-	
-	// Second
-	format = strings.ReplaceAll(format, "ss", "05")
-	format = strings.ReplaceAll(format, "s", "5")
-	
-	// AM/PM
-	format = strings.ReplaceAll(format, "a", "PM")
-	
-	// Day of week
-	format = strings.ReplaceAll(format, "EEEE", "Monday")
-	format = strings.ReplaceAll(format, "EEE", "Mon")
-	format = strings.ReplaceAll(format, "E", "Mon")
-	
-	// Timezone
-	format = strings.ReplaceAll(format, "XXX", "Z07:00")
-	format = strings.ReplaceAll(format, "XX", "Z0700")
-	format = strings.ReplaceAll(format, "X", "Z07")
-	format = strings.ReplaceAll(format, "zzz", "MST")
-	format = strings.ReplaceAll(format, "Z", "Z0700")
-	
-	// Milliseconds
-	format = strings.ReplaceAll(format, "SSS", "000")
-	
-	return format
+// formatJavaDate formats t with a Java DateTimeFormatter pattern such as
+// "dd.MM.yyyy" or "EEEE, d. MMMM yyyy 'um' HH:mm", as the original stencil
+// does. Text in single quotes is copied, two single quotes stand for one and
+// letters without a meaning in Java patterns are copied unchanged. Month and
+// weekday names are translated for locale.
+func formatJavaDate(t time.Time, pattern string, locale string) string {
+	var result strings.Builder
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			i++
+			if i < len(runes) && runes[i] == '\'' {
+				result.WriteRune('\'')
+				i++
+				continue
+			}
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						result.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				result.WriteRune(runes[i])
+				i++
+			}
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			count := 1
+			for i+count < len(runes) && runes[i+count] == r {
+				count++
+			}
+			result.WriteString(javaDateField(t, r, count, locale))
+			i += count
+		default:
+			result.WriteRune(r)
+			i++
+		}
+	}
+
+	return result.String()
 }
 
-// formatDateWithLocale formats a date with locale support
-func formatDateWithLocale(t time.Time, format string, locale string) string {
-	// First translate the format
-	goFormat := translateDateFormat(format, locale)
-	
-	// Format the date
-	result := t.Format(goFormat)
-	
-	// Apply locale-specific translations if needed
-	if locale != "" && locale != "en" {
-		result = applyLocaleTranslations(result, t, locale)
+// javaDateField formats the field of a Java date pattern that letter repeated
+// count times stands for.
+func javaDateField(t time.Time, letter rune, count int, locale string) string {
+	switch letter {
+	case 'y', 'u':
+		if count == 2 {
+			return t.Format("06")
+		}
+		return padDateNumber(t.Year(), count)
+	case 'M', 'L':
+		switch {
+		case count >= 4:
+			return localizedMonth(t, locale, false)
+		case count == 3:
+			return localizedMonth(t, locale, true)
+		default:
+			return padDateNumber(int(t.Month()), count)
+		}
+	case 'd':
+		return padDateNumber(t.Day(), count)
+	case 'D':
+		return padDateNumber(t.YearDay(), count)
+	case 'E':
+		return localizedWeekday(t, locale, count < 4)
+	case 'a':
+		return t.Format("PM")
+	case 'H':
+		return padDateNumber(t.Hour(), count)
+	case 'k':
+		hour := t.Hour()
+		if hour == 0 {
+			hour = 24
+		}
+		return padDateNumber(hour, count)
+	case 'K':
+		return padDateNumber(t.Hour()%12, count)
+	case 'h':
+		hour := t.Hour() % 12
+		if hour == 0 {
+			hour = 12
+		}
+		return padDateNumber(hour, count)
+	case 'm':
+		return padDateNumber(t.Minute(), count)
+	case 's':
+		return padDateNumber(t.Second(), count)
+	case 'S':
+		fraction := fmt.Sprintf("%09d", t.Nanosecond())
+		if count <= len(fraction) {
+			return fraction[:count]
+		}
+		return fraction + strings.Repeat("0", count-len(fraction))
+	case 'z':
+		return t.Format("MST")
+	case 'Z':
+		switch {
+		case count == 4:
+			return "GMT" + t.Format("-07:00")
+		case count >= 5:
+			return t.Format("Z07:00")
+		default:
+			return t.Format("-0700")
+		}
+	case 'X', 'x':
+		layout := [...]string{"Z07", "Z0700", "Z07:00"}[min(count, 3)-1]
+		if letter == 'x' {
+			layout = "-" + layout[1:]
+		}
+		return t.Format(layout)
+	default:
+		return strings.Repeat(string(letter), count)
 	}
-	
-	return result
 }
 
-// applyLocaleTranslations applies basic locale translations to formatted dates
-func applyLocaleTranslations(formatted string, t time.Time, locale string) string {
-	// Extract language from locale (e.g., "de-DE" -> "de")
-	lang := locale
-	if idx := strings.Index(locale, "-"); idx > 0 {
-		lang = locale[:idx]
+func padDateNumber(n int, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+// formatDateValue formats value for a %t or %T specifier of format(), such
+// as %tY or %1$tF. %T upper-cases the result.
+func formatDateValue(spec formatSpec, value interface{}, locale string) (string, error) {
+	if value == nil {
+		return padFormatted("null", spec), nil
 	}
-	if idx := strings.Index(locale, "_"); idx > 0 {
-		lang = locale[:idx]
+	t, err := parseDate(value)
+	if err != nil {
+		return "", fmt.Errorf("%%%c%c requires a date: %v", spec.verb, spec.date, err)
 	}
-	
+	result, err := javaDateConversion(t, spec.date, locale)
+	if err != nil {
+		return "", err
+	}
+	if spec.verb == 'T' {
+		result = strings.ToUpper(result)
+	}
+	return padFormatted(result, spec), nil
+}
+
+// javaDateConversion formats t for the date conversion character of a Java
+// %t specifier.
+func javaDateConversion(t time.Time, conversion rune, locale string) (string, error) {
+	switch conversion {
+	case 'Y':
+		return padDateNumber(t.Year(), 4), nil
+	case 'y':
+		return t.Format("06"), nil
+	case 'C':
+		return padDateNumber(t.Year()/100, 2), nil
+	case 'm':
+		return t.Format("01"), nil
+	case 'd':
+		return t.Format("02"), nil
+	case 'e':
+		return strconv.Itoa(t.Day()), nil
+	case 'j':
+		return padDateNumber(t.YearDay(), 3), nil
+	case 'B':
+		return localizedMonth(t, locale, false), nil
+	case 'b', 'h':
+		return localizedMonth(t, locale, true), nil
+	case 'A':
+		return localizedWeekday(t, locale, false), nil
+	case 'a':
+		return localizedWeekday(t, locale, true), nil
+	case 'H':
+		return t.Format("15"), nil
+	case 'I':
+		return t.Format("03"), nil
+	case 'k':
+		return strconv.Itoa(t.Hour()), nil
+	case 'l':
+		return t.Format("3"), nil
+	case 'M':
+		return t.Format("04"), nil
+	case 'S':
+		return t.Format("05"), nil
+	case 'L':
+		return padDateNumber(t.Nanosecond()/int(time.Millisecond), 3), nil
+	case 'N':
+		return padDateNumber(t.Nanosecond(), 9), nil
+	case 'p':
+		return t.Format("pm"), nil
+	case 'z':
+		return t.Format("-0700"), nil
+	case 'Z':
+		return t.Format("MST"), nil
+	case 's':
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case 'Q':
+		return strconv.FormatInt(t.UnixMilli(), 10), nil
+	case 'R':
+		return t.Format("15:04"), nil
+	case 'T':
+		return t.Format("15:04:05"), nil
+	case 'r':
+		return t.Format("03:04:05 PM"), nil
+	case 'D':
+		return t.Format("01/02/06"), nil
+	case 'F':
+		return t.Format("2006-01-02"), nil
+	case 'c':
+		return localizedWeekday(t, locale, true) + " " + localizedMonth(t, locale, true) + t.Format(" 02 15:04:05 MST 2006"), nil
+	default:
+		return "", fmt.Errorf("unknown date conversion %%t%c", conversion)
+	}
+}
+
+// localizedMonth returns the month name of t in the language of locale.
+func localizedMonth(t time.Time, locale string, short bool) string {
+	name := t.Format("January")
+	if short {
+		name = t.Format("Jan")
+	}
+	if translations := getDateTranslations(localeLanguage(locale)); translations != nil {
+		names := translations.months
+		if short {
+			names = translations.monthsShort
+		}
+		if translated, ok := names[name]; ok {
+			return translated
+		}
+	}
+	return name
+}
+
+// localizedWeekday returns the weekday name of t in the language of locale.
+func localizedWeekday(t time.Time, locale string, short bool) string {
+	name := t.Format("Monday")
+	if short {
+		name = t.Format("Mon")
+	}
+	if translations := getDateTranslations(localeLanguage(locale)); translations != nil {
+		names := translations.weekdays
+		if short {
+			names = translations.weekdaysShort
+		}
+		if translated, ok := names[name]; ok {
+			return translated
+		}
+	}
+	return name
+}
+
+// localeLanguage returns the lower-case language of a locale tag such as
+// "de-DE" or "en_US".
+func localeLanguage(locale string) string {
+	if idx := strings.IndexAny(locale, "-_"); idx > 0 {
+		locale = locale[:idx]
+	}
+	return strings.ToLower(locale)
+}
+
+// applyLocaleTranslations applies basic locale translations to formatted dates
+func applyLocaleTranslations(formatted string, t time.Time, locale string) string {
 	// Get translations for the language
-	translations := getDateTranslations(lang)
+	translations := getDateTranslations(localeLanguage(locale))
 	if translations == nil {
 		return formatted
 	}
@@ -356,7 +533,7 @@ func registerDateFunctions(registry *DefaultFunctionRegistry) {
 		testResult := t.Format(pattern)
 		if strings.Contains(testResult, pattern) || len(testResult) == 0 {
 			// Format failed, likely a Java-style pattern
-			return formatDateWithLocale(t, pattern, locale), nil
+			return formatJavaDate(t, pattern, locale), nil
 		}
 		
 		// Go format worked directly
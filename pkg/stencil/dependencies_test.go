@@ -0,0 +1,60 @@
+package stencil
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestLibraryImportsOnlyStandardLibrary keeps the library free of third-party
+// dependencies, so embedders who only need substitution do not pull in
+// anything beyond the Go standard library.
+func TestLibraryImportsOnlyStandardLibrary(t *testing.T) {
+	const modulePath = "github.com/benjaminschreck/go-stencil/"
+
+	err := filepath.WalkDir(".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == "testdata" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+			return nil
+		}
+		parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", file, err)
+		}
+		for _, spec := range parsed.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, _, _ := strings.Cut(path, "/")
+			if strings.Contains(first, ".") && !strings.HasPrefix(path, modulePath) {
+				t.Errorf("%s imports third-party package %s", file, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goMod, err := os.ReadFile(filepath.Join("..", "..", "go.mod"))
+	if err != nil {
+		t.Fatalf("failed to read go.mod: %v", err)
+	}
+	if strings.Contains(string(goMod), "require") {
+		t.Errorf("go.mod requires third-party modules:\n%s", goMod)
+	}
+}
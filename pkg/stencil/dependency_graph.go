@@ -0,0 +1,253 @@
+package stencil
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DependencyNodeKind is the kind of a node of a dependency graph.
+type DependencyNodeKind string
+
+const (
+	DependencyTemplate DependencyNodeKind = "template"
+	DependencyFragment DependencyNodeKind = "fragment"
+	DependencyField    DependencyNodeKind = "field"
+	DependencyFunction DependencyNodeKind = "function"
+)
+
+// DependencyEdgeKind is the kind of an edge of a dependency graph.
+type DependencyEdgeKind string
+
+const (
+	// DependencyIncludes links a template or fragment to a fragment it
+	// includes.
+	DependencyIncludes DependencyEdgeKind = "includes"
+	// DependencyReads links a template or fragment to a data field it reads.
+	DependencyReads DependencyEdgeKind = "reads"
+	// DependencyCalls links a template or fragment to a function it calls.
+	DependencyCalls DependencyEdgeKind = "calls"
+)
+
+// DependencyGraphInput controls dependency graph generation.
+type DependencyGraphInput struct {
+	DocxBytes []byte `json:"-"`
+	// Name labels the template node, such as the file name of the
+	// template. It defaults to "template".
+	Name string `json:"name,omitempty"`
+	// Fragments holds the fragments the template may include by name: DOCX
+	// files, or the text of text fragments. Included fragments are
+	// followed recursively.
+	Fragments map[string][]byte `json:"-"`
+}
+
+// DependencyNode is a template, fragment, data field or function of a
+// dependency graph.
+type DependencyNode struct {
+	ID   string             `json:"id"`
+	Kind DependencyNodeKind `json:"kind"`
+	Name string             `json:"name"`
+	// Missing reports a fragment that is included but not in the input.
+	Missing bool `json:"missing,omitempty"`
+	// DynamicIncludes counts the includes of a template or fragment whose
+	// fragment name is computed at render time.
+	DynamicIncludes int `json:"dynamicIncludes,omitempty"`
+}
+
+// DependencyEdge links the template or a fragment to what it depends on.
+type DependencyEdge struct {
+	From string             `json:"from"`
+	To   string             `json:"to"`
+	Kind DependencyEdgeKind `json:"kind"`
+}
+
+// DependencyGraph is the graph of what a template pulls in: the fragments
+// it includes, and the data fields and functions the template and each
+// fragment use. Nodes are sorted by kind and name, edges by their nodes.
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// BuildDependencyGraph builds the dependency graph of a DOCX template
+// without rendering it. Data fields use the paths listed by
+// BuildDataDictionary. Fields a fragment reads through a loop variable of
+// the including template are listed under the name of the variable.
+//
+// Example:
+//
+//	graph, err := stencil.BuildDependencyGraph(stencil.DependencyGraphInput{
+//	    DocxBytes: docxBytes,
+//	    Name:      "contract.docx",
+//	    Fragments: map[string][]byte{"terms": termsDocx},
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	os.WriteFile("contract.dot", []byte(graph.DOT()), 0o644)
+func BuildDependencyGraph(input DependencyGraphInput) (DependencyGraph, error) {
+	if len(input.DocxBytes) == 0 {
+		return DependencyGraph{}, fmt.Errorf("docx bytes are required")
+	}
+	name := input.Name
+	if name == "" {
+		name = "template"
+	}
+
+	nodes := make(map[string]*DependencyNode)
+	edges := make(map[DependencyEdge]bool)
+	addNode := func(id string, kind DependencyNodeKind, name string) *DependencyNode {
+		node, ok := nodes[id]
+		if !ok {
+			node = &DependencyNode{ID: id, Kind: kind, Name: name}
+			nodes[id] = node
+		}
+		return node
+	}
+
+	// visit adds the dependencies of the template or a fragment and
+	// returns the fragments it includes that are not yet in the graph
+	visit := func(from *DependencyNode, spans []tokenSpan) []string {
+		var pending []string
+		walkDataFields(spans, nil, func(path string, _ tokenSpan, _ bool) {
+			path = stripLiteralIndices(normalizeFieldPath(path))
+			if path == "" {
+				return
+			}
+			to := addNode("field:"+path, DependencyField, path)
+			edges[DependencyEdge{From: from.ID, To: to.ID, Kind: DependencyReads}] = true
+		}, nil)
+		for _, ref := range extractReferencesFromSpans(spans) {
+			if ref.Kind != TokenKindFunction {
+				continue
+			}
+			to := addNode("function:"+ref.Expression, DependencyFunction, ref.Expression)
+			edges[DependencyEdge{From: from.ID, To: to.ID, Kind: DependencyCalls}] = true
+		}
+		for _, span := range spans {
+			if span.Malformed || span.Token.Type != TokenInclude {
+				continue
+			}
+			fragmentName, ok := includedFragmentName(span.Token.Value)
+			if !ok {
+				from.DynamicIncludes++
+				continue
+			}
+			id := "fragment:" + fragmentName
+			if _, seen := nodes[id]; !seen {
+				pending = append(pending, fragmentName)
+			}
+			to := addNode(id, DependencyFragment, fragmentName)
+			edges[DependencyEdge{From: from.ID, To: to.ID, Kind: DependencyIncludes}] = true
+		}
+		return pending
+	}
+
+	spans, err := scanDOCXTokenSpans(input.DocxBytes)
+	if err != nil {
+		return DependencyGraph{}, err
+	}
+	pending := visit(addNode("template", DependencyTemplate, name), spans)
+	for len(pending) > 0 {
+		fragmentName := pending[0]
+		pending = pending[1:]
+		node := nodes["fragment:"+fragmentName]
+		content, ok := input.Fragments[fragmentName]
+		if !ok {
+			node.Missing = true
+			continue
+		}
+		if isDocxFragmentBytes(content) {
+			if spans, err = scanDOCXTokenSpans(content); err != nil {
+				return DependencyGraph{}, fmt.Errorf("fragment %s: %w", fragmentName, err)
+			}
+		} else {
+			spans = textTokenSpans(string(content))
+		}
+		pending = append(pending, visit(node, spans)...)
+	}
+
+	graph := DependencyGraph{
+		Nodes: make([]DependencyNode, 0, len(nodes)),
+		Edges: make([]DependencyEdge, 0, len(edges)),
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	kindOrder := map[DependencyNodeKind]int{DependencyTemplate: 0, DependencyFragment: 1, DependencyField: 2, DependencyFunction: 3}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		a, b := graph.Nodes[i], graph.Nodes[j]
+		if a.Kind != b.Kind {
+			return kindOrder[a.Kind] < kindOrder[b.Kind]
+		}
+		return a.Name < b.Name
+	})
+	for edge := range edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+	return graph, nil
+}
+
+// textTokenSpans returns the tags of a text fragment as token spans.
+func textTokenSpans(text string) []tokenSpan {
+	var spans []tokenSpan
+	for _, token := range Tokenize(text) {
+		if token.Type == TokenText {
+			continue
+		}
+		spans = append(spans, tokenSpan{Part: "text", Token: token, TokenOrdinal: len(spans)})
+	}
+	return spans
+}
+
+// DOT returns the graph in the DOT language of Graphviz, for rendering with
+// a command such as "dot -Tsvg". Missing fragments are drawn dashed.
+func (g DependencyGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		var attrs []string
+		switch node.Kind {
+		case DependencyTemplate:
+			attrs = append(attrs, "shape=box", "style=bold")
+		case DependencyFragment:
+			attrs = append(attrs, "shape=component")
+			if node.Missing {
+				attrs = append(attrs, "style=dashed", "color=red")
+			}
+		case DependencyField:
+			attrs = append(attrs, "shape=ellipse")
+		case DependencyFunction:
+			attrs = append(attrs, "shape=hexagon")
+		}
+		label := node.Name
+		if node.Kind == DependencyFunction {
+			label += "()"
+		}
+		if node.DynamicIncludes > 0 {
+			label += fmt.Sprintf("\n(dynamic includes: %d)", node.DynamicIncludes)
+		}
+		fmt.Fprintf(&b, "  %s [label=%s, %s];\n", strconv.Quote(node.ID), strconv.Quote(label), strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.Edges {
+		style := ""
+		if edge.Kind != DependencyIncludes {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%q%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), edge.Kind, style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
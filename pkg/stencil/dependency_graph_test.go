@@ -0,0 +1,86 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildDependencyGraph(t *testing.T) {
+	docx := createDOCXWithCustomStylesAndBody(t, `
+<w:p><w:r><w:t>{{upper(customer.name)}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{for item in items}}{{item.price}}{{end}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{include "terms"}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{include "annex"}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{include section}}</w:t></w:r></w:p>`, "")
+
+	graph, err := BuildDependencyGraph(DependencyGraphInput{
+		DocxBytes: docx,
+		Name:      "contract.docx",
+		Fragments: map[string][]byte{
+			"terms":     createDOCXWithCustomStylesAndBody(t, `<w:p><w:r><w:t>Due {{format("02.01.2006", dueDate)}} {{include "signature"}}</w:t></w:r></w:p>`, ""),
+			"signature": []byte(`Kind regards, {{sender}} {{include "terms"}}`),
+		},
+	})
+	if err != nil {
+		t.Fatalf("BuildDependencyGraph failed: %v", err)
+	}
+
+	var nodes []string
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node.ID)
+		switch node.ID {
+		case "template":
+			if node.Name != "contract.docx" || node.DynamicIncludes != 1 {
+				t.Errorf("unexpected template node %+v", node)
+			}
+		case "fragment:annex":
+			if !node.Missing {
+				t.Errorf("expected the annex fragment to be missing")
+			}
+		case "fragment:terms":
+			if node.Missing {
+				t.Errorf("expected the terms fragment to be found")
+			}
+		}
+	}
+	wantNodes := []string{
+		"template",
+		"fragment:annex", "fragment:signature", "fragment:terms",
+		"field:customer.name", "field:dueDate", "field:items", "field:items.price", "field:section", "field:sender",
+		"function:format", "function:upper",
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("nodes = %v, want %v", nodes, wantNodes)
+	}
+
+	for _, want := range []DependencyEdge{
+		{From: "template", To: "fragment:terms", Kind: DependencyIncludes},
+		{From: "template", To: "field:items.price", Kind: DependencyReads},
+		{From: "template", To: "function:upper", Kind: DependencyCalls},
+		{From: "fragment:terms", To: "field:dueDate", Kind: DependencyReads},
+		{From: "fragment:terms", To: "fragment:signature", Kind: DependencyIncludes},
+		{From: "fragment:signature", To: "fragment:terms", Kind: DependencyIncludes},
+		{From: "fragment:signature", To: "field:sender", Kind: DependencyReads},
+	} {
+		found := false
+		for _, edge := range graph.Edges {
+			found = found || edge == want
+		}
+		if !found {
+			t.Errorf("expected edge %+v in %+v", want, graph.Edges)
+		}
+	}
+
+	dot := graph.DOT()
+	for _, want := range []string{
+		"digraph dependencies {",
+		`"fragment:annex" [label="annex", shape=component, style=dashed, color=red];`,
+		`"template" -> "fragment:terms" [label="includes"];`,
+		`"function:upper" [label="upper()", shape=hexagon];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected %q in the DOT output:\n%s", want, dot)
+		}
+	}
+}
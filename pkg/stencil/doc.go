@@ -116,8 +116,10 @@
 // The package defines several error types for specific failure cases:
 //
 //   - TemplateError: Template syntax errors
-//   - RenderError: Errors during rendering
-//   - ParseError: Expression parsing errors
+//   - EvaluationError: Expression evaluation errors during rendering, with
+//     the expression text and the resolved sub-values and their types
+//   - ParseError: Expression parsing errors, with the expression text and
+//     the position of the offending token
 //
 // Check error types using errors.As():
 //
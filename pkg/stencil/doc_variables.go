@@ -0,0 +1,247 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	settingsPartName           = "word/settings.xml"
+	customPropertiesPartName   = "docProps/custom.xml"
+	settingsRelationType       = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings"
+	settingsContentType        = "application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"
+	customPropertiesRelType    = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties"
+	customPropertiesType       = "application/vnd.openxmlformats-officedocument.custom-properties+xml"
+	customPropertiesNamespace  = "http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"
+	docPropsVTypesNamespace    = "http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"
+	customPropertyFormatID     = "{D5CDD505-2E9C-101B-9397-08002B2CF9AE}"
+	firstCustomPropertyID      = 2
+	wordprocessingMLNamespace  = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	minimalSettingsXMLTemplate = xmlDeclaration + `<w:settings xmlns:w="` + wordprocessingMLNamespace + `"></w:settings>`
+)
+
+var (
+	docVarsBlockRegex = regexp.MustCompile(`(?s)<w:docVars\s*/>|<w:docVars>.*?</w:docVars>`)
+
+	// settingsElementsAfterDocVars lists the w:settings children that must
+	// follow w:docVars according to the CT_Settings sequence.
+	settingsElementsAfterDocVars = []string{
+		"<w:rsids", "<m:mathPr", "<w:attachedSchema", "<w:themeFontLang",
+		"<w:clrSchemeMapping", "<w:doNotIncludeSubdocsInStats", "<w:doNotAutoCompressPictures",
+		"<w:forceUpgrade", "<w:captions", "<w:readModeInkLockDown", "<w:smartTagType",
+		"<sl:schemaLibrary", "<w:shapeDefaults", "<w:doNotEmbedSmartTags",
+		"<w:decimalSymbol", "<w:listSeparator",
+	}
+)
+
+type docVariable struct {
+	name  string
+	value string
+}
+
+// applyDocVariables writes vars into the document settings (w:docVars) and the
+// custom properties part, creating either part when the template has none.
+func applyDocVariables(pkg *docxPackage, vars map[string]string) error {
+	if len(vars) == 0 {
+		return nil
+	}
+
+	settings, ok := pkg.get(settingsPartName)
+	if !ok {
+		settings = []byte(minimalSettingsXMLTemplate)
+		if _, err := pkg.ensureRelationship(documentRelationshipsPart, settingsRelationType, "settings.xml"); err != nil {
+			return err
+		}
+		if err := pkg.ensureContentTypeOverride(settingsPartName, settingsContentType); err != nil {
+			return err
+		}
+	}
+	updatedSettings, err := setSettingsDocVariables(settings, vars)
+	if err != nil {
+		return err
+	}
+	pkg.set(settingsPartName, updatedSettings)
+
+	customXML, _ := pkg.get(customPropertiesPartName)
+	updatedCustom, err := setCustomProperties(customXML, vars)
+	if err != nil {
+		return err
+	}
+	pkg.set(customPropertiesPartName, updatedCustom)
+	if _, err := pkg.ensureRelationship(packageRelationshipsPart, customPropertiesRelType, customPropertiesPartName); err != nil {
+		return err
+	}
+	return pkg.ensureContentTypeOverride(customPropertiesPartName, customPropertiesType)
+}
+
+// setSettingsDocVariables merges vars into the w:docVars element of a
+// settings.xml part. Existing variables keep their position; new variables are
+// appended in name order.
+func setSettingsDocVariables(settingsXML []byte, vars map[string]string) ([]byte, error) {
+	content := string(settingsXML)
+
+	var existing []docVariable
+	block := docVarsBlockRegex.FindString(content)
+	if block != "" {
+		existing = parseDocVariables(block)
+	}
+
+	merged := mergeDocVariables(existing, vars)
+	var docVars strings.Builder
+	docVars.WriteString("<w:docVars>")
+	for _, v := range merged {
+		docVars.WriteString(`<w:docVar w:name="`)
+		docVars.WriteString(escapeXMLText(v.name))
+		docVars.WriteString(`" w:val="`)
+		docVars.WriteString(escapeXMLText(v.value))
+		docVars.WriteString(`"/>`)
+	}
+	docVars.WriteString("</w:docVars>")
+
+	if block != "" {
+		return []byte(strings.Replace(content, block, docVars.String(), 1)), nil
+	}
+
+	insertAt := -1
+	for _, marker := range settingsElementsAfterDocVars {
+		if idx := strings.Index(content, marker); idx != -1 && (insertAt == -1 || idx < insertAt) {
+			insertAt = idx
+		}
+	}
+	if insertAt == -1 {
+		insertAt = strings.LastIndex(content, "</w:settings>")
+	}
+	if insertAt == -1 {
+		return nil, fmt.Errorf("settings part has no w:settings root")
+	}
+
+	return []byte(content[:insertAt] + docVars.String() + content[insertAt:]), nil
+}
+
+func parseDocVariables(block string) []docVariable {
+	wrapped := `<root xmlns:w="` + wordprocessingMLNamespace + `">` + block + `</root>`
+	decoder := xml.NewDecoder(strings.NewReader(wrapped))
+
+	var vars []docVariable
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "docVar" {
+			continue
+		}
+		var v docVariable
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "name":
+				v.name = attr.Value
+			case "val":
+				v.value = attr.Value
+			}
+		}
+		if v.name != "" {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+func mergeDocVariables(existing []docVariable, vars map[string]string) []docVariable {
+	merged := make([]docVariable, 0, len(existing)+len(vars))
+	seen := make(map[string]bool, len(existing))
+	for _, v := range existing {
+		if value, ok := vars[v.name]; ok {
+			v.value = value
+		}
+		seen[v.name] = true
+		merged = append(merged, v)
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if name != "" && !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged = append(merged, docVariable{name: name, value: vars[name]})
+	}
+	return merged
+}
+
+type customPropertiesXML struct {
+	XMLName    xml.Name            `xml:"Properties"`
+	Properties []customPropertyXML `xml:"property"`
+}
+
+type customPropertyXML struct {
+	FormatID string `xml:"fmtid,attr"`
+	PID      int    `xml:"pid,attr"`
+	Name     string `xml:"name,attr"`
+	Inner    string `xml:",innerxml"`
+}
+
+// setCustomProperties merges vars into a docProps/custom.xml part as string
+// properties. An empty existing part produces a new one.
+func setCustomProperties(existingXML []byte, vars map[string]string) ([]byte, error) {
+	var props customPropertiesXML
+	if len(bytes.TrimSpace(existingXML)) > 0 {
+		if err := xml.Unmarshal(existingXML, &props); err != nil {
+			return nil, fmt.Errorf("failed to parse custom properties: %w", err)
+		}
+	}
+
+	nextPID := firstCustomPropertyID
+	for _, prop := range props.Properties {
+		if prop.PID >= nextPID {
+			nextPID = prop.PID + 1
+		}
+	}
+
+	seen := make(map[string]bool, len(props.Properties))
+	for i := range props.Properties {
+		name := props.Properties[i].Name
+		seen[name] = true
+		if value, ok := vars[name]; ok {
+			props.Properties[i].Inner = "<vt:lpwstr>" + escapeXMLText(value) + "</vt:lpwstr>"
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if name != "" && !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		props.Properties = append(props.Properties, customPropertyXML{
+			FormatID: customPropertyFormatID,
+			PID:      nextPID,
+			Name:     name,
+			Inner:    "<vt:lpwstr>" + escapeXMLText(vars[name]) + "</vt:lpwstr>",
+		})
+		nextPID++
+	}
+
+	var buf strings.Builder
+	buf.WriteString(xmlDeclaration)
+	buf.WriteString(`<Properties xmlns="` + customPropertiesNamespace + `" xmlns:vt="` + docPropsVTypesNamespace + `">`)
+	for _, prop := range props.Properties {
+		fmtID := prop.FormatID
+		if fmtID == "" {
+			fmtID = customPropertyFormatID
+		}
+		fmt.Fprintf(&buf, `<property fmtid="%s" pid="%d" name="%s">%s</property>`,
+			escapeXMLText(fmtID), prop.PID, escapeXMLText(prop.Name), prop.Inner)
+	}
+	buf.WriteString("</Properties>")
+	return []byte(buf.String()), nil
+}
@@ -0,0 +1,126 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func renderWithOptionsToBytes(t *testing.T, docx []byte, data TemplateData, opts RenderOptions) []byte {
+	t.Helper()
+
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	reader, err := tmpl.RenderWithOptions(data, opts)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return output
+}
+
+func TestRenderWithOptions_DocVariables(t *testing.T) {
+	docx := createSimpleDOCX(t, "Hello {{name}}")
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"name": "World"}, RenderOptions{
+		DocVariables: map[string]string{
+			"ClientName": "Acme & Co",
+			"CaseNumber": "2024-17",
+		},
+	})
+
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Hello World") {
+		t.Fatalf("expected rendered text, got %q", text)
+	}
+
+	settings := extractPartFromDOCX(t, output, "word/settings.xml")
+	if !strings.Contains(settings, `<w:docVar w:name="CaseNumber" w:val="2024-17"/><w:docVar w:name="ClientName" w:val="Acme &amp; Co"/>`) {
+		t.Fatalf("expected docVars in settings.xml, got %s", settings)
+	}
+
+	custom := extractPartFromDOCX(t, output, "docProps/custom.xml")
+	if !strings.Contains(custom, `name="ClientName"><vt:lpwstr>Acme &amp; Co</vt:lpwstr>`) {
+		t.Fatalf("expected ClientName custom property, got %s", custom)
+	}
+
+	docRels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")
+	if !strings.Contains(docRels, settingsRelationType) {
+		t.Fatalf("expected settings relationship, got %s", docRels)
+	}
+	pkgRels := extractPartFromDOCX(t, output, "_rels/.rels")
+	if !strings.Contains(pkgRels, customPropertiesRelType) {
+		t.Fatalf("expected custom properties relationship, got %s", pkgRels)
+	}
+	contentTypes := extractPartFromDOCX(t, output, "[Content_Types].xml")
+	for _, partName := range []string{"/word/settings.xml", "/docProps/custom.xml"} {
+		if !strings.Contains(contentTypes, `PartName="`+partName+`"`) {
+			t.Fatalf("expected content type override for %s, got %s", partName, contentTypes)
+		}
+	}
+}
+
+func TestSetSettingsDocVariables_MergesExisting(t *testing.T) {
+	settings := []byte(`<w:settings xmlns:w="` + wordprocessingMLNamespace + `"><w:zoom w:percent="100"/>` +
+		`<w:docVars><w:docVar w:name="B" w:val="old"/><w:docVar w:name="Keep" w:val="x"/></w:docVars>` +
+		`<w:rsids/></w:settings>`)
+
+	updated, err := setSettingsDocVariables(settings, map[string]string{"B": "new", "A": "added"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `<w:docVars><w:docVar w:name="B" w:val="new"/><w:docVar w:name="Keep" w:val="x"/><w:docVar w:name="A" w:val="added"/></w:docVars><w:rsids/>`
+	if !strings.Contains(string(updated), want) {
+		t.Fatalf("expected merged docVars %s, got %s", want, updated)
+	}
+
+	noDocVars := []byte(`<w:settings xmlns:w="` + wordprocessingMLNamespace + `"><w:zoom w:percent="100"/><w:rsids/></w:settings>`)
+	updated, err = setSettingsDocVariables(noDocVars, map[string]string{"A": "1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(updated), `<w:zoom w:percent="100"/><w:docVars><w:docVar w:name="A" w:val="1"/></w:docVars><w:rsids/>`) {
+		t.Fatalf("expected docVars before w:rsids, got %s", updated)
+	}
+}
+
+func TestFieldFunction_DocVariable(t *testing.T) {
+	docx := createSimpleDOCX(t, `Client: {{field("DOCVARIABLE ClientName")}}`)
+	output := renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{
+		DocVariables: map[string]string{"ClientName": "Acme"},
+	})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	for _, want := range []string{
+		`w:fldCharType="begin"`,
+		`<w:instrText xml:space="preserve"> DOCVARIABLE ClientName </w:instrText>`,
+		`w:fldCharType="separate"`,
+		`w:fldCharType="end"`,
+	} {
+		if !strings.Contains(documentXML, want) {
+			t.Fatalf("expected %s in document.xml, got %s", want, documentXML)
+		}
+	}
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Client: Acme") {
+		t.Fatalf("expected cached field result, got %q", text)
+	}
+}
+
+func TestFieldFunction_ExplicitResult(t *testing.T) {
+	docx := createSimpleDOCX(t, `{{field("PAGE", "1")}}`)
+	output := renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	if !strings.Contains(documentXML, `> PAGE </w:instrText>`) {
+		t.Fatalf("expected PAGE field instruction, got %s", documentXML)
+	}
+	if text := extractTextFromDOCX(t, output); text != "1" {
+		t.Fatalf("expected cached result 1, got %q", text)
+	}
+}
@@ -0,0 +1,124 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const stylesPartName = "word/styles.xml"
+
+var (
+	// languageTagRegex matches BCP 47 language tags such as "de", "en-US"
+	// or "sr-Latn-RS".
+	languageTagRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+	langElementRegex   = regexp.MustCompile(`<w:lang\b[^>]*>`)
+	langValAttrRegex   = regexp.MustCompile(`\sw:val="[^"]*"`)
+	docDefaultsRegex   = regexp.MustCompile(`(?s)<w:docDefaults\s*/>|<w:docDefaults>.*?</w:docDefaults>`)
+	rPrDefaultRegex    = regexp.MustCompile(`(?s)<w:rPrDefault\s*/>|<w:rPrDefault>.*?</w:rPrDefault>`)
+	defaultRPrRegex    = regexp.MustCompile(`(?s)<w:rPr\s*/>|<w:rPr>.*?</w:rPr>`)
+	stylesOpenTagRegex = regexp.MustCompile(`<w:styles\b[^>]*>`)
+
+	// rPrElementsAfterLang lists the w:rPr children that must follow w:lang
+	// according to the CT_RPr sequence.
+	rPrElementsAfterLang = []string{"<w:eastAsianLayout", "<w:specVanish", "<w:oMath"}
+)
+
+// applyDocumentLanguage sets the proofing language of the rendered document
+// to lang: the w:val of every w:lang element of the document, its headers,
+// footers, notes and styles is replaced, and the default run properties of
+// the styles part get a w:lang, so text without a language of its own is
+// spell-checked in lang too. The East Asian and complex script languages
+// (w:eastAsia and w:bidi) are kept.
+func applyDocumentLanguage(pkg *docxPackage, lang string) error {
+	if !languageTagRegex.MatchString(lang) {
+		return fmt.Errorf("invalid language tag %q", lang)
+	}
+
+	for _, name := range pkg.names {
+		if name != "word/document.xml" && !isStoryPartName(name) {
+			continue
+		}
+		content, _ := pkg.get(name)
+		if updated := setLangElements(string(content), lang); updated != string(content) {
+			pkg.set(name, []byte(updated))
+		}
+	}
+
+	styles, ok := pkg.get(stylesPartName)
+	if !ok {
+		return nil
+	}
+	updated, err := setDefaultLanguage(setLangElements(string(styles), lang), lang)
+	if err != nil {
+		return err
+	}
+	pkg.set(stylesPartName, []byte(updated))
+	return nil
+}
+
+// setLangElements sets the w:val attribute of every w:lang element in
+// content to lang.
+func setLangElements(content, lang string) string {
+	val := ` w:val="` + escapeXMLText(lang) + `"`
+	return langElementRegex.ReplaceAllStringFunc(content, func(element string) string {
+		if langValAttrRegex.MatchString(element) {
+			return langValAttrRegex.ReplaceAllLiteralString(element, val)
+		}
+		return "<w:lang" + val + element[len("<w:lang"):]
+	})
+}
+
+// setDefaultLanguage adds a w:lang element to the default run properties
+// (w:docDefaults/w:rPrDefault/w:rPr) of a styles part that has none,
+// creating the elements that are missing.
+func setDefaultLanguage(styles, lang string) (string, error) {
+	langElement := `<w:lang w:val="` + escapeXMLText(lang) + `"/>`
+	rPr := "<w:rPr>" + langElement + "</w:rPr>"
+	rPrDefault := "<w:rPrDefault>" + rPr + "</w:rPrDefault>"
+
+	docDefaults := docDefaultsRegex.FindString(styles)
+	if docDefaults == "" {
+		open := stylesOpenTagRegex.FindString(styles)
+		if open == "" {
+			return "", fmt.Errorf("styles part has no w:styles element")
+		}
+		return strings.Replace(styles, open, open+"<w:docDefaults>"+rPrDefault+"</w:docDefaults>", 1), nil
+	}
+
+	var updated string
+	switch existing := rPrDefaultRegex.FindString(docDefaults); {
+	case strings.HasSuffix(docDefaults, "/>"):
+		updated = "<w:docDefaults>" + rPrDefault + "</w:docDefaults>"
+	case existing == "":
+		// w:rPrDefault is the first child of w:docDefaults
+		updated = "<w:docDefaults>" + rPrDefault + strings.TrimPrefix(docDefaults, "<w:docDefaults>")
+	default:
+		updated = strings.Replace(docDefaults, existing, setRPrLanguage(existing, rPr, langElement), 1)
+	}
+	return strings.Replace(styles, docDefaults, updated, 1), nil
+}
+
+// setRPrLanguage returns the w:rPrDefault element rPrDefault with a w:lang
+// element in its run properties, unless they already have one.
+func setRPrLanguage(rPrDefault, rPr, langElement string) string {
+	existing := defaultRPrRegex.FindString(rPrDefault)
+	if existing == "" {
+		return "<w:rPrDefault>" + rPr + "</w:rPrDefault>"
+	}
+	if strings.Contains(existing, "<w:lang") {
+		return rPrDefault
+	}
+	if strings.HasSuffix(existing, "/>") {
+		return strings.Replace(rPrDefault, existing, rPr, 1)
+	}
+
+	insertAt := len(existing) - len("</w:rPr>")
+	for _, next := range rPrElementsAfterLang {
+		if i := strings.Index(existing, next); i >= 0 && i < insertAt {
+			insertAt = i
+		}
+	}
+	return strings.Replace(rPrDefault, existing, existing[:insertAt]+langElement+existing[insertAt:], 1)
+}
@@ -0,0 +1,91 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSetDefaultLanguage(t *testing.T) {
+	const open = `<w:styles xmlns:w="` + wordprocessingMLNamespace + `">`
+	tests := []struct {
+		name   string
+		styles string
+		want   string
+	}{
+		{
+			name:   "no defaults",
+			styles: open + `<w:style w:styleId="Normal"/></w:styles>`,
+			want:   open + `<w:docDefaults><w:rPrDefault><w:rPr><w:lang w:val="de-DE"/></w:rPr></w:rPrDefault></w:docDefaults><w:style w:styleId="Normal"/></w:styles>`,
+		},
+		{
+			name:   "empty defaults",
+			styles: open + `<w:docDefaults/></w:styles>`,
+			want:   open + `<w:docDefaults><w:rPrDefault><w:rPr><w:lang w:val="de-DE"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+		},
+		{
+			name:   "paragraph defaults only",
+			styles: open + `<w:docDefaults><w:pPrDefault/></w:docDefaults></w:styles>`,
+			want:   open + `<w:docDefaults><w:rPrDefault><w:rPr><w:lang w:val="de-DE"/></w:rPr></w:rPrDefault><w:pPrDefault/></w:docDefaults></w:styles>`,
+		},
+		{
+			name:   "run defaults without language",
+			styles: open + `<w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="22"/><w:eastAsianLayout w:id="1"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+			want:   open + `<w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="22"/><w:lang w:val="de-DE"/><w:eastAsianLayout w:id="1"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+		},
+		{
+			name:   "run defaults with language",
+			styles: open + `<w:docDefaults><w:rPrDefault><w:rPr><w:lang w:val="de-DE" w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+			want:   open + `<w:docDefaults><w:rPrDefault><w:rPr><w:lang w:val="de-DE" w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setDefaultLanguage(tt.styles, "de-DE")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("setDefaultLanguage() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWithLanguage(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:rPr><w:lang w:val="en-US" w:bidi="ar-SA"/></w:rPr><w:t>{{greeting}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:rPr><w:lang w:eastAsia="ja-JP"/></w:rPr><w:t>Text</w:t></w:r></w:p>`)
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.set(stylesPartName, []byte(`<w:styles xmlns:w="`+wordprocessingMLNamespace+`">`+
+		`<w:style w:type="paragraph" w:styleId="Normal"><w:rPr><w:lang w:val="en-GB"/></w:rPr></w:style></w:styles>`))
+	if docx, err = pkg.bytes(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"greeting": "Hallo"}, RenderOptions{Language: "de-DE"})
+	document := extractPartFromDOCX(t, output, "word/document.xml")
+	for _, want := range []string{`<w:lang w:val="de-DE" w:bidi="ar-SA"`, `<w:lang w:val="de-DE" w:eastAsia="ja-JP"`} {
+		if !strings.Contains(document, want) {
+			t.Errorf("expected %s in document.xml, got %s", want, document)
+		}
+	}
+	if strings.Contains(document, "en-US") {
+		t.Errorf("expected the run language to be replaced, got %s", document)
+	}
+	styles := extractPartFromDOCX(t, output, stylesPartName)
+	if !strings.Contains(styles, `<w:docDefaults><w:rPrDefault><w:rPr><w:lang w:val="de-DE"/>`) || strings.Contains(styles, "en-GB") {
+		t.Errorf("expected the style and default languages to be set, got %s", styles)
+	}
+
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.RenderWithOptions(TemplateData{}, RenderOptions{Language: "de_DE"}); err == nil || !strings.Contains(err.Error(), "invalid language tag") {
+		t.Errorf("expected an invalid language tag error, got %v", err)
+	}
+}
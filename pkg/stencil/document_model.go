@@ -0,0 +1,105 @@
+package stencil
+
+import (
+	"fmt"
+)
+
+// Document returns a copy of the parsed main document of the template, or
+// nil if the template is closed. Use its Elements, Paragraphs, Tables and
+// Sections methods to inspect the template without parsing the DOCX again.
+// Changes to the copy do not affect the template; pass the changed document
+// to WithDocument to render it.
+//
+// Example:
+//
+//	doc := template.Document()
+//	for _, para := range doc.Paragraphs() {
+//	    fmt.Println(para.GetText())
+//	}
+func (pt *PreparedTemplate) Document() *Document {
+	if pt == nil {
+		return nil
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return nil
+	}
+	tmpl := pt.template
+	tmpl.mu.RLock()
+	defer tmpl.mu.RUnlock()
+	return cloneDocument(tmpl.document)
+}
+
+// WithDocument returns a new template that renders doc in place of the main
+// document, for example a copy from Document with paragraphs added or
+// removed. Everything else, including the other parts of the package, the
+// registered fragments and the fragment resolver, is taken from pt. doc is
+// copied, so later changes to it do not affect the new template.
+//
+// Validate checks the template package, not doc.
+func (pt *PreparedTemplate) WithDocument(doc *Document) (*PreparedTemplate, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("invalid template")
+	}
+	if doc == nil || doc.Body == nil {
+		return nil, fmt.Errorf("document must have a body")
+	}
+	return pt.withDocument(doc, nil)
+}
+
+// withDocument implements WithDocument; clauses are the clauses of an
+// assembled document.
+func (pt *PreparedTemplate) withDocument(doc *Document, clauses []assemblyClause) (*PreparedTemplate, error) {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return nil, errTemplateClosed
+	}
+	tmpl := pt.template
+
+	document := cloneDocument(doc)
+	macros, err := extractMacros(document.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid macro definition: %w", err)
+	}
+
+	fragments, headerFragment, footerFragment := tmpl.snapshotFragments()
+	tmpl.mu.RLock()
+	replaced := &template{
+		name:                tmpl.name,
+		docxReader:          tmpl.docxReader,
+		document:            document,
+		source:              tmpl.source,
+		fragments:           fragments,
+		fragmentResolver:    tmpl.fragmentResolver,
+		resolverMisses:      make(map[string]bool),
+		headerFragment:      headerFragment,
+		footerFragment:      footerFragment,
+		arithmeticPolicy:    tmpl.arithmeticPolicy,
+		concatenationPolicy: tmpl.concatenationPolicy,
+		maxIncludeDepth:     tmpl.maxIncludeDepth,
+		documentReplaced:    true,
+		clauses:             clauses,
+		macros:              mergeMacros(tmpl.macros, macros),
+		macroLibrary:        tmpl.macroLibrary,
+		copyCompressedParts: tmpl.copyCompressedParts,
+		mapping:             tmpl.mapping.retain(),
+	}
+	tmpl.mu.RUnlock()
+
+	if _, err := replaced.ensureRenderResources(); err != nil {
+		replaced.Close()
+		return nil, err
+	}
+
+	return &PreparedTemplate{
+		state:    newPreparedTemplateState(replaced),
+		template: replaced,
+		registry: pt.registry,
+		data:     pt.data,
+		warnings: pt.warnings,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPreparedTemplateDocument(t *testing.T) {
+	docx := createSimpleDOCX(t, "Static text")
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	doc := tmpl.Document()
+	paragraphs := doc.Paragraphs()
+	if len(paragraphs) != 1 || paragraphs[0].GetText() != "Static text" {
+		t.Fatalf("unexpected paragraphs: %d", len(paragraphs))
+	}
+
+	// Add a paragraph with a placeholder to the copy.
+	doc.Body.Elements = append(doc.Body.Elements, &Paragraph{
+		Runs: []Run{{Text: &Text{Content: "Hello {{name}}"}}},
+	})
+	if got := len(tmpl.Document().Paragraphs()); got != 1 {
+		t.Fatalf("changing the copy changed the template: %d paragraphs", got)
+	}
+
+	transformed, err := tmpl.WithDocument(doc)
+	if err != nil {
+		t.Fatalf("WithDocument failed: %v", err)
+	}
+	defer transformed.Close()
+
+	var out bytes.Buffer
+	reader, err := transformed.Render(TemplateData{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if _, err := out.ReadFrom(reader); err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	text := extractTextFromDOCX(t, out.Bytes())
+	if !strings.Contains(text, "Static text") || !strings.Contains(text, "Hello Ada") {
+		t.Errorf("expected the transformed document to render, got %q", text)
+	}
+
+	reader, err = tmpl.Render(TemplateData{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	out.Reset()
+	if _, err := out.ReadFrom(reader); err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if text := extractTextFromDOCX(t, out.Bytes()); strings.Contains(text, "Hello") {
+		t.Errorf("expected the original template to be unchanged, got %q", text)
+	}
+
+	if _, err := tmpl.WithDocument(&Document{}); err == nil {
+		t.Error("expected an error for a document without a body")
+	}
+	tmpl.Close()
+	if tmpl.Document() != nil {
+		t.Error("expected nil document for a closed template")
+	}
+}
@@ -0,0 +1,125 @@
+package stencil
+
+import "fmt"
+
+// AnomalyCode identifies a kind of structural problem in a DOCX package.
+type AnomalyCode string
+
+const (
+	// AnomalyInvalidPackage means the file is not a readable zip archive.
+	AnomalyInvalidPackage AnomalyCode = "INVALID_PACKAGE"
+	// AnomalyMissingPart means a required part or relationship is missing.
+	AnomalyMissingPart AnomalyCode = "MISSING_PART"
+	// AnomalyDuplicatePart means the archive contains a part twice.
+	AnomalyDuplicatePart AnomalyCode = "DUPLICATE_PART"
+	// AnomalyMalformedXML means an XML part is not well-formed.
+	AnomalyMalformedXML AnomalyCode = "MALFORMED_XML"
+	// AnomalyMissingContentType means a part has no declared content type.
+	AnomalyMissingContentType AnomalyCode = "MISSING_CONTENT_TYPE"
+	// AnomalyMissingTarget means a relationship or content type override
+	// names a part that does not exist.
+	AnomalyMissingTarget AnomalyCode = "MISSING_TARGET"
+	// AnomalyDuplicateRelationshipID means a relationship ID is used twice
+	// in one relationships part.
+	AnomalyDuplicateRelationshipID AnomalyCode = "DUPLICATE_RELATIONSHIP_ID"
+	// AnomalyUndefinedRelationship means content references a relationship
+	// ID its part does not define.
+	AnomalyUndefinedRelationship AnomalyCode = "UNDEFINED_RELATIONSHIP"
+	// AnomalyInvalidTableStructure means a table has no rows, a row no
+	// cells or a cell no paragraph.
+	AnomalyInvalidTableStructure AnomalyCode = "INVALID_TABLE_STRUCTURE"
+	// AnomalyInvalidSectionProperties means the body's section properties
+	// are not its last element or a page size is not positive.
+	AnomalyInvalidSectionProperties AnomalyCode = "INVALID_SECTION_PROPERTIES"
+	// AnomalyEmptyHeaderFooterRun means a header or footer contains empty
+	// runs. Word opens such files.
+	AnomalyEmptyHeaderFooterRun AnomalyCode = "EMPTY_HEADER_FOOTER_RUN"
+	// AnomalyUnclosedBookmark means a bookmark is started but never ended.
+	// Word opens such files.
+	AnomalyUnclosedBookmark AnomalyCode = "UNCLOSED_BOOKMARK"
+)
+
+// anomalyCodes maps package problem kinds to their public codes.
+var anomalyCodes = map[packageProblemKind]AnomalyCode{
+	problemMissingPart:              AnomalyMissingPart,
+	problemMalformedXML:             AnomalyMalformedXML,
+	problemMissingContentType:       AnomalyMissingContentType,
+	problemMissingTarget:            AnomalyMissingTarget,
+	problemDuplicateRelationshipID:  AnomalyDuplicateRelationshipID,
+	problemUndefinedRelationship:    AnomalyUndefinedRelationship,
+	problemEmptyHeaderFooterRun:     AnomalyEmptyHeaderFooterRun,
+	problemUnclosedBookmark:         AnomalyUnclosedBookmark,
+	problemDuplicatePart:            AnomalyDuplicatePart,
+	problemInvalidTableStructure:    AnomalyInvalidTableStructure,
+	problemInvalidSectionProperties: AnomalyInvalidSectionProperties,
+}
+
+// Anomaly is a structural problem found in a DOCX package, such as the ones
+// that make Word offer to repair a file.
+type Anomaly struct {
+	// Code identifies the kind of problem.
+	Code AnomalyCode
+	// Part is the package part the problem was found in. It is empty when
+	// the file could not be read as a package.
+	Part string
+	// Problem describes what is wrong.
+	Problem string
+	// Hint suggests how to fix the problem.
+	Hint string
+	// Fatal reports whether Word is expected to refuse to open the file or
+	// to offer to repair it.
+	Fatal bool
+}
+
+func (a Anomaly) String() string {
+	if a.Part == "" {
+		return fmt.Sprintf("%s: %s", a.Code, a.Problem)
+	}
+	return fmt.Sprintf("%s: %s: %s", a.Code, a.Part, a.Problem)
+}
+
+// AnalyzeDocx checks the structure of any DOCX file and returns the problems
+// that commonly make Word report unreadable content: missing or duplicate
+// parts, malformed XML, parts without a content type, broken or duplicate
+// relationship IDs, tables without rows, cells without a paragraph, and
+// misplaced section properties. It also reports patterns Word tolerates, such
+// as unclosed bookmarks, with Fatal set to false. It returns nil for a file
+// without problems.
+//
+// AnalyzeDocx does not render anything, so it can be used to triage templates
+// before they are prepared.
+//
+// Example:
+//
+//	for _, anomaly := range stencil.AnalyzeDocx(upload) {
+//	    if anomaly.Fatal {
+//	        return fmt.Errorf("template is damaged: %s", anomaly)
+//	    }
+//	}
+func AnalyzeDocx(docx []byte) []Anomaly {
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		return []Anomaly{{
+			Code:    AnomalyInvalidPackage,
+			Problem: err.Error(),
+			Hint:    "the file is not a DOCX package; check that it was uploaded completely and is not a .doc file",
+			Fatal:   true,
+		}}
+	}
+
+	problems := checkDocxPackage(pkg)
+	if len(problems) == 0 {
+		return nil
+	}
+	anomalies := make([]Anomaly, 0, len(problems))
+	for _, problem := range problems {
+		anomalies = append(anomalies, Anomaly{
+			Code:    anomalyCodes[problem.kind],
+			Part:    problem.part,
+			Problem: problem.message,
+			Hint:    packageProblemHints[problem.kind],
+			Fatal:   problem.fatal,
+		})
+	}
+	return anomalies
+}
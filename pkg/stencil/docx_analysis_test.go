@@ -0,0 +1,185 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func analysisDocumentXML(body string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<w:document xmlns:w="` + wordprocessingMLNamespace + `"><w:body>` + body + `</w:body></w:document>`)
+}
+
+func TestAnalyzeDocx(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(pkg *docxPackage)
+		wantCode  AnomalyCode
+		wantPart  string
+		wantText  string
+		wantFatal bool
+	}{
+		{
+			name:      "missing content types",
+			modify:    func(pkg *docxPackage) { pkg.remove(contentTypesPartName) },
+			wantCode:  AnomalyMissingPart,
+			wantPart:  contentTypesPartName,
+			wantText:  "required part is missing",
+			wantFatal: true,
+		},
+		{
+			name: "duplicate relationship ID",
+			modify: func(pkg *docxPackage) {
+				pkg.set(documentRelationshipsPart, []byte(`<Relationships xmlns="`+relationshipsNamespace+`">`+
+					`<Relationship Id="rId7" Type="t" Target="https://example.com" TargetMode="External"/>`+
+					`<Relationship Id="rId7" Type="t" Target="https://example.org" TargetMode="External"/>`+
+					`</Relationships>`))
+			},
+			wantCode:  AnomalyDuplicateRelationshipID,
+			wantPart:  documentRelationshipsPart,
+			wantText:  "duplicate relationship ID rId7",
+			wantFatal: true,
+		},
+		{
+			name: "cell without paragraph",
+			modify: func(pkg *docxPackage) {
+				pkg.set("word/document.xml", analysisDocumentXML(`<w:tbl><w:tr><w:tc><w:tcPr/></w:tc><w:tc><w:p/></w:tc></w:tr></w:tbl><w:p/>`))
+			},
+			wantCode:  AnomalyInvalidTableStructure,
+			wantPart:  "word/document.xml",
+			wantText:  "1 table cell(s) without a paragraph",
+			wantFatal: true,
+		},
+		{
+			name: "row without cells",
+			modify: func(pkg *docxPackage) {
+				pkg.set("word/document.xml", analysisDocumentXML(`<w:tbl><w:tr><w:trPr/></w:tr></w:tbl><w:p/>`))
+			},
+			wantCode:  AnomalyInvalidTableStructure,
+			wantText:  "1 table row(s) without cells",
+			wantFatal: true,
+		},
+		{
+			name: "table without rows",
+			modify: func(pkg *docxPackage) {
+				pkg.set("word/document.xml", analysisDocumentXML(`<w:tbl><w:tblPr/></w:tbl><w:p/>`))
+			},
+			wantCode:  AnomalyInvalidTableStructure,
+			wantText:  "1 table(s) without rows",
+			wantFatal: true,
+		},
+		{
+			name: "section properties not last",
+			modify: func(pkg *docxPackage) {
+				pkg.set("word/document.xml", analysisDocumentXML(`<w:p/><w:sectPr/><w:p/>`))
+			},
+			wantCode:  AnomalyInvalidSectionProperties,
+			wantText:  "body section properties are followed by w:p",
+			wantFatal: true,
+		},
+		{
+			name: "invalid page size",
+			modify: func(pkg *docxPackage) {
+				pkg.set("word/document.xml", analysisDocumentXML(`<w:p/><w:sectPr><w:pgSz w:w="0" w:h="15840"/></w:sectPr>`))
+			},
+			wantCode:  AnomalyInvalidSectionProperties,
+			wantText:  `invalid page size "0" x "15840"`,
+			wantFatal: true,
+		},
+		{
+			name: "unclosed bookmark",
+			modify: func(pkg *docxPackage) {
+				pkg.set("word/document.xml", analysisDocumentXML(`<w:p><w:bookmarkStart w:id="3" w:name="x"/></w:p>`))
+			},
+			wantCode:  AnomalyUnclosedBookmark,
+			wantText:  "bookmark 3 is never closed",
+			wantFatal: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, err := readDocxPackage(createSimpleDOCX(t, "Hello"))
+			if err != nil {
+				t.Fatalf("failed to read package: %v", err)
+			}
+			tt.modify(pkg)
+			docx, err := pkg.bytes()
+			if err != nil {
+				t.Fatalf("failed to write package: %v", err)
+			}
+
+			anomalies := AnalyzeDocx(docx)
+			if len(anomalies) != 1 {
+				t.Fatalf("expected one anomaly, got %v", anomalies)
+			}
+			got := anomalies[0]
+			if got.Code != tt.wantCode || !strings.Contains(got.Problem, tt.wantText) || got.Fatal != tt.wantFatal || got.Hint == "" {
+				t.Fatalf("unexpected anomaly %+v", got)
+			}
+			if tt.wantPart != "" && got.Part != tt.wantPart {
+				t.Fatalf("anomaly part = %q, want %q", got.Part, tt.wantPart)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDocxValidPackage(t *testing.T) {
+	if anomalies := AnalyzeDocx(createSimpleDOCX(t, "Hello")); anomalies != nil {
+		t.Fatalf("expected no anomalies, got %v", anomalies)
+	}
+
+	pkg, err := readDocxPackage(createSimpleDOCX(t, "Hello"))
+	if err != nil {
+		t.Fatalf("failed to read package: %v", err)
+	}
+	pkg.set("word/document.xml", analysisDocumentXML(
+		`<w:tbl><w:tr><w:tc><w:tbl><w:tr><w:tc><w:p/></w:tc></w:tr></w:tbl></w:tc></w:tr></w:tbl>`+
+			`<w:p><w:pPr><w:sectPr><w:pgSz w:w="12240" w:h="15840"/></w:sectPr></w:pPr></w:p>`+
+			`<w:sectPr><w:pgSz w:w="12240" w:h="15840"/></w:sectPr>`))
+	docx, err := pkg.bytes()
+	if err != nil {
+		t.Fatalf("failed to write package: %v", err)
+	}
+	if anomalies := AnalyzeDocx(docx); anomalies != nil {
+		t.Fatalf("expected nested tables and section breaks to be valid, got %v", anomalies)
+	}
+}
+
+func TestAnalyzeDocxDuplicatePart(t *testing.T) {
+	source, err := readDocxPackage(createSimpleDOCX(t, "Hello"))
+	if err != nil {
+		t.Fatalf("failed to read package: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	names := append(append([]string(nil), source.names...), "word/document.xml")
+	for _, name := range names {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		fw.Write(source.parts[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+
+	anomalies := AnalyzeDocx(buf.Bytes())
+	if len(anomalies) != 1 || anomalies[0].Code != AnomalyDuplicatePart || anomalies[0].Part != "word/document.xml" {
+		t.Fatalf("expected duplicate part anomaly, got %v", anomalies)
+	}
+}
+
+func TestAnalyzeDocxNotAZip(t *testing.T) {
+	anomalies := AnalyzeDocx([]byte("not a docx"))
+	if len(anomalies) != 1 || anomalies[0].Code != AnomalyInvalidPackage || !anomalies[0].Fatal {
+		t.Fatalf("expected invalid package anomaly, got %v", anomalies)
+	}
+	if !strings.HasPrefix(anomalies[0].String(), "INVALID_PACKAGE: ") {
+		t.Fatalf("unexpected string %q", anomalies[0].String())
+	}
+}
@@ -0,0 +1,170 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	contentTypesPartName      = "[Content_Types].xml"
+	packageRelationshipsPart  = "_rels/.rels"
+	documentRelationshipsPart = "word/_rels/document.xml.rels"
+	xmlDeclaration            = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+	relationshipsNamespace    = "http://schemas.openxmlformats.org/package/2006/relationships"
+	contentTypesNamespace     = "http://schemas.openxmlformats.org/package/2006/content-types"
+)
+
+// docxPackage is an in-memory view of a DOCX zip archive. It is used by
+// post-render steps that need to add or rewrite whole package parts after the
+// main document has been rendered. Part order is preserved on write.
+type docxPackage struct {
+	names []string
+	parts map[string][]byte
+	// duplicates lists part names that occur more than once in the archive.
+	// Only the last entry with a name is kept.
+	duplicates []string
+}
+
+// readDocxPackage loads all parts of a DOCX archive into memory.
+func readDocxPackage(data []byte) (*docxPackage, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package: %w", err)
+	}
+
+	pkg := &docxPackage{
+		names: make([]string, 0, len(zr.File)),
+		parts: make(map[string][]byte, len(zr.File)),
+	}
+	for _, file := range zr.File {
+		content, err := readZipFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		if _, exists := pkg.parts[file.Name]; !exists {
+			pkg.names = append(pkg.names, file.Name)
+		} else {
+			pkg.duplicates = append(pkg.duplicates, file.Name)
+		}
+		pkg.parts[file.Name] = content
+	}
+	return pkg, nil
+}
+
+// get returns the content of a part and whether it exists.
+func (p *docxPackage) get(name string) ([]byte, bool) {
+	content, ok := p.parts[name]
+	return content, ok
+}
+
+// set adds or replaces a part.
+func (p *docxPackage) set(name string, content []byte) {
+	if _, exists := p.parts[name]; !exists {
+		p.names = append(p.names, name)
+	}
+	p.parts[name] = content
+}
+
+// remove deletes a part if it exists.
+func (p *docxPackage) remove(name string) {
+	if _, exists := p.parts[name]; !exists {
+		return
+	}
+	delete(p.parts, name)
+	for i, existing := range p.names {
+		if existing == name {
+			p.names = append(p.names[:i], p.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// bytes serializes the package back into a DOCX archive.
+func (p *docxPackage) bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return newPooledFlateWriter(out)
+	})
+
+	for _, name := range p.names {
+		fw, err := w.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		if _, err := fw.Write(p.parts[name]); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close zip writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ensureContentTypeOverride registers an Override entry for partName in
+// [Content_Types].xml unless one already exists.
+func (p *docxPackage) ensureContentTypeOverride(partName, contentType string) error {
+	if !strings.HasPrefix(partName, "/") {
+		partName = "/" + partName
+	}
+
+	contentTypes := &ContentTypes{}
+	if content, ok := p.get(contentTypesPartName); ok {
+		if err := xml.Unmarshal(content, contentTypes); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", contentTypesPartName, err)
+		}
+	}
+	for _, override := range contentTypes.Overrides {
+		if override.PartName == partName {
+			return nil
+		}
+	}
+
+	contentTypes.Overrides = append(contentTypes.Overrides, ContentTypeOverride{
+		PartName:    partName,
+		ContentType: contentType,
+	})
+	if contentTypes.Namespace == "" {
+		contentTypes.Namespace = contentTypesNamespace
+	}
+
+	output, err := xml.Marshal(contentTypes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", contentTypesPartName, err)
+	}
+	p.set(contentTypesPartName, append([]byte(xmlDeclaration), output...))
+	return nil
+}
+
+// ensureRelationship makes sure relsPart contains a relationship of relType
+// pointing at target and returns its ID.
+func (p *docxPackage) ensureRelationship(relsPart, relType, target string) (string, error) {
+	var rels []Relationship
+	if content, ok := p.get(relsPart); ok {
+		rels = parseRelationships(content)
+	}
+	for _, rel := range rels {
+		if rel.Type == relType && rel.Target == target {
+			return rel.ID, nil
+		}
+	}
+
+	id := generateNewRelationshipID(rels)
+	rels = append(rels, Relationship{ID: id, Type: relType, Target: target})
+
+	output, err := xml.Marshal(&Relationships{
+		Namespace:    relationshipsNamespace,
+		Relationship: rels,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", relsPart, err)
+	}
+	p.set(relsPart, append([]byte(xmlDeclaration), output...))
+	return id, nil
+}
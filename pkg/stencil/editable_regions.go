@@ -0,0 +1,186 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// permissionRunRegex matches the runs that carry the w:permStart and
+// w:permEnd elements of editable regions. The elements are range markup
+// that belongs in the paragraph, so the run around them is dropped.
+var permissionRunRegex = regexp.MustCompile(`<w:r>(<w:perm(?:Start|End)\b[^>]*/>)</w:r>`)
+
+// editorGroups lists the groups an editable region can be opened to
+// (ST_EdGrp); any other editor names a single user.
+var editorGroups = map[string]bool{
+	"everyone": true, "current": true, "editors": true, "owners": true,
+	"contributors": true, "administrators": true, "none": true,
+}
+
+// editableRegion is a value rendered as an editable region by editable().
+type editableRegion struct {
+	Value  interface{}
+	Editor string
+}
+
+// editableBoundary is the start or end of an editable region spanning
+// several paragraphs, from editableStart() and editableEnd().
+type editableBoundary struct {
+	End    bool
+	Editor string
+}
+
+// editableRegionState numbers the editable regions of a render and tracks
+// the ones that are still open.
+type editableRegionState struct {
+	count int
+	open  []int
+}
+
+func registerEditableRegionFunctions(registry *DefaultFunctionRegistry) {
+	// editable() function - renders a value that can be edited in a
+	// document that is otherwise read-only
+	editableFn := NewSimpleFunction("editable", 1, 2, func(args ...interface{}) (interface{}, error) {
+		editor, err := editableRegionEditor("editable", args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &OOXMLFragment{Content: &editableRegion{Value: args[0], Editor: editor}}, nil
+	})
+	registry.RegisterFunction(editableFn)
+
+	// editableStart() and editableEnd() functions - mark an editable region
+	// spanning several paragraphs
+	editableStartFn := NewSimpleFunction("editableStart", 0, 1, func(args ...interface{}) (interface{}, error) {
+		editor, err := editableRegionEditor("editableStart", args)
+		if err != nil {
+			return nil, err
+		}
+		return &OOXMLFragment{Content: &editableBoundary{Editor: editor}}, nil
+	})
+	registry.RegisterFunction(editableStartFn)
+
+	editableEndFn := NewSimpleFunction("editableEnd", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: &editableBoundary{End: true}}, nil
+	})
+	registry.RegisterFunction(editableEndFn)
+}
+
+// editableRegionEditor returns the editor argument of an editable region
+// function, "everyone" when it is omitted.
+func editableRegionEditor(name string, args []interface{}) (string, error) {
+	if len(args) == 0 || args[0] == nil {
+		return "everyone", nil
+	}
+	editor, ok := args[0].(string)
+	if !ok {
+		return "", fmt.Errorf("%s() editor must be a string, got %T", name, args[0])
+	}
+	editor = strings.TrimSpace(editor)
+	if editor == "" {
+		return "", fmt.Errorf("%s() editor cannot be empty", name)
+	}
+	return editor, nil
+}
+
+// editableRegions returns the editable region state of the render.
+func (ctx *renderContext) editableRegions() *editableRegionState {
+	if ctx.editable == nil {
+		ctx.editable = &editableRegionState{}
+	}
+	return ctx.editable
+}
+
+// runs returns the runs of the value between the start and end of a new
+// editable region.
+func (r *editableRegion) runs(templateRun *Run, ctx *renderContext) ([]Run, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("editable() requires a render context")
+	}
+	state := ctx.editableRegions()
+	state.count++
+	id := state.count
+
+	var valueRuns []Run
+	if fragment, ok := r.Value.(*OOXMLFragment); ok {
+		var err error
+		valueRuns, _, err = fragmentContentRuns(fragment.Content, templateRun, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if valueRuns == nil {
+			return nil, fmt.Errorf("editable() value must be inline content")
+		}
+	} else {
+		valueRuns = []Run{{
+			Properties: templateRun.Properties,
+			Attrs:      templateRun.Attrs,
+			Text:       &Text{Content: ctx.formatText(r.Value), Space: "preserve"},
+		}}
+	}
+
+	runs := []Run{permissionRun(id, r.Editor, false)}
+	runs = append(runs, valueRuns...)
+	return append(runs, permissionRun(id, "", true)), nil
+}
+
+// run returns the run of the start or end of an editable region.
+func (b *editableBoundary) run(ctx *renderContext) (Run, error) {
+	if ctx == nil {
+		return Run{}, fmt.Errorf("editable regions require a render context")
+	}
+	state := ctx.editableRegions()
+	if !b.End {
+		state.count++
+		state.open = append(state.open, state.count)
+		return permissionRun(state.count, b.Editor, false), nil
+	}
+	if len(state.open) == 0 {
+		return Run{}, fmt.Errorf("editableEnd() without editableStart()")
+	}
+	id := state.open[len(state.open)-1]
+	state.open = state.open[:len(state.open)-1]
+	return permissionRun(id, "", true), nil
+}
+
+// checkEditableRegionsClosed reports an error when an editableStart() in
+// part has no editableEnd().
+func (ctx *renderContext) checkEditableRegionsClosed(part string) error {
+	if ctx == nil || ctx.editable == nil || len(ctx.editable.open) == 0 {
+		return nil
+	}
+	ctx.editable.open = nil
+	return fmt.Errorf("editableStart() without editableEnd() in %s", part)
+}
+
+// permissionRun returns a run carrying the w:permStart or w:permEnd element
+// of the editable region id.
+func permissionRun(id int, editor string, end bool) Run {
+	var element string
+	local := "permStart"
+	switch {
+	case end:
+		local = "permEnd"
+		element = `<w:permEnd w:id="` + strconv.Itoa(id) + `"/>`
+	case editorGroups[strings.ToLower(editor)]:
+		element = `<w:permStart w:id="` + strconv.Itoa(id) + `" w:edGrp="` + strings.ToLower(editor) + `"/>`
+	default:
+		element = `<w:permStart w:id="` + strconv.Itoa(id) + `" w:ed="` + escapeXMLText(editor) + `"/>`
+	}
+	return Run{RawXML: []RawXMLElement{{
+		XMLName: xml.Name{Space: wordprocessingMLNamespace, Local: local},
+		Content: []byte(element),
+	}}}
+}
+
+// unwrapPermissionRuns moves the w:permStart and w:permEnd elements of
+// editable regions out of the runs that carried them through rendering.
+func unwrapPermissionRuns(xmlChunk string) string {
+	if !strings.Contains(xmlChunk, "<w:perm") {
+		return xmlChunk
+	}
+	return permissionRunRegex.ReplaceAllString(xmlChunk, "$1")
+}
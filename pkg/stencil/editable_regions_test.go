@@ -0,0 +1,109 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEditableRegions(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Contract for {{customer}}`,
+		`Signed by: {{editable(signer)}} on {{editable("", "legal@example.com")}}`,
+		`{{editableStart("editors")}}Remarks:`,
+		`{{remarks}}{{editableEnd()}}`,
+	})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"customer": "Acme", "signer": "Jane Doe", "remarks": "none"}, RenderOptions{})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	assertInOrder(t, documentXML,
+		`Contract for Acme`,
+		`<w:permStart w:id="1" w:edGrp="everyone"/>`,
+		`Jane Doe</w:t>`,
+		`<w:permEnd w:id="1"/>`,
+		`<w:permStart w:id="2" w:ed="legal@example.com"/><w:permEnd w:id="2"/>`,
+		`<w:permStart w:id="3" w:edGrp="editors"/>`,
+		`Remarks:`,
+		`none`,
+		`<w:permEnd w:id="3"/>`,
+	)
+	if strings.Contains(documentXML, "<w:r><w:perm") {
+		t.Errorf("permission markers left in runs: %s", documentXML)
+	}
+
+	settings := extractPartFromDOCX(t, output, "word/settings.xml")
+	if !strings.Contains(settings, `<w:documentProtection w:edit="readOnly" w:enforcement="1"/>`) {
+		t.Errorf("expected read-only protection in settings: %s", settings)
+	}
+	rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")
+	if !strings.Contains(rels, `Target="settings.xml"`) {
+		t.Errorf("expected settings relationship: %s", rels)
+	}
+
+	plain := renderWithOptionsToBytes(t, createSimpleDOCX(t, "Hello {{name}}"), TemplateData{"name": "World"}, RenderOptions{})
+	if strings.Contains(extractDocumentXMLFromDOCX(t, plain), "<w:perm") {
+		t.Error("permission ranges added without editable regions")
+	}
+}
+
+func TestEditableRegionsUnbalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"end without start", `{{editableEnd()}}`, "editableEnd() without editableStart()"},
+		{"start without end", `{{editableStart()}}text`, "editableStart() without editableEnd() in word/document.xml"},
+		{"invalid editor", `{{editable("x", 42)}}`, "editable() editor must be a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := prepare(bytes.NewReader(createSimpleDOCX(t, tt.template)))
+			if err != nil {
+				t.Fatalf("failed to prepare template: %v", err)
+			}
+			defer tmpl.Close()
+			_, err = tmpl.Render(TemplateData{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Render() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsElementOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		want     string
+	}{
+		{
+			"before later elements",
+			`<w:settings xmlns:w="w"><w:zoom w:percent="100"/><w:proofState w:spelling="clean"/><w:defaultTabStop w:val="720"/></w:settings>`,
+			`<w:proofState w:spelling="clean"/>|<w:defaultTabStop`,
+		},
+		{
+			"at the end",
+			`<w:settings xmlns:w="w"><w:zoom w:percent="100"/></w:settings>`,
+			`<w:zoom w:percent="100"/>|</w:settings>`,
+		},
+		{
+			"after nested elements",
+			`<w:settings xmlns:w="w"><w:mailMerge><w:mainDocumentType w:val="formLetters"/></w:mailMerge><w:compat/></w:settings>`,
+			`</w:mailMerge>|<w:compat/>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, err := settingsElementOffset([]byte(tt.settings), func(name string) bool {
+				return settingsElementsBeforeProtection[name]
+			})
+			if err != nil {
+				t.Fatalf("settingsElementOffset() error = %v", err)
+			}
+			if got := tt.settings[:offset] + "|" + tt.settings[offset:]; !strings.Contains(got, tt.want) {
+				t.Errorf("insertion point in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	vmlNamespace       = "urn:schemas-microsoft-com:vml"
+	officeVMLNamespace = "urn:schemas-microsoft-com:office:office"
+
+	oleObjectRelationType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/oleObject"
+	packageRelationType   = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/package"
+	imageRelationType     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+	oleObjectContentType  = "application/vnd.openxmlformats-officedocument.oleObject"
+
+	// embeddedFileShapeBase offsets the VML shape IDs of embedded files from
+	// those Word assigns itself
+	embeddedFileShapeBase = 3000
+)
+
+// embeddedPackageTypes lists the files that are embedded as they are, as
+// package parts opened by their application, with their ProgID and content
+// type. Other files are wrapped in an OLE Packager object.
+var embeddedPackageTypes = map[string]struct{ progID, contentType string }{
+	"xlsx": {"Excel.Sheet.12", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"xlsm": {"Excel.SheetMacroEnabled.12", "application/vnd.ms-excel.sheet.macroEnabled.12"},
+	"docx": {"Word.Document.12", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	"docm": {"Word.DocumentMacroEnabled.12", "application/vnd.ms-word.document.macroEnabled.12"},
+	"pptx": {"PowerPoint.Show.12", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+	"pptm": {"PowerPoint.ShowMacroEnabled.12", "application/vnd.ms-powerpoint.presentation.macroEnabled.12"},
+}
+
+// shapeType75 defines the VML picture frame shape the icons of embedded
+// files are drawn with.
+const shapeType75 = `<v:shapetype id="_x0000_t75" coordsize="21600,21600" o:spt="75" o:preferrelative="t" path="m@4@5l@4@11@9@11@9@5xe" filled="f" stroked="f">` +
+	`<v:stroke joinstyle="miter"/><v:formulas>` +
+	`<v:f eqn="if lineDrawn pixelLineWidth 0"/><v:f eqn="sum @0 1 0"/><v:f eqn="sum 0 0 @1"/><v:f eqn="prod @2 1 2"/>` +
+	`<v:f eqn="prod @3 21600 pixelWidth"/><v:f eqn="prod @3 21600 pixelHeight"/><v:f eqn="sum @0 0 1"/><v:f eqn="prod @6 1 2"/>` +
+	`<v:f eqn="prod @7 21600 pixelWidth"/><v:f eqn="sum @8 21600 0"/><v:f eqn="prod @7 21600 pixelHeight"/><v:f eqn="sum @10 21600 0"/>` +
+	`</v:formulas><v:path o:extrusionok="f" gradientshapeok="t" o:connecttype="rect"/><o:lock v:ext="edit" aspectratio="t"/></v:shapetype>`
+
+// embeddedFile is a file embedded by embedFile().
+type embeddedFile struct {
+	Name string
+	Data []byte
+	Icon []byte
+}
+
+// embeddedPart is a part written to word/embeddings for an embedded file.
+type embeddedPart struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// embedFileFunc implements embedFile(data, name[, icon]).
+func embedFileFunc(args ...interface{}) (interface{}, error) {
+	var data []byte
+	switch v := args[0].(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil, fmt.Errorf("embedFile() data must be bytes or a string, got %T", args[0])
+	}
+	name, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("embedFile() name must be a string, got %T", args[1])
+	}
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name == "" || name == "." || name == "/" {
+		return nil, fmt.Errorf("embedFile() name cannot be empty")
+	}
+
+	file := &embeddedFile{Name: name, Data: data}
+	if len(args) > 2 && args[2] != nil {
+		icon, ok := args[2].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("embedFile() icon must be image bytes, got %T", args[2])
+		}
+		if iconExtension(icon) == "" {
+			return nil, fmt.Errorf("embedFile() icon must be a PNG, JPEG, GIF, BMP or EMF image")
+		}
+		file.Icon = icon
+	}
+	return &OOXMLFragment{Content: file}, nil
+}
+
+func registerEmbedFileFunction(registry *DefaultFunctionRegistry) {
+	// embedFile() function - embeds a file as an object shown as an icon
+	embedFileFn := NewSimpleFunction("embedFile", 2, 3, embedFileFunc)
+	registry.RegisterFunction(embedFileFn)
+}
+
+// runs adds the parts and relationships of the embedded file to the render
+// and returns the runs that show it: the object drawn as its icon, followed
+// by the file name as a caption on the next line.
+func (f *embeddedFile) runs(templateRun *Run, ctx *renderContext) ([]Run, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("embedFile() requires a render context")
+	}
+	if ctx.storyPart != "" {
+		return nil, fmt.Errorf("embedFile() is only supported in the main document, not in %s", ctx.storyPart)
+	}
+	for prefix, uri := range map[string]string{"v": vmlNamespace, "o": officeVMLNamespace, "r": officeDocumentRelationshipsNamespace} {
+		if existing, ok := ctx.collectedNamespaces[prefix]; ok && existing != uri {
+			return nil, fmt.Errorf("embedFile(): namespace prefix %q is already used for %q", prefix, existing)
+		}
+	}
+	ids, err := ctx.relationshipIDAllocator()
+	if err != nil {
+		return nil, err
+	}
+
+	number := len(ctx.embeddedFiles) + 1
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(f.Name), "."))
+	progID := "Package"
+	part := embeddedPart{contentType: oleObjectContentType}
+	relationshipType := oleObjectRelationType
+	if packageType, ok := embeddedPackageTypes[ext]; ok {
+		progID = packageType.progID
+		part.contentType = packageType.contentType
+		part.data = f.Data
+		part.name = ctx.unusedPartName("embeddings", "embeddedFile", number, ext)
+		relationshipType = packageRelationType
+	} else {
+		part.data, err = olePackage(f.Name, f.Data)
+		if err != nil {
+			return nil, fmt.Errorf("embedFile() %s: %w", f.Name, err)
+		}
+		part.name = ctx.unusedPartName("embeddings", "oleObject", number, "bin")
+	}
+
+	icon := f.Icon
+	if icon == nil {
+		icon = defaultEmbeddedFileIcon()
+	}
+	iconName := ctx.unusedPartName("media", "embeddedFileIcon", number, iconExtension(icon))
+	widthPt, heightPt := iconSize(icon)
+
+	objectID, iconID := ids.allocate(), ids.allocate()
+	ctx.embeddedFiles = append(ctx.embeddedFiles, part)
+	ctx.fragmentMedia[iconName] = icon
+	ctx.fragmentRelationships = append(ctx.fragmentRelationships,
+		Relationship{ID: objectID, Type: relationshipType, Target: "embeddings/" + part.name},
+		Relationship{ID: iconID, Type: imageRelationType, Target: "media/" + iconName},
+	)
+	ctx.collectedNamespaces["v"] = vmlNamespace
+	ctx.collectedNamespaces["o"] = officeVMLNamespace
+	ctx.collectedNamespaces["r"] = officeDocumentRelationshipsNamespace
+
+	shapeID := "_x0000_i" + strconv.Itoa(embeddedFileShapeBase+number)
+	width, height := strconv.FormatFloat(widthPt, 'f', -1, 64), strconv.FormatFloat(heightPt, 'f', -1, 64)
+	var object bytes.Buffer
+	fmt.Fprintf(&object, `<w:object w:dxaOrig="%d" w:dyaOrig="%d">`, int(widthPt*20), int(heightPt*20))
+	object.WriteString(shapeType75)
+	fmt.Fprintf(&object, `<v:shape id="%s" type="#_x0000_t75" style="width:%spt;height:%spt" o:ole="">`, shapeID, width, height)
+	fmt.Fprintf(&object, `<v:imagedata r:id="%s" o:title="%s"/></v:shape>`, iconID, escapeXMLText(f.Name))
+	fmt.Fprintf(&object, `<o:OLEObject Type="Embed" ProgID="%s" ShapeID="%s" DrawAspect="Icon" ObjectID="_%d" r:id="%s"/>`,
+		progID, shapeID, 1000000000+number, objectID)
+	object.WriteString(`</w:object>`)
+
+	return []Run{
+		{
+			Properties: templateRun.Properties,
+			RawXML:     []RawXMLElement{{XMLName: xml.Name{Local: "object"}, Content: object.Bytes()}},
+		},
+		{Properties: templateRun.Properties, Break: &Break{}},
+		{
+			Properties: templateRun.Properties,
+			Attrs:      templateRun.Attrs,
+			Text:       &Text{Content: f.Name, Space: "preserve"},
+		},
+	}, nil
+}
+
+// unusedPartName returns the name of a new part in word/dir, prefix followed
+// by the lowest number from number on that neither the template nor this
+// render uses yet.
+func (ctx *renderContext) unusedPartName(dir, prefix string, number int, ext string) string {
+	used := make(map[string]bool)
+	if ctx.template != nil && ctx.template.docxReader != nil {
+		for _, name := range ctx.template.docxReader.ListParts() {
+			used[name] = true
+		}
+	}
+	for name := range ctx.fragmentMedia {
+		used["word/media/"+name] = true
+	}
+	for _, part := range ctx.embeddedFiles {
+		used["word/embeddings/"+part.name] = true
+	}
+	for _, part := range ctx.importedSectionParts {
+		used[part.name] = true
+	}
+	for ; ; number++ {
+		name := prefix + strconv.Itoa(number) + "." + ext
+		if !used[path.Join("word", dir, name)] {
+			return name
+		}
+	}
+}
+
+// iconExtension returns the file extension of the icon image format, or ""
+// when it is not supported.
+func iconExtension(icon []byte) string {
+	switch {
+	case bytes.HasPrefix(icon, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(icon, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg"
+	case bytes.HasPrefix(icon, []byte("GIF8")):
+		return "gif"
+	case bytes.HasPrefix(icon, []byte("BM")) && len(icon) >= 26:
+		return "bmp"
+	case len(icon) >= 44 && bytes.Equal(icon[40:44], []byte(" EMF")):
+		return "emf"
+	}
+	return ""
+}
+
+// iconSize returns the display size of an icon in points, taking a pixel
+// as 0.75pt. Icons whose size cannot be read are shown at 48pt.
+func iconSize(icon []byte) (float64, float64) {
+	width, height := 64, 64
+	switch iconExtension(icon) {
+	case "bmp":
+		width = int(int32(binary.LittleEndian.Uint32(icon[18:])))
+		height = int(int32(binary.LittleEndian.Uint32(icon[22:])))
+		if height < 0 {
+			height = -height
+		}
+	case "png", "jpeg", "gif":
+		if w, h, ok := imageSize(icon); ok {
+			width, height = w, h
+		}
+	}
+	if width <= 0 || height <= 0 {
+		width, height = 64, 64
+	}
+	return float64(width) * 0.75, float64(height) * 0.75
+}
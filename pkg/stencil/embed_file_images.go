@@ -0,0 +1,58 @@
+//go:build !stencil_noimages && !stencil_lite
+
+package stencil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"sync"
+)
+
+// imageSize returns the size in pixels of a PNG, JPEG or GIF image.
+func imageSize(data []byte) (int, int, bool) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+var (
+	defaultIconOnce sync.Once
+	defaultIcon     []byte
+)
+
+// defaultEmbeddedFileIcon returns the icon of embedded files without one of
+// their own: a sheet of paper with a folded corner.
+func defaultEmbeddedFileIcon() []byte {
+	defaultIconOnce.Do(func() {
+		const width, height, fold = 32, 40, 10
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		paper := color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+		edge := color.NRGBA{R: 0x5F, G: 0x6B, B: 0x7A, A: 0xFF}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				corner := x - (width - fold) // distance into the folded corner
+				switch {
+				case corner > y:
+					// cut off by the fold
+				case x == 0 || y == height-1 || x == width-1 || y == 0 || corner == y || (corner >= 0 && y == fold):
+					img.Set(x, y, edge)
+				case corner == 0 && y < fold:
+					img.Set(x, y, edge)
+				default:
+					img.Set(x, y, paper)
+				}
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err == nil {
+			defaultIcon = buf.Bytes()
+		}
+	})
+	return defaultIcon
+}
@@ -0,0 +1,44 @@
+//go:build stencil_noimages || stencil_lite
+
+package stencil
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Without the image packages, left out by the stencil_noimages and
+// stencil_lite tags, image sizes are read from the PNG and GIF headers and
+// JPEG images are shown at the default size.
+
+// imageSize returns the size in pixels of a PNG or GIF image.
+func imageSize(data []byte) (int, int, bool) {
+	switch {
+	case len(data) >= 24 && bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) && string(data[12:16]) == "IHDR":
+		return int(binary.BigEndian.Uint32(data[16:])), int(binary.BigEndian.Uint32(data[20:])), true
+	case len(data) >= 10 && bytes.HasPrefix(data, []byte("GIF8")):
+		return int(binary.LittleEndian.Uint16(data[6:])), int(binary.LittleEndian.Uint16(data[8:])), true
+	}
+	return 0, 0, false
+}
+
+// defaultEmbeddedFileIcon returns the icon of embedded files without one of
+// their own, the PNG image full builds draw.
+func defaultEmbeddedFileIcon() []byte {
+	return defaultEmbeddedFileIconPNG
+}
+
+var defaultEmbeddedFileIconPNG = []byte("" +
+	"\x89\x50\x4e\x47\x0d\x0a\x1a\x0a\x00\x00\x00\x0d\x49\x48\x44\x52" +
+	"\x00\x00\x00\x20\x00\x00\x00\x28\x08\x06\x00\x00\x00\x9f\x29\xf8" +
+	"\x99\x00\x00\x00\x8a\x49\x44\x41\x54\x78\x9c\xec\xd7\x3b\x0e\x84" +
+	"\x30\x0c\x84\x61\x67\x95\x7b\xee\xf6\xcb\x09\xa0\x86\x13\x40\x0f" +
+	"\x27\x0d\x72\x61\x29\x42\xe4\xd1\x8c\xd3\xcc\x4f\x63\x51\x44\x9f" +
+	"\xa5\x34\x89\xdf\xff\x9c\x04\xd4\x75\x6c\xc1\xe6\x62\x0a\x40\xa4" +
+	"\xe7\xf6\x2c\xf7\xb1\x01\xd1\xb9\xaf\xd2\x42\x40\x01\x3d\x08\x38" +
+	"\xa0\x85\x70\x01\xd4\x10\x6e\x80\x12\xc2\x15\xf0\x86\x70\x07\x3c" +
+	"\x11\xd1\x7e\x7a\xa7\x08\x11\x49\x50\xc0\x6f\x5a\x6c\x2c\x16\xc1" +
+	"\x1b\x56\x53\xe0\x90\x3b\x90\x7f\x04\x10\x40\x00\x01\x04\x10\x40" +
+	"\x00\x01\x04\x10\x40\x00\x01\x04\x84\xfc\xad\x3e\xa2\x7b\x00\xc4" +
+	"\x3d\x83\xf1\x9e\x93\x68\x2e\x00\x00\x00\x00\x49\x45\x4e\x44\xae" +
+	"\x42\x60\x82")
@@ -0,0 +1,194 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestEmbedFile(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Source data: {{embedFile(workbook, "audit data.xlsx")}}`,
+		`Notes: {{embedFile(notes, "notes.txt")}}`,
+	})
+	workbook := []byte("PK\x03\x04 workbook")
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"workbook": workbook, "notes": "checked"}, RenderOptions{})
+
+	if got := extractPartFromDOCX(t, output, "word/embeddings/embeddedFile1.xlsx"); got != string(workbook) {
+		t.Errorf("embedded workbook = %q, want %q", got, workbook)
+	}
+	ole := []byte(extractPartFromDOCX(t, output, "word/embeddings/oleObject2.bin"))
+	if native := readCompoundFileStream(t, ole, "\x01Ole10Native"); !bytes.HasSuffix(native, []byte("notes.txt\x00\x07\x00\x00\x00checked")) {
+		t.Errorf("Ole10Native stream = %q", native)
+	}
+	if compObj := readCompoundFileStream(t, ole, "\x01CompObj"); !bytes.Contains(compObj, []byte("Package\x00")) {
+		t.Errorf("CompObj stream = %q", compObj)
+	}
+	if icon := extractPartFromDOCX(t, output, "word/media/embeddedFileIcon1.png"); !strings.HasPrefix(icon, "\x89PNG") {
+		t.Error("default icon is not a PNG image")
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	assertInOrder(t, documentXML,
+		`xmlns:o="urn:schemas-microsoft-com:office:office"`,
+		`Source data: </w:t>`,
+		`<w:object w:dxaOrig="480" w:dyaOrig="600">`,
+		`<v:shape id="_x0000_i3001" type="#_x0000_t75" style="width:24pt;height:30pt" o:ole="">`,
+		`o:title="audit data.xlsx"`,
+		`<o:OLEObject Type="Embed" ProgID="Excel.Sheet.12" ShapeID="_x0000_i3001" DrawAspect="Icon"`,
+		`audit data.xlsx</w:t>`,
+		`ProgID="Package" ShapeID="_x0000_i3002"`,
+		`notes.txt</w:t>`,
+	)
+
+	rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")
+	for _, want := range []string{
+		`relationships/package" Target="embeddings/embeddedFile1.xlsx"`,
+		`relationships/oleObject" Target="embeddings/oleObject2.bin"`,
+		`relationships/image" Target="media/embeddedFileIcon2.png"`,
+	} {
+		if !strings.Contains(rels, want) {
+			t.Errorf("expected %s in relationships: %s", want, rels)
+		}
+	}
+	contentTypes := extractPartFromDOCX(t, output, "[Content_Types].xml")
+	for _, want := range []string{
+		`PartName="/word/embeddings/embeddedFile1.xlsx" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"`,
+		`PartName="/word/embeddings/oleObject2.bin" ContentType="application/vnd.openxmlformats-officedocument.oleObject"`,
+		`Extension="png" ContentType="image/png"`,
+	} {
+		if !strings.Contains(contentTypes, want) {
+			t.Errorf("expected %s in content types: %s", want, contentTypes)
+		}
+	}
+}
+
+func TestEmbedFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"missing name", `{{embedFile(data, "")}}`, "name cannot be empty"},
+		{"invalid data", `{{embedFile(42, "a.txt")}}`, "data must be bytes or a string"},
+		{"invalid icon", `{{embedFile(data, "a.txt", data)}}`, "icon must be a PNG, JPEG, GIF, BMP or EMF image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := prepare(bytes.NewReader(createSimpleDOCX(t, tt.template)))
+			if err != nil {
+				t.Fatalf("failed to prepare template: %v", err)
+			}
+			defer tmpl.Close()
+			_, err = tmpl.Render(TemplateData{"data": []byte("text")})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Render() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`<w:p><w:r><w:t>Body</w:t></w:r></w:p>`),
+		"word/header1.xml":  validationHeaderXML(`<w:p><w:r><w:t>{{embedFile("x", "a.txt")}}</w:t></w:r></w:p>`),
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), "only supported in the main document") {
+		t.Errorf("Render() error = %v, want header error", err)
+	}
+}
+
+func TestOLEPackageLargeFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20000)
+	ole, err := olePackage("data.csv", content)
+	if err != nil {
+		t.Fatalf("olePackage() error = %v", err)
+	}
+	native := readCompoundFileStream(t, ole, "\x01Ole10Native")
+	if !bytes.HasSuffix(native, content) || int(binary.LittleEndian.Uint32(native)) != len(native)-4 {
+		t.Errorf("Ole10Native stream of %d bytes does not hold the file", len(native))
+	}
+	if _, err := olePackage("big.bin", make([]byte, cfbMaxFileSize)); err == nil {
+		t.Error("expected an error for a file too large to package")
+	}
+}
+
+// readCompoundFileStream reads a stream of the root storage of a compound
+// file written by compoundFile.
+func readCompoundFileStream(t *testing.T, file []byte, name string) []byte {
+	t.Helper()
+	if !bytes.HasPrefix(file, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}) {
+		t.Fatal("missing compound file signature")
+	}
+	u32 := func(b []byte, off int) uint32 { return binary.LittleEndian.Uint32(b[off:]) }
+	sector := func(id uint32) []byte {
+		start := int(id+1) * cfbSectorSize
+		return file[start : start+cfbSectorSize]
+	}
+
+	var fat []uint32
+	for i := 0; i < int(u32(file, 44)); i++ {
+		s := sector(u32(file, 76+4*i))
+		for off := 0; off < cfbSectorSize; off += 4 {
+			fat = append(fat, u32(s, off))
+		}
+	}
+	chain := func(start uint32) []byte {
+		var data []byte
+		for id := start; id != cfbEndOfChain; id = fat[id] {
+			data = append(data, sector(id)...)
+		}
+		return data
+	}
+
+	directory := chain(u32(file, 48))
+	miniStream := chain(u32(directory, 116))
+	var miniFAT []uint32
+	if start := u32(file, 60); start != cfbEndOfChain {
+		data := chain(start)
+		for off := 0; off < len(data); off += 4 {
+			miniFAT = append(miniFAT, u32(data, off))
+		}
+	}
+
+	for off := cfbEntrySize; off < len(directory); off += cfbEntrySize {
+		entry := directory[off : off+cfbEntrySize]
+		units := make([]uint16, binary.LittleEndian.Uint16(entry[64:])/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(entry[2*i:])
+		}
+		if len(units) == 0 || string(utf16.Decode(units[:len(units)-1])) != name {
+			continue
+		}
+		start, size := u32(entry, 116), int(u32(entry, 120))
+		var data []byte
+		if size >= cfbMiniCutoff {
+			data = chain(start)
+		} else {
+			for id := start; id != cfbEndOfChain; id = miniFAT[id] {
+				data = append(data, miniStream[int(id)*cfbMiniSectorSize:int(id+1)*cfbMiniSectorSize]...)
+			}
+		}
+		return data[:size]
+	}
+	t.Fatalf("stream %q not found", name)
+	return nil
+}
+
+func TestImageSize(t *testing.T) {
+	if width, height, ok := imageSize(defaultEmbeddedFileIcon()); !ok || width != 32 || height != 40 {
+		t.Errorf("imageSize(default icon) = %d, %d, %v; want 32, 40, true", width, height, ok)
+	}
+	gif := []byte("GIF89a\x10\x00\x08\x00\x00\x00\x00;")
+	if width, height, ok := imageSize(gif); !ok || width != 16 || height != 8 {
+		t.Errorf("imageSize(gif) = %d, %d, %v; want 16, 8, true", width, height, ok)
+	}
+	if _, _, ok := imageSize([]byte("not an image")); ok {
+		t.Error("imageSize succeeded for data that is not an image")
+	}
+}
@@ -0,0 +1,379 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Emoji in data render as empty boxes on machines whose fonts lack them
+// (RenderOptions.Emoji). Once the main document is assembled, each emoji in
+// its text is either replaced by an image at the font size of its run, such
+// as a Twemoji PNG, or set in an emoji font, so documents look the same
+// wherever they are opened.
+
+// defaultEmojiFont is the font emoji are set in without an image. It ships
+// with Windows; other systems substitute their own emoji font.
+const defaultEmojiFont = "Segoe UI Emoji"
+
+// emojiShapeBase offsets the VML shape IDs of emoji images from those of
+// embedded files and those Word assigns itself
+const emojiShapeBase = 4000
+
+// defaultEmojiSize is the font size, in half-points, of runs that do not set
+// their own.
+const defaultEmojiSize = 22
+
+// EmojiOptions sets how emoji in the rendered main document are drawn
+// (RenderOptions.Emoji).
+type EmojiOptions struct {
+	// Images returns the image of an emoji, such as the Twemoji PNG named
+	// TwemojiName(emoji) + ".png". It is called once per distinct emoji of
+	// a render. An emoji without an image (nil) is set in Font. When
+	// Images is nil, all emoji are set in Font.
+	Images func(emoji string) ([]byte, error)
+	// Font is the font emoji without an image are set in. Defaults to
+	// "Segoe UI Emoji".
+	Font string
+}
+
+// TwemojiName returns the name Twemoji gives the image of an emoji: its code
+// points in lowercase hex, joined by dashes, such as "1f44d-1f3fd". The
+// emoji presentation selector U+FE0F is left out unless the emoji is a
+// sequence joined with U+200D.
+func TwemojiName(emoji string) string {
+	joined := strings.ContainsRune(emoji, 0x200D)
+	parts := make([]string, 0, len(emoji)/4+1)
+	for _, r := range emoji {
+		if r == 0xFE0F && !joined {
+			continue
+		}
+		parts = append(parts, strconv.FormatInt(int64(r), 16))
+	}
+	return strings.Join(parts, "-")
+}
+
+// emojiPresentationRanges are the characters below U+1F000 that are shown as
+// emoji by default. Other symbols are only emoji when followed by U+FE0F.
+var emojiPresentationRanges = [][2]rune{
+	{0x231A, 0x231B}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0}, {0x23F3, 0x23F3},
+	{0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2648, 0x2653}, {0x267F, 0x267F},
+	{0x2693, 0x2693}, {0x26A1, 0x26A1}, {0x26AA, 0x26AB}, {0x26BD, 0x26BE},
+	{0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4}, {0x26EA, 0x26EA},
+	{0x26F2, 0x26F3}, {0x26F5, 0x26F5}, {0x26FA, 0x26FA}, {0x26FD, 0x26FD},
+	{0x2705, 0x2705}, {0x270A, 0x270B}, {0x2728, 0x2728}, {0x274C, 0x274C},
+	{0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757}, {0x2795, 0x2797},
+	{0x27B0, 0x27B0}, {0x27BF, 0x27BF}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50},
+	{0x2B55, 0x2B55},
+}
+
+// isEmojiPresentation reports whether r is shown as an emoji by default.
+func isEmojiPresentation(r rune) bool {
+	if r >= 0x1F000 && r <= 0x1FAFF {
+		return true
+	}
+	for _, rng := range emojiPresentationRanges {
+		if r >= rng[0] && r <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmojiModifier reports whether r belongs to the emoji before it: the
+// emoji presentation selector, the keycap mark, skin tones and the tags of
+// subdivision flags.
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0F || r == 0x20E3 || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F)
+}
+
+// emojiLength returns the number of runes of the emoji starting at
+// runes[i], including its modifiers and the emoji joined to it, or 0 when
+// no emoji starts there.
+func emojiLength(runes []rune, i int) int {
+	at := func(k int) rune {
+		if k < len(runes) {
+			return runes[k]
+		}
+		return 0
+	}
+	isRegional := func(r rune) bool { return r >= 0x1F1E6 && r <= 0x1F1FF }
+	startsEmoji := func(k int) bool {
+		r := at(k)
+		if (r >= '0' && r <= '9') || r == '#' || r == '*' {
+			// Keycaps such as 1️⃣
+			return at(k+1) == 0x20E3 || (at(k+1) == 0xFE0F && at(k+2) == 0x20E3)
+		}
+		return r != 0 && r != 0x200D && !isEmojiModifier(r) && (isEmojiPresentation(r) || at(k+1) == 0xFE0F)
+	}
+
+	if !startsEmoji(i) {
+		return 0
+	}
+	j := i + 1
+	if isRegional(at(i)) && isRegional(at(j)) {
+		// Flags are pairs of regional indicators
+		j++
+	}
+	for j < len(runes) {
+		switch {
+		case isEmojiModifier(runes[j]):
+			j++
+		case runes[j] == 0x200D && startsEmoji(j+1):
+			j += 2
+		default:
+			return j - i
+		}
+	}
+	return j - i
+}
+
+// emojiSegment is a part of a text that is either plain text or one emoji.
+type emojiSegment struct {
+	Text  string
+	Emoji bool
+}
+
+// splitEmoji splits text into plain text and single emoji. It returns nil
+// when the text holds no emoji.
+func splitEmoji(text string) []emojiSegment {
+	runes := []rune(text)
+	var segments []emojiSegment
+	start := 0
+	for i := 0; i < len(runes); {
+		n := emojiLength(runes, i)
+		if n == 0 {
+			i++
+			continue
+		}
+		if i > start {
+			segments = append(segments, emojiSegment{Text: string(runes[start:i])})
+		}
+		segments = append(segments, emojiSegment{Text: string(runes[i : i+n]), Emoji: true})
+		i += n
+		start = i
+	}
+	if segments == nil {
+		return nil
+	}
+	if start < len(runes) {
+		segments = append(segments, emojiSegment{Text: string(runes[start:])})
+	}
+	return segments
+}
+
+// emojiImage is an emoji image added to the rendered package.
+type emojiImage struct {
+	id     string  // relationship ID
+	aspect float64 // width divided by height
+}
+
+// emojiRenderer draws the emoji of a rendered document.
+type emojiRenderer struct {
+	opts   *EmojiOptions
+	font   string
+	ctx    *renderContext
+	images map[string]*emojiImage // by emoji; nil for emoji set in the font
+	shapes int
+}
+
+// renderEmoji replaces the emoji in the text of the paragraphs of elements,
+// also in tables, with images or runs in the emoji font of opts.
+func renderEmoji(elements []BodyElement, opts *EmojiOptions, ctx *renderContext) error {
+	r := &emojiRenderer{opts: opts, font: opts.Font, ctx: ctx, images: make(map[string]*emojiImage)}
+	if r.font == "" {
+		r.font = defaultEmojiFont
+	}
+	for _, para := range bodyElementParagraphs(elements) {
+		if err := r.renderParagraph(para); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *emojiRenderer) renderParagraph(para *Paragraph) error {
+	if len(para.Content) > 0 {
+		content := make([]ParagraphContent, 0, len(para.Content))
+		for _, item := range para.Content {
+			switch c := item.(type) {
+			case *Run:
+				runs, err := r.splitRun(c)
+				if err != nil {
+					return err
+				}
+				if runs == nil {
+					content = append(content, c)
+					continue
+				}
+				for i := range runs {
+					content = append(content, &runs[i])
+				}
+			case *Hyperlink:
+				runs, err := r.splitRuns(c.Runs)
+				if err != nil {
+					return err
+				}
+				link := *c
+				link.Runs = runs
+				content = append(content, &link)
+			default:
+				content = append(content, item)
+			}
+		}
+		para.Content = content
+		return nil
+	}
+
+	runs, err := r.splitRuns(para.Runs)
+	if err != nil {
+		return err
+	}
+	para.Runs = runs
+	if len(para.Hyperlinks) > 0 {
+		links := append([]Hyperlink(nil), para.Hyperlinks...)
+		for i := range links {
+			if links[i].Runs, err = r.splitRuns(links[i].Runs); err != nil {
+				return err
+			}
+		}
+		para.Hyperlinks = links
+	}
+	return nil
+}
+
+// splitRuns returns a copy of runs with the emoji of each run split off.
+func (r *emojiRenderer) splitRuns(runs []Run) ([]Run, error) {
+	if runs == nil {
+		return nil, nil
+	}
+	result := make([]Run, 0, len(runs))
+	for i := range runs {
+		split, err := r.splitRun(&runs[i])
+		if err != nil {
+			return nil, err
+		}
+		if split == nil {
+			split = runs[i : i+1]
+		}
+		result = append(result, split...)
+	}
+	return result, nil
+}
+
+// splitRun returns the runs that replace a text run holding emoji: the plain
+// text, the images of emoji and the emoji set in the emoji font, each
+// keeping the formatting of run. It returns nil when run holds no emoji.
+func (r *emojiRenderer) splitRun(run *Run) ([]Run, error) {
+	if run.Text == nil || run.Break != nil || len(run.RawXML) > 0 {
+		return nil, nil
+	}
+	segments := splitEmoji(run.Text.Content)
+	if segments == nil {
+		return nil, nil
+	}
+
+	var runs []Run
+	textRun := func(text string, props *RunProperties) {
+		// Emoji set in the font follow each other in one run
+		if last := len(runs) - 1; last >= 0 && runs[last].Text != nil && runs[last].Properties == props {
+			runs[last].Text = &Text{Content: runs[last].Text.Content + text, Space: "preserve"}
+			return
+		}
+		runs = append(runs, Run{Properties: props, Attrs: run.Attrs, Text: &Text{Content: text, Space: "preserve"}})
+	}
+	fontProps := emojiFontProperties(run.Properties, r.font)
+	for _, segment := range segments {
+		if !segment.Emoji {
+			runs = append(runs, Run{Properties: run.Properties, Attrs: run.Attrs, Text: &Text{Content: segment.Text, Space: "preserve"}})
+			continue
+		}
+		image, err := r.image(segment.Text)
+		if err != nil {
+			return nil, err
+		}
+		if image == nil {
+			textRun(segment.Text, fontProps)
+			continue
+		}
+		runs = append(runs, Run{Properties: run.Properties, Attrs: run.Attrs, RawXML: []RawXMLElement{r.picture(segment.Text, image, run.Properties)}})
+	}
+	return runs, nil
+}
+
+// emojiFontProperties returns a copy of props that sets the text in font.
+func emojiFontProperties(props *RunProperties, font string) *RunProperties {
+	copied := RunProperties{}
+	if props != nil {
+		copied = *props
+	}
+	copied.Font = &Font{ASCII: font, HAnsi: font, EastAsia: font, CS: font}
+	return &copied
+}
+
+// image returns the image of emoji, adding it to the rendered package the
+// first time it is drawn, or nil when it is set in the emoji font.
+func (r *emojiRenderer) image(emoji string) (*emojiImage, error) {
+	if r.opts.Images == nil {
+		return nil, nil
+	}
+	if image, ok := r.images[emoji]; ok {
+		return image, nil
+	}
+	data, err := r.opts.Images(emoji)
+	if err != nil {
+		return nil, fmt.Errorf("emoji image %s: %w", TwemojiName(emoji), err)
+	}
+	if len(data) == 0 {
+		r.images[emoji] = nil
+		return nil, nil
+	}
+	ext := iconExtension(data)
+	if ext == "" {
+		return nil, fmt.Errorf("emoji image %s must be a PNG, JPEG, GIF, BMP or EMF image", TwemojiName(emoji))
+	}
+	for prefix, uri := range map[string]string{"v": vmlNamespace, "o": officeVMLNamespace, "r": officeDocumentRelationshipsNamespace} {
+		if existing, ok := r.ctx.collectedNamespaces[prefix]; ok && existing != uri {
+			return nil, fmt.Errorf("emoji images: namespace prefix %q is already used for %q", prefix, existing)
+		}
+	}
+	ids, err := r.ctx.relationshipIDAllocator()
+	if err != nil {
+		return nil, err
+	}
+
+	name := r.ctx.unusedPartName("media", "emoji", 1, ext)
+	image := &emojiImage{id: ids.allocate()}
+	width, height := iconSize(data)
+	image.aspect = width / height
+	r.ctx.fragmentMedia[name] = data
+	r.ctx.fragmentRelationships = append(r.ctx.fragmentRelationships,
+		Relationship{ID: image.id, Type: imageRelationType, Target: "media/" + name})
+	r.ctx.collectedNamespaces["v"] = vmlNamespace
+	r.ctx.collectedNamespaces["o"] = officeVMLNamespace
+	r.ctx.collectedNamespaces["r"] = officeDocumentRelationshipsNamespace
+	r.images[emoji] = image
+	return image, nil
+}
+
+// picture returns the inline picture of emoji, as high as the font size of
+// a run with the properties props.
+func (r *emojiRenderer) picture(emoji string, image *emojiImage, props *RunProperties) RawXMLElement {
+	size := defaultEmojiSize
+	if props != nil && props.Size != nil && props.Size.Val > 0 {
+		size = props.Size.Val
+	}
+	height := float64(size) / 2
+	r.shapes++
+
+	var pict bytes.Buffer
+	pict.WriteString(`<w:pict>`)
+	pict.WriteString(shapeType75)
+	fmt.Fprintf(&pict, `<v:shape id="_x0000_i%d" type="#_x0000_t75" alt="%s" style="width:%spt;height:%spt">`,
+		emojiShapeBase+r.shapes, escapeXMLText(emoji),
+		strconv.FormatFloat(height*image.aspect, 'f', 2, 64), strconv.FormatFloat(height, 'f', 2, 64))
+	fmt.Fprintf(&pict, `<v:imagedata r:id="%s" o:title=""/></v:shape></w:pict>`, image.id)
+	return RawXMLElement{XMLName: xml.Name{Local: "pict"}, Content: pict.Bytes()}
+}
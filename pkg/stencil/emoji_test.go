@@ -0,0 +1,135 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitEmoji(t *testing.T) {
+	tests := []struct {
+		text string
+		want []emojiSegment
+	}{
+		{"plain text", nil},
+		{"© 2024 ✔ done", nil},
+		{"Done ✅", []emojiSegment{{Text: "Done "}, {Text: "✅", Emoji: true}}},
+		{"Hi 👋🏽!", []emojiSegment{{Text: "Hi "}, {Text: "👋🏽", Emoji: true}, {Text: "!"}}},
+		{"👨‍👩‍👧", []emojiSegment{{Text: "👨‍👩‍👧", Emoji: true}}},
+		{"🇩🇪🇫🇷", []emojiSegment{{Text: "🇩🇪", Emoji: true}, {Text: "🇫🇷", Emoji: true}}},
+		{"Press #️⃣ or 1", []emojiSegment{{Text: "Press "}, {Text: "#️⃣", Emoji: true}, {Text: " or 1"}}},
+		{"❤️‍🔥 and ©️", []emojiSegment{{Text: "❤️‍🔥", Emoji: true}, {Text: " and "}, {Text: "©️", Emoji: true}}},
+	}
+	for _, tt := range tests {
+		if got := splitEmoji(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitEmoji(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTwemojiName(t *testing.T) {
+	tests := map[string]string{
+		"😀":    "1f600",
+		"👍🏽":   "1f44d-1f3fd",
+		"❤️":   "2764",
+		"#️⃣":  "23-20e3",
+		"🏳️‍🌈": "1f3f3-fe0f-200d-1f308",
+		"🇩🇪":   "1f1e9-1f1ea",
+	}
+	for emoji, want := range tests {
+		if got := TwemojiName(emoji); got != want {
+			t.Errorf("TwemojiName(%q) = %q, want %q", emoji, got, want)
+		}
+	}
+}
+
+func TestEmojiFontFallback(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{"Status: {{status}}"})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"status": "done ✅🎉 ok"}, RenderOptions{Emoji: &EmojiOptions{}})
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	assertInOrder(t, documentXML,
+		`Status: done </w:t>`,
+		`<w:rFonts w:ascii="Segoe UI Emoji" w:hAnsi="Segoe UI Emoji" w:cs="Segoe UI Emoji" w:eastAsia="Segoe UI Emoji"`,
+		`✅🎉</w:t>`,
+		` ok</w:t>`,
+	)
+
+	output = renderWithOptionsToBytes(t, docx, TemplateData{"status": "done ✅"}, RenderOptions{})
+	if documentXML := extractDocumentXMLFromDOCX(t, output); strings.Contains(documentXML, "rFonts") {
+		t.Errorf("expected emoji to be left alone without the option: %s", documentXML)
+	}
+}
+
+func TestEmojiImages(t *testing.T) {
+	var icon bytes.Buffer
+	if err := png.Encode(&icon, image.NewNRGBA(image.Rect(0, 0, 72, 72))); err != nil {
+		t.Fatal(err)
+	}
+	var requested []string
+	opts := &EmojiOptions{
+		Font: "Noto Color Emoji",
+		Images: func(emoji string) ([]byte, error) {
+			requested = append(requested, TwemojiName(emoji))
+			if emoji == "🎉" {
+				return nil, nil
+			}
+			return icon.Bytes(), nil
+		},
+	}
+
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:rPr><w:sz w:val="28"/></w:rPr><w:t xml:space="preserve">{{a}}</w:t></w:r></w:p>`+
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t xml:space="preserve">{{b}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`)
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"a": "Nice 👍 work 🎉", "b": "👍 again"}, RenderOptions{Emoji: opts})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	assertInOrder(t, documentXML,
+		`Nice </w:t>`,
+		`<v:shape id="_x0000_i4001" type="#_x0000_t75" alt="👍" style="width:14.00pt;height:14.00pt"><v:imagedata r:id="rId`,
+		` work </w:t>`,
+		`w:ascii="Noto Color Emoji"`,
+		`🎉</w:t>`,
+		`<v:shape id="_x0000_i4002" type="#_x0000_t75" alt="👍" style="width:11.00pt;height:11.00pt">`,
+		` again</w:t>`,
+	)
+	if !reflect.DeepEqual(requested, []string{"1f44d", "1f389"}) {
+		t.Errorf("requested images %v, want one request per emoji", requested)
+	}
+	if media := extractPartFromDOCX(t, output, "word/media/emoji1.png"); media != icon.String() {
+		t.Errorf("emoji image not written to word/media/emoji1.png")
+	}
+	if rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels"); !strings.Contains(rels, `Target="media/emoji1.png"`) {
+		t.Errorf("missing image relationship: %s", rels)
+	}
+	if types := extractPartFromDOCX(t, output, "[Content_Types].xml"); !strings.Contains(types, `Extension="png"`) {
+		t.Errorf("missing png content type: %s", types)
+	}
+}
+
+func TestEmojiImageErrors(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{"{{status}}"})
+	tests := []struct {
+		name   string
+		images func(string) ([]byte, error)
+		want   string
+	}{
+		{"callback error", func(string) ([]byte, error) { return nil, errors.New("not found") }, "emoji image 1f680: not found"},
+		{"not an image", func(string) ([]byte, error) { return []byte("rocket"), nil }, "must be a PNG, JPEG, GIF, BMP or EMF image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Prepare(bytes.NewReader(docx))
+			if err != nil {
+				t.Fatalf("Prepare failed: %v", err)
+			}
+			defer tmpl.Close()
+			_, err = tmpl.RenderWithOptions(TemplateData{"status": "🚀"}, RenderOptions{Emoji: &EmojiOptions{Images: tt.images}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RenderWithOptions error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
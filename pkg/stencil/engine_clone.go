@@ -0,0 +1,221 @@
+package stencil
+
+import (
+	"container/list"
+	"crypto/ed25519"
+	"sync"
+)
+
+// Clone returns a child engine for a tenant that shares the engine's
+// template cache, so each template file is parsed once however many tenants
+// use it, while everything a tenant customizes is its own: functions,
+// global data, constants, value providers, processors, the function
+// policy and the configuration start as copies of the engine's and change
+// independently afterwards.
+//
+// Templates from the clone's PrepareFile have their own fragments, fragment
+// resolver and macros. Fragments added to one are kept for later
+// PrepareFile calls of the same clone, as with a cached template, but are
+// not seen by the engine or other clones. The clone has its own render
+// cache of the engine's size and no remote template source. Closing a
+// clone does not affect the engine.
+//
+// Example:
+//
+//	base := stencil.NewWithOptions(stencil.WithCache(500))
+//	tenant := base.Clone()
+//	tenant.SetGlobalData(stencil.TemplateData{"company": tenantName})
+//	tenant.RegisterFunction("brandColor", brandColorFn)
+//	tmpl, err := tenant.PrepareFile("templates/invoice.docx")
+func (e *Engine) Clone() *Engine {
+	root := e
+	if e.root != nil {
+		root = e.root
+	}
+	clone := &Engine{
+		cache:       e.cache,
+		registry:    copyFunctionRegistry(e.registry),
+		data:        e.data.clone(),
+		trustedKeys: append([]ed25519.PublicKey(nil), e.trustedKeys...),
+		root:        root,
+	}
+	if e.config != nil {
+		config := *e.config
+		clone.config = &config
+	} else {
+		clone.config = DefaultConfig()
+	}
+
+	e.variants.mu.RLock()
+	if len(e.variants.paths) > 0 {
+		clone.variants.paths = make(map[string]map[string]string, len(e.variants.paths))
+		for baseName, locales := range e.variants.paths {
+			clone.variants.paths[baseName] = make(map[string]string, len(locales))
+			for locale, path := range locales {
+				clone.variants.paths[baseName][locale] = path
+			}
+		}
+	}
+	e.variants.mu.RUnlock()
+	return clone
+}
+
+// copyFunctionRegistry returns a registry with the functions of registry.
+func copyFunctionRegistry(registry FunctionRegistry) *DefaultFunctionRegistry {
+	copied := NewFunctionRegistry()
+	if registry == nil {
+		return copied
+	}
+	for _, name := range registry.ListFunctions() {
+		if fn, ok := registry.GetFunction(name); ok {
+			copied.functions[name] = fn
+		}
+	}
+	return copied
+}
+
+// clone returns a copy of d with an empty render cache of the same size.
+// Maps are shared, as d replaces rather than modifies them.
+func (d *engineData) clone() *engineData {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	cloned := &engineData{
+		global:        d.global,
+		providers:     append([]ValueProvider(nil), d.providers...),
+		policy:        d.policy,
+		constants:     d.constants,
+		plurals:       d.plurals,
+		preprocessors: append([]PreProcessor(nil), d.preprocessors...),
+		processors:    append([]PostProcessor(nil), d.processors...),
+		tracer:        d.tracer,
+		sanitization:  d.sanitization,
+		features:      d.features,
+	}
+	if d.renderCache != nil {
+		cloned.renderCache = &renderCache{
+			maxBytes: d.renderCache.maxBytes,
+			ttl:      d.renderCache.ttl,
+			entries:  make(map[string]*list.Element),
+			lru:      list.New(),
+		}
+	}
+	return cloned
+}
+
+// tenantViews holds the templates a clone returned from PrepareFile, so
+// fragments added to them are kept while the shared template is cached.
+type tenantViews struct {
+	mu      sync.Mutex
+	entries map[string]tenantView
+}
+
+type tenantView struct {
+	shared *template
+	view   *PreparedTemplate
+}
+
+// prepareTenantFile implements PrepareFile for a clone: the root engine
+// prepares and caches the template, and the clone returns a view of it
+// bound to its own functions, data and fragments.
+func (e *Engine) prepareTenantFile(path string) (*PreparedTemplate, error) {
+	shared, err := e.root.PrepareFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer shared.Close()
+	cached := e.root.config.CacheMaxSize > 0 && e.root.cache != nil
+
+	e.views.mu.Lock()
+	defer e.views.mu.Unlock()
+	if entry, ok := e.views.entries[path]; ok {
+		if entry.shared == shared.template {
+			if tmpl, ok := entry.view.cloneHandle(); ok {
+				return tmpl, nil
+			}
+		}
+		entry.view.Close()
+		delete(e.views.entries, path)
+	}
+
+	view, err := e.tenantView(shared)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		return view, nil
+	}
+	tmpl, ok := view.cloneHandle()
+	if !ok {
+		return view, nil
+	}
+	if e.views.entries == nil {
+		e.views.entries = make(map[string]tenantView)
+	}
+	e.views.entries[path] = tenantView{shared: shared.template, view: view}
+	return tmpl, nil
+}
+
+// tenantView returns a template sharing the parsed document of shared,
+// without its fragments, bound to the clone's functions and data.
+func (e *Engine) tenantView(shared *PreparedTemplate) (*PreparedTemplate, error) {
+	shared.mu.RLock()
+	if shared.closed || shared.template == nil {
+		shared.mu.RUnlock()
+		return nil, errTemplateClosed
+	}
+	tmpl := shared.template
+	warnings := shared.warnings
+	shared.mu.RUnlock()
+
+	tmpl.mu.RLock()
+	if tmpl.closed {
+		tmpl.mu.RUnlock()
+		return nil, errTemplateClosed
+	}
+	view := &template{
+		name:                tmpl.name,
+		docxReader:          tmpl.docxReader,
+		document:            tmpl.document,
+		source:              tmpl.source,
+		fragments:           make(map[string]*fragment),
+		resolverMisses:      make(map[string]bool),
+		copyCompressedParts: tmpl.copyCompressedParts,
+		arithmeticPolicy:    e.config.ArithmeticPolicy,
+		concatenationPolicy: e.config.ConcatenationPolicy,
+		maxIncludeDepth:     e.config.includeDepthLimit(),
+		documentReplaced:    tmpl.documentReplaced,
+		macros:              tmpl.macros,
+		mapping:             tmpl.mapping.retain(),
+	}
+	tmpl.mu.RUnlock()
+
+	if _, err := view.ensureRenderResources(); err != nil {
+		view.Close()
+		return nil, err
+	}
+	pt := &PreparedTemplate{
+		state:    newPreparedTemplateState(view),
+		template: view,
+		registry: e.registry,
+		data:     e.data,
+		warnings: warnings,
+	}
+	if e.config.ValidateOnPrepare {
+		if err := pt.validateSyntax(); err != nil {
+			pt.Close()
+			return nil, err
+		}
+	}
+	return pt, nil
+}
+
+// closeViews releases the templates held for a clone's PrepareFile.
+func (e *Engine) closeViews() {
+	e.views.mu.Lock()
+	defer e.views.mu.Unlock()
+	for path, entry := range e.views.entries {
+		entry.view.Close()
+		delete(e.views.entries, path)
+	}
+}
@@ -0,0 +1,99 @@
+package stencil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEngineClone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "letter.docx")
+	docx := createDOCXWithParagraphs(t, []string{`{{brand(company)}} {{greeting}}`, `{{include "terms"}}`})
+	if err := os.WriteFile(path, docx, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	brand := func(suffix string) Function {
+		return NewSimpleFunction("brand", 1, 1, func(args ...interface{}) (interface{}, error) {
+			return args[0].(string) + suffix, nil
+		})
+	}
+
+	base := NewWithConfig(DefaultConfig())
+	defer base.Close()
+	base.RegisterFunction("brand", brand(" Inc."))
+	base.SetGlobalData(TemplateData{"greeting": "Hello"})
+
+	tenantA := base.Clone()
+	defer tenantA.Close()
+	tenantA.RegisterFunction("brand", brand(" GmbH"))
+	tenantA.SetGlobalData(TemplateData{"greeting": "Hallo"})
+	tenantB := base.Clone()
+	defer tenantB.Close()
+
+	render := func(engine *Engine, terms string) string {
+		t.Helper()
+		tmpl, err := engine.PrepareFile(path)
+		if err != nil {
+			t.Fatalf("PrepareFile failed: %v", err)
+		}
+		defer tmpl.Close()
+		if terms != "" {
+			if err := tmpl.AddFragment("terms", terms); err != nil {
+				t.Fatal(err)
+			}
+		}
+		output, err := tmpl.Render(TemplateData{"company": "Acme"})
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, err := io.ReadAll(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(extractTextFromDOCX(t, content))
+	}
+
+	if got := render(base, "Base terms"); got != "Acme Inc. HelloBase terms" {
+		t.Errorf("base render = %q", got)
+	}
+	if got := render(tenantA, "A terms"); got != "Acme GmbH HalloA terms" {
+		t.Errorf("tenant A render = %q", got)
+	}
+	if got := render(tenantB, "B terms"); got != "Acme Inc. HelloB terms" {
+		t.Errorf("tenant B render = %q", got)
+	}
+
+	// Fragments stay with the engine that added them
+	if got := render(tenantA, ""); got != "Acme GmbH HalloA terms" {
+		t.Errorf("tenant A render without adding fragments = %q", got)
+	}
+	if got := render(base, ""); got != "Acme Inc. HelloBase terms" {
+		t.Errorf("base render without adding fragments = %q", got)
+	}
+
+	// The template is parsed once and shared through the cache
+	if size := base.cache.Size(); size != 1 {
+		t.Errorf("cache size = %d, want 1", size)
+	}
+	shared, err := base.PrepareFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close()
+	view, err := tenantB.PrepareFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer view.Close()
+	if view.template.document != shared.template.document {
+		t.Error("clone parsed the template again instead of using the cached one")
+	}
+
+	// Configuration is copied
+	tenantB.Config().ValidateOnPrepare = true
+	if base.Config().ValidateOnPrepare {
+		t.Error("changing the clone's config changed the engine's")
+	}
+}
@@ -0,0 +1,190 @@
+package stencil
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrorCode is a stable, machine-readable identifier for a class of errors.
+// Unlike error messages, codes do not change between releases, so callers can
+// map them to HTTP statuses or localized messages.
+type ErrorCode string
+
+const (
+	// ErrorCodeUnknown is reported for errors without a more specific code.
+	ErrorCodeUnknown ErrorCode = "UNKNOWN"
+	// ErrorCodeTemplate is reported for errors in the template structure.
+	ErrorCodeTemplate ErrorCode = "TEMPLATE_ERROR"
+	// ErrorCodeParse is reported when an expression cannot be parsed.
+	ErrorCodeParse ErrorCode = "PARSE_ERROR"
+	// ErrorCodeBadForSyntax is reported for malformed for loop headers.
+	ErrorCodeBadForSyntax ErrorCode = "BAD_FOR_SYNTAX"
+	// ErrorCodeEvaluation is reported when an expression fails to evaluate.
+	ErrorCodeEvaluation ErrorCode = "EVALUATION_ERROR"
+	// ErrorCodeUnknownFunction is reported when a template calls a function
+	// that is not registered.
+	ErrorCodeUnknownFunction ErrorCode = "UNKNOWN_FUNCTION"
+	// ErrorCodeFunction is reported when a template function call fails.
+	ErrorCodeFunction ErrorCode = "FUNCTION_ERROR"
+	// ErrorCodePanic is reported when a template function or expression
+	// panicked during a render.
+	ErrorCodePanic ErrorCode = "PANIC"
+	// ErrorCodeFunctionNotAllowed is reported when a template calls a
+	// function its FunctionPolicy does not allow.
+	ErrorCodeFunctionNotAllowed ErrorCode = "FUNCTION_NOT_ALLOWED"
+	// ErrorCodeFunctionTimeout is reported when a function call exceeds the
+	// timeout of its FunctionPolicy.
+	ErrorCodeFunctionTimeout ErrorCode = "FUNCTION_TIMEOUT"
+	// ErrorCodeMissingFragment is reported when an included fragment is not
+	// defined.
+	ErrorCodeMissingFragment ErrorCode = "MISSING_FRAGMENT"
+	// ErrorCodeDocument is reported when reading or writing the DOCX fails.
+	ErrorCodeDocument ErrorCode = "DOCUMENT_ERROR"
+	// ErrorCodeInvalidPackage is reported when the rendered DOCX package
+	// fails validation.
+	ErrorCodeInvalidPackage ErrorCode = "INVALID_PACKAGE"
+	// ErrorCodeTemplateClosed is reported when a closed template is used.
+	ErrorCodeTemplateClosed ErrorCode = "TEMPLATE_CLOSED"
+	// ErrorCodeContextCancelled is reported when an operation stops because
+	// its context was cancelled or its deadline expired.
+	ErrorCodeContextCancelled ErrorCode = "CONTEXT_CANCELLED"
+	// ErrorCodeUntrustedTemplate is reported when an engine with trusted
+	// template keys prepares a template without a valid signature.
+	ErrorCodeUntrustedTemplate ErrorCode = "UNTRUSTED_TEMPLATE"
+	// ErrorCodeMemoryLimit is reported when a render exceeds
+	// RenderOptions.MaxMemoryBytes.
+	ErrorCodeMemoryLimit ErrorCode = "MEMORY_LIMIT"
+	// ErrorCodeAssertion is reported when assertions of a template fail in
+	// a render with RenderOptions.CheckAssertions.
+	ErrorCodeAssertion ErrorCode = "ASSERTION_FAILED"
+)
+
+// codedError is implemented by errors that carry an ErrorCode.
+type codedError interface {
+	error
+	Code() string
+}
+
+// errorWithCode attaches a code to an error without changing its message.
+type errorWithCode struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *errorWithCode) Error() string {
+	return e.err.Error()
+}
+
+func (e *errorWithCode) Unwrap() error {
+	return e.err
+}
+
+// Code returns the error code
+func (e *errorWithCode) Code() string {
+	return string(e.code)
+}
+
+// withErrorCode attaches code to err. It returns nil for a nil error.
+func withErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &errorWithCode{code: code, err: err}
+}
+
+// errTemplateClosed is returned when a closed template is used.
+var errTemplateClosed = withErrorCode(ErrorCodeTemplateClosed, errors.New("template is closed"))
+
+// errFragmentTemplateClosed is returned when a closed template is added as a
+// fragment.
+var errFragmentTemplateClosed = withErrorCode(ErrorCodeTemplateClosed, errors.New("fragment template is closed"))
+
+// ErrorCodeOf returns the code of the outermost error in err's chain that has
+// one. Context cancellation is reported as ErrorCodeContextCancelled, errors
+// without a code as ErrorCodeUnknown and a nil error as the empty code.
+//
+// Example:
+//
+//	_, err := tmpl.Render(data)
+//	switch stencil.ErrorCodeOf(err) {
+//	case stencil.ErrorCodeMissingFragment, stencil.ErrorCodeUnknownFunction:
+//	    // the template is broken: 422
+//	case stencil.ErrorCodeContextCancelled:
+//	    // the client went away: 499
+//	}
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCodeContextCancelled
+	}
+	var coded codedError
+	if errors.As(err, &coded) {
+		return ErrorCode(coded.Code())
+	}
+	return ErrorCodeUnknown
+}
+
+// causeCode returns the code of cause if it has one and fallback otherwise,
+// so that wrapping errors keep the more specific code of what they wrap.
+func causeCode(cause error, fallback ErrorCode) string {
+	if cause != nil {
+		if code := ErrorCodeOf(cause); code != ErrorCodeUnknown {
+			return string(code)
+		}
+	}
+	return string(fallback)
+}
+
+// Code returns the error code
+func (e *TemplateError) Code() string {
+	return string(ErrorCodeTemplate)
+}
+
+// Code returns the error code
+func (e *ParseError) Code() string {
+	return string(ErrorCodeParse)
+}
+
+// Code returns the code of the cause if it has one, and
+// ErrorCodeEvaluation otherwise.
+func (e *EvaluationError) Code() string {
+	return causeCode(e.Cause, ErrorCodeEvaluation)
+}
+
+// Code returns ErrorCodePanic for a recovered panic and ErrorCodeFunction
+// otherwise.
+func (e *FunctionError) Code() string {
+	if e.Panic != nil {
+		return string(ErrorCodePanic)
+	}
+	return string(ErrorCodeFunction)
+}
+
+// Code returns the code of the cause if it has one, and ErrorCodeDocument
+// otherwise.
+func (e *DocumentError) Code() string {
+	return causeCode(e.Cause, ErrorCodeDocument)
+}
+
+// Code returns the error code
+func (e *PackageValidationError) Code() string {
+	return string(ErrorCodeInvalidPackage)
+}
+
+// Code returns the code of the cause if it has one, and ErrorCodeUnknown
+// otherwise.
+func (e *FragmentError) Code() string {
+	return causeCode(e.Cause, ErrorCodeUnknown)
+}
+
+// Code returns the error code
+func (e *MemoryLimitError) Code() string {
+	return string(ErrorCodeMemoryLimit)
+}
+
+// Code returns the error code
+func (e *AssertionError) Code() string {
+	return string(ErrorCodeAssertion)
+}
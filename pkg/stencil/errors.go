@@ -3,6 +3,7 @@ package stencil
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -36,13 +37,19 @@ type ParseError struct {
 	Message  string
 	Token    string
 	Position int
+	// Expression is the expression text being parsed, if known.
+	Expression string
 }
 
 func (e *ParseError) Error() string {
+	prefix := "parse error"
+	if e.Expression != "" {
+		prefix = fmt.Sprintf("parse error in expression '%s'", e.Expression)
+	}
 	if e.Token != "" {
-		return fmt.Sprintf("parse error at position %d near '%s': %s", e.Position, e.Token, e.Message)
+		return fmt.Sprintf("%s at position %d near '%s': %s", prefix, e.Position, e.Token, e.Message)
 	}
-	return fmt.Sprintf("parse error at position %d: %s", e.Position, e.Message)
+	return fmt.Sprintf("%s at position %d: %s", prefix, e.Position, e.Message)
 }
 
 // NewParseError creates a new parse error
@@ -58,13 +65,37 @@ func NewParseError(message, token string, position int) error {
 type EvaluationError struct {
 	Expression string
 	Cause      error
+	// Values lists the resolved sub-values of the expression that led to
+	// the error, such as the operands of a failed comparison.
+	Values []EvaluatedValue
+}
+
+// EvaluatedValue is a sub-expression and the value it resolved to.
+type EvaluatedValue struct {
+	Expression string
+	Value      interface{}
+	Type       string // Go type of Value, e.g. "string" or "<nil>"
+}
+
+func (v EvaluatedValue) String() string {
+	return fmt.Sprintf("%s = %s (%s)", v.Expression, formatEvaluatedValue(v.Value), v.Type)
 }
 
 func (e *EvaluationError) Error() string {
+	var msg string
 	if e.Cause != nil {
-		return fmt.Sprintf("evaluation error for expression '%s': %v", e.Expression, e.Cause)
+		msg = fmt.Sprintf("evaluation error for expression '%s': %v", e.Expression, e.Cause)
+	} else {
+		msg = fmt.Sprintf("evaluation error for expression '%s'", e.Expression)
+	}
+	if len(e.Values) > 0 {
+		values := make([]string, len(e.Values))
+		for i, value := range e.Values {
+			values[i] = value.String()
+		}
+		msg += fmt.Sprintf(" [%s]", strings.Join(values, ", "))
 	}
-	return fmt.Sprintf("evaluation error for expression '%s'", e.Expression)
+	return msg
 }
 
 func (e *EvaluationError) Unwrap() error {
@@ -84,6 +115,9 @@ type FunctionError struct {
 	Function string
 	Args     []interface{}
 	Message  string
+	// Panic is the value the function panicked with, or nil if it returned
+	// an error.
+	Panic interface{}
 }
 
 func (e *FunctionError) Error() string {
@@ -134,6 +168,50 @@ func NewDocumentError(operation, path string, cause error) error {
 	}
 }
 
+// FragmentError reports an error raised while rendering an included
+// fragment. Its message names the template, the whole include chain and
+// the loop variables in scope, so that a failure can be reproduced from
+// the message alone.
+type FragmentError struct {
+	// Template is the path the template was prepared from with PrepareFile.
+	// It is empty for templates prepared from a reader.
+	Template string
+	// IncludeChain lists the included fragments from the outermost one to
+	// the one that failed.
+	IncludeChain []string
+	// LoopVariables holds the variables of the loops enclosing the failing
+	// fragment, also those of loops in the fragments that include it.
+	LoopVariables map[string]interface{}
+	Cause         error
+}
+
+func (e *FragmentError) Error() string {
+	name := ""
+	if len(e.IncludeChain) > 0 {
+		name = e.IncludeChain[len(e.IncludeChain)-1]
+	}
+	details := []string{"include chain: " + strings.Join(e.IncludeChain, " -> ")}
+	if e.Template != "" {
+		details = append(details, "template: "+e.Template)
+	}
+	if len(e.LoopVariables) > 0 {
+		names := make([]string, 0, len(e.LoopVariables))
+		for variable := range e.LoopVariables {
+			names = append(names, variable)
+		}
+		sort.Strings(names)
+		values := make([]string, len(names))
+		for i, variable := range names {
+			values[i] = variable + " = " + formatEvaluatedValue(e.LoopVariables[variable])
+		}
+		details = append(details, "loop variables: "+strings.Join(values, ", "))
+	}
+	return fmt.Sprintf("failed to render fragment %s (%s): %v", name, strings.Join(details, "; "), e.Cause)
+}
+
+func (e *FragmentError) Unwrap() error {
+	return e.Cause
+}
 
 // MultiError collects multiple errors
 type MultiError struct {
@@ -159,6 +237,12 @@ func (m *MultiError) Len() int {
 	return len(m.errors)
 }
 
+// Unwrap returns the collected errors, so that errors.Is and errors.As
+// look at each of them
+func (m *MultiError) Unwrap() []error {
+	return m.errors
+}
+
 // Err returns the multi-error or nil if empty
 func (m *MultiError) Err() error {
 	if len(m.errors) == 0 {
@@ -1,7 +1,10 @@
 package stencil
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -171,3 +174,222 @@ func TestMultiError(t *testing.T) {
 	}
 }
 
+
+func TestEvaluationErrorValueContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		data       TemplateData
+		wantExpr   string
+		wantValues []EvaluatedValue
+		wantMsg    string
+	}{
+		{
+			name:       "comparison operands",
+			expression: `order.total > 10`,
+			data:       TemplateData{"order": map[string]interface{}{"total": "12"}},
+			wantExpr:   "order.total > 10",
+			wantValues: []EvaluatedValue{{Expression: "order.total", Value: "12", Type: "string"}},
+			wantMsg:    `evaluation error for expression 'order.total > 10': cannot compare string and int [order.total = "12" (string)]`,
+		},
+		{
+			name:       "function arguments",
+			expression: `random(limit)`,
+			data:       TemplateData{"limit": 0},
+			wantExpr:   "random(limit)",
+			wantValues: []EvaluatedValue{{Expression: "limit", Value: 0, Type: "int"}},
+			wantMsg:    `evaluation error for expression 'random(limit)': random() limit must be positive, got 0 [limit = 0 (int)]`,
+		},
+		{
+			name:       "nested operation",
+			expression: `-(name + 1)`,
+			data:       TemplateData{"name": "x"},
+			wantExpr:   "-(name + 1)",
+			wantValues: []EvaluatedValue{{Expression: "name + 1", Value: "x1", Type: "string"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			_, err = node.Evaluate(tt.data)
+
+			var evalErr *EvaluationError
+			if !errors.As(err, &evalErr) {
+				t.Fatalf("expected EvaluationError, got %v", err)
+			}
+			if evalErr.Expression != tt.wantExpr {
+				t.Errorf("Expression = %q, want %q", evalErr.Expression, tt.wantExpr)
+			}
+			if len(evalErr.Values) != len(tt.wantValues) {
+				t.Fatalf("Values = %+v, want %+v", evalErr.Values, tt.wantValues)
+			}
+			for i, want := range tt.wantValues {
+				if got := evalErr.Values[i]; got != want {
+					t.Errorf("Values[%d] = %+v, want %+v", i, got, want)
+				}
+			}
+			if tt.wantMsg != "" && err.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestLoopCollectionErrorContext(t *testing.T) {
+	nodes, err := ParseControlStructures(`{{for item in order.count}}{{item}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseControlStructures() error = %v", err)
+	}
+	_, err = renderControlBody(nodes, TemplateData{"order": map[string]interface{}{"count": 3}})
+
+	var evalErr *EvaluationError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected EvaluationError, got %v", err)
+	}
+	if evalErr.Expression != "order.count" || len(evalErr.Values) != 1 || evalErr.Values[0].Type != "int" {
+		t.Fatalf("unexpected error context %+v", evalErr)
+	}
+	if !strings.Contains(err.Error(), "type int is not iterable [order.count = 3 (int)]") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestExpressionParseErrorContext(t *testing.T) {
+	tests := []struct {
+		expression   string
+		strict       bool
+		wantToken    string
+		wantPosition int
+	}{
+		{expression: "price @ 2", wantToken: "@", wantPosition: 6},
+		{expression: "name other", strict: true, wantToken: "other", wantPosition: 5},
+		{expression: "format(price", wantToken: "", wantPosition: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			var err error
+			if tt.strict {
+				_, err = ParseExpressionStrict(tt.expression)
+			} else {
+				_, err = ParseExpression(tt.expression)
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if parseErr.Expression != tt.expression || parseErr.Token != tt.wantToken || parseErr.Position != tt.wantPosition {
+				t.Fatalf("unexpected parse error %+v", parseErr)
+			}
+			if !strings.Contains(err.Error(), "parse error in expression '"+tt.expression+"'") {
+				t.Fatalf("expected expression in message, got %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "plain error", err: errors.New("boom"), want: ErrorCodeUnknown},
+		{name: "template error", err: NewTemplateError("bad", 1, 1), want: ErrorCodeTemplate},
+		{name: "parse error", err: NewParseError("bad", "x", 0), want: ErrorCodeParse},
+		{name: "evaluation error", err: NewEvaluationError("x", errors.New("boom")), want: ErrorCodeEvaluation},
+		{name: "function error", err: NewFunctionError("f", nil, "bad"), want: ErrorCodeFunction},
+		{name: "document error", err: NewDocumentError("read", "x.docx", errors.New("boom")), want: ErrorCodeDocument},
+		{name: "package validation error", err: &PackageValidationError{}, want: ErrorCodeInvalidPackage},
+		{name: "closed template", err: errTemplateClosed, want: ErrorCodeTemplateClosed},
+		{name: "cancelled", err: fmt.Errorf("render: %w", context.Canceled), want: ErrorCodeContextCancelled},
+		{name: "deadline", err: context.DeadlineExceeded, want: ErrorCodeContextCancelled},
+		{
+			name: "evaluation error keeps cause code",
+			err:  NewEvaluationError("f()", withErrorCode(ErrorCodeUnknownFunction, errors.New("unknown function: f"))),
+			want: ErrorCodeUnknownFunction,
+		},
+		{
+			name: "wrapped code",
+			err:  WithContext(withErrorCode(ErrorCodeMissingFragment, errors.New("fragment not found: x")), "render", nil),
+			want: ErrorCodeMissingFragment,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCodeOf(tt.err); got != tt.want {
+				t.Fatalf("ErrorCodeOf(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithErrorCodeKeepsMessage(t *testing.T) {
+	cause := errors.New("fragment not found: footer")
+	err := withErrorCode(ErrorCodeMissingFragment, cause)
+
+	if err.Error() != cause.Error() {
+		t.Fatalf("message changed to %q", err.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected wrapped cause to be reachable")
+	}
+	var coded interface{ Code() string }
+	if !errors.As(err, &coded) || coded.Code() != "MISSING_FRAGMENT" {
+		t.Fatalf("expected MISSING_FRAGMENT code, got %v", coded)
+	}
+	if withErrorCode(ErrorCodeMissingFragment, nil) != nil {
+		t.Fatalf("expected nil for a nil error")
+	}
+}
+
+func TestRenderErrorCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		paragraph string
+		want      ErrorCode
+	}{
+		{name: "missing fragment", paragraph: `{{include "missing"}}`, want: ErrorCodeMissingFragment},
+		{name: "unknown function", paragraph: `{{nosuchfunction(1)}}`, want: ErrorCodeUnknownFunction},
+		{name: "bad for syntax", paragraph: `{{for item items}}x{{end}}`, want: ErrorCodeBadForSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docx := createDOCXWithParagraphs(t, []string{tt.paragraph})
+			tmpl, err := Prepare(bytes.NewReader(docx))
+			if err == nil {
+				defer tmpl.Close()
+				_, err = tmpl.Render(TemplateData{"items": []interface{}{1}})
+			}
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got := ErrorCodeOf(err); got != tt.want {
+				t.Fatalf("ErrorCodeOf(%v) = %q, want %q", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClosedTemplateErrorCode(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{"Hello"})))
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	tmpl.Close()
+
+	if _, err := tmpl.Render(TemplateData{}); ErrorCodeOf(err) != ErrorCodeTemplateClosed {
+		t.Fatalf("Render() after Close() = %v, want TEMPLATE_CLOSED", err)
+	}
+	if _, err := tmpl.RenderWithOptions(TemplateData{}, RenderOptions{}); ErrorCodeOf(err) != ErrorCodeTemplateClosed {
+		t.Fatalf("RenderWithOptions() after Close() = %v, want TEMPLATE_CLOSED", err)
+	}
+}
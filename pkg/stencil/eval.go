@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Regular expressions for parsing field access
@@ -18,6 +20,10 @@ var (
 
 const parentDataKey = "\x00go_stencil_parent"
 
+// internalDataKeyPrefix starts the keys the renderer adds to template data
+// scopes, such as parentDataKey and renderHelpersKey; they are not data.
+const internalDataKeyPrefix = "\x00go_stencil_"
+
 // EvaluateVariable evaluates a variable expression with support for nested field access
 func EvaluateVariable(expression string, data TemplateData) (interface{}, error) {
 	// Trim whitespace
@@ -42,9 +48,13 @@ func EvaluateVariable(expression string, data TemplateData) (interface{}, error)
 	// Start with the data and navigate through the parts
 	current := interface{}(data)
 
-	for _, part := range parts {
+	for i, part := range parts {
 		switch part.Type {
 		case fieldTypeIdentifier:
+			if i == 0 {
+				current = accessRootTemplateDataField(data, part.Value)
+				break
+			}
 			// Handle map field access
 			current = accessMapField(current, part.Value)
 		case fieldTypeBracket:
@@ -59,7 +69,13 @@ func EvaluateVariable(expression string, data TemplateData) (interface{}, error)
 	}
 
 	if logger.IsDebugMode() {
-		logger.WithField("result", current).Debug("Variable evaluation complete")
+		fieldPath := make([]string, 0, len(parts))
+		for _, part := range parts {
+			if part.Type == fieldTypeIdentifier {
+				fieldPath = append(fieldPath, part.Value)
+			}
+		}
+		logger.WithField("result", scrubFieldForLog(fieldPath, current)).Debug("Variable evaluation complete")
 	}
 
 	return current, nil
@@ -159,6 +175,11 @@ func accessMapField(current interface{}, field string) interface{} {
 		return v[field]
 	case map[string]bool:
 		return v[field]
+	case []interface{}, []map[string]interface{}, []TemplateData:
+		// A field access on a list maps over its items, so items.price
+		// behaves like map("price", items)
+		values, _ := mapExtract(field, v)
+		return values
 	default:
 		// Not a map-like structure
 		return nil
@@ -264,7 +285,7 @@ func materializeTemplateData(data TemplateData) TemplateData {
 func materializeTemplateDataInto(dst TemplateData, data TemplateData) {
 	for _, current := range collectTemplateDataChain(data) {
 		for key, value := range current {
-			if key == parentDataKey {
+			if strings.HasPrefix(key, internalDataKeyPrefix) {
 				continue
 			}
 			dst[key] = value
@@ -292,6 +313,18 @@ func accessScopedTemplateDataField(data TemplateData, field string) interface{}
 	return nil
 }
 
+// accessRootTemplateDataField looks up a top-level variable, falling back to
+// the engine's value providers when no scope defines it.
+func accessRootTemplateDataField(data TemplateData, field string) interface{} {
+	for _, current := range collectTemplateDataScopes(data) {
+		if value, ok := current[field]; ok {
+			return value
+		}
+	}
+
+	return resolveProvidedValue(data, field)
+}
+
 func collectTemplateDataChain(data TemplateData) []TemplateData {
 	scopes := collectTemplateDataScopes(data)
 	for left, right := 0, len(scopes)-1; left < right; left, right = left+1, right-1 {
@@ -335,34 +368,78 @@ func templateDataIdentity(data TemplateData) uintptr {
 	return reflect.ValueOf(data).Pointer()
 }
 
-// FormatValue converts a value to its string representation
-func FormatValue(value interface{}) string {
-	if value == nil {
-		return ""
-	}
+// formatBufferPool holds scratch buffers for formatting floats, so that
+// only the resulting string is allocated.
+var formatBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 32)
+		return &buf
+	},
+}
 
+// FormatValue converts a value to its string representation. Strings,
+// integers, floats, booleans and times are formatted without fmt, as they
+// make up most of the values in a render.
+func FormatValue(value interface{}) string {
 	switch v := value.(type) {
+	case nil:
+		return ""
 	case string:
 		return v
-	case int, int8, int16, int32, int64:
-		return fmt.Sprintf("%d", v)
-	case uint, uint8, uint16, uint32, uint64:
-		return fmt.Sprintf("%d", v)
+	case int:
+		return strconv.Itoa(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
 	case float32:
-		// Use strconv.FormatFloat with 'g' format and precision 10 for cleaner representation
-		return strconv.FormatFloat(float64(v), 'g', 10, 32)
+		// Use 'g' format and precision 10 for cleaner representation
+		return formatFloat(float64(v), 10, 32)
 	case float64:
-		// Use strconv.FormatFloat with 'g' format and precision 15 for cleaner representation
+		// Use 'g' format and precision 15 for cleaner representation
 		// This removes unnecessary trailing zeros and handles precision issues
-		return strconv.FormatFloat(v, 'g', 15, 64)
+		return formatFloat(v, 15, 64)
 	case bool:
-		return fmt.Sprintf("%v", v)
+		if v {
+			return "true"
+		}
+		return "false"
+	case time.Time:
+		// Same as fmt's %v, which calls String
+		return v.String()
+	case Money:
+		return v.String()
 	case *TableRowMarker:
 		// TableRowMarker is a special marker that should be converted to a placeholder
 		// that can be detected during post-processing
-		return fmt.Sprintf("TABLE_ROW_MARKER:%s", v.Action)
+		return "TABLE_ROW_MARKER:" + v.Action
 	default:
 		// For complex types, use fmt.Sprintf
 		return fmt.Sprintf("%v", v)
 	}
 }
+
+// formatFloat formats f in 'g' format with the given precision using a
+// pooled buffer.
+func formatFloat(f float64, prec, bitSize int) string {
+	bufp := formatBufferPool.Get().(*[]byte)
+	buf := strconv.AppendFloat((*bufp)[:0], f, 'g', prec, bitSize)
+	s := string(buf)
+	*bufp = buf
+	formatBufferPool.Put(bufp)
+	return s
+}
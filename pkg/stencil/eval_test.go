@@ -2,6 +2,7 @@ package stencil
 
 import (
 	"testing"
+	"time"
 )
 
 func TestEvaluateVariable(t *testing.T) {
@@ -342,6 +343,19 @@ func TestEvaluateVariablePreservesUserParentFieldAcrossScopes(t *testing.T) {
 	}
 }
 
+func TestMaterializeTemplateDataSkipsInternalKeys(t *testing.T) {
+	parent := TemplateData{
+		"customer":       "Acme",
+		renderHelpersKey: &renderHelpers{functionPolicy: &FunctionPolicy{}},
+	}
+	child := (&ForNode{Variable: "item"}).iterationData(parent, 0, "Clause")
+
+	got := materializeTemplateData(child)
+	if len(got) != 2 || got["customer"] != "Acme" || got["item"] != "Clause" {
+		t.Fatalf("materializeTemplateData() = %v, want only customer and item", got)
+	}
+}
+
 func TestFormatValue(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -388,6 +402,36 @@ func TestFormatValue(t *testing.T) {
 			value: map[string]int{"a": 1},
 			want:  "map[a:1]",
 		},
+		{
+			name:  "sized integers",
+			value: []interface{}{int8(-8), uint16(16), int64(-1 << 40), uint64(1 << 63)},
+			want:  "[-8 16 -1099511627776 9223372036854775808]",
+		},
+		{
+			name:  "float32 value",
+			value: float32(2.5),
+			want:  "2.5",
+		},
+		{
+			name:  "large float value",
+			value: 1e21,
+			want:  "1e+21",
+		},
+		{
+			name:  "time value",
+			value: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+			want:  "2024-03-01 12:30:00 +0000 UTC",
+		},
+		{
+			name:  "named integer type",
+			value: time.Duration(90) * time.Second,
+			want:  "1m30s",
+		},
+		{
+			name:  "table row marker",
+			value: &TableRowMarker{Action: "hide"},
+			want:  "TABLE_ROW_MARKER:hide",
+		},
 	}
 
 	for _, tt := range tests {
@@ -399,3 +443,25 @@ func TestFormatValue(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatValueAllocations(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		max   float64
+	}{
+		{"string", "text", 0},
+		{"small int", 42, 0},
+		{"bool", true, 0},
+		{"int", 123456789, 1},
+		{"float64", 3.14159, 1},
+	}
+	for _, tt := range tests {
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = FormatValue(tt.value)
+		})
+		if allocs > tt.max {
+			t.Errorf("FormatValue(%s) allocates %v times, want at most %v", tt.name, allocs, tt.max)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Exhibit lettering letters the exhibits of a contract packet in order of
+// appearance: {{exhibit("pricing")}} renders the next letter, such as B, and
+// {{exhibitRef("pricing")}} anywhere in the document renders "Exhibit B".
+// Like clause numbers, the letters are assigned after rendering, so
+// exhibits left out by conditionals shift the later letters and every
+// reference follows.
+
+// defaultExhibitRefLabel is the word exhibitRef() puts before the letter.
+const defaultExhibitRefLabel = "Exhibit"
+
+// exhibitMarkerRegex matches the placeholders rendered by exhibit() and
+// exhibitRef(): {{EXHIBIT_MARKER:letter::<id>}} and
+// {{EXHIBIT_MARKER:ref:<label>:<id>}}.
+var exhibitMarkerRegex = regexp.MustCompile(`\{\{EXHIBIT_MARKER:(letter|ref):([^:}]*):([^}]*)\}\}`)
+
+// exhibitLetter renders the placeholder of exhibit(id).
+func exhibitLetter(args ...interface{}) (interface{}, error) {
+	id, err := referenceID("exhibit", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("{{EXHIBIT_MARKER:letter::%s}}", id), nil
+}
+
+// exhibitReference renders the placeholder of exhibitRef(id, label). The
+// label defaults to "Exhibit"; an empty label renders the bare letter.
+func exhibitReference(args ...interface{}) (interface{}, error) {
+	id, err := referenceID("exhibitRef", args[0])
+	if err != nil {
+		return nil, err
+	}
+	label := defaultExhibitRefLabel
+	if len(args) > 1 && args[1] != nil {
+		label = FormatValue(args[1])
+		if strings.ContainsAny(label, ":{}") {
+			return nil, fmt.Errorf("exhibitRef: label %q must not contain ':', '{' or '}'", label)
+		}
+	}
+	return fmt.Sprintf("{{EXHIBIT_MARKER:ref:%s:%s}}", label, id), nil
+}
+
+// exhibitSequenceLetter returns the letters of the nth exhibit, counting
+// from 1: A to Z, then AA, AB and so on.
+func exhibitSequenceLetter(n int) string {
+	var letters []byte
+	for n > 0 {
+		n--
+		letters = append([]byte{byte('A' + n%26)}, letters...)
+		n /= 26
+	}
+	return string(letters)
+}
+
+// ProcessExhibitLetters letters the exhibit() markers of a document in
+// order of appearance and replaces the exhibitRef() markers with the letter
+// of their exhibit. An exhibit id may be used once; a reference to an id
+// without an exhibit is an error.
+func ProcessExhibitLetters(doc *Document) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	paragraphs := documentParagraphs(doc)
+	letters := make(map[string]string)
+	found := false
+	for _, para := range paragraphs {
+		text := para.GetText()
+		if !strings.Contains(text, "EXHIBIT_MARKER:") {
+			continue
+		}
+		found = true
+		for _, match := range exhibitMarkerRegex.FindAllStringSubmatch(text, -1) {
+			if match[1] != "letter" {
+				continue
+			}
+			id := match[3]
+			if _, exists := letters[id]; exists {
+				return fmt.Errorf("exhibit %q is lettered more than once", id)
+			}
+			letters[id] = exhibitSequenceLetter(len(letters) + 1)
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	return replaceReferenceMarkers(paragraphs, exhibitMarkerRegex, "EXHIBIT_MARKER:", letters, "exhibitRef: unknown exhibit")
+}
+
+// registerExhibitFunctions registers the exhibit() and exhibitRef() functions
+func registerExhibitFunctions(registry *DefaultFunctionRegistry) {
+	// exhibit() function - letters an exhibit with a stable identifier
+	exhibitFn := NewSimpleFunction("exhibit", 1, 1, exhibitLetter)
+	registry.RegisterFunction(exhibitFn)
+
+	// exhibitRef() function - refers to the letter of an exhibit
+	exhibitRefFn := NewSimpleFunction("exhibitRef", 1, 2, exhibitReference)
+	registry.RegisterFunction(exhibitRefFn)
+}
@@ -0,0 +1,55 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExhibitLettering(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Pricing is in {{exhibitRef("pricing")}}, the SLA in {{exhibitRef("sla", "Schedule")}}.`,
+		`{{if withScope}}`,
+		`Exhibit {{exhibit("scope")}}: Scope`,
+		`{{end}}`,
+		`Exhibit {{exhibit "pricing"}}: Pricing`,
+		`Exhibit {{exhibit("sla")}}: Service Levels`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	tests := []struct {
+		withScope bool
+		want      []string
+	}{
+		{true, []string{"Pricing is in Exhibit B, the SLA in Schedule C.", "Exhibit A: Scope", "Exhibit B: Pricing", "Exhibit C: Service Levels"}},
+		{false, []string{"Pricing is in Exhibit A, the SLA in Schedule B.", "Exhibit A: Pricing", "Exhibit B: Service Levels"}},
+	}
+	for _, tt := range tests {
+		text := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{"withScope": tt.withScope}))
+		assertInOrder(t, text, tt.want...)
+		if strings.Contains(text, "EXHIBIT_MARKER") {
+			t.Errorf("unresolved marker in %q", text)
+		}
+	}
+
+	bad, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{exhibitRef("missing")}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer bad.Close()
+	if _, err := bad.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), `unknown exhibit "missing"`) {
+		t.Errorf("Render error = %v, want unknown exhibit", err)
+	}
+}
+
+func TestExhibitSequenceLetter(t *testing.T) {
+	for n, want := range map[int]string{1: "A", 26: "Z", 27: "AA", 28: "AB", 52: "AZ", 53: "BA", 703: "AAA"} {
+		if got := exhibitSequenceLetter(n); got != want {
+			t.Errorf("exhibitSequenceLetter(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -116,7 +116,24 @@ func (n *BinaryOpNode) Evaluate(data TemplateData) (interface{}, error) {
 		return nil, err
 	}
 
-	return EvaluateBinaryOperation(leftVal, n.Operator, rightVal)
+	if n.Operator == "+" && isMixedConcatenation(leftVal, rightVal) {
+		if err := concatenationPolicyFromData(data).check(leftVal, rightVal); err != nil {
+			return nil, newExpressionEvaluationError(n, err, []ExpressionNode{n.Left, n.Right}, []interface{}{leftVal, rightVal})
+		}
+	}
+
+	result, err := EvaluateBinaryOperation(leftVal, n.Operator, rightVal)
+	if isArithmeticOperator(n.Operator) {
+		helpers := attachedRenderHelpers(data)
+		result, err = helpers.effectiveArithmeticPolicy().apply(result, err, leftVal, rightVal)
+		if err == nil && n.Operator == "+" {
+			err = helpers.checkValueSize(result)
+		}
+	}
+	if err != nil {
+		return nil, newExpressionEvaluationError(n, err, []ExpressionNode{n.Left, n.Right}, []interface{}{leftVal, rightVal})
+	}
+	return result, nil
 }
 
 // UnaryOpNode represents a unary operation
@@ -135,16 +152,22 @@ func (n *UnaryOpNode) Evaluate(data TemplateData) (interface{}, error) {
 		return nil, err
 	}
 
+	var result interface{}
 	switch n.Operator {
 	case "!":
 		return !isTruthy(operandVal), nil
 	case "-":
-		return evaluateUnaryMinus(operandVal)
+		result, err = evaluateUnaryMinus(operandVal)
 	case "+":
-		return evaluateUnaryPlus(operandVal)
+		result, err = evaluateUnaryPlus(operandVal)
 	default:
 		return nil, fmt.Errorf("unknown unary operator: %s", n.Operator)
 	}
+	result, err = arithmeticPolicyFromData(data).apply(result, err, operandVal)
+	if err != nil {
+		return nil, newExpressionEvaluationError(n, err, []ExpressionNode{n.Operand}, []interface{}{operandVal})
+	}
+	return result, nil
 }
 
 // FunctionCallNode represents a function call
@@ -202,7 +225,7 @@ func (n *IndexAccessNode) Evaluate(data TemplateData) (interface{}, error) {
 		// Convert float to int for array access
 		return accessArrayIndex(obj, int(idx)), nil
 	default:
-		return nil, fmt.Errorf("invalid index type: %T", indexVal)
+		return nil, newExpressionEvaluationError(n, fmt.Errorf("invalid index type: %T", indexVal), []ExpressionNode{n.Object, n.Index}, []interface{}{obj, indexVal})
 	}
 }
 
@@ -220,6 +243,20 @@ func (n *FunctionCallNode) Evaluate(data TemplateData) (interface{}, error) {
 		return materializeTemplateData(data), nil
 	}
 
+	// Macros defined in the template take precedence over functions
+	if macro, ok := lookupMacro(data, n.Name); ok {
+		args, err := n.evaluateArgs(data, "macro")
+		if err != nil {
+			return nil, err
+		}
+		return macro.call(data, args)
+	}
+
+	// Assertions are only evaluated by renders that check them
+	if n.Name == assertFunctionName && !assertionsEnabled(data) {
+		return "", nil
+	}
+
 	// Get the function registry from data context if available
 	var registry FunctionRegistry
 	if reg, ok := resolveSpecialContextValue(data, "__functions__"); ok {
@@ -236,21 +273,35 @@ func (n *FunctionCallNode) Evaluate(data TemplateData) (interface{}, error) {
 	// Look up the function
 	fn, exists := registry.GetFunction(n.Name)
 	if !exists {
-		return nil, fmt.Errorf("unknown function: %s", n.Name)
+		return nil, withErrorCode(ErrorCodeUnknownFunction, fmt.Errorf("unknown function: %s", n.Name))
 	}
 
 	// Evaluate arguments
+	args, err := n.evaluateArgs(data, "function")
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the function
+	result, err := callFunction(fn, n.Name, data, args)
+	if err != nil {
+		return nil, newExpressionEvaluationError(n, err, n.Args, args)
+	}
+	return result, nil
+}
+
+// evaluateArgs evaluates the arguments of a call to a function or macro,
+// as named by kind.
+func (n *FunctionCallNode) evaluateArgs(data TemplateData, kind string) ([]interface{}, error) {
 	args := make([]interface{}, len(n.Args))
 	for i, arg := range n.Args {
 		val, err := arg.Evaluate(data)
 		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate argument %d for function %s: %w", i, n.Name, err)
+			return nil, fmt.Errorf("failed to evaluate argument %d for %s %s: %w", i, kind, n.Name, err)
 		}
 		args[i] = val
 	}
-
-	// Call the function
-	return fn.Call(args...)
+	return args, nil
 }
 
 // ExpressionToken represents a token in an expression
@@ -277,7 +328,7 @@ const (
 var (
 	// Regular expressions for tokenizing expressions
 	identifierRegex  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
-	numberRegex      = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?`)
+	numberRegex      = regexp.MustCompile(`^(0[xX][0-9A-Fa-f]+|[0-9]+(\.[0-9]+)?)`)
 	stringRegex      = regexp.MustCompile(`^"([^"\\]|\\.)*"`)
 	singleQuoteRegex = regexp.MustCompile(`^'([^'\\]|\\.)*'`)
 	// German typographic quotes: „..." (U+201E opening)
@@ -287,7 +338,7 @@ var (
 	germanQuoteRegex = regexp.MustCompile("^\xe2\x80\x9e([^\xe2\x80\x9c\xe2\x80\x9d\"\\\\]|\\\\.)*[\xe2\x80\x9c\xe2\x80\x9d\"]")
 	// French/Swiss quotes: »...« (U+00BB and U+00AB)
 	frenchQuoteRegex = regexp.MustCompile(`^»([^«\\]|\\.)*«`)
-	operatorRegex    = regexp.MustCompile(`^(==|!=|<=|>=|\+|\-|\*|\/|\%|\&|\||\!|<|>|=)`)
+	operatorRegex    = regexp.MustCompile(`^(->|==|!=|<=|>=|\+|\-|\*|\/|\%|\&|\||\!|<|>|=)`)
 )
 
 // TokenizeExpression tokenizes an expression string
@@ -480,7 +531,12 @@ func TokenizeExpression(expr string) ([]ExpressionToken, error) {
 		}
 
 		// If we get here, we have an unrecognized character
-		return nil, fmt.Errorf("unexpected character '%c' at position %d", expr[pos], pos)
+		return nil, &ParseError{
+			Message:    fmt.Sprintf("unexpected character '%c'", expr[pos]),
+			Token:      string(expr[pos]),
+			Position:   pos,
+			Expression: expr,
+		}
 	}
 
 	// Add EOF token
@@ -513,6 +569,10 @@ func parseExpressionWithModeCached(expr string, requireEOF bool) (ExpressionNode
 	}
 
 	node, err := parseExpressionWithMode(expr, requireEOF)
+	if err == nil {
+		// Evaluate literal-only subtrees once rather than on every render
+		node = foldConstants(node, nil)
+	}
 	entry := expressionCacheEntry{
 		node: node,
 		err:  err,
@@ -534,12 +594,23 @@ func parseExpressionWithMode(expr string, requireEOF bool) (ExpressionNode, erro
 
 	node, err := parser.parseExpression()
 	if err != nil {
-		return nil, err
+		token := parser.current()
+		return nil, &ParseError{
+			Message:    err.Error(),
+			Token:      token.Value,
+			Position:   token.Pos,
+			Expression: expr,
+		}
 	}
 
 	if requireEOF && parser.current().Type != ExprTokenEOF {
 		token := parser.current()
-		return nil, fmt.Errorf("unexpected trailing token %q at position %d", token.Value, token.Pos)
+		return nil, &ParseError{
+			Message:    fmt.Sprintf("unexpected trailing token %q", token.Value),
+			Token:      token.Value,
+			Position:   token.Pos,
+			Expression: expr,
+		}
 	}
 
 	return node, nil
@@ -750,10 +821,14 @@ func (p *ExpressionParser) parsePrimary() (ExpressionNode, error) {
 	switch token.Type {
 	case ExprTokenNumber:
 		p.advance()
-		// Try to parse as integer first
+		// Try to parse as integer first, such as 42 or the hexadecimal
+		// 0x26A0
 		if intVal, err := strconv.Atoi(token.Value); err == nil {
 			return &LiteralNode{Value: intVal}, nil
 		}
+		if hexVal, err := strconv.ParseInt(token.Value, 0, 0); err == nil {
+			return &LiteralNode{Value: int(hexVal)}, nil
+		}
 		// Otherwise parse as float
 		if floatVal, err := strconv.ParseFloat(token.Value, 64); err == nil {
 			return &LiteralNode{Value: floatVal}, nil
@@ -819,7 +894,7 @@ func (p *ExpressionParser) parseFunctionCall(name string) (ExpressionNode, error
 
 	// Parse arguments
 	for {
-		arg, err := p.parseExpression()
+		arg, err := p.parseArgument()
 		if err != nil {
 			return nil, err
 		}
@@ -841,8 +916,31 @@ func (p *ExpressionParser) parseFunctionCall(name string) (ExpressionNode, error
 	return &FunctionCallNode{Name: name, Args: args}, nil
 }
 
+// parseArgument parses a function argument, which is an expression or a
+// lambda such as x -> x.price
+func (p *ExpressionParser) parseArgument() (ExpressionNode, error) {
+	if p.current().Type == ExprTokenIdentifier && p.pos+1 < len(p.tokens) {
+		next := p.tokens[p.pos+1]
+		if next.Type == ExprTokenOperator && next.Value == "->" {
+			param := p.current().Value
+			p.advance() // consume parameter
+			p.advance() // consume '->'
+			body, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return &LambdaNode{Param: param, Body: body}, nil
+		}
+	}
+	return p.parseExpression()
+}
+
 // EvaluateBinaryOperation evaluates a binary operation between two values
 func EvaluateBinaryOperation(left interface{}, operator string, right interface{}) (interface{}, error) {
+	if result, ok, err := evaluateMoneyOperation(left, operator, right); ok {
+		return result, err
+	}
+
 	switch operator {
 	case "+":
 		return evaluateAddition(left, right)
@@ -939,7 +1037,7 @@ func evaluateDivision(left, right interface{}) (interface{}, error) {
 	}
 
 	if rightNum == 0 {
-		return nil, fmt.Errorf("division by zero")
+		return nil, errDivisionByZero
 	}
 
 	result := leftNum / rightNum
@@ -959,7 +1057,7 @@ func evaluateModulo(left, right interface{}) (interface{}, error) {
 	}
 
 	if rightInt == 0 {
-		return nil, fmt.Errorf("modulo by zero")
+		return nil, errModuloByZero
 	}
 
 	return leftInt % rightInt, nil
@@ -1130,6 +1228,8 @@ func isTruthy(val interface{}) bool {
 		return v != 0.0
 	case string:
 		return v != ""
+	case Money:
+		return v.Amount != 0
 	case []interface{}:
 		return len(v) > 0
 	case map[string]interface{}:
@@ -1141,6 +1241,10 @@ func isTruthy(val interface{}) bool {
 
 // Helper functions for unary operations
 func evaluateUnaryMinus(operand interface{}) (interface{}, error) {
+	if m, ok := toMoney(operand); ok {
+		return Money{Amount: -m.Amount, Currency: m.Currency}, nil
+	}
+
 	num, ok := toFloat64(operand)
 	if !ok {
 		return nil, fmt.Errorf("cannot apply unary minus to %T", operand)
@@ -1153,6 +1257,10 @@ func evaluateUnaryMinus(operand interface{}) (interface{}, error) {
 }
 
 func evaluateUnaryPlus(operand interface{}) (interface{}, error) {
+	if m, ok := toMoney(operand); ok {
+		return m, nil
+	}
+
 	num, ok := toFloat64(operand)
 	if !ok {
 		return nil, fmt.Errorf("cannot apply unary plus to %T", operand)
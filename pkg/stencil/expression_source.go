@@ -0,0 +1,122 @@
+package stencil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxEvaluatedValueLength limits how much of a value is shown in an error.
+const maxEvaluatedValueLength = 60
+
+// expressionSource renders an expression AST back into template syntax for
+// error messages. Nested binary operations are parenthesized.
+func expressionSource(node ExpressionNode) string {
+	switch n := node.(type) {
+	case nil:
+		return ""
+	case *LiteralNode:
+		switch v := n.Value.(type) {
+		case nil:
+			return "null"
+		case string:
+			return strconv.Quote(v)
+		default:
+			return fmt.Sprintf("%v", v)
+		}
+	case *VariableNode:
+		return n.Name
+	case *FieldAccessNode:
+		return expressionSource(n.Object) + "." + n.Field
+	case *IndexAccessNode:
+		return expressionSource(n.Object) + "[" + expressionSource(n.Index) + "]"
+	case *FunctionCallNode:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = expressionSource(arg)
+		}
+		return n.Name + "(" + strings.Join(args, ", ") + ")"
+	case *LambdaNode:
+		return n.Param + " -> " + expressionSource(n.Body)
+	case *UnaryOpNode:
+		return n.Operator + operandSource(n.Operand)
+	case *BinaryOpNode:
+		return operandSource(n.Left) + " " + n.Operator + " " + operandSource(n.Right)
+	case *ForCollectionNode:
+		var b strings.Builder
+		b.WriteString(expressionSource(n.Collection))
+		if n.Where != nil {
+			b.WriteString(" where " + expressionSource(n.Where))
+		}
+		if n.OrderBy != nil {
+			b.WriteString(" order by " + expressionSource(n.OrderBy))
+			if n.Descending {
+				b.WriteString(" desc")
+			}
+		}
+		if n.Limit != nil {
+			b.WriteString(" limit " + expressionSource(n.Limit))
+		}
+		return b.String()
+	default:
+		return node.String()
+	}
+}
+
+func operandSource(node ExpressionNode) string {
+	if _, ok := node.(*BinaryOpNode); ok {
+		return "(" + expressionSource(node) + ")"
+	}
+	return expressionSource(node)
+}
+
+// formatEvaluatedValue formats a value for an error message, quoting strings
+// and shortening long values.
+func formatEvaluatedValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(truncateEvaluatedValue(v))
+	default:
+		return truncateEvaluatedValue(fmt.Sprintf("%v", v))
+	}
+}
+
+func truncateEvaluatedValue(text string) string {
+	if len(text) > maxEvaluatedValueLength {
+		return text[:maxEvaluatedValueLength] + "..."
+	}
+	return text
+}
+
+// newExpressionEvaluationError wraps cause in an EvaluationError for node,
+// recording the values the given operand nodes resolved to. Literal operands
+// are left out since the expression already shows them.
+func newExpressionEvaluationError(node ExpressionNode, cause error, operands []ExpressionNode, values []interface{}) error {
+	evalErr := &EvaluationError{
+		Expression: expressionSource(node),
+		Cause:      cause,
+	}
+	for i, operand := range operands {
+		if _, literal := operand.(*LiteralNode); literal {
+			continue
+		}
+		evalErr.Values = append(evalErr.Values, EvaluatedValue{
+			Expression: expressionSource(operand),
+			Value:      values[i],
+			Type:       fmt.Sprintf("%T", values[i]),
+		})
+	}
+	return evalErr
+}
+
+// collectionItems converts the value of a for loop collection to its items,
+// describing the collection expression and value when it is not iterable.
+func collectionItems(node ExpressionNode, value interface{}) ([]interface{}, error) {
+	items, err := toSlice(value)
+	if err != nil {
+		return nil, newExpressionEvaluationError(node, err, []ExpressionNode{node}, []interface{}{value})
+	}
+	return items, nil
+}
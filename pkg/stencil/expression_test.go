@@ -36,6 +36,14 @@ func TestTokenizeExpression(t *testing.T) {
 				{Type: ExprTokenEOF, Pos: 4},
 			},
 		},
+		{
+			name: "hexadecimal literal",
+			expr: "0x26a0",
+			want: []ExpressionToken{
+				{Type: ExprTokenNumber, Value: "0x26a0", Pos: 0},
+				{Type: ExprTokenEOF, Pos: 6},
+			},
+		},
 		{
 			name: "decimal starting with dot",
 			expr: ".5",
@@ -285,6 +293,11 @@ func TestParseExpression(t *testing.T) {
 			expr: "3.14",
 			want: "Literal(3.14)",
 		},
+		{
+			name: "hexadecimal literal",
+			expr: "0x26A0",
+			want: "Literal(9888)",
+		},
 		{
 			name: "string literal",
 			expr: `"hello"`,
@@ -0,0 +1,70 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FeatureProvider reports whether a feature flag is enabled, such as by
+// asking the application's feature flag system. It reports false as ok when
+// it does not know the flag. Providers are called concurrently by parallel
+// renders and must be safe for concurrent use.
+type FeatureProvider func(name string) (enabled bool, ok bool)
+
+// WithFeatureProvider returns an option that sets the provider feature()
+// asks for flags that RenderOptions.Features does not set. Flags neither
+// sets are disabled.
+//
+// Example:
+//
+//	engine := stencil.NewWithOptions(stencil.WithFeatureProvider(func(name string) (bool, bool) {
+//	    return flags.Lookup(name)
+//	}))
+//
+// Templates gate content as {{if feature("newFooter")}}...{{end}}.
+func WithFeatureProvider(provider FeatureProvider) Option {
+	return func(e *Engine) {
+		e.data.mu.Lock()
+		e.data.features = provider
+		e.data.mu.Unlock()
+	}
+}
+
+// featureProvider returns the provider set with WithFeatureProvider, or
+// nil.
+func (d *engineData) featureProvider() FeatureProvider {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.features
+}
+
+// featureFunc implements feature(name) and reports whether the named
+// feature flag is enabled for the render.
+func featureFunc(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("feature() name must be a string, got %T", args[0])
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("feature() name cannot be empty")
+	}
+
+	if enabled, ok := helpers.features[name]; ok {
+		return enabled, nil
+	}
+	if helpers.featureProvider != nil {
+		if enabled, ok := helpers.featureProvider(name); ok {
+			return enabled, nil
+		}
+	}
+	return false, nil
+}
+
+// registerFeatureFunctions registers the feature() function
+func registerFeatureFunctions(registry *DefaultFunctionRegistry) {
+	registry.RegisterFunction(&renderHelperFunction{name: "feature", minArgs: 1, maxArgs: 1, handler: featureFunc})
+}
@@ -0,0 +1,50 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeatureFunction(t *testing.T) {
+	engine := NewWithOptions(
+		WithRenderCache(1<<20, time.Minute),
+		WithFeatureProvider(func(name string) (bool, bool) {
+			switch name {
+			case "newFooter":
+				return true, true
+			case "betaTerms":
+				return false, true
+			}
+			return false, false
+		}),
+	)
+	tmpl, err := engine.Prepare(bytes.NewReader(createSimpleDOCX(t,
+		`{{if feature("newFooter")}}new{{else}}old{{end}} {{feature("betaTerms")}} {{feature("unknown")}}`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{})
+	if content := extractTextFromDOCX(t, output); content != "new false false" {
+		t.Errorf("expected the flags of the provider, got %q", content)
+	}
+
+	// Render options take precedence over the provider
+	output = renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{}, RenderOptions{
+		Features: map[string]bool{"newFooter": false, "unknown": true},
+	})
+	if content := extractTextFromDOCX(t, output); content != "old false true" {
+		t.Errorf("expected the flags of the render options, got %q", content)
+	}
+
+	fn, _ := GetDefaultFunctionRegistry().GetFunction("feature")
+	if enabled, err := fn.Call("newFooter"); err != nil || enabled != false {
+		t.Errorf("expected flags outside a render to be disabled, got %v, %v", enabled, err)
+	}
+	if _, err := fn.Call(" "); err == nil || !strings.Contains(err.Error(), "feature() name cannot be empty") {
+		t.Errorf("expected an error for an empty name, got %v", err)
+	}
+}
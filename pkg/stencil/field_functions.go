@@ -0,0 +1,105 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+var (
+	fieldCharName = xml.Name{Space: wordprocessingMLNamespace, Local: "fldChar"}
+	instrTextName = xml.Name{Space: wordprocessingMLNamespace, Local: "instrText"}
+)
+
+// FieldCode represents a Word field inserted by the field() function.
+// It is rendered as a complex field (w:fldChar begin/separate/end) so Word
+// can update it, with Result as the cached display value.
+type FieldCode struct {
+	Instruction string
+	Result      string
+}
+
+// fieldFunc implements field(instruction[, result]).
+func fieldFunc(args ...interface{}) (interface{}, error) {
+	instruction, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("field() instruction must be a string, got %T", args[0])
+	}
+	instruction = strings.TrimSpace(instruction)
+	if instruction == "" {
+		return nil, fmt.Errorf("field() instruction cannot be empty")
+	}
+
+	field := &FieldCode{Instruction: instruction}
+	if len(args) > 1 && args[1] != nil {
+		field.Result = FormatValue(args[1])
+	}
+	return &OOXMLFragment{Content: field}, nil
+}
+
+func registerFieldFunctions(registry *DefaultFunctionRegistry) {
+	// field() function - inserts a Word field such as DOCVARIABLE or DOCPROPERTY
+	fieldFn := NewSimpleFunction("field", 1, 2, fieldFunc)
+	registry.RegisterFunction(fieldFn)
+}
+
+// resolveFieldResult returns the cached result for a field. DOCVARIABLE and
+// DOCPROPERTY fields without an explicit result are resolved from the
+// render's document variables.
+func resolveFieldResult(field *FieldCode, ctx *renderContext) string {
+	if field.Result != "" || ctx == nil || ctx.options == nil || len(ctx.options.DocVariables) == 0 {
+		return field.Result
+	}
+
+	parts := strings.Fields(field.Instruction)
+	if len(parts) < 2 {
+		return field.Result
+	}
+	switch strings.ToUpper(parts[0]) {
+	case "DOCVARIABLE", "DOCPROPERTY":
+		name := strings.Trim(parts[1], `"`)
+		if value, ok := ctx.options.DocVariables[name]; ok {
+			return value
+		}
+	}
+	return field.Result
+}
+
+// fieldCodeRuns builds the runs that make up a complex field, inheriting
+// formatting from templateRun.
+func fieldCodeRuns(field *FieldCode, templateRun *Run, ctx *renderContext) []Run {
+	fieldCharRun := func(charType string) Run {
+		return Run{
+			Properties: templateRun.Properties,
+			RawXML: []RawXMLElement{{
+				XMLName: fieldCharName,
+				Content: []byte(`<w:fldChar w:fldCharType="` + charType + `"/>`),
+			}},
+		}
+	}
+
+	runs := []Run{
+		fieldCharRun("begin"),
+		{
+			Properties: templateRun.Properties,
+			RawXML: []RawXMLElement{{
+				XMLName: instrTextName,
+				Content: []byte(`<w:instrText xml:space="preserve"> ` + escapeXMLText(field.Instruction) + ` </w:instrText>`),
+			}},
+		},
+		fieldCharRun("separate"),
+	}
+
+	if result := resolveFieldResult(field, ctx); result != "" {
+		runs = append(runs, Run{
+			Properties: templateRun.Properties,
+			Attrs:      templateRun.Attrs,
+			Text: &Text{
+				Space:   "preserve",
+				Content: result,
+			},
+		})
+	}
+
+	return append(runs, fieldCharRun("end"))
+}
@@ -0,0 +1,167 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+)
+
+// Figures carry text outside of the paragraphs of a story: the name, alt
+// text and title of a drawing are attributes of wp:docPr in the run's raw
+// XML, and chart titles live in separate chart parts. Template tokens in
+// both are rendered as plain text. Figure captions are ordinary paragraphs
+// and need no special handling.
+
+var (
+	// drawingPropertiesRegex matches a wp:docPr start tag. Attribute values
+	// may contain ">" because raw XML keeps them as decoded.
+	drawingPropertiesRegex = regexp.MustCompile(`<wp:docPr\b(?:[^>"]|"[^"]*")*>`)
+	drawingTextAttrRegex   = regexp.MustCompile(`(\s(?:name|descr|title)=")([^"]*)(")`)
+
+	// chartTextRegex matches the text of a DrawingML run in a chart part.
+	chartTextRegex = regexp.MustCompile(`(<a:t(?:\s[^>]*)?>)([^<]*)(</a:t>)`)
+)
+
+// isChartPartName reports whether name is a chart part.
+func isChartPartName(name string) bool {
+	return isNumberedWordPart(name, "charts/chart")
+}
+
+// hasDrawingPropertyTokens reports whether a drawing in para has template
+// tokens in its name, alt text or title.
+func hasDrawingPropertyTokens(para *Paragraph) bool {
+	for _, content := range para.Content {
+		switch c := content.(type) {
+		case *Run:
+			if runHasDrawingPropertyTokens(c) {
+				return true
+			}
+		case *Hyperlink:
+			for i := range c.Runs {
+				if runHasDrawingPropertyTokens(&c.Runs[i]) {
+					return true
+				}
+			}
+		}
+	}
+	for i := range para.Runs {
+		if runHasDrawingPropertyTokens(&para.Runs[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func runHasDrawingPropertyTokens(run *Run) bool {
+	for _, raw := range run.RawXML {
+		if !bytes.Contains(raw.Content, []byte("{{")) {
+			continue
+		}
+		for _, tag := range drawingPropertiesRegex.FindAll(raw.Content, -1) {
+			if bytes.Contains(tag, []byte("{{")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderDrawingProperties renders the template tokens in the wp:docPr
+// attributes of raw run elements. Elements without tokens are shared with
+// the template.
+func renderDrawingProperties(elements []RawXMLElement, data TemplateData, ctx *renderContext) ([]RawXMLElement, error) {
+	var rendered []RawXMLElement
+	for i, raw := range elements {
+		if !bytes.Contains(raw.Content, []byte("{{")) {
+			continue
+		}
+
+		var renderErr error
+		content := drawingPropertiesRegex.ReplaceAllFunc(raw.Content, func(tag []byte) []byte {
+			return drawingTextAttrRegex.ReplaceAllFunc(tag, func(attr []byte) []byte {
+				match := drawingTextAttrRegex.FindSubmatch(attr)
+				if renderErr != nil || !bytes.Contains(match[2], []byte("{{")) {
+					return attr
+				}
+				value, err := renderFigureText(string(match[2]), data, ctx)
+				if err != nil {
+					renderErr = err
+					return attr
+				}
+				var out bytes.Buffer
+				out.Write(match[1])
+				out.WriteString(escapeXMLText(value))
+				out.Write(match[3])
+				return out.Bytes()
+			})
+		})
+		if renderErr != nil {
+			return nil, fmt.Errorf("failed to render drawing properties: %w", renderErr)
+		}
+		if bytes.Equal(content, raw.Content) {
+			continue
+		}
+
+		if rendered == nil {
+			rendered = append([]RawXMLElement(nil), elements...)
+		}
+		rendered[i].Content = content
+	}
+	if rendered == nil {
+		return elements, nil
+	}
+	return rendered, nil
+}
+
+// renderFigureText renders the template tokens in text that is written as
+// plain text, such as an attribute value.
+func renderFigureText(text string, data TemplateData, ctx *renderContext) (string, error) {
+	rendered, err := RenderTextWithContext(&Text{Content: text}, data, ctx)
+	if err != nil {
+		return "", err
+	}
+	return rendered.Content, nil
+}
+
+// renderChartPart renders the template tokens in the text of a chart part,
+// such as its title and axis titles. A token must be within a single run.
+func renderChartPart(file *zip.File, data TemplateData, ctx *renderContext) ([]byte, error) {
+	fr, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer fr.Close()
+
+	content, err := io.ReadAll(fr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	if !bytes.Contains(content, []byte("{{")) {
+		return content, nil
+	}
+
+	var renderErr error
+	result := chartTextRegex.ReplaceAllFunc(content, func(element []byte) []byte {
+		match := chartTextRegex.FindSubmatch(element)
+		if renderErr != nil || !bytes.Contains(match[2], []byte("{{")) {
+			return element
+		}
+		value, err := renderFigureText(html.UnescapeString(string(match[2])), data, ctx)
+		if err != nil {
+			renderErr = err
+			return element
+		}
+		var out bytes.Buffer
+		out.Write(match[1])
+		out.WriteString(escapeXMLText(value))
+		out.Write(match[3])
+		return out.Bytes()
+	})
+	if renderErr != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", file.Name, renderErr)
+	}
+	return result, nil
+}
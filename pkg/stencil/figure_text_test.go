@@ -0,0 +1,102 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDrawingPropertiesRenderTemplateTokens(t *testing.T) {
+	drawing := `<w:r><w:drawing><wp:inline xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">` +
+		`<wp:extent cx="100" cy="100"/><wp:docPr id="1" name="{{img.name}}" descr="Photo of {{img.alt}}"/>` +
+		`</wp:inline></w:drawing></w:r>`
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>{{for img in images}}</w:t></w:r></w:p>`+
+		`<w:p>`+drawing+`</w:p>`+
+		`<w:p><w:r><w:t>Figure: {{img.name}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`<w:p>`+strings.Replace(drawing, "{{img.name}}", "Static", 1)+`</w:p>`)
+	data := TemplateData{
+		"images": []interface{}{
+			map[string]interface{}{"name": "Plant", "alt": "a plant & its <roots>"},
+			map[string]interface{}{"name": "Tree", "alt": "a tree"},
+		},
+		"img": map[string]interface{}{"name": "Outside", "alt": "the garden"},
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	assertInOrder(t, documentXML,
+		`name="Plant" descr="Photo of a plant &amp; its &lt;roots&gt;"`,
+		"Figure: Plant",
+		`name="Tree" descr="Photo of a tree"`,
+		"Figure: Tree",
+		`name="Static" descr="Photo of the garden"`,
+	)
+	if strings.Contains(documentXML, "{{") {
+		t.Errorf("template tokens left in output: %s", documentXML)
+	}
+}
+
+func TestChartPartRendersTemplateTokens(t *testing.T) {
+	chartXML := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">` +
+		`<c:chart><c:title><c:tx><c:rich><a:p><a:r><a:t>Sales {{year}} &amp; {{region}}</a:t></a:r></a:p></c:rich></c:tx></c:title>` +
+		`<c:plotArea><c:valAx><c:title><c:tx><c:rich><a:p><a:r><a:t xml:space="preserve">Revenue</a:t></a:r></a:p></c:rich></c:tx></c:title></c:valAx></c:plotArea>` +
+		`</c:chart></c:chartSpace>`
+	docx := addDOCXPart(t, createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Report</w:t></w:r></w:p>`), "word/charts/chart1.xml", chartXML)
+
+	rendered := renderWithOptionsToBytes(t, docx, TemplateData{"year": 2024, "region": "R&D"}, RenderOptions{})
+	got := extractPartFromDOCX(t, rendered, "word/charts/chart1.xml")
+	if want := `<a:t>Sales 2024 &amp; R&amp;D</a:t>`; !strings.Contains(got, want) {
+		t.Errorf("chart title not rendered, want %s in %s", want, got)
+	}
+	if !strings.Contains(got, `<a:t xml:space="preserve">Revenue</a:t>`) {
+		t.Errorf("static chart text changed: %s", got)
+	}
+}
+
+func TestChartPartWithoutTokensIsUnchanged(t *testing.T) {
+	chartXML := `<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart/></c:chartSpace>`
+	docx := addDOCXPart(t, createDOCXWithBodyXML(t, `<w:p><w:r><w:t>{{name}}</w:t></w:r></w:p>`), "word/charts/chart1.xml", chartXML)
+
+	rendered := renderWithOptionsToBytes(t, docx, TemplateData{"name": "x"}, RenderOptions{})
+	if got := extractPartFromDOCX(t, rendered, "word/charts/chart1.xml"); got != chartXML {
+		t.Errorf("chart part changed: %s", got)
+	}
+}
+
+// addDOCXPart returns a copy of docx with an additional part.
+func addDOCXPart(t *testing.T, docx []byte, name, content string) []byte {
+	t.Helper()
+
+	r, err := zip.NewReader(bytes.NewReader(docx), int64(len(docx)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		fw, err := w.Create(f.Name)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", f.Name, err)
+		}
+		if _, err := io.Copy(fw, rc); err != nil {
+			t.Fatalf("failed to copy %s: %v", f.Name, err)
+		}
+		rc.Close()
+	}
+	fw, err := w.Create(name)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", name, err)
+	}
+	io.WriteString(fw, content)
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
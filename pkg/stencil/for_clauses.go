@@ -0,0 +1,372 @@
+package stencil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// forClauseKeywords lists the optional for loop clauses in the order they
+// must appear after the collection.
+var forClauseKeywords = []string{"where", "order by", "limit"}
+
+// ForCollectionNode is the collection of a for loop with where, order by or
+// limit clauses. It evaluates to the items of Collection for which Where is
+// truthy, sorted by OrderBy and cut to at most Limit items. The item is bound
+// to Variable while Where and OrderBy are evaluated.
+type ForCollectionNode struct {
+	Collection ExpressionNode
+	Variable   string
+	Where      ExpressionNode // nil without a where clause
+	OrderBy    ExpressionNode // nil without an order by clause
+	Descending bool
+	Limit      ExpressionNode // nil without a limit clause
+}
+
+func (n *ForCollectionNode) String() string {
+	var b strings.Builder
+	b.WriteString(n.Collection.String())
+	if n.Where != nil {
+		fmt.Fprintf(&b, " where %s", n.Where.String())
+	}
+	if n.OrderBy != nil {
+		fmt.Fprintf(&b, " order by %s", n.OrderBy.String())
+		if n.Descending {
+			b.WriteString(" desc")
+		}
+	}
+	if n.Limit != nil {
+		fmt.Fprintf(&b, " limit %s", n.Limit.String())
+	}
+	return b.String()
+}
+
+func (n *ForCollectionNode) Evaluate(data TemplateData) (interface{}, error) {
+	collectionVal, err := n.Collection.Evaluate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := collectionItems(n.Collection, collectionVal)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Where != nil {
+		filtered := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			keep, err := n.Where.Evaluate(n.itemData(data, item))
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate where condition: %w", err)
+			}
+			if isTruthy(keep) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	if n.OrderBy != nil {
+		keys := make([]interface{}, len(items))
+		for i, item := range items {
+			key, err := n.OrderBy.Evaluate(n.itemData(data, item))
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate order by expression: %w", err)
+			}
+			keys[i] = key
+		}
+
+		order := make([]int, len(items))
+		for i := range order {
+			order[i] = i
+		}
+		locale := renderHelpersFromData(data).locale
+		sort.SliceStable(order, func(i, j int) bool {
+			cmp := compareSortKeys(keys[order[i]], keys[order[j]], locale)
+			if n.Descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+
+		sorted := make([]interface{}, len(items))
+		for i, idx := range order {
+			sorted[i] = items[idx]
+		}
+		items = sorted
+	}
+
+	if n.Limit != nil {
+		limitVal, err := n.Limit.Evaluate(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate limit: %w", err)
+		}
+		limit, ok := toInt(limitVal)
+		if !ok {
+			return nil, newExpressionEvaluationError(n.Limit, fmt.Errorf("limit must be an integer, got %T", limitVal), []ExpressionNode{n.Limit}, []interface{}{limitVal})
+		}
+		if limit < 0 {
+			return nil, newExpressionEvaluationError(n.Limit, fmt.Errorf("limit must not be negative, got %d", limit), []ExpressionNode{n.Limit}, []interface{}{limitVal})
+		}
+		if limit < len(items) {
+			items = items[:limit]
+		}
+	}
+
+	return items, nil
+}
+
+func (n *ForCollectionNode) itemData(data TemplateData, item interface{}) TemplateData {
+	itemData := newChildTemplateData(data, 1)
+	itemData[n.Variable] = item
+	return itemData
+}
+
+// compareSortKeys orders nil before booleans, numbers, times and strings.
+// Values of other types are compared by their formatted text. Strings are
+// compared in the collation of locale.
+func compareSortKeys(a, b interface{}, locale string) int {
+	rankA, rankB := sortKeyRank(a), sortKeyRank(b)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+
+	switch rankA {
+	case 0:
+		return 0
+	case 1:
+		switch {
+		case a.(bool) == b.(bool):
+			return 0
+		case !a.(bool):
+			return -1
+		default:
+			return 1
+		}
+	case 2:
+		numA, _ := toFloat64(a)
+		numB, _ := toFloat64(b)
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		default:
+			return 0
+		}
+	case 3:
+		return a.(time.Time).Compare(b.(time.Time))
+	case 4:
+		return compareSortText(a.(string), b.(string), locale)
+	default:
+		return compareSortText(FormatValue(a), FormatValue(b), locale)
+	}
+}
+
+// compareSortText compares a and b in the collation of locale, the root
+// collation without a locale.
+func compareSortText(a, b, locale string) int {
+	return compareCollated(a, b, locale)
+}
+
+func sortKeyRank(value interface{}) int {
+	if value == nil {
+		return 0
+	}
+	if _, ok := value.(bool); ok {
+		return 1
+	}
+	if _, ok := toFloat64(value); ok {
+		return 2
+	}
+	switch value.(type) {
+	case time.Time:
+		return 3
+	case string:
+		return 4
+	}
+	return 5
+}
+
+// forClauses holds the source text of a for loop collection and its
+// optional clauses.
+type forClauses struct {
+	collection string
+	where      string
+	orderBy    string
+	descending bool
+	limit      string
+}
+
+// splitForClauses splits the collection part of a for loop at the where,
+// order by and limit keywords outside of string literals and parentheses.
+func splitForClauses(text string) (forClauses, error) {
+	var clauses forClauses
+
+	start, keyword, keywordEnd := nextForClause(text, 0)
+	if start == -1 {
+		clauses.collection = text
+		return clauses, nil
+	}
+	clauses.collection = strings.TrimSpace(text[:start])
+
+	lastRank := -1
+	for start != -1 {
+		rank := forClauseRank(keyword)
+		if rank <= lastRank {
+			return clauses, fmt.Errorf("invalid for loop syntax: unexpected '%s' clause", keyword)
+		}
+		lastRank = rank
+
+		next, nextKeyword, nextEnd := nextForClause(text, keywordEnd)
+		end := next
+		if end == -1 {
+			end = len(text)
+		}
+		value := strings.TrimSpace(text[keywordEnd:end])
+		if value == "" {
+			return clauses, fmt.Errorf("invalid for loop syntax: missing expression after '%s'", keyword)
+		}
+
+		switch keyword {
+		case "where":
+			clauses.where = value
+		case "order by":
+			clauses.orderBy, clauses.descending = splitSortDirection(value)
+			if clauses.orderBy == "" {
+				return clauses, fmt.Errorf("invalid for loop syntax: missing expression after '%s'", keyword)
+			}
+		case "limit":
+			clauses.limit = value
+		}
+
+		start, keyword, keywordEnd = next, nextKeyword, nextEnd
+	}
+
+	return clauses, nil
+}
+
+// nextForClause returns the start, keyword and end of the next clause
+// keyword at or after from, or -1 if there is none.
+func nextForClause(text string, from int) (int, string, int) {
+	var quote byte
+	depth := 0
+	for i := from; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		case ch == '"' || ch == '\'':
+			quote = ch
+			continue
+		case ch == '(' || ch == '[':
+			depth++
+			continue
+		case ch == ')' || ch == ']':
+			depth--
+			continue
+		}
+		if depth != 0 || i == 0 || !isIncludeSpace(text[i-1]) {
+			continue
+		}
+		for _, keyword := range forClauseKeywords {
+			if end, ok := matchForClauseKeyword(text, i, keyword); ok {
+				return i, keyword, end
+			}
+		}
+	}
+	return -1, "", -1
+}
+
+// matchForClauseKeyword reports whether keyword starts at position i and is
+// followed by whitespace or the end of text. Words of a keyword may be
+// separated by any amount of whitespace.
+func matchForClauseKeyword(text string, i int, keyword string) (int, bool) {
+	pos := i
+	for w, word := range strings.Fields(keyword) {
+		if w > 0 {
+			spaceStart := pos
+			for pos < len(text) && isIncludeSpace(text[pos]) {
+				pos++
+			}
+			if pos == spaceStart {
+				return 0, false
+			}
+		}
+		if !strings.HasPrefix(text[pos:], word) {
+			return 0, false
+		}
+		pos += len(word)
+	}
+	if pos < len(text) && !isIncludeSpace(text[pos]) {
+		return 0, false
+	}
+	return pos, true
+}
+
+func forClauseRank(keyword string) int {
+	for i, candidate := range forClauseKeywords {
+		if candidate == keyword {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitSortDirection removes a trailing asc or desc keyword from an order by
+// expression.
+func splitSortDirection(value string) (string, bool) {
+	for _, direction := range []string{"desc", "asc"} {
+		if !strings.HasSuffix(value, direction) {
+			continue
+		}
+		rest := value[:len(value)-len(direction)]
+		if rest == "" || !isIncludeSpace(rest[len(rest)-1]) {
+			continue
+		}
+		return strings.TrimSpace(rest), direction == "desc"
+	}
+	return value, false
+}
+
+// applyForClauses wraps the collection in a ForCollectionNode when the loop
+// has where, order by or limit clauses.
+func applyForClauses(
+	collection ExpressionNode,
+	variable string,
+	clauses forClauses,
+	parseExpression func(string) (ExpressionNode, error),
+) (ExpressionNode, error) {
+	if clauses.where == "" && clauses.orderBy == "" && clauses.limit == "" {
+		return collection, nil
+	}
+
+	node := &ForCollectionNode{
+		Collection: collection,
+		Variable:   variable,
+		Descending: clauses.descending,
+	}
+	var err error
+	if clauses.where != "" {
+		if node.Where, err = parseExpression(clauses.where); err != nil {
+			return nil, fmt.Errorf("failed to parse where condition: %w", err)
+		}
+	}
+	if clauses.orderBy != "" {
+		if node.OrderBy, err = parseExpression(clauses.orderBy); err != nil {
+			return nil, fmt.Errorf("failed to parse order by expression: %w", err)
+		}
+	}
+	if clauses.limit != "" {
+		if node.Limit, err = parseExpression(clauses.limit); err != nil {
+			return nil, fmt.Errorf("failed to parse limit expression: %w", err)
+		}
+	}
+	return node, nil
+}
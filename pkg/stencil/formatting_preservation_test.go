@@ -50,14 +50,19 @@ func TestMergeConsecutiveRunsWithContent_PreservesProofErr(t *testing.T) {
 
 	render.MergeConsecutiveRuns(para)
 
-	if len(para.Content) != 5 {
-		t.Fatalf("expected 5 content items, got %d", len(para.Content))
+	// The proofing markers inside the split token move behind the merged token.
+	if len(para.Content) != 3 {
+		t.Fatalf("expected 3 content items, got %d", len(para.Content))
+	}
+	run, ok := para.Content[0].(*Run)
+	if !ok || run.Text == nil || run.Text.Content != "{{include}}" {
+		t.Fatalf("expected merged token run at content index 0, got %#v", para.Content[0])
 	}
 	if _, ok := para.Content[1].(*ProofErr); !ok {
 		t.Fatalf("expected proofErr at content index 1, got %T", para.Content[1])
 	}
-	if _, ok := para.Content[3].(*ProofErr); !ok {
-		t.Fatalf("expected proofErr at content index 3, got %T", para.Content[3])
+	if _, ok := para.Content[2].(*ProofErr); !ok {
+		t.Fatalf("expected proofErr at content index 2, got %T", para.Content[2])
 	}
 }
 
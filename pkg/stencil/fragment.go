@@ -1,6 +1,7 @@
 package stencil
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -60,6 +61,10 @@ func (n *UnlessNode) RenderWithContext(data TemplateData, ctx *renderContext) (s
 }
 
 func (n *ForNode) RenderWithContext(data TemplateData, ctx *renderContext) (string, error) {
+	if err := n.checkShadowing(data); err != nil {
+		return "", err
+	}
+
 	// Evaluate the collection expression
 	collectionValue, err := n.Collection.Evaluate(data)
 	if err != nil {
@@ -67,7 +72,7 @@ func (n *ForNode) RenderWithContext(data TemplateData, ctx *renderContext) (stri
 	}
 
 	// Convert to slice
-	slice, err := toSlice(collectionValue)
+	slice, err := collectionItems(n.Collection, collectionValue)
 	if err != nil {
 		return "", fmt.Errorf("failed to iterate over collection: %w", err)
 	}
@@ -75,11 +80,7 @@ func (n *ForNode) RenderWithContext(data TemplateData, ctx *renderContext) (stri
 	var result strings.Builder
 
 	for idx, item := range slice {
-		loopData := newChildTemplateData(data, 2)
-		loopData[n.Variable] = item
-		if n.IndexVar != "" {
-			loopData[n.IndexVar] = idx
-		}
+		loopData := n.iterationData(data, idx, item)
 
 		// Render the body
 		bodyResult, err := renderControlBodyWithContext(n.Body, loopData, ctx)
@@ -89,6 +90,11 @@ func (n *ForNode) RenderWithContext(data TemplateData, ctx *renderContext) (stri
 		result.WriteString(bodyResult)
 	}
 
+	// Fall back to else body
+	if len(slice) == 0 && len(n.ElseBody) > 0 {
+		return renderControlBodyWithContext(n.ElseBody, data, ctx)
+	}
+
 	return result.String(), nil
 }
 
@@ -109,6 +115,15 @@ func (n *ExpressionContentNode) RenderWithContext(data TemplateData, ctx *render
 	} else if marker, ok := value.(*TableColumnMarker); ok {
 		// Handle table column markers
 		return marker.String(), nil
+	} else if marker, ok := value.(*TableMarker); ok {
+		// Handle table markers
+		return marker.String(), nil
+	} else if marker, ok := value.(*TableSortMarker); ok {
+		// Handle table sort markers
+		return marker.String(), nil
+	} else if marker, ok := value.(*ParagraphMarker); ok {
+		// Handle paragraph markers
+		return marker.String(), nil
 	} else if marker, ok := value.(LinkReplacementMarker); ok && ctx != nil {
 		// Handle link replacement markers
 		markerKey := fmt.Sprintf("link_%d", len(ctx.linkMarkers))
@@ -117,7 +132,7 @@ func (n *ExpressionContentNode) RenderWithContext(data TemplateData, ctx *render
 		return fmt.Sprintf("{{LINK_REPLACEMENT:%s}}", markerKey), nil
 	}
 
-	return FormatValue(value), nil
+	return ctx.formatText(value), nil
 }
 
 func (n *IncludeNode) RenderWithContext(data TemplateData, ctx *renderContext) (string, error) {
@@ -125,12 +140,6 @@ func (n *IncludeNode) RenderWithContext(data TemplateData, ctx *renderContext) (
 		return "", fmt.Errorf("fragments not available in render context")
 	}
 
-	// Check render depth
-	config := GetGlobalConfig()
-	if ctx.renderDepth >= config.MaxRenderDepth {
-		return "", fmt.Errorf("maximum render depth exceeded: %d", config.MaxRenderDepth)
-	}
-
 	// Evaluate the fragment name expression
 	nameValue, err := n.FragmentName.Evaluate(data)
 	if err != nil {
@@ -148,29 +157,24 @@ func (n *IncludeNode) RenderWithContext(data TemplateData, ctx *renderContext) (
 		return "", fmt.Errorf("fragment name must be a string, got %T", nameValue)
 	}
 
-	// Check for circular references
-	for _, stackName := range ctx.fragmentStack {
-		if stackName == fragmentName {
-			return "", fmt.Errorf("circular fragment reference detected: %s", fragmentName)
-		}
-	}
-
 	fragment, err := resolveFragmentByName(fragmentName, ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve fragment %s: %w", fragmentName, err)
 	}
 	if fragment == nil {
-		return "", fmt.Errorf("fragment not found: %s", fragmentName)
+		fragmentName, fragment, err = resolveMissingFragment(fragmentName, ctx)
+		if err != nil {
+			return "", err
+		}
+		if fragment == nil {
+			return "", nil
+		}
 	}
 
-	// Push fragment onto stack and increment depth
-	ctx.fragmentStack = append(ctx.fragmentStack, fragmentName)
-	ctx.renderDepth++
-	defer func() {
-		// Pop fragment from stack and decrement depth
-		ctx.fragmentStack = ctx.fragmentStack[:len(ctx.fragmentStack)-1]
-		ctx.renderDepth--
-	}()
+	if err := ctx.enterFragment(fragmentName); err != nil {
+		return "", err
+	}
+	defer ctx.leaveFragment()
 
 	// For DOCX fragments, store a marker that will be replaced at the element level
 	// This is because DOCX fragments contain body elements (paragraphs, tables) not just text
@@ -184,18 +188,103 @@ func (n *IncludeNode) RenderWithContext(data TemplateData, ctx *renderContext) (
 		}
 		ctx.ooxmlFragments[markerKey] = fragment
 
+		if n.modifiers != nil {
+			mods, err := n.modifiers.resolve(data)
+			if err != nil {
+				return "", err
+			}
+			if ctx.includeModifiers == nil {
+				ctx.includeModifiers = make(map[string]*resolvedIncludeModifiers)
+			}
+			ctx.includeModifiers[markerKey] = mods
+		}
+
 		// Return the marker
 		return markerKey, nil
 	}
 
+	if n.modifiers != nil {
+		return "", fmt.Errorf("include modifiers require a DOCX fragment or an include on its own paragraph: %s", fragmentName)
+	}
+
+	span, endSpan := ctx.startSpan(spanInclude)
+	span.SetAttribute(attrFragment, fragmentName)
+
 	// For text fragments, parse and render as control structures
 	structures, err := ParseControlStructures(fragment.content)
 	if err != nil {
+		endSpan(err)
 		return "", fmt.Errorf("failed to parse fragment %s: %w", fragmentName, err)
 	}
 
 	// Render the fragment structures with context
-	return renderControlBodyWithContext(structures, data, ctx)
+	rendered, err := renderControlBodyWithContext(structures, data, ctx)
+	endSpan(err)
+	return rendered, ctx.fragmentError(data, err)
+}
+
+// enterFragment pushes a fragment onto the include stack. It fails when the
+// fragment is already being rendered or when the include depth limit is
+// reached; both errors report the include chain that led there.
+func (ctx *renderContext) enterFragment(name string) error {
+	for _, stackName := range ctx.fragmentStack {
+		if stackName == name {
+			return fmt.Errorf("circular fragment reference detected: %s (include chain: %s)",
+				name, formatIncludeChain(ctx.fragmentStack, name))
+		}
+	}
+
+	limit := ctx.includeDepthLimit()
+	if len(ctx.fragmentStack) >= limit {
+		return fmt.Errorf("maximum include depth exceeded: %d (include chain: %s)",
+			limit, formatIncludeChain(ctx.fragmentStack, name))
+	}
+
+	ctx.fragmentStack = append(ctx.fragmentStack, name)
+	return nil
+}
+
+// includeDepthLimit returns the include depth limit of the engine the
+// template was prepared with, or that of the global configuration.
+func (ctx *renderContext) includeDepthLimit() int {
+	if ctx.template != nil && ctx.template.maxIncludeDepth > 0 {
+		return ctx.template.maxIncludeDepth
+	}
+	return GetGlobalConfig().includeDepthLimit()
+}
+
+// leaveFragment pops the fragment pushed by the last enterFragment call.
+func (ctx *renderContext) leaveFragment() {
+	ctx.fragmentStack = ctx.fragmentStack[:len(ctx.fragmentStack)-1]
+}
+
+// fragmentError wraps err, raised while rendering the fragment on top of
+// the include stack, in a FragmentError. Errors of fragments included by
+// that fragment already carry the whole include chain and are returned
+// unchanged.
+func (ctx *renderContext) fragmentError(data TemplateData, err error) error {
+	var fragmentErr *FragmentError
+	if err == nil || errors.As(err, &fragmentErr) {
+		return err
+	}
+	fragmentErr = &FragmentError{
+		IncludeChain:  append([]string(nil), ctx.fragmentStack...),
+		LoopVariables: loopVariables(data),
+		Cause:         err,
+	}
+	if ctx.template != nil {
+		fragmentErr.Template = ctx.template.name
+	}
+	return fragmentErr
+}
+
+// formatIncludeChain formats an include stack followed by the fragment being
+// included, e.g. "a -> b -> a".
+func formatIncludeChain(stack []string, name string) string {
+	chain := make([]string, 0, len(stack)+1)
+	chain = append(chain, stack...)
+	chain = append(chain, name)
+	return strings.Join(chain, " -> ")
 }
 
 // renderControlBodyWithContext renders a slice of control structures with context
@@ -225,7 +314,6 @@ func ProcessTemplateWithFragments(content string, data TemplateData, fragments m
 	ctx := &renderContext{
 		fragments:      fragments,
 		fragmentStack:  make([]string, 0),
-		renderDepth:    0,
 		ooxmlFragments: make(map[string]interface{}),
 	}
 
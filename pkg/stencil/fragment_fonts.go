@@ -8,7 +8,8 @@ type fragmentFontOverrides struct {
 }
 
 type styleFontCatalog struct {
-	styles map[string]styleFontDefinition
+	styles           map[string]styleFontDefinition
+	defaultParagraph string
 }
 
 type styleFontDefinition struct {
@@ -24,6 +25,7 @@ type styleFontStylesXML struct {
 type styleFontStyleXML struct {
 	StyleID  string            `xml:"styleId,attr"`
 	Type     string            `xml:"type,attr"`
+	Default  string            `xml:"default,attr"`
 	BasedOn  *styleRefXML      `xml:"basedOn"`
 	RunProps *styleRunPropsXML `xml:"rPr"`
 }
@@ -105,6 +107,9 @@ func parseStyleFontCatalog(stylesXML []byte) (*styleFontCatalog, error) {
 			def.Font = cloneFont(style.RunProps.Font)
 		}
 		catalog.styles[style.StyleID] = def
+		if style.Type == "paragraph" && (style.Default == "1" || style.Default == "true") && catalog.defaultParagraph == "" {
+			catalog.defaultParagraph = style.StyleID
+		}
 	}
 
 	return catalog, nil
@@ -323,6 +323,63 @@ func TestCompiledFragmentNumberingPreservesExternalAbstractReference(t *testing.
 	}
 }
 
+func TestDOCXFragmentNumberingContinueVsRestart(t *testing.T) {
+	templateDoc := createDOCXWithOptionalNumbering(t,
+		`<w:p><w:r><w:t>{{include "roman"}}</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t>Between</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t>{{include "roman"}}</w:t></w:r></w:p>`,
+		"",
+		false,
+	)
+	fragmentDoc := createDOCXWithOptionalNumbering(t,
+		`<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Roman item</w:t></w:r></w:p>`,
+		upperRomanNumberingXML(),
+		true,
+	)
+
+	render := func(mode FragmentNumbering) (string, string) {
+		tmpl, err := prepare(bytes.NewReader(templateDoc))
+		if err != nil {
+			t.Fatalf("failed to prepare template: %v", err)
+		}
+		defer tmpl.Close()
+		if err := tmpl.AddFragmentFromBytes("roman", fragmentDoc); err != nil {
+			t.Fatalf("failed to add fragment: %v", err)
+		}
+		reader, err := tmpl.RenderWithOptions(nil, RenderOptions{FragmentNumbering: mode})
+		if err != nil {
+			t.Fatalf("failed to render template: %v", err)
+		}
+		rendered, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		return readDOCXPart(t, rendered, "word/document.xml"), readDOCXPart(t, rendered, "word/numbering.xml")
+	}
+
+	firstNumID := strconv.Itoa(fragmentNumberingIDFloor)
+	restartNumID := strconv.Itoa(fragmentNumberingIDFloor + 1)
+	numIDRef := func(id string) string { return `<w:numId w:val="` + id + `"` }
+
+	documentXML, numberingXML := render(FragmentNumberingContinue)
+	if count := strings.Count(documentXML, numIDRef(firstNumID)); count != 2 {
+		t.Fatalf("expected both includes to share numId=%s, got %d references in:\n%s", firstNumID, count, documentXML)
+	}
+	if strings.Contains(numberingXML, "w:startOverride") {
+		t.Fatalf("expected no restart overrides when continuing, got:\n%s", numberingXML)
+	}
+
+	documentXML, numberingXML = render(FragmentNumberingRestart)
+	if strings.Count(documentXML, numIDRef(firstNumID)) != 1 || strings.Count(documentXML, numIDRef(restartNumID)) != 1 {
+		t.Fatalf("expected includes to use numId=%s and numId=%s, got:\n%s", firstNumID, restartNumID, documentXML)
+	}
+	wantNum := `<w:num w:numId="` + restartNumID + `"><w:abstractNumId w:val="` + firstNumID + `"/>` +
+		`<w:lvlOverride w:ilvl="0"><w:startOverride w:val="1"/></w:lvlOverride></w:num>`
+	if !strings.Contains(numberingXML, wantNum) {
+		t.Fatalf("expected restart numbering instance %s, got:\n%s", wantNum, numberingXML)
+	}
+}
+
 func createDOCXWithOptionalNumbering(t *testing.T, bodyXML, numberingXML string, includeNumberingRelationship bool) []byte {
 	t.Helper()
 
@@ -122,6 +122,18 @@ func newLazyDocxFragment(name string, docxBytes []byte) *fragment {
 	}
 }
 
+// newSharedDocxFragment returns a DOCX fragment that keeps a reference to
+// docxBytes instead of a copy and reads its media from them only when it is
+// included, so registering many fragments keeps memory flat.
+func newSharedDocxFragment(name string, docxBytes []byte) *fragment {
+	return &fragment{
+		name:      name,
+		isDocx:    true,
+		docxData:  docxBytes,
+		lazyMedia: true,
+	}
+}
+
 func newResolvedFragment(name string, raw []byte) (*fragment, error) {
 	if _, err := NewDocxReader(bytes.NewReader(raw), int64(len(raw))); err == nil {
 		frag := newLazyDocxFragment(name, raw)
@@ -172,26 +184,29 @@ func (frag *fragment) ensurePrepared(mainStylesXML []byte) error {
 			return
 		}
 
-		mediaFiles := make(map[string][]byte)
-		for _, file := range zipReader.File {
-			if strings.HasPrefix(file.Name, "word/media/") {
-				rc, err := file.Open()
-				if err != nil {
-					frag.prepareErr = fmt.Errorf("failed to open media file %s: %w", file.Name, err)
-					return
+		// Lazy media is read from the source bytes when it is included
+		if !frag.lazyMedia {
+			mediaFiles := make(map[string][]byte)
+			for _, file := range zipReader.File {
+				if strings.HasPrefix(file.Name, "word/media/") {
+					rc, err := file.Open()
+					if err != nil {
+						frag.prepareErr = fmt.Errorf("failed to open media file %s: %w", file.Name, err)
+						return
+					}
+
+					content, err := io.ReadAll(rc)
+					rc.Close()
+					if err != nil {
+						frag.prepareErr = fmt.Errorf("failed to read media file %s: %w", file.Name, err)
+						return
+					}
+
+					mediaFiles[strings.TrimPrefix(file.Name, "word/")] = content
 				}
-
-				content, err := io.ReadAll(rc)
-				rc.Close()
-				if err != nil {
-					frag.prepareErr = fmt.Errorf("failed to read media file %s: %w", file.Name, err)
-					return
-				}
-
-				mediaFiles[strings.TrimPrefix(file.Name, "word/")] = content
 			}
+			frag.mediaFiles = mediaFiles
 		}
-		frag.mediaFiles = mediaFiles
 
 		var relationships []Relationship
 		for _, file := range zipReader.File {
@@ -226,6 +241,16 @@ func (frag *fragment) ensurePrepared(mainStylesXML []byte) error {
 				if err != nil {
 					frag.stylesXML = nil
 				}
+			case themePartName:
+				rc, err := file.Open()
+				if err != nil {
+					continue
+				}
+				themeXML, err := io.ReadAll(rc)
+				rc.Close()
+				if err == nil {
+					frag.theme = parseDocumentTheme(themeXML)
+				}
 			case "word/numbering.xml":
 				rc, err := file.Open()
 				if err != nil {
@@ -250,11 +275,44 @@ func (frag *fragment) ensurePrepared(mainStylesXML []byte) error {
 			frag.fontOverride = override
 			frag.hasFontOverride = true
 		}
+		frag.styleConflicts = conflictingStyleIDs(mainStylesXML, frag.stylesXML)
 	})
 
 	return frag.prepareErr
 }
 
+// mediaLoader returns a function that returns the content of a media file of
+// the fragment by its path relative to word/, such as "media/image1.png".
+// Fragments with lazy media read it from their source bytes on each call.
+func (frag *fragment) mediaLoader() func(target string) ([]byte, bool) {
+	if !frag.lazyMedia {
+		return func(target string) ([]byte, bool) {
+			content, ok := frag.mediaFiles[target]
+			return content, ok
+		}
+	}
+	files := make(map[string]*zip.File)
+	if zipReader, err := zip.NewReader(bytes.NewReader(frag.docxData), int64(len(frag.docxData))); err == nil {
+		for _, file := range zipReader.File {
+			if strings.HasPrefix(file.Name, "word/media/") {
+				files[strings.TrimPrefix(file.Name, "word/")] = file
+			}
+		}
+	}
+	return func(target string) ([]byte, bool) {
+		file, ok := files[target]
+		if !ok {
+			return nil, false
+		}
+		content, err := readZipFile(file)
+		if err != nil {
+			Warn("fragment %s: failed to read %s: %v", frag.name, file.Name, err)
+			return nil, false
+		}
+		return content, true
+	}
+}
+
 func installFragmentRenderPlans(ctx *renderContext, frag *fragment) {
 	if ctx == nil || frag == nil || frag.parsed == nil || frag.parsed.Body == nil {
 		return
@@ -25,8 +25,8 @@ func (pt *PreparedTemplate) SetFragmentResolver(resolver FragmentResolver) {
 	if pt == nil || pt.template == nil {
 		return
 	}
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
 	pt.template.mu.Lock()
 	defer pt.template.mu.Unlock()
 	pt.template.fragmentResolver = resolver
@@ -3,12 +3,14 @@ package stencil
 import (
 	"archive/zip"
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestFragmentResolver_ExplicitFragmentTakesPrecedence(t *testing.T) {
@@ -219,8 +221,8 @@ func TestRenderIncludedFragment_RespectsMaxRenderDepthForDOCXFragments(t *testin
 	if err == nil {
 		t.Fatal("expected render to fail for excessive DOCX fragment nesting")
 	}
-	if !strings.Contains(err.Error(), "maximum render depth exceeded") {
-		t.Fatalf("expected maximum render depth error, got %v", err)
+	if !strings.Contains(err.Error(), "maximum include depth exceeded") {
+		t.Fatalf("expected maximum include depth error, got %v", err)
 	}
 }
 
@@ -255,8 +257,8 @@ func TestRenderIncludedFragment_RespectsMaxRenderDepthAcrossMixedTextAndDOCXChai
 	if err == nil {
 		t.Fatal("expected render to fail for mixed text/DOCX fragment nesting")
 	}
-	if !strings.Contains(err.Error(), "maximum render depth exceeded") {
-		t.Fatalf("expected maximum render depth error, got %v", err)
+	if !strings.Contains(err.Error(), "maximum include depth exceeded") {
+		t.Fatalf("expected maximum include depth error, got %v", err)
 	}
 }
 
@@ -722,3 +724,114 @@ func joinRuns(parts []string) string {
 	}
 	return b.String()
 }
+
+func TestPreparedTemplate_AddFragmentDuringRender(t *testing.T) {
+	prepared, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`{{include "lazy"}}`,
+		`{{include "late"}}`,
+	})))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer prepared.Close()
+
+	// The resolver runs in the middle of a render. Registering a fragment
+	// from another goroutine must not wait for that render to finish.
+	added := make(chan error, 1)
+	prepared.SetFragmentResolver(FragmentResolverFunc(func(name string) ([]byte, error) {
+		if name != "lazy" {
+			return nil, nil
+		}
+		go func() {
+			added <- prepared.AddFragmentFromBytes("late", createFragmentDOCX(t, "Late"))
+		}()
+		select {
+		case err := <-added:
+			if err != nil {
+				return nil, err
+			}
+		case <-time.After(5 * time.Second):
+			return nil, fmt.Errorf("AddFragmentFromBytes blocked by render in progress")
+		}
+		return []byte("Lazy"), nil
+	}))
+
+	if _, err := prepared.Render(TemplateData{}); err != nil && strings.Contains(err.Error(), "blocked") {
+		t.Fatal(err)
+	}
+
+	output, err := prepared.Render(TemplateData{})
+	if err != nil {
+		t.Fatalf("second render failed: %v", err)
+	}
+	content, err := io.ReadAll(output)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if text := extractTextFromDOCX(t, content); text != "LazyLate" {
+		t.Fatalf("expected both fragments on the next render, got %q", text)
+	}
+}
+
+func TestPreparedTemplate_ConcurrentRegistrationAndRender(t *testing.T) {
+	prepared, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{include "body"}}`})))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer prepared.Close()
+	if err := prepared.AddFragmentFromBytes("body", createFragmentDOCX(t, "Body")); err != nil {
+		t.Fatalf("AddFragmentFromBytes failed: %v", err)
+	}
+	headerBytes := createFragmentDOCX(t, "Header")
+	fragmentBytes := createFragmentDOCX(t, "Replaced")
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 8)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				rendered, err := prepared.Render(TemplateData{})
+				if err != nil {
+					errCh <- err
+					return
+				}
+				if _, err := io.ReadAll(rendered); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("frag%d", i)
+			if err := prepared.AddFragment(name, "text"); err != nil {
+				errCh <- err
+				return
+			}
+			if err := prepared.AddFragmentFromBytes("body", fragmentBytes); err != nil {
+				errCh <- err
+				return
+			}
+			if err := prepared.SetHeaderFragment(fmt.Sprintf("header%d", i), headerBytes); err != nil {
+				errCh <- err
+				return
+			}
+			_ = prepared.Fragments()
+		}
+	}()
+
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("concurrent registration failed: %v", err)
+	}
+	if got := len(prepared.Fragments()); got != 41 {
+		t.Fatalf("expected 41 registered fragments, got %d", got)
+	}
+}
@@ -1,13 +1,50 @@
 package stencil
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// relationshipIDAttrRegex matches relationship references such as r:embed="rId6"
+// or r:id="rId6". RawXML stores the namespace as a full URI rather than a prefix.
+var relationshipIDAttrRegex = regexp.MustCompile(`((?:http://schemas\.openxmlformats\.org/officeDocument/2006/relationships|\br):(?:embed|id)=")([^"]*)(")`)
+
+// relationshipIDAllocator hands out relationship IDs for a part. All IDs the
+// part already uses are reserved, so remapped fragment relationships never
+// collide with them regardless of how they are numbered.
+type relationshipIDAllocator struct {
+	used map[string]bool
+	next int
+}
+
+func newRelationshipIDAllocator(rels []Relationship) *relationshipIDAllocator {
+	a := &relationshipIDAllocator{
+		used: make(map[string]bool, len(rels)),
+		next: 1,
+	}
+	for _, rel := range rels {
+		a.used[rel.ID] = true
+	}
+	return a
+}
+
+// allocate returns the lowest unused ID of the form rIdN.
+func (a *relationshipIDAllocator) allocate() string {
+	for {
+		id := "rId" + strconv.Itoa(a.next)
+		a.next++
+		if !a.used[id] {
+			a.used[id] = true
+			return id
+		}
+	}
+}
+
 // extractRelationshipNumber extracts the numeric ID from a relationship ID like "rId6"
 func extractRelationshipNumber(rId string) (int, error) {
 	if !strings.HasPrefix(rId, "rId") {
@@ -33,6 +70,81 @@ func renameMediaPath(originalPath, fragmentName string, counter int) string {
 	return path.Join(dir, newName)
 }
 
+// packageRelationshipTypes are fragment relationships that are not copied on
+// include: styles and numbering are merged separately, and the other parts
+// do not belong to the included body.
+var packageRelationshipTypes = map[string]bool{
+	"styles":      true,
+	"numbering":   true,
+	"settings":    true,
+	"webSettings": true,
+	"fontTable":   true,
+	"theme":       true,
+	"header":      true,
+	"footer":      true,
+	"customXml":   true,
+}
+
+// copyFragmentRelationships adds the relationships a fragment body needs to the
+// render context and returns the mapping from the fragment's relationship IDs
+// to the newly allocated ones. Media is copied under a fragment-specific name;
+// external relationships such as hyperlinks are copied as is. Other internal
+// relationships (footnotes, comments, charts, ...) are not supported and are
+// reported with a warning.
+func copyFragmentRelationships(fragmentName string, frag *fragment, ctx *renderContext) (map[string]string, error) {
+	ids, err := ctx.relationshipIDAllocator()
+	if err != nil {
+		return nil, err
+	}
+
+	idMap := make(map[string]string)
+	loadMedia := frag.mediaLoader()
+	imageCounter := 1
+	var unsupported []string
+	for _, rel := range frag.relationships {
+		switch {
+		case rel.TargetMode == "External":
+			newID := ids.allocate()
+			idMap[rel.ID] = newID
+			ctx.fragmentRelationships = append(ctx.fragmentRelationships, Relationship{
+				ID:         newID,
+				Type:       rel.Type,
+				Target:     rel.Target,
+				TargetMode: rel.TargetMode,
+			})
+
+		case isMediaRelationship(rel):
+			newID := ids.allocate()
+			idMap[rel.ID] = newID
+			newTarget := renameMediaPath(rel.Target, fragmentName, imageCounter)
+			if mediaContent, ok := loadMedia(rel.Target); ok {
+				newFilename := path.Base(newTarget)
+				ctx.fragmentMedia[newFilename] = mediaContent
+			}
+
+			ctx.fragmentRelationships = append(ctx.fragmentRelationships, Relationship{
+				ID:     newID,
+				Type:   rel.Type,
+				Target: newTarget,
+			})
+			imageCounter++
+
+		case !packageRelationshipTypes[path.Base(rel.Type)]:
+			unsupported = append(unsupported, path.Base(rel.Type))
+		}
+	}
+
+	if len(unsupported) > 0 {
+		ctx.options.warn(RenderWarning{
+			Code:     WarningFragmentRelationships,
+			Fragment: fragmentName,
+			Message: fmt.Sprintf("fragment %s: relationships of type %s are not copied; content referencing them will not display",
+				fragmentName, strings.Join(unsupported, ", ")),
+		})
+	}
+	return idMap, nil
+}
+
 // isMediaRelationship checks if a relationship is for media (images, video, etc.)
 func isMediaRelationship(rel Relationship) bool {
 	mediaTypes := []string{
@@ -386,59 +498,67 @@ func updateParagraphNumberingIDs(para *Paragraph, numIDMap map[string]string) {
 		return
 	}
 
+	// Paragraph properties may be shared with the parsed fragment, so copy
+	// them before rewriting to keep later includes remappable.
+	var rawXML []RawXMLElement
 	for i := range para.Properties.RawXML {
-		updateRawXMLNumberingIDs(&para.Properties.RawXML[i], numIDMap)
+		updated := para.Properties.RawXML[i]
+		updateRawXMLNumberingIDs(&updated, numIDMap)
+		if rawXML == nil && !bytes.Equal(updated.Content, para.Properties.RawXML[i].Content) {
+			rawXML = append([]RawXMLElement(nil), para.Properties.RawXML...)
+		}
+		if rawXML != nil {
+			rawXML[i] = updated
+		}
+	}
+	if rawXML != nil {
+		props := *para.Properties
+		props.RawXML = rawXML
+		para.Properties = &props
 	}
 }
 
-// updateRunRelationshipIDs updates relationship IDs in a run
+// updateRunRelationshipIDs updates relationship IDs in a run. The RawXML of a
+// rendered run may be shared with the parsed fragment, so it is copied before
+// rewriting.
 func updateRunRelationshipIDs(run *Run, idMap map[string]string) {
 	if run == nil {
 		return
 	}
 
 	// Update RawXML elements (contains images!)
+	var rawXML []RawXMLElement
 	for i := range run.RawXML {
-		updateRawXMLRelationshipIDs(&run.RawXML[i], idMap)
+		updated := run.RawXML[i]
+		updateRawXMLRelationshipIDs(&updated, idMap)
+		if rawXML == nil && !bytes.Equal(updated.Content, run.RawXML[i].Content) {
+			rawXML = append([]RawXMLElement(nil), run.RawXML...)
+		}
+		if rawXML != nil {
+			rawXML[i] = updated
+		}
+	}
+	if rawXML != nil {
+		run.RawXML = rawXML
 	}
 }
 
-// updateRawXMLRelationshipIDs updates relationship IDs in raw XML content
+// updateRawXMLRelationshipIDs updates relationship IDs in raw XML content.
+// All references are rewritten in a single pass so that an ID which is both
+// remapped and the target of another mapping is not rewritten twice.
 func updateRawXMLRelationshipIDs(raw *RawXMLElement, idMap map[string]string) {
-	if raw == nil || len(raw.Content) == 0 {
+	if raw == nil || len(raw.Content) == 0 || len(idMap) == 0 {
 		return
 	}
 
-	content := string(raw.Content)
-
-	// Replace relationship ID references
-	// Note: RawXML stores namespaces as full URIs, not as prefixes
-	// Example: "http://schemas.openxmlformats.org/officeDocument/2006/relationships:embed="rId6""
-	// not "r:embed="rId6""
-	relationshipsNS := "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
-
-	for oldID, newID := range idMap {
-		// Replace with full namespace URI (as stored in RawXML)
-		content = strings.ReplaceAll(content,
-			fmt.Sprintf(`%s:embed="%s"`, relationshipsNS, oldID),
-			fmt.Sprintf(`%s:embed="%s"`, relationshipsNS, newID))
-
-		// Also try r:embed (prefix form, in case it's used)
-		content = strings.ReplaceAll(content,
-			fmt.Sprintf(`r:embed="%s"`, oldID),
-			fmt.Sprintf(`r:embed="%s"`, newID))
-
-		// Replace r:id references (hyperlinks)
-		content = strings.ReplaceAll(content,
-			fmt.Sprintf(`%s:id="%s"`, relationshipsNS, oldID),
-			fmt.Sprintf(`%s:id="%s"`, relationshipsNS, newID))
-
-		content = strings.ReplaceAll(content,
-			fmt.Sprintf(`r:id="%s"`, oldID),
-			fmt.Sprintf(`r:id="%s"`, newID))
-	}
-
-	raw.Content = []byte(content)
+	raw.Content = relationshipIDAttrRegex.ReplaceAllFunc(raw.Content, func(match []byte) []byte {
+		parts := relationshipIDAttrRegex.FindSubmatch(match)
+		newID, ok := idMap[string(parts[2])]
+		if !ok {
+			return match
+		}
+		return []byte(string(parts[1]) + newID + string(parts[3]))
+	})
 }
 
 func updateRawXMLNumberingIDs(raw *RawXMLElement, numIDMap map[string]string) {
@@ -0,0 +1,320 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The sections of an included DOCX fragment refer to its headers and
+// footers by relationship IDs of the fragment package, which mean nothing
+// in the rendered document. By default the references are removed, so the
+// included content shows the headers and footers of the section it is
+// included in. With FragmentHeadersFootersImport the header and footer
+// parts of the fragment are rendered into new parts of the document, and
+// the included content is set apart in sections of its own: the content
+// before the include ends its section, and the included content ends with
+// the last section properties of the fragment.
+
+var (
+	// fragmentSectionMarkerRegex matches the paragraphs put around the
+	// content of a fragment whose headers and footers are imported:
+	// {{FRAGMENT_SECTION_MARKER:start:<n>}} and
+	// {{FRAGMENT_SECTION_MARKER:end:<n>}}.
+	fragmentSectionMarkerRegex = regexp.MustCompile(`^\{\{FRAGMENT_SECTION_MARKER:(start|end):(\d+)\}\}$`)
+
+	headerFooterReferenceRegex = regexp.MustCompile(`(?s)<w:(?:header|footer)Reference\b[^>]*?(?:/>|>.*?</w:(?:header|footer)Reference>)`)
+)
+
+// importedSectionPart is a header or footer part of an included fragment,
+// rendered to be added to the document.
+type importedSectionPart struct {
+	name          string // part name in the rendered document
+	kind          headerFooterKind
+	content       []byte
+	relationships []Relationship
+}
+
+// includeFragmentSections handles the headers and footers the sections of
+// a rendered fragment body refer to, as RenderOptions.FragmentHeadersFooters
+// says, and returns the elements to include.
+func includeFragmentSections(fragmentName string, frag *fragment, body *Body, data TemplateData, ctx *renderContext) ([]BodyElement, error) {
+	var refs []Relationship
+	for _, rel := range frag.relationships {
+		if rel.Type == headerRelationType || rel.Type == footerRelationType {
+			refs = append(refs, rel)
+		}
+	}
+	if len(refs) == 0 {
+		return body.Elements, nil
+	}
+
+	importParts := ctx.options != nil && ctx.options.FragmentHeadersFooters == FragmentHeadersFootersImport
+	if !importParts || ctx.storyPart != "" {
+		if _, seen := ctx.fragmentSectionIDMaps[fragmentName]; !seen {
+			message := fmt.Sprintf("fragment %s: its headers and footers are not included; set RenderOptions.FragmentHeadersFooters to import them", fragmentName)
+			if importParts {
+				message = fmt.Sprintf("fragment %s: headers and footers of fragments included in %s are not imported", fragmentName, ctx.storyPart)
+			}
+			ctx.options.warn(RenderWarning{Code: WarningFragmentHeadersFooters, Fragment: fragmentName, Message: message})
+			ctx.fragmentSectionIDMaps[fragmentName] = nil
+		}
+		return rewriteSectionProperties(body.Elements, func(sectPr string) string {
+			return headerFooterReferenceRegex.ReplaceAllString(sectPr, "")
+		}), nil
+	}
+
+	idMap := ctx.fragmentSectionIDMaps[fragmentName]
+	if idMap == nil {
+		var err error
+		if idMap, err = importFragmentHeadersFooters(fragmentName, frag, refs, data, ctx); err != nil {
+			return nil, err
+		}
+		ctx.fragmentSectionIDMaps[fragmentName] = idMap
+	}
+	elements := rewriteSectionProperties(body.Elements, func(sectPr string) string {
+		raw := RawXMLElement{Content: []byte(sectPr)}
+		updateRawXMLRelationshipIDs(&raw, idMap)
+		return string(raw.Content)
+	})
+
+	ctx.fragmentSections++
+	marker := func(kind string) BodyElement {
+		text := fmt.Sprintf("{{FRAGMENT_SECTION_MARKER:%s:%d}}", kind, ctx.fragmentSections)
+		return &Paragraph{Runs: []Run{{Text: &Text{Content: text}}}}
+	}
+	elements = append([]BodyElement{marker("start")}, elements...)
+	if body.SectionProperties != nil {
+		last := RawXMLElement{Content: []byte(convertNamespaceURIsToPrefix(string(body.SectionProperties.Content)))}
+		updateRawXMLRelationshipIDs(&last, idMap)
+		elements = endSectionWith(elements, string(last.Content))
+	}
+	return append(elements, marker("end")), nil
+}
+
+// rewriteSectionProperties replaces the section properties of the
+// paragraphs among elements that end a section with the result of rewrite,
+// which is given their XML. The paragraphs may be shared with the parsed
+// fragment, so they are replaced, not modified.
+func rewriteSectionProperties(elements []BodyElement, rewrite func(string) string) []BodyElement {
+	for i, elem := range elements {
+		raw := paragraphSectionProperties(elem)
+		if raw == nil {
+			continue
+		}
+		content := convertNamespaceURIsToPrefix(string(raw.Content))
+		updated := rewrite(content)
+		if updated == content {
+			continue
+		}
+		para := *elem.(*Paragraph)
+		props := *para.Properties
+		props.RawXML = append([]RawXMLElement(nil), props.RawXML...)
+		for j := range props.RawXML {
+			if props.RawXML[j].XMLName.Local == "sectPr" {
+				props.RawXML[j] = RawXMLElement{XMLName: raw.XMLName, Attrs: raw.Attrs, Content: []byte(updated)}
+				break
+			}
+		}
+		para.Properties = &props
+		elements[i] = &para
+	}
+	return elements
+}
+
+// importFragmentHeadersFooters renders the header and footer parts refs of
+// a fragment refer to into new parts of the document and returns the
+// mapping from the fragment's relationship IDs to those of the document.
+func importFragmentHeadersFooters(fragmentName string, frag *fragment, refs []Relationship, data TemplateData, ctx *renderContext) (map[string]string, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(frag.docxData), int64(len(frag.docxData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fragment %s as ZIP: %w", fragmentName, err)
+	}
+	files := make(map[string]*zip.File, len(zipReader.File))
+	for _, file := range zipReader.File {
+		files[file.Name] = file
+	}
+	ids, err := ctx.relationshipIDAllocator()
+	if err != nil {
+		return nil, err
+	}
+
+	idMap := make(map[string]string, len(refs))
+	for _, rel := range refs {
+		source := resolveRelationshipTarget("word", rel.Target)
+		file := files[source]
+		if file == nil {
+			ctx.options.warn(RenderWarning{
+				Code:     WarningFragmentHeadersFooters,
+				Fragment: fragmentName,
+				Message:  fmt.Sprintf("fragment %s: %s not found; its sections do not show it", fragmentName, source),
+			})
+			continue
+		}
+		kind := headerFragmentKind
+		if rel.Type == footerRelationType {
+			kind = footerFragmentKind
+		}
+
+		content, err := renderStoryPart(file, data, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s of fragment %s: %w", source, fragmentName, err)
+		}
+		relationships, err := importFragmentPartRelationships(fragmentName, frag, source, files[relationshipsPartFor(source)], ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		name := "word/" + ctx.unusedPartName("", string(kind), 1, "xml")
+		ctx.importedSectionParts = append(ctx.importedSectionParts, importedSectionPart{
+			name:          name,
+			kind:          kind,
+			content:       content,
+			relationships: relationships,
+		})
+		idMap[rel.ID] = ids.allocate()
+		ctx.fragmentRelationships = append(ctx.fragmentRelationships, Relationship{
+			ID:     idMap[rel.ID],
+			Type:   rel.Type,
+			Target: path.Base(name),
+		})
+	}
+	return idMap, nil
+}
+
+// importFragmentPartRelationships returns the relationships of an imported
+// header or footer part, read from its relationships file. The part keeps
+// its relationship IDs; media is copied under a fragment-specific name and
+// external relationships as is. Other relationships are reported with a
+// warning.
+func importFragmentPartRelationships(fragmentName string, frag *fragment, source string, relsFile *zip.File, ctx *renderContext) ([]Relationship, error) {
+	if relsFile == nil {
+		return nil, nil
+	}
+	rc, err := relsFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", relsFile.Name, err)
+	}
+	var relsXML bytes.Buffer
+	_, err = relsXML.ReadFrom(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", relsFile.Name, err)
+	}
+
+	var relationships []Relationship
+	var unsupported []string
+	loadMedia := frag.mediaLoader()
+	prefix := strings.TrimSuffix(path.Base(source), ".xml")
+	for i, rel := range parseRelationships(relsXML.Bytes()) {
+		switch {
+		case rel.TargetMode == "External":
+			relationships = append(relationships, rel)
+		case isMediaRelationship(rel):
+			target := renameMediaPath(rel.Target, fragmentName+"_"+prefix, i+1)
+			if mediaContent, ok := loadMedia(rel.Target); ok {
+				ctx.fragmentMedia[path.Base(target)] = mediaContent
+			}
+			relationships = append(relationships, Relationship{ID: rel.ID, Type: rel.Type, Target: target})
+		default:
+			unsupported = append(unsupported, path.Base(rel.Type))
+		}
+	}
+	if len(unsupported) > 0 {
+		ctx.options.warn(RenderWarning{
+			Code:     WarningFragmentRelationships,
+			Fragment: fragmentName,
+			Message: fmt.Sprintf("fragment %s: relationships of type %s of %s are not copied; content referencing them will not display",
+				fragmentName, strings.Join(unsupported, ", "), source),
+		})
+	}
+	return relationships, nil
+}
+
+// processFragmentSections ends the section before each fragment whose
+// headers and footers are imported, so the text before the include keeps
+// the properties of the section the include is in, and removes the
+// markers around the fragments.
+func processFragmentSections(body *Body) error {
+	if body == nil {
+		return nil
+	}
+	found := false
+	for _, para := range bodyElementParagraphs(body.Elements) {
+		if strings.Contains(para.GetText(), "FRAGMENT_SECTION_MARKER:") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	elements := body.Elements
+	var result []BodyElement
+	for i, elem := range elements {
+		if table, ok := elem.(*Table); ok {
+			for _, para := range bodyElementParagraphs([]BodyElement{table}) {
+				if strings.Contains(para.GetText(), "FRAGMENT_SECTION_MARKER:") {
+					return fmt.Errorf("fragments with imported headers and footers must not be included in a table")
+				}
+			}
+		}
+		para, ok := elem.(*Paragraph)
+		var match []string
+		if ok {
+			match = fragmentSectionMarkerRegex.FindStringSubmatch(para.GetText())
+		}
+		if match == nil {
+			result = append(result, elem)
+			continue
+		}
+		// A fragment at the start of a section needs no break before it
+		if match[1] == "end" || len(result) == 0 || paragraphSectionProperties(result[len(result)-1]) != nil {
+			continue
+		}
+
+		end := len(elements)
+		endText := "{{FRAGMENT_SECTION_MARKER:end:" + match[2] + "}}"
+		for j := i + 1; j < len(elements); j++ {
+			if p, ok := elements[j].(*Paragraph); ok && p.GetText() == endText {
+				end = j
+				break
+			}
+		}
+		result = endSectionWith(result, sectionPropertiesAfter(body, elements[min(end+1, len(elements)):]))
+	}
+	body.Elements = result
+	return nil
+}
+
+// applyImportedSectionParts writes the header and footer parts imported
+// from fragments into a rendered DOCX package. The document relationships
+// referring to them are added with the other fragment relationships.
+func applyImportedSectionParts(output []byte, parts []importedSectionPart) ([]byte, error) {
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+	for _, part := range parts {
+		pkg.set(part.name, part.content)
+		if len(part.relationships) > 0 {
+			relsXML, err := xml.Marshal(&Relationships{
+				Namespace:    relationshipsNamespace,
+				Relationship: part.relationships,
+			})
+			if err != nil {
+				return nil, NewDocumentError("write", relationshipsPartFor(part.name), err)
+			}
+			pkg.set(relationshipsPartFor(part.name), append([]byte(xmlDeclaration), relsXML...))
+		}
+		if err := pkg.ensureContentTypeOverride(part.name, part.kind.contentType()); err != nil {
+			return nil, NewDocumentError("write", part.name, err)
+		}
+	}
+	return pkg.bytes()
+}
@@ -0,0 +1,136 @@
+package stencil
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFragmentHeadersAndFooters(t *testing.T) {
+	mainDoc := createDOCXWithCustomStylesAndBody(t, `
+<w:p><w:r><w:t>Before</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{include "terms"}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>After</w:t></w:r></w:p>
+<w:sectPr><w:pgSz w:w="12240" w:h="15840"/></w:sectPr>`, "")
+
+	tmpl, err := Prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("terms", createFragmentWithHeader(t)); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	data := TemplateData{"title": "Contract terms"}
+
+	t.Run("strip", func(t *testing.T) {
+		var logs bytes.Buffer
+		previous := GetLogger()
+		SetLogger(NewLogger(&logs, LogWarn))
+		defer SetLogger(previous)
+
+		output := renderPreparedWithOptionsToBytes(t, tmpl, data, RenderOptions{ValidateOutput: true})
+		document := extractDocumentXMLFromDOCX(t, output)
+		if !strings.Contains(document, "Terms text") || strings.Contains(document, "headerReference") {
+			t.Errorf("expected the fragment content without header references, got %s", document)
+		}
+		for _, name := range docxPartNames(t, output) {
+			if isHeaderPartName(name) {
+				t.Errorf("expected no header part, got %s", name)
+			}
+		}
+		if !strings.Contains(logs.String(), "fragment terms: its headers and footers are not included") {
+			t.Errorf("expected a warning about the left out header, got %q", logs.String())
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		output := renderPreparedWithOptionsToBytes(t, tmpl, data, RenderOptions{
+			FragmentHeadersFooters: FragmentHeadersFootersImport,
+			ValidateOutput:         true,
+		})
+		document := extractDocumentXMLFromDOCX(t, output)
+		if strings.Contains(document, "FRAGMENT_SECTION_MARKER") {
+			t.Fatalf("expected the section markers to be removed, got %s", document)
+		}
+
+		sections := regexp.MustCompile(`(?s)<w:p>.*?</w:p>|<w:sectPr>.*?</w:sectPr>`).FindAllString(document, -1)
+		var before, terms string
+		for _, section := range sections {
+			switch {
+			case strings.Contains(section, "Before"):
+				before = section
+			case strings.Contains(section, "Terms text"):
+				terms = section
+			}
+		}
+		if !strings.Contains(before, `<w:pgSz w:w="12240" w:h="15840">`) || strings.Contains(before, "headerReference") {
+			t.Errorf("expected the text before the include to end the template's section, got %s", before)
+		}
+		idMatch := regexp.MustCompile(`<w:headerReference w:type="default" r:id="([^"]+)"`).FindStringSubmatch(terms)
+		if idMatch == nil || !strings.Contains(terms, "landscape") {
+			t.Fatalf("expected the fragment content to end its own section with the header, got %s", terms)
+		}
+		if strings.Count(document, "<w:sectPr") != 3 {
+			t.Errorf("expected three sections, got %s", document)
+		}
+
+		rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")
+		if !strings.Contains(rels, `Id="`+idMatch[1]+`" Type="`+headerRelationType+`" Target="header1.xml"`) {
+			t.Errorf("expected the header relationship %s, got %s", idMatch[1], rels)
+		}
+		if header := extractPartFromDOCX(t, output, "word/header1.xml"); !strings.Contains(header, "Contract terms") {
+			t.Errorf("expected the imported header to be rendered, got %s", header)
+		}
+		if types := extractPartFromDOCX(t, output, "[Content_Types].xml"); !strings.Contains(types, `PartName="/word/header1.xml" ContentType="`+headerContentType+`"`) {
+			t.Errorf("expected a content type for the imported header, got %s", types)
+		}
+	})
+}
+
+func TestProcessFragmentSectionsAtStartOfDocument(t *testing.T) {
+	body := &Body{Elements: []BodyElement{
+		&Paragraph{Runs: []Run{{Text: &Text{Content: "{{FRAGMENT_SECTION_MARKER:start:1}}"}}}},
+		&Paragraph{Runs: []Run{{Text: &Text{Content: "Terms"}}}},
+		&Paragraph{Runs: []Run{{Text: &Text{Content: "{{FRAGMENT_SECTION_MARKER:end:1}}"}}}},
+	}}
+	if err := processFragmentSections(body); err != nil {
+		t.Fatalf("processFragmentSections failed: %v", err)
+	}
+	if len(body.Elements) != 1 || paragraphSectionProperties(body.Elements[0]) != nil {
+		t.Errorf("expected only the fragment content without a section break, got %+v", body.Elements)
+	}
+}
+
+// createFragmentWithHeader returns a DOCX fragment whose only section shows
+// a header with a {{title}} tag.
+func createFragmentWithHeader(t *testing.T) []byte {
+	t.Helper()
+	pkg, err := readDocxPackage(createDOCXWithCustomStylesAndBody(t, "", ""))
+	if err != nil {
+		t.Fatalf("failed to read DOCX: %v", err)
+	}
+	pkg.set("word/document.xml", []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <w:body>
+    <w:p><w:r><w:t>Terms text</w:t></w:r></w:p>
+    <w:sectPr><w:headerReference w:type="default" r:id="rId7"/><w:pgSz w:w="15840" w:h="12240" w:orient="landscape"/></w:sectPr>
+  </w:body>
+</w:document>`))
+	pkg.set("word/header1.xml", []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>{{title}}</w:t></w:r></w:p></w:hdr>`))
+	pkg.set(documentRelationshipsPart, []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+  <Relationship Id="rId7" Type="`+headerRelationType+`" Target="header1.xml"/>
+</Relationships>`))
+	if err := pkg.ensureContentTypeOverride("word/header1.xml", headerContentType); err != nil {
+		t.Fatalf("failed to add content type: %v", err)
+	}
+	output, err := pkg.bytes()
+	if err != nil {
+		t.Fatalf("failed to write DOCX: %v", err)
+	}
+	return output
+}
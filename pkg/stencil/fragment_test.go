@@ -3,8 +3,10 @@ package stencil
 import (
 	"archive/zip"
 	"bytes"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -1457,6 +1459,336 @@ func TestFragmentStyleMergingPreservesTableBorders(t *testing.T) {
 	}
 }
 
+func TestAddFragmentFromTemplate(t *testing.T) {
+	mainDoc := createDOCXWithParagraphs(t, []string{
+		`Before`,
+		`{{include "signature"}}`,
+		`After`,
+	})
+	main, err := prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare main template: %v", err)
+	}
+	defer main.Close()
+
+	signature, err := prepare(bytes.NewReader(createFragmentDOCX(t, "Signed by {{name}}")))
+	if err != nil {
+		t.Fatalf("failed to prepare fragment template: %v", err)
+	}
+
+	if err := main.AddFragmentFromTemplate("signature", signature); err != nil {
+		t.Fatalf("failed to add fragment from template: %v", err)
+	}
+	if err := main.AddFragmentFromTemplate("self", main); err == nil {
+		t.Fatalf("expected error when adding a template to itself")
+	}
+
+	// The fragment must stay usable after the source template is closed.
+	signature.Close()
+	if err := main.AddFragmentFromTemplate("closed", signature); err == nil {
+		t.Fatalf("expected error when adding a closed template")
+	}
+
+	reader, err := main.Render(TemplateData{"name": "Jane"})
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	rendered, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+
+	content := extractTextFromDOCX(t, rendered)
+	if !strings.Contains(content, "BeforeSigned by JaneAfter") {
+		t.Fatalf("expected fragment content between paragraphs, got %q", content)
+	}
+}
+
+func TestAddFragmentIfMissing(t *testing.T) {
+	tmpl, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{include "a"}}`, `|`, `{{include "b"}}`})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if err := tmpl.AddFragment("a", "provided"); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	if err := tmpl.AddFragmentIfMissing("a", "fallback"); err != nil {
+		t.Fatalf("failed to add fragment if missing: %v", err)
+	}
+	if err := tmpl.AddFragmentIfMissing("b", "fallback"); err != nil {
+		t.Fatalf("failed to add fragment if missing: %v", err)
+	}
+
+	content := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{}))
+	if content != "provided|fallback" {
+		t.Fatalf("expected registered fragment to win over fallback, got %q", content)
+	}
+}
+
+func TestAddFragmentFromBytesLazy(t *testing.T) {
+	const imageRelType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+	fragment := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:r><w:drawing><a:blip xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" r:embed="rId1"/></w:drawing></w:r></w:p></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="` + imageRelType + `" Target="media/logo.png"/></Relationships>`),
+		"word/media/logo.png": []byte("logo"),
+	})
+	mainDoc := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>{{include "logo"}}</w:t></w:r></w:p></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`),
+	})
+
+	tmpl, err := prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytesLazy("logo", fragment); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	if err := tmpl.AddFragmentFromBytesLazy("unused", fragment); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	if err := tmpl.AddFragmentFromBytesLazy("broken", []byte("not a docx")); err == nil {
+		t.Fatal("expected invalid fragment bytes to be rejected")
+	}
+
+	for render := 0; render < 2; render++ {
+		output := renderPreparedToBytes(t, tmpl, TemplateData{})
+		if media := extractPartFromDOCX(t, output, "word/media/image_logo_1.png"); media != "logo" {
+			t.Fatalf("render %d: expected the fragment's image in the output, got %q", render, media)
+		}
+	}
+
+	frag := tmpl.template.fragments["logo"]
+	if frag.mediaFiles != nil {
+		t.Errorf("expected the media of a lazy fragment not to be held, got %v", frag.mediaFiles)
+	}
+	if &frag.docxData[0] != &fragment[0] {
+		t.Error("expected a lazy fragment to keep a reference to its bytes")
+	}
+	if tmpl.template.fragments["unused"].parsed != nil {
+		t.Error("expected a fragment that is not included not to be prepared")
+	}
+}
+
+func TestRenderOptions_MissingFragments(t *testing.T) {
+	var logs bytes.Buffer
+	previous := GetLogger()
+	SetLogger(NewLogger(&logs, LogWarn))
+	defer SetLogger(previous)
+
+	docx := createDOCXWithParagraphs(t, []string{
+		`Start`,
+		`{{include "tenantSection"}}`,
+		`Inline {{include "tenantNote"}} end`,
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), "fragment not found: tenantSection") {
+		t.Fatalf("expected missing fragment error without options, got %v", err)
+	}
+
+	reader, err := tmpl.RenderWithOptions(TemplateData{}, RenderOptions{SkipMissingFragments: true})
+	if err != nil {
+		t.Fatalf("expected missing fragments to be skipped, got %v", err)
+	}
+	rendered, _ := io.ReadAll(reader)
+	if content := extractTextFromDOCX(t, rendered); content != "StartInline  end" {
+		t.Fatalf("expected includes to be skipped, got %q", content)
+	}
+	if !strings.Contains(logs.String(), "fragment not found: tenantSection; skipping include") {
+		t.Fatalf("expected skip warning, got %q", logs.String())
+	}
+
+	if err := tmpl.AddFragment("placeholder", "n/a"); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	reader, err = tmpl.RenderWithOptions(TemplateData{}, RenderOptions{DefaultFragment: "placeholder"})
+	if err != nil {
+		t.Fatalf("expected default fragment to be used, got %v", err)
+	}
+	rendered, _ = io.ReadAll(reader)
+	if content := extractTextFromDOCX(t, rendered); content != "Startn/aInline n/a end" {
+		t.Fatalf("expected default fragment content, got %q", content)
+	}
+}
+
+func TestFragmentCollectionLoop(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for f in sections}}`,
+		`{{include f}}`,
+		`{{end}}`,
+		`Inline: {{for f in sections}}{{include f}};{{end}}`,
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if err := tmpl.AddFragment("terms", "Terms"); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	if err := tmpl.AddFragmentFromBytes("intro", createFragmentDOCX(t, "Intro")); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	names := tmpl.Fragments()
+	if strings.Join(names, ",") != "intro,terms" {
+		t.Fatalf("expected sorted fragment names, got %v", names)
+	}
+
+	// Reverse the registration order to show the data decides the order.
+	content := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"sections": []interface{}{"terms", "intro"},
+	}))
+	if content != "TermsIntroInline: Terms;Intro;" {
+		t.Fatalf("expected fragments in data order, got %q", content)
+	}
+
+	tmpl.Close()
+	if names := tmpl.Fragments(); names != nil {
+		t.Fatalf("expected no fragments on closed template, got %v", names)
+	}
+}
+
+func TestDOCXFragmentRelationshipIDAllocation(t *testing.T) {
+	const imageRelType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+	blip := func(id string) string {
+		return `<w:r><w:drawing><a:blip xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" r:embed="` + id + `"/></w:drawing></w:r>`
+	}
+
+	// The fragment uses an ID above the old 100-ID range, and its rId1 maps
+	// onto rId2, which is itself remapped.
+	fragment := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p>` +
+			blip("rId1") + blip("rId2") + blip("rId150") + `</w:p></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + imageRelType + `" Target="media/a.png"/>` +
+			`<Relationship Id="rId2" Type="` + imageRelType + `" Target="media/b.png"/>` +
+			`<Relationship Id="rId150" Type="` + imageRelType + `" Target="media/c.png"/>` +
+			`</Relationships>`),
+		"word/media/a.png": []byte("a"),
+		"word/media/b.png": []byte("b"),
+		"word/media/c.png": []byte("c"),
+	})
+
+	// The main template already uses an ID above the old main template range.
+	mainDoc := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:r><w:t>{{include "logo"}}</w:t></w:r></w:p></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`<Relationship Id="rId1000" Type="` + imageRelType + `" Target="media/main.png"/>` +
+			`</Relationships>`),
+	})
+
+	tmpl, err := prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("logo", fragment); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	for render := 0; render < 2; render++ {
+		output := renderPreparedToBytes(t, tmpl, TemplateData{})
+
+		targets := make(map[string]string)
+		for _, rel := range parseRelationships([]byte(extractPartFromDOCX(t, output, "word/_rels/document.xml.rels"))) {
+			if _, exists := targets[rel.ID]; exists {
+				t.Fatalf("duplicate relationship ID %s", rel.ID)
+			}
+			targets[rel.ID] = rel.Target
+		}
+		if targets["rId1"] != "styles.xml" || targets["rId1000"] != "media/main.png" {
+			t.Fatalf("expected main template relationships to keep their IDs, got %v", targets)
+		}
+
+		embeds := regexp.MustCompile(`embed="([^"]+)"`).FindAllStringSubmatch(extractDocumentXMLFromDOCX(t, output), -1)
+		if len(embeds) != 3 {
+			t.Fatalf("expected 3 image references, got %v", embeds)
+		}
+		for i, want := range []string{"media/image_logo_1.png", "media/image_logo_2.png", "media/image_logo_3.png"} {
+			if got := targets[embeds[i][1]]; got != want {
+				t.Fatalf("render %d: image %d references %s -> %q, want %q", render, i, embeds[i][1], got, want)
+			}
+		}
+	}
+}
+
+func TestDOCXFragmentHyperlinkRelationships(t *testing.T) {
+	var logs bytes.Buffer
+	previous := GetLogger()
+	SetLogger(NewLogger(&logs, LogWarn))
+	defer SetLogger(previous)
+
+	fragment := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:hyperlink r:id="rId5"><w:r><w:t>Website</w:t></w:r></w:hyperlink></w:p></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`<Relationship Id="rId5" Type="` + hyperlinkRelationType + `" Target="https://example.com" TargetMode="External"/>` +
+			`<Relationship Id="rId6" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>` +
+			`</Relationships>`),
+	})
+	mainDoc := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:r><w:t>{{include "links"}}</w:t></w:r></w:p></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`),
+	})
+
+	tmpl, err := prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("links", fragment); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{})
+
+	match := regexp.MustCompile(`<w:hyperlink[^>]*:id="([^"]+)"`).FindStringSubmatch(extractDocumentXMLFromDOCX(t, output))
+	if match == nil {
+		t.Fatalf("expected hyperlink in output")
+	}
+	var linkRel *Relationship
+	rels := parseRelationships([]byte(extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")))
+	for i := range rels {
+		if rels[i].ID == match[1] {
+			linkRel = &rels[i]
+		}
+	}
+	if linkRel == nil || linkRel.Type != hyperlinkRelationType || linkRel.Target != "https://example.com" || linkRel.TargetMode != "External" {
+		t.Fatalf("expected hyperlink %s to reference the copied external relationship, got %+v", match[1], rels)
+	}
+	if len(rels) != 2 {
+		t.Fatalf("expected only the hyperlink relationship to be copied, got %+v", rels)
+	}
+	if !strings.Contains(logs.String(), "fragment links: relationships of type comments are not copied") {
+		t.Fatalf("expected unsupported relationship warning, got %q", logs.String())
+	}
+}
+
 func TestFragmentCircularReference(t *testing.T) {
 	// Create template
 	tmpl, err := Parse("test.docx", "{{include \"a\"}}")
@@ -1483,6 +1815,131 @@ func TestFragmentCircularReference(t *testing.T) {
 	if !strings.Contains(err.Error(), "circular") {
 		t.Errorf("error %q does not contain 'circular'", err.Error())
 	}
+	if !strings.Contains(err.Error(), "include chain: a -> b -> a") {
+		t.Errorf("error %q does not report the include chain", err.Error())
+	}
+}
+
+func TestFragmentMaxIncludeDepth(t *testing.T) {
+	originalConfig := GetGlobalConfig()
+	defer SetGlobalConfig(originalConfig)
+	config := *originalConfig
+	config.MaxRenderDepth = 100
+	config.MaxIncludeDepth = 2
+	SetGlobalConfig(&config)
+
+	tmpl, err := prepare(bytes.NewReader(createSimpleDOCX(t, `{{include "a"}}`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	for name, content := range map[string]string{
+		"a": `A {{include "b"}}`,
+		"b": `B {{include "c"}}`,
+		"c": `C`,
+	} {
+		if err := tmpl.AddFragment(name, content); err != nil {
+			t.Fatalf("failed to add fragment %s: %v", name, err)
+		}
+	}
+
+	_, err = tmpl.Render(TemplateData{})
+	if err == nil || !strings.Contains(err.Error(), "maximum include depth exceeded: 2 (include chain: a -> b -> c)") {
+		t.Fatalf("expected include depth error with chain, got %v", err)
+	}
+
+	config.MaxIncludeDepth = 3
+	SetGlobalConfig(&config)
+	if content := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{})); content != "A B C" {
+		t.Fatalf("expected nested includes within the limit to render, got %q", content)
+	}
+}
+
+func TestFragmentMaxIncludeDepthFromEngineConfig(t *testing.T) {
+	prepareNested := func(engine *Engine) *PreparedTemplate {
+		t.Helper()
+		tmpl, err := engine.Prepare(bytes.NewReader(createSimpleDOCX(t, `{{include "a"}}`)))
+		if err != nil {
+			t.Fatalf("failed to prepare template: %v", err)
+		}
+		if err := tmpl.AddFragment("a", `A {{include "b"}}`); err != nil {
+			t.Fatalf("failed to add fragment a: %v", err)
+		}
+		if err := tmpl.AddFragment("b", `B`); err != nil {
+			t.Fatalf("failed to add fragment b: %v", err)
+		}
+		return tmpl
+	}
+
+	limited := prepareNested(NewWithConfig(&Config{MaxIncludeDepth: 1}))
+	defer limited.Close()
+	_, err := limited.Render(TemplateData{})
+	if err == nil || !strings.Contains(err.Error(), "maximum include depth exceeded: 1 (include chain: a -> b)") {
+		t.Fatalf("expected the engine's include depth limit to apply, got %v", err)
+	}
+
+	allowed := prepareNested(NewWithConfig(&Config{MaxIncludeDepth: 2}))
+	defer allowed.Close()
+	if content := extractTextFromDOCX(t, renderPreparedToBytes(t, allowed, TemplateData{})); content != "A B" {
+		t.Fatalf("expected nested includes within the engine's limit to render, got %q", content)
+	}
+}
+
+func TestFragmentErrorReportsIncludeContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invoice.docx")
+	if err := os.WriteFile(path, createDOCXWithParagraphs(t, []string{
+		`{{for order in orders}}`,
+		`{{include "order"}}`,
+		`{{end}}`,
+	}), 0o600); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	tmpl, err := New().PrepareFile(path)
+	if err != nil {
+		t.Fatalf("PrepareFile failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	fragments := map[string]string{
+		"order": `{{for i, line in order.lines}}{{include "line"}}{{end}}`,
+		"line":  `{{line.qty > 1}}`,
+	}
+	for name, content := range fragments {
+		if err := tmpl.AddFragment(name, content); err != nil {
+			t.Fatalf("failed to add fragment %s: %v", name, err)
+		}
+	}
+
+	_, err = tmpl.Render(TemplateData{"orders": []interface{}{
+		map[string]interface{}{"id": 7, "lines": []interface{}{
+			map[string]interface{}{"qty": "two"},
+		}},
+	}})
+	var fragmentErr *FragmentError
+	if !errors.As(err, &fragmentErr) {
+		t.Fatalf("expected a FragmentError, got %v", err)
+	}
+	if got := strings.Join(fragmentErr.IncludeChain, " -> "); got != "order -> line" {
+		t.Errorf("IncludeChain = %s", got)
+	}
+	if fragmentErr.Template != path {
+		t.Errorf("Template = %q, want %q", fragmentErr.Template, path)
+	}
+	for _, want := range []string{
+		"failed to render fragment line (include chain: order -> line; template: " + path + "; loop variables: ",
+		`i = 0`,
+		`line = map[qty:two]`,
+		`order = map[id:7`,
+		"cannot compare",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err.Error(), want)
+		}
+	}
+	if strings.Count(err.Error(), "failed to render fragment") != 1 {
+		t.Errorf("error %q wraps the fragment more than once", err.Error())
+	}
 }
 
 // Helper functions
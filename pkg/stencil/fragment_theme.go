@@ -0,0 +1,371 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strings"
+)
+
+// themePartName is the theme part of a document. A document has one theme,
+// so the theme of an included fragment is not copied; references to theme
+// fonts and colors the template's theme defines differently are replaced by
+// the fragment's values instead.
+const themePartName = "word/theme/theme1.xml"
+
+var (
+	themeColorSlotRegex   = regexp.MustCompile(`(?s)<a:(dk1|lt1|dk2|lt2|accent[1-6]|hlink|folHlink)>(.*?)</a:`)
+	themeColorValueRegex  = regexp.MustCompile(`<a:srgbClr\b[^>]*\bval="([0-9A-Fa-f]{6})"|\blastClr="([0-9A-Fa-f]{6})"`)
+	themeReferenceElement = regexp.MustCompile(`<w:(\w+)\b[^>]*\bw:(?:asciiTheme|hAnsiTheme|eastAsiaTheme|csTheme|themeColor|themeFill)="[^>]*>`)
+	wordAttributeRegex    = regexp.MustCompile(`\s+w:(\w+)="([^"]*)"`)
+)
+
+// themeColorSlots maps the theme colors WordprocessingML refers to, such as
+// "text1", to the colors of the theme's color scheme, such as "dk1", with the
+// default color mapping.
+var themeColorSlots = map[string]string{
+	"dark1": "dk1", "light1": "lt1", "dark2": "dk2", "light2": "lt2",
+	"text1": "dk1", "background1": "lt1", "text2": "dk2", "background2": "lt2",
+	"accent1": "accent1", "accent2": "accent2", "accent3": "accent3",
+	"accent4": "accent4", "accent5": "accent5", "accent6": "accent6",
+	"hyperlink": "hlink", "followedHyperlink": "folHlink",
+}
+
+// themeFontRefs are the theme font references of w:rFonts.
+var themeFontRefs = []string{
+	"majorAscii", "majorHAnsi", "majorEastAsia", "majorBidi",
+	"minorAscii", "minorHAnsi", "minorEastAsia", "minorBidi",
+}
+
+// documentTheme holds the fonts and colors of a theme part.
+type documentTheme struct {
+	// fonts maps a font group and script, such as "minor/latin", to its
+	// typeface.
+	fonts map[string]string
+	// colors maps a color of the color scheme, such as "accent1", to its
+	// RRGGBB value.
+	colors map[string]string
+}
+
+// parseDocumentTheme reads the fonts and colors of a theme part. It returns
+// nil for an empty part.
+func parseDocumentTheme(themeXML []byte) *documentTheme {
+	if len(themeXML) == 0 {
+		return nil
+	}
+	theme := &documentTheme{fonts: parseThemeFonts(themeXML), colors: make(map[string]string)}
+	for _, match := range themeColorSlotRegex.FindAllSubmatch(themeXML, -1) {
+		if value := themeColorValueRegex.FindSubmatch(match[2]); value != nil {
+			theme.colors[string(match[1])] = strings.ToUpper(string(value[1]) + string(value[2]))
+		}
+	}
+	return theme
+}
+
+// parseThemeFonts returns the typefaces of the major and minor fonts of a
+// theme part by group and script, such as "minor/latin".
+func parseThemeFonts(themeXML []byte) map[string]string {
+	fonts := make(map[string]string)
+	for _, match := range themeFontRegex.FindAllSubmatch(themeXML, -1) {
+		for _, script := range themeScriptFontRegex.FindAllSubmatch(match[2], -1) {
+			key := string(match[1]) + "/" + string(script[1])
+			if _, ok := fonts[key]; !ok {
+				fonts[key] = string(script[2])
+			}
+		}
+	}
+	return fonts
+}
+
+// fragmentThemeRemap holds the theme fonts and colors of a fragment that
+// the template's theme defines differently.
+type fragmentThemeRemap struct {
+	// fonts maps a theme font reference, such as "minorHAnsi", to the
+	// fragment's typeface.
+	fonts map[string]string
+	// colors maps a theme color, such as "accent1", to the fragment's
+	// RRGGBB value.
+	colors map[string]string
+}
+
+// buildFragmentThemeRemap compares the theme of a fragment with the theme
+// of the template. A template without a theme uses Word's built-in one, so
+// all the fragment's theme fonts and colors are remapped.
+func buildFragmentThemeRemap(mainTheme, fragmentTheme *documentTheme) (fragmentThemeRemap, bool) {
+	if fragmentTheme == nil {
+		return fragmentThemeRemap{}, false
+	}
+	if mainTheme == nil {
+		mainTheme = &documentTheme{}
+	}
+
+	remap := fragmentThemeRemap{fonts: make(map[string]string), colors: make(map[string]string)}
+	for _, ref := range themeFontRefs {
+		key := themeFontKey(ref)
+		if typeface := fragmentTheme.fonts[key]; typeface != "" && typeface != mainTheme.fonts[key] {
+			remap.fonts[ref] = typeface
+		}
+	}
+	for name, slot := range themeColorSlots {
+		if value := fragmentTheme.colors[slot]; value != "" && value != mainTheme.colors[slot] {
+			remap.colors[name] = value
+		}
+	}
+
+	if len(remap.fonts) == 0 && len(remap.colors) == 0 {
+		return fragmentThemeRemap{}, false
+	}
+	return remap, true
+}
+
+// remapStylesThemeReferences replaces the references to remapped theme
+// fonts and colors in the styles of a fragment by the fragment's values.
+func remapStylesThemeReferences(stylesXML []byte, remap fragmentThemeRemap) []byte {
+	return themeReferenceElement.ReplaceAllFunc(stylesXML, func(element []byte) []byte {
+		return []byte(remapThemeElement(string(element), remap))
+	})
+}
+
+// remapThemeElement rewrites the attributes of an element referring to the
+// theme: theme font references become typefaces, and theme colors become
+// colors, dropping their tint and shade since the value attribute Word
+// writes along with them already has them applied.
+func remapThemeElement(element string, remap fragmentThemeRemap) string {
+	match := themeReferenceElement.FindStringSubmatch(element)
+	if match == nil {
+		return element
+	}
+	name := match[1]
+
+	var names []string
+	values := make(map[string]string)
+	for _, attr := range wordAttributeRegex.FindAllStringSubmatch(element, -1) {
+		if _, ok := values[attr[1]]; !ok {
+			names = append(names, attr[1])
+		}
+		values[attr[1]] = attr[2]
+	}
+	set := func(attr, value string) {
+		if _, ok := values[attr]; !ok {
+			names = append(names, attr)
+		}
+		values[attr] = value
+	}
+	remove := func(attrs ...string) {
+		for _, attr := range attrs {
+			delete(values, attr)
+		}
+	}
+
+	changed := false
+	for _, slot := range []string{"ascii", "hAnsi", "eastAsia", "cs"} {
+		if typeface, ok := remap.fonts[values[slot+"Theme"]]; ok {
+			set(slot, escapeXMLText(typeface))
+			remove(slot + "Theme")
+			changed = true
+		}
+	}
+	colorAttr := "color"
+	if name == "color" {
+		colorAttr = "val"
+	}
+	for _, theme := range []struct{ attr, value, tint, shade string }{
+		{attr: "themeColor", value: colorAttr, tint: "themeTint", shade: "themeShade"},
+		{attr: "themeFill", value: "fill", tint: "themeFillTint", shade: "themeFillShade"},
+	} {
+		color, ok := remap.colors[values[theme.attr]]
+		if !ok {
+			continue
+		}
+		if current := values[theme.value]; current == "" || current == "auto" {
+			set(theme.value, color)
+		}
+		remove(theme.attr, theme.tint, theme.shade)
+		changed = true
+	}
+	if !changed {
+		return element
+	}
+
+	var rebuilt strings.Builder
+	rebuilt.WriteString("<w:" + name)
+	for _, attr := range names {
+		if value, ok := values[attr]; ok {
+			rebuilt.WriteString(" w:" + attr + `="` + value + `"`)
+		}
+	}
+	if strings.HasSuffix(element, "/>") {
+		rebuilt.WriteString("/>")
+	} else {
+		rebuilt.WriteString(">")
+	}
+	return rebuilt.String()
+}
+
+// fragmentThemeFonts resolves the theme fonts in effect for the content of
+// a fragment through its styles and document defaults.
+type fragmentThemeFonts struct {
+	remap    fragmentThemeRemap
+	catalog  *styleFontCatalog
+	defaults *Font
+}
+
+// newFragmentThemeFonts reads the fonts of the styles and document defaults
+// of a fragment.
+func newFragmentThemeFonts(stylesXML []byte, remap fragmentThemeRemap) *fragmentThemeFonts {
+	resolver := &fragmentThemeFonts{remap: remap}
+	if len(stylesXML) == 0 {
+		return resolver
+	}
+	if catalog, err := parseStyleFontCatalog(stylesXML); err == nil {
+		resolver.catalog = catalog
+	}
+	var defaults struct {
+		Font *Font `xml:"rPrDefault>rPr>rFonts"`
+	}
+	if docDefaults := docDefaultsRegex.Find(stylesXML); docDefaults != nil && xml.Unmarshal(docDefaults, &defaults) == nil {
+		resolver.defaults = defaults.Font
+	}
+	return resolver
+}
+
+// applyFragmentThemeRemap makes the rendered content of a fragment show the
+// fragment's theme fonts and colors: runs whose font comes from a remapped
+// theme font get the fragment's typeface, and cells shaded with a remapped
+// theme color get the fragment's color.
+func applyFragmentThemeRemap(elements []BodyElement, fonts *fragmentThemeFonts) {
+	for _, element := range elements {
+		switch el := element.(type) {
+		case *Paragraph:
+			fonts.applyParagraph(el)
+		case *Table:
+			for rowIdx := range el.Rows {
+				for cellIdx := range el.Rows[rowIdx].Cells {
+					cell := &el.Rows[rowIdx].Cells[cellIdx]
+					if cell.Properties != nil && cell.Properties.Shading != nil {
+						remapShading(cell.Properties.Shading, fonts.remap)
+					}
+					for paraIdx := range cell.Paragraphs {
+						fonts.applyParagraph(&cell.Paragraphs[paraIdx])
+					}
+				}
+			}
+		}
+	}
+}
+
+func remapShading(shading *Shading, remap fragmentThemeRemap) {
+	color, ok := remap.colors[shading.ThemeFill]
+	if !ok {
+		return
+	}
+	if shading.Fill == "" || shading.Fill == "auto" {
+		shading.Fill = color
+	}
+	shading.ThemeFill = ""
+}
+
+func (f *fragmentThemeFonts) applyParagraph(para *Paragraph) {
+	if para == nil {
+		return
+	}
+
+	styleID := ""
+	if para.Properties != nil && para.Properties.Style != nil {
+		styleID = para.Properties.Style.Val
+	} else if f.catalog != nil {
+		styleID = f.catalog.defaultParagraph
+	}
+	paragraphFonts := []*Font{f.defaults, f.catalog.effectiveFont(styleID)}
+
+	if para.Properties != nil && para.Properties.RunProperties != nil {
+		f.applyRunProperties(para.Properties.RunProperties, paragraphFonts)
+	}
+	applyRun := func(run *Run) {
+		if run.Properties != nil {
+			f.applyRunProperties(run.Properties, paragraphFonts)
+			return
+		}
+		if props := (&RunProperties{}); f.applyRunProperties(props, paragraphFonts) {
+			run.Properties = props
+		}
+	}
+	for idx := range para.Runs {
+		applyRun(&para.Runs[idx])
+	}
+	for idx := range para.Hyperlinks {
+		for runIdx := range para.Hyperlinks[idx].Runs {
+			applyRun(&para.Hyperlinks[idx].Runs[runIdx])
+		}
+	}
+	for _, content := range para.Content {
+		switch item := content.(type) {
+		case *Run:
+			applyRun(item)
+		case *Hyperlink:
+			for runIdx := range item.Runs {
+				applyRun(&item.Runs[runIdx])
+			}
+		}
+	}
+}
+
+// applyRunProperties sets the fragment's typeface for each script whose
+// font comes from a remapped theme font, from the most specific of the run
+// properties, its character style, the paragraph style and the document
+// defaults that sets it, and reports whether it set any.
+func (f *fragmentThemeFonts) applyRunProperties(props *RunProperties, paragraphFonts []*Font) bool {
+	levels := append([]*Font(nil), paragraphFonts...)
+	if props.Style != nil {
+		levels = append(levels, f.catalog.effectiveFont(props.Style.Val))
+	}
+	levels = append(levels, props.Font)
+
+	changed := false
+	for _, slot := range []struct {
+		explicit, theme func(*Font) *string
+	}{
+		{func(font *Font) *string { return &font.ASCII }, func(font *Font) *string { return &font.ASCIITheme }},
+		{func(font *Font) *string { return &font.HAnsi }, func(font *Font) *string { return &font.HAnsiTheme }},
+		{func(font *Font) *string { return &font.EastAsia }, func(font *Font) *string { return &font.EastAsiaTheme }},
+		{func(font *Font) *string { return &font.CS }, func(font *Font) *string { return &font.CSTheme }},
+	} {
+		ref := ""
+		for _, font := range levels {
+			if font == nil {
+				continue
+			}
+			if theme := *slot.theme(font); theme != "" {
+				ref = theme
+			} else if *slot.explicit(font) != "" {
+				ref = ""
+			}
+		}
+		typeface, ok := f.remap.fonts[ref]
+		if !ok {
+			continue
+		}
+		if props.Font == nil {
+			props.Font = &Font{}
+		}
+		*slot.explicit(props.Font) = typeface
+		*slot.theme(props.Font) = ""
+		changed = true
+	}
+	return changed
+}
+
+// fragmentThemeFontsFor returns the theme font resolver of a fragment for
+// the render, or nil when its theme matches the template's.
+func (ctx *renderContext) fragmentThemeFontsFor(fragmentName string, frag *fragment) *fragmentThemeFonts {
+	if themeFonts, ok := ctx.fragmentThemeFonts[fragmentName]; ok {
+		return themeFonts
+	}
+	var themeFonts *fragmentThemeFonts
+	if remap, ok := buildFragmentThemeRemap(ctx.mainTheme, frag.theme); ok {
+		themeFonts = newFragmentThemeFonts(frag.stylesXML, remap)
+	}
+	if ctx.fragmentThemeFonts != nil {
+		ctx.fragmentThemeFonts[fragmentName] = themeFonts
+	}
+	return themeFonts
+}
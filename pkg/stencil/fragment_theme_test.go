@@ -0,0 +1,143 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDOCXFragmentKeepsItsThemeFontsAndColors(t *testing.T) {
+	mainDoc := withThemePart(t, createDOCXWithCustomStylesAndBody(t, `
+<w:p>
+  <w:r><w:t>Main text</w:t></w:r>
+</w:p>
+<w:p>
+  <w:r><w:t>{{include "brand"}}</w:t></w:r>
+</w:p>`, `
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:asciiTheme="minorHAnsi" w:hAnsiTheme="minorHAnsi"/></w:rPr></w:rPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>`),
+		"Calibri Light", "Calibri", "4472C4")
+
+	fragmentDoc := withThemePart(t, createDOCXWithCustomStylesAndBody(t, `
+<w:p>
+  <w:r><w:t>Brand text</w:t></w:r>
+</w:p>
+<w:p>
+  <w:r><w:rPr><w:rFonts w:ascii="Arial" w:hAnsi="Arial"/></w:rPr><w:t>Arial text</w:t></w:r>
+</w:p>
+<w:p>
+  <w:pPr><w:pStyle w:val="BrandCallout"/></w:pPr>
+  <w:r><w:t>Callout</w:t></w:r>
+</w:p>
+<w:tbl>
+  <w:tr><w:tc>
+    <w:tcPr><w:shd w:val="clear" w:color="auto" w:fill="auto" w:themeFill="accent1"/></w:tcPr>
+    <w:p><w:r><w:t>Cell</w:t></w:r></w:p>
+  </w:tc></w:tr>
+</w:tbl>`, `
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:asciiTheme="minorHAnsi" w:hAnsiTheme="minorHAnsi"/></w:rPr></w:rPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="BrandCallout">
+  <w:name w:val="Brand Callout"/>
+  <w:rPr><w:rFonts w:asciiTheme="majorHAnsi" w:hAnsiTheme="majorHAnsi"/><w:color w:val="C00000" w:themeColor="accent1" w:themeShade="BF"/></w:rPr>
+</w:style>`),
+		"Georgia", "Verdana", "E00000")
+
+	tmpl, err := Prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("brand", fragmentDoc); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{})
+	doc, err := ParseDocument(bytes.NewReader([]byte(extractDocumentXMLFromDOCX(t, output))))
+	if err != nil {
+		t.Fatalf("failed to parse rendered document: %v", err)
+	}
+
+	fonts := make(map[string]*Font)
+	var cell *TableCell
+	for _, element := range doc.Body.Elements {
+		switch el := element.(type) {
+		case *Paragraph:
+			if len(el.Runs) > 0 && el.Runs[0].Properties != nil {
+				fonts[el.GetText()] = el.Runs[0].Properties.Font
+			} else {
+				fonts[el.GetText()] = nil
+			}
+		case *Table:
+			cell = &el.Rows[0].Cells[0]
+		}
+	}
+
+	if font := fonts["Main text"]; font != nil {
+		t.Errorf("expected the template's text to keep its theme font, got %+v", font)
+	}
+	if font := fonts["Brand text"]; font == nil || font.ASCII != "Verdana" || font.HAnsi != "Verdana" || font.ASCIITheme != "" {
+		t.Errorf("expected the fragment's minor font on its text, got %+v", font)
+	}
+	if font := fonts["Arial text"]; font == nil || font.ASCII != "Arial" {
+		t.Errorf("expected explicit fonts to be kept, got %+v", font)
+	}
+	if font := fonts["Callout"]; font == nil || font.ASCII != "Georgia" {
+		t.Errorf("expected the fragment's major font from its style, got %+v", font)
+	}
+	if cell == nil || cell.Properties == nil || cell.Properties.Shading == nil || cell.Properties.Shading.Fill != "E00000" || cell.Properties.Shading.ThemeFill != "" {
+		t.Errorf("expected the cell shading in the fragment's accent color, got %+v", cell)
+	}
+
+	styles := extractPartFromDOCX(t, output, "word/styles.xml")
+	if !strings.Contains(styles, `<w:rFonts w:ascii="Georgia" w:hAnsi="Georgia"/>`) || !strings.Contains(styles, `<w:color w:val="C00000"/>`) {
+		t.Errorf("expected the added style to refer to the fragment's font and color, got %s", styles)
+	}
+	if strings.Count(styles, `w:asciiTheme="minorHAnsi"`) != 1 {
+		t.Errorf("expected the template's document defaults to be kept, got %s", styles)
+	}
+}
+
+func TestBuildFragmentThemeRemapSkipsMatchingThemes(t *testing.T) {
+	theme := parseDocumentTheme(testThemeXML("Calibri Light", "Calibri", "4472C4"))
+	if _, ok := buildFragmentThemeRemap(theme, parseDocumentTheme(testThemeXML("Calibri Light", "Calibri", "4472C4"))); ok {
+		t.Error("expected no remap for identical themes")
+	}
+	if _, ok := buildFragmentThemeRemap(theme, nil); ok {
+		t.Error("expected no remap for a fragment without a theme")
+	}
+
+	remap, ok := buildFragmentThemeRemap(theme, parseDocumentTheme(testThemeXML("Calibri Light", "Calibri", "70AD47")))
+	if !ok || len(remap.fonts) != 0 || remap.colors["accent1"] != "70AD47" || remap.colors["text1"] != "" {
+		t.Errorf("expected only accent1 to be remapped, got %+v", remap)
+	}
+}
+
+func withThemePart(t *testing.T, docx []byte, majorFont, minorFont, accent1 string) []byte {
+	t.Helper()
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		t.Fatalf("failed to read DOCX: %v", err)
+	}
+	pkg.set(themePartName, testThemeXML(majorFont, minorFont, accent1))
+	output, err := pkg.bytes()
+	if err != nil {
+		t.Fatalf("failed to write DOCX: %v", err)
+	}
+	return output
+}
+
+func testThemeXML(majorFont, minorFont, accent1 string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<a:theme xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" name="Test"><a:themeElements>
+<a:clrScheme name="Test">
+<a:dk1><a:sysClr val="windowText" lastClr="000000"/></a:dk1><a:lt1><a:sysClr val="window" lastClr="FFFFFF"/></a:lt1>
+<a:dk2><a:srgbClr val="44546A"/></a:dk2><a:lt2><a:srgbClr val="E7E6E6"/></a:lt2>
+<a:accent1><a:srgbClr val="` + accent1 + `"/></a:accent1><a:accent2><a:srgbClr val="ED7D31"/></a:accent2>
+</a:clrScheme>
+<a:fontScheme name="Test">
+<a:majorFont><a:latin typeface="` + majorFont + `"/><a:ea typeface=""/><a:cs typeface=""/></a:majorFont>
+<a:minorFont><a:latin typeface="` + minorFont + `"/><a:ea typeface=""/><a:cs typeface=""/></a:minorFont>
+</a:fontScheme>
+</a:themeElements></a:theme>`)
+}
@@ -0,0 +1,201 @@
+package stencil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+)
+
+// FrozenTemplate is an immutable snapshot of a PreparedTemplate and its
+// fragments, created with PreparedTemplate.Freeze. All fragments are resolved
+// and parsed and the render plans are compiled up front, so rendering never
+// calls a fragment resolver. Parts of the template that rendering does not
+// change are copied into the output still compressed, which makes rendering
+// considerably faster than with the PreparedTemplate.
+//
+// A FrozenTemplate is safe for concurrent use and is not affected by later
+// changes to, or closing of, the PreparedTemplate it was created from.
+type FrozenTemplate struct {
+	template *template
+	registry FunctionRegistry
+	data     *engineData
+}
+
+// Freeze returns an immutable snapshot of the template for high-throughput
+// rendering. Fragments included by a literal name, also from within other
+// fragments, are resolved through the fragment resolver first; fragments
+// included by a computed name must already be registered. Freeze fails if a
+// fragment cannot be resolved or parsed.
+//
+// Example:
+//
+//	frozen, err := template.Freeze()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	// frozen can now be shared by any number of goroutines.
+//	output, err := frozen.Render(data)
+func (pt *PreparedTemplate) Freeze() (*FrozenTemplate, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return nil, errTemplateClosed
+	}
+	tmpl := pt.template
+
+	resources, err := tmpl.ensureRenderResources()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare template render resources: %w", err)
+	}
+	if err := tmpl.resolveStaticIncludes(resources.mainStylesXML); err != nil {
+		return nil, err
+	}
+
+	fragments, headerFragment, footerFragment := tmpl.snapshotFragments()
+	names := make([]string, 0, len(fragments))
+	for name := range fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fragments[name].ensurePrepared(resources.mainStylesXML); err != nil {
+			return nil, fmt.Errorf("failed to prepare fragment %s: %w", name, err)
+		}
+	}
+
+	tmpl.mu.RLock()
+	frozen := &template{
+		name:                tmpl.name,
+		docxReader:          tmpl.docxReader,
+		document:            tmpl.document,
+		source:              tmpl.source,
+		fragments:           fragments,
+		headerFragment:      headerFragment,
+		footerFragment:      footerFragment,
+		copyCompressedParts: true,
+		arithmeticPolicy:    tmpl.arithmeticPolicy,
+		concatenationPolicy: tmpl.concatenationPolicy,
+		maxIncludeDepth:     tmpl.maxIncludeDepth,
+		documentReplaced:    tmpl.documentReplaced,
+		clauses:             tmpl.clauses,
+		macros:              tmpl.macros,
+		macroLibrary:        tmpl.macroLibrary,
+		mapping:             tmpl.mapping.retain(),
+	}
+	tmpl.mu.RUnlock()
+
+	// The frozen template gets its own merged styles cache so that fragment
+	// changes on the prepared template do not invalidate it.
+	frozen.renderResources = &templateRenderResources{
+		mainNamespaces:    resources.mainNamespaces,
+		mainStylesXML:     resources.mainStylesXML,
+		mainTheme:         resources.mainTheme,
+		baseNumbering:     resources.baseNumbering,
+		staticParts:       resources.staticParts,
+		dynamicParts:      resources.dynamicParts,
+		mergedStylesCache: make(map[string]mergedStyles),
+		bodyPlans:         resources.bodyPlans,
+		paragraphPlans:    resources.paragraphPlans,
+	}
+
+	ft := &FrozenTemplate{
+		template: frozen,
+		registry: pt.registry,
+		data:     pt.data,
+	}
+	if frozen.mapping != nil {
+		// A frozen template has no Close, so the mapping of its source is
+		// released once the template is unreachable
+		runtime.SetFinalizer(ft, func(ft *FrozenTemplate) {
+			ft.template.mapping.release()
+		})
+	}
+	return ft, nil
+}
+
+// resolveStaticIncludes resolves every fragment the template includes by a
+// literal name, following includes inside those fragments, so that they are
+// registered and parsed.
+func (t *template) resolveStaticIncludes(mainStylesXML []byte) error {
+	spans, err := scanDOCXTokenSpans(t.source)
+	if err != nil {
+		return err
+	}
+
+	visited := make(map[string]bool)
+	var visit func(spans []tokenSpan) error
+	visit = func(spans []tokenSpan) error {
+		for _, span := range spans {
+			name, ok := staticIncludeName(span)
+			if !ok || visited[name] {
+				continue
+			}
+			visited[name] = true
+
+			frag, err := t.resolveFragment(name)
+			if err != nil {
+				return fmt.Errorf("failed to resolve fragment %s: %w", name, err)
+			}
+			if frag == nil {
+				// Missing fragments are reported when rendering, where
+				// DefaultFragment and SkipMissingFragments apply.
+				continue
+			}
+			fragmentSpans, err := scanFragmentTokenSpans(name, frag, mainStylesXML)
+			if err != nil {
+				return fmt.Errorf("failed to prepare fragment %s: %w", name, err)
+			}
+			if err := visit(fragmentSpans); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(spans)
+}
+
+// Render executes the frozen template with the given data.
+func (ft *FrozenTemplate) Render(data TemplateData) (io.Reader, error) {
+	return ft.RenderWithOptions(data, RenderOptions{})
+}
+
+// RenderWithOptions executes the frozen template like Render, applying the
+// given render options.
+func (ft *FrozenTemplate) RenderWithOptions(data TemplateData, opts RenderOptions) (io.Reader, error) {
+	if ft == nil || ft.template == nil {
+		return nil, NewTemplateError("invalid or nil template", 0, 0)
+	}
+
+	output, err := renderTemplatePackage(ft.template, ft.registry, ft.data, data, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err = applyRenderPackageOptions(output, ft.template.source, data, &opts)
+	runtime.KeepAlive(ft)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(output), nil
+}
+
+// Fragments returns the names of the fragments in the snapshot in sorted
+// order.
+func (ft *FrozenTemplate) Fragments() []string {
+	if ft == nil || ft.template == nil {
+		return nil
+	}
+	names := make([]string, 0, len(ft.template.fragments))
+	for name := range ft.template.fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,143 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFreezeResolvesAndIsolatesFragments(t *testing.T) {
+	prepared, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`{{include "intro"}}`,
+		`{{include name}}`,
+	})))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer prepared.Close()
+
+	var resolverCalls []string
+	resolved := map[string][]byte{
+		"intro":  createFragmentDOCX(t, `Intro {{include "nested"}}`),
+		"nested": []byte("nested"),
+	}
+	prepared.SetFragmentResolver(FragmentResolverFunc(func(name string) ([]byte, error) {
+		resolverCalls = append(resolverCalls, name)
+		return resolved[name], nil
+	}))
+	if err := prepared.AddFragment("dynamic", "Dynamic"); err != nil {
+		t.Fatalf("AddFragment failed: %v", err)
+	}
+
+	frozen, err := prepared.Freeze()
+	if err != nil {
+		t.Fatalf("Freeze failed: %v", err)
+	}
+	if got := strings.Join(frozen.Fragments(), ","); got != "dynamic,intro,nested" {
+		t.Fatalf("expected resolved fragments in snapshot, got %q", got)
+	}
+
+	// Changes to the prepared template after freezing are not visible.
+	if err := prepared.AddFragment("dynamic", "Changed"); err != nil {
+		t.Fatalf("AddFragment failed: %v", err)
+	}
+	prepared.Close()
+	callsAtFreeze := len(resolverCalls)
+
+	render := func() string {
+		output, err := frozen.Render(TemplateData{"name": "dynamic"})
+		if err != nil {
+			t.Fatalf("frozen render failed: %v", err)
+		}
+		content, err := io.ReadAll(output)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		return extractTextFromDOCX(t, content)
+	}
+	if text := render(); text != "Intro nestedDynamic" {
+		t.Fatalf("unexpected frozen output %q", text)
+	}
+	if len(resolverCalls) != callsAtFreeze {
+		t.Fatalf("frozen render must not call the resolver, got calls %v", resolverCalls[callsAtFreeze:])
+	}
+
+	if _, err := frozen.Render(TemplateData{"name": "unknown"}); err == nil {
+		t.Fatalf("expected error for fragment missing from the snapshot")
+	}
+}
+
+func TestFreezeFailsOnResolverError(t *testing.T) {
+	prepared, err := prepare(bytes.NewReader(createSimpleDOCX(t, `{{include "broken"}}`)))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer prepared.Close()
+	prepared.SetFragmentResolver(FragmentResolverFunc(func(name string) ([]byte, error) {
+		return nil, errors.New("storage offline")
+	}))
+
+	if _, err := prepared.Freeze(); err == nil || !strings.Contains(err.Error(), "storage offline") {
+		t.Fatalf("expected resolver error, got %v", err)
+	}
+
+	prepared.Close()
+	if _, err := prepared.Freeze(); err == nil {
+		t.Fatalf("expected error when freezing a closed template")
+	}
+}
+
+func TestFrozenTemplateConcurrentRender(t *testing.T) {
+	prepared, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`Hello {{name}}`,
+		`{{include "body"}}`,
+	})))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer prepared.Close()
+	if err := prepared.AddFragmentFromBytes("body", createFragmentDOCX(t, "Body {{name}}")); err != nil {
+		t.Fatalf("AddFragmentFromBytes failed: %v", err)
+	}
+	frozen, err := prepared.Freeze()
+	if err != nil {
+		t.Fatalf("Freeze failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				output, err := frozen.Render(TemplateData{"name": "Ada"})
+				if err != nil {
+					errCh <- err
+					return
+				}
+				if _, err := io.ReadAll(output); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("concurrent frozen render failed: %v", err)
+	}
+
+	output, err := frozen.Render(TemplateData{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	if text := extractTextFromDOCX(t, content); text != "Hello AdaBody Ada" {
+		t.Fatalf("unexpected output %q", text)
+	}
+}
@@ -0,0 +1,72 @@
+package stencil
+
+import (
+	"context"
+	"fmt"
+)
+
+// callFunction calls fn with args after checking the function policy of the
+// render. A panic in fn is returned as a *FunctionError naming the function
+// and its arguments unless panics are propagated for the render. A NaN or
+// infinite result is resolved by the arithmetic policy of the render.
+func callFunction(fn Function, name string, data TemplateData, args []interface{}) (interface{}, error) {
+	policy := functionPolicyFromData(data)
+	if !policy.allows(name) {
+		return nil, withErrorCode(ErrorCodeFunctionNotAllowed, fmt.Errorf("function %s is not allowed", name))
+	}
+
+	helpers := attachedRenderHelpers(data)
+	propagate := helpers != nil && helpers.propagatePanics
+	call := func(ctx context.Context) (interface{}, error) {
+		return invokeFunction(ctx, fn, name, data, args, propagate)
+	}
+	var result interface{}
+	var err error
+	if policy != nil && policy.Timeout > 0 {
+		result, err = callWithTimeout(name, policy, helpers.timedOutCallCounter(), call)
+	} else {
+		result, err = call(context.Background())
+	}
+	if err == nil {
+		if sizeErr := helpers.checkValueSize(result); sizeErr != nil {
+			return nil, fmt.Errorf("function %s: %w", name, sizeErr)
+		}
+	}
+	if err == nil && isNonFiniteNumber(result) {
+		return arithmeticPolicyFromData(data).fallback(fmt.Errorf("function %s returned %v, which is not a finite number", name, result))
+	}
+	return result, err
+}
+
+func invokeFunction(ctx context.Context, fn Function, name string, data TemplateData, args []interface{}, propagatePanics bool) (result interface{}, err error) {
+	if !propagatePanics {
+		defer func() {
+			if r := recover(); r != nil {
+				result = nil
+				err = &FunctionError{
+					Function: name,
+					Args:     args,
+					Message:  fmt.Sprintf("panic: %v", r),
+					Panic:    r,
+				}
+			}
+		}()
+	}
+
+	if helperFn, ok := fn.(*renderHelperFunction); ok {
+		return helperFn.callWithHelpers(renderHelpersFromData(data), args...)
+	}
+	if contextFn, ok := fn.(ContextFunction); ok {
+		return contextFn.CallContext(ctx, args...)
+	}
+	return fn.Call(args...)
+}
+
+// recoverRenderPanic turns a panic outside of a function call, for example
+// in expression evaluation, into an error stored in err. It must be called
+// directly by defer.
+func recoverRenderPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = withErrorCode(ErrorCodePanic, fmt.Errorf("render panicked: %v", r))
+	}
+}
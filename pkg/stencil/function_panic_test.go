@@ -0,0 +1,97 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func prepareWithPanickingFunction(t *testing.T) *PreparedTemplate {
+	t.Helper()
+	engine := NewWithConfig(DefaultConfig())
+	explode := NewSimpleFunction("explode", 0, -1, func(args ...interface{}) (interface{}, error) {
+		var values map[string]int
+		values["boom"] = 1 // assignment to a nil map panics
+		return nil, nil
+	})
+	if err := engine.RegisterFunction("explode", explode); err != nil {
+		t.Fatalf("RegisterFunction() error = %v", err)
+	}
+
+	docx := createDOCXWithParagraphs(t, []string{`Total: {{explode(name, 42)}}`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	t.Cleanup(func() { tmpl.Close() })
+	return tmpl
+}
+
+func TestFunctionPanicIsReturnedAsError(t *testing.T) {
+	tmpl := prepareWithPanickingFunction(t)
+
+	_, err := tmpl.Render(TemplateData{"name": "Ada"})
+	if err == nil {
+		t.Fatal("expected the panic to be returned as an error")
+	}
+
+	var fnErr *FunctionError
+	if !errors.As(err, &fnErr) {
+		t.Fatalf("expected FunctionError, got %v", err)
+	}
+	if fnErr.Function != "explode" || len(fnErr.Args) != 2 || fnErr.Args[0] != "Ada" || fnErr.Panic == nil {
+		t.Fatalf("unexpected function error %+v", fnErr)
+	}
+	if !strings.Contains(err.Error(), "function error in 'explode(Ada, 42)': panic: assignment to entry in nil map") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+	if got := ErrorCodeOf(err); got != ErrorCodePanic {
+		t.Fatalf("ErrorCodeOf() = %q, want %q", got, ErrorCodePanic)
+	}
+
+	// The template stays usable after a recovered panic.
+	if _, err := tmpl.Render(TemplateData{"name": "Ada"}); err == nil {
+		t.Fatal("expected the second render to fail the same way")
+	}
+}
+
+func TestFunctionPanicPropagates(t *testing.T) {
+	tmpl := prepareWithPanickingFunction(t)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected the panic to propagate")
+		}
+	}()
+	tmpl.RenderWithOptions(TemplateData{"name": "Ada"}, RenderOptions{PropagatePanics: true})
+}
+
+func TestCallFunctionRecoversPanic(t *testing.T) {
+	fn := NewSimpleFunction("explode", 0, 0, func(args ...interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	registry := NewFunctionRegistry()
+	registry.RegisterFunction(fn)
+
+	_, err := CallFunction("explode", TemplateData{"__functions__": registry})
+	var fnErr *FunctionError
+	if !errors.As(err, &fnErr) || fnErr.Panic != "boom" {
+		t.Fatalf("expected recovered panic, got %v", err)
+	}
+}
+
+func TestRecoverRenderPanic(t *testing.T) {
+	render := func() (err error) {
+		defer recoverRenderPanic(&err)
+		panic("unexpected")
+	}
+
+	err := render()
+	if err == nil || err.Error() != "render panicked: unexpected" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := ErrorCodeOf(err); got != ErrorCodePanic {
+		t.Fatalf("ErrorCodeOf() = %q, want %q", got, ErrorCodePanic)
+	}
+}
@@ -0,0 +1,178 @@
+package stencil
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMaxTimedOutCalls bounds the function calls of an engine that timed
+// out but are still running, unless FunctionPolicy.MaxTimedOutCalls sets
+// another bound. Once it is reached, calls under a FunctionPolicy with a
+// Timeout fail without running, so functions that never return cannot pile
+// up goroutines.
+const defaultMaxTimedOutCalls = 64
+
+// defaultTimedOutCalls counts the timed-out calls that are still running of
+// renders without an engine.
+var defaultTimedOutCalls atomic.Int64
+
+// FunctionPolicy restricts which functions a template may call and how long
+// each call may run. It is meant for deployments that render semi-trusted
+// templates, for example one policy per tenant.
+type FunctionPolicy struct {
+	// Allow lists the only functions templates may call. Nil allows every
+	// registered function.
+	Allow []string
+	// Deny lists functions templates may not call, even if they are in Allow.
+	Deny []string
+	// Timeout bounds the execution time of each function call. A call that
+	// does not return in time fails the render. A ContextFunction has its
+	// context canceled; other functions keep running in the background, so
+	// they should still stop on their own. While too many timed-out calls
+	// are still running, calls fail without running. Zero means no limit.
+	Timeout time.Duration
+	// MaxTimedOutCalls bounds the calls that timed out and are still
+	// running, counted per engine, so a hanging function of one engine or
+	// tenant does not block the calls of others. Zero means 64.
+	MaxTimedOutCalls int
+}
+
+// maxTimedOutCalls returns the bound on the running timed-out calls.
+func (p *FunctionPolicy) maxTimedOutCalls() int64 {
+	if p.MaxTimedOutCalls > 0 {
+		return int64(p.MaxTimedOutCalls)
+	}
+	return defaultMaxTimedOutCalls
+}
+
+// allows reports whether the policy permits calling the named function.
+func (p *FunctionPolicy) allows(name string) bool {
+	if p == nil {
+		return true
+	}
+	if slices.Contains(p.Deny, name) {
+		return false
+	}
+	return p.Allow == nil || slices.Contains(p.Allow, name)
+}
+
+// functionPolicyFromData returns the function policy of the render, or nil.
+func functionPolicyFromData(data TemplateData) *FunctionPolicy {
+	if helpers := attachedRenderHelpers(data); helpers != nil {
+		return helpers.functionPolicy
+	}
+	return nil
+}
+
+// ContextFunction is a Function that can be stopped. Under a FunctionPolicy
+// with a Timeout it is called with CallContext, and the context is canceled
+// when the call times out.
+type ContextFunction interface {
+	Function
+	// CallContext executes the function with the given arguments; it should
+	// return once ctx is canceled
+	CallContext(ctx context.Context, args ...interface{}) (interface{}, error)
+}
+
+// contextFunction is the ContextFunction of NewContextFunction.
+type contextFunction struct {
+	name    string
+	minArgs int
+	maxArgs int
+	handler func(ctx context.Context, args ...interface{}) (interface{}, error)
+}
+
+// NewContextFunction creates a ContextFunction, such as for a function that
+// queries a service and should stop when the call times out. Call runs
+// handler with a background context.
+func NewContextFunction(name string, minArgs, maxArgs int, handler func(ctx context.Context, args ...interface{}) (interface{}, error)) ContextFunction {
+	return &contextFunction{name: name, minArgs: minArgs, maxArgs: maxArgs, handler: handler}
+}
+
+func (f *contextFunction) Call(args ...interface{}) (interface{}, error) {
+	return f.CallContext(context.Background(), args...)
+}
+
+func (f *contextFunction) CallContext(ctx context.Context, args ...interface{}) (interface{}, error) {
+	argCount := len(args)
+	if argCount < f.minArgs {
+		return nil, fmt.Errorf("function %s requires at least %d arguments, got %d", f.name, f.minArgs, argCount)
+	}
+	if f.maxArgs >= 0 && argCount > f.maxArgs {
+		return nil, fmt.Errorf("function %s accepts at most %d arguments, got %d", f.name, f.maxArgs, argCount)
+	}
+	return f.handler(ctx, args...)
+}
+
+func (f *contextFunction) Name() string {
+	return f.name
+}
+
+func (f *contextFunction) MinArgs() int {
+	return f.minArgs
+}
+
+func (f *contextFunction) MaxArgs() int {
+	return f.maxArgs
+}
+
+// callWithTimeout runs call and fails with a FUNCTION_TIMEOUT error when it
+// does not return within the timeout of policy, canceling the context passed
+// to call. A call that times out counts in timedOut until it returns. A panic
+// of call is raised again on the calling goroutine, where the render can
+// recover it; once the call timed out, its panic is dropped.
+func callWithTimeout(name string, policy *FunctionPolicy, timedOut *atomic.Int64, call func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if running := timedOut.Load(); running >= policy.maxTimedOutCalls() {
+		return nil, withErrorCode(ErrorCodeFunctionTimeout, fmt.Errorf("function %s not called: %d timed-out function calls are still running", name, running))
+	}
+
+	type outcome struct {
+		result   interface{}
+		err      error
+		panicked bool
+		panic    interface{}
+	}
+	const (
+		callRunning int32 = iota
+		callReturned
+		callAbandoned
+	)
+	var state atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan outcome, 1)
+	go func() {
+		out := outcome{panicked: true}
+		defer func() {
+			if out.panicked {
+				out.panic = recover()
+			}
+			if !state.CompareAndSwap(callRunning, callReturned) {
+				timedOut.Add(-1)
+			}
+			done <- out
+		}()
+		out.result, out.err = call(ctx)
+		out.panicked = false
+	}()
+
+	timer := time.NewTimer(policy.Timeout)
+	defer timer.Stop()
+	select {
+	case out := <-done:
+		cancel()
+		if out.panicked {
+			panic(out.panic)
+		}
+		return out.result, out.err
+	case <-timer.C:
+		timedOut.Add(1)
+		if !state.CompareAndSwap(callRunning, callAbandoned) {
+			timedOut.Add(-1)
+		}
+		cancel()
+		return nil, withErrorCode(ErrorCodeFunctionTimeout, fmt.Errorf("function %s did not return within %s", name, policy.Timeout))
+	}
+}
@@ -0,0 +1,199 @@
+package stencil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFunctionPolicyAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *FunctionPolicy
+		fn     string
+		want   bool
+	}{
+		{name: "nil policy", policy: nil, fn: "uppercase", want: true},
+		{name: "empty policy", policy: &FunctionPolicy{}, fn: "uppercase", want: true},
+		{name: "allowed", policy: &FunctionPolicy{Allow: []string{"uppercase"}}, fn: "uppercase", want: true},
+		{name: "not in allowlist", policy: &FunctionPolicy{Allow: []string{"uppercase"}}, fn: "lowercase", want: false},
+		{name: "empty allowlist", policy: &FunctionPolicy{Allow: []string{}}, fn: "uppercase", want: false},
+		{name: "denied", policy: &FunctionPolicy{Deny: []string{"uppercase"}}, fn: "uppercase", want: false},
+		{name: "deny wins", policy: &FunctionPolicy{Allow: []string{"uppercase"}, Deny: []string{"uppercase"}}, fn: "uppercase", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.allows(tt.fn); got != tt.want {
+				t.Fatalf("allows(%q) = %v, want %v", tt.fn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineFunctionPolicy(t *testing.T) {
+	engine := NewWithOptions(WithFunctionPolicy(FunctionPolicy{Allow: []string{"uppercase"}}))
+	docx := createDOCXWithParagraphs(t, []string{`{{uppercase(name)}}`, `{{lowercase(name)}}`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	defer tmpl.Close()
+
+	_, err = tmpl.Render(TemplateData{"name": "Ada"})
+	if got := ErrorCodeOf(err); got != ErrorCodeFunctionNotAllowed {
+		t.Fatalf("ErrorCodeOf(%v) = %q, want %q", err, got, ErrorCodeFunctionNotAllowed)
+	}
+	if !strings.Contains(err.Error(), "function lowercase is not allowed") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+
+	// A per-render policy replaces the engine policy.
+	output, err := tmpl.RenderWithOptions(TemplateData{"name": "Ada"}, RenderOptions{
+		FunctionPolicy: &FunctionPolicy{Allow: []string{"uppercase", "lowercase"}},
+	})
+	if err != nil {
+		t.Fatalf("RenderWithOptions() error = %v", err)
+	}
+	rendered, _ := io.ReadAll(output)
+	if text := extractTextFromDOCX(t, rendered); !strings.Contains(text, "ADA") || !strings.Contains(text, "ada") {
+		t.Fatalf("unexpected output %q", text)
+	}
+}
+
+func TestFunctionPolicyTimeout(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	release := make(chan struct{})
+	defer close(release)
+	slow := NewSimpleFunction("slow", 0, 0, func(args ...interface{}) (interface{}, error) {
+		<-release
+		return "done", nil
+	})
+	fast := NewSimpleFunction("fast", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return "done", nil
+	})
+	engine.RegisterFunction("slow", slow)
+	engine.RegisterFunction("fast", fast)
+
+	render := func(paragraph string) error {
+		tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{paragraph})))
+		if err != nil {
+			t.Fatalf("Prepare() error = %v", err)
+		}
+		defer tmpl.Close()
+		_, err = tmpl.RenderWithOptions(TemplateData{}, RenderOptions{
+			FunctionPolicy: &FunctionPolicy{Timeout: 20 * time.Millisecond},
+		})
+		return err
+	}
+
+	if err := render(`{{fast()}}`); err != nil {
+		t.Fatalf("fast function failed: %v", err)
+	}
+
+	err := render(`{{slow()}}`)
+	if got := ErrorCodeOf(err); got != ErrorCodeFunctionTimeout {
+		t.Fatalf("ErrorCodeOf(%v) = %q, want %q", err, got, ErrorCodeFunctionTimeout)
+	}
+	if !strings.Contains(err.Error(), "function slow did not return within 20ms") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestFunctionPolicyTimeoutRecoversPanic(t *testing.T) {
+	fn := NewSimpleFunction("explode", 0, 0, func(args ...interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	data := TemplateData{renderHelpersKey: &renderHelpers{functionPolicy: &FunctionPolicy{Timeout: time.Second}}}
+
+	_, err := callFunction(fn, "explode", data, nil)
+	var fnErr *FunctionError
+	if !errors.As(err, &fnErr) || fnErr.Panic != "boom" {
+		t.Fatalf("expected recovered panic, got %v", err)
+	}
+}
+
+func TestFunctionPolicyTimeoutCancelsContextFunction(t *testing.T) {
+	stopped := make(chan error, 1)
+	fn := NewContextFunction("lookup", 0, 0, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	data := TemplateData{renderHelpersKey: &renderHelpers{functionPolicy: &FunctionPolicy{Timeout: 10 * time.Millisecond}}}
+
+	_, err := callFunction(fn, "lookup", data, nil)
+	if got := ErrorCodeOf(err); got != ErrorCodeFunctionTimeout {
+		t.Fatalf("ErrorCodeOf(%v) = %q, want %q", err, got, ErrorCodeFunctionTimeout)
+	}
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("context error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("context of the timed-out call was not canceled")
+	}
+}
+
+func TestFunctionPolicyTimeoutLimitsRunningCallsPerEngine(t *testing.T) {
+	release := make(chan struct{})
+	hang := NewSimpleFunction("hang", 0, 0, func(args ...interface{}) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	fast := NewSimpleFunction("fast", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return "done", nil
+	})
+	policy := &FunctionPolicy{Timeout: time.Millisecond, MaxTimedOutCalls: 3}
+	var hungEngine, otherEngine atomic.Int64
+	data := TemplateData{renderHelpersKey: &renderHelpers{functionPolicy: policy, timedOutCalls: &hungEngine}}
+
+	for i := 0; i < policy.MaxTimedOutCalls; i++ {
+		if _, err := callFunction(hang, "hang", data, nil); ErrorCodeOf(err) != ErrorCodeFunctionTimeout {
+			close(release)
+			t.Fatalf("call %d: expected a timeout, got %v", i, err)
+		}
+	}
+	_, err := callFunction(fast, "fast", data, nil)
+	other := TemplateData{renderHelpersKey: &renderHelpers{functionPolicy: policy, timedOutCalls: &otherEngine}}
+	result, otherErr := callFunction(fast, "fast", other, nil)
+	close(release)
+	if got := ErrorCodeOf(err); got != ErrorCodeFunctionTimeout || !strings.Contains(err.Error(), "timed-out function calls are still running") {
+		t.Fatalf("expected the call to fail without running, got %v", err)
+	}
+	if otherErr != nil || result != "done" {
+		t.Fatalf("another engine's call = %v, %v; want it to run", result, otherErr)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hungEngine.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timed-out calls still counted after they returned", hungEngine.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFunctionPolicyTimeoutPropagatesPanicToCaller(t *testing.T) {
+	fn := NewSimpleFunction("explode", 0, 0, func(args ...interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	data := TemplateData{renderHelpersKey: &renderHelpers{
+		functionPolicy:  &FunctionPolicy{Timeout: time.Second},
+		propagatePanics: true,
+	}}
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("recovered %v, want the panic of the function", r)
+		}
+	}()
+	callFunction(fn, "explode", data, nil)
+	t.Fatal("expected the panic to reach the caller")
+}
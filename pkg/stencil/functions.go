@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unicode"
 )
 
@@ -40,6 +41,10 @@ type FunctionRegistry interface {
 type DefaultFunctionRegistry struct {
 	functions map[string]Function
 	mutex     sync.RWMutex
+	// revision counts the functions registered, and cacheID identifies the
+	// registry, for the render cache
+	revision uint64
+	cacheID  atomic.Uint64
 }
 
 // NewFunctionRegistry creates a new function registry
@@ -59,6 +64,7 @@ func (r *DefaultFunctionRegistry) RegisterFunction(fn Function) error {
 	}
 
 	r.functions[name] = fn
+	r.revision++
 	return nil
 }
 
@@ -145,6 +151,12 @@ func registerBasicFunctions(registry *DefaultFunctionRegistry) {
 	// Register number format functions
 	registerNumberFormatFunctions(registry)
 
+	// Register unit conversion functions
+	registerUnitFunctions(registry)
+
+	// Register scientific and engineering notation functions
+	registerNotationFunctions(registry)
+
 	// Register HTML functions
 	registerHTMLFunction(registry)
 
@@ -157,9 +169,62 @@ func registerBasicFunctions(registry *DefaultFunctionRegistry) {
 	// Register table column functions
 	registerTableColumnFunctions(registry)
 
+	// Register conditional table functions
+	registerTableConditionalFunctions(registry)
+
+	// Register table sorting functions
+	registerTableSortFunctions(registry)
+	registerTSVRowsFunction(registry)
+
+	// Register calendar, timeline, org chart, checklist and outline functions
+	registerCalendarFunction(registry)
+	registerTimelineFunction(registry)
+	registerOrgChartFunction(registry)
+	registerChecklistFunction(registry)
+	registerOutlineFunction(registry)
+
+	// Register letter functions
+	registerAddressBlockFunction(registry)
+	registerNameFunctions(registry)
+	registerPluralFunctions(registry)
+
+	// Register legal clause numbering and exhibit lettering functions
+	registerClauseFunctions(registry)
+	registerExhibitFunctions(registry)
+	registerGlossaryFunctions(registry)
+	registerCaptionFunctions(registry)
+
+	// Register paragraph override functions
+	registerParagraphFormatFunctions(registry)
+	registerParagraphsFunction(registry)
+	registerLandscapeAppendixFunction(registry)
+	registerSplitDocumentFunction(registry)
+	registerEmbedFileFunction(registry)
+	registerSymbolFunctions(registry)
+	registerEditableRegionFunctions(registry)
+
 	// Register link functions
 	registerLinkFunctions(registry)
 
+	// Register field functions
+	registerFieldFunctions(registry)
+	registerIndexFunctions(registry)
+
+	// Register url() for including fragments by URL
+	registerURLFunction(registry)
+
+	// Register uuid, random and sequence functions
+	registerRandomFunctions(registry)
+
+	// Register feature() for feature flags
+	registerFeatureFunctions(registry)
+
+	// Register assert() for template assertions
+	registerAssertFunction(registry)
+
+	// Register script(), if built with the stencil_script tag
+	registerScriptFunction(registry)
+
 	// empty() function - checks if a value is empty
 	emptyFn := NewSimpleFunction("empty", 1, 1, func(args ...interface{}) (interface{}, error) {
 		return isEmpty(args[0]), nil
@@ -191,8 +256,13 @@ func registerBasicFunctions(registry *DefaultFunctionRegistry) {
 	})
 	registry.RegisterFunction(dataFn)
 
-	// map() function - extracts values from a collection by path
+	// map() function - extracts values from a collection by path, or
+	// transforms each item with a lambda: map(items, x -> x.price * x.qty)
 	mapFn := NewSimpleFunction("map", 2, 2, func(args ...interface{}) (interface{}, error) {
+		if fn, ok := args[1].(*lambda); ok {
+			return mapWithLambda(args[0], fn)
+		}
+
 		path, ok := args[0].(string)
 		if !ok {
 			return nil, fmt.Errorf("first parameter of map() must be a string")
@@ -203,6 +273,36 @@ func registerBasicFunctions(registry *DefaultFunctionRegistry) {
 	})
 	registry.RegisterFunction(mapFn)
 
+	// filter() function - keeps the items for which a lambda is truthy
+	filterFn := NewSimpleFunction("filter", 2, 2, func(args ...interface{}) (interface{}, error) {
+		fn, err := lambdaArg("filter", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return filterWithLambda(args[0], fn)
+	})
+	registry.RegisterFunction(filterFn)
+
+	// sortBy() function - sorts items by the key a lambda returns, text
+	// in the collation of the render locale
+	registry.RegisterFunction(&renderHelperFunction{name: "sortBy", minArgs: 2, maxArgs: 2, handler: func(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+		fn, err := lambdaArg("sortBy", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return sortByLambda(args[0], fn, helpers.locale)
+	}})
+
+	// groupBy() function - groups items by the key a lambda returns, the
+	// groups in the order of sortBy()
+	registry.RegisterFunction(&renderHelperFunction{name: "groupBy", minArgs: 2, maxArgs: 2, handler: func(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+		fn, err := lambdaArg("groupBy", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return groupByLambda(args[0], fn, helpers.locale)
+	}})
+
 	// str() function - converts value to string
 	strFn := NewSimpleFunction("str", 1, 1, func(args ...interface{}) (interface{}, error) {
 		if args[0] == nil {
@@ -339,6 +439,28 @@ func registerBasicFunctions(registry *DefaultFunctionRegistry) {
 	})
 	registry.RegisterFunction(joinAndFn)
 
+	// sentence() function - assembles a sentence from its non-empty parts
+	sentenceFn := NewSimpleFunction("sentence", 0, -1, func(args ...interface{}) (interface{}, error) {
+		var parts []string
+		for _, arg := range args {
+			switch arg.(type) {
+			case nil, string, map[string]interface{}:
+				parts = appendSentencePart(parts, arg)
+				continue
+			}
+			items, err := toSlice(arg)
+			if err != nil {
+				parts = appendSentencePart(parts, arg)
+				continue
+			}
+			for _, item := range items {
+				parts = appendSentencePart(parts, item)
+			}
+		}
+		return assembleSentence(parts), nil
+	})
+	registry.RegisterFunction(sentenceFn)
+
 	// replace() function - replaces all occurrences of pattern with replacement
 	replaceFn := NewSimpleFunction("replace", 3, 3, func(args ...interface{}) (interface{}, error) {
 		// Get text
@@ -527,10 +649,10 @@ func CallFunction(name string, data TemplateData, args ...interface{}) (interfac
 
 	fn, exists := registry.GetFunction(name)
 	if !exists {
-		return nil, fmt.Errorf("unknown function: %s", name)
+		return nil, withErrorCode(ErrorCodeUnknownFunction, fmt.Errorf("unknown function: %s", name))
 	}
 
-	return fn.Call(args...)
+	return callFunction(fn, name, data, args)
 }
 
 // mapExtract extracts values from a collection following a path
@@ -564,6 +686,11 @@ func mapExtract(path string, data interface{}) (interface{}, error) {
 		for i, item := range v {
 			current[i] = item
 		}
+	case []TemplateData:
+		current = make([]interface{}, len(v))
+		for i, item := range v {
+			current[i] = map[string]interface{}(item)
+		}
 	default:
 		// If not a slice, wrap in a slice
 		current = []interface{}{data}
@@ -583,6 +710,9 @@ func mapExtract(path string, data interface{}) (interface{}, error) {
 			}
 
 			// For maps, extract the field value
+			if data, ok := item.(TemplateData); ok {
+				item = map[string]interface{}(data)
+			}
 			switch v := item.(type) {
 			case map[string]interface{}:
 				if val, exists := v[part]; exists {
@@ -771,6 +901,43 @@ func toTitleCase(s string) string {
 	return result.String()
 }
 
+// appendSentencePart adds a part of a sentence() to parts, without its
+// surrounding spaces and trailing commas, unless it is empty.
+func appendSentencePart(parts []string, value interface{}) []string {
+	if value == nil {
+		return parts
+	}
+	part := strings.TrimRight(strings.TrimSpace(FormatValue(value)), ", ")
+	if part == "" {
+		return parts
+	}
+	return append(parts, part)
+}
+
+// assembleSentence joins parts with commas and a final "and", capitalizes
+// the first letter and ends the sentence with a period unless it already
+// ends with punctuation.
+func assembleSentence(parts []string) string {
+	var sentence string
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		sentence = parts[0]
+	default:
+		sentence = strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+
+	runes := []rune(sentence)
+	runes[0] = unicode.ToUpper(runes[0])
+	switch runes[len(runes)-1] {
+	case '.', '!', '?', '…':
+	default:
+		runes = append(runes, '.')
+	}
+	return string(runes)
+}
+
 // mathRound rounds a number to the nearest integer
 func mathRound(val interface{}) (interface{}, error) {
 	if val == nil {
@@ -783,6 +950,10 @@ func mathRound(val interface{}) (interface{}, error) {
 		return nil, err
 	}
 
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		// Left to the arithmetic policy of the render
+		return num, nil
+	}
 	// Round and return as int to match Go expression evaluation
 	return int(math.Round(num)), nil
 }
@@ -800,6 +971,10 @@ func mathFloor(val interface{}) (interface{}, error) {
 	}
 
 	// Floor and return as int to match Go expression evaluation
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		// Left to the arithmetic policy of the render
+		return num, nil
+	}
 	return int(math.Floor(num)), nil
 }
 
@@ -816,6 +991,10 @@ func mathCeil(val interface{}) (interface{}, error) {
 	}
 
 	// Ceil and return as int to match Go expression evaluation
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		// Left to the arithmetic policy of the render
+		return num, nil
+	}
 	return int(math.Ceil(num)), nil
 }
 
@@ -851,12 +1030,23 @@ func sumList(val interface{}) (interface{}, error) {
 	// Sum the items
 	var sum float64 = 0
 	hasFloat := false
+	currency := ""
 
 	for _, item := range items {
 		if item == nil {
 			continue // Skip nil values
 		}
 
+		// Money adds up in its currency
+		if m, ok := toMoney(item); ok {
+			if currency != "" && m.Currency != currency {
+				return nil, fmt.Errorf("sum() cannot mix currencies %s and %s", currency, m.Currency)
+			}
+			currency = m.Currency
+			sum += m.Amount
+			continue
+		}
+
 		// Convert item to number
 		num, err := toNumber(item)
 		if err != nil {
@@ -879,6 +1069,10 @@ func sumList(val interface{}) (interface{}, error) {
 		}
 	}
 
+	if currency != "" {
+		return Money{Amount: sum, Currency: currency}, nil
+	}
+
 	// Return int if all inputs were integers, float otherwise
 	if !hasFloat && sum == float64(int(sum)) {
 		return int(sum), nil
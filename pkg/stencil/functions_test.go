@@ -1,7 +1,9 @@
 package stencil
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"math"
 	"strings"
 	"testing"
@@ -802,6 +804,39 @@ func TestMapFunctionInExpressions(t *testing.T) {
 			},
 			want: []interface{}{[]interface{}{"Alice", "Bob"}},
 		},
+		{
+			name: "field access on a list maps over its items",
+			expr: "sum(items.price)",
+			data: TemplateData{
+				"items": []interface{}{
+					map[string]interface{}{"name": "Item1", "price": 10.5},
+					map[string]interface{}{"name": "Item2", "price": 20.0},
+				},
+			},
+			want: 30.5,
+		},
+		{
+			name: "nested field access on a list",
+			expr: "orders.product.name",
+			data: TemplateData{
+				"orders": []map[string]interface{}{
+					{"product": map[string]interface{}{"name": "Widget"}},
+					{"product": map[string]interface{}{"name": "Gadget"}},
+				},
+			},
+			want: []interface{}{"Widget", "Gadget"},
+		},
+		{
+			name: "field access on an indexed item is not mapped",
+			expr: "items[1].price",
+			data: TemplateData{
+				"items": []interface{}{
+					map[string]interface{}{"price": 10.5},
+					map[string]interface{}{"price": 20.0},
+				},
+			},
+			want: 20.0,
+		},
 		{
 			name:    "map with non-string first argument",
 			expr:    "map(123, items)",
@@ -3058,3 +3093,40 @@ func TestSwitchInExpressions(t *testing.T) {
 		})
 	}
 }
+
+func TestFunctionsReturningBuiltContent(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	engine.RegisterFunction("badge", NewSimpleFunction("badge", 1, 1, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: NewRun(FormatValue(args[0]), RunProps{Bold: true})}, nil
+	}))
+	engine.RegisterFunction("summary", NewSimpleFunction("summary", 0, 0, func(args ...interface{}) (interface{}, error) {
+		table := NewTable(1, 2)
+		table.Cell(0, 0).SetText("Total", ParagraphOptions{})
+		table.Cell(0, 1).SetText("42", ParagraphOptions{Alignment: "right"})
+		return &OOXMLFragment{Content: table}, nil
+	}))
+
+	docx := createDOCXWithParagraphs(t, []string{`Status: {{badge(status)}}!`, `{{summary()}}`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(TemplateData{"status": "open"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	documentXML := extractDocumentXMLFromDOCX(t, content)
+	for _, want := range []string{
+		`<w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">open</w:t></w:r>`,
+		`<w:tbl>`, `>Total<`, `<w:jc w:val="right">`,
+	} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("missing %s in %s", want, documentXML)
+		}
+	}
+	if strings.Contains(documentXML, "OOXML_FRAGMENT") {
+		t.Errorf("fragment placeholder left in output: %s", documentXML)
+	}
+}
@@ -0,0 +1,382 @@
+package stencil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// defaultFuzzRuns is the number of renders of FuzzOptions without Runs.
+const defaultFuzzRuns = 100
+
+// fuzzWords are the words of generated strings.
+var fuzzWords = []string{
+	"alpha", "Müller", "invoice", "straße", "O'Brien", "total", "über", "naïve",
+	"renewal", "Zoë", "quarterly", "contract", "émigré", "delivery", "São Paulo",
+}
+
+// fuzzSpecialStrings are generated strings with characters that need
+// escaping or special handling in a document.
+var fuzzSpecialStrings = []string{
+	`<b>bold</b> & "quoted" 'text'`,
+	"first line\nsecond line\tafter a tab",
+	"   ",
+	"{{not a placeholder}}",
+	"Ünïcödé 中文 עברית العربية 😀",
+	"\u00a0non-breaking\u00a0spaces and a soft\u00adhyphen",
+}
+
+// FuzzOptions configures PreparedTemplate.Fuzz.
+type FuzzOptions struct {
+	// Schema describes the data to generate. When it has no fields, the
+	// schema is inferred from the template with InferSchema.
+	Schema ValidationSchema
+	// Runs is the number of renders, 100 by default.
+	Runs int
+	// Seed is the seed of the data of the first render; render i uses
+	// Seed+i, so GenerateData(schema, seed) reproduces a failure.
+	Seed int64
+	// RenderOptions are the options of every render.
+	RenderOptions RenderOptions
+}
+
+// FuzzFailure is a render that failed on generated data.
+type FuzzFailure struct {
+	Seed  int64        `json:"seed"`
+	Data  TemplateData `json:"data"`
+	Error string       `json:"error"`
+	// Kind is Error without the data values it quotes, the same for
+	// failures with the same cause.
+	Kind string `json:"kind"`
+}
+
+// FuzzReport is the result of PreparedTemplate.Fuzz.
+type FuzzReport struct {
+	// Schema is the schema the data was generated from.
+	Schema   ValidationSchema `json:"schema"`
+	Runs     int              `json:"runs"`
+	Failures []FuzzFailure    `json:"failures"`
+}
+
+// OK reports whether every render succeeded.
+func (r FuzzReport) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// Fuzz renders the template repeatedly with data generated from a schema,
+// to catch templates that fail only on edge data such as empty
+// collections, very long strings, zero numbers or null values. A render
+// fails when it returns an error or panics.
+//
+// Example:
+//
+//	report, err := tmpl.Fuzz(stencil.FuzzOptions{Runs: 500})
+//	for _, failure := range report.Failures {
+//	    log.Printf("seed %d: %s", failure.Seed, failure.Error)
+//	}
+func (pt *PreparedTemplate) Fuzz(opts FuzzOptions) (FuzzReport, error) {
+	if pt == nil {
+		return FuzzReport{}, fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
+	if pt.closed || pt.template == nil {
+		pt.mu.RUnlock()
+		return FuzzReport{}, errTemplateClosed
+	}
+	schema := opts.Schema
+	if len(schema.Fields) == 0 {
+		inferred, err := InferSchema(pt.template.source)
+		if err != nil {
+			pt.mu.RUnlock()
+			return FuzzReport{}, fmt.Errorf("failed to infer schema: %w", err)
+		}
+		schema = inferred
+	}
+	pt.mu.RUnlock()
+	runs := opts.Runs
+	if runs <= 0 {
+		runs = defaultFuzzRuns
+	}
+
+	report := FuzzReport{Schema: schema, Runs: runs}
+	for i := 0; i < runs; i++ {
+		seed := opts.Seed + int64(i)
+		data := GenerateData(schema, seed)
+		if err := pt.fuzzRender(data, opts.RenderOptions); err != nil {
+			report.Failures = append(report.Failures, FuzzFailure{Seed: seed, Data: data, Error: err.Error(), Kind: fuzzFailureKind(err)})
+		}
+	}
+	return report, nil
+}
+
+// fuzzRender renders data, turning a panic into an error.
+func (pt *PreparedTemplate) fuzzRender(data TemplateData, opts RenderOptions) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	reader, err := pt.RenderWithOptions(data, opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, reader)
+	return err
+}
+
+// fuzzFailureKind returns the message of err without the values of
+// evaluation and function errors.
+func fuzzFailureKind(err error) string {
+	message := err.Error()
+	var evalErr *EvaluationError
+	if errors.As(err, &evalErr) && len(evalErr.Values) > 0 {
+		withoutValues := *evalErr
+		withoutValues.Values = nil
+		message = strings.Replace(message, evalErr.Error(), withoutValues.Error(), 1)
+	}
+	var fnErr *FunctionError
+	if errors.As(err, &fnErr) && len(fnErr.Args) > 0 {
+		withoutArgs := *fnErr
+		withoutArgs.Args = nil
+		message = strings.Replace(message, fnErr.Error(), withoutArgs.Error(), 1)
+	}
+	return message
+}
+
+// InferSchema returns a schema of the data fields a DOCX template reads,
+// for generating data when no schema is at hand. The collections for loops
+// iterate are collections, fields used in arithmetic or ordering
+// comparisons are numbers and other fields are strings. Inferred fields
+// are required, as the template gives no hint of which may be missing.
+func InferSchema(docx []byte) (ValidationSchema, error) {
+	if len(docx) == 0 {
+		return ValidationSchema{}, fmt.Errorf("docx bytes are required")
+	}
+	spans, err := scanDOCXTokenSpans(docx)
+	if err != nil {
+		return ValidationSchema{}, err
+	}
+
+	numeric := make(map[string]bool)
+	collections := make(map[string]bool)
+	walkDataFields(spans, map[string]FieldDefinition{}, func(path string, _ tokenSpan, isNumeric bool) {
+		path = stripLiteralIndices(normalizeFieldPath(path))
+		if path == "" {
+			return
+		}
+		numeric[path] = numeric[path] || isNumeric
+	}, func(path string) {
+		if path = stripLiteralIndices(normalizeFieldPath(path)); path != "" {
+			collections[path] = true
+		}
+	})
+
+	// Parents of fields are objects, or collections of objects
+	paths := make(map[string]bool)
+	for path := range numeric {
+		paths[path] = true
+	}
+	for path := range collections {
+		paths[path] = true
+	}
+	parents := make(map[string]bool)
+	for path := range paths {
+		for i := strings.LastIndexByte(path, '.'); i > 0; i = strings.LastIndexByte(path[:i], '.') {
+			parents[path[:i]] = true
+		}
+	}
+	for path := range parents {
+		paths[path] = true
+	}
+
+	schema := ValidationSchema{Fields: make([]FieldDefinition, 0, len(paths))}
+	for path := range paths {
+		field := FieldDefinition{Path: path, Type: semanticKindString, Collection: collections[path], Required: true}
+		switch {
+		case parents[path]:
+			field.Type = semanticKindObject
+		case numeric[path]:
+			field.Type = semanticKindNumber
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	sort.Slice(schema.Fields, func(i, j int) bool {
+		return schema.Fields[i].Path < schema.Fields[j].Path
+	})
+	return schema, nil
+}
+
+// GenerateData returns random data valid for the schema, biased towards
+// edge values: empty and long collections, empty, very long and unusual
+// strings, zero, negative and large numbers, and null for nullable
+// fields. Fields that are not required are sometimes left out and
+// computed fields always are. The same schema and seed give the same
+// data.
+func GenerateData(schema ValidationSchema, seed int64) TemplateData {
+	root := &dataNode{}
+	for _, field := range schema.Fields {
+		path := stripLiteralIndices(normalizeFieldPath(field.Path))
+		if path == "" || field.Compute != "" {
+			continue
+		}
+		node := root
+		for _, name := range strings.Split(path, ".") {
+			node = node.child(name)
+		}
+		def := field
+		node.def = &def
+	}
+
+	g := &dataGenerator{rng: rand.New(rand.NewSource(seed))}
+	data := TemplateData{}
+	for name, value := range g.object(root) {
+		data[name] = value
+	}
+	return data
+}
+
+// dataNode is a field of a schema with the fields nested in it.
+type dataNode struct {
+	def      *FieldDefinition
+	names    []string
+	children map[string]*dataNode
+}
+
+func (n *dataNode) child(name string) *dataNode {
+	if child, ok := n.children[name]; ok {
+		return child
+	}
+	if n.children == nil {
+		n.children = make(map[string]*dataNode)
+	}
+	child := &dataNode{}
+	n.children[name] = child
+	n.names = append(n.names, name)
+	sort.Strings(n.names)
+	return child
+}
+
+// dataGenerator generates the values of GenerateData.
+type dataGenerator struct {
+	rng *rand.Rand
+}
+
+func (g *dataGenerator) object(node *dataNode) map[string]interface{} {
+	object := make(map[string]interface{}, len(node.names))
+	for _, name := range node.names {
+		child := node.children[name]
+		// Decide before generating so a field left out does not change
+		// the values of the fields after it
+		omit := child.def != nil && !child.def.Required && g.rng.Intn(8) == 0
+		value := g.value(child)
+		if !omit {
+			object[name] = value
+		}
+	}
+	return object
+}
+
+func (g *dataGenerator) value(node *dataNode) interface{} {
+	if node.def != nil && node.def.Nullable && g.rng.Intn(5) == 0 {
+		return nil
+	}
+	if node.def != nil && node.def.Collection {
+		items := make([]interface{}, g.length())
+		for i := range items {
+			items[i] = g.element(node)
+		}
+		return items
+	}
+	return g.element(node)
+}
+
+// element returns a value of the field, or an item of a collection.
+func (g *dataGenerator) element(node *dataNode) interface{} {
+	if len(node.names) > 0 {
+		return g.object(node)
+	}
+	kind := semanticKindAny
+	if node.def != nil && node.def.Type != "" {
+		kind = node.def.Type
+	}
+	return g.scalar(kind)
+}
+
+func (g *dataGenerator) scalar(kind string) interface{} {
+	switch kind {
+	case semanticKindString:
+		return g.string()
+	case semanticKindNumber:
+		return g.number()
+	case semanticKindBool:
+		return g.rng.Intn(2) == 0
+	case semanticKindObject:
+		return map[string]interface{}{}
+	case semanticKindArray:
+		return []interface{}{}
+	default:
+		if g.rng.Intn(2) == 0 {
+			return g.number()
+		}
+		return g.string()
+	}
+}
+
+// length returns the length of a collection: empty a fifth of the time,
+// long a tenth of the time.
+func (g *dataGenerator) length() int {
+	switch r := g.rng.Intn(10); {
+	case r < 2:
+		return 0
+	case r < 4:
+		return 1
+	case r < 9:
+		return 2 + g.rng.Intn(4)
+	default:
+		return 20 + g.rng.Intn(40)
+	}
+}
+
+func (g *dataGenerator) string() string {
+	switch r := g.rng.Intn(10); {
+	case r == 0:
+		return ""
+	case r == 1:
+		return g.words(200 + g.rng.Intn(400))
+	case r == 2:
+		return strings.Repeat("x", 100+g.rng.Intn(200))
+	case r == 3:
+		return fuzzSpecialStrings[g.rng.Intn(len(fuzzSpecialStrings))]
+	default:
+		return g.words(1 + g.rng.Intn(4))
+	}
+}
+
+func (g *dataGenerator) words(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fuzzWords[g.rng.Intn(len(fuzzWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+func (g *dataGenerator) number() interface{} {
+	switch r := g.rng.Intn(10); {
+	case r == 0:
+		return 0
+	case r == 1:
+		return -1 - g.rng.Intn(10000)
+	case r == 2:
+		return 1e12 + float64(g.rng.Int63n(1e12))
+	case r == 3:
+		return math.Round(g.rng.Float64()*100000) / 100
+	case r == 4:
+		return 0.001
+	default:
+		return 1 + g.rng.Intn(1000)
+	}
+}
@@ -0,0 +1,120 @@
+package stencil
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		"Invoice for {{customer.name}}",
+		"{{for line in lines}}{{line.name}}: {{line.price * line.qty}}{{for tag in line.tags}}{{tag}}{{end}}{{end}}",
+		"{{if total > 100}}Discount{{end}} {{-balance}}",
+	})
+	schema, err := InferSchema(docx)
+	if err != nil {
+		t.Fatalf("InferSchema failed: %v", err)
+	}
+	want := []FieldDefinition{
+		{Path: "balance", Type: "number", Required: true},
+		{Path: "customer", Type: "object", Required: true},
+		{Path: "customer.name", Type: "string", Required: true},
+		{Path: "lines", Type: "object", Collection: true, Required: true},
+		{Path: "lines.name", Type: "string", Required: true},
+		{Path: "lines.price", Type: "number", Required: true},
+		{Path: "lines.qty", Type: "number", Required: true},
+		{Path: "lines.tags", Type: "string", Collection: true, Required: true},
+		{Path: "total", Type: "number", Required: true},
+	}
+	if !reflect.DeepEqual(schema.Fields, want) {
+		t.Errorf("Fields = %+v, want %+v", schema.Fields, want)
+	}
+}
+
+func TestGenerateData(t *testing.T) {
+	schema := ValidationSchema{Fields: []FieldDefinition{
+		{Path: "title", Type: "string", Required: true},
+		{Path: "count", Type: "number", Required: true},
+		{Path: "items", Type: "object", Collection: true, Required: true},
+		{Path: "items.name", Type: "string", Required: true},
+		{Path: "note", Type: "string", Nullable: true},
+		{Path: "total", Type: "number", Compute: "sum(map(\"price\", items))"},
+	}}
+
+	if a, b := GenerateData(schema, 7), GenerateData(schema, 7); !reflect.DeepEqual(a, b) {
+		t.Errorf("GenerateData is not deterministic: %v != %v", a, b)
+	}
+
+	var emptyItems, nullNote, missingNote, longTitle bool
+	for seed := int64(0); seed < 200; seed++ {
+		data := GenerateData(schema, seed)
+		title, ok := data["title"].(string)
+		if !ok {
+			t.Fatalf("seed %d: title = %#v, want a string", seed, data["title"])
+		}
+		longTitle = longTitle || len(title) > 500
+		switch data["count"].(type) {
+		case int, float64:
+		default:
+			t.Fatalf("seed %d: count = %#v, want a number", seed, data["count"])
+		}
+		items, ok := data["items"].([]interface{})
+		if !ok {
+			t.Fatalf("seed %d: items = %#v, want a list", seed, data["items"])
+		}
+		emptyItems = emptyItems || len(items) == 0
+		for _, item := range items {
+			if _, ok := item.(map[string]interface{})["name"].(string); !ok {
+				t.Fatalf("seed %d: item = %#v, want an object with a name", seed, item)
+			}
+		}
+		note, present := data["note"]
+		nullNote = nullNote || (present && note == nil)
+		missingNote = missingNote || !present
+		if _, ok := data["total"]; ok {
+			t.Fatalf("seed %d: computed field total was generated", seed)
+		}
+	}
+	if !emptyItems || !nullNote || !missingNote || !longTitle {
+		t.Errorf("edge values not generated: empty items %v, null note %v, missing note %v, long title %v",
+			emptyItems, nullNote, missingNote, longTitle)
+	}
+}
+
+func TestPreparedTemplateFuzz(t *testing.T) {
+	prepare := func(paragraphs ...string) *PreparedTemplate {
+		tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, paragraphs)))
+		if err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		t.Cleanup(func() { tmpl.Close() })
+		return tmpl
+	}
+
+	robust := prepare("Dear {{name}}", "{{for line in lines}}{{line.name}}: {{line.price * 2}}{{end}}")
+	report, err := robust.Fuzz(FuzzOptions{Runs: 50})
+	if err != nil {
+		t.Fatalf("Fuzz failed: %v", err)
+	}
+	if !report.OK() || report.Runs != 50 {
+		t.Errorf("Fuzz = %d runs, failures %+v; want 50 runs without failures", report.Runs, report.Failures)
+	}
+
+	fragile := prepare("Average: {{total / count}}")
+	report, err = fragile.Fuzz(FuzzOptions{Seed: 1000})
+	if err != nil {
+		t.Fatalf("Fuzz failed: %v", err)
+	}
+	if report.OK() || report.Runs != defaultFuzzRuns {
+		t.Fatalf("Fuzz = %d runs without failures, want division by zero failures", report.Runs)
+	}
+	failure := report.Failures[0]
+	if failure.Data["count"] != 0 || !strings.Contains(failure.Error, "division by zero") {
+		t.Errorf("failure = %+v, want a division by zero with count 0", failure)
+	}
+	if !reflect.DeepEqual(GenerateData(report.Schema, failure.Seed), failure.Data) {
+		t.Error("GenerateData does not reproduce the data of the failure")
+	}
+}
@@ -0,0 +1,236 @@
+package stencil
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ValueProvider resolves a top-level template variable that is not present in
+// the render data or the engine's global data. It reports false when it has no
+// value for key. Providers are called concurrently by parallel renders and
+// must be safe for concurrent use.
+type ValueProvider func(key string) (interface{}, bool)
+
+// constantsName is the template variable that holds the engine's constants.
+const constantsName = "constants"
+
+// PostProcessor transforms a rendered document before it is written, such as
+// to apply company-specific table styling. data holds the render data merged
+// with the engine's global data. A processor can inspect it to decide
+// whether to change the document at all.
+type PostProcessor func(doc *Document, data TemplateData) error
+
+// PreProcessor normalizes the data of a render before the template is
+// rendered, such as to trim strings or add computed fields. It receives a
+// shallow copy of the render data and returns the data to render with; an
+// error fails the render.
+type PreProcessor func(data TemplateData) (TemplateData, error)
+
+// engineData holds the data, function policy and processors an engine
+// applies to every render. It is shared with the templates the engine
+// prepares, so changes apply to later renders of those templates.
+type engineData struct {
+	mu            sync.RWMutex
+	global        TemplateData
+	providers     []ValueProvider
+	policy        *FunctionPolicy
+	constants     map[string]interface{}
+	plurals       map[string]map[string]PluralForms
+	preprocessors []PreProcessor
+	processors    []PostProcessor
+	renderCache   *renderCache
+	tracer        Tracer
+	sanitization  *TextSanitization
+	features      FeatureProvider
+
+	// processorRevision counts the pre- and post-processors added, for the
+	// render cache
+	processorRevision uint64
+	// timedOutCalls counts the timed-out function calls of the engine's
+	// renders that are still running
+	timedOutCalls atomic.Int64
+}
+
+func newEngineData() *engineData {
+	return &engineData{}
+}
+
+func (d *engineData) setGlobal(data TemplateData) {
+	global := make(TemplateData, len(data))
+	for k, v := range data {
+		global[k] = v
+	}
+
+	d.mu.Lock()
+	d.global = global
+	d.mu.Unlock()
+}
+
+func (d *engineData) addProvider(provider ValueProvider) {
+	if provider == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.providers = append(d.providers, provider)
+	d.mu.Unlock()
+}
+
+// addConstants adds constants, replacing those with the same names. The map
+// is replaced rather than modified, so renders in progress keep their view.
+func (d *engineData) addConstants(constants map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	merged := make(map[string]interface{}, len(d.constants)+len(constants))
+	for name, value := range d.constants {
+		merged[name] = value
+	}
+	for name, value := range constants {
+		merged[name] = value
+	}
+	d.constants = merged
+}
+
+// constantValues returns the registered constants. The map must not be
+// modified.
+func (d *engineData) constantValues() map[string]interface{} {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.constants
+}
+
+func (d *engineData) addPreProcessor(processor PreProcessor) {
+	if processor == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.preprocessors = append(d.preprocessors, processor)
+	d.processorRevision++
+	d.mu.Unlock()
+}
+
+// preprocess runs the pre-processors on data in the order they were added.
+func (d *engineData) preprocess(data TemplateData) (TemplateData, error) {
+	if d == nil {
+		return data, nil
+	}
+
+	d.mu.RLock()
+	processors := append([]PreProcessor(nil), d.preprocessors...)
+	d.mu.RUnlock()
+
+	for i, processor := range processors {
+		processed, err := processor(data)
+		if err != nil {
+			return nil, WithContext(err, "running pre-processor", map[string]interface{}{"index": i})
+		}
+		if processed == nil {
+			processed = make(TemplateData)
+		}
+		data = processed
+	}
+	return data, nil
+}
+
+func (d *engineData) addPostProcessor(processor PostProcessor) {
+	if processor == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.processors = append(d.processors, processor)
+	d.processorRevision++
+	d.mu.Unlock()
+}
+
+// postProcessors returns the post-processors in the order they were added.
+func (d *engineData) postProcessors() []PostProcessor {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]PostProcessor(nil), d.processors...)
+}
+
+func (d *engineData) setFunctionPolicy(policy *FunctionPolicy) {
+	d.mu.Lock()
+	d.policy = policy
+	d.mu.Unlock()
+}
+
+// attach makes the global data available to renderData, and the value
+// providers and function policy to helpers. The global data becomes the
+// parent scope, so render data takes precedence. A function policy of the
+// render takes precedence over that of the engine.
+func (d *engineData) attach(renderData TemplateData, helpers *renderHelpers) {
+	if d == nil {
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.global) > 0 {
+		renderData[parentDataKey] = d.global
+	}
+	if len(d.providers) > 0 {
+		helpers.valueProviders = append([]ValueProvider(nil), d.providers...)
+	}
+	if helpers.functionPolicy == nil {
+		helpers.functionPolicy = d.policy
+	}
+	helpers.timedOutCalls = &d.timedOutCalls
+}
+
+// attachConstants makes the constants of the engine, with the overrides of
+// the render applied, available to renderData as the "constants" variable.
+// The variable sits between the render data and the global data, so render
+// data named "constants" hides it.
+func (d *engineData) attachConstants(renderData TemplateData, overrides map[string]interface{}) {
+	var constants map[string]interface{}
+	if d != nil {
+		d.mu.RLock()
+		constants = d.constants
+		d.mu.RUnlock()
+	}
+	if len(overrides) > 0 {
+		merged := make(map[string]interface{}, len(constants)+len(overrides))
+		for name, value := range constants {
+			merged[name] = value
+		}
+		for name, value := range overrides {
+			merged[name] = value
+		}
+		constants = merged
+	}
+	if len(constants) == 0 {
+		return
+	}
+
+	scope := TemplateData{constantsName: constants}
+	if parent, ok := renderData[parentDataKey]; ok {
+		scope[parentDataKey] = parent
+	}
+	renderData[parentDataKey] = scope
+}
+
+// resolveProvidedValue asks the value providers attached to data for key.
+func resolveProvidedValue(data TemplateData, key string) interface{} {
+	helpers := attachedRenderHelpers(data)
+	if helpers == nil {
+		return nil
+	}
+	for _, provider := range helpers.valueProviders {
+		if value, ok := provider(key); ok {
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEngineGlobalDataAndValueProviders(t *testing.T) {
+	var providerCalls []string
+	engine := NewWithOptions(
+		WithValueProvider(func(key string) (interface{}, bool) {
+			providerCalls = append(providerCalls, key)
+			if key == "env" {
+				return "staging", true
+			}
+			return nil, false
+		}),
+		WithValueProvider(func(key string) (interface{}, bool) {
+			if key == "env" || key == "betaFeatures" {
+				return true, true
+			}
+			return nil, false
+		}),
+	)
+	engine.SetGlobalData(TemplateData{
+		"company":  map[string]interface{}{"name": "Acme"},
+		"greeting": "Hello",
+	})
+
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`{{greeting}} {{name}} from {{company.name}}`,
+		`Environment: {{env}}`,
+		`{{if betaFeatures}}Beta enabled{{end}}`,
+		`{{for item in items}}{{item}}{{greeting}};{{end}}`,
+		`[{{missing}}]`,
+	})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{
+		"name":     "Jane",
+		"greeting": "Hi",
+		"items":    []interface{}{"a", "b"},
+	})
+	want := "Hi Jane from AcmeEnvironment: stagingBeta enabledaHi;bHi;[]"
+	if content := extractTextFromDOCX(t, output); content != want {
+		t.Fatalf("expected %q, got %q", want, content)
+	}
+	for _, key := range providerCalls {
+		if key == "name" || key == "greeting" || key == "company" {
+			t.Fatalf("provider must not be asked for key %q present in data", key)
+		}
+	}
+
+	// Later changes to the global data apply to templates already prepared.
+	engine.SetGlobalData(TemplateData{"greeting": "Welcome", "company": map[string]interface{}{"name": "Globex"}})
+	output = renderPreparedToBytes(t, tmpl, TemplateData{"name": "Jane", "items": []interface{}{}})
+	if content := extractTextFromDOCX(t, output); !strings.HasPrefix(content, "Welcome Jane from Globex") {
+		t.Fatalf("expected updated global data, got %q", content)
+	}
+}
+
+func TestEngineGlobalDataNotVisibleToOtherEngines(t *testing.T) {
+	engine := New()
+	engine.SetGlobalData(TemplateData{"name": "global"})
+
+	tmpl, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "[{{name}}]")))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if content := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{})); content != "[]" {
+		t.Fatalf("expected default engine to ignore other engine's global data, got %q", content)
+	}
+}
+
+func TestEngineConstants(t *testing.T) {
+	engine := New()
+	engine.SetGlobalData(TemplateData{"country": "DE"})
+	engine.RegisterConstants(map[string]interface{}{
+		"vatRate":      0.19,
+		"statusLabels": map[string]interface{}{"P": "Paid", "O": "Open"},
+	})
+	engine.RegisterConstants(map[string]interface{}{"currency": "EUR"})
+
+	tmpl, err := engine.Prepare(bytes.NewReader(createSimpleDOCX(t,
+		"{{country}} {{constants.vatRate}} {{constants.currency}} {{constants.statusLabels[status]}}")))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{"status": "P"})
+	if content := extractTextFromDOCX(t, output); content != "DE 0.19 EUR Paid" {
+		t.Fatalf("expected constants, got %q", content)
+	}
+
+	reader, err := tmpl.RenderWithOptions(TemplateData{"status": "O"}, RenderOptions{
+		Constants: map[string]interface{}{"vatRate": 0.2},
+	})
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	output, _ = io.ReadAll(reader)
+	if content := extractTextFromDOCX(t, output); content != "DE 0.2 EUR Open" {
+		t.Fatalf("expected overridden constant, got %q", content)
+	}
+
+	// Render data named "constants" hides the registered constants.
+	output = renderPreparedToBytes(t, tmpl, TemplateData{"status": "P", "constants": map[string]interface{}{
+		"vatRate":      0,
+		"statusLabels": map[string]interface{}{"P": "Bezahlt"},
+	}})
+	if content := extractTextFromDOCX(t, output); content != "DE 0  Bezahlt" {
+		t.Fatalf("expected render data to take precedence, got %q", content)
+	}
+}
+
+func TestEnginePostProcessors(t *testing.T) {
+	engine := New()
+	engine.SetGlobalData(TemplateData{"brand": "acme"})
+	var order []string
+	engine.AddPostProcessor(func(doc *Document, data TemplateData) error {
+		order = append(order, "style")
+		if data["brand"] != "acme" {
+			return nil
+		}
+		for _, table := range doc.Tables() {
+			if table.Properties == nil {
+				table.Properties = &TableProperties{}
+			}
+			table.Properties.Style = &Style{Val: "AcmeTable"}
+		}
+		return nil
+	})
+	engine.AddPostProcessor(func(doc *Document, data TemplateData) error {
+		order = append(order, "check")
+		if data["fail"] == true {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+
+	// A document without template tags is processed too.
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithBodyXML(t,
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{}))
+	if !strings.Contains(documentXML, `<w:tblStyle w:val="AcmeTable"`) {
+		t.Errorf("post-processor did not style the table: %s", documentXML)
+	}
+	if strings.Join(order, ",") != "style,check" {
+		t.Errorf("post-processors ran as %v", order)
+	}
+
+	documentXML = extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{"brand": "other"}))
+	if strings.Contains(documentXML, "AcmeTable") {
+		t.Errorf("post-processor styled the table for another brand: %s", documentXML)
+	}
+
+	if _, err := tmpl.Render(TemplateData{"fail": true}); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Render() error = %v, want the post-processor error", err)
+	}
+}
+
+func TestEnginePreProcessors(t *testing.T) {
+	engine := New()
+	engine.AddPreProcessor(func(data TemplateData) (TemplateData, error) {
+		name, ok := data["name"].(string)
+		if !ok {
+			return nil, errors.New("name is required")
+		}
+		data["name"] = strings.TrimSpace(name)
+		return data, nil
+	})
+	engine.AddPreProcessor(func(data TemplateData) (TemplateData, error) {
+		return TemplateData{"name": data["name"], "initial": data["name"].(string)[:1]}, nil
+	})
+
+	tmpl, err := engine.Prepare(bytes.NewReader(createSimpleDOCX(t, "[{{name}}] {{initial}} {{extra}}")))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	data := TemplateData{"name": "  Ada  ", "extra": "dropped"}
+	output := renderPreparedToBytes(t, tmpl, data)
+	if content := extractTextFromDOCX(t, output); content != "[Ada] A " {
+		t.Errorf("expected pre-processed data, got %q", content)
+	}
+	if data["name"] != "  Ada  " {
+		t.Errorf("pre-processor modified the caller's data: %q", data["name"])
+	}
+
+	if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("Render() error = %v, want the pre-processor error", err)
+	}
+}
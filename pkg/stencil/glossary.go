@@ -0,0 +1,191 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// The glossary document holds the building blocks of a template, such as
+// the Quick Parts and AutoText entries saved with it. Its placeholders are
+// rendered like those of a header, and {{include "Name"}} includes the
+// building block called Name when no fragment of that name is added to the
+// template.
+
+const glossaryDocumentPartName = "word/glossary/document.xml"
+
+// isGlossaryPartName reports whether name is the glossary document part.
+func isGlossaryPartName(name string) bool {
+	return name == glossaryDocumentPartName
+}
+
+// glossaryIndex is the index of the building blocks of a template by name.
+type glossaryIndex struct {
+	blocks map[string]glossaryBlock
+	// rootTag is the start tag of the glossary document, which declares the
+	// namespaces the building blocks use.
+	rootTag string
+}
+
+// glossaryBlock locates the content of a w:docPartBody element of the
+// glossary document.
+type glossaryBlock struct {
+	start, end int
+}
+
+// buildingBlock returns the building block called name as a fragment, or
+// nil if the template has none of that name.
+func (t *template) buildingBlock(name string) (*fragment, error) {
+	t.glossaryOnce.Do(func() {
+		if t.docxReader == nil {
+			return
+		}
+		content, err := t.docxReader.GetPart(glossaryDocumentPartName)
+		if err != nil {
+			return
+		}
+		index, err := indexGlossary(content)
+		if err != nil {
+			Warn("glossary document: %v; building blocks cannot be included", err)
+			return
+		}
+		t.glossary, t.glossaryContent = index, content
+	})
+	if t.glossary == nil {
+		return nil, nil
+	}
+	block, ok := t.glossary.blocks[name]
+	if !ok {
+		return nil, nil
+	}
+
+	docx, err := buildingBlockDOCX(t.docxReader, t.glossary.rootTag, t.glossaryContent[block.start:block.end])
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract building block %s: %w", name, err)
+	}
+	return newLazyDocxFragment(name, docx), nil
+}
+
+// indexGlossary indexes the building blocks of a glossary document by the
+// name in their w:docPartPr. The first of several blocks with the same name
+// wins.
+func indexGlossary(content []byte) (*glossaryIndex, error) {
+	rootStart, rootEnd := rootStartTagBounds(content)
+	if rootStart < 0 {
+		return nil, fmt.Errorf("no root element")
+	}
+	index := &glossaryIndex{
+		blocks:  make(map[string]glossaryBlock),
+		rootTag: string(content[rootStart:rootEnd]),
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	depth := 0
+	inProperties := false
+	var name string
+	var block *glossaryBlock
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 3 && t.Name.Local == "docPart":
+				name, block = "", nil
+			case depth == 4 && t.Name.Local == "docPartPr":
+				inProperties = true
+			case depth == 5 && inProperties && t.Name.Local == "name":
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "val" {
+						name = attr.Value
+					}
+				}
+			case depth == 4 && t.Name.Local == "docPartBody":
+				block = &glossaryBlock{start: int(decoder.InputOffset())}
+			}
+		case xml.EndElement:
+			switch {
+			case depth == 4 && t.Name.Local == "docPartPr":
+				inProperties = false
+			case depth == 4 && t.Name.Local == "docPartBody" && block != nil:
+				block.end = offset
+			case depth == 3 && t.Name.Local == "docPart":
+				if _, exists := index.blocks[name]; name != "" && block != nil && !exists {
+					index.blocks[name] = *block
+				}
+			}
+			depth--
+		}
+	}
+}
+
+// buildingBlockDOCX builds a DOCX fragment from the content of a building
+// block, with the relationships, media, styles and numbering of the
+// glossary document.
+func buildingBlockDOCX(reader *DocxReader, rootTag string, body []byte) ([]byte, error) {
+	root := xmlTagName(rootTag)
+	prefix := ""
+	if i := strings.IndexByte(root, ':'); i >= 0 {
+		prefix = root[:i+1]
+	}
+	rootTag = "<" + prefix + "document" + strings.TrimPrefix(rootTag, "<"+root)
+	if strings.HasSuffix(rootTag, "/>") {
+		rootTag = strings.TrimSuffix(rootTag, "/>") + ">"
+	}
+
+	pkg := &docxPackage{parts: make(map[string][]byte)}
+	pkg.set(contentTypesPartName, []byte(xmlDeclaration+
+		`<Types xmlns="`+contentTypesNamespace+`">`+
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`+
+		`<Default Extension="xml" ContentType="application/xml"/>`+
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>`+
+		`</Types>`))
+	document := xmlDeclaration + rootTag + "<" + prefix + "body>" + string(body) + "</" + prefix + "body></" + prefix + "document>"
+	pkg.set("word/document.xml", []byte(document))
+
+	for _, name := range []string{"styles.xml", "numbering.xml"} {
+		if content, err := reader.GetPart("word/glossary/" + name); err == nil {
+			pkg.set("word/"+name, content)
+		}
+	}
+
+	rels, err := reader.GetRelationships(glossaryDocumentPartName)
+	if err != nil {
+		return nil, err
+	}
+	if len(rels) > 0 {
+		// Media targets are relative to word/glossary; the fragment keeps its
+		// media in word/media
+		for i, rel := range rels {
+			if rel.TargetMode == "External" || !isMediaRelationship(rel) {
+				continue
+			}
+			source := path.Join("word/glossary", rel.Target)
+			content, err := reader.GetPart(source)
+			if err != nil {
+				continue
+			}
+			rels[i].Target = "media/" + path.Base(source)
+			pkg.set("word/"+rels[i].Target, content)
+		}
+		output, err := xml.Marshal(&Relationships{
+			Namespace:    relationshipsNamespace,
+			Relationship: rels,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pkg.set(documentRelationshipsPart, append([]byte(xmlDeclaration), output...))
+	}
+	return pkg.bytes()
+}
@@ -0,0 +1,208 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Glossary tables list the terms a document defines: {{defineTerm("SLA",
+// "Service Level Agreement")}} renders the term and records its definition,
+// and {{glossary()}} expands into a table of the recorded terms, sorted
+// alphabetically. Like exhibit letters, the table is built after rendering,
+// so it lists only the terms of content that made it into the document,
+// wherever glossary() stands.
+
+const (
+	defaultGlossaryTermTitle       = "Term"
+	defaultGlossaryDefinitionTitle = "Definition"
+
+	// glossaryTermWidth and glossaryDefinitionWidth are the column widths of
+	// the glossary table in twips
+	glossaryTermWidth       = 2700
+	glossaryDefinitionWidth = 6300
+)
+
+var (
+	// glossaryMarkerRegex matches the placeholder rendered by glossary():
+	// {{GLOSSARY_MARKER:<term title>:<definition title>}}.
+	glossaryMarkerRegex = regexp.MustCompile(`\{\{GLOSSARY_MARKER:([^:}]*):([^}]*)\}\}`)
+
+	// glossaryTermMarkerRegex matches the placeholder that follows the term
+	// rendered by defineTerm(): {{GLOSSARY_TERM:<index>}}.
+	glossaryTermMarkerRegex = regexp.MustCompile(`\{\{GLOSSARY_TERM:(\d+)\}\}`)
+)
+
+// definedTerm is a term defined by defineTerm().
+type definedTerm struct {
+	Term       string
+	Definition string
+}
+
+// defineTerm implements defineTerm(term, definition).
+func defineTerm(args ...interface{}) (interface{}, error) {
+	term := strings.TrimSpace(FormatValue(args[0]))
+	if term == "" {
+		return nil, fmt.Errorf("defineTerm: term cannot be empty")
+	}
+	definition := strings.TrimSpace(FormatValue(args[1]))
+	if definition == "" {
+		return nil, fmt.Errorf("defineTerm: definition of %q cannot be empty", term)
+	}
+	return &OOXMLFragment{Content: &definedTerm{Term: term, Definition: definition}}, nil
+}
+
+// glossaryTable renders the placeholder of glossary(termTitle,
+// definitionTitle). Empty titles leave out the header row.
+func glossaryTable(args ...interface{}) (interface{}, error) {
+	titles := []string{defaultGlossaryTermTitle, defaultGlossaryDefinitionTitle}
+	for i := range args {
+		if args[i] == nil {
+			continue
+		}
+		titles[i] = FormatValue(args[i])
+		if strings.ContainsAny(titles[i], ":{}") {
+			return nil, fmt.Errorf("glossary: title %q must not contain ':', '{' or '}'", titles[i])
+		}
+	}
+	return fmt.Sprintf("{{GLOSSARY_MARKER:%s:%s}}", titles[0], titles[1]), nil
+}
+
+// run records the term in the render and returns the run of the term,
+// followed by the placeholder that tells the glossary the term made it into
+// the document. Terms in headers, footers and notes are rendered without
+// being recorded.
+func (d *definedTerm) run(templateRun *Run, ctx *renderContext) Run {
+	text := d.Term
+	if ctx != nil && ctx.storyPart == "" {
+		text += "{{GLOSSARY_TERM:" + strconv.Itoa(len(ctx.definedTerms)) + "}}"
+		ctx.definedTerms = append(ctx.definedTerms, *d)
+	}
+	return Run{
+		Properties: templateRun.Properties,
+		Attrs:      templateRun.Attrs,
+		Text:       &Text{Content: text, Space: "preserve"},
+	}
+}
+
+// processGlossary removes the defineTerm() placeholders of a document and
+// replaces each body paragraph holding a glossary() placeholder with a
+// table of the terms; defined lists the terms recorded during the render.
+// A glossary without terms is removed. A term defined twice must
+// have the same definition both times.
+func processGlossary(doc *Document, defined []definedTerm) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	terms := make(map[string]string)
+	for _, para := range documentParagraphs(doc) {
+		text := para.GetText()
+		if !strings.Contains(text, "GLOSSARY_TERM:") {
+			continue
+		}
+		for _, match := range glossaryTermMarkerRegex.FindAllStringSubmatch(text, -1) {
+			index, _ := strconv.Atoi(match[1])
+			for _, run := range paragraphRunsInOrder(para) {
+				replaceRunPlaceholder(run, match[0], "")
+			}
+			if index >= len(defined) {
+				continue
+			}
+			term := defined[index]
+			if existing, ok := terms[term.Term]; ok && existing != term.Definition {
+				return fmt.Errorf("defineTerm: %q is defined as both %q and %q", term.Term, existing, term.Definition)
+			}
+			terms[term.Term] = term.Definition
+		}
+	}
+
+	var elements []BodyElement
+	for _, elem := range doc.Body.Elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			match := glossaryMarkerRegex.FindStringSubmatch(e.GetText())
+			if match == nil {
+				break
+			}
+			if len(terms) > 0 {
+				elements = append(elements, newGlossaryTable(terms, match[1], match[2]))
+			}
+			removeGlossaryMarkers(e)
+			if !paragraphHasContent(e) {
+				continue
+			}
+		case *Table:
+			// Glossaries are only expanded in body paragraphs
+			for _, para := range bodyElementParagraphs([]BodyElement{e}) {
+				removeGlossaryMarkers(para)
+			}
+		}
+		elements = append(elements, elem)
+	}
+	doc.Body.Elements = elements
+	return nil
+}
+
+// removeGlossaryMarkers removes the glossary() placeholders from the text
+// of para.
+func removeGlossaryMarkers(para *Paragraph) {
+	for _, marker := range glossaryMarkerRegex.FindAllString(para.GetText(), -1) {
+		for _, run := range paragraphRunsInOrder(para) {
+			replaceRunPlaceholder(run, marker, "")
+		}
+	}
+}
+
+// newGlossaryTable returns the table of terms, sorted alphabetically
+// regardless of case, with a bold header row of the titles unless both are
+// empty.
+func newGlossaryTable(terms map[string]string, termTitle, definitionTitle string) *Table {
+	names := make([]string, 0, len(terms))
+	for name := range terms {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := strings.ToLower(names[i]), strings.ToLower(names[j])
+		if a != b {
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+
+	header := 0
+	if termTitle != "" || definitionTitle != "" {
+		header = 1
+	}
+	table := NewTable(len(names)+header, 2)
+	table.Grid.Columns[0].Width = glossaryTermWidth
+	table.Grid.Columns[1].Width = glossaryDefinitionWidth
+	for i := range table.Rows {
+		table.Rows[i].Cells[0].Properties.Width.Val = glossaryTermWidth
+		table.Rows[i].Cells[1].Properties.Width.Val = glossaryDefinitionWidth
+	}
+	if header == 1 {
+		bold := ParagraphOptions{Run: RunProps{Bold: true}}
+		table.Cell(0, 0).SetText(termTitle, bold)
+		table.Cell(0, 1).SetText(definitionTitle, bold)
+	}
+	for i, name := range names {
+		table.Cell(i+header, 0).SetText(name, ParagraphOptions{})
+		table.Cell(i+header, 1).SetText(terms[name], ParagraphOptions{})
+	}
+	return table
+}
+
+// registerGlossaryFunctions registers the defineTerm() and glossary()
+// functions
+func registerGlossaryFunctions(registry *DefaultFunctionRegistry) {
+	// defineTerm() function - renders a term and records its definition
+	defineTermFn := NewSimpleFunction("defineTerm", 2, 2, defineTerm)
+	registry.RegisterFunction(defineTermFn)
+
+	// glossary() function - expands into a table of the defined terms
+	glossaryFn := NewSimpleFunction("glossary", 0, 2, glossaryTable)
+	registry.RegisterFunction(glossaryFn)
+}
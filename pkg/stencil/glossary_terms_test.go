@@ -0,0 +1,72 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGlossary(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Glossary`,
+		`{{glossary()}}`,
+		`The {{defineTerm("SLA", "Service Level Agreement")}} covers {{defineTerm("api", "Application Programming Interface")}} uptime.`,
+		`{{if withRPO}}Backups meet the {{defineTerm("RPO", "Recovery Point Objective")}}.{{end}}`,
+		`Breaches of the {{defineTerm("SLA", "Service Level Agreement")}} are credited.`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	tests := []struct {
+		withRPO bool
+		rows    int
+		want    []string
+	}{
+		{true, 4, []string{"Glossary", "Term", "Definition", "api", "Application Programming Interface", "RPO", "Recovery Point Objective", "SLA", "Service Level Agreement", "The SLA covers api uptime.", "Backups meet the RPO."}},
+		{false, 3, []string{"Glossary", "Term", "Definition", "api", "Application Programming Interface", "SLA", "Service Level Agreement", "The SLA covers api uptime."}},
+	}
+	for _, tt := range tests {
+		output := renderPreparedToBytes(t, tmpl, TemplateData{"withRPO": tt.withRPO})
+		text := extractTextFromDOCX(t, output)
+		assertInOrder(t, text, tt.want...)
+		if strings.Contains(text, "GLOSSARY_") {
+			t.Errorf("unresolved marker in %q", text)
+		}
+		if !tt.withRPO && strings.Contains(text, "Recovery Point Objective") {
+			t.Errorf("term of omitted content listed: %q", text)
+		}
+		if got := strings.Count(extractDocumentXMLFromDOCX(t, output), "<w:tr>"); got != tt.rows {
+			t.Errorf("glossary has %d rows, want %d", got, tt.rows)
+		}
+	}
+}
+
+func TestGlossaryOptions(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{glossary("", "")}}`,
+		`{{defineTerm("KPI", "Key Performance Indicator")}}`,
+	})
+	text := extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{}))
+	if strings.Contains(text, "Term") || !strings.Contains(text, "Key Performance Indicator") {
+		t.Errorf("glossary without header = %q", text)
+	}
+
+	empty := createDOCXWithParagraphs(t, []string{`Intro`, `{{glossary()}}`})
+	if xml := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, empty, TemplateData{}, RenderOptions{})); strings.Contains(xml, "<w:tbl>") || strings.Contains(xml, "GLOSSARY") {
+		t.Errorf("glossary without terms rendered: %s", xml)
+	}
+
+	conflict, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`{{defineTerm("PO", "Purchase Order")}} {{defineTerm("PO", "Product Owner")}}`,
+	})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer conflict.Close()
+	if _, err := conflict.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), `"PO" is defined as both "Purchase Order" and "Product Owner"`) {
+		t.Errorf("Render error = %v, want conflicting definitions", err)
+	}
+}
@@ -0,0 +1,125 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func createDOCXWithGlossary(t *testing.T, bodyXML, docPartsXML string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+  <Default Extension="xml" ContentType="application/xml"/>
+  <Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+  <Override PartName="/word/glossary/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.glossary+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`},
+		{"word/_rels/document.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/glossaryDocument" Target="glossary/document.xml"/>
+</Relationships>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + bodyXML + `</w:body></w:document>`},
+		{"word/glossary/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:glossaryDocument xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:docParts>` + docPartsXML + `</w:docParts></w:glossaryDocument>`},
+	}
+	for _, part := range parts {
+		f, err := w.Create(part.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(part.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func glossaryDocPart(name, bodyXML string) string {
+	return `<w:docPart><w:docPartPr><w:name w:val="` + name + `"/><w:category><w:name w:val="General"/></w:category></w:docPartPr>` +
+		`<w:docPartBody>` + bodyXML + `</w:docPartBody></w:docPart>`
+}
+
+func TestGlossaryBuildingBlocks(t *testing.T) {
+	docx := createDOCXWithGlossary(t,
+		`<w:p><w:r><w:t>Dear {{name}},</w:t></w:r></w:p><w:p><w:r><w:t>{{include "Signature Block"}}</w:t></w:r></w:p>`,
+		glossaryDocPart("Signature Block", `<w:p><w:r><w:t>Regards, {{sender}}</w:t></w:r></w:p>`)+
+			glossaryDocPart("Disclaimer", `<w:p><w:r><w:t>{{company}} accepts no liability.</w:t></w:r></w:p>`))
+	data := TemplateData{"name": "Ada", "sender": "Grace", "company": "Acme"}
+
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{})
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Dear Ada,") || !strings.Contains(text, "Regards, Grace") {
+		t.Errorf("building block not included: %q", text)
+	}
+	glossary := extractPartFromDOCX(t, output, "word/glossary/document.xml")
+	for _, want := range []string{"Regards, Grace", "Acme accepts no liability.", `<w:name w:val="Signature Block"/>`} {
+		if !strings.Contains(glossary, want) {
+			t.Errorf("rendered glossary missing %q: %s", want, glossary)
+		}
+	}
+
+	t.Run("added fragments take precedence", func(t *testing.T) {
+		tmpl, err := prepare(bytes.NewReader(docx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tmpl.Close()
+		if err := tmpl.AddFragment("Signature Block", "Sincerely"); err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(output)
+		if text := extractTextFromDOCX(t, buf.Bytes()); !strings.Contains(text, "Sincerely") || strings.Contains(text, "Regards") {
+			t.Errorf("added fragment not used: %q", text)
+		}
+	})
+
+	t.Run("unknown building block", func(t *testing.T) {
+		tmpl, err := prepare(bytes.NewReader(createDOCXWithGlossary(t,
+			`<w:p><w:r><w:t>{{include "Letterhead"}}</w:t></w:r></w:p>`,
+			glossaryDocPart("Signature Block", `<w:p><w:r><w:t>Regards</w:t></w:r></w:p>`))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tmpl.Close()
+		if _, err := tmpl.Render(data); err == nil || !strings.Contains(err.Error(), "fragment not found: Letterhead") {
+			t.Errorf("expected a missing fragment error, got %v", err)
+		}
+	})
+}
+
+func TestIndexGlossary(t *testing.T) {
+	content := []byte(`<?xml version="1.0"?><w:glossaryDocument xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:docParts>` +
+		glossaryDocPart("A", `<w:p><w:r><w:t>first</w:t></w:r></w:p>`) +
+		glossaryDocPart("A", `<w:p><w:r><w:t>second</w:t></w:r></w:p>`) +
+		`<w:docPart><w:docPartPr><w:name w:val="Empty"/></w:docPartPr><w:docPartBody/></w:docPart>` +
+		`</w:docParts></w:glossaryDocument>`)
+
+	index, err := indexGlossary(content)
+	if err != nil {
+		t.Fatalf("indexGlossary failed: %v", err)
+	}
+	if len(index.blocks) != 2 {
+		t.Fatalf("blocks = %v, want A and Empty", index.blocks)
+	}
+	if block := index.blocks["A"]; string(content[block.start:block.end]) != `<w:p><w:r><w:t>first</w:t></w:r></w:p>` {
+		t.Errorf("block A = %q", content[block.start:block.end])
+	}
+	if block := index.blocks["Empty"]; block.start != block.end {
+		t.Errorf("block Empty = %q, want empty", content[block.start:block.end])
+	}
+}
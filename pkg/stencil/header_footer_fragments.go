@@ -0,0 +1,347 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerRelationType                   = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/header"
+	footerRelationType                   = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer"
+	headerContentType                    = "application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"
+	footerContentType                    = "application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"
+	officeDocumentRelationshipsNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+)
+
+var (
+	sectPrStartTagRegex   = regexp.MustCompile(`<w:sectPr\b[^>]*?(/?)>`)
+	documentStartTagRegex = regexp.MustCompile(`<w:document\b[^>]*>`)
+)
+
+// headerFooterKind identifies whether a fragment replaces headers or footers.
+type headerFooterKind string
+
+const (
+	headerFragmentKind headerFooterKind = "header"
+	footerFragmentKind headerFooterKind = "footer"
+)
+
+func (k headerFooterKind) rootElement() string {
+	if k == headerFragmentKind {
+		return "w:hdr"
+	}
+	return "w:ftr"
+}
+
+func (k headerFooterKind) referenceElement() string {
+	if k == headerFragmentKind {
+		return "w:headerReference"
+	}
+	return "w:footerReference"
+}
+
+func (k headerFooterKind) relationType() string {
+	if k == headerFragmentKind {
+		return headerRelationType
+	}
+	return footerRelationType
+}
+
+func (k headerFooterKind) contentType() string {
+	if k == headerFragmentKind {
+		return headerContentType
+	}
+	return footerContentType
+}
+
+func (k headerFooterKind) matchesPart(name string) bool {
+	if k == headerFragmentKind {
+		return isHeaderPartName(name)
+	}
+	return isFooterPartName(name)
+}
+
+// renderedHeaderFooterFragment holds a header or footer fragment rendered
+// during the body pass, waiting to be written into the output package.
+type renderedHeaderFooterFragment struct {
+	kind          headerFooterKind
+	content       []byte
+	namespaces    map[string]string
+	relationships []Relationship
+}
+
+// SetHeaderFragment adds a DOCX fragment and designates it as the header of
+// the rendered document. The body of the fragment is rendered with the same
+// data as the template and replaces the content of every header part. If the
+// template has no header, one is created and referenced from each section.
+//
+// Example:
+//
+//	headerBytes, err := os.ReadFile("corporate-header.docx")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = template.SetHeaderFragment("Corporate Header", headerBytes)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func (pt *PreparedTemplate) SetHeaderFragment(name string, docxBytes []byte) error {
+	return pt.setHeaderFooterFragment(headerFragmentKind, name, docxBytes)
+}
+
+// SetFooterFragment adds a DOCX fragment and designates it as the footer of
+// the rendered document. It behaves like SetHeaderFragment for footers.
+func (pt *PreparedTemplate) SetFooterFragment(name string, docxBytes []byte) error {
+	return pt.setHeaderFooterFragment(footerFragmentKind, name, docxBytes)
+}
+
+func (pt *PreparedTemplate) setHeaderFooterFragment(kind headerFooterKind, name string, docxBytes []byte) error {
+	if pt == nil {
+		return fmt.Errorf("invalid template")
+	}
+	if name == "" {
+		return fmt.Errorf("%s fragment name cannot be empty", kind)
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return errTemplateClosed
+	}
+
+	if err := validateDocxFragmentBytes(docxBytes); err != nil {
+		return err
+	}
+
+	frag := newLazyDocxFragment(name, docxBytes)
+	tmpl := pt.template
+	tmpl.mu.Lock()
+	defer tmpl.mu.Unlock()
+	if tmpl.fragments == nil {
+		tmpl.fragments = make(map[string]*fragment)
+	}
+	tmpl.fragments[name] = frag
+	delete(tmpl.resolverMisses, name)
+	if kind == headerFragmentKind {
+		tmpl.headerFragment = name
+	} else {
+		tmpl.footerFragment = name
+	}
+	tmpl.invalidateFragmentCachesLocked()
+	return nil
+}
+
+// renderHeaderFooterFragments renders the designated header and footer
+// fragments. Styles, numbering and media are merged through the shared render
+// context; relationships are collected separately because they belong to the
+// header or footer part rather than the main document.
+func renderHeaderFooterFragments(data TemplateData, ctx *renderContext) error {
+	designated := []struct {
+		kind headerFooterKind
+		name string
+	}{
+		{headerFragmentKind, ctx.headerFragment},
+		{footerFragmentKind, ctx.footerFragment},
+	}
+
+	for _, d := range designated {
+		if d.name == "" {
+			continue
+		}
+		frag := ctx.fragments[d.name]
+		if frag == nil {
+			return withErrorCode(ErrorCodeMissingFragment, fmt.Errorf("%s fragment not found: %s", d.kind, d.name))
+		}
+
+		span, endSpan := ctx.startSpan(spanRenderHeaderFooter)
+		span.SetAttribute(attrFragment, d.name)
+		span.SetAttribute(attrFragmentKind, string(d.kind))
+
+		partCtx := *ctx
+		partCtx.fragmentStack = make([]string, 0)
+		partCtx.storyPart = string(d.kind) + " fragment " + d.name
+		partCtx.fragmentRelationships = make([]Relationship, 0)
+		partCtx.fragmentIDMaps = make(map[string]map[string]string)
+		// The part is written without any existing relationships.
+		partCtx.relationshipIDs = newRelationshipIDAllocator(nil)
+
+		elements, err := renderIncludedFragment(d.name, frag, data, &partCtx)
+		if err != nil {
+			endSpan(err)
+			return fmt.Errorf("failed to render %s fragment %s: %w", d.kind, d.name, err)
+		}
+		if err := partCtx.checkEditableRegionsClosed(string(d.kind) + " fragment " + d.name); err != nil {
+			endSpan(err)
+			return err
+		}
+		normalizeRenderedBodyElements(elements)
+		renumberSEQFieldsInElements(elements)
+
+		content, err := encodeStoryElements(elements)
+		endSpan(err)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s fragment %s: %w", d.kind, d.name, err)
+		}
+		ctx.headerFooterFragments = append(ctx.headerFooterFragments, renderedHeaderFooterFragment{
+			kind:          d.kind,
+			content:       content,
+			namespaces:    frag.namespaces,
+			relationships: partCtx.fragmentRelationships,
+		})
+	}
+	return nil
+}
+
+// applyHeaderFooterFragments writes rendered header and footer fragments into
+// a rendered DOCX package.
+func applyHeaderFooterFragments(output []byte, rendered []renderedHeaderFooterFragment) ([]byte, error) {
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+	for _, r := range rendered {
+		if err := applyHeaderFooterFragment(pkg, r); err != nil {
+			return nil, NewDocumentError("write", string(r.kind)+" fragment", err)
+		}
+	}
+	return pkg.bytes()
+}
+
+func applyHeaderFooterFragment(pkg *docxPackage, r renderedHeaderFooterFragment) error {
+	var parts []string
+	for _, name := range pkg.names {
+		if r.kind.matchesPart(name) {
+			parts = append(parts, name)
+		}
+	}
+	sort.Strings(parts)
+
+	if len(parts) == 0 {
+		partName := nextHeaderFooterPartName(pkg, r.kind)
+		relID, err := pkg.ensureRelationship(documentRelationshipsPart, r.kind.relationType(), path.Base(partName))
+		if err != nil {
+			return err
+		}
+		if err := pkg.ensureContentTypeOverride(partName, r.kind.contentType()); err != nil {
+			return err
+		}
+		documentXML, ok := pkg.get("word/document.xml")
+		if !ok {
+			return fmt.Errorf("word/document.xml not found")
+		}
+		pkg.set("word/document.xml", addSectionHeaderFooterReference(documentXML, r.kind, relID))
+		parts = []string{partName}
+	}
+
+	for _, partName := range parts {
+		existing, _ := pkg.get(partName)
+		pkg.set(partName, headerFooterPartXML(existing, r))
+
+		relsPart := "word/_rels/" + path.Base(partName) + ".rels"
+		if len(r.relationships) == 0 {
+			// The previous content is replaced, so its relationships are stale.
+			pkg.remove(relsPart)
+			continue
+		}
+		relsXML, err := xml.Marshal(&Relationships{
+			Namespace:    relationshipsNamespace,
+			Relationship: r.relationships,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", relsPart, err)
+		}
+		pkg.set(relsPart, append([]byte(xmlDeclaration), relsXML...))
+	}
+	return nil
+}
+
+func nextHeaderFooterPartName(pkg *docxPackage, kind headerFooterKind) string {
+	for i := 1; ; i++ {
+		name := "word/" + string(kind) + strconv.Itoa(i) + ".xml"
+		if _, exists := pkg.get(name); !exists {
+			return name
+		}
+	}
+}
+
+// headerFooterPartXML builds a header or footer part around the rendered
+// fragment content. The root element of an existing part is kept with all
+// its attributes, along with any comments and processing instructions
+// before it, and declares any namespaces the fragment needs in addition.
+func headerFooterPartXML(existing []byte, r renderedHeaderFooterFragment) []byte {
+	prolog := []byte(xmlDeclaration)
+	_, rootLocal, _ := strings.Cut(r.kind.rootElement(), ":")
+	root := xml.StartElement{Name: xml.Name{Space: "w", Local: rootLocal}}
+	if tag, start, _, ok := rootStartTag(existing); ok && tag.Name.Local == rootLocal {
+		root = tag
+		prolog = existing[:start]
+		if !bytes.HasPrefix(prolog, []byte("<?xml")) {
+			prolog = append([]byte(xmlDeclaration), prolog...)
+		}
+	}
+
+	namespaces := map[string]string{
+		"w": wordprocessingMLNamespace,
+		"r": officeDocumentRelationshipsNamespace,
+	}
+	for prefix, uri := range r.namespaces {
+		if prefix != "" {
+			namespaces[prefix] = uri
+		}
+	}
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		if !declaresNamespacePrefix(root, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		root.Attr = append(root.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: namespaces[prefix]})
+	}
+
+	var out bytes.Buffer
+	out.Write(prolog)
+	encodeRawStartTag(&out, root)
+	out.Write(r.content)
+	out.WriteString("</" + rawXMLName(root.Name) + ">")
+	return out.Bytes()
+}
+
+// addSectionHeaderFooterReference references a header or footer part as the
+// default for every section of the document. A section properties element is
+// created when the document has none.
+func addSectionHeaderFooterReference(documentXML []byte, kind headerFooterKind, relID string) []byte {
+	content := string(documentXML)
+	reference := `<` + kind.referenceElement() + ` w:type="default" r:id="` + relID + `"/>`
+
+	if sectPrStartTagRegex.MatchString(content) {
+		content = sectPrStartTagRegex.ReplaceAllStringFunc(content, func(tag string) string {
+			if strings.HasSuffix(tag, "/>") {
+				return strings.TrimSuffix(tag, "/>") + ">" + reference + "</w:sectPr>"
+			}
+			return tag + reference
+		})
+	} else if idx := strings.LastIndex(content, "</w:body>"); idx != -1 {
+		content = content[:idx] + "<w:sectPr>" + reference + "</w:sectPr>" + content[idx:]
+	}
+
+	return declareDocumentRelationshipsNamespace([]byte(content))
+}
+
+// declareDocumentRelationshipsNamespace declares the r prefix of
+// relationship IDs on the root of documentXML unless it is declared.
+func declareDocumentRelationshipsNamespace(documentXML []byte) []byte {
+	content := string(documentXML)
+	if startTag := documentStartTagRegex.FindString(content); startTag != "" && !strings.Contains(startTag, "xmlns:r=") {
+		updated := strings.TrimSuffix(startTag, ">") + ` xmlns:r="` + officeDocumentRelationshipsNamespace + `">`
+		content = strings.Replace(content, startTag, updated, 1)
+	}
+	return []byte(content)
+}
@@ -0,0 +1,153 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func renderPreparedToBytes(t *testing.T, tmpl *PreparedTemplate, data TemplateData) []byte {
+	t.Helper()
+
+	reader, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return output
+}
+
+func TestSetHeaderFragment_CreatesHeaderPart(t *testing.T) {
+	tmpl, err := prepare(bytes.NewReader(createSimpleDOCX(t, "Body {{company}}")))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if err := tmpl.SetHeaderFragment("Corporate Header", createFragmentDOCX(t, "Header for {{company}}")); err != nil {
+		t.Fatalf("failed to set header fragment: %v", err)
+	}
+	if err := tmpl.SetFooterFragment("Corporate Footer", createFragmentDOCX(t, "Footer text")); err != nil {
+		t.Fatalf("failed to set footer fragment: %v", err)
+	}
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{"company": "Acme"})
+
+	header := extractPartFromDOCX(t, output, "word/header1.xml")
+	if !strings.HasPrefix(header, xmlDeclaration+"<w:hdr") || !strings.Contains(extractTextFromDocumentXML(header), "Header for Acme") {
+		t.Fatalf("expected rendered header part, got %s", header)
+	}
+	footer := extractPartFromDOCX(t, output, "word/footer1.xml")
+	if !strings.Contains(extractTextFromDocumentXML(footer), "Footer text") {
+		t.Fatalf("expected rendered footer part, got %s", footer)
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	if !strings.Contains(documentXML, `<w:headerReference w:type="default" r:id="`) ||
+		!strings.Contains(documentXML, `<w:footerReference w:type="default" r:id="`) {
+		t.Fatalf("expected section references to header and footer, got %s", documentXML)
+	}
+	if !strings.Contains(documentXML, `xmlns:r="`+officeDocumentRelationshipsNamespace+`"`) {
+		t.Fatalf("expected relationships namespace on document root, got %s", documentXML)
+	}
+
+	rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")
+	if !strings.Contains(rels, `Target="header1.xml"`) || !strings.Contains(rels, `Target="footer1.xml"`) {
+		t.Fatalf("expected header/footer relationships, got %s", rels)
+	}
+	contentTypes := extractPartFromDOCX(t, output, "[Content_Types].xml")
+	if !strings.Contains(contentTypes, headerContentType) || !strings.Contains(contentTypes, footerContentType) {
+		t.Fatalf("expected header/footer content types, got %s", contentTypes)
+	}
+}
+
+func TestSetHeaderFragment_ReplacesExistingHeader(t *testing.T) {
+	docx := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:r><w:t>Body</w:t></w:r></w:p><w:sectPr><w:headerReference w:type="default" r:id="rId5"/></w:sectPr></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId5" Type="` + headerRelationType + `" Target="header1.xml"/></Relationships>`),
+		"word/header1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:p><w:r><w:t>Old header</w:t></w:r></w:p></w:hdr>`),
+		"word/_rels/header1.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/old.png"/></Relationships>`),
+	})
+
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if err := tmpl.SetHeaderFragment("header", createFragmentDOCX(t, "New header {{n}}")); err != nil {
+		t.Fatalf("failed to set header fragment: %v", err)
+	}
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{"n": 2})
+
+	header := extractPartFromDOCX(t, output, "word/header1.xml")
+	text := extractTextFromDocumentXML(header)
+	if strings.Contains(text, "Old header") || !strings.Contains(text, "New header 2") {
+		t.Fatalf("expected header content to be replaced, got %s", header)
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	if strings.Count(documentXML, "<w:headerReference") != 1 {
+		t.Fatalf("expected the existing header reference to be kept as is, got %s", documentXML)
+	}
+
+	for _, name := range docxPartNames(t, output) {
+		if name == "word/_rels/header1.xml.rels" {
+			t.Fatalf("expected stale header relationships to be removed")
+		}
+	}
+}
+
+func TestAddSectionHeaderFooterReference_SelfClosingSectPr(t *testing.T) {
+	documentXML := []byte(`<w:document xmlns:w="` + wordprocessingMLNamespace + `"><w:body><w:sectPr/></w:body></w:document>`)
+	updated := string(addSectionHeaderFooterReference(documentXML, footerFragmentKind, "rId9"))
+	if !strings.Contains(updated, `<w:sectPr><w:footerReference w:type="default" r:id="rId9"/></w:sectPr>`) {
+		t.Fatalf("expected footer reference in expanded sectPr, got %s", updated)
+	}
+}
+
+func TestHeaderFooterPartXML_KeepsRootAndProlog(t *testing.T) {
+	existing := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!-- generated > by a tool --><?mso-application progid="Word.Document"?>` +
+		`<w:hdr xmlns:w="` + wordprocessingMLNamespace + `" xmlns:mc="urn:mc" mc:Ignorable="w14 &amp; wp14"/>`)
+	rendered := renderedHeaderFooterFragment{
+		kind:       headerFragmentKind,
+		content:    []byte(`<w:p><w:r><w:t>Header</w:t></w:r></w:p>`),
+		namespaces: map[string]string{"custom": "urn:example:custom"},
+	}
+
+	got := string(headerFooterPartXML(existing, rendered))
+	want := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!-- generated > by a tool --><?mso-application progid="Word.Document"?>` +
+		`<w:hdr xmlns:w="` + wordprocessingMLNamespace + `" xmlns:mc="urn:mc" mc:Ignorable="w14 &amp; wp14"` +
+		` xmlns:custom="urn:example:custom" xmlns:r="` + officeDocumentRelationshipsNamespace + `">` +
+		`<w:p><w:r><w:t>Header</w:t></w:r></w:p></w:hdr>`
+	if got != want {
+		t.Fatalf("unexpected part:\n got: %s\nwant: %s", got, want)
+	}
+
+	// A part with another root is not reused
+	got = string(headerFooterPartXML([]byte(`<w:ftr xmlns:w="`+wordprocessingMLNamespace+`"/>`), rendered))
+	if !strings.HasPrefix(got, xmlDeclaration+`<w:hdr xmlns:custom=`) || !strings.HasSuffix(got, `</w:hdr>`) {
+		t.Fatalf("expected a new header root, got %s", got)
+	}
+}
+
+func docxPartNames(t *testing.T, docx []byte) []string {
+	t.Helper()
+
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		t.Fatalf("failed to read package: %v", err)
+	}
+	return pkg.names
+}
@@ -0,0 +1,203 @@
+package stencil
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// headingStyleRegex matches the style IDs of the built-in heading styles and
+// captures their level.
+var headingStyleRegex = regexp.MustCompile(`^[Hh]eading ?([1-9])$`)
+
+// HouseStyle is a house-style profile the rendered document is made to
+// follow (RenderOptions.HouseStyle), so documents assembled from many
+// fragments look uniform. Deviations in the direct formatting of the main
+// document are rewritten and reported; formatting that comes from styles is
+// left to the styles of the template.
+type HouseStyle struct {
+	// Fonts lists the allowed fonts. A run with a font that is not listed
+	// gets the first one. When empty, any font is allowed.
+	Fonts []string
+	// HeadingSizes maps heading levels, 1 for the Heading1 style, to their
+	// font size in points. Runs of a heading that set another size get it.
+	HeadingSizes map[int]float64
+	// TableBorders is the border every table gets on all of its edges and
+	// between its cells. When nil, table borders are left alone.
+	TableBorders *HouseStyleBorder
+	// OnDeviation receives each deviation that was rewritten. When nil,
+	// deviations are logged as warnings.
+	OnDeviation func(StyleDeviation)
+}
+
+// HouseStyleBorder is a table border of a HouseStyle.
+type HouseStyleBorder struct {
+	// Style is the border style, such as "single" or "none".
+	Style string
+	// Size is the width of the border in eighths of a point.
+	Size int
+	// Color is the hex color of the border, such as "000000", or "auto".
+	Color string
+}
+
+// StyleDeviation is a deviation from a HouseStyle found in a rendered
+// document.
+type StyleDeviation struct {
+	// Rule is the rule that was violated: "font", "headingSize" or
+	// "tableBorders".
+	Rule string
+	// Location describes where the deviation was found, such as
+	// "paragraph 4" or "table 2, row 1, cell 3, paragraph 1".
+	Location string
+	// Found is the formatting that was found and Applied the formatting
+	// that replaced it.
+	Found   string
+	Applied string
+}
+
+func (d StyleDeviation) String() string {
+	return fmt.Sprintf("%s: %s %q replaced with %q", d.Location, d.Rule, d.Found, d.Applied)
+}
+
+func (s *HouseStyle) report(deviation StyleDeviation) {
+	if s.OnDeviation != nil {
+		s.OnDeviation(deviation)
+		return
+	}
+	Warn("house style: %s", deviation)
+}
+
+// applyHouseStyle rewrites the deviations of the body elements from the
+// house style and reports them.
+func applyHouseStyle(elements []BodyElement, style *HouseStyle) {
+	paragraphs, tables := 0, 0
+	for _, elem := range elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			paragraphs++
+			style.applyToParagraph(e, "paragraph "+strconv.Itoa(paragraphs))
+		case *Table:
+			tables++
+			location := "table " + strconv.Itoa(tables)
+			style.applyToTable(e, location)
+			for rowIdx := range e.Rows {
+				for cellIdx := range e.Rows[rowIdx].Cells {
+					cell := &e.Rows[rowIdx].Cells[cellIdx]
+					for paraIdx := range cell.Paragraphs {
+						style.applyToParagraph(&cell.Paragraphs[paraIdx], fmt.Sprintf("%s, row %d, cell %d, paragraph %d", location, rowIdx+1, cellIdx+1, paraIdx+1))
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *HouseStyle) applyToParagraph(para *Paragraph, location string) {
+	headingSize := 0
+	if para.Properties != nil && para.Properties.Style != nil {
+		if match := headingStyleRegex.FindStringSubmatch(para.Properties.Style.Val); match != nil {
+			level, _ := strconv.Atoi(match[1])
+			if points, ok := s.HeadingSizes[level]; ok {
+				headingSize = int(math.Round(points * 2))
+			}
+		}
+	}
+
+	for _, run := range paragraphRunsInOrder(para) {
+		if run.Properties == nil {
+			continue
+		}
+		if font := run.Properties.Font; font != nil && len(s.Fonts) > 0 {
+			if found := s.disallowedFont(font); found != "" {
+				for _, name := range []*string{&font.ASCII, &font.HAnsi, &font.CS, &font.EastAsia} {
+					if *name != "" {
+						*name = s.Fonts[0]
+					}
+				}
+				s.report(StyleDeviation{Rule: "font", Location: location, Found: found, Applied: s.Fonts[0]})
+			}
+		}
+		if size := run.Properties.Size; size != nil && headingSize > 0 && size.Val != headingSize {
+			s.report(StyleDeviation{Rule: "headingSize", Location: location, Found: halfPointsText(size.Val), Applied: halfPointsText(headingSize)})
+			size.Val = headingSize
+			if run.Properties.SizeCs != nil {
+				run.Properties.SizeCs.Val = headingSize
+			}
+		}
+	}
+}
+
+// disallowedFont returns the first font of a run that the house style does
+// not allow, or "" when all are allowed.
+func (s *HouseStyle) disallowedFont(font *Font) string {
+	for _, name := range []string{font.ASCII, font.HAnsi, font.CS, font.EastAsia} {
+		if name == "" {
+			continue
+		}
+		allowed := false
+		for _, candidate := range s.Fonts {
+			if strings.EqualFold(name, candidate) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return name
+		}
+	}
+	return ""
+}
+
+func (s *HouseStyle) applyToTable(table *Table, location string) {
+	if s.TableBorders == nil {
+		return
+	}
+	want := &BorderProperties{
+		Val:   s.TableBorders.Style,
+		Sz:    strconv.Itoa(s.TableBorders.Size),
+		Color: s.TableBorders.Color,
+	}
+
+	if table.Properties == nil {
+		table.Properties = &TableProperties{}
+	}
+	found := "none"
+	if borders := table.Properties.Borders; borders != nil {
+		matches := true
+		for _, border := range []*BorderProperties{borders.Top, borders.Left, borders.Bottom, borders.Right, borders.InsideH, borders.InsideV} {
+			if border == nil || border.Val != want.Val || border.Sz != want.Sz || border.Color != want.Color {
+				matches = false
+				if border != nil {
+					found = borderText(border)
+				}
+				break
+			}
+		}
+		if matches {
+			return
+		}
+	}
+
+	table.Properties.Borders = &TableBorders{}
+	for _, edge := range []**BorderProperties{
+		&table.Properties.Borders.Top, &table.Properties.Borders.Left,
+		&table.Properties.Borders.Bottom, &table.Properties.Borders.Right,
+		&table.Properties.Borders.InsideH, &table.Properties.Borders.InsideV,
+	} {
+		border := *want
+		*edge = &border
+	}
+	s.report(StyleDeviation{Rule: "tableBorders", Location: location, Found: found, Applied: borderText(want)})
+}
+
+// halfPointsText formats a font size in half-points as points, such as "14pt".
+func halfPointsText(halfPoints int) string {
+	return strconv.FormatFloat(float64(halfPoints)/2, 'f', -1, 64) + "pt"
+}
+
+// borderText describes a border, such as "single 4 000000".
+func borderText(border *BorderProperties) string {
+	return strings.TrimSpace(border.Val + " " + border.Sz + " " + border.Color)
+}
@@ -0,0 +1,62 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderWithHouseStyle(t *testing.T) {
+	docx := createDOCXWithBodyXML(t,
+		`<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Comic Sans MS" w:hAnsi="Comic Sans MS"/><w:sz w:val="40"/><w:szCs w:val="40"/></w:rPr><w:t>{{title}}</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:rPr><w:rFonts w:ascii="arial"/><w:sz w:val="40"/></w:rPr><w:t>Body</w:t></w:r></w:p>`+
+			`<w:tbl><w:tblPr><w:tblBorders><w:top w:val="double" w:sz="12" w:color="FF0000"/></w:tblBorders></w:tblPr>`+
+			`<w:tr><w:tc><w:p><w:r><w:rPr><w:rFonts w:ascii="Papyrus"/></w:rPr><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`)
+
+	var deviations []StyleDeviation
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"title": "Report"}, RenderOptions{HouseStyle: &HouseStyle{
+		Fonts:        []string{"Arial", "Calibri"},
+		HeadingSizes: map[int]float64{1: 16},
+		TableBorders: &HouseStyleBorder{Style: "single", Size: 4, Color: "000000"},
+		OnDeviation:  func(d StyleDeviation) { deviations = append(deviations, d) },
+	}})
+
+	want := []StyleDeviation{
+		{Rule: "font", Location: "paragraph 1", Found: "Comic Sans MS", Applied: "Arial"},
+		{Rule: "headingSize", Location: "paragraph 1", Found: "20pt", Applied: "16pt"},
+		{Rule: "tableBorders", Location: "table 1", Found: "double 12 FF0000", Applied: "single 4 000000"},
+		{Rule: "font", Location: "table 1, row 1, cell 1, paragraph 1", Found: "Papyrus", Applied: "Arial"},
+	}
+	if !reflect.DeepEqual(deviations, want) {
+		t.Errorf("deviations =\n%+v\nwant\n%+v", deviations, want)
+	}
+
+	document := extractPartFromDOCX(t, output, "word/document.xml")
+	for _, unwanted := range []string{"Comic Sans MS", "Papyrus", `w:val="double"`} {
+		if strings.Contains(document, unwanted) {
+			t.Errorf("expected %s to be rewritten, got %s", unwanted, document)
+		}
+	}
+	if !strings.Contains(document, `<w:sz w:val="32"></w:sz><w:szCs w:val="32">`) {
+		t.Errorf("expected the heading size to be 16pt, got %s", document)
+	}
+	if strings.Count(document, `w:val="single"`) != 6 {
+		t.Errorf("expected six single borders, got %s", document)
+	}
+	// Sizes outside headings are not changed
+	if !strings.Contains(document, `<w:sz w:val="40">`) {
+		t.Errorf("expected the body size to be kept, got %s", document)
+	}
+}
+
+func TestHouseStyleAllowsConformingDocument(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:rPr><w:rFonts w:ascii="calibri"/></w:rPr><w:t>Text</w:t></w:r></w:p>`)
+	var deviations []StyleDeviation
+	renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{HouseStyle: &HouseStyle{
+		Fonts:       []string{"Arial", "Calibri"},
+		OnDeviation: func(d StyleDeviation) { deviations = append(deviations, d) },
+	}})
+	if len(deviations) != 0 {
+		t.Errorf("expected no deviations, got %+v", deviations)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build stencil_nohtml || stencil_lite
+
+package stencil
+
+// registerHTMLFunction registers nothing when the library is built with the
+// stencil_nohtml or stencil_lite tag, which leave out html() and its HTML
+// conversion.
+func registerHTMLFunction(registry *DefaultFunctionRegistry) {}
@@ -936,35 +936,3 @@ func pathToRunProperties(path []string) *RunProperties {
 
 	return props
 }
-
-// registerHTMLFunction registers the html() function
-func registerHTMLFunction(registry *DefaultFunctionRegistry) {
-	htmlFn := NewSimpleFunction("html", 1, 1, func(args ...interface{}) (interface{}, error) {
-		// Handle nil input
-		if args[0] == nil {
-			return nil, nil
-		}
-
-		// Convert to string
-		content := FormatValue(args[0])
-
-		if htmlNeedsBodyRendering(content) {
-			htmlBody, err := htmlToOOXMLBody(content)
-			if err != nil {
-				return nil, fmt.Errorf("html() function error: %w", err)
-			}
-			return &OOXMLFragment{Content: htmlBody}, nil
-		}
-
-		// Parse HTML and convert to OOXML runs
-		htmlRuns, err := htmlToOOXMLRuns(content)
-		if err != nil {
-			return nil, fmt.Errorf("html() function error: %w", err)
-		}
-
-		// Return as OOXML fragment
-		return &OOXMLFragment{Content: htmlRuns}, nil
-	})
-
-	registry.RegisterFunction(htmlFn)
-}
@@ -0,0 +1,84 @@
+//go:build !stencil_nohttp && !stencil_lite
+
+package stencil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The HTTP implementations of RemoteTemplateSource and HTTPFetcher. They
+// are the only users of net/http, which the stencil_nohttp and stencil_lite
+// tags leave out; sources and fetchers can then still be written with
+// RemoteTemplateSourceFunc and HTTPFetcherFunc.
+
+// NewHTTPTemplateSource returns a RemoteTemplateSource that fetches the
+// template named name from baseURL/name with client, or http.DefaultClient
+// when client is nil. It sends the held ETag in an If-None-Match header and
+// treats a 304 response as an unchanged revision.
+func NewHTTPTemplateSource(baseURL string, client *http.Client) RemoteTemplateSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return RemoteTemplateSourceFunc(func(ctx context.Context, name, etag string) (*RemoteTemplate, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/"+url.PathEscape(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			content, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			return &RemoteTemplate{Content: content, ETag: resp.Header.Get("ETag")}, nil
+		case http.StatusNotModified:
+			return &RemoteTemplate{ETag: etag, NotModified: true}, nil
+		default:
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+	})
+}
+
+// NewHTTPFetcher returns an HTTPFetcher that issues GET requests with
+// client, or http.DefaultClient when client is nil. A 404 or 410 response
+// is a missing fragment; other responses than 200 are errors.
+func NewHTTPFetcher(client *http.Client) HTTPFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return HTTPFetcherFunc(func(ctx context.Context, url string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return io.ReadAll(resp.Body)
+		case http.StatusNotFound, http.StatusGone:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+	})
+}
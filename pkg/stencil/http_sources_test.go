@@ -0,0 +1,111 @@
+//go:build !stencil_nohttp && !stencil_lite
+
+package stencil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEnginePrepareRemote(t *testing.T) {
+	var mu sync.Mutex
+	revision := "v1"
+	revisions := map[string][]byte{
+		"v1": createDOCXWithParagraphs(t, []string{`Revision one for {{name}}`}),
+		"v2": createDOCXWithParagraphs(t, []string{`Revision two for {{name}}`}),
+	}
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if r.URL.Path != "/templates/letter.docx" {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + revision + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(revisions[revision])
+	}))
+	defer server.Close()
+
+	engine := New()
+	defer engine.Close()
+	engine.SetRemoteTemplateSource(NewHTTPTemplateSource(server.URL+"/templates/", server.Client()), 0)
+
+	render := func() string {
+		t.Helper()
+		tmpl, err := engine.PrepareRemote(context.Background(), "letter.docx")
+		if err != nil {
+			t.Fatalf("PrepareRemote failed: %v", err)
+		}
+		defer tmpl.Close()
+		output, err := tmpl.Render(TemplateData{"name": "Ada"})
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, _ := io.ReadAll(output)
+		return extractTextFromDOCX(t, content)
+	}
+
+	if text := render(); !strings.Contains(text, "Revision one for Ada") {
+		t.Errorf("first render = %q", text)
+	}
+	if text := render(); !strings.Contains(text, "Revision one for Ada") {
+		t.Errorf("second render = %q", text)
+	}
+	if notModified != 1 {
+		t.Errorf("got %d not-modified responses, want 1", notModified)
+	}
+
+	mu.Lock()
+	revision = "v2"
+	mu.Unlock()
+	if text := render(); !strings.Contains(text, "Revision two for Ada") {
+		t.Errorf("render after publishing = %q", text)
+	}
+
+	// A failing check keeps the previous revision
+	server.Close()
+	if text := render(); !strings.Contains(text, "Revision two for Ada") {
+		t.Errorf("render with registry down = %q", text)
+	}
+	if err := engine.RefreshRemoteTemplates(context.Background()); err == nil {
+		t.Error("RefreshRemoteTemplates succeeded with the registry down")
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clause":
+			io.WriteString(w, "clause text")
+		case "/error":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(server.Client())
+	if content, err := fetcher.Fetch(context.Background(), server.URL+"/clause"); err != nil || string(content) != "clause text" {
+		t.Errorf("Fetch(/clause) = %q, %v", content, err)
+	}
+	if content, err := fetcher.Fetch(context.Background(), server.URL+"/missing"); err != nil || content != nil {
+		t.Errorf("Fetch(/missing) = %q, %v, want nil, nil", content, err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/error"); err == nil {
+		t.Error("Fetch(/error) succeeded, want an error")
+	}
+}
@@ -0,0 +1,298 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// includeIndentTwips is the left indentation added per indent level to
+	// paragraphs that are not part of a numbered list (0.5 inch).
+	includeIndentTwips = 720
+	maxNumberingLevel  = 8
+)
+
+var (
+	includeModifierKeywords = []string{"as", "indent"}
+	numberingLevelAttrRegex = regexp.MustCompile(`(ilvl\s+[^>]*?val=")(\d+)(")`)
+	styleIDRegex            = regexp.MustCompile(`(?s)<w:style\b[^>]*\bw:styleId="([^"]*)"[^>]*>.*?</w:style>`)
+	styleNameRegex          = regexp.MustCompile(`<w:name\b[^>]*\bw:val="([^"]*)"`)
+)
+
+// includeModifiers holds the optional modifiers of an include directive:
+//
+//	{{include "clause" as "Quote"}}  applies a paragraph style
+//	{{include "list" indent 1}}      shifts paragraphs one level deeper
+type includeModifiers struct {
+	style  ExpressionNode
+	indent ExpressionNode
+}
+
+// resolvedIncludeModifiers holds include modifiers evaluated against the
+// render data.
+type resolvedIncludeModifiers struct {
+	style  string
+	indent int
+}
+
+// parseIncludeDirective parses the content of an include directive into the
+// fragment name expression and its modifiers, if any.
+func parseIncludeDirective(content string) (ExpressionNode, *includeModifiers, error) {
+	nameExpr, modifierText := splitIncludeModifiers(content)
+	name, err := ParseExpression(nameExpr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if modifierText == "" {
+		return name, nil, nil
+	}
+
+	mods, err := parseIncludeModifiers(modifierText)
+	if err != nil {
+		return nil, nil, err
+	}
+	return name, mods, nil
+}
+
+// splitIncludeModifiers splits include content at the first modifier keyword
+// outside of string literals and parentheses.
+func splitIncludeModifiers(content string) (string, string) {
+	content = strings.TrimSpace(content)
+	if idx, _ := nextIncludeModifier(content, 0); idx != -1 {
+		return strings.TrimSpace(content[:idx]), content[idx:]
+	}
+	return content, ""
+}
+
+// nextIncludeModifier returns the position and keyword of the next modifier
+// keyword at or after from, or -1 if there is none.
+func nextIncludeModifier(content string, from int) (int, string) {
+	var quote byte
+	depth := 0
+	for i := from; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		case ch == '"' || ch == '\'':
+			quote = ch
+			continue
+		case ch == '(' || ch == '[':
+			depth++
+			continue
+		case ch == ')' || ch == ']':
+			depth--
+			continue
+		}
+		if depth != 0 || (i > 0 && !isIncludeSpace(content[i-1])) {
+			continue
+		}
+		for _, keyword := range includeModifierKeywords {
+			end := i + len(keyword)
+			if !strings.HasPrefix(content[i:], keyword) {
+				continue
+			}
+			// A trailing keyword is only a modifier when something precedes it,
+			// so that a lone variable named "as" still parses as a name.
+			if (end < len(content) && isIncludeSpace(content[end])) || (end == len(content) && i > 0) {
+				return i, keyword
+			}
+		}
+	}
+	return -1, ""
+}
+
+func isIncludeSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func parseIncludeModifiers(text string) (*includeModifiers, error) {
+	mods := &includeModifiers{}
+	pos := 0
+	for pos < len(text) {
+		start, keyword := nextIncludeModifier(text, pos)
+		if start != pos {
+			return nil, fmt.Errorf("unexpected include modifier %q", strings.TrimSpace(text[pos:]))
+		}
+		valueStart := start + len(keyword)
+		next, _ := nextIncludeModifier(text, valueStart)
+		if next == -1 {
+			next = len(text)
+		}
+		value := strings.TrimSpace(text[valueStart:next])
+		if value == "" {
+			return nil, fmt.Errorf("include modifier %q requires a value", keyword)
+		}
+		expr, err := ParseExpression(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include modifier %q: %w", keyword, err)
+		}
+
+		switch keyword {
+		case "as":
+			if mods.style != nil {
+				return nil, fmt.Errorf("duplicate include modifier %q", keyword)
+			}
+			mods.style = expr
+		case "indent":
+			if mods.indent != nil {
+				return nil, fmt.Errorf("duplicate include modifier %q", keyword)
+			}
+			mods.indent = expr
+		}
+		pos = next
+	}
+	return mods, nil
+}
+
+// resolve evaluates the modifiers against data.
+func (m *includeModifiers) resolve(data TemplateData) (*resolvedIncludeModifiers, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	resolved := &resolvedIncludeModifiers{}
+	if m.style != nil {
+		value, err := m.style.Evaluate(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate include style: %w", err)
+		}
+		style, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("include style must be a string, got %T", value)
+		}
+		resolved.style = strings.TrimSpace(style)
+	}
+	if m.indent != nil {
+		value, err := m.indent.Evaluate(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate include indent: %w", err)
+		}
+		indent, ok := toInt(value)
+		if !ok {
+			return nil, fmt.Errorf("include indent must be an integer, got %T", value)
+		}
+		resolved.indent = indent
+	}
+	return resolved, nil
+}
+
+// applyIncludeModifiers applies a paragraph style and indentation to the
+// top-level paragraphs of an included fragment. Paragraph properties may be
+// shared with the parsed fragment, so they are copied before modification.
+func applyIncludeModifiers(elements []BodyElement, mods *resolvedIncludeModifiers, ctx *renderContext) {
+	if mods == nil || (mods.style == "" && mods.indent == 0) {
+		return
+	}
+
+	styleID := ""
+	if mods.style != "" {
+		var stylesXML []byte
+		if ctx != nil {
+			stylesXML = ctx.mainStylesXML
+		}
+		styleID = resolveParagraphStyleID(stylesXML, mods.style)
+	}
+
+	for _, elem := range elements {
+		para, ok := elem.(*Paragraph)
+		if !ok {
+			continue
+		}
+
+		props := &ParagraphProperties{}
+		if para.Properties != nil {
+			copied := *para.Properties
+			props = &copied
+		}
+
+		if styleID != "" {
+			props.Style = &Style{Val: styleID}
+		}
+		if mods.indent != 0 && !indentNumberingLevel(props, mods.indent) {
+			indentParagraph(props, mods.indent)
+		}
+		para.Properties = props
+	}
+}
+
+// indentNumberingLevel shifts the list level of a numbered paragraph and
+// reports whether the paragraph is numbered.
+func indentNumberingLevel(props *ParagraphProperties, levels int) bool {
+	for i, raw := range props.RawXML {
+		if raw.XMLName.Local != "numPr" || !numberingLevelAttrRegex.Match(raw.Content) {
+			continue
+		}
+
+		rawXML := append([]RawXMLElement(nil), props.RawXML...)
+		rawXML[i].Content = numberingLevelAttrRegex.ReplaceAllFunc(raw.Content, func(match []byte) []byte {
+			parts := numberingLevelAttrRegex.FindSubmatch(match)
+			level, _ := strconv.Atoi(string(parts[2]))
+			level = clampInt(level+levels, 0, maxNumberingLevel)
+			return []byte(string(parts[1]) + strconv.Itoa(level) + string(parts[3]))
+		})
+		props.RawXML = rawXML
+		return true
+	}
+	return false
+}
+
+// indentParagraph adds left indentation to a paragraph.
+func indentParagraph(props *ParagraphProperties, levels int) {
+	ind := &Indentation{}
+	if props.Indentation != nil {
+		copied := *props.Indentation
+		ind = &copied
+	}
+
+	current := ind.Left
+	if current == "" {
+		current = ind.Start
+	}
+	left, _ := strconv.Atoi(current)
+	left += levels * includeIndentTwips
+	if left < 0 {
+		left = 0
+	}
+
+	ind.Left = strconv.Itoa(left)
+	ind.Start = ""
+	props.Indentation = ind
+}
+
+// resolveParagraphStyleID maps a style name (e.g. "Heading 1") to its style
+// ID using the template styles. Unknown names are used as IDs unchanged.
+func resolveParagraphStyleID(stylesXML []byte, style string) string {
+	if len(stylesXML) == 0 {
+		return style
+	}
+
+	for _, match := range styleIDRegex.FindAllSubmatch(stylesXML, -1) {
+		if string(match[1]) == style {
+			return style
+		}
+	}
+	for _, match := range styleIDRegex.FindAllSubmatch(stylesXML, -1) {
+		if name := styleNameRegex.FindSubmatch(match[0]); name != nil && strings.EqualFold(string(name[1]), style) {
+			return string(match[1])
+		}
+	}
+	return style
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseIncludeDirective(t *testing.T) {
+	tests := []struct {
+		content    string
+		wantName   string
+		wantStyle  bool
+		wantIndent bool
+		wantErr    bool
+	}{
+		{content: `"clause"`, wantName: `"clause"`},
+		{content: `"clause" as "Quote"`, wantName: `"clause"`, wantStyle: true},
+		{content: `"list" indent 1`, wantName: `"list"`, wantIndent: true},
+		{content: `name as style indent level + 1`, wantName: `name`, wantStyle: true, wantIndent: true},
+		{content: `"as indent" as "Quote"`, wantName: `"as indent"`, wantStyle: true},
+		{content: `"clause" as`, wantErr: true},
+		{content: `"clause" as "A" as "B"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			name, mods, err := parseIncludeDirective(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantExpr, _ := ParseExpression(tt.wantName)
+			if name.String() != wantExpr.String() {
+				t.Fatalf("expected name %s, got %s", wantExpr.String(), name.String())
+			}
+			if got := mods != nil && mods.style != nil; got != tt.wantStyle {
+				t.Fatalf("expected style modifier %v, got %v", tt.wantStyle, got)
+			}
+			if got := mods != nil && mods.indent != nil; got != tt.wantIndent {
+				t.Fatalf("expected indent modifier %v, got %v", tt.wantIndent, got)
+			}
+		})
+	}
+}
+
+func TestIncludeModifiers_StyleAndIndent(t *testing.T) {
+	mainDoc := createDOCXWithParagraphs(t, []string{
+		`{{include "clause" as "Quote"}}`,
+		`{{include "list" indent depth}}`,
+	})
+	tmpl, err := prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if err := tmpl.AddFragmentFromBytes("clause", createFragmentDOCX(t, "Quoted clause")); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+	listFragment := createDOCXWithOptionalNumbering(t,
+		`<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Item</w:t></w:r></w:p>`+
+			`<w:p><w:pPr><w:ind w:left="360"/></w:pPr><w:r><w:t>Plain</w:t></w:r></w:p>`,
+		upperRomanNumberingXML(),
+		true,
+	)
+	if err := tmpl.AddFragmentFromBytes("list", listFragment); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	render := func() string {
+		return extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{"depth": 2}))
+	}
+	documentXML := render()
+
+	if !strings.Contains(documentXML, `<w:pStyle w:val="Quote"`) {
+		t.Fatalf("expected Quote style on included paragraph, got %s", documentXML)
+	}
+	if !strings.Contains(documentXML, `<w:ilvl w:val="2">`) {
+		t.Fatalf("expected numbered paragraph to move to level 2, got %s", documentXML)
+	}
+	if !strings.Contains(documentXML, `w:left="1800"`) {
+		t.Fatalf("expected plain paragraph indentation 360+2*720, got %s", documentXML)
+	}
+
+	// The modifiers must not leak into the parsed fragment.
+	if again := render(); strings.Count(again, `<w:ilvl w:val="2">`) != 1 || !strings.Contains(again, `w:left="1800"`) {
+		t.Fatalf("expected identical output on second render, got %s", again)
+	}
+}
+
+func TestIncludeModifiers_TextFragmentInlineError(t *testing.T) {
+	tmpl, err := prepare(bytes.NewReader(createSimpleDOCX(t, `Text {{include "note" as "Quote"}}`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("note", "note"); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), "include modifiers require") {
+		t.Fatalf("expected include modifier error, got %v", err)
+	}
+}
+
+func TestResolveParagraphStyleID(t *testing.T) {
+	stylesXML := []byte(`<w:styles xmlns:w="` + wordprocessingMLNamespace + `">` +
+		`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="IntenseQuote"><w:name w:val="Intense Quote"/></w:style>` +
+		`</w:styles>`)
+
+	for style, want := range map[string]string{
+		"Heading 1":     "Heading1",
+		"Intense Quote": "IntenseQuote",
+		"IntenseQuote":  "IntenseQuote",
+		"Unknown":       "Unknown",
+	} {
+		if got := resolveParagraphStyleID(stylesXML, style); got != want {
+			t.Fatalf("resolveParagraphStyleID(%q) = %q, want %q", style, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package stencil
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// RenderResult is a rendered document together with the data it was
+// rendered from, as returned by PreparedTemplate.ReRender.
+type RenderResult struct {
+	// Data is the render data, with every patch applied so far.
+	Data TemplateData
+	// Content is the rendered DOCX package.
+	Content []byte
+	// Reused reports whether Content was taken over from the previous
+	// result because the patch changed no data the template reads.
+	Reused bool
+
+	template *template
+}
+
+// templateDataDependencies are the top-level data keys a template reads.
+type templateDataDependencies struct {
+	// roots holds the first segment of every variable path.
+	roots map[string]bool
+	// all is set when the keys cannot be determined statically, for
+	// example because the template includes fragments or calls data().
+	all bool
+}
+
+// ReRender renders the template with the data of previous updated by
+// patch, for preview UIs that change one field at a time. When no key of
+// the patch that the template reads has changed, the previous document is
+// returned without rendering. Otherwise the whole document is rendered
+// again. Pass a nil previous result for the first render.
+//
+// The template's references are analyzed once. Templates that include
+// fragments, use block directives or call data() are always rendered.
+//
+// Example:
+//
+//	result, err := tmpl.ReRender(nil, data)
+//	// the user edits a field
+//	result, err = tmpl.ReRender(result, stencil.TemplateData{"title": newTitle})
+func (pt *PreparedTemplate) ReRender(previous *RenderResult, patch TemplateData) (*RenderResult, error) {
+	if pt == nil {
+		return nil, NewTemplateError("invalid or nil template", 0, 0)
+	}
+	pt.mu.RLock()
+	closed, tmpl := pt.closed, pt.template
+	pt.mu.RUnlock()
+	if closed || tmpl == nil {
+		return nil, errTemplateClosed
+	}
+
+	data := make(TemplateData)
+	if previous != nil {
+		for key, value := range previous.Data {
+			data[key] = value
+		}
+	}
+	changed := make([]string, 0, len(patch))
+	for key, value := range patch {
+		if old, ok := data[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, key)
+		}
+		data[key] = value
+	}
+
+	if previous != nil && previous.template == tmpl {
+		deps, err := tmpl.dataDependencies()
+		if err != nil {
+			return nil, err
+		}
+		if !deps.all && !deps.readsAny(changed) {
+			return &RenderResult{Data: data, Content: previous.Content, Reused: true, template: tmpl}, nil
+		}
+	}
+
+	output, err := pt.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered document: %w", err)
+	}
+	return &RenderResult{Data: data, Content: content, template: tmpl}, nil
+}
+
+func (d *templateDataDependencies) readsAny(keys []string) bool {
+	for _, key := range keys {
+		if d.roots[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// dataDependencies returns the top-level data keys the template reads,
+// computing them on first use.
+func (t *template) dataDependencies() (*templateDataDependencies, error) {
+	t.mu.RLock()
+	deps := t.dependencies
+	replaced := t.documentReplaced
+	t.mu.RUnlock()
+	if deps != nil {
+		return deps, nil
+	}
+
+	deps = &templateDataDependencies{roots: make(map[string]bool)}
+	if replaced {
+		// The parsed document no longer matches the package that can be
+		// scanned.
+		deps.all = true
+	} else {
+		spans, err := scanDOCXTokenSpans(t.source)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, figureTextTokenSpans(t.docxReader)...)
+		for _, span := range spans {
+			switch span.Token.Type {
+			case TokenInclude, TokenBlock:
+				deps.all = true
+			}
+		}
+		for _, ref := range extractReferencesFromSpans(spans) {
+			switch ref.Kind {
+			case TokenKindVariable:
+				root, _, _ := strings.Cut(ref.Expression, ".")
+				root, _, _ = strings.Cut(root, "[")
+				deps.roots[root] = true
+			case TokenKindFunction:
+				if ref.Expression == "data" {
+					deps.all = true
+				}
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.dependencies = deps
+	t.mu.Unlock()
+	return deps, nil
+}
+
+// figureTextTokenSpans returns the template tokens in drawing properties
+// and chart parts, which the paragraph scan does not see.
+func figureTextTokenSpans(reader *DocxReader) []tokenSpan {
+	if reader == nil {
+		return nil
+	}
+	var spans []tokenSpan
+	addTokens := func(part string, text []byte) {
+		for _, token := range Tokenize(string(text)) {
+			if token.Type != TokenText {
+				spans = append(spans, tokenSpan{Part: part, Token: token})
+			}
+		}
+	}
+	for _, part := range reader.ListParts() {
+		isStory := part == "word/document.xml" || isStoryPartName(part)
+		if !isStory && !isChartPartName(part) {
+			continue
+		}
+		content, err := reader.GetPart(part)
+		if err != nil || !strings.Contains(string(content), "{{") {
+			continue
+		}
+		if isStory {
+			for _, tag := range drawingPropertiesRegex.FindAll(content, -1) {
+				for _, attr := range drawingTextAttrRegex.FindAllSubmatch(tag, -1) {
+					addTokens(part, []byte(html.UnescapeString(string(attr[2]))))
+				}
+			}
+			continue
+		}
+		for _, match := range chartTextRegex.FindAllSubmatch(content, -1) {
+			addTokens(part, []byte(html.UnescapeString(string(match[2]))))
+		}
+	}
+	return spans
+}
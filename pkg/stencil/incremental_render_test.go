@@ -0,0 +1,86 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReRenderReusesUnaffectedResults(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>{{title}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{for item in items}}{{item.name}} {{end}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:drawing><wp:inline xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"><wp:docPr id="1" name="Logo" descr="{{alt}}"/></wp:inline></w:drawing></w:r></w:p>`)
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+
+	first, err := tmpl.ReRender(nil, TemplateData{"title": "Draft", "items": []interface{}{map[string]interface{}{"name": "a"}}, "alt": "Logo"})
+	if err != nil {
+		t.Fatalf("ReRender failed: %v", err)
+	}
+	if first.Reused || !strings.Contains(extractDocumentXMLFromDOCX(t, first.Content), "Draft") {
+		t.Fatalf("unexpected first result: reused=%v", first.Reused)
+	}
+
+	unused, err := tmpl.ReRender(first, TemplateData{"notInTemplate": 1, "title": "Draft"})
+	if err != nil {
+		t.Fatalf("ReRender failed: %v", err)
+	}
+	if !unused.Reused || !bytes.Equal(unused.Content, first.Content) {
+		t.Error("expected the previous document to be reused for unread or unchanged keys")
+	}
+	if unused.Data["notInTemplate"] != 1 || unused.Data["title"] != "Draft" {
+		t.Errorf("patch not applied to data: %v", unused.Data)
+	}
+
+	for key, value := range map[string]interface{}{
+		"title": "Final",
+		"items": []interface{}{map[string]interface{}{"name": "b"}},
+		"alt":   "New logo",
+	} {
+		result, err := tmpl.ReRender(unused, TemplateData{key: value})
+		if err != nil {
+			t.Fatalf("ReRender failed: %v", err)
+		}
+		if result.Reused {
+			t.Errorf("changing %s reused the previous document", key)
+		}
+	}
+
+	final, err := tmpl.ReRender(unused, TemplateData{"title": "Final"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documentXML := extractDocumentXMLFromDOCX(t, final.Content); !strings.Contains(documentXML, "Final") || !strings.Contains(documentXML, "a ") {
+		t.Errorf("re-render lost data: %s", documentXML)
+	}
+}
+
+func TestReRenderAlwaysRendersDynamicTemplates(t *testing.T) {
+	for name, body := range map[string]string{
+		"include": `{{include "part"}}`,
+		"data":    `{{length(data())}}`,
+	} {
+		tmpl, err := Prepare(bytes.NewReader(createSimpleDOCX(t, body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tmpl.AddFragment("part", "{{anything}}"); err != nil {
+			t.Fatal(err)
+		}
+		first, err := tmpl.ReRender(nil, TemplateData{"anything": "x"})
+		if err != nil {
+			t.Fatalf("%s: ReRender failed: %v", name, err)
+		}
+		second, err := tmpl.ReRender(first, TemplateData{"anything": "y"})
+		if err != nil {
+			t.Fatalf("%s: ReRender failed: %v", name, err)
+		}
+		if second.Reused {
+			t.Errorf("%s: expected a full render", name)
+		}
+		tmpl.Close()
+	}
+}
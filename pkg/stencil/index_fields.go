@@ -0,0 +1,71 @@
+package stencil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultIndexColumns is the number of columns of indexTable().
+const defaultIndexColumns = 2
+
+// indexFieldEscaper escapes the text of an index entry for a quoted field
+// argument. A colon separates an entry from its subentry.
+var indexFieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `:`, `\:`)
+
+// indexTableField is the INDEX field rendered by indexTable().
+type indexTableField struct {
+	Columns int
+}
+
+// indexEntry implements indexEntry(term[, subentry]): an XE field marking
+// the position of term for the index.
+func indexEntry(args ...interface{}) (interface{}, error) {
+	term := strings.TrimSpace(FormatValue(args[0]))
+	if term == "" {
+		return nil, fmt.Errorf("indexEntry: term cannot be empty")
+	}
+	text := indexFieldEscaper.Replace(term)
+	if len(args) > 1 && args[1] != nil {
+		if subentry := strings.TrimSpace(FormatValue(args[1])); subentry != "" {
+			text += ":" + indexFieldEscaper.Replace(subentry)
+		}
+	}
+	return &OOXMLFragment{Content: &FieldCode{Instruction: `XE "` + text + `"`}}, nil
+}
+
+// indexTable implements indexTable([columns]).
+func indexTable(args ...interface{}) (interface{}, error) {
+	columns := defaultIndexColumns
+	if len(args) > 0 && args[0] != nil {
+		n, ok := toInt(args[0])
+		if !ok || n < 1 || n > 4 {
+			return nil, fmt.Errorf("indexTable: columns must be an integer from 1 to 4, got %v", args[0])
+		}
+		columns = n
+	}
+	return &OOXMLFragment{Content: &indexTableField{Columns: columns}}, nil
+}
+
+// runs returns the runs of the INDEX field, with entries grouped under a
+// heading per letter. The index is built by Word, so the document is set to
+// update its fields when it is opened.
+func (f *indexTableField) runs(templateRun *Run, ctx *renderContext) []Run {
+	if ctx != nil {
+		ctx.updateFieldsOnOpen = true
+	}
+	field := &FieldCode{Instruction: `INDEX \h "A" \c "` + strconv.Itoa(f.Columns) + `"`}
+	return fieldCodeRuns(field, templateRun, ctx)
+}
+
+// registerIndexFunctions registers the indexEntry() and indexTable()
+// functions
+func registerIndexFunctions(registry *DefaultFunctionRegistry) {
+	// indexEntry() function - marks a keyword for the index
+	indexEntryFn := NewSimpleFunction("indexEntry", 1, 2, indexEntry)
+	registry.RegisterFunction(indexEntryFn)
+
+	// indexTable() function - inserts the index of the marked keywords
+	indexTableFn := NewSimpleFunction("indexTable", 0, 1, indexTable)
+	registry.RegisterFunction(indexTableFn)
+}
@@ -0,0 +1,58 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIndexFields(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Limits of liability{{indexEntry("liability")}} apply.`,
+		`Caps{{indexEntry("liability", "caps")}} and ratios{{indexEntry("a:b \"ratio\"")}}.`,
+		`{{indexTable(3)}}`,
+	})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{})
+
+	documentXML := strings.ReplaceAll(extractDocumentXMLFromDOCX(t, output), "&#34;", `"`)
+	assertInOrder(t, documentXML,
+		`Limits of liability`,
+		`<w:instrText xml:space="preserve"> XE "liability" </w:instrText>`,
+		`> XE "liability:caps" </w:instrText>`,
+		`> XE "a\:b \"ratio\"" </w:instrText>`,
+		`w:fldCharType="begin"`,
+		`> INDEX \h "A" \c "3" </w:instrText>`,
+		`w:fldCharType="end"`,
+	)
+	if text := extractTextFromDOCX(t, output); strings.Contains(text, "XE") {
+		t.Errorf("index entries show in the text: %q", text)
+	}
+
+	settings := extractPartFromDOCX(t, output, "word/settings.xml")
+	if !strings.Contains(settings, `<w:updateFields w:val="true"/>`) {
+		t.Errorf("expected fields to update on open: %s", settings)
+	}
+
+	entriesOnly := renderWithOptionsToBytes(t, createSimpleDOCX(t, `Term{{indexEntry("term")}}`), TemplateData{}, RenderOptions{})
+	reader, err := zip.NewReader(bytes.NewReader(entriesOnly), int64(len(entriesOnly)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	for _, f := range reader.File {
+		if f.Name == "word/settings.xml" && strings.Contains(extractPartFromDOCX(t, entriesOnly, f.Name), "updateFields") {
+			t.Error("fields set to update without an index")
+		}
+	}
+}
+
+func TestIndexTableColumns(t *testing.T) {
+	for _, columns := range []interface{}{0, 5, "two"} {
+		if _, err := indexTable(columns); err == nil {
+			t.Errorf("indexTable(%v) succeeded, want an error", columns)
+		}
+	}
+	if _, err := indexEntry(" "); err == nil {
+		t.Error("indexEntry with an empty term succeeded, want an error")
+	}
+}
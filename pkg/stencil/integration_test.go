@@ -134,6 +134,7 @@ func TestIntegrationRealWorldTemplates(t *testing.T) {
 
 			// Add fragments if the template uses them
 			if strings.Contains(tc.templateFile, "report.docx") {
+				skipWithoutFunction(t, "html")
 				// Add fragments that report.docx expects
 				tmpl.AddFragment("header", "Quarterly Report Header")
 				tmpl.AddFragment("disclaimer", "This report is for informational purposes only.")
@@ -0,0 +1,144 @@
+package stencil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Validation issues that are known and intended can be suppressed, so they
+// do not keep failing a CI check:
+//
+//   - inline, by a comment tag right before the tag with the issue:
+//     {{! stencil:ignore UNKNOWN_FIELD }}{{legacy.field}}. The comment names
+//     the codes it suppresses, separated by spaces or commas; without codes
+//     it suppresses every issue of the tag.
+//   - in a suppression file, by the AnchorID of the tag, for templates that
+//     should not carry tool annotations. Use AnchorStrategyContent, so the
+//     anchors survive edits of the template around the tags.
+
+// inlineSuppressionDirective starts the comments that suppress issues.
+const inlineSuppressionDirective = "stencil:ignore"
+
+// IssueSuppression suppresses the validation issues of one template tag.
+type IssueSuppression struct {
+	// AnchorID is the anchor of the tag, as reported in the issue location.
+	AnchorID string `json:"anchorId"`
+	// Code limits the suppression to issues with this code; empty means
+	// every issue of the tag.
+	Code StencilIssueCode `json:"code,omitempty"`
+	// Reason documents why the issue is intended; it is not interpreted.
+	Reason string `json:"reason,omitempty"`
+}
+
+// suppressionFile is the JSON form of a suppression file.
+type suppressionFile struct {
+	Suppressions []IssueSuppression `json:"suppressions"`
+}
+
+// ReadSuppressionsFile reads the suppression file at path.
+func ReadSuppressionsFile(path string) ([]IssueSuppression, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppression file: %w", err)
+	}
+	return ReadSuppressions(data)
+}
+
+// ReadSuppressions reads suppressions from the JSON of a suppression file:
+//
+//	{"suppressions": [
+//	  {"anchorId": "canchor_3f2a...", "code": "UNKNOWN_FIELD", "reason": "filled by the mail merge"}
+//	]}
+func ReadSuppressions(data []byte) ([]IssueSuppression, error) {
+	var file suppressionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid suppression file: %w", err)
+	}
+	for i, suppression := range file.Suppressions {
+		if suppression.AnchorID == "" {
+			return nil, fmt.Errorf("invalid suppression file: suppression %d has no anchorId", i+1)
+		}
+	}
+	return file.Suppressions, nil
+}
+
+// issueSuppressions holds the suppressed codes by AnchorID; a nil code set
+// suppresses every code.
+type issueSuppressions map[string]map[StencilIssueCode]bool
+
+// add suppresses code, or every code if code is empty, for anchor.
+func (s issueSuppressions) add(anchor string, code StencilIssueCode) {
+	codes, exists := s[anchor]
+	if exists && codes == nil {
+		return
+	}
+	if code == "" {
+		s[anchor] = nil
+		return
+	}
+	if codes == nil {
+		codes = make(map[StencilIssueCode]bool)
+		s[anchor] = codes
+	}
+	codes[code] = true
+}
+
+func (s issueSuppressions) suppresses(issue StencilValidationIssue) bool {
+	codes, ok := s[issue.Location.AnchorID]
+	return ok && (codes == nil || codes[issue.Code])
+}
+
+// collectIssueSuppressions returns the suppressions of the inline
+// stencil:ignore comments of spans and of suppressions. A comment applies
+// to the next tag of its part that is not a comment.
+func collectIssueSuppressions(spans []tokenSpan, suppressions []IssueSuppression) issueSuppressions {
+	result := make(issueSuppressions)
+	for i, span := range spans {
+		if span.Malformed || span.Token.Type != TokenComment {
+			continue
+		}
+		rest, ok := strings.CutPrefix(span.Token.Value, inlineSuppressionDirective)
+		if !ok || rest != "" && !strings.ContainsAny(rest[:1], " \t,") {
+			continue
+		}
+		for _, next := range spans[i+1:] {
+			if next.Part != span.Part {
+				break
+			}
+			if !next.Malformed && next.Token.Type == TokenComment {
+				continue
+			}
+			codes := strings.FieldsFunc(rest, func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+			if len(codes) == 0 {
+				result.add(next.AnchorID, "")
+			}
+			for _, code := range codes {
+				result.add(next.AnchorID, StencilIssueCode(code))
+			}
+			break
+		}
+	}
+	for _, suppression := range suppressions {
+		result.add(suppression.AnchorID, suppression.Code)
+	}
+	return result
+}
+
+// suppressIssues removes the issues suppressions suppress and returns the
+// remaining issues with the number removed.
+func suppressIssues(issues []StencilValidationIssue, suppressions issueSuppressions) ([]StencilValidationIssue, int) {
+	if len(suppressions) == 0 {
+		return issues, 0
+	}
+	kept := issues[:0]
+	for _, issue := range issues {
+		if !suppressions.suppresses(issue) {
+			kept = append(kept, issue)
+		}
+	}
+	return kept, len(issues) - len(kept)
+}
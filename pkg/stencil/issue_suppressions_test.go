@@ -0,0 +1,87 @@
+package stencil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInlineIssueSuppressions(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		"{{! stencil:ignore UNKNOWN_FIELD }}{{legacy.code}}",
+		"{{! stencil:ignore TYPE_MISMATCH, CONSTANT_CONDITION }}{{other.field}}",
+		"{{! stencil:ignore }}{{! note }}{{if 1 > 2}}x{{end}}",
+		"{{! stencil:ignored }}{{third.field}}",
+	})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"legacy": map[string]interface{}{"code": "A1"}}, RenderOptions{})
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "A1") || strings.Contains(text, "stencil:ignore") {
+		t.Errorf("rendered text = %q, want the value without the comments", text)
+	}
+
+	result, err := ValidateTemplate(ValidateTemplateInput{DocxBytes: docx, IncludeWarnings: true, Strict: true})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+	var got []string
+	for _, issue := range result.Issues {
+		got = append(got, string(issue.Code)+":"+issue.Token.Raw)
+	}
+	want := "UNKNOWN_FIELD:{{other.field}} UNKNOWN_FIELD:{{third.field}}"
+	if strings.Join(got, " ") != want {
+		t.Errorf("issues = %v, want %s", got, want)
+	}
+	if result.Summary.SuppressedCount != 2 || result.Summary.ErrorCount != 2 {
+		t.Errorf("summary = %+v, want 2 suppressed and 2 errors", result.Summary)
+	}
+
+	tmpl := prepareValidationTemplate(t, `{{! stencil:ignore UNKNOWN_FIELD }}{{missing.value}}`)
+	prepared, err := tmpl.Validate(TemplateSchema{})
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if !prepared.Valid || prepared.Summary.SuppressedCount != 1 {
+		t.Errorf("prepared validation = %+v, want the issue suppressed", prepared)
+	}
+}
+
+func TestSuppressionFile(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{"Intro", "{{legacy.code}} and {{other.field}}"})
+	input := ValidateTemplateInput{DocxBytes: docx, Strict: true, AnchorStrategy: AnchorStrategyContent}
+	result, err := ValidateTemplate(input)
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+	if len(result.Issues) != 2 {
+		t.Fatalf("expected 2 issues, got %+v", result.Issues)
+	}
+	anchor := result.Issues[0].Location.AnchorID
+
+	path := filepath.Join(t.TempDir(), "suppressions.json")
+	content := `{"suppressions": [{"anchorId": "` + anchor + `", "code": "UNKNOWN_FIELD", "reason": "set by the mail merge"}]}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	suppressions, err := ReadSuppressionsFile(path)
+	if err != nil {
+		t.Fatalf("ReadSuppressionsFile failed: %v", err)
+	}
+	if len(suppressions) != 1 || suppressions[0].Reason != "set by the mail merge" {
+		t.Fatalf("suppressions = %+v", suppressions)
+	}
+
+	// The anchors survive an edit before the tags
+	input.DocxBytes = createDOCXWithParagraphs(t, []string{"A longer intro", "Code: {{legacy.code}} and {{other.field}}"})
+	input.Suppressions = suppressions
+	result, err = ValidateTemplate(input)
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Token.Raw != "{{other.field}}" || result.Summary.SuppressedCount != 1 {
+		t.Errorf("issues = %+v, summary = %+v, want only {{other.field}}", result.Issues, result.Summary)
+	}
+
+	if _, err := ReadSuppressions([]byte(`{"suppressions": [{"code": "UNKNOWN_FIELD"}]}`)); err == nil {
+		t.Error("expected an error for a suppression without anchorId")
+	}
+}
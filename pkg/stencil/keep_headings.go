@@ -0,0 +1,97 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Headings are kept on the page of the paragraph that follows them
+// (RenderOptions.KeepHeadingsWithNext): a heading gets w:keepNext and
+// w:keepLines, so Word moves it to the next page instead of leaving it as
+// the last line of a page. Empty paragraphs between a heading and its
+// content are kept with the next paragraph too, as the heading would
+// otherwise only stay with the blank line.
+
+// headingStyleIDs returns the IDs of the heading styles of stylesXML: the
+// built-in Heading1 to Heading9 and styles named "heading 1" to
+// "heading 9", as localized templates name their heading styles.
+func headingStyleIDs(stylesXML []byte) map[string]bool {
+	ids := make(map[string]bool)
+	for _, match := range styleIDRegex.FindAllSubmatch(stylesXML, -1) {
+		if name := styleNameRegex.FindSubmatch(match[0]); name != nil && headingStyleRegex.Match(name[1]) {
+			ids[string(match[1])] = true
+		}
+	}
+	return ids
+}
+
+// isHeadingParagraph reports whether para has a heading style or an outline
+// level of its own.
+func isHeadingParagraph(para *Paragraph, headingStyles map[string]bool) bool {
+	if para.Properties == nil {
+		return false
+	}
+	if style := para.Properties.Style; style != nil && (headingStyles[style.Val] || headingStyleRegex.MatchString(style.Val)) {
+		return true
+	}
+	for _, raw := range para.Properties.RawXML {
+		if raw.XMLName.Local == "outlineLvl" && !strings.Contains(string(raw.Content), `w:val="9"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepHeadingsWithNext keeps the heading paragraphs of the body elements,
+// and the empty paragraphs that follow them, with the next paragraph.
+// Headings in table cells are left alone, as keepNext keeps the whole row
+// with the next one there.
+func keepHeadingsWithNext(elements []BodyElement, stylesXML []byte) {
+	headingStyles := headingStyleIDs(stylesXML)
+	afterHeading := false
+	for _, elem := range elements {
+		para, ok := elem.(*Paragraph)
+		if !ok {
+			afterHeading = false
+			continue
+		}
+		switch {
+		case isHeadingParagraph(para, headingStyles):
+			setParagraphKeepProperties(para, "keepNext", "keepLines")
+			afterHeading = true
+		case afterHeading && !paragraphHasContent(para):
+			setParagraphKeepProperties(para, "keepNext")
+		default:
+			afterHeading = false
+		}
+	}
+}
+
+// setParagraphKeepProperties adds the named empty properties, such as
+// keepNext, to para unless it sets them already. The properties may be
+// shared with the template, so they are copied before modification.
+func setParagraphKeepProperties(para *Paragraph, names ...string) {
+	props := &ParagraphProperties{}
+	if para.Properties != nil {
+		copied := *para.Properties
+		props = &copied
+	}
+	raws := append([]RawXMLElement(nil), props.RawXML...)
+	for _, name := range names {
+		set := false
+		for _, raw := range raws {
+			if raw.XMLName.Local == name {
+				set = true
+				break
+			}
+		}
+		if !set {
+			raws = append(raws, RawXMLElement{
+				XMLName: xml.Name{Local: name},
+				Content: []byte(`<w:` + name + `/>`),
+			})
+		}
+	}
+	props.RawXML = raws
+	para.Properties = props
+}
@@ -0,0 +1,48 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeepHeadingsWithNext(t *testing.T) {
+	docx := createDOCXWithBodyXML(t,
+		`<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>{{title}}</w:t></w:r></w:p>`+
+			`<w:p></w:p>`+
+			`<w:p><w:r><w:t>Body</w:t></w:r></w:p>`+
+			`<w:p><w:pPr><w:outlineLvl w:val="1"/></w:pPr><w:r><w:t>Outline</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t>More</w:t></w:r></w:p>`+
+			`<w:p></w:p>`)
+
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"title": "Report"}, RenderOptions{KeepHeadingsWithNext: true})
+	document := extractDocumentXMLFromDOCX(t, output)
+	paragraphs := strings.Split(document, "</w:p>")
+	want := []string{"keepNext keepLines", "keepNext", "", "keepNext keepLines", "", ""}
+	for i, props := range want {
+		var got []string
+		for _, name := range []string{"keepNext", "keepLines"} {
+			if strings.Contains(paragraphs[i], "<w:"+name+"/>") {
+				got = append(got, name)
+			}
+		}
+		if strings.Join(got, " ") != props {
+			t.Errorf("paragraph %d keeps %q, want %q: %s", i+1, got, props, paragraphs[i])
+		}
+	}
+
+	plain := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, TemplateData{"title": "Report"}, RenderOptions{}))
+	if strings.Contains(plain, "keepNext") {
+		t.Errorf("headings kept without the option: %s", plain)
+	}
+}
+
+func TestHeadingStyleIDs(t *testing.T) {
+	styles := []byte(`<w:styles>` +
+		`<w:style w:type="paragraph" w:styleId="berschrift1"><w:name w:val="heading 1"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/></w:style>` +
+		`</w:styles>`)
+	ids := headingStyleIDs(styles)
+	if !ids["berschrift1"] || ids["Title"] {
+		t.Errorf("headingStyleIDs() = %v, want only berschrift1", ids)
+	}
+}
@@ -0,0 +1,153 @@
+package stencil
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LambdaNode is a one-parameter function literal such as x -> x.qty > 0. It
+// can only appear as a function argument, where it evaluates to a lambda that
+// collection functions like filter, map and sortBy call for each item.
+type LambdaNode struct {
+	Param string
+	Body  ExpressionNode
+}
+
+func (n *LambdaNode) String() string {
+	return fmt.Sprintf("Lambda(%s -> %s)", n.Param, n.Body.String())
+}
+
+// Evaluate returns a lambda that evaluates Body in a scope of data in which
+// Param is bound to its argument.
+func (n *LambdaNode) Evaluate(data TemplateData) (interface{}, error) {
+	return &lambda{node: n, data: data}, nil
+}
+
+// lambda is the value of a LambdaNode.
+type lambda struct {
+	node *LambdaNode
+	data TemplateData
+}
+
+func (l *lambda) String() string {
+	return expressionSource(l.node)
+}
+
+// call evaluates the lambda body with its parameter bound to arg.
+func (l *lambda) call(arg interface{}) (interface{}, error) {
+	scope := newChildTemplateData(l.data, 1)
+	scope[l.node.Param] = arg
+	return l.node.Body.Evaluate(scope)
+}
+
+// lambdaArg returns the lambda passed as argument index of a function call.
+func lambdaArg(function string, args []interface{}, index int) (*lambda, error) {
+	fn, ok := args[index].(*lambda)
+	if !ok {
+		return nil, fmt.Errorf("argument %d of %s() must be a lambda such as x -> x.price, got %T", index+1, function, args[index])
+	}
+	return fn, nil
+}
+
+// filterWithLambda returns the items of collection for which fn is truthy.
+func filterWithLambda(collection interface{}, fn *lambda) (interface{}, error) {
+	items, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("filter() requires a list, got %T", collection)
+	}
+	result := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		keep, err := fn.call(item)
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(keep) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// mapWithLambda returns the results of calling fn for each item of
+// collection.
+func mapWithLambda(collection interface{}, fn *lambda) (interface{}, error) {
+	items, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("map() requires a list, got %T", collection)
+	}
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		value, err := fn.call(item)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = value
+	}
+	return result, nil
+}
+
+// sortByLambda returns the items of collection sorted by the keys fn returns
+// for them, in the order of a for loop's order by clause in locale. The sort
+// is stable.
+func sortByLambda(collection interface{}, fn *lambda, locale string) (interface{}, error) {
+	sorted, _, err := sortItemsByLambda("sortBy", collection, fn, locale)
+	if err != nil {
+		return nil, err
+	}
+	return sorted, nil
+}
+
+// groupByLambda groups the items of collection by the keys fn returns for
+// them. It returns a group {"key": key, "items": [...]} per distinct key,
+// ordered like sortBy() orders the keys in locale; the items of a group keep
+// their order.
+func groupByLambda(collection interface{}, fn *lambda, locale string) (interface{}, error) {
+	sorted, keys, err := sortItemsByLambda("groupBy", collection, fn, locale)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]interface{}, 0)
+	var group map[string]interface{}
+	for i, item := range sorted {
+		if group == nil || compareSortKeys(group["key"], keys[i], locale) != 0 {
+			group = map[string]interface{}{"key": keys[i], "items": []interface{}{}}
+			groups = append(groups, group)
+		}
+		group["items"] = append(group["items"].([]interface{}), item)
+	}
+	return groups, nil
+}
+
+// sortItemsByLambda returns the items of collection stably sorted by the
+// keys fn returns for them, with the keys in the same order. name is the
+// function sorting, for errors.
+func sortItemsByLambda(name string, collection interface{}, fn *lambda, locale string) ([]interface{}, []interface{}, error) {
+	items, err := toSlice(collection)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s() requires a list, got %T", name, collection)
+	}
+	keys := make([]interface{}, len(items))
+	for i, item := range items {
+		key, err := fn.call(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys[i] = key
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return compareSortKeys(keys[order[i]], keys[order[j]], locale) < 0
+	})
+
+	sorted := make([]interface{}, len(items))
+	sortedKeys := make([]interface{}, len(items))
+	for i, idx := range order {
+		sorted[i] = items[idx]
+		sortedKeys[i] = keys[idx]
+	}
+	return sorted, sortedKeys, nil
+}
@@ -0,0 +1,165 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLambdaExpressions(t *testing.T) {
+	data := TemplateData{
+		"items": []interface{}{
+			map[string]interface{}{"name": "Pen", "price": 2.5, "qty": 4},
+			map[string]interface{}{"name": "Ink", "price": 10.0, "qty": 0},
+			map[string]interface{}{"name": "Pad", "price": 1.0, "qty": 3},
+		},
+		"minQty": 1,
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		{
+			name: "filter",
+			expr: "map(\"name\", filter(items, x -> x.qty > 0))",
+			want: []interface{}{"Pen", "Pad"},
+		},
+		{
+			name: "map with lambda",
+			expr: "map(items, x -> x.price * x.qty)",
+			want: []interface{}{10.0, 0.0, 3.0},
+		},
+		{
+			name: "sum of mapped lambda",
+			expr: "sum(map(items, x -> x.price * x.qty))",
+			want: 13.0,
+		},
+		{
+			name: "sortBy",
+			expr: "map(\"name\", sortBy(items, item -> item.price))",
+			want: []interface{}{"Pad", "Pen", "Ink"},
+		},
+		{
+			name: "groupBy keys",
+			expr: "map(\"key\", groupBy(items, item -> item.qty > 0))",
+			want: []interface{}{false, true},
+		},
+		{
+			name: "groupBy keeps the item order",
+			expr: "map(\"name\", groupBy(items, item -> item.qty > 0)[1].items)",
+			want: []interface{}{"Pen", "Pad"},
+		},
+		{
+			name: "lambda reads the enclosing scope",
+			expr: "length(filter(items, x -> x.qty >= minQty))",
+			want: 2,
+		},
+		{
+			name: "filter on null",
+			expr: "filter(missing, x -> x)",
+			want: []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+			got, err := node.Evaluate(data)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error = %v", tt.expr, err)
+			}
+			if !compareValues(got, tt.want) {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLambdaParsing(t *testing.T) {
+	node, err := ParseExpression("filter(items, x -> x.qty > 0 & x.price < 5)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	call, ok := node.(*FunctionCallNode)
+	if !ok || len(call.Args) != 2 {
+		t.Fatalf("expected a call with 2 arguments, got %s", node)
+	}
+	lambdaNode, ok := call.Args[1].(*LambdaNode)
+	if !ok {
+		t.Fatalf("expected a lambda argument, got %T", call.Args[1])
+	}
+	if lambdaNode.Param != "x" {
+		t.Errorf("Param = %q, want x", lambdaNode.Param)
+	}
+	if got, want := expressionSource(lambdaNode), "x -> (x.qty > 0) & (x.price < 5)"; got != want {
+		t.Errorf("expressionSource() = %q, want %q", got, want)
+	}
+
+	if _, err := ParseExpressionStrict("x -> x.qty"); err == nil {
+		t.Error("expected a lambda outside a function call to fail")
+	}
+}
+
+func TestLambdaErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "filter(items, \"qty\")", wantErr: "argument 2 of filter() must be a lambda"},
+		{expr: "sortBy(items, 1)", wantErr: "argument 2 of sortBy() must be a lambda"},
+		{expr: "filter(42, x -> x)", wantErr: "filter() requires a list"},
+		{expr: "groupBy(42, x -> x)", wantErr: "groupBy() requires a list"},
+		{expr: "map(items, x -> unknownFn(x))", wantErr: "unknown function: unknownFn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+			_, err = node.Evaluate(TemplateData{"items": []interface{}{1, 2}})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Evaluate(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate_Lambdas(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p><w:r><w:t>{{sum(map(items, x -&gt; x.price * x.qty))}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{for item in filter(items, x -&gt; x.missing)}}{{item.name}}{{end}}</w:t></w:r></w:p>
+		`),
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes:       docx,
+		Strict:          true,
+		IncludeWarnings: true,
+		Schema: ValidationSchema{
+			Fields: []FieldDefinition{
+				{Path: "items", Type: "object", Collection: true},
+				{Path: "items.price", Type: "number"},
+				{Path: "items.qty", Type: "number"},
+				{Path: "items.name", Type: "string"},
+			},
+			Functions: functionDefinitionsFromRegistry(nil),
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+
+	if len(result.Issues) != 1 {
+		t.Fatalf("issue count=%d, want 1: %+v", len(result.Issues), result.Issues)
+	}
+	if issue := result.Issues[0]; issue.Code != IssueCodeUnknownField || !strings.Contains(issue.Message, "x.missing") {
+		t.Fatalf("expected unknown field x.missing, got %+v", issue)
+	}
+}
@@ -0,0 +1,312 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// landscapeAppendix holds the tables of a landscapeAppendix() call. The
+// tables are sized and appended when the body has been rendered, because
+// their width depends on the page of the final section.
+type landscapeAppendix struct {
+	Tables []appendixTable
+}
+
+// appendixTable is one table of a landscape appendix.
+type appendixTable struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+}
+
+const (
+	// defaultSectionPageWidth and defaultSectionPageHeight are the US Letter
+	// page size Word uses when a document has no section properties.
+	defaultSectionPageWidth  = 12240
+	defaultSectionPageHeight = 15840
+	defaultSectionMargin     = 1440
+
+	// appendix column widths are proportional to the longest text of each
+	// column, bounded so that short and very long columns stay readable.
+	appendixMinColumnWeight = 4
+	appendixMaxColumnWeight = 40
+)
+
+var (
+	sectionPageSizeRegex   = regexp.MustCompile(`<w:pgSz\b([^>]*?)(/?)>`)
+	sectionPageMarginRegex = regexp.MustCompile(`<w:pgMar\b([^>]*?)/?>`)
+	sectionAttrRegex       = regexp.MustCompile(`\bw:([A-Za-z]+)="([^"]*)"`)
+
+	// sectionAfterPageSizeRegex matches the section properties that follow
+	// w:pgSz in the schema order.
+	sectionAfterPageSizeRegex = regexp.MustCompile(`<w:(pgMar|paperSrc|pgBorders|lnNumType|pgNumType|cols|formProt|vAlign|noEndnote|titlePg|textDirection|bidi|rtlGutter|docGrid|printerSettings|sectPrChange)\b`)
+)
+
+// landscapeAppendixFunction collects tables for a landscape section at the
+// end of the document. Each entry is a map with "rows" and optional "title"
+// and "columns". A row is a list of cell values, or a map read by column
+// name when columns are given.
+func landscapeAppendixFunction(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	entries, err := toSlice(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("landscapeAppendix: %w", err)
+	}
+
+	appendix := &landscapeAppendix{}
+	for i, entry := range entries {
+		table, err := parseAppendixTable(entry)
+		if err != nil {
+			return nil, fmt.Errorf("landscapeAppendix: table %d: %w", i+1, err)
+		}
+		appendix.Tables = append(appendix.Tables, table)
+	}
+	if len(appendix.Tables) == 0 {
+		return "", nil
+	}
+	return &OOXMLFragment{Content: appendix}, nil
+}
+
+// parseAppendixTable converts an entry of the landscapeAppendix() argument.
+func parseAppendixTable(entry interface{}) (appendixTable, error) {
+	switch entry.(type) {
+	case map[string]interface{}, TemplateData:
+	default:
+		return appendixTable{}, fmt.Errorf("expected a map with title, columns and rows, got %T", entry)
+	}
+
+	var table appendixTable
+	if title := accessMapField(entry, "title"); title != nil {
+		table.Title = FormatValue(title)
+	}
+	columns, err := toSlice(accessMapField(entry, "columns"))
+	if err != nil {
+		return appendixTable{}, fmt.Errorf("columns: %w", err)
+	}
+	for _, column := range columns {
+		table.Columns = append(table.Columns, FormatValue(column))
+	}
+
+	rows, err := toSlice(accessMapField(entry, "rows"))
+	if err != nil {
+		return appendixTable{}, fmt.Errorf("rows: %w", err)
+	}
+	for i, row := range rows {
+		var cells []string
+		switch row.(type) {
+		case map[string]interface{}, TemplateData:
+			if len(table.Columns) == 0 {
+				return appendixTable{}, fmt.Errorf("row %d is a map, which needs columns", i+1)
+			}
+			for _, column := range table.Columns {
+				cells = append(cells, formatAppendixCell(accessMapField(row, column)))
+			}
+		default:
+			values, err := toSlice(row)
+			if err != nil {
+				return appendixTable{}, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			for _, value := range values {
+				cells = append(cells, formatAppendixCell(value))
+			}
+		}
+		table.Rows = append(table.Rows, cells)
+	}
+
+	if len(table.Columns) == 0 && len(table.Rows) == 0 {
+		return appendixTable{}, fmt.Errorf("table has no columns and no rows")
+	}
+	return table, nil
+}
+
+func formatAppendixCell(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return FormatValue(value)
+}
+
+// registerLandscapeAppendixFunction registers the landscapeAppendix() function
+func registerLandscapeAppendixFunction(registry *DefaultFunctionRegistry) {
+	registry.RegisterFunction(NewSimpleFunction("landscapeAppendix", 1, 1, landscapeAppendixFunction))
+}
+
+// appendLandscapeAppendices ends the current last section of body and adds
+// a landscape section with the appendix tables.
+func appendLandscapeAppendices(body *Body, appendices []*landscapeAppendix) {
+	if body == nil || len(appendices) == 0 {
+		return
+	}
+
+	portrait := body.SectionProperties
+	if portrait == nil {
+		portrait = &RawXMLElement{
+			XMLName: xml.Name{Local: "sectPr"},
+			Content: []byte(defaultSectionProperties()),
+		}
+	}
+
+	// The current section now ends at a paragraph carrying its properties.
+	var sectionBreak bytes.Buffer
+	writeSectionPropertiesXML(&sectionBreak, portrait)
+	body.Elements = append(body.Elements, &Paragraph{
+		Properties: &ParagraphProperties{
+			RawXML: []RawXMLElement{{XMLName: xml.Name{Local: "sectPr"}, Content: sectionBreak.Bytes()}},
+		},
+	})
+
+	landscape, width := landscapeSectionProperties(portrait)
+	for _, appendix := range appendices {
+		for _, table := range appendix.Tables {
+			if table.Title != "" {
+				body.Elements = append(body.Elements, &Paragraph{
+					Runs: []Run{{
+						Properties: &RunProperties{Bold: &Empty{}},
+						Text:       &Text{Content: table.Title, Space: "preserve"},
+					}},
+				})
+			}
+			// A paragraph follows every table so that adjacent tables are not
+			// merged and the body does not end with a table.
+			body.Elements = append(body.Elements, buildAppendixTable(table, width), &Paragraph{})
+		}
+	}
+	body.SectionProperties = landscape
+}
+
+// defaultSectionProperties returns the child elements of the section
+// properties of a document without any: a US Letter page with one inch
+// margins.
+func defaultSectionProperties() string {
+	return fmt.Sprintf(`<w:pgSz w:w="%d" w:h="%d"/><w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="720" w:footer="720" w:gutter="0"/>`,
+		defaultSectionPageWidth, defaultSectionPageHeight, defaultSectionMargin, defaultSectionMargin, defaultSectionMargin, defaultSectionMargin)
+}
+
+// landscapeSectionProperties returns a copy of the section properties with
+// a landscape page, and the width available to tables on that page.
+func landscapeSectionProperties(portrait *RawXMLElement) (*RawXMLElement, int) {
+	content := convertNamespaceURIsToPrefix(string(portrait.Content))
+
+	long, short := defaultSectionPageHeight, defaultSectionPageWidth
+	if match := sectionPageSizeRegex.FindStringSubmatch(content); match != nil {
+		attrs := sectionAttributes(match[1])
+		if w, h := attrs["w"], attrs["h"]; w > 0 && h > 0 {
+			long, short = max(w, h), min(w, h)
+		}
+	}
+	pageSize := fmt.Sprintf(`<w:pgSz w:w="%d" w:h="%d" w:orient="landscape"`, long, short)
+	if loc := sectionPageSizeRegex.FindStringSubmatchIndex(content); loc != nil {
+		content = content[:loc[0]] + pageSize + content[loc[4]:loc[5]] + ">" + content[loc[1]:]
+	} else if loc := sectionAfterPageSizeRegex.FindStringIndex(content); loc != nil {
+		content = content[:loc[0]] + pageSize + "/>" + content[loc[0]:]
+	} else {
+		content += pageSize + "/>"
+	}
+
+	left, right := defaultSectionMargin, defaultSectionMargin
+	if match := sectionPageMarginRegex.FindStringSubmatch(content); match != nil {
+		attrs := sectionAttributes(match[1])
+		if value, ok := attrs["left"]; ok {
+			left = value
+		}
+		if value, ok := attrs["right"]; ok {
+			right = value
+		}
+	}
+	width := long - left - right
+	if width < defaultSectionMargin {
+		width = long
+	}
+
+	landscape := &RawXMLElement{
+		XMLName: portrait.XMLName,
+		Attrs:   append([]xml.Attr(nil), portrait.Attrs...),
+		Content: []byte(content),
+	}
+	return landscape, width
+}
+
+// sectionAttributes parses the numeric w: attributes of a section property
+// element.
+func sectionAttributes(attrs string) map[string]int {
+	values := make(map[string]int)
+	for _, match := range sectionAttrRegex.FindAllStringSubmatch(attrs, -1) {
+		if n, err := strconv.Atoi(match[2]); err == nil {
+			values[match[1]] = n
+		}
+	}
+	return values
+}
+
+// buildAppendixTable builds a table with a bold, shaded header row and
+// columns sized in proportion to their longest text.
+func buildAppendixTable(table appendixTable, width int) *Table {
+	columnCount := len(table.Columns)
+	for _, row := range table.Rows {
+		columnCount = max(columnCount, len(row))
+	}
+	columnCount = max(columnCount, 1)
+
+	weights := make([]int, columnCount)
+	measure := func(cells []string) {
+		for i, cell := range cells {
+			weights[i] = max(weights[i], utf8.RuneCountInString(cell))
+		}
+	}
+	measure(table.Columns)
+	for _, row := range table.Rows {
+		measure(row)
+	}
+	total := 0
+	for i := range weights {
+		weights[i] = min(max(weights[i], appendixMinColumnWeight), appendixMaxColumnWeight)
+		total += weights[i]
+	}
+	widths := make([]int, columnCount)
+	remaining := width
+	for i := range widths {
+		widths[i] = width * weights[i] / total
+		remaining -= widths[i]
+	}
+	widths[columnCount-1] += remaining
+
+	var rows []TableRow
+	if len(table.Columns) > 0 {
+		rows = append(rows, buildAppendixRow(table.Columns, widths, true))
+	}
+	for _, row := range table.Rows {
+		rows = append(rows, buildAppendixRow(row, widths, false))
+	}
+
+	result := newHTMLTableWithColumnCount(rows, columnCount, nil, widths)
+	result.Properties.Width = &Width{Type: "dxa", Val: width}
+	result.Properties.Layout = &TableLayout{Type: "fixed"}
+	return result
+}
+
+func buildAppendixRow(cells []string, widths []int, header bool) TableRow {
+	row := TableRow{Cells: make([]TableCell, len(widths))}
+	for i := range widths {
+		text := ""
+		if i < len(cells) {
+			text = cells[i]
+		}
+		run := Run{Text: &Text{Content: text, Space: "preserve"}}
+		cell := TableCell{
+			Properties: &TableCellProperties{Width: &Width{Type: "dxa", Val: widths[i]}},
+			Paragraphs: []Paragraph{{Runs: []Run{run}}},
+		}
+		if header {
+			cell.Paragraphs[0].Runs[0].Properties = &RunProperties{Bold: &Empty{}}
+			cell.Properties.Shading = &Shading{Val: "clear", Color: "auto", Fill: "EDEDED"}
+		}
+		row.Cells[i] = cell
+	}
+	return row
+}
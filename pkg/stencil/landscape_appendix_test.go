@@ -0,0 +1,78 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLandscapeAppendix(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Report</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{landscapeAppendix(appendix)}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>Summary</w:t></w:r></w:p>`+
+		`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1417" w:right="1000" w:bottom="1134" w:left="1838" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr>`)
+	data := TemplateData{
+		"appendix": []interface{}{
+			map[string]interface{}{
+				"title":   "Positions",
+				"columns": []interface{}{"Id", "Description"},
+				"rows": []interface{}{
+					[]interface{}{1, "A rather long description of the first position"},
+					map[string]interface{}{"Id": 2, "Description": nil},
+				},
+			},
+			map[string]interface{}{
+				"rows": []interface{}{[]interface{}{"x", "y", "z"}},
+			},
+		},
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	if strings.Contains(documentXML, "OOXML_FRAGMENT") {
+		t.Fatalf("appendix marker left in output: %s", documentXML)
+	}
+	assertInOrder(t, documentXML,
+		"Report",
+		"Summary",
+		`<w:pPr><w:sectPr><w:pgSz w:w="11906" w:h="16838">`,
+		"Positions",
+		`<w:tblW w:type="dxa" w:w="14000">`,
+		`<w:tblLayout w:type="fixed">`,
+		`<w:gridCol w:w="1272">`,
+		`<w:gridCol w:w="12728">`,
+		"Description",
+		"A rather long description of the first position",
+		`<w:gridCol w:w="4668">`,
+		"z",
+		`<w:sectPr><w:pgSz w:w="16838" w:h="11906" w:orient="landscape"`,
+		`w:left="1838"`,
+		`</w:sectPr></w:body>`,
+	)
+	if got := strings.Count(documentXML, "<w:sectPr"); got != 2 {
+		t.Errorf("expected two sections, got %d: %s", got, documentXML)
+	}
+}
+
+func TestLandscapeAppendixErrors(t *testing.T) {
+	for _, arg := range []interface{}{
+		[]interface{}{"not a table"},
+		[]interface{}{map[string]interface{}{}},
+		[]interface{}{map[string]interface{}{"rows": []interface{}{map[string]interface{}{"a": 1}}}},
+	} {
+		if _, err := landscapeAppendixFunction(arg); err == nil {
+			t.Errorf("landscapeAppendix(%v) expected an error", arg)
+		}
+	}
+	if got, err := landscapeAppendixFunction(nil); err != nil || got != "" {
+		t.Errorf("landscapeAppendix(nil) = %v, %v; want empty string", got, err)
+	}
+}
+
+func TestLandscapeSectionPropertiesDefaults(t *testing.T) {
+	landscape, width := landscapeSectionProperties(&RawXMLElement{Content: []byte(`<w:cols w:space="720"></w:cols>`)})
+	if want := `<w:pgSz w:w="15840" w:h="12240" w:orient="landscape"/><w:cols w:space="720"></w:cols>`; string(landscape.Content) != want {
+		t.Errorf("content = %s, want %s", landscape.Content, want)
+	}
+	if width != 15840-2*1440 {
+		t.Errorf("width = %d, want %d", width, 15840-2*1440)
+	}
+}
@@ -0,0 +1,542 @@
+package stencil
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CheckLayout compares a rendered document against a layout budget without
+// laying it out: the page count is estimated from the text, font sizes,
+// spacing and breaks of the main document, table widths are read from
+// their properties and grid, and fonts from the runs, the styles in use and
+// the theme.
+
+const (
+	// layoutLineHeight is the height of a single-spaced line relative to
+	// its font size.
+	layoutLineHeight = 1.15
+	// layoutCharWidth is the average width of a character relative to the
+	// font size.
+	layoutCharWidth = 0.5
+	// layoutDefaultFontSize is the font size, in half-points, Word uses when
+	// neither the document defaults nor a run set one.
+	layoutDefaultFontSize = 20
+)
+
+var (
+	defaultFontSizeRegex  = regexp.MustCompile(`(?s)<w:rPrDefault>.*?<w:sz\b([^>]*?)/?>`)
+	defaultSpacingRegex   = regexp.MustCompile(`(?s)<w:pPrDefault>.*?<w:spacing\b([^>]*?)/?>`)
+	sectionTypeValueRegex = regexp.MustCompile(`<w:type\b[^>]*?\bw:val="([^"]*)"`)
+	runFontsRegex         = regexp.MustCompile(`<w:rFonts\b[^>]*>`)
+	runFontAttrRegex      = regexp.MustCompile(`\bw:(ascii|hAnsi|cs|eastAsia)(Theme)?="([^"]*)"`)
+	themeFontRegex        = regexp.MustCompile(`(?s)<a:(major|minor)Font>(.*?)</a:(?:major|minor)Font>`)
+	themeScriptFontRegex  = regexp.MustCompile(`<a:(latin|ea|cs) typeface="([^"]*)"`)
+	embeddedFontRegex     = regexp.MustCompile(`(?s)<w:font w:name="([^"]*)"[^>]*>(.*?)</w:font>`)
+)
+
+// LayoutBudget sets the limits CheckLayout compares a rendered document
+// against. Zero values do not limit anything.
+type LayoutBudget struct {
+	// MaxPages is the number of pages the document may have.
+	MaxPages int `json:"maxPages"`
+	// Fonts lists the fonts available where the document is opened or
+	// converted. Other fonts are substituted unless they are embedded in
+	// the document. When empty, fonts are not checked.
+	Fonts []string `json:"fonts"`
+}
+
+// LayoutViolation is a limit of a LayoutBudget a document exceeds.
+type LayoutViolation struct {
+	// Rule is the limit that is exceeded: "pages", "tableWidth" or "font".
+	Rule string `json:"rule"`
+	// Location describes where, such as "document", "table 2" or
+	// "word/header1.xml".
+	Location string `json:"location"`
+	// Found is what was found, such as "14" pages, a table width of
+	// "520pt" or a font name, and Limit the limit, such as "451.3pt".
+	Found string `json:"found"`
+	Limit string `json:"limit,omitempty"`
+}
+
+func (v LayoutViolation) String() string {
+	if v.Limit == "" {
+		return fmt.Sprintf("%s: %s %q", v.Location, v.Rule, v.Found)
+	}
+	return fmt.Sprintf("%s: %s %s exceeds %s", v.Location, v.Rule, v.Found, v.Limit)
+}
+
+// LayoutReport is the result of CheckLayout.
+type LayoutReport struct {
+	// EstimatedPages is the estimated number of pages of the document.
+	EstimatedPages int `json:"estimatedPages"`
+	// Fonts lists the fonts the document uses, sorted.
+	Fonts []string `json:"fonts"`
+	// Violations lists the limits the document exceeds, in the order of
+	// the rules and then of the document.
+	Violations []LayoutViolation `json:"violations"`
+}
+
+// OK reports whether the document stays within its budget.
+func (r LayoutReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// CheckLayout checks a rendered DOCX document against a layout budget: its
+// estimated page count, tables wider than the text area of their page, and
+// fonts that are not available. The page count is an estimate from the
+// length of the text and is meant to catch documents whose size grows out
+// of bounds, not to predict the exact count.
+//
+// Example:
+//
+//	report, err := stencil.CheckLayout(rendered, stencil.LayoutBudget{
+//	    MaxPages: 10,
+//	    Fonts:    []string{"Calibri", "Calibri Light", "Arial"},
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	for _, violation := range report.Violations {
+//	    t.Errorf("layout budget: %s", violation)
+//	}
+func CheckLayout(docx []byte, budget LayoutBudget) (LayoutReport, error) {
+	if len(docx) == 0 {
+		return LayoutReport{}, fmt.Errorf("docx bytes are required")
+	}
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		return LayoutReport{}, NewDocumentError("parse", "DOCX", err)
+	}
+	documentXML, ok := pkg.get("word/document.xml")
+	if !ok {
+		return LayoutReport{}, NewDocumentError("extract", "document.xml", fmt.Errorf("part not found"))
+	}
+	doc, err := ParseDocument(strings.NewReader(string(documentXML)))
+	if err != nil {
+		return LayoutReport{}, NewDocumentError("parse", "document.xml", err)
+	}
+	stylesXML, _ := pkg.get("word/styles.xml")
+
+	var report LayoutReport
+	layout := newPageLayout(doc.Body, stylesXML)
+	report.EstimatedPages = layout.pages()
+	if budget.MaxPages > 0 && report.EstimatedPages > budget.MaxPages {
+		report.Violations = append(report.Violations, LayoutViolation{
+			Rule: "pages", Location: "document", Found: strconv.Itoa(report.EstimatedPages), Limit: strconv.Itoa(budget.MaxPages),
+		})
+	}
+	report.Violations = append(report.Violations, layout.tableOverflows()...)
+
+	fonts, err := documentFonts(pkg, stylesXML)
+	if err != nil {
+		return LayoutReport{}, err
+	}
+	available := make(map[string]bool, len(budget.Fonts))
+	for _, font := range budget.Fonts {
+		available[strings.ToLower(font)] = true
+	}
+	if fontTable, ok := pkg.get("word/fontTable.xml"); ok {
+		for _, match := range embeddedFontRegex.FindAllSubmatch(fontTable, -1) {
+			if strings.Contains(string(match[2]), "<w:embed") {
+				available[strings.ToLower(string(match[1]))] = true
+			}
+		}
+	}
+	report.Fonts = make([]string, 0, len(fonts))
+	for _, font := range fonts {
+		report.Fonts = append(report.Fonts, font.name)
+		if len(budget.Fonts) > 0 && !available[strings.ToLower(font.name)] {
+			report.Violations = append(report.Violations, LayoutViolation{Rule: "font", Location: font.part, Found: font.name})
+		}
+	}
+	return report, nil
+}
+
+// layoutSection is the page setup of a section, in twips.
+type layoutSection struct {
+	newPage    bool // the section starts on a new page
+	textWidth  int
+	textHeight int
+}
+
+// pageLayout estimates the layout of the body of a document.
+type pageLayout struct {
+	body     *Body
+	sections []layoutSection // the section of each body element
+	fontSize int             // default font size in half-points
+	before   int             // default space before paragraphs in twips
+	after    int             // default space after paragraphs in twips
+}
+
+func newPageLayout(body *Body, stylesXML []byte) *pageLayout {
+	layout := &pageLayout{body: body, fontSize: layoutDefaultFontSize}
+	defaults := docDefaultsRegex.Find(stylesXML)
+	if match := defaultFontSizeRegex.FindSubmatch(defaults); match != nil {
+		if size := sectionAttributes(string(match[1]))["val"]; size > 0 {
+			layout.fontSize = size
+		}
+	}
+	if match := defaultSpacingRegex.FindSubmatch(defaults); match != nil {
+		attrs := sectionAttributes(string(match[1]))
+		layout.before, layout.after = attrs["before"], attrs["after"]
+	}
+	if body == nil {
+		return layout
+	}
+
+	// A section ends with the paragraph holding its properties; the last
+	// one ends with the body.
+	section := layoutSectionOf(defaultSectionProperties())
+	if body.SectionProperties != nil {
+		section = layoutSectionOf(convertNamespaceURIsToPrefix(string(body.SectionProperties.Content)))
+	}
+	layout.sections = make([]layoutSection, len(body.Elements))
+	for i := len(body.Elements) - 1; i >= 0; i-- {
+		if raw := paragraphSectionProperties(body.Elements[i]); raw != nil {
+			section = layoutSectionOf(convertNamespaceURIsToPrefix(string(raw.Content)))
+		}
+		layout.sections[i] = section
+	}
+	return layout
+}
+
+// layoutSectionOf returns the page setup of the section properties sectPr.
+func layoutSectionOf(sectPr string) layoutSection {
+	width, height := defaultSectionPageWidth, defaultSectionPageHeight
+	if match := sectionPageSizeRegex.FindStringSubmatch(sectPr); match != nil {
+		attrs := sectionAttributes(match[1])
+		if attrs["w"] > 0 && attrs["h"] > 0 {
+			width, height = attrs["w"], attrs["h"]
+		}
+	}
+	margins := map[string]int{"top": defaultSectionMargin, "right": defaultSectionMargin, "bottom": defaultSectionMargin, "left": defaultSectionMargin}
+	if match := sectionPageMarginRegex.FindStringSubmatch(sectPr); match != nil {
+		for side, value := range sectionAttributes(match[1]) {
+			if _, ok := margins[side]; ok {
+				margins[side] = max(value, -value)
+			}
+		}
+	}
+	section := layoutSection{
+		newPage:    true,
+		textWidth:  max(width-margins["left"]-margins["right"], defaultSectionMargin),
+		textHeight: max(height-margins["top"]-margins["bottom"], defaultSectionMargin),
+	}
+	if match := sectionTypeValueRegex.FindStringSubmatch(sectPr); match != nil && match[1] == "continuous" {
+		section.newPage = false
+	}
+	return section
+}
+
+// pages returns the estimated number of pages of the body.
+func (l *pageLayout) pages() int {
+	if l.body == nil {
+		return 1
+	}
+	pages, used := 1, 0
+	newPage := func() {
+		pages++
+		used = 0
+	}
+	add := func(height, pageHeight int) {
+		if used > 0 && used+height > pageHeight && height <= pageHeight {
+			newPage()
+		}
+		used += height
+		for used > pageHeight {
+			pages++
+			used -= pageHeight
+		}
+	}
+
+	for i, elem := range l.body.Elements {
+		section := l.sections[i]
+		if i > 0 && section.newPage && paragraphSectionProperties(l.body.Elements[i-1]) != nil {
+			newPage()
+		}
+		switch e := elem.(type) {
+		case *Paragraph:
+			if paragraphHasRawProperty(e, "pageBreakBefore") && used > 0 {
+				newPage()
+			}
+			for j, height := range l.paragraphHeights(e, section.textWidth) {
+				if j > 0 {
+					newPage()
+				}
+				add(height, section.textHeight)
+			}
+		case *Table:
+			for _, row := range e.Rows {
+				add(l.rowHeight(e, row, section.textWidth), section.textHeight)
+			}
+		}
+	}
+	return pages
+}
+
+// paragraphHeights returns the heights of the parts of a paragraph between
+// its page breaks, in twips, when set in the given width.
+func (l *pageLayout) paragraphHeights(para *Paragraph, width int) []int {
+	size := 0
+	chars := []int{0}
+	for _, run := range paragraphRunsInOrder(para) {
+		if run.Properties != nil && run.Properties.Size != nil {
+			size = max(size, run.Properties.Size.Val)
+		}
+		if run.Text != nil {
+			chars[len(chars)-1] += utf8.RuneCountInString(run.Text.Content)
+		}
+		if run.Break != nil && run.Break.Type == "page" {
+			chars = append(chars, 0)
+		}
+	}
+	if size == 0 {
+		size = l.fontSize
+	}
+
+	before, after := l.before, l.after
+	lineHeight := float64(size) * 10 * layoutLineHeight
+	if props := para.Properties; props != nil {
+		if props.Spacing != nil {
+			before, after = props.Spacing.Before, props.Spacing.After
+			if line := float64(props.Spacing.Line); line > 0 {
+				switch props.Spacing.LineRule {
+				case "exact":
+					lineHeight = line
+				case "atLeast":
+					lineHeight = math.Max(line, lineHeight)
+				default:
+					lineHeight *= line / 240
+				}
+			}
+		}
+		if ind := props.Indentation; ind != nil {
+			indent := 0
+			for _, value := range []string{ind.Left, ind.Start, ind.Right, ind.End} {
+				n, _ := strconv.Atoi(value)
+				indent += n
+			}
+			width = max(width-indent, width/4)
+		}
+	}
+
+	perLine := max(int(float64(width)/(float64(size)*10*layoutCharWidth)), 1)
+	heights := make([]int, len(chars))
+	for i, count := range chars {
+		lines := max((count+perLine-1)/perLine, 1)
+		heights[i] = int(float64(lines)*lineHeight) + before + after
+	}
+	return heights
+}
+
+// rowHeight returns the estimated height of a table row in twips: the
+// height of its tallest cell.
+func (l *pageLayout) rowHeight(table *Table, row TableRow, textWidth int) int {
+	height := 0
+	for i, cell := range row.Cells {
+		width := textWidth / max(len(row.Cells), 1)
+		if cell.Properties != nil && cell.Properties.Width != nil && cell.Properties.Width.Type == "dxa" && cell.Properties.Width.Val > 0 {
+			width = cell.Properties.Width.Val
+		} else if table.Grid != nil && len(table.Grid.Columns) == len(row.Cells) && table.Grid.Columns[i].Width > 0 {
+			width = table.Grid.Columns[i].Width
+		}
+		cellHeight := 0
+		for j := range cell.Paragraphs {
+			for _, h := range l.paragraphHeights(&cell.Paragraphs[j], width) {
+				cellHeight += h
+			}
+		}
+		height = max(height, cellHeight)
+	}
+	return height
+}
+
+// tableOverflows returns a violation for each table of the body that is
+// wider than the text area of its page.
+func (l *pageLayout) tableOverflows() []LayoutViolation {
+	if l.body == nil {
+		return nil
+	}
+	var violations []LayoutViolation
+	tables := 0
+	for i, elem := range l.body.Elements {
+		table, ok := elem.(*Table)
+		if !ok {
+			continue
+		}
+		tables++
+		textWidth := l.sections[i].textWidth
+		if right := tableRightEdge(table, textWidth); right > textWidth {
+			violations = append(violations, LayoutViolation{
+				Rule: "tableWidth", Location: "table " + strconv.Itoa(tables),
+				Found: twipsText(right), Limit: twipsText(textWidth),
+			})
+		}
+	}
+	return violations
+}
+
+// tableRightEdge returns the distance of the right edge of a table from the
+// left margin in twips: its indentation and its width, given by its
+// properties or the sum of its grid columns.
+func tableRightEdge(table *Table, textWidth int) int {
+	width, indent := 0, 0
+	if props := table.Properties; props != nil {
+		if w := props.Width; w != nil {
+			switch w.Type {
+			case "dxa":
+				width = w.Val
+			case "pct":
+				width = textWidth * w.Val / 5000
+			}
+		}
+		if ind := props.Indentation; ind != nil && (ind.Type == "dxa" || ind.Type == "") {
+			indent = ind.Width
+		}
+	}
+	if table.Grid != nil {
+		grid := 0
+		for _, col := range table.Grid.Columns {
+			grid += col.Width
+		}
+		width = max(width, grid)
+	}
+	return indent + width
+}
+
+// twipsText describes a length in twips in points, such as "451.3pt".
+func twipsText(twips int) string {
+	return strconv.FormatFloat(float64(twips)/20, 'f', -1, 64) + "pt"
+}
+
+// paragraphHasRawProperty reports whether para has the unparsed paragraph
+// property name, such as pageBreakBefore.
+func paragraphHasRawProperty(para *Paragraph, name string) bool {
+	if para.Properties == nil {
+		return false
+	}
+	for _, raw := range para.Properties.RawXML {
+		if raw.XMLName.Local == name && !strings.Contains(string(raw.Content), `w:val="0"`) && !strings.Contains(string(raw.Content), `w:val="false"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// usedFont is a font a document uses and the first part it is used in.
+type usedFont struct {
+	name string
+	part string
+}
+
+// documentFonts returns the fonts of the runs of the main document, its
+// headers, footers and notes, of the document defaults and of the styles in
+// use, sorted by name. Theme fonts are resolved through the theme.
+func documentFonts(pkg *docxPackage, stylesXML []byte) ([]usedFont, error) {
+	theme := make(map[string]string)
+	for _, name := range pkg.names {
+		if !strings.HasPrefix(name, "word/theme/") {
+			continue
+		}
+		theme = parseThemeFonts(pkg.parts[name])
+		break
+	}
+
+	fonts := make(map[string]usedFont)
+	collect := func(part string, content []byte) {
+		for _, element := range runFontsRegex.FindAll(content, -1) {
+			for _, attr := range runFontAttrRegex.FindAllSubmatch(element, -1) {
+				name := string(attr[3])
+				if len(attr[2]) > 0 {
+					name = theme[themeFontKey(name)]
+				}
+				if key := strings.ToLower(name); name != "" && fonts[key].name == "" {
+					fonts[key] = usedFont{name: name, part: part}
+				}
+			}
+		}
+	}
+
+	for _, name := range pkg.names {
+		if name == "word/document.xml" || isHeaderPartName(name) || isFooterPartName(name) || isNotesPartName(name) {
+			collect(name, pkg.parts[name])
+		}
+	}
+	if len(stylesXML) > 0 {
+		collect("word/styles.xml", docDefaultsRegex.Find(stylesXML))
+		styles, err := parseStyleDefinitions(stylesXML)
+		if err != nil {
+			return nil, NewDocumentError("parse", "styles.xml", err)
+		}
+		for _, style := range usedStyles(pkg, styles) {
+			collect("word/styles.xml", stylesXML[style.start:style.end])
+		}
+	}
+
+	result := make([]usedFont, 0, len(fonts))
+	for _, font := range fonts {
+		result = append(result, font)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result, nil
+}
+
+// themeFontKey returns the key of a theme font reference, such as
+// "minorHAnsi", in the fonts read from the theme, such as "minor/latin".
+func themeFontKey(ref string) string {
+	for _, group := range []string{"major", "minor"} {
+		if suffix, ok := strings.CutPrefix(ref, group); ok {
+			switch suffix {
+			case "Ascii", "HAnsi":
+				return group + "/latin"
+			case "EastAsia":
+				return group + "/ea"
+			case "Bidi":
+				return group + "/cs"
+			}
+		}
+	}
+	return ""
+}
+
+// usedStyles returns the default styles, the styles the parts of pkg refer
+// to and the styles those are based on or linked to.
+func usedStyles(pkg *docxPackage, styles []styleDefinition) []styleDefinition {
+	byID := make(map[string]*styleDefinition, len(styles))
+	var pending []string
+	for i := range styles {
+		byID[styles[i].id] = &styles[i]
+		if styles[i].isDefault {
+			pending = append(pending, styles[i].id)
+		}
+	}
+	for _, name := range pkg.names {
+		if name == "word/styles.xml" || !strings.HasSuffix(name, ".xml") {
+			continue
+		}
+		for _, match := range styleReferencePattern.FindAllSubmatch(pkg.parts[name], -1) {
+			pending = append(pending, string(match[1]))
+		}
+	}
+
+	kept := make(map[string]bool)
+	var result []styleDefinition
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if kept[id] {
+			continue
+		}
+		kept[id] = true
+		if style, ok := byID[id]; ok {
+			result = append(result, *style)
+			pending = append(pending, style.references...)
+		}
+	}
+	return result
+}
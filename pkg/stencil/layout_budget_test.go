@@ -0,0 +1,113 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckLayoutPages(t *testing.T) {
+	paragraph := func(text string) string {
+		return `<w:p><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p>`
+	}
+	pageBreak := `<w:p><w:r><w:br w:type="page"/></w:r></w:p>`
+	longText := strings.Repeat("All work and no play makes a long report. ", 600)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"empty", "", 1},
+		{"page breaks", paragraph("One") + pageBreak + paragraph("Two") + pageBreak + paragraph("Three"), 3},
+		{"page break before", paragraph("One") + `<w:p><w:pPr><w:pageBreakBefore/></w:pPr><w:r><w:t>Two</w:t></w:r></w:p>`, 2},
+		{"section break", `<w:p><w:pPr><w:sectPr><w:pgSz w:w="12240" w:h="15840"/></w:sectPr></w:pPr></w:p>` + paragraph("Next section"), 2},
+		{"continuous section", `<w:p><w:pPr><w:sectPr><w:type w:val="continuous"/></w:sectPr></w:pPr></w:p>` + paragraph("Same page") +
+			`<w:sectPr><w:type w:val="continuous"/></w:sectPr>`, 1},
+		{"long text", paragraph(longText), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docx := buildValidationDOCX(t, map[string]string{"word/document.xml": validationDocumentXML(tt.body)})
+			report, err := CheckLayout(docx, LayoutBudget{})
+			if err != nil {
+				t.Fatalf("CheckLayout failed: %v", err)
+			}
+			if report.EstimatedPages != tt.want {
+				t.Errorf("EstimatedPages = %d, want %d", report.EstimatedPages, tt.want)
+			}
+		})
+	}
+
+	docx := buildValidationDOCX(t, map[string]string{"word/document.xml": validationDocumentXML(paragraph(longText))})
+	report, err := CheckLayout(docx, LayoutBudget{MaxPages: 4})
+	if err != nil {
+		t.Fatalf("CheckLayout failed: %v", err)
+	}
+	want := []LayoutViolation{{Rule: "pages", Location: "document", Found: "5", Limit: "4"}}
+	if !reflect.DeepEqual(report.Violations, want) || report.OK() {
+		t.Errorf("Violations = %v, want %v", report.Violations, want)
+	}
+}
+
+func TestCheckLayoutTableWidth(t *testing.T) {
+	table := func(grid string) string {
+		return `<w:tbl><w:tblPr><w:tblW w:w="0" w:type="auto"/></w:tblPr><w:tblGrid>` + grid +
+			`</w:tblGrid><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`
+	}
+	docx := buildValidationDOCX(t, map[string]string{"word/document.xml": validationDocumentXML(
+		table(`<w:gridCol w:w="4000"/><w:gridCol w:w="5000"/>`) +
+			table(`<w:gridCol w:w="6000"/><w:gridCol w:w="6000"/>`) +
+			`<w:sectPr><w:pgSz w:w="12240" w:h="15840"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440"/></w:sectPr>`)})
+
+	report, err := CheckLayout(docx, LayoutBudget{})
+	if err != nil {
+		t.Fatalf("CheckLayout failed: %v", err)
+	}
+	want := []LayoutViolation{{Rule: "tableWidth", Location: "table 2", Found: "600pt", Limit: "468pt"}}
+	if !reflect.DeepEqual(report.Violations, want) {
+		t.Errorf("Violations = %v, want %v", report.Violations, want)
+	}
+	if got := report.Violations[0].String(); got != "table 2: tableWidth 600pt exceeds 468pt" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestCheckLayoutFonts(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`<w:p><w:pPr><w:pStyle w:val="Quote"/></w:pPr>` +
+			`<w:r><w:rPr><w:rFonts w:ascii="Comic Sans MS" w:hAnsi="Comic Sans MS"/></w:rPr><w:t>Fun</w:t></w:r></w:p>`),
+		"word/header1.xml": validationHeaderXML(`<w:p><w:r><w:rPr><w:rFonts w:asciiTheme="majorHAnsi" w:hAnsiTheme="majorHAnsi"/></w:rPr><w:t>Header</w:t></w:r></w:p>`),
+		"word/styles.xml": `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+			`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:asciiTheme="minorHAnsi" w:hAnsiTheme="minorHAnsi"/></w:rPr></w:rPrDefault></w:docDefaults>` +
+			`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>` +
+			`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:rPr><w:rFonts w:ascii="Georgia" w:hAnsi="Georgia"/></w:rPr></w:style>` +
+			`<w:style w:type="paragraph" w:styleId="Unused"><w:name w:val="Unused"/><w:rPr><w:rFonts w:ascii="Papyrus"/></w:rPr></w:style>` +
+			`</w:styles>`,
+		"word/theme/theme1.xml": `<a:theme xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:themeElements><a:fontScheme name="Office">` +
+			`<a:majorFont><a:latin typeface="Calibri Light"/><a:ea typeface=""/><a:cs typeface=""/></a:majorFont>` +
+			`<a:minorFont><a:latin typeface="Calibri"/><a:ea typeface=""/><a:cs typeface=""/></a:minorFont>` +
+			`</a:fontScheme></a:themeElements></a:theme>`,
+		"word/fontTable.xml": `<w:fonts xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+			`<w:font w:name="Georgia"><w:embedRegular w:fontKey="{00000000-0000-0000-0000-000000000000}"/></w:font></w:fonts>`,
+	})
+
+	report, err := CheckLayout(docx, LayoutBudget{Fonts: []string{"calibri", "Calibri Light"}})
+	if err != nil {
+		t.Fatalf("CheckLayout failed: %v", err)
+	}
+	if want := []string{"Calibri", "Calibri Light", "Comic Sans MS", "Georgia"}; !reflect.DeepEqual(report.Fonts, want) {
+		t.Errorf("Fonts = %v, want %v", report.Fonts, want)
+	}
+	want := []LayoutViolation{{Rule: "font", Location: "word/document.xml", Found: "Comic Sans MS"}}
+	if !reflect.DeepEqual(report.Violations, want) {
+		t.Errorf("Violations = %v, want %v", report.Violations, want)
+	}
+
+	if report, err := CheckLayout(docx, LayoutBudget{}); err != nil || !report.OK() {
+		t.Errorf("CheckLayout without fonts = %v, %v; want no violations", report.Violations, err)
+	}
+	if _, err := CheckLayout(nil, LayoutBudget{}); err == nil {
+		t.Error("expected an error for empty docx bytes")
+	}
+}
@@ -0,0 +1,20 @@
+// Package lite is the core API of go-stencil for programs that only
+// substitute data into templates: preparing DOCX templates, registering
+// functions and rendering. Build the program with the stencil_lite tag to
+// leave out the optional subsystems of the main package and the standard
+// library packages they pull in:
+//
+//	go build -tags stencil_lite ./...
+//
+// The tag removes the HTTP template sources and fragment fetchers
+// (net/http), image decoding for embedded files and emoji (image/*), the
+// html() function and its HTML conversion, and the chart functions
+// calendar(), timeline() and orgChart(). Each can also be left out on its
+// own with stencil_nohttp, stencil_noimages, stencil_nohtml or
+// stencil_nocharts. Templates keep every other expression, control
+// structure and built-in function.
+//
+// The package works without the tag as well; the tag is what makes the
+// binary smaller. Core returns the values of the main package for features
+// outside this API.
+package lite
@@ -0,0 +1,128 @@
+package lite
+
+import (
+	"bytes"
+	"io"
+
+	core "github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// TemplateData is the data a template is rendered with.
+type TemplateData = core.TemplateData
+
+// Function is a function templates can call.
+type Function = core.Function
+
+// NewSimpleFunction returns a function called name that accepts between
+// minArgs and maxArgs arguments, -1 meaning any number, and calls handler.
+func NewSimpleFunction(name string, minArgs, maxArgs int, handler func(args ...interface{}) (interface{}, error)) Function {
+	return core.NewSimpleFunction(name, minArgs, maxArgs, handler)
+}
+
+// Engine prepares templates with the functions registered with it.
+type Engine struct {
+	engine *core.Engine
+}
+
+// New returns an engine with the global configuration and the built-in
+// functions.
+func New() *Engine {
+	return &Engine{engine: core.New()}
+}
+
+// Prepare parses a template from r.
+func (e *Engine) Prepare(r io.Reader) (*PreparedTemplate, error) {
+	return wrapPrepared(e.engine.Prepare(r))
+}
+
+// PrepareFile parses the template at path, from the cache of the engine if
+// caching is enabled.
+func (e *Engine) PrepareFile(path string) (*PreparedTemplate, error) {
+	return wrapPrepared(e.engine.PrepareFile(path))
+}
+
+// RegisterFunction makes fn available to the templates the engine
+// prepares.
+func (e *Engine) RegisterFunction(name string, fn Function) error {
+	return e.engine.RegisterFunction(name, fn)
+}
+
+// SetGlobalData sets data every render of the engine's templates sees;
+// render data takes precedence over it.
+func (e *Engine) SetGlobalData(data TemplateData) {
+	e.engine.SetGlobalData(data)
+}
+
+// Close releases the templates the engine cached.
+func (e *Engine) Close() error {
+	return e.engine.Close()
+}
+
+// Core returns the engine of the main package.
+func (e *Engine) Core() *core.Engine {
+	return e.engine
+}
+
+// Prepare parses a template from r with the default engine.
+func Prepare(r io.Reader) (*PreparedTemplate, error) {
+	return wrapPrepared(core.Prepare(r))
+}
+
+// PrepareFile parses the template at path with the default engine.
+func PrepareFile(path string) (*PreparedTemplate, error) {
+	return wrapPrepared(core.PrepareFile(path))
+}
+
+// Render renders template, a DOCX document, with data and returns the
+// rendered document.
+func Render(template []byte, data TemplateData) ([]byte, error) {
+	tmpl, err := Prepare(bytes.NewReader(template))
+	if err != nil {
+		return nil, err
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(output)
+}
+
+// PreparedTemplate is a parsed template, ready to be rendered any number
+// of times, also concurrently.
+type PreparedTemplate struct {
+	template *core.PreparedTemplate
+}
+
+func wrapPrepared(template *core.PreparedTemplate, err error) (*PreparedTemplate, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedTemplate{template: template}, nil
+}
+
+// Render renders the template with data and returns the DOCX document.
+func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
+	return pt.template.Render(data)
+}
+
+// AddFragment adds a text fragment the template can include by name.
+func (pt *PreparedTemplate) AddFragment(name string, content string) error {
+	return pt.template.AddFragment(name, content)
+}
+
+// AddFragmentFromBytes adds a DOCX fragment the template can include by
+// name.
+func (pt *PreparedTemplate) AddFragmentFromBytes(name string, docxBytes []byte) error {
+	return pt.template.AddFragmentFromBytes(name, docxBytes)
+}
+
+// Close releases the template. It cannot be rendered afterwards.
+func (pt *PreparedTemplate) Close() error {
+	return pt.template.Close()
+}
+
+// Core returns the template of the main package.
+func (pt *PreparedTemplate) Core() *core.PreparedTemplate {
+	return pt.template
+}
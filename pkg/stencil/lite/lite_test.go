@@ -0,0 +1,113 @@
+package lite
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func createTemplate(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+			`</Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+			`</Relationships>`,
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			`<w:p><w:r><w:t xml:space="preserve">` + body + `</w:t></w:r></w:p></w:body></w:document>`,
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func renderedDocumentXML(t *testing.T, output io.Reader) string {
+	t.Helper()
+	content, err := io.ReadAll(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			xml, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(xml)
+		}
+	}
+	t.Fatal("rendered document has no word/document.xml")
+	return ""
+}
+
+func TestRender(t *testing.T) {
+	engine := New()
+	defer engine.Close()
+	shout := NewSimpleFunction("shout", 1, 1, func(args ...interface{}) (interface{}, error) {
+		return strings.ToUpper(args[0].(string)) + "!", nil
+	})
+	if err := engine.RegisterFunction("shout", shout); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	engine.SetGlobalData(TemplateData{"company": "Acme"})
+
+	tmpl, err := engine.Prepare(bytes.NewReader(createTemplate(t, `{{shout(name)}} at {{company}}{{include "sig"}}{{for i in items}} {{i}}{{end}}`)))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("sig", " - Regards"); err != nil {
+		t.Fatalf("AddFragment failed: %v", err)
+	}
+	output, err := tmpl.Render(TemplateData{"name": "ada", "items": []interface{}{"x", "y"}})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	documentXML := renderedDocumentXML(t, output)
+	for _, want := range []string{">ADA!<", ">Acme<", "Regards", ">x<", ">y<"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("rendered document lacks %q: %s", want, documentXML)
+		}
+	}
+
+	rendered, err := Render(createTemplate(t, `Hello {{name}}`), TemplateData{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if documentXML := renderedDocumentXML(t, bytes.NewReader(rendered)); !strings.Contains(documentXML, "Hello Ada") {
+		t.Errorf("rendered document lacks the name: %s", documentXML)
+	}
+	if _, err := Render([]byte("not a docx"), nil); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+}
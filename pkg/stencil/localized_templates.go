@@ -0,0 +1,92 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// templateVariants holds the template files registered for each locale of
+// a template, by base name and normalized locale.
+type templateVariants struct {
+	mu    sync.RWMutex
+	paths map[string]map[string]string
+}
+
+// RegisterTemplateVariant registers the template file at path as the
+// variant of the template baseName for locale, such as "de-AT" or "de". An
+// empty locale registers the default variant, used when no variant matches
+// the requested locale. Registering a variant again replaces it.
+//
+// Example:
+//
+//	engine.RegisterTemplateVariant("invoice", "", "templates/invoice.docx")
+//	engine.RegisterTemplateVariant("invoice", "de", "templates/invoice.de.docx")
+//	engine.RegisterTemplateVariant("invoice", "de-AT", "templates/invoice.de-AT.docx")
+func (e *Engine) RegisterTemplateVariant(baseName, locale, path string) {
+	e.variants.mu.Lock()
+	defer e.variants.mu.Unlock()
+	if e.variants.paths == nil {
+		e.variants.paths = make(map[string]map[string]string)
+	}
+	if e.variants.paths[baseName] == nil {
+		e.variants.paths[baseName] = make(map[string]string)
+	}
+	e.variants.paths[baseName][normalizeLocale(locale)] = path
+}
+
+// PrepareLocalized prepares the variant of the template baseName that best
+// matches locale with PrepareFile. The locale falls back by dropping its
+// last subtag and then to the default variant, so "de-AT" tries the de-AT,
+// de and default variants in turn. Locales match regardless of case, and
+// "_" separates subtags like "-".
+//
+// Example:
+//
+//	tmpl, err := engine.PrepareLocalized("invoice", customer.Locale)
+//	if err != nil {
+//	    return err
+//	}
+//	output, err := tmpl.Render(data)
+func (e *Engine) PrepareLocalized(baseName, locale string) (*PreparedTemplate, error) {
+	path, ok := e.templateVariant(baseName, locale)
+	if !ok {
+		return nil, fmt.Errorf("no variant of template %s for locale %q", baseName, locale)
+	}
+	return e.PrepareFile(path)
+}
+
+// templateVariant returns the path of the variant of the template baseName
+// that best matches locale.
+func (e *Engine) templateVariant(baseName, locale string) (string, bool) {
+	e.variants.mu.RLock()
+	defer e.variants.mu.RUnlock()
+	variants := e.variants.paths[baseName]
+	for _, candidate := range localeFallbacks(locale) {
+		if path, ok := variants[candidate]; ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// localeFallbacks returns the normalized locales to try for locale, from
+// the most specific to the default: "de-AT" gives "de-at", "de" and "".
+func localeFallbacks(locale string) []string {
+	locale = normalizeLocale(locale)
+	var fallbacks []string
+	for locale != "" {
+		fallbacks = append(fallbacks, locale)
+		i := strings.LastIndexByte(locale, '-')
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(fallbacks, "")
+}
+
+// normalizeLocale lowercases a locale and separates its subtags with "-".
+func normalizeLocale(locale string) string {
+	return strings.Trim(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")), "-")
+}
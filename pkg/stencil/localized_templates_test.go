@@ -0,0 +1,82 @@
+package stencil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnginePrepareLocalized(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, createDOCXWithParagraphs(t, []string{text + " {{name}}"}), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	engine := NewWithConfig(DefaultConfig())
+	defer engine.Close()
+	engine.RegisterTemplateVariant("invoice", "", write("invoice.docx", "Invoice for"))
+	engine.RegisterTemplateVariant("invoice", "de", write("invoice.de.docx", "Rechnung für"))
+	engine.RegisterTemplateVariant("invoice", "de_AT", write("invoice.de-AT.docx", "Rechnung (AT) für"))
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"de-AT", "Rechnung (AT) für Ada"},
+		{"de-at", "Rechnung (AT) für Ada"},
+		{"de-CH", "Rechnung für Ada"},
+		{"de", "Rechnung für Ada"},
+		{"fr-FR", "Invoice for Ada"},
+		{"", "Invoice for Ada"},
+	}
+	for _, tt := range tests {
+		tmpl, err := engine.PrepareLocalized("invoice", tt.locale)
+		if err != nil {
+			t.Fatalf("PrepareLocalized(%q) failed: %v", tt.locale, err)
+		}
+		output, err := tmpl.Render(TemplateData{"name": "Ada"})
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, _ := io.ReadAll(output)
+		if text := extractTextFromDOCX(t, content); !strings.Contains(text, tt.want) {
+			t.Errorf("locale %q rendered %q, want %q", tt.locale, text, tt.want)
+		}
+	}
+
+	if _, err := engine.PrepareLocalized("receipt", "de"); err == nil {
+		t.Error("PrepareLocalized succeeded for an unregistered template")
+	}
+}
+
+func TestEnginePrepareLocalizedWithoutDefault(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	defer engine.Close()
+	engine.RegisterTemplateVariant("invoice", "de", "invoice.de.docx")
+
+	if _, err := engine.PrepareLocalized("invoice", "en-US"); err == nil || !strings.Contains(err.Error(), `locale "en-US"`) {
+		t.Errorf("PrepareLocalized error = %v, want no variant for en-US", err)
+	}
+}
+
+func TestLocaleFallbacks(t *testing.T) {
+	tests := map[string][]string{
+		"de-AT":      {"de-at", "de", ""},
+		"zh_Hant_TW": {"zh-hant-tw", "zh-hant", "zh", ""},
+		"en":         {"en", ""},
+		"":           {""},
+	}
+	for locale, want := range tests {
+		if got := localeFallbacks(locale); !reflect.DeepEqual(got, want) {
+			t.Errorf("localeFallbacks(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
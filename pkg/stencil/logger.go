@@ -187,7 +187,7 @@ func (l *Logger) DebugTemplate(template string, context interface{}) {
 		return
 	}
 	l.Debug("Template: %s", template)
-	l.Debug("Context: %+v", context)
+	l.Debug("Context: %+v", scrubForLog(context))
 }
 
 func (l *Logger) DebugExpression(expr string, result interface{}) {
@@ -195,7 +195,7 @@ func (l *Logger) DebugExpression(expr string, result interface{}) {
 		return
 	}
 	l.Debug("Expression: %s", expr)
-	l.Debug("Result: %v", result)
+	l.Debug("Result: %v", scrubForLog(result))
 }
 
 // Global logging functions
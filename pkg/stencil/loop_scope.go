@@ -0,0 +1,73 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loopScopeKey marks the data scope of a for loop iteration so that nested
+// loops can tell loop variables apart from render data.
+const loopScopeKey = "\x00go_stencil_loop_scope"
+
+// iterationData creates the data scope of one loop iteration, binding the
+// loop variable and the optional index variable.
+func (n *ForNode) iterationData(data TemplateData, index int, item interface{}) TemplateData {
+	loopData := newChildTemplateData(data, 3)
+	loopData[loopScopeKey] = true
+	loopData[n.Variable] = item
+	if n.IndexVar != "" {
+		loopData[n.IndexVar] = index
+	}
+	return loopData
+}
+
+// checkShadowing returns an error when strict shadowing is enabled for the
+// render and the loop reuses a variable name of an enclosing loop.
+func (n *ForNode) checkShadowing(data TemplateData) error {
+	if helpers := attachedRenderHelpers(data); helpers == nil || !helpers.strictShadowing {
+		return nil
+	}
+	for _, name := range []string{n.Variable, n.IndexVar} {
+		if name != "" && isLoopVariable(data, name) {
+			return fmt.Errorf("for loop variable %q shadows the variable of an enclosing loop", name)
+		}
+	}
+	return nil
+}
+
+// loopVariables returns the variables bound by the loops enclosing data,
+// with the values of the innermost loops for shadowed names.
+func loopVariables(data TemplateData) map[string]interface{} {
+	var variables map[string]interface{}
+	for _, scope := range collectTemplateDataScopes(data) {
+		if _, ok := scope[loopScopeKey]; !ok {
+			continue
+		}
+		for name, value := range scope {
+			if strings.HasPrefix(name, "\x00") {
+				continue
+			}
+			if _, ok := variables[name]; ok {
+				continue
+			}
+			if variables == nil {
+				variables = make(map[string]interface{})
+			}
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
+// isLoopVariable reports whether name is bound by an enclosing loop.
+func isLoopVariable(data TemplateData, name string) bool {
+	for _, scope := range collectTemplateDataScopes(data) {
+		if _, ok := scope[loopScopeKey]; !ok {
+			continue
+		}
+		if _, ok := scope[name]; ok {
+			return true
+		}
+	}
+	return false
+}
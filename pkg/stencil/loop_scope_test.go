@@ -0,0 +1,54 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStrictVariableShadowing(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for item in groups}}{{for item in item.items}}{{item}} {{end}}{{end}}`,
+	})
+	data := TemplateData{
+		"groups": []interface{}{
+			map[string]interface{}{"items": []interface{}{"a", "b"}},
+		},
+	}
+
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{})
+	if content := extractTextFromDOCX(t, output); content != "a b " {
+		t.Fatalf("expected shadowing to render by default, got %q", content)
+	}
+
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	_, err = tmpl.RenderWithOptions(data, RenderOptions{StrictVariableShadowing: true})
+	if err == nil || !strings.Contains(err.Error(), `for loop variable "item" shadows`) {
+		t.Fatalf("expected shadowing error, got %v", err)
+	}
+}
+
+func TestStrictVariableShadowingAllowsDistinctNames(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for item in items}}{{item}}{{end}}`,
+		`{{for group in groups}}{{for item in group.items}}{{item}}{{end}}{{end}}`,
+		`{{for item in items}}{{end}}`,
+	})
+	data := TemplateData{
+		"item":  "top-level",
+		"items": []interface{}{"x"},
+		"groups": []interface{}{
+			map[string]interface{}{"items": []interface{}{"a"}},
+		},
+	}
+
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{StrictVariableShadowing: true})
+	if content := extractTextFromDOCX(t, output); content != "xa" {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
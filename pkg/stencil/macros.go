@@ -0,0 +1,324 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A macro is a reusable piece of a template, defined in the document body:
+//
+//	{{macro priceCell(p)}}
+//	{{format("%.2f", p.price)}} {{p.currency}}
+//	{{endmacro}}
+//
+// and called like a function: {{priceCell(item)}}. The definitions are
+// removed from the document when the template is prepared. A macro whose
+// body is one paragraph can be called among text; its runs are inserted
+// with their formatting. A longer macro must be called alone in a
+// paragraph, which its rendered body replaces.
+
+// macroHeaderRegex matches the name and parameter list of a macro
+// definition, such as `priceCell(p, currency)`.
+var macroHeaderRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*\(([^()]*)\)$`)
+
+// templateMacro is a macro defined in a template.
+type templateMacro struct {
+	name     string
+	params   []string
+	elements []BodyElement
+}
+
+// macroCall is the fragment content of a macro call: the macro and the data
+// its body renders with.
+type macroCall struct {
+	macro *templateMacro
+	data  TemplateData
+}
+
+// parseMacroHeader parses the name and parameters of a macro definition.
+func parseMacroHeader(header string) (string, []string, error) {
+	match := macroHeaderRegex.FindStringSubmatch(strings.TrimSpace(header))
+	if match == nil {
+		return "", nil, fmt.Errorf("invalid macro definition %q, expected {{macro name(params)}}", header)
+	}
+	name := match[1]
+	if reservedBlockNames[name] || name == "macro" || name == "endmacro" {
+		return "", nil, fmt.Errorf("macro name %q is a reserved keyword", name)
+	}
+
+	var params []string
+	if list := strings.TrimSpace(match[2]); list != "" {
+		seen := make(map[string]bool)
+		for _, param := range strings.Split(list, ",") {
+			param = strings.TrimSpace(param)
+			if identifierRegex.FindString(param) != param || param == "" {
+				return "", nil, fmt.Errorf("macro %s: invalid parameter name %q", name, param)
+			}
+			if seen[param] {
+				return "", nil, fmt.Errorf("macro %s: duplicate parameter %s", name, param)
+			}
+			seen[param] = true
+			params = append(params, param)
+		}
+	}
+	return name, params, nil
+}
+
+// macroTag returns the token of a paragraph that consists of a {{macro}} or
+// {{endmacro}} tag.
+func macroTag(elem BodyElement) (Token, bool) {
+	para, ok := elem.(*Paragraph)
+	if !ok {
+		return Token{}, false
+	}
+	text := strings.TrimSpace(para.GetText())
+	if !strings.Contains(text, "macro") {
+		return Token{}, false
+	}
+	tokens := Tokenize(text)
+	if len(tokens) != 1 || (tokens[0].Type != TokenMacro && tokens[0].Type != TokenEndMacro) {
+		return Token{}, false
+	}
+	return tokens[0], true
+}
+
+// extractMacros removes the macro definitions from body and returns them by
+// name. The {{macro}} and {{endmacro}} tags must each stand in a paragraph
+// of their own at the top level of the body.
+func extractMacros(body *Body) (map[string]*templateMacro, error) {
+	if body == nil {
+		return nil, nil
+	}
+
+	var macros map[string]*templateMacro
+	elements := make([]BodyElement, 0, len(body.Elements))
+	for i := 0; i < len(body.Elements); i++ {
+		token, ok := macroTag(body.Elements[i])
+		if !ok {
+			elements = append(elements, body.Elements[i])
+			continue
+		}
+		if token.Type == TokenEndMacro {
+			return nil, fmt.Errorf("{{endmacro}} has no matching {{macro}}")
+		}
+		name, params, err := parseMacroHeader(token.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		end := -1
+		for j := i + 1; j < len(body.Elements) && end == -1; j++ {
+			if inner, ok := macroTag(body.Elements[j]); ok {
+				if inner.Type == TokenMacro {
+					return nil, fmt.Errorf("macro %s: macros cannot be nested", name)
+				}
+				end = j
+			}
+		}
+		if end == -1 {
+			return nil, fmt.Errorf("macro %s: missing {{endmacro}}", name)
+		}
+		if _, exists := macros[name]; exists {
+			return nil, fmt.Errorf("macro %s is defined more than once", name)
+		}
+		if end == i+1 {
+			return nil, fmt.Errorf("macro %s is empty", name)
+		}
+
+		if macros == nil {
+			macros = make(map[string]*templateMacro)
+		}
+		macros[name] = &templateMacro{
+			name:     name,
+			params:   params,
+			elements: append([]BodyElement(nil), body.Elements[i+1:end]...),
+		}
+		i = end
+	}
+
+	for _, elem := range elements {
+		if bodyElementHasMacroTag(elem) {
+			return nil, fmt.Errorf("{{macro}} and {{endmacro}} must each stand in a paragraph of their own in the document body")
+		}
+	}
+	if macros != nil {
+		body.Elements = elements
+	}
+	return macros, nil
+}
+
+// bodyElementHasMacroTag reports whether elem contains a {{macro}} or
+// {{endmacro}} tag.
+func bodyElementHasMacroTag(elem BodyElement) bool {
+	var texts []string
+	switch e := elem.(type) {
+	case *Paragraph:
+		texts = append(texts, e.GetText())
+	case *Table:
+		for i := range e.Rows {
+			for j := range e.Rows[i].Cells {
+				texts = append(texts, e.Rows[i].Cells[j].GetText())
+			}
+		}
+	}
+	for _, text := range texts {
+		if !strings.Contains(text, "macro") {
+			continue
+		}
+		for _, token := range Tokenize(text) {
+			if token.Type == TokenMacro || token.Type == TokenEndMacro {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupMacro returns the macro of the template being rendered with the
+// given name.
+func lookupMacro(data TemplateData, name string) (*templateMacro, bool) {
+	helpers := attachedRenderHelpers(data)
+	if helpers == nil {
+		return nil, false
+	}
+	macro, ok := helpers.macros[name]
+	return macro, ok
+}
+
+// call binds args to the parameters of the macro. Missing arguments are
+// nil.
+func (m *templateMacro) call(data TemplateData, args []interface{}) (*OOXMLFragment, error) {
+	if len(args) > len(m.params) {
+		return nil, fmt.Errorf("macro %s takes %d arguments, got %d", m.name, len(m.params), len(args))
+	}
+	scope := newChildTemplateData(data, len(m.params))
+	for i, param := range m.params {
+		if i < len(args) {
+			scope[param] = args[i]
+		} else {
+			scope[param] = nil
+		}
+	}
+	return &OOXMLFragment{Content: &macroCall{macro: m, data: scope}}, nil
+}
+
+// inline reports whether the macro body is a single paragraph, whose runs
+// can be inserted among text.
+func (m *templateMacro) inline() bool {
+	if len(m.elements) != 1 {
+		return false
+	}
+	_, ok := m.elements[0].(*Paragraph)
+	return ok
+}
+
+// runs renders the single paragraph of an inline macro and returns its runs.
+func (c *macroCall) runs(ctx *renderContext) ([]Run, error) {
+	if err := ctx.enterMacro(c.macro.name); err != nil {
+		return nil, err
+	}
+	defer ctx.leaveMacro()
+
+	rendered, err := RenderParagraphWithContext(c.macro.elements[0].(*Paragraph), c.data, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("macro %s: %w", c.macro.name, err)
+	}
+	return rendered.Runs, nil
+}
+
+// bodyElements renders the body of the macro.
+func (c *macroCall) bodyElements(ctx *renderContext) ([]BodyElement, error) {
+	if err := ctx.enterMacro(c.macro.name); err != nil {
+		return nil, err
+	}
+	defer ctx.leaveMacro()
+
+	elements, err := renderElementsWithContext(c.macro.elements, c.data, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("macro %s: %w", c.macro.name, err)
+	}
+	return elements, nil
+}
+
+// enterMacro counts a macro call being rendered. Macros may call
+// themselves, up to the include depth limit.
+func (ctx *renderContext) enterMacro(name string) error {
+	limit := ctx.includeDepthLimit()
+	if ctx.macroDepth >= limit {
+		return fmt.Errorf("maximum macro depth exceeded: %d (macro %s)", limit, name)
+	}
+	ctx.macroDepth++
+	return nil
+}
+
+// leaveMacro ends the macro call counted by the last enterMacro call.
+func (ctx *renderContext) leaveMacro() {
+	ctx.macroDepth--
+}
+
+// AddMacros makes the macros defined in library callable from the template,
+// so that a set of macros can be shared by several templates. Macros defined
+// in the template itself take precedence over library macros of the same
+// name, and macros added later replace earlier ones.
+//
+// Library macros are rendered as part of the calling template and use its
+// styles. Images and hyperlinks in a library macro are not supported.
+func (pt *PreparedTemplate) AddMacros(library *PreparedTemplate) error {
+	if pt == nil {
+		return fmt.Errorf("invalid template")
+	}
+	if library == nil {
+		return fmt.Errorf("invalid macro library")
+	}
+	if library == pt {
+		return fmt.Errorf("cannot add the macros of a template to itself")
+	}
+
+	library.mu.RLock()
+	if library.closed || library.template == nil {
+		library.mu.RUnlock()
+		return fmt.Errorf("macro library is closed")
+	}
+	macros := library.template.macroSet()
+	library.mu.RUnlock()
+
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return errTemplateClosed
+	}
+	tmpl := pt.template
+	tmpl.mu.Lock()
+	defer tmpl.mu.Unlock()
+	tmpl.macroLibrary = mergeMacros(tmpl.macroLibrary, macros)
+	tmpl.revision++
+	return nil
+}
+
+// macroSet returns the macros callable from the template.
+func (t *template) macroSet() map[string]*templateMacro {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return mergeMacros(t.macroLibrary, t.macros)
+}
+
+// mergeMacros returns the macros of base and overlay, preferring overlay.
+// The maps are not modified.
+func mergeMacros(base, overlay map[string]*templateMacro) map[string]*templateMacro {
+	if len(overlay) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return overlay
+	}
+	merged := make(map[string]*templateMacro, len(base)+len(overlay))
+	for name, macro := range base {
+		merged[name] = macro
+	}
+	for name, macro := range overlay {
+		merged[name] = macro
+	}
+	return merged
+}
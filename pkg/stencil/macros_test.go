@@ -0,0 +1,210 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func renderMacroTemplate(t *testing.T, bodyXML string, data TemplateData) string {
+	t.Helper()
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, bodyXML)))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	return extractDocumentXMLFromDOCX(t, content)
+}
+
+func TestMacros(t *testing.T) {
+	t.Run("inline call keeps formatting", func(t *testing.T) {
+		documentXML := renderMacroTemplate(t, `
+			<w:p><w:r><w:t>{{macro priceCell(p)}}</w:t></w:r></w:p>
+			<w:p><w:r><w:rPr><w:b/></w:rPr><w:t>{{p.price}}</w:t></w:r><w:r><w:t xml:space="preserve"> {{p.currency}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t xml:space="preserve">Price: {{priceCell(item)}}.</w:t></w:r></w:p>`,
+			TemplateData{"item": map[string]interface{}{"price": 12, "currency": "EUR"}})
+
+		assertInOrder(t, documentXML, `>Price: <`, `<w:b/>`, `>12<`, `> EUR<`, `>.<`)
+		if strings.Contains(documentXML, "macro") || strings.Contains(documentXML, "OOXML_FRAGMENT") {
+			t.Errorf("macro definition or placeholder left in output: %s", documentXML)
+		}
+	})
+
+	t.Run("multi-paragraph macro with loops", func(t *testing.T) {
+		documentXML := renderMacroTemplate(t, `
+			<w:p><w:r><w:t>{{macro section(title, items)}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{title}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{for i in items}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>- {{i}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{section("Fruit", fruit)}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{section("Empty")}}</w:t></w:r></w:p>`,
+			TemplateData{"fruit": []interface{}{"apple", "pear"}})
+
+		assertInOrder(t, documentXML, `>Fruit<`, `>- apple<`, `>- pear<`, `>Empty<`)
+	})
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		bodyXML string
+		wantErr string
+	}{
+		{
+			name:    "missing endmacro",
+			bodyXML: `<w:p><w:r><w:t>{{macro a()}}</w:t></w:r></w:p><w:p><w:r><w:t>x</w:t></w:r></w:p>`,
+			wantErr: "macro a: missing {{endmacro}}",
+		},
+		{
+			name:    "endmacro without macro",
+			bodyXML: `<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>`,
+			wantErr: "{{endmacro}} has no matching {{macro}}",
+		},
+		{
+			name: "nested",
+			bodyXML: `<w:p><w:r><w:t>{{macro a()}}</w:t></w:r></w:p><w:p><w:r><w:t>{{macro b()}}</w:t></w:r></w:p>
+				<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p><w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>`,
+			wantErr: "macro a: macros cannot be nested",
+		},
+		{
+			name: "duplicate parameter",
+			bodyXML: `<w:p><w:r><w:t>{{macro a(x, x)}}</w:t></w:r></w:p><w:p><w:r><w:t>x</w:t></w:r></w:p>
+				<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>`,
+			wantErr: "macro a: duplicate parameter x",
+		},
+		{
+			name:    "inline tag",
+			bodyXML: `<w:p><w:r><w:t>Text {{macro a()}} more text</w:t></w:r></w:p>`,
+			wantErr: "must each stand in a paragraph of their own",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, tt.bodyXML)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Prepare() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("render errors", func(t *testing.T) {
+		tmpl, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, `
+			<w:p><w:r><w:t>{{macro pair(a)}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{a}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{a}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{macro loop()}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{loop()}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{if mode == "text"}}See {{pair(1)}}{{end}}{{if mode == "args"}}{{pair(1, 2)}}{{end}}{{if mode == "loop"}}{{loop()}}{{end}}</w:t></w:r></w:p>`)))
+		if err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		defer tmpl.Close()
+
+		for mode, wantErr := range map[string]string{
+			"text": "macro pair has more than one paragraph and cannot be inserted at run level",
+			"args": "macro pair takes 1 arguments, got 2",
+			"loop": "maximum macro depth exceeded",
+		} {
+			_, err := tmpl.Render(TemplateData{"mode": mode})
+			if err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Errorf("Render(%s) error = %v, want %q", mode, err, wantErr)
+			}
+		}
+	})
+}
+
+func TestAddMacros(t *testing.T) {
+	library, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, `
+		<w:p><w:r><w:t>{{macro greet(name)}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>Hello {{name}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{macro sign()}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>Library</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>`)))
+	if err != nil {
+		t.Fatalf("Prepare library failed: %v", err)
+	}
+	defer library.Close()
+
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, `
+		<w:p><w:r><w:t>{{macro sign()}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>Local</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{greet(who)}}, {{sign()}}</w:t></w:r></w:p>`)))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddMacros(library); err != nil {
+		t.Fatalf("AddMacros failed: %v", err)
+	}
+	if err := tmpl.AddMacros(tmpl); err == nil {
+		t.Error("AddMacros accepted the template itself")
+	}
+
+	output, err := tmpl.Render(TemplateData{"who": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	documentXML := extractDocumentXMLFromDOCX(t, content)
+	assertInOrder(t, documentXML, `>Hello Ada<`, `>, <`, `>Local<`)
+	if strings.Contains(documentXML, "Library") {
+		t.Errorf("library macro hid the template's own macro: %s", documentXML)
+	}
+}
+
+func TestValidateMacros(t *testing.T) {
+	bodyXML := `
+		<w:p><w:r><w:t>{{macro priceCell(p)}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{p.price}} {{p.currency}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{for item in items}}{{priceCell(item)}}{{end}}</w:t></w:r></w:p>`
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, bodyXML)))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	result, err := tmpl.Validate(TemplateSchema{
+		"items": List(Object(TemplateSchema{"price": Number})),
+	})
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if !result.Valid {
+		t.Fatalf("expected valid template, got issues: %+v", result.Issues)
+	}
+
+	syntax, err := ValidateTemplateSyntax(ValidateTemplateSyntaxInput{DocxBytes: createDOCXWithBodyXML(t, `
+		<w:p><w:r><w:t>{{macro a()}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{if x}}</w:t></w:r></w:p>
+		<w:p><w:r><w:t>{{endmacro}}</w:t></w:r></w:p>`)})
+	if err != nil {
+		t.Fatalf("ValidateTemplateSyntax failed: %v", err)
+	}
+	if syntax.Valid || len(syntax.Issues) == 0 || syntax.Issues[0].Code != IssueCodeControlBlockMismatch {
+		t.Errorf("expected a control block mismatch, got %+v", syntax.Issues)
+	}
+
+	dictionary, err := BuildDataDictionary(DataDictionaryInput{DocxBytes: createDOCXWithBodyXML(t, bodyXML)})
+	if err != nil {
+		t.Fatalf("BuildDataDictionary failed: %v", err)
+	}
+	for _, field := range dictionary.Fields {
+		if strings.HasPrefix(field.Path, "p.") || field.Path == "p" {
+			t.Errorf("macro parameter listed as a field: %+v", dictionary.Fields)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package stencil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// minMappedFileSize is the size below which prepareMappedFile reads a
+// template file rather than mapping it. Small files save little memory
+// mapped, and read they cannot crash a render when rewritten in place.
+const minMappedFileSize = 64 << 10
+
+// errMemoryMapUnsupported is returned by mapFile on platforms without
+// memory-mapped files.
+var errMemoryMapUnsupported = errors.New("memory-mapped files are not supported on this platform")
+
+// mappedFile is a template file mapped into memory. Templates sharing its
+// data retain it, and the last to be closed unmaps it.
+type mappedFile struct {
+	data []byte
+
+	mu   sync.Mutex
+	refs int
+}
+
+// retain adds a reference to m, which may be nil.
+func (m *mappedFile) retain() *mappedFile {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	m.refs++
+	m.mu.Unlock()
+	return m
+}
+
+// release removes a reference to m, unmapping it with the last.
+func (m *mappedFile) release() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.refs == 0 {
+		return nil
+	}
+	m.refs--
+	if m.refs > 0 {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return unmapFile(data)
+}
+
+// prepareMappedFile prepares the template file at path from a memory
+// mapping of it. Parts the template renders unchanged, such as media, are
+// never copied onto the heap; rendering copies them into the output still
+// compressed. Files smaller than minMappedFileSize, and all files on
+// platforms without memory-mapped files, are read instead.
+func (e *Engine) prepareMappedFile(path string) (*PreparedTemplate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open template file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open template file: %w", err)
+	}
+	if info.Size() < minMappedFileSize {
+		return e.prepare(file)
+	}
+	data, err := mapFile(file, info.Size())
+	if err != nil {
+		if !errors.Is(err, errMemoryMapUnsupported) {
+			return nil, fmt.Errorf("failed to map template file: %w", err)
+		}
+		return e.prepare(file)
+	}
+	mapping := &mappedFile{data: data, refs: 1}
+
+	tmpl, err := e.prepareWith(func() (*PreparedTemplate, error) {
+		return prepareSource(mapping.data)
+	})
+	if err != nil {
+		mapping.release()
+		return nil, err
+	}
+	tmpl.template.mu.Lock()
+	tmpl.template.mapping = mapping
+	tmpl.template.copyCompressedParts = true
+	tmpl.template.mu.Unlock()
+	return tmpl, nil
+}
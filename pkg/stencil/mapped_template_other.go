@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package stencil
+
+import "os"
+
+func mapFile(*os.File, int64) ([]byte, error) {
+	return nil, errMemoryMapUnsupported
+}
+
+func unmapFile([]byte) error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareFileMemoryMapped(t *testing.T) {
+	// A template with a large media part
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	base := createDOCXWithParagraphs(t, []string{"Hello {{name}}"})
+	r, err := zip.NewReader(bytes.NewReader(base), int64(len(base)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range r.File {
+		if err := w.Copy(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Incompressible, so the file is large enough to be mapped
+	media := make([]byte, 2*minMappedFileSize)
+	rand.New(rand.NewSource(1)).Read(media)
+	fw, err := w.Create("word/media/image1.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(media)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "letter.docx")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.MemoryMapTemplates = true
+	engine := NewWithConfig(config)
+	defer engine.Close()
+
+	tmpl, err := engine.PrepareFile(path)
+	if err != nil {
+		t.Fatalf("PrepareFile failed: %v", err)
+	}
+	mapping := tmpl.template.mapping
+	if mapping == nil {
+		t.Skip("memory-mapped files are not supported on this platform")
+	}
+	edited, err := tmpl.WithDocument(tmpl.Document())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edited.Close()
+
+	render := func(pt *PreparedTemplate) []byte {
+		t.Helper()
+		output, err := pt.Render(TemplateData{"name": "Ada"})
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, err := io.ReadAll(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+	output := render(tmpl)
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Hello Ada") {
+		t.Errorf("rendered text = %q", text)
+	}
+	rendered, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range rendered.File {
+		if file.Name != "word/media/image1.png" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(content, media) {
+			t.Errorf("media part not copied intact: %v", err)
+		}
+	}
+
+	// The mapping outlives the cached template while a template made from
+	// it is open
+	tmpl.Close()
+	engine.ClearCache()
+	if mapping.data == nil {
+		t.Fatal("mapping released while a template made from it is open")
+	}
+	if text := extractTextFromDOCX(t, render(edited)); !strings.Contains(text, "Hello Ada") {
+		t.Errorf("rendered text of the edited template = %q", text)
+	}
+	edited.Close()
+	if mapping.data != nil {
+		t.Error("mapping not released after the last template was closed")
+	}
+}
+
+func TestPrepareFileReadsSmallTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small.docx")
+	if err := os.WriteFile(path, createDOCXWithParagraphs(t, []string{"Hello {{name}}"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultConfig()
+	config.MemoryMapTemplates = true
+	engine := NewWithConfig(config)
+	defer engine.Close()
+
+	tmpl, err := engine.PrepareFile(path)
+	if err != nil {
+		t.Fatalf("PrepareFile failed: %v", err)
+	}
+	defer tmpl.Close()
+	if tmpl.template.mapping != nil {
+		t.Error("template under minMappedFileSize was mapped")
+	}
+
+	// Rewriting the file in place does not affect the template read from it
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if text := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{"name": "Ada"})); !strings.Contains(text, "Hello Ada") {
+		t.Errorf("rendered text = %q", text)
+	}
+}
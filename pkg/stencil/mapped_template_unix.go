@@ -0,0 +1,27 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package stencil
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the size bytes of file into memory read-only. MAP_PRIVATE
+// would not help against files changed in place: pages beyond the end of a
+// truncated file fault with SIGBUS either way, and pages not yet copied on
+// write still show the file's new content.
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	if size <= 0 || int64(int(size)) != size {
+		return nil, fmt.Errorf("cannot map %d bytes", size)
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
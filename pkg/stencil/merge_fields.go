@@ -0,0 +1,432 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil/render"
+)
+
+// MergeFieldReport describes the changes ExportMergeFields or
+// ImportMergeFields made.
+type MergeFieldReport struct {
+	// Converted counts the placeholders or merge fields converted.
+	Converted int
+	// Fields lists the names of the converted fields, sorted and without
+	// duplicates.
+	Fields []string
+	// Skipped lists the template tags or field instructions left as they
+	// were because they have no counterpart, such as {{if paid}} or
+	// {{price * 2}} on export and MERGEFIELD "First Name" on import.
+	Skipped []string
+}
+
+// fldSimplePattern matches the simple fields (w:fldSimple) of a part.
+var fldSimplePattern = regexp.MustCompile(`(?s)<w:fldSimple\b[^>]*?(?:/>|>(.*?)</w:fldSimple>)`)
+
+// runPropertiesPattern matches the first run properties of a simple field.
+var runPropertiesPattern = regexp.MustCompile(`(?s)<w:rPr>.*?</w:rPr>|<w:rPr/>`)
+
+// ExportMergeFields converts the placeholders of a template, such as
+// {{customer.name}}, into Word MERGEFIELD fields, so the template can be
+// used in a classic Word mail merge. Only placeholders of a plain field
+// path are converted; the field is named by the path, so the columns of the
+// mail merge data source are named like the data of the template. Control
+// structures, expressions and function calls have no merge field
+// counterpart and are left as text and listed in MergeFieldReport.Skipped.
+//
+// The body, headers, footers, footnotes and endnotes are converted.
+// ImportMergeFields converts the fields back.
+//
+// Example:
+//
+//	mailMerge, report, err := stencil.ExportMergeFields(docxBytes)
+//	if err != nil {
+//	    return err
+//	}
+//	log.Printf("merge fields: %s", strings.Join(report.Fields, ", "))
+func ExportMergeFields(docx []byte) ([]byte, MergeFieldReport, error) {
+	fields := make(map[string]bool)
+	var report MergeFieldReport
+	output, err := rewriteTemplateParts(docx, nil, func(para *Paragraph) {
+		rewriteParagraphRuns(para, func(runs []Run) []Run {
+			return exportMergeFieldRuns(runs, fields, &report)
+		})
+	})
+	if err != nil {
+		return nil, report, err
+	}
+	report.Fields = sortedMergeFieldNames(fields)
+	return output, report, nil
+}
+
+// ImportMergeFields converts the MERGEFIELD fields of a Word mail merge
+// document into placeholders, such as {{customer.name}}, taking the
+// formatting of the field result. Both complex fields and simple fields
+// (w:fldSimple) are converted. Fields whose name is not a plain field path,
+// such as "First Name", and merge fields nested in other fields, such as in
+// an IF field, are left as they are and listed in MergeFieldReport.Skipped.
+// Field switches, such as \* Upper, are dropped.
+//
+// Example:
+//
+//	template, report, err := stencil.ImportMergeFields(mailMergeBytes)
+func ImportMergeFields(docx []byte) ([]byte, MergeFieldReport, error) {
+	fields := make(map[string]bool)
+	var report MergeFieldReport
+	output, err := rewriteTemplateParts(docx, func(content []byte) []byte {
+		return importSimpleMergeFields(content, fields, &report)
+	}, func(para *Paragraph) {
+		rewriteParagraphRuns(para, func(runs []Run) []Run {
+			return importMergeFieldRuns(runs, fields, &report)
+		})
+	})
+	if err != nil {
+		return nil, report, err
+	}
+	report.Fields = sortedMergeFieldNames(fields)
+	return output, report, nil
+}
+
+// rewriteTemplateParts applies rewriteXML to the content and
+// rewriteParagraph to the paragraphs of the main document and the story
+// parts of docx, in document order. Runs are merged first, so template tags
+// are not split across runs of the same formatting.
+func rewriteTemplateParts(docx []byte, rewriteXML func([]byte) []byte, rewriteParagraph func(*Paragraph)) ([]byte, error) {
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		return nil, NewDocumentError("parse", "DOCX", err)
+	}
+	if _, ok := pkg.get("word/document.xml"); !ok {
+		return nil, NewDocumentError("extract", "document.xml", fmt.Errorf("part not found"))
+	}
+
+	rewriteParagraphs := func(paragraphs []*Paragraph) {
+		for _, para := range paragraphs {
+			render.MergeConsecutiveRuns(para)
+			rewriteParagraph(para)
+		}
+	}
+	for _, name := range pkg.names {
+		if name != "word/document.xml" && !isStoryPartName(name) {
+			continue
+		}
+		content := pkg.parts[name]
+		if rewriteXML != nil {
+			content = rewriteXML(content)
+		}
+		if name == "word/document.xml" {
+			doc, err := ParseDocument(bytes.NewReader(content))
+			if err != nil {
+				return nil, NewDocumentError("parse", name, err)
+			}
+			if doc.Body != nil {
+				rewriteParagraphs(documentParagraphs(doc))
+				if content, err = marshalDocumentWithNamespaces(doc); err != nil {
+					return nil, NewDocumentError("write", name, err)
+				}
+			}
+		} else {
+			var encoded bytes.Buffer
+			content, err = spliceStoryContainers(name, content, func(elements []BodyElement) ([]byte, bool, error) {
+				rewriteParagraphs(bodyElementParagraphs(elements))
+				chunk, err := encodeStoryElements(elements)
+				if err != nil {
+					return nil, false, err
+				}
+				encoded.Write(chunk)
+				return chunk, true, nil
+			})
+			if err != nil {
+				return nil, NewDocumentError("write", name, err)
+			}
+			content = declareStoryNamespaces(content, encoded.Bytes(), nil)
+		}
+		pkg.set(name, content)
+	}
+
+	output, err := pkg.bytes()
+	if err != nil {
+		return nil, NewDocumentError("write", "DOCX", err)
+	}
+	return output, nil
+}
+
+// rewriteParagraphRuns replaces each sequence of consecutive runs of para,
+// and the runs of each hyperlink, with rewrite(runs).
+func rewriteParagraphRuns(para *Paragraph, rewrite func([]Run) []Run) {
+	if len(para.Content) == 0 {
+		para.Runs = rewrite(para.Runs)
+		return
+	}
+
+	var content []ParagraphContent
+	var pending []Run
+	flush := func() {
+		for _, run := range rewrite(pending) {
+			r := run
+			content = append(content, &r)
+		}
+		pending = nil
+	}
+	for _, item := range para.Content {
+		switch c := item.(type) {
+		case *Run:
+			pending = append(pending, *c)
+		case *Hyperlink:
+			flush()
+			link := *c
+			link.Runs = rewrite(c.Runs)
+			content = append(content, &link)
+		default:
+			flush()
+			content = append(content, item)
+		}
+	}
+	flush()
+
+	para.Content = content
+	para.Runs = nil
+	para.Hyperlinks = nil
+	for _, item := range content {
+		switch c := item.(type) {
+		case *Run:
+			para.Runs = append(para.Runs, *c)
+		case *Hyperlink:
+			para.Hyperlinks = append(para.Hyperlinks, *c)
+		}
+	}
+}
+
+// exportMergeFieldRuns replaces the placeholders of runs with MERGEFIELD
+// fields.
+func exportMergeFieldRuns(runs []Run, fields map[string]bool, report *MergeFieldReport) []Run {
+	var result []Run
+	for _, run := range runs {
+		if run.Text == nil || !strings.Contains(run.Text.Content, "{{") {
+			result = append(result, run)
+			continue
+		}
+		text := run.Text.Content
+		textRun := func(content string) {
+			if content == "" {
+				return
+			}
+			result = append(result, Run{
+				Properties: run.Properties,
+				Attrs:      run.Attrs,
+				Text:       &Text{Space: "preserve", Content: content},
+			})
+		}
+
+		last := 0
+		rest := run
+		rest.Text = nil
+		for _, match := range tokenRegex.FindAllStringSubmatchIndex(text, -1) {
+			tag := text[match[0]:match[1]]
+			token := parseToken(strings.TrimSpace(text[match[2]:match[3]]))
+			path, ok := "", false
+			if token.Type == TokenVariable {
+				path, ok = mergeFieldPath(token.Value)
+			}
+			if !ok {
+				report.Skipped = append(report.Skipped, tag)
+				continue
+			}
+			textRun(text[last:match[0]])
+			field := &FieldCode{
+				Instruction: "MERGEFIELD " + path + ` \* MERGEFORMAT`,
+				Result:      "«" + path + "»",
+			}
+			result = append(result, fieldCodeRuns(field, &rest, nil)...)
+			fields[path] = true
+			report.Converted++
+			last = match[1]
+		}
+		if last == 0 {
+			result = append(result, run)
+			continue
+		}
+		textRun(text[last:])
+		// Keep breaks and other content of the run after its text
+		if run.Break != nil || len(run.RawXML) > 0 {
+			rest.Break = run.Break
+			result = append(result, rest)
+		}
+	}
+	return result
+}
+
+// importMergeFieldRuns replaces the MERGEFIELD complex fields of runs with
+// placeholders.
+func importMergeFieldRuns(runs []Run, fields map[string]bool, report *MergeFieldReport) []Run {
+	var result []Run
+	for i := 0; i < len(runs); i++ {
+		if fieldCharType(runs[i]) != "begin" {
+			result = append(result, runs[i])
+			continue
+		}
+
+		// Find the end of the field and its instruction
+		var instruction strings.Builder
+		var resultRun *Run
+		depth, nested, inResult := 0, false, false
+		end := -1
+		for j := i; j < len(runs) && end < 0; j++ {
+			for _, raw := range runs[j].RawXML {
+				kind, value, ok := parseFieldInstructionRawXML(raw.Content)
+				if !ok {
+					continue
+				}
+				switch kind {
+				case "begin":
+					depth++
+					nested = nested || depth > 1
+				case "instrText":
+					if depth == 1 && !inResult {
+						instruction.WriteString(value)
+					}
+				case "separate":
+					if depth == 1 {
+						inResult = true
+					}
+				case "end":
+					depth--
+					if depth == 0 {
+						end = j
+					}
+				}
+			}
+			if inResult && resultRun == nil && runs[j].Text != nil {
+				resultRun = &runs[j]
+			}
+		}
+		if end < 0 {
+			result = append(result, runs[i:]...)
+			break
+		}
+
+		name, isMergeField := parseMergeFieldInstruction(instruction.String())
+		path, ok := mergeFieldPath(name)
+		if !isMergeField || nested || !ok || path != name {
+			if isMergeField {
+				report.Skipped = append(report.Skipped, strings.TrimSpace(instruction.String()))
+			}
+			result = append(result, runs[i:end+1]...)
+			i = end
+			continue
+		}
+		placeholder := runs[i]
+		if resultRun != nil {
+			placeholder = *resultRun
+		}
+		placeholder.RawXML = nil
+		placeholder.Break = nil
+		placeholder.Text = &Text{Space: "preserve", Content: "{{" + path + "}}"}
+		result = append(result, placeholder)
+		fields[path] = true
+		report.Converted++
+		i = end
+	}
+	return result
+}
+
+// importSimpleMergeFields replaces the MERGEFIELD simple fields of a part
+// with placeholder runs.
+func importSimpleMergeFields(content []byte, fields map[string]bool, report *MergeFieldReport) []byte {
+	return fldSimplePattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		decoder := xml.NewDecoder(bytes.NewReader(match))
+		token, err := decoder.Token()
+		start, ok := token.(xml.StartElement)
+		if err != nil || !ok {
+			return match
+		}
+		var instruction string
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "instr" {
+				instruction = attr.Value
+			}
+		}
+		name, isMergeField := parseMergeFieldInstruction(instruction)
+		if !isMergeField {
+			return match
+		}
+		path, ok := mergeFieldPath(name)
+		if !ok || path != name || bytes.Contains(match[1:], []byte("<w:fldSimple")) {
+			report.Skipped = append(report.Skipped, strings.TrimSpace(instruction))
+			return match
+		}
+		fields[path] = true
+		report.Converted++
+		properties := runPropertiesPattern.Find(match)
+		return []byte(`<w:r>` + string(properties) + `<w:t xml:space="preserve">{{` + escapeXMLText(path) + `}}</w:t></w:r>`)
+	})
+}
+
+// fieldCharType returns the type of the first field character of run, such
+// as "begin", or "" if it has none.
+func fieldCharType(run Run) string {
+	for _, raw := range run.RawXML {
+		if raw.XMLName.Local != "fldChar" {
+			continue
+		}
+		if kind, _, ok := parseFieldInstructionRawXML(raw.Content); ok {
+			return kind
+		}
+	}
+	return ""
+}
+
+// parseMergeFieldInstruction returns the field name of a MERGEFIELD
+// instruction, such as customer.name of MERGEFIELD customer.name \*
+// MERGEFORMAT. Quoted names are unquoted.
+func parseMergeFieldInstruction(instruction string) (string, bool) {
+	instruction = strings.TrimSpace(instruction)
+	keyword, rest, _ := strings.Cut(instruction, " ")
+	if !strings.EqualFold(keyword, "MERGEFIELD") {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, `"`) {
+		if end := strings.Index(rest[1:], `"`); end >= 0 {
+			return rest[1 : end+1], true
+		}
+		return "", true
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name, true
+}
+
+// mergeFieldPath returns the field path expr reads, such as customer.name,
+// if expr is nothing but a field path.
+func mergeFieldPath(expr string) (string, bool) {
+	node, err := ParseExpressionStrict(expr)
+	if err != nil {
+		return "", false
+	}
+	var path func(node ExpressionNode) (string, bool)
+	path = func(node ExpressionNode) (string, bool) {
+		switch n := node.(type) {
+		case *VariableNode:
+			return n.Name, true
+		case *FieldAccessNode:
+			object, ok := path(n.Object)
+			return object + "." + n.Field, ok
+		}
+		return "", false
+	}
+	return path(node)
+}
+
+func sortedMergeFieldNames(fields map[string]bool) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
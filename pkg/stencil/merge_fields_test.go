@@ -0,0 +1,98 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportAndImportMergeFields(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		"Dear {{customer.name}}, from {{ city }}",
+		"{{if vip}}Thanks{{end}} Total: {{total * 2}}",
+	})
+
+	exported, report, err := ExportMergeFields(docx)
+	if err != nil {
+		t.Fatalf("ExportMergeFields failed: %v", err)
+	}
+	if report.Converted != 2 || !reflect.DeepEqual(report.Fields, []string{"city", "customer.name"}) {
+		t.Errorf("export report = %+v", report)
+	}
+	if want := []string{"{{if vip}}", "{{end}}", "{{total * 2}}"}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("Skipped = %q, want %q", report.Skipped, want)
+	}
+	documentXML := extractDocumentXMLFromDOCX(t, exported)
+	for _, want := range []string{`MERGEFIELD customer.name \* MERGEFORMAT`, `w:fldCharType="separate"`, "«city»"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("exported document missing %q:\n%s", want, documentXML)
+		}
+	}
+	if strings.Contains(documentXML, "{{customer.name}}") {
+		t.Error("exported document still contains the placeholder")
+	}
+
+	imported, report, err := ImportMergeFields(exported)
+	if err != nil {
+		t.Fatalf("ImportMergeFields failed: %v", err)
+	}
+	if report.Converted != 2 || len(report.Skipped) != 0 {
+		t.Errorf("import report = %+v", report)
+	}
+	if text := extractTextFromDOCX(t, imported); text != "Dear {{customer.name}}, from {{city}}{{if vip}}Thanks{{end}} Total: {{total * 2}}" {
+		t.Errorf("imported text = %q", text)
+	}
+
+	tmpl, err := Prepare(bytes.NewReader(imported))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(TemplateData{"customer": map[string]interface{}{"name": "Ada"}, "city": "Berlin", "vip": false, "total": 5})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, err := io.ReadAll(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := extractTextFromDOCX(t, content); text != "Dear Ada, from Berlin Total: 10" {
+		t.Errorf("rendered text = %q", text)
+	}
+}
+
+func TestImportMergeFieldsFromWord(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p>`+
+		`<w:r><w:t xml:space="preserve">Hello </w:t></w:r>`+
+		`<w:fldSimple w:instr=" MERGEFIELD first_name "><w:r><w:rPr><w:b/></w:rPr><w:t>«first_name»</w:t></w:r></w:fldSimple>`+
+		`<w:r><w:t xml:space="preserve"> from </w:t></w:r>`+
+		`<w:r><w:fldChar w:fldCharType="begin"/></w:r>`+
+		`<w:r><w:instrText xml:space="preserve"> MERGEFIELD "Home City" </w:instrText></w:r>`+
+		`<w:r><w:fldChar w:fldCharType="separate"/></w:r>`+
+		`<w:r><w:t>«Home City»</w:t></w:r>`+
+		`<w:r><w:fldChar w:fldCharType="end"/></w:r>`+
+		`</w:p>`)
+
+	imported, report, err := ImportMergeFields(docx)
+	if err != nil {
+		t.Fatalf("ImportMergeFields failed: %v", err)
+	}
+	if report.Converted != 1 || !reflect.DeepEqual(report.Fields, []string{"first_name"}) {
+		t.Errorf("report = %+v", report)
+	}
+	if want := []string{`MERGEFIELD "Home City"`}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("Skipped = %q, want %q", report.Skipped, want)
+	}
+	documentXML := extractDocumentXMLFromDOCX(t, imported)
+	if !strings.Contains(documentXML, "<w:b") || !strings.Contains(documentXML, "{{first_name}}") {
+		t.Errorf("simple field not converted with its formatting:\n%s", documentXML)
+	}
+	if strings.Contains(documentXML, "«first_name»") || strings.Contains(documentXML, "fldSimple") {
+		t.Errorf("simple field left in the document:\n%s", documentXML)
+	}
+	if !strings.Contains(documentXML, "Home City") || !strings.Contains(documentXML, "fldChar") {
+		t.Errorf("skipped field removed:\n%s", documentXML)
+	}
+}
@@ -0,0 +1,232 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Money is an amount in a currency. Arithmetic, sum(), format() and
+// currency() carry the currency along: combining or comparing amounts in
+// different currencies fails the render, and output uses the currency's
+// symbol.
+//
+// Example:
+//
+//	data := stencil.TemplateData{
+//	    "items": []map[string]interface{}{
+//	        {"price": stencil.Money{Amount: 19.99, Currency: "EUR"}},
+//	        {"price": stencil.Money{Amount: 5, Currency: "EUR"}},
+//	    },
+//	}
+//	// {{sum(map("price", items))}} renders "24,99 €"
+type Money struct {
+	Amount float64
+	// Currency is the ISO 4217 code of the currency, such as "EUR".
+	Currency string
+}
+
+// String formats the amount in the customary format of its currency.
+func (m Money) String() string {
+	return formatMoney(m, "")
+}
+
+// currencyInfo describes how amounts in a currency are written.
+type currencyInfo struct {
+	symbol   string
+	decimals int
+	// locale is the number format used when no locale is given.
+	locale string
+}
+
+var currencies = map[string]currencyInfo{
+	"USD": {symbol: "$", decimals: 2, locale: "en-US"},
+	"EUR": {symbol: "€", decimals: 2, locale: "de-DE"},
+	"GBP": {symbol: "£", decimals: 2, locale: "en-GB"},
+	"JPY": {symbol: "¥", decimals: 0, locale: "ja-JP"},
+	"HUF": {symbol: "Ft", decimals: 2, locale: "hu-HU"},
+	"CHF": {symbol: "CHF", decimals: 2, locale: "de-CH"},
+}
+
+// lookupCurrency returns how amounts in the currency with the given code are
+// written. Unknown currencies are written with their code.
+func lookupCurrency(code string) currencyInfo {
+	if info, ok := currencies[code]; ok {
+		return info
+	}
+	return currencyInfo{symbol: code, decimals: 2, locale: "en-US"}
+}
+
+// formatMoney formats m with the number format and symbol placement of
+// locale, or of the currency's customary locale if locale is empty.
+func formatMoney(m Money, locale string) string {
+	info := lookupCurrency(normalizeCurrency(m.Currency))
+	if locale == "" {
+		locale = info.locale
+	}
+
+	_, before, space := currencyPlacement(locale)
+	// Codes such as "CHF" are always separated from the number
+	if before && isCurrencyCode(info.symbol) {
+		space = true
+	}
+
+	return newCurrencyFormatter(locale, info.symbol, before, space, info.decimals)(m.Amount)
+}
+
+// isCurrencyCode reports whether symbol is a letter code rather than a sign.
+func isCurrencyCode(symbol string) bool {
+	if len(symbol) < 2 {
+		return false
+	}
+	for _, r := range symbol {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// toMoney returns val as Money with a normalized currency code.
+func toMoney(val interface{}) (Money, bool) {
+	switch v := val.(type) {
+	case Money:
+		v.Currency = normalizeCurrency(v.Currency)
+		return v, true
+	case *Money:
+		if v == nil {
+			return Money{}, false
+		}
+		return Money{Amount: v.Amount, Currency: normalizeCurrency(v.Currency)}, true
+	default:
+		return Money{}, false
+	}
+}
+
+// evaluateMoneyOperation evaluates a binary operation with a Money operand.
+// It reports false if neither operand is Money or the operator has no money
+// semantics, such as string concatenation, so the operation is evaluated as
+// usual.
+func evaluateMoneyOperation(left interface{}, operator string, right interface{}) (interface{}, bool, error) {
+	leftMoney, leftOk := toMoney(left)
+	rightMoney, rightOk := toMoney(right)
+	if !leftOk && !rightOk {
+		return nil, false, nil
+	}
+
+	switch operator {
+	case "+", "-", "<", ">", "<=", ">=":
+		if operator == "+" && (isString(left) || isString(right)) {
+			return nil, false, nil
+		}
+		a, b, currency, err := moneyOperands(left, right, leftMoney, rightMoney, leftOk, rightOk, operator)
+		if err != nil {
+			return nil, true, err
+		}
+		switch operator {
+		case "+":
+			return Money{Amount: a + b, Currency: currency}, true, nil
+		case "-":
+			return Money{Amount: a - b, Currency: currency}, true, nil
+		case "<":
+			return a < b, true, nil
+		case ">":
+			return a > b, true, nil
+		case "<=":
+			return a <= b, true, nil
+		default:
+			return a >= b, true, nil
+		}
+	case "==", "!=":
+		if !leftOk || !rightOk {
+			return nil, false, nil
+		}
+		equal := leftMoney.Currency == rightMoney.Currency && leftMoney.Amount == rightMoney.Amount
+		return equal == (operator == "=="), true, nil
+	case "*":
+		if leftOk && rightOk {
+			return nil, true, fmt.Errorf("cannot multiply two amounts of money")
+		}
+		if leftOk {
+			factor, ok := toFloat64(right)
+			if !ok {
+				return nil, true, fmt.Errorf("cannot multiply Money and %T", right)
+			}
+			return Money{Amount: leftMoney.Amount * factor, Currency: leftMoney.Currency}, true, nil
+		}
+		factor, ok := toFloat64(left)
+		if !ok {
+			return nil, true, fmt.Errorf("cannot multiply %T and Money", left)
+		}
+		return Money{Amount: factor * rightMoney.Amount, Currency: rightMoney.Currency}, true, nil
+	case "/":
+		if !leftOk {
+			return nil, true, fmt.Errorf("cannot divide %T by Money", left)
+		}
+		if rightOk {
+			if leftMoney.Currency != rightMoney.Currency {
+				return nil, true, fmt.Errorf("cannot divide %s and %s amounts", leftMoney.Currency, rightMoney.Currency)
+			}
+			if rightMoney.Amount == 0 {
+				return nil, true, errDivisionByZero
+			}
+			return leftMoney.Amount / rightMoney.Amount, true, nil
+		}
+		divisor, ok := toFloat64(right)
+		if !ok {
+			return nil, true, fmt.Errorf("cannot divide Money by %T", right)
+		}
+		if divisor == 0 {
+			return nil, true, errDivisionByZero
+		}
+		return Money{Amount: leftMoney.Amount / divisor, Currency: leftMoney.Currency}, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// moneyOperands returns the amounts of an addition, subtraction or
+// comparison with a Money operand and the currency of the result. A plain
+// number is taken to be in the currency of the other operand.
+func moneyOperands(left, right interface{}, leftMoney, rightMoney Money, leftOk, rightOk bool, operator string) (float64, float64, string, error) {
+	verb := moneyOperatorVerb(operator)
+	switch {
+	case leftOk && rightOk:
+		if leftMoney.Currency != rightMoney.Currency {
+			return 0, 0, "", fmt.Errorf("cannot %s %s and %s amounts", verb, leftMoney.Currency, rightMoney.Currency)
+		}
+		return leftMoney.Amount, rightMoney.Amount, leftMoney.Currency, nil
+	case leftOk:
+		number, ok := toFloat64(right)
+		if !ok {
+			return 0, 0, "", fmt.Errorf("cannot %s Money and %T", verb, right)
+		}
+		return leftMoney.Amount, number, leftMoney.Currency, nil
+	default:
+		number, ok := toFloat64(left)
+		if !ok {
+			return 0, 0, "", fmt.Errorf("cannot %s %T and Money", verb, left)
+		}
+		return number, rightMoney.Amount, rightMoney.Currency, nil
+	}
+}
+
+func moneyOperatorVerb(operator string) string {
+	switch operator {
+	case "+":
+		return "add"
+	case "-":
+		return "subtract"
+	default:
+		return "compare"
+	}
+}
+
+func isString(val interface{}) bool {
+	_, ok := val.(string)
+	return ok
+}
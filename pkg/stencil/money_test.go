@@ -0,0 +1,94 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMoneyExpressions(t *testing.T) {
+	data := TemplateData{
+		"net":   Money{Amount: 100, Currency: "EUR"},
+		"fee":   Money{Amount: 2.5, Currency: "eur"},
+		"usd":   Money{Amount: 10, Currency: "USD"},
+		"yen":   Money{Amount: 1500, Currency: "JPY"},
+		"items": []interface{}{Money{Amount: 19.99, Currency: "EUR"}, Money{Amount: 5, Currency: "EUR"}, nil},
+		"mixed": []interface{}{Money{Amount: 1, Currency: "EUR"}, Money{Amount: 1, Currency: "USD"}},
+	}
+
+	tests := []struct {
+		expr    string
+		want    interface{}
+		wantErr string
+	}{
+		{expr: `net + fee`, want: Money{Amount: 102.5, Currency: "EUR"}},
+		{expr: `net - 10`, want: Money{Amount: 90, Currency: "EUR"}},
+		{expr: `net * 1.2`, want: Money{Amount: 120, Currency: "EUR"}},
+		{expr: `2 * fee`, want: Money{Amount: 5, Currency: "EUR"}},
+		{expr: `net / 4`, want: Money{Amount: 25, Currency: "EUR"}},
+		{expr: `fee / net`, want: 0.025},
+		{expr: `-fee`, want: Money{Amount: -2.5, Currency: "EUR"}},
+		{expr: `net > fee`, want: true},
+		{expr: `net >= 100`, want: true},
+		{expr: `net == money(100, "eur")`, want: true},
+		{expr: `net != usd`, want: true},
+		{expr: `sum(items)`, want: Money{Amount: 24.99, Currency: "EUR"}},
+		{expr: `currency(net + fee)`, want: "102,50 €"},
+		{expr: `currency(usd, "de-DE")`, want: "10,00 $"},
+		{expr: `currency(yen)`, want: "¥1,500"},
+		{expr: `currency(money(1234.5, "CHF"), "en-US")`, want: "CHF 1,234.50"},
+		{expr: `format("%.2f", fee)`, want: "2.50"},
+		{expr: `format("Total: %s", usd)`, want: "Total: $10.00"},
+		{expr: `"Fee: " + fee`, want: "Fee: 2,50 €"},
+		{expr: `net + usd`, wantErr: "cannot add EUR and USD amounts"},
+		{expr: `net < usd`, wantErr: "cannot compare EUR and USD amounts"},
+		{expr: `net * fee`, wantErr: "cannot multiply two amounts of money"},
+		{expr: `sum(mixed)`, wantErr: "sum() cannot mix currencies EUR and USD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+
+			got, err := node.Evaluate(data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if m, ok := got.(Money); ok {
+				want := tt.want.(Money)
+				if m.Currency != want.Currency || FormatValue(m.Amount) != FormatValue(want.Amount) {
+					t.Errorf("got %#v, want %#v", m, want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyRendersWithSymbol(t *testing.T) {
+	docx := createSimpleDOCX(t, "Total: {{total}}")
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	content := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"total": Money{Amount: -1234.5, Currency: "GBP"},
+	}))
+	if !strings.Contains(content, "Total: -£1,234.50") {
+		t.Errorf("expected the amount with its symbol, got %s", content)
+	}
+}
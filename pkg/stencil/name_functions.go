@@ -0,0 +1,196 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// personGender is the grammatical gender a salutation addresses.
+type personGender int
+
+const (
+	genderUnknown personGender = iota
+	genderMale
+	genderFemale
+)
+
+// person holds the name fields of a person map.
+type person struct {
+	title      string
+	honorific  string
+	firstName  string
+	middleName string
+	lastName   string
+	gender     personGender
+}
+
+// parsePerson reads the fields "title" (such as "Dr."), "honorific" (such
+// as "Mx."), "firstName", "middleName", "lastName" and "gender" of value.
+func parsePerson(name string, value interface{}) (person, error) {
+	switch value.(type) {
+	case map[string]interface{}, map[string]string, TemplateData:
+	default:
+		return person{}, fmt.Errorf("%s: person must be a map, got %T", name, value)
+	}
+
+	field := func(key string) string {
+		return strings.TrimSpace(FormatValue(accessMapField(value, key)))
+	}
+	p := person{
+		title:      field("title"),
+		honorific:  field("honorific"),
+		firstName:  field("firstName"),
+		middleName: field("middleName"),
+		lastName:   field("lastName"),
+	}
+	switch strings.ToLower(strings.TrimSuffix(field("gender"), ".")) {
+	case "m", "male", "man", "mr", "herr":
+		p.gender = genderMale
+	case "f", "w", "female", "woman", "ms", "mrs", "frau":
+		p.gender = genderFemale
+	}
+	return p, nil
+}
+
+// joinNonEmpty joins the non-empty parts with sep.
+func joinNonEmpty(sep string, parts ...string) string {
+	kept := parts[:0:0]
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// fullName formats the name of a person for fullName(person, format).
+func fullName(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	p, err := parsePerson("fullName", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	format := "first-last"
+	if len(args) > 1 && args[1] != nil {
+		format = FormatValue(args[1])
+	}
+	switch format {
+	case "first-last":
+		return joinNonEmpty(" ", p.title, p.firstName, p.middleName, p.lastName), nil
+	case "last-first":
+		return joinNonEmpty(", ", p.lastName, joinNonEmpty(" ", p.firstName, p.middleName)), nil
+	case "initials":
+		initials := ""
+		for _, name := range strings.Fields(p.firstName + " " + p.middleName) {
+			initials += string([]rune(name)[:1]) + ". "
+		}
+		return joinNonEmpty(" ", p.title, strings.TrimSpace(initials), p.lastName), nil
+	default:
+		return nil, fmt.Errorf("fullName: invalid format '%s' (must be 'first-last', 'last-first' or 'initials')", format)
+	}
+}
+
+// salutationRules holds the words of the salutations of a language.
+type salutationRules struct {
+	male, female string // honorifics such as "Mr."
+	openMale     string // opening before a man's name
+	openFemale   string // opening before a woman's name
+	openNamed    string // opening before the name of a person of unknown gender
+	anonymous    string // salutation without a name
+	closing      string // punctuation after the name
+	named        bool   // whether the salutation includes the name
+}
+
+var salutations = map[string]salutationRules{
+	"en": {male: "Mr.", female: "Ms.", openMale: "Dear", openFemale: "Dear", openNamed: "Dear", anonymous: "Dear Sir or Madam,", closing: ",", named: true},
+	"de": {male: "Herr", female: "Frau", openMale: "Sehr geehrter", openFemale: "Sehr geehrte", openNamed: "Guten Tag", anonymous: "Sehr geehrte Damen und Herren,", closing: ",", named: true},
+	"fr": {male: "Monsieur", female: "Madame", anonymous: "Madame, Monsieur,", closing: ","},
+	"es": {male: "Sr.", female: "Sra.", openMale: "Estimado", openFemale: "Estimada", openNamed: "Estimado/a", anonymous: "Estimados señores:", closing: ":", named: true},
+}
+
+// honorificFor returns the honorific of p in the language of locale: the
+// person's own honorific, or the one of their gender.
+func (p person) honorificFor(locale string) string {
+	if p.honorific != "" {
+		return p.honorific
+	}
+	rules, ok := salutations[localeLanguage(locale)]
+	if !ok {
+		rules = salutations["en"]
+	}
+	switch p.gender {
+	case genderMale:
+		return rules.male
+	case genderFemale:
+		return rules.female
+	default:
+		return ""
+	}
+}
+
+// salutation returns the letter salutation for salutation(person, locale),
+// such as "Dear Dr. Smith," or "Sehr geehrte Frau Dr. Müller,".
+func salutation(args ...interface{}) (interface{}, error) {
+	locale := "en"
+	if len(args) > 1 && args[1] != nil {
+		locale = FormatValue(args[1])
+	}
+	lang := localeLanguage(locale)
+	rules, ok := salutations[lang]
+	if !ok {
+		lang, rules = "en", salutations["en"]
+	}
+	if args[0] == nil {
+		return rules.anonymous, nil
+	}
+	p, err := parsePerson("salutation", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if p.lastName == "" {
+		return rules.anonymous, nil
+	}
+
+	honorific := p.honorificFor(lang)
+	if !rules.named {
+		// French letters address the person by honorific only
+		if honorific == "" {
+			return rules.anonymous, nil
+		}
+		return honorific + rules.closing, nil
+	}
+
+	switch {
+	case honorific != "":
+		opening := rules.openNamed
+		switch p.gender {
+		case genderMale:
+			opening = rules.openMale
+		case genderFemale:
+			opening = rules.openFemale
+		}
+		// English drops the honorific before an academic title
+		if lang == "en" && p.title != "" && p.honorific == "" {
+			honorific = ""
+		}
+		return joinNonEmpty(" ", opening, honorific, p.title, p.lastName) + rules.closing, nil
+	case p.title != "":
+		return joinNonEmpty(" ", rules.openNamed, p.title, p.lastName) + rules.closing, nil
+	default:
+		return joinNonEmpty(" ", rules.openNamed, p.firstName, p.lastName) + rules.closing, nil
+	}
+}
+
+// registerNameFunctions registers the fullName() and salutation() functions
+func registerNameFunctions(registry *DefaultFunctionRegistry) {
+	// fullName() function - formats the name of a person
+	fullNameFn := NewSimpleFunction("fullName", 1, 2, fullName)
+	registry.RegisterFunction(fullNameFn)
+
+	// salutation() function - the salutation of a letter to a person
+	salutationFn := NewSimpleFunction("salutation", 1, 2, salutation)
+	registry.RegisterFunction(salutationFn)
+}
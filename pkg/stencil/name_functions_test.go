@@ -0,0 +1,82 @@
+package stencil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFullName(t *testing.T) {
+	person := map[string]interface{}{"title": "Dr.", "firstName": "Anna", "middleName": "Maria", "lastName": "Müller"}
+
+	tests := []struct {
+		format interface{}
+		want   string
+	}{
+		{format: nil, want: "Dr. Anna Maria Müller"},
+		{format: "last-first", want: "Müller, Anna Maria"},
+		{format: "initials", want: "Dr. A. M. Müller"},
+	}
+	for _, tt := range tests {
+		got, err := fullName(person, tt.format)
+		if err != nil || got != tt.want {
+			t.Errorf("fullName(%v) = %v, %v, want %q", tt.format, got, err, tt.want)
+		}
+	}
+
+	if got, _ := fullName(map[string]interface{}{"lastName": "Smith"}, "last-first"); got != "Smith" {
+		t.Errorf("fullName() without first name = %q, want Smith", got)
+	}
+	if _, err := fullName(person, "first"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestSalutation(t *testing.T) {
+	drMueller := map[string]interface{}{"title": "Dr.", "firstName": "Anna", "lastName": "Müller", "gender": "female"}
+	smith := map[string]interface{}{"firstName": "John", "lastName": "Smith", "gender": "m"}
+	lee := map[string]interface{}{"firstName": "Sam", "lastName": "Lee"}
+
+	tests := []struct {
+		person interface{}
+		locale interface{}
+		want   string
+	}{
+		{person: drMueller, locale: "de-DE", want: "Sehr geehrte Frau Dr. Müller,"},
+		{person: smith, locale: "de", want: "Sehr geehrter Herr Smith,"},
+		{person: lee, locale: "de", want: "Guten Tag Sam Lee,"},
+		{person: drMueller, locale: "en-GB", want: "Dear Dr. Müller,"},
+		{person: smith, locale: nil, want: "Dear Mr. Smith,"},
+		{person: lee, locale: "en", want: "Dear Sam Lee,"},
+		{person: map[string]interface{}{"lastName": "Lee", "honorific": "Mx."}, locale: "en", want: "Dear Mx. Lee,"},
+		{person: drMueller, locale: "fr", want: "Madame,"},
+		{person: lee, locale: "fr", want: "Madame, Monsieur,"},
+		{person: smith, locale: "es", want: "Estimado Sr. Smith:"},
+		{person: nil, locale: "de", want: "Sehr geehrte Damen und Herren,"},
+		{person: map[string]interface{}{"firstName": "Sam"}, locale: "en", want: "Dear Sir or Madam,"},
+		{person: smith, locale: "it", want: "Dear Mr. Smith,"},
+	}
+	for _, tt := range tests {
+		got, err := salutation(tt.person, tt.locale)
+		if err != nil || got != tt.want {
+			t.Errorf("salutation(%v, %v) = %v, %v, want %q", tt.person, tt.locale, got, err, tt.want)
+		}
+	}
+}
+
+func TestNameFunctionsInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`{{salutation(recipient, "de")}}`,
+		`{{fullName(recipient, "last-first")}}`,
+	})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{
+		"recipient": map[string]interface{}{"firstName": "Max", "lastName": "Müller", "gender": "male"},
+	})
+	if got := extractTextFromDOCX(t, output); got != "Sehr geehrter Herr Müller,Müller, Max" {
+		t.Errorf("unexpected text %q", got)
+	}
+}
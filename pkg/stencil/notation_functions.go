@@ -0,0 +1,133 @@
+package stencil
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// siPrefixes are the SI prefixes of the powers of 1000 from 10^-24 to
+// 10^24, indexed by exponent/3 + 8.
+var siPrefixes = []string{"y", "z", "a", "f", "p", "n", "µ", "m", "", "k", "M", "G", "T", "P", "E", "Z", "Y"}
+
+// superscriptDigits writes exponents such as 10⁻³.
+var superscriptDigits = strings.NewReplacer(
+	"0", "⁰", "1", "¹", "2", "²", "3", "³", "4", "⁴",
+	"5", "⁵", "6", "⁶", "7", "⁷", "8", "⁸", "9", "⁹", "-", "⁻",
+)
+
+// significantDigits rounds value to sigFigs significant digits and returns
+// its sign, its digits without a decimal point, and the exponent of its
+// first digit, so 0.0047 gives "", "47" and -3 for two digits.
+func significantDigits(value float64, sigFigs int) (sign, digits string, exponent int) {
+	formatted := strconv.FormatFloat(value, 'e', sigFigs-1, 64)
+	if strings.HasPrefix(formatted, "-") {
+		sign, formatted = "-", formatted[1:]
+	}
+	mantissa, exp, _ := strings.Cut(formatted, "e")
+	exponent, _ = strconv.Atoi(exp)
+	return sign, strings.Replace(mantissa, ".", "", 1), exponent
+}
+
+// placeDecimalPoint writes digits with intDigits digits before the decimal
+// point, padding with zeros.
+func placeDecimalPoint(digits string, intDigits int) string {
+	if len(digits) <= intDigits {
+		return digits + strings.Repeat("0", intDigits-len(digits))
+	}
+	return digits[:intDigits] + "." + digits[intDigits:]
+}
+
+// notationArgs reads the value and the number of significant digits of a
+// notation function.
+func notationArgs(function string, args []interface{}, sigFigsIndex int) (float64, int, error) {
+	value, err := toNumber(args[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", function, err)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, 0, fmt.Errorf("%s: %v is not a finite number", function, value)
+	}
+	sigFigs := 3
+	if len(args) > sigFigsIndex && args[sigFigsIndex] != nil {
+		n, err := toNumber(args[sigFigsIndex])
+		if err != nil || n < 1 || n > 17 || n != math.Trunc(n) {
+			return 0, 0, fmt.Errorf("%s: significant digits must be an integer from 1 to 17, got %v", function, args[sigFigsIndex])
+		}
+		sigFigs = int(n)
+	}
+	return value, sigFigs, nil
+}
+
+// sci writes a number in scientific notation for sci(value, sigFigs), such
+// as 1.23 × 10³, keeping trailing zeros as significant digits.
+func sci(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	value, sigFigs, err := notationArgs("sci", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	sign, digits, exponent := significantDigits(value, sigFigs)
+	if value == 0 {
+		exponent = 0
+	}
+	return sign + placeDecimalPoint(digits, 1) + " × 10" + superscriptDigits.Replace(strconv.Itoa(exponent)), nil
+}
+
+// eng writes a number in engineering notation with an SI prefix for
+// eng(value, unit, sigFigs), such as 4.7 k, dropping trailing zeros.
+func eng(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	value, sigFigs, err := notationArgs("eng", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	unit := ""
+	if len(args) > 1 && args[1] != nil {
+		unit = FormatValue(args[1])
+	}
+
+	sign, digits, exponent := significantDigits(value, sigFigs)
+	group := 0
+	if value != 0 {
+		group = int(math.Floor(float64(exponent) / 3))
+	}
+	if group < -8 {
+		group = -8
+	} else if group > 8 {
+		group = 8
+	}
+
+	intDigits := exponent - group*3 + 1
+	if intDigits < 1 {
+		// Below the smallest prefix, such as 10^-26
+		digits = strings.Repeat("0", 1-intDigits) + digits
+		intDigits = 1
+	}
+	number := placeDecimalPoint(digits, intDigits)
+	if strings.Contains(number, ".") {
+		number = strings.TrimRight(strings.TrimRight(number, "0"), ".")
+	}
+
+	suffix := siPrefixes[group+8] + unit
+	if suffix == "" {
+		return sign + number, nil
+	}
+	return sign + number + " " + suffix, nil
+}
+
+// registerNotationFunctions registers the sci() and eng() functions
+func registerNotationFunctions(registry *DefaultFunctionRegistry) {
+	// sci() function - scientific notation
+	sciFn := NewSimpleFunction("sci", 1, 2, sci)
+	registry.RegisterFunction(sciFn)
+
+	// eng() function - engineering notation with SI prefixes
+	engFn := NewSimpleFunction("eng", 1, 3, eng)
+	registry.RegisterFunction(engFn)
+}
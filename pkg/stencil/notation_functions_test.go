@@ -0,0 +1,79 @@
+package stencil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSci(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{args: []interface{}{1234.5, 3}, want: "1.23 × 10³"},
+		{args: []interface{}{0.00047, 2}, want: "4.7 × 10⁻⁴"},
+		{args: []interface{}{-1200, 3}, want: "-1.20 × 10³"},
+		{args: []interface{}{9.999, 2}, want: "1.0 × 10¹"},
+		{args: []interface{}{6.02214076e23}, want: "6.02 × 10²³"},
+		{args: []interface{}{0, 2}, want: "0.0 × 10⁰"},
+		{args: []interface{}{"42", 1}, want: "4 × 10¹"},
+	}
+	for _, tt := range tests {
+		got, err := sci(tt.args...)
+		if err != nil || got != tt.want {
+			t.Errorf("sci(%v) = %v, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+
+	if _, err := sci(1, 0); err == nil {
+		t.Error("expected an error for zero significant digits")
+	}
+	if got, err := sci(nil, 3); got != nil || err != nil {
+		t.Errorf("sci(nil) = %v, %v, want nil", got, err)
+	}
+}
+
+func TestEng(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{args: []interface{}{12345}, want: "12.3 k"},
+		{args: []interface{}{0.0000047}, want: "4.7 µ"},
+		{args: []interface{}{4700, "Ω"}, want: "4.7 kΩ"},
+		{args: []interface{}{470, "Ω"}, want: "470 Ω"},
+		{args: []interface{}{470}, want: "470"},
+		{args: []interface{}{999.9}, want: "1 k"},
+		{args: []interface{}{-0.0123, "A"}, want: "-12.3 mA"},
+		{args: []interface{}{123456789, "Hz", 4}, want: "123.5 MHz"},
+		{args: []interface{}{100000, nil, 1}, want: "100 k"},
+		{args: []interface{}{0, "V"}, want: "0 V"},
+		{args: []interface{}{1.5e-26, "g"}, want: "0.015 yg"},
+	}
+	for _, tt := range tests {
+		got, err := eng(tt.args...)
+		if err != nil || got != tt.want {
+			t.Errorf("eng(%v) = %v, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+
+	if _, err := eng("ten"); err == nil {
+		t.Error("expected an error for a value that is not a number")
+	}
+}
+
+func TestNotationFunctionsInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`c = {{sci(concentration, 2)}} mol/L`,
+		`R = {{eng(resistance, "Ω")}}`,
+	})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{"concentration": 0.000153, "resistance": 4700})
+	if got := extractTextFromDOCX(t, output); got != "c = 1.5 × 10⁻⁴ mol/LR = 4.7 kΩ" {
+		t.Errorf("unexpected text %q", got)
+	}
+}
@@ -2,9 +2,11 @@ package stencil
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // formatPattern represents a parsed format pattern
@@ -25,9 +27,13 @@ type formatSpec struct {
 	width     string // field width
 	precision string // precision
 	verb      rune   // format verb
+	date      rune   // date conversion character of a %t or %T verb
 }
 
-var formatSpecRegex = regexp.MustCompile(`%(?:(\d+)\$)?([-#+ 0,(\<]*)?(\d+)?(\.\d+)?([a-zA-Z%])`)
+var formatSpecRegex = regexp.MustCompile(`%(?:(\d+)\$)?([-#+ 0,(\<]*)?(\d+)?(\.\d+)?([tT][a-zA-Z]|[a-zA-Z%])`)
+
+// localeTagRegex matches locale tags such as "de", "de-DE" or "en_US".
+var localeTagRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(?:[-_][a-zA-Z0-9]{2,8})*$`)
 
 // parseFormatPattern parses a format pattern into segments
 func parseFormatPattern(pattern string) (*formatPattern, error) {
@@ -74,6 +80,9 @@ func parseFormatPattern(pattern string) (*formatPattern, error) {
 		// Verb (match[10] and match[11])
 		if match[10] >= 0 {
 			spec.verb = rune(pattern[match[10]])
+			if match[11]-match[10] == 2 {
+				spec.date = rune(pattern[match[10]+1])
+			}
 		}
 		
 		result.segments = append(result.segments, formatSegment{
@@ -97,6 +106,11 @@ func parseFormatPattern(pattern string) (*formatPattern, error) {
 
 // convertFormatValue converts a value to the appropriate type for the format verb
 func convertFormatValue(value interface{}, verb rune) (interface{}, error) {
+	// Numeric verbs format the amount of Money, the others add its symbol
+	if m, ok := toMoney(value); ok && verb != 's' && verb != 'v' && verb != 'q' {
+		value = m.Amount
+	}
+
 	if value == nil {
 		switch verb {
 		case 'd', 'b', 'o', 'x', 'X':
@@ -248,18 +262,114 @@ func convertFormatValue(value interface{}, verb rune) (interface{}, error) {
 	}
 }
 
+// formatArgs formats values with a printf-style pattern. Explicit (%2$s) and
+// relative (%<s) argument indexes, the ',' and '(' flags and the %t date
+// conversions follow Java's Formatter, so patterns from the original stencil
+// can be used unchanged. function names the template function in errors.
+func formatArgs(function, locale, pattern string, values []interface{}) (interface{}, error) {
+	parsed, err := parseFormatPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count the values the pattern needs
+	required, sequential := 0, 0
+	for _, seg := range parsed.segments {
+		switch {
+		case seg.literal || seg.spec.verb == '%' || seg.spec.verb == 'n' || strings.Contains(seg.spec.flags, "<"):
+		case seg.spec.index > 0:
+			required = max(required, seg.spec.index)
+		default:
+			sequential++
+			required = max(required, sequential)
+		}
+	}
+	if len(values) < required {
+		return nil, fmt.Errorf("%s() expects %d values for pattern but got %d", function, required, len(values))
+	}
+
+	var result strings.Builder
+	next := 0
+	var previous interface{}
+	hasPrevious := false
+
+	for _, seg := range parsed.segments {
+		switch {
+		case seg.literal:
+			result.WriteString(seg.text)
+		case seg.spec.verb == '%':
+			result.WriteRune('%')
+		case seg.spec.verb == 'n':
+			result.WriteRune('\n')
+		default:
+			// Get the value for this specifier
+			var value interface{}
+			switch {
+			case strings.Contains(seg.spec.flags, "<"):
+				if !hasPrevious {
+					return nil, fmt.Errorf("%s() relative index %%< has no previous value", function)
+				}
+				value = previous
+			case seg.spec.index > 0:
+				if seg.spec.index > len(values) {
+					return nil, fmt.Errorf("%s() index %d out of range", function, seg.spec.index)
+				}
+				value = values[seg.spec.index-1]
+			default:
+				if next >= len(values) {
+					return nil, fmt.Errorf("%s() not enough arguments", function)
+				}
+				value = values[next]
+				next++
+			}
+			previous, hasPrevious = value, true
+
+			formatted, err := formatValue(seg.spec, value, locale)
+			if err != nil {
+				return nil, err
+			}
+			result.WriteString(formatted)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// isFormatLocaleArgument reports whether format() was called as
+// format(locale, pattern, values...): first is a locale tag and second a
+// pattern with a format specifier.
+func isFormatLocaleArgument(first, second interface{}) bool {
+	locale, ok := first.(string)
+	if !ok || !localeTagRegex.MatchString(locale) {
+		return false
+	}
+	pattern, ok := second.(string)
+	return ok && strings.Contains(pattern, "%")
+}
+
 // formatValue formats a single value according to a format spec
 func formatValue(spec formatSpec, value interface{}, locale string) (string, error) {
+	if spec.verb == 't' || spec.verb == 'T' {
+		return formatDateValue(spec, value, locale)
+	}
+
 	// Convert value to appropriate type
 	converted, err := convertFormatValue(value, spec.verb)
 	if err != nil {
 		return "", err
 	}
-	
-	// Build the format string
+
+	switch spec.verb {
+	case 'd', 'e', 'E', 'f', 'F', 'g', 'G':
+		if locale != "" || strings.ContainsAny(spec.flags, ",(") {
+			return formatNumber(spec, converted, locale), nil
+		}
+	}
+
+	// Build the format string; Go's fmt has no ',', '(' or '<' flags
 	var formatStr strings.Builder
 	formatStr.WriteRune('%')
-	formatStr.WriteString(spec.flags)
+	formatStr.WriteString(strings.NewReplacer(",", "", "(", "", "<", "").Replace(spec.flags))
 	formatStr.WriteString(spec.width)
 	formatStr.WriteString(spec.precision)
 	formatStr.WriteRune(spec.verb)
@@ -268,14 +378,111 @@ func formatValue(spec formatSpec, value interface{}, locale string) (string, err
 	result := fmt.Sprintf(formatStr.String(), converted)
 	
 	// Apply locale-specific formatting for numeric values when locale is specified
-	if locale != "" && (spec.verb == 'd' || spec.verb == 'b' || spec.verb == 'o' || spec.verb == 'x' || spec.verb == 'X' || 
-		spec.verb == 'e' || spec.verb == 'E' || spec.verb == 'f' || spec.verb == 'F' || spec.verb == 'g' || spec.verb == 'G') {
+	if locale != "" && (spec.verb == 'b' || spec.verb == 'o' || spec.verb == 'x' || spec.verb == 'X') {
 		result = applyLocaleNumberFormatting(result, locale)
 	}
 	
 	return result, nil
 }
 
+// formatNumber formats a decimal number like Java's Formatter: the ',' flag
+// groups digits, the '(' flag encloses negative numbers in parentheses and the
+// locale sets the separators. As formatWithLocale always did, a locale groups
+// digits even without the ',' flag.
+func formatNumber(spec formatSpec, value interface{}, locale string) string {
+	negative := false
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			negative, value = true, -v
+		}
+	case int64:
+		if v < 0 {
+			negative, value = true, -v
+		}
+	case float64:
+		if v < 0 && !math.IsInf(v, 0) {
+			negative, value = true, -v
+		}
+	}
+
+	number := fmt.Sprintf("%"+spec.precision+string(spec.verb), value)
+	if locale != "" || strings.Contains(spec.flags, ",") {
+		group, decimal := localeNumberSeparators(locale)
+		number = groupDigits(number, group, decimal)
+	}
+
+	switch {
+	case negative && strings.Contains(spec.flags, "("):
+		number = "(" + number + ")"
+	case negative:
+		number = "-" + number
+	case strings.Contains(spec.flags, "+"):
+		number = "+" + number
+	case strings.Contains(spec.flags, " "):
+		number = " " + number
+	}
+
+	return padFormatted(number, spec)
+}
+
+// groupDigits inserts group between the thousands of the integer part of
+// number and replaces its decimal point with decimal.
+func groupDigits(number, group, decimal string) string {
+	end := strings.IndexFunc(number, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(number)
+	}
+	intPart, rest := number[:end], number[end:]
+
+	var grouped strings.Builder
+	for i, digit := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			grouped.WriteString(group)
+		}
+		grouped.WriteRune(digit)
+	}
+	if strings.HasPrefix(rest, ".") {
+		rest = decimal + rest[1:]
+	}
+	return grouped.String() + rest
+}
+
+// localeNumberSeparators returns the digit group and decimal separators of
+// locale.
+func localeNumberSeparators(locale string) (group, decimal string) {
+	switch localeLanguage(locale) {
+	case "de":
+		return ".", ","
+	case "fr", "hu":
+		return " ", ","
+	default:
+		return ",", "."
+	}
+}
+
+// padFormatted pads s to the width of spec. The '-' flag pads on the right
+// and the '0' flag with zeros after the sign.
+func padFormatted(s string, spec formatSpec) string {
+	width, _ := strconv.Atoi(spec.width)
+	padding := width - utf8.RuneCountInString(s)
+	if padding <= 0 {
+		return s
+	}
+	switch {
+	case strings.Contains(spec.flags, "-"):
+		return s + strings.Repeat(" ", padding)
+	case strings.Contains(spec.flags, "0"):
+		sign := 0
+		if strings.IndexAny(s, "+-( ") == 0 {
+			sign = 1
+		}
+		return s[:sign] + strings.Repeat("0", padding) + s[sign:]
+	default:
+		return strings.Repeat(" ", padding) + s
+	}
+}
+
 // applyLocaleNumberFormatting applies locale-specific number formatting
 func applyLocaleNumberFormatting(formatted string, locale string) string {
 	// Extract language from locale
@@ -395,6 +602,20 @@ func getNumberFormatter(locale string) func(float64, int) string {
 
 // getCurrencyFormatter returns a function to format currency for a specific locale
 func getCurrencyFormatter(locale string) func(float64) string {
+	symbol, before, space := currencyPlacement(locale)
+
+	// Japanese Yen has no decimals
+	decimals := 2
+	if strings.ToLower(strings.Split(locale, "-")[0]) == "ja" {
+		decimals = 0
+	}
+
+	return newCurrencyFormatter(locale, symbol, before, space, decimals)
+}
+
+// currencyPlacement returns the currency symbol of a locale and whether the
+// locale writes it before the number and separated by a space.
+func currencyPlacement(locale string) (symbol string, before bool, space bool) {
 	// Parse locale
 	parts := strings.Split(strings.ToUpper(locale), "-")
 	lang := strings.ToLower(parts[0])
@@ -403,49 +624,30 @@ func getCurrencyFormatter(locale string) func(float64) string {
 		country = parts[1]
 	}
 	
-	// Determine currency symbol and placement
-	var symbol string
-	var before bool
-	var space bool
-	
 	switch {
 	case lang == "en" && country == "US":
-		symbol = "$"
-		before = true
-		space = false
+		return "$", true, false
 	case lang == "en" && country == "GB":
-		symbol = "£"
-		before = true
-		space = false
+		return "£", true, false
 	case lang == "de" || (lang == "fr" && country == "FR"):
-		symbol = "€"
-		before = false
-		space = true
+		return "€", false, true
 	case lang == "ja":
-		symbol = "¥"
-		before = true
-		space = false
+		return "¥", true, false
 	case lang == "hu":
-		symbol = "Ft"
-		before = false
-		space = true
+		return "Ft", false, true
 	default:
 		// Default to dollar
-		symbol = "$"
-		before = true
-		space = false
+		return "$", true, false
 	}
-	
+}
+
+// newCurrencyFormatter returns a function that formats amounts with the
+// number format of locale and the given symbol placement.
+func newCurrencyFormatter(locale, symbol string, before, space bool, decimals int) func(float64) string {
 	// Get number formatter for locale
 	formatter := getNumberFormatter(locale)
 	
 	return func(value float64) string {
-		// Determine decimals (Japanese Yen has no decimals)
-		decimals := 2
-		if lang == "ja" {
-			decimals = 0
-		}
-		
 		// Format the number
 		negative := value < 0
 		if negative {
@@ -472,16 +674,7 @@ func getCurrencyFormatter(locale string) func(float64) string {
 		
 		// Handle negative values
 		if negative {
-			if before {
-				result = "-" + result
-			} else {
-				result = "-" + formatted
-				if space {
-					result += " " + symbol
-				} else {
-					result += symbol
-				}
-			}
+			result = "-" + result
 		}
 		
 		return result
@@ -524,147 +717,28 @@ func getPercentFormatter(locale string) func(float64) string {
 func registerNumberFormatFunctions(registry *DefaultFunctionRegistry) {
 	// format() function - formats values using printf-style patterns
 	formatFn := NewSimpleFunction("format", 1, -1, func(args ...interface{}) (interface{}, error) {
-		if len(args) < 1 {
-			return nil, fmt.Errorf("format() expects at least 1 argument")
-		}
-		
 		// Handle nil pattern
 		if args[0] == nil {
 			return nil, nil
 		}
-		
-		pattern := FormatValue(args[0])
-		
-		// Parse the pattern
-		parsed, err := parseFormatPattern(pattern)
-		if err != nil {
-			return nil, err
-		}
-		
-		// Count format specifiers
-		specCount := 0
-		for _, seg := range parsed.segments {
-			if !seg.literal && seg.spec.verb != '%' {
-				specCount++
-			}
-		}
-		
-		// Check argument count
-		if len(args)-1 < specCount {
-			return nil, fmt.Errorf("format() expects %d values for pattern but got %d", specCount, len(args)-1)
-		}
-		
-		// Build result
-		var result strings.Builder
-		argIndex := 1
-		
-		for _, seg := range parsed.segments {
-			if seg.literal {
-				result.WriteString(seg.text)
-			} else if seg.spec.verb == '%' {
-				result.WriteRune('%')
-			} else {
-				// Get the value for this specifier
-				var value interface{}
-				if seg.spec.index > 0 {
-					// Explicit index
-					if seg.spec.index > len(args)-1 {
-						return nil, fmt.Errorf("format() index %d out of range", seg.spec.index)
-					}
-					value = args[seg.spec.index]
-				} else {
-					// Use next argument
-					if argIndex >= len(args) {
-						return nil, fmt.Errorf("format() not enough arguments")
-					}
-					value = args[argIndex]
-					argIndex++
-				}
-				
-				// Format the value
-				formatted, err := formatValue(seg.spec, value, "")
-				if err != nil {
-					return nil, err
-				}
-				result.WriteString(formatted)
-			}
+
+		// format(locale, pattern, values...) as in Java's String.format
+		if len(args) > 1 && isFormatLocaleArgument(args[0], args[1]) {
+			return formatArgs("format", FormatValue(args[0]), FormatValue(args[1]), args[2:])
 		}
-		
-		return result.String(), nil
+
+		return formatArgs("format", "", FormatValue(args[0]), args[1:])
 	})
 	registry.RegisterFunction(formatFn)
 	
 	// formatWithLocale() function - formats with locale support
 	formatWithLocaleFn := NewSimpleFunction("formatWithLocale", 2, -1, func(args ...interface{}) (interface{}, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("formatWithLocale() expects at least 2 arguments")
-		}
-		
 		// Handle nil arguments
 		if args[0] == nil || args[1] == nil {
 			return nil, nil
 		}
-		
-		locale := FormatValue(args[0])
-		pattern := FormatValue(args[1])
-		
-		// Parse the pattern
-		parsed, err := parseFormatPattern(pattern)
-		if err != nil {
-			return nil, err
-		}
-		
-		// Count format specifiers
-		specCount := 0
-		for _, seg := range parsed.segments {
-			if !seg.literal && seg.spec.verb != '%' {
-				specCount++
-			}
-		}
-		
-		// Check argument count
-		if len(args)-2 < specCount {
-			return nil, fmt.Errorf("formatWithLocale() expects %d values for pattern but got %d", specCount, len(args)-2)
-		}
-		
-		// Build result
-		var result strings.Builder
-		argIndex := 2
-		
-		for _, seg := range parsed.segments {
-			if seg.literal {
-				result.WriteString(seg.text)
-			} else if seg.spec.verb == '%' {
-				result.WriteRune('%')
-			} else {
-				// Get the value for this specifier
-				var value interface{}
-				if seg.spec.index > 0 {
-					// Explicit index (adjust for locale parameter)
-					realIndex := seg.spec.index + 1
-					if realIndex >= len(args) {
-						return nil, fmt.Errorf("formatWithLocale() index %d out of range", seg.spec.index)
-					}
-					value = args[realIndex]
-				} else {
-					// Use next argument
-					if argIndex >= len(args) {
-						return nil, fmt.Errorf("formatWithLocale() not enough arguments")
-					}
-					value = args[argIndex]
-					argIndex++
-				}
-				
-				// Format the value with locale
-				formatted, err := formatValue(seg.spec, value, locale)
-				if err != nil {
-					return nil, err
-				}
-				result.WriteString(formatted)
-			}
-		}
-		
-		return result.String(), nil
+
+		return formatArgs("formatWithLocale", FormatValue(args[0]), FormatValue(args[1]), args[2:])
 	})
 	registry.RegisterFunction(formatWithLocaleFn)
 	
@@ -673,6 +747,16 @@ func registerNumberFormatFunctions(registry *DefaultFunctionRegistry) {
 		if args[0] == nil {
 			return nil, nil
 		}
+
+		// Money is written with its own symbol, in the format of its
+		// currency unless a locale is given
+		if m, ok := toMoney(args[0]); ok {
+			locale := ""
+			if len(args) > 1 && args[1] != nil {
+				locale = FormatValue(args[1])
+			}
+			return formatMoney(m, locale), nil
+		}
 		
 		// Convert to float
 		var value float64
@@ -712,6 +796,24 @@ func registerNumberFormatFunctions(registry *DefaultFunctionRegistry) {
 		return formatter(value), nil
 	})
 	registry.RegisterFunction(currencyFn)
+
+	// money() function - makes an amount in a currency
+	moneyFn := NewSimpleFunction("money", 2, 2, func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+
+		amount, err := toNumber(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("money() amount: %w", err)
+		}
+		currency := normalizeCurrency(FormatValue(args[1]))
+		if currency == "" {
+			return nil, fmt.Errorf("money() requires a currency")
+		}
+		return Money{Amount: amount, Currency: currency}, nil
+	})
+	registry.RegisterFunction(moneyFn)
 	
 	// percent() function - formats number as percentage
 	percentFn := NewSimpleFunction("percent", 1, 2, func(args ...interface{}) (interface{}, error) {
@@ -2,6 +2,7 @@ package stencil
 
 import (
 	"testing"
+	"time"
 )
 
 func TestFormatFunction(t *testing.T) {
@@ -594,4 +595,67 @@ func TestNumberFormattingInExpressions(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestFormatJavaCompatibility(t *testing.T) {
+	date := time.Date(2024, time.March, 5, 9, 7, 3, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		args    []interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "grouping", args: []interface{}{"%,.2f", 1234567.891}, want: "1,234,567.89"},
+		{name: "grouping integer", args: []interface{}{"%,d", 1234567}, want: "1,234,567"},
+		{name: "grouping small number", args: []interface{}{"%,d", 999}, want: "999"},
+		{name: "grouping negative", args: []interface{}{"%,.1f", -1234.5}, want: "-1,234.5"},
+		{name: "parentheses", args: []interface{}{"%(,.2f", -1234.5}, want: "(1,234.50)"},
+		{name: "parentheses positive", args: []interface{}{"%(.2f", 12.5}, want: "12.50"},
+		{name: "grouping with width", args: []interface{}{"[%,10d]", 12345}, want: "[    12,345]"},
+		{name: "grouping left aligned", args: []interface{}{"[%-,8d]", 1234}, want: "[1,234   ]"},
+		{name: "grouping with plus", args: []interface{}{"%+,d", 1234}, want: "+1,234"},
+		{name: "locale argument", args: []interface{}{"de-DE", "%,.2f", 1234567.891}, want: "1.234.567,89"},
+		{name: "locale argument with underscore", args: []interface{}{"fr_FR", "%,d €", 1500}, want: "1 500 €"},
+		{name: "locale-looking pattern without values", args: []interface{}{"de"}, want: "de"},
+		{name: "explicit index reused", args: []interface{}{"%1$s and %1$s", "x"}, want: "x and x"},
+		{name: "relative index", args: []interface{}{"%s/%<s", "a"}, want: "a/a"},
+		{name: "newline", args: []interface{}{"a%nb"}, want: "a\nb"},
+		{name: "date conversions", args: []interface{}{"%1$td.%1$tm.%1$tY %1$tH:%1$tM", date}, want: "05.03.2024 09:07"},
+		{name: "iso date", args: []interface{}{"%tF", date}, want: "2024-03-05"},
+		{name: "month name", args: []interface{}{"%tB %<te, %<tY", date}, want: "March 5, 2024"},
+		{name: "upper-case conversion", args: []interface{}{"%Tb", date}, want: "MAR"},
+		{name: "localized month", args: []interface{}{"de", "%te. %<tB %<tY", date}, want: "5. März 2024"},
+		{name: "date from string", args: []interface{}{"%tD", "2024-03-05"}, want: "03/05/24"},
+		{name: "unknown date conversion", args: []interface{}{"%tq", date}, wantErr: true},
+		{name: "date conversion of non-date", args: []interface{}{"%tY", "soon"}, wantErr: true},
+		{name: "relative index without previous", args: []interface{}{"%<s", "a"}, wantErr: true},
+	}
+
+	fn, exists := GetDefaultFunctionRegistry().GetFunction("format")
+	if !exists {
+		t.Fatal("format() is not registered")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fn.Call(tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("format() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatWithLocaleGroupingFlag(t *testing.T) {
+	fn, _ := GetDefaultFunctionRegistry().GetFunction("formatWithLocale")
+	got, err := fn.Call("de", "%(,.2f", -1234.5)
+	if err != nil {
+		t.Fatalf("formatWithLocale() error = %v", err)
+	}
+	if got != "(1.234,50)" {
+		t.Errorf("formatWithLocale() = %q, want %q", got, "(1.234,50)")
+	}
+}
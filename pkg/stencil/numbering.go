@@ -29,6 +29,8 @@ var (
 	numberingNSIDRegex          = regexp.MustCompile(`(?s)<w:nsid\b[^>]*/>`)
 	numberingTemplateRegex      = regexp.MustCompile(`(?s)<w:tmpl\b[^>]*/>`)
 	numberingDurableIDRegex     = regexp.MustCompile(`\s+w16cid:durableId="[^"]*"`)
+	numberingLevelRegex         = regexp.MustCompile(`(?s)<w:lvl\b[^>]*\bw:ilvl="(\d+)".*?</w:lvl>`)
+	numberingLevelStartRegex    = regexp.MustCompile(`<w:start\b[^>]*\bw:val="(\d+)"`)
 	numberingCloseTag           = "</w:numbering>"
 )
 
@@ -235,6 +237,118 @@ func (ctx *numberingContext) ensureCompiledFragmentDefinitions(fragmentName stri
 	return numMap, nil
 }
 
+// restartFragmentNumbering creates new numbering instances for an already
+// merged fragment so that a repeated include starts its lists over. Each new
+// w:num references the same abstract definition with start overrides for
+// every level. The returned map replaces numMap for this include.
+func (ctx *numberingContext) restartFragmentNumbering(numMap map[string]string) map[string]string {
+	if len(numMap) == 0 {
+		return numMap
+	}
+
+	oldIDs := make([]string, 0, len(numMap))
+	for oldID := range numMap {
+		oldIDs = append(oldIDs, oldID)
+	}
+	sort.Strings(oldIDs)
+
+	restarted := make(map[string]string, len(numMap))
+	numBlocks := make([]string, 0, len(numMap))
+	for _, oldID := range oldIDs {
+		abstractID, ok := ctx.abstractIDForNum(numMap[oldID])
+		if !ok {
+			restarted[oldID] = numMap[oldID]
+			continue
+		}
+
+		newNumID := strconv.Itoa(ctx.nextNumID)
+		ctx.nextNumID++
+
+		var block strings.Builder
+		block.WriteString(`<w:num w:numId="` + newNumID + `"><w:abstractNumId w:val="` + abstractID + `"/>`)
+		for _, level := range ctx.abstractLevelStarts(abstractID) {
+			block.WriteString(`<w:lvlOverride w:ilvl="` + level[0] + `"><w:startOverride w:val="` + level[1] + `"/></w:lvlOverride>`)
+		}
+		block.WriteString(`</w:num>`)
+
+		numBlocks = append(numBlocks, block.String())
+		restarted[oldID] = newNumID
+	}
+
+	ctx.xml = insertNumberingBlocks(ctx.xml, nil, numBlocks)
+	ctx.modified = true
+	return restarted
+}
+
+// abstractIDForNum returns the abstract numbering ID referenced by numID.
+func (ctx *numberingContext) abstractIDForNum(numID string) (string, bool) {
+	for _, block := range numberingNumBlockRegex.FindAllString(ctx.xml, -1) {
+		if id, ok := extractNumberingMatch(block, numberingNumIDRegex); ok && id == numID {
+			return extractNumberingMatch(block, numberingNumRefRegex)
+		}
+	}
+	return "", false
+}
+
+// abstractLevelStarts returns [ilvl, start] pairs for the levels of an
+// abstract numbering definition. Levels without w:start use the schema
+// default of 0.
+func (ctx *numberingContext) abstractLevelStarts(abstractID string) [][2]string {
+	for _, block := range numberingAbstractBlockRegex.FindAllString(ctx.xml, -1) {
+		id, ok := extractNumberingMatch(block, numberingAbstractIDRegex)
+		if !ok || id != abstractID {
+			continue
+		}
+
+		var levels [][2]string
+		for _, match := range numberingLevelRegex.FindAllStringSubmatch(block, -1) {
+			start := "0"
+			if value, ok := extractNumberingMatch(match[0], numberingLevelStartRegex); ok {
+				start = value
+			}
+			levels = append(levels, [2]string{match[1], start})
+		}
+		return levels
+	}
+	return nil
+}
+
+// listBulletSymbols and listNumberFormats are the bullets and number
+// formats of list content, repeating every three levels.
+var (
+	listBulletSymbols = []string{"\u2022", "\u25E6", "\u25AA"}
+	listNumberFormats = []string{"decimal", "lowerLetter", "lowerRoman"}
+)
+
+// addList adds the numbering definition of a list built by a function and
+// returns the ID of its numbering instance.
+func (ctx *numberingContext) addList(ordered bool) string {
+	abstractID := strconv.Itoa(ctx.nextAbstractNumID)
+	ctx.nextAbstractNumID++
+	numID := strconv.Itoa(ctx.nextNumID)
+	ctx.nextNumID++
+
+	var block strings.Builder
+	block.WriteString(`<w:abstractNum w:abstractNumId="` + abstractID + `"><w:multiLevelType w:val="hybridMultilevel"/>`)
+	for level := 0; level <= maxNumberingLevel; level++ {
+		block.WriteString(`<w:lvl w:ilvl="` + strconv.Itoa(level) + `"><w:start w:val="1"/>`)
+		if ordered {
+			block.WriteString(`<w:numFmt w:val="` + listNumberFormats[level%len(listNumberFormats)] + `"/>`)
+			block.WriteString(`<w:lvlText w:val="%` + strconv.Itoa(level+1) + `."/>`)
+		} else {
+			block.WriteString(`<w:numFmt w:val="bullet"/>`)
+			block.WriteString(`<w:lvlText w:val="` + listBulletSymbols[level%len(listBulletSymbols)] + `"/>`)
+		}
+		block.WriteString(`<w:lvlJc w:val="left"/><w:pPr><w:ind w:left="` + strconv.Itoa(includeIndentTwips*(level+1)) + `" w:hanging="360"/></w:pPr></w:lvl>`)
+	}
+	block.WriteString(`</w:abstractNum>`)
+
+	num := `<w:num w:numId="` + numID + `"><w:abstractNumId w:val="` + abstractID + `"/></w:num>`
+	ctx.xml = insertNumberingBlocks(ctx.xml, []string{block.String()}, []string{num})
+	ctx.modified = true
+	return numID
+}
+
 func (ctx *numberingContext) needsRelationship() bool {
 	return ctx.modified && !ctx.relationshipExists
 }
@@ -0,0 +1,285 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Compound File Binary format (MS-CFB) constants for version 3 files with
+// 512-byte sectors.
+const (
+	cfbSectorSize     = 512
+	cfbMiniSectorSize = 64
+	cfbMiniCutoff     = 4096
+	cfbEntrySize      = 128
+	cfbHeaderFATSlots = 109
+
+	cfbFreeSector  = 0xFFFFFFFF
+	cfbEndOfChain  = 0xFFFFFFFE
+	cfbFATSector   = 0xFFFFFFFD
+	cfbNoStream    = 0xFFFFFFFF
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+	cfbColorRed    = 0
+	cfbColorBlack  = 1
+	cfbMaxFileSize = cfbHeaderFATSlots * (cfbSectorSize / 4) * cfbSectorSize
+)
+
+// packagerCLSID is the class ID of the OLE Packager, {0003000C-0000-0000-C000-000000000046},
+// in its little-endian byte layout.
+var packagerCLSID = [16]byte{0x0C, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+
+// cfbStream is a stream of a compound file.
+type cfbStream struct {
+	name string
+	data []byte
+}
+
+// olePackage returns an OLE Packager object embedding the file content
+// under name, as stored in the word/embeddings part of an oleObject
+// relationship with the ProgID "Package".
+func olePackage(name string, content []byte) ([]byte, error) {
+	var native bytes.Buffer
+	// Ole10Native: the type, label, source path, temporary path and data
+	// of the packaged file, prefixed with the total size
+	binary.Write(&native, binary.LittleEndian, uint16(2))
+	native.WriteString(name + "\x00")
+	native.WriteString(name + "\x00")
+	binary.Write(&native, binary.LittleEndian, uint32(0x00030000))
+	binary.Write(&native, binary.LittleEndian, uint32(len(name)+1))
+	native.WriteString(name + "\x00")
+	binary.Write(&native, binary.LittleEndian, uint32(len(content)))
+	native.Write(content)
+
+	ole10Native := make([]byte, 4, 4+native.Len())
+	binary.LittleEndian.PutUint32(ole10Native, uint32(native.Len()))
+	ole10Native = append(ole10Native, native.Bytes()...)
+
+	var compObj bytes.Buffer
+	// CompObj: a header naming the class, then the user type, no
+	// clipboard format and the ProgID
+	compObj.Write([]byte{0x01, 0x00, 0xFE, 0xFF, 0x03, 0x0A, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF})
+	compObj.Write(packagerCLSID[:])
+	for _, s := range []string{"OLE Package", "", "Package"} {
+		if s == "" {
+			binary.Write(&compObj, binary.LittleEndian, uint32(0))
+			continue
+		}
+		binary.Write(&compObj, binary.LittleEndian, uint32(len(s)+1))
+		compObj.WriteString(s + "\x00")
+	}
+	binary.Write(&compObj, binary.LittleEndian, uint32(0))
+
+	return compoundFile(packagerCLSID, []cfbStream{
+		{name: "\x01CompObj", data: compObj.Bytes()},
+		{name: "\x01Ole10Native", data: ole10Native},
+	})
+}
+
+// compoundFile returns a compound file whose root storage has the class ID
+// clsid and holds streams. Streams shorter than the mini stream cutoff are
+// stored in the mini stream.
+func compoundFile(clsid [16]byte, streams []cfbStream) ([]byte, error) {
+	streams = append([]cfbStream(nil), streams...)
+	sort.Slice(streams, func(i, j int) bool {
+		return cfbNameLess(streams[i].name, streams[j].name)
+	})
+
+	var sectors bytes.Buffer
+	var fat []uint32
+	// allocate stores data in a chain of new sectors and returns the first
+	allocate := func(data []byte) uint32 {
+		if len(data) == 0 {
+			return cfbEndOfChain
+		}
+		start := uint32(len(fat))
+		count := (len(data) + cfbSectorSize - 1) / cfbSectorSize
+		for i := 0; i < count; i++ {
+			next := uint32(len(fat) + 1)
+			if i == count-1 {
+				next = cfbEndOfChain
+			}
+			fat = append(fat, next)
+		}
+		sectors.Write(data)
+		sectors.Write(make([]byte, count*cfbSectorSize-len(data)))
+		return start
+	}
+
+	// The mini stream holds the short streams in 64-byte mini sectors
+	var miniStream bytes.Buffer
+	var miniFAT []uint32
+	starts := make([]uint32, len(streams))
+	for i, stream := range streams {
+		if len(stream.data) >= cfbMiniCutoff {
+			continue
+		}
+		if len(stream.data) == 0 {
+			starts[i] = cfbEndOfChain
+			continue
+		}
+		starts[i] = uint32(len(miniFAT))
+		count := (len(stream.data) + cfbMiniSectorSize - 1) / cfbMiniSectorSize
+		for j := 0; j < count; j++ {
+			next := uint32(len(miniFAT) + 1)
+			if j == count-1 {
+				next = cfbEndOfChain
+			}
+			miniFAT = append(miniFAT, next)
+		}
+		miniStream.Write(stream.data)
+		miniStream.Write(make([]byte, count*cfbMiniSectorSize-len(stream.data)))
+	}
+	miniStreamStart := allocate(miniStream.Bytes())
+	for i, stream := range streams {
+		if len(stream.data) >= cfbMiniCutoff {
+			starts[i] = allocate(stream.data)
+		}
+	}
+	miniFATStart := allocate(uint32Bytes(miniFAT, cfbFreeSector))
+	miniFATSectors := (len(miniFAT)*4 + cfbSectorSize - 1) / cfbSectorSize
+
+	// The directory: the root entry, then the streams as a red-black tree
+	// below it
+	entries := make([][]byte, len(streams)+1)
+	left := make([]uint32, len(streams))
+	right := make([]uint32, len(streams))
+	colors := make([]byte, len(streams))
+	treeRoot := cfbTree(0, len(streams), left, right, colors)
+	entries[0] = cfbEntry("Root Entry", cfbTypeRoot, cfbColorBlack, cfbNoStream, cfbNoStream, treeRoot, clsid, miniStreamStart, uint32(miniStream.Len()))
+	for i, stream := range streams {
+		entries[i+1] = cfbEntry(stream.name, cfbTypeStream, colors[i], left[i], right[i], cfbNoStream, [16]byte{}, starts[i], uint32(len(stream.data)))
+	}
+	var directory bytes.Buffer
+	for _, entry := range entries {
+		directory.Write(entry)
+	}
+	for directory.Len()%cfbSectorSize != 0 {
+		directory.Write(cfbEntry("", 0, cfbColorRed, cfbNoStream, cfbNoStream, cfbNoStream, [16]byte{}, 0, 0))
+	}
+	directoryStart := allocate(directory.Bytes())
+
+	// The FAT covers all sectors, including its own
+	fatSectors := 0
+	for fatSectors*cfbSectorSize/4 < len(fat)+fatSectors {
+		fatSectors++
+	}
+	if fatSectors > cfbHeaderFATSlots {
+		return nil, fmt.Errorf("embedded file too large: at most %d bytes can be packaged", cfbMaxFileSize)
+	}
+	fatStart := uint32(len(fat))
+	for i := 0; i < fatSectors; i++ {
+		fat = append(fat, cfbFATSector)
+	}
+	fatData := uint32Bytes(fat, cfbFreeSector)
+	fatData = append(fatData, bytes.Repeat([]byte{0xFF}, fatSectors*cfbSectorSize-len(fatData))...)
+
+	header := make([]byte, cfbSectorSize)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	binary.LittleEndian.PutUint16(header[24:], 0x003E)
+	binary.LittleEndian.PutUint16(header[26:], 0x0003)
+	binary.LittleEndian.PutUint16(header[28:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[30:], 9)
+	binary.LittleEndian.PutUint16(header[32:], 6)
+	binary.LittleEndian.PutUint32(header[44:], uint32(fatSectors))
+	binary.LittleEndian.PutUint32(header[48:], directoryStart)
+	binary.LittleEndian.PutUint32(header[56:], cfbMiniCutoff)
+	binary.LittleEndian.PutUint32(header[60:], miniFATStart)
+	binary.LittleEndian.PutUint32(header[64:], uint32(miniFATSectors))
+	binary.LittleEndian.PutUint32(header[68:], cfbEndOfChain)
+	for i := 0; i < cfbHeaderFATSlots; i++ {
+		slot := uint32(cfbFreeSector)
+		if i < fatSectors {
+			slot = fatStart + uint32(i)
+		}
+		binary.LittleEndian.PutUint32(header[76+4*i:], slot)
+	}
+
+	var file bytes.Buffer
+	file.Grow(len(header) + sectors.Len() + len(fatData))
+	file.Write(header)
+	file.Write(sectors.Bytes())
+	file.Write(fatData)
+	return file.Bytes(), nil
+}
+
+// cfbTree links the sorted entries from to to-1 into a balanced binary
+// search tree and returns the index of its root as a directory stream ID.
+// The entries on the deepest level are red when it is not full, so every
+// path has the same number of black entries.
+func cfbTree(from, to int, left, right []uint32, colors []byte) uint32 {
+	depth := 0
+	for n := to - from; n > 0; n >>= 1 {
+		depth++
+	}
+	full := to-from == 1<<depth-1
+	var link func(from, to, level int) uint32
+	link = func(from, to, level int) uint32 {
+		if from >= to {
+			return cfbNoStream
+		}
+		mid := (from + to) / 2
+		left[mid] = link(from, mid, level+1)
+		right[mid] = link(mid+1, to, level+1)
+		colors[mid] = cfbColorBlack
+		if !full && level == depth-1 {
+			colors[mid] = cfbColorRed
+		}
+		// Stream IDs count from 1; the root entry is 0
+		return uint32(mid + 1)
+	}
+	return link(from, to, 0)
+}
+
+// cfbEntry returns a directory entry.
+func cfbEntry(name string, entryType, color byte, left, right, child uint32, clsid [16]byte, start, size uint32) []byte {
+	entry := make([]byte, cfbEntrySize)
+	if name != "" {
+		units := utf16.Encode([]rune(name))
+		for i, unit := range units {
+			binary.LittleEndian.PutUint16(entry[2*i:], unit)
+		}
+		binary.LittleEndian.PutUint16(entry[64:], uint16((len(units)+1)*2))
+	}
+	entry[66] = entryType
+	entry[67] = color
+	binary.LittleEndian.PutUint32(entry[68:], left)
+	binary.LittleEndian.PutUint32(entry[72:], right)
+	binary.LittleEndian.PutUint32(entry[76:], child)
+	copy(entry[80:], clsid[:])
+	binary.LittleEndian.PutUint32(entry[116:], start)
+	binary.LittleEndian.PutUint32(entry[120:], size)
+	return entry
+}
+
+// cfbNameLess orders directory entry names as compound files require:
+// shorter names first, then by their upper case form.
+func cfbNameLess(a, b string) bool {
+	la, lb := len(utf16.Encode([]rune(a))), len(utf16.Encode([]rune(b)))
+	if la != lb {
+		return la < lb
+	}
+	return strings.ToUpper(a) < strings.ToUpper(b)
+}
+
+// uint32Bytes encodes values in little-endian order, padded with pad to a
+// whole number of sectors.
+func uint32Bytes(values []uint32, pad uint32) []byte {
+	if len(values) == 0 {
+		return nil
+	}
+	count := (len(values)*4 + cfbSectorSize - 1) / cfbSectorSize * cfbSectorSize / 4
+	data := make([]byte, 4*count)
+	for i := 0; i < count; i++ {
+		value := pad
+		if i < len(values) {
+			value = values[i]
+		}
+		binary.LittleEndian.PutUint32(data[4*i:], value)
+	}
+	return data
+}
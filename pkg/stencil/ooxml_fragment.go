@@ -0,0 +1,302 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FragmentLevel is the kind of position an OOXMLFragment can be inserted at.
+type FragmentLevel int
+
+const (
+	// FragmentLevelRun content, such as runs, breaks and fields, is
+	// inserted among the text around the expression.
+	FragmentLevelRun FragmentLevel = iota
+	// FragmentLevelParagraph content is a single paragraph. It replaces the
+	// paragraph of an expression that stands alone in it.
+	FragmentLevelParagraph
+	// FragmentLevelBody content, such as tables and HTML blocks, is one or
+	// more body elements. It replaces the paragraph of an expression that
+	// stands alone in it.
+	FragmentLevelBody
+)
+
+// String returns the name of the level.
+func (l FragmentLevel) String() string {
+	switch l {
+	case FragmentLevelRun:
+		return "run"
+	case FragmentLevelParagraph:
+		return "paragraph"
+	case FragmentLevelBody:
+		return "body"
+	default:
+		return fmt.Sprintf("FragmentLevel(%d)", int(l))
+	}
+}
+
+// FragmentGroup is the content of a fragment composed with Fragments.
+type FragmentGroup struct {
+	Parts []interface{}
+}
+
+// Fragments composes several fragments into one, so that a function can
+// return them from a single call. Each part is an *OOXMLFragment or fragment
+// content such as a *Run, *Paragraph or *Table; nil parts are skipped.
+//
+// Run-level parts only make a run-level fragment, which can be used inline.
+// Otherwise the fragment must stand alone in its paragraph: consecutive
+// run-level parts become a paragraph and the other parts follow in order.
+//
+// Example:
+//
+//	return stencil.Fragments(
+//	    stencil.NewParagraph("Summary", stencil.ParagraphOptions{Style: "Heading2"}),
+//	    table,
+//	), nil
+func Fragments(parts ...interface{}) *OOXMLFragment {
+	group := &FragmentGroup{Parts: make([]interface{}, 0, len(parts))}
+	for _, part := range parts {
+		if fragment, ok := part.(*OOXMLFragment); ok {
+			if fragment == nil {
+				continue
+			}
+			part = fragment.Content
+		}
+		if nested, ok := part.(*FragmentGroup); ok {
+			group.Parts = append(group.Parts, nested.Parts...)
+			continue
+		}
+		if part != nil {
+			group.Parts = append(group.Parts, part)
+		}
+	}
+	return &OOXMLFragment{Content: group}
+}
+
+// ListContent is fragment content for a bulleted or numbered list. Every list has
+// its own numbering, starting at 1.
+type ListContent struct {
+	Items []ListItem
+	// Ordered numbers the items; otherwise they are bulleted.
+	Ordered bool
+	// Paragraph formats the items.
+	Paragraph ParagraphOptions
+}
+
+// ListItem is an item of a ListContent.
+type ListItem struct {
+	Text string
+	// Level is the nesting level, from 0 for top-level items to 8.
+	Level int
+}
+
+// NewList returns a list with a top-level item for each text.
+//
+// Example:
+//
+//	return &stencil.OOXMLFragment{Content: stencil.NewList(false, "Apples", "Pears")}, nil
+func NewList(ordered bool, items ...string) *ListContent {
+	list := &ListContent{Ordered: ordered, Items: make([]ListItem, len(items))}
+	for i, text := range items {
+		list.Items[i] = ListItem{Text: text}
+	}
+	return list
+}
+
+// Level returns the level of the fragment content, or an error if the
+// content is not a supported type.
+func (f *OOXMLFragment) Level() (FragmentLevel, error) {
+	if f == nil {
+		return FragmentLevelRun, fmt.Errorf("fragment is nil")
+	}
+	return fragmentContentLevel(f.Content)
+}
+
+// Validate reports an error if the fragment cannot be inserted at a position
+// of the given level. Expressions among other text are run-level positions;
+// expressions that stand alone in their paragraph are body-level positions.
+func (f *OOXMLFragment) Validate(context FragmentLevel) error {
+	level, err := f.Level()
+	if err != nil {
+		return err
+	}
+	if level > context {
+		if call, ok := f.Content.(*macroCall); ok {
+			return fmt.Errorf("macro %s has more than one paragraph and cannot be inserted at %s level; put the call in a paragraph of its own", call.macro.name, context)
+		}
+		return fmt.Errorf("%s-level fragment content (%T) cannot be inserted at %s level; put the expression in a paragraph of its own", level, f.Content, context)
+	}
+	return nil
+}
+
+func fragmentContentLevel(content interface{}) (FragmentLevel, error) {
+	switch c := content.(type) {
+	case *Break, *Run, *HTMLRuns, *FieldCode, *XMLFragment, *embeddedFile, *editableRegion, *editableBoundary, *definedTerm, *indexTableField, *captionField:
+		return FragmentLevelRun, nil
+	case *Paragraph:
+		return FragmentLevelParagraph, nil
+	case *HTMLBody, *HTMLTable, *Table, *ListContent, []BodyElement, *landscapeAppendix, *outline, *textParagraphs:
+		return FragmentLevelBody, nil
+	case *macroCall:
+		if c.macro.inline() {
+			return FragmentLevelRun, nil
+		}
+		return FragmentLevelBody, nil
+	case *FragmentGroup:
+		level := FragmentLevelRun
+		for _, part := range c.Parts {
+			partLevel, err := fragmentContentLevel(part)
+			if err != nil {
+				return level, err
+			}
+			if partLevel > level {
+				level = partLevel
+			}
+		}
+		if level > FragmentLevelRun && len(c.Parts) > 1 {
+			level = FragmentLevelBody
+		}
+		return level, nil
+	default:
+		return FragmentLevelRun, fmt.Errorf("unsupported fragment content %T", content)
+	}
+}
+
+// fragmentGroupElements returns the body elements of a paragraph- or
+// body-level group. Consecutive run-level parts are joined in a paragraph
+// like base.
+func fragmentGroupElements(group *FragmentGroup, base *Paragraph, ctx *renderContext) ([]BodyElement, error) {
+	template := &Run{Text: &Text{}}
+	for i := range base.Runs {
+		if base.Runs[i].Text != nil {
+			template.Properties = base.Runs[i].Properties
+			break
+		}
+	}
+
+	var elements []BodyElement
+	var para *Paragraph
+	for _, part := range group.Parts {
+		runs, inline, err := fragmentContentRuns(part, template, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if inline {
+			if para == nil {
+				para = &Paragraph{Properties: base.Properties, Attrs: base.Attrs}
+				elements = append(elements, para)
+			}
+			para.Runs = append(para.Runs, runs...)
+			continue
+		}
+		para = nil
+		if paragraphs, ok := part.(*textParagraphs); ok {
+			elements = append(elements, paragraphs.elements(base)...)
+			continue
+		}
+		partElements, err := fragmentContentElements(part, ctx)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, partElements...)
+	}
+	return elements, nil
+}
+
+// fragmentContentElements returns the body elements of paragraph- or
+// body-level fragment content.
+func fragmentContentElements(content interface{}, ctx *renderContext) ([]BodyElement, error) {
+	switch c := content.(type) {
+	case *HTMLBody:
+		if c == nil {
+			return nil, nil
+		}
+		return c.Elements, nil
+	case *HTMLTable:
+		if c == nil || c.Table == nil {
+			return nil, nil
+		}
+		return []BodyElement{c.Table}, nil
+	case *Paragraph:
+		if c == nil {
+			return nil, nil
+		}
+		return []BodyElement{c}, nil
+	case *Table:
+		if c == nil {
+			return nil, nil
+		}
+		return []BodyElement{c}, nil
+	case *ListContent:
+		return listParagraphs(c, ctx)
+	case []BodyElement:
+		return c, nil
+	case *macroCall:
+		return c.bodyElements(ctx)
+	case *outline:
+		return c.paragraphs(ctx), nil
+	case *textParagraphs:
+		return c.elements(nil), nil
+	case *landscapeAppendix:
+		// The appendix is added at the end of the document, so it renders
+		// as nothing here.
+		ctx.landscapeAppendices = append(ctx.landscapeAppendices, c)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported fragment content %T", content)
+	}
+}
+
+// listParagraphs returns the paragraphs of a list, adding its numbering
+// definition to the document.
+func listParagraphs(list *ListContent, ctx *renderContext) ([]BodyElement, error) {
+	if list == nil || len(list.Items) == 0 {
+		return nil, nil
+	}
+	if ctx == nil || ctx.numbering == nil {
+		return nil, fmt.Errorf("lists can only be inserted in the document body")
+	}
+
+	numID := ctx.numbering.addList(list.Ordered)
+	elements := make([]BodyElement, len(list.Items))
+	for i, item := range list.Items {
+		para := NewParagraph(item.Text, list.Paragraph)
+		if para.Properties == nil {
+			para.Properties = &ParagraphProperties{}
+		}
+		level := clampInt(item.Level, 0, maxNumberingLevel)
+		para.Properties.RawXML = []RawXMLElement{{
+			XMLName: xml.Name{Local: "numPr"},
+			Content: []byte(`<w:numPr><w:ilvl w:val="` + strconv.Itoa(level) + `"/><w:numId w:val="` + numID + `"/></w:numPr>`),
+		}}
+		elements[i] = para
+	}
+	return elements, nil
+}
+
+// validateInlineFragments reports an error if a paragraph still holds the
+// placeholder of paragraph- or body-level content, which happens when the
+// expression does not stand alone in its paragraph.
+func validateInlineFragments(para *Paragraph, ctx *renderContext) error {
+	if para == nil || ctx == nil || ctx.ooxmlFragments == nil {
+		return nil
+	}
+	text := buildParagraphRenderText(para)
+	if !strings.Contains(text, "{{OOXML_FRAGMENT:") {
+		return nil
+	}
+	for _, match := range ooxmlFragmentRegex.FindAllStringSubmatch(text, -1) {
+		content, ok := ctx.ooxmlFragments[match[1]]
+		if !ok {
+			continue
+		}
+		fragment := &OOXMLFragment{Content: content}
+		if err := fragment.Validate(FragmentLevelRun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
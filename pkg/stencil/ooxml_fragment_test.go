@@ -0,0 +1,202 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFragments(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	engine.RegisterFunction("report", NewSimpleFunction("report", 0, 0, func(args ...interface{}) (interface{}, error) {
+		table := NewTable(1, 1)
+		table.Cell(0, 0).SetText("Cell", ParagraphOptions{})
+		return Fragments(
+			NewParagraph("Summary", ParagraphOptions{Style: "Heading2"}),
+			table,
+			NewRun("Total: ", RunProps{}),
+			&OOXMLFragment{Content: NewRun("42", RunProps{Bold: true})},
+		), nil
+	}))
+	engine.RegisterFunction("status", NewSimpleFunction("status", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return Fragments(NewRun("open", RunProps{Italic: true}), nil, Fragments(&Break{})), nil
+	}))
+
+	docx := createDOCXWithParagraphs(t, []string{`{{report()}}`, `Status: {{status()}}.`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(TemplateData{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	documentXML := extractDocumentXMLFromDOCX(t, content)
+
+	assertInOrder(t, documentXML,
+		`<w:pStyle w:val="Heading2">`, `>Summary<`,
+		`<w:tbl>`, `>Cell<`, `</w:tbl>`,
+		`<w:p>`, `>Total: <`, `<w:b/>`, `>42<`, `</w:p>`,
+		`>Status: <`, `<w:i/>`, `>open<`, `<w:br>`, `>.<`,
+	)
+	if strings.Contains(documentXML, "OOXML_FRAGMENT") {
+		t.Errorf("fragment placeholder left in output: %s", documentXML)
+	}
+}
+
+func TestFragmentLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment *OOXMLFragment
+		want     FragmentLevel
+	}{
+		{"run", &OOXMLFragment{Content: NewRun("x", RunProps{})}, FragmentLevelRun},
+		{"break", &OOXMLFragment{Content: &Break{Type: "page"}}, FragmentLevelRun},
+		{"paragraph", &OOXMLFragment{Content: NewParagraph("x", ParagraphOptions{})}, FragmentLevelParagraph},
+		{"table", &OOXMLFragment{Content: NewTable(1, 1)}, FragmentLevelBody},
+		{"runs", Fragments(NewRun("a", RunProps{}), NewRun("b", RunProps{})), FragmentLevelRun},
+		{"one paragraph", Fragments(NewParagraph("x", ParagraphOptions{})), FragmentLevelParagraph},
+		{"paragraph and run", Fragments(NewParagraph("x", ParagraphOptions{}), NewRun("y", RunProps{})), FragmentLevelBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, err := tt.fragment.Level()
+			if err != nil {
+				t.Fatalf("Level() error = %v", err)
+			}
+			if level != tt.want {
+				t.Errorf("Level() = %v, want %v", level, tt.want)
+			}
+		})
+	}
+
+	if err := (&OOXMLFragment{Content: NewTable(1, 1)}).Validate(FragmentLevelRun); err == nil {
+		t.Error("Validate() accepted a table at run level")
+	}
+	if err := (&OOXMLFragment{Content: NewTable(1, 1)}).Validate(FragmentLevelBody); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (&OOXMLFragment{Content: "text"}).Validate(FragmentLevelBody); err == nil || !strings.Contains(err.Error(), "unsupported fragment content string") {
+		t.Errorf("Validate() error = %v, want unsupported content", err)
+	}
+}
+
+func TestFragmentInsertionErrors(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	engine.RegisterFunction("grid", NewSimpleFunction("grid", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: NewTable(2, 2)}, nil
+	}))
+	engine.RegisterFunction("odd", NewSimpleFunction("odd", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: 42}, nil
+	}))
+
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{"body content among text", `See {{grid()}} below`, "body-level fragment content (*xml.Table) cannot be inserted at run level"},
+		{"unsupported content", `Value: {{odd()}}`, "unsupported fragment content int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := engine.Prepare(bytes.NewReader(createSimpleDOCX(t, tt.text)))
+			if err != nil {
+				t.Fatalf("Prepare failed: %v", err)
+			}
+			defer tmpl.Close()
+			_, err = tmpl.Render(TemplateData{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Render() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlockFunctionResults(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	engine.RegisterFunction("steps", NewSimpleFunction("steps", 0, 0, func(args ...interface{}) (interface{}, error) {
+		list := NewList(true, "Open", "Sign")
+		list.Items = append(list.Items, ListItem{Text: "Twice", Level: 1})
+		return &OOXMLFragment{Content: list}, nil
+	}))
+	engine.RegisterFunction("notes", NewSimpleFunction("notes", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: []BodyElement{
+			NewParagraph("First note", ParagraphOptions{}),
+			NewParagraph("Second note", ParagraphOptions{}),
+		}}, nil
+	}))
+
+	docx := createDOCXWithParagraphs(t, []string{`{{steps()}}`, `{{notes()}}`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(TemplateData{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	documentXML := extractDocumentXMLFromDOCX(t, content)
+
+	assertInOrder(t, documentXML,
+		`<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1024"/></w:numPr>`, `>Open<`,
+		`<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1024"/></w:numPr>`, `>Sign<`,
+		`<w:numPr><w:ilvl w:val="1"/><w:numId w:val="1024"/></w:numPr>`, `>Twice<`,
+		`>First note<`, `</w:p><w:p>`, `>Second note<`,
+	)
+	numberingXML := extractPartFromDOCX(t, content, "word/numbering.xml")
+	for _, want := range []string{
+		`<w:abstractNum w:abstractNumId="1024">`,
+		`<w:lvl w:ilvl="1"><w:start w:val="1"/><w:numFmt w:val="lowerLetter"/><w:lvlText w:val="%2."/>`,
+		`<w:num w:numId="1024"><w:abstractNumId w:val="1024"/></w:num>`,
+	} {
+		if !strings.Contains(numberingXML, want) {
+			t.Errorf("numbering.xml missing %s:\n%s", want, numberingXML)
+		}
+	}
+}
+
+func TestBlockFunctionResultErrors(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	engine.RegisterFunction("bullets", NewSimpleFunction("bullets", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: NewList(false, "a", "b")}, nil
+	}))
+	engine.RegisterFunction("grid", NewSimpleFunction("grid", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &OOXMLFragment{Content: NewTable(1, 1)}, nil
+	}))
+
+	tests := []struct {
+		name    string
+		bodyXML string
+		wantErr string
+	}{
+		{
+			name:    "list among text",
+			bodyXML: `<w:p><w:r><w:t xml:space="preserve">Items: {{bullets()}}</w:t></w:r></w:p>`,
+			wantErr: "body-level fragment content (*stencil.ListContent) cannot be inserted at run level",
+		},
+		{
+			name:    "table in a table cell",
+			bodyXML: `<w:tbl><w:tr><w:tc><w:p><w:r><w:t>{{grid()}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`,
+			wantErr: "a table cannot be inserted in a table cell",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithBodyXML(t, tt.bodyXML)))
+			if err != nil {
+				t.Fatalf("Prepare failed: %v", err)
+			}
+			defer tmpl.Close()
+			_, err = tmpl.Render(TemplateData{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Render() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/benjaminschreck/go-stencil/pkg/stencil/render"
+)
+
+// OptimizeOptions configures OptimizeTemplate.
+type OptimizeOptions struct {
+	// KeepStyles lists the IDs of styles to keep even though the template
+	// does not reference them, such as styles applied by post-processors.
+	KeepStyles []string
+}
+
+// OptimizeReport describes the changes OptimizeTemplate made.
+type OptimizeReport struct {
+	// InputSize and OutputSize are the sizes of the DOCX files in bytes.
+	InputSize  int
+	OutputSize int
+	// RemovedRsids counts the revision save IDs (w:rsid* attributes) removed.
+	RemovedRsids int
+	// RemovedProofErrors counts the spelling and grammar markers removed.
+	RemovedProofErrors int
+	// MergedRuns counts the runs merged into the run before them.
+	MergedRuns int
+	// NormalizedTags counts the template tags whose text was normalized.
+	NormalizedTags int
+	// RemovedStyles lists the IDs of the unused styles removed, sorted.
+	RemovedStyles []string
+}
+
+// rsidAttributePattern matches the revision save ID attributes Word adds to
+// paragraphs, runs, rows and sections, such as w:rsidR="00A1B2C3".
+var rsidAttributePattern = regexp.MustCompile(`\s+w:rsid[A-Za-z]*="[^"]*"`)
+
+// settingsRsidsPattern matches the table of revision save IDs in
+// word/settings.xml.
+var settingsRsidsPattern = regexp.MustCompile(`(?s)<w:rsids>.*?</w:rsids>|<w:rsids/>`)
+
+// styleReferencePattern matches the references to styles outside
+// word/styles.xml.
+var styleReferencePattern = regexp.MustCompile(`<w:(?:pStyle|rStyle|tblStyle|numStyleLink|styleLink)\s[^>]*?w:val="([^"]*)"`)
+
+// OptimizeTemplate rewrites a DOCX template into a smaller template that
+// renders the same and renders faster: it removes revision save IDs and
+// spelling and grammar markers, merges the runs Word split because of them,
+// trims the whitespace inside template tags and removes styles nothing
+// refers to. Default styles, styles other styles are based on and styles
+// named in template tags, such as by include modifiers, are kept.
+//
+// The body, headers, footers, footnotes and endnotes are rewritten; text
+// boxes and other content kept as raw XML are left as they are.
+//
+// Example:
+//
+//	optimized, report, err := stencil.OptimizeTemplate(docxBytes, stencil.OptimizeOptions{})
+//	if err != nil {
+//	    return err
+//	}
+//	log.Printf("optimized template: %d -> %d bytes", report.InputSize, report.OutputSize)
+func OptimizeTemplate(docx []byte, opts OptimizeOptions) ([]byte, OptimizeReport, error) {
+	report := OptimizeReport{InputSize: len(docx)}
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		return nil, report, NewDocumentError("parse", "DOCX", err)
+	}
+	if _, ok := pkg.get("word/document.xml"); !ok {
+		return nil, report, NewDocumentError("extract", "document.xml", fmt.Errorf("part not found"))
+	}
+
+	var tagTexts []string
+	for _, name := range pkg.names {
+		if name != "word/document.xml" && !isStoryPartName(name) {
+			continue
+		}
+		content := stripRsidAttributes(pkg.parts[name], &report)
+		if name == "word/document.xml" {
+			content, err = optimizeDocumentPart(content, &report)
+		} else {
+			content, err = optimizeStoryPart(name, content, &report)
+		}
+		if err != nil {
+			return nil, report, NewDocumentError("optimize", name, err)
+		}
+		paragraphs, err := partParagraphTexts(content)
+		if err != nil {
+			return nil, report, NewDocumentError("read", name, err)
+		}
+		for _, text := range paragraphs {
+			tagTexts = append(tagTexts, tokenRegex.FindAllString(text, -1)...)
+		}
+		pkg.set(name, content)
+	}
+
+	if settings, ok := pkg.get("word/settings.xml"); ok {
+		pkg.set("word/settings.xml", settingsRsidsPattern.ReplaceAll(settings, nil))
+	}
+
+	if styles, ok := pkg.get("word/styles.xml"); ok {
+		optimized, removed, err := removeUnusedStyles(pkg, styles, tagTexts, opts.KeepStyles)
+		if err != nil {
+			return nil, report, NewDocumentError("optimize", "word/styles.xml", err)
+		}
+		pkg.set("word/styles.xml", optimized)
+		report.RemovedStyles = removed
+	}
+
+	output, err := pkg.bytes()
+	if err != nil {
+		return nil, report, NewDocumentError("write", "DOCX", err)
+	}
+	report.OutputSize = len(output)
+	return output, report, nil
+}
+
+// optimizeDocumentPart optimizes the paragraphs of word/document.xml.
+func optimizeDocumentPart(content []byte, report *OptimizeReport) ([]byte, error) {
+	doc, err := ParseDocument(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if doc.Body == nil {
+		return content, nil
+	}
+	for _, para := range bodyElementParagraphs(doc.Body.Elements) {
+		optimizeParagraph(para, report)
+	}
+	return marshalDocumentWithNamespaces(doc)
+}
+
+// optimizeStoryPart optimizes the paragraphs of a header, footer or notes
+// part.
+func optimizeStoryPart(name string, content []byte, report *OptimizeReport) ([]byte, error) {
+	var encoded bytes.Buffer
+	result, err := spliceStoryContainers(name, content, func(elements []BodyElement) ([]byte, bool, error) {
+		for _, para := range bodyElementParagraphs(elements) {
+			optimizeParagraph(para, report)
+		}
+		chunk, err := encodeStoryElements(elements)
+		if err != nil {
+			return nil, false, err
+		}
+		encoded.Write(chunk)
+		return chunk, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return declareStoryNamespaces(result, encoded.Bytes(), nil), nil
+}
+
+// optimizeParagraph removes the proofing markers of para, merges its runs
+// and normalizes its template tags.
+func optimizeParagraph(para *Paragraph, report *OptimizeReport) {
+	before := paragraphRunCount(para)
+	if len(para.Content) > 0 {
+		kept := para.Content[:0]
+		for _, item := range para.Content {
+			if _, ok := item.(*ProofErr); ok {
+				report.RemovedProofErrors++
+				continue
+			}
+			kept = append(kept, item)
+		}
+		para.Content = kept
+	}
+	render.MergeConsecutiveRuns(para)
+	report.MergedRuns += before - paragraphRunCount(para)
+
+	normalizeRun := func(run *Run) {
+		if run.Text == nil || !strings.Contains(run.Text.Content, "{{") {
+			return
+		}
+		run.Text.Content = tokenRegex.ReplaceAllStringFunc(run.Text.Content, func(tag string) string {
+			normalized := normalizeTemplateTag(tag)
+			if normalized != tag {
+				report.NormalizedTags++
+			}
+			return normalized
+		})
+	}
+	if len(para.Content) > 0 {
+		for _, item := range para.Content {
+			switch c := item.(type) {
+			case *Run:
+				normalizeRun(c)
+			case *Hyperlink:
+				for i := range c.Runs {
+					normalizeRun(&c.Runs[i])
+				}
+			}
+		}
+		return
+	}
+	for i := range para.Runs {
+		normalizeRun(&para.Runs[i])
+	}
+	for i := range para.Hyperlinks {
+		for j := range para.Hyperlinks[i].Runs {
+			normalizeRun(&para.Hyperlinks[i].Runs[j])
+		}
+	}
+}
+
+// paragraphRunCount returns the number of runs of para, including the runs
+// of its hyperlinks.
+func paragraphRunCount(para *Paragraph) int {
+	count := 0
+	if len(para.Content) > 0 {
+		for _, item := range para.Content {
+			switch c := item.(type) {
+			case *Run:
+				count++
+			case *Hyperlink:
+				count += len(c.Runs)
+			}
+		}
+		return count
+	}
+	count = len(para.Runs)
+	for _, hyperlink := range para.Hyperlinks {
+		count += len(hyperlink.Runs)
+	}
+	return count
+}
+
+// normalizeTemplateTag trims the whitespace inside a template tag and
+// separates the keyword of control tags from their arguments by a single
+// space, as in {{if  x }} to {{if x}}. The tag is returned unchanged when
+// normalizing would change how it is parsed.
+func normalizeTemplateTag(tag string) string {
+	content := strings.TrimSpace(tag[2 : len(tag)-2])
+	if content == "" {
+		return tag
+	}
+	normalized := content
+	if fields := strings.Fields(content); len(fields) > 1 {
+		normalized = fields[0] + " " + strings.TrimSpace(strings.TrimPrefix(content, fields[0]))
+		if parseToken(normalized) != parseToken(content) {
+			normalized = content
+		}
+	}
+	return "{{" + normalized + "}}"
+}
+
+// stripRsidAttributes removes the revision save ID attributes from the tags
+// of a part. Text, comments and CDATA sections are copied unchanged.
+func stripRsidAttributes(content []byte, report *OptimizeReport) []byte {
+	if !bytes.Contains(content, []byte("w:rsid")) {
+		return content
+	}
+	s := string(content)
+	var out strings.Builder
+	out.Grow(len(s))
+	for i := 0; i < len(s); {
+		start := strings.IndexByte(s[i:], '<')
+		if start < 0 {
+			out.WriteString(s[i:])
+			break
+		}
+		start += i
+		out.WriteString(s[i:start])
+
+		end := xmlMarkupEnd(s, start)
+		if end < 0 {
+			out.WriteString(s[start:])
+			break
+		}
+		tag := s[start:end]
+		if !strings.HasPrefix(tag, "<!") && !strings.HasPrefix(tag, "<?") {
+			report.RemovedRsids += len(rsidAttributePattern.FindAllStringIndex(tag, -1))
+			tag = rsidAttributePattern.ReplaceAllString(tag, "")
+		}
+		out.WriteString(tag)
+		i = end
+	}
+	return []byte(out.String())
+}
+
+// styleDefinition is a w:style element of word/styles.xml.
+type styleDefinition struct {
+	id         string
+	name       string
+	isDefault  bool
+	references []string
+	start, end int
+}
+
+// removeUnusedStyles removes the styles of stylesXML that no part of pkg,
+// no kept style and no template tag refers to, and returns their IDs.
+func removeUnusedStyles(pkg *docxPackage, stylesXML []byte, tagTexts, keep []string) ([]byte, []string, error) {
+	styles, err := parseStyleDefinitions(stylesXML)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, id := range keep {
+		used[id] = true
+	}
+	for _, name := range pkg.names {
+		if name == "word/styles.xml" || !strings.HasSuffix(name, ".xml") {
+			continue
+		}
+		for _, match := range styleReferencePattern.FindAllSubmatch(pkg.parts[name], -1) {
+			used[string(match[1])] = true
+		}
+	}
+	tags := strings.Join(tagTexts, "\n")
+	byID := make(map[string]*styleDefinition, len(styles))
+	var pending []string
+	for i := range styles {
+		style := &styles[i]
+		byID[style.id] = style
+		if style.isDefault || used[style.id] ||
+			strings.Contains(tags, style.id) || (style.name != "" && strings.Contains(tags, style.name)) {
+			pending = append(pending, style.id)
+		}
+	}
+
+	kept := make(map[string]bool)
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if kept[id] {
+			continue
+		}
+		kept[id] = true
+		if style, ok := byID[id]; ok {
+			pending = append(pending, style.references...)
+		}
+	}
+
+	var removed []string
+	var out bytes.Buffer
+	copied := 0
+	for _, style := range styles {
+		if kept[style.id] {
+			continue
+		}
+		removed = append(removed, style.id)
+		out.Write(stylesXML[copied:style.start])
+		copied = style.end
+	}
+	if len(removed) == 0 {
+		return stylesXML, nil, nil
+	}
+	out.Write(stylesXML[copied:])
+	sort.Strings(removed)
+	return out.Bytes(), removed, nil
+}
+
+// parseStyleDefinitions returns the w:style elements of word/styles.xml
+// with their byte ranges and the styles they refer to.
+func parseStyleDefinitions(stylesXML []byte) ([]styleDefinition, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(stylesXML))
+	var styles []styleDefinition
+	var current *styleDefinition
+	depth := 0
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return styles, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse styles.xml: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 2 && t.Name.Local == "style":
+				start := int(offset) + bytes.IndexByte(stylesXML[offset:], '<')
+				styles = append(styles, styleDefinition{start: start})
+				current = &styles[len(styles)-1]
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "styleId":
+						current.id = attr.Value
+					case "default":
+						current.isDefault = attr.Value == "1" || attr.Value == "true" || attr.Value == "on"
+					}
+				}
+			case depth == 3 && current != nil:
+				for _, attr := range t.Attr {
+					if attr.Name.Local != "val" {
+						continue
+					}
+					switch t.Name.Local {
+					case "name":
+						current.name = attr.Value
+					case "basedOn", "link", "next":
+						current.references = append(current.references, attr.Value)
+					}
+				}
+			}
+		case xml.EndElement:
+			if depth == 2 && current != nil {
+				current.end = int(decoder.InputOffset())
+				current = nil
+			}
+			depth--
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptimizeTemplate(t *testing.T) {
+	body := `<w:p w:rsidR="00A1B2C3" w:rsidRDefault="00D4E5F6"><w:pPr><w:pStyle w:val="Heading1"/></w:pPr>` +
+		`<w:r w:rsidRPr="00112233"><w:t xml:space="preserve">Dear {{ </w:t></w:r><w:proofErr w:type="spellStart"/>` +
+		`<w:r w:rsidRPr="00445566"><w:t>customer.name</w:t></w:r><w:proofErr w:type="spellEnd"/>` +
+		`<w:r><w:t xml:space="preserve"> }}, </w:t></w:r><w:r w:rsidR="00778899"><w:t>welcome.</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{if  paid }}Thank you.{{end}}</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>{{ "a  b" }}</w:t></w:r></w:p>`
+	styles := `<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Base"><w:name w:val="Base"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Base"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Unused"><w:name w:val="Unused"/></w:style>` +
+		`<w:style w:type="character" w:styleId="UnusedChar"><w:name w:val="Unused Char"/><w:link w:val="Unused"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Signature"><w:name w:val="Signature"/></w:style>`
+	docx := createDOCXWithCustomStylesAndBody(t, body, styles)
+
+	optimized, report, err := OptimizeTemplate(docx, OptimizeOptions{KeepStyles: []string{"Signature"}})
+	if err != nil {
+		t.Fatalf("OptimizeTemplate failed: %v", err)
+	}
+
+	if report.RemovedRsids != 5 || report.RemovedProofErrors != 2 || report.NormalizedTags != 3 {
+		t.Errorf("RemovedRsids, RemovedProofErrors, NormalizedTags = %d, %d, %d, want 5, 2, 3",
+			report.RemovedRsids, report.RemovedProofErrors, report.NormalizedTags)
+	}
+	if report.MergedRuns != 3 {
+		t.Errorf("MergedRuns = %d, want 3", report.MergedRuns)
+	}
+	if want := []string{"Unused", "UnusedChar"}; !reflect.DeepEqual(report.RemovedStyles, want) {
+		t.Errorf("RemovedStyles = %v, want %v", report.RemovedStyles, want)
+	}
+	if report.InputSize != len(docx) || report.OutputSize != len(optimized) {
+		t.Errorf("sizes = %d, %d, want %d, %d", report.InputSize, report.OutputSize, len(docx), len(optimized))
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, optimized)
+	for _, unwanted := range []string{"rsid", "proofErr"} {
+		if strings.Contains(documentXML, unwanted) {
+			t.Errorf("optimized document still contains %s: %s", unwanted, documentXML)
+		}
+	}
+	for _, want := range []string{"Dear {{customer.name}}, welcome.", "{{if paid}}Thank you.{{end}}"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("optimized document missing %q: %s", want, documentXML)
+		}
+	}
+	if !strings.Contains(documentXML, "a  b") {
+		t.Errorf("optimized template changed a string literal: %s", documentXML)
+	}
+	stylesXML := extractPartFromDOCX(t, optimized, "word/styles.xml")
+	if strings.Contains(stylesXML, `w:styleId="Unused"`) || !strings.Contains(stylesXML, `w:styleId="Base"`) {
+		t.Errorf("unexpected styles after optimizing: %s", stylesXML)
+	}
+
+	data := TemplateData{"customer": map[string]interface{}{"name": "Ada"}, "paid": true}
+	want := extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	got := extractTextFromDOCX(t, renderWithOptionsToBytes(t, optimized, data, RenderOptions{}))
+	if got != want {
+		t.Errorf("optimized template renders %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeTemplateTag(t *testing.T) {
+	tests := map[string]string{
+		"{{ name }}":              "{{name}}",
+		"{{for  item in  items}}": "{{for item in  items}}",
+		"{{elsif x > 1}}":         "{{elsif x > 1}}",
+		"{{ a  +  b }}":           "{{a  +  b}}",
+		`{{ "two  spaces" }}`:     `{{"two  spaces"}}`,
+		"{{  }}":                  "{{  }}",
+		`{{include  "terms" }}`:   `{{include "terms"}}`,
+	}
+	for tag, want := range tests {
+		if got := normalizeTemplateTag(tag); got != want {
+			t.Errorf("normalizeTemplateTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package stencil
+
+import "fmt"
+
+// orgChart renders a hierarchy for orgChart(tree, style) as a nested list.
+// The tree is a node or a list of nodes; each node is a map with a "name"
+// (or "title"), an optional "role" and optional "children". Style is
+// "bullets" (the default) or "numbered".
+func orgChart(args ...interface{}) (interface{}, error) {
+	list := &ListContent{}
+	if len(args) > 1 && args[1] != nil {
+		switch style := FormatValue(args[1]); style {
+		case "bullets":
+		case "numbered":
+			list.Ordered = true
+		default:
+			return nil, fmt.Errorf("orgChart: invalid style '%s' (must be 'bullets' or 'numbered')", style)
+		}
+	}
+
+	roots, err := orgChartNodes(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		if err := addOrgChartNode(list, root, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return &OOXMLFragment{Content: list}, nil
+}
+
+// orgChartNodes returns value as a list of nodes. A single node is a list
+// of one.
+func orgChartNodes(value interface{}) ([]interface{}, error) {
+	switch value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}, TemplateData:
+		return []interface{}{value}, nil
+	}
+	nodes, err := toSlice(value)
+	if err != nil {
+		return nil, fmt.Errorf("orgChart: expected a node or a list of nodes, got %T", value)
+	}
+	return nodes, nil
+}
+
+// addOrgChartNode adds node and its children to list.
+func addOrgChartNode(list *ListContent, node interface{}, level int) error {
+	if node == nil {
+		return nil
+	}
+	if level > maxNumberingLevel {
+		return fmt.Errorf("orgChart: the hierarchy is deeper than %d levels", maxNumberingLevel+1)
+	}
+
+	name := accessMapField(node, "name")
+	if name == nil {
+		name = accessMapField(node, "title")
+	}
+	text := FormatValue(name)
+	if role := FormatValue(accessMapField(node, "role")); role != "" {
+		text += " – " + role
+	}
+	list.Items = append(list.Items, ListItem{Text: text, Level: level})
+
+	children, err := orgChartNodes(accessMapField(node, "children"))
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := addOrgChartNode(list, child, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
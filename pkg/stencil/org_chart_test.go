@@ -0,0 +1,76 @@
+//go:build !stencil_nocharts && !stencil_lite
+
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOrgChart(t *testing.T) {
+	tree := map[string]interface{}{
+		"name": "Holding AG",
+		"children": []interface{}{
+			map[string]interface{}{"name": "Ada", "role": "CEO", "children": []map[string]interface{}{
+				{"name": "Bob", "role": "CTO"},
+			}},
+			map[string]interface{}{"title": "Subsidiary GmbH"},
+		},
+	}
+
+	result, err := orgChart(tree)
+	if err != nil {
+		t.Fatalf("orgChart() error = %v", err)
+	}
+	list := result.(*OOXMLFragment).Content.(*ListContent)
+	want := []ListItem{
+		{Text: "Holding AG", Level: 0},
+		{Text: "Ada – CEO", Level: 1},
+		{Text: "Bob – CTO", Level: 2},
+		{Text: "Subsidiary GmbH", Level: 1},
+	}
+	if len(list.Items) != len(want) || list.Ordered {
+		t.Fatalf("got %+v, want bulleted %+v", list, want)
+	}
+	for i := range want {
+		if list.Items[i].Text != want[i].Text || list.Items[i].Level != want[i].Level {
+			t.Errorf("item %d = %+v, want %+v", i, list.Items[i], want[i])
+		}
+	}
+
+	result, err = orgChart([]interface{}{tree, map[string]interface{}{"name": "Other"}}, "numbered")
+	if err != nil {
+		t.Fatalf("orgChart() error = %v", err)
+	}
+	if list := result.(*OOXMLFragment).Content.(*ListContent); !list.Ordered || len(list.Items) != 5 {
+		t.Errorf("expected a numbered list of 5 items, got %+v", list)
+	}
+
+	if result, err := orgChart(nil); result != nil || err != nil {
+		t.Errorf("orgChart(nil) = %v, %v, want nothing", result, err)
+	}
+	if _, err := orgChart(tree, "boxes"); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+	deep := map[string]interface{}{"name": "leaf"}
+	for i := 0; i < 10; i++ {
+		deep = map[string]interface{}{"name": "node", "children": []interface{}{deep}}
+	}
+	if _, err := orgChart(deep); err == nil || !strings.Contains(err.Error(), "deeper than 9 levels") {
+		t.Errorf("error = %v, want a depth error", err)
+	}
+}
+
+func TestOrgChartInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{orgChart(company)}}`})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"company": map[string]interface{}{"name": "Acme", "children": []interface{}{map[string]interface{}{"name": "Sales"}}},
+	}))
+	assertInOrder(t, documentXML, `<w:ilvl w:val="0"/>`, `>Acme<`, `<w:ilvl w:val="1"/>`, `>Sales<`)
+}
@@ -0,0 +1,178 @@
+package stencil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxHeadingLevel is the deepest built-in heading style, Heading9.
+const maxHeadingLevel = 9
+
+// outline is the content of outline(): headings and body paragraphs in
+// document order. Heading styles are resolved against the styles of the
+// template when it renders.
+type outline struct {
+	entries []outlineEntry
+}
+
+// outlineEntry is a paragraph of an outline. Level is the heading level of
+// a heading and zero for body text.
+type outlineEntry struct {
+	text  string
+	level int
+}
+
+// buildOutline renders a document tree for outline(tree, startLevel) as
+// headings and body text. The tree is a section or a list of sections;
+// each section is a map with a "title" (or "heading"), an optional "body"
+// and optional "sections" (or "children"). Titles at the top get the style
+// Heading<startLevel>, 1 by default, and each level of sections below gets
+// the next heading level. A body is a string, whose paragraphs are
+// separated by blank lines, or a list of paragraph strings.
+func buildOutline(args ...interface{}) (interface{}, error) {
+	startLevel := 1
+	if len(args) > 1 && args[1] != nil {
+		level, err := toNumber(args[1])
+		if err != nil || level < 1 || level > maxHeadingLevel || level != float64(int(level)) {
+			return nil, fmt.Errorf("outline: start level must be an integer from 1 to %d, got %v", maxHeadingLevel, args[1])
+		}
+		startLevel = int(level)
+	}
+
+	content := &outline{}
+	sections, err := outlineSections(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		if err := content.addSection(section, startLevel); err != nil {
+			return nil, err
+		}
+	}
+	if len(content.entries) == 0 {
+		return nil, nil
+	}
+	return &OOXMLFragment{Content: content}, nil
+}
+
+// outlineSections returns value as a list of sections. A single section is
+// a list of one.
+func outlineSections(value interface{}) ([]interface{}, error) {
+	switch value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}, TemplateData:
+		return []interface{}{value}, nil
+	}
+	sections, err := toSlice(value)
+	if err != nil {
+		return nil, fmt.Errorf("outline: expected a section or a list of sections, got %T", value)
+	}
+	return sections, nil
+}
+
+// addSection adds the title of section as a heading of level, followed by
+// its body and its sections. The sections of a section without a title
+// stay at its level.
+func (o *outline) addSection(section interface{}, level int) error {
+	if section == nil {
+		return nil
+	}
+	if text, ok := section.(string); ok {
+		o.addBody(text)
+		return nil
+	}
+
+	title := accessMapField(section, "title")
+	if title == nil {
+		title = accessMapField(section, "heading")
+	}
+	childLevel := level
+	if text := strings.TrimSpace(FormatValue(title)); text != "" {
+		if level > maxHeadingLevel {
+			return fmt.Errorf("outline: the document tree is deeper than heading level %d", maxHeadingLevel)
+		}
+		o.entries = append(o.entries, outlineEntry{text: text, level: level})
+		childLevel = level + 1
+	}
+
+	switch body := accessMapField(section, "body").(type) {
+	case nil:
+	case string:
+		o.addBody(body)
+	default:
+		paragraphs, err := toSlice(body)
+		if err != nil {
+			return fmt.Errorf("outline: body must be a string or a list of strings, got %T", body)
+		}
+		for _, paragraph := range paragraphs {
+			o.addBody(FormatValue(paragraph))
+		}
+	}
+
+	children := accessMapField(section, "sections")
+	if children == nil {
+		children = accessMapField(section, "children")
+	}
+	sections, err := outlineSections(children)
+	if err != nil {
+		return err
+	}
+	for _, child := range sections {
+		if err := o.addSection(child, childLevel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBody adds the paragraphs of text, which are separated by blank lines.
+func (o *outline) addBody(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph = strings.Trim(paragraph, "\n"); strings.TrimSpace(paragraph) != "" {
+			o.entries = append(o.entries, outlineEntry{text: paragraph})
+		}
+	}
+}
+
+// paragraphs returns the paragraphs of the outline, with the heading
+// styles of the template.
+func (o *outline) paragraphs(ctx *renderContext) []BodyElement {
+	var stylesXML []byte
+	if ctx != nil {
+		stylesXML = ctx.mainStylesXML
+	}
+	styles := make(map[int]string)
+	elements := make([]BodyElement, 0, len(o.entries))
+	for _, entry := range o.entries {
+		opts := ParagraphOptions{}
+		if entry.level > 0 {
+			if _, ok := styles[entry.level]; !ok {
+				styles[entry.level] = headingStyleID(stylesXML, entry.level)
+			}
+			opts.Style = styles[entry.level]
+		}
+		elements = append(elements, NewParagraph(entry.text, opts))
+	}
+	return elements
+}
+
+// headingStyleID returns the ID of the built-in heading style of level in
+// the template styles, which is not Heading1 in templates made with a
+// localized Word, such as "berschrift1".
+func headingStyleID(stylesXML []byte, level int) string {
+	name := "heading " + strconv.Itoa(level)
+	if id := resolveParagraphStyleID(stylesXML, name); id != name {
+		return id
+	}
+	return "Heading" + strconv.Itoa(level)
+}
+
+// registerOutlineFunction registers the outline() function
+func registerOutlineFunction(registry *DefaultFunctionRegistry) {
+	// outline() function - renders a document tree as headings and text
+	outlineFn := NewSimpleFunction("outline", 1, 2, buildOutline)
+	registry.RegisterFunction(outlineFn)
+}
@@ -0,0 +1,112 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOutline(t *testing.T) {
+	tree := []interface{}{
+		map[string]interface{}{
+			"title": "Scope",
+			"body":  "This policy applies to all staff.\n\nIt replaces the 2019 policy.",
+			"sections": []interface{}{
+				map[string]interface{}{"title": "Contractors", "body": []interface{}{"Contractors sign the NDA."}, "sections": []map[string]interface{}{
+					{"heading": "Exceptions", "body": "None."},
+				}},
+			},
+		},
+		map[string]interface{}{"title": "Review", "children": []interface{}{"Reviewed yearly."}},
+	}
+
+	result, err := buildOutline(tree)
+	if err != nil {
+		t.Fatalf("outline() error = %v", err)
+	}
+	content := result.(*OOXMLFragment).Content.(*outline)
+	want := []outlineEntry{
+		{text: "Scope", level: 1},
+		{text: "This policy applies to all staff."},
+		{text: "It replaces the 2019 policy."},
+		{text: "Contractors", level: 2},
+		{text: "Contractors sign the NDA."},
+		{text: "Exceptions", level: 3},
+		{text: "None."},
+		{text: "Review", level: 1},
+		{text: "Reviewed yearly."},
+	}
+	if len(content.entries) != len(want) {
+		t.Fatalf("got %+v, want %+v", content.entries, want)
+	}
+	for i := range want {
+		if content.entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, content.entries[i], want[i])
+		}
+	}
+
+	result, _ = buildOutline(map[string]interface{}{"title": "Annex"}, 2)
+	if entries := result.(*OOXMLFragment).Content.(*outline).entries; len(entries) != 1 || entries[0].level != 2 {
+		t.Errorf("start level 2 gave %+v", entries)
+	}
+	if result, err := buildOutline(nil); result != nil || err != nil {
+		t.Errorf("outline(nil) = %v, %v, want nothing", result, err)
+	}
+	if _, err := buildOutline(tree, 10); err == nil {
+		t.Error("expected an error for a start level beyond Heading9")
+	}
+	deep := map[string]interface{}{"title": "leaf"}
+	for i := 0; i < 9; i++ {
+		deep = map[string]interface{}{"title": "section", "sections": []interface{}{deep}}
+	}
+	if _, err := buildOutline(deep); err == nil || !strings.Contains(err.Error(), "heading level 9") {
+		t.Errorf("error = %v, want a depth error", err)
+	}
+}
+
+func TestHeadingStyleID(t *testing.T) {
+	styles := []byte(`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		`<w:style w:type="paragraph" w:styleId="berschrift1"><w:name w:val="heading 1"/></w:style>` +
+		`</w:styles>`)
+	if got := headingStyleID(styles, 1); got != "berschrift1" {
+		t.Errorf("headingStyleID(1) = %q, want berschrift1", got)
+	}
+	if got := headingStyleID(styles, 2); got != "Heading2" {
+		t.Errorf("headingStyleID(2) = %q, want Heading2", got)
+	}
+	if got := headingStyleID(nil, 3); got != "Heading3" {
+		t.Errorf("headingStyleID(3) without styles = %q, want Heading3", got)
+	}
+}
+
+func TestOutlineInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{outline(policy)}}`})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"policy": map[string]interface{}{
+			"title": "Data Retention",
+			"body":  "Keep records for ten years.",
+			"sections": []interface{}{
+				map[string]interface{}{"title": "Backups", "body": "Backups expire after 90 days."},
+			},
+		},
+	}))
+	for _, want := range []string{
+		`<w:pStyle w:val="Heading1"></w:pStyle>`,
+		`>Data Retention<`,
+		`>Keep records for ten years.<`,
+		`<w:pStyle w:val="Heading2"></w:pStyle>`,
+		`>Backups expire after 90 days.<`,
+	} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %s in output: %s", want, documentXML)
+		}
+	}
+	if strings.Contains(documentXML, "{{") {
+		t.Errorf("template markers left in output: %s", documentXML)
+	}
+}
@@ -0,0 +1,622 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const officeDocumentRelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+
+// RenderTransform names a step of the render pipeline that can introduce a
+// problem into the output package.
+type RenderTransform string
+
+const (
+	// TransformTemplate means the problem is already present in the template.
+	TransformTemplate RenderTransform = "template"
+	// TransformRender covers rendering the template's own parts.
+	TransformRender RenderTransform = "render"
+	// TransformFragmentInclude covers content, media and relationships copied
+	// from included DOCX fragments.
+	TransformFragmentInclude RenderTransform = "fragment include"
+	// TransformHeaderFooter covers parts written for header and footer fragments.
+	TransformHeaderFooter RenderTransform = "header/footer fragment"
+	// TransformDocVariables covers the parts written for RenderOptions.DocVariables.
+	TransformDocVariables RenderTransform = "document variables"
+)
+
+// RepairHint describes a problem found in a rendered package, the render step
+// that most likely introduced it and how to fix it.
+type RepairHint struct {
+	// Part is the package part the problem was found in.
+	Part string
+	// Problem describes what is wrong.
+	Problem string
+	// Transform is the render step that most likely introduced the problem.
+	Transform RenderTransform
+	// Hint suggests how to fix the problem.
+	Hint string
+	// Fatal reports whether Word is expected to refuse to open the file.
+	Fatal bool
+}
+
+func (h RepairHint) String() string {
+	return fmt.Sprintf("%s: %s (introduced by %s): %s", h.Part, h.Problem, h.Transform, h.Hint)
+}
+
+// PackageValidationError reports the problems found in a rendered DOCX
+// package when RenderOptions.ValidateOutput is set. Each problem names the
+// part it was found in; Hints holds the repair hint for each problem.
+type PackageValidationError struct {
+	Problems []string
+	Hints    []RepairHint
+}
+
+func (e *PackageValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid document package: " + e.Problems[0]
+	}
+	return fmt.Sprintf("invalid document package: %d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// packageProblemKind classifies a package problem for attribution and hints.
+type packageProblemKind int
+
+const (
+	problemMissingPart packageProblemKind = iota
+	problemMalformedXML
+	problemMissingContentType
+	problemMissingTarget
+	problemDuplicateRelationshipID
+	problemUndefinedRelationship
+	problemEmptyHeaderFooterRun
+	problemUnclosedBookmark
+	problemDuplicatePart
+	problemInvalidTableStructure
+	problemInvalidSectionProperties
+)
+
+var packageProblemHints = map[packageProblemKind]string{
+	problemMissingPart:              "the template is not a complete DOCX package; open and re-save it in Word",
+	problemMalformedXML:             "check values inserted with xml() or html() for unescaped or unbalanced markup",
+	problemMissingContentType:       "declare a Default for the extension or an Override for the part in [Content_Types].xml",
+	problemMissingTarget:            "remove the relationship or add the part it targets",
+	problemDuplicateRelationshipID:  "relationship IDs must be unique within a part; check relationships added after rendering",
+	problemUndefinedRelationship:    "content references a relationship that was not copied; include images and links from DOCX fragments rather than text fragments",
+	problemEmptyHeaderFooterRun:     "an expression or control structure in the header or footer rendered to nothing; move it so the whole run is removed",
+	problemUnclosedBookmark:         "a bookmark was split by a control structure; keep bookmark start and end inside the same {{if}} or {{for}} block",
+	problemDuplicatePart:            "the archive contains the same part twice; re-save the file in Word or rebuild the zip without duplicate entries",
+	problemInvalidTableStructure:    "every table needs a row, every row a cell and every cell a paragraph; check {{for}} loops and hideRow()/hideColumn() that empty a table",
+	problemInvalidSectionProperties: "the body's section properties must be its last child and page sizes must be positive; move content after the last section break or re-save the file in Word",
+}
+
+// packageProblem is a single problem found by the package validator.
+type packageProblem struct {
+	part    string
+	kind    packageProblemKind
+	message string
+	// relationshipID is set for problems concerning one relationship.
+	relationshipID string
+	// fatal problems fail validation; the others only produce repair hints.
+	fatal bool
+}
+
+func (p packageProblem) String() string {
+	return p.part + ": " + p.message
+}
+
+// packageValidator collects the problems found while checking a package.
+type packageValidator struct {
+	pkg      *docxPackage
+	problems []packageProblem
+}
+
+func (v *packageValidator) add(part string, kind packageProblemKind, format string, args ...interface{}) *packageProblem {
+	v.problems = append(v.problems, packageProblem{
+		part:    part,
+		kind:    kind,
+		message: fmt.Sprintf(format, args...),
+		fatal:   kind != problemEmptyHeaderFooterRun && kind != problemUnclosedBookmark,
+	})
+	return &v.problems[len(v.problems)-1]
+}
+
+// validateDocxPackage checks the structure of a DOCX package: required parts
+// are present, XML parts are well-formed, every part has a content type and
+// every internal relationship and relationship reference resolves. Every
+// problem, including known-bad patterns Word tolerates, is passed to report
+// as a repair hint. It returns a *PackageValidationError listing the problems
+// that would stop Word from opening the file.
+func validateDocxPackage(pkg *docxPackage, source []byte, report func(RepairHint)) error {
+	hints, err := diagnoseDocxPackage(pkg, source)
+	if err != nil {
+		return err
+	}
+
+	var validationErr *PackageValidationError
+	for _, hint := range hints {
+		if report != nil {
+			report(hint)
+		}
+		if hint.Fatal {
+			if validationErr == nil {
+				validationErr = &PackageValidationError{}
+			}
+			validationErr.Problems = append(validationErr.Problems, hint.Part+": "+hint.Problem)
+			validationErr.Hints = append(validationErr.Hints, hint)
+		}
+	}
+	if validationErr != nil {
+		return validationErr
+	}
+	return nil
+}
+
+// diagnoseDocxPackage validates pkg and returns a repair hint for every
+// problem found, including known-bad patterns that Word tolerates. When the
+// template source is given, problems are attributed to the transform that
+// introduced them by comparing against it.
+func diagnoseDocxPackage(pkg *docxPackage, source []byte) ([]RepairHint, error) {
+	problems := checkDocxPackage(pkg)
+	if len(problems) == 0 {
+		return nil, nil
+	}
+
+	var sourcePkg *docxPackage
+	sourceProblems := make(map[string]bool)
+	if len(source) > 0 {
+		var err error
+		if sourcePkg, err = readDocxPackage(source); err != nil {
+			return nil, fmt.Errorf("failed to read template package: %w", err)
+		}
+		for _, problem := range checkDocxPackage(sourcePkg) {
+			sourceProblems[problem.String()] = true
+		}
+	}
+
+	hints := make([]RepairHint, 0, len(problems))
+	for _, problem := range problems {
+		transform := TransformRender
+		if sourcePkg != nil {
+			transform = attributeProblem(problem, sourcePkg, sourceProblems)
+		}
+		hint := packageProblemHints[problem.kind]
+		if transform == TransformTemplate {
+			hint = "fix the template: " + hint
+		}
+		hints = append(hints, RepairHint{
+			Part:      problem.part,
+			Problem:   problem.message,
+			Transform: transform,
+			Hint:      hint,
+			Fatal:     problem.fatal,
+		})
+	}
+	return hints, nil
+}
+
+// attributeProblem returns the transform that most likely introduced a
+// problem, given the template package and the problems already present in it.
+func attributeProblem(problem packageProblem, source *docxPackage, sourceProblems map[string]bool) RenderTransform {
+	if sourceProblems[problem.String()] {
+		return TransformTemplate
+	}
+
+	part, relsPart := problem.part, relationshipsPartFor(problem.part)
+	if strings.HasSuffix(part, ".rels") {
+		relsPart = part
+		if sourcePart := relationshipSourcePart(part); sourcePart != "" {
+			part = sourcePart
+		}
+	}
+	switch part {
+	case "word/settings.xml", "docProps/custom.xml":
+		return TransformDocVariables
+	}
+
+	if _, inTemplate := source.get(part); !inTemplate {
+		if isHeaderFooterPart(part) {
+			return TransformHeaderFooter
+		}
+		return TransformFragmentInclude
+	}
+
+	// A relationship the template does not define was added while copying
+	// fragment relationships.
+	if problem.relationshipID != "" {
+		var ids map[string]bool
+		if content, ok := source.get(relsPart); ok {
+			ids = make(map[string]bool)
+			for _, rel := range parseRelationships(content) {
+				ids[rel.ID] = true
+			}
+		}
+		if !ids[problem.relationshipID] {
+			return TransformFragmentInclude
+		}
+	}
+	return TransformRender
+}
+
+// checkDocxPackage runs all package checks and returns the problems found in
+// part order.
+func checkDocxPackage(pkg *docxPackage) []packageProblem {
+	v := &packageValidator{pkg: pkg}
+
+	contentTypes := v.checkContentTypes()
+	v.checkMainDocument()
+	for _, name := range pkg.duplicates {
+		v.add(name, problemDuplicatePart, "part occurs more than once in the archive")
+	}
+
+	for _, name := range pkg.names {
+		if name == contentTypesPartName || strings.HasSuffix(name, "/") {
+			continue
+		}
+		if contentTypes != nil && !contentTypes.covers(name) {
+			v.add(name, problemMissingContentType, "no content type declared")
+		}
+		if !isXMLPartName(name) {
+			continue
+		}
+
+		scan, err := scanXMLPart(pkg.parts[name])
+		if err != nil {
+			v.add(name, problemMalformedXML, "malformed XML: %v", err)
+			continue
+		}
+		if strings.HasSuffix(name, ".rels") {
+			v.checkRelationships(name)
+			continue
+		}
+		v.checkRelationshipReferences(name, scan.references)
+		if scan.emptyRuns > 0 && isHeaderFooterPart(name) {
+			v.add(name, problemEmptyHeaderFooterRun, "%d empty run(s)", scan.emptyRuns)
+		}
+		for _, id := range scan.unclosedBookmarks {
+			v.add(name, problemUnclosedBookmark, "bookmark %s is never closed", id)
+		}
+		for _, element := range []string{"tbl", "tr", "tc"} {
+			if count := scan.emptyTableElements[element]; count > 0 {
+				v.add(name, problemInvalidTableStructure, "%d %s", count, emptyTableElementDescriptions[element])
+			}
+		}
+		for _, problem := range scan.sectionProblems {
+			v.add(name, problemInvalidSectionProperties, "%s", problem)
+		}
+	}
+
+	return v.problems
+}
+
+// packageContentTypes indexes the declarations of [Content_Types].xml.
+type packageContentTypes struct {
+	defaults  map[string]bool
+	overrides map[string]bool
+}
+
+func (c *packageContentTypes) covers(name string) bool {
+	if c.overrides["/"+name] {
+		return true
+	}
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	return c.defaults[strings.ToLower(ext)]
+}
+
+func (v *packageValidator) checkContentTypes() *packageContentTypes {
+	content, ok := v.pkg.get(contentTypesPartName)
+	if !ok {
+		v.add(contentTypesPartName, problemMissingPart, "required part is missing")
+		return nil
+	}
+
+	var parsed ContentTypes
+	if err := xml.Unmarshal(content, &parsed); err != nil {
+		v.add(contentTypesPartName, problemMalformedXML, "malformed XML: %v", err)
+		return nil
+	}
+
+	contentTypes := &packageContentTypes{
+		defaults:  make(map[string]bool, len(parsed.Defaults)),
+		overrides: make(map[string]bool, len(parsed.Overrides)),
+	}
+	for _, def := range parsed.Defaults {
+		contentTypes.defaults[strings.ToLower(def.Extension)] = true
+	}
+	for _, override := range parsed.Overrides {
+		contentTypes.overrides[override.PartName] = true
+		if _, exists := v.pkg.get(strings.TrimPrefix(override.PartName, "/")); !exists {
+			v.add(contentTypesPartName, problemMissingTarget, "content type override for missing part %s", override.PartName)
+		}
+	}
+	return contentTypes
+}
+
+func (v *packageValidator) checkMainDocument() {
+	content, ok := v.pkg.get(packageRelationshipsPart)
+	if !ok {
+		v.add(packageRelationshipsPart, problemMissingPart, "required part is missing")
+		return
+	}
+	for _, rel := range parseRelationships(content) {
+		if rel.Type == officeDocumentRelationshipType {
+			return
+		}
+	}
+	v.add(packageRelationshipsPart, problemMissingPart, "no main document relationship")
+}
+
+// checkRelationships verifies that a relationships part has unique IDs and
+// that its internal targets exist.
+func (v *packageValidator) checkRelationships(relsPart string) {
+	baseDir := relationshipSourceDir(relsPart)
+	seen := make(map[string]bool)
+	for _, rel := range parseRelationships(v.pkg.parts[relsPart]) {
+		if seen[rel.ID] {
+			v.add(relsPart, problemDuplicateRelationshipID, "duplicate relationship ID %s", rel.ID).relationshipID = rel.ID
+		}
+		seen[rel.ID] = true
+
+		if rel.TargetMode == "External" {
+			continue
+		}
+		target := resolveRelationshipTarget(baseDir, rel.Target)
+		if _, exists := v.pkg.get(target); !exists {
+			v.add(relsPart, problemMissingTarget, "relationship %s targets missing part %s", rel.ID, target).relationshipID = rel.ID
+		}
+	}
+}
+
+// checkRelationshipReferences verifies that every r:id style attribute in a
+// part names a relationship of that part.
+func (v *packageValidator) checkRelationshipReferences(name string, references []string) {
+	if len(references) == 0 {
+		return
+	}
+
+	ids := make(map[string]bool)
+	if content, ok := v.pkg.get(relationshipsPartFor(name)); ok {
+		for _, rel := range parseRelationships(content) {
+			ids[rel.ID] = true
+		}
+	}
+
+	reported := make(map[string]bool)
+	for _, id := range references {
+		if !ids[id] && !reported[id] {
+			reported[id] = true
+			v.add(name, problemUndefinedRelationship, "reference to undefined relationship %s", id).relationshipID = id
+		}
+	}
+}
+
+// xmlPartScan holds what scanXMLPart found in a part.
+type xmlPartScan struct {
+	// references are the values of attributes in the relationships
+	// namespace, such as r:id and r:embed.
+	references []string
+	// emptyRuns counts w:r elements without content other than w:rPr and
+	// empty w:t.
+	emptyRuns int
+	// unclosedBookmarks lists bookmarkStart IDs without a bookmarkEnd.
+	unclosedBookmarks []string
+	// emptyTableElements counts w:tbl elements without w:tr, w:tr without
+	// w:tc and w:tc without a paragraph or nested table, by local name.
+	emptyTableElements map[string]int
+	// sectionProblems describes invalid section properties of the body.
+	sectionProblems []string
+}
+
+// emptyTableElementDescriptions describes the empty table elements counted
+// by scanXMLPart.
+var emptyTableElementDescriptions = map[string]string{
+	"tbl": "table(s) without rows",
+	"tr":  "table row(s) without cells",
+	"tc":  "table cell(s) without a paragraph",
+}
+
+// requiredTableChildren lists the child elements of which a table element
+// needs at least one. Content controls and custom XML may wrap rows and
+// cells.
+var requiredTableChildren = map[string][]string{
+	"tbl": {"tr", "sdt", "customXml"},
+	"tr":  {"tc", "sdt", "customXml"},
+	"tc":  {"p", "tbl", "sdt", "customXml"},
+}
+
+// scanXMLPart checks that content is well-formed XML and collects the
+// relationship references and known-bad patterns in it.
+func scanXMLPart(content []byte) (*xmlPartScan, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	scan := &xmlPartScan{}
+
+	// runs tracks the open w:r elements: their element depth and whether they
+	// have content other than run properties and empty text.
+	type openRun struct {
+		depth   int
+		content bool
+	}
+	var runs []openRun
+	var openBookmarks []string
+	// tables tracks the open w:tbl, w:tr and w:tc elements and whether they
+	// have a required child.
+	type openTableElement struct {
+		local  string
+		depth  int
+		filled bool
+	}
+	var tables []openTableElement
+	bodyDepth := -1
+	bodySectPr := false
+	depth := 0
+	inRunText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			for _, attr := range t.Attr {
+				if attr.Name.Space == officeDocumentRelationshipsNamespace && attr.Value != "" {
+					scan.references = append(scan.references, attr.Value)
+				}
+			}
+			isWord := t.Name.Space == wordprocessingMLNamespace
+			if n := len(runs); n > 0 && depth == runs[n-1].depth+1 {
+				switch {
+				case isWord && t.Name.Local == "t":
+					inRunText = true
+				case !isWord || t.Name.Local != "rPr":
+					runs[n-1].content = true
+				}
+			}
+			if n := len(tables); n > 0 && depth == tables[n-1].depth+1 {
+				if slices.Contains(requiredTableChildren[tables[n-1].local], t.Name.Local) {
+					tables[n-1].filled = true
+				}
+			}
+			if !isWord {
+				continue
+			}
+			if depth == bodyDepth+1 {
+				if bodySectPr {
+					scan.sectionProblems = append(scan.sectionProblems, fmt.Sprintf("body section properties are followed by w:%s", t.Name.Local))
+				}
+				bodySectPr = t.Name.Local == "sectPr"
+			}
+			switch t.Name.Local {
+			case "body":
+				bodyDepth = depth
+			case "tbl", "tr", "tc":
+				tables = append(tables, openTableElement{local: t.Name.Local, depth: depth})
+			case "pgSz":
+				width, height := wordAttr(t, "w"), wordAttr(t, "h")
+				if !isValidPageMeasure(width) || !isValidPageMeasure(height) {
+					scan.sectionProblems = append(scan.sectionProblems, fmt.Sprintf("invalid page size %q x %q", width, height))
+				}
+			case "r":
+				runs = append(runs, openRun{depth: depth})
+			case "bookmarkStart":
+				openBookmarks = append(openBookmarks, wordAttr(t, "id"))
+			case "bookmarkEnd":
+				id := wordAttr(t, "id")
+				for i, open := range openBookmarks {
+					if open == id {
+						openBookmarks = append(openBookmarks[:i], openBookmarks[i+1:]...)
+						break
+					}
+				}
+			}
+		case xml.CharData:
+			if inRunText && len(t) > 0 {
+				runs[len(runs)-1].content = true
+			}
+		case xml.EndElement:
+			inRunText = false
+			if n := len(runs); n > 0 && depth == runs[n-1].depth {
+				if !runs[n-1].content {
+					scan.emptyRuns++
+				}
+				runs = runs[:n-1]
+			}
+			if n := len(tables); n > 0 && depth == tables[n-1].depth {
+				if !tables[n-1].filled {
+					if scan.emptyTableElements == nil {
+						scan.emptyTableElements = make(map[string]int)
+					}
+					scan.emptyTableElements[tables[n-1].local]++
+				}
+				tables = tables[:n-1]
+			}
+			if depth == bodyDepth {
+				bodyDepth = -1
+			}
+			depth--
+		}
+	}
+
+	scan.unclosedBookmarks = openBookmarks
+	return scan, nil
+}
+
+// isValidPageMeasure reports whether an optional page measure is absent or a
+// positive number.
+func isValidPageMeasure(value string) bool {
+	if value == "" {
+		return true
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	return err == nil && n > 0
+}
+
+// wordAttr returns the value of a w: attribute of an element.
+func wordAttr(start xml.StartElement, local string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == wordprocessingMLNamespace && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func isXMLPartName(name string) bool {
+	return strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".rels")
+}
+
+func isHeaderFooterPart(name string) bool {
+	base := path.Base(name)
+	return path.Dir(name) == "word" && (strings.HasPrefix(base, "header") || strings.HasPrefix(base, "footer"))
+}
+
+// relationshipSourceDir returns the directory that relative targets in a
+// relationships part resolve against, e.g. "word" for
+// "word/_rels/document.xml.rels".
+func relationshipSourceDir(relsPart string) string {
+	dir := path.Dir(path.Dir(relsPart))
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// relationshipSourcePart returns the part a relationships part belongs to,
+// e.g. "word/document.xml" for "word/_rels/document.xml.rels".
+func relationshipSourcePart(relsPart string) string {
+	name := strings.TrimSuffix(path.Base(relsPart), ".rels")
+	if dir := relationshipSourceDir(relsPart); dir != "" {
+		return dir + "/" + name
+	}
+	return name
+}
+
+// relationshipsPartFor returns the relationships part of a package part.
+func relationshipsPartFor(name string) string {
+	return path.Join(path.Dir(name), "_rels", path.Base(name)+".rels")
+}
+
+// resolveRelationshipTarget resolves a relationship target to a part name.
+func resolveRelationshipTarget(baseDir, target string) string {
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		target = target[:i]
+	}
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(path.Clean(target), "/")
+	}
+	return strings.TrimPrefix(path.Join("/", baseDir, target), "/")
+}
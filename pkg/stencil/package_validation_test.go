@@ -0,0 +1,262 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateOutputAcceptsRenderedPackage(t *testing.T) {
+	output := renderWithOptionsToBytes(t, createSimpleDOCX(t, "Hello {{name}}"), TemplateData{"name": "World"}, RenderOptions{
+		ValidateOutput: true,
+		DocVariables:   map[string]string{"Client": "Acme"},
+	})
+	if content := extractTextFromDOCX(t, output); content != "Hello World" {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestValidateOutputRejectsBrokenPackage(t *testing.T) {
+	docx := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:hyperlink r:id="rId9"><w:r><w:t>{{name}}</w:t></w:r></w:hyperlink></w:p></w:body></w:document>`),
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	if _, err := tmpl.RenderWithOptions(TemplateData{"name": "x"}, RenderOptions{}); err != nil {
+		t.Fatalf("expected render without validation to succeed, got %v", err)
+	}
+
+	_, err = tmpl.RenderWithOptions(TemplateData{"name": "x"}, RenderOptions{
+		ValidateOutput: true,
+		OnRepairHint:   func(RepairHint) {},
+	})
+	var validationErr *PackageValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected PackageValidationError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "word/document.xml: reference to undefined relationship rId9") {
+		t.Fatalf("expected undefined relationship problem, got %v", err)
+	}
+}
+
+func TestValidateDocxPackageProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(pkg *docxPackage)
+		want   string
+	}{
+		{
+			name:   "missing content types",
+			modify: func(pkg *docxPackage) { pkg.remove(contentTypesPartName) },
+			want:   "[Content_Types].xml: required part is missing",
+		},
+		{
+			name: "missing main document relationship",
+			modify: func(pkg *docxPackage) {
+				pkg.set(packageRelationshipsPart, []byte(`<Relationships xmlns="`+relationshipsNamespace+`"/>`))
+			},
+			want: "_rels/.rels: no main document relationship",
+		},
+		{
+			name:   "malformed XML",
+			modify: func(pkg *docxPackage) { pkg.set("word/document.xml", []byte(`<w:document><w:body></w:document>`)) },
+			want:   "word/document.xml: malformed XML",
+		},
+		{
+			name:   "part without content type",
+			modify: func(pkg *docxPackage) { pkg.set("word/media/image1.png", []byte("png")) },
+			want:   "word/media/image1.png: no content type declared",
+		},
+		{
+			name:   "missing relationship target",
+			modify: func(pkg *docxPackage) { pkg.remove("word/document.xml") },
+			want:   "_rels/.rels: relationship rId1 targets missing part word/document.xml",
+		},
+		{
+			name: "duplicate relationship ID",
+			modify: func(pkg *docxPackage) {
+				pkg.set(documentRelationshipsPart, []byte(`<Relationships xmlns="`+relationshipsNamespace+`">`+
+					`<Relationship Id="rId1" Type="t" Target="https://example.com" TargetMode="External"/>`+
+					`<Relationship Id="rId1" Type="t" Target="https://example.org" TargetMode="External"/>`+
+					`</Relationships>`))
+			},
+			want: "word/_rels/document.xml.rels: duplicate relationship ID rId1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, err := readDocxPackage(createSimpleDOCX(t, "Hello"))
+			if err != nil {
+				t.Fatalf("failed to read package: %v", err)
+			}
+			if err := validateDocxPackage(pkg, nil, nil); err != nil {
+				t.Fatalf("expected valid package before modification, got %v", err)
+			}
+
+			tt.modify(pkg)
+			err = validateDocxPackage(pkg, nil, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected problem %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestResolveRelationshipTarget(t *testing.T) {
+	for _, tt := range []struct{ baseDir, target, want string }{
+		{"word", "media/image1.png", "word/media/image1.png"},
+		{"word", "../customXml/item1.xml", "customXml/item1.xml"},
+		{"word", "/word/styles.xml", "word/styles.xml"},
+		{"", "word/document.xml", "word/document.xml"},
+		{"word", "media/my%20image.png", "word/media/my image.png"},
+	} {
+		if got := resolveRelationshipTarget(tt.baseDir, tt.target); got != tt.want {
+			t.Fatalf("resolveRelationshipTarget(%q, %q) = %q, want %q", tt.baseDir, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestValidateOutputRepairHints(t *testing.T) {
+	docx := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+			`<w:p><w:r><w:t>{{name}}</w:t></w:r></w:p>` +
+			`<w:sectPr><w:headerReference w:type="default" r:id="rId1"/></w:sectPr></w:body></w:document>`),
+		"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="` + headerRelationType + `" Target="header1.xml"/></Relationships>`),
+		"word/header1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:bookmarkStart w:id="0" w:name="Top"/><w:r><w:rPr><w:b/></w:rPr></w:r><w:r><w:t>Header</w:t></w:r></w:p></w:hdr>`),
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	var hints []RepairHint
+	_, err = tmpl.RenderWithOptions(TemplateData{"name": "x"}, RenderOptions{
+		ValidateOutput: true,
+		OnRepairHint:   func(hint RepairHint) { hints = append(hints, hint) },
+	})
+	if err != nil {
+		t.Fatalf("expected known-bad patterns not to fail validation, got %v", err)
+	}
+
+	want := map[string]bool{
+		"word/header1.xml: 1 empty run(s)":             false,
+		"word/header1.xml: bookmark 0 is never closed": false,
+	}
+	for _, hint := range hints {
+		key := hint.Part + ": " + hint.Problem
+		if _, ok := want[key]; !ok {
+			t.Fatalf("unexpected hint %s", hint)
+		}
+		want[key] = true
+		if hint.Fatal || hint.Transform != TransformTemplate || hint.Hint == "" {
+			t.Fatalf("expected non-fatal template hint with advice, got %+v", hint)
+		}
+	}
+	for key, seen := range want {
+		if !seen {
+			t.Fatalf("expected hint %q, got %v", key, hints)
+		}
+	}
+}
+
+func TestValidateOutputErrorCarriesHints(t *testing.T) {
+	docx := createMinimalDocx(map[string][]byte{
+		"word/document.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:hyperlink r:id="rId9"><w:r><w:t>link</w:t></w:r></w:hyperlink></w:p></w:body></w:document>`),
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	var buf bytes.Buffer
+	previous := GetLogger()
+	SetLogger(NewLogger(&buf, LogWarn))
+	defer SetLogger(previous)
+
+	_, err = tmpl.RenderWithOptions(TemplateData{}, RenderOptions{ValidateOutput: true})
+	var validationErr *PackageValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Hints) != 1 {
+		t.Fatalf("expected validation error with one hint, got %v", err)
+	}
+	if hint := validationErr.Hints[0]; !hint.Fatal || hint.Transform != TransformTemplate || !strings.HasPrefix(hint.Hint, "fix the template") {
+		t.Fatalf("unexpected hint %+v", hint)
+	}
+	if !strings.Contains(buf.String(), "repair hint: word/document.xml: reference to undefined relationship rId9 (introduced by template)") {
+		t.Fatalf("expected hint to be logged, got %q", buf.String())
+	}
+}
+
+func TestDiagnoseDocxPackageAttribution(t *testing.T) {
+	source := createSimpleDOCX(t, "Hello")
+
+	tests := []struct {
+		name   string
+		modify func(pkg *docxPackage)
+		want   RenderTransform
+	}{
+		{
+			name:   "rendered part",
+			modify: func(pkg *docxPackage) { pkg.set("word/document.xml", []byte(`<w:document>`)) },
+			want:   TransformRender,
+		},
+		{
+			name:   "fragment media",
+			modify: func(pkg *docxPackage) { pkg.set("word/media/image1.png", []byte("png")) },
+			want:   TransformFragmentInclude,
+		},
+		{
+			name: "fragment relationship",
+			modify: func(pkg *docxPackage) {
+				pkg.set(documentRelationshipsPart, []byte(`<Relationships xmlns="`+relationshipsNamespace+`">`+
+					`<Relationship Id="rId7" Type="t" Target="media/missing.png"/></Relationships>`))
+			},
+			want: TransformFragmentInclude,
+		},
+		{
+			name:   "header part",
+			modify: func(pkg *docxPackage) { pkg.set("word/header1.xml", []byte(`<w:hdr>`)) },
+			want:   TransformHeaderFooter,
+		},
+		{
+			name:   "settings part",
+			modify: func(pkg *docxPackage) { pkg.set("word/settings.xml", []byte(`<w:settings>`)) },
+			want:   TransformDocVariables,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, err := readDocxPackage(source)
+			if err != nil {
+				t.Fatalf("failed to read package: %v", err)
+			}
+			tt.modify(pkg)
+
+			hints, err := diagnoseDocxPackage(pkg, source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(hints) == 0 {
+				t.Fatalf("expected a repair hint")
+			}
+			for _, hint := range hints {
+				if hint.Transform != tt.want {
+					t.Fatalf("expected %s, got %s for %s", tt.want, hint.Transform, hint)
+				}
+			}
+		})
+	}
+}
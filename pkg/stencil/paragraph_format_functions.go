@@ -0,0 +1,348 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParagraphMarker represents a marker that sets a property of the output
+// paragraph containing it. Markers are resolved after rendering, so they
+// apply to each paragraph a loop produces.
+type ParagraphMarker struct {
+	Property string // "align", "spacingBefore", "spacingAfter", "indent", "shading" or "border"
+	Value    string // alignment value, length in twips, fill color or border as sides,style,size,color
+}
+
+// String returns the string representation of the marker for rendering
+func (m ParagraphMarker) String() string {
+	return fmt.Sprintf("{{PARAGRAPH_MARKER:%s:%s}}", m.Property, m.Value)
+}
+
+var paragraphMarkerRegex = regexp.MustCompile(`\{\{PARAGRAPH_MARKER:([A-Za-z]+):(-?[A-Za-z0-9]+(?:,[A-Za-z0-9]+)*)\}\}`)
+
+// paragraphBorderSides maps the sides of paragraphBorder() to the border
+// elements they set, in the schema order of w:pBdr.
+var paragraphBorderSides = map[string][]string{
+	"box":    {"top", "left", "bottom", "right"},
+	"top":    {"top"},
+	"left":   {"left"},
+	"bottom": {"bottom"},
+	"right":  {"right"},
+}
+
+// paragraphBorderOrder is the schema order of the elements of w:pBdr.
+var paragraphBorderOrder = []string{"top", "left", "bottom", "right", "between", "bar"}
+
+// paragraphBorderStyles are the border styles paragraphBorder() accepts.
+var paragraphBorderStyles = map[string]bool{
+	"single": true, "double": true, "thick": true, "dotted": true, "dashed": true,
+	"dotDash": true, "wave": true, "none": true,
+}
+
+// paragraphBorderElementRegex matches an element of w:pBdr.
+var paragraphBorderElementRegex = regexp.MustCompile(`<w:(top|left|bottom|right|between|bar)\b[^>]*?(?:/>|></w:(?:top|left|bottom|right|between|bar)>)`)
+
+const (
+	// defaultParagraphBorderSize is the border width paragraphBorder()
+	// uses, in eighths of a point, and the bounds Word accepts.
+	defaultParagraphBorderSize = 4
+	minParagraphBorderSize     = 2
+	maxParagraphBorderSize     = 96
+)
+
+// paragraphLengthUnits maps length units to twips, the unit of paragraph
+// spacing and indentation in OOXML.
+var paragraphLengthUnits = map[string]float64{
+	"pt": 20,
+	"cm": 1440 / 2.54,
+	"mm": 144 / 2.54,
+	"in": 1440,
+}
+
+// alignParagraph sets the alignment of the containing paragraph.
+func alignParagraph(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	value, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("align: alignment must be a string, got %T", args[0])
+	}
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "left", "center", "right", "both", "start", "end", "distribute":
+	case "justify":
+		value = "both"
+	default:
+		return nil, fmt.Errorf("align: invalid alignment '%s' (must be 'left', 'center', 'right', 'justify', 'start', 'end' or 'distribute')", value)
+	}
+	return &ParagraphMarker{Property: "align", Value: value}, nil
+}
+
+// paragraphLengthFunction returns a function that sets a paragraph length
+// property. Negative lengths are only accepted when allowNegative is set.
+func paragraphLengthFunction(name string, allowNegative bool) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return "", nil
+		}
+		twips, err := parseParagraphLength(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if twips < 0 && !allowNegative {
+			return nil, fmt.Errorf("%s: length must not be negative, got %v", name, args[0])
+		}
+		return &ParagraphMarker{Property: name, Value: strconv.Itoa(twips)}, nil
+	}
+}
+
+// parseParagraphLength converts a length to twips. Numbers are points;
+// strings may carry a pt, cm, mm or in unit, such as "1.25cm".
+func parseParagraphLength(value interface{}) (int, error) {
+	if s, ok := value.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+		factor := paragraphLengthUnits["pt"]
+		for unit, unitFactor := range paragraphLengthUnits {
+			if strings.HasSuffix(s, unit) {
+				s = strings.TrimSpace(strings.TrimSuffix(s, unit))
+				factor = unitFactor
+				break
+			}
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid length %q (use a number of points or a value such as \"12pt\", \"1.25cm\", \"10mm\" or \"0.5in\")", value)
+		}
+		return int(math.Round(n * factor)), nil
+	}
+
+	n, ok := toFloat64(value)
+	if !ok {
+		return 0, fmt.Errorf("length must be a number or a string, got %T", value)
+	}
+	return int(math.Round(n * paragraphLengthUnits["pt"])), nil
+}
+
+// paragraphShading sets the background color of the containing paragraph.
+func paragraphShading(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	value, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("paragraphShading: color must be a string, got %T", args[0])
+	}
+	color, ok := normalizeHTMLColor(value)
+	if !ok {
+		return nil, fmt.Errorf("paragraphShading: invalid color %q (use a hex color such as \"#F2F2F2\")", value)
+	}
+	return &ParagraphMarker{Property: "shading", Value: color}, nil
+}
+
+// paragraphBorder sets a border on sides of the containing paragraph:
+// paragraphBorder(sides, style[, size[, color]]). The size is in eighths of
+// a point.
+func paragraphBorder(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	sides, ok := args[0].(string)
+	if _, known := paragraphBorderSides[sides]; !ok || !known {
+		return nil, fmt.Errorf("paragraphBorder: invalid sides %v (must be 'box', 'top', 'bottom', 'left' or 'right')", args[0])
+	}
+	style, ok := args[1].(string)
+	if !ok || !paragraphBorderStyles[style] {
+		return nil, fmt.Errorf("paragraphBorder: invalid style %v (must be 'single', 'double', 'thick', 'dotted', 'dashed', 'dotDash', 'wave' or 'none')", args[1])
+	}
+	size := defaultParagraphBorderSize
+	if len(args) > 2 && args[2] != nil {
+		size, ok = toInt(args[2])
+		if !ok || size < minParagraphBorderSize || size > maxParagraphBorderSize {
+			return nil, fmt.Errorf("paragraphBorder: size must be an integer from %d to %d eighths of a point, got %v", minParagraphBorderSize, maxParagraphBorderSize, args[2])
+		}
+	}
+	color := "auto"
+	if len(args) > 3 && args[3] != nil {
+		value, _ := args[3].(string)
+		if color, ok = normalizeHTMLColor(value); !ok {
+			return nil, fmt.Errorf("paragraphBorder: invalid color %v", args[3])
+		}
+	}
+	return &ParagraphMarker{Property: "border", Value: fmt.Sprintf("%s,%s,%d,%s", sides, style, size, color)}, nil
+}
+
+// registerParagraphFormatFunctions registers the paragraph override functions
+func registerParagraphFormatFunctions(registry *DefaultFunctionRegistry) {
+	registry.RegisterFunction(NewSimpleFunction("align", 1, 1, alignParagraph))
+	registry.RegisterFunction(NewSimpleFunction("spacingBefore", 1, 1, paragraphLengthFunction("spacingBefore", false)))
+	registry.RegisterFunction(NewSimpleFunction("spacingAfter", 1, 1, paragraphLengthFunction("spacingAfter", false)))
+	registry.RegisterFunction(NewSimpleFunction("indent", 1, 1, paragraphLengthFunction("indent", true)))
+	registry.RegisterFunction(NewSimpleFunction("paragraphShading", 1, 1, paragraphShading))
+	registry.RegisterFunction(NewSimpleFunction("paragraphBorder", 2, 4, paragraphBorder))
+}
+
+// applyParagraphMarkers removes paragraph markers from the runs of a rendered
+// paragraph and applies them to its properties. Later markers win. The
+// properties may be shared with the template, so they are copied before
+// modification.
+func applyParagraphMarkers(para *Paragraph) {
+	if para == nil {
+		return
+	}
+
+	// Content holds its own copies of the runs and is what gets encoded when
+	// present, so its markers are the ones applied.
+	var markers []ParagraphMarker
+	var content []ParagraphContent
+	for _, item := range para.Content {
+		if run, ok := item.(*Run); ok {
+			found := stripParagraphMarkers(run)
+			markers = append(markers, found...)
+			if len(found) > 0 && run.Text.Content == "" && run.Break == nil && len(run.RawXML) == 0 {
+				continue
+			}
+		} else if link, ok := item.(*Hyperlink); ok {
+			for i := range link.Runs {
+				markers = append(markers, stripParagraphMarkers(&link.Runs[i])...)
+			}
+		}
+		content = append(content, item)
+	}
+	if para.Content != nil {
+		para.Content = content
+	}
+
+	var legacyMarkers []ParagraphMarker
+	for i := range para.Runs {
+		legacyMarkers = append(legacyMarkers, stripParagraphMarkers(&para.Runs[i])...)
+	}
+	for i := range para.Hyperlinks {
+		for j := range para.Hyperlinks[i].Runs {
+			legacyMarkers = append(legacyMarkers, stripParagraphMarkers(&para.Hyperlinks[i].Runs[j])...)
+		}
+	}
+	if len(para.Content) == 0 {
+		markers = legacyMarkers
+	}
+	if len(markers) == 0 {
+		return
+	}
+
+	props := &ParagraphProperties{}
+	if para.Properties != nil {
+		copied := *para.Properties
+		props = &copied
+	}
+	for _, marker := range markers {
+		applyParagraphMarker(props, marker)
+	}
+	para.Properties = props
+}
+
+// stripParagraphMarkers removes the paragraph markers from the text of run
+// and returns them.
+func stripParagraphMarkers(run *Run) []ParagraphMarker {
+	if run.Text == nil || !strings.Contains(run.Text.Content, "{{PARAGRAPH_MARKER:") {
+		return nil
+	}
+	var markers []ParagraphMarker
+	for _, match := range paragraphMarkerRegex.FindAllStringSubmatch(run.Text.Content, -1) {
+		markers = append(markers, ParagraphMarker{Property: match[1], Value: match[2]})
+	}
+	text := *run.Text
+	text.Content = paragraphMarkerRegex.ReplaceAllString(text.Content, "")
+	run.Text = &text
+	return markers
+}
+
+// applyParagraphMarker sets the property named by marker.
+func applyParagraphMarker(props *ParagraphProperties, marker ParagraphMarker) {
+	switch marker.Property {
+	case "align":
+		props.Alignment = &Alignment{Val: marker.Value}
+		return
+	case "shading":
+		setParagraphRawProperty(props, "shd", `<w:shd w:val="clear" w:color="auto" w:fill="`+marker.Value+`"/>`)
+		return
+	case "border":
+		applyParagraphBorder(props, marker.Value)
+		return
+	}
+
+	twips, err := strconv.Atoi(marker.Value)
+	if err != nil {
+		return
+	}
+	switch marker.Property {
+	case "spacingBefore", "spacingAfter":
+		spacing := &Spacing{}
+		if props.Spacing != nil {
+			copied := *props.Spacing
+			spacing = &copied
+		}
+		if marker.Property == "spacingBefore" {
+			spacing.Before = twips
+		} else {
+			spacing.After = twips
+		}
+		props.Spacing = spacing
+	case "indent":
+		ind := &Indentation{}
+		if props.Indentation != nil {
+			copied := *props.Indentation
+			ind = &copied
+		}
+		ind.Left = strconv.Itoa(twips)
+		ind.Start = ""
+		props.Indentation = ind
+	}
+}
+
+// applyParagraphBorder sets the sides of the paragraph border described by
+// value, as rendered by paragraphBorder(), keeping the other sides.
+func applyParagraphBorder(props *ParagraphProperties, value string) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return
+	}
+	elements := make(map[string]string)
+	for _, raw := range props.RawXML {
+		if raw.XMLName.Local != "pBdr" {
+			continue
+		}
+		for _, match := range paragraphBorderElementRegex.FindAllStringSubmatch(convertNamespaceURIsToPrefix(string(raw.Content)), -1) {
+			elements[match[1]] = match[0]
+		}
+	}
+	for _, side := range paragraphBorderSides[parts[0]] {
+		elements[side] = fmt.Sprintf(`<w:%s w:val="%s" w:sz="%s" w:space="1" w:color="%s"/>`, side, parts[1], parts[2], parts[3])
+	}
+
+	var pBdr strings.Builder
+	pBdr.WriteString("<w:pBdr>")
+	for _, side := range paragraphBorderOrder {
+		pBdr.WriteString(elements[side])
+	}
+	pBdr.WriteString("</w:pBdr>")
+	setParagraphRawProperty(props, "pBdr", pBdr.String())
+}
+
+// setParagraphRawProperty replaces or adds the paragraph property stored as
+// raw XML under name. The raw XML may be shared with the template, so it is
+// copied before modification.
+func setParagraphRawProperty(props *ParagraphProperties, name, content string) {
+	raws := append([]RawXMLElement(nil), props.RawXML...)
+	element := RawXMLElement{XMLName: xml.Name{Local: name}, Content: []byte(content)}
+	for i := range raws {
+		if raws[i].XMLName.Local == name {
+			raws[i] = element
+			props.RawXML = raws
+			return
+		}
+	}
+	props.RawXML = append(raws, element)
+}
@@ -0,0 +1,140 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParagraphFormatFunctions(t *testing.T) {
+	registry := GetDefaultFunctionRegistry()
+
+	tests := []struct {
+		name string
+		args []interface{}
+		want interface{}
+	}{
+		{name: "align", args: []interface{}{"center"}, want: &ParagraphMarker{Property: "align", Value: "center"}},
+		{name: "align", args: []interface{}{"Justify"}, want: &ParagraphMarker{Property: "align", Value: "both"}},
+		{name: "align", args: []interface{}{nil}, want: ""},
+		{name: "spacingBefore", args: []interface{}{12}, want: &ParagraphMarker{Property: "spacingBefore", Value: "240"}},
+		{name: "spacingAfter", args: []interface{}{"6pt"}, want: &ParagraphMarker{Property: "spacingAfter", Value: "120"}},
+		{name: "indent", args: []interface{}{"1.25cm"}, want: &ParagraphMarker{Property: "indent", Value: "709"}},
+		{name: "indent", args: []interface{}{"0.5in"}, want: &ParagraphMarker{Property: "indent", Value: "720"}},
+		{name: "indent", args: []interface{}{"-10mm"}, want: &ParagraphMarker{Property: "indent", Value: "-567"}},
+		{name: "paragraphShading", args: []interface{}{"#f2f2f2"}, want: &ParagraphMarker{Property: "shading", Value: "F2F2F2"}},
+		{name: "paragraphShading", args: []interface{}{nil}, want: ""},
+		{name: "paragraphBorder", args: []interface{}{"box", "single", 4}, want: &ParagraphMarker{Property: "border", Value: "box,single,4,auto"}},
+		{name: "paragraphBorder", args: []interface{}{"left", "thick", 24, "#C00000"}, want: &ParagraphMarker{Property: "border", Value: "left,thick,24,C00000"}},
+	}
+
+	for _, tt := range tests {
+		fn, exists := registry.GetFunction(tt.name)
+		if !exists {
+			t.Fatalf("%s function not found in registry", tt.name)
+		}
+		got, err := fn.Call(tt.args...)
+		if err != nil {
+			t.Fatalf("%s(%v) error = %v", tt.name, tt.args, err)
+		}
+		if marker, ok := tt.want.(*ParagraphMarker); ok {
+			if gotMarker, ok := got.(*ParagraphMarker); !ok || *gotMarker != *marker {
+				t.Errorf("%s(%v) = %#v, want %#v", tt.name, tt.args, got, marker)
+			}
+		} else if got != tt.want {
+			t.Errorf("%s(%v) = %#v, want %#v", tt.name, tt.args, got, tt.want)
+		}
+	}
+
+	errorTests := []struct {
+		name    string
+		args    []interface{}
+		wantErr string
+	}{
+		{name: "align", args: []interface{}{"middle"}, wantErr: "invalid alignment"},
+		{name: "align", args: []interface{}{1}, wantErr: "alignment must be a string"},
+		{name: "spacingBefore", args: []interface{}{-1}, wantErr: "must not be negative"},
+		{name: "indent", args: []interface{}{"2em"}, wantErr: "invalid length"},
+		{name: "paragraphShading", args: []interface{}{"light"}, wantErr: "invalid color"},
+		{name: "paragraphBorder", args: []interface{}{"all", "single"}, wantErr: "invalid sides"},
+		{name: "paragraphBorder", args: []interface{}{"box", "groove"}, wantErr: "invalid style"},
+		{name: "paragraphBorder", args: []interface{}{"box", "single", 200}, wantErr: "size must be an integer from 2 to 96"},
+	}
+	for _, tt := range errorTests {
+		fn, _ := registry.GetFunction(tt.name)
+		if _, err := fn.Call(tt.args...); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s(%v) error = %v, want %q", tt.name, tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParagraphFormatInTemplate(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+<w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p>
+<w:p><w:pPr><w:spacing w:after="80"/></w:pPr><w:r><w:t xml:space="preserve">{{align(item.align)}}{{spacingBefore(item.gap)}}{{item.name}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>{{indent("1.25cm")}}cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`),
+		"word/header1.xml": validationHeaderXML(`<w:p><w:r><w:t>{{align("right")}}</w:t></w:r><w:r><w:t>Header</w:t></w:r></w:p>`),
+	})
+	data := TemplateData{
+		"items": []interface{}{
+			map[string]interface{}{"name": "First", "align": "center", "gap": 12},
+			map[string]interface{}{"name": "Second", "align": nil, "gap": nil},
+		},
+	}
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	if strings.Contains(documentXML, "PARAGRAPH_MARKER") {
+		t.Fatalf("paragraph markers left in output: %s", documentXML)
+	}
+	assertInOrder(t, documentXML,
+		`<w:jc w:val="center"></w:jc><w:spacing w:before="240" w:after="80"></w:spacing>`,
+		`First`,
+		`<w:spacing w:after="80"></w:spacing></w:pPr>`,
+		`Second`,
+		`<w:ind w:left="709"></w:ind>`,
+		`cell`,
+	)
+
+	header := extractPartFromDOCX(t, output, "word/header1.xml")
+	if strings.Contains(header, "PARAGRAPH_MARKER") {
+		t.Fatalf("paragraph markers left in header: %s", header)
+	}
+	assertInOrder(t, header, `<w:jc w:val="right"`, `Header`)
+
+	// The template paragraph must not be modified by a render.
+	output = renderWithOptionsToBytes(t, docx, TemplateData{"items": []interface{}{map[string]interface{}{"name": "Plain"}}}, RenderOptions{})
+	if documentXML := extractDocumentXMLFromDOCX(t, output); strings.Contains(documentXML, "w:jc") {
+		t.Errorf("expected no alignment without align(): %s", documentXML)
+	}
+}
+
+func TestParagraphShadingAndBorderInTemplate(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+<w:p><w:r><w:t>{{for n in notices}}</w:t></w:r></w:p>
+<w:p><w:pPr><w:pBdr><w:bottom w:val="double" w:sz="6" w:space="1" w:color="auto"/></w:pBdr></w:pPr><w:r><w:t xml:space="preserve">{{paragraphShading(n.fill)}}{{paragraphBorder("left", "thick", 24, n.color)}}{{n.text}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`),
+	})
+	data := TemplateData{
+		"notices": []interface{}{
+			map[string]interface{}{"text": "Warning", "fill": "#FFF4CE", "color": "#C00000"},
+			map[string]interface{}{"text": "Note", "fill": nil, "color": nil},
+		},
+	}
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	if strings.Contains(documentXML, "PARAGRAPH_MARKER") {
+		t.Fatalf("paragraph markers left in output: %s", documentXML)
+	}
+	assertInOrder(t, documentXML,
+		`<w:pBdr><w:left w:val="thick" w:sz="24" w:space="1" w:color="C00000"/><w:bottom w:val="double" w:sz="6" w:space="1" w:color="auto"`,
+		`<w:shd w:val="clear" w:color="auto" w:fill="FFF4CE"/>`,
+		`Warning`,
+		`<w:pBdr><w:left w:val="thick" w:sz="24" w:space="1" w:color="auto"/>`,
+		`Note`,
+	)
+	if got := strings.Count(documentXML, "<w:shd "); got != 1 {
+		t.Errorf("got %d shaded paragraphs, want 1: %s", got, documentXML)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func BenchmarkMarshalDocumentWithNamespaces_RawXML(b *testing.B) {
@@ -169,12 +170,12 @@ func BenchmarkRenderHeaderOrFooter_Template(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		content, err := renderHeaderOrFooter(headerPart, data, &renderContext{})
+		content, err := renderStoryPart(headerPart, data, &renderContext{})
 		if err != nil {
-			b.Fatalf("renderHeaderOrFooter failed: %v", err)
+			b.Fatalf("renderStoryPart failed: %v", err)
 		}
 		if len(content) == 0 {
-			b.Fatal("renderHeaderOrFooter returned empty content")
+			b.Fatal("renderStoryPart returned empty content")
 		}
 	}
 }
@@ -233,3 +234,15 @@ func BenchmarkRenderProductionShape_Synthetic(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkFormatValue(b *testing.B) {
+	values := []interface{}{"text", 42, 123456789, int64(-7), 3.14159, float32(2.5), true, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, value := range values {
+			_ = FormatValue(value)
+		}
+	}
+}
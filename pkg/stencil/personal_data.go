@@ -0,0 +1,133 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PersonalDataOptions makes a render report which personal data fields it
+// embedded into the document and where, to support data subject access
+// requests and retention tooling.
+type PersonalDataOptions struct {
+	// Fields names the personal data fields with the patterns of
+	// ScrubPolicy.Fields, such as the SensitiveFields of a
+	// ValidationSchema.
+	Fields []string
+	// OnReport receives the report of the render once the document is
+	// rendered. It is not called when the render fails.
+	OnReport func(PersonalDataReport)
+}
+
+// PersonalDataReport lists the personal data fields found in a rendered
+// document.
+type PersonalDataReport struct {
+	// Fields lists the fields whose values appear in the document, sorted
+	// by path. Fields that are not embedded are not listed.
+	Fields []PersonalDataField
+}
+
+// PersonalDataField is a personal data field embedded into a document.
+type PersonalDataField struct {
+	// Path is the path of the field in the render data, with list indexes,
+	// such as "customer.email" or "orders[1].card".
+	Path string
+	// Locations lists where the value of the field appears.
+	Locations []PersonalDataLocation
+}
+
+// PersonalDataLocation is a paragraph of a document part.
+type PersonalDataLocation struct {
+	// Part is the name of the part, such as "word/document.xml" or
+	// "word/header1.xml".
+	Part string
+	// Paragraph is the number of the paragraph in the part, counting from
+	// 1 in document order and including the paragraphs of tables.
+	Paragraph int
+}
+
+// reportPersonalData finds the values of the personal data fields of data
+// in the paragraphs of a rendered package and passes the report to the
+// OnReport callback of opts. Values are found by their text, so a field
+// is only reported where its value appears as given in the data, not
+// where a function transformed it.
+func reportPersonalData(pkg *docxPackage, data TemplateData, opts *PersonalDataOptions) error {
+	if opts == nil || opts.OnReport == nil {
+		return nil
+	}
+
+	values := (&ScrubPolicy{Fields: opts.Fields}).sensitiveFieldValues(data)
+	locations := make(map[string][]PersonalDataLocation)
+	if len(values) > 0 {
+		for _, name := range pkg.names {
+			if name != "word/document.xml" && !isStoryPartName(name) {
+				continue
+			}
+			paragraphs, err := partParagraphTexts(pkg.parts[name])
+			if err != nil {
+				return NewDocumentError("read", name, err)
+			}
+			for i, text := range paragraphs {
+				for _, value := range values {
+					if strings.Contains(text, value.text) {
+						locations[value.path] = append(locations[value.path], PersonalDataLocation{Part: name, Paragraph: i + 1})
+					}
+				}
+			}
+		}
+	}
+
+	report := PersonalDataReport{Fields: make([]PersonalDataField, 0, len(locations))}
+	for path, found := range locations {
+		report.Fields = append(report.Fields, PersonalDataField{Path: path, Locations: found})
+	}
+	sort.Slice(report.Fields, func(i, j int) bool {
+		return report.Fields[i].Path < report.Fields[j].Path
+	})
+	opts.OnReport(report)
+	return nil
+}
+
+// partParagraphTexts returns the text of the paragraphs of a WordprocessingML
+// part in document order. The text of a paragraph nested in another, such
+// as in a text box, belongs to the nested paragraph only.
+func partParagraphTexts(content []byte) ([]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var paragraphs []string
+	var open []int
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return paragraphs, nil
+			}
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				open = append(open, len(paragraphs))
+				paragraphs = append(paragraphs, "")
+			case "t":
+				inText = len(open) > 0
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				if len(open) > 0 {
+					open = open[:len(open)-1]
+				}
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				paragraphs[open[len(open)-1]] += string(t)
+			}
+		}
+	}
+}
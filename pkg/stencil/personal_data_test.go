@@ -0,0 +1,72 @@
+package stencil
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPersonalDataReport(t *testing.T) {
+	tmpl, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		"Dear {{customer.name}},",
+		"We will contact you at {{customer.email}}.",
+		"{{for order in orders}}Order {{order.id}} paid with {{order.card}}{{end}}",
+		"Your reference: {{uppercase(customer.reference)}}",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+
+	data := TemplateData{
+		"customer": map[string]interface{}{
+			"name":      "Ada Lovelace",
+			"email":     "ada@example.com",
+			"phone":     "+1 555 0100",
+			"reference": "abc-123",
+		},
+		"orders": []interface{}{
+			map[string]interface{}{"id": 1, "card": "4111 1111"},
+			map[string]interface{}{"id": 2, "card": "5500 0000"},
+		},
+	}
+	var report *PersonalDataReport
+	_, err = tmpl.RenderWithOptions(data, RenderOptions{PersonalData: &PersonalDataOptions{
+		Fields:   []string{"customer.name", "customer.email", "customer.phone", "customer.reference", "orders.card"},
+		OnReport: func(r PersonalDataReport) { report = &r },
+	}})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if report == nil {
+		t.Fatal("expected a report")
+	}
+
+	// The phone number is not in the document and the reference is only
+	// embedded in upper case.
+	want := []PersonalDataField{
+		{Path: "customer.email", Locations: []PersonalDataLocation{{Part: "word/document.xml", Paragraph: 2}}},
+		{Path: "customer.name", Locations: []PersonalDataLocation{{Part: "word/document.xml", Paragraph: 1}}},
+		{Path: "orders[0].card", Locations: []PersonalDataLocation{{Part: "word/document.xml", Paragraph: 3}}},
+		{Path: "orders[1].card", Locations: []PersonalDataLocation{{Part: "word/document.xml", Paragraph: 3}}},
+	}
+	if !reflect.DeepEqual(report.Fields, want) {
+		t.Errorf("report = %+v, want %+v", report.Fields, want)
+	}
+}
+
+func TestPartParagraphTexts(t *testing.T) {
+	content := []byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>Hello </w:t></w:r><w:r><w:t>World</w:t></w:r></w:p>` +
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+		`<w:p><w:r><w:t>Before</w:t></w:r><w:r><w:txbxContent><w:p><w:r><w:t>Box</w:t></w:r></w:p></w:txbxContent></w:r><w:r><w:t> after</w:t></w:r></w:p>` +
+		`</w:body></w:document>`)
+	got, err := partParagraphTexts(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Hello World", "Cell", "Before after", "Box"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("partParagraphTexts() = %q, want %q", got, want)
+	}
+}
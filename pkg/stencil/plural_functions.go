@@ -0,0 +1,192 @@
+package stencil
+
+import (
+	"fmt"
+	"math"
+)
+
+// PluralForms are the forms of a word or phrase that pluralize() chooses
+// from by the plural category of a count in a language, such as "item" and
+// "items" in English or "Datei", "Dateien" in German. Languages use the
+// categories they need: English and German One and Other, Russian and
+// Polish also Few and Many. A category left empty uses Other. Zero, if
+// set, is used for a count of 0 in any language, as in "no items".
+type PluralForms struct {
+	Zero  string
+	One   string
+	Few   string
+	Many  string
+	Other string
+}
+
+// RegisterPlurals adds the plural forms of words for locale, such as "de"
+// or "pt-BR", to the catalog pluralize() reads, by key. An empty locale
+// registers the default forms, used when no forms of the render locale
+// have the key. Registering a key of a locale again replaces its forms.
+//
+// Example:
+//
+//	engine.RegisterPlurals("en", map[string]stencil.PluralForms{
+//	    "file": {One: "file", Other: "files"},
+//	})
+//	engine.RegisterPlurals("ru", map[string]stencil.PluralForms{
+//	    "file": {One: "файл", Few: "файла", Many: "файлов", Other: "файла"},
+//	})
+//
+// Templates use them as {{count}} {{pluralize(count, "file")}}.
+func (e *Engine) RegisterPlurals(locale string, forms map[string]PluralForms) {
+	e.data.addPlurals(locale, forms)
+}
+
+// addPlurals adds forms to the plural catalog. The maps are replaced rather
+// than modified, so renders in progress keep their view.
+func (d *engineData) addPlurals(locale string, forms map[string]PluralForms) {
+	locale = normalizeLocale(locale)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	catalog := make(map[string]map[string]PluralForms, len(d.plurals)+1)
+	for existing, words := range d.plurals {
+		catalog[existing] = words
+	}
+	words := make(map[string]PluralForms, len(catalog[locale])+len(forms))
+	for key, form := range catalog[locale] {
+		words[key] = form
+	}
+	for key, form := range forms {
+		words[key] = form
+	}
+	catalog[locale] = words
+	d.plurals = catalog
+}
+
+// pluralCatalog returns the registered plural forms by normalized locale
+// and key. The maps must not be modified.
+func (d *engineData) pluralCatalog() map[string]map[string]PluralForms {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.plurals
+}
+
+// pluralCategory returns the plural category of count in the language of
+// locale, following the cardinal rules of the Unicode CLDR for the
+// languages that need more than one and other.
+func pluralCategory(count float64, locale string) string {
+	n := math.Abs(count)
+	integer := n == math.Trunc(n)
+	i := int64(n)
+	mod10, mod100 := i%10, i%100
+
+	switch localeLanguage(locale) {
+	case "ja", "zh", "ko", "th", "vi", "id", "ms":
+		return "other"
+	case "fr", "pt":
+		if i <= 1 {
+			return "one"
+		}
+	case "ru", "uk", "be":
+		switch {
+		case !integer:
+		case mod10 == 1 && mod100 != 11:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "pl":
+		switch {
+		case !integer:
+		case i == 1:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "cs", "sk":
+		switch {
+		case !integer:
+			return "many"
+		case i == 1:
+			return "one"
+		case i >= 2 && i <= 4:
+			return "few"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// form returns the form of the plural forms for count in locale.
+func (f PluralForms) form(count float64, locale string) string {
+	if count == 0 && f.Zero != "" {
+		return f.Zero
+	}
+	var form string
+	switch pluralCategory(count, locale) {
+	case "one":
+		form = f.One
+	case "few":
+		form = f.Few
+	case "many":
+		form = f.Many
+	}
+	if form == "" {
+		return f.Other
+	}
+	return form
+}
+
+// pluralFunc implements plural(count, singular, plural) and returns
+// singular for a count of 1 or -1 and plural otherwise.
+func pluralFunc(args ...interface{}) (interface{}, error) {
+	count, err := toNumber(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("plural() count must be a number: %w", err)
+	}
+	if math.Abs(count) == 1 {
+		return FormatValue(args[1]), nil
+	}
+	return FormatValue(args[2]), nil
+}
+
+// pluralizeFunc implements pluralize(count, key) and pluralize(count, key,
+// locale) and returns the form of the key registered with
+// Engine.RegisterPlurals for count. The locale defaults to
+// RenderOptions.Language.
+func pluralizeFunc(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	count, err := toNumber(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("pluralize() count must be a number: %w", err)
+	}
+	key := FormatValue(args[1])
+	locale := helpers.locale
+	if len(args) > 2 && args[2] != nil {
+		locale = FormatValue(args[2])
+	}
+
+	for _, candidate := range localeFallbacks(locale) {
+		if forms, ok := helpers.plurals[candidate][key]; ok {
+			return forms.form(count, locale), nil
+		}
+	}
+	return nil, fmt.Errorf("pluralize(): no plural forms registered for %q in locale %q", key, locale)
+}
+
+// registerPluralFunctions registers the plural() and pluralize() functions
+func registerPluralFunctions(registry *DefaultFunctionRegistry) {
+	// plural() function - picks the singular or plural form for a count
+	pluralFn := NewSimpleFunction("plural", 3, 3, pluralFunc)
+	registry.RegisterFunction(pluralFn)
+
+	// pluralize() function - picks the registered plural form of a key for a count
+	registry.RegisterFunction(&renderHelperFunction{name: "pluralize", minArgs: 2, maxArgs: 3, handler: pluralizeFunc})
+}
@@ -0,0 +1,118 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPluralFunction(t *testing.T) {
+	registry := GetDefaultFunctionRegistry()
+	fn, ok := registry.GetFunction("plural")
+	if !ok {
+		t.Fatal("plural function not registered")
+	}
+
+	for _, tt := range []struct {
+		count interface{}
+		want  string
+	}{
+		{count: 1, want: "item"},
+		{count: -1, want: "item"},
+		{count: 0, want: "items"},
+		{count: 3, want: "items"},
+		{count: 1.5, want: "items"},
+		{count: "1", want: "item"},
+		{count: nil, want: "items"},
+	} {
+		got, err := fn.Call(tt.count, "item", "items")
+		if err != nil {
+			t.Fatalf("plural(%v) failed: %v", tt.count, err)
+		}
+		if got != tt.want {
+			t.Errorf("plural(%v) = %v, want %q", tt.count, got, tt.want)
+		}
+	}
+
+	if _, err := fn.Call("many", "item", "items"); err == nil || !strings.Contains(err.Error(), "count must be a number") {
+		t.Errorf("expected an error for a count that is not a number, got %v", err)
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	for _, tt := range []struct {
+		locale string
+		counts []float64
+		want   string
+	}{
+		{locale: "en", counts: []float64{1}, want: "one"},
+		{locale: "en-US", counts: []float64{0, 2, 1.5, 11}, want: "other"},
+		{locale: "fr", counts: []float64{0, 1, 1.5}, want: "one"},
+		{locale: "fr", counts: []float64{2}, want: "other"},
+		{locale: "ja", counts: []float64{1}, want: "other"},
+		{locale: "ru", counts: []float64{1, 21, 101}, want: "one"},
+		{locale: "ru", counts: []float64{2, 4, 22}, want: "few"},
+		{locale: "ru", counts: []float64{0, 5, 11, 12, 14, 25}, want: "many"},
+		{locale: "ru", counts: []float64{1.5}, want: "other"},
+		{locale: "pl", counts: []float64{1}, want: "one"},
+		{locale: "pl", counts: []float64{2, 23}, want: "few"},
+		{locale: "pl", counts: []float64{0, 5, 12, 21}, want: "many"},
+		{locale: "cs", counts: []float64{3}, want: "few"},
+		{locale: "cs", counts: []float64{0.5}, want: "many"},
+		{locale: "cs", counts: []float64{5}, want: "other"},
+	} {
+		for _, count := range tt.counts {
+			if got := pluralCategory(count, tt.locale); got != tt.want {
+				t.Errorf("pluralCategory(%v, %q) = %q, want %q", count, tt.locale, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestPluralizeUsesEngineCatalog(t *testing.T) {
+	engine := New()
+	engine.RegisterPlurals("", map[string]PluralForms{
+		"file": {One: "file", Other: "files"},
+		"item": {Zero: "no items", One: "item", Other: "items"},
+	})
+	engine.RegisterPlurals("ru", map[string]PluralForms{
+		"file": {One: "файл", Few: "файла", Many: "файлов", Other: "файла"},
+	})
+
+	tmpl, err := engine.Prepare(bytes.NewReader(createSimpleDOCX(t,
+		`{{n}} {{pluralize(n, "file")}}, {{pluralize(n, "file", "ru-RU")}}, {{pluralize(n, "item")}}`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	for _, tt := range []struct {
+		n    int
+		want string
+	}{
+		{n: 1, want: "1 file, файл, item"},
+		{n: 3, want: "3 files, файла, items"},
+		{n: 5, want: "5 files, файлов, items"},
+		{n: 0, want: "0 files, файлов, no items"},
+	} {
+		output := renderPreparedToBytes(t, tmpl, TemplateData{"n": tt.n})
+		if content := extractTextFromDOCX(t, output); content != tt.want {
+			t.Errorf("n=%d: got %q, want %q", tt.n, content, tt.want)
+		}
+	}
+
+	// The render language selects the locale when none is given
+	output := renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{"n": 22}, RenderOptions{Language: "ru"})
+	if content := extractTextFromDOCX(t, output); content != "22 файла, файла, items" {
+		t.Errorf("expected the forms of the render language, got %q", content)
+	}
+
+	tmpl, err = engine.Prepare(bytes.NewReader(createSimpleDOCX(t, `{{pluralize(2, "folder")}}`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.Render(TemplateData{}); err == nil || !strings.Contains(err.Error(), `no plural forms registered for "folder"`) {
+		t.Errorf("expected an error for an unknown key, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxPreflightIssueProblems limits the validation issues listed in the
+// problems of a PreflightReport.
+const maxPreflightIssueProblems = 5
+
+// PreflightOptions configures Engine.PreflightWithOptions.
+type PreflightOptions struct {
+	// Schema validates the template against the types of its data, and
+	// the sample data against the schema with ValidateData. When nil,
+	// only the syntax of the template is validated.
+	Schema TemplateSchema
+	// SampleData is the data of the dry-run render. When nil, the template
+	// is rendered with empty data.
+	SampleData TemplateData
+	// RenderOptions are the options of the dry-run render. The rendered
+	// package is always checked as with RenderOptions.ValidateOutput.
+	RenderOptions RenderOptions
+}
+
+// PreflightReport describes whether a template is ready to render.
+type PreflightReport struct {
+	// Path is the path of the template.
+	Path string
+	// Ready is set when the template was prepared, has no validation
+	// errors and rendered the sample data.
+	Ready bool
+	// Problems describes what makes the template not ready, one problem
+	// per entry.
+	Problems []string
+	// Validation is the result of validating the template. Its issues
+	// include warnings, which do not affect Ready.
+	Validation ValidateTemplateResult
+	// DataIssues are the issues of the sample data found by validating it
+	// against the schema.
+	DataIssues []DataIssue
+	// PrepareTime, ValidateTime and RenderTime are the durations of the
+	// steps of the preflight.
+	PrepareTime  time.Duration
+	ValidateTime time.Duration
+	RenderTime   time.Duration
+	// OutputSize is the size of the dry-run document in bytes.
+	OutputSize int
+}
+
+// Preflight checks that the template at path is ready to render, for use at
+// service startup so broken templates fail the deploy instead of the first
+// request. It prepares the template, validates its syntax and renders it
+// once with empty data. See PreflightWithOptions.
+func (e *Engine) Preflight(path string) (PreflightReport, error) {
+	return e.PreflightWithOptions(path, PreflightOptions{})
+}
+
+// PreflightWithOptions checks that the template at path is ready to render:
+// it prepares the template with PrepareFile, validates it against
+// opts.Schema, or its syntax when there is no schema, validates
+// opts.SampleData against opts.Schema, and renders opts.SampleData once,
+// checking the rendered package. The report lists
+// the problems found; the error is non-nil when the template is not ready.
+// A template the engine caches stays in the cache, warmed by the dry-run.
+//
+// Example:
+//
+//	for _, path := range []string{"invoice.docx", "letter.docx"} {
+//	    report, err := engine.PreflightWithOptions(path, stencil.PreflightOptions{SampleData: samples[path]})
+//	    if err != nil {
+//	        log.Fatalf("template %s: %v", path, err)
+//	    }
+//	    log.Printf("template %s ready, rendered in %v", path, report.RenderTime)
+//	}
+func (e *Engine) PreflightWithOptions(path string, opts PreflightOptions) (PreflightReport, error) {
+	report := PreflightReport{Path: path}
+
+	start := time.Now()
+	tmpl, err := e.PrepareFile(path)
+	report.PrepareTime = time.Since(start)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("prepare: %v", err))
+		return report, report.err()
+	}
+	defer tmpl.Close()
+
+	start = time.Now()
+	if opts.Schema != nil {
+		report.Validation, err = tmpl.Validate(opts.Schema)
+	} else {
+		var syntax ValidateTemplateSyntaxResult
+		syntax, err = ValidateTemplateSyntax(ValidateTemplateSyntaxInput{DocxBytes: tmpl.template.source})
+		report.Validation = ValidateTemplateResult(syntax)
+	}
+	report.ValidateTime = time.Since(start)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("validate: %v", err))
+	}
+	errorCount := 0
+	for _, issue := range report.Validation.Issues {
+		if issue.Severity != IssueSeverityError {
+			continue
+		}
+		if errorCount++; errorCount <= maxPreflightIssueProblems {
+			report.Problems = append(report.Problems, fmt.Sprintf("validate: %s (%s)", issue.Message, issue.Token.Raw))
+		}
+	}
+	if errorCount > maxPreflightIssueProblems {
+		report.Problems = append(report.Problems, fmt.Sprintf("validate: %d more errors", errorCount-maxPreflightIssueProblems))
+	}
+	if opts.Schema != nil && opts.SampleData != nil {
+		report.DataIssues = opts.Schema.ValidateData(opts.SampleData)
+		for i, issue := range report.DataIssues {
+			if i == maxPreflightIssueProblems {
+				report.Problems = append(report.Problems, fmt.Sprintf("data: %d more issues", len(report.DataIssues)-i))
+				break
+			}
+			report.Problems = append(report.Problems, "data: "+issue.Message)
+		}
+	}
+
+	data := opts.SampleData
+	if data == nil {
+		data = TemplateData{}
+	}
+	renderOpts := opts.RenderOptions
+	renderOpts.ValidateOutput = true
+	start = time.Now()
+	output, err := tmpl.RenderWithOptions(data, renderOpts)
+	report.RenderTime = time.Since(start)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("render: %v", err))
+	} else if sized, ok := output.(interface{ Len() int }); ok {
+		report.OutputSize = sized.Len()
+	}
+
+	report.Ready = len(report.Problems) == 0
+	return report, report.err()
+}
+
+// err returns an error listing the problems of the report, or nil.
+func (r PreflightReport) err() error {
+	if len(r.Problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("template %s is not ready: %s", r.Path, strings.Join(r.Problems, "; "))
+}
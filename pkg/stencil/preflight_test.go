@@ -0,0 +1,88 @@
+package stencil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePreflightTemplate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "template.docx")
+	if err := os.WriteFile(path, createSimpleDOCX(t, content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPreflight(t *testing.T) {
+	engine := New()
+	defer engine.Close()
+
+	path := writePreflightTemplate(t, "Hello {{name}}")
+	report, err := engine.PreflightWithOptions(path, PreflightOptions{SampleData: TemplateData{"name": "World"}})
+	if err != nil {
+		t.Fatalf("Preflight failed: %v", err)
+	}
+	if !report.Ready || len(report.Problems) != 0 {
+		t.Errorf("report = %+v, want ready", report)
+	}
+	if report.OutputSize == 0 {
+		t.Error("expected the size of the dry-run document")
+	}
+	if cached, ok := engine.cache.Get(path); !ok {
+		t.Error("expected the prepared template to stay cached")
+	} else {
+		cached.Close()
+	}
+
+	for name, content := range map[string]string{
+		"unclosed block":   "{{if name}}Hello",
+		"unknown function": "{{noSuchFunction(name)}}",
+	} {
+		path := writePreflightTemplate(t, content)
+		report, err := engine.Preflight(path)
+		if err == nil || report.Ready {
+			t.Errorf("%s: report = %+v, want not ready", name, report)
+			continue
+		}
+		if len(report.Problems) == 0 || !strings.Contains(err.Error(), "is not ready") {
+			t.Errorf("%s: problems = %v, error = %v", name, report.Problems, err)
+		}
+	}
+
+	report, err = engine.Preflight(filepath.Join(t.TempDir(), "missing.docx"))
+	if err == nil || report.Ready || !strings.HasPrefix(report.Problems[0], "prepare:") {
+		t.Errorf("missing template: report = %+v, error = %v", report, err)
+	}
+}
+
+func TestPreflightValidatesSampleData(t *testing.T) {
+	engine := New()
+	defer engine.Close()
+
+	path := writePreflightTemplate(t, "Pay by {{paymentMethod}} {{iban}}")
+	schema := TemplateSchema{
+		"paymentMethod": String,
+		"iban":          RequiredIf(Nullable(String), `paymentMethod == "SEPA"`),
+	}
+	report, err := engine.PreflightWithOptions(path, PreflightOptions{
+		Schema:     schema,
+		SampleData: TemplateData{"paymentMethod": "SEPA"},
+	})
+	if err == nil || report.Ready || len(report.DataIssues) != 1 {
+		t.Fatalf("report = %+v, want a data issue", report)
+	}
+	if want := `data: iban is required when paymentMethod == "SEPA"`; len(report.Problems) != 1 || report.Problems[0] != want {
+		t.Errorf("problems = %v, want [%s]", report.Problems, want)
+	}
+
+	report, err = engine.PreflightWithOptions(path, PreflightOptions{
+		Schema:     schema,
+		SampleData: TemplateData{"paymentMethod": "SEPA", "iban": "DE89370400440532013000"},
+	})
+	if err != nil || !report.Ready {
+		t.Errorf("report = %+v, error = %v, want ready", report, err)
+	}
+}
@@ -31,7 +31,7 @@ func (pt *PreparedTemplate) Validate(schema TemplateSchema) (ValidateTemplateRes
 	pt.mu.RLock()
 	if pt.closed || pt.template == nil {
 		pt.mu.RUnlock()
-		return ValidateTemplateResult{}, fmt.Errorf("template is closed")
+		return ValidateTemplateResult{}, errTemplateClosed
 	}
 	defer pt.mu.RUnlock()
 	tmpl := pt.template
@@ -44,6 +44,13 @@ func (pt *PreparedTemplate) Validate(schema TemplateSchema) (ValidateTemplateRes
 
 	validationSchema := validationSchemaFromTemplateSchema(schema)
 	validationSchema.Functions = functionDefinitionsFromRegistry(registry)
+	for _, macro := range tmpl.macroSet() {
+		validationSchema.Functions = append(validationSchema.Functions, FunctionDefinition{
+			Name:       macro.name,
+			MaxArgs:    len(macro.params),
+			ReturnKind: semanticKindAny,
+		})
+	}
 
 	walker := &templateValidationWalker{
 		tmpl:          tmpl,
@@ -69,6 +76,7 @@ func (pt *PreparedTemplate) Validate(schema TemplateSchema) (ValidateTemplateRes
 	allIssues = append(allIssues, syntaxIssues...)
 	allIssues = append(allIssues, semanticIssues...)
 	allIssues = append(allIssues, walker.issues...)
+	allIssues, suppressedCount := suppressIssues(allIssues, collectIssueSuppressions(walker.spans, nil))
 	sortPreparedValidationIssues(allIssues)
 	for i := range allIssues {
 		allIssues[i].ID = fmt.Sprintf("iss_%03d", i+1)
@@ -82,6 +90,7 @@ func (pt *PreparedTemplate) Validate(schema TemplateSchema) (ValidateTemplateRes
 			ErrorCount:         errorCount,
 			WarningCount:       warningCount,
 			ReturnedIssueCount: len(allIssues),
+			SuppressedCount:    suppressedCount,
 		},
 		Issues:          allIssues,
 		IssuesTruncated: false,
@@ -89,6 +98,53 @@ func (pt *PreparedTemplate) Validate(schema TemplateSchema) (ValidateTemplateRes
 	}, nil
 }
 
+// Warnings returns the syntax issues found when the template was prepared
+// with Config.ValidateOnPrepare enabled, such as unclosed control blocks or
+// malformed expressions that would fail at render time. It returns nil when
+// validation did not run or found no issues.
+func (pt *PreparedTemplate) Warnings() []StencilValidationIssue {
+	if pt == nil {
+		return nil
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	if len(pt.warnings) == 0 {
+		return nil
+	}
+	return append([]StencilValidationIssue(nil), pt.warnings...)
+}
+
+// validateSyntax runs the syntax validator over the template source and
+// stores the issues it finds as warnings.
+func (pt *PreparedTemplate) validateSyntax() error {
+	result, err := ValidateTemplateSyntax(ValidateTemplateSyntaxInput{DocxBytes: pt.template.source})
+	if err != nil {
+		return fmt.Errorf("failed to validate template syntax: %w", err)
+	}
+	issues := result.Issues
+	if constants := pt.data.constantValues(); len(constants) > 0 {
+		// Conditions on registered constants are constant too
+		spans, err := scanDOCXTokenSpans(pt.template.source)
+		if err != nil {
+			return fmt.Errorf("failed to validate template syntax: %w", err)
+		}
+		issues = issues[:0:0]
+		for _, issue := range result.Issues {
+			if issue.Code != IssueCodeConstantCondition {
+				issues = append(issues, issue)
+			}
+		}
+		issues = append(issues, constantConditionIssues(spans, constants)...)
+		issues, _ = suppressIssues(issues, collectIssueSuppressions(spans, nil))
+		sortPreparedValidationIssues(issues)
+	}
+
+	pt.mu.Lock()
+	pt.warnings = issues
+	pt.mu.Unlock()
+	return nil
+}
+
 func (w *templateValidationWalker) scanMainTemplate() error {
 	spans, err := scanDOCXTokenSpans(w.tmpl.source)
 	if err != nil {
@@ -114,7 +170,7 @@ func (w *templateValidationWalker) traverseFragmentInclude(name string, includeS
 		if stackName == name {
 			w.appendIncludeIssue(
 				includeSpan,
-				fmt.Sprintf("circular fragment reference detected: %s. This include chain references a fragment that is already being validated.", name),
+				fmt.Sprintf("circular fragment reference detected: %s (include chain: %s). This include chain references a fragment that is already being validated.", name, formatIncludeChain(w.stack, name)),
 				[]string{
 					fmt.Sprintf("Remove the include of %q from one fragment in the cycle.", name),
 					"Move shared content into a separate fragment that does not include its callers.",
@@ -216,11 +272,17 @@ func (w *templateValidationWalker) validateSemanticSpansWithIncludes() []Stencil
 }
 
 func (w *templateValidationWalker) scanFragment(name string, frag *fragment) ([]tokenSpan, error) {
+	return scanFragmentTokenSpans(name, frag, w.resources.mainStylesXML)
+}
+
+// scanFragmentTokenSpans prepares a fragment if needed and returns the token
+// spans of its body.
+func scanFragmentTokenSpans(name string, frag *fragment, mainStylesXML []byte) ([]tokenSpan, error) {
 	if frag == nil {
 		return nil, nil
 	}
 	if frag.isDocx {
-		if err := frag.ensurePrepared(w.resources.mainStylesXML); err != nil {
+		if err := frag.ensurePrepared(mainStylesXML); err != nil {
 			return nil, err
 		}
 		if frag.parsed == nil || frag.parsed.Body == nil {
@@ -278,3 +278,62 @@ func hasValidationSuggestion(result ValidateTemplateResult, suggestion string) b
 	}
 	return false
 }
+
+func TestPrepareWarnings(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`<w:p><w:r><w:t>{{if user.active}}Hello {{user.name}}</w:t></w:r></w:p>`),
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		tmpl, err := NewWithConfig(DefaultConfig()).Prepare(bytes.NewReader(docx))
+		if err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		defer tmpl.Close()
+		if warnings := tmpl.Warnings(); warnings != nil {
+			t.Fatalf("expected no warnings without ValidateOnPrepare, got %+v", warnings)
+		}
+	})
+
+	t.Run("reports syntax issues", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ValidateOnPrepare = true
+		tmpl, err := NewWithConfig(config).Prepare(bytes.NewReader(docx))
+		if err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		defer tmpl.Close()
+
+		warnings := tmpl.Warnings()
+		if len(warnings) != 1 || warnings[0].Code != IssueCodeControlBlockMismatch {
+			t.Fatalf("expected one control block warning, got %+v", warnings)
+		}
+		if !strings.Contains(warnings[0].Token.Raw, "if user.active") {
+			t.Fatalf("expected warning to point at the unclosed if, got %+v", warnings[0])
+		}
+
+		warnings[0].Message = "changed"
+		if tmpl.Warnings()[0].Message == "changed" {
+			t.Fatalf("Warnings returned the template's own slice")
+		}
+	})
+
+	t.Run("valid template", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ValidateOnPrepare = true
+		tmpl := prepareTemplateWithEngine(t, NewWithConfig(config), `{{if user.active}}Hello{{end}}`)
+		if warnings := tmpl.Warnings(); warnings != nil {
+			t.Fatalf("expected no warnings, got %+v", warnings)
+		}
+	})
+}
+
+func prepareTemplateWithEngine(t *testing.T, engine *Engine, text string) *PreparedTemplate {
+	t.Helper()
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{text})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	t.Cleanup(func() { tmpl.Close() })
+	return tmpl
+}
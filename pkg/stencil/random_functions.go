@@ -0,0 +1,245 @@
+package stencil
+
+import (
+	cryptorand "crypto/rand"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// renderHelpersKey stores the per-render state and settings of a render in
+// the render data, where expressions and functions find them.
+const renderHelpersKey = "\x00go_stencil_render_helpers"
+
+// SequenceStore persists the counters used by the sequence() function.
+// Implementations are called concurrently by parallel renders and must be
+// safe for concurrent use.
+type SequenceStore interface {
+	// Next increments the named counter and returns its new value.
+	Next(name string) (int64, error)
+}
+
+// MemorySequenceStore is a SequenceStore that keeps its counters in memory.
+// Counters start at 1.
+type MemorySequenceStore struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+// NewMemorySequenceStore creates an empty in-memory sequence store.
+func NewMemorySequenceStore() *MemorySequenceStore {
+	return &MemorySequenceStore{counters: make(map[string]int64)}
+}
+
+// Next increments the named counter and returns its new value.
+func (s *MemorySequenceStore) Next(name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[name]++
+	return s.counters[name], nil
+}
+
+// defaultSequenceStore is used by renders that do not set
+// RenderOptions.SequenceStore, so counters are shared within the process.
+var defaultSequenceStore = NewMemorySequenceStore()
+
+// renderHelpers holds the state shared by the helper functions during one
+// render and the settings that apply to its expressions and function calls.
+type renderHelpers struct {
+	mu        sync.Mutex
+	rng       *rand.Rand // nil uses unseeded randomness
+	sequences SequenceStore
+	// locale and plurals are the render locale and the plural catalog of
+	// pluralize()
+	locale  string
+	plurals map[string]map[string]PluralForms
+	// features and featureProvider are the flags of feature()
+	features        map[string]bool
+	featureProvider FeatureProvider
+	// assertions collects the failed assertions of assert(); nil when the
+	// render does not check them
+	assertions *assertionLog
+	// valueProviders resolve the names that the render data and the global
+	// data do not define
+	valueProviders []ValueProvider
+	// functionPolicy restricts the function calls of the render; nil allows
+	// every function
+	functionPolicy *FunctionPolicy
+	// propagatePanics and strictShadowing are the RenderOptions of the same
+	// name
+	propagatePanics bool
+	strictShadowing bool
+	// arithmeticPolicy and concatenationPolicy are the engine's policies;
+	// empty means the defaults
+	arithmeticPolicy    ArithmeticPolicy
+	concatenationPolicy ConcatenationPolicy
+	// macros are the macros of the template being rendered
+	macros map[string]*templateMacro
+	// valueSizeLimit bounds the estimated size in bytes of the values that
+	// + and function calls produce; 0 means no limit. Scripts set it.
+	valueSizeLimit int
+	// timedOutCalls counts the timed-out function calls of the engine that
+	// are still running; nil uses the count of renders without an engine
+	timedOutCalls *atomic.Int64
+}
+
+// timedOutCallCounter returns the count of the running timed-out function
+// calls that bounds the calls of the render.
+func (h *renderHelpers) timedOutCallCounter() *atomic.Int64 {
+	if h == nil || h.timedOutCalls == nil {
+		return &defaultTimedOutCalls
+	}
+	return h.timedOutCalls
+}
+
+func newRenderHelpers(opts *RenderOptions) *renderHelpers {
+	helpers := &renderHelpers{sequences: defaultSequenceStore}
+	if opts == nil {
+		return helpers
+	}
+	if opts.Seed != 0 {
+		seed := uint64(opts.Seed)
+		helpers.rng = rand.New(rand.NewPCG(seed, seed))
+	}
+	if opts.SequenceStore != nil {
+		helpers.sequences = opts.SequenceStore
+	}
+	helpers.locale = opts.Language
+	helpers.features = opts.Features
+	if opts.CheckAssertions {
+		helpers.assertions = &assertionLog{}
+	}
+	helpers.functionPolicy = opts.FunctionPolicy
+	helpers.propagatePanics = opts.PropagatePanics
+	helpers.strictShadowing = opts.StrictVariableShadowing
+	return helpers
+}
+
+// renderHelpersFromData returns the render helpers attached to data, or
+// unseeded helpers when the expression is evaluated outside a render.
+func renderHelpersFromData(data TemplateData) *renderHelpers {
+	if helpers := attachedRenderHelpers(data); helpers != nil {
+		return helpers
+	}
+	return newRenderHelpers(nil)
+}
+
+// attachedRenderHelpers returns the render helpers attached to data, or nil
+// outside a render.
+func attachedRenderHelpers(data TemplateData) *renderHelpers {
+	value, _ := resolveSpecialContextValue(data, renderHelpersKey)
+	helpers, _ := value.(*renderHelpers)
+	return helpers
+}
+
+func (h *renderHelpers) intN(n int) int {
+	if h.rng == nil {
+		return rand.IntN(n)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rng.IntN(n)
+}
+
+func (h *renderHelpers) read(b []byte) {
+	if h.rng == nil {
+		cryptorand.Read(b)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range b {
+		b[i] = byte(h.rng.Uint32())
+	}
+}
+
+// renderHelperFunction is a built-in function that needs the per-render
+// helper state. FunctionCallNode.Evaluate passes the state from the render
+// data; Call uses unseeded helpers.
+type renderHelperFunction struct {
+	name    string
+	minArgs int
+	maxArgs int
+	handler func(helpers *renderHelpers, args ...interface{}) (interface{}, error)
+}
+
+func (f *renderHelperFunction) Call(args ...interface{}) (interface{}, error) {
+	return f.callWithHelpers(newRenderHelpers(nil), args...)
+}
+
+func (f *renderHelperFunction) callWithHelpers(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	argCount := len(args)
+	if argCount < f.minArgs {
+		return nil, fmt.Errorf("function %s requires at least %d arguments, got %d", f.name, f.minArgs, argCount)
+	}
+	if f.maxArgs >= 0 && argCount > f.maxArgs {
+		return nil, fmt.Errorf("function %s accepts at most %d arguments, got %d", f.name, f.maxArgs, argCount)
+	}
+	return f.handler(helpers, args...)
+}
+
+func (f *renderHelperFunction) Name() string {
+	return f.name
+}
+
+func (f *renderHelperFunction) MinArgs() int {
+	return f.minArgs
+}
+
+func (f *renderHelperFunction) MaxArgs() int {
+	return f.maxArgs
+}
+
+// uuidFunc implements uuid() and returns a random (version 4) UUID.
+func uuidFunc(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	var b [16]byte
+	helpers.read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// randomFunc implements random(n) and returns an integer in [0, n).
+func randomFunc(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	n, ok := toInt(args[0])
+	if !ok {
+		return nil, fmt.Errorf("random() limit must be an integer, got %T", args[0])
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("random() limit must be positive, got %d", n)
+	}
+	return helpers.intN(n), nil
+}
+
+// sequenceFunc implements sequence(name) and returns the next value of the
+// named counter.
+func sequenceFunc(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("sequence() name must be a string, got %T", args[0])
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("sequence() name cannot be empty")
+	}
+
+	value, err := helpers.sequences.Next(name)
+	if err != nil {
+		return nil, fmt.Errorf("sequence(%q): %w", name, err)
+	}
+	return value, nil
+}
+
+func registerRandomFunctions(registry *DefaultFunctionRegistry) {
+	// uuid() function - generates a random UUID
+	registry.RegisterFunction(&renderHelperFunction{name: "uuid", minArgs: 0, maxArgs: 0, handler: uuidFunc})
+
+	// random() function - generates a random integer below a limit
+	registry.RegisterFunction(&renderHelperFunction{name: "random", minArgs: 1, maxArgs: 1, handler: randomFunc})
+
+	// sequence() function - returns the next value of a named counter
+	registry.RegisterFunction(&renderHelperFunction{name: "sequence", minArgs: 1, maxArgs: 1, handler: sequenceFunc})
+}
@@ -0,0 +1,105 @@
+package stencil
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRandomFunctionsSeededRenderIsDeterministic(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{uuid()}}`,
+		`{{random(1000)}},{{random(1000)}},{{random(1000)}}`,
+	})
+
+	render := func(seed int64) []string {
+		output := renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{Seed: seed})
+		documentXML := extractDocumentXMLFromDOCX(t, output)
+		var texts []string
+		for _, match := range regexp.MustCompile(`<w:t[^>]*>([^<]*)</w:t>`).FindAllStringSubmatch(documentXML, -1) {
+			texts = append(texts, match[1])
+		}
+		return texts
+	}
+
+	first := render(42)
+	if len(first) != 2 {
+		t.Fatalf("expected two paragraphs, got %q", first)
+	}
+	if !uuidPattern.MatchString(first[0]) {
+		t.Fatalf("expected version 4 UUID, got %q", first[0])
+	}
+	if second := render(42); strings.Join(second, "|") != strings.Join(first, "|") {
+		t.Fatalf("expected identical output for the same seed, got %q and %q", first, second)
+	}
+	if other := render(7); strings.Join(other, "|") == strings.Join(first, "|") {
+		t.Fatalf("expected different output for a different seed, got %q", other)
+	}
+}
+
+func TestRandomFunctionsUnseeded(t *testing.T) {
+	registry := GetDefaultFunctionRegistry()
+
+	uuidFn, _ := registry.GetFunction("uuid")
+	first, err := uuidFn.Call()
+	if err != nil {
+		t.Fatalf("uuid() failed: %v", err)
+	}
+	second, _ := uuidFn.Call()
+	if !uuidPattern.MatchString(first.(string)) || first == second {
+		t.Fatalf("expected two distinct UUIDs, got %v and %v", first, second)
+	}
+
+	randomFn, _ := registry.GetFunction("random")
+	for i := 0; i < 20; i++ {
+		value, err := randomFn.Call(3)
+		if err != nil {
+			t.Fatalf("random(3) failed: %v", err)
+		}
+		if n := value.(int); n < 0 || n >= 3 {
+			t.Fatalf("random(3) returned %d", n)
+		}
+	}
+	for _, arg := range []interface{}{0, -1, "x"} {
+		if _, err := randomFn.Call(arg); err == nil {
+			t.Fatalf("expected error for random(%v)", arg)
+		}
+	}
+}
+
+type failingSequenceStore struct{}
+
+func (failingSequenceStore) Next(name string) (int64, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func TestSequenceFunction(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Invoice {{sequence("invoice")}}`,
+		`Order {{sequence("order")}}`,
+	})
+
+	store := NewMemorySequenceStore()
+	opts := RenderOptions{SequenceStore: store}
+	if content := extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, TemplateData{}, opts)); content != "Invoice 1Order 1" {
+		t.Fatalf("unexpected first render %q", content)
+	}
+	if content := extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, TemplateData{}, opts)); content != "Invoice 2Order 2" {
+		t.Fatalf("expected counters to persist in the store, got %q", content)
+	}
+	if next, _ := store.Next("invoice"); next != 3 {
+		t.Fatalf("expected invoice counter 3, got %d", next)
+	}
+
+	tmpl, err := prepare(strings.NewReader(string(docx)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.RenderWithOptions(TemplateData{}, RenderOptions{SequenceStore: failingSequenceStore{}}); err == nil || !strings.Contains(err.Error(), "store unavailable") {
+		t.Fatalf("expected sequence store error, got %v", err)
+	}
+}
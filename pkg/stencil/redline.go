@@ -0,0 +1,889 @@
+package stencil
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// defaultRedlineAuthor is the revision author when RedlineOptions.Author is
+// empty.
+const defaultRedlineAuthor = "go-stencil"
+
+// maxRedlineDiffCells bounds the table a single sequence diff may allocate.
+// Larger differences are shown as a deletion followed by an insertion.
+const maxRedlineDiffCells = 1 << 22
+
+var (
+	redlineBodyStartRegex  = regexp.MustCompile(`<w:body\b[^>]*>`)
+	redlineRevisionIDRegex = regexp.MustCompile(`\sw:id="(\d+)"`)
+	// redlineDeletedTextRegex matches the text elements that become
+	// w:delText and w:delInstrText in deleted runs.
+	redlineDeletedTextRegex = regexp.MustCompile(`<(/?)w:(t|instrText)([\s/>])`)
+)
+
+// RedlineOptions controls RenderRedlineWithOptions.
+type RedlineOptions struct {
+	// RenderOptions are used for both renders.
+	RenderOptions
+
+	// Author is recorded on every tracked change. Defaults to "go-stencil".
+	Author string
+
+	// Date is recorded on every tracked change. Defaults to the current time.
+	Date time.Time
+}
+
+// RenderRedline renders the template with oldData and with newData and
+// returns the second document with the differences between the two marked
+// as Word tracked changes (w:ins and w:del), as in a contract comparison.
+// Reviewers see exactly what changed and can accept or reject each change.
+func (pt *PreparedTemplate) RenderRedline(oldData, newData TemplateData) (io.Reader, error) {
+	return pt.RenderRedlineWithOptions(oldData, newData, RedlineOptions{})
+}
+
+// RenderRedlineWithOptions is RenderRedline with render options and the
+// author and date of the tracked changes.
+//
+// The main document body is compared word by word, including table cells.
+// Headers, footers and other parts are taken from the newData document.
+// Paragraph and table properties are taken from the newData document and
+// their changes are not tracked.
+func (pt *PreparedTemplate) RenderRedlineWithOptions(oldData, newData TemplateData, opts RedlineOptions) (io.Reader, error) {
+	oldDocument, err := renderRedlineVersion(pt, oldData, opts.RenderOptions)
+	if err != nil {
+		return nil, fmt.Errorf("redline: failed to render old data: %w", err)
+	}
+	newDocument, err := renderRedlineVersion(pt, newData, opts.RenderOptions)
+	if err != nil {
+		return nil, fmt.Errorf("redline: failed to render new data: %w", err)
+	}
+	content, err := redlineDocuments(oldDocument, newDocument, opts)
+	if err != nil {
+		return nil, fmt.Errorf("redline: %w", err)
+	}
+	return bytes.NewReader(content), nil
+}
+
+func renderRedlineVersion(pt *PreparedTemplate, data TemplateData, opts RenderOptions) ([]byte, error) {
+	output, err := pt.RenderWithOptions(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(output)
+}
+
+// redlineDocuments returns newDocument with the main document body
+// replaced by a comparison of the bodies of both documents.
+func redlineDocuments(oldDocument, newDocument []byte, opts RedlineOptions) ([]byte, error) {
+	oldPkg, err := readDocxPackage(oldDocument)
+	if err != nil {
+		return nil, err
+	}
+	newPkg, err := readDocxPackage(newDocument)
+	if err != nil {
+		return nil, err
+	}
+	oldXML, ok := oldPkg.get("word/document.xml")
+	if !ok {
+		return nil, fmt.Errorf("word/document.xml not found")
+	}
+	newXML, ok := newPkg.get("word/document.xml")
+	if !ok {
+		return nil, fmt.Errorf("word/document.xml not found")
+	}
+
+	oldBody, _, _, err := splitDocumentBody(string(oldXML))
+	if err != nil {
+		return nil, err
+	}
+	newBody, prefix, suffix, err := splitDocumentBody(string(newXML))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &redliner{
+		author: opts.Author,
+		date:   opts.Date,
+		nextID: maxRevisionID(string(oldXML), string(newXML)) + 1,
+	}
+	if r.author == "" {
+		r.author = defaultRedlineAuthor
+	}
+	if r.date.IsZero() {
+		r.date = time.Now()
+	}
+
+	// The final section properties stay in place after the compared content.
+	oldElements, _ := withoutSectionProperties(splitXMLElements(oldBody))
+	newElements, sectPr := withoutSectionProperties(splitXMLElements(newBody))
+
+	var body strings.Builder
+	body.WriteString(prefix)
+	body.WriteString(r.diffBlocks(oldElements, newElements))
+	body.WriteString(sectPr)
+	body.WriteString(suffix)
+	newPkg.set("word/document.xml", []byte(body.String()))
+	return newPkg.bytes()
+}
+
+// splitDocumentBody returns the content of the w:body element of a document
+// part together with the text before and after it.
+func splitDocumentBody(documentXML string) (body, prefix, suffix string, err error) {
+	start := redlineBodyStartRegex.FindStringIndex(documentXML)
+	end := strings.LastIndex(documentXML, "</w:body>")
+	if start == nil || end < start[1] {
+		return "", "", "", fmt.Errorf("document body not found")
+	}
+	return documentXML[start[1]:end], documentXML[:start[1]], documentXML[end:], nil
+}
+
+// maxRevisionID returns the largest w:id used in the documents, so tracked
+// change IDs do not collide with bookmarks, comments or existing revisions.
+func maxRevisionID(documents ...string) int {
+	maxID := 0
+	for _, document := range documents {
+		for _, match := range redlineRevisionIDRegex.FindAllStringSubmatch(document, -1) {
+			if id, err := strconv.Atoi(match[1]); err == nil && id > maxID {
+				maxID = id
+			}
+		}
+	}
+	return maxID
+}
+
+func withoutSectionProperties(elements []xmlElement) ([]xmlElement, string) {
+	if n := len(elements); n > 0 && elements[n-1].name == "w:sectPr" {
+		return elements[:n-1], elements[n-1].xml
+	}
+	return elements, ""
+}
+
+// xmlElement is an element of a rendered part, kept as XML text.
+type xmlElement struct {
+	name string // qualified name, such as "w:p"
+	xml  string
+}
+
+// splitXMLElements returns the top-level elements of an XML fragment.
+// Text, comments and processing instructions between them are dropped.
+func splitXMLElements(fragment string) []xmlElement {
+	var elements []xmlElement
+	depth, start := 0, 0
+	name := ""
+	for i := 0; i < len(fragment); {
+		if fragment[i] != '<' {
+			i++
+			continue
+		}
+		end := xmlMarkupEnd(fragment, i)
+		if end < 0 {
+			break
+		}
+		tag := fragment[i:end]
+		switch {
+		case strings.HasPrefix(tag, "<!"), strings.HasPrefix(tag, "<?"):
+		case strings.HasPrefix(tag, "</"):
+			depth--
+			if depth == 0 {
+				elements = append(elements, xmlElement{name: name, xml: fragment[start:end]})
+			}
+		default:
+			selfClosing := strings.HasSuffix(tag, "/>")
+			if depth == 0 {
+				start, name = i, xmlTagName(tag)
+				if selfClosing {
+					elements = append(elements, xmlElement{name: name, xml: tag})
+				}
+			}
+			if !selfClosing {
+				depth++
+			}
+		}
+		i = end
+	}
+	return elements
+}
+
+// xmlMarkupEnd returns the index after the markup starting at s[i], or -1.
+func xmlMarkupEnd(s string, i int) int {
+	for _, delim := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if strings.HasPrefix(s[i:], delim[0]) {
+			end := strings.Index(s[i+len(delim[0]):], delim[1])
+			if end < 0 {
+				return -1
+			}
+			return i + len(delim[0]) + end + len(delim[1])
+		}
+	}
+	var quote byte
+	for j := i + 1; j < len(s); j++ {
+		switch c := s[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+func xmlTagName(tag string) string {
+	name := strings.TrimPrefix(tag, "<")
+	if end := strings.IndexAny(name, " \t\r\n/>"); end >= 0 {
+		name = name[:end]
+	}
+	return name
+}
+
+// splitXMLElement returns the start tag, content and end tag of an element.
+// The content and end tag of an empty element are empty.
+func splitXMLElement(element string) (startTag, content, endTag string) {
+	end := xmlMarkupEnd(element, 0)
+	if end < 0 || strings.HasSuffix(element[:end], "/>") {
+		return element, "", ""
+	}
+	closeAt := strings.LastIndex(element, "</")
+	if closeAt < end {
+		return element, "", ""
+	}
+	return element[:end], element[end:closeAt], element[closeAt:]
+}
+
+// redlineToken is a unit of paragraph content compared by the redline.
+type redlineToken struct {
+	kind redlineTokenKind
+	// value is the text of a text token and the XML of other tokens.
+	value string
+	// rPr is the run properties XML of text and run content tokens.
+	rPr string
+}
+
+type redlineTokenKind int
+
+const (
+	// redlineText is a word, a space or a punctuation character.
+	redlineText redlineTokenKind = iota
+	// redlineRunContent is a non-text run child such as w:tab or w:drawing.
+	redlineRunContent
+	// redlineMarker is a zero-width element such as a bookmark.
+	redlineMarker
+	// redlineContainer is an element that holds runs, such as a hyperlink.
+	redlineContainer
+)
+
+func (t redlineToken) key() string {
+	return strconv.Itoa(int(t.kind)) + t.rPr + "\x00" + t.value
+}
+
+// redlineMarkers are paragraph children that take no space.
+var redlineMarkers = map[string]bool{
+	"w:bookmarkStart": true, "w:bookmarkEnd": true, "w:proofErr": true,
+	"w:permStart": true, "w:permEnd": true,
+	"w:commentRangeStart": true, "w:commentRangeEnd": true,
+}
+
+// paragraphTokens splits a paragraph into its properties and tokens.
+func paragraphTokens(paragraph string) (string, []redlineToken) {
+	_, content, _ := splitXMLElement(paragraph)
+	var pPr string
+	var tokens []redlineToken
+	for _, child := range splitXMLElements(content) {
+		switch {
+		case child.name == "w:pPr":
+			pPr = child.xml
+		case child.name == "w:r":
+			tokens = append(tokens, runTokens(child.xml)...)
+		case redlineMarkers[child.name]:
+			tokens = append(tokens, redlineToken{kind: redlineMarker, value: child.xml})
+		default:
+			tokens = append(tokens, redlineToken{kind: redlineContainer, value: child.xml})
+		}
+	}
+	return pPr, tokens
+}
+
+func runTokens(run string) []redlineToken {
+	_, content, _ := splitXMLElement(run)
+	children := splitXMLElements(content)
+	var rPr string
+	if len(children) > 0 && children[0].name == "w:rPr" {
+		rPr = children[0].xml
+		children = children[1:]
+	}
+	var tokens []redlineToken
+	for _, child := range children {
+		if child.name != "w:t" {
+			tokens = append(tokens, redlineToken{kind: redlineRunContent, value: child.xml, rPr: rPr})
+			continue
+		}
+		_, text, _ := splitXMLElement(child.xml)
+		for _, word := range splitRedlineWords(html.UnescapeString(text)) {
+			tokens = append(tokens, redlineToken{kind: redlineText, value: word, rPr: rPr})
+		}
+	}
+	return tokens
+}
+
+// splitRedlineWords splits text into words, runs of spaces and single
+// other characters.
+func splitRedlineWords(text string) []string {
+	var words []string
+	start := 0
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+	prev := -1
+	for i, r := range text {
+		c := class(r)
+		if i > start && (c != prev || c == 0) {
+			words = append(words, text[start:i])
+			start = i
+		}
+		prev = c
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// diffOp is one step of a sequence diff: '=' keeps old[oldIndex] as
+// new[newIndex], '-' deletes old[oldIndex] and '+' inserts new[newIndex].
+type diffOp struct {
+	kind     byte
+	oldIndex int
+	newIndex int
+}
+
+// diffSequences returns the steps that turn old into new, keeping a
+// longest common subsequence.
+func diffSequences(old, new []string) []diffOp {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(old)+len(new))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: '=', oldIndex: i, newIndex: i})
+	}
+
+	a, b := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	if len(a)*len(b) > maxRedlineDiffCells {
+		for i := range a {
+			ops = append(ops, diffOp{kind: '-', oldIndex: prefix + i})
+		}
+		for j := range b {
+			ops = append(ops, diffOp{kind: '+', newIndex: prefix + j})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of
+		// a[i:] and b[j:].
+		width := len(b) + 1
+		lcs := make([]int32, (len(a)+1)*width)
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+				} else if down, right := lcs[(i+1)*width+j], lcs[i*width+j+1]; down > right {
+					lcs[i*width+j] = down
+				} else {
+					lcs[i*width+j] = right
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				ops = append(ops, diffOp{kind: '=', oldIndex: prefix + i, newIndex: prefix + j})
+				i++
+				j++
+			case i < len(a) && (j == len(b) || lcs[(i+1)*width+j] >= lcs[i*width+j+1]):
+				// Deletions come before insertions, as in Word.
+				ops = append(ops, diffOp{kind: '-', oldIndex: prefix + i})
+				i++
+			default:
+				ops = append(ops, diffOp{kind: '+', newIndex: prefix + j})
+				j++
+			}
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, diffOp{kind: '=', oldIndex: len(old) - suffix + i, newIndex: len(new) - suffix + i})
+	}
+	return ops
+}
+
+// redliner writes tracked changes with consecutive revision IDs.
+type redliner struct {
+	author string
+	date   time.Time
+	nextID int
+}
+
+// revisionAttrs returns the attributes of a new tracked change.
+func (r *redliner) revisionAttrs() string {
+	id := r.nextID
+	r.nextID++
+	return fmt.Sprintf(` w:id="%d" w:author="%s" w:date="%s"`, id, escapeXMLText(r.author), r.date.UTC().Format(time.RFC3339))
+}
+
+// wrap encloses runs in a w:ins or w:del element.
+func (r *redliner) wrap(kind, runs string) string {
+	if runs == "" {
+		return ""
+	}
+	return "<w:" + kind + r.revisionAttrs() + ">" + runs + "</w:" + kind + ">"
+}
+
+// mark returns an empty w:ins or w:del element, which marks a paragraph
+// mark or table row as inserted or deleted.
+func (r *redliner) mark(kind string) string {
+	return "<w:" + kind + r.revisionAttrs() + "/>"
+}
+
+// diffBlocks compares two sequences of block elements, such as the content
+// of the body or of a table cell.
+func (r *redliner) diffBlocks(old, new []xmlElement) string {
+	var out strings.Builder
+	ops := diffSequences(elementXML(old), elementXML(new))
+	for i := 0; i < len(ops); {
+		if ops[i].kind == '=' {
+			out.WriteString(new[ops[i].newIndex].xml)
+			i++
+			continue
+		}
+		var deleted, inserted []xmlElement
+		for ; i < len(ops) && ops[i].kind != '='; i++ {
+			if ops[i].kind == '-' {
+				deleted = append(deleted, old[ops[i].oldIndex])
+			} else {
+				inserted = append(inserted, new[ops[i].newIndex])
+			}
+		}
+		// Changed paragraphs and tables are paired in order and compared;
+		// the other elements were removed or added.
+		for _, op := range diffSequences(elementNames(deleted), elementNames(inserted)) {
+			switch {
+			case op.kind == '-':
+				out.WriteString(r.markElement(deleted[op.oldIndex], "del"))
+			case op.kind == '+':
+				out.WriteString(r.markElement(inserted[op.newIndex], "ins"))
+			case inserted[op.newIndex].name == "w:p":
+				out.WriteString(r.diffParagraph(deleted[op.oldIndex].xml, inserted[op.newIndex].xml))
+			case inserted[op.newIndex].name == "w:tbl":
+				out.WriteString(r.diffTable(deleted[op.oldIndex].xml, inserted[op.newIndex].xml))
+			default:
+				out.WriteString(r.markElement(deleted[op.oldIndex], "del"))
+				out.WriteString(r.markElement(inserted[op.newIndex], "ins"))
+			}
+		}
+	}
+	return out.String()
+}
+
+func elementNames(elements []xmlElement) []string {
+	names := make([]string, len(elements))
+	for i, element := range elements {
+		names[i] = element.name
+	}
+	return names
+}
+
+func elementXML(elements []xmlElement) []string {
+	keys := make([]string, len(elements))
+	for i, element := range elements {
+		keys[i] = element.xml
+	}
+	return keys
+}
+
+// diffParagraph compares two paragraphs word by word.
+func (r *redliner) diffParagraph(old, new string) string {
+	_, oldTokens := paragraphTokens(old)
+	pPr, newTokens := paragraphTokens(new)
+	oldKeys := make([]string, len(oldTokens))
+	for i, token := range oldTokens {
+		oldKeys[i] = token.key()
+	}
+	newKeys := make([]string, len(newTokens))
+	for i, token := range newTokens {
+		newKeys[i] = token.key()
+	}
+
+	type change struct {
+		kind  byte
+		token redlineToken
+	}
+	var changes []change
+	for _, op := range diffSequences(oldKeys, newKeys) {
+		if op.kind == '-' {
+			changes = append(changes, change{kind: op.kind, token: oldTokens[op.oldIndex]})
+		} else {
+			changes = append(changes, change{kind: op.kind, token: newTokens[op.newIndex]})
+		}
+	}
+
+	startTag, _, endTag := splitXMLElement(new)
+	var out strings.Builder
+	out.WriteString(startTag)
+	out.WriteString(pPr)
+	for i := 0; i < len(changes); {
+		kind := changes[i].kind
+		if changes[i].token.kind == redlineContainer {
+			container := xmlElement{name: xmlTagName(changes[i].token.value), xml: changes[i].token.value}
+			switch kind {
+			case '=':
+				out.WriteString(container.xml)
+			case '-':
+				out.WriteString(r.markElement(container, "del"))
+			default:
+				out.WriteString(r.markElement(container, "ins"))
+			}
+			i++
+			continue
+		}
+		var tokens []redlineToken
+		for ; i < len(changes) && changes[i].kind == kind && changes[i].token.kind != redlineContainer; i++ {
+			if kind == '-' && changes[i].token.kind == redlineMarker {
+				continue
+			}
+			tokens = append(tokens, changes[i].token)
+		}
+		runs := redlineRuns(tokens, kind == '-')
+		switch kind {
+		case '=':
+			out.WriteString(runs)
+		case '-':
+			out.WriteString(r.wrap("del", runs))
+		default:
+			out.WriteString(r.wrap("ins", runs))
+		}
+	}
+	if endTag == "" {
+		endTag = "</w:p>"
+	}
+	out.WriteString(endTag)
+	return out.String()
+}
+
+// redlineRuns writes tokens as runs, merging neighbours with the same run
+// properties.
+func redlineRuns(tokens []redlineToken, deleted bool) string {
+	var out, text strings.Builder
+	textElement := "w:t"
+	if deleted {
+		textElement = "w:delText"
+	}
+	flushText := func() {
+		if text.Len() > 0 {
+			out.WriteString(`<` + textElement + ` xml:space="preserve">` + escapeXMLText(text.String()) + `</` + textElement + `>`)
+			text.Reset()
+		}
+	}
+	inRun := false
+	rPr := ""
+	closeRun := func() {
+		if inRun {
+			flushText()
+			out.WriteString("</w:r>")
+			inRun = false
+		}
+	}
+	for _, token := range tokens {
+		if token.kind == redlineMarker {
+			closeRun()
+			out.WriteString(token.value)
+			continue
+		}
+		if !inRun || token.rPr != rPr {
+			closeRun()
+			out.WriteString("<w:r>")
+			out.WriteString(token.rPr)
+			inRun, rPr = true, token.rPr
+		}
+		if token.kind == redlineText {
+			text.WriteString(token.value)
+			continue
+		}
+		flushText()
+		if deleted {
+			out.WriteString(deletedRunContent(token.value))
+		} else {
+			out.WriteString(token.value)
+		}
+	}
+	closeRun()
+	return out.String()
+}
+
+// deletedRunContent turns the text of run content into deleted text.
+func deletedRunContent(content string) string {
+	return redlineDeletedTextRegex.ReplaceAllStringFunc(content, func(tag string) string {
+		match := redlineDeletedTextRegex.FindStringSubmatch(tag)
+		name := "delText"
+		if match[2] == "instrText" {
+			name = "delInstrText"
+		}
+		return "<" + match[1] + "w:" + name + match[3]
+	})
+}
+
+// diffTable compares two tables row by row. Rows with the same number of
+// cells are compared cell by cell.
+func (r *redliner) diffTable(old, new string) string {
+	_, oldContent, _ := splitXMLElement(old)
+	startTag, newContent, endTag := splitXMLElement(new)
+	oldRows, _ := splitTableRows(splitXMLElements(oldContent))
+	newRows, properties := splitTableRows(splitXMLElements(newContent))
+
+	var out strings.Builder
+	out.WriteString(startTag)
+	out.WriteString(properties)
+	ops := diffSequences(elementXML(oldRows), elementXML(newRows))
+	for i := 0; i < len(ops); {
+		if ops[i].kind == '=' {
+			out.WriteString(newRows[ops[i].newIndex].xml)
+			i++
+			continue
+		}
+		var deleted, inserted []xmlElement
+		for ; i < len(ops) && ops[i].kind != '='; i++ {
+			if ops[i].kind == '-' {
+				deleted = append(deleted, oldRows[ops[i].oldIndex])
+			} else {
+				inserted = append(inserted, newRows[ops[i].newIndex])
+			}
+		}
+		paired := 0
+		for paired < len(deleted) && paired < len(inserted) {
+			row, ok := r.diffRow(deleted[paired].xml, inserted[paired].xml)
+			if !ok {
+				break
+			}
+			out.WriteString(row)
+			paired++
+		}
+		for _, row := range deleted[paired:] {
+			out.WriteString(r.markElement(row, "del"))
+		}
+		for _, row := range inserted[paired:] {
+			out.WriteString(r.markElement(row, "ins"))
+		}
+	}
+	out.WriteString(endTag)
+	return out.String()
+}
+
+// splitTableRows returns the rows of a table and the XML of its other
+// children, such as w:tblPr and w:tblGrid.
+func splitTableRows(children []xmlElement) ([]xmlElement, string) {
+	var rows []xmlElement
+	var other strings.Builder
+	for _, child := range children {
+		if child.name == "w:tr" {
+			rows = append(rows, child)
+		} else {
+			other.WriteString(child.xml)
+		}
+	}
+	return rows, other.String()
+}
+
+// diffRow compares two rows cell by cell. It reports false when the rows
+// have a different number of cells.
+func (r *redliner) diffRow(old, new string) (string, bool) {
+	_, oldContent, _ := splitXMLElement(old)
+	startTag, newContent, endTag := splitXMLElement(new)
+	oldChildren := splitXMLElements(oldContent)
+	newChildren := splitXMLElements(newContent)
+	oldCells := cellElements(oldChildren)
+	if len(oldCells) != len(cellElements(newChildren)) {
+		return "", false
+	}
+
+	var out strings.Builder
+	out.WriteString(startTag)
+	cell := 0
+	for _, child := range newChildren {
+		if child.name != "w:tc" {
+			out.WriteString(child.xml)
+			continue
+		}
+		out.WriteString(r.diffCell(oldCells[cell].xml, child.xml))
+		cell++
+	}
+	out.WriteString(endTag)
+	return out.String(), true
+}
+
+func cellElements(children []xmlElement) []xmlElement {
+	var cells []xmlElement
+	for _, child := range children {
+		if child.name == "w:tc" {
+			cells = append(cells, child)
+		}
+	}
+	return cells
+}
+
+func (r *redliner) diffCell(old, new string) string {
+	_, oldContent, _ := splitXMLElement(old)
+	startTag, newContent, endTag := splitXMLElement(new)
+	oldBlocks := splitXMLElements(oldContent)
+	if len(oldBlocks) > 0 && oldBlocks[0].name == "w:tcPr" {
+		oldBlocks = oldBlocks[1:]
+	}
+	newBlocks := splitXMLElements(newContent)
+	var tcPr string
+	if len(newBlocks) > 0 && newBlocks[0].name == "w:tcPr" {
+		tcPr = newBlocks[0].xml
+		newBlocks = newBlocks[1:]
+	}
+	return startTag + tcPr + r.diffBlocks(oldBlocks, newBlocks) + endTag
+}
+
+// markElement marks all content of an element as inserted or deleted:
+// runs are wrapped in kind, and paragraph marks and table rows carry a
+// kind mark.
+func (r *redliner) markElement(element xmlElement, kind string) string {
+	switch element.name {
+	case "w:r":
+		if kind == "del" {
+			return r.wrap(kind, deletedRunContent(element.xml))
+		}
+		return r.wrap(kind, element.xml)
+	case "w:pPr", "w:rPr", "w:tblPr", "w:tblGrid", "w:tblPrEx", "w:trPr", "w:tcPr", "w:sectPr", "w:ins", "w:del":
+		return element.xml
+	}
+	if redlineMarkers[element.name] {
+		if kind == "del" {
+			return ""
+		}
+		return element.xml
+	}
+
+	startTag, content, endTag := splitXMLElement(element.xml)
+	children := splitXMLElements(content)
+	if endTag == "" || !onlyElements(content, children) {
+		return element.xml
+	}
+
+	var out strings.Builder
+	out.WriteString(startTag)
+	switch element.name {
+	case "w:p":
+		if len(children) > 0 && children[0].name == "w:pPr" {
+			out.WriteString(r.markParagraphProperties(children[0].xml, kind))
+			children = children[1:]
+		} else {
+			out.WriteString("<w:pPr><w:rPr>" + r.mark(kind) + "</w:rPr></w:pPr>")
+		}
+	case "w:tr":
+		for len(children) > 0 && children[0].name == "w:tblPrEx" {
+			out.WriteString(children[0].xml)
+			children = children[1:]
+		}
+		if len(children) > 0 && children[0].name == "w:trPr" {
+			out.WriteString(insertBeforeChild(children[0].xml, "w:trPrChange", r.mark(kind)))
+			children = children[1:]
+		} else {
+			out.WriteString("<w:trPr>" + r.mark(kind) + "</w:trPr>")
+		}
+	}
+	for _, child := range children {
+		out.WriteString(r.markElement(child, kind))
+	}
+	out.WriteString(endTag)
+	return out.String()
+}
+
+// markParagraphProperties adds a kind mark to the run properties of the
+// paragraph mark in pPr.
+func (r *redliner) markParagraphProperties(pPr, kind string) string {
+	startTag, content, endTag := splitXMLElement(pPr)
+	if endTag == "" {
+		return strings.TrimSuffix(strings.TrimSpace(startTag), "/>") + "><w:rPr>" + r.mark(kind) + "</w:rPr></w:pPr>"
+	}
+	var out strings.Builder
+	out.WriteString(startTag)
+	marked := false
+	for _, child := range splitXMLElements(content) {
+		switch {
+		case child.name == "w:rPr" && !marked:
+			rPrStart, rPrContent, rPrEnd := splitXMLElement(child.xml)
+			if rPrEnd == "" {
+				out.WriteString(strings.TrimSuffix(strings.TrimSpace(rPrStart), "/>") + ">" + r.mark(kind) + "</w:rPr>")
+			} else {
+				out.WriteString(rPrStart + r.mark(kind) + rPrContent + rPrEnd)
+			}
+			marked = true
+			continue
+		case (child.name == "w:sectPr" || child.name == "w:pPrChange") && !marked:
+			out.WriteString("<w:rPr>" + r.mark(kind) + "</w:rPr>")
+			marked = true
+		}
+		out.WriteString(child.xml)
+	}
+	if !marked {
+		out.WriteString("<w:rPr>" + r.mark(kind) + "</w:rPr>")
+	}
+	out.WriteString(endTag)
+	return out.String()
+}
+
+// insertBeforeChild inserts markup into element before its first child
+// named before, or at the end.
+func insertBeforeChild(element, before, markup string) string {
+	startTag, content, endTag := splitXMLElement(element)
+	if endTag == "" {
+		return strings.TrimSuffix(strings.TrimSpace(startTag), "/>") + ">" + markup + "</" + xmlTagName(startTag) + ">"
+	}
+	var out strings.Builder
+	out.WriteString(startTag)
+	inserted := false
+	for _, child := range splitXMLElements(content) {
+		if child.name == before && !inserted {
+			out.WriteString(markup)
+			inserted = true
+		}
+		out.WriteString(child.xml)
+	}
+	if !inserted {
+		out.WriteString(markup)
+	}
+	out.WriteString(endTag)
+	return out.String()
+}
+
+// onlyElements reports whether content consists of children and
+// whitespace, so it can be rebuilt from children.
+func onlyElements(content string, children []xmlElement) bool {
+	for _, child := range children {
+		content = strings.Replace(content, child.xml, "", 1)
+	}
+	return strings.TrimSpace(content) == ""
+}
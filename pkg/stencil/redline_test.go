@@ -0,0 +1,119 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderRedline(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Contract</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">Price: {{price}} EUR, due in {{days}} days.</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{if penalty}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>A penalty applies.</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Item</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Qty</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{item.name}}</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>{{item.qty}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`+
+		`<w:p><w:r><w:t>Signed</w:t></w:r></w:p>`)
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	oldData := TemplateData{
+		"price": 100, "days": 30, "penalty": true,
+		"items": []interface{}{map[string]interface{}{"name": "Pen", "qty": 2}},
+	}
+	newData := TemplateData{
+		"price": 120, "days": 30, "penalty": false,
+		"items": []interface{}{
+			map[string]interface{}{"name": "Pen", "qty": 3},
+			map[string]interface{}{"name": "Ink", "qty": 1},
+		},
+	}
+	output, err := tmpl.RenderRedlineWithOptions(oldData, newData, RedlineOptions{
+		Author: "Legal & Co",
+		Date:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("RenderRedline failed: %v", err)
+	}
+	content, err := io.ReadAll(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	documentXML := extractDocumentXMLFromDOCX(t, content)
+
+	decoder := xml.NewDecoder(strings.NewReader(documentXML))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("redline document is not well-formed: %v\n%s", err, documentXML)
+		}
+	}
+
+	revision := `w:author="Legal &amp; Co" w:date="2026-01-02T03:04:05Z"`
+	for _, want := range []string{
+		// Changed words keep their formatting.
+		`<w:del w:id="1" ` + revision + `><w:r><w:rPr><w:b/></w:rPr><w:delText xml:space="preserve">100</w:delText></w:r></w:del>`,
+		`<w:ins w:id="2" ` + revision + `><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">120</w:t></w:r></w:ins>`,
+		`<w:t xml:space="preserve"> EUR, due in 30 days.</w:t>`,
+		// The removed paragraph and its paragraph mark are deleted.
+		`>A penalty applies.</w:delText>`,
+		// The new row is inserted.
+		`<w:trPr><w:ins `,
+		`<w:t>Ink</w:t></w:r></w:ins>`,
+	} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("missing %s in\n%s", want, documentXML)
+		}
+	}
+	if strings.Count(documentXML, "<w:del ") < 2 || strings.Count(documentXML, "<w:ins ") < 3 {
+		t.Errorf("expected tracked changes for the quantity, penalty and new row:\n%s", documentXML)
+	}
+	for _, unchanged := range []string{"Contract", "Signed", "Pen"} {
+		if !strings.Contains(documentXML, ">"+unchanged+"<") {
+			t.Errorf("unchanged text %q missing", unchanged)
+		}
+	}
+}
+
+func TestRenderRedlineWithoutChanges(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "Hello {{name}}")))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	output, err := tmpl.RenderRedline(TemplateData{"name": "Ada"}, TemplateData{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("RenderRedline failed: %v", err)
+	}
+	content, _ := io.ReadAll(output)
+	documentXML := extractDocumentXMLFromDOCX(t, content)
+	if strings.Contains(documentXML, "<w:ins") || strings.Contains(documentXML, "<w:del") {
+		t.Errorf("unexpected tracked changes:\n%s", documentXML)
+	}
+
+	if _, err := tmpl.RenderRedline(TemplateData{}, TemplateData{"name": "Ada"}); err != nil {
+		t.Errorf("RenderRedline with a missing variable failed: %v", err)
+	}
+}
+
+func TestDiffSequences(t *testing.T) {
+	ops := diffSequences([]string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d", "e"})
+	var got strings.Builder
+	for _, op := range ops {
+		got.WriteByte(op.kind)
+	}
+	if got.String() != "=-+==+" {
+		t.Errorf("diffSequences ops = %s", got.String())
+	}
+}
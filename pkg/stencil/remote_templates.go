@@ -0,0 +1,204 @@
+package stencil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RemoteTemplate is a template revision returned by a RemoteTemplateSource.
+type RemoteTemplate struct {
+	// Content is the DOCX template. It is empty when NotModified is set.
+	Content []byte
+	// ETag identifies the revision, such as the ETag header of an HTTP
+	// response. It is passed back to the source on the next fetch.
+	ETag string
+	// NotModified reports that the revision passed to FetchTemplate is
+	// still current.
+	NotModified bool
+}
+
+// RemoteTemplateSource fetches published templates by name, such as from a
+// template registry service. FetchTemplate returns a RemoteTemplate with
+// NotModified set when etag, the ETag of the revision the engine holds, is
+// still current; etag is empty on the first fetch. It is called
+// concurrently for different names.
+type RemoteTemplateSource interface {
+	FetchTemplate(ctx context.Context, name, etag string) (*RemoteTemplate, error)
+}
+
+// RemoteTemplateSourceFunc adapts a function into a RemoteTemplateSource.
+type RemoteTemplateSourceFunc func(ctx context.Context, name, etag string) (*RemoteTemplate, error)
+
+// FetchTemplate fetches the template named name.
+func (f RemoteTemplateSourceFunc) FetchTemplate(ctx context.Context, name, etag string) (*RemoteTemplate, error) {
+	return f(ctx, name, etag)
+}
+
+// remoteTemplateEntry is a template prepared from a remote source.
+type remoteTemplateEntry struct {
+	mu      sync.Mutex
+	tmpl    *PreparedTemplate
+	etag    string
+	checked time.Time
+}
+
+// remoteTemplates holds the templates an engine prepared from its remote
+// source, by name.
+type remoteTemplates struct {
+	source   RemoteTemplateSource
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*remoteTemplateEntry
+}
+
+// SetRemoteTemplateSource makes PrepareRemote prepare templates fetched from
+// source. A template is checked for a new revision when it is requested
+// more than interval after its last check; zero checks on every request.
+// Set the source before preparing templates; setting it again drops the
+// templates prepared from the previous source.
+//
+// Example:
+//
+//	engine.SetRemoteTemplateSource(stencil.NewHTTPTemplateSource("https://templates.example.com/published", nil), time.Minute)
+//	tmpl, err := engine.PrepareRemote(ctx, "invoice.docx")
+//	if err != nil {
+//	    return err
+//	}
+//	defer tmpl.Close()
+func (e *Engine) SetRemoteTemplateSource(source RemoteTemplateSource, interval time.Duration) {
+	previous := e.remote
+	e.remote = &remoteTemplates{
+		source:   source,
+		interval: interval,
+		entries:  make(map[string]*remoteTemplateEntry),
+	}
+	if previous != nil {
+		previous.closeAll()
+	}
+}
+
+// PrepareRemote returns the latest revision of the template named name from
+// the engine's remote source, preparing it when it is new or has changed.
+// When a check for a new revision fails, the revision prepared before is
+// returned and a warning is logged. The caller should close the returned
+// template; the engine keeps its own reference.
+func (e *Engine) PrepareRemote(ctx context.Context, name string) (*PreparedTemplate, error) {
+	if e.remote == nil || e.remote.source == nil {
+		return nil, errors.New("no remote template source configured; use SetRemoteTemplateSource")
+	}
+	if len(e.trustedKeys) > 0 {
+		return nil, withErrorCode(ErrorCodeUntrustedTemplate, errors.New("engine requires signed templates; remote templates are not verified"))
+	}
+
+	entry := e.remote.entry(name)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.tmpl == nil || time.Since(entry.checked) >= e.remote.interval {
+		if err := e.refreshRemoteTemplate(ctx, name, entry); err != nil {
+			if entry.tmpl == nil {
+				return nil, err
+			}
+			Warn("using previous revision of remote template %s: %v", name, err)
+		}
+	}
+
+	handle, ok := entry.tmpl.cloneHandle()
+	if !ok {
+		return nil, fmt.Errorf("remote template %s is closed", name)
+	}
+	return handle, nil
+}
+
+// RefreshRemoteTemplates checks every template prepared with PrepareRemote
+// for a new revision now, regardless of the check interval, such as from a
+// background ticker or a webhook. It returns the errors of the checks that
+// failed; those templates keep their previous revision.
+func (e *Engine) RefreshRemoteTemplates(ctx context.Context) error {
+	if e.remote == nil {
+		return nil
+	}
+
+	e.remote.mu.Lock()
+	names := make([]string, 0, len(e.remote.entries))
+	entries := make([]*remoteTemplateEntry, 0, len(e.remote.entries))
+	for name, entry := range e.remote.entries {
+		names = append(names, name)
+		entries = append(entries, entry)
+	}
+	e.remote.mu.Unlock()
+
+	var errs []error
+	for i, entry := range entries {
+		entry.mu.Lock()
+		if entry.tmpl != nil {
+			if err := e.refreshRemoteTemplate(ctx, names[i], entry); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		entry.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// refreshRemoteTemplate fetches the template name and prepares it when its
+// revision changed. The caller holds entry.mu.
+func (e *Engine) refreshRemoteTemplate(ctx context.Context, name string, entry *remoteTemplateEntry) error {
+	etag := entry.etag
+	if entry.tmpl == nil {
+		etag = ""
+	}
+	fetched, err := e.remote.source.FetchTemplate(ctx, name, etag)
+	if err != nil {
+		return fmt.Errorf("fetch remote template %s: %w", name, err)
+	}
+	if fetched == nil {
+		return fmt.Errorf("fetch remote template %s: no template returned", name)
+	}
+	entry.checked = time.Now()
+	if fetched.NotModified && entry.tmpl != nil {
+		return nil
+	}
+
+	tmpl, err := e.prepare(bytes.NewReader(fetched.Content))
+	if err != nil {
+		return fmt.Errorf("prepare remote template %s: %w", name, err)
+	}
+	if entry.tmpl != nil {
+		entry.tmpl.Close()
+	}
+	entry.tmpl = tmpl
+	entry.etag = fetched.ETag
+	return nil
+}
+
+// entry returns the entry of the template name, adding it if needed.
+func (r *remoteTemplates) entry(name string) *remoteTemplateEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[name]
+	if !ok {
+		entry = &remoteTemplateEntry{}
+		r.entries[name] = entry
+	}
+	return entry
+}
+
+// closeAll releases the engine's references to its remote templates.
+func (r *remoteTemplates) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, entry := range r.entries {
+		entry.mu.Lock()
+		if entry.tmpl != nil {
+			entry.tmpl.Close()
+		}
+		entry.mu.Unlock()
+		delete(r.entries, name)
+	}
+}
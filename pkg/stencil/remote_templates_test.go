@@ -0,0 +1,22 @@
+package stencil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEnginePrepareRemoteErrors(t *testing.T) {
+	engine := New()
+	if _, err := engine.PrepareRemote(context.Background(), "a.docx"); err == nil {
+		t.Error("PrepareRemote succeeded without a source")
+	}
+
+	engine.SetRemoteTemplateSource(RemoteTemplateSourceFunc(func(ctx context.Context, name, etag string) (*RemoteTemplate, error) {
+		return nil, errors.New("registry unavailable")
+	}), 0)
+	if _, err := engine.PrepareRemote(context.Background(), "a.docx"); err == nil || !strings.Contains(err.Error(), "registry unavailable") {
+		t.Errorf("PrepareRemote error = %v, want the fetch error", err)
+	}
+}
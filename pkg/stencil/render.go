@@ -183,6 +183,12 @@ func RenderParagraphWithContext(para *Paragraph, data TemplateData, ctx *renderC
 				rendered.Hyperlinks = append(rendered.Hyperlinks, *renderedHyperlink)
 			case *ProofErr:
 				rendered.Content = append(rendered.Content, c)
+			case *AlternateContent:
+				renderedAlternate, err := renderAlternateContent(c, data, ctx)
+				if err != nil {
+					return nil, err
+				}
+				rendered.Content = append(rendered.Content, renderedAlternate)
 			}
 		}
 	} else {
@@ -465,7 +471,7 @@ func renderInlineControlRuns(runs []Run, startIdx int, data TemplateData, ctx *r
 }
 
 func renderInlineForRuns(runs []Run, startIdx int, loopExpr string, data TemplateData, ctx *renderContext) ([]Run, int, error) {
-	endIdx, err := findInlineBlockEndInRuns(runs, startIdx)
+	branches, endIdx, err := findInlineIfBranchesInRuns(runs, startIdx)
 	if err != nil {
 		return nil, startIdx, err
 	}
@@ -475,24 +481,32 @@ func renderInlineForRuns(runs []Run, startIdx int, loopExpr string, data Templat
 		return nil, startIdx, fmt.Errorf("failed to parse inline for syntax: %w", err)
 	}
 
+	if err := forNode.checkShadowing(data); err != nil {
+		return nil, startIdx, err
+	}
+
 	collectionValue, err := forNode.Collection.Evaluate(data)
 	if err != nil {
 		return nil, startIdx, fmt.Errorf("failed to evaluate inline collection: %w", err)
 	}
 
-	items, err := toSlice(collectionValue)
+	items, err := collectionItems(forNode.Collection, collectionValue)
 	if err != nil {
 		return nil, startIdx, fmt.Errorf("failed to iterate over inline collection: %w", err)
 	}
 
 	bodyRuns := runs[startIdx+1 : endIdx]
+	// Runs after an {{else}} render in place of an empty collection
+	if len(branches) > 0 && branches[0].branchType == "else" {
+		bodyRuns = runs[startIdx+1 : branches[0].index]
+		if len(items) == 0 {
+			rendered, _, err := renderInlineControlRuns(runs[branches[0].index+1:endIdx], 0, data, ctx)
+			return rendered, endIdx + 1, err
+		}
+	}
 	rendered := make([]Run, 0, len(bodyRuns)*len(items))
 	for idx, item := range items {
-		loopData := newChildTemplateData(data, 2)
-		loopData[forNode.Variable] = item
-		if forNode.IndexVar != "" {
-			loopData[forNode.IndexVar] = idx
-		}
+		loopData := forNode.iterationData(data, idx, item)
 
 		bodyRendered, nextIdx, err := renderInlineControlRuns(bodyRuns, 0, loopData, ctx)
 		if err != nil {
@@ -607,29 +621,6 @@ func findInlineIfBranchesInRuns(runs []Run, startIdx int) ([]inlineRunBranch, in
 	return nil, -1, fmt.Errorf("no matching {{end}} found for inline control structure")
 }
 
-func findInlineBlockEndInRuns(runs []Run, startIdx int) (int, error) {
-	depth := 1
-
-	for i := startIdx + 1; i < len(runs); i++ {
-		tagType, _, isControlTag := parseInlineControlTag(runs[i])
-		if !isControlTag {
-			continue
-		}
-
-		switch tagType {
-		case "if", "unless", "for":
-			depth++
-		case "end":
-			depth--
-			if depth == 0 {
-				return i, nil
-			}
-		}
-	}
-
-	return -1, fmt.Errorf("no matching {{end}} found for inline control structure")
-}
-
 func parseInlineControlTag(run Run) (string, string, bool) {
 	if run.Text == nil {
 		return "", "", false
@@ -666,12 +657,12 @@ func parseInlineControlTag(run Run) (string, string, bool) {
 }
 
 func renderInlineIncludeRun(run Run, fragmentNameExpr string, data TemplateData, ctx *renderContext) ([]Run, error) {
-	expr, err := ParseExpression(fragmentNameExpr)
+	expr, modifiers, err := parseIncludeDirective(fragmentNameExpr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse include fragment name: %w", err)
 	}
 
-	renderedText, err := (&IncludeNode{FragmentName: expr}).RenderWithContext(data, ctx)
+	renderedText, err := (&IncludeNode{FragmentName: expr, modifiers: modifiers}).RenderWithContext(data, ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -716,6 +707,14 @@ func RenderRunWithContext(run *Run, data TemplateData, ctx *renderContext) (*Run
 		rendered.Text = renderedText
 	}
 
+	if len(run.RawXML) > 0 {
+		renderedRawXML, err := renderDrawingProperties(run.RawXML, data, ctx)
+		if err != nil {
+			return nil, err
+		}
+		rendered.RawXML = renderedRawXML
+	}
+
 	return rendered, nil
 }
 
@@ -871,67 +870,15 @@ func expandOOXMLFragments(run *Run, _ TemplateData, ctx *renderContext) ([]Run,
 		}
 
 		if fragmentContent != nil {
-			switch content := fragmentContent.(type) {
-			case *Break:
-				// Page break
-				breakRun := Run{
-					Properties: run.Properties,
-					Attrs:      run.Attrs,
-					Break:      content,
-				}
-				runs = append(runs, breakRun)
-
-			case *HTMLRuns:
-				// HTML runs - expand into multiple runs
-				for _, htmlRun := range content.Runs {
-					newRun := Run{
-						Properties: htmlRun.Properties,
-						Attrs:      run.Attrs,
-					}
-
-					// Convert HTML run elements to text/breaks
-					for _, elem := range htmlRun.Content {
-						switch elem.Type {
-						case "text":
-							if newRun.Text == nil {
-								newRun.Text = &Text{
-									XMLName: run.Text.XMLName,
-									Space:   run.Text.Space,
-									Content: elem.Text,
-								}
-							} else {
-								newRun.Text.Content += elem.Text
-							}
-						case "break":
-							// If there's already text, create a new run for the break
-							if newRun.Text != nil {
-								runs = append(runs, newRun)
-								newRun = Run{
-									Properties: htmlRun.Properties,
-									Attrs:      run.Attrs,
-									Break:      &Break{}, // Empty break for line break
-								}
-							} else {
-								newRun.Break = &Break{} // Empty break for line break
-							}
-						}
-					}
-
-					// Add the final run if it has content
-					if newRun.Text != nil || newRun.Break != nil {
-						runs = append(runs, newRun)
-					}
-				}
-
-			case *XMLFragment:
-				// XML fragment - convert XML elements to runs
-				for _, elem := range content.Elements {
-					expandedRuns := convertXMLElementToRuns(elem, run)
-					runs = append(runs, expandedRuns...)
-				}
-
-			default:
-				// Unknown fragment type, preserve as text
+			expanded, inline, err := fragmentContentRuns(fragmentContent, run, ctx)
+			if err != nil {
+				return nil, err
+			}
+			if inline {
+				runs = append(runs, expanded...)
+			} else {
+				// Paragraph and body content stays a placeholder until
+				// the paragraph is replaced by it
 				fragmentRun := Run{
 					Properties: run.Properties,
 					Attrs:      run.Attrs,
@@ -997,6 +944,148 @@ func expandOOXMLFragments(run *Run, _ TemplateData, ctx *renderContext) ([]Run,
 	return runs, nil
 }
 
+// fragmentContentRuns returns the runs of run-level fragment content, using
+// run as the template for their formatting. It reports false for paragraph
+// and body content, and an error for content it cannot insert.
+func fragmentContentRuns(fragmentContent interface{}, run *Run, ctx *renderContext) ([]Run, bool, error) {
+	level, err := fragmentContentLevel(fragmentContent)
+	if err != nil {
+		return nil, false, err
+	}
+	if level != FragmentLevelRun {
+		return nil, false, nil
+	}
+
+	var runs []Run
+	switch content := fragmentContent.(type) {
+	case *Break:
+		// Page break
+		breakRun := Run{
+			Properties: run.Properties,
+			Attrs:      run.Attrs,
+			Break:      content,
+		}
+		runs = append(runs, breakRun)
+
+	case *HTMLRuns:
+		// HTML runs - expand into multiple runs
+		for _, htmlRun := range content.Runs {
+			newRun := Run{
+				Properties: htmlRun.Properties,
+				Attrs:      run.Attrs,
+			}
+
+			// Convert HTML run elements to text/breaks
+			for _, elem := range htmlRun.Content {
+				switch elem.Type {
+				case "text":
+					if newRun.Text == nil {
+						newRun.Text = &Text{
+							XMLName: run.Text.XMLName,
+							Space:   run.Text.Space,
+							Content: elem.Text,
+						}
+					} else {
+						newRun.Text.Content += elem.Text
+					}
+				case "break":
+					// If there's already text, create a new run for the break
+					if newRun.Text != nil {
+						runs = append(runs, newRun)
+						newRun = Run{
+							Properties: htmlRun.Properties,
+							Attrs:      run.Attrs,
+							Break:      &Break{}, // Empty break for line break
+						}
+					} else {
+						newRun.Break = &Break{} // Empty break for line break
+					}
+				}
+			}
+
+			// Add the final run if it has content
+			if newRun.Text != nil || newRun.Break != nil {
+				runs = append(runs, newRun)
+			}
+		}
+
+	case *Run:
+		// Run built by a function, such as with NewRun. Without formatting
+		// of its own it takes the formatting of the expression.
+		built := *content
+		if built.Properties == nil {
+			built.Properties = run.Properties
+		}
+		runs = append(runs, built)
+
+	case *FieldCode:
+		// Word field - expand into begin/instruction/separate/result/end runs
+		runs = append(runs, fieldCodeRuns(content, run, ctx)...)
+
+	case *XMLFragment:
+		// XML fragment - convert XML elements to runs
+		for _, elem := range content.Elements {
+			expandedRuns := convertXMLElementToRuns(elem, run)
+			runs = append(runs, expandedRuns...)
+		}
+
+	case *embeddedFile:
+		// File embedded as an object shown as an icon
+		embedRuns, err := content.runs(run, ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		runs = append(runs, embedRuns...)
+
+	case *indexTableField:
+		// INDEX field of the keyword index
+		runs = append(runs, content.runs(run, ctx)...)
+
+	case *captionField:
+		// Caption numbered with a SEQ field
+		runs = append(runs, content.runs(run, ctx)...)
+
+	case *definedTerm:
+		// Term recorded for the glossary
+		runs = append(runs, content.run(run, ctx))
+
+	case *editableRegion:
+		// Value in an editable region
+		editableRuns, err := content.runs(run, ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		runs = append(runs, editableRuns...)
+
+	case *editableBoundary:
+		// Start or end of an editable region
+		boundaryRun, err := content.run(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		runs = append(runs, boundaryRun)
+
+	case *macroCall:
+		// Call of a macro whose body is a single paragraph
+		macroRuns, err := content.runs(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		runs = append(runs, macroRuns...)
+
+	case *FragmentGroup:
+		// Fragments composed with Fragments
+		for _, part := range content.Parts {
+			partRuns, _, err := fragmentContentRuns(part, run, ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			runs = append(runs, partRuns...)
+		}
+	}
+	return runs, true, nil
+}
+
 // RenderText renders text content with variable substitution
 func RenderText(text *Text, data TemplateData) (*Text, error) {
 	return RenderTextWithContext(text, data, nil)
@@ -1039,6 +1128,15 @@ func RenderTextWithContext(text *Text, data TemplateData, ctx *renderContext) (*
 				} else if marker, ok := value.(*TableColumnMarker); ok {
 					// Handle table column markers
 					result.WriteString(marker.String())
+				} else if marker, ok := value.(*TableMarker); ok {
+					// Handle table markers
+					result.WriteString(marker.String())
+				} else if marker, ok := value.(*TableSortMarker); ok {
+					// Handle table sort markers
+					result.WriteString(marker.String())
+				} else if marker, ok := value.(*ParagraphMarker); ok {
+					// Handle paragraph markers
+					result.WriteString(marker.String())
 				} else if marker, ok := value.(LinkReplacementMarker); ok {
 					// Handle link replacement markers
 					if ctx != nil {
@@ -1051,7 +1149,7 @@ func RenderTextWithContext(text *Text, data TemplateData, ctx *renderContext) (*
 						result.WriteString("")
 					}
 				} else {
-					result.WriteString(FormatValue(value))
+					result.WriteString(ctx.formatText(value))
 				}
 			} else {
 				// Fall back to simple variable evaluation for backward compatibility
@@ -1077,6 +1175,15 @@ func RenderTextWithContext(text *Text, data TemplateData, ctx *renderContext) (*
 				} else if marker, ok := value.(*TableColumnMarker); ok {
 					// Handle table column markers
 					result.WriteString(marker.String())
+				} else if marker, ok := value.(*TableMarker); ok {
+					// Handle table markers
+					result.WriteString(marker.String())
+				} else if marker, ok := value.(*TableSortMarker); ok {
+					// Handle table sort markers
+					result.WriteString(marker.String())
+				} else if marker, ok := value.(*ParagraphMarker); ok {
+					// Handle paragraph markers
+					result.WriteString(marker.String())
 				} else if marker, ok := value.(LinkReplacementMarker); ok {
 					// Handle link replacement markers
 					if ctx != nil {
@@ -1089,7 +1196,7 @@ func RenderTextWithContext(text *Text, data TemplateData, ctx *renderContext) (*
 						result.WriteString("")
 					}
 				} else {
-					result.WriteString(FormatValue(value))
+					result.WriteString(ctx.formatText(value))
 				}
 			}
 		case TokenPageBreak:
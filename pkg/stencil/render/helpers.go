@@ -124,19 +124,20 @@ func mergeConsecutiveRunsWithContent(para *xml.Paragraph) {
 
 	var mergedContent []xml.ParagraphContent
 	var pendingRuns []xml.Run
+	// deferredContent holds non-run content found inside a split template
+	// token. It is emitted after the merged token so the token stays whole.
+	var deferredContent []xml.ParagraphContent
 
 	// Helper function to merge pending runs
 	mergePendingRuns := func() {
-		if len(pendingRuns) == 0 {
-			return
-		}
-
 		merged := mergeRunSlice(pendingRuns)
 		for _, run := range merged {
 			r := run // Create a new variable to avoid aliasing
 			mergedContent = append(mergedContent, &r)
 		}
 		pendingRuns = nil
+		mergedContent = append(mergedContent, deferredContent...)
+		deferredContent = nil
 	}
 
 	// Process content elements
@@ -145,6 +146,9 @@ func mergeConsecutiveRunsWithContent(para *xml.Paragraph) {
 		case *xml.Run:
 			// Accumulate runs outside hyperlinks
 			pendingRuns = append(pendingRuns, *c)
+			if len(deferredContent) > 0 && !runsNeedTokenLookahead(pendingRuns) {
+				mergePendingRuns()
+			}
 
 		case *xml.Hyperlink:
 			// First, merge any pending runs
@@ -161,7 +165,13 @@ func mergeConsecutiveRunsWithContent(para *xml.Paragraph) {
 			}
 
 		default:
-			// Preserve non-run paragraph content (e.g. proofErr) at original position.
+			// Move non-run paragraph content (e.g. proofErr) found inside a
+			// split template token behind the token; otherwise keep it at its
+			// original position.
+			if runsNeedTokenLookahead(pendingRuns) {
+				deferredContent = append(deferredContent, c)
+				continue
+			}
 			mergePendingRuns()
 			mergedContent = append(mergedContent, c)
 		}
@@ -309,6 +319,11 @@ func mergeTemplateExpressionRuns(runs []xml.Run) []xml.Run {
 		}
 
 		formatRun := selectTemplateExpressionFormatRun(runs[i : j+1])
+		preserved := nonTextRunContent(runs[i : j+1])
+		var tail *xml.Run
+		if len(preserved) > 0 {
+			tail = splitTemplateExpressionTail(&mergedText, runs[j])
+		}
 		combinedRun := xml.Run{
 			Properties: formatRun.Properties,
 			Attrs:      formatRun.Attrs,
@@ -319,6 +334,12 @@ func mergeTemplateExpressionRuns(runs []xml.Run) []xml.Run {
 			},
 		}
 		result = append(result, combinedRun)
+		// Keep field characters, comment references and other non-text
+		// content of the merged runs, placed behind the reassembled token.
+		result = append(result, preserved...)
+		if tail != nil {
+			result = append(result, *tail)
+		}
 		if merged || j > i {
 			i = j + 1
 		} else {
@@ -329,6 +350,68 @@ func mergeTemplateExpressionRuns(runs []xml.Run) []xml.Run {
 	return result
 }
 
+// nonTextRunContent returns the non-text content of runs that are merged into
+// a template expression: runs without text and the RawXML of runs with text.
+// The text of these runs becomes part of the merged expression.
+func nonTextRunContent(runs []xml.Run) []xml.Run {
+	var preserved []xml.Run
+	for _, run := range runs {
+		switch {
+		case run.Text == nil:
+			preserved = append(preserved, run)
+		case len(run.RawXML) > 0 || run.Break != nil:
+			preserved = append(preserved, xml.Run{
+				Properties: run.Properties,
+				Attrs:      run.Attrs,
+				Break:      run.Break,
+				RawXML:     run.RawXML,
+			})
+		}
+	}
+	return preserved
+}
+
+// splitTemplateExpressionTail removes the text after the last closing marker
+// from mergedText when all of it comes from last, and returns it as a run
+// with the formatting of last. This keeps text that follows a reassembled
+// token behind the non-text content moved out of the token.
+func splitTemplateExpressionTail(mergedText *string, last xml.Run) *xml.Run {
+	end := strings.LastIndex(*mergedText, "}}")
+	if end < 0 || last.Text == nil {
+		return nil
+	}
+	end += len("}}")
+	tailText := (*mergedText)[end:]
+	if tailText == "" || len(tailText) >= len(last.Text.Content) {
+		return nil
+	}
+	*mergedText = (*mergedText)[:end]
+	return &xml.Run{
+		Properties: last.Properties,
+		Attrs:      last.Attrs,
+		Text: &xml.Text{
+			XMLName: last.Text.XMLName,
+			Space:   "preserve",
+			Content: tailText,
+		},
+	}
+}
+
+// runsNeedTokenLookahead reports whether the text of runs ends inside a
+// template token.
+func runsNeedTokenLookahead(runs []xml.Run) bool {
+	if len(runs) == 0 {
+		return false
+	}
+	state := newTemplateMarkerState()
+	for _, run := range runs {
+		if run.Text != nil {
+			state.scan(run.Text.Content)
+		}
+	}
+	return state.needsMergeLookahead()
+}
+
 func selectTemplateExpressionFormatRun(runs []xml.Run) xml.Run {
 	if len(runs) == 0 {
 		return xml.Run{}
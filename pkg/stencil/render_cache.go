@@ -0,0 +1,407 @@
+package stencil
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// templateCacheIDs numbers templates, so rendered documents of templates
+// with their own fragments or macros are cached apart.
+var templateCacheIDs atomic.Uint64
+
+// registryCacheIDs numbers function registries, so rendered documents of
+// templates calling different functions are cached apart.
+var registryCacheIDs atomic.Uint64
+
+// maxCacheKeyDepth bounds the nesting of the values hashed into a cache key.
+const maxCacheKeyDepth = 64
+
+// maxCacheKeyBytes bounds the encoding of the values hashed into a cache
+// key, so renders with huge data are not cached rather than hashed at
+// length.
+const maxCacheKeyBytes = 8 << 20
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// renderCache keeps rendered documents keyed by template revision and
+// render data, for WithRenderCache.
+type renderCache struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+}
+
+// renderCacheEntry is a rendered document in a renderCache.
+type renderCacheEntry struct {
+	key     string
+	output  []byte
+	expires time.Time
+}
+
+// WithRenderCache returns an option that caches the documents rendered from
+// the engine's templates. Rendering the same template revision again with
+// identical data and options returns the cached document, which helps
+// preview endpoints that receive the same request many times. The cache
+// holds at most maxBytes of documents, dropping the least recently used
+// first, and keeps each for ttl; a ttl of 0 keeps documents until they are
+// dropped for space.
+//
+// Output that depends on anything but the template, the render data, the
+// render options, the global data, the constants, the registered functions
+// and the pre- and post-processors is frozen at the first render: uuid(),
+// random(), now() and sequence() return their first values, and fragments
+// fetched from URLs are not fetched again. Renders of engines with value
+// providers or a feature provider, renders with data holding functions,
+// channels or cycles or taking more than 8 MiB to encode into the cache key,
+// and renders with an OnRepairHint, an OnWarning, a SequenceStore or a
+// PersonalData report are never cached. Frozen templates are not cached.
+func WithRenderCache(maxBytes int64, ttl time.Duration) Option {
+	return func(e *Engine) {
+		e.data.mu.Lock()
+		e.data.renderCache = &renderCache{
+			maxBytes: maxBytes,
+			ttl:      ttl,
+			entries:  make(map[string]*list.Element),
+			lru:      list.New(),
+		}
+		e.data.mu.Unlock()
+	}
+}
+
+// renderCacheKey returns the cache key of a render of tmpl with the
+// functions of registry and whether the render may be cached.
+func (d *engineData) renderCacheKey(tmpl *template, registry FunctionRegistry, data TemplateData, opts *RenderOptions) (*renderCache, string, bool) {
+	if d == nil {
+		return nil, "", false
+	}
+	d.mu.RLock()
+	cache, global, constants, plurals, providers := d.renderCache, d.global, d.constants, d.plurals, len(d.providers)
+	features, processorRevision := d.features, d.processorRevision
+	d.mu.RUnlock()
+	if cache == nil || providers > 0 || features != nil {
+		return nil, "", false
+	}
+
+	functions, ok := registryCacheKey(registry)
+	if !ok {
+		return nil, "", false
+	}
+	options, ok := renderOptionsCacheKey(opts)
+	if !ok {
+		return nil, "", false
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%d\x00", tmpl.renderCacheKey(), functions, processorRevision)
+	encoder := newCacheKeyEncoder(hash)
+	for _, value := range []interface{}{map[string]interface{}(data), map[string]interface{}(global), constants, plurals, options} {
+		if !encoder.write(reflect.ValueOf(value), 0) {
+			return nil, "", false
+		}
+	}
+	return cache, hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// registryCacheKey identifies registry and the revision of its functions.
+// Only a DefaultFunctionRegistry counts its changes, so renders with other
+// registries are not cached.
+func registryCacheKey(registry FunctionRegistry) (string, bool) {
+	if registry == nil {
+		return "", true
+	}
+	defaults, ok := registry.(*DefaultFunctionRegistry)
+	if !ok {
+		return "", false
+	}
+	defaults.mutex.RLock()
+	defer defaults.mutex.RUnlock()
+	id := defaults.cacheID.Load()
+	if id == 0 {
+		defaults.cacheID.CompareAndSwap(0, registryCacheIDs.Add(1))
+		id = defaults.cacheID.Load()
+	}
+	return fmt.Sprintf("%d.%d", id, defaults.revision), true
+}
+
+// cacheKeyEncoder writes an encoding of values to w that differs for values
+// of different types or content, including unexported struct fields, which
+// JSON would leave out. Maps, pointers and slices met again are written as
+// a reference to their first encoding, so data sharing subtrees is encoded
+// once per subtree rather than once per path to it. An encoder gives up on
+// values that cannot be compared by content, such as functions and
+// channels, on cycles, on values nested deeper than maxCacheKeyDepth and
+// once it has written maxCacheKeyBytes.
+type cacheKeyEncoder struct {
+	w       io.Writer
+	budget  *int64
+	visited map[cacheKeyRef]cacheKeyVisit
+}
+
+// cacheKeyRef identifies a map, pointer or slice by its type, address and,
+// for slices, length.
+type cacheKeyRef struct {
+	typ     reflect.Type
+	pointer uintptr
+	length  int
+}
+
+// cacheKeyVisit numbers an encoded reference and tells whether its encoding
+// is complete; a reference met again before that is a cycle.
+type cacheKeyVisit struct {
+	id   int
+	done bool
+}
+
+func newCacheKeyEncoder(w io.Writer) *cacheKeyEncoder {
+	budget := int64(maxCacheKeyBytes)
+	return &cacheKeyEncoder{w: w, budget: &budget, visited: make(map[cacheKeyRef]cacheKeyVisit)}
+}
+
+// printf writes to the encoding and reports whether it is within the byte
+// budget.
+func (e *cacheKeyEncoder) printf(format string, args ...interface{}) bool {
+	n, _ := fmt.Fprintf(e.w, format, args...)
+	*e.budget -= int64(n)
+	return *e.budget >= 0
+}
+
+// write encodes value.
+func (e *cacheKeyEncoder) write(value reflect.Value, depth int) bool {
+	if depth > maxCacheKeyDepth {
+		return false
+	}
+	if !value.IsValid() {
+		return e.printf("n")
+	}
+	if value.Type() == timeType && value.CanInterface() {
+		t := value.Interface().(time.Time)
+		return e.printf("T%s %s;", t.Format(time.RFC3339Nano), t.Location())
+	}
+
+	switch value.Kind() {
+	case reflect.Map, reflect.Pointer, reflect.Slice:
+		if value.IsNil() {
+			return e.printf("n")
+		}
+		ref := cacheKeyRef{typ: value.Type(), pointer: value.Pointer()}
+		if value.Kind() == reflect.Slice {
+			ref.length = value.Len()
+		}
+		if visit, ok := e.visited[ref]; ok {
+			return visit.done && e.printf("r%d;", visit.id)
+		}
+		visit := cacheKeyVisit{id: len(e.visited)}
+		e.visited[ref] = visit
+		if !e.writeContent(value, depth) {
+			return false
+		}
+		visit.done = true
+		e.visited[ref] = visit
+		return true
+	}
+	return e.writeContent(value, depth)
+}
+
+// writeContent encodes value without checking for references.
+func (e *cacheKeyEncoder) writeContent(value reflect.Value, depth int) bool {
+	switch value.Kind() {
+	case reflect.Bool:
+		return e.printf("b%t;", value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.printf("i%d;", value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.printf("u%d;", value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return e.printf("f%s;", strconv.FormatFloat(value.Float(), 'g', -1, 64))
+	case reflect.Complex64, reflect.Complex128:
+		return e.printf("c%v;", value.Complex())
+	case reflect.String:
+		return e.printf("s%d:%s", value.Len(), value.String())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+			return e.printf("x%d:%s", value.Len(), value.Bytes())
+		}
+		if !e.printf("l%d:", value.Len()) {
+			return false
+		}
+		for i := 0; i < value.Len(); i++ {
+			if !e.write(value.Index(i), depth+1) {
+				return false
+			}
+		}
+	case reflect.Map:
+		type entry struct {
+			key   []byte
+			value reflect.Value
+		}
+		entries := make([]entry, 0, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			// Keys are encoded on their own, as the order they are met in
+			// would number their references differently per render.
+			var key bytes.Buffer
+			keys := &cacheKeyEncoder{w: &key, budget: e.budget, visited: make(map[cacheKeyRef]cacheKeyVisit)}
+			if !keys.write(iter.Key(), depth+1) {
+				return false
+			}
+			entries = append(entries, entry{key: key.Bytes(), value: iter.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+		if !e.printf("m%d:", len(entries)) {
+			return false
+		}
+		for _, entry := range entries {
+			if !e.printf("%s", entry.key) || !e.write(entry.value, depth+1) {
+				return false
+			}
+		}
+	case reflect.Pointer:
+		return e.printf("p") && e.write(value.Elem(), depth+1)
+	case reflect.Interface:
+		if value.IsNil() {
+			return e.printf("n")
+		}
+		return e.printf("I%s;", value.Elem().Type()) && e.write(value.Elem(), depth+1)
+	case reflect.Struct:
+		if !e.printf("S%s;", value.Type()) {
+			return false
+		}
+		for i := 0; i < value.NumField(); i++ {
+			if !e.write(value.Field(i), depth+1) {
+				return false
+			}
+		}
+	default:
+		// Functions, channels and unsafe pointers
+		if value.IsNil() {
+			return e.printf("n")
+		}
+		return false
+	}
+	return true
+}
+
+// renderOptionsCacheKey returns the options that affect the rendered
+// document. Options holding functions or interfaces cannot be compared, so
+// a render that sets them is not cached; the trace context does not affect
+// the document and is ignored.
+func renderOptionsCacheKey(opts *RenderOptions) (map[string]interface{}, bool) {
+	key := make(map[string]interface{})
+	if opts == nil {
+		return key, true
+	}
+	value := reflect.ValueOf(*opts)
+	for i := 0; i < value.NumField(); i++ {
+		field, fieldType := value.Field(i), value.Type().Field(i)
+		if !fieldType.IsExported() || fieldType.Name == "TraceContext" {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Func, reflect.Interface, reflect.Chan:
+			if !field.IsNil() {
+				return nil, false
+			}
+			continue
+		}
+		key[fieldType.Name] = field.Interface()
+	}
+	return key, true
+}
+
+// renderCacheKey identifies the revision of the template. Templates with
+// only the content of their source share documents; templates with
+// fragments, a fragment resolver or added macros are keyed by their
+// identity and the revision of their fragments.
+func (t *template) renderCacheKey() string {
+	sourceHash := t.sourceDigest()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.fragments) == 0 && t.fragmentResolver == nil && t.macroLibrary == nil && !t.documentReplaced {
+		return sourceHash
+	}
+	id := t.cacheID.Load()
+	if id == 0 {
+		t.cacheID.CompareAndSwap(0, templateCacheIDs.Add(1))
+		id = t.cacheID.Load()
+	}
+	return fmt.Sprintf("%s#%d.%d", sourceHash, id, t.revision)
+}
+
+// sourceDigest returns the hex SHA-256 hash of the template source.
+func (t *template) sourceDigest() string {
+	t.sourceOnce.Do(func() {
+		sum := sha256.Sum256(t.source)
+		t.sourceHash = hex.EncodeToString(sum[:])
+	})
+	return t.sourceHash
+}
+
+// get returns the cached document for key.
+func (c *renderCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*renderCacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.removeLocked(element)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return entry.output, true
+}
+
+// set caches output for key, dropping the least recently used documents
+// to stay within the size limit. Documents larger than the limit are not
+// cached.
+func (c *renderCache) set(key string, output []byte) {
+	size := int64(len(output))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.removeLocked(element)
+	}
+	for c.size+size > c.maxBytes && c.lru.Len() > 0 {
+		c.removeLocked(c.lru.Back())
+	}
+	entry := &renderCacheEntry{key: key, output: output, expires: time.Now().Add(c.ttl)}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += size
+}
+
+// clear removes all cached documents.
+func (c *renderCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
+
+func (c *renderCache) removeLocked(element *list.Element) {
+	entry := c.lru.Remove(element).(*renderCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.output))
+}
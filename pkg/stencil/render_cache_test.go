@@ -0,0 +1,240 @@
+package stencil
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngineRenderCache(t *testing.T) {
+	engine := NewWithOptions(WithRenderCache(10<<20, time.Hour))
+	docx := createDOCXWithParagraphs(t, []string{`Hello {{name}}, id {{uuid()}}`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	render := func(tmpl *PreparedTemplate, data TemplateData, opts RenderOptions) string {
+		t.Helper()
+		output, err := tmpl.RenderWithOptions(data, opts)
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, _ := io.ReadAll(output)
+		return extractTextFromDOCX(t, content)
+	}
+
+	first := render(tmpl, TemplateData{"name": "Ada"}, RenderOptions{})
+	if again := render(tmpl, TemplateData{"name": "Ada"}, RenderOptions{}); again != first {
+		t.Errorf("identical render was not cached: %q, then %q", first, again)
+	}
+	if other := render(tmpl, TemplateData{"name": "Grace"}, RenderOptions{}); !strings.Contains(other, "Hello Grace") {
+		t.Errorf("render with other data = %q", other)
+	}
+	if seeded := render(tmpl, TemplateData{"name": "Ada"}, RenderOptions{Seed: 7}); seeded == first {
+		t.Error("render with other options returned the cached document")
+	}
+
+	// Another template prepared from the same source shares the cache
+	same, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer same.Close()
+	if shared := render(same, TemplateData{"name": "Ada"}, RenderOptions{}); shared != first {
+		t.Errorf("template with the same source did not share the cache: %q", shared)
+	}
+
+	// Changing fragments changes the template revision
+	withFragment, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{include "greeting"}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer withFragment.Close()
+	withFragment.AddFragment("greeting", "Hello")
+	if text := render(withFragment, TemplateData{}, RenderOptions{}); !strings.Contains(text, "Hello") {
+		t.Errorf("render = %q", text)
+	}
+	withFragment.AddFragment("greeting", "Goodbye")
+	if text := render(withFragment, TemplateData{}, RenderOptions{}); !strings.Contains(text, "Goodbye") {
+		t.Errorf("render after replacing the fragment = %q", text)
+	}
+
+	engine.ClearCache()
+	if cleared := render(tmpl, TemplateData{"name": "Ada"}, RenderOptions{}); cleared == first {
+		t.Error("ClearCache kept the rendered document")
+	}
+}
+
+// cacheKeyAmount renders from an unexported field, which JSON leaves out.
+type cacheKeyAmount struct {
+	cents int
+}
+
+func (a cacheKeyAmount) String() string {
+	return fmt.Sprintf("%d.%02d", a.cents/100, a.cents%100)
+}
+
+// cacheKeyCustomer renders from a field JSON skips.
+type cacheKeyCustomer struct {
+	Name string
+	Tier string `json:"-"`
+}
+
+func (c cacheKeyCustomer) String() string {
+	return c.Name + "/" + c.Tier
+}
+
+func TestEngineRenderCacheKeysHiddenFields(t *testing.T) {
+	engine := NewWithOptions(WithRenderCache(10<<20, time.Hour))
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{amount}} {{customer}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	render := func(data TemplateData) string {
+		t.Helper()
+		return extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, data))
+	}
+	if got := render(TemplateData{"amount": cacheKeyAmount{cents: 150}, "customer": cacheKeyCustomer{"Ada", "gold"}}); got != "1.50 Ada/gold" {
+		t.Fatalf("render = %q", got)
+	}
+	if got := render(TemplateData{"amount": cacheKeyAmount{cents: 275}, "customer": cacheKeyCustomer{"Ada", "gold"}}); got != "2.75 Ada/gold" {
+		t.Errorf("render with another unexported field = %q, want 2.75 Ada/gold", got)
+	}
+	if got := render(TemplateData{"amount": cacheKeyAmount{cents: 275}, "customer": cacheKeyCustomer{"Ada", "silver"}}); got != "2.75 Ada/silver" {
+		t.Errorf(`render with another json:"-" field = %q, want 2.75 Ada/silver`, got)
+	}
+
+	cyclic := TemplateData{}
+	cyclic["self"] = cyclic
+	if _, _, ok := engine.data.renderCacheKey(tmpl.template, tmpl.registry, cyclic, &RenderOptions{}); ok {
+		t.Error("cyclic data was cacheable")
+	}
+}
+
+func TestEngineRenderCacheKeysFunctionsAndProcessors(t *testing.T) {
+	engine := NewWithOptions(WithRenderCache(10<<20, time.Hour))
+	engine.registry = NewFunctionRegistry()
+	greeting := func(text string) Function {
+		return NewSimpleFunction("greeting", 0, 0, func(args ...interface{}) (interface{}, error) {
+			return text, nil
+		})
+	}
+	engine.RegisterFunction("greeting", greeting("Hello"))
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{greeting()}} {{name}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	render := func() string {
+		t.Helper()
+		return extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{"name": "Ada"}))
+	}
+	if got := render(); got != "Hello Ada" {
+		t.Fatalf("render = %q", got)
+	}
+	engine.RegisterFunction("greeting", greeting("Goodbye"))
+	if got := render(); got != "Goodbye Ada" {
+		t.Errorf("render after replacing a function = %q, want Goodbye Ada", got)
+	}
+	engine.AddPreProcessor(func(data TemplateData) (TemplateData, error) {
+		data["name"] = "Grace"
+		return data, nil
+	})
+	if got := render(); got != "Goodbye Grace" {
+		t.Errorf("render after adding a pre-processor = %q, want Goodbye Grace", got)
+	}
+	engine.AddPostProcessor(func(doc *Document, data TemplateData) error {
+		return errors.New("post-processor ran")
+	})
+	if _, err := tmpl.Render(TemplateData{"name": "Ada"}); err == nil || !strings.Contains(err.Error(), "post-processor ran") {
+		t.Errorf("render after adding a post-processor returned the cached document, err = %v", err)
+	}
+}
+
+func TestEngineRenderCacheKeysSharedSubtrees(t *testing.T) {
+	engine := NewWithOptions(WithRenderCache(10<<20, time.Hour))
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{name}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	// Each level refers to the next twice, so the data has 2^25 paths to
+	// its leaf.
+	shared := func(leaf string) TemplateData {
+		var level interface{} = map[string]interface{}{"leaf": leaf}
+		for i := 0; i < 25; i++ {
+			level = map[string]interface{}{"left": level, "right": level}
+		}
+		return TemplateData{"name": "Ada", "tree": level}
+	}
+	key := func(data TemplateData) string {
+		t.Helper()
+		_, key, ok := engine.data.renderCacheKey(tmpl.template, tmpl.registry, data, &RenderOptions{})
+		if !ok {
+			t.Fatal("data with shared subtrees was not cacheable")
+		}
+		return key
+	}
+	if key(shared("x")) != key(shared("x")) {
+		t.Error("equal data had different keys")
+	}
+	if key(shared("x")) == key(shared("y")) {
+		t.Error("data with another shared leaf had the same key")
+	}
+
+	// A subtree shared by two fields keys like two equal copies would not,
+	// but both differ from data with another leaf in one of the fields.
+	leaf := map[string]interface{}{"v": 1}
+	sharedTwice := TemplateData{"a": leaf, "b": leaf}
+	changed := TemplateData{"a": leaf, "b": map[string]interface{}{"v": 2}}
+	if key(sharedTwice) == key(changed) {
+		t.Error("data with another second subtree had the same key")
+	}
+
+	huge := TemplateData{"text": strings.Repeat("x", maxCacheKeyBytes)}
+	if _, _, ok := engine.data.renderCacheKey(tmpl.template, tmpl.registry, huge, &RenderOptions{}); ok {
+		t.Error("data over the cache key byte budget was cacheable")
+	}
+}
+
+func TestEngineRenderCacheSkipsUncacheableRenders(t *testing.T) {
+	engine := NewWithOptions(WithRenderCache(10<<20, 0))
+	tmpl, err := engine.Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{uuid()}}`})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	if _, _, ok := engine.data.renderCacheKey(tmpl.template, tmpl.registry, TemplateData{"f": func() {}}, &RenderOptions{}); ok {
+		t.Error("data with a function was cacheable")
+	}
+	if _, _, ok := engine.data.renderCacheKey(tmpl.template, tmpl.registry, TemplateData{}, &RenderOptions{OnRepairHint: func(RepairHint) {}}); ok {
+		t.Error("render with OnRepairHint was cacheable")
+	}
+
+	small := &renderCache{maxBytes: 10, entries: make(map[string]*list.Element), lru: list.New()}
+	small.set("a", []byte("12345"))
+	small.set("b", []byte("67890"))
+	small.set("c", []byte("x"))
+	if _, ok := small.get("a"); ok {
+		t.Error("least recently used document was kept over the size limit")
+	}
+	if _, ok := small.get("c"); !ok {
+		t.Error("newest document was dropped")
+	}
+	small.set("big", make([]byte, 11))
+	if _, ok := small.get("big"); ok {
+		t.Error("document over the size limit was cached")
+	}
+}
@@ -87,7 +87,7 @@ func compileParagraphRenderPlan(para *Paragraph) *paragraphRenderPlan {
 	}
 
 	fullText := buildParagraphRenderText(para)
-	if !strings.Contains(fullText, "{{") {
+	if !strings.Contains(fullText, "{{") && !hasAlternateContentTokens(para) && !hasDrawingPropertyTokens(para) {
 		return &paragraphRenderPlan{
 			mode:     paragraphRenderPlanStatic,
 			fullText: fullText,
@@ -102,16 +102,19 @@ func compileParagraphRenderPlan(para *Paragraph) *paragraphRenderPlan {
 	if len(para.Content) > 0 {
 		hasProofErr := false
 		hasHyperlink := false
+		hasAlternateContent := false
 		for _, content := range para.Content {
 			switch content.(type) {
 			case *ProofErr:
 				hasProofErr = true
 			case *Hyperlink:
 				hasHyperlink = true
+			case *AlternateContent:
+				hasAlternateContent = true
 			}
 		}
 
-		plan.useLegacyRunRendering = hasProofErr && !hasHyperlink
+		plan.useLegacyRunRendering = hasProofErr && !hasHyperlink && !hasAlternateContent
 		if plan.useLegacyRunRendering {
 			baseRuns := para.Runs
 			if len(baseRuns) == 0 {
@@ -2,7 +2,6 @@ package stencil
 
 import (
 	"fmt"
-	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -73,7 +72,11 @@ func expandDOCXFragmentParagraph(
 
 		fragmentElements, err := renderFragment(fragmentName, frag)
 		if err != nil {
-			return nil, false, fmt.Errorf("failed to render fragment %s: %w", fragmentName, err)
+			return nil, false, err
+		}
+		if mods, ok := ctx.includeModifiers[markerKey]; ok {
+			applyIncludeModifiers(fragmentElements, mods, ctx)
+			delete(ctx.includeModifiers, markerKey)
 		}
 
 		if len(fragmentElements) > 0 {
@@ -133,38 +136,41 @@ func expandDOCXFragmentParagraph(
 	return result, true, nil
 }
 
-func expandHTMLBodyFragmentParagraph(renderedPara *Paragraph, ctx *renderContext) ([]BodyElement, bool) {
+func expandHTMLBodyFragmentParagraph(renderedPara *Paragraph, ctx *renderContext) ([]BodyElement, bool, error) {
 	if renderedPara == nil || ctx == nil || ctx.ooxmlFragments == nil {
-		return nil, false
+		return nil, false, nil
 	}
 
 	text := buildParagraphRenderText(renderedPara)
 	matches := ooxmlFragmentRegex.FindAllStringSubmatchIndex(text, -1)
 	if len(matches) != 1 {
-		return nil, false
+		return nil, false, nil
 	}
 
 	match := matches[0]
 	if strings.TrimSpace(text[:match[0]]) != "" || strings.TrimSpace(text[match[1]:]) != "" {
-		return nil, false
+		return nil, false, nil
 	}
 
 	fragmentKey := text[match[2]:match[3]]
-	fragmentContent := ctx.ooxmlFragments[fragmentKey]
-	switch htmlContent := fragmentContent.(type) {
-	case *HTMLBody:
-		if htmlContent == nil {
-			return nil, false
-		}
-		return htmlContent.Elements, true
-	case *HTMLTable:
-		if htmlContent == nil || htmlContent.Table == nil {
-			return nil, false
-		}
-		return []BodyElement{htmlContent.Table}, true
-	default:
-		return nil, false
+	fragmentContent, ok := ctx.ooxmlFragments[fragmentKey]
+	if !ok {
+		return nil, false, nil
+	}
+	level, err := fragmentContentLevel(fragmentContent)
+	if err != nil || level == FragmentLevelRun {
+		// Run-level content is expanded inline
+		return nil, false, err
 	}
+	if group, ok := fragmentContent.(*FragmentGroup); ok {
+		elements, err := fragmentGroupElements(group, renderedPara, ctx)
+		return elements, err == nil, err
+	}
+	if paragraphs, ok := fragmentContent.(*textParagraphs); ok {
+		return paragraphs.elements(renderedPara), true, nil
+	}
+	elements, err := fragmentContentElements(fragmentContent, ctx)
+	return elements, err == nil, err
 }
 
 func newParagraphWithRunsLike(base *Paragraph, runs []Run) *Paragraph {
@@ -274,6 +280,14 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 			controlContent := entry.controlContent
 			if controlType == "" && plan == nil {
 				controlType, controlContent = render.DetectControlStructure(el)
+				if content, ok := detectBlockDirective(el); ok && controlType == "" {
+					controlType, controlContent = "block", content
+				}
+			}
+			if controlType == "inline-for" && containsIncludeToken(controlContent) {
+				// The text-based inline loop cannot resolve fragments, so loops
+				// over includes are rendered run by run like other inline controls.
+				controlType = ""
 			}
 
 			switch controlType {
@@ -283,6 +297,10 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 					return nil, err
 				}
 				for _, p := range renderedParas {
+					if err := ctx.chargeMemory(&p); err != nil {
+						return nil, err
+					}
+					ctx.markAnchorSource(el, &p)
 					result = append(result, &p)
 				}
 				i++
@@ -298,37 +316,66 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 				}
 
 				endIdx := entry.endIdx
+				branches := branchBodiesForEntry(entry, endIdx)
 				if endIdx < 0 {
 					var err error
-					endIdx, err = fallbackFindMatchingEnd(body.Elements, i)
+					endIdx, branches, err = fallbackFindIfStructure(body.Elements, i)
 					if err != nil {
 						return nil, fmt.Errorf("no matching {{end}} for {{for}} at element %d", i)
 					}
 				}
+				// An {{else}} branch renders in place of an empty collection
+				bodyEnd, elseIdx := endIdx, -1
+				if len(branches) > 0 && branches[0].BranchType == "else" {
+					bodyEnd, elseIdx = branches[0].Index, branches[0].Index
+				}
+
+				if err := forNode.checkShadowing(data); err != nil {
+					return nil, err
+				}
 
 				collection, err := forNode.Collection.Evaluate(data)
 				if err != nil {
 					return nil, fmt.Errorf("failed to evaluate collection: %w", err)
 				}
 
-				items, err := toSlice(collection)
+				items, err := collectionItems(forNode.Collection, collection)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert collection to slice: %w", err)
 				}
 
-				for idx, item := range items {
-					loopData := newChildTemplateData(data, 2)
-					loopData[forNode.Variable] = item
-					if forNode.IndexVar != "" {
-						loopData[forNode.IndexVar] = idx
+				if len(items) == 0 {
+					result = appendMissingSectionPreview(result, forNode.Collection, data, ctx)
+					if elseIdx >= 0 {
+						elseRendered, err := renderBodyElementRange(body, plan, elseIdx+1, endIdx, data, ctx)
+						if err != nil {
+							return nil, err
+						}
+						result = append(result, elseRendered...)
 					}
+				}
+				// Wrap the loop in a repeating section content control
+				// (RenderOptions.RepeatingSections)
+				section := ctx.beginRepeatingSection(forNode, items)
+				if section >= 0 {
+					result = append(result, repeatingSectionMarker(section, repeatingSectionStart))
+				}
+				for idx, item := range items {
+					loopData := forNode.iterationData(data, idx, item)
 
-					loopRendered, err := renderBodyElementRange(body, plan, i+1, endIdx, loopData, ctx)
+					loopRendered, err := renderBodyElementRange(body, plan, i+1, bodyEnd, loopData, ctx)
 					if err != nil {
 						return nil, err
 					}
+					if section >= 0 {
+						result = append(result, repeatingSectionMarker(section, repeatingSectionItem))
+						loopRendered = append(loopRendered, repeatingSectionMarker(section, repeatingSectionItemEnd))
+					}
 					result = append(result, loopRendered...)
 				}
+				if section >= 0 {
+					result = append(result, repeatingSectionMarker(section, repeatingSectionEnd))
+				}
 
 				i = endIdx + 1
 
@@ -359,6 +406,9 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 					return nil, fmt.Errorf("failed to evaluate if condition: %w", err)
 				}
 
+				if !isTruthy(condValue) {
+					result = appendMissingSectionPreview(result, expr, data, ctx)
+				}
 				branchElements, err := renderSelectedIfBranch(body, plan, el, i, endIdx, branches, prefixRuns, isTruthy(condValue), data, ctx)
 				if err != nil {
 					return nil, err
@@ -410,19 +460,35 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 				}
 				i = endIdx + 1
 
+			case "block":
+				endIdx := entry.endIdx
+				if endIdx < 0 {
+					return nil, fmt.Errorf("no matching {{end}} for {{%s}} at element %d", controlContent, i)
+				}
+				blockElements, err := renderBlockDirective(body, plan, i, endIdx, controlContent, data, ctx)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, blockElements...)
+				i = endIdx + 1
+
 			case "include":
 				if ctx == nil || ctx.fragments == nil {
 					return nil, fmt.Errorf("fragments not available in render context")
 				}
 
-				expr := entry.includeExpr
+				expr, mods := entry.includeExpr, entry.includeMods
 				if expr == nil {
 					var err error
-					expr, err = ParseExpression(controlContent)
+					expr, mods, err = parseIncludeDirective(controlContent)
 					if err != nil {
 						return nil, fmt.Errorf("failed to parse include expression: %w", err)
 					}
 				}
+				resolvedMods, err := mods.resolve(data)
+				if err != nil {
+					return nil, err
+				}
 
 				fragmentNameValue, err := expr.Evaluate(data)
 				if err != nil {
@@ -439,13 +505,21 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 					return nil, fmt.Errorf("failed to resolve fragment %s: %w", fragmentName, err)
 				}
 				if frag == nil {
-					return nil, fmt.Errorf("fragment not found: %s", fragmentName)
+					fragmentName, frag, err = resolveMissingFragment(fragmentName, ctx)
+					if err != nil {
+						return nil, err
+					}
+					if frag == nil {
+						i++
+						continue
+					}
 				}
 
 				fragmentElements, err := renderIncludedFragment(fragmentName, frag, data, ctx)
 				if err != nil {
 					return nil, err
 				}
+				applyIncludeModifiers(fragmentElements, resolvedMods, ctx)
 				result = append(result, fragmentElements...)
 				i++
 
@@ -458,7 +532,14 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 					return nil, err
 				}
 
-				if htmlElements, handled := expandHTMLBodyFragmentParagraph(renderedPara, ctx); handled {
+				htmlElements, handled, err := expandHTMLBodyFragmentParagraph(renderedPara, ctx)
+				if err != nil {
+					return nil, err
+				}
+				if handled {
+					if err := ctx.chargeMemory(htmlElements...); err != nil {
+						return nil, err
+					}
 					result = append(result, htmlElements...)
 					i++
 					continue
@@ -476,12 +557,21 @@ func renderBodyElementRange(body *Body, plan *bodyRenderPlan, start, end int, da
 					continue
 				}
 
+				if err := validateInlineFragments(renderedPara, ctx); err != nil {
+					return nil, err
+				}
+				if err := ctx.chargeMemory(renderedPara); err != nil {
+					return nil, err
+				}
+				ctx.markAnchorSource(el, renderedPara)
+
 				result = append(result, renderedPara)
 				i++
 			}
 
 		case *Table:
 			table := cloneTable(el)
+			ctx.trackAnchorTable(el, table)
 			renderedTable, err := RenderTableWithControlStructures(table, data, ctx)
 			if err != nil {
 				return nil, err
@@ -584,9 +674,13 @@ func renderSelectedIfBranch(body *Body, plan *bodyRenderPlan, openingPara *Parag
 	return append([]BodyElement{prefixPara}, branchElements...), nil
 }
 
-func renderIncludedFragment(fragmentName string, frag *fragment, data TemplateData, ctx *renderContext) ([]BodyElement, error) {
+func renderIncludedFragment(fragmentName string, frag *fragment, data TemplateData, ctx *renderContext) (_ []BodyElement, err error) {
+	span, endSpan := ctx.startSpan(spanInclude)
+	defer func() { endSpan(err) }()
+	span.SetAttribute(attrFragment, fragmentName)
+
 	if frag == nil {
-		return nil, fmt.Errorf("fragment not found: %s", fragmentName)
+		return nil, withErrorCode(ErrorCodeMissingFragment, fmt.Errorf("fragment not found: %s", fragmentName))
 	}
 	if err := frag.ensurePrepared(ctx.mainStylesXML); err != nil {
 		return nil, fmt.Errorf("failed to prepare fragment %s: %w", fragmentName, err)
@@ -613,101 +707,56 @@ func renderIncludedFragment(fragmentName string, frag *fragment, data TemplateDa
 	if frag.parsed == nil || frag.parsed.Body == nil {
 		return nil, nil
 	}
-	if ctx != nil && frag.isDocx {
-		ctx.usedDocxFragments[fragmentName] = true
-	}
-
-	for _, f := range ctx.fragmentStack {
-		if f == fragmentName {
-			return nil, fmt.Errorf("circular fragment reference detected: %s", fragmentName)
-		}
-	}
-
-	config := GetGlobalConfig()
-	if ctx.renderDepth >= config.MaxRenderDepth {
-		return nil, fmt.Errorf("maximum render depth exceeded: %d", config.MaxRenderDepth)
+	if err := ctx.enterFragment(fragmentName); err != nil {
+		return nil, err
 	}
-
-	ctx.fragmentStack = append(ctx.fragmentStack, fragmentName)
-	ctx.renderDepth++
-	if len(ctx.fragmentStack) > config.MaxRenderDepth {
-		ctx.fragmentStack = ctx.fragmentStack[:len(ctx.fragmentStack)-1]
-		ctx.renderDepth--
-		return nil, fmt.Errorf("maximum render depth exceeded")
+	if frag.isDocx {
+		ctx.usedDocxFragments[fragmentName] = true
 	}
 
 	renderedBody, err := func() (*Body, error) {
-		defer func() {
-			ctx.fragmentStack = ctx.fragmentStack[:len(ctx.fragmentStack)-1]
-			ctx.renderDepth--
-		}()
-		return RenderBodyWithControlStructures(frag.parsed.Body, data, ctx)
+		defer ctx.leaveFragment()
+		body, err := RenderBodyWithControlStructures(frag.parsed.Body, data, ctx)
+		return body, ctx.fragmentError(data, err)
 	}()
 	if err != nil {
-		return nil, fmt.Errorf("failed to render fragment %s: %w", fragmentName, err)
+		return nil, err
 	}
 	if frag.hasFontOverride {
 		ctx.fragmentFontOverrides[fragmentName] = frag.fontOverride
 	}
 	applyFragmentFontOverrides(renderedBody.Elements, fragmentName, ctx)
+	if ctx.options != nil && ctx.options.StyleConflicts == StyleConflictRename {
+		renameFragmentStyleReferences(renderedBody.Elements, fragmentStyleRenames(fragmentName, frag.styleConflicts))
+	}
+	if themeFonts := ctx.fragmentThemeFontsFor(fragmentName, frag); themeFonts != nil {
+		applyFragmentThemeRemap(renderedBody.Elements, themeFonts)
+	}
 
 	if frag.isDocx && len(frag.relationships) > 0 {
-		rangeStart, exists := ctx.fragmentIDAllocations[fragmentName]
+		idMap, exists := ctx.fragmentIDMaps[fragmentName]
 		if !exists {
-			rangeStart = ctx.nextFragmentIDRange
-			ctx.fragmentIDAllocations[fragmentName] = rangeStart
-			ctx.nextFragmentIDRange += FragmentIDRangeSize
-		}
-
-		if !ctx.fragmentResourcesAdded[fragmentName] {
-			imageCounter := 1
-			for _, rel := range frag.relationships {
-				if !isMediaRelationship(rel) {
-					continue
-				}
-
-				idNum, err := extractRelationshipNumber(rel.ID)
-				if err != nil {
-					return nil, fmt.Errorf("invalid relationship ID %s in fragment %s: %w", rel.ID, fragmentName, err)
-				}
-				if idNum >= FragmentIDRangeSize {
-					return nil, fmt.Errorf("fragment %s relationship ID %s exceeds range size %d",
-						fragmentName, rel.ID, FragmentIDRangeSize)
-				}
-
-				newID := fmt.Sprintf("rId%d", rangeStart+idNum)
-				newTarget := renameMediaPath(rel.Target, fragmentName, imageCounter)
-				if mediaContent, ok := frag.mediaFiles[rel.Target]; ok {
-					newFilename := filepath.Base(newTarget)
-					ctx.fragmentMedia[newFilename] = mediaContent
-				}
-
-				ctx.fragmentRelationships = append(ctx.fragmentRelationships, Relationship{
-					ID:     newID,
-					Type:   rel.Type,
-					Target: newTarget,
-				})
-				imageCounter++
-			}
-			ctx.fragmentResourcesAdded[fragmentName] = true
-		}
-
-		idMap := make(map[string]string)
-		for _, rel := range frag.relationships {
-			if !isMediaRelationship(rel) {
-				continue
+			var err error
+			idMap, err = copyFragmentRelationships(fragmentName, frag, ctx)
+			if err != nil {
+				return nil, err
 			}
-			idNum, _ := extractRelationshipNumber(rel.ID)
-			idMap[rel.ID] = fmt.Sprintf("rId%d", rangeStart+idNum)
+			ctx.fragmentIDMaps[fragmentName] = idMap
 		}
 
 		tempDoc := &Document{Body: renderedBody}
 		updateDocumentRelationshipIDs(tempDoc, idMap)
 	}
+	if frag.isDocx {
+		if renderedBody.Elements, err = includeFragmentSections(fragmentName, frag, renderedBody, data, ctx); err != nil {
+			return nil, err
+		}
+	}
 
 	if frag.isDocx && ctx.numbering != nil && len(frag.numberingXML) > 0 {
 		var numMap map[string]string
 		var err error
+		_, alreadyMerged := ctx.numbering.fragmentNumMaps[fragmentName]
 		if frag.compiled != nil && frag.compiled.numbering != nil {
 			numMap, err = ctx.numbering.ensureCompiledFragmentDefinitions(fragmentName, frag.compiled.numbering)
 		} else {
@@ -716,15 +765,38 @@ func renderIncludedFragment(fragmentName string, frag *fragment, data TemplateDa
 		if err != nil {
 			return nil, fmt.Errorf("failed to merge numbering for fragment %s: %w", fragmentName, err)
 		}
+		if alreadyMerged && ctx.options != nil && ctx.options.FragmentNumbering == FragmentNumberingRestart {
+			numMap = ctx.numbering.restartFragmentNumbering(numMap)
+		}
 		if len(numMap) > 0 {
 			tempDoc := &Document{Body: renderedBody}
 			updateDocumentNumberingIDs(tempDoc, numMap)
 		}
 	}
 
+	setElementCountAttributes(span, renderedBody.Elements)
 	return renderedBody.Elements, nil
 }
 
+// relationshipIDAllocator returns the allocator for fragment relationship IDs,
+// reserving the IDs of the main document relationships on first use.
+func (ctx *renderContext) relationshipIDAllocator() (*relationshipIDAllocator, error) {
+	if ctx.relationshipIDs != nil {
+		return ctx.relationshipIDs, nil
+	}
+
+	var rels []Relationship
+	if ctx.template != nil && ctx.template.docxReader != nil {
+		relsXML, err := ctx.template.docxReader.GetRelationshipsXML()
+		if err != nil {
+			return nil, NewDocumentError("extract", "relationships", err)
+		}
+		rels = parseRelationships([]byte(relsXML))
+	}
+	ctx.relationshipIDs = newRelationshipIDAllocator(rels)
+	return ctx.relationshipIDs, nil
+}
+
 // RenderBodyWithControlStructures renders a document body handling control structures
 func RenderBodyWithControlStructures(body *Body, data TemplateData, ctx *renderContext) (*Body, error) {
 	rendered, err := renderBodyWithElementOrder(body, data, ctx)
@@ -850,6 +922,10 @@ func renderInlineForLoop(para *Paragraph, loopText string, data TemplateData, _
 		return nil, fmt.Errorf("invalid for syntax: %w", err)
 	}
 
+	if err := forNode.checkShadowing(data); err != nil {
+		return nil, err
+	}
+
 	// Evaluate collection
 	collection, err := forNode.Collection.Evaluate(data)
 	if err != nil {
@@ -867,16 +943,20 @@ func renderInlineForLoop(para *Paragraph, loopText string, data TemplateData, _
 	resultText.WriteString(processedPrefix)
 
 	// Iterate over collection
-	items, err := toSlice(collection)
+	items, err := collectionItems(forNode.Collection, collection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert collection to slice: %w", err)
 	}
-	for idx, item := range items {
-		loopData := newChildTemplateData(data, 2)
-		loopData[forNode.Variable] = item
-		if forNode.IndexVar != "" {
-			loopData[forNode.IndexVar] = idx
+	loopBody, elseBody, hasElse := splitInlineForElse(loopBody)
+	if len(items) == 0 && hasElse {
+		processedElse, err := processTemplateText(elseBody, data)
+		if err != nil {
+			return nil, err
 		}
+		resultText.WriteString(processedElse)
+	}
+	for idx, item := range items {
+		loopData := forNode.iterationData(data, idx, item)
 
 		// Process loop body with substitutions
 		processedBody, err := processTemplateText(loopBody, loopData)
@@ -923,6 +1003,45 @@ func renderInlineForLoop(para *Paragraph, loopText string, data TemplateData, _
 	return []Paragraph{*resultPara}, nil
 }
 
+// splitInlineForElse splits the body of an inline for loop at the {{else}}
+// of the loop itself, skipping those of nested control structures
+func splitInlineForElse(loopBody string) (string, string, bool) {
+	depth := 0
+	for pos := 0; pos < len(loopBody); {
+		start := strings.Index(loopBody[pos:], "{{")
+		if start < 0 {
+			break
+		}
+		start += pos
+		end := strings.Index(loopBody[start:], "}}")
+		if end < 0 {
+			break
+		}
+		end += start + 2
+
+		tag := strings.TrimSpace(loopBody[start+2 : end-2])
+		switch {
+		case strings.HasPrefix(tag, "for "), strings.HasPrefix(tag, "if "), strings.HasPrefix(tag, "unless "):
+			depth++
+		case tag == "end":
+			depth--
+		case tag == "else" && depth == 0:
+			return loopBody[:start], loopBody[end:], true
+		}
+		pos = end
+	}
+	return loopBody, "", false
+}
+
+func containsIncludeToken(text string) bool {
+	for _, token := range Tokenize(text) {
+		if token.Type == TokenInclude {
+			return true
+		}
+	}
+	return false
+}
+
 // processTemplateText processes template variables and control structures in text
 // Only processes control structures that are complete within the text
 func processTemplateText(text string, data TemplateData) (string, error) {
@@ -1290,13 +1409,17 @@ func processForStatement(tokens []Token, startIdx int, data TemplateData) (strin
 		return "", startIdx, fmt.Errorf("invalid for syntax: %w", err)
 	}
 
-	// Find the matching {{end}} by tracking nesting depth
-	endIdx := -1
+	// Find the matching {{else}} and {{end}} by tracking nesting depth
+	endIdx, elseIdx := -1, -1
 	depth := 1
 	for i := startIdx + 1; i < len(tokens); i++ {
 		switch tokens[i].Type {
 		case TokenIf, TokenUnless, TokenFor:
 			depth++
+		case TokenElse:
+			if depth == 1 && elseIdx == -1 {
+				elseIdx = i
+			}
 		case TokenEnd:
 			depth--
 			if depth == 0 {
@@ -1312,28 +1435,38 @@ func processForStatement(tokens []Token, startIdx int, data TemplateData) (strin
 		return "", startIdx, fmt.Errorf("no matching end for for loop")
 	}
 
+	if err := forNode.checkShadowing(data); err != nil {
+		return "", endIdx + 1, err
+	}
+
 	// Evaluate the collection
 	collectionVal, err := forNode.Collection.Evaluate(data)
 	if err != nil {
 		return "", endIdx + 1, fmt.Errorf("failed to evaluate for collection: %w", err)
 	}
 
-	items, err := toSlice(collectionVal)
+	items, err := collectionItems(forNode.Collection, collectionVal)
 	if err != nil {
 		return "", endIdx + 1, fmt.Errorf("failed to convert collection to slice: %w", err)
 	}
 
-	// Extract body tokens (between for and end)
+	// Extract body tokens (between for and else or end)
 	bodyTokens := tokens[startIdx+1 : endIdx]
+	if elseIdx != -1 {
+		bodyTokens = tokens[startIdx+1 : elseIdx]
+		if len(items) == 0 {
+			rendered, _, err := processTokens(tokens[elseIdx+1:endIdx], 0, data)
+			if err != nil {
+				return "", startIdx, err
+			}
+			return rendered, endIdx + 1, nil
+		}
+	}
 
 	// Iterate and render
 	var result strings.Builder
 	for idx, item := range items {
-		loopData := newChildTemplateData(data, 2)
-		loopData[forNode.Variable] = item
-		if forNode.IndexVar != "" {
-			loopData[forNode.IndexVar] = idx
-		}
+		loopData := forNode.iterationData(data, idx, item)
 
 		rendered, _, err := processTokens(bodyTokens, 0, loopData)
 		if err != nil {
@@ -1496,6 +1629,10 @@ func RenderTableCell(cell *TableCell, data TemplateData, ctx *renderContext) (*T
 	}
 
 	// Use renderElementsWithContext to handle control structures that span multiple paragraphs
+	if ctx != nil {
+		ctx.cellDepth++
+		defer func() { ctx.cellDepth-- }()
+	}
 	renderedElements, err := renderElementsWithContext(elements, data, ctx)
 	if err != nil {
 		return nil, err
@@ -1503,8 +1640,11 @@ func RenderTableCell(cell *TableCell, data TemplateData, ctx *renderContext) (*T
 
 	// Convert back to paragraphs
 	for _, elem := range renderedElements {
-		if para, ok := elem.(*Paragraph); ok {
-			rendered.Paragraphs = append(rendered.Paragraphs, *para)
+		switch el := elem.(type) {
+		case *Paragraph:
+			rendered.Paragraphs = append(rendered.Paragraphs, *el)
+		case *Table:
+			return nil, fmt.Errorf("a table cannot be inserted in a table cell")
 		}
 	}
 
@@ -1519,6 +1659,10 @@ func renderTableForLoop(rows []TableRow, forExpr string, data TemplateData, ctx
 		return nil, fmt.Errorf("invalid for syntax: %w", err)
 	}
 
+	if err := forNode.checkShadowing(data); err != nil {
+		return nil, err
+	}
+
 	// Evaluate collection
 	collection, err := forNode.Collection.Evaluate(data)
 	if err != nil {
@@ -1526,7 +1670,7 @@ func renderTableForLoop(rows []TableRow, forExpr string, data TemplateData, ctx
 	}
 
 	// Convert to slice
-	items, err := toSlice(collection)
+	items, err := collectionItems(forNode.Collection, collection)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert collection to slice: %w", err)
 	}
@@ -1534,15 +1678,19 @@ func renderTableForLoop(rows []TableRow, forExpr string, data TemplateData, ctx
 	// Collect body rows (skip first and last row which contain for/end)
 	bodyRows := rows[1 : len(rows)-1]
 
+	// Rows after an {{else}} row render in place of an empty collection
+	if _, branches, err := render.FindMatchingTableIfEndInSlice(rows, 0); err == nil && len(branches) > 0 && branches[0].BranchType == "else" {
+		bodyRows = rows[1:branches[0].Index]
+		if len(items) == 0 {
+			return renderTableRows(rows[branches[0].Index+1:len(rows)-1], data, ctx)
+		}
+	}
+
 	var result []TableRow
 
 	// Iterate over collection
 	for idx, item := range items {
-		loopData := newChildTemplateData(data, 2)
-		loopData[forNode.Variable] = item
-		if forNode.IndexVar != "" {
-			loopData[forNode.IndexVar] = idx
-		}
+		loopData := forNode.iterationData(data, idx, item)
 
 		// Process body rows with loop data
 		i := 0
@@ -1628,6 +1776,12 @@ func renderTableForLoop(rows []TableRow, forExpr string, data TemplateData, ctx
 		}
 	}
 
+	if ctx != nil && ctx.options != nil && ctx.options.KeepLoopRowsTogether {
+		for i := range result {
+			keepRowTogether(&result[i])
+		}
+	}
+
 	return result, nil
 }
 
@@ -1691,7 +1845,12 @@ func renderTableIfElse(rows []TableRow, ifExpr string, branches []render.ElseBra
 		}
 	}
 
-	// Render selected rows, handling nested control structures
+	return renderTableRows(bodyRows, data, ctx)
+}
+
+// renderTableRows renders the rows of a table branch, handling nested
+// control structures
+func renderTableRows(bodyRows []TableRow, data TemplateData, ctx *renderContext) ([]TableRow, error) {
 	var result []TableRow
 	i := 0
 	for i < len(bodyRows) {
@@ -1839,89 +1998,5 @@ func renderTableUnlessElse(rows []TableRow, unlessExpr string, branches []render
 		}
 	}
 
-	// Render selected rows, handling nested control structures
-	var result []TableRow
-	i := 0
-	for i < len(bodyRows) {
-		row := &bodyRows[i]
-		controlType, controlContent := render.DetectTableRowControlStructure(row)
-
-		switch controlType {
-		case "for":
-			// Find matching end for nested for loop
-			endIdx, err := render.FindMatchingTableEndInSlice(bodyRows, i)
-			if err != nil {
-				return nil, fmt.Errorf("failed to find matching end for nested for: %w", err)
-			}
-
-			// Render nested for loop block
-			renderedRows, err := renderTableForLoop(bodyRows[i:endIdx+1], controlContent, data, ctx)
-			if err != nil {
-				return nil, err
-			}
-			result = append(result, renderedRows...)
-			i = endIdx + 1
-
-		case "if":
-			// Find matching else/elsif/end for nested if
-			endIdx, nestedBranches, err := render.FindMatchingTableIfEndInSlice(bodyRows, i)
-			if err != nil {
-				return nil, fmt.Errorf("failed to find matching end for nested if: %w", err)
-			}
-
-			// Adjust branch indices to be relative to the slice
-			adjustedBranches := make([]render.ElseBranch, len(nestedBranches))
-			for idx, branch := range nestedBranches {
-				adjustedBranches[idx] = render.ElseBranch{
-					Index:      branch.Index - i,
-					BranchType: branch.BranchType,
-					Condition:  branch.Condition,
-				}
-			}
-
-			// Render nested if/elsif/else block
-			renderedRows, err := renderTableIfElse(bodyRows[i:endIdx+1], controlContent, adjustedBranches, data, ctx)
-			if err != nil {
-				return nil, err
-			}
-			result = append(result, renderedRows...)
-			i = endIdx + 1
-
-		case "unless":
-			// Find matching else/elsif/end for nested unless
-			endIdx, nestedBranches, err := render.FindMatchingTableIfEndInSlice(bodyRows, i)
-			if err != nil {
-				return nil, fmt.Errorf("failed to find matching end for nested unless: %w", err)
-			}
-
-			// Adjust branch indices to be relative to the slice
-			adjustedBranches := make([]render.ElseBranch, len(nestedBranches))
-			for idx, branch := range nestedBranches {
-				adjustedBranches[idx] = render.ElseBranch{
-					Index:      branch.Index - i,
-					BranchType: branch.BranchType,
-					Condition:  branch.Condition,
-				}
-			}
-
-			// Render nested unless/elsif/else block
-			renderedRows, err := renderTableUnlessElse(bodyRows[i:endIdx+1], controlContent, adjustedBranches, data, ctx)
-			if err != nil {
-				return nil, err
-			}
-			result = append(result, renderedRows...)
-			i = endIdx + 1
-
-		default:
-			// Regular row, render with data
-			renderedRow, err := RenderTableRow(row, data, ctx)
-			if err != nil {
-				return nil, err
-			}
-			result = append(result, *renderedRow)
-			i++
-		}
-	}
-
-	return result, nil
+	return renderTableRows(bodyRows, data, ctx)
 }
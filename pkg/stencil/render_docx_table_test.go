@@ -78,6 +78,9 @@ func TestRenderTableCellWithSplitRuns(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if strings.HasPrefix(tt.name, "html") {
+				skipWithoutFunction(t, "html")
+			}
 			// Create a render context
 			ctx := &renderContext{
 				linkMarkers:    make(map[string]*LinkReplacementMarker),
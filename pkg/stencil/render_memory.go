@@ -0,0 +1,147 @@
+package stencil
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Estimated sizes of the markup around the content of document elements.
+const (
+	paragraphOverheadBytes = 64
+	runOverheadBytes       = 48
+	elementOverheadBytes   = 32
+)
+
+// MemoryLimitError is returned when a render exceeds
+// RenderOptions.MaxMemoryBytes.
+type MemoryLimitError struct {
+	// Limit is RenderOptions.MaxMemoryBytes.
+	Limit int64
+	// Used is the estimated memory the render had taken when it stopped.
+	Used int64
+	// Phase is the step of the render that exceeded the limit, such as
+	// "rendering" or "assembling the document".
+	Phase string
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("render exceeded the memory limit of %d bytes while %s (about %d bytes used)", e.Limit, e.Phase, e.Used)
+}
+
+// renderMemory tracks the estimated memory of a render against
+// RenderOptions.MaxMemoryBytes. A nil *renderMemory tracks nothing.
+type renderMemory struct {
+	limit int64
+	used  atomic.Int64
+}
+
+func newRenderMemory(opts *RenderOptions) *renderMemory {
+	if opts == nil || opts.MaxMemoryBytes <= 0 {
+		return nil
+	}
+	return &renderMemory{limit: opts.MaxMemoryBytes}
+}
+
+// charge adds n bytes to the memory of the render and returns a
+// *MemoryLimitError once the limit is exceeded.
+func (m *renderMemory) charge(n int, phase string) error {
+	if m == nil || n <= 0 {
+		return nil
+	}
+	if used := m.used.Add(int64(n)); used > m.limit {
+		return &MemoryLimitError{Limit: m.limit, Used: used, Phase: phase}
+	}
+	return nil
+}
+
+// writer returns w charging the bytes written to it, or w itself if m
+// tracks nothing.
+func (m *renderMemory) writer(w io.Writer) io.Writer {
+	if m == nil {
+		return w
+	}
+	return &memoryTrackingWriter{w: w, memory: m}
+}
+
+// memoryTrackingWriter charges the bytes written to the document being
+// assembled.
+type memoryTrackingWriter struct {
+	w      io.Writer
+	memory *renderMemory
+}
+
+func (w *memoryTrackingWriter) Write(p []byte) (int, error) {
+	if err := w.memory.charge(len(p), "assembling the document"); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// chargeMemory charges the estimated size of elements rendered into the
+// document.
+func (ctx *renderContext) chargeMemory(elements ...BodyElement) error {
+	if ctx == nil || ctx.memory == nil {
+		return nil
+	}
+	size := 0
+	for _, elem := range elements {
+		size += estimatedElementBytes(elem)
+	}
+	return ctx.memory.charge(size, "rendering")
+}
+
+// estimatedElementBytes estimates the size of the markup of a body element.
+func estimatedElementBytes(elem BodyElement) int {
+	switch e := elem.(type) {
+	case *Paragraph:
+		return estimatedParagraphBytes(e)
+	case *Table:
+		size := paragraphOverheadBytes
+		for i := range e.Rows {
+			for j := range e.Rows[i].Cells {
+				size += elementOverheadBytes
+				for k := range e.Rows[i].Cells[j].Paragraphs {
+					size += estimatedParagraphBytes(&e.Rows[i].Cells[j].Paragraphs[k])
+				}
+			}
+		}
+		return size
+	}
+	return elementOverheadBytes
+}
+
+func estimatedParagraphBytes(para *Paragraph) int {
+	size := paragraphOverheadBytes
+	if len(para.Content) == 0 {
+		for i := range para.Runs {
+			size += estimatedRunBytes(&para.Runs[i])
+		}
+		return size
+	}
+	for _, content := range para.Content {
+		switch c := content.(type) {
+		case *Run:
+			size += estimatedRunBytes(c)
+		case *Hyperlink:
+			size += elementOverheadBytes
+			for i := range c.Runs {
+				size += estimatedRunBytes(&c.Runs[i])
+			}
+		default:
+			size += elementOverheadBytes
+		}
+	}
+	return size
+}
+
+func estimatedRunBytes(run *Run) int {
+	size := runOverheadBytes
+	if run.Text != nil {
+		size += len(run.Text.Content)
+	}
+	for _, raw := range run.RawXML {
+		size += elementOverheadBytes + len(raw.Content)
+	}
+	return size
+}
@@ -0,0 +1,67 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderMaxMemoryBytes(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for row in rows}}`,
+		`{{for col in cols}}`,
+		`{{include "clause"}}`,
+		`{{end}}`,
+		`{{end}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("clause", strings.Repeat("The supplier shall deliver the goods. ", 25)); err != nil {
+		t.Fatalf("AddFragment failed: %v", err)
+	}
+
+	items := make([]interface{}, 20)
+	for i := range items {
+		items[i] = i
+	}
+	data := TemplateData{"rows": items, "cols": items}
+
+	_, err = tmpl.RenderWithOptions(data, RenderOptions{MaxMemoryBytes: 100_000})
+	var limitErr *MemoryLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a MemoryLimitError, got %v", err)
+	}
+	if limitErr.Limit != 100_000 || limitErr.Used <= limitErr.Limit || limitErr.Phase != "rendering" {
+		t.Errorf("unexpected error fields: %+v", limitErr)
+	}
+	if code := ErrorCodeOf(err); code != ErrorCodeMemoryLimit {
+		t.Errorf("ErrorCodeOf = %s, want %s", code, ErrorCodeMemoryLimit)
+	}
+
+	for _, limit := range []int64{0, 10_000_000} {
+		if _, err := tmpl.RenderWithOptions(data, RenderOptions{MaxMemoryBytes: limit}); err != nil {
+			t.Errorf("render with MaxMemoryBytes %d failed: %v", limit, err)
+		}
+	}
+}
+
+func TestRenderMaxMemoryBytesWhileAssembling(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{"Hello {{name}}"})))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	_, err = tmpl.RenderWithOptions(TemplateData{"name": "World"}, RenderOptions{MaxMemoryBytes: 1000})
+	var limitErr *MemoryLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a MemoryLimitError, got %v", err)
+	}
+	if limitErr.Phase != "assembling the document" {
+		t.Errorf("Phase = %q", limitErr.Phase)
+	}
+}
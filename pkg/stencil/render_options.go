@@ -0,0 +1,375 @@
+package stencil
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// FragmentNumbering controls how numbered lists behave when the same DOCX
+// fragment is included more than once.
+type FragmentNumbering int
+
+const (
+	// FragmentNumberingContinue makes every include of a fragment share its
+	// numbering instances, so lists continue where the previous include
+	// stopped. This is the default.
+	FragmentNumberingContinue FragmentNumbering = iota
+	// FragmentNumberingRestart gives every include of a fragment its own
+	// numbering instances, so lists start over on each include.
+	FragmentNumberingRestart
+)
+
+// FragmentHeadersFooters controls what happens to the headers and footers
+// of an included DOCX fragment.
+type FragmentHeadersFooters int
+
+const (
+	// FragmentHeadersFootersStrip leaves out the headers and footers of a
+	// fragment: its content shows those of the section it is included in,
+	// and a warning is logged. This is the default.
+	FragmentHeadersFootersStrip FragmentHeadersFooters = iota
+	// FragmentHeadersFootersImport adds the headers and footers of a
+	// fragment to the document as new parts. The included content becomes
+	// sections of its own that show them, with the page setup of the
+	// fragment.
+	FragmentHeadersFootersImport
+)
+
+// RenderOptions controls optional behavior for a single render.
+// The zero value renders exactly like Render.
+type RenderOptions struct {
+	// DocVariables are written into word/settings.xml as document variables
+	// (w:docVars) and into docProps/custom.xml as custom document properties.
+	// Word fields such as { DOCVARIABLE name } and { DOCPROPERTY name } as well
+	// as macros and other automations can read them from the generated file.
+	DocVariables map[string]string
+
+	// FragmentNumbering selects whether numbered lists continue or restart
+	// across repeated includes of the same DOCX fragment.
+	FragmentNumbering FragmentNumbering
+
+	// FragmentHeadersFooters selects whether the headers and footers of
+	// included DOCX fragments are left out or imported into sections of
+	// their own.
+	FragmentHeadersFooters FragmentHeadersFooters
+
+	// DefaultFragment names a fragment that is rendered in place of any
+	// {{include}} whose fragment cannot be found. A warning is logged for
+	// each substitution.
+	DefaultFragment string
+
+	// SkipMissingFragments renders nothing for an {{include}} whose fragment
+	// cannot be found (and DefaultFragment does not apply) instead of failing
+	// the render. A warning is logged for each skipped include.
+	SkipMissingFragments bool
+
+	// StyleConflicts selects how a style of an included DOCX fragment is
+	// merged when the template defines a style with the same ID
+	// differently: the template's definition is kept (the default), the
+	// fragment's replaces it, or the fragment's style is added under a new
+	// ID its content is changed to refer to.
+	StyleConflicts StyleConflictPolicy
+
+	// OnStyleMergeWarning receives a StyleMergeWarning for every style of
+	// an included DOCX fragment the template or another fragment defines
+	// differently, and for every fragment whose styles cannot be merged.
+	// When nil, warnings are logged.
+	OnStyleMergeWarning func(StyleMergeWarning)
+
+	// Seed makes uuid() and random() deterministic: renders with the same
+	// non-zero seed produce the same values. Zero uses unseeded randomness.
+	Seed int64
+
+	// SequenceStore persists the counters of sequence(). When nil, counters
+	// are kept in memory and shared by all renders in the process.
+	SequenceStore SequenceStore
+
+	// ValidateOutput checks the structure of the rendered package before it
+	// is returned: required parts are present, XML parts are well-formed,
+	// every part has a content type and every relationship resolves. A
+	// *PackageValidationError describing the problems is returned instead
+	// of a document Word would refuse to open.
+	ValidateOutput bool
+
+	// OnRepairHint receives a RepairHint for every problem ValidateOutput
+	// finds, including known-bad patterns Word tolerates such as empty runs
+	// in headers or unclosed bookmarks. When nil, hints are logged as
+	// warnings.
+	OnRepairHint func(RepairHint)
+
+	// OnWarning receives a RenderWarning for every problem the render works
+	// around instead of failing: a missing fragment replaced by
+	// DefaultFragment or skipped, fragment styles, headers, footers or
+	// relationships that are not merged, and repair hints. It also receives
+	// the warnings passed to OnStyleMergeWarning and OnRepairHint. It is
+	// called on the goroutine of the render, one warning at a time; a
+	// function shared by concurrent renders must be safe for concurrent use.
+	// When nil, warnings are logged.
+	OnWarning func(RenderWarning)
+
+	// StrictVariableShadowing fails the render when a nested {{for}} loop
+	// reuses the variable or index name of an enclosing loop, which would
+	// otherwise silently hide the outer value inside the nested loop.
+	StrictVariableShadowing bool
+
+	// PropagatePanics lets panics in template functions and expression
+	// evaluation crash the render with their original stack trace. By
+	// default a panic is recovered and returned as an error naming the
+	// function and its arguments. Enable it while debugging a function.
+	PropagatePanics bool
+
+	// FunctionPolicy restricts the functions this render may call and bounds
+	// their execution time. It replaces the policy set with
+	// WithFunctionPolicy, so a shared engine can apply a different policy
+	// per template or tenant.
+	FunctionPolicy *FunctionPolicy
+
+	// KeepLoopRowsTogether sets w:cantSplit on every table row generated by
+	// a {{for}} loop, so multi-line rows are not split across pages. Use
+	// keepRowTogether() in a row to set it on individual rows.
+	KeepLoopRowsTogether bool
+
+	// RepeatingSections wraps the content each {{for}} loop of the main
+	// document body renders in a Word repeating section content control,
+	// with an item for each item of the loop, bound to a custom XML data
+	// part holding the items. Recipients with tooling for data-bound
+	// content controls can then refresh or re-expand the loops in Word.
+	// Loops over table rows, in table cells and in headers and footers
+	// are not wrapped.
+	RepeatingSections bool
+
+	// TableContinuation splits tables longer than a page into pages that
+	// repeat the header rows and end in a "(continued)" caption row.
+	TableContinuation *TableContinuation
+
+	// Audiences selects the {{audience "name"}}...{{end}} blocks to render.
+	// A block is rendered when one of its audiences is listed and omitted
+	// otherwise, so audience-specific content never appears by accident.
+	Audiences []string
+
+	// Constants overrides constants registered with Engine.RegisterConstants
+	// for this render, such as a tax rate that differs per country. Entries
+	// replace the registered constants of the same name; the others stay
+	// available.
+	Constants map[string]interface{}
+
+	// CheckAssertions evaluates the {{assert condition}} tags of the
+	// template, which other renders skip, and fails the render with an
+	// *AssertionError when any of them is false.
+	CheckAssertions bool
+
+	// Features sets the feature flags feature() reports for this render,
+	// such as the flags of the user the document is rendered for. Flags it
+	// does not set are asked of the engine's WithFeatureProvider.
+	Features map[string]bool
+
+	// TraceContext is the parent of the spans reported for this render to
+	// the tracer set with WithTracer, such as the context of the request
+	// the document is rendered for. It does not cancel the render.
+	TraceContext context.Context
+
+	// PersonalData reports which personal data fields the render embedded
+	// into the document and where.
+	PersonalData *PersonalDataOptions
+
+	// DataSnapshot embeds the render data, as JSON and optionally
+	// encrypted, in a custom XML part of the rendered document, so
+	// ReRenderFromDocument can render the same data with a newer revision
+	// of the template.
+	DataSnapshot *DataSnapshotOptions
+
+	// PreviewMissingSections renders each {{if}} or {{for}} block that would
+	// be dropped because the data it depends on is missing as a gray box
+	// naming the missing fields, so reviewers see the full structure of the
+	// document when previewing it with partial data. Blocks whose data is
+	// present but false or empty are dropped as usual.
+	PreviewMissingSections bool
+
+	// Schema applies the data rules of the fields of a schema to the
+	// render data, so fallback and derived values are declared once
+	// instead of throughout the template. Default values fill in fields
+	// the data lacks or holds null in; a default applies only where the
+	// parent of its field is present, and the fields of a collection get
+	// their defaults in each item. Compute expressions are then evaluated
+	// once, in the order of the fields, and their results set at the
+	// paths of their fields.
+	Schema *ValidationSchema
+
+	// Language is the BCP 47 tag, such as "de-DE", of the proofing language
+	// of the rendered document. It replaces the language of every run and
+	// style and becomes the document default, so spell-checking matches
+	// the render locale instead of the language the template was authored
+	// in. East Asian and complex script languages are kept.
+	Language string
+
+	// HouseStyle makes the rendered main document follow a house-style
+	// profile of allowed fonts, heading sizes and table borders, so
+	// documents assembled from many fragments look uniform. Deviations
+	// are rewritten once the document is assembled and reported to
+	// HouseStyle.OnDeviation.
+	HouseStyle *HouseStyle
+
+	// KeepHeadingsWithNext keeps every heading of the main document on the
+	// page of the paragraph that follows it, so no page ends with a
+	// heading. Headings are paragraphs with a heading style or an outline
+	// level; they get keep-with-next and keep-lines-together.
+	KeepHeadingsWithNext bool
+
+	// Emoji draws the emoji in the text of the main document as images at
+	// the font size of their text, or sets them in an emoji font, so they
+	// do not render as empty boxes on machines without such a font.
+	Emoji *EmojiOptions
+
+	// MaxMemoryBytes stops the render with a *MemoryLimitError once it
+	// has taken about this many bytes, rather than letting a template that
+	// repeats large content in nested loops exhaust the memory of the
+	// process. The memory is estimated from the rendered content and the
+	// size of the document being assembled. Zero means no limit.
+	MaxMemoryBytes int64
+
+	// OnAnchorPositions receives, once the template is rendered, the
+	// paragraphs of the rendered main document that each token of the
+	// template's main document ended up in, keyed by the AnchorID of
+	// PreparedTemplate.TokenMap. Tokens of paragraphs that were not
+	// rendered, such as control tags on their own paragraph or branches
+	// not taken, and of included fragments are not listed.
+	OnAnchorPositions func(AnchorPositions)
+
+	// SectionData returns the data of the headers and footers of a section
+	// of the rendered document, such as the title of a chapter. It is called
+	// once for each section, with the template parts the section shows, and
+	// the data it returns takes precedence over the render data in those
+	// parts. A header or footer shown by several sections is rendered once
+	// for each of them into a part of its own.
+	SectionData func(SectionParts) TemplateData
+
+	// splitDocuments keeps splitDocument() markers in the rendered
+	// document for RenderMulti to cut it at.
+	splitDocuments bool
+}
+
+// needsPackagePostProcessing reports whether any option requires rewriting
+// parts of the rendered package.
+func (o *RenderOptions) needsPackagePostProcessing() bool {
+	if o == nil {
+		return false
+	}
+	return len(o.DocVariables) > 0 || o.Language != "" || o.ValidateOutput || o.DataSnapshot != nil ||
+		(o.PersonalData != nil && o.PersonalData.OnReport != nil)
+}
+
+func (o *RenderOptions) reportRepairHint(hint RepairHint) {
+	if o.OnRepairHint != nil {
+		o.OnRepairHint(hint)
+		if o.OnWarning == nil {
+			return
+		}
+	}
+	o.warn(RenderWarning{Code: WarningRepairHint, Message: "repair hint: " + hint.String()})
+}
+
+func (o *RenderOptions) reportStyleMergeWarnings(warnings []StyleMergeWarning) {
+	for _, warning := range warnings {
+		if o != nil && o.OnStyleMergeWarning != nil {
+			o.OnStyleMergeWarning(warning)
+			if o.OnWarning == nil {
+				continue
+			}
+		}
+		o.warn(RenderWarning{Code: WarningStyleMerge, Fragment: warning.Fragment, Message: "style merge: " + warning.String()})
+	}
+}
+
+// RenderWithOptions executes the template like Render, applying the given
+// render options.
+//
+// Example:
+//
+//	reader, err := template.RenderWithOptions(data, stencil.RenderOptions{
+//	    DocVariables: map[string]string{"ClientName": "Acme Corp"},
+//	})
+func (pt *PreparedTemplate) RenderWithOptions(data TemplateData, opts RenderOptions) (io.Reader, error) {
+	if pt == nil {
+		return nil, NewTemplateError("invalid or nil template", 0, 0)
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return nil, withErrorCode(ErrorCodeTemplateClosed, NewTemplateError("template is closed", 0, 0))
+	}
+
+	// Return the document of an identical earlier render (WithRenderCache)
+	cache, cacheKey, cacheable := pt.data.renderCacheKey(pt.template, pt.registry, data, &opts)
+	if cacheable {
+		if output, ok := cache.get(cacheKey); ok {
+			return bytes.NewReader(output), nil
+		}
+	}
+
+	output, err := renderTemplatePackage(pt.template, pt.registry, pt.data, data, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err = applyRenderPackageOptions(output, pt.template.source, data, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		cache.set(cacheKey, output)
+	}
+	return bytes.NewReader(output), nil
+}
+
+// applyRenderPackageOptions applies options that operate on whole package
+// parts to a rendered DOCX. source is the template package the output was
+// rendered from and data the data it was rendered with.
+func applyRenderPackageOptions(output, source []byte, data TemplateData, opts *RenderOptions) ([]byte, error) {
+	if !opts.needsPackagePostProcessing() {
+		return output, nil
+	}
+
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+
+	modified := false
+	if len(opts.DocVariables) > 0 {
+		if err := applyDocVariables(pkg, opts.DocVariables); err != nil {
+			return nil, NewDocumentError("write", "document variables", err)
+		}
+		modified = true
+	}
+	if opts.Language != "" {
+		if err := applyDocumentLanguage(pkg, opts.Language); err != nil {
+			return nil, NewDocumentError("write", "document language", err)
+		}
+		modified = true
+	}
+	if opts.DataSnapshot != nil {
+		if err := applyDataSnapshot(pkg, data, opts.DataSnapshot); err != nil {
+			return nil, NewDocumentError("write", "data snapshot", err)
+		}
+		modified = true
+	}
+
+	// Validation and the personal data report run last so they see the
+	// package exactly as delivered.
+	if opts.ValidateOutput {
+		if err := validateDocxPackage(pkg, source, opts.reportRepairHint); err != nil {
+			return nil, NewDocumentError("validate", "rendered document", err)
+		}
+	}
+	if err := reportPersonalData(pkg, data, opts.PersonalData); err != nil {
+		return nil, err
+	}
+
+	if !modified {
+		return output, nil
+	}
+	return pkg.bytes()
+}
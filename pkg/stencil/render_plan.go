@@ -15,6 +15,7 @@ type bodyRenderPlanEntry struct {
 	forNode        *ForNode
 	conditionExpr  ExpressionNode
 	includeExpr    ExpressionNode
+	includeMods    *includeModifiers
 }
 
 type bodyRenderBranch struct {
@@ -73,6 +74,11 @@ func compileBodyRenderPlan(body *Body) *bodyRenderPlan {
 		}
 
 		controlType, controlContent := render.DetectControlStructure(para)
+		if controlType == "" {
+			if content, ok := detectBlockDirective(para); ok {
+				controlType, controlContent = "block", content
+			}
+		}
 		entry := &plan.entries[i]
 		entry.controlType = controlType
 		entry.controlContent = controlContent
@@ -88,9 +94,12 @@ func compileBodyRenderPlan(body *Body) *bodyRenderPlan {
 				entry.conditionExpr = expr
 			}
 			stack = append(stack, openBodyControl{index: i, controlType: controlType})
+		case "block":
+			stack = append(stack, openBodyControl{index: i, controlType: controlType})
 		case "include":
-			if expr, err := ParseExpression(controlContent); err == nil {
+			if expr, mods, err := parseIncludeDirective(controlContent); err == nil {
 				entry.includeExpr = expr
+				entry.includeMods = mods
 			}
 		case "elsif", "elseif", "elif":
 			if len(stack) == 0 {
@@ -114,7 +123,7 @@ func compileBodyRenderPlan(body *Body) *bodyRenderPlan {
 				continue
 			}
 			open := stack[len(stack)-1]
-			if open.controlType != "if" && open.controlType != "unless" {
+			if open.controlType != "if" && open.controlType != "unless" && open.controlType != "for" {
 				continue
 			}
 			plan.entries[open.index].branches = append(plan.entries[open.index].branches, bodyRenderBranch{
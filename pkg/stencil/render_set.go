@@ -0,0 +1,95 @@
+package stencil
+
+import (
+	"fmt"
+	"io"
+)
+
+// TemplateRenderer is implemented by the template types that can render
+// with options: *PreparedTemplate, *FrozenTemplate and *TemplatePool.
+type TemplateRenderer interface {
+	RenderWithOptions(data TemplateData, opts RenderOptions) (io.Reader, error)
+}
+
+// RenderSet renders a bundle of documents from several templates all or
+// nothing, such as a contract with its appendices and a cover letter.
+//
+// Example:
+//
+//	set := stencil.NewRenderSet()
+//	set.Add("cover-letter.docx", coverLetter)
+//	set.Add("contract.docx", contract)
+//	set.AddWithOptions("appendix.docx", appendix, stencil.RenderOptions{ValidateOutput: true})
+//	documents, err := set.Render(data)
+//	if err != nil {
+//	    // nothing was rendered; err lists every failed template
+//	}
+type RenderSet struct {
+	entries []renderSetEntry
+}
+
+type renderSetEntry struct {
+	name     string
+	template TemplateRenderer
+	opts     RenderOptions
+}
+
+// RenderedDocument is a document rendered by a RenderSet.
+type RenderedDocument struct {
+	Name    string
+	Content []byte
+}
+
+// NewRenderSet creates an empty render set.
+func NewRenderSet() *RenderSet {
+	return &RenderSet{}
+}
+
+// Add adds a template to the set under a unique name.
+func (s *RenderSet) Add(name string, tmpl TemplateRenderer) error {
+	return s.AddWithOptions(name, tmpl, RenderOptions{})
+}
+
+// AddWithOptions adds a template that is rendered with the given options.
+func (s *RenderSet) AddWithOptions(name string, tmpl TemplateRenderer, opts RenderOptions) error {
+	if tmpl == nil {
+		return fmt.Errorf("render set: template %q is nil", name)
+	}
+	for _, entry := range s.entries {
+		if entry.name == name {
+			return fmt.Errorf("render set: duplicate template name %q", name)
+		}
+	}
+	s.entries = append(s.entries, renderSetEntry{name: name, template: tmpl, opts: opts})
+	return nil
+}
+
+// Render renders every template of the set with the same data and returns
+// the documents in the order they were added. If any template fails, no
+// documents are returned and the error names each failed template; the
+// other templates are still rendered so that all errors are reported at
+// once. A single failure is returned as is, several as a *MultiError.
+func (s *RenderSet) Render(data TemplateData) ([]RenderedDocument, error) {
+	documents := make([]RenderedDocument, 0, len(s.entries))
+	errs := NewMultiError()
+	for _, entry := range s.entries {
+		content, err := renderSetEntryContent(entry, data)
+		if err != nil {
+			errs.Add(fmt.Errorf("render set: %s: %w", entry.name, err))
+			continue
+		}
+		documents = append(documents, RenderedDocument{Name: entry.name, Content: content})
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func renderSetEntryContent(entry renderSetEntry, data TemplateData) ([]byte, error) {
+	output, err := entry.template.RenderWithOptions(data, entry.opts)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(output)
+}
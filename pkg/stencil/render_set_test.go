@@ -0,0 +1,90 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderSet(t *testing.T) {
+	cover, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "Dear {{name}}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cover.Close()
+	contract, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "Contract for {{name}}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer contract.Close()
+	frozen, err := contract.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := NewRenderSet()
+	if err := set.Add("cover", cover); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.AddWithOptions("contract", frozen, RenderOptions{ValidateOutput: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Add("cover", contract); err == nil {
+		t.Error("expected an error for a duplicate name")
+	}
+
+	documents, err := set.Render(TemplateData{"name": "ACME"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(documents) != 2 || documents[0].Name != "cover" || documents[1].Name != "contract" {
+		t.Fatalf("unexpected documents: %+v", documents)
+	}
+	if text := extractTextFromDOCX(t, documents[0].Content); !strings.Contains(text, "Dear ACME") {
+		t.Errorf("cover = %q", text)
+	}
+	if text := extractTextFromDOCX(t, documents[1].Content); !strings.Contains(text, "Contract for ACME") {
+		t.Errorf("contract = %q", text)
+	}
+}
+
+func TestRenderSetIsAllOrNothing(t *testing.T) {
+	good, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "{{name}}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer good.Close()
+	bad1, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "{{missingOne()}}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad1.Close()
+	bad2, err := Prepare(bytes.NewReader(createSimpleDOCX(t, "{{missingTwo()}}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad2.Close()
+
+	set := NewRenderSet()
+	set.Add("appendix-a", bad1)
+	set.Add("main", good)
+	set.Add("appendix-b", bad2)
+
+	documents, err := set.Render(TemplateData{"name": "x"})
+	if documents != nil {
+		t.Errorf("expected no documents, got %d", len(documents))
+	}
+	var multi *MultiError
+	if !errors.As(err, &multi) || multi.Len() != 2 {
+		t.Fatalf("expected a MultiError with two errors, got %v", err)
+	}
+	for _, want := range []string{"appendix-a", "missingOne", "appendix-b", "missingTwo"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+	if ErrorCodeOf(err) != ErrorCodeUnknownFunction {
+		t.Errorf("ErrorCodeOf = %s, want %s", ErrorCodeOf(err), ErrorCodeUnknownFunction)
+	}
+}
@@ -92,7 +92,6 @@ func TestRenderTableWithForLoop_Debug(t *testing.T) {
 		linkMarkers:      make(map[string]*LinkReplacementMarker),
 		fragments:        make(map[string]*fragment),
 		fragmentStack:    []string{},
-		renderDepth:      0,
 		ooxmlFragments:   make(map[string]interface{}),
 	}
 
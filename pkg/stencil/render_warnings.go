@@ -0,0 +1,49 @@
+package stencil
+
+// RenderWarningCode identifies the kind of a RenderWarning.
+type RenderWarningCode string
+
+const (
+	// WarningDefaultFragment is reported when RenderOptions.DefaultFragment
+	// is rendered in place of a fragment that cannot be found.
+	WarningDefaultFragment RenderWarningCode = "DEFAULT_FRAGMENT"
+	// WarningSkippedFragment is reported when an {{include}} renders nothing
+	// because of RenderOptions.SkipMissingFragments.
+	WarningSkippedFragment RenderWarningCode = "SKIPPED_FRAGMENT"
+	// WarningStyleMerge is reported for every StyleMergeWarning.
+	WarningStyleMerge RenderWarningCode = "STYLE_MERGE"
+	// WarningRepairHint is reported for every RepairHint.
+	WarningRepairHint RenderWarningCode = "REPAIR_HINT"
+	// WarningFragmentHeadersFooters is reported when the headers or footers
+	// of an included DOCX fragment are left out or cannot be imported.
+	WarningFragmentHeadersFooters RenderWarningCode = "FRAGMENT_HEADERS_FOOTERS"
+	// WarningFragmentRelationships is reported when relationships of an
+	// included DOCX fragment, such as embedded objects, are not copied.
+	WarningFragmentRelationships RenderWarningCode = "FRAGMENT_RELATIONSHIPS"
+)
+
+// RenderWarning describes a problem a render worked around instead of
+// failing, so the document it returns may differ from what the template
+// intends.
+type RenderWarning struct {
+	// Code identifies the kind of warning.
+	Code RenderWarningCode
+	// Fragment is the name of the fragment the warning concerns, if any.
+	Fragment string
+	// Message describes what happened.
+	Message string
+}
+
+// String returns the message of the warning.
+func (w RenderWarning) String() string {
+	return w.Message
+}
+
+// warn passes warning to OnWarning, or logs it when OnWarning is nil.
+func (o *RenderOptions) warn(warning RenderWarning) {
+	if o != nil && o.OnWarning != nil {
+		o.OnWarning(warning)
+		return
+	}
+	Warn("%s", warning)
+}
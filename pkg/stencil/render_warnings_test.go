@@ -0,0 +1,92 @@
+package stencil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRenderWarningsForMissingFragments(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{include "intro"}}`,
+		`{{include "outro"}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("fallback", "Fallback"); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	var warnings []RenderWarning
+	renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{}, RenderOptions{
+		DefaultFragment: "fallback",
+		OnWarning:       func(w RenderWarning) { warnings = append(warnings, w) },
+	})
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	for i, name := range []string{"intro", "outro"} {
+		want := RenderWarning{
+			Code:     WarningDefaultFragment,
+			Fragment: name,
+			Message:  "fragment not found: " + name + "; using default fragment fallback",
+		}
+		if warnings[i] != want {
+			t.Errorf("warning %d = %+v, want %+v", i, warnings[i], want)
+		}
+	}
+
+	warnings = nil
+	renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{}, RenderOptions{
+		SkipMissingFragments: true,
+		OnWarning:            func(w RenderWarning) { warnings = append(warnings, w) },
+	})
+	if len(warnings) != 2 || warnings[0].Code != WarningSkippedFragment || warnings[1].Fragment != "outro" {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestRenderWarningsIncludeStyleMergeWarnings(t *testing.T) {
+	mainDoc := createDOCXWithCustomStylesAndBody(t, `
+<w:p>
+  <w:r><w:t>{{include "terms"}}</w:t></w:r>
+</w:p>`, `
+<w:style w:type="paragraph" w:styleId="Heading1">
+  <w:name w:val="heading 1"/>
+  <w:rPr><w:color w:val="000000"/></w:rPr>
+</w:style>`)
+	fragmentDoc := createDOCXWithCustomStylesAndBody(t, `
+<w:p>
+  <w:pPr><w:pStyle w:val="Heading1"/></w:pPr>
+  <w:r><w:t>Terms heading</w:t></w:r>
+</w:p>`, `
+<w:style w:type="paragraph" w:styleId="Heading1">
+  <w:name w:val="heading 1"/>
+  <w:rPr><w:color w:val="FF0000"/></w:rPr>
+</w:style>`)
+
+	tmpl, err := Prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("terms", fragmentDoc); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	var styleWarnings []StyleMergeWarning
+	var warnings []RenderWarning
+	renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{}, RenderOptions{
+		OnStyleMergeWarning: func(w StyleMergeWarning) { styleWarnings = append(styleWarnings, w) },
+		OnWarning:           func(w RenderWarning) { warnings = append(warnings, w) },
+	})
+	if len(styleWarnings) != 1 || len(warnings) != 1 {
+		t.Fatalf("expected one warning for each callback, got %v and %v", styleWarnings, warnings)
+	}
+	want := RenderWarning{Code: WarningStyleMerge, Fragment: "terms", Message: "style merge: " + styleWarnings[0].String()}
+	if warnings[0] != want {
+		t.Errorf("warning = %+v, want %+v", warnings[0], want)
+	}
+}
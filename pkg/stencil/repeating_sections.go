@@ -0,0 +1,307 @@
+package stencil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// With RenderOptions.RepeatingSections, the content a {{for}} loop of the
+// main document body renders is wrapped in a repeating section content
+// control (w15:repeatingSection) with a repeating section item for each
+// item. The items of every loop are written to a custom XML data part,
+// customXml/itemN.xml, as
+//
+//	<sections xmlns="urn:go-stencil:repeating-sections">
+//	  <section name="items"><item>...</item><item>...</item></section>
+//	</sections>
+//
+// and each repeating section is bound to the items of its section, so tools
+// that work with data-bound content controls can refresh or re-expand the
+// loops in Word. The loops are written in the order they were rendered; a
+// loop nested in another has a section for each item of the outer loop.
+//
+// Loops render a marker paragraph before and after the content of the loop
+// and each item, which the document XML replaces with the content control
+// tags once it is marshaled.
+
+const (
+	repeatingSectionsNamespace = "urn:go-stencil:repeating-sections"
+	word2012Namespace          = "http://schemas.microsoft.com/office/word/2012/wordml"
+	customXMLRelationType      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/customXml"
+	customXMLPropsRelationType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/customXmlProps"
+	customXMLPropsContentType  = "application/vnd.openxmlformats-officedocument.customXmlProperties+xml"
+	customXMLDataContentType   = "application/xml"
+
+	// repeatingSectionMarkerPrefix starts the text of the marker paragraphs.
+	repeatingSectionMarkerPrefix = "__STENCIL_REPEATING_SECTION_"
+	// repeatingSectionFirstID is the w:id of the first content control;
+	// it is high to stay clear of the IDs of the template's own controls.
+	repeatingSectionFirstID = 1500000000
+)
+
+// Kinds of repeating section markers.
+const (
+	repeatingSectionStart   = "START"
+	repeatingSectionItem    = "ITEM"
+	repeatingSectionItemEnd = "ITEM_END"
+	repeatingSectionEnd     = "END"
+)
+
+// repeatingSection is a loop rendered as a repeating section.
+type repeatingSection struct {
+	// name is the collection expression of the loop.
+	name  string
+	items []interface{}
+}
+
+// repeatingSectionState collects the repeating sections of a render.
+type repeatingSectionState struct {
+	sections []repeatingSection
+	// dataXML and storeItemID are the custom XML data part of the sections
+	// and its ID, set when the document is wrapped.
+	dataXML     []byte
+	storeItemID string
+}
+
+// beginRepeatingSection records a loop over items as a repeating section
+// and returns its index, or -1 when the loop is not wrapped: the render does
+// not ask for repeating sections, the loop has no items or it is not part
+// of the main document body.
+func (ctx *renderContext) beginRepeatingSection(forNode *ForNode, items []interface{}) int {
+	if ctx == nil || ctx.options == nil || !ctx.options.RepeatingSections ||
+		ctx.storyPart != "" || ctx.cellDepth > 0 || len(items) == 0 {
+		return -1
+	}
+	if ctx.repeatingSections == nil {
+		ctx.repeatingSections = &repeatingSectionState{}
+	}
+	ctx.collectedNamespaces["w15"] = word2012Namespace
+	state := ctx.repeatingSections
+	state.sections = append(state.sections, repeatingSection{
+		name:  expressionSource(forNode.Collection),
+		items: items,
+	})
+	return len(state.sections) - 1
+}
+
+// sectionList returns the repeating sections recorded so far, or nil for a
+// nil state.
+func (s *repeatingSectionState) sectionList() []repeatingSection {
+	if s == nil {
+		return nil
+	}
+	return s.sections
+}
+
+// repeatingSectionMarker returns the marker paragraph of kind for the
+// repeating section with index section.
+func repeatingSectionMarker(section int, kind string) *Paragraph {
+	return NewParagraph(fmt.Sprintf("%s%d_%s__", repeatingSectionMarkerPrefix, section, kind), ParagraphOptions{})
+}
+
+// wrap replaces the marker paragraphs in documentXML with the tags of the
+// repeating section content controls and builds the data part they are
+// bound to.
+func (s *repeatingSectionState) wrap(documentXML []byte) ([]byte, error) {
+	if s == nil || len(s.sections) == 0 {
+		return documentXML, nil
+	}
+
+	var data bytes.Buffer
+	data.WriteString(xmlDeclaration)
+	data.WriteString(`<sections xmlns="` + repeatingSectionsNamespace + `">`)
+	for _, section := range s.sections {
+		data.WriteString(`<section name="` + escapeXMLText(section.name) + `">`)
+		for _, item := range section.items {
+			writeRepeatingSectionValue(&data, "item", normalizeRepeatingSectionValue(item))
+		}
+		data.WriteString(`</section>`)
+	}
+	data.WriteString(`</sections>`)
+	s.dataXML = data.Bytes()
+	s.storeItemID = customXMLItemID(s.dataXML)
+
+	var out bytes.Buffer
+	out.Grow(len(documentXML) + len(s.sections)*512)
+	nextID := repeatingSectionFirstID
+	rest := documentXML
+	for {
+		markerStart := bytes.Index(rest, []byte(repeatingSectionMarkerPrefix))
+		if markerStart < 0 {
+			out.Write(rest)
+			return out.Bytes(), nil
+		}
+		paraStart := lastParagraphStart(rest[:markerStart])
+		paraEnd := bytes.Index(rest[markerStart:], []byte("</w:p>"))
+		if paraStart < 0 || paraEnd < 0 {
+			return nil, fmt.Errorf("repeating section marker outside a paragraph")
+		}
+		paraEnd += markerStart + len("</w:p>")
+
+		marker := rest[markerStart+len(repeatingSectionMarkerPrefix):]
+		marker = marker[:bytes.Index(marker, []byte("__"))]
+		index, kind, _ := strings.Cut(string(marker), "_")
+		section, err := strconv.Atoi(index)
+		if err != nil || section >= len(s.sections) {
+			return nil, fmt.Errorf("invalid repeating section marker %q", marker)
+		}
+
+		out.Write(rest[:paraStart])
+		switch kind {
+		case repeatingSectionStart:
+			name := escapeXMLText(s.sections[section].name)
+			fmt.Fprintf(&out, `<w:sdt><w:sdtPr><w:alias w:val="%s"/><w:tag w:val="%s"/><w:id w:val="%d"/>`+
+				`<w15:dataBinding w:prefixMappings="xmlns:ns0='%s'" w:xpath="/ns0:sections[1]/ns0:section[%d]/ns0:item" w:storeItemID="%s"/>`+
+				`<w15:repeatingSection/></w:sdtPr><w:sdtContent>`,
+				name, name, nextID, repeatingSectionsNamespace, section+1, s.storeItemID)
+			nextID++
+		case repeatingSectionItem:
+			fmt.Fprintf(&out, `<w:sdt><w:sdtPr><w:id w:val="%d"/><w15:repeatingSectionItem/></w:sdtPr><w:sdtContent>`, nextID)
+			nextID++
+		default:
+			out.WriteString(`</w:sdtContent></w:sdt>`)
+		}
+		rest = rest[paraEnd:]
+	}
+}
+
+// lastParagraphStart returns the index of the last w:p start tag in xml, or
+// -1 if there is none.
+func lastParagraphStart(xml []byte) int {
+	for end := len(xml); end > 0; {
+		i := bytes.LastIndex(xml[:end], []byte("<w:p"))
+		if i < 0 {
+			return -1
+		}
+		if next := i + len("<w:p"); next < len(xml) && (xml[next] == '>' || xml[next] == ' ') {
+			return i
+		}
+		end = i
+	}
+	return -1
+}
+
+// normalizeRepeatingSectionValue converts value to the maps, slices and
+// scalars of its JSON form, so structs are written like maps. Values that
+// cannot be encoded as JSON are written as text.
+func normalizeRepeatingSectionValue(value interface{}) interface{} {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return FormatValue(value)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var normalized interface{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return FormatValue(value)
+	}
+	return normalized
+}
+
+// writeRepeatingSectionValue writes value as the element name: maps as
+// child elements in key order, slices as item elements and other values as
+// text.
+func writeRepeatingSectionValue(buf *bytes.Buffer, name string, value interface{}) {
+	buf.WriteString("<" + name + ">")
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeRepeatingSectionValue(buf, repeatingSectionElementName(key), v[key])
+		}
+	case []interface{}:
+		for _, item := range v {
+			writeRepeatingSectionValue(buf, "item", item)
+		}
+	default:
+		buf.WriteString(escapeXMLText(FormatValue(v)))
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+// repeatingSectionElementName turns a data key into an XML element name:
+// characters a name cannot contain become underscores, and names that
+// cannot start as they do get a leading underscore.
+func repeatingSectionElementName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7F && isXMLChar(r):
+			b.WriteRune(r)
+		case r == '-' || r == '.' || r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// applyRepeatingSectionData adds the custom XML data part the repeating
+// sections of a render are bound to, with its properties part, to output.
+func applyRepeatingSectionData(output []byte, state *repeatingSectionState) ([]byte, error) {
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+	if _, err := pkg.addCustomXMLPart(state.dataXML, state.storeItemID, repeatingSectionsNamespace); err != nil {
+		return nil, NewDocumentError("write", "repeating section data", err)
+	}
+	return pkg.bytes()
+}
+
+// customXMLItemID returns the GUID of a custom XML data part, derived from
+// its content so renders of the same data are identical.
+func customXMLItemID(dataXML []byte) string {
+	sum := sha256.Sum256(dataXML)
+	return fmt.Sprintf("{%X-%X-%X-%X-%X}", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// addCustomXMLPart adds dataXML as the next free customXml/itemN.xml part,
+// with a properties part giving it storeItemID and the schema schemaURI, and
+// returns the name of the part.
+func (p *docxPackage) addCustomXMLPart(dataXML []byte, storeItemID, schemaURI string) (string, error) {
+	n := 1
+	for {
+		if _, exists := p.get(fmt.Sprintf("customXml/item%d.xml", n)); !exists {
+			break
+		}
+		n++
+	}
+	itemName := fmt.Sprintf("customXml/item%d.xml", n)
+	propsName := fmt.Sprintf("customXml/itemProps%d.xml", n)
+
+	p.set(itemName, dataXML)
+	p.set(propsName, []byte(xmlDeclaration+`<ds:datastoreItem ds:itemID="`+storeItemID+
+		`" xmlns:ds="http://schemas.openxmlformats.org/officeDocument/2006/customXml">`+
+		`<ds:schemaRefs><ds:schemaRef ds:uri="`+schemaURI+`"/></ds:schemaRefs></ds:datastoreItem>`))
+	if _, err := p.ensureRelationship(fmt.Sprintf("customXml/_rels/item%d.xml.rels", n), customXMLPropsRelationType, fmt.Sprintf("itemProps%d.xml", n)); err != nil {
+		return "", err
+	}
+	if _, err := p.ensureRelationship(documentRelationshipsPart, customXMLRelationType, "../"+itemName); err != nil {
+		return "", err
+	}
+	if err := p.ensureContentTypeOverride(itemName, customXMLDataContentType); err != nil {
+		return "", err
+	}
+	if err := p.ensureContentTypeOverride(propsName, customXMLPropsContentType); err != nil {
+		return "", err
+	}
+	return itemName, nil
+}
@@ -0,0 +1,126 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestRepeatingSections(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Orders`,
+		`{{for order in orders}}`,
+		`Order {{order.id}}`,
+		`{{for line in order.lines}}`,
+		`{{line.product}}`,
+		`{{end}}`,
+		`{{end}}`,
+		`{{for note in notes}}`,
+		`{{note}}`,
+		`{{end}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	data := TemplateData{
+		"orders": []interface{}{
+			map[string]interface{}{"id": 1, "lines": []interface{}{
+				map[string]interface{}{"product": "Bolts <M6>", "unit price": 0.25},
+				map[string]interface{}{"product": "Nuts", "unit price": 0.1},
+			}},
+			map[string]interface{}{"id": 2, "lines": []interface{}{
+				map[string]interface{}{"product": "Washers", "unit price": 0.05},
+			}},
+		},
+		"notes": []interface{}{},
+	}
+	output := renderPreparedWithOptionsToBytes(t, tmpl, data, RenderOptions{RepeatingSections: true, ValidateOutput: true})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	if strings.Contains(documentXML, repeatingSectionMarkerPrefix) {
+		t.Fatalf("markers left in document: %s", documentXML)
+	}
+	if err := xml.Unmarshal([]byte(documentXML), new(struct{})); err != nil {
+		t.Fatalf("document is not valid XML: %v", err)
+	}
+	if !strings.Contains(documentXML, `xmlns:w15="http://schemas.microsoft.com/office/word/2012/wordml"`) {
+		t.Errorf("w15 namespace not declared: %s", documentXML)
+	}
+	// The order loop, each order's line loop and no section for the empty
+	// notes loop
+	if got := strings.Count(documentXML, "<w15:repeatingSection/>"); got != 3 {
+		t.Errorf("expected 3 repeating sections, got %d: %s", got, documentXML)
+	}
+	if got := strings.Count(documentXML, "<w15:repeatingSectionItem/>"); got != 5 {
+		t.Errorf("expected 5 repeating section items, got %d: %s", got, documentXML)
+	}
+	if got, want := strings.Count(documentXML, "<w:sdt>"), strings.Count(documentXML, "</w:sdt>"); got != 8 || want != 8 {
+		t.Errorf("expected 8 balanced content controls, got %d opened and %d closed", got, want)
+	}
+	assertInOrder(t, documentXML,
+		`<w:alias w:val="orders"/>`,
+		`w:xpath="/ns0:sections[1]/ns0:section[1]/ns0:item"`,
+		`Order 1`,
+		`<w:alias w:val="order.lines"/>`,
+		`w:xpath="/ns0:sections[1]/ns0:section[2]/ns0:item"`,
+		`Bolts &lt;M6&gt;`,
+		`Nuts`,
+		`Order 2`,
+		`w:xpath="/ns0:sections[1]/ns0:section[3]/ns0:item"`,
+		`Washers`,
+	)
+
+	dataXML := extractPartFromDOCX(t, output, "customXml/item1.xml")
+	for _, want := range []string{
+		`<sections xmlns="urn:go-stencil:repeating-sections">`,
+		`<section name="orders"><item><id>1</id><lines><item><product>Bolts &lt;M6&gt;</product><unit_price>0.25</unit_price></item>`,
+		`<section name="order.lines"><item><product>Washers</product><unit_price>0.05</unit_price></item></section>`,
+	} {
+		if !strings.Contains(dataXML, want) {
+			t.Errorf("expected %s in data part: %s", want, dataXML)
+		}
+	}
+	props := extractPartFromDOCX(t, output, "customXml/itemProps1.xml")
+	storeItemID := props[strings.Index(props, `ds:itemID="`)+len(`ds:itemID="`):]
+	storeItemID = storeItemID[:strings.Index(storeItemID, `"`)]
+	if !strings.Contains(documentXML, `w:storeItemID="`+storeItemID+`"`) {
+		t.Errorf("sections are not bound to data part %s", storeItemID)
+	}
+	if rels := extractPartFromDOCX(t, output, "word/_rels/document.xml.rels"); !strings.Contains(rels, `Target="../customXml/item1.xml"`) {
+		t.Errorf("data part not related to the document: %s", rels)
+	}
+	if rels := extractPartFromDOCX(t, output, "customXml/_rels/item1.xml.rels"); !strings.Contains(rels, `Target="itemProps1.xml"`) {
+		t.Errorf("properties part not related to the data part: %s", rels)
+	}
+	if types := extractPartFromDOCX(t, output, "[Content_Types].xml"); !strings.Contains(types, `PartName="/customXml/itemProps1.xml"`) {
+		t.Errorf("properties part has no content type: %s", types)
+	}
+
+	// Without the option loops render as before
+	plain := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, data))
+	if strings.Contains(plain, "<w:sdt>") {
+		t.Errorf("unexpected content controls without RepeatingSections: %s", plain)
+	}
+}
+
+func TestRepeatingSectionElementName(t *testing.T) {
+	tests := map[string]string{
+		"name":       "name",
+		"unit price": "unit_price",
+		"1st":        "_1st",
+		"-x":         "_-x",
+		"a.b-c_d":    "a.b-c_d",
+		"größe":      "größe",
+		"":           "_",
+		"a:b":        "a_b",
+	}
+	for key, want := range tests {
+		if got := repeatingSectionElementName(key); got != want {
+			t.Errorf("repeatingSectionElementName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package stencil
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// applySchemaDefaults returns data with the Default values of the fields of
+// schema set where data lacks the field or holds null
+// (RenderOptions.Schema). The fields of a collection get their defaults
+// in each item. Objects and collections that are missing are not created,
+// so a default applies only where its parent is present. The maps and lists
+// on the way to a default are copied, so data is left unchanged.
+func applySchemaDefaults(data TemplateData, schema *ValidationSchema) TemplateData {
+	if schema == nil {
+		return data
+	}
+	fields := make([]FieldDefinition, 0, len(schema.Fields))
+	collections := schemaCollections(*schema)
+	for _, field := range schema.Fields {
+		field.Path = stripLiteralIndices(normalizeFieldPath(field.Path))
+		if field.Path != "" && field.Default != nil {
+			fields = append(fields, field)
+		}
+	}
+	// Parents get their defaults before their fields
+	sort.SliceStable(fields, func(i, j int) bool {
+		return strings.Count(fields[i].Path, ".") < strings.Count(fields[j].Path, ".")
+	})
+
+	for _, field := range fields {
+		if updated, ok := withDefault(data, strings.Split(field.Path, "."), "", field.Default, collections); ok {
+			data = updated.(TemplateData)
+		}
+	}
+	return data
+}
+
+// withDefault returns a copy of object with value set at the field path
+// segments below it, and whether anything was set.
+func withDefault(object interface{}, segments []string, prefix string, value interface{}, collections map[string]bool) (interface{}, bool) {
+	var fields map[string]interface{}
+	switch m := object.(type) {
+	case TemplateData:
+		fields = m
+	case map[string]interface{}:
+		fields = m
+	default:
+		return object, false
+	}
+
+	key := segments[0]
+	path := key
+	if prefix != "" {
+		path = prefix + "." + key
+	}
+	child := accessMapField(object, key)
+	if len(segments) == 1 {
+		if child != nil {
+			return object, false
+		}
+		return copyWithField(object, fields, key, value), true
+	}
+	if child == nil {
+		return object, false
+	}
+
+	if !collections[path] {
+		updated, ok := withDefault(child, segments[1:], path, value, collections)
+		if !ok {
+			return object, false
+		}
+		return copyWithField(object, fields, key, updated), true
+	}
+
+	list := reflect.ValueOf(child)
+	if !isMergeableList(list) {
+		return object, false
+	}
+	items := make([]interface{}, list.Len())
+	changed := false
+	for i := range items {
+		item, ok := withDefault(list.Index(i).Interface(), segments[1:], path, value, collections)
+		items[i] = item
+		changed = changed || ok
+	}
+	if !changed {
+		return object, false
+	}
+	return copyWithField(object, fields, key, items), true
+}
+
+// copyWithField returns a copy of the map object, whose fields are fields,
+// with key set to value. The copy has the type of object.
+func copyWithField(object interface{}, fields map[string]interface{}, key string, value interface{}) interface{} {
+	copied := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		copied[k] = v
+	}
+	copied[key] = value
+	if _, ok := object.(TemplateData); ok {
+		return TemplateData(copied)
+	}
+	return copied
+}
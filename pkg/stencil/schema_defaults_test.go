@@ -0,0 +1,79 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplySchemaDefaults(t *testing.T) {
+	schema := &ValidationSchema{Fields: []FieldDefinition{
+		{Path: "currency", Type: "string", Default: "EUR"},
+		{Path: "customer.country", Type: "string", Default: "DE"},
+		{Path: "shipping.method", Type: "string", Default: "standard"},
+		{Path: "items", Type: "object", Collection: true},
+		{Path: "items.qty", Type: "number", Default: 1},
+		{Path: "title", Type: "string"},
+	}}
+	data := TemplateData{
+		"customer": map[string]interface{}{"name": "Acme", "country": nil},
+		"items": []interface{}{
+			map[string]interface{}{"name": "Widget"},
+			map[string]interface{}{"name": "Gadget", "qty": 3},
+		},
+		"currency": "USD",
+	}
+
+	got := applySchemaDefaults(data, schema)
+	want := TemplateData{
+		"customer": map[string]interface{}{"name": "Acme", "country": "DE"},
+		"items": []interface{}{
+			map[string]interface{}{"name": "Widget", "qty": 1},
+			map[string]interface{}{"name": "Gadget", "qty": 3},
+		},
+		"currency": "USD",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applySchemaDefaults() = %v, want %v", got, want)
+	}
+
+	// The data is left unchanged
+	if country := data["customer"].(map[string]interface{})["country"]; country != nil {
+		t.Errorf("data was modified: country = %v", country)
+	}
+	if _, ok := data["items"].([]interface{})[0].(map[string]interface{})["qty"]; ok {
+		t.Error("data was modified: qty added")
+	}
+
+	if got := applySchemaDefaults(TemplateData{}, schema); !reflect.DeepEqual(got, TemplateData{"currency": "EUR"}) {
+		t.Errorf("applySchemaDefaults(empty) = %v", got)
+	}
+}
+
+func TestRenderWithDefaults(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Currency: {{currency}}`,
+		`{{for item in items}}{{item.name}} x{{item.qty}};{{end}}`,
+	})
+	data := TemplateData{"items": []interface{}{
+		map[string]interface{}{"name": "Widget"},
+		map[string]interface{}{"name": "Gadget", "qty": 3},
+	}}
+	schema := &ValidationSchema{Fields: []FieldDefinition{
+		{Path: "currency", Type: "string", Default: "EUR"},
+		{Path: "items", Type: "object", Collection: true},
+		{Path: "items.qty", Type: "number", Default: 1},
+	}}
+
+	text := extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{Schema: schema}))
+	for _, want := range []string{"Currency: EUR", "Widget x1;", "Gadget x3;"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in %q", want, text)
+		}
+	}
+
+	text = extractTextFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	if strings.Contains(text, "EUR") || !strings.Contains(text, "Widget x;") {
+		t.Errorf("defaults applied without the option: %q", text)
+	}
+}
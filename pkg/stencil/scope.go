@@ -0,0 +1,72 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The built-in {{scope chapter1}}...{{end}} block directive renders its
+// content with the fields of an object of the data as variables, so a
+// chapter can use {{title}} and {{summary}} instead of {{chapter1.title}}
+// and {{chapter1.summary}}. Names that are not fields of the object still
+// resolve to the data around the block, and scopes can be nested. A scope
+// whose object is missing or null renders its content with the data around
+// it.
+//
+// The tag only opens the block when it has an argument, so a variable
+// named scope can still be used on its own.
+
+const scopeDirectiveName = "scope"
+
+func init() {
+	blockDirectives.directives[scopeDirectiveName] = scopeBlockDirective
+}
+
+func scopeBlockDirective(block *Block) ([]BodyElement, error) {
+	data, err := scopeData(block.Args, block.Data)
+	if err != nil {
+		return nil, err
+	}
+	return block.Render(data)
+}
+
+// scopeData evaluates the object expression of a scope block and returns a
+// child scope of data with the fields of the object.
+func scopeData(expression string, data TemplateData) (TemplateData, error) {
+	node, err := ParseExpressionStrict(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scope %q: %w", strings.TrimSpace(expression), err)
+	}
+	value, err := node.Evaluate(data)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return data, nil
+	}
+	object, ok := mergeableMap(value)
+	if !ok {
+		return nil, fmt.Errorf("scope %s must be an object, got %T", strings.TrimSpace(expression), value)
+	}
+
+	scope := newChildTemplateData(data, len(object))
+	for name, field := range object {
+		if !strings.HasPrefix(name, "\x00") {
+			scope[name] = field
+		}
+	}
+	return scope, nil
+}
+
+// scopeBlockObject returns the object expression of the opening tag of a
+// scope block, as in the value of a TokenBlock token.
+func scopeBlockObject(token Token) (string, bool) {
+	if token.Type != TokenBlock {
+		return "", false
+	}
+	fields := strings.Fields(token.Value)
+	if len(fields) == 0 || fields[0] != scopeDirectiveName {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(token.Value, scopeDirectiveName)), true
+}
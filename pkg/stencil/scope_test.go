@@ -0,0 +1,88 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestScopeBlocks(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{scope chapters[0]}}`,
+		`{{title}}: {{summary}} ({{company}})`,
+		`{{scope author}}`,
+		`by {{name}} for {{title}}`,
+		`{{end}}`,
+		`{{end}}`,
+		`{{scope appendix}}`,
+		`{{title}}`,
+		`{{end}}`,
+		`Scope: {{scope}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := extractTextFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"title":   "Annual Report",
+		"company": "Acme",
+		"scope":   "full",
+		"chapters": []interface{}{map[string]interface{}{
+			"title":   "Results",
+			"summary": "Revenue grew",
+			"author":  map[string]string{"name": "Ada"},
+		}},
+	}))
+	// The missing appendix renders its content with the data around it
+	want := "Results: Revenue grew (Acme)by Ada for ResultsAnnual ReportScope: full"
+	if output != want {
+		t.Errorf("rendered text = %q, want %q", output, want)
+	}
+
+	_, err = tmpl.Render(TemplateData{"chapters": []interface{}{"text"}})
+	if err == nil || !strings.Contains(err.Error(), "scope chapters[0] must be an object, got string") {
+		t.Errorf("expected an error for a scope that is not an object, got %v", err)
+	}
+}
+
+func TestScopeBlocksValidation(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{scope chapter1}}`,
+		`{{title}} {{summary}} {{company}}`,
+		`{{end}}`,
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes: docx,
+		Strict:    true,
+		Schema: ValidationSchema{Fields: []FieldDefinition{
+			{Path: "chapter1", Type: "object"},
+			{Path: "chapter1.title", Type: "string"},
+			{Path: "company", Type: "string"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+	var unknown []string
+	for _, issue := range result.Issues {
+		unknown = append(unknown, issue.Token.Expression)
+	}
+	if strings.Join(unknown, ", ") != "summary" {
+		t.Errorf("issues for %q, want only summary", unknown)
+	}
+
+	dictionary, err := BuildDataDictionary(DataDictionaryInput{DocxBytes: docx})
+	if err != nil {
+		t.Fatalf("BuildDataDictionary failed: %v", err)
+	}
+	var paths []string
+	for _, field := range dictionary.Fields {
+		paths = append(paths, field.Path)
+	}
+	if got := strings.Join(paths, ", "); got != "chapter1, chapter1.company, chapter1.summary, chapter1.title" {
+		t.Errorf("data dictionary paths = %s", got)
+	}
+}
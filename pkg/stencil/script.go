@@ -0,0 +1,545 @@
+//go:build stencil_script
+
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Limits of a single script() call. A script that exceeds them fails the
+// render. maxScriptMemory bounds the estimated size of each value the
+// script computes and of all its variables together, in bytes.
+const (
+	maxScriptLength   = 4096
+	maxScriptSteps    = 10000
+	maxScriptDuration = 100 * time.Millisecond
+	maxScriptMemory   = 1 << 20
+)
+
+var (
+	scriptKeywordRegex    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+	scriptAssignmentRegex = regexp.MustCompile(`(?s)^([a-zA-Z_][a-zA-Z0-9_]*)\s*([-+*/]?)=([^=].*)$`)
+	scriptForRegex        = regexp.MustCompile(`(?s)^([a-zA-Z_][a-zA-Z0-9_]*)\s+in\s+(.+)$`)
+)
+
+// registerScriptFunction registers script(), which runs a small script for
+// logic that template expressions cannot express:
+//
+//	{{script("total = 0; for item in args[0] { total += item.qty * item.price }; return total", items)}}
+//
+// Scripts are statements separated by newlines or semicolons: assignments
+// (x = expr, with let, +=, -=, *= and /=), if/else if/else, for x in list,
+// while and return, with blocks in braces and # comments. Expressions are
+// template expressions and may call template functions other than script(),
+// under the function policy of the render. The arguments after the source
+// are available as the list args. Scripts have no access to the render data
+// or anything outside the render, and are limited in length, steps, running
+// time and memory.
+func registerScriptFunction(registry *DefaultFunctionRegistry) {
+	registry.RegisterFunction(&renderHelperFunction{name: "script", minArgs: 1, maxArgs: -1, handler: func(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+		source, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("script() requires the script source as a string, got %T", args[0])
+		}
+		return runScript(helpers, source, args[1:])
+	}})
+}
+
+// runScript parses and runs source, called by a render with caller, with
+// args bound to the args variable.
+func runScript(caller *renderHelpers, source string, args []interface{}) (interface{}, error) {
+	if len(source) > maxScriptLength {
+		return nil, fmt.Errorf("script is longer than %d bytes", maxScriptLength)
+	}
+
+	tokens, err := lexScript(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := &scriptParser{tokens: tokens}
+	stmts, err := parser.parseBlock(false)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &scriptRun{
+		env: TemplateData{
+			"args":           append([]interface{}(nil), args...),
+			renderHelpersKey: scriptHelpers(caller),
+		},
+		sizes:    make(map[string]int),
+		deadline: time.Now().Add(maxScriptDuration),
+	}
+	result, _, err := run.exec(stmts)
+	return result, err
+}
+
+// scriptHelpers returns the helpers of a script called by a render with
+// caller. The script shares the sequence store and locale of the render and
+// calls functions under its function policy, which also denies script() so
+// scripts cannot start other scripts.
+func scriptHelpers(caller *renderHelpers) *renderHelpers {
+	policy := &FunctionPolicy{Deny: []string{"script"}}
+	if caller.functionPolicy != nil {
+		scoped := *caller.functionPolicy
+		scoped.Deny = append(slices.Clone(scoped.Deny), "script")
+		policy = &scoped
+	}
+	return &renderHelpers{
+		sequences:      caller.sequences,
+		locale:         caller.locale,
+		plurals:        caller.plurals,
+		functionPolicy: policy,
+		valueSizeLimit: maxScriptMemory,
+		timedOutCalls:  caller.timedOutCalls,
+	}
+}
+
+type scriptTokenKind int
+
+const (
+	scriptText scriptTokenKind = iota
+	scriptOpen
+	scriptClose
+	scriptEnd
+)
+
+type scriptToken struct {
+	kind scriptTokenKind
+	text string
+	line int
+}
+
+// lexScript splits source into statement texts, braces and statement ends.
+// Braces, semicolons and newlines inside strings, parentheses and brackets
+// belong to the statement text.
+func lexScript(source string) ([]scriptToken, error) {
+	var tokens []scriptToken
+	var text strings.Builder
+	line, textLine := 1, 1
+	depth := 0
+	var quote rune
+	escaped, comment := false, false
+
+	flush := func() {
+		if s := strings.TrimSpace(text.String()); s != "" {
+			tokens = append(tokens, scriptToken{kind: scriptText, text: s, line: textLine})
+		}
+		text.Reset()
+	}
+
+	for _, r := range source {
+		if comment && r != '\n' {
+			continue
+		}
+		comment = false
+
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+		} else {
+			switch r {
+			case '#':
+				comment = true
+				continue
+			case '"', '\'':
+				quote = r
+			case '(', '[':
+				depth++
+			case ')', ']':
+				depth--
+			case '{', '}', ';', '\n':
+				if depth > 0 && r == '\n' {
+					break
+				}
+				if depth > 0 {
+					return nil, fmt.Errorf("script line %d: unexpected %q inside parentheses", line, r)
+				}
+				flush()
+				kind := scriptEnd
+				if r == '{' {
+					kind = scriptOpen
+				} else if r == '}' {
+					kind = scriptClose
+				}
+				tokens = append(tokens, scriptToken{kind: kind, line: line})
+				if r == '\n' {
+					line++
+				}
+				continue
+			}
+		}
+
+		if strings.TrimSpace(text.String()) == "" {
+			textLine = line
+		}
+		text.WriteRune(r)
+		if r == '\n' {
+			line++
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("script line %d: unterminated string", textLine)
+	}
+	flush()
+	return tokens, nil
+}
+
+type scriptStmt interface{}
+
+type scriptAssign struct {
+	line     int
+	name     string
+	operator string
+	value    ExpressionNode
+}
+
+type scriptExpr struct {
+	line  int
+	value ExpressionNode
+}
+
+type scriptReturn struct {
+	line  int
+	value ExpressionNode
+}
+
+type scriptIf struct {
+	line      int
+	condition ExpressionNode
+	then      []scriptStmt
+	otherwise []scriptStmt
+}
+
+type scriptFor struct {
+	line int
+	name string
+	list ExpressionNode
+	body []scriptStmt
+}
+
+type scriptWhile struct {
+	line      int
+	condition ExpressionNode
+	body      []scriptStmt
+}
+
+type scriptParser struct {
+	tokens []scriptToken
+	pos    int
+}
+
+// parseBlock parses statements up to the closing brace of a nested block or
+// the end of the script.
+func (p *scriptParser) parseBlock(nested bool) ([]scriptStmt, error) {
+	var stmts []scriptStmt
+	for {
+		p.skipEnds()
+		if p.pos >= len(p.tokens) {
+			if nested {
+				return nil, fmt.Errorf("script: missing }")
+			}
+			return stmts, nil
+		}
+
+		tok := p.tokens[p.pos]
+		p.pos++
+		switch tok.kind {
+		case scriptClose:
+			if !nested {
+				return nil, fmt.Errorf("script line %d: unexpected }", tok.line)
+			}
+			return stmts, nil
+		case scriptOpen:
+			return nil, fmt.Errorf("script line %d: unexpected {", tok.line)
+		}
+
+		stmt, err := p.parseStatement(tok)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+func (p *scriptParser) skipEnds() {
+	for p.pos < len(p.tokens) && p.tokens[p.pos].kind == scriptEnd {
+		p.pos++
+	}
+}
+
+func (p *scriptParser) parseStatement(tok scriptToken) (scriptStmt, error) {
+	keyword := scriptKeywordRegex.FindString(tok.text)
+	rest := strings.TrimSpace(tok.text[len(keyword):])
+
+	switch keyword {
+	case "if":
+		return p.parseIf(tok.line, rest)
+	case "for":
+		match := scriptForRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, fmt.Errorf("script line %d: expected for name in list", tok.line)
+		}
+		list, err := parseScriptExpression(tok.line, match[2])
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBody(tok.line)
+		if err != nil {
+			return nil, err
+		}
+		return &scriptFor{line: tok.line, name: match[1], list: list, body: body}, nil
+	case "while":
+		condition, err := parseScriptExpression(tok.line, rest)
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBody(tok.line)
+		if err != nil {
+			return nil, err
+		}
+		return &scriptWhile{line: tok.line, condition: condition, body: body}, nil
+	case "return":
+		if rest == "" {
+			return &scriptReturn{line: tok.line}, nil
+		}
+		value, err := parseScriptExpression(tok.line, rest)
+		if err != nil {
+			return nil, err
+		}
+		return &scriptReturn{line: tok.line, value: value}, nil
+	case "let":
+		if scriptAssignmentRegex.MatchString(rest) {
+			return parseScriptAssignment(tok.line, rest)
+		}
+		return nil, fmt.Errorf("script line %d: expected let name = value", tok.line)
+	case "else":
+		return nil, fmt.Errorf("script line %d: else without if", tok.line)
+	}
+
+	if scriptAssignmentRegex.MatchString(tok.text) {
+		return parseScriptAssignment(tok.line, tok.text)
+	}
+	value, err := parseScriptExpression(tok.line, tok.text)
+	if err != nil {
+		return nil, err
+	}
+	return &scriptExpr{line: tok.line, value: value}, nil
+}
+
+// parseIf parses an if statement with its else if and else branches.
+func (p *scriptParser) parseIf(line int, condition string) (scriptStmt, error) {
+	cond, err := parseScriptExpression(line, condition)
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parseBody(line)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &scriptIf{line: line, condition: cond, then: then}
+
+	// An else may follow on the same or a later line
+	start := p.pos
+	p.skipEnds()
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != scriptText || scriptKeywordRegex.FindString(p.tokens[p.pos].text) != "else" {
+		p.pos = start
+		return stmt, nil
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	rest := strings.TrimSpace(tok.text[len("else"):])
+	switch {
+	case rest == "":
+		stmt.otherwise, err = p.parseBody(tok.line)
+	case scriptKeywordRegex.FindString(rest) == "if":
+		var elseIf scriptStmt
+		elseIf, err = p.parseIf(tok.line, strings.TrimSpace(rest[len("if"):]))
+		stmt.otherwise = []scriptStmt{elseIf}
+	default:
+		err = fmt.Errorf("script line %d: expected { or if after else", tok.line)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseBody parses the braced block of a statement.
+func (p *scriptParser) parseBody(line int) ([]scriptStmt, error) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != scriptOpen {
+		return nil, fmt.Errorf("script line %d: expected {", line)
+	}
+	p.pos++
+	return p.parseBlock(true)
+}
+
+func parseScriptAssignment(line int, text string) (scriptStmt, error) {
+	match := scriptAssignmentRegex.FindStringSubmatch(text)
+	value, err := parseScriptExpression(line, match[3])
+	if err != nil {
+		return nil, err
+	}
+	return &scriptAssign{line: line, name: match[1], operator: match[2], value: value}, nil
+}
+
+func parseScriptExpression(line int, text string) (ExpressionNode, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("script line %d: missing expression", line)
+	}
+	node, err := ParseExpressionStrict(text)
+	if err != nil {
+		return nil, fmt.Errorf("script line %d: %w", line, err)
+	}
+	return node, nil
+}
+
+// scriptRun is the state of a running script. Variables live in a single
+// scope for the whole script.
+type scriptRun struct {
+	env   TemplateData
+	steps int
+	// sizes holds the estimated size of each variable the script assigned,
+	// and memory their sum
+	sizes    map[string]int
+	memory   int
+	deadline time.Time
+}
+
+// exec runs stmts and returns the value of a return statement, if one ran.
+func (r *scriptRun) exec(stmts []scriptStmt) (interface{}, bool, error) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *scriptAssign:
+			if err := r.step(s.line); err != nil {
+				return nil, false, err
+			}
+			value, err := r.eval(s.line, s.value)
+			if err != nil {
+				return nil, false, err
+			}
+			if s.operator != "" {
+				value, err = EvaluateBinaryOperation(r.env[s.name], s.operator, value)
+				if err != nil {
+					return nil, false, fmt.Errorf("script line %d: %w", s.line, err)
+				}
+			}
+			if err := r.assign(s.line, s.name, value); err != nil {
+				return nil, false, err
+			}
+		case *scriptExpr:
+			if err := r.step(s.line); err != nil {
+				return nil, false, err
+			}
+			if _, err := r.eval(s.line, s.value); err != nil {
+				return nil, false, err
+			}
+		case *scriptReturn:
+			if err := r.step(s.line); err != nil {
+				return nil, false, err
+			}
+			if s.value == nil {
+				return nil, true, nil
+			}
+			value, err := r.eval(s.line, s.value)
+			return value, err == nil, err
+		case *scriptIf:
+			if err := r.step(s.line); err != nil {
+				return nil, false, err
+			}
+			condition, err := r.eval(s.line, s.condition)
+			if err != nil {
+				return nil, false, err
+			}
+			body := s.otherwise
+			if isTruthy(condition) {
+				body = s.then
+			}
+			if value, returned, err := r.exec(body); returned || err != nil {
+				return value, returned, err
+			}
+		case *scriptFor:
+			listValue, err := r.eval(s.line, s.list)
+			if err != nil {
+				return nil, false, err
+			}
+			items, err := toSlice(listValue)
+			if err != nil {
+				return nil, false, fmt.Errorf("script line %d: %w", s.line, err)
+			}
+			for _, item := range items {
+				if err := r.step(s.line); err != nil {
+					return nil, false, err
+				}
+				if err := r.assign(s.line, s.name, item); err != nil {
+					return nil, false, err
+				}
+				if value, returned, err := r.exec(s.body); returned || err != nil {
+					return value, returned, err
+				}
+			}
+		case *scriptWhile:
+			for {
+				if err := r.step(s.line); err != nil {
+					return nil, false, err
+				}
+				condition, err := r.eval(s.line, s.condition)
+				if err != nil {
+					return nil, false, err
+				}
+				if !isTruthy(condition) {
+					break
+				}
+				if value, returned, err := r.exec(s.body); returned || err != nil {
+					return value, returned, err
+				}
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// step counts a step of the script and fails once the script exceeds its
+// limits.
+func (r *scriptRun) step(line int) error {
+	r.steps++
+	if r.steps > maxScriptSteps {
+		return fmt.Errorf("script line %d: script exceeded %d steps", line, maxScriptSteps)
+	}
+	if time.Now().After(r.deadline) {
+		return fmt.Errorf("script line %d: script exceeded its time limit of %s", line, maxScriptDuration)
+	}
+	return nil
+}
+
+// assign sets the variable name and fails once the variables of the script
+// exceed maxScriptMemory.
+func (r *scriptRun) assign(line int, name string, value interface{}) error {
+	size := estimateValueSize(value, maxScriptMemory)
+	memory := r.memory - r.sizes[name] + size
+	if memory > maxScriptMemory {
+		return fmt.Errorf("script line %d: script exceeded its memory limit of %d bytes", line, maxScriptMemory)
+	}
+	r.env[name] = value
+	r.sizes[name] = size
+	r.memory = memory
+	return nil
+}
+
+func (r *scriptRun) eval(line int, node ExpressionNode) (interface{}, error) {
+	value, err := node.Evaluate(r.env)
+	if err != nil {
+		return nil, fmt.Errorf("script line %d: %w", line, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,7 @@
+//go:build !stencil_script
+
+package stencil
+
+// registerScriptFunction registers nothing unless the library is built with
+// the stencil_script tag, which adds the script() function.
+func registerScriptFunction(registry *DefaultFunctionRegistry) {}
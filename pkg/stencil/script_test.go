@@ -0,0 +1,130 @@
+//go:build stencil_script
+
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptFunction(t *testing.T) {
+	data := TemplateData{
+		"items": []interface{}{
+			map[string]interface{}{"qty": 2, "price": 10},
+			map[string]interface{}{"qty": 0, "price": 99},
+			map[string]interface{}{"qty": 1, "price": 5},
+		},
+		"discount": `
+			# Tiered discount
+			total = 0
+			for item in args[0] {
+				if item.qty > 0 { total += item.qty * item.price }
+			}
+			if total > 100 {
+				return total * 0.9
+			} else if total > 20 {
+				return total - 1
+			}
+			return total`,
+	}
+
+	tests := []struct {
+		expr    string
+		want    interface{}
+		wantErr string
+	}{
+		{expr: `script(discount, items)`, want: 24},
+		{expr: `script("let n = 0; while n < 5 { n = n + 2 }; return n")`, want: 6},
+		{expr: `script("x = 'a;b{c}'; return x + args[0]", "!")`, want: "a;b{c}!"},
+		{expr: `script("return uppercase(args[0])", "hi")`, want: "HI"},
+		{expr: `script("x = 1")`, want: nil},
+		{expr: `script("while true { }")`, wantErr: "exceeded 10000 steps"},
+		{expr: `script("return script('return 1')")`, wantErr: "function script is not allowed"},
+		{expr: `script("return data().items")`, want: nil},
+		{expr: `script("if true { return 1")`, wantErr: "missing }"},
+		{expr: `script("else { }")`, wantErr: "else without if"},
+		{expr: `script(42)`, wantErr: "requires the script source as a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+
+			got, err := node.Evaluate(data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptLength(t *testing.T) {
+	if _, err := runScript(newRenderHelpers(nil), strings.Repeat(" ", maxScriptLength+1), nil); err == nil {
+		t.Error("expected an error for a script over the length limit")
+	}
+}
+
+func TestScriptMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		args   []interface{}
+	}{
+		{name: "doubling string", source: `s = "x"; while true { s = s + s }`},
+		{name: "compound assignment", source: `s = "x"; while true { s += s }`},
+		{name: "function result", source: `return range(100000)`},
+		{name: "single expression", source: `s = args[0]; return s + s + s`, args: []interface{}{strings.Repeat("x", maxScriptMemory/2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runScript(newRenderHelpers(nil), tt.source, tt.args)
+			if err == nil || !strings.Contains(err.Error(), "memory limit") {
+				t.Fatalf("expected a memory limit error, got %v", err)
+			}
+		})
+	}
+
+	got, err := runScript(newRenderHelpers(nil), `s = args[0]; return s + "!"`, []interface{}{strings.Repeat("x", 1000)})
+	if err != nil || len(got.(string)) != 1001 {
+		t.Fatalf("expected a script within the limit to run, got %v", err)
+	}
+}
+
+func TestScriptUsesTheRenderPolicyAndSequenceStore(t *testing.T) {
+	sequences := NewMemorySequenceStore()
+	data := TemplateData{renderHelpersKey: &renderHelpers{
+		sequences:      sequences,
+		functionPolicy: &FunctionPolicy{Deny: []string{"uppercase"}},
+	}}
+
+	node, err := ParseExpression(`script("return uppercase(args[0])", "hi")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Evaluate(data); err == nil || !strings.Contains(err.Error(), "function uppercase is not allowed") {
+		t.Fatalf("expected the render's policy to deny uppercase() in the script, got %v", err)
+	}
+
+	node, err = ParseExpression(`script("sequence('invoice'); return sequence('invoice')")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := node.Evaluate(data); err != nil || got != int64(2) {
+		t.Fatalf("script sequence = %v, %v; want 2", got, err)
+	}
+	if next, _ := sequences.Next("invoice"); next != 3 {
+		t.Fatalf("render's sequence store counter = %d, want 3", next)
+	}
+}
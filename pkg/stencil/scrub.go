@@ -0,0 +1,328 @@
+package stencil
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultScrubReplacement replaces redacted values when
+// ScrubPolicy.Replacement is empty.
+const DefaultScrubReplacement = "[REDACTED]"
+
+// minScrubbedValueLength is the length below which sensitive values are not
+// searched for in error messages, where they would match unrelated text
+// such as line numbers.
+const minScrubbedValueLength = 3
+
+// maxScrubDepth bounds how deep values are walked, so cyclic data cannot
+// recurse forever. Values nested deeper are replaced as a whole.
+const maxScrubDepth = 32
+
+// ScrubPolicy redacts sensitive render data, such as personal data of
+// customers, before it is logged or interpolated into error messages. Set
+// it with SetScrubPolicy.
+//
+// Debug logging of data and evaluated variables redacts the values of
+// sensitive fields. Errors returned by renders have every occurrence of a
+// sensitive value of the render data replaced in their message, including
+// function arguments and panic values; the wrapped errors keep their
+// original values, so errors.As still reaches them.
+type ScrubPolicy struct {
+	// Fields names the sensitive fields as dot-separated paths such as
+	// "customer.email", where list items are skipped as in templates:
+	// "orders.card" matches the card of every order. Each segment is a
+	// case-insensitive path.Match pattern. A pattern without a dot matches
+	// fields of that name at any depth, so "*password*" matches every
+	// field whose name contains password.
+	Fields []string
+	// Replacement replaces redacted values. Empty means "[REDACTED]".
+	Replacement string
+	// Scrub decides about values no entry of Fields matches. It receives
+	// the path of the value and reports whether to redact it. Nil redacts
+	// only the fields listed.
+	Scrub func(path string, value interface{}) bool
+}
+
+var scrubPolicy atomic.Pointer[ScrubPolicy]
+
+// SetScrubPolicy sets the policy that redacts sensitive data in logs and
+// render errors. Nil disables scrubbing.
+//
+// Example:
+//
+//	stencil.SetScrubPolicy(&stencil.ScrubPolicy{
+//	    Fields: append(schema.SensitiveFields(), "*password*", "*.iban"),
+//	})
+func SetScrubPolicy(policy *ScrubPolicy) {
+	scrubPolicy.Store(policy)
+}
+
+// GetScrubPolicy returns the policy set with SetScrubPolicy, or nil.
+func GetScrubPolicy() *ScrubPolicy {
+	return scrubPolicy.Load()
+}
+
+// ScrubData returns a copy of data with the values of sensitive fields
+// replaced, such as to log render data. Maps, lists and structs are copied
+// as far as they are walked; data itself is not changed. A nil policy
+// returns data as is.
+func (p *ScrubPolicy) ScrubData(data TemplateData) TemplateData {
+	if p == nil || data == nil {
+		return data
+	}
+	scrubbed := make(TemplateData, len(data))
+	for key, value := range data {
+		if isInternalDataKey(key) {
+			continue
+		}
+		scrubbed[key] = p.scrubValue([]string{key}, value, 0)
+	}
+	return scrubbed
+}
+
+// replacement returns the text that replaces redacted values.
+func (p *ScrubPolicy) replacement() string {
+	if p.Replacement != "" {
+		return p.Replacement
+	}
+	return DefaultScrubReplacement
+}
+
+// sensitive reports whether the field at path is redacted.
+func (p *ScrubPolicy) sensitive(fieldPath []string, value interface{}) bool {
+	for _, pattern := range p.Fields {
+		if scrubPatternMatches(strings.Split(strings.ToLower(pattern), "."), fieldPath) {
+			return true
+		}
+	}
+	return p.Scrub != nil && p.Scrub(strings.Join(fieldPath, "."), value)
+}
+
+// scrubPatternMatches reports whether the segments of a Fields pattern
+// match a field path. A single segment matches the last field of the path.
+func scrubPatternMatches(pattern, fieldPath []string) bool {
+	if len(pattern) == 1 {
+		return scrubSegmentMatches(pattern[0], fieldPath[len(fieldPath)-1])
+	}
+	if len(pattern) != len(fieldPath) {
+		return false
+	}
+	for i, segment := range pattern {
+		if !scrubSegmentMatches(segment, fieldPath[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func scrubSegmentMatches(pattern, field string) bool {
+	matched, err := path.Match(pattern, strings.ToLower(field))
+	return err == nil && matched
+}
+
+// scrubValue returns value with its sensitive fields replaced.
+func (p *ScrubPolicy) scrubValue(fieldPath []string, value interface{}, depth int) interface{} {
+	if value == nil {
+		return nil
+	}
+	if depth > maxScrubDepth || p.sensitive(fieldPath, value) {
+		return p.replacement()
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return value
+		}
+		scrubbed := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			scrubbed[key] = p.scrubValue(appendScrubPath(fieldPath, key), iter.Value().Interface(), depth+1)
+		}
+		return scrubbed
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return value
+		}
+		scrubbed := make([]interface{}, v.Len())
+		for i := range scrubbed {
+			scrubbed[i] = p.scrubValue(fieldPath, v.Index(i).Interface(), depth+1)
+		}
+		return scrubbed
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return value
+		}
+		return p.scrubValue(fieldPath, v.Elem().Interface(), depth+1)
+	case reflect.Struct:
+		scrubbed := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.IsExported() {
+				scrubbed[field.Name] = p.scrubValue(appendScrubPath(fieldPath, field.Name), v.Field(i).Interface(), depth+1)
+			}
+		}
+		return scrubbed
+	}
+	return value
+}
+
+// isInternalDataKey reports whether a render data key holds state of the
+// engine rather than data.
+func isInternalDataKey(key string) bool {
+	return strings.HasPrefix(key, "\x00") || key == "__functions__"
+}
+
+// appendScrubPath returns fieldPath followed by key without sharing the
+// backing array of fieldPath.
+func appendScrubPath(fieldPath []string, key string) []string {
+	next := make([]string, len(fieldPath)+1)
+	copy(next, fieldPath)
+	next[len(fieldPath)] = key
+	return next
+}
+
+// sensitiveFieldValue is the text of a sensitive value and the path of
+// the field holding it, with list indexes, such as "orders[1].card".
+type sensitiveFieldValue struct {
+	path string
+	text string
+}
+
+// sensitiveFieldValues returns the sensitive values in data at least
+// minScrubbedValueLength long, including the values nested in sensitive
+// maps, lists and structs, in no particular order.
+func (p *ScrubPolicy) sensitiveFieldValues(data TemplateData) []sensitiveFieldValue {
+	var values []sensitiveFieldValue
+	var collect func(fieldPath []string, concretePath string, value interface{}, sensitive bool, depth int)
+	collect = func(fieldPath []string, concretePath string, value interface{}, sensitive bool, depth int) {
+		if value == nil || depth > maxScrubDepth {
+			return
+		}
+		sensitive = sensitive || p.sensitive(fieldPath, value)
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() == reflect.String {
+				iter := v.MapRange()
+				for iter.Next() {
+					key := iter.Key().String()
+					collect(appendScrubPath(fieldPath, key), concretePath+"."+key, iter.Value().Interface(), sensitive, depth+1)
+				}
+				return
+			}
+		case reflect.Slice, reflect.Array:
+			if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+				for i := 0; i < v.Len(); i++ {
+					collect(fieldPath, fmt.Sprintf("%s[%d]", concretePath, i), v.Index(i).Interface(), sensitive, depth+1)
+				}
+				return
+			}
+		case reflect.Pointer:
+			if !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+				collect(fieldPath, concretePath, v.Elem().Interface(), sensitive, depth+1)
+				return
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if field := v.Type().Field(i); field.IsExported() {
+					collect(appendScrubPath(fieldPath, field.Name), concretePath+"."+field.Name, v.Field(i).Interface(), sensitive, depth+1)
+				}
+			}
+			return
+		}
+		if text := fmt.Sprint(value); sensitive && len(text) >= minScrubbedValueLength {
+			values = append(values, sensitiveFieldValue{path: concretePath, text: text})
+		}
+	}
+	for key, value := range data {
+		if !isInternalDataKey(key) {
+			collect([]string{key}, key, value, false, 0)
+		}
+	}
+	return values
+}
+
+// sensitiveValues returns the text of the sensitive values in data, longest
+// first, for redacting messages.
+func (p *ScrubPolicy) sensitiveValues(data TemplateData) []string {
+	seen := make(map[string]bool)
+	values := make([]string, 0)
+	for _, value := range p.sensitiveFieldValues(data) {
+		if !seen[value.text] {
+			seen[value.text] = true
+			values = append(values, value.text)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
+
+// scrubbedError is an error whose message has sensitive values replaced.
+// It wraps the original error.
+type scrubbedError struct {
+	err     error
+	message string
+}
+
+func (e *scrubbedError) Error() string { return e.message }
+func (e *scrubbedError) Unwrap() error { return e.err }
+
+// scrubRenderError replaces the sensitive values of data in the message of
+// err under the scrub policy.
+func scrubRenderError(err error, data TemplateData) error {
+	policy := GetScrubPolicy()
+	if err == nil || policy == nil {
+		return err
+	}
+	values := policy.sensitiveValues(data)
+	if len(values) == 0 {
+		return err
+	}
+	message := err.Error()
+	scrubbed := message
+	for _, value := range values {
+		scrubbed = strings.ReplaceAll(scrubbed, value, policy.replacement())
+	}
+	if scrubbed == message {
+		return err
+	}
+	return &scrubbedError{err: err, message: scrubbed}
+}
+
+// scrubForLog returns value with its sensitive fields replaced under the
+// scrub policy when it is render data, and value otherwise.
+func scrubForLog(value interface{}) interface{} {
+	policy := GetScrubPolicy()
+	if policy == nil {
+		return value
+	}
+	switch data := value.(type) {
+	case TemplateData:
+		return policy.ScrubData(data)
+	case map[string]interface{}:
+		return map[string]interface{}(policy.ScrubData(data))
+	}
+	return value
+}
+
+// scrubFieldForLog returns the value of the variable at fieldPath, or the
+// replacement when the scrub policy redacts it.
+func scrubFieldForLog(fieldPath []string, value interface{}) interface{} {
+	policy := GetScrubPolicy()
+	if policy == nil || len(fieldPath) == 0 {
+		return value
+	}
+	return policy.scrubValue(fieldPath, value, 0)
+}
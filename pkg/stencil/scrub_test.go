@@ -0,0 +1,111 @@
+package stencil
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScrubData(t *testing.T) {
+	type card struct {
+		Number string
+		Brand  string
+	}
+	policy := &ScrubPolicy{
+		Fields: []string{"customer.email", "*password*", "orders.card"},
+		Scrub: func(path string, value interface{}) bool {
+			return path == "customer.phone"
+		},
+	}
+	data := TemplateData{
+		"customer": map[string]interface{}{
+			"name":          "Ada",
+			"email":         "ada@example.com",
+			"phone":         "+1 555 0100",
+			"loginPassword": "secret",
+		},
+		"orders": []interface{}{
+			map[string]interface{}{"id": 1, "card": card{Number: "4111", Brand: "Visa"}},
+		},
+		"payment": &card{Number: "5500", Brand: "MC"},
+	}
+
+	got := policy.ScrubData(data)
+	want := TemplateData{
+		"customer": map[string]interface{}{
+			"name":          "Ada",
+			"email":         DefaultScrubReplacement,
+			"phone":         DefaultScrubReplacement,
+			"loginPassword": DefaultScrubReplacement,
+		},
+		"orders": []interface{}{
+			map[string]interface{}{"id": 1, "card": DefaultScrubReplacement},
+		},
+		"payment": map[string]interface{}{"Number": "5500", "Brand": "MC"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScrubData() = %#v, want %#v", got, want)
+	}
+	if data["customer"].(map[string]interface{})["email"] != "ada@example.com" {
+		t.Error("ScrubData changed the data")
+	}
+
+	var nilPolicy *ScrubPolicy
+	if got := nilPolicy.ScrubData(data); !reflect.DeepEqual(got, data) {
+		t.Error("a nil policy should return the data as is")
+	}
+
+	schema := ValidationSchema{Fields: []FieldDefinition{
+		{Path: "customer.name", Type: "string"},
+		{Path: "customer.email", Type: "string", Sensitive: true},
+	}}
+	if got := schema.SensitiveFields(); !reflect.DeepEqual(got, []string{"customer.email"}) {
+		t.Errorf("SensitiveFields() = %v", got)
+	}
+}
+
+func TestScrubPolicyRedactsRenderErrorsAndLogs(t *testing.T) {
+	previous := GetScrubPolicy()
+	defer SetScrubPolicy(previous)
+	previousLogger := GetLogger()
+	defer SetLogger(previousLogger)
+
+	docx := createDOCXWithParagraphs(t, []string{"Contact {{customer.name}}", "{{url(customer.email)}}"})
+	data := TemplateData{"customer": map[string]interface{}{"name": "Ada", "email": "ada@example.com"}}
+	render := func() error {
+		tmpl, err := prepare(bytes.NewReader(docx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tmpl.Close()
+		_, err = tmpl.Render(data)
+		return err
+	}
+
+	SetScrubPolicy(nil)
+	unscrubbed := render()
+	if unscrubbed == nil || !strings.Contains(unscrubbed.Error(), "ada@example.com") {
+		t.Fatalf("expected the error to name the value, got %v", unscrubbed)
+	}
+
+	var logs bytes.Buffer
+	SetLogger(NewLogger(&logs, LogDebug))
+	SetScrubPolicy(&ScrubPolicy{Fields: []string{"customer.email"}, Replacement: "***"})
+	err := render()
+	if err == nil {
+		t.Fatal("expected the render to fail")
+	}
+	if strings.Contains(err.Error(), "ada@example.com") || !strings.Contains(err.Error(), "***") {
+		t.Errorf("error = %v, want the email redacted", err)
+	}
+	if ErrorCodeOf(err) != ErrorCodeOf(unscrubbed) {
+		t.Errorf("error code = %s, want %s", ErrorCodeOf(err), ErrorCodeOf(unscrubbed))
+	}
+	if strings.Contains(logs.String(), "ada@example.com") {
+		t.Errorf("debug log leaks the email:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "Ada") {
+		t.Error("expected the debug log to keep fields that are not sensitive")
+	}
+}
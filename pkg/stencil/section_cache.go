@@ -0,0 +1,180 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The built-in {{cache "name" keys=a,b ttl=1h}}...{{end}} block directive
+// keeps the rendered elements of a template section and reuses them in
+// later renders of the same template, as long as the values of the listed
+// keys are the same and the entry has not expired.
+
+const (
+	cacheDirectiveName = "cache"
+
+	// maxCachedSections bounds the entries kept per template.
+	maxCachedSections = 1024
+)
+
+// cacheOptionRegex matches the options of a cache block.
+var cacheOptionRegex = regexp.MustCompile(`(?:^|\s)(ttl|keys)=`)
+
+func init() {
+	blockDirectives.directives[cacheDirectiveName] = cacheBlockDirective
+}
+
+// sectionCache holds the rendered sections of a template.
+type sectionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSection
+}
+
+type cachedSection struct {
+	elements []BodyElement
+	expires  time.Time // zero for no expiry
+}
+
+func (c *sectionCache) get(key string, now time.Time) ([]BodyElement, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.elements, true
+}
+
+func (c *sectionCache) put(key string, entry cachedSection, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedSection)
+	}
+	if len(c.entries) >= maxCachedSections {
+		for k, e := range c.entries {
+			if !e.expires.IsZero() && !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < maxCachedSections {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// cacheBlockDirective renders a cache block, reusing the cached elements
+// when possible.
+func cacheBlockDirective(block *Block) ([]BodyElement, error) {
+	key, ttl, err := parseCacheBlockArgs(block)
+	if err != nil {
+		return nil, err
+	}
+	ctx := block.ctx
+	if ctx == nil || ctx.template == nil {
+		return block.Render(block.Data)
+	}
+	cache := &ctx.template.sections
+	// Audience blocks inside the section render differently per audience.
+	if audiences := selectedAudiencesKey(ctx.options); audiences != "" {
+		key += "|audiences=" + audiences
+	}
+	if ctx.previewMissingSections() {
+		key += "|preview"
+	}
+
+	now := time.Now()
+	if elements, ok := cache.get(key, now); ok {
+		return cloneBody(&Body{Elements: elements}).Elements, nil
+	}
+
+	before := renderContextResourceCount(ctx)
+	rendered, err := block.Render(block.Data)
+	if err != nil {
+		return nil, err
+	}
+	// Sections that pulled fragments, media, links or OOXML fragments into
+	// this render refer to resources of this render only.
+	if renderContextResourceCount(ctx) == before {
+		entry := cachedSection{elements: cloneBody(&Body{Elements: rendered}).Elements}
+		if ttl > 0 {
+			entry.expires = now.Add(ttl)
+		}
+		cache.put(key, entry, now)
+	}
+	return rendered, nil
+}
+
+// parseCacheBlockArgs returns the cache key and time to live of a cache
+// block: the section name, then the values of the keys option.
+func parseCacheBlockArgs(block *Block) (string, time.Duration, error) {
+	args := block.Args
+	options := cacheOptionRegex.FindAllStringSubmatchIndex(args, -1)
+	nameExpr := args
+	if len(options) > 0 {
+		nameExpr = args[:options[0][0]]
+	}
+	name, err := block.Evaluate(strings.TrimSpace(nameExpr))
+	if err != nil {
+		return "", 0, err
+	}
+	nameString, ok := name.(string)
+	if !ok || nameString == "" {
+		return "", 0, fmt.Errorf("cache block name must be a non-empty string, got %v", name)
+	}
+
+	var key strings.Builder
+	key.WriteString(fmt.Sprintf("%q", nameString))
+	var ttl time.Duration
+	for i, option := range options {
+		end := len(args)
+		if i+1 < len(options) {
+			end = options[i+1][0]
+		}
+		value := strings.TrimSpace(args[option[1]:end])
+		switch args[option[2]:option[3]] {
+		case "ttl":
+			ttl, err = time.ParseDuration(value)
+			if err != nil || ttl <= 0 {
+				return "", 0, fmt.Errorf("invalid cache ttl %q", value)
+			}
+		case "keys":
+			for _, expr := range strings.Split(value, ",") {
+				expr = strings.TrimSpace(expr)
+				if expr == "" {
+					return "", 0, fmt.Errorf("empty key in cache keys %q", value)
+				}
+				keyValue, err := block.Evaluate(expr)
+				if err != nil {
+					return "", 0, err
+				}
+				key.WriteString(fmt.Sprintf("|%s=%#v", expr, keyValue))
+			}
+		}
+	}
+	return key.String(), ttl, nil
+}
+
+// renderContextResourceCount counts the resources a render has collected
+// that rendered elements can refer to.
+func renderContextResourceCount(ctx *renderContext) int {
+	count := 0
+	if ctx.editable != nil {
+		count = ctx.editable.count
+	}
+	return count + len(ctx.ooxmlFragments) + len(ctx.linkMarkers) + len(ctx.fragmentMedia) +
+		len(ctx.fragmentRelationships) + len(ctx.usedDocxFragments) + len(ctx.includeModifiers) +
+		len(ctx.headerFooterFragments) + len(ctx.landscapeAppendices) + len(ctx.embeddedFiles) + len(ctx.definedTerms) +
+		len(ctx.importedSectionParts) + len(ctx.repeatingSections.sectionList())
+}
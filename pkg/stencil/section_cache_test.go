@@ -0,0 +1,110 @@
+package stencil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheBlock(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	calls := 0
+	engine.RegisterFunction("terms", NewSimpleFunction("terms", 1, 1, func(args ...interface{}) (interface{}, error) {
+		calls++
+		return "Terms in " + FormatValue(args[0]), nil
+	}))
+
+	docx := createDOCXWithParagraphs(t, []string{
+		`Dear {{name}}`,
+		`{{cache "terms" keys=lang}}`,
+		`{{terms(lang)}}`,
+		`{{end}}`,
+	})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	render := func(data TemplateData) string {
+		t.Helper()
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		content, _ := io.ReadAll(output)
+		return extractTextFromDOCX(t, content)
+	}
+
+	for _, tc := range []struct {
+		data      TemplateData
+		want      []string
+		wantCalls int
+	}{
+		{TemplateData{"name": "Ada", "lang": "en"}, []string{"Dear Ada", "Terms in en"}, 1},
+		{TemplateData{"name": "Bob", "lang": "en"}, []string{"Dear Bob", "Terms in en"}, 1},
+		{TemplateData{"name": "Bob", "lang": "de"}, []string{"Dear Bob", "Terms in de"}, 2},
+		{TemplateData{"name": "Cy", "lang": "en"}, []string{"Dear Cy", "Terms in en"}, 2},
+	} {
+		text := render(tc.data)
+		for _, want := range tc.want {
+			if !strings.Contains(text, want) {
+				t.Errorf("render %v: missing %q in %q", tc.data, want, text)
+			}
+		}
+		if strings.Contains(text, "{{") {
+			t.Errorf("render %v: leftover tag in %q", tc.data, text)
+		}
+		if calls != tc.wantCalls {
+			t.Errorf("render %v: terms called %d times, want %d", tc.data, calls, tc.wantCalls)
+		}
+	}
+}
+
+func TestCacheBlockTTL(t *testing.T) {
+	engine := NewWithConfig(DefaultConfig())
+	calls := 0
+	engine.RegisterFunction("stamp", NewSimpleFunction("stamp", 0, 0, func(args ...interface{}) (interface{}, error) {
+		calls++
+		return "stamp", nil
+	}))
+
+	docx := createDOCXWithParagraphs(t, []string{`{{cache "s" ttl=1ns}}`, `{{stamp()}}`, `{{end}}`})
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := tmpl.Render(TemplateData{}); err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls != 2 {
+		t.Errorf("stamp called %d times, want 2 after the entry expired", calls)
+	}
+}
+
+func TestCacheBlockArgs(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{`Value: {{cache}}`})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"cache": "plain"}, RenderOptions{})
+	if text := extractTextFromDOCX(t, output); !strings.Contains(text, "Value: plain") {
+		t.Errorf("cache without a name should stay a variable, got %q", text)
+	}
+
+	for _, args := range []string{`"x" ttl=soon`, `"x" ttl=-1s`, `"x" keys=a,,b`, `"" keys=a`} {
+		docx := createDOCXWithParagraphs(t, []string{`{{cache ` + args + `}}`, `text`, `{{end}}`})
+		tmpl, err := Prepare(bytes.NewReader(docx))
+		if err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		if _, err := tmpl.Render(TemplateData{"a": 1, "b": 2}); err == nil {
+			t.Errorf("{{cache %s}}: expected an error", args)
+		}
+		tmpl.Close()
+	}
+}
@@ -0,0 +1,431 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// SectionParts describes the header and footer parts the pages of a section
+// of a document show.
+type SectionParts struct {
+	// Index is the position of the section in the document, from 0.
+	Index int
+	// Headers maps the reference types "default", "first" and "even" to the
+	// header part of the section, such as "word/header2.xml". A section
+	// without its own reference of a type uses that of the section before
+	// it, as in Word.
+	Headers map[string]string
+	// Footers maps the reference types to the footer part of the section,
+	// like Headers.
+	Footers map[string]string
+}
+
+// sectionReference is a header or footer reference of the section
+// properties of a section.
+type sectionReference struct {
+	kind    headerFooterKind
+	refType string
+	relID   string
+}
+
+// SectionParts returns the header and footer parts of each section of the
+// main document of the template in order. A document without section
+// properties has a single section.
+//
+// Example:
+//
+//	sections, err := tmpl.SectionParts()
+//	for _, section := range sections {
+//	    fmt.Println(section.Index, section.Headers["default"])
+//	}
+func (pt *PreparedTemplate) SectionParts() ([]SectionParts, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	if pt.closed || pt.template == nil {
+		return nil, errTemplateClosed
+	}
+	tmpl := pt.template
+	tmpl.mu.RLock()
+	defer tmpl.mu.RUnlock()
+
+	pkg, err := readDocxPackage(tmpl.source)
+	if err != nil {
+		return nil, err
+	}
+	documentXML, ok := pkg.get("word/document.xml")
+	if !ok {
+		return nil, fmt.Errorf("word/document.xml not found")
+	}
+	relsXML, _ := pkg.get(documentRelationshipsPart)
+	return documentSections(documentXML, parseRelationships(relsXML))
+}
+
+// documentSections returns the sections of documentXML with the parts their
+// references resolve to through rels, the relationships of the document.
+func documentSections(documentXML []byte, rels []Relationship) ([]SectionParts, error) {
+	references, err := documentSectionReferences(documentXML)
+	if err != nil {
+		return nil, err
+	}
+	if len(references) == 0 {
+		references = [][]sectionReference{nil}
+	}
+
+	targets := make(map[string]Relationship, len(rels))
+	for _, rel := range rels {
+		targets[rel.ID] = rel
+	}
+	sections := make([]SectionParts, len(references))
+	for i, refs := range references {
+		section := SectionParts{Index: i, Headers: make(map[string]string), Footers: make(map[string]string)}
+		if i > 0 {
+			for refType, part := range sections[i-1].Headers {
+				section.Headers[refType] = part
+			}
+			for refType, part := range sections[i-1].Footers {
+				section.Footers[refType] = part
+			}
+		}
+		for _, ref := range refs {
+			rel, ok := targets[ref.relID]
+			if !ok || rel.Type != ref.kind.relationType() || rel.TargetMode == "External" {
+				continue
+			}
+			part := resolveRelationshipTarget("word", rel.Target)
+			if ref.kind == headerFragmentKind {
+				section.Headers[ref.refType] = part
+			} else {
+				section.Footers[ref.refType] = part
+			}
+		}
+		sections[i] = section
+	}
+	return sections, nil
+}
+
+// documentSectionReferences returns the header and footer references of
+// each section properties element of the body of documentXML in order. The
+// previous section properties of a tracked change are skipped.
+func documentSectionReferences(documentXML []byte) ([][]sectionReference, error) {
+	var sections [][]sectionReference
+	depth := 0
+	err := scanSectionProperties(documentXML, func(token xml.Token, _, _ int) bool {
+		switch t := token.(type) {
+		case xml.StartElement:
+			if isWordElement(t.Name, "sectPr") {
+				depth++
+				if depth == 1 {
+					sections = append(sections, nil)
+				}
+			} else if depth == 1 {
+				if ref, ok := sectionReferenceOf(t); ok {
+					sections[len(sections)-1] = append(sections[len(sections)-1], ref)
+				}
+			}
+		case xml.EndElement:
+			if isWordElement(t.Name, "sectPr") {
+				depth--
+			}
+		}
+		return true
+	})
+	return sections, err
+}
+
+// scanSectionProperties calls fn with each token of documentXML and its byte
+// range until fn returns false.
+func scanSectionProperties(documentXML []byte, fn func(token xml.Token, start, end int) bool) error {
+	decoder := xml.NewDecoder(bytes.NewReader(documentXML))
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse document: %w", err)
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			if i := bytes.IndexByte(documentXML[start:], '<'); i >= 0 {
+				start += i
+			}
+		}
+		if !fn(token, start, int(decoder.InputOffset())) {
+			return nil
+		}
+	}
+}
+
+// sectionReferenceOf returns the reference start is, if it is a header or
+// footer reference.
+func sectionReferenceOf(start xml.StartElement) (sectionReference, bool) {
+	var ref sectionReference
+	switch {
+	case isWordElement(start.Name, "headerReference"):
+		ref.kind = headerFragmentKind
+	case isWordElement(start.Name, "footerReference"):
+		ref.kind = footerFragmentKind
+	default:
+		return sectionReference{}, false
+	}
+	ref.refType = "default"
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == wordprocessingMLNamespace && attr.Name.Local == "type":
+			ref.refType = attr.Value
+		case attr.Name.Space == officeDocumentRelationshipsNamespace && attr.Name.Local == "id":
+			ref.relID = attr.Value
+		}
+	}
+	return ref, true
+}
+
+func isWordElement(name xml.Name, local string) bool {
+	return name.Space == wordprocessingMLNamespace && name.Local == local
+}
+
+// setSectionReference makes the section at index reference the header or
+// footer part with the relationship relID for refType, replacing the
+// reference it has or adding one.
+func setSectionReference(documentXML []byte, index int, kind headerFooterKind, refType, relID string) ([]byte, error) {
+	reference := `<` + kind.referenceElement() + ` w:type="` + refType + `" r:id="` + relID + `"/>`
+
+	sectionStart, sectionEnd := -1, -1
+	selfClosing := false
+	refStart, refEnd := -1, -1
+	section, depth, refDepth := -1, 0, 0
+	err := scanSectionProperties(documentXML, func(token xml.Token, start, end int) bool {
+		switch t := token.(type) {
+		case xml.StartElement:
+			if isWordElement(t.Name, "sectPr") {
+				depth++
+				if depth == 1 {
+					section++
+					if section == index {
+						sectionStart, sectionEnd = start, end
+						selfClosing = bytes.HasSuffix(documentXML[start:end], []byte("/>"))
+					}
+				}
+				return true
+			}
+			if depth == 1 && section == index {
+				if ref, ok := sectionReferenceOf(t); ok && ref.kind == kind && ref.refType == refType {
+					refStart, refDepth = start, 1
+					return true
+				}
+			}
+			if refDepth > 0 {
+				refDepth++
+			}
+		case xml.EndElement:
+			if refDepth > 0 {
+				refDepth--
+				if refDepth == 0 {
+					refEnd = end
+					return false
+				}
+				return true
+			}
+			if isWordElement(t.Name, "sectPr") {
+				depth--
+				if depth == 0 && section == index {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sectionStart < 0 {
+		return nil, fmt.Errorf("section %d not found", index)
+	}
+
+	var out bytes.Buffer
+	switch {
+	case refStart >= 0 && refEnd >= 0:
+		out.Write(documentXML[:refStart])
+		out.WriteString(reference)
+		out.Write(documentXML[refEnd:])
+	case selfClosing:
+		tag := bytes.TrimSuffix(bytes.TrimRight(bytes.TrimSuffix(documentXML[sectionStart:sectionEnd], []byte(">")), " "), []byte("/"))
+		out.Write(documentXML[:sectionStart])
+		out.Write(tag)
+		out.WriteString(">" + reference + "</w:sectPr>")
+		out.Write(documentXML[sectionEnd:])
+	default:
+		out.Write(documentXML[:sectionEnd])
+		out.WriteString(reference)
+		out.Write(documentXML[sectionEnd:])
+	}
+	return declareDocumentRelationshipsNamespace(out.Bytes()), nil
+}
+
+// sectionPartPlan assigns the header and footer parts of a rendered document
+// to its sections for RenderOptions.SectionData. The first section that
+// shows a part keeps it; each further section that shows it gets a copy
+// rendered with its own data, unless neither of the two sections has data
+// of its own.
+type sectionPartPlan struct {
+	// data holds the data to render the parts kept by a section with
+	data map[string]TemplateData
+	// owners reports whether the section that keeps a part has data of its
+	// own
+	owners map[string]bool
+	// copies holds the copies of each part
+	copies map[string][]*sectionPartCopy
+}
+
+// sectionPartCopy is a header or footer part rendered again for a section.
+type sectionPartCopy struct {
+	section  int
+	kind     headerFooterKind
+	refTypes []string
+	data     TemplateData
+	content  []byte
+}
+
+// planSectionParts calls sectionData for each section of the rendered
+// document and assigns the header and footer parts with template markers to
+// the sections. Parts in static are rendered the same for every section.
+func planSectionParts(documentXML []byte, rels []Relationship, data TemplateData, sectionData func(SectionParts) TemplateData, static map[string][]byte) (*sectionPartPlan, error) {
+	sections, err := documentSections(documentXML, rels)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &sectionPartPlan{
+		data:   make(map[string]TemplateData),
+		owners: make(map[string]bool),
+		copies: make(map[string][]*sectionPartCopy),
+	}
+	for _, section := range sections {
+		scoped := data
+		own := sectionData(section)
+		if len(own) > 0 {
+			scoped = newChildTemplateData(data, len(own))
+			for key, value := range own {
+				scoped[key] = value
+			}
+		}
+
+		copies := make(map[string]*sectionPartCopy)
+		for _, group := range []struct {
+			kind  headerFooterKind
+			parts map[string]string
+		}{{headerFragmentKind, section.Headers}, {footerFragmentKind, section.Footers}} {
+			refTypes := make([]string, 0, len(group.parts))
+			for refType := range group.parts {
+				refTypes = append(refTypes, refType)
+			}
+			sort.Strings(refTypes)
+			for _, refType := range refTypes {
+				part := group.parts[refType]
+				if _, ok := static[part]; ok {
+					continue
+				}
+				if _, kept := plan.data[part]; !kept {
+					plan.data[part] = scoped
+					plan.owners[part] = len(own) > 0
+					continue
+				}
+				if partCopy, ok := copies[part]; ok {
+					partCopy.refTypes = append(partCopy.refTypes, refType)
+					continue
+				}
+				if len(own) == 0 && !plan.owners[part] {
+					continue
+				}
+				partCopy := &sectionPartCopy{section: section.Index, kind: group.kind, refTypes: []string{refType}, data: scoped}
+				copies[part] = partCopy
+				plan.copies[part] = append(plan.copies[part], partCopy)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// dataFor returns the data to render the part name with.
+func (p *sectionPartPlan) dataFor(name string, data TemplateData) TemplateData {
+	if p == nil {
+		return data
+	}
+	if scoped, ok := p.data[name]; ok {
+		return scoped
+	}
+	return data
+}
+
+// renderCopies renders the copies of the part file for further sections.
+func (p *sectionPartPlan) renderCopies(file *zip.File, ctx *renderContext) error {
+	if p == nil {
+		return nil
+	}
+	for _, partCopy := range p.copies[file.Name] {
+		content, err := renderStoryPart(file, partCopy.data, ctx)
+		if err != nil {
+			return fmt.Errorf("section %d: %w", partCopy.section, err)
+		}
+		if err := ctx.memory.charge(len(content), "rendering "+file.Name); err != nil {
+			return err
+		}
+		partCopy.content = content
+	}
+	return nil
+}
+
+func (p *sectionPartPlan) hasCopies() bool {
+	return p != nil && len(p.copies) > 0
+}
+
+// applySectionPartCopies writes the copies of header and footer parts into a
+// rendered DOCX package and makes their sections reference them.
+func applySectionPartCopies(output []byte, plan *sectionPartPlan) ([]byte, error) {
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+	documentXML, ok := pkg.get("word/document.xml")
+	if !ok {
+		return nil, NewDocumentError("read", "rendered document", fmt.Errorf("word/document.xml not found"))
+	}
+
+	sources := make([]string, 0, len(plan.copies))
+	for source := range plan.copies {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		for _, partCopy := range plan.copies[source] {
+			partName := nextHeaderFooterPartName(pkg, partCopy.kind)
+			pkg.set(partName, partCopy.content)
+			if rels, ok := pkg.get(relationshipsPartFor(source)); ok {
+				pkg.set(relationshipsPartFor(partName), rels)
+			}
+			if err := pkg.ensureContentTypeOverride(partName, partCopy.kind.contentType()); err != nil {
+				return nil, NewDocumentError("write", partName, err)
+			}
+			relID, err := pkg.ensureRelationship(documentRelationshipsPart, partCopy.kind.relationType(), path.Base(partName))
+			if err != nil {
+				return nil, NewDocumentError("write", partName, err)
+			}
+			for _, refType := range partCopy.refTypes {
+				documentXML, err = setSectionReference(documentXML, partCopy.section, partCopy.kind, refType, relID)
+				if err != nil {
+					return nil, NewDocumentError("write", "section references", err)
+				}
+			}
+		}
+	}
+	pkg.set("word/document.xml", documentXML)
+	return pkg.bytes()
+}
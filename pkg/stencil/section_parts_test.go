@@ -0,0 +1,130 @@
+package stencil
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSectionDataRendersHeadersPerSection(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+<w:p><w:pPr><w:sectPr><w:headerReference w:type="default" r:id="rId1"/><w:pgSz w:w="11906" w:h="16838"/></w:sectPr></w:pPr><w:r><w:t>Intro</w:t></w:r></w:p>
+<w:p><w:pPr><w:sectPr><w:pgSz w:w="11906" w:h="16838"/></w:sectPr></w:pPr><w:r><w:t>Terms</w:t></w:r></w:p>
+<w:p><w:r><w:t>Appendix</w:t></w:r></w:p>
+<w:sectPr/>`),
+		"word/header1.xml": validationHeaderXML(`<w:p><w:r><w:t>{{chapter}} - {{company}}</w:t></w:r></w:p>`),
+	})
+
+	tmpl, err := prepare(strings.NewReader(string(docx)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	sections, err := tmpl.SectionParts()
+	if err != nil {
+		t.Fatalf("SectionParts failed: %v", err)
+	}
+	if len(sections) != 3 {
+		t.Fatalf("expected 3 sections, got %+v", sections)
+	}
+	for _, section := range sections {
+		if section.Headers["default"] != "word/header1.xml" {
+			t.Errorf("section %d shows header %q", section.Index, section.Headers["default"])
+		}
+	}
+
+	chapters := []string{"Intro", "Terms"}
+	var seen []int
+	output := renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{"chapter": "Default", "company": "Acme"}, RenderOptions{
+		SectionData: func(section SectionParts) TemplateData {
+			seen = append(seen, section.Index)
+			if section.Index < len(chapters) {
+				return TemplateData{"chapter": chapters[section.Index]}
+			}
+			return nil
+		},
+	})
+	if len(seen) != 3 {
+		t.Errorf("SectionData called for sections %v", seen)
+	}
+
+	for part, want := range map[string]string{
+		"word/header1.xml": "Intro - Acme",
+		"word/header2.xml": "Terms - Acme",
+		"word/header3.xml": "Default - Acme",
+	} {
+		if header := extractPartFromDOCX(t, output, part); !strings.Contains(header, want) {
+			t.Errorf("expected %s in %s, got %s", want, part, header)
+		}
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	references := regexp.MustCompile(`<w:headerReference w:type="default" r:id="(\w+)"/>`).FindAllStringSubmatch(documentXML, -1)
+	if len(references) != 3 {
+		t.Fatalf("expected a header reference in each section, got %s", documentXML)
+	}
+	rels := parseRelationships([]byte(extractPartFromDOCX(t, output, "word/_rels/document.xml.rels")))
+	targets := make(map[string]string)
+	for _, rel := range rels {
+		targets[rel.ID] = rel.Target
+	}
+	for i, want := range []string{"header1.xml", "header2.xml", "header3.xml"} {
+		if got := targets[references[i][1]]; got != want {
+			t.Errorf("section %d references %q, want %q", i, got, want)
+		}
+	}
+	if contentTypes := extractPartFromDOCX(t, output, "[Content_Types].xml"); !strings.Contains(contentTypes, `/word/header3.xml`) {
+		t.Errorf("expected a content type for the copied header: %s", contentTypes)
+	}
+
+	// Without data of their own, sections share the header part
+	output = renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{"chapter": "Default", "company": "Acme"}, RenderOptions{
+		SectionData: func(SectionParts) TemplateData { return nil },
+	})
+	for _, name := range docxPartNames(t, output) {
+		if name == "word/header2.xml" {
+			t.Errorf("expected no header copies")
+		}
+	}
+}
+
+func TestSetSectionReference(t *testing.T) {
+	documentXML := []byte(`<w:document xmlns:w="` + wordprocessingMLNamespace + `" xmlns:r="` + officeDocumentRelationshipsNamespace + `"><w:body>` +
+		`<w:p><w:pPr><w:sectPr><w:headerReference w:type="default" r:id="rId1"></w:headerReference>` +
+		`<w:sectPrChange><w:sectPr/></w:sectPrChange></w:sectPr></w:pPr></w:p>` +
+		`<w:sectPr/></w:body></w:document>`)
+
+	updated, err := setSectionReference(documentXML, 0, headerFragmentKind, "default", "rId7")
+	if err != nil {
+		t.Fatalf("setSectionReference failed: %v", err)
+	}
+	updated, err = setSectionReference(updated, 1, footerFragmentKind, "first", "rId8")
+	if err != nil {
+		t.Fatalf("setSectionReference failed: %v", err)
+	}
+	want := `<w:p><w:pPr><w:sectPr><w:headerReference w:type="default" r:id="rId7"/>` +
+		`<w:sectPrChange><w:sectPr/></w:sectPrChange></w:sectPr></w:pPr></w:p>` +
+		`<w:sectPr><w:footerReference w:type="first" r:id="rId8"/></w:sectPr></w:body>`
+	if !strings.Contains(string(updated), want) {
+		t.Fatalf("unexpected document: %s", updated)
+	}
+	if _, err := setSectionReference(updated, 2, headerFragmentKind, "default", "rId9"); err == nil {
+		t.Errorf("expected an error for a missing section")
+	}
+}
+
+func renderPreparedWithOptionsToBytes(t *testing.T, tmpl *PreparedTemplate, data TemplateData, opts RenderOptions) []byte {
+	t.Helper()
+
+	reader, err := tmpl.RenderWithOptions(data, opts)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return output
+}
@@ -0,0 +1,106 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const (
+	// sectionPreviewFill is the background of the box of a missing section.
+	sectionPreviewFill = "D9D9D9"
+	// sectionPreviewColor is the color of the border and the label.
+	sectionPreviewColor = "7F7F7F"
+)
+
+// previewMissingSections reports whether the render previews the sections
+// whose data is missing (RenderOptions.PreviewMissingSections).
+func (ctx *renderContext) previewMissingSections() bool {
+	return ctx != nil && ctx.options != nil && ctx.options.PreviewMissingSections
+}
+
+// appendMissingSectionPreview appends the box of a section to elements when
+// the render previews missing sections and the fields node reads are
+// missing from data.
+func appendMissingSectionPreview(elements []BodyElement, node ExpressionNode, data TemplateData, ctx *renderContext) []BodyElement {
+	if !ctx.previewMissingSections() {
+		return elements
+	}
+	missing := missingDataFields(node, data)
+	if len(missing) == 0 {
+		return elements
+	}
+	return append(elements, missingSectionParagraph(missing))
+}
+
+// missingDataFields returns the paths of the fields node reads that are
+// absent or null in data, in the order they appear. Lambda parameters are
+// not fields.
+func missingDataFields(node ExpressionNode, data TemplateData) []string {
+	var missing []string
+	seen := make(map[string]bool)
+	var walk func(node ExpressionNode, params map[string]bool)
+	walk = func(node ExpressionNode, params map[string]bool) {
+		if node == nil {
+			return
+		}
+		if path, ok := referencePathFromNode(node); ok {
+			root := path
+			if i := strings.IndexAny(root, ".["); i >= 0 {
+				root = root[:i]
+			}
+			if params[root] || seen[path] {
+				return
+			}
+			if value, err := node.Evaluate(data); err != nil || value == nil {
+				seen[path] = true
+				missing = append(missing, path)
+			}
+			return
+		}
+
+		switch n := node.(type) {
+		case *FunctionCallNode:
+			for _, arg := range n.Args {
+				walk(arg, params)
+			}
+		case *BinaryOpNode:
+			walk(n.Left, params)
+			walk(n.Right, params)
+		case *UnaryOpNode:
+			walk(n.Operand, params)
+		case *LambdaNode:
+			inner := map[string]bool{n.Param: true}
+			for param := range params {
+				inner[param] = true
+			}
+			walk(n.Body, inner)
+		case *FieldAccessNode:
+			walk(n.Object, params)
+		case *IndexAccessNode:
+			walk(n.Object, params)
+			walk(n.Index, params)
+		}
+	}
+	walk(node, nil)
+	return missing
+}
+
+// missingSectionParagraph returns a gray box labeled with the missing
+// fields of a section.
+func missingSectionParagraph(missing []string) *Paragraph {
+	para := NewParagraph("Missing data: "+strings.Join(missing, ", "), ParagraphOptions{
+		Run: RunProps{Italic: true, Color: sectionPreviewColor},
+	})
+	border := `w:val="single" w:sz="4" w:space="4" w:color="` + sectionPreviewColor + `"`
+	para.Properties = &ParagraphProperties{RawXML: []RawXMLElement{
+		{
+			XMLName: xml.Name{Local: "pBdr"},
+			Content: []byte(`<w:pBdr><w:top ` + border + `/><w:left ` + border + `/><w:bottom ` + border + `/><w:right ` + border + `/></w:pBdr>`),
+		},
+		{
+			XMLName: xml.Name{Local: "shd"},
+			Content: []byte(`<w:shd w:val="clear" w:color="auto" w:fill="` + sectionPreviewFill + `"/>`),
+		},
+	}}
+	return para
+}
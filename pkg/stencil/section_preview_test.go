@@ -0,0 +1,69 @@
+package stencil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreviewMissingSections(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{if customer.vatId}}`,
+		`VAT ID: {{customer.vatId}}`,
+		`{{end}}`,
+		`{{for item in orders}}`,
+		`Order {{item.id}}`,
+		`{{end}}`,
+		`{{if showTerms}}`,
+		`Terms apply.`,
+		`{{end}}`,
+		`{{for note in notes}}`,
+		`{{note}}`,
+		`{{end}}`,
+	})
+	data := TemplateData{"customer": map[string]interface{}{"name": "Ada"}, "showTerms": false, "notes": []interface{}{}}
+
+	output := renderWithOptionsToBytes(t, docx, data, RenderOptions{PreviewMissingSections: true})
+	text := extractTextFromDOCX(t, output)
+	for _, want := range []string{"Missing data: customer.vatId", "Missing data: orders"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in %q", want, text)
+		}
+	}
+	// Sections whose data is present but false or empty are dropped
+	if strings.Contains(text, "showTerms") || strings.Contains(text, "notes") || strings.Count(text, "Missing data") != 2 {
+		t.Errorf("unexpected placeholders in %q", text)
+	}
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	if strings.Count(documentXML, `w:fill="D9D9D9"`) != 2 || !strings.Contains(documentXML, "<w:pBdr>") {
+		t.Errorf("expected two gray boxes: %s", documentXML)
+	}
+
+	output = renderWithOptionsToBytes(t, docx, data, RenderOptions{})
+	if text := extractTextFromDOCX(t, output); strings.Contains(text, "Missing data") {
+		t.Errorf("placeholders rendered without preview: %q", text)
+	}
+}
+
+func TestMissingDataFields(t *testing.T) {
+	data := TemplateData{
+		"customer": map[string]interface{}{"name": "Ada", "email": nil},
+		"items":    []interface{}{},
+	}
+	tests := map[string][]string{
+		`customer.vatId`:                         {"customer.vatId"},
+		`customer.name & customer.email`:         {"customer.email"},
+		`length(filter(items, x -> x.done)) > 0`: nil,
+		`discount | coupon.code | discount > 0`:  {"discount", "coupon.code"},
+		`items`:                                  nil,
+	}
+	for expression, want := range tests {
+		node, err := ParseExpression(expression)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q) failed: %v", expression, err)
+		}
+		if got := missingDataFields(node, data); !reflect.DeepEqual(got, want) {
+			t.Errorf("missingDataFields(%q) = %q, want %q", expression, got, want)
+		}
+	}
+}
@@ -10,6 +10,11 @@ import (
 type seqFieldRenumberState struct {
 	counters map[string]int
 	stack    []*seqFieldFrame
+
+	// captions, when set, collects the label and number of the caption()
+	// captions by id; duplicateCaption is the first id seen twice.
+	captions         map[string]string
+	duplicateCaption string
 }
 
 type seqFieldFrame struct {
@@ -154,7 +159,11 @@ func (state *seqFieldRenumberState) finishCurrentSEQField() {
 	}
 
 	state.counters[spec.identifier]++
-	setSEQFieldResult(frame.resultRuns, formatSEQFieldValue(state.counters[spec.identifier], spec.format))
+	value := formatSEQFieldValue(state.counters[spec.identifier], spec.format)
+	if state.captions != nil {
+		state.recordCaption(frame.resultRuns, spec.identifier+" "+value)
+	}
+	setSEQFieldResult(frame.resultRuns, value)
 }
 
 func parseFieldInstructionRawXML(raw []byte) (kind, value string, ok bool) {
@@ -0,0 +1,121 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// settingsElementsBeforeProtection lists the w:settings children that
+	// precede w:documentProtection according to the CT_Settings sequence.
+	settingsElementsBeforeProtection = map[string]bool{
+		"writeProtection": true, "view": true, "zoom": true, "removePersonalInformation": true,
+		"removeDateAndTime": true, "doNotDisplayPageBoundaries": true, "displayBackgroundShape": true,
+		"printPostScriptOverText": true, "printFractionalCharacterWidth": true, "printFormsData": true,
+		"embedTrueTypeFonts": true, "embedSystemFonts": true, "saveSubsetFonts": true,
+		"saveFormsData": true, "mirrorMargins": true, "alignBordersAndEdges": true,
+		"bordersDoNotSurroundHeader": true, "bordersDoNotSurroundFooter": true, "gutterAtTop": true,
+		"hideSpellingErrors": true, "hideGrammaticalErrors": true, "activeWritingStyle": true,
+		"proofState": true, "formsDesign": true, "attachedTemplate": true, "linkStyles": true,
+		"stylePaneFormatFilter": true, "stylePaneSortMethod": true, "documentType": true,
+		"mailMerge": true, "revisionView": true, "trackRevisions": true, "doNotTrackMoves": true,
+		"doNotTrackFormatting": true,
+	}
+
+	// settingsElementsAfterUpdateFields lists the w:settings children that
+	// follow w:updateFields according to the CT_Settings sequence.
+	settingsElementsAfterUpdateFields = map[string]bool{
+		"hdrShapeDefaults": true, "footnotePr": true, "endnotePr": true, "compat": true,
+		"docVars": true, "rsids": true, "mathPr": true, "attachedSchema": true,
+		"themeFontLang": true, "clrSchemeMapping": true, "doNotIncludeSubdocsInStats": true,
+		"doNotAutoCompressPictures": true, "forceUpgrade": true, "captions": true,
+		"readModeInkLockDown": true, "smartTagType": true, "schemaLibrary": true,
+		"shapeDefaults": true, "doNotEmbedSmartTags": true, "decimalSymbol": true,
+		"listSeparator": true,
+	}
+)
+
+// applyRenderSettings adds the document settings that content rendered
+// with ctx relies on: read-only editing restrictions for editable regions
+// and updating fields on open for the index.
+func applyRenderSettings(output []byte, ctx *renderContext) ([]byte, error) {
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+
+	if ctx.editable != nil && ctx.editable.count > 0 {
+		err := ensureSettingsElement(pkg, "documentProtection", `<w:documentProtection w:edit="readOnly" w:enforcement="1"/>`,
+			func(name string) bool { return settingsElementsBeforeProtection[name] })
+		if err != nil {
+			return nil, NewDocumentError("write", "editing restrictions", err)
+		}
+	}
+	if ctx.updateFieldsOnOpen {
+		err := ensureSettingsElement(pkg, "updateFields", `<w:updateFields w:val="true"/>`,
+			func(name string) bool { return !settingsElementsAfterUpdateFields[name] })
+		if err != nil {
+			return nil, NewDocumentError("write", "update fields setting", err)
+		}
+	}
+	return pkg.bytes()
+}
+
+// ensureSettingsElement adds element to the settings part of pkg, creating
+// the part when the package has none, unless the settings already have an
+// element named name; the template's own setting is kept. precedes reports
+// whether a w:settings child of the given name comes before the element in
+// the CT_Settings sequence.
+func ensureSettingsElement(pkg *docxPackage, name, element string, precedes func(string) bool) error {
+	settings, ok := pkg.get(settingsPartName)
+	if !ok {
+		settings = []byte(minimalSettingsXMLTemplate)
+		if _, err := pkg.ensureRelationship(documentRelationshipsPart, settingsRelationType, "settings.xml"); err != nil {
+			return err
+		}
+		if err := pkg.ensureContentTypeOverride(settingsPartName, settingsContentType); err != nil {
+			return err
+		}
+	}
+	if strings.Contains(string(settings), "<w:"+name) {
+		return nil
+	}
+	offset, err := settingsElementOffset(settings, precedes)
+	if err != nil {
+		return err
+	}
+	pkg.set(settingsPartName, []byte(string(settings[:offset])+element+string(settings[offset:])))
+	return nil
+}
+
+// settingsElementOffset returns the offset in a settings part at which a
+// new child of w:settings is inserted: before the first child for which
+// precedes is false, or at the end.
+func settingsElementOffset(settings []byte, precedes func(string) bool) (int, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(settings)))
+	depth := 0
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return 0, fmt.Errorf("settings part has no w:settings root")
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse settings: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && !precedes(t.Name.Local) {
+				return offset, nil
+			}
+		case xml.EndElement:
+			if depth == 1 {
+				return offset, nil
+			}
+			depth--
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderedTextPreservesSpaces(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+<w:p><w:r><w:t>{{code}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>Amount:{{amount}}</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>{{for line in lines}}</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>{{line}}</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+<w:p><w:r><w:t>Plain {{name}}</w:t></w:r></w:p>`),
+		"word/header1.xml": validationHeaderXML(`<w:p><w:r><w:t>{{code}}</w:t></w:r></w:p>`),
+	})
+
+	output := renderWithOptionsToBytes(t, docx, TemplateData{
+		"code":   "  AB  12",
+		"amount": "    9.50",
+		"lines":  []string{"Item      1.00", " Tax"},
+		"name":   "Ada",
+	}, RenderOptions{})
+
+	documentXML := extractDocumentXMLFromDOCX(t, output)
+	for _, want := range []string{
+		`<w:t xml:space="preserve">  AB  12</w:t>`,
+		`<w:t xml:space="preserve">Amount:    9.50</w:t>`,
+		`<w:t xml:space="preserve">Item      1.00</w:t>`,
+		`<w:t xml:space="preserve"> Tax</w:t>`,
+		`<w:t>Plain Ada</w:t>`,
+	} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %s in document: %s", want, documentXML)
+		}
+	}
+
+	if header := extractPartFromDOCX(t, output, "word/header1.xml"); !strings.Contains(header, `xml:space="preserve">  AB  12</w:t>`) {
+		t.Errorf("expected preserved spaces in header: %s", header)
+	}
+}
@@ -0,0 +1,190 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+)
+
+// splitDocumentMarkerPlaceholder is the rendered form of splitDocument()
+const splitDocumentMarkerPlaceholder = "{{SPLIT_DOCUMENT_MARKER}}"
+
+// registerSplitDocumentFunction registers splitDocument(), which marks
+// where RenderMulti cuts the rendered document into separate documents.
+func registerSplitDocumentFunction(registry *DefaultFunctionRegistry) {
+	splitDocumentFn := NewSimpleFunction("splitDocument", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return splitDocumentMarkerPlaceholder, nil
+	})
+	registry.RegisterFunction(splitDocumentFn)
+}
+
+// RenderMulti renders the template like Render and cuts the output into
+// separate documents after each paragraph that calls splitDocument(), such
+// as one letter per recipient from a single {{for}} loop. Every document
+// keeps the styles, numbering, headers, footers and final section
+// properties of the template. A paragraph left empty by the marker is
+// dropped, and parts without content, such as the one after a marker at
+// the end, are omitted.
+//
+// Example:
+//
+//	// Template: {{for c in customers}}Dear {{c.name}}, ...{{splitDocument()}}{{end}}
+//	letters, err := template.RenderMulti(data)
+//	for i, letter := range letters {
+//	    os.WriteFile(fmt.Sprintf("letter-%d.docx", i+1), letter, 0o644)
+//	}
+func (pt *PreparedTemplate) RenderMulti(data TemplateData) ([][]byte, error) {
+	if pt == nil {
+		return nil, NewTemplateError("invalid or nil template", 0, 0)
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return nil, withErrorCode(ErrorCodeTemplateClosed, NewTemplateError("template is closed", 0, 0))
+	}
+
+	output, err := renderTemplatePackage(pt.template, pt.registry, pt.data, data, &RenderOptions{splitDocuments: true})
+	if err != nil {
+		return nil, err
+	}
+	return splitDocumentPackage(output)
+}
+
+// splitDocumentPackage cuts a rendered package into one package per part
+// of its main document body between splitDocument() markers.
+func splitDocumentPackage(output []byte) ([][]byte, error) {
+	pkg, err := readDocxPackage(output)
+	if err != nil {
+		return nil, NewDocumentError("read", "rendered document", err)
+	}
+	documentXML, ok := pkg.get("word/document.xml")
+	if !ok || !bytes.Contains(documentXML, []byte(splitDocumentMarkerPlaceholder)) {
+		return [][]byte{output}, nil
+	}
+	doc, err := ParseDocument(bytes.NewReader(documentXML))
+	if err != nil {
+		return nil, NewDocumentError("parse", "rendered document", err)
+	}
+	if doc.Body == nil {
+		return [][]byte{output}, nil
+	}
+
+	var bodies [][]BodyElement
+	var current []BodyElement
+	for _, elem := range doc.Body.Elements {
+		para, ok := elem.(*Paragraph)
+		if !ok || !strings.Contains(para.GetText(), splitDocumentMarkerPlaceholder) {
+			current = append(current, elem)
+			continue
+		}
+		if removeSplitDocumentMarker(para) {
+			current = append(current, para)
+		}
+		if bodyElementsHaveContent(current) {
+			bodies = append(bodies, current)
+		}
+		current = nil
+	}
+	if bodyElementsHaveContent(current) || len(bodies) == 0 {
+		bodies = append(bodies, current)
+	}
+
+	documents := make([][]byte, 0, len(bodies))
+	for _, elements := range bodies {
+		doc.Body.Elements = elements
+		renderedXML, err := marshalDocumentWithNamespaces(doc)
+		if err != nil {
+			return nil, NewDocumentError("marshal", "split document", err)
+		}
+		pkg.set("word/document.xml", renderedXML)
+		document, err := pkg.bytes()
+		if err != nil {
+			return nil, NewDocumentError("write", "split document", err)
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// ProcessSplitDocumentMarkers replaces splitDocument() markers in a document
+// rendered as a single file with page breaks, so each part starts on a new
+// page. A marker at the end of the document is removed without a break.
+func ProcessSplitDocumentMarkers(doc *Document) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	var elements []BodyElement
+	for i, elem := range doc.Body.Elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			if !strings.Contains(e.GetText(), splitDocumentMarkerPlaceholder) {
+				break
+			}
+			keep := removeSplitDocumentMarker(e)
+			if bodyElementsHaveContent(doc.Body.Elements[i+1:]) {
+				appendParagraphRun(e, Run{Break: &Break{Type: "page"}})
+				keep = true
+			}
+			if !keep {
+				continue
+			}
+		case *Table:
+			// Markers are only honored in body paragraphs
+			for j := range e.Rows {
+				replaceTableRowPlaceholder(&e.Rows[j], splitDocumentMarkerPlaceholder, "")
+			}
+		}
+		elements = append(elements, elem)
+	}
+	doc.Body.Elements = elements
+	return nil
+}
+
+// removeSplitDocumentMarker removes the splitDocument() markers from the
+// text of para and reports whether the paragraph has content left.
+func removeSplitDocumentMarker(para *Paragraph) bool {
+	for _, run := range paragraphRunsInOrder(para) {
+		replaceRunPlaceholder(run, splitDocumentMarkerPlaceholder, "")
+	}
+	return paragraphHasContent(para)
+}
+
+// appendParagraphRun adds run at the end of para.
+func appendParagraphRun(para *Paragraph, run Run) {
+	if para.Content != nil {
+		para.Content = append(para.Content, &run)
+		return
+	}
+	para.Runs = append(para.Runs, run)
+}
+
+// bodyElementsHaveContent reports whether any of elements is a table or a
+// paragraph with content.
+func bodyElementsHaveContent(elements []BodyElement) bool {
+	for _, elem := range elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			if paragraphHasContent(e) {
+				return true
+			}
+		case *Table:
+			return true
+		}
+	}
+	return false
+}
+
+// paragraphHasContent reports whether para has text other than whitespace,
+// a break, or raw content such as a drawing.
+func paragraphHasContent(para *Paragraph) bool {
+	if strings.TrimSpace(para.GetText()) != "" {
+		return true
+	}
+	for _, run := range paragraphRunsInOrder(para) {
+		if run.Break != nil || len(run.RawXML) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderMulti(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for c in customers}}`,
+		`Dear {{c.name}},`,
+		`Your balance is {{c.balance}}.{{splitDocument()}}`,
+		`{{splitDocument()}}`,
+		`{{end}}`,
+	})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+
+	data := TemplateData{"customers": []interface{}{
+		map[string]interface{}{"name": "Ada", "balance": 10},
+		map[string]interface{}{"name": "Bob", "balance": 20},
+	}}
+	documents, err := tmpl.RenderMulti(data)
+	if err != nil {
+		t.Fatalf("RenderMulti() error = %v", err)
+	}
+	// The parts between consecutive markers and after the last one have no
+	// content and are omitted
+	var texts []string
+	for _, document := range documents {
+		texts = append(texts, extractTextFromDOCX(t, document))
+	}
+	if len(texts) != 2 {
+		t.Fatalf("expected 2 documents, got %d: %q", len(texts), texts)
+	}
+	for i, want := range []string{"Dear Ada,Your balance is 10.", "Dear Bob,Your balance is 20."} {
+		if got := strings.Join(strings.Fields(texts[i]), ""); got != strings.Join(strings.Fields(want), "") {
+			t.Errorf("document %d text = %q, want %q", i+1, texts[i], want)
+		}
+	}
+	for i, document := range documents {
+		if strings.Contains(extractDocumentXMLFromDOCX(t, document), "SPLIT_DOCUMENT_MARKER") {
+			t.Errorf("document %d contains the marker", i+1)
+		}
+	}
+}
+
+func TestRenderMultiWithoutMarkers(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{`Hello {{name}}`})
+	tmpl, err := prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpl.Close()
+
+	documents, err := tmpl.RenderMulti(TemplateData{"name": "World"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 || !strings.Contains(extractTextFromDOCX(t, documents[0]), "Hello World") {
+		t.Errorf("expected one document, got %d", len(documents))
+	}
+}
+
+func TestRenderSplitDocumentAsPageBreaks(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`{{for name in names}}`,
+		`Dear {{name}},{{splitDocument()}}`,
+		`{{end}}`,
+	})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"names": []interface{}{"Ada", "Bob", "Cy"}}, RenderOptions{})
+	document := extractDocumentXMLFromDOCX(t, output)
+	if strings.Contains(document, "SPLIT_DOCUMENT_MARKER") {
+		t.Errorf("expected the markers to be removed, got %s", document)
+	}
+	// No break after the last part
+	if got := strings.Count(document, `w:type="page"`); got != 2 {
+		t.Errorf("expected 2 page breaks, got %d in %s", got, document)
+	}
+}
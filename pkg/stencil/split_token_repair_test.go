@@ -0,0 +1,138 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitTokenRepair(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantText string
+		// wantInOrder lists fragments of the rendered document XML that must
+		// appear in this order.
+		wantInOrder []string
+	}{
+		{
+			name: "field characters inside token",
+			body: `<w:p>` +
+				`<w:r><w:t xml:space="preserve">A {{</w:t></w:r>` +
+				`<w:r><w:fldChar w:fldCharType="begin"/></w:r>` +
+				`<w:r><w:instrText xml:space="preserve"> PAGE </w:instrText></w:r>` +
+				`<w:r><w:fldChar w:fldCharType="end"/></w:r>` +
+				`<w:r><w:t xml:space="preserve">name}} B</w:t></w:r>` +
+				`</w:p>`,
+			wantText: "A Ada B",
+			wantInOrder: []string{
+				`A Ada`,
+				`w:fldCharType="begin"`,
+				`PAGE`,
+				`w:fldCharType="end"`,
+				` B`,
+			},
+		},
+		{
+			name: "comment reference inside token",
+			body: `<w:p>` +
+				`<w:r><w:t>{{na</w:t></w:r>` +
+				`<w:r><w:rPr><w:rStyle w:val="CommentReference"/></w:rPr><w:commentReference w:id="0"/></w:r>` +
+				`<w:r><w:t>me}}</w:t></w:r>` +
+				`</w:p>`,
+			wantText: "Ada",
+			wantInOrder: []string{
+				`Ada`,
+				`<w:commentReference w:id="0"`,
+			},
+		},
+		{
+			name: "proofing marker inside token next to hyperlink",
+			body: `<w:p>` +
+				`<w:r><w:t>{{na</w:t></w:r>` +
+				`<w:proofErr w:type="spellStart"/>` +
+				`<w:r><w:t>me}}</w:t></w:r>` +
+				`<w:proofErr w:type="spellEnd"/>` +
+				`<w:hyperlink w:anchor="top"><w:r><w:t xml:space="preserve"> link</w:t></w:r></w:hyperlink>` +
+				`</w:p>`,
+			wantText: "Ada link",
+			wantInOrder: []string{
+				`Ada`,
+				`w:type="spellStart"`,
+				`w:type="spellEnd"`,
+				` link`,
+			},
+		},
+		{
+			name: "footnote reference run inside token",
+			body: `<w:p>` +
+				`<w:r><w:t>{{na</w:t></w:r>` +
+				`<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteReference w:id="1"/></w:r>` +
+				`<w:r><w:t xml:space="preserve">me}} end</w:t></w:r>` +
+				`</w:p>`,
+			wantText: "Ada end",
+			wantInOrder: []string{
+				`Ada`,
+				`<w:footnoteReference w:id="1"`,
+				` end`,
+			},
+		},
+		{
+			name: "footnote reference in the run of the token",
+			body: `<w:p>` +
+				`<w:r><w:t>{{name}}</w:t><w:footnoteReference w:id="1"/></w:r>` +
+				`<w:r><w:t xml:space="preserve"> end</w:t></w:r>` +
+				`</w:p>`,
+			wantText: "Ada end",
+			wantInOrder: []string{
+				`<w:t>Ada</w:t></w:r>`,
+				`<w:footnoteReference w:id="1"`,
+				` end`,
+			},
+		},
+		{
+			name: "endnote reference between text of one run",
+			body: `<w:p>` +
+				`<w:r><w:t>{{na</w:t><w:endnoteReference w:id="2"/><w:t xml:space="preserve">me}} end</w:t></w:r>` +
+				`</w:p>`,
+			wantText: "Ada end",
+			wantInOrder: []string{
+				`Ada`,
+				`<w:endnoteReference w:id="2"`,
+				` end`,
+			},
+		},
+		{
+			name: "token without non-text content",
+			body: `<w:p>` +
+				`<w:r><w:t>{{na</w:t></w:r>` +
+				`<w:r><w:rPr><w:b/></w:rPr><w:t>me}}!</w:t></w:r>` +
+				`</w:p>`,
+			wantText:    "Ada!",
+			wantInOrder: []string{`Ada!`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docx := createDOCXWithBodyXML(t, tt.body)
+			output := renderWithOptionsToBytes(t, docx, TemplateData{"name": "Ada"}, RenderOptions{})
+
+			if got := strings.TrimSpace(extractTextFromDOCX(t, output)); got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+
+			documentXML := extractDocumentXMLFromDOCX(t, output)
+			if strings.Contains(documentXML, "{{") || strings.Contains(documentXML, "}}") {
+				t.Errorf("template markers left in output: %s", documentXML)
+			}
+			offset := 0
+			for _, want := range tt.wantInOrder {
+				index := strings.Index(documentXML[offset:], want)
+				if index < 0 {
+					t.Fatalf("expected %q after offset %d in output: %s", want, offset, documentXML)
+				}
+				offset += index + len(want)
+			}
+		})
+	}
+}
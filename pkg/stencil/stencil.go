@@ -3,6 +3,7 @@ package stencil
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -11,36 +12,87 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 const (
-	// ID ranges for relationship management
-	MainTemplateIDRange     = 999  // Main template uses rId1 - rId999
-	FragmentIDRangeSize     = 100  // Each fragment gets 100 IDs
-	FragmentIDRangeStart    = 1000 // Fragments start at rId1000
+	// Deprecated: relationship IDs for fragments are allocated dynamically
+	// around the IDs the template already uses; these ranges are unused.
+	MainTemplateIDRange  = 999
+	FragmentIDRangeSize  = 100
+	FragmentIDRangeStart = 1000
+
 	numberedParagraphAnchor = "\u200B"
 )
 
 // template represents a parsed template document (internal use)
 type template struct {
+	// name is the path the template was prepared from with PrepareFile;
+	// error messages name it.
+	name             string
 	docxReader       *DocxReader
 	document         *Document
 	source           []byte
 	fragments        map[string]*fragment
 	fragmentResolver FragmentResolver
 	resolverMisses   map[string]bool
+	headerFragment   string // fragment rendered as the document header
+	footerFragment   string // fragment rendered as the document footer
 	renderResources  *templateRenderResources
-	closed           bool
-	mu               sync.RWMutex
+	// copyCompressedParts copies unchanged parts into the output without
+	// recompressing them. It is set for frozen templates.
+	copyCompressedParts bool
+	// mapping is the memory mapping source is read from, for templates
+	// prepared with Config.MemoryMapTemplates.
+	mapping *mappedFile
+	// arithmeticPolicy is the engine's ArithmeticPolicy when the template
+	// was prepared.
+	arithmeticPolicy ArithmeticPolicy
+	// concatenationPolicy is the engine's ConcatenationPolicy when the
+	// template was prepared.
+	concatenationPolicy ConcatenationPolicy
+	// maxIncludeDepth is the engine's include depth limit when the template
+	// was prepared; 0 uses the global configuration.
+	maxIncludeDepth int
+	// documentReplaced is set when document no longer matches the
+	// word/document.xml part of source, so the part is always rendered.
+	documentReplaced bool
+	// sections holds the rendered {{cache}} blocks of the template.
+	sections sectionCache
+	// clauses are the clauses of a document built with Assemble.
+	clauses []assemblyClause
+	// macros are the macros defined in the template; macroLibrary holds
+	// those added with AddMacros.
+	macros       map[string]*templateMacro
+	macroLibrary map[string]*templateMacro
+	// dependencies caches the data keys the template reads, for ReRender.
+	dependencies *templateDataDependencies
+	// outputSizeHint is the size of the last rendered package. The output
+	// buffer of the next render starts at this size.
+	outputSizeHint atomic.Int64
+	// cacheID identifies the template in the keys of the render cache,
+	// and revision counts the changes of its fragments and macros.
+	cacheID    atomic.Uint64
+	revision   uint64
+	sourceOnce sync.Once
+	sourceHash string
+	// glossary indexes the building blocks of glossaryContent, the glossary
+	// document of the template, on first use.
+	glossaryOnce    sync.Once
+	glossary        *glossaryIndex
+	glossaryContent []byte
+	closed     bool
+	mu         sync.RWMutex
 }
 
 type templateRenderResources struct {
 	mainNamespaces    map[string]string
 	mainStylesXML     []byte
+	mainTheme         *documentTheme
 	baseNumbering     *numberingContext
 	staticParts       map[string][]byte
 	dynamicParts      map[string]bool
-	mergedStylesCache map[string][]byte
+	mergedStylesCache map[string]mergedStyles
 	bodyPlans         map[*Body]*bodyRenderPlan
 	paragraphPlans    map[*Paragraph]*paragraphRenderPlan
 	cacheMu           sync.RWMutex
@@ -54,7 +106,8 @@ type fragment struct {
 	parsed             *Document
 	isDocx             bool
 	docxData           []byte
-	mediaFiles         map[string][]byte // filename -> content
+	mediaFiles         map[string][]byte // filename -> content; nil with lazyMedia
+	lazyMedia          bool              // media is read from docxData on include
 	relationships      []Relationship    // from word/_rels/document.xml.rels
 	numberingXML       []byte            // from word/numbering.xml
 	stylesXML          []byte            // from word/styles.xml
@@ -64,6 +117,8 @@ type fragment struct {
 	paragraphPlans     map[*Paragraph]*paragraphRenderPlan
 	fontOverride       fragmentFontOverrides
 	hasFontOverride    bool
+	styleConflicts     map[string]bool // IDs of styles the main styles define differently
+	theme              *documentTheme  // from word/theme/theme1.xml
 	prepareOnce        sync.Once
 	prepareErr         error
 }
@@ -74,34 +129,114 @@ type renderContext struct {
 	fragments      map[string]*fragment
 	template       *template
 	fragmentStack  []string               // Track fragment inclusion stack for circular reference detection
-	renderDepth    int                    // Track render depth to prevent excessive nesting
 	ooxmlFragments map[string]interface{} // Store OOXML fragments for later processing
 
 	// Fragment resource tracking
-	fragmentMedia          map[string][]byte // remapped filename -> content
-	fragmentRelationships  []Relationship    // relationships to add
-	fragmentIDAllocations  map[string]int    // fragment name -> allocated range start
-	nextFragmentIDRange    int               // next available range start
-	fragmentResourcesAdded map[string]bool   // fragment name -> already added
-	usedDocxFragments      map[string]bool   // docx fragments included during this render
-	numbering              *numberingContext
-	fragmentFontOverrides  map[string]fragmentFontOverrides
-	mainStylesXML          []byte
+	fragmentMedia         map[string][]byte            // remapped filename -> content
+	fragmentRelationships []Relationship               // relationships to add
+	fragmentIDMaps        map[string]map[string]string // fragment name -> original -> remapped relationship ID
+	relationshipIDs       *relationshipIDAllocator     // allocates IDs for fragmentRelationships; created on first use
+	usedDocxFragments     map[string]bool              // docx fragments included during this render
+	numbering             *numberingContext
+	fragmentFontOverrides map[string]fragmentFontOverrides
+	fragmentThemeFonts    map[string]*fragmentThemeFonts
+	mainStylesXML         []byte
+	mainTheme             *documentTheme
 
 	// Namespace collection
 	collectedNamespaces map[string]string // prefix -> URI, collected from all fragments
 	bodyPlans           map[*Body]*bodyRenderPlan
 	paragraphPlans      map[*Paragraph]*paragraphRenderPlan
+
+	// options holds the per-render options passed to RenderWithOptions
+	options *RenderOptions
+
+	// headerFragment and footerFragment name the designated header/footer
+	// fragments at the time the fragments were snapshotted
+	headerFragment string
+	footerFragment string
+
+	// headerFooterFragments holds designated header/footer fragments rendered
+	// for this document
+	headerFooterFragments []renderedHeaderFooterFragment
+
+	// fragmentSectionIDMaps maps the relationship IDs of the headers and
+	// footers of included fragments to those of the parts imported for them
+	// (RenderOptions.FragmentHeadersFooters), by fragment name; nil for
+	// fragments whose headers and footers are left out
+	fragmentSectionIDMaps map[string]map[string]string
+	importedSectionParts  []importedSectionPart
+	fragmentSections      int
+
+	// includeModifiers holds evaluated include modifiers (as, indent) for
+	// DOCX fragment markers awaiting expansion
+	includeModifiers map[string]*resolvedIncludeModifiers
+
+	// landscapeAppendices holds the landscapeAppendix() tables to append to
+	// the body once it is rendered
+	landscapeAppendices []*landscapeAppendix
+
+	// embeddedFiles holds the parts of the files embedded with embedFile()
+	embeddedFiles []embeddedPart
+
+	// definedTerms holds the terms defined with defineTerm(), in the order
+	// they were rendered
+	definedTerms []definedTerm
+
+	// updateFieldsOnOpen asks Word to update the fields of the document
+	// when it is opened, such as the index of indexTable()
+	updateFieldsOnOpen bool
+
+	// editable numbers the editable regions (editable(), editableStart());
+	// shared with the contexts of headers and footers
+	editable *editableRegionState
+
+	// storyPart names the header, footer or note part being rendered; it is
+	// empty while the main document is rendered
+	storyPart string
+
+	// macroDepth counts the macro calls being rendered
+	macroDepth int
+
+	// cellDepth counts the table cells being rendered
+	cellDepth int
+
+	// repeatingSections collects the loops rendered as repeating sections
+	// (RenderOptions.RepeatingSections); nil until the first one
+	repeatingSections *repeatingSectionState
+
+	// tracer and traceCtx report the spans of the render (WithTracer);
+	// traceCtx holds the current span
+	tracer   Tracer
+	traceCtx context.Context
+
+	// sanitization cleans up the text of output values
+	// (WithTextSanitization)
+	sanitization *TextSanitization
+
+	// memory tracks the estimated memory of the render against
+	// RenderOptions.MaxMemoryBytes; nil without a limit
+	memory *renderMemory
+
+	// anchors follows the paragraphs of the main document for
+	// RenderOptions.OnAnchorPositions; nil when it is not set
+	anchors *anchorTracker
 }
 
 // PreparedTemplate represents a compiled template ready for rendering.
 // Use Prepare() or PrepareFile() to create an instance.
+//
+// A PreparedTemplate is safe for concurrent use. Fragments may be registered
+// while other goroutines render. Registration does not wait for renders in
+// progress; a render may or may not use fragments registered after it started.
 type PreparedTemplate struct {
 	state    *preparedTemplateState
 	template *template
 	closed   bool
 	mu       sync.RWMutex
 	registry FunctionRegistry // Function registry to use during rendering
+	data     *engineData      // Engine global data and value providers
+	warnings []StencilValidationIssue
 }
 
 type preparedTemplateState struct {
@@ -176,17 +311,19 @@ type TemplateData map[string]interface{}
 func prepare(r io.Reader) (*PreparedTemplate, error) {
 	// Read the entire content into memory
 	buf := new(bytes.Buffer)
-	size, err := buf.ReadFrom(r)
-	if err != nil {
+	if _, err := buf.ReadFrom(r); err != nil {
 		return nil, NewDocumentError("read", "", err)
 	}
+	return prepareSource(buf.Bytes())
+}
 
-	// Create a copy of the buffer for DocxReader
-	source := buf.Bytes()
+// prepareSource compiles the template package source, which the template
+// keeps without copying.
+func prepareSource(source []byte) (*PreparedTemplate, error) {
 	reader := bytes.NewReader(source)
 
 	// Parse as DOCX
-	docxReader, err := NewDocxReader(reader, size)
+	docxReader, err := NewDocxReader(reader, int64(len(source)))
 	if err != nil {
 		return nil, NewDocumentError("parse", "DOCX", err)
 	}
@@ -202,12 +339,22 @@ func prepare(r io.Reader) (*PreparedTemplate, error) {
 		return nil, NewParseError("document structure", "", 0)
 	}
 
+	var macros map[string]*templateMacro
+	if strings.Contains(docXML, "macro") {
+		macros, err = extractMacros(doc.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid macro definition: %w", err)
+		}
+	}
+
 	tmpl := &template{
-		docxReader:     docxReader,
-		document:       doc,
-		source:         source,
-		fragments:      make(map[string]*fragment),
-		resolverMisses: make(map[string]bool),
+		docxReader:       docxReader,
+		document:         doc,
+		source:           source,
+		fragments:        make(map[string]*fragment),
+		resolverMisses:   make(map[string]bool),
+		macros:           macros,
+		documentReplaced: macros != nil,
 	}
 
 	if _, err := tmpl.ensureRenderResources(); err != nil {
@@ -344,6 +491,7 @@ func ensureNumberedParagraphAnchor(para *Paragraph) {
 }
 
 func normalizeRenderedParagraph(para *Paragraph) {
+	applyParagraphMarkers(para)
 	cleanEmptyRuns(para)
 	ensureNumberedParagraphAnchor(para)
 }
@@ -373,177 +521,65 @@ func normalizeRenderedBodyElements(elements []BodyElement) {
 	}
 }
 
-// renderHeaderOrFooter processes a header or footer XML file with template rendering
-func renderHeaderOrFooter(file *zip.File, data TemplateData, ctx *renderContext) ([]byte, error) {
-	// Read the original header/footer XML
-	fr, err := file.Open()
-	if err != nil {
-		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
-	}
-	defer fr.Close()
-
-	content, err := io.ReadAll(fr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
-	}
-
-	// Check if this header/footer contains any template markers
-	// If not, return it as-is to preserve all XML structure and namespaces
-	if !partHasPotentialTemplateMarkers(file.Name, content) {
-		return content, nil
-	}
-
-	// Parse using a generic structure that handles both <w:hdr> and <w:ftr>
-	// These have the same structure as document body - just paragraphs and tables
-	var headerFooter struct {
-		XMLName    xml.Name     `xml:""`
-		Attrs      []xml.Attr   `xml:",any,attr"`
-		Paragraphs []*Paragraph `xml:"p"`
-		Tables     []*Table     `xml:"tbl"`
-	}
-
-	if err := xml.Unmarshal(content, &headerFooter); err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", file.Name, err)
-	}
-
-	// Convert paragraphs and tables to BodyElements
-	var elements []BodyElement
-	for _, p := range headerFooter.Paragraphs {
-		elements = append(elements, p)
-	}
-	for _, t := range headerFooter.Tables {
-		elements = append(elements, t)
-	}
-
-	// Render the elements with context
-	renderedElements, err := renderElementsWithContext(elements, data, ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to render elements in %s: %w", file.Name, err)
-	}
-
-	// Separate rendered elements back into paragraphs and tables
-	var renderedParas []*Paragraph
-	var renderedTables []*Table
-	for _, elem := range renderedElements {
-		switch e := elem.(type) {
-		case *Paragraph:
-			renderedParas = append(renderedParas, e)
-		case *Table:
-			renderedTables = append(renderedTables, e)
-		}
-	}
-
-	// Normalize rendered paragraphs so empty Word list items still render reliably.
-	for _, p := range renderedParas {
-		normalizeRenderedParagraph(p)
-	}
-	for _, t := range renderedTables {
-		normalizeRenderedTable(t)
-	}
-
-	renumberSEQFieldsInElements(renderedElements)
-
-	headerFooter.Paragraphs = renderedParas
-	headerFooter.Tables = renderedTables
-
-	rawXMLMap := make(map[string][]byte)
-	markerIndex := 0
-	for _, p := range renderedParas {
-		prepareParagraphRawXML(p, rawXMLMap, &markerIndex)
-	}
-	for tableIdx := range renderedTables {
-		for rowIdx := range renderedTables[tableIdx].Rows {
-			for cellIdx := range renderedTables[tableIdx].Rows[rowIdx].Cells {
-				for paraIdx := range renderedTables[tableIdx].Rows[rowIdx].Cells[cellIdx].Paragraphs {
-					prepareParagraphRawXML(&renderedTables[tableIdx].Rows[rowIdx].Cells[cellIdx].Paragraphs[paraIdx], rawXMLMap, &markerIndex)
-				}
-			}
-		}
-	}
-
-	var bodyXML bytes.Buffer
-	for _, p := range renderedParas {
-		chunk, err := encodeXMLChunk(p, xml.StartElement{Name: xml.Name{Local: "w:p"}}, rawXMLMap)
-		if err != nil {
-			return nil, fmt.Errorf("failed to encode paragraph: %w", err)
-		}
-		bodyXML.Write(chunk)
-	}
-	for _, t := range renderedTables {
-		chunk, err := encodeXMLChunk(t, xml.StartElement{Name: xml.Name{Local: "w:tbl"}}, rawXMLMap)
-		if err != nil {
-			return nil, fmt.Errorf("failed to encode table: %w", err)
-		}
-		bodyXML.Write(chunk)
-	}
-
-	// Extract the opening tag with namespaces from the original content
-	contentStr := string(content)
-
-	// Skip the XML declaration if present
-	xmlDeclEnd := strings.Index(contentStr, "?>")
-	searchStart := 0
-	if xmlDeclEnd != -1 && strings.HasPrefix(strings.TrimSpace(contentStr), "<?xml") {
-		searchStart = xmlDeclEnd + 2
-	}
-
-	// Find the opening root tag (starts after XML declaration)
-	rootTagStart := strings.Index(contentStr[searchStart:], "<")
-	if rootTagStart == -1 {
-		return nil, fmt.Errorf("malformed XML: no root tag found")
-	}
-	rootTagStart += searchStart
-
-	// Find the end of the opening tag
-	openTagEnd := strings.Index(contentStr[rootTagStart:], ">")
-	if openTagEnd == -1 {
-		return nil, fmt.Errorf("malformed XML: no opening tag end found")
-	}
-	openTagEnd += rootTagStart
-
-	// Find where the closing tag starts
-	var closingTag string
-	if strings.Contains(contentStr, "</w:hdr>") {
-		closingTag = "</w:hdr>"
-	} else if strings.Contains(contentStr, "</w:ftr>") {
-		closingTag = "</w:ftr>"
-	} else {
-		return nil, fmt.Errorf("unknown root element type")
-	}
-
-	// Reconstruct: XML declaration + original opening tag + rendered body + closing tag
-	result := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
-	result = append(result, content[rootTagStart:openTagEnd+1]...) // Opening tag with namespaces
-	result = append(result, bodyXML.Bytes()...)                    // Rendered body
-	result = append(result, []byte(closingTag)...)                 // Closing tag
-
-	return result, nil
+func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
+	return pt.RenderWithOptions(data, RenderOptions{})
 }
 
-func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
-	if pt == nil {
-		return nil, NewTemplateError("invalid or nil template", 0, 0)
+// renderTemplatePackage renders the template into a complete DOCX package.
+func renderTemplatePackage(tmpl *template, registry FunctionRegistry, defaults *engineData, data TemplateData, opts *RenderOptions) (_ []byte, err error) {
+	var traceParent context.Context
+	if opts != nil {
+		traceParent = opts.TraceContext
 	}
-	pt.mu.RLock()
-	defer pt.mu.RUnlock()
+	traceCtx, span := defaults.startSpan(traceParent, spanRender)
+	defer func() { span.End(err) }()
+	// Redact sensitive data from the error (SetScrubPolicy), after panics
+	// are recovered and before the span records the error
+	defer func() { err = scrubRenderError(err, data) }()
+	span.SetAttribute(attrTemplateHash, tmpl.sourceDigest())
+	span.SetAttribute(attrTemplateSize, len(tmpl.source))
 
-	if pt.closed || pt.template == nil {
-		return nil, NewTemplateError("template is closed", 0, 0)
+	if opts == nil || !opts.PropagatePanics {
+		defer recoverRenderPanic(&err)
 	}
 
-	tmpl := pt.template
-	registry := pt.registry
-
 	// Create a copy of the data to avoid modifying the original
 	renderData := make(TemplateData)
 	for k, v := range data {
 		renderData[k] = v
 	}
+	// Run the engine's pre-processors (Engine.AddPreProcessor)
+	renderData, err = defaults.preprocess(renderData)
+	if err != nil {
+		return nil, err
+	}
+	helpers := newRenderHelpers(opts)
+	defaults.attach(renderData, helpers)
+	var constantOverrides map[string]interface{}
+	if opts != nil {
+		constantOverrides = opts.Constants
+	}
+	defaults.attachConstants(renderData, constantOverrides)
+	helpers.plurals = defaults.pluralCatalog()
+	helpers.featureProvider = defaults.featureProvider()
+	helpers.arithmeticPolicy = tmpl.arithmeticPolicy
+	helpers.concatenationPolicy = tmpl.concatenationPolicy
+	helpers.macros = tmpl.macroSet()
+	renderData[renderHelpersKey] = helpers
 
 	// Inject the function registry if available and not already present
 	if registry != nil && renderData["__functions__"] == nil {
 		renderData["__functions__"] = registry
 	}
+	// Apply the defaults and computed fields of the schema once functions,
+	// global data and constants are available
+	if opts != nil && opts.Schema != nil {
+		renderData = applySchemaDefaults(renderData, opts.Schema)
+		renderData, err = applyComputedFields(renderData, opts.Schema)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	resources, err := tmpl.ensureRenderResources()
 	if err != nil {
@@ -552,26 +588,38 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 
 	// Create render context
 	numberingCtx := resources.baseNumbering.clone()
+	fragments, headerFragment, footerFragment := tmpl.snapshotFragments()
 
 	renderCtx := &renderContext{
-		linkMarkers:            make(map[string]*LinkReplacementMarker),
-		fragments:              tmpl.snapshotFragments(),
-		template:               tmpl,
-		fragmentStack:          make([]string, 0),
-		renderDepth:            0,
-		ooxmlFragments:         make(map[string]interface{}),
-		fragmentMedia:          make(map[string][]byte),
-		fragmentRelationships:  make([]Relationship, 0),
-		fragmentIDAllocations:  make(map[string]int),
-		nextFragmentIDRange:    FragmentIDRangeStart,
-		fragmentResourcesAdded: make(map[string]bool),
-		usedDocxFragments:      make(map[string]bool),
-		numbering:              numberingCtx,
-		fragmentFontOverrides:  make(map[string]fragmentFontOverrides),
-		mainStylesXML:          resources.mainStylesXML,
-		collectedNamespaces:    make(map[string]string),
-		bodyPlans:              cloneBodyPlanMap(resources.bodyPlans),
-		paragraphPlans:         cloneParagraphPlanMap(resources.paragraphPlans),
+		linkMarkers:           make(map[string]*LinkReplacementMarker),
+		fragments:             fragments,
+		headerFragment:        headerFragment,
+		footerFragment:        footerFragment,
+		template:              tmpl,
+		fragmentStack:         make([]string, 0),
+		ooxmlFragments:        make(map[string]interface{}),
+		fragmentMedia:         make(map[string][]byte),
+		fragmentRelationships: make([]Relationship, 0),
+		fragmentIDMaps:        make(map[string]map[string]string),
+		fragmentSectionIDMaps: make(map[string]map[string]string),
+		usedDocxFragments:     make(map[string]bool),
+		numbering:             numberingCtx,
+		fragmentFontOverrides: make(map[string]fragmentFontOverrides),
+		fragmentThemeFonts:    make(map[string]*fragmentThemeFonts),
+		mainStylesXML:         resources.mainStylesXML,
+		mainTheme:             resources.mainTheme,
+		collectedNamespaces:   make(map[string]string),
+		bodyPlans:             cloneBodyPlanMap(resources.bodyPlans),
+		paragraphPlans:        cloneParagraphPlanMap(resources.paragraphPlans),
+		options:               opts,
+		tracer:                defaults.activeTracer(),
+		traceCtx:              traceCtx,
+		sanitization:          defaults.textSanitization(),
+		editable:              &editableRegionState{},
+		memory:                newRenderMemory(opts),
+	}
+	if opts != nil && opts.OnAnchorPositions != nil {
+		renderCtx.anchors = newAnchorTracker(tmpl.document)
 	}
 
 	// Collect namespaces from the main template document (V5: REQUIRED)
@@ -581,14 +629,35 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 
 	renderedXML := resources.staticParts["word/document.xml"]
 	var renderedDoc *Document
-	if resources.dynamicParts["word/document.xml"] {
+	processors := defaults.postProcessors()
+	houseStyle := opts != nil && opts.HouseStyle != nil
+	keepHeadings := opts != nil && opts.KeepHeadingsWithNext
+	emoji := opts != nil && opts.Emoji != nil
+	if resources.dynamicParts["word/document.xml"] || len(processors) > 0 || houseStyle || keepHeadings || emoji {
+		documentSpan, endDocumentSpan := renderCtx.startSpan(spanRenderDocument)
+		defer func() { endDocumentSpan(err) }()
+
 		// First pass: render the document with variable substitution
+		_, endBodySpan := renderCtx.startSpan(spanRenderBody)
 		renderedDoc, err = RenderDocumentWithContext(tmpl.document, renderData, renderCtx)
+		endBodySpan(err)
 		if err != nil {
 			return nil, WithContext(err, "rendering document", map[string]interface{}{"hasData": data != nil})
 		}
+		if err := renderCtx.checkEditableRegionsClosed("word/document.xml"); err != nil {
+			return nil, err
+		}
 		if renderedDoc != nil && renderedDoc.Body != nil {
 			normalizeRenderedBodyElements(renderedDoc.Body.Elements)
+			appendLandscapeAppendices(renderedDoc.Body, renderCtx.landscapeAppendices)
+		}
+
+		// Process table markers (tableIf() functions)
+		_, endTablesSpan := renderCtx.startSpan(spanRenderTables)
+		defer func() { endTablesSpan(err) }()
+		err = ProcessTableMarkers(renderedDoc)
+		if err != nil {
+			return nil, WithContext(err, "processing table markers", nil)
 		}
 
 		// Process table row markers (hideRow() functions)
@@ -597,16 +666,133 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 			return nil, WithContext(err, "processing table row markers", nil)
 		}
 
+		// Expand tab-separated text into table rows (tsvRows() functions)
+		// before rows are sorted and numbered
+		err = ProcessTSVRowMarkers(renderedDoc)
+		if err != nil {
+			return nil, WithContext(err, "processing tsv row markers", nil)
+		}
+
+		// Process table sort markers (sortTable() functions) before columns
+		// are hidden, so column indexes refer to the template
+		err = processTableSortMarkers(renderedDoc, helpers.locale)
+		if err != nil {
+			return nil, WithContext(err, "processing table sort markers", nil)
+		}
+
+		// Number table rows (rowNumber() functions) once rows are hidden
+		// and sorted
+		err = ProcessTableRowNumbers(renderedDoc)
+		if err != nil {
+			return nil, WithContext(err, "processing table row numbers", nil)
+		}
+
 		// Process table column markers (hideColumn() functions)
 		err = ProcessTableColumnMarkers(renderedDoc)
 		if err != nil {
 			return nil, WithContext(err, "processing table column markers", nil)
 		}
+		endTablesSpan(nil)
+
+		// Number clauses and resolve references to them (clause() and
+		// clauseRef() functions) once conditional content is settled
+		err = ProcessClauseNumbers(renderedDoc)
+		if err != nil {
+			return nil, WithContext(err, "processing clause numbers", nil)
+		}
+
+		// Letter exhibits and resolve references to them (exhibit() and
+		// exhibitRef() functions)
+		err = ProcessExhibitLetters(renderedDoc)
+		if err != nil {
+			return nil, WithContext(err, "processing exhibit letters", nil)
+		}
+
+		// Build the glossary of the terms that made it into the document
+		// (defineTerm() and glossary() functions)
+		err = processGlossary(renderedDoc, renderCtx.definedTerms)
+		if err != nil {
+			return nil, WithContext(err, "processing glossary", nil)
+		}
+
+		// Turn splitDocument() markers into page breaks unless RenderMulti
+		// cuts the document at them
+		if opts == nil || !opts.splitDocuments {
+			err = ProcessSplitDocumentMarkers(renderedDoc)
+			if err != nil {
+				return nil, WithContext(err, "processing split document markers", nil)
+			}
+		}
+
+		// End the sections of included fragments whose headers and footers
+		// are imported (RenderOptions.FragmentHeadersFooters)
+		if renderedDoc != nil {
+			if err := processFragmentSections(renderedDoc.Body); err != nil {
+				return nil, WithContext(err, "processing fragment sections", nil)
+			}
+		}
+
+		// Turn columns blocks into continuous sections with balanced
+		// columns ({{columns}} directive)
+		if renderedDoc != nil {
+			if err := processColumnSections(renderedDoc.Body); err != nil {
+				return nil, WithContext(err, "processing column sections", nil)
+			}
+		}
+
+		// Split long tables into pages (RenderOptions.TableContinuation)
+		if renderedDoc != nil && opts != nil && opts.TableContinuation != nil {
+			if err := applyTableContinuation(renderedDoc.Body, opts.TableContinuation); err != nil {
+				return nil, err
+			}
+		}
+
+		// Number captions and resolve references to them (caption() and
+		// figRef() functions) once tables are split
+		err = processCaptionNumbers(renderedDoc, resolveParagraphStyleID(renderCtx.mainStylesXML, captionStyle))
+		if err != nil {
+			return nil, WithContext(err, "processing caption numbers", nil)
+		}
 
 		if renderedDoc != nil && renderedDoc.Body != nil {
 			renumberSEQFieldsInElements(renderedDoc.Body.Elements)
 		}
 
+		// Run the engine's post-processors (Engine.AddPostProcessor)
+		if renderedDoc != nil && len(processors) > 0 {
+			processorData := materializeTemplateData(renderData)
+			for i, processor := range processors {
+				if err := processor(renderedDoc, processorData); err != nil {
+					return nil, WithContext(err, "running post-processor", map[string]interface{}{"index": i})
+				}
+			}
+		}
+
+		// Enforce the house style on the assembled document
+		// (RenderOptions.HouseStyle)
+		if renderedDoc != nil && renderedDoc.Body != nil && houseStyle {
+			applyHouseStyle(renderedDoc.Body.Elements, opts.HouseStyle)
+		}
+
+		// Keep headings with the paragraph that follows them
+		// (RenderOptions.KeepHeadingsWithNext)
+		if renderedDoc != nil && renderedDoc.Body != nil && keepHeadings {
+			keepHeadingsWithNext(renderedDoc.Body.Elements, renderCtx.mainStylesXML)
+		}
+
+		// Draw emoji as images or in an emoji font (RenderOptions.Emoji)
+		if renderedDoc != nil && renderedDoc.Body != nil && emoji {
+			if err := renderEmoji(renderedDoc.Body.Elements, opts.Emoji, renderCtx); err != nil {
+				return nil, WithContext(err, "rendering emoji", nil)
+			}
+		}
+
+		// Record where the paragraphs of the template ended up
+		// (RenderOptions.OnAnchorPositions)
+		if renderedDoc != nil {
+			renderCtx.anchors.collect(renderedDoc.Body)
+		}
+
 		// V5: Merge collected namespaces from fragments into main document
 		if len(renderCtx.collectedNamespaces) > 0 {
 			renderedDoc.MergeNamespaces(renderCtx.collectedNamespaces)
@@ -617,6 +803,24 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 		if err != nil {
 			return nil, NewDocumentError("marshal", "rendered document", err)
 		}
+
+		// Wrap the content of loops in repeating section content controls
+		// (RenderOptions.RepeatingSections)
+		renderedXML, err = renderCtx.repeatingSections.wrap(renderedXML)
+		if err != nil {
+			return nil, NewDocumentError("marshal", "repeating sections", err)
+		}
+		if err := renderCtx.memory.charge(len(renderedXML), "marshaling the document"); err != nil {
+			return nil, err
+		}
+		if renderedDoc != nil && renderedDoc.Body != nil {
+			setElementCountAttributes(documentSpan, renderedDoc.Body.Elements)
+		}
+		endDocumentSpan(nil)
+	}
+
+	if err := renderHeaderFooterFragments(renderData, renderCtx); err != nil {
+		return nil, err
 	}
 
 	// Process link replacements and fragment relationships
@@ -634,6 +838,10 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 		}
 		currentRels := parseRelationships([]byte(relsXML))
 
+		// Add fragment relationships first so that IDs generated for link
+		// replacements do not collide with them
+		currentRels = append(currentRels, renderCtx.fragmentRelationships...)
+
 		// Process link replacements if any
 		if len(renderCtx.linkMarkers) > 0 {
 			renderedXML, updatedRelationships, err = processLinkReplacements(renderedXML, renderCtx.linkMarkers, currentRels)
@@ -644,11 +852,6 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 			updatedRelationships = currentRels
 		}
 
-		// Add fragment relationships
-		if len(renderCtx.fragmentRelationships) > 0 {
-			updatedRelationships = append(updatedRelationships, renderCtx.fragmentRelationships...)
-		}
-
 		if renderCtx.numbering != nil && renderCtx.numbering.needsRelationship() {
 			updatedRelationships = append(updatedRelationships, Relationship{
 				ID:     generateNewRelationshipID(updatedRelationships),
@@ -660,7 +863,8 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 
 	// Create a new DOCX with the rendered content
 	buf := new(bytes.Buffer)
-	w := zip.NewWriter(buf)
+	buf.Grow(int(tmpl.outputSizeHint.Load()))
+	w := zip.NewWriter(renderCtx.memory.writer(buf))
 	w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
 		return newPooledFlateWriter(out)
 	})
@@ -672,28 +876,70 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 		return nil, fmt.Errorf("failed to read source zip: %w", err)
 	}
 
-	renderedHeaderFooterParts := make(map[string][]byte)
+	// Assign the headers and footers to the sections of the rendered
+	// document (RenderOptions.SectionData)
+	var sectionParts *sectionPartPlan
+	if opts != nil && opts.SectionData != nil {
+		documentRels := updatedRelationships
+		if len(documentRels) == 0 {
+			relsXML, err := tmpl.docxReader.GetRelationshipsXML()
+			if err != nil {
+				return nil, NewDocumentError("extract", "relationships", err)
+			}
+			documentRels = parseRelationships([]byte(relsXML))
+		}
+		sectionParts, err = planSectionParts(renderedXML, documentRels, renderData, opts.SectionData, resources.staticParts)
+		if err != nil {
+			return nil, NewDocumentError("parse", "section properties", err)
+		}
+	}
+
+	renderedStoryParts := make(map[string][]byte)
 	for _, file := range zipReader.File {
-		if !isHeaderPartName(file.Name) && !isFooterPartName(file.Name) {
+		if !isStoryPartName(file.Name) && !isChartPartName(file.Name) {
 			continue
 		}
 		if staticPart, ok := resources.staticParts[file.Name]; ok {
-			renderedHeaderFooterParts[file.Name] = staticPart
+			renderedStoryParts[file.Name] = staticPart
 			continue
 		}
 
-		renderedPart, err := renderHeaderOrFooter(file, renderData, renderCtx)
+		partSpan, endPartSpan := renderCtx.startSpan(spanRenderPart)
+		partSpan.SetAttribute(attrPart, file.Name)
+		var renderedPart []byte
+		if isChartPartName(file.Name) {
+			renderedPart, err = renderChartPart(file, renderData, renderCtx)
+		} else {
+			renderedPart, err = renderStoryPart(file, sectionParts.dataFor(file.Name, renderData), renderCtx)
+		}
+		if err == nil {
+			err = renderCtx.memory.charge(len(renderedPart), "rendering "+file.Name)
+		}
+		if err == nil {
+			err = sectionParts.renderCopies(file, renderCtx)
+		}
+		endPartSpan(err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to render %s: %w", file.Name, err)
 		}
-		renderedHeaderFooterParts[file.Name] = renderedPart
+		renderedStoryParts[file.Name] = renderedPart
+	}
+
+	// Fail the render if assertions of the template failed
+	// (RenderOptions.CheckAssertions)
+	if err := helpers.assertions.err(); err != nil {
+		return nil, err
 	}
 
+	assembleSpan, endAssembleSpan := renderCtx.startSpan(spanAssemble)
+	defer func() { endAssembleSpan(err) }()
+	assembleSpan.SetAttribute(attrParts, len(zipReader.File)+len(renderCtx.fragmentMedia)+len(renderCtx.embeddedFiles))
+
 	// Track if we need to update Content Types for fragment media
 	var contentTypes *ContentTypes
 	hasFragmentMedia := len(renderCtx.fragmentMedia) > 0
 	needsNumberingPartWrite := renderCtx.numbering != nil && renderCtx.numbering.modified
-	needsContentTypesUpdate := hasFragmentMedia
+	needsContentTypesUpdate := hasFragmentMedia || len(renderCtx.embeddedFiles) > 0
 	if renderCtx.numbering != nil && renderCtx.numbering.needsContentTypeOverride() {
 		needsContentTypesUpdate = true
 	}
@@ -711,7 +957,7 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
 			}
 		} else if isHeaderPartName(file.Name) {
-			renderedHeader, ok := renderedHeaderFooterParts[file.Name]
+			renderedHeader, ok := renderedStoryParts[file.Name]
 			if !ok {
 				return nil, fmt.Errorf("missing pre-rendered header part %s", file.Name)
 			}
@@ -724,7 +970,7 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
 			}
 		} else if isFooterPartName(file.Name) {
-			renderedFooter, ok := renderedHeaderFooterParts[file.Name]
+			renderedFooter, ok := renderedStoryParts[file.Name]
 			if !ok {
 				return nil, fmt.Errorf("missing pre-rendered footer part %s", file.Name)
 			}
@@ -736,6 +982,32 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
 			}
+		} else if isNotesPartName(file.Name) || isGlossaryPartName(file.Name) {
+			renderedStory, ok := renderedStoryParts[file.Name]
+			if !ok {
+				return nil, fmt.Errorf("missing pre-rendered story part %s", file.Name)
+			}
+			fw, err := w.Create(file.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", file.Name, err)
+			}
+			_, err = fw.Write(renderedStory)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
+			}
+		} else if isChartPartName(file.Name) {
+			renderedChart, ok := renderedStoryParts[file.Name]
+			if !ok {
+				return nil, fmt.Errorf("missing pre-rendered chart part %s", file.Name)
+			}
+			fw, err := w.Create(file.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", file.Name, err)
+			}
+			_, err = fw.Write(renderedChart)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
+			}
 		} else if file.Name == "word/_rels/document.xml.rels" && len(updatedRelationships) > 0 {
 			// Update relationships if we have link replacements or fragment relationships
 			// Use Marshal (not MarshalIndent) to produce compact XML like the original
@@ -790,7 +1062,7 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
 			}
 		} else if file.Name == "word/styles.xml" {
-			mergedStyles := resources.stylesXMLForRender(renderCtx.numbering, renderCtx.fragments, renderCtx.usedDocxFragments)
+			mergedStyles := resources.stylesXMLForRender(renderCtx.numbering, renderCtx.fragments, renderCtx.usedDocxFragments, renderCtx.options)
 
 			// Write merged styles
 			fw, err := w.Create(file.Name)
@@ -889,6 +1161,11 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
 			}
+		} else if tmpl.copyCompressedParts {
+			// Copy other files as-is without recompressing them
+			if err := w.Copy(file); err != nil {
+				return nil, fmt.Errorf("failed to copy %s: %w", file.Name, err)
+			}
 		} else {
 			// Copy other files as-is
 			fw, err := w.Create(file.Name)
@@ -921,6 +1198,18 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 		}
 	}
 
+	// Add the parts of embedded files
+	for _, part := range renderCtx.embeddedFiles {
+		fw, err := w.Create("word/embeddings/" + part.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embedded file %s: %w", part.name, err)
+		}
+		_, err = fw.Write(part.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write embedded file %s: %w", part.name, err)
+		}
+	}
+
 	if needsNumberingPartWrite && renderCtx.numbering != nil && !renderCtx.numbering.existsInTemplate {
 		fw, err := w.Create("word/numbering.xml")
 		if err != nil {
@@ -978,6 +1267,13 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 			}
 		}
 
+		for _, part := range renderCtx.embeddedFiles {
+			contentTypes.Overrides = append(contentTypes.Overrides, ContentTypeOverride{
+				PartName:    "/word/embeddings/" + part.name,
+				ContentType: part.contentType,
+			})
+		}
+
 		if renderCtx.numbering != nil && renderCtx.numbering.needsContentTypeOverride() {
 			alreadyRegistered := false
 			for _, override := range contentTypes.Overrides {
@@ -1025,8 +1321,45 @@ func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to close zip writer: %w", err)
 	}
+	tmpl.outputSizeHint.Store(int64(buf.Len()))
+
+	output := buf.Bytes()
+	if len(renderCtx.importedSectionParts) > 0 {
+		output, err = applyImportedSectionParts(output, renderCtx.importedSectionParts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if sectionParts.hasCopies() {
+		output, err = applySectionPartCopies(output, sectionParts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(renderCtx.headerFooterFragments) > 0 {
+		output, err = applyHeaderFooterFragments(output, renderCtx.headerFooterFragments)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if renderCtx.editable.count > 0 || renderCtx.updateFieldsOnOpen {
+		output, err = applyRenderSettings(output, renderCtx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if renderCtx.repeatingSections != nil {
+		output, err = applyRepeatingSectionData(output, renderCtx.repeatingSections)
+		if err != nil {
+			return nil, err
+		}
+	}
+	assembleSpan.SetAttribute(attrOutputSize, len(output))
 
-	return bytes.NewReader(buf.Bytes()), nil
+	if renderCtx.anchors != nil {
+		opts.OnAnchorPositions(renderCtx.anchors.positions)
+	}
+	return output, nil
 }
 
 // Close releases any resources held by the prepared template.
@@ -1072,6 +1405,8 @@ func (pt *PreparedTemplate) cloneHandle() (*PreparedTemplate, bool) {
 		state:    pt.state,
 		template: pt.template,
 		registry: pt.registry,
+		data:     pt.data,
+		warnings: pt.warnings,
 	}, true
 }
 
@@ -1094,11 +1429,53 @@ func (pt *PreparedTemplate) AddFragment(name string, content string) error {
 	if pt == nil {
 		return fmt.Errorf("invalid template")
 	}
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return errTemplateClosed
+	}
+
+	frag, err := newTextFragment(name, content)
+	if err != nil {
+		return err
+	}
+
+	tmpl := pt.template
+	tmpl.mu.Lock()
+	defer tmpl.mu.Unlock()
+	if tmpl.fragments == nil {
+		tmpl.fragments = make(map[string]*fragment)
+	}
+	tmpl.fragments[name] = frag
+	delete(tmpl.resolverMisses, name)
+	tmpl.invalidateFragmentCachesLocked()
+	return nil
+}
 
+// AddFragmentIfMissing adds a text fragment unless a fragment with the same
+// name is already registered or can be resolved by the fragment resolver.
+// This is useful for registering fallback content for optional sections.
+//
+// Example:
+//
+//	err := template.AddFragmentIfMissing("tenantFooter", "")
+func (pt *PreparedTemplate) AddFragmentIfMissing(name string, content string) error {
+	if pt == nil {
+		return fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
 	if pt.closed || pt.template == nil {
-		return fmt.Errorf("template is closed")
+		pt.mu.RUnlock()
+		return errTemplateClosed
+	}
+	existing, err := pt.template.resolveFragment(name)
+	pt.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to resolve fragment %s: %w", name, err)
+	}
+	if existing != nil {
+		return nil
 	}
 
 	frag, err := newTextFragment(name, content)
@@ -1106,12 +1483,21 @@ func (pt *PreparedTemplate) AddFragment(name string, content string) error {
 		return err
 	}
 
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	if pt.closed || pt.template == nil {
+		return errTemplateClosed
+	}
+
 	tmpl := pt.template
 	tmpl.mu.Lock()
 	defer tmpl.mu.Unlock()
 	if tmpl.fragments == nil {
 		tmpl.fragments = make(map[string]*fragment)
 	}
+	if _, exists := tmpl.fragments[name]; exists {
+		return nil
+	}
 	tmpl.fragments[name] = frag
 	delete(tmpl.resolverMisses, name)
 	tmpl.invalidateFragmentCachesLocked()
@@ -1138,11 +1524,11 @@ func (pt *PreparedTemplate) AddFragmentFromBytes(name string, docxBytes []byte)
 	if pt == nil {
 		return fmt.Errorf("invalid template")
 	}
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
 
 	if pt.closed || pt.template == nil {
-		return fmt.Errorf("template is closed")
+		return errTemplateClosed
 	}
 
 	if err := validateDocxFragmentBytes(docxBytes); err != nil {
@@ -1150,6 +1536,123 @@ func (pt *PreparedTemplate) AddFragmentFromBytes(name string, docxBytes []byte)
 	}
 
 	frag := newLazyDocxFragment(name, docxBytes)
+	pt.template.addFragment(name, frag)
+	return nil
+}
+
+// AddFragmentFromBytesLazy adds a DOCX fragment from raw bytes like
+// AddFragmentFromBytes, but keeps a reference to docxBytes instead of a
+// copy and reads the images and other media of the fragment from them only
+// while a render includes it. Services registering hundreds of fragments
+// keep their memory flat this way: the media of a fragment is not held
+// outside the renders that include it, and a fragment that is never
+// included costs little more than its bytes.
+//
+// docxBytes must not be modified after the call.
+//
+// Example:
+//
+//	for name, path := range fragmentFiles {
+//	    fragmentBytes, err := os.ReadFile(path)
+//	    if err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    if err := template.AddFragmentFromBytesLazy(name, fragmentBytes); err != nil {
+//	        log.Fatal(err)
+//	    }
+//	}
+func (pt *PreparedTemplate) AddFragmentFromBytesLazy(name string, docxBytes []byte) error {
+	if pt == nil {
+		return fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return errTemplateClosed
+	}
+
+	if err := validateDocxFragmentBytes(docxBytes); err != nil {
+		return err
+	}
+
+	pt.template.addFragment(name, newSharedDocxFragment(name, docxBytes))
+	return nil
+}
+
+// addFragment registers frag under name, replacing any fragment of the
+// same name.
+func (tmpl *template) addFragment(name string, frag *fragment) {
+	tmpl.mu.Lock()
+	defer tmpl.mu.Unlock()
+	if tmpl.fragments == nil {
+		tmpl.fragments = make(map[string]*fragment)
+	}
+	tmpl.fragments[name] = frag
+	delete(tmpl.resolverMisses, name)
+	tmpl.invalidateFragmentCachesLocked()
+}
+
+// AddFragmentFromTemplate adds another prepared template as a DOCX fragment.
+// The fragment shares the other template's in-memory document, including its
+// media, styles and numbering, so no bytes need to be read again. This allows
+// keeping a library of prepared building blocks that can be included into
+// many templates.
+//
+// Example:
+//
+//	signature, err := stencil.PrepareFile("signature.docx")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = template.AddFragmentFromTemplate("signature", signature)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+// Then in your template: {{include "signature"}}
+func (pt *PreparedTemplate) AddFragmentFromTemplate(name string, other *PreparedTemplate) error {
+	if pt == nil {
+		return fmt.Errorf("invalid template")
+	}
+	if other == nil {
+		return fmt.Errorf("invalid fragment template")
+	}
+	if other == pt {
+		return fmt.Errorf("cannot add template %q as a fragment of itself", name)
+	}
+
+	other.mu.RLock()
+	if other.closed || other.template == nil {
+		other.mu.RUnlock()
+		return errFragmentTemplateClosed
+	}
+	other.template.mu.RLock()
+	source := other.template.source
+	if other.template.mapping != nil {
+		// A mapped source is unmapped when other is closed
+		source = append([]byte(nil), source...)
+	}
+	other.template.mu.RUnlock()
+	other.mu.RUnlock()
+	if len(source) == 0 {
+		return errFragmentTemplateClosed
+	}
+
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return errTemplateClosed
+	}
+
+	// The source of a prepared template is never modified, so it can be
+	// shared without copying.
+	frag := &fragment{
+		name:     name,
+		isDocx:   true,
+		docxData: source,
+	}
 	tmpl := pt.template
 	tmpl.mu.Lock()
 	defer tmpl.mu.Unlock()
@@ -1162,6 +1665,34 @@ func (pt *PreparedTemplate) AddFragmentFromBytes(name string, docxBytes []byte)
 	return nil
 }
 
+// Fragments returns the names of all registered fragments in sorted order.
+// Fragments loaded through a FragmentResolver are listed once they have been
+// resolved. Together with a dynamic include inside a loop, this allows the
+// assembly order of a document to be driven entirely by data:
+//
+//	{{for name in sections}}{{include name}}{{end}}
+func (pt *PreparedTemplate) Fragments() []string {
+	if pt == nil {
+		return nil
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if pt.closed || pt.template == nil {
+		return nil
+	}
+
+	tmpl := pt.template
+	tmpl.mu.RLock()
+	defer tmpl.mu.RUnlock()
+	names := make([]string, 0, len(tmpl.fragments))
+	for name := range tmpl.fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (t *template) invalidateRenderResources() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -1170,12 +1701,16 @@ func (t *template) invalidateRenderResources() {
 }
 
 func (t *template) invalidateFragmentCachesLocked() {
-	if t == nil || t.renderResources == nil {
+	if t == nil {
+		return
+	}
+	t.revision++
+	if t.renderResources == nil {
 		return
 	}
 	t.renderResources.cacheMu.Lock()
 	defer t.renderResources.cacheMu.Unlock()
-	t.renderResources.mergedStylesCache = make(map[string][]byte)
+	t.renderResources.mergedStylesCache = make(map[string]mergedStyles)
 }
 
 func (t *template) ensureRenderResources() (*templateRenderResources, error) {
@@ -1206,24 +1741,33 @@ func (t *template) refreshRenderResources() (*templateRenderResources, error) {
 	}
 
 	var mainStylesXML []byte
+	var mainTheme *documentTheme
 	if t.docxReader != nil {
 		if stylesXML, err := t.docxReader.GetPart("word/styles.xml"); err == nil {
 			mainStylesXML = append([]byte(nil), stylesXML...)
 		}
+		if themeXML, err := t.docxReader.GetPart(themePartName); err == nil {
+			mainTheme = parseDocumentTheme(themeXML)
+		}
 	}
 
 	staticParts, dynamicParts, err := buildStaticPartCache(t.docxReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build static part cache: %w", err)
 	}
+	if t.documentReplaced {
+		delete(staticParts, "word/document.xml")
+		dynamicParts["word/document.xml"] = true
+	}
 
 	resources := &templateRenderResources{
 		mainNamespaces:    mainNamespaces,
 		mainStylesXML:     mainStylesXML,
+		mainTheme:         mainTheme,
 		baseNumbering:     baseNumbering,
 		staticParts:       staticParts,
 		dynamicParts:      dynamicParts,
-		mergedStylesCache: make(map[string][]byte),
+		mergedStylesCache: make(map[string]mergedStyles),
 		bodyPlans:         buildTemplateBodyPlans(t.document),
 		paragraphPlans:    buildTemplateParagraphPlans(t.document),
 	}
@@ -1231,7 +1775,14 @@ func (t *template) refreshRenderResources() (*templateRenderResources, error) {
 	return resources, nil
 }
 
-func (r *templateRenderResources) stylesXMLForRender(numbering *numberingContext, fragments map[string]*fragment, usedDocxFragments map[string]bool) []byte {
+// mergedStyles is the styles.xml of a render merged with the styles of the
+// fragments it included, and the warnings of merging them.
+type mergedStyles struct {
+	xml      []byte
+	warnings []StyleMergeWarning
+}
+
+func (r *templateRenderResources) stylesXMLForRender(numbering *numberingContext, fragments map[string]*fragment, usedDocxFragments map[string]bool, opts *RenderOptions) []byte {
 	if r == nil {
 		return nil
 	}
@@ -1256,15 +1807,20 @@ func (r *templateRenderResources) stylesXMLForRender(numbering *numberingContext
 
 	sort.Strings(names)
 
-	cacheKey := buildMergedStylesCacheKey(names, numbering)
+	var policy StyleConflictPolicy
+	if opts != nil {
+		policy = opts.StyleConflicts
+	}
+	cacheKey := fmt.Sprintf("%d;%s", policy, buildMergedStylesCacheKey(names, numbering))
 	r.cacheMu.RLock()
 	if cached, ok := r.mergedStylesCache[cacheKey]; ok {
 		r.cacheMu.RUnlock()
-		return cached
+		opts.reportStyleMergeWarnings(cached.warnings)
+		return cached.xml
 	}
 	r.cacheMu.RUnlock()
 
-	fragmentStyles := make([][]byte, 0, len(names))
+	fragmentStyles := make([]fragmentStyleSet, 0, len(names))
 	for _, name := range names {
 		stylesXML := fragments[name].stylesXML
 		if numbering != nil {
@@ -1275,26 +1831,32 @@ func (r *templateRenderResources) stylesXMLForRender(numbering *numberingContext
 		if len(stylesXML) == 0 {
 			continue
 		}
-		fragmentStyles = append(fragmentStyles, stylesXML)
+		if remap, ok := buildFragmentThemeRemap(r.mainTheme, fragments[name].theme); ok {
+			stylesXML = remapStylesThemeReferences(stylesXML, remap)
+		}
+		fragmentStyles = append(fragmentStyles, fragmentStyleSet{name: name, stylesXML: stylesXML, conflicts: fragments[name].styleConflicts})
 	}
 	if len(fragmentStyles) == 0 {
 		return r.mainStylesXML
 	}
 
-	mergedStyles, err := mergeStyles(r.mainStylesXML, fragmentStyles...)
+	mergedXML, warnings, err := mergeStyles(r.mainStylesXML, policy, fragmentStyles...)
 	if err != nil {
+		opts.reportStyleMergeWarnings([]StyleMergeWarning{{
+			Fragment: strings.Join(names, ", "),
+			Message:  fmt.Sprintf("styles are not merged because the template's cannot be parsed: %v", err),
+		}})
 		return r.mainStylesXML
 	}
+	opts.reportStyleMergeWarnings(warnings)
 
 	r.cacheMu.Lock()
-	if cached, ok := r.mergedStylesCache[cacheKey]; ok {
-		r.cacheMu.Unlock()
-		return cached
+	if _, ok := r.mergedStylesCache[cacheKey]; !ok {
+		r.mergedStylesCache[cacheKey] = mergedStyles{xml: mergedXML, warnings: warnings}
 	}
-	r.mergedStylesCache[cacheKey] = mergedStyles
 	r.cacheMu.Unlock()
 
-	return mergedStyles
+	return mergedXML
 }
 
 func buildMergedStylesCacheKey(names []string, numbering *numberingContext) string {
@@ -1350,12 +1912,9 @@ func isNumberedWordPart(name, prefix string) bool {
 
 // wrapInDocumentXML wraps plain text content in minimal document XML structure
 func wrapInDocumentXML(content string) string {
-	// Escape XML special characters
-	content = strings.ReplaceAll(content, "&", "&amp;")
-	content = strings.ReplaceAll(content, "<", "&lt;")
-	content = strings.ReplaceAll(content, ">", "&gt;")
-	content = strings.ReplaceAll(content, "\"", "&quot;")
-	content = strings.ReplaceAll(content, "'", "&apos;")
+	// Line breaks are normalized as an XML parser would for raw text;
+	// escaped ones would otherwise keep their carriage returns.
+	content = escapeXMLText(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(content))
 
 	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
 <w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
@@ -1412,7 +1971,9 @@ func (t *template) Close() error {
 	t.docxReader = nil
 	t.source = nil
 
-	return nil
+	mapping := t.mapping
+	t.mapping = nil
+	return mapping.release()
 }
 
 // createSimpleDOCXBytes creates a minimal DOCX file with the given content
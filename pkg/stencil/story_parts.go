@@ -0,0 +1,418 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Story parts are the parts besides word/document.xml that hold paragraphs
+// and tables: headers, footers, footnotes, endnotes and the glossary
+// document with the building blocks of the template. They are rendered
+// with the same element renderer as the document body, so fragment-returning
+// functions such as pageBreak(), html() and xml() behave the same everywhere.
+
+// isNotesPartName reports whether name is the footnotes or endnotes part.
+func isNotesPartName(name string) bool {
+	return name == "word/footnotes.xml" || name == "word/endnotes.xml"
+}
+
+// isStoryPartName reports whether name is a header, footer, notes or
+// glossary part.
+func isStoryPartName(name string) bool {
+	return isHeaderPartName(name) || isFooterPartName(name) || isNotesPartName(name) || isGlossaryPartName(name)
+}
+
+// storyContainerDepth returns the depth of the elements whose paragraphs and
+// tables are rendered: the root of a header or footer, the w:footnote and
+// w:endnote elements below the root of a notes part, and the w:docPartBody
+// elements of the w:docPart elements of the glossary document.
+func storyContainerDepth(partName string) int {
+	switch {
+	case isNotesPartName(partName):
+		return 1
+	case isGlossaryPartName(partName):
+		return 3
+	}
+	return 0
+}
+
+// isStoryContainer reports whether start, an element at the container depth
+// of a story part, is a container. In the glossary document the w:docPartPr
+// siblings of the w:docPartBody elements are not.
+func isStoryContainer(partName string, start xml.StartElement) bool {
+	return !isGlossaryPartName(partName) || start.Name.Local == "docPartBody"
+}
+
+// renderStoryPart renders the template markers in a story part file.
+func renderStoryPart(file *zip.File, data TemplateData, ctx *renderContext) ([]byte, error) {
+	fr, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer fr.Close()
+
+	content, err := io.ReadAll(fr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+
+	// Parts without template markers are returned as-is to preserve all XML
+	// structure and namespaces
+	if !partHasPotentialTemplateMarkers(file.Name, content) {
+		return content, nil
+	}
+
+	if ctx != nil {
+		ctx.storyPart = file.Name
+		defer func() { ctx.storyPart = "" }()
+	}
+
+	var rendered bytes.Buffer
+	result, err := spliceStoryContainers(file.Name, content, func(elements []BodyElement) ([]byte, bool, error) {
+		if !elementsHavePotentialTemplateMarkers(elements) {
+			return nil, false, nil
+		}
+		chunk, err := renderStoryElements(elements, data, ctx)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to render elements in %s: %w", file.Name, err)
+		}
+		rendered.Write(chunk)
+		return chunk, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.checkEditableRegionsClosed(file.Name); err != nil {
+		return nil, err
+	}
+
+	var collected map[string]string
+	if ctx != nil {
+		collected = ctx.collectedNamespaces
+	}
+	return declareStoryNamespaces(result, rendered.Bytes(), collected), nil
+}
+
+// renderStoryElements renders and encodes the paragraphs and tables of a
+// story container in order.
+func renderStoryElements(elements []BodyElement, data TemplateData, ctx *renderContext) ([]byte, error) {
+	rendered, err := renderElementsWithContext(elements, data, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize rendered paragraphs so empty Word list items still render reliably.
+	normalizeRenderedBodyElements(rendered)
+	renumberSEQFieldsInElements(rendered)
+
+	return encodeStoryElements(rendered)
+}
+
+// encodeStoryElements encodes rendered paragraphs and tables in order.
+func encodeStoryElements(elements []BodyElement) ([]byte, error) {
+	rawXMLMap := make(map[string][]byte)
+	markerIndex := 0
+	var out bytes.Buffer
+	for _, elem := range elements {
+		prepareBodyElementRawXML(elem, rawXMLMap, &markerIndex)
+
+		var chunk []byte
+		var err error
+		switch e := elem.(type) {
+		case *Paragraph:
+			chunk, err = encodeXMLChunk(e, xml.StartElement{Name: xml.Name{Local: "w:p"}}, rawXMLMap)
+		case *Table:
+			chunk, err = encodeXMLChunk(e, xml.StartElement{Name: xml.Name{Local: "w:tbl"}}, rawXMLMap)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %T: %w", elem, err)
+		}
+		out.Write(chunk)
+	}
+	return out.Bytes(), nil
+}
+
+// spliceStoryContainers calls replace with the paragraphs and tables of each
+// container of a story part and substitutes the content of the container with
+// the bytes it returns. Block-level content controls (w:sdt), such as the
+// page number boxes Word puts in headers and footers, are kept: replace is
+// called separately for the blocks before and after each of them and for the
+// blocks of its w:sdtContent. Everything outside the replaced blocks,
+// including the XML declaration and namespace declarations, is copied
+// unchanged.
+func spliceStoryContainers(partName string, content []byte, replace func([]BodyElement) ([]byte, bool, error)) ([]byte, error) {
+	containerDepth := storyContainerDepth(partName)
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	splicer := &storySplicer{content: content, replace: replace}
+
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", partName, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth != containerDepth || !isStoryContainer(partName, t) {
+				depth++
+				continue
+			}
+			if err := splicer.spliceContainer(decoder); err != nil {
+				if splicer.replaceErr != nil {
+					return nil, splicer.replaceErr
+				}
+				return nil, fmt.Errorf("failed to parse %s: %w", partName, err)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	if splicer.copied == 0 {
+		return content, nil
+	}
+	splicer.out.Write(content[splicer.copied:])
+	return splicer.out.Bytes(), nil
+}
+
+// storySplicer substitutes the blocks of the containers of a story part.
+type storySplicer struct {
+	content []byte
+	replace func([]BodyElement) ([]byte, bool, error)
+	out     bytes.Buffer
+	// copied is the offset up to which content has been written to out
+	copied int
+	// replaceErr is the error returned by replace
+	replaceErr error
+}
+
+// spliceContainer reads the children of the element whose start tag the
+// decoder has just read, up to its end tag, and splices its blocks.
+// Elements other than paragraphs, tables and content controls are dropped
+// from blocks that are replaced, as in the document body.
+func (s *storySplicer) spliceContainer(decoder *xml.Decoder) error {
+	blockStart := int(decoder.InputOffset())
+	var elements []BodyElement
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				var para Paragraph
+				if err := decoder.DecodeElement(&para, &t); err != nil {
+					return err
+				}
+				elements = append(elements, &para)
+			case "tbl":
+				var table Table
+				if err := decoder.DecodeElement(&table, &t); err != nil {
+					return err
+				}
+				elements = append(elements, &table)
+			case "sdt":
+				if err := s.splice(blockStart, offset, elements); err != nil {
+					return err
+				}
+				elements = nil
+				if err := s.spliceContentControl(decoder); err != nil {
+					return err
+				}
+				blockStart = int(decoder.InputOffset())
+			default:
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			// The end tag of a self-closing container has no bytes of its own.
+			end := int(decoder.InputOffset())
+			innerEnd := bytes.LastIndex(s.content[blockStart:end], []byte("</"))
+			if innerEnd < 0 {
+				return nil
+			}
+			return s.splice(blockStart, blockStart+innerEnd, elements)
+		}
+	}
+}
+
+// spliceContentControl reads the children of the w:sdt element whose start
+// tag the decoder has just read and splices the blocks of its w:sdtContent.
+func (s *storySplicer) spliceContentControl(decoder *xml.Decoder) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "sdtContent" {
+				if err := s.spliceContainer(decoder); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := decoder.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// splice substitutes content[start:end] with what replace returns for
+// elements, if it returns anything.
+func (s *storySplicer) splice(start, end int, elements []BodyElement) error {
+	if len(elements) == 0 {
+		return nil
+	}
+	replacement, ok, err := s.replace(elements)
+	if err != nil {
+		s.replaceErr = err
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	s.out.Write(s.content[s.copied:start])
+	s.out.Write(replacement)
+	s.copied = end
+	return nil
+}
+
+// declareStoryNamespaces declares the namespace prefixes that rendered uses
+// but the root element of a story part does not declare, such as the wp and
+// pic prefixes of an image in an included fragment or xml() content. The
+// prefixes of included fragments take precedence over the well-known ones.
+func declareStoryNamespaces(content, rendered []byte, collected map[string]string) []byte {
+	if len(rendered) == 0 {
+		return content
+	}
+	root, _, rootEnd, ok := rootStartTag(content)
+	if !ok {
+		return content
+	}
+
+	namespaces := make(map[string]string, len(knownNamespacePrefixes)+len(collected))
+	for uri, prefix := range knownNamespacePrefixes {
+		namespaces[prefix] = uri
+	}
+	for prefix, uri := range collected {
+		namespaces[prefix] = uri
+	}
+
+	var declarations []string
+	for prefix, uri := range namespaces {
+		if prefix == "xml" || declaresNamespacePrefix(root, prefix) {
+			continue
+		}
+		if !bytes.Contains(rendered, []byte("<"+prefix+":")) && !bytes.Contains(rendered, []byte(" "+prefix+":")) {
+			continue
+		}
+		declarations = append(declarations, fmt.Sprintf(` xmlns:%s="%s"`, prefix, uri))
+	}
+	if len(declarations) == 0 {
+		return content
+	}
+	sort.Strings(declarations)
+
+	insertAt := rootEnd - 1
+	if content[insertAt-1] == '/' {
+		insertAt--
+	}
+	result := make([]byte, 0, len(content)+len(declarations)*64)
+	result = append(result, content[:insertAt]...)
+	result = append(result, strings.Join(declarations, "")...)
+	return append(result, content[insertAt:]...)
+}
+
+// rootStartTagBounds returns the byte range of the start tag of the root
+// element of content, or -1, -1 if there is none.
+func rootStartTagBounds(content []byte) (int, int) {
+	_, start, end, ok := rootStartTag(content)
+	if !ok {
+		return -1, -1
+	}
+	return start, end
+}
+
+// rootStartTag returns the start tag of the root element of content, with
+// the namespace prefixes of its name and attributes as written, and the
+// byte range of the tag. Processing instructions, comments and the document
+// type before the root element are skipped.
+func rootStartTag(content []byte) (tag xml.StartElement, start, end int, ok bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err != nil {
+			return xml.StartElement{}, -1, -1, false
+		}
+		if t, isStart := token.(xml.StartElement); isStart {
+			start := bytes.IndexByte(content[offset:], '<')
+			if start < 0 {
+				return xml.StartElement{}, -1, -1, false
+			}
+			return t.Copy(), int(offset) + start, int(decoder.InputOffset()), true
+		}
+	}
+}
+
+// declaresNamespacePrefix reports whether tag, as returned by rootStartTag,
+// declares prefix.
+func declaresNamespacePrefix(tag xml.StartElement, prefix string) bool {
+	for _, attr := range tag.Attr {
+		if attr.Name.Space == "xmlns" && attr.Name.Local == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeRawStartTag writes tag, as returned by rootStartTag, with the
+// namespace prefixes of its name and attributes as written.
+func encodeRawStartTag(out *bytes.Buffer, tag xml.StartElement) {
+	out.WriteString("<" + rawXMLName(tag.Name))
+	for _, attr := range tag.Attr {
+		out.WriteString(" " + rawXMLName(attr.Name) + `="`)
+		out.WriteString(escapeXMLText(attr.Value))
+		out.WriteString(`"`)
+	}
+	out.WriteString(">")
+}
+
+// rawXMLName returns name as written, with its namespace prefix.
+func rawXMLName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// storyPartHasPotentialTemplateMarkers reports whether any container of a
+// story part has template markers in its text.
+func storyPartHasPotentialTemplateMarkers(partName string, content []byte) bool {
+	found := false
+	_, err := spliceStoryContainers(partName, content, func(elements []BodyElement) ([]byte, bool, error) {
+		found = found || elementsHavePotentialTemplateMarkers(elements)
+		return nil, false, nil
+	})
+	return err != nil || found
+}
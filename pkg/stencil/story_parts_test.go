@@ -0,0 +1,126 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStoryPartsRenderFragments(t *testing.T) {
+	skipWithoutFunction(t, "html")
+	headerXML := validationHeaderXML(`
+<w:p><w:r><w:t xml:space="preserve">H {{html("&lt;b&gt;bold&lt;/b&gt;")}} {{name}}</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>{{html("&lt;i&gt;cell&lt;/i&gt;")}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+<w:p><w:r><w:t xml:space="preserve">after {{pageBreak()}}</w:t></w:r></w:p>`)
+	footnotesXML := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:footnotes xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+		`<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>` +
+		`<w:footnote w:id="1"><w:p><w:r><w:t xml:space="preserve">Note {{name}} {{html("&lt;b&gt;b&lt;/b&gt;")}}</w:t></w:r></w:p></w:footnote>` +
+		`</w:footnotes>`
+
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml":  validationDocumentXML(`<w:p><w:r><w:t>Body</w:t></w:r></w:p>`),
+		"word/header1.xml":   headerXML,
+		"word/footnotes.xml": footnotesXML,
+	})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"name": "Ada"}, RenderOptions{})
+
+	header := extractPartFromDOCX(t, output, "word/header1.xml")
+	assertInOrder(t, header,
+		`<w:t xml:space="preserve">H </w:t>`,
+		`<w:b/></w:rPr><w:t`,
+		`bold</w:t>`,
+		`Ada`,
+		`<w:tbl>`,
+		`<w:i/></w:rPr><w:t>cell</w:t>`,
+		`</w:tbl>`,
+		`after`,
+		`<w:br w:type="page">`,
+	)
+
+	footnotes := extractPartFromDOCX(t, output, "word/footnotes.xml")
+	assertInOrder(t, footnotes,
+		`<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>`,
+		`<w:footnote w:id="1">`,
+		`Note Ada`,
+		`<w:b/></w:rPr><w:t`,
+		`>b</w:t>`,
+	)
+	if strings.Contains(footnotes, "{{") {
+		t.Errorf("template markers left in footnotes: %s", footnotes)
+	}
+}
+
+func TestStoryPartsDeclareFragmentNamespaces(t *testing.T) {
+	content := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p/></w:hdr>`)
+	rendered := []byte(`<w:p><w:r><w:rPr><w14:ligatures w14:val="all"/></w:rPr><w:t>fi</w:t></w:r>` +
+		`<w:r><w:drawing><wp:inline><custom:shape/></wp:inline></w:drawing></w:r></w:p>`)
+	collected := map[string]string{"custom": "urn:example:custom"}
+
+	got := string(declareStoryNamespaces(content, rendered, collected))
+	wantRoot := `<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"` +
+		` xmlns:custom="urn:example:custom"` +
+		` xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml"` +
+		` xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">`
+	if !strings.Contains(got, wantRoot) {
+		t.Fatalf("expected root %s, got: %s", wantRoot, got)
+	}
+	decoder := xml.NewDecoder(strings.NewReader(got))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("part is not well-formed: %v\n%s", err, got)
+		}
+	}
+
+	if again := declareStoryNamespaces([]byte(got), rendered, collected); string(again) != got {
+		t.Errorf("expected declared prefixes not to be added twice: %s", again)
+	}
+	plain := []byte(`<w:p><w:r><w:t>text</w:t></w:r></w:p>`)
+	if unchanged := declareStoryNamespaces(content, plain, nil); string(unchanged) != string(content) {
+		t.Errorf("expected the part to be unchanged: %s", unchanged)
+	}
+}
+
+func TestStoryPartsKeepContentControls(t *testing.T) {
+	headerXML := validationHeaderXML(`
+<w:sdt><w:sdtPr><w:docPartObj><w:docPartGallery w:val="Page Numbers (Top of Page)"/></w:docPartObj></w:sdtPr>` +
+		`<w:sdtContent><w:p><w:r><w:t xml:space="preserve">Page {{pageLabel}} </w:t></w:r>` +
+		`<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText>PAGE</w:instrText></w:r>` +
+		`<w:r><w:fldChar w:fldCharType="end"/></w:r></w:p></w:sdtContent></w:sdt>
+<w:p><w:r><w:t>{{name}}</w:t></w:r></w:p>`)
+
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`<w:p><w:r><w:t>Body</w:t></w:r></w:p>`),
+		"word/header1.xml":  headerXML,
+	})
+	output := renderWithOptionsToBytes(t, docx, TemplateData{"name": "Ada", "pageLabel": "Seite"}, RenderOptions{})
+
+	header := extractPartFromDOCX(t, output, "word/header1.xml")
+	assertInOrder(t, header,
+		`<w:sdt><w:sdtPr><w:docPartObj>`,
+		`<w:sdtContent>`,
+		`Page Seite`,
+		`<w:instrText>PAGE</w:instrText>`,
+		`</w:sdtContent></w:sdt>`,
+		`Ada`,
+	)
+	if strings.Contains(header, "{{") {
+		t.Errorf("template markers left in header: %s", header)
+	}
+}
+
+func assertInOrder(t *testing.T, s string, fragments ...string) {
+	t.Helper()
+	offset := 0
+	for _, want := range fragments {
+		index := strings.Index(s[offset:], want)
+		if index < 0 {
+			t.Fatalf("expected %q after offset %d in: %s", want, offset, s)
+		}
+		offset += index + len(want)
+	}
+}
@@ -716,6 +716,73 @@ func TestJoinAndFunction(t *testing.T) {
 	}
 }
 
+func TestSentenceFunction(t *testing.T) {
+	tests := []struct {
+		name string
+		args []interface{}
+		want interface{}
+	}{
+		{
+			name: "sentence() with no parts",
+			args: []interface{}{},
+			want: "",
+		},
+		{
+			name: "sentence() with only empty parts",
+			args: []interface{}{"", nil, "  "},
+			want: "",
+		},
+		{
+			name: "sentence() with one part",
+			args: []interface{}{"the contract ends on 31 March"},
+			want: "The contract ends on 31 March.",
+		},
+		{
+			name: "sentence() with two parts",
+			args: []interface{}{"parking included", "pets allowed"},
+			want: "Parking included and pets allowed.",
+		},
+		{
+			name: "sentence() skips empty parts",
+			args: []interface{}{"the flat has a balcony", "", nil, "a garden ", "a garage,"},
+			want: "The flat has a balcony, a garden and a garage.",
+		},
+		{
+			name: "sentence() flattens collections",
+			args: []interface{}{[]string{"a balcony", "", "a garden"}, []interface{}{nil, "a garage"}},
+			want: "A balcony, a garden and a garage.",
+		},
+		{
+			name: "sentence() keeps final punctuation",
+			args: []interface{}{"is everything included?"},
+			want: "Is everything included?",
+		},
+		{
+			name: "sentence() formats other values",
+			args: []interface{}{"über uns", 3},
+			want: "Über uns and 3.",
+		},
+	}
+
+	registry := GetDefaultFunctionRegistry()
+	fn, exists := registry.GetFunction("sentence")
+	if !exists {
+		t.Fatal("sentence function not registered")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fn.Call(tt.args...)
+			if err != nil {
+				t.Fatalf("sentence() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("sentence() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestReplaceFunction(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,234 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// StyleConflictPolicy selects how a style of an included DOCX fragment is
+// merged into the rendered document when the template defines a style with
+// the same ID differently, such as a "Heading1" in another font.
+type StyleConflictPolicy int
+
+const (
+	// StyleConflictPreferMain keeps the template's definition, so the
+	// fragment's content takes on the look of the template. This is the
+	// default.
+	StyleConflictPreferMain StyleConflictPolicy = iota
+	// StyleConflictPreferFragment replaces the template's definition with
+	// the fragment's, which changes the template's content using the style
+	// too.
+	StyleConflictPreferFragment
+	// StyleConflictRename adds the fragment's style under its ID prefixed
+	// with the fragment name, such as "termsHeading1" for the "Heading1"
+	// of fragment "terms", and makes the fragment's content refer to it, so
+	// both keep their look.
+	StyleConflictRename
+)
+
+// String returns the name of the policy.
+func (p StyleConflictPolicy) String() string {
+	switch p {
+	case StyleConflictPreferMain:
+		return "prefer-main"
+	case StyleConflictPreferFragment:
+		return "prefer-fragment"
+	case StyleConflictRename:
+		return "rename"
+	default:
+		return fmt.Sprintf("StyleConflictPolicy(%d)", int(p))
+	}
+}
+
+// StyleMergeWarning describes a style of an included DOCX fragment that was
+// not merged into the rendered document as it is defined in the fragment,
+// or a fragment whose styles could not be merged at all.
+type StyleMergeWarning struct {
+	// Fragment is the name of the fragment.
+	Fragment string
+	// StyleID is the ID of the style, or empty when the styles of the
+	// fragment could not be read.
+	StyleID string
+	// RenamedTo is the ID the style was added under by
+	// StyleConflictRename.
+	RenamedTo string
+	// Message describes what happened to the style.
+	Message string
+}
+
+// String returns a description of the warning.
+func (w StyleMergeWarning) String() string {
+	return fmt.Sprintf("fragment %q: %s", w.Fragment, w.Message)
+}
+
+var (
+	styleReferenceRegex   = regexp.MustCompile(`(<w:(?:basedOn|next|link)\b[^>]*\bw:val=")([^"]*)(")`)
+	styleDisplayNameRegex = regexp.MustCompile(`(<w:name\b[^>]*\bw:val=")([^"]*)(")`)
+	styleWhitespace       = regexp.MustCompile(`>\s+<`)
+)
+
+// conflictingStyleIDs returns the IDs of the styles of fragmentStylesXML
+// that mainStylesXML defines differently.
+func conflictingStyleIDs(mainStylesXML, fragmentStylesXML []byte) map[string]bool {
+	if len(mainStylesXML) == 0 || len(fragmentStylesXML) == 0 {
+		return nil
+	}
+	mainStyles, err := parseStyles(mainStylesXML)
+	if err != nil {
+		return nil
+	}
+	fragmentStyles, err := parseStyles(fragmentStylesXML)
+	if err != nil {
+		return nil
+	}
+
+	definitions := make(map[string]DocumentStyle, len(mainStyles.Styles))
+	for _, style := range mainStyles.Styles {
+		definitions[style.StyleID] = style
+	}
+	var conflicts map[string]bool
+	for _, style := range fragmentStyles.Styles {
+		if existing, ok := definitions[style.StyleID]; ok && !sameStyleDefinition(existing, style) {
+			if conflicts == nil {
+				conflicts = make(map[string]bool)
+			}
+			conflicts[style.StyleID] = true
+		}
+	}
+	return conflicts
+}
+
+// sameStyleDefinition reports whether a and b define the same style,
+// ignoring the whitespace between elements.
+func sameStyleDefinition(a, b DocumentStyle) bool {
+	normalize := func(raw []byte) string {
+		return styleWhitespace.ReplaceAllString(strings.TrimSpace(string(raw)), "><")
+	}
+	return a.Type == b.Type && normalize(a.RawXML) == normalize(b.RawXML)
+}
+
+// fragmentStyleRenames returns the IDs StyleConflictRename adds the
+// conflicting styles of a fragment under, by their original ID.
+func fragmentStyleRenames(fragmentName string, conflicts map[string]bool) map[string]string {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	var prefix strings.Builder
+	for _, r := range fragmentName {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			prefix.WriteRune(r)
+		}
+	}
+	if prefix.Len() == 0 {
+		prefix.WriteString("Fragment")
+	}
+
+	renames := make(map[string]string, len(conflicts))
+	for styleID := range conflicts {
+		renames[styleID] = prefix.String() + styleID
+	}
+	return renames
+}
+
+// renamedStyle returns style with its ID, display name and references to
+// other styles changed as renames says.
+func renamedStyle(style DocumentStyle, fragmentName string, renames map[string]string) DocumentStyle {
+	raw := styleReferenceRegex.ReplaceAllStringFunc(string(style.RawXML), func(ref string) string {
+		parts := styleReferenceRegex.FindStringSubmatch(ref)
+		if renamed, ok := renames[parts[2]]; ok {
+			return parts[1] + renamed + parts[3]
+		}
+		return ref
+	})
+	if renamed, ok := renames[style.StyleID]; ok {
+		style.StyleID = renamed
+		raw = styleDisplayNameRegex.ReplaceAllString(raw, "${1}${2} ("+strings.ReplaceAll(escapeXMLText(fragmentName), "$", "$$")+")${3}")
+	}
+	style.RawXML = []byte(raw)
+	return style
+}
+
+// renameFragmentStyleReferences makes the paragraphs, runs and tables of
+// rendered fragment content refer to the styles renames says.
+func renameFragmentStyleReferences(elements []BodyElement, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	for _, element := range elements {
+		switch el := element.(type) {
+		case *Paragraph:
+			renameParagraphStyleReferences(el, renames)
+		case *Table:
+			if el.Properties != nil && el.Properties.Style != nil {
+				renameStyleReference(&el.Properties.Style.Val, renames)
+			}
+			for rowIdx := range el.Rows {
+				for cellIdx := range el.Rows[rowIdx].Cells {
+					for paraIdx := range el.Rows[rowIdx].Cells[cellIdx].Paragraphs {
+						renameParagraphStyleReferences(&el.Rows[rowIdx].Cells[cellIdx].Paragraphs[paraIdx], renames)
+					}
+				}
+			}
+		}
+	}
+}
+
+func renameParagraphStyleReferences(para *Paragraph, renames map[string]string) {
+	if para.Properties != nil {
+		if para.Properties.Style != nil {
+			renameStyleReference(&para.Properties.Style.Val, renames)
+		}
+		if para.Properties.RunProperties != nil && para.Properties.RunProperties.Style != nil {
+			renameStyleReference(&para.Properties.RunProperties.Style.Val, renames)
+		}
+	}
+
+	renameRun := func(run *Run) {
+		if run.Properties != nil && run.Properties.Style != nil {
+			renameStyleReference(&run.Properties.Style.Val, renames)
+		}
+	}
+	for idx := range para.Runs {
+		renameRun(&para.Runs[idx])
+	}
+	for idx := range para.Hyperlinks {
+		for runIdx := range para.Hyperlinks[idx].Runs {
+			renameRun(&para.Hyperlinks[idx].Runs[runIdx])
+		}
+	}
+	for _, content := range para.Content {
+		switch item := content.(type) {
+		case *Run:
+			renameRun(item)
+		case *Hyperlink:
+			for runIdx := range item.Runs {
+				renameRun(&item.Runs[runIdx])
+			}
+		}
+	}
+}
+
+// renameStyleReference changes the style ID at ref if renames renames it.
+func renameStyleReference(ref *string, renames map[string]string) {
+	if renamed, ok := renames[*ref]; ok {
+		*ref = renamed
+	}
+}
+
+// replaceStyleDefinition replaces the content and type of the style with
+// the ID of style in stylesXML by those of style, keeping its other
+// attributes, such as w:default.
+func replaceStyleDefinition(stylesXML string, style DocumentStyle) string {
+	pattern := regexp.MustCompile(`(?s)<w:style\b([^>]*?)\bw:styleId="` + regexp.QuoteMeta(style.StyleID) + `"([^>]*?)(?:/>|>.*?</w:style>)`)
+	loc := pattern.FindStringSubmatchIndex(stylesXML)
+	if loc == nil {
+		return stylesXML
+	}
+	typeAttr := regexp.MustCompile(`\bw:type="[^"]*"`)
+	before := typeAttr.ReplaceAllString(stylesXML[loc[2]:loc[3]], `w:type="`+style.Type+`"`)
+	after := typeAttr.ReplaceAllString(stylesXML[loc[4]:loc[5]], `w:type="`+style.Type+`"`)
+	replacement := `<w:style` + before + `w:styleId="` + style.StyleID + `"` + after + `>` + string(style.RawXML) + `</w:style>`
+	return stylesXML[:loc[0]] + replacement + stylesXML[loc[1]:]
+}
@@ -0,0 +1,152 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFragmentStyleConflictPolicies(t *testing.T) {
+	mainDoc := createDOCXWithCustomStylesAndBody(t, `
+<w:p>
+  <w:pPr><w:pStyle w:val="Heading1"/></w:pPr>
+  <w:r><w:t>Main heading</w:t></w:r>
+</w:p>
+<w:p>
+  <w:r><w:t>{{include "terms"}}</w:t></w:r>
+</w:p>`, `
+<w:style w:type="paragraph" w:default="1" w:styleId="Heading1">
+  <w:name w:val="heading 1"/>
+  <w:rPr><w:color w:val="000000"/></w:rPr>
+</w:style>
+<w:style w:type="paragraph" w:styleId="Quote">
+  <w:name w:val="Quote"/>
+  <w:rPr><w:i/></w:rPr>
+</w:style>`)
+
+	fragmentDoc := createDOCXWithCustomStylesAndBody(t, `
+<w:p>
+  <w:pPr><w:pStyle w:val="Heading1"/></w:pPr>
+  <w:r><w:t>Terms heading</w:t></w:r>
+</w:p>
+<w:p>
+  <w:pPr><w:pStyle w:val="TermsBody"/></w:pPr>
+  <w:r><w:t>Terms body</w:t></w:r>
+</w:p>`, `
+<w:style w:type="paragraph" w:styleId="Heading1">
+  <w:name w:val="heading 1"/>
+  <w:rPr><w:color w:val="FF0000"/></w:rPr>
+</w:style>
+<w:style w:type="paragraph" w:styleId="Quote">
+  <w:name w:val="Quote"/>
+  <w:rPr><w:i/></w:rPr>
+</w:style>
+<w:style w:type="paragraph" w:styleId="TermsBody">
+  <w:name w:val="Terms Body"/>
+  <w:basedOn w:val="Heading1"/>
+</w:style>`)
+
+	tmpl, err := Prepare(bytes.NewReader(mainDoc))
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("terms", fragmentDoc); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	render := func(policy StyleConflictPolicy) (styles, document string, warnings []StyleMergeWarning) {
+		t.Helper()
+		output := renderPreparedWithOptionsToBytes(t, tmpl, TemplateData{}, RenderOptions{
+			StyleConflicts:      policy,
+			OnStyleMergeWarning: func(w StyleMergeWarning) { warnings = append(warnings, w) },
+		})
+		return extractPartFromDOCX(t, output, "word/styles.xml"), extractDocumentXMLFromDOCX(t, output), warnings
+	}
+
+	t.Run("prefer main", func(t *testing.T) {
+		styles, document, warnings := render(StyleConflictPreferMain)
+		if strings.Contains(styles, "FF0000") || !strings.Contains(styles, "000000") {
+			t.Errorf("expected the template's Heading1 to be kept, got %s", styles)
+		}
+		if strings.Count(styles, `w:styleId="Heading1"`) != 1 || strings.Count(styles, `w:styleId="Quote"`) != 1 {
+			t.Errorf("expected every style ID once, got %s", styles)
+		}
+		if !strings.Contains(styles, `w:styleId="TermsBody"`) {
+			t.Errorf("expected the fragment's own style to be added, got %s", styles)
+		}
+		if strings.Count(document, `w:val="Heading1"`) != 2 {
+			t.Errorf("expected both headings to keep their style, got %s", document)
+		}
+		if len(warnings) != 1 || warnings[0].Fragment != "terms" || warnings[0].StyleID != "Heading1" || !strings.Contains(warnings[0].Message, "is kept") {
+			t.Errorf("expected a warning for Heading1 only, got %+v", warnings)
+		}
+	})
+
+	t.Run("prefer fragment", func(t *testing.T) {
+		styles, _, warnings := render(StyleConflictPreferFragment)
+		if !strings.Contains(styles, "FF0000") || strings.Contains(styles, "000000") {
+			t.Errorf("expected the fragment's Heading1 to replace the template's, got %s", styles)
+		}
+		if strings.Count(styles, `w:styleId="Heading1"`) != 1 || !strings.Contains(styles, `w:default="1"`) {
+			t.Errorf("expected Heading1 once with the template's attributes, got %s", styles)
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "is replaced") {
+			t.Errorf("expected a warning for the replaced style, got %+v", warnings)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		styles, document, warnings := render(StyleConflictRename)
+		if !strings.Contains(styles, `w:styleId="termsHeading1"`) || !strings.Contains(styles, `w:val="heading 1 (terms)"`) {
+			t.Errorf("expected the fragment's Heading1 to be added as termsHeading1, got %s", styles)
+		}
+		if !strings.Contains(styles, "FF0000") || !strings.Contains(styles, "000000") {
+			t.Errorf("expected both definitions of Heading1, got %s", styles)
+		}
+		if !strings.Contains(styles, `<w:basedOn w:val="termsHeading1"/>`) {
+			t.Errorf("expected TermsBody to be based on the renamed style, got %s", styles)
+		}
+		if strings.Count(document, `w:val="Heading1"`) != 1 || strings.Count(document, `w:val="termsHeading1"`) != 1 {
+			t.Errorf("expected only the fragment's heading to refer to the renamed style, got %s", document)
+		}
+		if len(warnings) != 1 || warnings[0].RenamedTo != "termsHeading1" {
+			t.Errorf("expected a warning naming the new style ID, got %+v", warnings)
+		}
+	})
+}
+
+func TestMergeStylesReportsUnreadableFragmentStyles(t *testing.T) {
+	mainStyles := []byte(`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:style w:type="paragraph" w:styleId="Normal"/></w:styles>`)
+
+	merged, warnings, err := mergeStyles(mainStyles, StyleConflictPreferMain, fragmentStyleSet{name: "broken", stylesXML: []byte("<w:styles")})
+	if err != nil {
+		t.Fatalf("mergeStyles failed: %v", err)
+	}
+	if string(merged) != string(mainStyles) {
+		t.Errorf("expected the main styles unchanged, got %s", merged)
+	}
+	if len(warnings) != 1 || warnings[0].Fragment != "broken" || warnings[0].StyleID != "" {
+		t.Errorf("expected a warning for the unreadable fragment styles, got %+v", warnings)
+	}
+}
+
+func TestMergeStylesReportsFragmentsDefiningAStyleDifferently(t *testing.T) {
+	mainStyles := []byte(`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"></w:styles>`)
+	first := []byte(`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:style w:type="paragraph" w:styleId="Note"><w:rPr><w:b/></w:rPr></w:style></w:styles>`)
+	second := []byte(`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:style w:type="paragraph" w:styleId="Note"><w:rPr><w:i/></w:rPr></w:style></w:styles>`)
+
+	merged, warnings, err := mergeStyles(mainStyles, StyleConflictPreferMain,
+		fragmentStyleSet{name: "a", stylesXML: first},
+		fragmentStyleSet{name: "b", stylesXML: second},
+		fragmentStyleSet{name: "c", stylesXML: first})
+	if err != nil {
+		t.Fatalf("mergeStyles failed: %v", err)
+	}
+	if strings.Count(string(merged), `w:styleId="Note"`) != 1 || !strings.Contains(string(merged), "<w:b/>") {
+		t.Errorf("expected the first fragment's Note only, got %s", merged)
+	}
+	if len(warnings) != 1 || warnings[0].Fragment != "b" || !strings.Contains(warnings[0].Message, `fragment "a"`) {
+		t.Errorf("expected a warning for fragment b only, got %+v", warnings)
+	}
+}
@@ -31,18 +31,30 @@ func parseStyles(stylesXML []byte) (*Styles, error) {
 	return &styles, nil
 }
 
+// fragmentStyleSet is the styles.xml of a fragment to merge, with the IDs
+// of its styles the main styles define differently.
+type fragmentStyleSet struct {
+	name      string
+	stylesXML []byte
+	conflicts map[string]bool
+}
+
 // mergeStyles merges fragment styles into the main styles.
 // It adds any styles from fragmentStyles that don't exist in mainStyles (by styleId).
 // This supports all style types (paragraph, character, table, numbering, etc.) to ensure
 // fragments can bring their own formatting while avoiding duplicate style definitions.
+// Styles the main styles define differently are merged as policy says, and
+// a warning is returned for each of them, for each style two fragments
+// define differently (the first fragment's definition is kept) and for each
+// fragment whose styles cannot be parsed.
 //
 // Originally designed to preserve table borders from fragments (see commit 6f6a439),
 // now extended to support all style types for complete fragment formatting support.
-func mergeStyles(mainStylesXML []byte, fragmentStylesXMLs ...[]byte) ([]byte, error) {
+func mergeStyles(mainStylesXML []byte, policy StyleConflictPolicy, fragments ...fragmentStyleSet) ([]byte, []StyleMergeWarning, error) {
 	// Parse main styles
 	mainStyles, err := parseStyles(mainStylesXML)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Create a map of existing style IDs
@@ -52,30 +64,90 @@ func mergeStyles(mainStylesXML []byte, fragmentStylesXMLs ...[]byte) ([]byte, er
 	}
 
 	// Collect new styles from fragments
-	var newStyles []DocumentStyle
-	for _, fragmentStylesXML := range fragmentStylesXMLs {
-		fragmentStyles, err := parseStyles(fragmentStylesXML)
+	var newStyles, replacedStyles []DocumentStyle
+	var warnings []StyleMergeWarning
+	added := make(map[string]fragmentStyle)
+	replaced := make(map[string]bool)
+	for _, fragment := range fragments {
+		fragmentStyles, err := parseStyles(fragment.stylesXML)
 		if err != nil {
-			// Skip if we can't parse
+			warnings = append(warnings, StyleMergeWarning{
+				Fragment: fragment.name,
+				Message:  fmt.Sprintf("styles are not merged: %v", err),
+			})
 			continue
 		}
 
+		var renames map[string]string
+		if policy == StyleConflictRename {
+			renames = fragmentStyleRenames(fragment.name, fragment.conflicts)
+		}
 		for _, style := range fragmentStyles.Styles {
-			// Add any style that doesn't already exist (regardless of type)
-			if !existingStyles[style.StyleID] {
+			styleID := style.StyleID
+			switch {
+			case fragment.conflicts[styleID]:
+				warning := StyleMergeWarning{Fragment: fragment.name, StyleID: styleID}
+				switch {
+				case policy == StyleConflictRename:
+					style = renamedStyle(style, fragment.name, renames)
+					warning.RenamedTo = style.StyleID
+					warning.Message = fmt.Sprintf("style %q differs from the template's and is added as %q", styleID, style.StyleID)
+					if !existingStyles[style.StyleID] {
+						newStyles = append(newStyles, style)
+						existingStyles[style.StyleID] = true
+					}
+				case policy == StyleConflictPreferFragment && !replaced[styleID]:
+					warning.Message = fmt.Sprintf("style %q differs from the template's, whose definition is replaced", styleID)
+					replacedStyles = append(replacedStyles, style)
+					replaced[styleID] = true
+				case policy == StyleConflictPreferFragment:
+					warning.Message = fmt.Sprintf("style %q differs from the template's, whose definition was replaced by another fragment's", styleID)
+				default:
+					warning.Message = fmt.Sprintf("style %q differs from the template's, whose definition is kept", styleID)
+				}
+				warnings = append(warnings, warning)
+			case !existingStyles[styleID]:
+				// Add any style that doesn't already exist (regardless of type)
+				if renames != nil {
+					style = renamedStyle(style, fragment.name, renames)
+				}
 				newStyles = append(newStyles, style)
-				existingStyles[style.StyleID] = true
+				existingStyles[styleID] = true
+				added[styleID] = fragmentStyle{fragment: fragment.name, style: style}
+			default:
+				if renames != nil {
+					style = renamedStyle(style, fragment.name, renames)
+				}
+				if first, ok := added[styleID]; ok && !sameStyleDefinition(first.style, style) {
+					warnings = append(warnings, StyleMergeWarning{
+						Fragment: fragment.name,
+						StyleID:  styleID,
+						Message:  fmt.Sprintf("style %q differs from the one of fragment %q, whose definition is kept", styleID, first.fragment),
+					})
+				}
 			}
 		}
 	}
 
 	// If no new styles, return original
-	if len(newStyles) == 0 {
-		return mainStylesXML, nil
+	if len(newStyles) == 0 && len(replacedStyles) == 0 {
+		return mainStylesXML, warnings, nil
+	}
+
+	for _, style := range replacedStyles {
+		mainStylesXML = []byte(replaceStyleDefinition(string(mainStylesXML), style))
 	}
 
 	// Rebuild the styles.xml with new styles added
-	return rebuildStylesXML(mainStylesXML, newStyles)
+	merged, err := rebuildStylesXML(mainStylesXML, newStyles)
+	return merged, warnings, err
+}
+
+// fragmentStyle is a style added to the merged styles and the fragment that
+// defined it.
+type fragmentStyle struct {
+	fragment string
+	style    DocumentStyle
 }
 
 // rebuildStylesXML adds new styles to the existing styles.xml
@@ -0,0 +1,122 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbols are rendered as w:sym runs, which name the font of the glyph
+// instead of relying on the font of the surrounding text, so status icons
+// look the same on every machine with the font installed. Wingdings ships
+// with Office on Windows and macOS.
+
+// defaultSymbolFont is the font of symbol() without a font argument. It
+// covers the Unicode symbol blocks, such as U+26A0 WARNING SIGN.
+const defaultSymbolFont = "Segoe UI Symbol"
+
+// symbolFonts are the fonts with a private character set, whose characters
+// Word addresses from F000 on.
+var symbolFonts = map[string]bool{
+	"Symbol": true, "Webdings": true, "Wingdings": true, "Wingdings 2": true, "Wingdings 3": true,
+}
+
+// iconSymbol is the glyph of a named icon.
+type iconSymbol struct {
+	Font string
+	Char int
+}
+
+// icons are the glyphs icon() renders by name.
+var icons = map[string]iconSymbol{
+	"check":      {"Wingdings", 0xF0FC},
+	"cross":      {"Wingdings", 0xF0FB},
+	"checkbox":   {"Wingdings", 0xF0A8},
+	"checkedBox": {"Wingdings", 0xF0FE},
+	"crossedBox": {"Wingdings", 0xF0FD},
+	"bullet":     {"Wingdings", 0xF06C},
+	"square":     {"Wingdings", 0xF06E},
+	"star":       {"Wingdings", 0xF0AB},
+	"flag":       {"Wingdings", 0xF04F},
+	"phone":      {"Wingdings", 0xF028},
+	"email":      {"Wingdings", 0xF02A},
+	"smiley":     {"Wingdings", 0xF04A},
+	"arrowLeft":  {"Wingdings", 0xF0E7},
+	"arrowRight": {"Wingdings", 0xF0E8},
+	"arrowUp":    {"Wingdings", 0xF0E9},
+	"arrowDown":  {"Wingdings", 0xF0EA},
+	"warning":    {defaultSymbolFont, 0x26A0},
+	"info":       {defaultSymbolFont, 0x2139},
+}
+
+// iconFunc implements icon(name).
+func iconFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	name, ok := args[0].(string)
+	icon, known := icons[name]
+	if !ok || !known {
+		names := make([]string, 0, len(icons))
+		for name := range icons {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("icon: unknown icon %v (must be one of %s)", args[0], strings.Join(names, ", "))
+	}
+	return &OOXMLFragment{Content: symbolRun(icon.Font, icon.Char)}, nil
+}
+
+// symbolFunc implements symbol(code[, font]). The code is a number, such
+// as 0x26A0, or a hexadecimal string such as "26A0" or "U+26A0". Codes
+// below 0x100 in a symbol font such as Wingdings address its characters.
+func symbolFunc(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	code, ok := toInt(args[0])
+	if s, isString := args[0].(string); isString {
+		s = strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "U+"), "0X")
+		parsed, err := strconv.ParseInt(s, 16, 32)
+		code, ok = int(parsed), err == nil
+	}
+	if !ok || code < 0x20 || code > 0xFFFF {
+		return nil, fmt.Errorf("symbol: code must be a character code from 0x20 to 0xFFFF, got %v", args[0])
+	}
+
+	font := defaultSymbolFont
+	if len(args) > 1 && args[1] != nil {
+		font = strings.TrimSpace(FormatValue(args[1]))
+		if font == "" {
+			return nil, fmt.Errorf("symbol: font cannot be empty")
+		}
+	}
+	if symbolFonts[font] && code < 0x100 {
+		code += 0xF000
+	}
+	return &OOXMLFragment{Content: symbolRun(font, code)}, nil
+}
+
+// symbolRun returns a run with the glyph char of font. Without formatting
+// of its own it takes the formatting of the expression.
+func symbolRun(font string, char int) *Run {
+	return &Run{
+		RawXML: []RawXMLElement{{
+			XMLName: xml.Name{Local: "sym"},
+			Content: []byte(fmt.Sprintf(`<w:sym w:font="%s" w:char="%04X"/>`, escapeXMLText(font), char)),
+		}},
+	}
+}
+
+// registerSymbolFunctions registers the icon() and symbol() functions
+func registerSymbolFunctions(registry *DefaultFunctionRegistry) {
+	// icon() function - inserts a named status icon
+	iconFn := NewSimpleFunction("icon", 1, 1, iconFunc)
+	registry.RegisterFunction(iconFn)
+
+	// symbol() function - inserts a character of a symbol font
+	symbolFn := NewSimpleFunction("symbol", 1, 2, symbolFunc)
+	registry.RegisterFunction(symbolFn)
+}
@@ -0,0 +1,43 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSymbolsInTemplate(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Status: {{icon("check")}} done`,
+		`{{symbol(0x26A0)}} {{symbol("U+2139")}} {{symbol(0xFC, "Wingdings")}} {{symbol(0x2713, "Arial Unicode MS")}}`,
+	})
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, TemplateData{}, RenderOptions{}))
+	assertInOrder(t, documentXML,
+		`Status: `,
+		`<w:sym w:font="Wingdings" w:char="F0FC"/>`,
+		` done`,
+		`<w:sym w:font="Segoe UI Symbol" w:char="26A0"/>`,
+		`<w:sym w:font="Segoe UI Symbol" w:char="2139"/>`,
+		`<w:sym w:font="Wingdings" w:char="F0FC"/>`,
+		`<w:sym w:font="Arial Unicode MS" w:char="2713"/>`,
+	)
+}
+
+func TestSymbolErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		call func() (interface{}, error)
+		want string
+	}{
+		{"unknown icon", func() (interface{}, error) { return iconFunc("unicorn") }, "icon: unknown icon unicorn (must be one of arrowDown"},
+		{"invalid code", func() (interface{}, error) { return symbolFunc("zz") }, "symbol: code must be a character code"},
+		{"code out of range", func() (interface{}, error) { return symbolFunc(0x1F600) }, "symbol: code must be a character code"},
+		{"empty font", func() (interface{}, error) { return symbolFunc(0x41, " ") }, "symbol: font cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.call(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TableMarker represents a marker for operations on a whole table
+type TableMarker struct {
+	Action string // "remove" for tableIf() with a false condition
+}
+
+// String returns the string representation of the marker for rendering
+func (m TableMarker) String() string {
+	return fmt.Sprintf("{{TABLE_MARKER:%s}}", m.Action)
+}
+
+// tableIf keeps the table containing the call when the condition is truthy
+// and marks it for removal otherwise
+func tableIf(args ...interface{}) (interface{}, error) {
+	if isTruthy(args[0]) {
+		return "", nil
+	}
+	return &TableMarker{Action: "remove"}, nil
+}
+
+// registerTableConditionalFunctions registers whole-table operation functions
+func registerTableConditionalFunctions(registry *DefaultFunctionRegistry) {
+	// tableIf() function - removes the enclosing table when the condition is false
+	tableIfFn := NewSimpleFunction("tableIf", 1, 1, tableIf)
+	registry.RegisterFunction(tableIfFn)
+}
+
+// ProcessTableMarkers removes tables that contain a table removal marker
+func ProcessTableMarkers(doc *Document) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	var newElements []BodyElement
+	for _, elem := range doc.Body.Elements {
+		if table, ok := elem.(*Table); ok && containsRemoveTableMarker(table) {
+			continue
+		}
+		newElements = append(newElements, elem)
+	}
+
+	doc.Body.Elements = newElements
+	return nil
+}
+
+// containsRemoveTableMarker checks if any cell of a table contains a removal marker
+func containsRemoveTableMarker(table *Table) bool {
+	if table == nil {
+		return false
+	}
+	for _, row := range table.Rows {
+		for _, cell := range row.Cells {
+			for _, para := range cell.Paragraphs {
+				for _, run := range para.Runs {
+					if run.Text != nil && strings.Contains(run.Text.Content, "TABLE_MARKER:remove") {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
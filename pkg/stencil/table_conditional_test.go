@@ -0,0 +1,74 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableIfFunction(t *testing.T) {
+	registry := GetDefaultFunctionRegistry()
+	fn, exists := registry.GetFunction("tableIf")
+	if !exists {
+		t.Fatalf("tableIf function not found in registry")
+	}
+
+	result, err := fn.Call(true)
+	if err != nil || result != "" {
+		t.Fatalf("expected empty string for true condition, got %v (%v)", result, err)
+	}
+
+	result, err = fn.Call(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if marker, ok := result.(*TableMarker); !ok || marker.Action != "remove" {
+		t.Fatalf("expected remove marker for false condition, got %#v", result)
+	}
+
+	if _, err := fn.Call(); err == nil {
+		t.Fatalf("expected error without a condition")
+	}
+}
+
+func TestTableIfInTemplate(t *testing.T) {
+	bodyXML := `<w:p><w:r><w:t>Before</w:t></w:r></w:p>` +
+		`<w:tbl><w:tr>` +
+		`<w:tc><w:p><w:r><w:t>{{tableIf(showItems)}}Item</w:t></w:r></w:p></w:tc>` +
+		`<w:tc><w:p><w:r><w:t>Price</w:t></w:r></w:p></w:tc>` +
+		`</w:tr><w:tr>` +
+		`<w:tc><w:p><w:r><w:t>Apple</w:t></w:r></w:p></w:tc>` +
+		`<w:tc><w:p><w:r><w:t>1.00</w:t></w:r></w:p></w:tc>` +
+		`</w:tr></w:tbl>` +
+		`<w:p><w:r><w:t>After</w:t></w:r></w:p>`
+	docx := createDOCXWithBodyXML(t, bodyXML)
+
+	tests := []struct {
+		name      string
+		showItems interface{}
+		wantTable bool
+	}{
+		{name: "true keeps table", showItems: true, wantTable: true},
+		{name: "false removes table", showItems: false, wantTable: false},
+		{name: "empty list removes table", showItems: []interface{}{}, wantTable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := renderWithOptionsToBytes(t, docx, TemplateData{"showItems": tt.showItems}, RenderOptions{})
+			documentXML := extractDocumentXMLFromDOCX(t, output)
+
+			if strings.Contains(documentXML, "TABLE_MARKER") {
+				t.Fatalf("marker left in output: %s", documentXML)
+			}
+			if got := strings.Contains(documentXML, "<w:tbl>"); got != tt.wantTable {
+				t.Fatalf("expected table present = %v, got %s", tt.wantTable, documentXML)
+			}
+			if tt.wantTable && !strings.Contains(documentXML, ">Item<") {
+				t.Fatalf("expected header text without marker, got %s", documentXML)
+			}
+			if !strings.Contains(documentXML, ">Before<") || !strings.Contains(documentXML, ">After<") {
+				t.Fatalf("expected surrounding paragraphs to be kept, got %s", documentXML)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package stencil
+
+import "fmt"
+
+// defaultContinuationCaption is the caption of TableContinuation when none
+// is set.
+const defaultContinuationCaption = "(continued)"
+
+// TableContinuation splits long tables into pages with a fixed number of
+// rows. Each page repeats the header rows of the table, and every page but
+// the last ends with a caption row such as "(continued)". Word cannot tell
+// where a table breaks, so the rows per page are an estimate that forces the
+// breaks: choose a value that fits the page with the tallest rows expected.
+type TableContinuation struct {
+	// RowsPerPage is the number of rows below the header rows on each page.
+	RowsPerPage int
+	// Caption is the text of the caption row, "(continued)" by default.
+	Caption string
+}
+
+// applyTableContinuation splits the tables of body that have more than
+// RowsPerPage rows below their header rows. The header rows are the leading
+// rows marked as repeating header rows, or the first row if there are none.
+// The pages are separate tables, divided by page breaks.
+func applyTableContinuation(body *Body, continuation *TableContinuation) error {
+	if body == nil || continuation == nil {
+		return nil
+	}
+	if continuation.RowsPerPage < 1 {
+		return fmt.Errorf("table continuation: rows per page must be at least 1, got %d", continuation.RowsPerPage)
+	}
+	caption := continuation.Caption
+	if caption == "" {
+		caption = defaultContinuationCaption
+	}
+
+	elements := make([]BodyElement, 0, len(body.Elements))
+	for _, elem := range body.Elements {
+		table, ok := elem.(*Table)
+		if !ok {
+			elements = append(elements, elem)
+			continue
+		}
+		headerRows := tableHeaderRowCount(table)
+		rows := table.Rows[headerRows:]
+		if len(rows) <= continuation.RowsPerPage {
+			elements = append(elements, elem)
+			continue
+		}
+
+		for start := 0; start < len(rows); start += continuation.RowsPerPage {
+			end := start + continuation.RowsPerPage
+			page := &Table{Properties: table.Properties, Grid: table.Grid}
+			page.Rows = append(page.Rows, table.Rows[:headerRows]...)
+			if end >= len(rows) {
+				page.Rows = append(page.Rows, rows[start:]...)
+				elements = append(elements, page)
+				break
+			}
+			page.Rows = append(page.Rows, rows[start:end]...)
+			page.Rows = append(page.Rows, continuationCaptionRow(table, caption))
+			elements = append(elements, page, &Paragraph{
+				Runs: []Run{{Break: &Break{Type: "page"}}},
+			})
+		}
+	}
+	body.Elements = elements
+	return nil
+}
+
+// tableHeaderRowCount returns the number of leading repeating header rows
+// of table, or 1 when it has none and more than one row.
+func tableHeaderRowCount(table *Table) int {
+	count := 0
+	for count < len(table.Rows) && table.Rows[count].Properties != nil && table.Rows[count].Properties.TableHeader {
+		count++
+	}
+	if count == 0 && len(table.Rows) > 1 {
+		count = 1
+	}
+	return count
+}
+
+// continuationCaptionRow returns a row with one right-aligned italic cell
+// spanning the whole table.
+func continuationCaptionRow(table *Table, caption string) TableRow {
+	columns := 0
+	if table.Grid != nil {
+		columns = len(table.Grid.Columns)
+	}
+	if columns == 0 && len(table.Rows) > 0 {
+		for _, span := range tableRowLayout(&table.Rows[0]) {
+			columns += span
+		}
+	}
+
+	cell := TableCell{
+		Paragraphs: []Paragraph{{
+			Properties: &ParagraphProperties{Alignment: &Alignment{Val: "right"}},
+			Runs: []Run{{
+				Properties: &RunProperties{Italic: &Empty{}},
+				Text:       &Text{Content: caption, Space: "preserve"},
+			}},
+		}},
+	}
+	if columns > 1 {
+		cell.Properties = &TableCellProperties{GridSpan: &GridSpan{Val: columns}}
+	}
+	return TableRow{Properties: &TableRowProperties{CantSplit: true}, Cells: []TableCell{cell}}
+}
@@ -0,0 +1,54 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTableContinuation(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:tbl><w:tblGrid><w:gridCol w:w="3000"/><w:gridCol w:w="2000"/></w:tblGrid>`+
+		`<w:tr><w:trPr><w:tblHeader/></w:trPr><w:tc><w:p><w:r><w:t>Item</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Qty</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{item}}</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`</w:tbl><w:p><w:r><w:t>After</w:t></w:r></w:p>`)
+	data := TemplateData{"items": []interface{}{"a", "b", "c", "d", "e"}}
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	if got := strings.Count(documentXML, "<w:tbl>"); got != 1 {
+		t.Fatalf("expected one table without the option, got %d", got)
+	}
+	if !strings.Contains(documentXML, "<w:tblHeader>") {
+		t.Errorf("repeating header row lost: %s", documentXML)
+	}
+
+	documentXML = extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{
+		TableContinuation: &TableContinuation{RowsPerPage: 2, Caption: "(Fortsetzung)"},
+	}))
+	if got := strings.Count(documentXML, "<w:tbl>"); got != 3 {
+		t.Fatalf("expected 3 pages, got %d tables: %s", got, documentXML)
+	}
+	if got := strings.Count(documentXML, ">Item<"); got != 3 {
+		t.Errorf("expected the header on every page, got %d", got)
+	}
+	if got := strings.Count(documentXML, `<w:br w:type="page"`); got != 2 {
+		t.Errorf("expected 2 page breaks, got %d", got)
+	}
+	if got := strings.Count(documentXML, "(Fortsetzung)"); got != 2 {
+		t.Errorf("expected a caption on all pages but the last, got %d", got)
+	}
+	assertInOrder(t, documentXML, ">a<", ">b<", "(Fortsetzung)", ">c<", ">d<", "(Fortsetzung)", ">e<", "After")
+	if !strings.Contains(documentXML, `<w:gridSpan w:val="2">`) {
+		t.Errorf("caption row does not span the table: %s", documentXML)
+	}
+
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.RenderWithOptions(data, RenderOptions{TableContinuation: &TableContinuation{}}); err == nil {
+		t.Error("expected an error without rows per page")
+	}
+}
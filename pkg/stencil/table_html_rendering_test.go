@@ -1,3 +1,5 @@
+//go:build !stencil_nohtml && !stencil_lite
+
 package stencil
 
 import (
@@ -1,12 +1,13 @@
 package stencil
 
 import (
+	"strconv"
 	"strings"
 )
 
 // TableRowMarker represents a marker for table row operations
 type TableRowMarker struct {
-	Action string // "hide" for hideRow()
+	Action string // "hide" for hideRow(), "keepTogether" for keepRowTogether(), "number" for rowNumber()
 }
 
 // registerTableRowFunctions registers table row operation functions
@@ -17,6 +18,18 @@ func registerTableRowFunctions(registry *DefaultFunctionRegistry) {
 		return &TableRowMarker{Action: "hide"}, nil
 	})
 	registry.RegisterFunction(hideRowFn)
+
+	// keepRowTogether() function - prevents a table row from splitting across pages
+	keepRowTogetherFn := NewSimpleFunction("keepRowTogether", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &TableRowMarker{Action: "keepTogether"}, nil
+	})
+	registry.RegisterFunction(keepRowTogetherFn)
+
+	// rowNumber() function - numbers the generated rows of a table
+	rowNumberFn := NewSimpleFunction("rowNumber", 0, 0, func(args ...interface{}) (interface{}, error) {
+		return &TableRowMarker{Action: "number"}, nil
+	})
+	registry.RegisterFunction(rowNumberFn)
 }
 
 // ProcessTableRowMarkers processes table row markers in a document and removes marked rows
@@ -84,6 +97,9 @@ func processTableRowMarkersInTable(table *Table) (*Table, error) {
 		
 		// This row is not hidden, so we keep it
 		rowCopy := row
+		if stripKeepRowTogetherMarkers(&rowCopy) {
+			keepRowTogether(&rowCopy)
+		}
 		
 		// If this is the first row we're keeping and the original first row was hidden,
 		// we might need to add the top border
@@ -137,4 +153,123 @@ func containsTableRowMarkerPlaceholder(text string) bool {
 	// when a TableRowMarker is rendered
 	// This will be coordinated with the rendering logic
 	return strings.Contains(text, "TABLE_ROW_MARKER:hide")
-}
\ No newline at end of file
+}
+// keepRowTogetherMarkerPlaceholder is the rendered form of keepRowTogether()
+const keepRowTogetherMarkerPlaceholder = "{{TABLE_ROW_MARKER:keepTogether}}"
+
+// keepRowTogether sets w:cantSplit on a row. The row properties may be
+// shared with the template, so they are copied before modification.
+func keepRowTogether(row *TableRow) {
+	props := &TableRowProperties{}
+	if row.Properties != nil {
+		copied := *row.Properties
+		props = &copied
+	}
+	props.CantSplit = true
+	row.Properties = props
+}
+
+// stripKeepRowTogetherMarkers removes keepRowTogether() markers from the
+// cells of a row and reports whether any were found. The cells are copied
+// before modification.
+func stripKeepRowTogetherMarkers(row *TableRow) bool {
+	return replaceTableRowPlaceholder(row, keepRowTogetherMarkerPlaceholder, "")
+}
+
+// replaceTableRowPlaceholder replaces a marker placeholder in the cells of
+// a row and reports whether any were found. Runs left empty by removing the
+// placeholder are dropped. The cells are copied before modification.
+func replaceTableRowPlaceholder(row *TableRow, placeholder, replacement string) bool {
+	found := false
+	for i := range row.Cells {
+		for j := range row.Cells[i].Paragraphs {
+			para := &row.Cells[i].Paragraphs[j]
+			if !strings.Contains(para.GetText(), placeholder) {
+				continue
+			}
+			if !found {
+				row.Cells = append([]TableCell(nil), row.Cells...)
+				found = true
+			}
+			cell := &row.Cells[i]
+			cell.Paragraphs = append([]Paragraph(nil), cell.Paragraphs...)
+			para = &cell.Paragraphs[j]
+
+			runs := make([]Run, len(para.Runs))
+			copy(runs, para.Runs)
+			for k := range runs {
+				replaceRunPlaceholder(&runs[k], placeholder, replacement)
+			}
+			para.Runs = runs
+
+			if para.Content != nil {
+				content := make([]ParagraphContent, 0, len(para.Content))
+				for _, item := range para.Content {
+					if run, ok := item.(*Run); ok {
+						runCopy := *run
+						if replaceRunPlaceholder(&runCopy, placeholder, replacement) && runCopy.Text.Content == "" && runCopy.Break == nil && len(runCopy.RawXML) == 0 {
+							continue
+						}
+						item = &runCopy
+					}
+					content = append(content, item)
+				}
+				para.Content = content
+			}
+		}
+	}
+	return found
+}
+
+// replaceRunPlaceholder replaces a marker placeholder in the text of run and
+// reports whether any were found.
+func replaceRunPlaceholder(run *Run, placeholder, replacement string) bool {
+	if run.Text == nil || !strings.Contains(run.Text.Content, placeholder) {
+		return false
+	}
+	text := *run.Text
+	text.Content = strings.ReplaceAll(text.Content, placeholder, replacement)
+	run.Text = &text
+	return true
+}
+
+// rowNumberMarkerPlaceholder is the rendered form of rowNumber()
+const rowNumberMarkerPlaceholder = "{{TABLE_ROW_MARKER:number}}"
+
+// ProcessTableRowNumbers replaces rowNumber() markers with the 1-based
+// position of their row among the rows of the table that call rowNumber().
+// It runs after rows are hidden and sorted, so the numbers have no gaps.
+func ProcessTableRowNumbers(doc *Document) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	for _, elem := range doc.Body.Elements {
+		table, ok := elem.(*Table)
+		if !ok {
+			continue
+		}
+		number := 0
+		for i := range table.Rows {
+			row := &table.Rows[i]
+			if !tableRowContainsText(row, rowNumberMarkerPlaceholder) {
+				continue
+			}
+			number++
+			replaceTableRowPlaceholder(row, rowNumberMarkerPlaceholder, strconv.Itoa(number))
+		}
+	}
+	return nil
+}
+
+// tableRowContainsText reports whether the text of a cell of row contains s
+func tableRowContainsText(row *TableRow, s string) bool {
+	for i := range row.Cells {
+		for j := range row.Cells[i].Paragraphs {
+			if strings.Contains(row.Cells[i].Paragraphs[j].GetText(), s) {
+				return true
+			}
+		}
+	}
+	return false
+}
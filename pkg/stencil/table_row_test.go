@@ -1,6 +1,7 @@
 package stencil
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -280,3 +281,55 @@ func TestProcessTableRowMarkers(t *testing.T) {
 		})
 	}
 }
+
+func TestKeepRowTogether(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:tbl>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>Header</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:trPr><w:trHeight w:val="400"/></w:trPr><w:tc><w:p><w:r><w:t>{{item}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t xml:space="preserve">{{keepRowTogether()}}Total</w:t></w:r></w:p></w:tc></w:tr>`+
+		`</w:tbl>`)
+	data := TemplateData{"items": []interface{}{"first", "second"}}
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	if strings.Contains(documentXML, "TABLE_ROW_MARKER") {
+		t.Fatalf("row markers left in output: %s", documentXML)
+	}
+	if got := strings.Count(documentXML, "<w:cantSplit>"); got != 1 {
+		t.Errorf("expected only the marked row to be kept together, got %d: %s", got, documentXML)
+	}
+	assertInOrder(t, documentXML, "first", "second", "<w:cantSplit>", "Total")
+
+	documentXML = extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{KeepLoopRowsTogether: true}))
+	if got := strings.Count(documentXML, "<w:cantSplit>"); got != 3 {
+		t.Errorf("expected both loop rows and the marked row to be kept together, got %d: %s", got, documentXML)
+	}
+	if got := strings.Count(documentXML, `<w:trHeight w:val="400">`); got != 2 {
+		t.Errorf("expected the row height to be preserved, got %d: %s", got, documentXML)
+	}
+	assertInOrder(t, documentXML, "Header", "<w:cantSplit>", "first", "<w:cantSplit>", "second", "<w:cantSplit>", "Total")
+}
+
+func TestRowNumber(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:tbl>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>No.</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Item{{sortTable()}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t xml:space="preserve">{{if item.hidden}}{{hideRow()}}{{end}}{{rowNumber()}}.</w:t></w:r></w:p></w:tc>`+
+		`<w:tc><w:p><w:r><w:t>{{item.name}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>{{end}}</w:t></w:r></w:p></w:tc></w:tr>`+
+		`<w:tr><w:tc><w:p><w:r><w:t>Total</w:t></w:r></w:p></w:tc></w:tr>`+
+		`</w:tbl>`)
+	data := TemplateData{"items": []interface{}{
+		map[string]interface{}{"name": "pear"},
+		map[string]interface{}{"name": "fig", "hidden": true},
+		map[string]interface{}{"name": "apple"},
+		map[string]interface{}{"name": "kiwi"},
+	}}
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderWithOptionsToBytes(t, docx, data, RenderOptions{}))
+	if strings.Contains(documentXML, "TABLE_ROW_MARKER") || strings.Contains(documentXML, ">fig<") {
+		t.Fatalf("row markers or hidden row left in output: %s", documentXML)
+	}
+	assertInOrder(t, documentXML, "No.", ">1<", ">apple<", ">2<", ">kiwi<", ">3<", ">pear<", "Total")
+}
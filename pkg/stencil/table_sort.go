@@ -0,0 +1,300 @@
+package stencil
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TableSortMarker represents a sortTable() call in a table
+type TableSortMarker struct {
+	Column      string // header text of the sort column; empty when ColumnIndex is used
+	ColumnIndex int    // 0-based sort column, or -1 for the column containing the call
+	Descending  bool
+}
+
+// String returns the string representation of the marker for rendering
+func (m TableSortMarker) String() string {
+	order := "asc"
+	if m.Descending {
+		order = "desc"
+	}
+	if m.Column != "" {
+		return fmt.Sprintf("{{TABLE_SORT_MARKER:%s:%s}}", order, strconv.Quote(m.Column))
+	}
+	return fmt.Sprintf("{{TABLE_SORT_MARKER:%s:%d}}", order, m.ColumnIndex)
+}
+
+// tableSortMarkerRegex matches the rendered form of a TableSortMarker
+var tableSortMarkerRegex = regexp.MustCompile(`\{\{TABLE_SORT_MARKER:(asc|desc):(-?\d+|"(?:[^"\\]|\\.)*")\}\}`)
+
+// sortTable marks the containing table for sorting its rows below the row
+// containing the call. The column is the header text of a column or its
+// 0-based index; without it the column containing the call is used.
+func sortTable(args ...interface{}) (interface{}, error) {
+	marker := TableSortMarker{ColumnIndex: -1}
+
+	if len(args) >= 1 {
+		switch v := args[0].(type) {
+		case nil:
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return nil, fmt.Errorf("sortTable: column name must not be empty")
+			}
+			marker.Column = strings.TrimSpace(v)
+		default:
+			index, ok := toFloat64(v)
+			if !ok || index < 0 || index != float64(int(index)) {
+				return nil, fmt.Errorf("sortTable: column must be a header text or a non-negative integer, got %v", v)
+			}
+			marker.ColumnIndex = int(index)
+		}
+	}
+
+	if len(args) == 2 {
+		order, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("sortTable: order must be a string, got %T", args[1])
+		}
+		switch strings.ToLower(order) {
+		case "asc":
+		case "desc":
+			marker.Descending = true
+		default:
+			return nil, fmt.Errorf("sortTable: invalid order '%s' (must be 'asc' or 'desc')", order)
+		}
+	}
+
+	return &marker, nil
+}
+
+// registerTableSortFunctions registers table sorting functions
+func registerTableSortFunctions(registry *DefaultFunctionRegistry) {
+	// sortTable() function - sorts the rendered rows of the containing table
+	sortTableFn := NewSimpleFunction("sortTable", 0, 2, sortTable)
+	registry.RegisterFunction(sortTableFn)
+}
+
+// ProcessTableSortMarkers sorts the rows of tables that contain a sort marker
+func ProcessTableSortMarkers(doc *Document) error {
+	return processTableSortMarkers(doc, "")
+}
+
+// processTableSortMarkers sorts the rows of tables that contain a sort
+// marker, text in the collation of locale or the root collation.
+func processTableSortMarkers(doc *Document, locale string) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	for _, elem := range doc.Body.Elements {
+		if table, ok := elem.(*Table); ok {
+			if err := sortTableRows(table, locale); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortTableRows sorts the rows below the first row containing a sort marker
+// by the text of the sort column, up to the first row with other cells. Numbers sort numerically and before text;
+// text sorts case-insensitively, in the collation of locale or the root
+// collation without one. Rows with equal keys keep their order.
+func sortTableRows(table *Table, locale string) error {
+	markerRow := -1
+	var marker TableSortMarker
+	for i := range table.Rows {
+		found, column, ok := stripTableSortMarkers(&table.Rows[i])
+		if ok && markerRow == -1 {
+			markerRow, marker = i, found
+			if marker.Column == "" && marker.ColumnIndex == -1 {
+				marker.ColumnIndex = column
+			}
+		}
+	}
+	if markerRow == -1 {
+		return nil
+	}
+
+	column := marker.ColumnIndex
+	header := table.Rows[markerRow]
+	if marker.Column != "" {
+		column = -1
+		for _, cell := range tableRowGridCells(&header) {
+			if strings.EqualFold(strings.TrimSpace(cell.text), marker.Column) {
+				column = cell.column
+				break
+			}
+		}
+		if column == -1 {
+			return fmt.Errorf("sortTable: column %q not found in the header row", marker.Column)
+		}
+	}
+
+	// Sort the rows laid out like the first row below the header, so that
+	// a differently laid out totals row stays last.
+	end := markerRow + 1
+	if end < len(table.Rows) {
+		layout := tableRowLayout(&table.Rows[end])
+		for end < len(table.Rows) && slices.Equal(tableRowLayout(&table.Rows[end]), layout) {
+			end++
+		}
+	}
+	rows := table.Rows[markerRow+1 : end]
+	keys := make([]string, len(rows))
+	for i := range rows {
+		for _, cell := range tableRowGridCells(&rows[i]) {
+			if cell.column == column {
+				keys[i] = strings.TrimSpace(cell.text)
+			}
+		}
+	}
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		result := compareTableSortKeys(keys[a], keys[b], locale)
+		if marker.Descending {
+			return -result
+		}
+		return result
+	})
+
+	sorted := make([]TableRow, len(rows))
+	for i, index := range order {
+		sorted[i] = rows[index]
+	}
+	copy(rows, sorted)
+	return nil
+}
+
+// tableGridCell is a cell of a row with the grid column it starts in
+type tableGridCell struct {
+	column int
+	text   string
+}
+
+func tableRowGridCells(row *TableRow) []tableGridCell {
+	cells := make([]tableGridCell, 0, len(row.Cells))
+	column := 0
+	for i := range row.Cells {
+		cells = append(cells, tableGridCell{column: column, text: row.Cells[i].GetText()})
+		column += getCellGridSpan(&row.Cells[i])
+	}
+	return cells
+}
+
+// tableRowLayout returns the grid spans of the cells of row
+func tableRowLayout(row *TableRow) []int {
+	spans := make([]int, len(row.Cells))
+	for i := range row.Cells {
+		spans[i] = getCellGridSpan(&row.Cells[i])
+	}
+	return spans
+}
+
+// compareTableSortKeys orders numbers numerically before text, and text
+// case-insensitively, in the collation of locale or the root collation.
+func compareTableSortKeys(a, b, locale string) int {
+	aNumber, aOK := parseTableSortNumber(a)
+	bNumber, bOK := parseTableSortNumber(b)
+	switch {
+	case aOK && bOK:
+		return cmp.Compare(aNumber, bNumber)
+	case aOK:
+		return -1
+	case bOK:
+		return 1
+	}
+	return compareCollated(strings.ToLower(a), strings.ToLower(b), locale)
+}
+
+// parseTableSortNumber parses a cell text as a number, allowing comma
+// thousands separators.
+func parseTableSortNumber(text string) (float64, bool) {
+	if text == "" {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", ""), 64)
+	return number, err == nil
+}
+
+// stripTableSortMarkers removes sort markers from the cells of a row. It
+// returns the first marker found and the grid column of its cell. The
+// cells are copied before modification.
+func stripTableSortMarkers(row *TableRow) (TableSortMarker, int, bool) {
+	var marker TableSortMarker
+	markerColumn := -1
+	found := false
+	column := 0
+	for i := range row.Cells {
+		span := getCellGridSpan(&row.Cells[i])
+		for j := range row.Cells[i].Paragraphs {
+			para := &row.Cells[i].Paragraphs[j]
+			match := tableSortMarkerRegex.FindStringSubmatch(para.GetText())
+			if match == nil {
+				continue
+			}
+			if !found {
+				marker = parseTableSortMarker(match)
+				markerColumn = column
+				row.Cells = append([]TableCell(nil), row.Cells...)
+				found = true
+			}
+			cell := &row.Cells[i]
+			cell.Paragraphs = append([]Paragraph(nil), cell.Paragraphs...)
+			para = &cell.Paragraphs[j]
+
+			runs := make([]Run, len(para.Runs))
+			copy(runs, para.Runs)
+			for k := range runs {
+				stripTableSortMarker(&runs[k])
+			}
+			para.Runs = runs
+
+			if para.Content != nil {
+				content := make([]ParagraphContent, 0, len(para.Content))
+				for _, item := range para.Content {
+					if run, ok := item.(*Run); ok {
+						runCopy := *run
+						if stripTableSortMarker(&runCopy) && runCopy.Text.Content == "" && runCopy.Break == nil && len(runCopy.RawXML) == 0 {
+							continue
+						}
+						item = &runCopy
+					}
+					content = append(content, item)
+				}
+				para.Content = content
+			}
+		}
+		column += span
+	}
+	return marker, markerColumn, found
+}
+
+func parseTableSortMarker(match []string) TableSortMarker {
+	marker := TableSortMarker{Descending: match[1] == "desc"}
+	if column, err := strconv.Unquote(match[2]); err == nil {
+		marker.Column = column
+	} else {
+		marker.ColumnIndex, _ = strconv.Atoi(match[2])
+	}
+	return marker
+}
+
+// stripTableSortMarker removes sort markers from the text of run and
+// reports whether any were found.
+func stripTableSortMarker(run *Run) bool {
+	if run.Text == nil || !tableSortMarkerRegex.MatchString(run.Text.Content) {
+		return false
+	}
+	text := *run.Text
+	text.Content = tableSortMarkerRegex.ReplaceAllString(text.Content, "")
+	run.Text = &text
+	return true
+}
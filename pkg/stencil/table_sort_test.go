@@ -0,0 +1,108 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSortTableFunction(t *testing.T) {
+	registry := GetDefaultFunctionRegistry()
+	fn, exists := registry.GetFunction("sortTable")
+	if !exists {
+		t.Fatalf("sortTable function not found in registry")
+	}
+
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{nil, "{{TABLE_SORT_MARKER:asc:-1}}"},
+		{[]interface{}{"Price", "DESC"}, `{{TABLE_SORT_MARKER:desc:"Price"}}`},
+		{[]interface{}{2}, "{{TABLE_SORT_MARKER:asc:2}}"},
+	}
+	for _, tt := range tests {
+		result, err := fn.Call(tt.args...)
+		if err != nil {
+			t.Fatalf("sortTable(%v) failed: %v", tt.args, err)
+		}
+		if got := result.(*TableSortMarker).String(); got != tt.want {
+			t.Errorf("sortTable(%v) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, args := range [][]interface{}{{-1}, {1.5}, {""}, {"Price", "up"}, {"Price", 1}} {
+		if _, err := fn.Call(args...); err == nil {
+			t.Errorf("sortTable(%v): expected an error", args)
+		}
+	}
+}
+
+func TestSortTableInTemplate(t *testing.T) {
+	row := func(cells ...string) string {
+		var b strings.Builder
+		b.WriteString(`<w:tr>`)
+		for _, cell := range cells {
+			b.WriteString(`<w:tc><w:p><w:r><w:t xml:space="preserve">` + cell + `</w:t></w:r></w:p></w:tc>`)
+		}
+		b.WriteString(`</w:tr>`)
+		return b.String()
+	}
+	table := func(header string) []byte {
+		return createDOCXWithBodyXML(t, `<w:tbl>`+
+			row("Title", header)+
+			row("{{for item in items}}")+
+			row("{{item.name}}", "{{item.price}}")+
+			row("{{end}}")+
+			row("Total")+
+			`</w:tbl>`)
+	}
+	data := TemplateData{"items": []interface{}{
+		map[string]interface{}{"name": "pear", "price": "12.5"},
+		map[string]interface{}{"name": "Apple", "price": "1,200"},
+		map[string]interface{}{"name": "fig", "price": "n/a"},
+		map[string]interface{}{"name": "kiwi", "price": 3},
+	}}
+
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{"current column", `Price{{sortTable()}}`, []string{"kiwi", "pear", "Apple", "fig"}},
+		{"header text descending", `Price{{sortTable("title", "desc")}}`, []string{"pear", "kiwi", "fig", "Apple"}},
+		{"column index", `Price{{sortTable(0)}}`, []string{"Apple", "fig", "kiwi", "pear"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := renderWithOptionsToBytes(t, table(tt.header), data, RenderOptions{})
+			documentXML := extractDocumentXMLFromDOCX(t, output)
+			if strings.Contains(documentXML, "TABLE_SORT_MARKER") {
+				t.Fatalf("sort marker left in output: %s", documentXML)
+			}
+			last := strings.Index(documentXML, ">Price<")
+			if last == -1 {
+				t.Fatalf("header cell text changed: %s", documentXML)
+			}
+			for _, name := range tt.want {
+				index := strings.Index(documentXML, ">"+name+"<")
+				if index < last {
+					t.Fatalf("want rows in order %v: %s", tt.want, documentXML)
+				}
+				last = index
+			}
+			if strings.Index(documentXML, ">Total<") < last {
+				t.Errorf("totals row was sorted: %s", documentXML)
+			}
+		})
+	}
+
+	tmpl, err := Prepare(bytes.NewReader(table(`Price{{sortTable("Amount")}}`)))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.Render(data); err == nil || !strings.Contains(err.Error(), `"Amount" not found`) {
+		t.Errorf("expected an error for an unknown column, got %v", err)
+	}
+}
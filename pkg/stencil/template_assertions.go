@@ -0,0 +1,114 @@
+package stencil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Templates state invariants of their data with assertions, such as
+// {{assert total > 0}}. The tag is short for {{assert(total > 0,
+// "total > 0")}}: the second argument is the message reported when the
+// condition is false.
+// Assertions are evaluated in the scope of the tag, so an assertion inside
+// a loop is checked for every item. They are only checked by renders with
+// RenderOptions.CheckAssertions and by CheckAssertions; other renders skip
+// them without evaluating the condition. An assertion renders as nothing.
+
+// assertFunctionName is the name of the assert() function.
+const assertFunctionName = "assert"
+
+// AssertionError is returned by a render with RenderOptions.CheckAssertions
+// when assertions of the template fail.
+type AssertionError struct {
+	// Failures holds the message of each failed assertion, in the order
+	// they failed. An assertion in a loop may fail once per item.
+	Failures []string
+}
+
+func (e *AssertionError) Error() string {
+	if len(e.Failures) == 1 {
+		return "assertion failed: " + e.Failures[0]
+	}
+	return fmt.Sprintf("%d assertions failed: %s", len(e.Failures), strings.Join(e.Failures, "; "))
+}
+
+// assertionLog collects the failed assertions of a render.
+type assertionLog struct {
+	mu       sync.Mutex
+	failures []string
+}
+
+func (l *assertionLog) fail(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures = append(l.failures, message)
+}
+
+// err returns an *AssertionError if assertions failed, and nil otherwise or
+// for a nil log.
+func (l *assertionLog) err() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.failures) == 0 {
+		return nil
+	}
+	return &AssertionError{Failures: append([]string(nil), l.failures...)}
+}
+
+// assertionsEnabled reports whether the render of data checks assertions.
+func assertionsEnabled(data TemplateData) bool {
+	return renderHelpersFromData(data).assertions != nil
+}
+
+// assertTagValue rewrites the arguments of an {{assert condition}} tag into
+// a call of assert() with the condition as its message.
+func assertTagValue(condition string) string {
+	message := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(condition)
+	return assertFunctionName + "(" + condition + `, "` + message + `")`
+}
+
+// assertFunc implements assert(condition, message) and records message as
+// a failure when the render checks assertions and condition is false.
+func assertFunc(helpers *renderHelpers, args ...interface{}) (interface{}, error) {
+	if helpers.assertions == nil || isTruthy(args[0]) {
+		return "", nil
+	}
+	message := "assertion failed"
+	if len(args) > 1 {
+		message = FormatValue(args[1])
+	}
+	helpers.assertions.fail(message)
+	return "", nil
+}
+
+// CheckAssertions renders the template with data, checking its assertions,
+// and returns the message of each assertion that fails. An error is
+// returned only when the template cannot be rendered for another reason.
+//
+// Example:
+//
+//	failures, err := tmpl.CheckAssertions(data)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, failure := range failures {
+//	    t.Errorf("assertion failed: %s", failure)
+//	}
+func (pt *PreparedTemplate) CheckAssertions(data TemplateData) ([]string, error) {
+	_, err := pt.RenderWithOptions(data, RenderOptions{CheckAssertions: true})
+	var assertionErr *AssertionError
+	if errors.As(err, &assertionErr) {
+		return assertionErr.Failures, nil
+	}
+	return nil, err
+}
+
+// registerAssertFunction registers the assert() function
+func registerAssertFunction(registry *DefaultFunctionRegistry) {
+	registry.RegisterFunction(&renderHelperFunction{name: assertFunctionName, minArgs: 1, maxArgs: 2, handler: assertFunc})
+}
@@ -0,0 +1,56 @@
+package stencil
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateAssertions(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`Total: {{total}}{{assert sum(map("price", items)) == total}}`,
+		`{{for item in items}}{{item.name}}{{assert(item.price > 0, "price of " + item.name)}}{{end}}`,
+	})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	valid := TemplateData{"total": 30, "items": []interface{}{
+		map[string]interface{}{"name": "A", "price": 10},
+		map[string]interface{}{"name": "B", "price": 20},
+	}}
+	invalid := TemplateData{"total": 40, "items": []interface{}{
+		map[string]interface{}{"name": "A", "price": 30},
+		map[string]interface{}{"name": "B", "price": -5},
+	}}
+
+	// Renders that do not check assertions skip them
+	output := renderPreparedToBytes(t, tmpl, invalid)
+	if content := extractTextFromDOCX(t, output); content != "Total: 40AB" {
+		t.Errorf("expected the assertions to render as nothing, got %q", content)
+	}
+
+	failures, err := tmpl.CheckAssertions(valid)
+	if err != nil || len(failures) != 0 {
+		t.Errorf("expected no failures, got %v, %v", failures, err)
+	}
+	failures, err = tmpl.CheckAssertions(invalid)
+	if err != nil {
+		t.Fatalf("CheckAssertions failed: %v", err)
+	}
+	want := []string{`sum(map("price", items)) == total`, "price of B"}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("failures = %q, want %q", failures, want)
+	}
+
+	_, err = tmpl.RenderWithOptions(invalid, RenderOptions{CheckAssertions: true})
+	var assertionErr *AssertionError
+	if !errors.As(err, &assertionErr) || ErrorCodeOf(err) != ErrorCodeAssertion {
+		t.Fatalf("expected an AssertionError, got %v", err)
+	}
+	if err.Error() != `2 assertions failed: sum(map("price", items)) == total; price of B` {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
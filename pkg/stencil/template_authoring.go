@@ -0,0 +1,188 @@
+package stencil
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// InsertPlaceholder turns a static document into a template step by step:
+// it inserts text, such as "{{customer.name}}", as a new paragraph after
+// the first paragraph of the main document whose text matches the regular
+// expression afterParagraphMatching, and returns the updated DOCX.
+// Paragraphs in table cells are searched too, and the new paragraph is
+// added to the same cell. Each line of text becomes a paragraph, so a
+// control structure can be inserted as "{{if paid}}\nPaid in full\n{{end}}".
+// The new paragraphs have no formatting of their own.
+//
+// Example:
+//
+//	template, err := stencil.InsertPlaceholder(docx, `^Dear Sir or Madam,$`, "{{salutation(customer)}}")
+func InsertPlaceholder(docx []byte, afterParagraphMatching, text string) ([]byte, error) {
+	pattern, err := regexp.Compile(afterParagraphMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid paragraph pattern: %w", err)
+	}
+	return editDocumentParagraphs(docx, func(positions []paragraphPosition) error {
+		match, ok := findAuthoringParagraph(positions, pattern, nil)
+		if !ok {
+			return fmt.Errorf("no paragraph matches %q", afterParagraphMatching)
+		}
+		match.container.insert(match.index+1, authoringParagraphs(text)...)
+		return nil
+	})
+}
+
+// WrapParagraphs wraps existing content in a control structure: it inserts
+// openTag, such as "{{if customer.isCompany}}" or "{{for item in items}}",
+// as a paragraph before the first paragraph of the main document matching
+// the regular expression firstMatching, and an {{end}} paragraph after the
+// first paragraph from there on matching lastMatching, and returns the
+// updated DOCX. Both paragraphs must be in the body or in the same table
+// cell; a single paragraph matching both is wrapped on its own.
+//
+// Example:
+//
+//	template, err := stencil.WrapParagraphs(docx, `^VAT ID:`, `^VAT ID:`, "{{if customer.vatId}}")
+func WrapParagraphs(docx []byte, firstMatching, lastMatching, openTag string) ([]byte, error) {
+	first, err := regexp.Compile(firstMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid first paragraph pattern: %w", err)
+	}
+	last, err := regexp.Compile(lastMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid last paragraph pattern: %w", err)
+	}
+	openTag = strings.TrimSpace(openTag)
+	if tokens := Tokenize(openTag); len(tokens) != 1 || !opensControlBlock(tokens[0]) {
+		return nil, fmt.Errorf("%q does not open a control structure", openTag)
+	}
+
+	return editDocumentParagraphs(docx, func(positions []paragraphPosition) error {
+		start, ok := findAuthoringParagraph(positions, first, nil)
+		if !ok {
+			return fmt.Errorf("no paragraph matches %q", firstMatching)
+		}
+		end, ok := findAuthoringParagraph(positions, last, &start)
+		if !ok {
+			return fmt.Errorf("no paragraph matching %q follows the paragraph matching %q in the same body or table cell", lastMatching, firstMatching)
+		}
+		start.container.insert(end.index+1, authoringParagraphs("{{end}}")...)
+		start.container.insert(start.index, authoringParagraphs(openTag)...)
+		return nil
+	})
+}
+
+// opensControlBlock reports whether token opens a block closed by {{end}}.
+func opensControlBlock(token Token) bool {
+	switch token.Type {
+	case TokenIf, TokenUnless, TokenFor, TokenBlock:
+		return true
+	}
+	return false
+}
+
+// paragraphContainer is the body or a table cell of a document, holding
+// paragraphs the authoring functions search and insert into.
+type paragraphContainer struct {
+	body *Body
+	cell *TableCell
+}
+
+func (c *paragraphContainer) insert(index int, paragraphs ...Paragraph) {
+	if c.cell != nil {
+		c.cell.Paragraphs = append(c.cell.Paragraphs[:index], append(paragraphs, c.cell.Paragraphs[index:]...)...)
+		return
+	}
+	elements := make([]BodyElement, 0, len(paragraphs))
+	for i := range paragraphs {
+		elements = append(elements, &paragraphs[i])
+	}
+	c.body.Elements = append(c.body.Elements[:index], append(elements, c.body.Elements[index:]...)...)
+}
+
+// paragraphPosition is the index of a paragraph in its container.
+type paragraphPosition struct {
+	container *paragraphContainer
+	index     int
+	para      *Paragraph
+}
+
+// findAuthoringParagraph returns the first paragraph of positions matching
+// pattern, or, if from is set, the first one in the container of from at or
+// after it.
+func findAuthoringParagraph(positions []paragraphPosition, pattern *regexp.Regexp, from *paragraphPosition) (paragraphPosition, bool) {
+	for _, position := range positions {
+		if from != nil && (position.container != from.container || position.index < from.index) {
+			continue
+		}
+		if pattern.MatchString(position.para.GetText()) {
+			return position, true
+		}
+	}
+	return paragraphPosition{}, false
+}
+
+// authoringParagraphs returns a paragraph for each line of text.
+func authoringParagraphs(text string) []Paragraph {
+	lines := strings.Split(text, "\n")
+	paragraphs := make([]Paragraph, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		paragraphs = append(paragraphs, Paragraph{Runs: []Run{{Text: &Text{Content: line}}}})
+	}
+	return paragraphs
+}
+
+// editDocumentParagraphs applies edit to the positions of the paragraphs of
+// the main document of docx, including those in table cells, in document
+// order, and returns the updated DOCX.
+func editDocumentParagraphs(docx []byte, edit func([]paragraphPosition) error) ([]byte, error) {
+	pkg, err := readDocxPackage(docx)
+	if err != nil {
+		return nil, NewDocumentError("parse", "DOCX", err)
+	}
+	content, ok := pkg.get("word/document.xml")
+	if !ok {
+		return nil, NewDocumentError("extract", "document.xml", fmt.Errorf("part not found"))
+	}
+	doc, err := ParseDocument(bytes.NewReader(content))
+	if err != nil {
+		return nil, NewDocumentError("parse", "word/document.xml", err)
+	}
+	if doc.Body == nil {
+		return nil, NewDocumentError("parse", "word/document.xml", fmt.Errorf("document has no body"))
+	}
+
+	var positions []paragraphPosition
+	body := &paragraphContainer{body: doc.Body}
+	for index, elem := range doc.Body.Elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			positions = append(positions, paragraphPosition{container: body, index: index, para: e})
+		case *Table:
+			for i := range e.Rows {
+				for j := range e.Rows[i].Cells {
+					cell := &paragraphContainer{cell: &e.Rows[i].Cells[j]}
+					for k := range cell.cell.Paragraphs {
+						positions = append(positions, paragraphPosition{container: cell, index: k, para: &cell.cell.Paragraphs[k]})
+					}
+				}
+			}
+		}
+	}
+	if err := edit(positions); err != nil {
+		return nil, err
+	}
+
+	if content, err = marshalDocumentWithNamespaces(doc); err != nil {
+		return nil, NewDocumentError("write", "word/document.xml", err)
+	}
+	pkg.set("word/document.xml", content)
+	output, err := pkg.bytes()
+	if err != nil {
+		return nil, NewDocumentError("write", "DOCX", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,89 @@
+package stencil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInsertPlaceholderAndWrapParagraphs(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Dear Sir or Madam,</w:t></w:r></w:p>`+
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Total</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`+
+		`<w:p><w:r><w:t>VAT ID:</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>Kind regards</w:t></w:r></w:p>`)
+
+	template, err := InsertPlaceholder(docx, `^Dear Sir or Madam,$`, "{{customer}}\n{{if paid}}Paid in full{{end}}")
+	if err != nil {
+		t.Fatalf("InsertPlaceholder failed: %v", err)
+	}
+	if template, err = InsertPlaceholder(template, `Total`, "{{total}}"); err != nil {
+		t.Fatalf("InsertPlaceholder failed: %v", err)
+	}
+	if template, err = WrapParagraphs(template, `^VAT ID:$`, `^VAT ID:$`, "{{if vatId}}"); err != nil {
+		t.Fatalf("WrapParagraphs failed: %v", err)
+	}
+
+	documentXML := extractDocumentXMLFromDOCX(t, template)
+	if !strings.Contains(documentXML, `<w:tc><w:p><w:r><w:t>Total</w:t></w:r></w:p><w:p><w:r><w:t>{{total}}</w:t></w:r></w:p></w:tc>`) {
+		t.Errorf("expected the placeholder in the table cell: %s", documentXML)
+	}
+
+	output := renderWithOptionsToBytes(t, template, TemplateData{"customer": "Acme", "paid": true, "total": 42}, RenderOptions{})
+	if text := extractTextFromDOCX(t, output); text != "Dear Sir or Madam,AcmePaid in fullTotal42Kind regards" {
+		t.Errorf("rendered text = %q", text)
+	}
+}
+
+func TestTemplateAuthoringErrors(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Intro</w:t></w:r></w:p>`+
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`+
+		`<w:p><w:r><w:t>Outro</w:t></w:r></w:p>`)
+
+	tests := []struct {
+		name string
+		edit func() ([]byte, error)
+		want string
+	}{
+		{
+			name: "no match",
+			edit: func() ([]byte, error) { return InsertPlaceholder(docx, `^Missing$`, "{{name}}") },
+			want: `no paragraph matches "^Missing$"`,
+		},
+		{
+			name: "invalid pattern",
+			edit: func() ([]byte, error) { return InsertPlaceholder(docx, `(`, "{{name}}") },
+			want: "invalid paragraph pattern",
+		},
+		{
+			name: "tag that does not open a block",
+			edit: func() ([]byte, error) { return WrapParagraphs(docx, `Intro`, `Outro`, "{{name}}") },
+			want: "does not open a control structure",
+		},
+		{
+			name: "last paragraph in another container",
+			edit: func() ([]byte, error) { return WrapParagraphs(docx, `Intro`, `Cell`, "{{if show}}") },
+			want: "in the same body or table cell",
+		},
+		{
+			name: "last paragraph before the first",
+			edit: func() ([]byte, error) { return WrapParagraphs(docx, `Outro`, `Intro`, "{{if show}}") },
+			want: "follows the paragraph",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.edit(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Wrapping across a table keeps the table inside the block
+	template, err := WrapParagraphs(docx, `Intro`, `Outro`, "{{for i in list(1, 2)}}")
+	if err != nil {
+		t.Fatalf("WrapParagraphs failed: %v", err)
+	}
+	output := renderWithOptionsToBytes(t, template, TemplateData{}, RenderOptions{})
+	if text := extractTextFromDOCX(t, output); text != "IntroCellOutroIntroCellOutro" {
+		t.Errorf("rendered text = %q", text)
+	}
+}
@@ -0,0 +1,281 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+)
+
+// A template pack (.stencilpack) bundles a template with everything it
+// needs into one versioned artifact: the main template, its fragments, the
+// schema of its data, sample data and metadata such as its name and
+// version. The pack is a ZIP archive holding a stencilpack.json manifest
+// that lists the other files:
+//
+//	stencilpack.json
+//	template.docx
+//	fragments/<name>.docx   (or .txt for text fragments)
+//	schema.json             (optional)
+//	sample-data.json        (optional)
+
+// PackFormatVersion is the version of the pack format TemplatePack.Bytes
+// writes. Packs of a later version are rejected.
+const PackFormatVersion = 1
+
+const (
+	packManifestName   = "stencilpack.json"
+	packTemplateName   = "template.docx"
+	packSchemaName     = "schema.json"
+	packSampleDataName = "sample-data.json"
+	packFragmentsDir   = "fragments/"
+)
+
+// PackManifest describes a template pack. The paths name files of the
+// archive; TemplatePack.Bytes sets them.
+type PackManifest struct {
+	FormatVersion int               `json:"formatVersion"`
+	Name          string            `json:"name,omitempty"`
+	Version       string            `json:"version,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Template      string            `json:"template"`
+	Fragments     map[string]string `json:"fragments,omitempty"` // fragment name -> path
+	Schema        string            `json:"schema,omitempty"`
+	SampleData    string            `json:"sampleData,omitempty"`
+}
+
+// TemplatePack is the content of a template pack.
+type TemplatePack struct {
+	Manifest PackManifest
+	// Template is the main template.
+	Template []byte
+	// Fragments holds the fragments by name: DOCX files, or the text of
+	// text fragments.
+	Fragments map[string][]byte
+	// Schema describes the data of the template; nil when the pack has
+	// none.
+	Schema *ValidationSchema
+	// SampleData is example data for the template; nil when the pack has
+	// none.
+	SampleData TemplateData
+}
+
+// ReadPackFile reads the template pack at path.
+func ReadPackFile(path string) (*TemplatePack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template pack: %w", err)
+	}
+	return ReadPack(data)
+}
+
+// ReadPack reads a template pack from its bytes.
+func ReadPack(data []byte) (*TemplatePack, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template pack: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zipReader.File))
+	for _, file := range zipReader.File {
+		files[file.Name] = file
+	}
+	read := func(name string) ([]byte, error) {
+		file, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid template pack: %s not found", name)
+		}
+		content, err := readZipFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template pack: failed to read %s: %w", name, err)
+		}
+		return content, nil
+	}
+
+	pack := &TemplatePack{}
+	manifest, err := read(packManifestName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifest, &pack.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid template pack: %s: %w", packManifestName, err)
+	}
+	if v := pack.Manifest.FormatVersion; v < 1 || v > PackFormatVersion {
+		return nil, fmt.Errorf("unsupported template pack format version %d", v)
+	}
+	if pack.Manifest.Template == "" {
+		return nil, fmt.Errorf("invalid template pack: %s names no template", packManifestName)
+	}
+	if pack.Template, err = read(pack.Manifest.Template); err != nil {
+		return nil, err
+	}
+
+	if len(pack.Manifest.Fragments) > 0 {
+		pack.Fragments = make(map[string][]byte, len(pack.Manifest.Fragments))
+		for name, file := range pack.Manifest.Fragments {
+			if pack.Fragments[name], err = read(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if pack.Manifest.Schema != "" {
+		content, err := read(pack.Manifest.Schema)
+		if err != nil {
+			return nil, err
+		}
+		pack.Schema = &ValidationSchema{}
+		if err := json.Unmarshal(content, pack.Schema); err != nil {
+			return nil, fmt.Errorf("invalid template pack: %s: %w", pack.Manifest.Schema, err)
+		}
+	}
+	if pack.Manifest.SampleData != "" {
+		content, err := read(pack.Manifest.SampleData)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &pack.SampleData); err != nil {
+			return nil, fmt.Errorf("invalid template pack: %s: %w", pack.Manifest.SampleData, err)
+		}
+	}
+	return pack, nil
+}
+
+// Bytes returns the pack as a .stencilpack archive. The files are laid out
+// as described above and the paths and format version of the manifest are
+// set accordingly. Writing the same pack twice gives the same bytes.
+func (p *TemplatePack) Bytes() ([]byte, error) {
+	if len(p.Template) == 0 {
+		return nil, fmt.Errorf("template pack has no template")
+	}
+	manifest := p.Manifest
+	manifest.FormatVersion = PackFormatVersion
+	manifest.Template = packTemplateName
+	manifest.Fragments = nil
+	manifest.Schema = ""
+	manifest.SampleData = ""
+
+	type packFile struct {
+		name    string
+		content []byte
+	}
+	files := []packFile{{packTemplateName, p.Template}}
+	names := make([]string, 0, len(p.Fragments))
+	for name := range p.Fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ext := ".txt"
+		if isDocxFragmentBytes(p.Fragments[name]) {
+			ext = ".docx"
+		}
+		file := packFragmentsDir + url.PathEscape(name) + ext
+		if manifest.Fragments == nil {
+			manifest.Fragments = make(map[string]string, len(names))
+		}
+		manifest.Fragments[name] = file
+		files = append(files, packFile{file, p.Fragments[name]})
+	}
+	if p.Schema != nil {
+		schema, err := json.MarshalIndent(p.Schema, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode schema: %w", err)
+		}
+		manifest.Schema = packSchemaName
+		files = append(files, packFile{packSchemaName, schema})
+	}
+	if p.SampleData != nil {
+		sample, err := json.MarshalIndent(p.SampleData, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode sample data: %w", err)
+		}
+		manifest.SampleData = packSampleDataName
+		files = append(files, packFile{packSampleDataName, sample})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	files = append([]packFile{{packManifestName, manifestJSON}}, files...)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, file := range files {
+		fw, err := w.Create(file.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		if _, err := io.Copy(fw, bytes.NewReader(file.content)); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write template pack: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// PreparePack prepares the template of the template pack at path and adds
+// the fragments of the pack to it: DOCX files with AddFragmentFromBytes,
+// text with AddFragment. An engine with trusted template keys rejects packs
+// like other unsigned templates. The schema and sample data of the pack
+// are available through ReadPackFile.
+//
+// Example:
+//
+//	tmpl, err := engine.PreparePack("invoice.stencilpack")
+//	if err != nil {
+//	    return err
+//	}
+//	defer tmpl.Close()
+//	output, err := tmpl.Render(data)
+func (e *Engine) PreparePack(path string) (*PreparedTemplate, error) {
+	pack, err := ReadPackFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := e.Prepare(bytes.NewReader(pack.Template))
+	if err != nil {
+		return nil, err
+	}
+	tmpl.template.name = path
+
+	names := make([]string, 0, len(pack.Fragments))
+	for name := range pack.Fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := pack.Fragments[name]
+		if isDocxFragmentBytes(content) {
+			err = tmpl.AddFragmentFromBytes(name, content)
+		} else {
+			err = tmpl.AddFragment(name, string(content))
+		}
+		if err != nil {
+			tmpl.Close()
+			return nil, fmt.Errorf("failed to add fragment %s of template pack: %w", name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// isDocxFragmentBytes reports whether the content of a fragment is a DOCX
+// file rather than text.
+func isDocxFragmentBytes(content []byte) bool {
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return false
+	}
+	for _, file := range zipReader.File {
+		if path.Clean(file.Name) == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
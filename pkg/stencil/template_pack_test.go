@@ -0,0 +1,109 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplatePackRoundTrip(t *testing.T) {
+	pack := &TemplatePack{
+		Manifest: PackManifest{
+			Name:     "invoice",
+			Version:  "1.2.0",
+			Metadata: map[string]string{"owner": "billing"},
+		},
+		Template: createDOCXWithCustomStylesAndBody(t, `
+<w:p><w:r><w:t>Invoice for {{customer}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{include "terms/short"}}</w:t></w:r></w:p>
+<w:p><w:r><w:t>{{include "signature"}}</w:t></w:r></w:p>`, ""),
+		Fragments: map[string][]byte{
+			"terms/short": createDOCXWithCustomStylesAndBody(t, `<w:p><w:r><w:t>Payable within 30 days</w:t></w:r></w:p>`, ""),
+			"signature":   []byte("Kind regards"),
+		},
+		Schema:     &ValidationSchema{Fields: []FieldDefinition{{Path: "customer", Type: "string", Required: true}}},
+		SampleData: TemplateData{"customer": "Acme Corp"},
+	}
+
+	packBytes, err := pack.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	if again, err := pack.Bytes(); err != nil || !bytes.Equal(again, packBytes) {
+		t.Errorf("expected writing the pack twice to give the same bytes")
+	}
+
+	path := filepath.Join(t.TempDir(), "invoice.stencilpack")
+	if err := os.WriteFile(path, packBytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPackFile(path)
+	if err != nil {
+		t.Fatalf("ReadPackFile failed: %v", err)
+	}
+	if read.Manifest.Name != "invoice" || read.Manifest.Version != "1.2.0" || read.Manifest.FormatVersion != PackFormatVersion ||
+		read.Manifest.Metadata["owner"] != "billing" {
+		t.Errorf("unexpected manifest %+v", read.Manifest)
+	}
+	if read.Manifest.Fragments["terms/short"] != "fragments/terms%2Fshort.docx" || read.Manifest.Fragments["signature"] != "fragments/signature.txt" {
+		t.Errorf("unexpected fragment paths %v", read.Manifest.Fragments)
+	}
+	if !bytes.Equal(read.Template, pack.Template) || !reflect.DeepEqual(read.Fragments, pack.Fragments) {
+		t.Error("expected the template and fragments to be read back unchanged")
+	}
+	if !reflect.DeepEqual(read.Schema, pack.Schema) || read.SampleData["customer"] != "Acme Corp" {
+		t.Errorf("expected the schema and sample data to be read back, got %+v and %v", read.Schema, read.SampleData)
+	}
+
+	tmpl, err := PreparePack(path)
+	if err != nil {
+		t.Fatalf("PreparePack failed: %v", err)
+	}
+	defer tmpl.Close()
+	document := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, read.SampleData))
+	for _, want := range []string{"Invoice for Acme Corp", "Payable within 30 days", "Kind regards"} {
+		if !strings.Contains(document, want) {
+			t.Errorf("expected %q in the rendered document, got %s", want, document)
+		}
+	}
+}
+
+func TestReadPackRejectsInvalidPacks(t *testing.T) {
+	archive := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for name, content := range files {
+			f, err := w.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte(content))
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"not an archive":   {[]byte("template"), "invalid template pack"},
+		"no manifest":      {archive(map[string]string{"template.docx": "x"}), "stencilpack.json not found"},
+		"newer format":     {archive(map[string]string{"stencilpack.json": `{"formatVersion": 2, "template": "template.docx"}`}), "unsupported template pack format version 2"},
+		"missing template": {archive(map[string]string{"stencilpack.json": `{"formatVersion": 1, "template": "template.docx"}`}), "template.docx not found"},
+		"missing fragment": {archive(map[string]string{
+			"stencilpack.json": `{"formatVersion": 1, "template": "template.docx", "fragments": {"terms": "fragments/terms.docx"}}`,
+			"template.docx":    "x",
+		}), "fragments/terms.docx not found"},
+	} {
+		if _, err := ReadPack(tc.data); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package stencil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// TemplatePool holds a fixed number of independently prepared and frozen
+// copies of a template for very hot render paths. Each render borrows one
+// copy, so concurrent renders do not share a document model, style caches
+// or output buffer sizing. Renders wait while all copies are in use.
+//
+// A TemplatePool is safe for concurrent use.
+type TemplatePool struct {
+	instances chan *FrozenTemplate
+	size      int
+}
+
+// NewTemplatePool prepares size copies of the DOCX template in docxBytes
+// with the default engine and freezes each of them.
+//
+// Example:
+//
+//	pool, err := stencil.NewTemplatePool(docxBytes, runtime.GOMAXPROCS(0))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if err := pool.Warmup(sampleData); err != nil {
+//	    log.Fatal(err)
+//	}
+//	output, err := pool.Render(data)
+func NewTemplatePool(docxBytes []byte, size int) (*TemplatePool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("template pool size must be at least 1, got %d", size)
+	}
+
+	pool := &TemplatePool{
+		instances: make(chan *FrozenTemplate, size),
+		size:      size,
+	}
+	for i := 0; i < size; i++ {
+		prepared, err := Prepare(bytes.NewReader(docxBytes))
+		if err != nil {
+			return nil, err
+		}
+		frozen, err := prepared.Freeze()
+		prepared.Close()
+		if err != nil {
+			return nil, err
+		}
+		pool.instances <- frozen
+	}
+	return pool, nil
+}
+
+// Size returns the number of template copies in the pool.
+func (p *TemplatePool) Size() int {
+	if p == nil {
+		return 0
+	}
+	return p.size
+}
+
+// Render executes a template copy from the pool with the given data.
+func (p *TemplatePool) Render(data TemplateData) (io.Reader, error) {
+	return p.RenderWithOptions(data, RenderOptions{})
+}
+
+// RenderWithOptions executes a template copy from the pool like Render,
+// applying the given render options.
+func (p *TemplatePool) RenderWithOptions(data TemplateData, opts RenderOptions) (io.Reader, error) {
+	if p == nil || p.instances == nil {
+		return nil, NewTemplateError("invalid or nil template pool", 0, 0)
+	}
+
+	frozen := <-p.instances
+	defer func() { p.instances <- frozen }()
+	return frozen.RenderWithOptions(data, opts)
+}
+
+// Warmup renders every copy in the pool once with data, so that the caches
+// of each copy are filled and output buffers are sized before the first
+// real render. Other renders wait until the warmup has finished.
+func (p *TemplatePool) Warmup(data TemplateData) error {
+	if p == nil || p.instances == nil {
+		return NewTemplateError("invalid or nil template pool", 0, 0)
+	}
+
+	borrowed := make([]*FrozenTemplate, 0, p.size)
+	defer func() {
+		for _, frozen := range borrowed {
+			p.instances <- frozen
+		}
+	}()
+	for i := 0; i < p.size; i++ {
+		frozen := <-p.instances
+		borrowed = append(borrowed, frozen)
+		if _, err := frozen.Render(data); err != nil {
+			return fmt.Errorf("template pool warmup: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package stencil
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTemplatePoolRendersConcurrently(t *testing.T) {
+	pool, err := NewTemplatePool(createSimpleDOCX(t, "Hello {{name}}!"), 2)
+	if err != nil {
+		t.Fatalf("NewTemplatePool failed: %v", err)
+	}
+	if pool.Size() != 2 {
+		t.Errorf("Size() = %d, want 2", pool.Size())
+	}
+	if err := pool.Warmup(TemplateData{"name": "warmup"}); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("user%d", i)
+			output, err := pool.Render(TemplateData{"name": name})
+			if err != nil {
+				errs <- err
+				return
+			}
+			docx, err := io.ReadAll(output)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if text := extractTextFromDOCX(t, docx); !strings.Contains(text, "Hello "+name+"!") {
+				errs <- fmt.Errorf("render %d: got %q", i, text)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestTemplatePoolErrors(t *testing.T) {
+	if _, err := NewTemplatePool(createSimpleDOCX(t, "x"), 0); err == nil {
+		t.Error("expected an error for pool size 0")
+	}
+	if _, err := NewTemplatePool([]byte("not a docx"), 1); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+
+	pool, err := NewTemplatePool(createSimpleDOCX(t, "{{missing()}}"), 1)
+	if err != nil {
+		t.Fatalf("NewTemplatePool failed: %v", err)
+	}
+	if err := pool.Warmup(TemplateData{}); err == nil {
+		t.Error("expected warmup to fail for an unknown function")
+	}
+	// The failed warmup returns the copy to the pool.
+	if _, err := pool.RenderWithOptions(TemplateData{}, RenderOptions{}); err == nil {
+		t.Error("expected render to fail for an unknown function")
+	}
+}
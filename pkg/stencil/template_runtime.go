@@ -2,7 +2,6 @@ package stencil
 
 import (
 	"bytes"
-	"encoding/xml"
 	"fmt"
 	"strings"
 )
@@ -18,13 +17,16 @@ func cloneFragmentMap(src map[string]*fragment) map[string]*fragment {
 	return cloned
 }
 
-func (t *template) snapshotFragments() map[string]*fragment {
+// snapshotFragments copies the registered fragments together with the
+// header and footer designations, so registrations made while a render runs
+// never modify the map the render reads.
+func (t *template) snapshotFragments() (fragments map[string]*fragment, headerFragment, footerFragment string) {
 	if t == nil {
-		return make(map[string]*fragment)
+		return make(map[string]*fragment), "", ""
 	}
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return cloneFragmentMap(t.fragments)
+	return cloneFragmentMap(t.fragments), t.headerFragment, t.footerFragment
 }
 
 func cloneBodyPlanMap(src map[*Body]*bodyRenderPlan) map[*Body]*bodyRenderPlan {
@@ -69,6 +71,14 @@ func (t *template) resolveFragment(name string) (*fragment, error) {
 	resolver := t.fragmentResolver
 	t.mu.RUnlock()
 
+	frag, err := t.buildingBlock(name)
+	if err != nil {
+		return nil, err
+	}
+	if frag != nil {
+		return t.addResolvedFragment(name, frag), nil
+	}
+
 	if resolver == nil {
 		return nil, nil
 	}
@@ -81,15 +91,24 @@ func (t *template) resolveFragment(name string) (*fragment, error) {
 		return nil, nil
 	}
 
-	frag, err := newResolvedFragment(name, content)
+	frag, err = newResolvedFragment(name, content)
 	if err != nil {
 		return nil, err
 	}
+	if volatile, ok := resolver.(volatileFragmentResolver); ok && volatile.volatileFragment(name) {
+		// The render keeps the fragment; the next render resolves it again
+		return frag, nil
+	}
+	return t.addResolvedFragment(name, frag), nil
+}
 
+// addResolvedFragment keeps a fragment resolved for name and returns it, or
+// the fragment a concurrent resolve kept first.
+func (t *template) addResolvedFragment(name string, frag *fragment) *fragment {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	if existing, ok := t.fragments[name]; ok {
-		return existing, nil
+		return existing
 	}
 	if t.resolverMisses != nil {
 		delete(t.resolverMisses, name)
@@ -98,7 +117,7 @@ func (t *template) resolveFragment(name string) (*fragment, error) {
 		t.fragments = make(map[string]*fragment)
 	}
 	t.fragments[name] = frag
-	return frag, nil
+	return frag
 }
 
 func buildStaticPartCache(reader *DocxReader) (map[string][]byte, map[string]bool, error) {
@@ -110,7 +129,7 @@ func buildStaticPartCache(reader *DocxReader) (map[string][]byte, map[string]boo
 
 	candidateParts := []string{"word/document.xml"}
 	for _, name := range reader.ListParts() {
-		if isHeaderPartName(name) || isFooterPartName(name) {
+		if isStoryPartName(name) || isChartPartName(name) {
 			candidateParts = append(candidateParts, name)
 		}
 	}
@@ -141,31 +160,13 @@ func partHasPotentialTemplateMarkers(partName string, content []byte) bool {
 			return true
 		}
 		return bodyHasPotentialTemplateMarkers(doc.Body)
-	case isHeaderPartName(partName), isFooterPartName(partName):
-		var headerFooter struct {
-			Paragraphs []*Paragraph `xml:"p"`
-			Tables     []*Table     `xml:"tbl"`
-		}
-		if err := xml.Unmarshal(content, &headerFooter); err != nil {
-			return true
-		}
-		return elementsHavePotentialTemplateMarkers(paragraphsAndTablesToElements(headerFooter.Paragraphs, headerFooter.Tables))
+	case isStoryPartName(partName):
+		return storyPartHasPotentialTemplateMarkers(partName, content)
 	default:
 		return false
 	}
 }
 
-func paragraphsAndTablesToElements(paragraphs []*Paragraph, tables []*Table) []BodyElement {
-	elements := make([]BodyElement, 0, len(paragraphs)+len(tables))
-	for _, para := range paragraphs {
-		elements = append(elements, para)
-	}
-	for _, table := range tables {
-		elements = append(elements, table)
-	}
-	return elements
-}
-
 func bodyHasPotentialTemplateMarkers(body *Body) bool {
 	if body == nil {
 		return false
@@ -194,7 +195,7 @@ func paragraphHasPotentialTemplateMarkers(para *Paragraph) bool {
 		return false
 	}
 	text := para.GetText()
-	return strings.Contains(text, "{{") || strings.Contains(text, "}}")
+	return strings.Contains(text, "{{") || strings.Contains(text, "}}") || hasDrawingPropertyTokens(para)
 }
 
 func tableHasPotentialTemplateMarkers(table *Table) bool {
@@ -233,3 +234,39 @@ func resolveFragmentByName(name string, ctx *renderContext) (*fragment, error) {
 	}
 	return frag, nil
 }
+
+// resolveMissingFragment applies the render options for an include whose
+// fragment could not be resolved. It returns the name and fragment to render
+// instead, or a nil fragment if the include should be skipped.
+func resolveMissingFragment(name string, ctx *renderContext) (string, *fragment, error) {
+	var opts *RenderOptions
+	if ctx != nil {
+		opts = ctx.options
+	}
+
+	if opts != nil && opts.DefaultFragment != "" && opts.DefaultFragment != name {
+		frag, err := resolveFragmentByName(opts.DefaultFragment, ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to resolve default fragment %s: %w", opts.DefaultFragment, err)
+		}
+		if frag != nil {
+			opts.warn(RenderWarning{
+				Code:     WarningDefaultFragment,
+				Fragment: name,
+				Message:  fmt.Sprintf("fragment not found: %s; using default fragment %s", name, opts.DefaultFragment),
+			})
+			return opts.DefaultFragment, frag, nil
+		}
+	}
+
+	if opts != nil && opts.SkipMissingFragments {
+		opts.warn(RenderWarning{
+			Code:     WarningSkippedFragment,
+			Fragment: name,
+			Message:  fmt.Sprintf("fragment not found: %s; skipping include", name),
+		})
+		return name, nil, nil
+	}
+
+	return "", nil, withErrorCode(ErrorCodeMissingFragment, fmt.Errorf("fragment not found: %s", name))
+}
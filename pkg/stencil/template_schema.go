@@ -2,10 +2,12 @@ package stencil
 
 // Type describes a template value type used by TemplateSchema validation.
 type Type struct {
-	kind     string
-	fields   TemplateSchema
-	element  *Type
-	nullable bool
+	kind       string
+	fields     TemplateSchema
+	element    *Type
+	nullable   bool
+	required   bool
+	requiredIf string
 }
 
 // TemplateType is the public type accepted by TemplateSchema values.
@@ -44,6 +46,21 @@ func Nullable(t TemplateType) TemplateType {
 	return t
 }
 
+// Required marks a field that must be present in the data, as checked by
+// ValidateData.
+func Required(t TemplateType) TemplateType {
+	t.required = true
+	return t
+}
+
+// RequiredIf marks a field that must be present and not null when the
+// condition expression holds, as checked by ValidateData. See
+// FieldDefinition.RequiredIf.
+func RequiredIf(t TemplateType, condition string) TemplateType {
+	t.requiredIf = condition
+	return t
+}
+
 func validationSchemaFromTemplateSchema(schema TemplateSchema) ValidationSchema {
 	fields := make([]FieldDefinition, 0)
 	appendTemplateSchemaFields(&fields, "", schema, false)
@@ -79,15 +96,19 @@ func appendTemplateTypeField(fields *[]FieldDefinition, path string, typ Templat
 			Type:       elementKind,
 			Nullable:   nullable,
 			Collection: true,
+			Required:   typ.required,
+			RequiredIf: typ.requiredIf,
 		})
 		if typ.element != nil && len(typ.element.fields) > 0 {
 			appendTemplateSchemaFields(fields, path, typ.element.fields, typ.element.nullable)
 		}
 	case semanticKindObject:
 		*fields = append(*fields, FieldDefinition{
-			Path:     path,
-			Type:     semanticKindObject,
-			Nullable: nullable,
+			Path:       path,
+			Type:       semanticKindObject,
+			Nullable:   nullable,
+			Required:   typ.required,
+			RequiredIf: typ.requiredIf,
 		})
 		appendTemplateSchemaFields(fields, path, typ.fields, false)
 	default:
@@ -96,9 +117,11 @@ func appendTemplateTypeField(fields *[]FieldDefinition, path string, typ Templat
 			kind = semanticKindAny
 		}
 		*fields = append(*fields, FieldDefinition{
-			Path:     path,
-			Type:     kind,
-			Nullable: nullable,
+			Path:       path,
+			Type:       kind,
+			Nullable:   nullable,
+			Required:   typ.required,
+			RequiredIf: typ.requiredIf,
 		})
 	}
 }
@@ -0,0 +1,106 @@
+package stencil
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Templates can be signed to prove where they come from. A signature is a
+// detached Ed25519 signature over the SHA-256 hash of the template package.
+// An engine with trusted keys only prepares templates whose signature
+// verifies with one of them.
+
+// TemplateSignatureExtension is appended to a template path to find the
+// signature file that PrepareFile reads on an engine with trusted keys.
+const TemplateSignatureExtension = ".sig"
+
+// SignTemplate returns a detached signature of the template package in
+// docxBytes.
+//
+// Example:
+//
+//	signature, err := stencil.SignTemplate(docxBytes, privateKey)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = os.WriteFile("contract.docx.sig", signature, 0o644)
+func SignTemplate(docxBytes []byte, key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid template signing key: expected %d bytes, got %d", ed25519.PrivateKeySize, len(key))
+	}
+	hash := sha256.Sum256(docxBytes)
+	return ed25519.Sign(key, hash[:]), nil
+}
+
+// VerifyTemplateSignature checks that signature is a signature of the
+// template package in docxBytes made with the private key of one of keys.
+// The returned error has the code ErrorCodeUntrustedTemplate.
+func VerifyTemplateSignature(docxBytes, signature []byte, keys ...ed25519.PublicKey) error {
+	if len(signature) == 0 {
+		return withErrorCode(ErrorCodeUntrustedTemplate, errors.New("template is not signed"))
+	}
+	hash := sha256.Sum256(docxBytes)
+	for _, key := range keys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, hash[:], signature) {
+			return nil
+		}
+	}
+	return withErrorCode(ErrorCodeUntrustedTemplate, errors.New("template signature does not match a trusted key"))
+}
+
+// WithTrustedTemplateKeys returns an option that makes the engine verify
+// templates when preparing them. Templates must be prepared with
+// PrepareSigned, or with PrepareFile next to a signature file; Prepare
+// fails because it has no signature to check.
+//
+// Example:
+//
+//	engine := stencil.NewWithOptions(stencil.WithTrustedTemplateKeys(counselKey))
+//	// Reads contract.docx.sig and rejects the template if it was modified.
+//	tmpl, err := engine.PrepareFile("contract.docx")
+func WithTrustedTemplateKeys(keys ...ed25519.PublicKey) Option {
+	return func(e *Engine) {
+		e.trustedKeys = append(e.trustedKeys, keys...)
+	}
+}
+
+// PrepareSigned verifies the template in r with the engine's trusted keys
+// and prepares it.
+func (e *Engine) PrepareSigned(r io.Reader, signature []byte) (*PreparedTemplate, error) {
+	if len(e.trustedKeys) == 0 {
+		return nil, withErrorCode(ErrorCodeUntrustedTemplate, errors.New("engine has no trusted template keys"))
+	}
+	docxBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, NewDocumentError("read", "", err)
+	}
+	if err := VerifyTemplateSignature(docxBytes, signature, e.trustedKeys...); err != nil {
+		return nil, err
+	}
+	return e.prepare(bytes.NewReader(docxBytes))
+}
+
+// prepareFileSigned prepares the template at path with the signature in
+// the file next to it.
+func (e *Engine) prepareFileSigned(path string) (*PreparedTemplate, error) {
+	signature, err := os.ReadFile(path + TemplateSignatureExtension)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, withErrorCode(ErrorCodeUntrustedTemplate, fmt.Errorf("template %s is not signed", path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template signature: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open template file: %w", err)
+	}
+	defer file.Close()
+
+	return e.PrepareSigned(file, signature)
+}
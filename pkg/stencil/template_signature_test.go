@@ -0,0 +1,95 @@
+package stencil
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignedTemplates(t *testing.T) {
+	trustedPublic, trustedPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docx := createSimpleDOCX(t, "Hello {{name}}")
+	signature, err := SignTemplate(docx, trustedPrivate)
+	if err != nil {
+		t.Fatalf("SignTemplate failed: %v", err)
+	}
+	if err := VerifyTemplateSignature(docx, signature, trustedPublic); err != nil {
+		t.Errorf("VerifyTemplateSignature failed: %v", err)
+	}
+
+	engine := NewWithOptions(WithTrustedTemplateKeys(trustedPublic))
+	tmpl, err := engine.PrepareSigned(bytes.NewReader(docx), signature)
+	if err != nil {
+		t.Fatalf("PrepareSigned failed: %v", err)
+	}
+	tmpl.Close()
+
+	tampered := append([]byte(nil), docx...)
+	tampered[len(tampered)/2] ^= 0xff
+	otherSignature, err := SignTemplate(docx, otherPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, prepare := range map[string]func() (*PreparedTemplate, error){
+		"tampered":  func() (*PreparedTemplate, error) { return engine.PrepareSigned(bytes.NewReader(tampered), signature) },
+		"other key": func() (*PreparedTemplate, error) { return engine.PrepareSigned(bytes.NewReader(docx), otherSignature) },
+		"unsigned":  func() (*PreparedTemplate, error) { return engine.PrepareSigned(bytes.NewReader(docx), nil) },
+		"Prepare":   func() (*PreparedTemplate, error) { return engine.Prepare(bytes.NewReader(docx)) },
+		"no trusted keys": func() (*PreparedTemplate, error) {
+			return New().PrepareSigned(bytes.NewReader(docx), signature)
+		},
+	} {
+		if _, err := prepare(); ErrorCodeOf(err) != ErrorCodeUntrustedTemplate {
+			t.Errorf("%s: error = %v, want code %s", name, err, ErrorCodeUntrustedTemplate)
+		}
+	}
+
+	if _, err := SignTemplate(docx, ed25519.PrivateKey("short")); err == nil {
+		t.Error("expected an error for an invalid signing key")
+	}
+}
+
+func TestPrepareFileReadsSignatureFile(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docx := createSimpleDOCX(t, "Hello {{name}}")
+	signature, err := SignTemplate(docx, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "template.docx")
+	if err := os.WriteFile(path, docx, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewWithOptions(WithConfig(&Config{MaxRenderDepth: 10}), WithTrustedTemplateKeys(public))
+	if _, err := engine.PrepareFile(path); ErrorCodeOf(err) != ErrorCodeUntrustedTemplate {
+		t.Errorf("PrepareFile without signature file: error = %v, want code %s", err, ErrorCodeUntrustedTemplate)
+	}
+
+	if err := os.WriteFile(path+TemplateSignatureExtension, signature, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := engine.PrepareFile(path)
+	if err != nil {
+		t.Fatalf("PrepareFile failed: %v", err)
+	}
+	defer tmpl.Close()
+	if _, err := tmpl.Render(TemplateData{"name": "World"}); err != nil {
+		t.Errorf("Render failed: %v", err)
+	}
+}
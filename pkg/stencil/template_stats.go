@@ -0,0 +1,132 @@
+package stencil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TemplateStats counts the elements and template tags of a prepared
+// template, such as to track the composition of a fleet of templates on a
+// dashboard and notice unexpected changes after an edit.
+type TemplateStats struct {
+	// Paragraphs and Tables count the paragraphs and tables of the main
+	// document. Paragraphs include those in table cells.
+	Paragraphs int `json:"paragraphs"`
+	Tables     int `json:"tables"`
+	// Tags is the number of template tags, such as {{name}} or {{end}}, in
+	// the main document, headers, footers and notes.
+	Tags int `json:"tags"`
+	// Placeholders counts the tags that output a value, such as {{name}}.
+	Placeholders int `json:"placeholders"`
+	// ControlStructures counts the control tags by keyword: "for", "if",
+	// "elsif", "else", "unless", "macro" and the names of block
+	// directives, such as "columns". {{end}} tags are not counted.
+	ControlStructures map[string]int `json:"controlStructures"`
+	// Includes counts the {{include}} tags.
+	Includes int `json:"includes"`
+	// Expressions counts the expressions the tags evaluate: the values of
+	// placeholders, the conditions and the collections of loops, as
+	// counted by AnalyzeComplexity.
+	Expressions int `json:"expressions"`
+	// Fragments is the number of fragments added to the template.
+	Fragments int `json:"fragments"`
+}
+
+// Stats counts the paragraphs, tables and template tags of the template.
+// The main document is counted as the template renders it, which differs
+// from its file for templates made with WithDocument.
+//
+// Example:
+//
+//	stats, err := tmpl.Stats()
+//	if err != nil {
+//	    return err
+//	}
+//	metrics.Gauge("template.tables", stats.Tables, "template:"+name)
+//	metrics.Gauge("template.loops", stats.ControlStructures["for"], "template:"+name)
+func (pt *PreparedTemplate) Stats() (TemplateStats, error) {
+	if pt == nil {
+		return TemplateStats{}, fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	if pt.closed || pt.template == nil {
+		return TemplateStats{}, errTemplateClosed
+	}
+	tmpl := pt.template
+	tmpl.mu.RLock()
+	defer tmpl.mu.RUnlock()
+
+	stats := TemplateStats{ControlStructures: make(map[string]int), Fragments: len(tmpl.fragments)}
+	if tmpl.document != nil && tmpl.document.Body != nil {
+		countBodyElements(&stats, tmpl.document.Body.Elements)
+	}
+	spans, err := templateTokenSpans(tmpl)
+	if err != nil {
+		return TemplateStats{}, err
+	}
+	for _, span := range spans {
+		countTag(&stats, span)
+	}
+	return stats, nil
+}
+
+// countBodyElements adds the paragraphs and tables of elements to stats.
+func countBodyElements(stats *TemplateStats, elements []BodyElement) {
+	for _, element := range elements {
+		switch e := element.(type) {
+		case *Paragraph:
+			stats.Paragraphs++
+		case *Table:
+			stats.Tables++
+			for _, row := range e.Rows {
+				for _, cell := range row.Cells {
+					stats.Paragraphs += len(cell.Paragraphs)
+				}
+			}
+		}
+	}
+}
+
+// countTag adds a template tag to stats.
+func countTag(stats *TemplateStats, span tokenSpan) {
+	stats.Tags++
+	if span.Malformed {
+		return
+	}
+	countExpression := func(value string) {
+		if _, err := ParseExpressionStrict(value); err == nil {
+			stats.Expressions++
+		}
+	}
+	switch span.Token.Type {
+	case TokenVariable:
+		stats.Placeholders++
+		countExpression(span.Token.Value)
+	case TokenIf, TokenElsif, TokenUnless:
+		stats.ControlStructures[tokenKeywords[span.Token.Type]]++
+		countExpression(span.Token.Value)
+	case TokenFor:
+		stats.ControlStructures["for"]++
+		if _, err := parseForSyntaxWithExpressionParser(span.Token.Value, ParseExpressionStrict); err == nil {
+			stats.Expressions++
+		}
+	case TokenElse:
+		stats.ControlStructures["else"]++
+	case TokenMacro:
+		stats.ControlStructures["macro"]++
+	case TokenBlock:
+		if fields := strings.Fields(span.Token.Value); len(fields) > 0 {
+			stats.ControlStructures[fields[0]]++
+		}
+	case TokenInclude:
+		stats.Includes++
+	}
+}
+
+// tokenKeywords are the keywords of the conditional tags.
+var tokenKeywords = map[TokenType]string{
+	TokenIf:     "if",
+	TokenElsif:  "elsif",
+	TokenUnless: "unless",
+}
@@ -0,0 +1,72 @@
+package stencil
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPreparedTemplateStats(t *testing.T) {
+	paragraph := func(text string) string {
+		return `<w:p><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p>`
+	}
+	cell := func(text string) string {
+		return `<w:tc>` + paragraph(text) + `</w:tc>`
+	}
+	docx := createDOCXWithBodyXML(t, paragraph("Dear {{name}},")+
+		paragraph("{{if vip}}Thanks for your loyalty{{elsif new}}Welcome{{else}}Hello{{end}}")+
+		`<w:tbl><w:tblGrid><w:gridCol w:w="4000"/><w:gridCol w:w="2000"/></w:tblGrid>`+
+		`<w:tr>`+cell("{{for line in lines}}")+cell("")+`</w:tr>`+
+		`<w:tr>`+cell("{{line.name}}")+cell("{{line.price * 2}}")+`</w:tr>`+
+		`<w:tr>`+cell("{{end}}")+cell("")+`</w:tr></w:tbl>`+
+		paragraph("{{columns 2}}")+
+		paragraph(`{{unless paid}}{{include "reminder"}}{{end}}`)+
+		paragraph("{{end}}")+
+		paragraph("{{broken +}}"))
+
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("reminder", "Please pay."); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := tmpl.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	want := TemplateStats{
+		Paragraphs:   12,
+		Tables:       1,
+		Tags:         15,
+		Placeholders: 4,
+		ControlStructures: map[string]int{
+			"if": 1, "elsif": 1, "else": 1, "for": 1, "columns": 1, "unless": 1,
+		},
+		Includes:    1,
+		Expressions: 7,
+		Fragments:   1,
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+
+	// A template with a replaced document counts the new document
+	doc := tmpl.Document()
+	doc.Body.Elements = doc.Body.Elements[:1]
+	edited, err := tmpl.WithDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edited.Close()
+	if stats, err := edited.Stats(); err != nil || stats.Paragraphs != 1 || stats.Tables != 0 || stats.Tags != 1 {
+		t.Errorf("Stats() of the edited template = %+v, %v", stats, err)
+	}
+
+	tmpl.Close()
+	if _, err := tmpl.Stats(); err == nil {
+		t.Error("expected an error for a closed template")
+	}
+}
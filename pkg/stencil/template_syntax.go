@@ -0,0 +1,513 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TemplateSyntax names the template syntax of another template engine that
+// ConvertTemplateSyntax can convert from.
+type TemplateSyntax string
+
+const (
+	// SyntaxDocxtpl is the Jinja syntax of the Python docxtpl library, such
+	// as {{ name|upper }}, {%p if paid %} and {%tr for item in items %}.
+	SyntaxDocxtpl TemplateSyntax = "docxtpl"
+	// SyntaxDocxtemplater is the syntax of the JavaScript docxtemplater
+	// library, such as {name}, {#items}...{/items} and {^paid}...{/paid}.
+	SyntaxDocxtemplater TemplateSyntax = "docxtemplater"
+)
+
+// SyntaxConversionReport describes the changes ConvertTemplateSyntax made.
+type SyntaxConversionReport struct {
+	// Converted counts the template tags converted.
+	Converted int
+	// Skipped lists the template tags left as they were because they have
+	// no go-stencil counterpart, such as {%tc for col in cols %} or
+	// {{ loop.index }}.
+	Skipped []string
+	// Warnings lists converted tags whose meaning may differ and that
+	// should be reviewed, such as docxtemplater sections, which are
+	// converted to loops.
+	Warnings []string
+}
+
+// docxtplTagPattern matches the variable, statement and comment tags of a
+// docxtpl template.
+var docxtplTagPattern = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{%.*?%\}|\{#.*?#\}`)
+
+// docxtemplaterTagPattern matches the tags of a docxtemplater template and,
+// to leave them alone, go-stencil tags.
+var docxtemplaterTagPattern = regexp.MustCompile(`\{\{.*?\}\}|\{[^{}]*\}`)
+
+// docxtemplaterTagBody matches the body of a docxtemplater tag: an optional
+// section marker and a field path.
+var docxtemplaterTagBody = regexp.MustCompile(`^\s*([#^/]?)\s*([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*|\.)?\s*$`)
+
+// jinjaForPattern matches the head of a Jinja for statement.
+var jinjaForPattern = regexp.MustCompile(`^for\s+([A-Za-z_][A-Za-z0-9_]*)\s+in\s+(.+)$`)
+
+// jinjaFilterPattern matches a Jinja filter with its optional arguments.
+var jinjaFilterPattern = regexp.MustCompile(`(?s)^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:\((.*)\))?\s*$`)
+
+// jinjaFilters maps Jinja filters to go-stencil functions taking the
+// filtered value as their first argument. Filters mapped to "" are dropped.
+var jinjaFilters = map[string]string{
+	"upper":   "uppercase",
+	"lower":   "lowercase",
+	"title":   "titlecase",
+	"length":  "length",
+	"count":   "length",
+	"join":    "join",
+	"replace": "replace",
+	"default": "coalesce",
+	"d":       "coalesce",
+	"int":     "integer",
+	"float":   "decimal",
+	"string":  "str",
+	"sum":     "sum",
+	"e":       "",
+	"escape":  "",
+	"safe":    "",
+}
+
+// ConvertTemplateSyntax rewrites a template written for another template
+// engine into go-stencil syntax, easing the migration of existing
+// templates. The converted template is prepared and rendered like any other
+// go-stencil template.
+//
+// For SyntaxDocxtpl, variables, filters, if/elif/else and for statements
+// are converted, as are the and, or and not operators; comments are
+// removed. Paragraph and table row tags, such as {%p if paid %} and
+// {%tr for item in items %}, replace the paragraph they are in, as in
+// docxtpl. For SyntaxDocxtemplater, {name} fields, {#items}...{/items}
+// sections and {^paid}...{/paid} inverted sections are converted. A section
+// becomes a loop whose variable prefixes the fields of the section, so
+// {#items}{name}{/} becomes {{for item in items}}{{item.name}}{{end}}.
+//
+// Tags that have no go-stencil counterpart are left as they were and listed
+// in SyntaxConversionReport.Skipped. The body, headers, footers, footnotes
+// and endnotes are converted.
+//
+// Example:
+//
+//	converted, report, err := stencil.ConvertTemplateSyntax(docxBytes, stencil.SyntaxDocxtpl)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, tag := range report.Skipped {
+//	    log.Printf("convert by hand: %s", tag)
+//	}
+func ConvertTemplateSyntax(docx []byte, syntax TemplateSyntax) ([]byte, SyntaxConversionReport, error) {
+	var report SyntaxConversionReport
+	var rewrite func(*Paragraph)
+	switch syntax {
+	case SyntaxDocxtpl:
+		rewrite = func(para *Paragraph) {
+			convertDocxtplParagraph(para, &report)
+		}
+	case SyntaxDocxtemplater:
+		var sections []docxtemplaterSection
+		rewrite = func(para *Paragraph) {
+			rewriteParagraphRuns(para, func(runs []Run) []Run {
+				return replaceRunText(runs, docxtemplaterTagPattern, func(tag string) string {
+					return convertDocxtemplaterTag(tag, &sections, &report)
+				})
+			})
+		}
+	default:
+		return nil, report, fmt.Errorf("unknown template syntax %q", syntax)
+	}
+
+	output, err := rewriteTemplateParts(docx, nil, rewrite)
+	if err != nil {
+		return nil, report, err
+	}
+	return output, report, nil
+}
+
+// convertDocxtplParagraph converts the docxtpl tags of para. A paragraph
+// with a paragraph or table row tag is reduced to its template tags.
+func convertDocxtplParagraph(para *Paragraph, report *SyntaxConversionReport) {
+	paragraphLevel := false
+	rewriteParagraphRuns(para, func(runs []Run) []Run {
+		return replaceRunText(runs, docxtplTagPattern, func(tag string) string {
+			converted, prefix, ok := convertDocxtplTag(tag)
+			if !ok {
+				report.Skipped = append(report.Skipped, tag)
+				return tag
+			}
+			report.Converted++
+			if prefix == "p" || prefix == "tr" {
+				paragraphLevel = true
+			}
+			return converted
+		})
+	})
+	if !paragraphLevel {
+		return
+	}
+	rewriteParagraphRuns(para, func(runs []Run) []Run {
+		var result []Run
+		for _, run := range runs {
+			if run.Text != nil {
+				tags := strings.Join(tokenRegex.FindAllString(run.Text.Content, -1), "")
+				if tags == "" && run.Break == nil && len(run.RawXML) == 0 {
+					continue
+				}
+				run.Text = &Text{Space: "preserve", Content: tags}
+			}
+			result = append(result, run)
+		}
+		return result
+	})
+}
+
+// convertDocxtplTag converts a docxtpl tag into go-stencil syntax. It
+// returns the tag prefix, such as "p" of {%p if paid %}, and false if the
+// tag has no go-stencil counterpart.
+func convertDocxtplTag(tag string) (string, string, bool) {
+	if strings.HasPrefix(tag, "{#") {
+		return "", "", true
+	}
+	statement := strings.HasPrefix(tag, "{%")
+	body := strings.TrimPrefix(tag[2:len(tag)-2], "-")
+	body = strings.TrimSuffix(body, "-")
+	prefix := ""
+	for _, p := range []string{"p ", "r ", "tr ", "tc "} {
+		if strings.HasPrefix(body, p) {
+			prefix = strings.TrimSpace(p)
+			body = body[len(p):]
+			break
+		}
+	}
+	body = strings.TrimSpace(body)
+
+	if !statement {
+		// {{p subdoc}} inserts a sub-document and {{tr}} and {{tc}} are
+		// not variables
+		if prefix != "" && prefix != "r" {
+			return "", prefix, false
+		}
+		expr, ok := convertJinjaExpression(body)
+		if !ok {
+			return "", prefix, false
+		}
+		return "{{" + expr + "}}", prefix, true
+	}
+
+	// Column loops have no counterpart
+	if prefix == "tc" {
+		return "", prefix, false
+	}
+	keyword, rest, _ := strings.Cut(body, " ")
+	rest = strings.TrimSpace(rest)
+	switch keyword {
+	case "if", "elif":
+		expr, ok := convertJinjaExpression(rest)
+		if !ok || rest == "" {
+			return "", prefix, false
+		}
+		if keyword == "elif" {
+			keyword = "elsif"
+		}
+		return "{{" + keyword + " " + expr + "}}", prefix, true
+	case "else":
+		return "{{else}}", prefix, rest == ""
+	case "endif", "endfor":
+		return "{{end}}", prefix, rest == ""
+	case "for":
+		match := jinjaForPattern.FindStringSubmatch(body)
+		if match == nil {
+			return "", prefix, false
+		}
+		collection, ok := convertJinjaExpression(match[2])
+		if !ok {
+			return "", prefix, false
+		}
+		return "{{for " + match[1] + " in " + collection + "}}", prefix, true
+	}
+	return "", prefix, false
+}
+
+// convertJinjaExpression converts a Jinja expression with filters, such as
+// name|default("n/a")|upper, into a go-stencil expression. It returns false
+// if the expression uses Jinja features without counterpart.
+func convertJinjaExpression(expr string) (string, bool) {
+	parts := splitJinjaFilters(expr)
+	result, ok := convertJinjaOperators(parts[0])
+	if !ok || strings.TrimSpace(result) == "" {
+		return "", false
+	}
+	for _, filter := range parts[1:] {
+		match := jinjaFilterPattern.FindStringSubmatch(filter)
+		if match == nil {
+			return "", false
+		}
+		function, known := jinjaFilters[match[1]]
+		if !known {
+			return "", false
+		}
+		if function == "" {
+			continue
+		}
+		args := ""
+		if strings.TrimSpace(match[2]) != "" {
+			if args, ok = convertJinjaOperators(match[2]); !ok {
+				return "", false
+			}
+			args = ", " + strings.TrimSpace(args)
+		}
+		result = function + "(" + result + args + ")"
+	}
+	if _, err := ParseExpressionStrict(result); err != nil {
+		return "", false
+	}
+	return result, true
+}
+
+// splitJinjaFilters splits expr at the filter separators outside string
+// literals and brackets.
+func splitJinjaFilters(expr string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == '|' && depth == 0:
+			parts = append(parts, strings.TrimSpace(expr[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(expr[start:]))
+}
+
+// convertJinjaOperators converts the operators and constants of a Jinja
+// expression without filters. Tests (is), membership (in), conditional
+// expressions and the loop variable have no counterpart.
+func convertJinjaOperators(expr string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return "", false
+			}
+			b.WriteString(expr[i : end+1])
+			i = end + 1
+		case identifierRegex.MatchString(expr[i:]):
+			end := i + len(identifierRegex.FindString(expr[i:]))
+			word := expr[i:end]
+			// Field names after a dot are never keywords
+			if i > 0 && expr[i-1] == '.' {
+				b.WriteString(word)
+				i = end
+				continue
+			}
+			switch word {
+			case "and":
+				b.WriteString("&")
+			case "or":
+				b.WriteString("|")
+			case "not":
+				b.WriteString("!")
+				for end < len(expr) && expr[end] == ' ' {
+					end++
+				}
+			case "True", "true":
+				b.WriteString("true")
+			case "False", "false":
+				b.WriteString("false")
+			case "None", "none":
+				b.WriteString("null")
+			case "is", "in", "if", "else", "loop":
+				return "", false
+			default:
+				b.WriteString(word)
+			}
+			i = end
+		case c == '~':
+			b.WriteByte('+')
+			i++
+		case c == '|' || c == '&' || strings.HasPrefix(expr[i:], "**") || strings.HasPrefix(expr[i:], "//"):
+			return "", false
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), true
+}
+
+// docxtemplaterSection is an open docxtemplater section.
+type docxtemplaterSection struct {
+	// name is the name in the section tags, such as items of {#items}.
+	name string
+	// variable is the loop variable of a section, or "" for an inverted
+	// section.
+	variable string
+}
+
+// convertDocxtemplaterTag converts a docxtemplater tag into go-stencil
+// syntax, tracking the open sections in sections.
+func convertDocxtemplaterTag(tag string, sections *[]docxtemplaterSection, report *SyntaxConversionReport) string {
+	if strings.HasPrefix(tag, "{{") {
+		return tag
+	}
+	match := docxtemplaterTagBody.FindStringSubmatch(tag[1 : len(tag)-1])
+	if match == nil || (match[1] != "/" && match[2] == "") {
+		report.Skipped = append(report.Skipped, tag)
+		return tag
+	}
+	marker, name := match[1], match[2]
+
+	// Fields of a section are fields of its current item
+	path := name
+	for i := len(*sections) - 1; i >= 0; i-- {
+		if variable := (*sections)[i].variable; variable != "" {
+			if name == "." {
+				path = variable
+			} else {
+				path = variable + "." + name
+			}
+			break
+		}
+	}
+	if path == "." && marker != "/" {
+		report.Skipped = append(report.Skipped, tag)
+		return tag
+	}
+
+	switch marker {
+	case "#":
+		variable := docxtemplaterLoopVariable(name, *sections)
+		*sections = append(*sections, docxtemplaterSection{name: name, variable: variable})
+		report.Converted++
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s converted to a loop over %s; fields in it are read from %s", tag, path, variable))
+		return "{{for " + variable + " in " + path + "}}"
+	case "^":
+		*sections = append(*sections, docxtemplaterSection{name: name})
+		report.Converted++
+		return "{{unless " + path + "}}"
+	case "/":
+		open := len(*sections) - 1
+		if open < 0 || (name != "" && (*sections)[open].name != name) {
+			report.Skipped = append(report.Skipped, tag)
+			return tag
+		}
+		*sections = (*sections)[:open]
+		report.Converted++
+		return "{{end}}"
+	}
+	report.Converted++
+	return "{{" + path + "}}"
+}
+
+// docxtemplaterLoopVariable names the loop variable of a section over name,
+// such as item for items, unused by the open sections.
+func docxtemplaterLoopVariable(name string, sections []docxtemplaterSection) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	base := name + "Item"
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		base = strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		base = strings.TrimSuffix(name, "s")
+	}
+	variable := base
+	for n := 2; ; n++ {
+		used := variable == name
+		for _, section := range sections {
+			used = used || section.variable == variable || section.name == variable
+		}
+		if !used {
+			return variable
+		}
+		variable = fmt.Sprintf("%s%d", base, n)
+	}
+}
+
+// replaceRunText replaces the matches of pattern in the text of runs with
+// replace(match), also matches spanning several runs. A replacement takes
+// the formatting of the run its match starts in.
+func replaceRunText(runs []Run, pattern *regexp.Regexp, replace func(string) string) []Run {
+	var text strings.Builder
+	for _, run := range runs {
+		if run.Text != nil {
+			text.WriteString(run.Text.Content)
+		}
+	}
+	full := text.String()
+	matches := pattern.FindAllStringIndex(full, -1)
+	if len(matches) == 0 {
+		return runs
+	}
+
+	result := make([]Run, 0, len(runs))
+	offset, m := 0, 0
+	for _, run := range runs {
+		if run.Text == nil {
+			result = append(result, run)
+			continue
+		}
+		start, end := offset, offset+len(run.Text.Content)
+		offset = end
+		var content strings.Builder
+		for pos := start; pos < end; {
+			for m < len(matches) && matches[m][1] <= pos {
+				m++
+			}
+			if m == len(matches) || matches[m][0] >= end {
+				content.WriteString(full[pos:end])
+				break
+			}
+			match := matches[m]
+			if match[0] > pos {
+				content.WriteString(full[pos:match[0]])
+				pos = match[0]
+			}
+			if match[0] == pos {
+				content.WriteString(replace(full[match[0]:match[1]]))
+			}
+			pos = min(match[1], end)
+		}
+		if content.String() == run.Text.Content {
+			result = append(result, run)
+			continue
+		}
+		if content.Len() == 0 {
+			if run.Break == nil && len(run.RawXML) == 0 {
+				continue
+			}
+			run.Text = nil
+		} else {
+			run.Text = &Text{Space: "preserve", Content: content.String()}
+		}
+		result = append(result, run)
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package stencil
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func renderConvertedTemplate(t *testing.T, docx []byte, data TemplateData) string {
+	t.Helper()
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	output, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	content, err := io.ReadAll(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extractTextFromDOCX(t, content)
+}
+
+func TestConvertTemplateSyntaxDocxtpl(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		"Dear {{ customer.name|title }},{# salutation #}",
+		"{%p if vip and not blocked %}",
+		"Thanks, {{ nickname|default('friend')|upper }}!",
+		"{%p elif total > 100 %}",
+		"Big order",
+		"{%p endif %}",
+		"{%p for item in items %}",
+		"{{ item.name }}: {{ item.tags|join(', ') }} ",
+		"{%p endfor %}",
+		"{%tc for col in columns %}",
+	})
+
+	converted, report, err := ConvertTemplateSyntax(docx, SyntaxDocxtpl)
+	if err != nil {
+		t.Fatalf("ConvertTemplateSyntax failed: %v", err)
+	}
+	if report.Converted != 10 {
+		t.Errorf("Converted = %d, want 10", report.Converted)
+	}
+	if want := []string{"{%tc for col in columns %}"}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("Skipped = %q, want %q", report.Skipped, want)
+	}
+	text := html.UnescapeString(extractTextFromDOCX(t, converted))
+	for _, want := range []string{
+		"Dear {{titlecase(customer.name)}},",
+		"{{if vip & !blocked}}",
+		`{{uppercase(coalesce(nickname, 'friend'))}}`,
+		"{{elsif total > 100}}",
+		"{{for item in items}}",
+		"{{join(item.tags, ', ')}}",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("converted text missing %q:\n%s", want, text)
+		}
+	}
+
+	rendered := renderConvertedTemplate(t, converted, TemplateData{
+		"customer": map[string]interface{}{"name": "ada lovelace"},
+		"vip":      true,
+		"blocked":  false,
+		"items": []interface{}{
+			map[string]interface{}{"name": "Pen", "tags": []string{"blue", "fine"}},
+		},
+	})
+	if want := "Dear Ada Lovelace,Thanks, FRIEND!Pen: blue, fine {%tc for col in columns %}"; rendered != want {
+		t.Errorf("rendered text = %q, want %q", rendered, want)
+	}
+}
+
+func TestConvertTemplateSyntaxAcrossRuns(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p>`+
+		`<w:r><w:t xml:space="preserve">Hello {{ cus</w:t></w:r>`+
+		`<w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">tomer }} and {%r if vip %}VIP{%r endif %}</w:t></w:r>`+
+		`</w:p>`)
+
+	converted, report, err := ConvertTemplateSyntax(docx, SyntaxDocxtpl)
+	if err != nil {
+		t.Fatalf("ConvertTemplateSyntax failed: %v", err)
+	}
+	if report.Converted != 3 || len(report.Skipped) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if text := extractTextFromDOCX(t, converted); text != "Hello {{customer}} and {{if vip}}VIP{{end}}" {
+		t.Errorf("converted text = %q", text)
+	}
+	if rendered := renderConvertedTemplate(t, converted, TemplateData{"customer": "Ada", "vip": true}); rendered != "Hello Ada and VIP" {
+		t.Errorf("rendered text = %q", rendered)
+	}
+}
+
+func TestConvertTemplateSyntaxDocxtemplater(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		"Invoice for { user.name }",
+		"{#orders}Order {id}: {#lines}{sku}{/lines}{/orders}",
+		"{^paid}Payment due{/paid} {@footer} {{kept}}",
+	})
+
+	converted, report, err := ConvertTemplateSyntax(docx, SyntaxDocxtemplater)
+	if err != nil {
+		t.Fatalf("ConvertTemplateSyntax failed: %v", err)
+	}
+	if report.Converted != 9 {
+		t.Errorf("Converted = %d, want 9", report.Converted)
+	}
+	if want := []string{"{@footer}"}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("Skipped = %q, want %q", report.Skipped, want)
+	}
+	if len(report.Warnings) != 2 {
+		t.Errorf("Warnings = %q, want one per section", report.Warnings)
+	}
+	text := extractTextFromDOCX(t, converted)
+	if want := "Invoice for {{user.name}}" +
+		"{{for order in orders}}Order {{order.id}}: {{for line in order.lines}}{{line.sku}}{{end}}{{end}}" +
+		"{{unless paid}}Payment due{{end}} {@footer} {{kept}}"; text != want {
+		t.Errorf("converted text = %q, want %q", text, want)
+	}
+
+	rendered := renderConvertedTemplate(t, converted, TemplateData{
+		"user": map[string]interface{}{"name": "Ada"},
+		"orders": []interface{}{
+			map[string]interface{}{"id": 7, "lines": []interface{}{
+				map[string]interface{}{"sku": "A1"},
+				map[string]interface{}{"sku": "B2"},
+			}},
+		},
+		"paid": false,
+		"kept": "ok",
+	})
+	if want := "Invoice for AdaOrder 7: A1B2Payment due {@footer} ok"; rendered != want {
+		t.Errorf("rendered text = %q, want %q", rendered, want)
+	}
+}
+
+func TestConvertTemplateSyntaxUnknown(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{"{{name}}"})
+	if _, _, err := ConvertTemplateSyntax(docx, "mustache"); err == nil {
+		t.Error("expected an error for an unknown syntax")
+	}
+}
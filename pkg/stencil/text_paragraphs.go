@@ -0,0 +1,77 @@
+package stencil
+
+import (
+	"regexp"
+	"strings"
+)
+
+// blankLineRegex matches the blank lines separating the paragraphs of a
+// text.
+var blankLineRegex = regexp.MustCompile(`\n[ \t]*\n`)
+
+// textParagraphs is the content of paragraphs(): the paragraphs of a
+// multi-line text. They take the properties of the paragraph of the call,
+// so they keep its style.
+type textParagraphs struct {
+	paragraphs []string
+}
+
+// splitParagraphs splits a text for paragraphs(text) into paragraphs at
+// blank lines. The other line endings become line breaks within the
+// paragraphs.
+func splitParagraphs(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	text := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(FormatValue(args[0]))
+
+	content := &textParagraphs{}
+	for _, paragraph := range blankLineRegex.Split(text, -1) {
+		if paragraph = strings.Trim(paragraph, "\n"); strings.TrimSpace(paragraph) != "" {
+			content.paragraphs = append(content.paragraphs, paragraph)
+		}
+	}
+	if len(content.paragraphs) == 0 {
+		return "", nil
+	}
+	return &OOXMLFragment{Content: content}, nil
+}
+
+// elements returns the paragraphs of the text with the paragraph and run
+// properties of base, the paragraph of the call. Base may be nil.
+func (p *textParagraphs) elements(base *Paragraph) []BodyElement {
+	template := &Paragraph{}
+	var runProps *RunProperties
+	if base != nil {
+		template.Properties = base.Properties
+		template.Attrs = base.Attrs
+		for i := range base.Runs {
+			if base.Runs[i].Text != nil {
+				runProps = base.Runs[i].Properties
+				break
+			}
+		}
+	}
+
+	elements := make([]BodyElement, 0, len(p.paragraphs))
+	for _, paragraph := range p.paragraphs {
+		para := &Paragraph{Properties: template.Properties, Attrs: template.Attrs}
+		for i, line := range strings.Split(paragraph, "\n") {
+			if i > 0 {
+				para.Runs = append(para.Runs, Run{Properties: runProps, Break: &Break{}})
+			}
+			if line != "" {
+				para.Runs = append(para.Runs, Run{Properties: runProps, Text: &Text{Content: line, Space: "preserve"}})
+			}
+		}
+		elements = append(elements, para)
+	}
+	return elements
+}
+
+// registerParagraphsFunction registers the paragraphs() function
+func registerParagraphsFunction(registry *DefaultFunctionRegistry) {
+	// paragraphs() function - splits a multi-line text into paragraphs
+	paragraphsFn := NewSimpleFunction("paragraphs", 1, 1, splitParagraphs)
+	registry.RegisterFunction(paragraphsFn)
+}
@@ -0,0 +1,53 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitParagraphs(t *testing.T) {
+	result, err := splitParagraphs("First paragraph.\r\n\r\nSecond paragraph,\nsecond line.\n \n\n\nThird paragraph.\n")
+	if err != nil {
+		t.Fatalf("paragraphs() error = %v", err)
+	}
+	got := result.(*OOXMLFragment).Content.(*textParagraphs).paragraphs
+	want := []string{"First paragraph.", "Second paragraph,\nsecond line.", "Third paragraph."}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("paragraphs = %q, want %q", got, want)
+	}
+
+	for _, empty := range []interface{}{nil, "", "\n\n  \n"} {
+		if result, err := splitParagraphs(empty); result != "" || err != nil {
+			t.Errorf("paragraphs(%q) = %v, %v, want nothing", empty, result, err)
+		}
+	}
+}
+
+func TestParagraphsInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t,
+		`<w:p><w:pPr><w:pStyle w:val="LegalText"/></w:pPr><w:r><w:rPr><w:i/></w:rPr><w:t>{{paragraphs(customText)}}</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t>After</w:t></w:r></w:p>`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"customText": "Sample clause.\n\nThis shows multi-line\ntext.\n\nAdditional paragraph.",
+	}))
+	if got := strings.Count(documentXML, `<w:pStyle w:val="LegalText">`); got != 3 {
+		t.Errorf("expected 3 paragraphs with the style of the placeholder, got %d: %s", got, documentXML)
+	}
+	if got := strings.Count(documentXML, "<w:i/>"); got != 5 {
+		t.Errorf("expected 5 italic runs, got %d: %s", got, documentXML)
+	}
+	for _, want := range []string{">Sample clause.<", ">This shows multi-line<", "<w:br>", ">text.<", ">Additional paragraph.<", ">After<"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %s in output: %s", want, documentXML)
+		}
+	}
+	if strings.Contains(documentXML, "{{") {
+		t.Errorf("template markers left in output: %s", documentXML)
+	}
+}
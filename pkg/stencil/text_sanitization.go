@@ -0,0 +1,135 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextSanitization configures how WithTextSanitization cleans up the text
+// of the values a template outputs.
+type TextSanitization struct {
+	// LineBreaks renders line endings (\n, \r\n and \r), vertical tabs,
+	// form feeds and the Unicode line and paragraph separators as line
+	// breaks. Otherwise they become spaces.
+	LineBreaks bool
+	// TabWidth expands each tab into spaces up to the next multiple of
+	// TabWidth columns. When zero, tabs become tab stops.
+	TabWidth int
+}
+
+// WithTextSanitization returns an option that cleans up the text of the
+// values templates output, such as data sourced from user input. Characters
+// XML does not allow, such as NUL and the other control characters, and
+// invalid UTF-8 are removed; line endings and tabs are rendered as
+// configured by sanitization.
+//
+// Example:
+//
+//	engine := stencil.NewWithOptions(stencil.WithTextSanitization(stencil.TextSanitization{LineBreaks: true}))
+func WithTextSanitization(sanitization TextSanitization) Option {
+	return func(e *Engine) {
+		e.data.mu.Lock()
+		e.data.sanitization = &sanitization
+		e.data.mu.Unlock()
+	}
+}
+
+// textSanitization returns the sanitization set with WithTextSanitization,
+// or nil.
+func (d *engineData) textSanitization() *TextSanitization {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sanitization
+}
+
+// formatText formats value as text, sanitized when the engine sanitizes
+// text. Line breaks and tab stops become OOXML fragment placeholders, which
+// expand into runs with the paragraph.
+func (ctx *renderContext) formatText(value interface{}) string {
+	text := FormatValue(value)
+	if ctx == nil || ctx.sanitization == nil || ctx.ooxmlFragments == nil {
+		return text
+	}
+	sanitized := sanitizeText(text, *ctx.sanitization)
+	if !strings.ContainsAny(sanitized, "\n\t") {
+		return sanitized
+	}
+
+	var result strings.Builder
+	for _, r := range sanitized {
+		switch r {
+		case '\n':
+			result.WriteString(ctx.fragmentPlaceholder(&Break{}))
+		case '\t':
+			result.WriteString(ctx.fragmentPlaceholder(&Run{RawXML: []RawXMLElement{{
+				XMLName: xml.Name{Local: "tab"},
+				Content: []byte(`<w:tab/>`),
+			}}}))
+		default:
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+// fragmentPlaceholder stores content as an OOXML fragment of the render and
+// returns its placeholder.
+func (ctx *renderContext) fragmentPlaceholder(content interface{}) string {
+	key := fmt.Sprintf("fragment_%d", len(ctx.ooxmlFragments))
+	ctx.ooxmlFragments[key] = content
+	return fmt.Sprintf("{{OOXML_FRAGMENT:%s}}", key)
+}
+
+// sanitizeText removes the characters XML does not allow from text and
+// normalizes its line endings to \n, or to spaces without line breaks.
+// Tabs are expanded into spaces with a tab width and kept otherwise.
+func sanitizeText(text string, sanitization TextSanitization) string {
+	var result strings.Builder
+	result.Grow(len(text))
+	column := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size == 1:
+			continue
+		case r == '\r' && i < len(text) && text[i] == '\n':
+			continue
+		case r == '\n' || r == '\r' || r == '\v' || r == '\f' || r == '\u2028' || r == '\u2029':
+			if sanitization.LineBreaks {
+				result.WriteByte('\n')
+			} else {
+				result.WriteByte(' ')
+			}
+			column = 0
+			continue
+		case r == '\t':
+			if sanitization.TabWidth > 0 {
+				spaces := sanitization.TabWidth - column%sanitization.TabWidth
+				result.WriteString(strings.Repeat(" ", spaces))
+				column += spaces
+			} else {
+				result.WriteByte('\t')
+			}
+			continue
+		case !isXMLChar(r):
+			continue
+		}
+		result.WriteRune(r)
+		column++
+	}
+	return result.String()
+}
+
+// isXMLChar reports whether r is allowed in XML 1.0 documents.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
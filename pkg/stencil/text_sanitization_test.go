@@ -0,0 +1,104 @@
+package stencil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		sanitization TextSanitization
+		want         string
+	}{
+		{"line endings", "a\r\nb\rc\vd\fe f", TextSanitization{LineBreaks: true}, "a\nb\nc\nd\ne\nf"},
+		{"line endings as spaces", "a\r\nb\nc", TextSanitization{}, "a b c"},
+		{"control characters", "a\x00b\x1bc\x7f\uFFFEd", TextSanitization{}, "abc\x7fd"},
+		{"invalid UTF-8", "a\xffb", TextSanitization{}, "ab"},
+		{"tab stops", "a\tb", TextSanitization{}, "a\tb"},
+		{"expanded tabs", "ab\tc\n\td", TextSanitization{LineBreaks: true, TabWidth: 4}, "ab  c\n    d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeText(tt.input, tt.sanitization); got != tt.want {
+				t.Errorf("sanitizeText(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithTextSanitization(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Note: {{note}}`,
+		`{{if show}}Inline: {{note}}{{end}}`,
+	})
+	data := TemplateData{"note": "first\r\nsecond\x0bthird\x00\tend", "show": true}
+
+	engine := NewWithOptions(WithTextSanitization(TextSanitization{LineBreaks: true}))
+	tmpl, err := engine.Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, data))
+	for _, want := range []string{">first<", ">second<", ">third<", ">end<", "<w:tab/>"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %s in output: %s", want, documentXML)
+		}
+	}
+	if got := strings.Count(documentXML, "<w:br>"); got != 4 {
+		t.Errorf("expected 4 line breaks, got %d: %s", got, documentXML)
+	}
+	if strings.Contains(documentXML, "OOXML_FRAGMENT") || strings.Contains(documentXML, "\uFFFD") {
+		t.Errorf("unexpected placeholder or replacement character in output: %s", documentXML)
+	}
+
+	// Without the option the text is written as is
+	tmpl, err = Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, data)); strings.Contains(documentXML, "<w:br>") {
+		t.Errorf("unexpected line breaks without sanitization: %s", documentXML)
+	}
+}
+
+func TestRenderEscapesSpecialCharactersInDataAndTextFragments(t *testing.T) {
+	docx := createDOCXWithParagraphs(t, []string{
+		`Name: {{name}}`,
+		`{{include "note"}}`,
+	})
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("note", "\uFEFFTerms & <conditions>\x00 apply here\r\nok"); err != nil {
+		t.Fatalf("failed to add fragment: %v", err)
+	}
+
+	data := TemplateData{"name": "\uFEFFSmith & Sons <Ltd>\x01 \"Don\u00ADau\" \xed\xa0\x80"}
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, data))
+	if err := xml.Unmarshal([]byte(documentXML), new(struct{})); err != nil {
+		t.Fatalf("rendered document is not valid XML: %v\n%s", err, documentXML)
+	}
+	for _, want := range []string{
+		"Smith &amp; Sons &lt;Ltd&gt;",
+		"Don\u00ADau",
+		"Terms &amp; &lt;conditions&gt; apply here",
+	} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("expected %q in output: %s", want, documentXML)
+		}
+	}
+	for _, unwanted := range []string{"\uFEFF", "\uFFFD", "\x00", "\x01", "&amp;amp;", "&#xD;"} {
+		if strings.Contains(documentXML, unwanted) {
+			t.Errorf("unexpected %q in output: %s", unwanted, documentXML)
+		}
+	}
+}
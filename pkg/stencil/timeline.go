@@ -0,0 +1,149 @@
+package stencil
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// timelineBarColor is the fill of the cells a task spans.
+	timelineBarColor = "4472C4"
+	// timelineNameWidth is the width of the task name column in twips.
+	timelineNameWidth = 2250
+	// timelineTableWidth is the total width of a timeline table in twips.
+	timelineTableWidth = 9000
+	// maxTimelineColumns limits the number of period columns.
+	maxTimelineColumns = 60
+)
+
+// timelinePeriod is a column of a timeline: the days from start up to, but
+// not including, end.
+type timelinePeriod struct {
+	start time.Time
+	end   time.Time
+	label string
+}
+
+// timeline builds a Gantt-style table for timeline(tasks, startDate,
+// endDate, locale): one row per task with its name, and one column per day,
+// week or month between the dates, depending on the length of the range.
+// The cells of the periods a task overlaps are filled. Each task is a map
+// with a "name", a "start" and an optional "end" date; without an end it
+// covers its start day.
+func timeline(args ...interface{}) (interface{}, error) {
+	from, err := parseDate(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("timeline: start date: %w", err)
+	}
+	to, err := parseDate(args[2])
+	if err != nil {
+		return nil, fmt.Errorf("timeline: end date: %w", err)
+	}
+	from, to = truncateToDay(from), truncateToDay(to)
+	if to.Before(from) {
+		return nil, fmt.Errorf("timeline: end date %s is before start date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	locale := ""
+	if len(args) > 3 && args[3] != nil {
+		locale = FormatValue(args[3])
+	}
+
+	var items []interface{}
+	if args[0] != nil {
+		items, err = toSlice(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("timeline: tasks must be a list, got %T", args[0])
+		}
+	}
+
+	periods := timelinePeriods(from, to, locale)
+	if len(periods) > maxTimelineColumns {
+		return nil, fmt.Errorf("timeline: the range needs %d columns, more than the limit of %d", len(periods), maxTimelineColumns)
+	}
+
+	table := NewTable(len(items)+1, len(periods)+1)
+	periodWidth := (timelineTableWidth - timelineNameWidth) / len(periods)
+	table.Grid.Columns[0].Width = timelineNameWidth
+	for i := range periods {
+		table.Grid.Columns[i+1].Width = periodWidth
+	}
+	for row := range table.Rows {
+		for col := range table.Rows[row].Cells {
+			width := periodWidth
+			if col == 0 {
+				width = timelineNameWidth
+			}
+			table.Rows[row].Cells[col].Properties.Width.Val = width
+		}
+	}
+
+	header := ParagraphOptions{Alignment: "center", Run: RunProps{Bold: true, Size: 8}}
+	for i, period := range periods {
+		table.Cell(0, i+1).SetText(period.label, header)
+	}
+
+	for i, item := range items {
+		name := accessMapField(item, "name")
+		if name == nil {
+			name = accessMapField(item, "title")
+		}
+		table.Cell(i+1, 0).SetText(FormatValue(name), ParagraphOptions{})
+
+		start, err := parseDate(accessMapField(item, "start"))
+		if err != nil {
+			return nil, fmt.Errorf("timeline: task %d start: %w", i, err)
+		}
+		end := start
+		if value := accessMapField(item, "end"); value != nil {
+			if end, err = parseDate(value); err != nil {
+				return nil, fmt.Errorf("timeline: task %d end: %w", i, err)
+			}
+		}
+		start, end = truncateToDay(start), truncateToDay(end)
+
+		for j, period := range periods {
+			if start.Before(period.end) && !end.Before(period.start) {
+				table.Cell(i+1, j+1).Properties.Shading = &Shading{Val: "clear", Color: "auto", Fill: timelineBarColor}
+			}
+		}
+	}
+
+	return &OOXMLFragment{Content: table}, nil
+}
+
+// timelinePeriods divides the days from one date to another into columns:
+// days for up to a month, weeks starting on Monday for up to half a year,
+// and months beyond that.
+func timelinePeriods(from, to time.Time, locale string) []timelinePeriod {
+	days := int(to.Sub(from).Hours()/24) + 1
+	var periods []timelinePeriod
+
+	switch {
+	case days <= 31:
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			periods = append(periods, timelinePeriod{start: day, end: day.AddDate(0, 0, 1), label: strconv.Itoa(day.Day())})
+		}
+	case days <= 183:
+		monday := from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		for week := monday; !week.After(to); week = week.AddDate(0, 0, 7) {
+			_, number := week.ISOWeek()
+			periods = append(periods, timelinePeriod{start: week, end: week.AddDate(0, 0, 7), label: "W" + strconv.Itoa(number)})
+		}
+	default:
+		first := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+		for month := first; !month.After(to); month = month.AddDate(0, 1, 0) {
+			label := localizedMonth(month, locale, true)
+			if month.Month() == time.January || month.Equal(first) {
+				label += " " + strconv.Itoa(month.Year())
+			}
+			periods = append(periods, timelinePeriod{start: month, end: month.AddDate(0, 1, 0), label: label})
+		}
+	}
+	return periods
+}
+
+// truncateToDay returns midnight at the start of the day of t.
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
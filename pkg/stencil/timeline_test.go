@@ -0,0 +1,84 @@
+//go:build !stencil_nocharts && !stencil_lite
+
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	tasks := []interface{}{
+		map[string]interface{}{"name": "Design", "start": "2026-03-02", "end": "2026-03-04"},
+		map[string]interface{}{"name": "Launch", "start": "2026-03-06"},
+		map[string]interface{}{"title": "Later", "start": "2026-05-01", "end": "2026-05-02"},
+	}
+
+	result, err := timeline(tasks, "2026-03-01", "2026-03-07")
+	if err != nil {
+		t.Fatalf("timeline() error = %v", err)
+	}
+	table := result.(*OOXMLFragment).Content.(*Table)
+	if len(table.Rows) != 4 || len(table.Rows[0].Cells) != 8 {
+		t.Fatalf("expected 4 rows of 8 cells, got %d rows of %d", len(table.Rows), len(table.Rows[0].Cells))
+	}
+	if got := table.Cell(0, 1).Paragraphs[0].GetText(); got != "1" {
+		t.Errorf("first day label = %q, want 1", got)
+	}
+
+	bars := func(row int) string {
+		var b strings.Builder
+		for col := 1; col < len(table.Rows[row].Cells); col++ {
+			if table.Cell(row, col).Properties.Shading != nil {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		return b.String()
+	}
+	for row, want := range map[int]string{1: ".###...", 2: ".....#.", 3: "......."} {
+		if got := bars(row); got != want {
+			t.Errorf("row %d bars = %s, want %s", row, got, want)
+		}
+	}
+	if got := table.Cell(3, 0).Paragraphs[0].GetText(); got != "Later" {
+		t.Errorf("task name = %q, want Later", got)
+	}
+
+	// Longer ranges use weeks and months
+	result, _ = timeline(tasks, "2026-03-01", "2026-04-30")
+	if got := result.(*OOXMLFragment).Content.(*Table).Cell(0, 1).Paragraphs[0].GetText(); got != "W9" {
+		t.Errorf("first week label = %q, want W9", got)
+	}
+	result, _ = timeline(tasks, "2025-11-15", "2026-06-30", "de")
+	table = result.(*OOXMLFragment).Content.(*Table)
+	for col, want := range map[int]string{1: "Nov 2025", 2: "Dez", 3: "Jan 2026", 5: "Mär"} {
+		if got := table.Cell(0, col).Paragraphs[0].GetText(); got != want {
+			t.Errorf("month label %d = %q, want %q", col, got, want)
+		}
+	}
+
+	if _, err := timeline(tasks, "2026-03-07", "2026-03-01"); err == nil {
+		t.Error("expected an error for an end date before the start date")
+	}
+	if _, err := timeline(tasks, "2000-01-01", "2026-01-01"); err == nil {
+		t.Error("expected an error for too many columns")
+	}
+}
+
+func TestTimelineInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{`{{timeline(tasks, "2026-03-01", "2026-03-10")}}`})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	documentXML := extractDocumentXMLFromDOCX(t, renderPreparedToBytes(t, tmpl, TemplateData{
+		"tasks": []interface{}{map[string]interface{}{"name": "Build", "start": "2026-03-03", "end": "2026-03-05"}},
+	}))
+	if !strings.Contains(documentXML, ">Build<") || strings.Count(documentXML, `w:fill="4472C4"`) != 3 {
+		t.Errorf("expected a task row with a three day bar: %s", documentXML)
+	}
+}
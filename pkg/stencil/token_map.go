@@ -0,0 +1,233 @@
+package stencil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// anchorSourceAttr marks a rendered paragraph with the index of the template
+// paragraph it was rendered from. It is removed before the document is
+// written.
+const anchorSourceAttr = "stencil-anchor-source"
+
+// AnchorPositions maps the AnchorID of each token of the main document to
+// the indexes of the paragraphs of the rendered main document that the
+// token's paragraph was rendered into, in ascending order. Indexes count
+// from 0 in document order and include the paragraphs of tables, like
+// TemplateLocation.ParagraphIndex.
+type AnchorPositions map[string][]int
+
+// TokenMap returns the well-formed template tags of the main document,
+// headers, footers and notes in document order, with the location and
+// AnchorID that validation reports for them. Pass
+// RenderOptions.OnAnchorPositions to find the paragraphs of a rendered
+// document each tag of the main document ended up in, such as to highlight
+// where a data value was placed in a review UI.
+//
+// Example:
+//
+//	tokens, err := tmpl.TokenMap()
+//	var positions stencil.AnchorPositions
+//	output, err := tmpl.RenderWithOptions(data, stencil.RenderOptions{
+//	    OnAnchorPositions: func(p stencil.AnchorPositions) { positions = p },
+//	})
+//	for _, token := range tokens {
+//	    highlight(token.Expression, positions[token.Location.AnchorID])
+//	}
+func (pt *PreparedTemplate) TokenMap() ([]TemplateTokenRef, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("invalid template")
+	}
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	if pt.closed || pt.template == nil {
+		return nil, errTemplateClosed
+	}
+	tmpl := pt.template
+	tmpl.mu.RLock()
+	defer tmpl.mu.RUnlock()
+
+	spans, err := templateTokenSpans(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]TemplateTokenRef, 0, len(spans))
+	for _, span := range spans {
+		if span.Malformed || span.Token.Type == TokenText || span.Token.Type == TokenComment {
+			continue
+		}
+		span.TokenOrdinal = len(tokens)
+		kind := TokenKindControl
+		if span.Token.Type == TokenVariable {
+			kind = TokenKindVariable
+		}
+		tokens = append(tokens, TemplateTokenRef{
+			Raw:        span.Raw,
+			Kind:       kind,
+			Expression: span.Token.Value,
+			Location:   locationFromSpan(span),
+		})
+	}
+	return tokens, nil
+}
+
+// templateTokenSpans returns the token spans of the main document as the
+// template renders it, followed by those of the other parts of its
+// package. The caller must hold tmpl.mu.
+func templateTokenSpans(tmpl *template) ([]tokenSpan, error) {
+	var spans []tokenSpan
+	if tmpl.document != nil && tmpl.document.Body != nil {
+		spans = scanDocumentBodyTokenSpans("word/document.xml", tmpl.document.Body, 0)
+	}
+	if len(tmpl.source) > 0 {
+		partSpans, err := scanDOCXTokenSpans(tmpl.source)
+		if err != nil {
+			return nil, err
+		}
+		for _, span := range partSpans {
+			if span.Part != "word/document.xml" {
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans, nil
+}
+
+// anchorTracker follows the paragraphs of the main document that hold
+// tokens through a render for RenderOptions.OnAnchorPositions.
+type anchorTracker struct {
+	// sources maps the paragraphs of the template body, and of the clones
+	// of its tables, to their paragraph index
+	sources map[*Paragraph]int
+	// anchors holds the anchor IDs of the tokens of each paragraph index
+	anchors map[int][]string
+	// positions is set once the main document is rendered
+	positions AnchorPositions
+}
+
+func newAnchorTracker(doc *Document) *anchorTracker {
+	tracker := &anchorTracker{
+		sources:   make(map[*Paragraph]int),
+		anchors:   make(map[int][]string),
+		positions: make(AnchorPositions),
+	}
+	if doc == nil || doc.Body == nil {
+		return tracker
+	}
+	index := 0
+	track := func(para *Paragraph) {
+		for _, span := range scanParagraphTokenSpans("word/document.xml", index, para, 0) {
+			if !span.Malformed {
+				tracker.anchors[index] = append(tracker.anchors[index], span.AnchorID)
+			}
+		}
+		if len(tracker.anchors[index]) > 0 {
+			tracker.sources[para] = index
+		}
+		index++
+	}
+	for _, elem := range doc.Body.Elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			track(e)
+		case *Table:
+			forEachTableParagraph(e, track)
+		}
+	}
+	return tracker
+}
+
+// forEachTableParagraph calls fn for the paragraphs of the cells of table
+// in document order.
+func forEachTableParagraph(table *Table, fn func(*Paragraph)) {
+	for i := range table.Rows {
+		for j := range table.Rows[i].Cells {
+			cell := &table.Rows[i].Cells[j]
+			for k := range cell.Paragraphs {
+				fn(&cell.Paragraphs[k])
+			}
+		}
+	}
+}
+
+// trackTable makes the paragraphs of clone, a copy of source made for
+// rendering, stand for those of source.
+func (a *anchorTracker) trackTable(source, clone *Table) {
+	if a == nil {
+		return
+	}
+	var clones []*Paragraph
+	forEachTableParagraph(clone, func(para *Paragraph) {
+		clones = append(clones, para)
+	})
+	i := 0
+	forEachTableParagraph(source, func(para *Paragraph) {
+		if index, ok := a.sources[para]; ok && i < len(clones) {
+			a.sources[clones[i]] = index
+		}
+		i++
+	})
+}
+
+// trackAnchorTable makes the paragraphs of clone stand for those of source
+// for RenderOptions.OnAnchorPositions.
+func (ctx *renderContext) trackAnchorTable(source, clone *Table) {
+	if ctx != nil {
+		ctx.anchors.trackTable(source, clone)
+	}
+}
+
+// markAnchorSource records that rendered was rendered from source for
+// RenderOptions.OnAnchorPositions.
+func (ctx *renderContext) markAnchorSource(source, rendered *Paragraph) {
+	if ctx != nil {
+		ctx.anchors.mark(source, rendered)
+	}
+}
+
+// mark records that rendered was rendered from source.
+func (a *anchorTracker) mark(source, rendered *Paragraph) {
+	if a == nil || rendered == nil {
+		return
+	}
+	index, ok := a.sources[source]
+	if !ok {
+		return
+	}
+	// The attributes may be shared with the template
+	attrs := rendered.Attrs[:len(rendered.Attrs):len(rendered.Attrs)]
+	rendered.Attrs = append(attrs, xml.Attr{Name: xml.Name{Local: anchorSourceAttr}, Value: strconv.Itoa(index)})
+}
+
+// collect records the positions of the paragraphs of the rendered main
+// document body and removes their marks.
+func (a *anchorTracker) collect(body *Body) {
+	if a == nil || body == nil {
+		return
+	}
+	index := 0
+	visit := func(para *Paragraph) {
+		for i, attr := range para.Attrs {
+			if attr.Name.Space != "" || attr.Name.Local != anchorSourceAttr {
+				continue
+			}
+			if source, err := strconv.Atoi(attr.Value); err == nil {
+				for _, anchor := range a.anchors[source] {
+					a.positions[anchor] = append(a.positions[anchor], index)
+				}
+			}
+			para.Attrs = append(para.Attrs[:i:i], para.Attrs[i+1:]...)
+			break
+		}
+		index++
+	}
+	for _, elem := range body.Elements {
+		switch e := elem.(type) {
+		case *Paragraph:
+			visit(e)
+		case *Table:
+			forEachTableParagraph(e, visit)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package stencil
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenMapAndAnchorPositions(t *testing.T) {
+	docx := createDOCXWithBodyXML(t, `<w:p><w:r><w:t>Dear {{name}},</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{for item in items}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>Item: {{item}}</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>{{end}}</w:t></w:r></w:p>`+
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Total: {{total}}</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`)
+	tmpl, err := Prepare(bytes.NewReader(docx))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+
+	tokens, err := tmpl.TokenMap()
+	if err != nil {
+		t.Fatalf("TokenMap failed: %v", err)
+	}
+	var summary []string
+	anchors := make(map[string]string)
+	for i, token := range tokens {
+		if token.Location.TokenOrdinal != i || token.Location.AnchorID == "" {
+			t.Errorf("token %d has location %+v", i, token.Location)
+		}
+		summary = append(summary, string(token.Kind)+":"+token.Raw)
+		anchors[token.Raw] = token.Location.AnchorID
+	}
+	want := "variable:{{name}} control:{{for item in items}} variable:{{item}} control:{{end}} variable:{{total}}"
+	if got := strings.Join(summary, " "); got != want {
+		t.Fatalf("tokens = %s, want %s", got, want)
+	}
+
+	references, err := ExtractReferences(ExtractReferencesInput{DocxBytes: docx})
+	if err != nil {
+		t.Fatalf("ExtractReferences failed: %v", err)
+	}
+	for _, ref := range references.References {
+		if anchor := anchors[ref.Raw]; ref.Location.AnchorID != anchor {
+			t.Errorf("reference %s has anchor %s, TokenMap has %s", ref.Raw, ref.Location.AnchorID, anchor)
+		}
+	}
+
+	var positions AnchorPositions
+	output, err := tmpl.RenderWithOptions(TemplateData{
+		"name":  "Ada",
+		"items": []interface{}{"a", "b"},
+		"total": 3,
+	}, RenderOptions{OnAnchorPositions: func(p AnchorPositions) { positions = p }})
+	if err != nil {
+		t.Fatalf("RenderWithOptions failed: %v", err)
+	}
+	wantPositions := AnchorPositions{
+		anchors["{{name}}"]:  {0},
+		anchors["{{item}}"]:  {1, 2},
+		anchors["{{total}}"]: {3},
+	}
+	if !reflect.DeepEqual(positions, wantPositions) {
+		t.Errorf("positions = %v, want %v", positions, wantPositions)
+	}
+
+	var buf bytes.Buffer
+	buf.ReadFrom(output)
+	if documentXML := extractDocumentXMLFromDOCX(t, buf.Bytes()); strings.Contains(documentXML, anchorSourceAttr) {
+		t.Errorf("rendered document keeps the anchor marks: %s", documentXML)
+	}
+}
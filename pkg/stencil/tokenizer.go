@@ -3,6 +3,8 @@ package stencil
 import (
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // TokenType represents the type of a template token
@@ -19,6 +21,10 @@ const (
 	TokenEnd
 	TokenPageBreak
 	TokenInclude
+	TokenBlock    // opening tag of a registered block directive
+	TokenMacro    // {{macro name(params)}}
+	TokenEndMacro // {{endmacro}}
+	TokenComment  // {{! comment}}, which renders nothing
 )
 
 // Token represents a parsed template token
@@ -72,7 +78,10 @@ func Tokenize(input string) []Token {
 					"content": content,
 				}).Debug("Found token")
 			}
-			tokens = append(tokens, token)
+			// Comments are for template authors and tools; they render nothing
+			if token.Type != TokenComment {
+				tokens = append(tokens, token)
+			}
 		}
 
 		lastEnd = match[1]
@@ -101,6 +110,14 @@ func parseToken(content string) Token {
 		return Token{Type: TokenText, Value: "{{" + content + "}}"}
 	}
 
+	// {{! text}} is a comment; {{!flag}} stays a negation
+	if isCommentTag(content) {
+		return Token{
+			Type:  TokenComment,
+			Value: strings.TrimSpace(content[1:]),
+		}
+	}
+
 	keyword := parts[0]
 	
 	switch keyword {
@@ -144,7 +161,56 @@ func parseToken(content string) Token {
 			Type:  TokenInclude,
 			Value: strings.TrimSpace(strings.TrimPrefix(content, "include")),
 		}
+	case "macro":
+		header := strings.TrimSpace(strings.TrimPrefix(content, "macro"))
+		if macroHeaderRegex.MatchString(header) {
+			return Token{
+				Type:  TokenMacro,
+				Value: header,
+			}
+		}
+		// A variable named macro
+		return Token{
+			Type:  TokenVariable,
+			Value: content,
+		}
+	case "endmacro":
+		return Token{
+			Type:  TokenEndMacro,
+			Value: "",
+		}
+	case assertFunctionName:
+		// {{assert condition}} is short for {{assert(condition, "condition")}}
+		if condition := strings.TrimSpace(strings.TrimPrefix(content, keyword)); condition != "" {
+			return Token{
+				Type:  TokenVariable,
+				Value: assertTagValue(condition),
+			}
+		}
+		return Token{
+			Type:  TokenVariable,
+			Value: content,
+		}
+	case "clause", "clauseRef", "exhibit", "exhibitRef":
+		// {{clause "id"}} is short for {{clause("id")}}
+		args := strings.TrimSpace(strings.TrimPrefix(content, keyword))
+		if startsWithQuote(args) {
+			return Token{
+				Type:  TokenVariable,
+				Value: keyword + "(" + args + ")",
+			}
+		}
+		return Token{
+			Type:  TokenVariable,
+			Value: content,
+		}
 	default:
+		if _, ok := lookupBlockDirective(keyword); ok && opensBlockDirective(keyword, content) {
+			return Token{
+				Type:  TokenBlock,
+				Value: strings.TrimSpace(content),
+			}
+		}
 		// It's a variable or expression
 		return Token{
 			Type:  TokenVariable,
@@ -153,6 +219,13 @@ func parseToken(content string) Token {
 	}
 }
 
+// startsWithQuote reports whether s starts with a straight or typographic
+// quotation mark, as Word may replace the quotes typed in a tag.
+func startsWithQuote(s string) bool {
+	first, _ := utf8.DecodeRuneInString(s)
+	return strings.ContainsRune(`"'“”„‘’‚«»`, first)
+}
+
 // FindTemplateTokens finds all template tokens in a string
 // This is a utility function for debugging and analysis
 func FindTemplateTokens(input string) []string {
@@ -161,4 +234,15 @@ func FindTemplateTokens(input string) []string {
 		return []string{}
 	}
 	return matches
-}
\ No newline at end of file
+}
+
+// isCommentTag reports whether the content of a tag is a comment: an
+// exclamation mark followed by whitespace, or by nothing.
+func isCommentTag(content string) bool {
+	rest, ok := strings.CutPrefix(content, "!")
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || unicode.IsSpace(r)
+}
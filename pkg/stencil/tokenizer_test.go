@@ -158,6 +158,15 @@ func TestTokenize(t *testing.T) {
 				{Type: TokenInclude, Value: "footer"},
 			},
 		},
+		{
+			name:  "comments render nothing",
+			input: "Hi {{! greeting }}{{name}}{{!}}{{!flag}}",
+			want: []Token{
+				{Type: TokenText, Value: "Hi "},
+				{Type: TokenVariable, Value: "name"},
+				{Type: TokenVariable, Value: "!flag"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,151 @@
+package stencil
+
+import "context"
+
+// Span names and attribute keys reported to a Tracer.
+const (
+	spanPrepare            = "stencil.prepare"
+	spanRender             = "stencil.render"
+	spanRenderDocument     = "stencil.render.document"
+	spanRenderBody         = "stencil.render.body"
+	spanRenderTables       = "stencil.render.tables"
+	spanRenderPart         = "stencil.render.part"
+	spanRenderHeaderFooter = "stencil.render.header_footer"
+	spanInclude            = "stencil.include"
+	spanAssemble           = "stencil.assemble"
+
+	attrTemplateHash = "stencil.template.hash"
+	attrTemplateSize = "stencil.template.size"
+	attrParagraphs   = "stencil.paragraphs"
+	attrTables       = "stencil.tables"
+	attrFragment     = "stencil.fragment"
+	attrFragmentKind = "stencil.fragment.kind"
+	attrPart         = "stencil.part"
+	attrParts        = "stencil.parts"
+	attrOutputSize   = "stencil.output.size"
+)
+
+// Tracer starts the spans of WithTracer. Start returns a span named name
+// as a child of the span in ctx and a context holding the new span, the
+// parent of the spans started inside it. Tracers are called concurrently by
+// parallel renders.
+//
+// The package imports only the standard library and ships no OpenTelemetry
+// integration; applications write a small adapter that maps a Tracer onto
+// an OpenTelemetry trace.Tracer. The Tracing section of docs/API.md has a
+// complete one:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, stencil.Span) {
+//	    ctx, span := t.Tracer.Start(ctx, name)
+//	    return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. SetAttribute values are strings,
+// ints or bools. End is called once with the error the traced step failed
+// with, or nil.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// WithTracer returns an option that reports spans for the phases of
+// preparing and rendering templates to tracer: prepare, render, the main
+// document with its body and table processing, each header, footer and
+// other story part, each fragment include, and the assembly of the output
+// package. Spans carry the template hash and element counts, so traces show
+// where document generation time goes. Render spans are children of
+// RenderOptions.TraceContext.
+//
+// Example:
+//
+//	engine := stencil.NewWithOptions(stencil.WithTracer(otelTracer{otel.Tracer("stencil")}))
+//	output, err := tmpl.RenderWithOptions(data, stencil.RenderOptions{TraceContext: r.Context()})
+func WithTracer(tracer Tracer) Option {
+	return func(e *Engine) {
+		e.data.mu.Lock()
+		e.data.tracer = tracer
+		e.data.mu.Unlock()
+	}
+}
+
+// startSpan starts a span with the engine's tracer. Without a tracer it
+// returns parent and a span that does nothing.
+func (d *engineData) startSpan(parent context.Context, name string) (context.Context, Span) {
+	return startTracerSpan(d.activeTracer(), parent, name)
+}
+
+// activeTracer returns the tracer set with WithTracer, or nil.
+func (d *engineData) activeTracer() Tracer {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.tracer
+}
+
+// startTracerSpan starts a span with tracer, which may be nil.
+func startTracerSpan(tracer Tracer, parent context.Context, name string) (context.Context, Span) {
+	if tracer == nil {
+		return parent, noopSpan{}
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, span := tracer.Start(parent, name)
+	if span == nil {
+		span = noopSpan{}
+	}
+	return ctx, span
+}
+
+// startSpan starts a span of the render as a child of its current span.
+// The new span is the current span until end is called; calls of end after
+// the first do nothing, so end may also be deferred.
+func (ctx *renderContext) startSpan(name string) (Span, func(err error)) {
+	if ctx == nil || ctx.tracer == nil {
+		return noopSpan{}, func(error) {}
+	}
+	parent := ctx.traceCtx
+	spanCtx, span := startTracerSpan(ctx.tracer, parent, name)
+	ctx.traceCtx = spanCtx
+	ended := false
+	return span, func(err error) {
+		if ended {
+			return
+		}
+		ended = true
+		ctx.traceCtx = parent
+		span.End(err)
+	}
+}
+
+// noopSpan is the span of renders without a tracer.
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) End(error)                        {}
+
+// setElementCountAttributes records the number of paragraphs and tables
+// among elements on span.
+func setElementCountAttributes(span Span, elements []BodyElement) {
+	if _, ok := span.(noopSpan); ok {
+		return
+	}
+	paragraphs, tables := 0, 0
+	for _, element := range elements {
+		switch element.(type) {
+		case *Paragraph:
+			paragraphs++
+		case *Table:
+			tables++
+		}
+	}
+	span.SetAttribute(attrParagraphs, paragraphs)
+	span.SetAttribute(attrTables, tables)
+}
@@ -0,0 +1,128 @@
+package stencil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type traceContextKey struct{}
+
+// recordingTracer records the spans it starts with the name of their parent.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	ended  int
+	err    error
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(traceContextKey{}).(string)
+	span := &recordedSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, traceContextKey{}, name), span
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordedSpan) End(err error)                              { s.ended++; s.err = err }
+
+func (t *recordingTracer) find(name string) []*recordedSpan {
+	var found []*recordedSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			found = append(found, span)
+		}
+	}
+	return found
+}
+
+func TestWithTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	engine := NewWithOptions(WithTracer(tracer))
+	defer engine.Close()
+
+	tmpl, err := engine.prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		"Hello {{name}}",
+		`{{include "clause"}}`,
+	})))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragmentFromBytes("clause", createDOCXWithParagraphs(t, []string{"Clause for {{uppercase(name)}}"})); err != nil {
+		t.Fatal(err)
+	}
+
+	prepareSpans := tracer.find(spanPrepare)
+	if len(prepareSpans) != 1 || prepareSpans[0].attrs[attrParagraphs] != 2 || prepareSpans[0].attrs[attrTemplateHash] == "" {
+		t.Fatalf("prepare spans = %+v", prepareSpans)
+	}
+
+	parent := context.WithValue(context.Background(), traceContextKey{}, "request")
+	if _, err := tmpl.RenderWithOptions(TemplateData{"name": "World"}, RenderOptions{TraceContext: parent}); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	for _, want := range []struct{ name, parent string }{
+		{spanRender, "request"},
+		{spanRenderDocument, spanRender},
+		{spanRenderBody, spanRenderDocument},
+		{spanInclude, spanRenderBody},
+		{spanRenderTables, spanRenderDocument},
+		{spanAssemble, spanRender},
+	} {
+		spans := tracer.find(want.name)
+		if len(spans) != 1 {
+			t.Errorf("%s: %d spans, want 1", want.name, len(spans))
+			continue
+		}
+		if spans[0].parent != want.parent || spans[0].ended != 1 || spans[0].err != nil {
+			t.Errorf("%s: parent %q, ended %d times, error %v; want parent %q", want.name, spans[0].parent, spans[0].ended, spans[0].err, want.parent)
+		}
+	}
+	if render := tracer.find(spanRender); len(render) == 1 && render[0].attrs[attrTemplateHash] != prepareSpans[0].attrs[attrTemplateHash] {
+		t.Errorf("render hash = %v, want the hash of the prepared template", render[0].attrs[attrTemplateHash])
+	}
+	if include := tracer.find(spanInclude); len(include) == 1 && (include[0].attrs[attrFragment] != "clause" || include[0].attrs[attrParagraphs] != 1) {
+		t.Errorf("include attributes = %v", include[0].attrs)
+	}
+	if assemble := tracer.find(spanAssemble); len(assemble) == 1 {
+		if size, _ := assemble[0].attrs[attrOutputSize].(int); size == 0 {
+			t.Errorf("assemble attributes = %v, want the output size", assemble[0].attrs)
+		}
+	}
+
+	tracer.spans = nil
+	_, renderErr := tmpl.RenderWithOptions(TemplateData{"name": "World"}, RenderOptions{
+		FunctionPolicy: &FunctionPolicy{Deny: []string{"uppercase"}},
+	})
+	if renderErr == nil {
+		t.Fatal("expected the denied function to fail the render")
+	}
+	render := tracer.find(spanRender)
+	if len(render) != 1 || render[0].err == nil || render[0].ended != 1 {
+		t.Errorf("failed render spans = %s", describeSpans(render))
+	}
+	if include := tracer.find(spanInclude); len(include) != 1 || include[0].err == nil {
+		t.Errorf("failed include spans = %s", describeSpans(include))
+	}
+}
+
+func describeSpans(spans []*recordedSpan) string {
+	var parts []string
+	for _, span := range spans {
+		parts = append(parts, fmt.Sprintf("%s(ended %d, err %v)", span.name, span.ended, span.err))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,144 @@
+package stencil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TSVRowsMarker represents a tsvRows() call in a table row
+type TSVRowsMarker struct {
+	Text string // tab-separated lines, one per row
+}
+
+// String returns the string representation of the marker for rendering
+func (m TSVRowsMarker) String() string {
+	return fmt.Sprintf("{{TSV_ROWS_MARKER:%s}}", strconv.Quote(m.Text))
+}
+
+// tsvRowsMarkerRegex matches the rendered form of a TSVRowsMarker
+var tsvRowsMarkerRegex = regexp.MustCompile(`\{\{TSV_ROWS_MARKER:("(?:[^"\\]|\\.)*")\}\}`)
+
+// tsvRows marks the containing table row for expansion into one row per
+// line of a tab-separated text.
+func tsvRows(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return &TSVRowsMarker{}, nil
+	}
+	text, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("tsvRows: text must be a string, got %T", args[0])
+	}
+	return &TSVRowsMarker{Text: text}, nil
+}
+
+// registerTSVRowsFunction registers the tsvRows() function
+func registerTSVRowsFunction(registry *DefaultFunctionRegistry) {
+	// tsvRows() function - expands the containing row into the rows of a tab-separated text
+	tsvRowsFn := NewSimpleFunction("tsvRows", 1, 1, tsvRows)
+	registry.RegisterFunction(tsvRowsFn)
+}
+
+// ProcessTSVRowMarkers expands the table rows that contain a tsvRows()
+// marker into one row per line of its text
+func ProcessTSVRowMarkers(doc *Document) error {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	for _, elem := range doc.Body.Elements {
+		if table, ok := elem.(*Table); ok {
+			if err := expandTSVRows(table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandTSVRows replaces each row containing a marker with a copy of the
+// row per non-blank line of the marker text. The fields of a line fill the
+// cell of the marker and the cells after it, which are emptied when the
+// line has fewer fields; the cells before it keep their content. Fields
+// beyond the last cell are dropped. A row whose text has no lines is
+// removed.
+func expandTSVRows(table *Table) error {
+	var rows []TableRow
+	for i := range table.Rows {
+		row := &table.Rows[i]
+		column, text, found, err := findTSVRowsMarker(row)
+		if err != nil {
+			return err
+		}
+		if !found {
+			rows = append(rows, *row)
+			continue
+		}
+
+		for _, line := range tsvLines(text) {
+			expanded := cloneTableRow(row)
+			fields := strings.Split(line, "\t")
+			for j := column; j < len(expanded.Cells); j++ {
+				field := ""
+				if j-column < len(fields) {
+					field = fields[j-column]
+				}
+				setTSVCellText(&expanded.Cells[j], field)
+			}
+			rows = append(rows, *expanded)
+		}
+	}
+	table.Rows = rows
+	return nil
+}
+
+// findTSVRowsMarker returns the index of the first cell of row containing
+// a marker and the text of the marker.
+func findTSVRowsMarker(row *TableRow) (int, string, bool, error) {
+	for i := range row.Cells {
+		match := tsvRowsMarkerRegex.FindStringSubmatch(row.Cells[i].GetText())
+		if match == nil {
+			continue
+		}
+		text, err := strconv.Unquote(match[1])
+		if err != nil {
+			return 0, "", false, fmt.Errorf("tsvRows: invalid marker: %w", err)
+		}
+		return i, text, true, nil
+	}
+	return 0, "", false, nil
+}
+
+// tsvLines returns the non-blank lines of a tab-separated text
+func tsvLines(text string) []string {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// setTSVCellText replaces the content of cell with text, keeping the
+// paragraph and run formatting of its first paragraph.
+func setTSVCellText(cell *TableCell, text string) {
+	para := Paragraph{}
+	var runProps *RunProperties
+	if len(cell.Paragraphs) > 0 {
+		para.Properties = cell.Paragraphs[0].Properties
+		para.Attrs = cell.Paragraphs[0].Attrs
+		for _, run := range cell.Paragraphs[0].Runs {
+			if run.Text != nil {
+				runProps = run.Properties
+				break
+			}
+		}
+	}
+	if text != "" {
+		para.Runs = []Run{{Properties: runProps, Text: &Text{Content: text, Space: "preserve"}}}
+	}
+	cell.Paragraphs = []Paragraph{para}
+}
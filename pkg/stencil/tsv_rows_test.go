@@ -0,0 +1,95 @@
+package stencil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTSVRowsFunction(t *testing.T) {
+	registry := GetDefaultFunctionRegistry()
+	fn, exists := registry.GetFunction("tsvRows")
+	if !exists {
+		t.Fatalf("tsvRows function not found in registry")
+	}
+
+	result, err := fn.Call("a\tb\nc\t\"d}}\"")
+	if err != nil {
+		t.Fatalf("tsvRows() failed: %v", err)
+	}
+	marker := result.(*TSVRowsMarker).String()
+	if want := `{{TSV_ROWS_MARKER:"a\tb\nc\t\"d}}\""}}`; marker != want {
+		t.Errorf("tsvRows() = %s, want %s", marker, want)
+	}
+	if match := tsvRowsMarkerRegex.FindStringSubmatch("x" + marker + "y"); match == nil || match[0] != marker {
+		t.Errorf("marker regex did not match %s", marker)
+	}
+
+	if _, err := fn.Call(42); err == nil {
+		t.Error("expected an error for text that is not a string")
+	}
+}
+
+func TestTSVRowsInTemplate(t *testing.T) {
+	row := func(cells ...string) string {
+		var b strings.Builder
+		b.WriteString(`<w:tr>`)
+		for _, cell := range cells {
+			b.WriteString(`<w:tc><w:p><w:r><w:t xml:space="preserve">` + cell + `</w:t></w:r></w:p></w:tc>`)
+		}
+		b.WriteString(`</w:tr>`)
+		return b.String()
+	}
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithBodyXML(t, `<w:tbl>`+
+		row("Source", "Host", "Status", "Uptime")+
+		row("{{source}}", "{{tsvRows(dump)}}", "", "")+
+		row("Total", "", "", "")+
+		`</w:tbl>`)))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{
+		"source": "monitor",
+		"dump":   "web-1\tup\t99.9%\r\ndb-1\tdown\n\nextra-1\tup\t100%\tignored\n",
+	})
+	table := renderedTSVTable(t, output)
+
+	want := [][]string{
+		{"Source", "Host", "Status", "Uptime"},
+		{"monitor", "web-1", "up", "99.9%"},
+		{"monitor", "db-1", "down", ""},
+		{"monitor", "extra-1", "up", "100%"},
+		{"Total", "", "", ""},
+	}
+	if len(table.Rows) != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), len(table.Rows))
+	}
+	for i, cells := range want {
+		for j, text := range cells {
+			if got := table.Rows[i].Cells[j].GetText(); got != text {
+				t.Errorf("cell %d,%d = %q, want %q", i, j, got, text)
+			}
+		}
+	}
+
+	output = renderPreparedToBytes(t, tmpl, TemplateData{"source": "monitor", "dump": nil})
+	if rows := len(renderedTSVTable(t, output).Rows); rows != 2 {
+		t.Errorf("expected the row removed without text, got %d rows", rows)
+	}
+}
+
+// renderedTSVTable returns the first table of a rendered document
+func renderedTSVTable(t *testing.T, output []byte) *Table {
+	t.Helper()
+	doc, err := ParseDocument(strings.NewReader(extractDocumentXMLFromDOCX(t, output)))
+	if err != nil {
+		t.Fatalf("failed to parse rendered document: %v", err)
+	}
+	table, ok := doc.Body.Elements[0].(*Table)
+	if !ok {
+		t.Fatalf("expected a table, got %T", doc.Body.Elements[0])
+	}
+	return table
+}
@@ -0,0 +1,266 @@
+package stencil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnitDefinition defines a unit of measure for the convert() and
+// formatLength() functions.
+type UnitDefinition struct {
+	// Name is the symbol of the unit in templates, such as "cm" or "psi".
+	// Names are case sensitive, so "mPa" and "MPa" are different units.
+	Name string
+	// Dimension names what the unit measures, such as "length" or "mass".
+	// Units convert into the units of the same dimension only.
+	Dimension string
+	// Factor is the size of the unit in the base unit of its dimension, and
+	// Offset is where its zero lies in the base unit: a value v in the unit
+	// is v*Factor + Offset in the base unit. Offset is zero except for
+	// scales such as degrees Celsius.
+	Factor float64
+	Offset float64
+	// System names the measurement system, such as "metric" or "imperial",
+	// in whose units formatLength() may write a length. Units formatLength()
+	// does not choose leave it empty.
+	System string
+}
+
+// builtinUnits are the units defined by this package. The base units are
+// the metre, the kilogram, the kelvin, the pascal and the litre.
+var builtinUnits = []UnitDefinition{
+	{Name: "µm", Dimension: "length", Factor: 1e-6},
+	{Name: "mm", Dimension: "length", Factor: 0.001, System: "metric"},
+	{Name: "cm", Dimension: "length", Factor: 0.01, System: "metric"},
+	{Name: "dm", Dimension: "length", Factor: 0.1},
+	{Name: "m", Dimension: "length", Factor: 1, System: "metric"},
+	{Name: "km", Dimension: "length", Factor: 1000, System: "metric"},
+	{Name: "in", Dimension: "length", Factor: 0.0254, System: "imperial"},
+	{Name: "ft", Dimension: "length", Factor: 0.3048, System: "imperial"},
+	{Name: "yd", Dimension: "length", Factor: 0.9144},
+	{Name: "mi", Dimension: "length", Factor: 1609.344, System: "imperial"},
+	{Name: "pt", Dimension: "length", Factor: 0.0254 / 72},
+	{Name: "mg", Dimension: "mass", Factor: 1e-6},
+	{Name: "g", Dimension: "mass", Factor: 0.001},
+	{Name: "kg", Dimension: "mass", Factor: 1},
+	{Name: "t", Dimension: "mass", Factor: 1000},
+	{Name: "oz", Dimension: "mass", Factor: 0.028349523125},
+	{Name: "lb", Dimension: "mass", Factor: 0.45359237},
+	{Name: "K", Dimension: "temperature", Factor: 1},
+	{Name: "C", Dimension: "temperature", Factor: 1, Offset: 273.15},
+	{Name: "F", Dimension: "temperature", Factor: 5.0 / 9, Offset: 273.15 - 32*5.0/9},
+	{Name: "Pa", Dimension: "pressure", Factor: 1},
+	{Name: "kPa", Dimension: "pressure", Factor: 1e3},
+	{Name: "MPa", Dimension: "pressure", Factor: 1e6},
+	{Name: "bar", Dimension: "pressure", Factor: 1e5},
+	{Name: "psi", Dimension: "pressure", Factor: 6894.757293168},
+	{Name: "ml", Dimension: "volume", Factor: 0.001},
+	{Name: "l", Dimension: "volume", Factor: 1},
+	{Name: "m3", Dimension: "volume", Factor: 1000},
+	{Name: "gal", Dimension: "volume", Factor: 3.785411784},
+}
+
+var units = struct {
+	sync.RWMutex
+	definitions map[string]UnitDefinition
+}{definitions: builtinUnitDefinitions()}
+
+func builtinUnitDefinitions() map[string]UnitDefinition {
+	definitions := make(map[string]UnitDefinition, len(builtinUnits))
+	for _, unit := range builtinUnits {
+		definitions[unit.Name] = unit
+	}
+	return definitions
+}
+
+// RegisterUnit defines a unit of measure for all templates, or redefines
+// a built-in unit of the same name. Its Factor relates it to the units of
+// its Dimension, so a unit of a new dimension picks the base unit of that
+// dimension with a Factor of 1.
+//
+// Example:
+//
+//	err := stencil.RegisterUnit(stencil.UnitDefinition{
+//	    Name: "mil", Dimension: "length", Factor: 0.0000254,
+//	})
+//
+// In a template, {{convert(thickness, "mm", "mil")}}.
+func RegisterUnit(unit UnitDefinition) error {
+	if strings.TrimSpace(unit.Name) == "" {
+		return fmt.Errorf("unit name is required")
+	}
+	if strings.TrimSpace(unit.Dimension) == "" {
+		return fmt.Errorf("unit %s has no dimension", unit.Name)
+	}
+	if !(unit.Factor > 0) || math.IsInf(unit.Factor, 0) {
+		return fmt.Errorf("unit %s has factor %v; it must be a positive number", unit.Name, unit.Factor)
+	}
+
+	units.Lock()
+	defer units.Unlock()
+	units.definitions[unit.Name] = unit
+	return nil
+}
+
+// lookupUnit returns the unit registered under name.
+func lookupUnit(function, name string) (UnitDefinition, error) {
+	units.RLock()
+	defer units.RUnlock()
+	unit, ok := units.definitions[name]
+	if !ok {
+		return UnitDefinition{}, fmt.Errorf("%s: unknown unit %q", function, name)
+	}
+	return unit, nil
+}
+
+// convertUnit converts value from one unit into another of its dimension.
+func convertUnit(function string, value float64, fromName, toName string) (float64, error) {
+	from, err := lookupUnit(function, fromName)
+	if err != nil {
+		return 0, err
+	}
+	to, err := lookupUnit(function, toName)
+	if err != nil {
+		return 0, err
+	}
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("%s: cannot convert %s (%s) to %s (%s)", function, from.Name, from.Dimension, to.Name, to.Dimension)
+	}
+	magnitude := math.Max(math.Abs(value*from.Factor), math.Max(math.Abs(from.Offset), math.Abs(to.Offset)))
+	return roundConverted((value*from.Factor+from.Offset-to.Offset)/to.Factor, magnitude/to.Factor), nil
+}
+
+// convertPrecision is the number of significant digits convertUnit keeps.
+const convertPrecision = 12
+
+// roundConverted rounds a converted value to convertPrecision significant
+// digits of magnitude, the size of the largest term it was computed from,
+// so the rounding errors of factors and offsets cancel: 100 °C is 212 °F
+// rather than 211.99999999999991, and 32 °F is 0 °C rather than 5.7e-14.
+func roundConverted(value, magnitude float64) float64 {
+	if value == 0 || magnitude == 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return value
+	}
+	text := strconv.FormatFloat(value, 'g', convertPrecision, 64)
+	if decimals := convertPrecision - 1 - int(math.Floor(math.Log10(magnitude))); decimals >= 0 {
+		text = strconv.FormatFloat(value, 'f', decimals, 64)
+	}
+	rounded, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return value
+	}
+	return rounded
+}
+
+// systemUnits returns the units of dimension in system, from the smallest
+// to the largest.
+func systemUnits(dimension, system string) []UnitDefinition {
+	units.RLock()
+	defer units.RUnlock()
+	var found []UnitDefinition
+	for _, unit := range units.definitions {
+		if unit.Dimension == dimension && unit.System == system {
+			found = append(found, unit)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Factor < found[j].Factor
+	})
+	return found
+}
+
+// formatLength writes a length for formatLength(value, system, unit,
+// decimals) in the largest unit of system in which it is at least 1, or in
+// the unit system names.
+func formatLength(args ...interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	value, err := toNumber(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("formatLength: %w", err)
+	}
+	system := FormatValue(args[1])
+	unitName := "m"
+	if len(args) > 2 && args[2] != nil {
+		unitName = FormatValue(args[2])
+	}
+	decimals := 2
+	if len(args) > 3 && args[3] != nil {
+		n, err := toNumber(args[3])
+		if err != nil || n < 0 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("formatLength: decimals must be a non-negative integer, got %v", args[3])
+		}
+		decimals = int(n)
+	}
+
+	unit, err := lookupUnit("formatLength", unitName)
+	if err != nil {
+		return nil, err
+	}
+	if unit.Dimension != "length" {
+		return nil, fmt.Errorf("formatLength: %s is not a unit of length", unit.Name)
+	}
+
+	candidates := systemUnits("length", system)
+	if len(candidates) == 0 {
+		// A unit name writes the length in that unit
+		target, err := lookupUnit("formatLength", system)
+		if err != nil {
+			return nil, fmt.Errorf("formatLength: unknown measurement system or unit %q", system)
+		}
+		candidates = []UnitDefinition{target}
+	}
+
+	target := candidates[0]
+	for _, candidate := range candidates[1:] {
+		converted, err := convertUnit("formatLength", value, unit.Name, candidate.Name)
+		if err != nil {
+			return nil, err
+		}
+		if math.Abs(converted) < 1 {
+			break
+		}
+		target = candidate
+	}
+	converted, err := convertUnit("formatLength", value, unit.Name, target.Name)
+	if err != nil {
+		return nil, err
+	}
+	return formatMeasure(converted, decimals) + " " + target.Name, nil
+}
+
+// formatMeasure writes value rounded to decimals places, without trailing
+// zeros.
+func formatMeasure(value float64, decimals int) string {
+	scale := math.Pow(10, float64(decimals))
+	rounded := math.Round(value*scale) / scale
+	if rounded == 0 {
+		rounded = 0 // avoid "-0"
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// registerUnitFunctions registers the convert() and formatLength() functions
+func registerUnitFunctions(registry *DefaultFunctionRegistry) {
+	// convert() function - converts a value between units of measure
+	convertFn := NewSimpleFunction("convert", 3, 3, func(args ...interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		value, err := toNumber(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("convert: %w", err)
+		}
+		return convertUnit("convert", value, FormatValue(args[1]), FormatValue(args[2]))
+	})
+	registry.RegisterFunction(convertFn)
+
+	// formatLength() function - writes a length in a readable unit
+	formatLengthFn := NewSimpleFunction("formatLength", 2, 4, formatLength)
+	registry.RegisterFunction(formatLengthFn)
+}
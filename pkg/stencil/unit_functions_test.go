@@ -0,0 +1,118 @@
+package stencil
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{value: 12, from: "in", to: "cm", want: 30.48},
+		{value: 1, from: "mi", to: "km", want: 1.609344},
+		{value: 100, from: "C", to: "F", want: 212},
+		{value: -40, from: "F", to: "C", want: -40},
+		{value: 0, from: "C", to: "K", want: 273.15},
+		{value: 1, from: "bar", to: "psi", want: 14.503773773},
+		{value: 2.5, from: "kg", to: "g", want: 2500},
+	}
+	for _, tt := range tests {
+		got, err := convertUnit("convert", tt.value, tt.from, tt.to)
+		if err != nil || math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("convert(%v, %s, %s) = %v, %v, want %v", tt.value, tt.from, tt.to, got, err, tt.want)
+		}
+	}
+
+	// Temperatures convert exactly between all scales
+	temperatures := []map[string]float64{
+		{"C": 0, "F": 32, "K": 273.15},
+		{"C": 100, "F": 212, "K": 373.15},
+		{"C": -40, "F": -40, "K": 233.15},
+	}
+	for _, temperature := range temperatures {
+		for from, value := range temperature {
+			for to, want := range temperature {
+				if got, err := convertUnit("convert", value, from, to); err != nil || got != want {
+					t.Errorf("convert(%v, %s, %s) = %v, %v, want exactly %v", value, from, to, got, err, want)
+				}
+			}
+		}
+	}
+
+	if _, err := convertUnit("convert", 1, "kg", "m"); err == nil {
+		t.Error("expected an error converting mass to length")
+	}
+	if _, err := convertUnit("convert", 1, "furlong", "m"); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
+
+func TestFormatLength(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{args: []interface{}{3.5, "metric"}, want: "3.5 m"},
+		{args: []interface{}{0.0125, "metric"}, want: "1.25 cm"},
+		{args: []interface{}{0.004, "metric"}, want: "4 mm"},
+		{args: []interface{}{1500, "metric"}, want: "1.5 km"},
+		{args: []interface{}{3.5, "imperial"}, want: "11.48 ft"},
+		{args: []interface{}{250, "imperial", "mm"}, want: "9.84 in"},
+		{args: []interface{}{12, "metric", "in", 1}, want: "30.5 cm"},
+		{args: []interface{}{0.5, "mm"}, want: "500 mm"},
+	}
+	for _, tt := range tests {
+		got, err := formatLength(tt.args...)
+		if err != nil || got != tt.want {
+			t.Errorf("formatLength(%v) = %v, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+
+	if _, err := formatLength(1, "nautical"); err == nil {
+		t.Error("expected an error for an unknown measurement system")
+	}
+	if _, err := formatLength(1, "metric", "kg"); err == nil {
+		t.Error("expected an error for a unit that is not a length")
+	}
+}
+
+func TestRegisterUnit(t *testing.T) {
+	if err := RegisterUnit(UnitDefinition{Name: "mil", Dimension: "length", Factor: 0.0000254}); err != nil {
+		t.Fatalf("RegisterUnit failed: %v", err)
+	}
+	defer func() {
+		units.Lock()
+		delete(units.definitions, "mil")
+		units.Unlock()
+	}()
+
+	if got, err := convertUnit("convert", 1, "mm", "mil"); err != nil || math.Abs(got-39.37007874) > 1e-6 {
+		t.Errorf("convert(1, mm, mil) = %v, %v", got, err)
+	}
+	if err := RegisterUnit(UnitDefinition{Name: "bad", Dimension: "length"}); err == nil {
+		t.Error("expected an error for a unit without a factor")
+	}
+	if err := RegisterUnit(UnitDefinition{Name: "bad", Factor: 1}); err == nil {
+		t.Error("expected an error for a unit without a dimension")
+	}
+}
+
+func TestUnitFunctionsInTemplate(t *testing.T) {
+	tmpl, err := Prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`Width: {{convert(width, "in", "cm")}} cm`,
+		`Length: {{formatLength(length, "metric", "mm")}}`,
+	})))
+	if err != nil {
+		t.Fatalf("failed to prepare template: %v", err)
+	}
+	defer tmpl.Close()
+
+	output := renderPreparedToBytes(t, tmpl, TemplateData{"width": 12, "length": 2400})
+	if got := extractTextFromDOCX(t, output); got != "Width: 30.48 cmLength: 2.4 m" {
+		t.Errorf("unexpected text %q", got)
+	}
+}
@@ -0,0 +1,209 @@
+package stencil
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultURLFetchTimeout bounds a fetch when URLFetchPolicy.Timeout is
+	// zero.
+	defaultURLFetchTimeout = 10 * time.Second
+	// defaultURLFetchMaxBytes bounds a fragment when URLFetchPolicy.MaxBytes
+	// is zero.
+	defaultURLFetchMaxBytes = 20 << 20
+)
+
+// HTTPFetcher fetches the content at a URL for NewURLFragmentResolver. It
+// returns nil content and no error when there is nothing at the URL, so the
+// include is handled like any missing fragment. Fetch is called
+// concurrently by parallel renders.
+type HTTPFetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// HTTPFetcherFunc adapts a function into an HTTPFetcher.
+type HTTPFetcherFunc func(ctx context.Context, url string) ([]byte, error)
+
+// Fetch fetches the content at url.
+func (f HTTPFetcherFunc) Fetch(ctx context.Context, url string) ([]byte, error) {
+	return f(ctx, url)
+}
+
+// URLFetchPolicy restricts the URLs NewURLFragmentResolver fetches.
+type URLFetchPolicy struct {
+	// AllowedHosts lists the hosts fragments may be fetched from, such as
+	// "cdn.example.com". An entry "*.example.com" allows the subdomains of
+	// example.com. URLs on other hosts are rejected; an empty list rejects
+	// all.
+	AllowedHosts []string
+	// AllowHTTP permits plain http URLs. By default only https is fetched.
+	AllowHTTP bool
+	// Timeout bounds each fetch. Zero means 10 seconds.
+	Timeout time.Duration
+	// MaxBytes bounds the size of a fragment. Zero means 20 MiB.
+	MaxBytes int64
+	// CacheTTL keeps fetched fragments for reuse by later renders. Zero
+	// fetches a fragment on every render that includes it.
+	CacheTTL time.Duration
+}
+
+// allowsHost reports whether host matches an entry of AllowedHosts.
+func (p URLFetchPolicy) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// urlFragmentEntry is a cached fragment fetched from a URL.
+type urlFragmentEntry struct {
+	content []byte
+	expires time.Time
+}
+
+// urlFragmentResolver resolves fragments named by http and https URLs and
+// passes other names to next.
+type urlFragmentResolver struct {
+	fetcher HTTPFetcher
+	policy  URLFetchPolicy
+	next    FragmentResolver
+
+	mu    sync.Mutex
+	cache map[string]urlFragmentEntry
+}
+
+// NewURLFragmentResolver returns a FragmentResolver that fetches DOCX
+// fragments named by URLs, as in {{include url("https://cdn.example.com/
+// clauses/privacy.docx")}}, with fetcher and within policy. Fragments with
+// other names are resolved by next, which may be nil.
+//
+// Unlike fragments from other resolvers, fetched fragments are not kept by
+// the template beyond a render, so a central clause library can change
+// without preparing templates again; policy.CacheTTL bounds how long a
+// fetched fragment is reused.
+//
+// Example:
+//
+//	resolver := stencil.NewURLFragmentResolver(stencil.NewHTTPFetcher(nil), stencil.URLFetchPolicy{
+//	    AllowedHosts: []string{"cdn.example.com"},
+//	    CacheTTL:     10 * time.Minute,
+//	}, stencil.DirectoryFragmentResolver("fragments"))
+//	tmpl.SetFragmentResolver(resolver)
+func NewURLFragmentResolver(fetcher HTTPFetcher, policy URLFetchPolicy, next FragmentResolver) FragmentResolver {
+	return &urlFragmentResolver{
+		fetcher: fetcher,
+		policy:  policy,
+		next:    next,
+		cache:   make(map[string]urlFragmentEntry),
+	}
+}
+
+// ResolveFragment fetches the fragment at name if it is a URL.
+func (r *urlFragmentResolver) ResolveFragment(name string) ([]byte, error) {
+	if !isURLFragmentName(name) {
+		if r.next == nil {
+			return nil, nil
+		}
+		return r.next.ResolveFragment(name)
+	}
+	if r.fetcher == nil {
+		return nil, fmt.Errorf("no HTTP fetcher configured for %s", name)
+	}
+
+	parsed, err := url.Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fragment URL %s: %w", name, err)
+	}
+	if parsed.Scheme == "http" && !r.policy.AllowHTTP {
+		return nil, fmt.Errorf("fragment URL %s: plain http is not allowed", name)
+	}
+	if !r.policy.allowsHost(parsed.Hostname()) {
+		return nil, fmt.Errorf("fragment URL %s: host %s is not allowed", name, parsed.Hostname())
+	}
+
+	now := time.Now()
+	if r.policy.CacheTTL > 0 {
+		r.mu.Lock()
+		entry, ok := r.cache[name]
+		r.mu.Unlock()
+		if ok && now.Before(entry.expires) {
+			return entry.content, nil
+		}
+	}
+
+	timeout := r.policy.Timeout
+	if timeout <= 0 {
+		timeout = defaultURLFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	content, err := r.fetcher.Fetch(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("fetch fragment %s: %w", name, err)
+	}
+	maxBytes := r.policy.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultURLFetchMaxBytes
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, fmt.Errorf("fetch fragment %s: %d bytes exceed the limit of %d", name, len(content), maxBytes)
+	}
+
+	if r.policy.CacheTTL > 0 && content != nil {
+		r.mu.Lock()
+		for key, entry := range r.cache {
+			if !now.Before(entry.expires) {
+				delete(r.cache, key)
+			}
+		}
+		r.cache[name] = urlFragmentEntry{content: content, expires: now.Add(r.policy.CacheTTL)}
+		r.mu.Unlock()
+	}
+	return content, nil
+}
+
+// volatileFragment reports whether the fragment name is fetched from a URL
+// and must not be kept by the template.
+func (r *urlFragmentResolver) volatileFragment(name string) bool {
+	return isURLFragmentName(name)
+}
+
+// volatileFragmentResolver is implemented by resolvers whose fragments may
+// change between renders.
+type volatileFragmentResolver interface {
+	volatileFragment(name string) bool
+}
+
+// isURLFragmentName reports whether a fragment name is an http or https
+// URL.
+func isURLFragmentName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// registerURLFunction registers the url() function
+func registerURLFunction(registry *DefaultFunctionRegistry) {
+	// url() function - names a fragment by its URL for {{include}}
+	urlFn := NewSimpleFunction("url", 1, 1, func(args ...interface{}) (interface{}, error) {
+		s := strings.TrimSpace(FormatValue(args[0]))
+		parsed, err := url.Parse(s)
+		if err != nil || !isURLFragmentName(s) || parsed.Host == "" {
+			return nil, fmt.Errorf("url: %q is not an http or https URL", s)
+		}
+		return s, nil
+	})
+	registry.RegisterFunction(urlFn)
+}
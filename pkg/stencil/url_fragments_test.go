@@ -0,0 +1,89 @@
+package stencil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestURLFragmentResolver(t *testing.T) {
+	var fetches atomic.Int32
+	fetcher := HTTPFetcherFunc(func(ctx context.Context, url string) ([]byte, error) {
+		fetches.Add(1)
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("fetch context has no deadline")
+		}
+		return []byte("Privacy clause from " + url), nil
+	})
+
+	prepared, err := prepare(bytes.NewReader(createDOCXWithParagraphs(t, []string{
+		`{{include url("https://cdn.example.com/privacy.docx")}}`,
+		`{{include "local"}}`,
+	})))
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	defer prepared.Close()
+	prepared.SetFragmentResolver(NewURLFragmentResolver(fetcher, URLFetchPolicy{
+		AllowedHosts: []string{"*.example.com"},
+		CacheTTL:     time.Hour,
+	}, FragmentResolverFunc(func(name string) ([]byte, error) {
+		return []byte("Local " + name), nil
+	})))
+
+	for i := 0; i < 2; i++ {
+		rendered, err := prepared.Render(TemplateData{})
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		output, _ := io.ReadAll(rendered)
+		text := extractTextFromDOCX(t, output)
+		assertInOrder(t, text, "Privacy clause from https://cdn.example.com/privacy.docx", "Local local")
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetched %d times, want 1 within the cache TTL", got)
+	}
+
+	prepared.template.mu.RLock()
+	_, kept := prepared.template.fragments["https://cdn.example.com/privacy.docx"]
+	prepared.template.mu.RUnlock()
+	if kept {
+		t.Error("the template kept a fragment fetched from a URL")
+	}
+}
+
+func TestURLFragmentResolverPolicy(t *testing.T) {
+	fetcher := HTTPFetcherFunc(func(ctx context.Context, url string) ([]byte, error) {
+		return []byte(strings.Repeat("x", 100)), nil
+	})
+	tests := []struct {
+		name   string
+		url    string
+		policy URLFetchPolicy
+		want   string
+	}{
+		{"host not allowed", "https://evil.test/a.docx", URLFetchPolicy{AllowedHosts: []string{"cdn.example.com"}}, "host evil.test is not allowed"},
+		{"no allowlist", "https://cdn.example.com/a.docx", URLFetchPolicy{}, "is not allowed"},
+		{"plain http", "http://cdn.example.com/a.docx", URLFetchPolicy{AllowedHosts: []string{"cdn.example.com"}}, "plain http"},
+		{"too large", "https://cdn.example.com/a.docx", URLFetchPolicy{AllowedHosts: []string{"cdn.example.com"}, MaxBytes: 10}, "exceed the limit"},
+		{"allowed", "http://cdn.example.com/a.docx", URLFetchPolicy{AllowedHosts: []string{"CDN.example.com"}, AllowHTTP: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewURLFragmentResolver(fetcher, tt.policy, nil).ResolveFragment(tt.url)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ResolveFragment failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ResolveFragment error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
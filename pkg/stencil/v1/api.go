@@ -0,0 +1,172 @@
+package stencil
+
+import (
+	"io"
+
+	core "github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+// APIVersion is the version of the API this package provides.
+const APIVersion = "v1"
+
+// Types of the v1 API. They are the types of the main package, which only
+// gain fields and methods; testdata/types.txt pins their shape.
+type (
+	// TemplateData is the data a template is rendered with.
+	TemplateData = core.TemplateData
+	// RenderOptions are the options of a render.
+	RenderOptions = core.RenderOptions
+	// Config is the configuration of an engine.
+	Config = core.Config
+	// Function is a function templates can call.
+	Function = core.Function
+	// TemplateSchema describes the data of a template for validation.
+	TemplateSchema = core.TemplateSchema
+	// TemplateType is the type of a field of a TemplateSchema.
+	TemplateType = core.TemplateType
+	// ValidateTemplateResult is the result of a template validation.
+	ValidateTemplateResult = core.ValidateTemplateResult
+)
+
+// The scalar types of a TemplateSchema.
+var (
+	String = core.String
+	Number = core.Number
+	Bool   = core.Bool
+	Any    = core.Any
+)
+
+// Object declares a nested object with named fields.
+func Object(fields TemplateSchema) TemplateType {
+	return core.Object(fields)
+}
+
+// List declares a list whose elements have the type element.
+func List(element TemplateType) TemplateType {
+	return core.List(element)
+}
+
+// Nullable marks a type as accepting null values.
+func Nullable(t TemplateType) TemplateType {
+	return core.Nullable(t)
+}
+
+// NewSimpleFunction returns a function called name that accepts between
+// minArgs and maxArgs arguments, -1 meaning any number, and calls handler.
+func NewSimpleFunction(name string, minArgs, maxArgs int, handler func(args ...interface{}) (interface{}, error)) Function {
+	return core.NewSimpleFunction(name, minArgs, maxArgs, handler)
+}
+
+// Engine prepares templates with a configuration and the functions
+// registered with it.
+type Engine struct {
+	engine *core.Engine
+}
+
+// New returns an engine with the global configuration and the built-in
+// functions.
+func New() *Engine {
+	return &Engine{engine: core.New()}
+}
+
+// NewWithConfig returns an engine with config, a template cache of its own
+// and the built-in functions.
+func NewWithConfig(config *Config) *Engine {
+	return &Engine{engine: core.NewWithConfig(config)}
+}
+
+// Prepare parses a template from r.
+func (e *Engine) Prepare(r io.Reader) (*PreparedTemplate, error) {
+	return wrapPrepared(e.engine.Prepare(r))
+}
+
+// PrepareFile parses the template at path, from the cache of the engine if
+// caching is enabled.
+func (e *Engine) PrepareFile(path string) (*PreparedTemplate, error) {
+	return wrapPrepared(e.engine.PrepareFile(path))
+}
+
+// RegisterFunction makes fn available to the templates the engine
+// prepares.
+func (e *Engine) RegisterFunction(name string, fn Function) error {
+	return e.engine.RegisterFunction(name, fn)
+}
+
+// SetGlobalData sets data every render of the engine's templates sees;
+// render data takes precedence over it.
+func (e *Engine) SetGlobalData(data TemplateData) {
+	e.engine.SetGlobalData(data)
+}
+
+// Close releases the templates the engine cached.
+func (e *Engine) Close() error {
+	return e.engine.Close()
+}
+
+// Core returns the engine of the main package, for features outside the
+// v1 API.
+func (e *Engine) Core() *core.Engine {
+	return e.engine
+}
+
+// Prepare parses a template from r with the default engine.
+func Prepare(r io.Reader) (*PreparedTemplate, error) {
+	return wrapPrepared(core.Prepare(r))
+}
+
+// PrepareFile parses the template at path with the default engine.
+func PrepareFile(path string) (*PreparedTemplate, error) {
+	return wrapPrepared(core.PrepareFile(path))
+}
+
+// PreparedTemplate is a parsed template, ready to be rendered any number
+// of times, also concurrently.
+type PreparedTemplate struct {
+	template *core.PreparedTemplate
+}
+
+func wrapPrepared(template *core.PreparedTemplate, err error) (*PreparedTemplate, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedTemplate{template: template}, nil
+}
+
+// Render renders the template with data and returns the DOCX document.
+func (pt *PreparedTemplate) Render(data TemplateData) (io.Reader, error) {
+	return pt.template.Render(data)
+}
+
+// RenderWithOptions renders the template with data like Render, applying
+// opts.
+func (pt *PreparedTemplate) RenderWithOptions(data TemplateData, opts RenderOptions) (io.Reader, error) {
+	return pt.template.RenderWithOptions(data, opts)
+}
+
+// AddFragment adds a text fragment the template can include by name.
+func (pt *PreparedTemplate) AddFragment(name string, content string) error {
+	return pt.template.AddFragment(name, content)
+}
+
+// AddFragmentFromBytes adds a DOCX fragment the template can include by
+// name.
+func (pt *PreparedTemplate) AddFragmentFromBytes(name string, docxBytes []byte) error {
+	return pt.template.AddFragmentFromBytes(name, docxBytes)
+}
+
+// Validate checks the template and the fragments it includes against the
+// data described by schema, without rendering it.
+func (pt *PreparedTemplate) Validate(schema TemplateSchema) (ValidateTemplateResult, error) {
+	return pt.template.Validate(schema)
+}
+
+// Close releases the template. It cannot be rendered afterwards.
+func (pt *PreparedTemplate) Close() error {
+	return pt.template.Close()
+}
+
+// Core returns the template of the main package, for features outside the
+// v1 API.
+func (pt *PreparedTemplate) Core() *core.PreparedTemplate {
+	return pt.template
+}
@@ -0,0 +1,378 @@
+package stencil
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	core "github.com/benjaminschreck/go-stencil/pkg/stencil"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/types.txt with the current shape of the v1 types")
+
+// The signatures of the v1 API. A change that breaks one of them breaks
+// the build of this test.
+var (
+	_ func() *Engine                                                             = New
+	_ func(*Config) *Engine                                                      = NewWithConfig
+	_ func(io.Reader) (*PreparedTemplate, error)                                 = Prepare
+	_ func(string) (*PreparedTemplate, error)                                    = PrepareFile
+	_ func(string, int, int, func(...interface{}) (interface{}, error)) Function = NewSimpleFunction
+	_ func(TemplateSchema) TemplateType                                          = Object
+	_ func(TemplateType) TemplateType                                            = List
+	_ func(TemplateType) TemplateType                                            = Nullable
+
+	_ func(*Engine, io.Reader) (*PreparedTemplate, error) = (*Engine).Prepare
+	_ func(*Engine, string) (*PreparedTemplate, error)    = (*Engine).PrepareFile
+	_ func(*Engine, string, Function) error               = (*Engine).RegisterFunction
+	_ func(*Engine, TemplateData)                         = (*Engine).SetGlobalData
+	_ func(*Engine) error                                 = (*Engine).Close
+	_ func(*Engine) *core.Engine                          = (*Engine).Core
+
+	_ func(*PreparedTemplate, TemplateData) (io.Reader, error)                = (*PreparedTemplate).Render
+	_ func(*PreparedTemplate, TemplateData, RenderOptions) (io.Reader, error) = (*PreparedTemplate).RenderWithOptions
+	_ func(*PreparedTemplate, string, string) error                           = (*PreparedTemplate).AddFragment
+	_ func(*PreparedTemplate, string, []byte) error                           = (*PreparedTemplate).AddFragmentFromBytes
+	_ func(*PreparedTemplate, TemplateSchema) (ValidateTemplateResult, error) = (*PreparedTemplate).Validate
+	_ func(*PreparedTemplate) error                                           = (*PreparedTemplate).Close
+	_ func(*PreparedTemplate) *core.PreparedTemplate                          = (*PreparedTemplate).Core
+)
+
+// The signatures of the main package the v1 API is built on. A change to
+// one of them must keep the v1 API above working the same way.
+var (
+	_ func() *core.Engine                                                             = core.New
+	_ func(*core.Config) *core.Engine                                                 = core.NewWithConfig
+	_ func(io.Reader) (*core.PreparedTemplate, error)                                 = core.Prepare
+	_ func(string) (*core.PreparedTemplate, error)                                    = core.PrepareFile
+	_ func(string, int, int, func(...interface{}) (interface{}, error)) core.Function = core.NewSimpleFunction
+	_ func(core.TemplateSchema) core.TemplateType                                     = core.Object
+	_ func(core.TemplateType) core.TemplateType                                       = core.List
+	_ func(core.TemplateType) core.TemplateType                                       = core.Nullable
+	_ core.TemplateType                                                               = core.String
+	_ core.TemplateType                                                               = core.Number
+	_ core.TemplateType                                                               = core.Bool
+	_ core.TemplateType                                                               = core.Any
+
+	_ func(*core.Engine, io.Reader) (*core.PreparedTemplate, error) = (*core.Engine).Prepare
+	_ func(*core.Engine, string) (*core.PreparedTemplate, error)    = (*core.Engine).PrepareFile
+	_ func(*core.Engine, string, core.Function) error               = (*core.Engine).RegisterFunction
+	_ func(*core.Engine, core.TemplateData)                         = (*core.Engine).SetGlobalData
+	_ func(*core.Engine) error                                      = (*core.Engine).Close
+
+	_ func(*core.PreparedTemplate, core.TemplateData) (io.Reader, error)                     = (*core.PreparedTemplate).Render
+	_ func(*core.PreparedTemplate, core.TemplateData, core.RenderOptions) (io.Reader, error) = (*core.PreparedTemplate).RenderWithOptions
+	_ func(*core.PreparedTemplate, string, string) error                                     = (*core.PreparedTemplate).AddFragment
+	_ func(*core.PreparedTemplate, string, []byte) error                                     = (*core.PreparedTemplate).AddFragmentFromBytes
+	_ func(*core.PreparedTemplate, core.TemplateSchema) (core.ValidateTemplateResult, error) = (*core.PreparedTemplate).Validate
+	_ func(*core.PreparedTemplate) error                                                     = (*core.PreparedTemplate).Close
+)
+
+// TestTypesKeepTheirShape pins the types the v1 API shares with the main
+// package, and the types of the main package they reach, in
+// testdata/types.txt: the exported fields of structs, the methods of types
+// and the underlying types of the others. Fields and methods may be added,
+// except to interfaces, which users implement; nothing may be removed or
+// changed. After adding to the API, run go test -update.
+func TestTypesKeepTheirShape(t *testing.T) {
+	current := describeTypes(
+		reflect.TypeOf(TemplateData{}),
+		reflect.TypeOf(RenderOptions{}),
+		reflect.TypeOf(Config{}),
+		reflect.TypeOf((*Function)(nil)).Elem(),
+		reflect.TypeOf(TemplateSchema{}),
+		reflect.TypeOf(TemplateType{}),
+		reflect.TypeOf(ValidateTemplateResult{}),
+	)
+	path := filepath.Join("testdata", "types.txt")
+	if *update {
+		if err := os.WriteFile(path, []byte(strings.Join(current, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := strings.Split(strings.TrimSpace(string(content)), "\n")
+
+	have := make(map[string]bool, len(current))
+	for _, line := range current {
+		have[line] = true
+	}
+	want := make(map[string]bool, len(pinned))
+	interfaces := make(map[string]bool)
+	for _, line := range pinned {
+		want[line] = true
+		if name, ok := strings.CutSuffix(line, " interface"); ok {
+			interfaces[strings.TrimPrefix(name, "type ")] = true
+		}
+		if !have[line] {
+			t.Errorf("v1 API changed: %s is gone", line)
+		}
+	}
+	for _, line := range current {
+		owner, _, _ := strings.Cut(line, ".")
+		if !want[line] && interfaces[owner] {
+			t.Errorf("v1 API changed: %s was added to an interface", line)
+		}
+	}
+}
+
+// describeTypes returns the shape of types and of the types of the main
+// package they reach, one sorted line per type, field and method.
+func describeTypes(types ...reflect.Type) []string {
+	corePath := reflect.TypeOf(core.Engine{}).PkgPath()
+	seen := make(map[reflect.Type]bool)
+	var lines []string
+	var visit func(reflect.Type)
+	visit = func(typ reflect.Type) {
+		if typ.Name() == "" {
+			switch typ.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Chan:
+				visit(typ.Elem())
+			case reflect.Map:
+				visit(typ.Key())
+				visit(typ.Elem())
+			case reflect.Func:
+				for i := 0; i < typ.NumIn(); i++ {
+					visit(typ.In(i))
+				}
+				for i := 0; i < typ.NumOut(); i++ {
+					visit(typ.Out(i))
+				}
+			}
+			return
+		}
+		if typ.PkgPath() != corePath || seen[typ] {
+			return
+		}
+		seen[typ] = true
+
+		name := typ.Name()
+		switch typ.Kind() {
+		case reflect.Struct:
+			lines = append(lines, "type "+name+" struct")
+			for i := 0; i < typ.NumField(); i++ {
+				field := typ.Field(i)
+				if !field.IsExported() {
+					continue
+				}
+				line := fmt.Sprintf("%s.%s %s", name, field.Name, field.Type)
+				if field.Tag != "" {
+					line += fmt.Sprintf(" `%s`", field.Tag)
+				}
+				lines = append(lines, line)
+				visit(field.Type)
+			}
+		case reflect.Interface:
+			lines = append(lines, "type "+name+" interface")
+			for i := 0; i < typ.NumMethod(); i++ {
+				method := typ.Method(i)
+				lines = append(lines, fmt.Sprintf("%s.%s %s", name, method.Name, method.Type))
+				visit(method.Type)
+			}
+			return
+		default:
+			underlying := underlyingType(typ)
+			lines = append(lines, fmt.Sprintf("type %s %s", name, underlying))
+			visit(underlying)
+		}
+
+		methods := reflect.PointerTo(typ)
+		for i := 0; i < methods.NumMethod(); i++ {
+			method := methods.Method(i)
+			signature := methodSignature(method.Type)
+			lines = append(lines, fmt.Sprintf("%s.%s %s", name, method.Name, signature))
+			visit(signature)
+		}
+	}
+	for _, typ := range types {
+		visit(typ)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// underlyingType returns the unnamed type a named type other than a struct
+// or interface is defined as.
+func underlyingType(typ reflect.Type) reflect.Type {
+	switch typ.Kind() {
+	case reflect.Map:
+		return reflect.MapOf(typ.Key(), typ.Elem())
+	case reflect.Slice:
+		return reflect.SliceOf(typ.Elem())
+	case reflect.Array:
+		return reflect.ArrayOf(typ.Len(), typ.Elem())
+	case reflect.Pointer:
+		return reflect.PointerTo(typ.Elem())
+	case reflect.Chan:
+		return reflect.ChanOf(typ.ChanDir(), typ.Elem())
+	case reflect.Func:
+		in := make([]reflect.Type, typ.NumIn())
+		for i := range in {
+			in[i] = typ.In(i)
+		}
+		out := make([]reflect.Type, typ.NumOut())
+		for i := range out {
+			out[i] = typ.Out(i)
+		}
+		return reflect.FuncOf(in, out, typ.IsVariadic())
+	}
+	// A basic kind: reflect names it like the predeclared type.
+	return reflect.Zero(typ).Convert(basicTypes[typ.Kind()]).Type()
+}
+
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(0),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// methodSignature returns the type of a method expression without its
+// receiver.
+func methodSignature(typ reflect.Type) reflect.Type {
+	in := make([]reflect.Type, typ.NumIn()-1)
+	for i := range in {
+		in[i] = typ.In(i + 1)
+	}
+	out := make([]reflect.Type, typ.NumOut())
+	for i := range out {
+		out[i] = typ.Out(i)
+	}
+	return reflect.FuncOf(in, out, typ.IsVariadic())
+}
+
+func createTemplate(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+			`</Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+			`</Relationships>`,
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			`<w:p><w:r><w:t xml:space="preserve">` + body + `</w:t></w:r></w:p></w:body></w:document>`,
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func renderedDocumentXML(t *testing.T, output io.Reader) string {
+	t.Helper()
+	content, err := io.ReadAll(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			xml, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(xml)
+		}
+	}
+	t.Fatal("rendered document has no word/document.xml")
+	return ""
+}
+
+func TestEngineRendersThroughV1API(t *testing.T) {
+	engine := NewWithConfig(&Config{})
+	defer engine.Close()
+	shout := NewSimpleFunction("shout", 1, 1, func(args ...interface{}) (interface{}, error) {
+		return strings.ToUpper(args[0].(string)) + "!", nil
+	})
+	if err := engine.RegisterFunction("shout", shout); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	engine.SetGlobalData(TemplateData{"company": "Acme"})
+
+	tmpl, err := engine.Prepare(bytes.NewReader(createTemplate(t, `{{shout(name)}} at {{company}}{{include "sig"}}`)))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer tmpl.Close()
+	if err := tmpl.AddFragment("sig", " - Regards"); err != nil {
+		t.Fatalf("AddFragment failed: %v", err)
+	}
+
+	output, err := tmpl.RenderWithOptions(TemplateData{"name": "ada"}, RenderOptions{})
+	if err != nil {
+		t.Fatalf("RenderWithOptions failed: %v", err)
+	}
+	documentXML := renderedDocumentXML(t, output)
+	for _, want := range []string{">ADA!<", ">Acme<", "Regards"} {
+		if !strings.Contains(documentXML, want) {
+			t.Errorf("rendered document lacks %q: %s", want, documentXML)
+		}
+	}
+
+	result, err := tmpl.Validate(TemplateSchema{"name": String, "tags": List(Nullable(String)), "user": Object(TemplateSchema{"id": Number})})
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if result.Summary.CheckedTokens == 0 {
+		t.Errorf("Validate checked no tokens: %+v", result)
+	}
+	if tmpl.Core() == nil || engine.Core() == nil {
+		t.Error("Core returned nil")
+	}
+}
+
+func TestPrepareReturnsErrors(t *testing.T) {
+	if _, err := Prepare(strings.NewReader("not a docx")); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+	if _, err := PrepareFile("does-not-exist.docx"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,26 @@
+// Package stencil is the stable v1 API of go-stencil, for services that
+// render documents for years and cannot follow every change of the main
+// package. Import it in place of the main package; its name is stencil
+// too, so code written against the main package mostly compiles after
+// changing the import path:
+//
+//	import "github.com/benjaminschreck/go-stencil/pkg/stencil/v1"
+//
+// Compatibility guarantees, for all releases of the module:
+//
+//   - The functions and methods of this package keep their signatures and
+//     behavior. When the main package changes a signature, this package
+//     adapts to it instead, so it doubles as the compatibility layer for
+//     the signatures the main package has today.
+//   - The option and data types, such as RenderOptions and Config, are
+//     aliases of the types of the main package. They only gain fields, and
+//     the zero value of a new field keeps the earlier behavior.
+//   - Templates that render without error keep rendering the same
+//     document content. Fixes to documents Word reports as damaged are
+//     the exception.
+//   - Nothing is removed. Deprecated identifiers stay available.
+//
+// Features that are not part of the v1 surface remain available through
+// Core, which returns the underlying value of the main package. The main
+// package itself makes no such guarantees while the module is at v0.
+package stencil
@@ -43,6 +43,8 @@ const (
 	IssueCodeUnknownFunction      StencilIssueCode = "UNKNOWN_FUNCTION"
 	IssueCodeFunctionArgError     StencilIssueCode = "FUNCTION_ARGUMENT_ERROR"
 	IssueCodeTypeMismatch         StencilIssueCode = "TYPE_MISMATCH"
+	IssueCodeVariableShadowing    StencilIssueCode = "VARIABLE_SHADOWING"
+	IssueCodeConstantCondition    StencilIssueCode = "CONSTANT_CONDITION"
 )
 
 // TokenKind identifies extracted token/reference categories.
@@ -62,6 +64,12 @@ type ValidateTemplateInput struct {
 	IncludeWarnings    bool             `json:"includeWarnings,omitempty"`
 	MaxIssues          int              `json:"maxIssues,omitempty"` // 0 = unlimited
 	Schema             ValidationSchema `json:"schema"`
+	// AnchorStrategy selects how the AnchorIDs of issue locations are
+	// derived; empty means AnchorStrategyPosition.
+	AnchorStrategy AnchorStrategy `json:"anchorStrategy,omitempty"`
+	// Suppressions suppress issues by the AnchorID of their tag, in
+	// addition to the stencil:ignore comments of the template.
+	Suppressions []IssueSuppression `json:"suppressions,omitempty"`
 }
 
 // ValidationSchema contains field/function schema definitions used for semantic validation.
@@ -76,6 +84,40 @@ type FieldDefinition struct {
 	Type       string `json:"type"`
 	Nullable   bool   `json:"nullable,omitempty"`
 	Collection bool   `json:"collection,omitempty"`
+	// Required marks fields ValidateData reports when absent from the
+	// data. It does not affect template validation.
+	Required bool `json:"required,omitempty"`
+	// RequiredIf is an expression, such as paymentMethod == "SEPA", that
+	// makes ValidateData require the field to be present and not null when
+	// it holds. It is evaluated against the object holding the field, with
+	// the fields of the render data also in scope.
+	RequiredIf string `json:"requiredIf,omitempty"`
+	// Default is the value RenderOptions.Schema fills in when the data
+	// lacks the field or holds null.
+	Default interface{} `json:"default,omitempty"`
+	// Compute is an expression RenderOptions.Schema evaluates against the
+	// render data to set the field, such as sum(map("amount", lines)).
+	// ValidateData does not check computed fields.
+	Compute string `json:"compute,omitempty"`
+	// Description and Example document the field for people; they do not
+	// affect validation.
+	Description string      `json:"description,omitempty"`
+	Example     interface{} `json:"example,omitempty"`
+	// Sensitive marks fields holding personal or secret data, which
+	// SensitiveFields lists for a ScrubPolicy.
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// SensitiveFields returns the paths of the fields marked Sensitive, for
+// ScrubPolicy.Fields.
+func (s ValidationSchema) SensitiveFields() []string {
+	var paths []string
+	for _, field := range s.Fields {
+		if field.Sensitive {
+			paths = append(paths, field.Path)
+		}
+	}
+	return paths
 }
 
 // FunctionDefinition defines one function signature.
@@ -92,12 +134,21 @@ type ValidateTemplateSyntaxInput struct {
 	DocxBytes          []byte `json:"-"`
 	TemplateRevisionID string `json:"templateRevisionId,omitempty"`
 	MaxIssues          int    `json:"maxIssues,omitempty"` // 0 = unlimited
+	// AnchorStrategy selects how the AnchorIDs of issue locations are
+	// derived; empty means AnchorStrategyPosition.
+	AnchorStrategy AnchorStrategy `json:"anchorStrategy,omitempty"`
+	// Suppressions suppress issues by the AnchorID of their tag, in
+	// addition to the stencil:ignore comments of the template.
+	Suppressions []IssueSuppression `json:"suppressions,omitempty"`
 }
 
 // ExtractReferencesInput controls reference extraction behavior.
 type ExtractReferencesInput struct {
 	DocxBytes          []byte `json:"-"`
 	TemplateRevisionID string `json:"templateRevisionId,omitempty"`
+	// AnchorStrategy selects how the AnchorIDs of reference locations are
+	// derived; empty means AnchorStrategyPosition.
+	AnchorStrategy AnchorStrategy `json:"anchorStrategy,omitempty"`
 }
 
 // TemplateLocation identifies a token location in a DOCX part.
@@ -136,6 +187,9 @@ type StencilValidationSummary struct {
 	ErrorCount         int `json:"errorCount"`
 	WarningCount       int `json:"warningCount"`
 	ReturnedIssueCount int `json:"returnedIssueCount"`
+	// SuppressedCount is the number of issues suppressed by stencil:ignore
+	// comments and suppressions; they are not counted as errors or warnings.
+	SuppressedCount int `json:"suppressedCount,omitempty"`
 }
 
 // StencilMetadata identifies parser metadata and request passthrough fields.
@@ -182,12 +236,16 @@ func ValidateTemplate(input ValidateTemplateInput) (ValidateTemplateResult, erro
 	if err != nil {
 		return ValidateTemplateResult{}, err
 	}
+	if err := applyAnchorStrategy(spans, input.AnchorStrategy); err != nil {
+		return ValidateTemplateResult{}, err
+	}
 
 	syntaxIssues := validateTokenSpans(spans)
 	semanticIssues := validateSemanticTokenSpans(spans, input.Schema, input.Strict)
 	allIssues := make([]StencilValidationIssue, 0, len(syntaxIssues)+len(semanticIssues))
 	allIssues = append(allIssues, syntaxIssues...)
 	allIssues = append(allIssues, semanticIssues...)
+	allIssues, suppressedCount := suppressIssues(allIssues, collectIssueSuppressions(spans, input.Suppressions))
 
 	sortValidationIssues(allIssues)
 	for i := range allIssues {
@@ -205,6 +263,7 @@ func ValidateTemplate(input ValidateTemplateInput) (ValidateTemplateResult, erro
 			ErrorCount:         errorCount,
 			WarningCount:       warningCount,
 			ReturnedIssueCount: len(returnedIssues),
+			SuppressedCount:    suppressedCount,
 		},
 		Issues:          returnedIssues,
 		IssuesTruncated: issuesTruncated,
@@ -225,8 +284,11 @@ func ValidateTemplateSyntax(input ValidateTemplateSyntaxInput) (ValidateTemplate
 	if err != nil {
 		return ValidateTemplateSyntaxResult{}, err
 	}
+	if err := applyAnchorStrategy(spans, input.AnchorStrategy); err != nil {
+		return ValidateTemplateSyntaxResult{}, err
+	}
 
-	issues := validateTokenSpans(spans)
+	issues, suppressedCount := suppressIssues(validateTokenSpans(spans), collectIssueSuppressions(spans, input.Suppressions))
 	sortValidationIssues(issues)
 	for i := range issues {
 		issues[i].ID = fmt.Sprintf("iss_%03d", i+1)
@@ -246,6 +308,7 @@ func ValidateTemplateSyntax(input ValidateTemplateSyntaxInput) (ValidateTemplate
 			ErrorCount:         len(issues),
 			WarningCount:       0,
 			ReturnedIssueCount: len(returnedIssues),
+			SuppressedCount:    suppressedCount,
 		},
 		Issues:          returnedIssues,
 		IssuesTruncated: issuesTruncated,
@@ -265,6 +328,9 @@ func ExtractReferences(input ExtractReferencesInput) (ExtractReferencesResult, e
 	if err != nil {
 		return ExtractReferencesResult{}, err
 	}
+	if err := applyAnchorStrategy(spans, input.AnchorStrategy); err != nil {
+		return ExtractReferencesResult{}, err
+	}
 
 	references := extractReferencesFromSpans(spans)
 	sortTemplateReferences(references)
@@ -332,6 +398,9 @@ type semanticTypeInfo struct {
 type semanticScopedVar struct {
 	TypeInfo     semanticTypeInfo
 	SchemaPrefix string
+	// Untyped variables, such as macro parameters, can hold any value, so
+	// their fields are not checked against the schema.
+	Untyped bool
 }
 
 type semanticControlFrame struct {
@@ -691,6 +760,30 @@ func validateTokenSpans(spans []tokenSpan) []StencilValidationIssue {
 			if _, err := ParseExpressionStrict(span.Token.Value); err != nil {
 				appendIssue(IssueCodeUnsupportedExpr, fmt.Sprintf("unsupported include expression: %v", err), span, TokenKindControl, span.Token.Value)
 			}
+		case TokenBlock:
+			if object, ok := scopeBlockObject(span.Token); ok {
+				if _, err := ParseExpressionStrict(object); err != nil {
+					appendIssue(IssueCodeUnsupportedExpr, fmt.Sprintf("unsupported scope expression: %v", err), span, TokenKindControl, span.Token.Value)
+				}
+			}
+			controlStack = append(controlStack, validationControlFrame{span: span})
+		case TokenMacro:
+			if _, _, err := parseMacroHeader(span.Token.Value); err != nil {
+				appendIssue(IssueCodeSyntaxError, err.Error(), span, TokenKindControl, span.Token.Value)
+			}
+			for _, frame := range controlStack {
+				if frame.span.Token.Type == TokenMacro {
+					appendIssue(IssueCodeControlBlockMismatch, "macros cannot be nested", span, TokenKindControl, span.Token.Value)
+					break
+				}
+			}
+			controlStack = append(controlStack, validationControlFrame{span: span})
+		case TokenEndMacro:
+			if len(controlStack) == 0 || controlStack[len(controlStack)-1].span.Token.Type != TokenMacro {
+				appendIssue(IssueCodeControlBlockMismatch, "{{endmacro}} has no matching {{macro}}", span, TokenKindControl, "")
+				continue
+			}
+			controlStack = controlStack[:len(controlStack)-1]
 		case TokenElsif:
 			if len(controlStack) == 0 || controlStack[len(controlStack)-1].span.Token.Type != TokenIf {
 				appendIssue(IssueCodeControlBlockMismatch, "{{elsif}} must be inside an {{if}} block", span, TokenKindControl, span.Token.Value)
@@ -707,15 +800,15 @@ func validateTokenSpans(spans []tokenSpan) []StencilValidationIssue {
 			}
 
 			top := &controlStack[len(controlStack)-1]
-			if top.span.Token.Type != TokenIf && top.span.Token.Type != TokenUnless {
-				appendIssue(IssueCodeControlBlockMismatch, "{{else}} only matches {{if}} or {{unless}}", span, TokenKindControl, "")
+			if top.span.Token.Type != TokenIf && top.span.Token.Type != TokenUnless && top.span.Token.Type != TokenFor {
+				appendIssue(IssueCodeControlBlockMismatch, "{{else}} only matches {{if}}, {{unless}} or {{for}}", span, TokenKindControl, "")
 			} else if top.sawElse {
-				appendIssue(IssueCodeControlBlockMismatch, "{{else}} can only appear once in an {{if}} or {{unless}} block", span, TokenKindControl, "")
+				appendIssue(IssueCodeControlBlockMismatch, "{{else}} can only appear once in an {{if}}, {{unless}} or {{for}} block", span, TokenKindControl, "")
 			} else {
 				top.sawElse = true
 			}
 		case TokenEnd:
-			if len(controlStack) == 0 {
+			if len(controlStack) == 0 || controlStack[len(controlStack)-1].span.Token.Type == TokenMacro {
 				appendIssue(IssueCodeControlBlockMismatch, "{{end}} has no matching opening control block", span, TokenKindControl, "")
 				continue
 			}
@@ -724,15 +817,20 @@ func validateTokenSpans(spans []tokenSpan) []StencilValidationIssue {
 	}
 
 	for _, opening := range controlStack {
+		closing := "{{end}}"
+		if opening.span.Token.Type == TokenMacro {
+			closing = "{{endmacro}}"
+		}
 		appendIssue(
 			IssueCodeControlBlockMismatch,
-			fmt.Sprintf("missing {{end}} for opening control block %q", opening.span.Raw),
+			fmt.Sprintf("missing %s for opening control block %q", closing, opening.span.Raw),
 			opening.span,
 			TokenKindControl,
 			opening.span.Token.Value,
 		)
 	}
 
+	issues = append(issues, constantConditionIssues(spans, nil)...)
 	return issues
 }
 
@@ -742,10 +840,11 @@ func validateSemanticTokenSpans(
 	strict bool,
 ) []StencilValidationIssue {
 	issues := make([]StencilValidationIssue, 0)
+	functions := append(append([]FunctionDefinition(nil), schema.Functions...), macroFunctionDefinitions(spans)...)
 	validateSemanticTokenSpansWithContext(
 		spans,
 		indexFieldDefinitions(schema.Fields),
-		indexFunctionDefinitions(schema.Functions),
+		indexFunctionDefinitions(functions),
 		semanticSeverity(strict),
 		[]map[string]semanticScopedVar{{}},
 		nil,
@@ -792,6 +891,37 @@ func validateSemanticTokenSpansWithContext(
 				_ = inferExpressionType(node, span, scopeStack, fieldIndex, functionIndex, severity, issues)
 			}
 			controlStack = append(controlStack, semanticControlFrame{TokenType: TokenUnless})
+		case TokenBlock:
+			object, ok := scopeBlockObject(span.Token)
+			if !ok {
+				controlStack = append(controlStack, semanticControlFrame{TokenType: TokenBlock})
+				continue
+			}
+			node, err := ParseExpressionStrict(object)
+			if err != nil {
+				controlStack = append(controlStack, semanticControlFrame{TokenType: TokenBlock})
+				continue
+			}
+			_ = inferExpressionType(node, span, scopeStack, fieldIndex, functionIndex, severity, issues)
+			scopeStack = append(scopeStack, scopeFieldVariables(node, scopeStack, fieldIndex))
+			controlStack = append(controlStack, semanticControlFrame{TokenType: TokenBlock, HasScope: true})
+		case TokenMacro:
+			localScope := make(map[string]semanticScopedVar)
+			if _, params, err := parseMacroHeader(span.Token.Value); err == nil {
+				for _, param := range params {
+					localScope[param] = semanticScopedVar{TypeInfo: semanticUnknownType(), Untyped: true}
+				}
+			}
+			scopeStack = append(scopeStack, localScope)
+			controlStack = append(controlStack, semanticControlFrame{TokenType: TokenMacro, HasScope: true})
+		case TokenEndMacro:
+			if len(controlStack) == 0 || controlStack[len(controlStack)-1].TokenType != TokenMacro {
+				continue
+			}
+			controlStack = controlStack[:len(controlStack)-1]
+			if len(scopeStack) > 1 {
+				scopeStack = scopeStack[:len(scopeStack)-1]
+			}
 		case TokenElsif:
 			node, err := ParseExpressionStrict(span.Token.Value)
 			if err != nil {
@@ -802,8 +932,10 @@ func validateSemanticTokenSpansWithContext(
 			pushedScope := false
 			forNode, err := parseForSyntaxWithExpressionParser(span.Token.Value, ParseExpressionStrict)
 			if err == nil {
+				collection, itemExprs, limit := splitForCollection(forNode.Collection)
+				_ = inferExpressionType(limit, span, scopeStack, fieldIndex, functionIndex, severity, issues)
 				collectionType := inferExpressionType(
-					forNode.Collection,
+					collection,
 					span,
 					scopeStack,
 					fieldIndex,
@@ -812,10 +944,31 @@ func validateSemanticTokenSpansWithContext(
 					issues,
 				)
 
+				for _, name := range []string{forNode.Variable, forNode.IndexVar} {
+					if name == "" {
+						continue
+					}
+					if _, shadowed := resolveScopedVariable(name, scopeStack); shadowed {
+						appendValidationIssueWithSuggestions(
+							issues,
+							IssueSeverityWarning,
+							IssueCodeVariableShadowing,
+							fmt.Sprintf("for loop variable %q shadows the variable of an enclosing loop. The outer value is not accessible inside this loop.", name),
+							span,
+							TokenKindControl,
+							span.Token.Value,
+							[]string{
+								fmt.Sprintf("Rename the nested loop variable %q.", name),
+								"Enable RenderOptions.StrictVariableShadowing to reject shadowing when rendering.",
+							},
+						)
+					}
+				}
+
 				localScope := make(map[string]semanticScopedVar)
 				localScope[forNode.Variable] = semanticScopedVar{
 					TypeInfo:     forLoopVariableType(collectionType),
-					SchemaPrefix: forLoopSchemaPrefix(forNode.Collection, scopeStack, fieldIndex),
+					SchemaPrefix: forLoopSchemaPrefix(collection, scopeStack, fieldIndex),
 				}
 				if forNode.IndexVar != "" {
 					localScope[forNode.IndexVar] = semanticScopedVar{
@@ -824,6 +977,9 @@ func validateSemanticTokenSpansWithContext(
 				}
 				scopeStack = append(scopeStack, localScope)
 				pushedScope = true
+				for _, expr := range itemExprs {
+					_ = inferExpressionType(expr, span, scopeStack, fieldIndex, functionIndex, severity, issues)
+				}
 			}
 
 			controlStack = append(controlStack, semanticControlFrame{
@@ -839,6 +995,16 @@ func validateSemanticTokenSpansWithContext(
 			if includeHook != nil {
 				*issues = append(*issues, includeHook(span, scopeStack)...)
 			}
+		case TokenElse:
+			// The {{else}} of a for loop renders without the loop variables
+			if len(controlStack) == 0 {
+				continue
+			}
+			top := &controlStack[len(controlStack)-1]
+			if top.TokenType == TokenFor && top.HasScope && len(scopeStack) > 1 {
+				scopeStack = scopeStack[:len(scopeStack)-1]
+				top.HasScope = false
+			}
 		case TokenEnd:
 			if len(controlStack) == 0 {
 				continue
@@ -905,7 +1071,23 @@ func inferExpressionType(
 		return semanticTypeFromLiteral(n.Value)
 	case *FunctionCallNode:
 		argTypes := make([]semanticTypeInfo, 0, len(n.Args))
-		for _, arg := range n.Args {
+		for i, arg := range n.Args {
+			if lambdaNode, ok := arg.(*LambdaNode); ok && i > 0 {
+				// Collection functions call a lambda with the items of
+				// their first argument.
+				argTypes = append(argTypes, inferLambdaType(
+					lambdaNode,
+					n.Args[0],
+					argTypes[0],
+					span,
+					scopeStack,
+					fieldIndex,
+					functionIndex,
+					severity,
+					issues,
+				))
+				continue
+			}
 			argTypes = append(argTypes, inferExpressionType(
 				arg,
 				span,
@@ -1005,6 +1187,8 @@ func inferExpressionType(
 		default:
 			return semanticUnknownType()
 		}
+	case *LambdaNode:
+		return inferLambdaType(n, nil, semanticUnknownType(), span, scopeStack, fieldIndex, functionIndex, severity, issues)
 	case *FieldAccessNode:
 		return inferExpressionType(n.Object, span, scopeStack, fieldIndex, functionIndex, severity, issues)
 	case *IndexAccessNode:
@@ -1022,6 +1206,34 @@ func inferExpressionType(
 	}
 }
 
+// inferLambdaType checks the body of a lambda with its parameter bound like
+// the variable of a for loop over collection. Lambdas are checked loosely:
+// their own type is unknown, so they satisfy any argument kind.
+func inferLambdaType(
+	node *LambdaNode,
+	collection ExpressionNode,
+	collectionType semanticTypeInfo,
+	span tokenSpan,
+	scopeStack []map[string]semanticScopedVar,
+	fieldIndex map[string]FieldDefinition,
+	functionIndex map[string]FunctionDefinition,
+	severity IssueSeverity,
+	issues *[]StencilValidationIssue,
+) semanticTypeInfo {
+	param := semanticScopedVar{TypeInfo: semanticUnknownType()}
+	if collection != nil {
+		param = semanticScopedVar{
+			TypeInfo:     forLoopVariableType(collectionType),
+			SchemaPrefix: forLoopSchemaPrefix(collection, scopeStack, fieldIndex),
+		}
+	}
+	lambdaScope := make([]map[string]semanticScopedVar, len(scopeStack), len(scopeStack)+1)
+	copy(lambdaScope, scopeStack)
+	lambdaScope = append(lambdaScope, map[string]semanticScopedVar{node.Param: param})
+	_ = inferExpressionType(node.Body, span, lambdaScope, fieldIndex, functionIndex, severity, issues)
+	return semanticUnknownType()
+}
+
 func appendValidationIssue(
 	issues *[]StencilValidationIssue,
 	severity IssueSeverity,
@@ -1079,6 +1291,23 @@ func indexFieldDefinitions(fields []FieldDefinition) map[string]FieldDefinition
 	return index
 }
 
+// macroFunctionDefinitions returns a function definition for each macro
+// defined in spans, so that calls of the macros validate.
+func macroFunctionDefinitions(spans []tokenSpan) []FunctionDefinition {
+	var defs []FunctionDefinition
+	for _, span := range spans {
+		if span.Malformed || span.Token.Type != TokenMacro {
+			continue
+		}
+		name, params, err := parseMacroHeader(span.Token.Value)
+		if err != nil {
+			continue
+		}
+		defs = append(defs, FunctionDefinition{Name: name, MaxArgs: len(params), ReturnKind: semanticKindAny})
+	}
+	return defs
+}
+
 func indexFunctionDefinitions(functions []FunctionDefinition) map[string]FunctionDefinition {
 	index := make(map[string]FunctionDefinition, len(functions))
 	for _, function := range functions {
@@ -1106,16 +1335,55 @@ func resolveFieldReference(
 		if remainder == "" {
 			return scopedVar.TypeInfo, scopedVar.SchemaPrefix, true
 		}
+		if scopedVar.Untyped {
+			return semanticUnknownType(), "", true
+		}
 		if scopedVar.SchemaPrefix == "" {
 			return semanticUnknownType(), "", false
 		}
 
 		scopedPath := joinReferencePath(scopedVar.SchemaPrefix, remainder)
 		fieldType, resolvedPath, found := lookupFieldType(scopedPath, fieldIndex)
+		if found && accessesCollectionField(scopedPath, scopedVar.SchemaPrefix, fieldIndex) {
+			fieldType = semanticCollectionOf(fieldType)
+		}
 		return fieldType, resolvedPath, found
 	}
 
-	return lookupFieldType(normalizedPath, fieldIndex)
+	fieldType, resolvedPath, found := lookupFieldType(normalizedPath, fieldIndex)
+	if found && accessesCollectionField(normalizedPath, "", fieldIndex) {
+		fieldType = semanticCollectionOf(fieldType)
+	}
+	return fieldType, resolvedPath, found
+}
+
+// accessesCollectionField reports whether path applies a field access to a
+// collection field after prefix, as in "items.price" for a collection
+// "items". Such an access maps over the collection, so it evaluates to a
+// list of the field's values.
+func accessesCollectionField(path, prefix string, fieldIndex map[string]FieldDefinition) bool {
+	for i := len(prefix) + 1; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if field, ok := fieldIndex[path[:i]]; ok && field.Collection {
+			return true
+		}
+	}
+	return false
+}
+
+// semanticCollectionOf returns the type of a list of values of elementType.
+func semanticCollectionOf(elementType semanticTypeInfo) semanticTypeInfo {
+	elementKind := elementType.Kind
+	if !elementType.Known || elementKind == "" || elementKind == semanticKindArray {
+		elementKind = semanticKindAny
+	}
+	return semanticTypeInfo{
+		Kind:        semanticKindArray,
+		Known:       true,
+		ElementKind: elementKind,
+	}
 }
 
 func lookupFieldType(path string, fieldIndex map[string]FieldDefinition) (semanticTypeInfo, string, bool) {
@@ -1203,11 +1471,20 @@ func joinReferencePath(prefix, remainder string) string {
 	return normalizedPrefix + "." + normalizedRemainder
 }
 
+// itemPreservingFunctions lists the functions that return a subset or
+// reordering of the items of their first argument.
+var itemPreservingFunctions = map[string]bool{"filter": true, "sortBy": true}
+
 func forLoopSchemaPrefix(
 	collection ExpressionNode,
 	scopeStack []map[string]semanticScopedVar,
 	fieldIndex map[string]FieldDefinition,
 ) string {
+	// filter and sortBy return items of their first argument.
+	if call, ok := collection.(*FunctionCallNode); ok && len(call.Args) > 0 && itemPreservingFunctions[call.Name] {
+		return forLoopSchemaPrefix(call.Args[0], scopeStack, fieldIndex)
+	}
+
 	collectionPath, ok := referencePathFromNode(collection)
 	if !ok {
 		return ""
@@ -1220,6 +1497,54 @@ func forLoopSchemaPrefix(
 	return stripLiteralIndices(resolvedPath)
 }
 
+// scopeFieldVariables returns the fields of the object of a scope block
+// that the schema defines, as variables of the block.
+func scopeFieldVariables(
+	object ExpressionNode,
+	scopeStack []map[string]semanticScopedVar,
+	fieldIndex map[string]FieldDefinition,
+) map[string]semanticScopedVar {
+	variables := make(map[string]semanticScopedVar)
+	objectPath, ok := referencePathFromNode(object)
+	if !ok {
+		return variables
+	}
+	_, resolvedPath, found := resolveFieldReference(objectPath, scopeStack, fieldIndex)
+	if !found {
+		return variables
+	}
+	prefix := stripLiteralIndices(resolvedPath) + "."
+	for path, field := range fieldIndex {
+		name := strings.TrimPrefix(path, prefix)
+		if name == path || name == "" || strings.ContainsAny(name, ".[") {
+			continue
+		}
+		variables[name] = semanticScopedVar{
+			TypeInfo:     semanticTypeFromField(field),
+			SchemaPrefix: path,
+		}
+	}
+	return variables
+}
+
+// splitForCollection returns the collection of a for loop collection
+// expression, the clause expressions evaluated in the loop variable's scope
+// and the limit expression, which is nil without a limit clause.
+func splitForCollection(node ExpressionNode) (ExpressionNode, []ExpressionNode, ExpressionNode) {
+	clauses, ok := node.(*ForCollectionNode)
+	if !ok {
+		return node, nil, nil
+	}
+	var itemExprs []ExpressionNode
+	if clauses.Where != nil {
+		itemExprs = append(itemExprs, clauses.Where)
+	}
+	if clauses.OrderBy != nil {
+		itemExprs = append(itemExprs, clauses.OrderBy)
+	}
+	return clauses.Collection, itemExprs, clauses.Limit
+}
+
 func forLoopVariableType(collectionType semanticTypeInfo) semanticTypeInfo {
 	switch collectionType.Kind {
 	case semanticKindArray:
@@ -1426,15 +1751,20 @@ func extractReferencesFromSpans(spans []tokenSpan) []TemplateTokenRef {
 			collectExpressionReferences(node, func(kind TokenKind, expression string) {
 				appendRef(span, kind, expression)
 			})
+		case TokenBlock, TokenMacro:
+			appendRef(span, TokenKindControl, span.Token.Value)
 		case TokenFor:
 			appendRef(span, TokenKindControl, span.Token.Value)
 			forNode, err := parseForSyntaxWithExpressionParser(span.Token.Value, ParseExpressionStrict)
 			if err != nil {
 				continue
 			}
-			collectExpressionReferences(forNode.Collection, func(kind TokenKind, expression string) {
-				appendRef(span, kind, expression)
-			})
+			collection, itemExprs, limit := splitForCollection(forNode.Collection)
+			for _, expr := range append([]ExpressionNode{collection}, append(itemExprs, limit)...) {
+				collectExpressionReferences(expr, func(kind TokenKind, expression string) {
+					appendRef(span, kind, expression)
+				})
+			}
 		case TokenInclude:
 			appendRef(span, TokenKindControl, span.Token.Value)
 			node, err := ParseExpressionStrict(span.Token.Value)
@@ -1476,6 +1806,8 @@ func collectExpressionReferences(node ExpressionNode, emit func(kind TokenKind,
 		collectExpressionReferences(n.Right, emit)
 	case *UnaryOpNode:
 		collectExpressionReferences(n.Operand, emit)
+	case *LambdaNode:
+		collectExpressionReferences(n.Body, emit)
 	case *FieldAccessNode:
 		collectExpressionReferences(n.Object, emit)
 	case *IndexAccessNode:
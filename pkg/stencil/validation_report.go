@@ -0,0 +1,264 @@
+package stencil
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Validation report formats accepted by ValidateTemplateResult.Render.
+const (
+	ValidationReportText  = "text"
+	ValidationReportJSON  = "json"
+	ValidationReportSARIF = "sarif"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// FilterByCode returns a copy of the result that only contains issues with
+// the given code. Valid and the error and warning counts of the summary
+// describe the remaining issues.
+func (r ValidateTemplateResult) FilterByCode(code StencilIssueCode) ValidateTemplateResult {
+	return r.filterIssues(func(issue StencilValidationIssue) bool {
+		return issue.Code == code
+	})
+}
+
+// ForPart returns a copy of the result that only contains issues in the given
+// DOCX part, such as "word/document.xml" or "word/header1.xml". Valid and the
+// error and warning counts of the summary describe the remaining issues.
+func (r ValidateTemplateResult) ForPart(part string) ValidateTemplateResult {
+	return r.filterIssues(func(issue StencilValidationIssue) bool {
+		return issue.Location.Part == part
+	})
+}
+
+// HasErrors reports whether the result contains an issue with error severity.
+func (r ValidateTemplateResult) HasErrors() bool {
+	if r.Summary.ErrorCount > 0 {
+		return true
+	}
+	for _, issue := range r.Issues {
+		if issue.Severity == IssueSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r ValidateTemplateResult) filterIssues(keep func(StencilValidationIssue) bool) ValidateTemplateResult {
+	issues := make([]StencilValidationIssue, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		if keep(issue) {
+			issues = append(issues, issue)
+		}
+	}
+
+	errorCount, warningCount := summarizeIssueSeverities(issues)
+	filtered := r
+	filtered.Issues = issues
+	filtered.Valid = errorCount == 0
+	filtered.Summary.ErrorCount = errorCount
+	filtered.Summary.WarningCount = warningCount
+	filtered.Summary.ReturnedIssueCount = len(issues)
+	return filtered
+}
+
+// Render formats the result as a report. The format is one of:
+//
+//   - "text": one line per issue in the form part:paragraph:column, followed
+//     by a summary line
+//   - "json": the result as indented JSON
+//   - "sarif": a SARIF 2.1.0 log for code scanning tools such as GitHub code
+//     scanning. Each issue becomes a result whose rule is the issue code and
+//     whose location is the DOCX part, with the paragraph as line and the
+//     UTF-16 offset in the paragraph as column.
+func (r ValidateTemplateResult) Render(format string) ([]byte, error) {
+	switch format {
+	case ValidationReportText:
+		return r.renderText(), nil
+	case ValidationReportJSON:
+		return json.MarshalIndent(r, "", "  ")
+	case ValidationReportSARIF:
+		return json.MarshalIndent(r.sarifLog(), "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported validation report format %q (want %q, %q or %q)",
+			format, ValidationReportText, ValidationReportJSON, ValidationReportSARIF)
+	}
+}
+
+func (r ValidateTemplateResult) renderText() []byte {
+	var b strings.Builder
+	for _, issue := range r.Issues {
+		fmt.Fprintf(&b, "%s:%d:%d: %s %s: %s\n",
+			issue.Location.Part,
+			issue.Location.ParagraphIndex+1,
+			issue.Location.CharStartUTF16+1,
+			issue.Severity,
+			issue.Code,
+			issue.Message,
+		)
+		for _, suggestion := range issue.Suggestions {
+			fmt.Fprintf(&b, "\thint: %s\n", suggestion)
+		}
+	}
+	fmt.Fprintf(&b, "%d error(s), %d warning(s) in %d token(s)",
+		r.Summary.ErrorCount, r.Summary.WarningCount, r.Summary.CheckedTokens)
+	if r.IssuesTruncated {
+		b.WriteString(", issues truncated")
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// sarifLog is the subset of the SARIF 2.1.0 format written by Render.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine"`
+	StartColumn int           `json:"startColumn"`
+	EndColumn   int           `json:"endColumn,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+// sarifRuleDescriptions describes the issue codes in SARIF rules.
+var sarifRuleDescriptions = map[StencilIssueCode]string{
+	IssueCodeSyntaxError:          "Template token has invalid syntax",
+	IssueCodeControlBlockMismatch: "Control blocks are not balanced",
+	IssueCodeUnsupportedExpr:      "Expression is not supported",
+	IssueCodeUnknownField:         "Field is not defined in the schema",
+	IssueCodeUnknownFunction:      "Function is not registered",
+	IssueCodeFunctionArgError:     "Function is called with invalid arguments",
+	IssueCodeTypeMismatch:         "Value has the wrong type",
+	IssueCodeVariableShadowing:    "Loop variable shadows an enclosing loop variable",
+}
+
+func (r ValidateTemplateResult) sarifLog() sarifLog {
+	codes := make([]string, 0)
+	seen := make(map[StencilIssueCode]bool)
+	for _, issue := range r.Issues {
+		if !seen[issue.Code] {
+			seen[issue.Code] = true
+			codes = append(codes, string(issue.Code))
+		}
+	}
+	sort.Strings(codes)
+
+	rules := make([]sarifRule, len(codes))
+	ruleIndex := make(map[string]int, len(codes))
+	for i, code := range codes {
+		description := sarifRuleDescriptions[StencilIssueCode(code)]
+		if description == "" {
+			description = code
+		}
+		rules[i] = sarifRule{ID: code, ShortDescription: sarifMessage{Text: description}}
+		ruleIndex[code] = i
+	}
+
+	results := make([]sarifResult, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		level := "error"
+		if issue.Severity == IssueSeverityWarning {
+			level = "warning"
+		}
+		region := sarifRegion{
+			StartLine:   issue.Location.ParagraphIndex + 1,
+			StartColumn: issue.Location.CharStartUTF16 + 1,
+		}
+		if issue.Location.CharEndUTF16 > issue.Location.CharStartUTF16 {
+			region.EndColumn = issue.Location.CharEndUTF16 + 1
+		}
+		if issue.Token.Raw != "" {
+			region.Snippet = &sarifMessage{Text: issue.Token.Raw}
+		}
+		result := sarifResult{
+			RuleID:    string(issue.Code),
+			RuleIndex: ruleIndex[string(issue.Code)],
+			Level:     level,
+			Message:   sarifMessage{Text: issue.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: issue.Location.Part},
+					Region:           region,
+				},
+			}},
+		}
+		if issue.Location.AnchorID != "" {
+			key := "stencilAnchor/v1"
+			if strings.HasPrefix(issue.Location.AnchorID, contentAnchorPrefix) {
+				key = "stencilContentAnchor/v1"
+			}
+			result.PartialFingerprints = map[string]string{key: issue.Location.AnchorID}
+		}
+		results = append(results, result)
+	}
+
+	return sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "go-stencil",
+				InformationURI: "https://github.com/benjaminschreck/go-stencil",
+				Version:        validationParserVersion,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
@@ -0,0 +1,131 @@
+package stencil
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func validationReportResult(t *testing.T) ValidateTemplateResult {
+	t.Helper()
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`<w:p><w:r><w:t>Hello {{name}}</w:t></w:r></w:p><w:p><w:r><w:t>{{if ready}}</w:t></w:r></w:p>`),
+		"word/header1.xml":  validationHeaderXML(`<w:p><w:r><w:t>{{headerMissing}}</w:t></w:r></w:p>`),
+	})
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes:       docx,
+		IncludeWarnings: true,
+		Schema: ValidationSchema{
+			Fields: []FieldDefinition{{Path: "ready", Type: "boolean"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+	return result
+}
+
+func TestValidateTemplateResultFilters(t *testing.T) {
+	result := validationReportResult(t)
+	if !result.HasErrors() {
+		t.Fatalf("expected errors, got %+v", result.Issues)
+	}
+
+	mismatches := result.FilterByCode(IssueCodeControlBlockMismatch)
+	if len(mismatches.Issues) != 1 || mismatches.Valid || mismatches.Summary.ErrorCount != 1 || mismatches.Summary.WarningCount != 0 {
+		t.Fatalf("unexpected control block mismatch result %+v", mismatches)
+	}
+
+	unknown := result.FilterByCode(IssueCodeUnknownField)
+	if len(unknown.Issues) != 2 || unknown.HasErrors() || !unknown.Valid || unknown.Summary.ReturnedIssueCount != 2 {
+		t.Fatalf("unexpected unknown field result %+v", unknown)
+	}
+
+	header := result.ForPart("word/header1.xml")
+	if len(header.Issues) != 1 || header.Issues[0].Token.Expression != "headerMissing" {
+		t.Fatalf("unexpected header issues %+v", header.Issues)
+	}
+	if chained := result.ForPart("word/document.xml").FilterByCode(IssueCodeUnknownField); len(chained.Issues) != 1 {
+		t.Fatalf("expected one unknown field in the document, got %+v", chained.Issues)
+	}
+	if len(result.Issues) != 3 {
+		t.Fatalf("filtering changed the original result: %+v", result.Issues)
+	}
+}
+
+func TestValidateTemplateResultRenderText(t *testing.T) {
+	report, err := validationReportResult(t).FilterByCode(IssueCodeUnknownField).Render(ValidationReportText)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	text := string(report)
+	if !strings.Contains(text, "word/document.xml:1:7: warning UNKNOWN_FIELD: ") {
+		t.Fatalf("expected document issue line, got:\n%s", text)
+	}
+	if !strings.Contains(text, "word/header1.xml:1:1: warning UNKNOWN_FIELD: ") {
+		t.Fatalf("expected header issue line, got:\n%s", text)
+	}
+	if !strings.HasSuffix(text, "0 error(s), 2 warning(s) in 3 token(s)\n") {
+		t.Fatalf("expected summary line, got:\n%s", text)
+	}
+}
+
+func TestValidateTemplateResultRenderJSON(t *testing.T) {
+	result := validationReportResult(t)
+	report, err := result.Render(ValidationReportJSON)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	var decoded ValidateTemplateResult
+	if err := json.Unmarshal(report, &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded.Issues) != len(result.Issues) || decoded.Metadata.DocumentHash != result.Metadata.DocumentHash {
+		t.Fatalf("JSON report does not match result: %s", report)
+	}
+}
+
+func TestValidateTemplateResultRenderSARIF(t *testing.T) {
+	report, err := validationReportResult(t).Render(ValidationReportSARIF)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(report, &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log %s", report)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "CONTROL_BLOCK_MISMATCH" || run.Tool.Driver.Rules[1].ID != "UNKNOWN_FIELD" {
+		t.Fatalf("unexpected rules %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("unexpected results %+v", run.Results)
+	}
+
+	var mismatch *sarifResult
+	for i := range run.Results {
+		if run.Results[i].RuleID == "CONTROL_BLOCK_MISMATCH" {
+			mismatch = &run.Results[i]
+		}
+	}
+	if mismatch == nil || mismatch.Level != "error" || mismatch.RuleIndex != 0 {
+		t.Fatalf("unexpected control block result %+v", mismatch)
+	}
+	location := mismatch.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "word/document.xml" || location.Region.StartLine != 2 || location.Region.StartColumn != 1 {
+		t.Fatalf("unexpected location %+v", location)
+	}
+	if location.Region.Snippet == nil || location.Region.Snippet.Text != "{{if ready}}" {
+		t.Fatalf("unexpected snippet %+v", location.Region.Snippet)
+	}
+}
+
+func TestValidateTemplateResultRenderUnknownFormat(t *testing.T) {
+	if _, err := (ValidateTemplateResult{}).Render("xml"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
@@ -71,6 +71,35 @@ func TestValidateTemplateSyntax_SplitAcrossRunsAndHyperlink(t *testing.T) {
 	}
 }
 
+func TestValidateTemplateSyntax_SplitAroundNoteReference(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p>
+				<w:r><w:t>{{na</w:t><w:footnoteReference w:id="1"/><w:t>me}}</w:t></w:r>
+			</w:p>
+		`),
+	})
+
+	result, err := ValidateTemplateSyntax(ValidateTemplateSyntaxInput{DocxBytes: docx})
+	if err != nil {
+		t.Fatalf("ValidateTemplateSyntax failed: %v", err)
+	}
+	if !result.Valid {
+		t.Fatalf("expected valid template, got issues: %+v", result.Issues)
+	}
+
+	refs, err := ExtractReferences(ExtractReferencesInput{DocxBytes: docx})
+	if err != nil {
+		t.Fatalf("ExtractReferences failed: %v", err)
+	}
+	if len(refs.References) != 1 || refs.References[0].Expression != "name" {
+		t.Fatalf("references = %+v, want one reference to name", refs.References)
+	}
+	if loc := refs.References[0].Location; loc.CharStartUTF16 != 0 || loc.CharEndUTF16 != 8 {
+		t.Fatalf("UTF-16 range = [%d,%d), want [0,8)", loc.CharStartUTF16, loc.CharEndUTF16)
+	}
+}
+
 func TestValidateTemplateSyntax_HeaderFooterTraversalOrder(t *testing.T) {
 	docx := buildValidationDOCX(t, map[string]string{
 		"word/document.xml": validationDocumentXML(`<w:p><w:r><w:t>{{body}}</w:t></w:r></w:p>`),
@@ -896,3 +925,161 @@ func validationFooterXML(bodyElements string) string {
 %s
 </w:ftr>`, bodyElements)
 }
+
+func TestValidateTemplate_ForClausesUseLoopScope(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p><w:r><w:t>{{for item in items where item.qty > 0 &amp; item.missing order by item.name limit maxItems}}{{item.name}}{{end}}</w:t></w:r></w:p>
+		`),
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes:       docx,
+		Strict:          true,
+		IncludeWarnings: true,
+		Schema: ValidationSchema{
+			Fields: []FieldDefinition{
+				{Path: "items", Type: "array", Collection: true},
+				{Path: "items.qty", Type: "number"},
+				{Path: "items.name", Type: "string"},
+				{Path: "maxItems", Type: "number"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+
+	if len(result.Issues) != 1 {
+		t.Fatalf("issue count=%d, want 1: %+v", len(result.Issues), result.Issues)
+	}
+	if issue := result.Issues[0]; issue.Code != IssueCodeUnknownField || !strings.Contains(issue.Message, "item.missing") {
+		t.Fatalf("expected unknown field item.missing, got %+v", issue)
+	}
+
+	refs, err := ExtractReferences(ExtractReferencesInput{DocxBytes: docx})
+	if err != nil {
+		t.Fatalf("ExtractReferences failed: %v", err)
+	}
+	var expressions []string
+	for _, ref := range refs.References {
+		expressions = append(expressions, ref.Expression)
+	}
+	if joined := strings.Join(expressions, ","); !strings.Contains(joined, "item.missing") || !strings.Contains(joined, "item.name") || !strings.Contains(joined, "maxItems") {
+		t.Fatalf("expected clause references, got %v", expressions)
+	}
+}
+
+func TestValidateTemplate_ForElse(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p><w:r><w:t>{{for item in items}}{{item.name}}{{else}}No items for {{customer}}{{item.name}}{{end}}</w:t></w:r></w:p>
+		`),
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes: docx,
+		Strict:    true,
+		Schema: ValidationSchema{
+			Fields: []FieldDefinition{
+				{Path: "items", Type: "array", Collection: true},
+				{Path: "items.name", Type: "string"},
+				{Path: "customer", Type: "string"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+
+	// The loop variable is out of scope in the {{else}} branch
+	if len(result.Issues) != 1 {
+		t.Fatalf("issue count=%d, want 1: %+v", len(result.Issues), result.Issues)
+	}
+	if issue := result.Issues[0]; issue.Code != IssueCodeUnknownField || issue.Token.Expression != "item.name" {
+		t.Fatalf("expected unknown field item.name in the else branch, got %+v", issue)
+	}
+
+	dictionary, err := BuildDataDictionary(DataDictionaryInput{DocxBytes: docx})
+	if err != nil {
+		t.Fatalf("BuildDataDictionary failed: %v", err)
+	}
+	var paths []string
+	for _, field := range dictionary.Fields {
+		paths = append(paths, field.Path)
+	}
+	if got := strings.Join(paths, ", "); got != "customer, item.name, items, items.name" {
+		t.Errorf("data dictionary paths = %s", got)
+	}
+}
+
+func TestValidateTemplate_CollectionFieldShorthand(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p><w:r><w:t>{{total(items.price)}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{format(items.price)}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{for item in items}}{{format(item.price)}}{{end}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{format(items[0].price)}}</w:t></w:r></w:p>
+		`),
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes:       docx,
+		Strict:          true,
+		IncludeWarnings: true,
+		Schema: ValidationSchema{
+			Fields: []FieldDefinition{
+				{Path: "items", Type: "object", Collection: true},
+				{Path: "items.price", Type: "number"},
+			},
+			Functions: []FunctionDefinition{
+				{Name: "total", MinArgs: 1, MaxArgs: 1, ArgKinds: [][]string{{"array"}}, ReturnKind: "number"},
+				{Name: "format", MinArgs: 1, MaxArgs: 1, ArgKinds: [][]string{{"number"}}, ReturnKind: "string"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+
+	if len(result.Issues) != 1 {
+		t.Fatalf("issue count=%d, want 1: %+v", len(result.Issues), result.Issues)
+	}
+	issue := result.Issues[0]
+	if issue.Code != IssueCodeTypeMismatch || issue.Location.ParagraphIndex != 1 {
+		t.Fatalf("expected type mismatch for format(items.price), got %+v", issue)
+	}
+}
+
+func TestValidateTemplate_NestedLoopVariableShadowing(t *testing.T) {
+	docx := buildValidationDOCX(t, map[string]string{
+		"word/document.xml": validationDocumentXML(`
+			<w:p><w:r><w:t>{{for item in items}}{{for item in item.children}}{{item}}{{end}}{{end}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{for i, row in items}}{{for i, cell in row.children}}{{cell}}{{end}}{{end}}</w:t></w:r></w:p>
+			<w:p><w:r><w:t>{{for item in items}}{{end}}{{for item in items}}{{end}}</w:t></w:r></w:p>
+		`),
+	})
+
+	result, err := ValidateTemplate(ValidateTemplateInput{
+		DocxBytes:       docx,
+		IncludeWarnings: true,
+	})
+	if err != nil {
+		t.Fatalf("ValidateTemplate failed: %v", err)
+	}
+
+	var shadowed []string
+	for _, issue := range result.Issues {
+		if issue.Code != IssueCodeVariableShadowing {
+			continue
+		}
+		if issue.Severity != IssueSeverityWarning {
+			t.Fatalf("expected warning severity, got %+v", issue)
+		}
+		shadowed = append(shadowed, issue.Token.Expression)
+	}
+	want := []string{"item in item.children", "i, cell in row.children"}
+	if !reflect.DeepEqual(shadowed, want) {
+		t.Fatalf("shadowing issues = %v, want %v", shadowed, want)
+	}
+}
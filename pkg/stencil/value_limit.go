@@ -0,0 +1,61 @@
+package stencil
+
+import "fmt"
+
+// valueSizeExceeds reports whether the estimated memory held by value
+// exceeds limit bytes: the length of strings and keys plus 16 bytes per
+// element of lists and maps and per other value. It stops at the limit, so
+// large values are not walked entirely.
+func valueSizeExceeds(value interface{}, limit int) bool {
+	return estimateValueSize(value, limit) > limit
+}
+
+// estimateValueSize returns the estimated size of value, or a number larger
+// than limit once the estimate exceeds it.
+func estimateValueSize(value interface{}, limit int) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []interface{}:
+		size := 16 * len(v)
+		for _, item := range v {
+			if size > limit {
+				break
+			}
+			size += estimateValueSize(item, limit-size)
+		}
+		return size
+	case []string:
+		size := 16 * len(v)
+		for _, item := range v {
+			size += len(item)
+		}
+		return size
+	case map[string]interface{}:
+		return estimateMapSize(v, limit)
+	case TemplateData:
+		return estimateMapSize(v, limit)
+	default:
+		return 16
+	}
+}
+
+func estimateMapSize(m map[string]interface{}, limit int) int {
+	size := 16 * len(m)
+	for key, item := range m {
+		if size > limit {
+			break
+		}
+		size += len(key) + estimateValueSize(item, limit-size)
+	}
+	return size
+}
+
+// checkValueSize fails when the render limits the size of values, as
+// scripts do, and value exceeds the limit.
+func (h *renderHelpers) checkValueSize(value interface{}) error {
+	if h == nil || h.valueSizeLimit <= 0 || !valueSizeExceeds(value, h.valueSizeLimit) {
+		return nil
+	}
+	return fmt.Errorf("value exceeds the memory limit of %d bytes", h.valueSizeLimit)
+}
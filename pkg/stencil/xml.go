@@ -20,20 +20,24 @@ type (
 type (
 	Document = xml.Document
 	Body     = xml.Body
+	Section  = xml.Section
 )
 
 // Re-export paragraph types
 type (
-	Paragraph           = xml.Paragraph
-	ParagraphProperties = xml.ParagraphProperties
-	TextAlignment       = xml.TextAlignment
-	Tabs                = xml.Tabs
-	Tab                 = xml.Tab
-	Alignment           = xml.Alignment
-	Indentation         = xml.Indentation
-	Spacing             = xml.Spacing
-	ProofErr            = xml.ProofErr
-	Hyperlink           = xml.Hyperlink
+	Paragraph              = xml.Paragraph
+	ParagraphProperties    = xml.ParagraphProperties
+	TextAlignment          = xml.TextAlignment
+	Tabs                   = xml.Tabs
+	Tab                    = xml.Tab
+	Alignment              = xml.Alignment
+	Indentation            = xml.Indentation
+	Spacing                = xml.Spacing
+	ProofErr               = xml.ProofErr
+	Hyperlink              = xml.Hyperlink
+	AlternateContent       = xml.AlternateContent
+	AlternateContentBranch = xml.AlternateContentBranch
+	ParagraphOptions       = xml.ParagraphOptions
 )
 
 // Re-export run types
@@ -50,6 +54,7 @@ type (
 	RunStyle       = xml.RunStyle
 	UnderlineStyle = xml.UnderlineStyle
 	VerticalAlign  = xml.VerticalAlign
+	RunProps       = xml.RunProps
 )
 
 // Re-export table types
@@ -79,4 +84,14 @@ type (
 // Re-export functions
 var (
 	ParseDocument = xml.ParseDocument
+	NewRun        = xml.NewRun
+	NewParagraph  = xml.NewParagraph
+	NewTable      = xml.NewTable
 )
+
+// escapeXMLText escapes s for use in XML character data or attribute values,
+// dropping characters a document part cannot contain. All text the engine
+// writes into parts goes through it or the xml package's marshaling.
+func escapeXMLText(s string) string {
+	return xml.EscapeString(s)
+}
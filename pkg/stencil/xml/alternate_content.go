@@ -0,0 +1,250 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// AlternateContent represents an mc:AlternateContent element within a
+// paragraph. All of its branches are kept. Each branch is parsed into
+// Content so that template tokens in it can be rendered, and keeps its
+// original XML so that branches without tokens are written back verbatim.
+type AlternateContent struct {
+	Attrs    []xml.Attr
+	Branches []AlternateContentBranch
+	// Selected is the index of the branch a consumer displays: the first
+	// Choice whose requirements are understood, else the Fallback, else the
+	// first Choice. It is -1 when there are no branches.
+	Selected int
+}
+
+// AlternateContentBranch is an mc:Choice or mc:Fallback branch.
+type AlternateContentBranch struct {
+	Name    string // "Choice" or "Fallback"
+	Attrs   []xml.Attr
+	Content []ParagraphContent
+	// RawXML is the original inner XML of the branch. It is written instead
+	// of Content while set; rendering clears it for branches it changes.
+	RawXML []byte
+	// RawXMLMarker stands in for RawXML while the document is marshaled.
+	RawXMLMarker string
+}
+
+// isParagraphContent implements the ParagraphContent interface
+func (a AlternateContent) isParagraphContent() {}
+
+// GetText returns the text of the selected branch
+func (a *AlternateContent) GetText() string {
+	if a.Selected < 0 || a.Selected >= len(a.Branches) {
+		return ""
+	}
+	para := Paragraph{Content: a.Branches[a.Selected].Content}
+	return para.GetText()
+}
+
+// MarshalXML writes the element with all of its branches
+func (a AlternateContent) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "mc:AlternateContent"}
+	start.Attr = append([]xml.Attr(nil), a.Attrs...)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, branch := range a.Branches {
+		branchStart := xml.StartElement{
+			Name: xml.Name{Local: "mc:" + branch.Name},
+			Attr: append([]xml.Attr(nil), branch.Attrs...),
+		}
+		if err := e.EncodeToken(branchStart); err != nil {
+			return err
+		}
+		if branch.RawXMLMarker != "" {
+			if err := e.EncodeToken(xml.CharData(branch.RawXMLMarker)); err != nil {
+				return err
+			}
+		} else if err := encodeParagraphContent(e, branch.Content); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(xml.EndElement{Name: branchStart.Name}); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(xml.EndElement{Name: start.Name})
+}
+
+// collectAlternateContentBranch handles mc:AlternateContent by keeping all
+// of its branches in a single AlternateContent. The runs and hyperlinks of
+// the selected branch are also added to the legacy run and hyperlink lists.
+func collectAlternateContentBranch(
+	d *xml.Decoder,
+	start xml.StartElement,
+	inScopeNamespaces map[string]string,
+	tempContent *[]ParagraphContent,
+	tempRuns *[]Run,
+	tempHyperlinks *[]Hyperlink,
+	useContent *bool,
+) error {
+	alternate := &AlternateContent{
+		Attrs:    append([]xml.Attr(nil), start.Attr...),
+		Selected: -1,
+	}
+	firstChoice, fallback := -1, -1
+
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != markupCompatibilityNamespace || (t.Name.Local != "Choice" && t.Name.Local != "Fallback") {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			branch, err := captureAlternateContentBranch(d, t, inScopeNamespaces)
+			if err != nil {
+				return err
+			}
+			index := len(alternate.Branches)
+			alternate.Branches = append(alternate.Branches, branch)
+			if t.Name.Local == "Fallback" {
+				if fallback < 0 {
+					fallback = index
+				}
+				continue
+			}
+			if firstChoice < 0 {
+				firstChoice = index
+			}
+			if alternate.Selected < 0 && choiceRequirementsSupported(inScopeNamespaces, t) {
+				alternate.Selected = index
+			}
+		case xml.EndElement:
+			if t.Name.Local == start.Name.Local && t.Name.Space == start.Name.Space {
+				if alternate.Selected < 0 {
+					// Prevent silent data loss when no choice requirements are
+					// recognized and the AlternateContent has no fallback branch.
+					alternate.Selected = fallback
+					if alternate.Selected < 0 {
+						alternate.Selected = firstChoice
+					}
+				}
+				if alternate.Selected >= 0 {
+					for _, content := range alternate.Branches[alternate.Selected].Content {
+						switch c := content.(type) {
+						case *Run:
+							*tempRuns = append(*tempRuns, *c)
+						case *Hyperlink:
+							*tempHyperlinks = append(*tempHyperlinks, *c)
+						}
+					}
+				}
+				*tempContent = append(*tempContent, alternate)
+				*useContent = true
+				return nil
+			}
+		}
+	}
+}
+
+// captureAlternateContentBranch reads a Choice or Fallback branch, keeping
+// its original XML and parsing its paragraph content.
+func captureAlternateContentBranch(d *xml.Decoder, start xml.StartElement, inScopeNamespaces map[string]string) (AlternateContentBranch, error) {
+	branch := AlternateContentBranch{
+		Name:  start.Name.Local,
+		Attrs: append([]xml.Attr(nil), start.Attr...),
+	}
+
+	tokens := []xml.Token{start.Copy()}
+	var raw bytes.Buffer
+	depth := 1
+	for depth > 0 {
+		token, err := d.Token()
+		if err != nil {
+			return branch, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			writeRawStartElement(&raw, t)
+		case xml.EndElement:
+			depth--
+			if depth > 0 {
+				writeRawEndElement(&raw, t)
+			}
+		case xml.CharData:
+			raw.WriteString(EscapeString(string(t)))
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+	branch.RawXML = raw.Bytes()
+
+	// Replay the branch to parse its content with the usual rules.
+	replay := xml.NewTokenDecoder(&tokenSliceReader{tokens: tokens})
+	first, err := replay.Token()
+	if err != nil {
+		return branch, err
+	}
+	var runs []Run
+	var hyperlinks []Hyperlink
+	var useContent bool
+	if err := collectNestedParagraphContent(replay, first.(xml.StartElement), inScopeNamespaces, &branch.Content, &runs, &hyperlinks, &useContent); err != nil {
+		return branch, err
+	}
+	return branch, nil
+}
+
+// tokenSliceReader replays recorded tokens as an xml.TokenReader.
+type tokenSliceReader struct {
+	tokens []xml.Token
+}
+
+func (r *tokenSliceReader) Token() (xml.Token, error) {
+	if len(r.tokens) == 0 {
+		return nil, io.EOF
+	}
+	token := r.tokens[0]
+	r.tokens = r.tokens[1:]
+	return token, nil
+}
+
+func writeRawStartElement(buf *bytes.Buffer, start xml.StartElement) {
+	buf.WriteString("<")
+	writeRawName(buf, start.Name)
+	for _, attr := range start.Attr {
+		buf.WriteString(" ")
+		if attr.Name.Space == "xmlns" {
+			buf.WriteString("xmlns:")
+			buf.WriteString(attr.Name.Local)
+		} else {
+			writeRawName(buf, attr.Name)
+		}
+		buf.WriteString(`="`)
+		buf.WriteString(EscapeString(attr.Value))
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+}
+
+func writeRawEndElement(buf *bytes.Buffer, end xml.EndElement) {
+	buf.WriteString("</")
+	writeRawName(buf, end.Name)
+	buf.WriteString(">")
+}
+
+func writeRawName(buf *bytes.Buffer, name xml.Name) {
+	if name.Space != "" {
+		buf.WriteString(namespaceToPrefix(name.Space))
+		buf.WriteString(":")
+	}
+	buf.WriteString(name.Local)
+}
@@ -0,0 +1,166 @@
+package xml
+
+import (
+	"math"
+	"strings"
+)
+
+// defaultTableWidth is the total column width of NewTable in twips, the
+// text width of an A4 or Letter page with default margins.
+const defaultTableWidth = 9000
+
+// RunProps holds the common formatting options of NewRun.
+type RunProps struct {
+	Bold      bool
+	Italic    bool
+	Underline bool
+	Strike    bool
+	// Color is a hex RGB color such as "C00000".
+	Color string
+	// Size is the font size in points; zero keeps the size of the style.
+	Size float64
+	// Font is a font family such as "Arial".
+	Font string
+	// Style is the ID of a character style.
+	Style string
+}
+
+// ParagraphOptions holds the options of NewParagraph.
+type ParagraphOptions struct {
+	// Style is the ID of a paragraph style such as "Heading1".
+	Style string
+	// Alignment is "left", "center", "right" or "both" (justified).
+	Alignment string
+	// Run formats the text of the paragraph.
+	Run RunProps
+}
+
+// NewRun returns a run of text with the given formatting.
+//
+// Example:
+//
+//	run := xml.NewRun("Total", xml.RunProps{Bold: true})
+func NewRun(text string, props RunProps) *Run {
+	return &Run{
+		Properties: props.runProperties(),
+		Text:       &Text{Content: text, Space: "preserve"},
+	}
+}
+
+// runProperties returns the run properties for p, or nil when p sets none.
+func (p RunProps) runProperties() *RunProperties {
+	if p == (RunProps{}) {
+		return nil
+	}
+	props := &RunProperties{}
+	if p.Style != "" {
+		props.Style = &RunStyle{Val: p.Style}
+	}
+	if p.Font != "" {
+		props.Font = &Font{ASCII: p.Font, HAnsi: p.Font, CS: p.Font}
+	}
+	if p.Bold {
+		props.Bold = &Empty{}
+	}
+	if p.Italic {
+		props.Italic = &Empty{}
+	}
+	if p.Underline {
+		props.Underline = &UnderlineStyle{Val: "single"}
+	}
+	if p.Strike {
+		props.Strike = &Empty{}
+	}
+	if p.Color != "" {
+		props.Color = &Color{Val: strings.TrimPrefix(p.Color, "#")}
+	}
+	if p.Size > 0 {
+		// Font sizes are stored in half-points.
+		props.Size = &Size{Val: int(math.Round(p.Size * 2))}
+	}
+	return props
+}
+
+// NewParagraph returns a paragraph of text. Line breaks in text become line
+// breaks in the paragraph.
+//
+// Example:
+//
+//	para := xml.NewParagraph("Summary", xml.ParagraphOptions{Style: "Heading2"})
+func NewParagraph(text string, opts ParagraphOptions) *Paragraph {
+	para := &Paragraph{}
+	if opts.Style != "" || opts.Alignment != "" {
+		para.Properties = &ParagraphProperties{}
+		if opts.Style != "" {
+			para.Properties.Style = &Style{Val: opts.Style}
+		}
+		if opts.Alignment != "" {
+			para.Properties.Alignment = &Alignment{Val: opts.Alignment}
+		}
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			para.Runs = append(para.Runs, Run{Properties: opts.Run.runProperties(), Break: &Break{}})
+		}
+		if line != "" || i == 0 {
+			para.Runs = append(para.Runs, *NewRun(line, opts.Run))
+		}
+	}
+	return para
+}
+
+// NewTable returns a table with single borders and the given number of
+// equally wide columns. Every cell holds an empty paragraph; fill the cells
+// with Cell and SetText.
+//
+// Example:
+//
+//	table := xml.NewTable(2, 2)
+//	table.Cell(0, 0).SetText("Item", xml.ParagraphOptions{Run: xml.RunProps{Bold: true}})
+func NewTable(rows, cols int) *Table {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 0 {
+		rows = 0
+	}
+	border := &BorderProperties{Val: "single", Sz: "4", Space: "0", Color: "auto"}
+	table := &Table{
+		Properties: &TableProperties{
+			Width: &Width{Type: "auto", Val: 0},
+			Borders: &TableBorders{
+				Top: border, Left: border, Bottom: border, Right: border,
+				InsideH: border, InsideV: border,
+			},
+		},
+		Grid: &TableGrid{Columns: make([]GridColumn, cols)},
+		Rows: make([]TableRow, rows),
+	}
+	for i := range table.Grid.Columns {
+		table.Grid.Columns[i] = GridColumn{Width: defaultTableWidth / cols}
+	}
+	for i := range table.Rows {
+		table.Rows[i].Cells = make([]TableCell, cols)
+		for j := range table.Rows[i].Cells {
+			table.Rows[i].Cells[j] = TableCell{
+				Properties: &TableCellProperties{Width: &Width{Type: "dxa", Val: defaultTableWidth / cols}},
+				Paragraphs: []Paragraph{{}},
+			}
+		}
+	}
+	return table
+}
+
+// Cell returns the cell at the 0-based row and column, or nil if there is
+// none.
+func (t *Table) Cell(row, col int) *TableCell {
+	if row < 0 || row >= len(t.Rows) || col < 0 || col >= len(t.Rows[row].Cells) {
+		return nil
+	}
+	return &t.Rows[row].Cells[col]
+}
+
+// SetText replaces the content of the cell with a paragraph of text.
+func (c *TableCell) SetText(text string, opts ParagraphOptions) {
+	c.Paragraphs = []Paragraph{*NewParagraph(text, opts)}
+}
@@ -0,0 +1,49 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestBuilders(t *testing.T) {
+	run := NewRun(" Total ", RunProps{Bold: true, Color: "#C00000", Size: 10.5, Font: "Arial"})
+	props := run.Properties
+	if props == nil || props.Bold == nil || props.Italic != nil || props.Color.Val != "C00000" || props.Size.Val != 21 || props.Font.ASCII != "Arial" {
+		t.Errorf("unexpected run properties: %+v", props)
+	}
+	if run.Text.Content != " Total " || run.Text.Space != "preserve" {
+		t.Errorf("unexpected run text: %+v", run.Text)
+	}
+	if NewRun("plain", RunProps{}).Properties != nil {
+		t.Error("run without formatting has properties")
+	}
+
+	para := NewParagraph("first\nsecond", ParagraphOptions{Style: "Heading1", Alignment: "center", Run: RunProps{Italic: true}})
+	if para.Properties.Style.Val != "Heading1" || para.Properties.Alignment.Val != "center" {
+		t.Errorf("unexpected paragraph properties: %+v", para.Properties)
+	}
+	if len(para.Runs) != 3 || para.Runs[1].Break == nil || para.Runs[2].Text.Content != "second" || para.Runs[2].Properties.Italic == nil {
+		t.Errorf("unexpected paragraph runs: %+v", para.Runs)
+	}
+	if NewParagraph("", ParagraphOptions{}).Properties != nil {
+		t.Error("paragraph without options has properties")
+	}
+
+	table := NewTable(2, 3)
+	if len(table.Grid.Columns) != 3 || len(table.Rows) != 2 || len(table.Rows[1].Cells) != 3 {
+		t.Fatalf("unexpected table shape: %+v", table)
+	}
+	for _, row := range table.Rows {
+		for _, cell := range row.Cells {
+			if len(cell.Paragraphs) != 1 {
+				t.Fatalf("cell without a paragraph: %+v", cell)
+			}
+		}
+	}
+	table.Cell(1, 2).SetText("42", ParagraphOptions{Alignment: "right"})
+	if got := table.Rows[1].Cells[2].GetText(); got != "42" {
+		t.Errorf("cell text = %q", got)
+	}
+	if table.Cell(2, 0) != nil || table.Cell(0, 3) != nil {
+		t.Error("Cell returned a cell outside the table")
+	}
+}
@@ -243,6 +243,75 @@ func (b Body) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.EncodeToken(xml.EndElement{Name: start.Name})
 }
 
+// Section is a run of consecutive body elements that share page setup.
+type Section struct {
+	// Elements are the paragraphs and tables of the section in order. A
+	// section that is not the last one ends with the paragraph holding its
+	// section properties.
+	Elements []BodyElement
+	// Properties is the w:sectPr element of the section, or nil if the
+	// document does not define one.
+	Properties *RawXMLElement
+}
+
+// Elements returns the top-level paragraphs and tables of the body in order.
+func (doc *Document) Elements() []BodyElement {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+	return doc.Body.Elements
+}
+
+// Paragraphs returns the top-level paragraphs of the body in order.
+// Paragraphs inside tables are reached through Tables.
+func (doc *Document) Paragraphs() []*Paragraph {
+	var paragraphs []*Paragraph
+	for _, elem := range doc.Elements() {
+		if para, ok := elem.(*Paragraph); ok {
+			paragraphs = append(paragraphs, para)
+		}
+	}
+	return paragraphs
+}
+
+// Tables returns the top-level tables of the body in order.
+func (doc *Document) Tables() []*Table {
+	var tables []*Table
+	for _, elem := range doc.Elements() {
+		if table, ok := elem.(*Table); ok {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// Sections returns the sections of the body in order. Every section but the
+// last ends at a paragraph with section properties; the last section uses
+// the section properties of the body.
+func (doc *Document) Sections() []Section {
+	if doc == nil || doc.Body == nil {
+		return nil
+	}
+
+	var sections []Section
+	var current []BodyElement
+	for _, elem := range doc.Body.Elements {
+		current = append(current, elem)
+		para, ok := elem.(*Paragraph)
+		if !ok || para.Properties == nil {
+			continue
+		}
+		for i := range para.Properties.RawXML {
+			if para.Properties.RawXML[i].XMLName.Local == "sectPr" {
+				sections = append(sections, Section{Elements: current, Properties: &para.Properties.RawXML[i]})
+				current = nil
+				break
+			}
+		}
+	}
+	return append(sections, Section{Elements: current, Properties: doc.Body.SectionProperties})
+}
+
 // ParseDocument parses a Word document XML
 func ParseDocument(r io.Reader) (*Document, error) {
 	decoder := xml.NewDecoder(r)
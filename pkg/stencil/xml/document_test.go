@@ -227,3 +227,45 @@ func TestParseDocument_ConcurrentParsersDoNotSerialize(t *testing.T) {
 		t.Fatal("first parse did not finish after release")
 	}
 }
+
+func TestDocumentAccessors(t *testing.T) {
+	docXML := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>Cover</w:t></w:r></w:p>` +
+		`<w:p><w:pPr><w:sectPr><w:pgSz w:w="11906" w:h="16838"/></w:sectPr></w:pPr></w:p>` +
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+		`<w:p><w:r><w:t>Body</w:t></w:r></w:p>` +
+		`<w:sectPr><w:pgSz w:w="16838" w:h="11906"/></w:sectPr>` +
+		`</w:body></w:document>`
+	doc, err := ParseDocument(strings.NewReader(docXML))
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	if got := len(doc.Elements()); got != 4 {
+		t.Errorf("Elements() length = %d, want 4", got)
+	}
+	paragraphs := doc.Paragraphs()
+	if len(paragraphs) != 3 || paragraphs[0].GetText() != "Cover" || paragraphs[2].GetText() != "Body" {
+		t.Errorf("unexpected Paragraphs(): %d paragraphs", len(paragraphs))
+	}
+	tables := doc.Tables()
+	if len(tables) != 1 || tables[0].Rows[0].Cells[0].GetText() != "Cell" {
+		t.Errorf("unexpected Tables(): %+v", tables)
+	}
+
+	sections := doc.Sections()
+	if len(sections) != 2 {
+		t.Fatalf("Sections() length = %d, want 2", len(sections))
+	}
+	if len(sections[0].Elements) != 2 || sections[0].Properties == nil || !strings.Contains(string(sections[0].Properties.Content), `w="11906"`) {
+		t.Errorf("unexpected first section: %d elements, %s", len(sections[0].Elements), sections[0].Properties.Content)
+	}
+	if len(sections[1].Elements) != 2 || sections[1].Properties != doc.Body.SectionProperties {
+		t.Errorf("unexpected last section: %+v", sections[1])
+	}
+
+	var empty *Document
+	if empty.Paragraphs() != nil || empty.Sections() != nil {
+		t.Error("expected nil results for a nil document")
+	}
+}
@@ -0,0 +1,102 @@
+package xml
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// EscapeString escapes s for use in the character data or attribute values
+// of a document part. It is the one place text is escaped for output:
+// &, < and > become entity references, and quotes, tabs, line feeds and
+// carriage returns character references, as encoding/xml writes them, so
+// attribute values keep them. The characters ValidText removes are left
+// out. Other characters, such as non-breaking spaces and soft hyphens, are
+// written as they are.
+func EscapeString(s string) string {
+	s = ValidText(s)
+	if !strings.ContainsAny(s, "&<>\"'\t\n\r") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 16)
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var ref string
+		switch s[i] {
+		case '&':
+			ref = "&amp;"
+		case '<':
+			ref = "&lt;"
+		case '>':
+			ref = "&gt;"
+		case '"':
+			ref = "&#34;"
+		case '\'':
+			ref = "&#39;"
+		case '\t':
+			ref = "&#x9;"
+		case '\n':
+			ref = "&#xA;"
+		case '\r':
+			ref = "&#xD;"
+		default:
+			continue
+		}
+		b.WriteString(s[last:i])
+		b.WriteString(ref)
+		last = i + 1
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// ValidText returns s without the characters that cannot appear in a
+// document part: characters XML 1.0 does not allow, such as NUL, the other
+// control characters, U+FFFE and U+FFFF, bytes that are not valid UTF-8,
+// including encoded surrogate halves, and byte order marks (U+FEFF), which
+// text pasted from files often starts with. Text that is already valid is
+// returned as is.
+func ValidText(s string) string {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c < utf8.RuneSelf && (c >= 0x20 || c == '\t' || c == '\n' || c == '\r') {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !keepRune(r, size) {
+			break
+		}
+		i += size
+	}
+	if i == len(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:i])
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if keepRune(r, size) {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// keepRune reports whether ValidText keeps the rune r of size bytes.
+func keepRune(r rune, size int) bool {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return false
+	case r == '\uFEFF':
+		return false
+	}
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
@@ -0,0 +1,78 @@
+package xml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestEscapeString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "Net total", want: "Net total"},
+		{name: "empty", in: "", want: ""},
+		{name: "ampersand", in: "Smith & Sons", want: "Smith &amp; Sons"},
+		{name: "entity-like text", in: "&amp; &#38;", want: "&amp;amp; &amp;#38;"},
+		{name: "markup", in: "<w:t>a > b</w:t>", want: "&lt;w:t&gt;a &gt; b&lt;/w:t&gt;"},
+		{name: "quotes", in: `"it's"`, want: "&#34;it&#39;s&#34;"},
+		{name: "whitespace", in: "a\tb\nc\rd", want: "a&#x9;b&#xA;c&#xD;d"},
+		{name: "non-breaking space", in: "10\u00A0kg", want: "10\u00A0kg"},
+		{name: "soft hyphen", in: "Donau\u00ADdampf", want: "Donau\u00ADdampf"},
+		{name: "non-ASCII", in: "Grüße, 東京, 🚀", want: "Grüße, 東京, 🚀"},
+		{name: "NUL", in: "a\x00b", want: "ab"},
+		{name: "control characters", in: "a\x01\x08\x0b\x0c\x0e\x1fb\x7f", want: "ab\x7f"},
+		{name: "BOM", in: "\uFEFFHello\uFEFF", want: "Hello"},
+		{name: "noncharacters", in: "a\uFFFEb\uFFFFc", want: "abc"},
+		{name: "encoded surrogate halves", in: "a\xed\xa0\x80b\xed\xbf\xbfc", want: "abc"},
+		{name: "invalid UTF-8", in: "a\xffb\xc3", want: "ab"},
+		{name: "replacement character", in: "a\uFFFDb", want: "a\uFFFDb"},
+		{name: "mixed", in: "\uFEFF<a & b>\x00\u00A0", want: "&lt;a &amp; b&gt;\u00A0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EscapeString(tt.in)
+			if got != tt.want {
+				t.Errorf("EscapeString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+
+			// The result is valid XML that reads back as the text without
+			// the dropped characters.
+			var parsed struct {
+				Value string `xml:"v,attr"`
+				Text  string `xml:",chardata"`
+			}
+			doc := `<e v="` + got + `">` + got + `</e>`
+			if err := xml.Unmarshal([]byte(doc), &parsed); err != nil {
+				t.Fatalf("escaped %q is not valid XML: %v", tt.in, err)
+			}
+			if want := ValidText(tt.in); parsed.Value != want || parsed.Text != want {
+				t.Errorf("escaped %q read back as %q and %q, want %q", tt.in, parsed.Value, parsed.Text, want)
+			}
+		})
+	}
+}
+
+func TestValidTextReturnsValidInputUnchanged(t *testing.T) {
+	for _, s := range []string{"", "Total", "a & <b>", "10\u00A0kg", "🚀\t\r\n"} {
+		if got := ValidText(s); got != s {
+			t.Errorf("ValidText(%q) = %q", s, got)
+		}
+	}
+}
+
+func TestTextMarshalDropsInvalidCharacters(t *testing.T) {
+	output, err := xml.Marshal(Text{Content: "\uFEFFA\x00 & B\x1b\u00AD"})
+	if err != nil {
+		t.Fatalf("failed to marshal text: %v", err)
+	}
+	if want := "<w:t>A &amp; B\u00AD</w:t>"; string(output) != want {
+		t.Errorf("got %s, want %s", output, want)
+	}
+	if strings.Contains(string(output), "\uFFFD") {
+		t.Errorf("unexpected replacement character in %s", output)
+	}
+}
@@ -78,8 +78,7 @@ func (p *Paragraph) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				if err := d.DecodeElement(&run, &t); err != nil {
 					return err
 				}
-				tempContent = append(tempContent, &run)
-				tempRuns = append(tempRuns, run)
+				appendDecodedRun(&tempContent, &tempRuns, run)
 			case "hyperlink":
 				if !isWordprocessingMLElement(t) {
 					if err := collectNestedParagraphContent(d, t, parseNamespaces, &tempContent, &tempRuns, &tempHyperlinks, &useContent); err != nil {
@@ -91,6 +90,7 @@ func (p *Paragraph) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				if err := d.DecodeElement(&hyperlink, &t); err != nil {
 					return err
 				}
+				hyperlink.Runs = expandSplitRuns(hyperlink.Runs)
 				tempContent = append(tempContent, &hyperlink)
 				tempHyperlinks = append(tempHyperlinks, hyperlink)
 				useContent = true
@@ -194,6 +194,16 @@ func supportedMCNamespaceURI(uri string) bool {
 	return namespaceToPrefix(uri) != uri
 }
 
+// appendDecodedRun appends a decoded run, followed by the runs split off it,
+// to the paragraph content.
+func appendDecodedRun(tempContent *[]ParagraphContent, tempRuns *[]Run, run Run) {
+	for _, decoded := range expandSplitRuns([]Run{run}) {
+		decoded := decoded
+		*tempContent = append(*tempContent, &decoded)
+		*tempRuns = append(*tempRuns, decoded)
+	}
+}
+
 // collectNestedParagraphContent walks unknown paragraph child elements and keeps
 // supported nested content instead of dropping it with Decoder.Skip().
 func collectNestedParagraphContent(
@@ -223,8 +233,7 @@ func collectNestedParagraphContent(
 		if err := d.DecodeElement(&run, &start); err != nil {
 			return err
 		}
-		*tempContent = append(*tempContent, &run)
-		*tempRuns = append(*tempRuns, run)
+		appendDecodedRun(tempContent, tempRuns, run)
 		return nil
 	case "hyperlink":
 		if !isWordprocessingMLElement(start) {
@@ -234,6 +243,7 @@ func collectNestedParagraphContent(
 		if err := d.DecodeElement(&hyperlink, &start); err != nil {
 			return err
 		}
+		hyperlink.Runs = expandSplitRuns(hyperlink.Runs)
 		*tempContent = append(*tempContent, &hyperlink)
 		*tempHyperlinks = append(*tempHyperlinks, hyperlink)
 		*useContent = true
@@ -273,116 +283,6 @@ func collectNestedParagraphContent(
 	}
 }
 
-// collectAlternateContentBranch handles mc:AlternateContent by selecting a single branch.
-// OOXML semantics prefer a Choice branch when available, otherwise Fallback.
-func collectAlternateContentBranch(
-	d *xml.Decoder,
-	start xml.StartElement,
-	inScopeNamespaces map[string]string,
-	tempContent *[]ParagraphContent,
-	tempRuns *[]Run,
-	tempHyperlinks *[]Hyperlink,
-	useContent *bool,
-) error {
-	type branchContent struct {
-		content    []ParagraphContent
-		runs       []Run
-		hyperlinks []Hyperlink
-		useContent bool
-	}
-
-	appendBranch := func(branch *branchContent) {
-		if branch == nil {
-			return
-		}
-		*tempContent = append(*tempContent, branch.content...)
-		*tempRuns = append(*tempRuns, branch.runs...)
-		*tempHyperlinks = append(*tempHyperlinks, branch.hyperlinks...)
-		if branch.useContent {
-			*useContent = true
-		}
-	}
-
-	captureBranch := func(branchStart xml.StartElement) (*branchContent, error) {
-		var branch branchContent
-		if err := collectNestedParagraphContent(
-			d,
-			branchStart,
-			inScopeNamespaces,
-			&branch.content,
-			&branch.runs,
-			&branch.hyperlinks,
-			&branch.useContent,
-		); err != nil {
-			return nil, err
-		}
-		return &branch, nil
-	}
-
-	var selectedChoice *branchContent
-	var firstChoice *branchContent
-	var fallback *branchContent
-
-	for {
-		token, err := d.Token()
-		if err == io.EOF {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		switch t := token.(type) {
-		case xml.StartElement:
-			if t.Name.Space == markupCompatibilityNamespace && t.Name.Local == "Choice" {
-				branch, err := captureBranch(t)
-				if err != nil {
-					return err
-				}
-				if firstChoice == nil {
-					firstChoice = branch
-				}
-				if selectedChoice == nil && choiceRequirementsSupported(inScopeNamespaces, t) {
-					selectedChoice = branch
-				}
-				continue
-			}
-
-			if t.Name.Space == markupCompatibilityNamespace && t.Name.Local == "Fallback" {
-				if fallback == nil {
-					branch, err := captureBranch(t)
-					if err != nil {
-						return err
-					}
-					fallback = branch
-				} else {
-					if err := d.Skip(); err != nil {
-						return err
-					}
-				}
-				continue
-			}
-
-			if err := collectNestedParagraphContent(d, t, inScopeNamespaces, tempContent, tempRuns, tempHyperlinks, useContent); err != nil {
-				return err
-			}
-		case xml.EndElement:
-			if t.Name.Local == start.Name.Local && t.Name.Space == start.Name.Space {
-				if selectedChoice != nil {
-					appendBranch(selectedChoice)
-				} else if fallback != nil {
-					appendBranch(fallback)
-				} else if firstChoice != nil {
-					// Prevent silent data loss when no choice requirements are recognized
-					// and the AlternateContent has no fallback branch.
-					appendBranch(firstChoice)
-				}
-				return nil
-			}
-		}
-	}
-}
-
 // MarshalXML implements custom XML marshaling for Paragraph to ensure proper namespacing
 func (p Paragraph) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	// Start the paragraph element
@@ -405,21 +305,8 @@ func (p Paragraph) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 
 	// If we have Content, use that to preserve order
 	if len(p.Content) > 0 {
-		for _, content := range p.Content {
-			switch c := content.(type) {
-			case *Run:
-				if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "w:r"}}); err != nil {
-					return err
-				}
-			case *Hyperlink:
-				if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "w:hyperlink"}}); err != nil {
-					return err
-				}
-			case *ProofErr:
-				if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "w:proofErr"}}); err != nil {
-					return err
-				}
-			}
+		if err := encodeParagraphContent(e, p.Content); err != nil {
+			return err
 		}
 	} else {
 		// Fall back to legacy fields
@@ -440,6 +327,32 @@ func (p Paragraph) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.EncodeToken(xml.EndElement{Name: start.Name})
 }
 
+// encodeParagraphContent writes runs, hyperlinks and other paragraph content
+// in order
+func encodeParagraphContent(e *xml.Encoder, content []ParagraphContent) error {
+	for _, item := range content {
+		switch c := item.(type) {
+		case *Run:
+			if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "w:r"}}); err != nil {
+				return err
+			}
+		case *Hyperlink:
+			if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "w:hyperlink"}}); err != nil {
+				return err
+			}
+		case *ProofErr:
+			if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "w:proofErr"}}); err != nil {
+				return err
+			}
+		case *AlternateContent:
+			if err := e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "mc:AlternateContent"}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // GetText returns the concatenated text of all runs in a paragraph
 func (p *Paragraph) GetText() string {
 	var texts []string
@@ -456,6 +369,10 @@ func (p *Paragraph) GetText() string {
 				if text := c.GetText(); text != "" {
 					texts = append(texts, text)
 				}
+			case *AlternateContent:
+				if text := c.GetText(); text != "" {
+					texts = append(texts, text)
+				}
 			}
 		}
 		return strings.Join(texts, "")
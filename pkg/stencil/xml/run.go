@@ -76,6 +76,9 @@ type Run struct {
 	Attrs []xml.Attr `xml:"-"`
 	// RawXML stores unparsed XML elements (like drawings) to preserve them
 	RawXML []RawXMLElement `xml:"-"`
+	// split holds the runs split off a w:r element whose text is interleaved
+	// with footnote or endnote references, until the paragraph takes them.
+	split []Run
 }
 
 // isParagraphContent implements the ParagraphContent interface
@@ -89,6 +92,27 @@ func (r *Run) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	} else {
 		r.Attrs = nil
 	}
+	r.split = nil
+
+	// A footnote or endnote reference that shares a w:r element with text
+	// gets a run of its own, so that placeholders on either side of it are
+	// reassembled like those around a separate reference run
+	current := r
+	var split []*Run
+	startRun := func() {
+		next := &Run{Attrs: append([]xml.Attr(nil), r.Attrs...)}
+		if r.Properties != nil {
+			props := *r.Properties
+			next.Properties = &props
+		}
+		split = append(split, next)
+		current = next
+	}
+	defer func() {
+		for _, run := range split {
+			r.split = append(r.split, *run)
+		}
+	}()
 
 	// Process elements in order
 	for {
@@ -114,13 +138,16 @@ func (r *Run) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				if err := d.DecodeElement(&text, &t); err != nil {
 					return err
 				}
-				r.Text = &text
+				if current.hasNoteReference() {
+					startRun()
+				}
+				current.Text = &text
 			case "br":
 				var br Break
 				if err := d.DecodeElement(&br, &t); err != nil {
 					return err
 				}
-				r.Break = &br
+				current.Break = &br
 			default:
 				// Preserve unknown elements as raw XML
 				var raw RawXMLElement
@@ -179,7 +206,7 @@ func (r *Run) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 							}
 							buf.WriteString(attr.Name.Local)
 							buf.WriteString("=\"")
-							buf.WriteString(attr.Value)
+							buf.WriteString(EscapeString(attr.Value))
 							buf.WriteString("\"")
 						}
 						buf.WriteString(">")
@@ -196,12 +223,7 @@ func (r *Run) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 						buf.WriteString(">")
 					case xml.CharData:
 						// Write character data with XML escaping
-						escaped := string(tt)
-						escaped = strings.Replace(escaped, "&", "&amp;", -1)
-						escaped = strings.Replace(escaped, "<", "&lt;", -1)
-						escaped = strings.Replace(escaped, ">", "&gt;", -1)
-						escaped = strings.Replace(escaped, "\"", "&quot;", -1)
-						buf.WriteString(escaped)
+						buf.WriteString(EscapeString(string(tt)))
 					}
 				}
 
@@ -209,7 +231,10 @@ func (r *Run) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				// Don't add it again here!
 
 				raw.Content = []byte(buf.String())
-				r.RawXML = append(r.RawXML, raw)
+				if isNoteReference(raw.XMLName.Local) && current.Text != nil {
+					startRun()
+				}
+				current.RawXML = append(current.RawXML, raw)
 			}
 		case xml.EndElement:
 			if t.Name.Local == "r" {
@@ -261,6 +286,36 @@ func (r Run) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.EncodeToken(xml.EndElement{Name: start.Name})
 }
 
+// hasNoteReference reports whether the run holds a footnote or endnote
+// reference.
+func (r *Run) hasNoteReference() bool {
+	for _, raw := range r.RawXML {
+		if isNoteReference(raw.XMLName.Local) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNoteReference reports whether local names a footnote or endnote
+// reference element.
+func isNoteReference(local string) bool {
+	return local == "footnoteReference" || local == "endnoteReference"
+}
+
+// expandSplitRuns returns runs with the runs split off each of them
+// following it.
+func expandSplitRuns(runs []Run) []Run {
+	expanded := runs[:0:0]
+	for _, run := range runs {
+		split := run.split
+		run.split = nil
+		expanded = append(expanded, run)
+		expanded = append(expanded, split...)
+	}
+	return expanded
+}
+
 // GetText returns the text content of a run
 func (r *Run) GetText() string {
 	if r.Text == nil {
@@ -294,17 +349,37 @@ type Text struct {
 	Content string   `xml:",chardata"`
 }
 
-// MarshalXML implements custom XML marshaling for Text to ensure proper namespacing
+// MarshalXML implements custom XML marshaling for Text to ensure proper namespacing.
+// Text whose spaces Word would collapse is written with xml:space="preserve"
+// even if Space is not set, so padded values keep their layout.
 func (t Text) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	start.Name = xml.Name{Local: "w:t"}
-	if t.Space == "preserve" {
+	if t.Space == "preserve" || needsSpacePreservation(t.Content) {
 		// Use the predefined XML namespace
 		start.Attr = append(start.Attr, xml.Attr{
 			Name:  xml.Name{Space: "http://www.w3.org/XML/1998/namespace", Local: "space"},
 			Value: "preserve",
 		})
 	}
-	return e.EncodeElement(t.Content, start)
+	return e.EncodeElement(ValidText(t.Content), start)
+}
+
+// needsSpacePreservation reports whether Word would change text without
+// xml:space="preserve": text with leading or trailing whitespace,
+// consecutive spaces, tabs or line breaks.
+func needsSpacePreservation(content string) bool {
+	if content == "" {
+		return false
+	}
+	switch content[0] {
+	case ' ', '\t', '\n', '\r':
+		return true
+	}
+	switch content[len(content)-1] {
+	case ' ', '\t', '\n', '\r':
+		return true
+	}
+	return strings.Contains(content, "  ") || strings.ContainsAny(content, "\t\n\r")
 }
 
 // Break represents a line break
@@ -0,0 +1,32 @@
+package xml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestTextMarshalPreservesSpaces(t *testing.T) {
+	tests := []struct {
+		content  string
+		preserve bool
+	}{
+		{content: "Total", preserve: false},
+		{content: "Net total", preserve: false},
+		{content: "", preserve: false},
+		{content: "  42", preserve: true},
+		{content: "42 ", preserve: true},
+		{content: "A  B", preserve: true},
+		{content: "A\tB", preserve: true},
+	}
+
+	for _, tt := range tests {
+		output, err := xml.Marshal(Text{Content: tt.content})
+		if err != nil {
+			t.Fatalf("failed to marshal %q: %v", tt.content, err)
+		}
+		if got := strings.Contains(string(output), `space="preserve"`); got != tt.preserve {
+			t.Errorf("text %q marshaled as %s, want preserve=%v", tt.content, output, tt.preserve)
+		}
+	}
+}
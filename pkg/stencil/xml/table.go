@@ -354,8 +354,9 @@ func (r TableRow) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 
 // TableRowProperties represents row properties
 type TableRowProperties struct {
-	CantSplit bool    `xml:"-"` // Prevent row from splitting across pages
-	Height    *Height `xml:"trHeight"`
+	CantSplit   bool    `xml:"-"` // Prevent row from splitting across pages
+	Height      *Height `xml:"trHeight"`
+	TableHeader bool    `xml:"-"` // Repeat the row at the top of each page
 }
 
 // UnmarshalXML implements custom XML unmarshaling for TableRowProperties
@@ -383,6 +384,16 @@ func (p *TableRowProperties) UnmarshalXML(d *xml.Decoder, start xml.StartElement
 					return err
 				}
 				p.Height = &height
+			case "tblHeader":
+				p.TableHeader = true
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "val" && (attr.Value == "0" || attr.Value == "false" || attr.Value == "off") {
+						p.TableHeader = false
+					}
+				}
+				if err := d.Skip(); err != nil {
+					return err
+				}
 			default:
 				if err := d.Skip(); err != nil {
 					return err
@@ -418,6 +429,13 @@ func (p TableRowProperties) MarshalXML(e *xml.Encoder, start xml.StartElement) e
 		}
 	}
 
+	// Encode tblHeader if true
+	if p.TableHeader {
+		if err := e.EncodeElement(struct{}{}, xml.StartElement{Name: xml.Name{Local: "w:tblHeader"}}); err != nil {
+			return err
+		}
+	}
+
 	return e.EncodeToken(xml.EndElement{Name: start.Name})
 }
 
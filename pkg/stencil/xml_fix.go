@@ -153,51 +153,53 @@ func min(a, b int) int {
 	return b
 }
 
+// knownNamespacePrefixes maps the namespace URIs used in WordprocessingML
+// parts to their conventional prefixes.
+var knownNamespacePrefixes = map[string]string{
+	"http://schemas.openxmlformats.org/wordprocessingml/2006/main":           "w",
+	"http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing": "wp",
+	"http://schemas.openxmlformats.org/drawingml/2006/main":                  "a",
+	"http://schemas.openxmlformats.org/drawingml/2006/picture":               "pic",
+	"http://schemas.microsoft.com/office/word/2010/wordprocessingDrawing":    "wp14",
+	"http://schemas.microsoft.com/office/drawing/2010/main":                  "a14",
+	"http://schemas.openxmlformats.org/officeDocument/2006/relationships":    "r",
+	"http://www.w3.org/XML/1998/namespace":                                   "xml",
+	"http://schemas.openxmlformats.org/markup-compatibility/2006":            "mc",
+	"http://schemas.microsoft.com/office/word/2010/wordprocessingCanvas":     "wpc",
+	"http://schemas.microsoft.com/office/drawing/2014/chartex":               "cx",
+	"http://schemas.microsoft.com/office/drawing/2015/9/8/chartex":           "cx1",
+	"http://schemas.microsoft.com/office/drawing/2015/10/21/chartex":         "cx2",
+	"http://schemas.microsoft.com/office/drawing/2016/5/9/chartex":           "cx3",
+	"http://schemas.microsoft.com/office/drawing/2016/5/10/chartex":          "cx4",
+	"http://schemas.microsoft.com/office/drawing/2016/5/11/chartex":          "cx5",
+	"http://schemas.microsoft.com/office/drawing/2016/5/12/chartex":          "cx6",
+	"http://schemas.microsoft.com/office/drawing/2016/5/13/chartex":          "cx7",
+	"http://schemas.microsoft.com/office/drawing/2016/5/14/chartex":          "cx8",
+	"http://schemas.microsoft.com/office/drawing/2016/ink":                   "aink",
+	"http://schemas.microsoft.com/office/drawing/2017/model3d":               "am3d",
+	"urn:schemas-microsoft-com:office:office":                                "o",
+	"http://schemas.microsoft.com/office/2019/extlst":                        "oel",
+	"http://schemas.openxmlformats.org/officeDocument/2006/math":             "m",
+	"urn:schemas-microsoft-com:vml":                                          "v",
+	"urn:schemas-microsoft-com:office:word":                                  "w10",
+	"http://schemas.microsoft.com/office/word/2010/wordml":                   "w14",
+	"http://schemas.microsoft.com/office/word/2012/wordml":                   "w15",
+	"http://schemas.microsoft.com/office/word/2018/wordml/cex":               "w16cex",
+	"http://schemas.microsoft.com/office/word/2016/wordml/cid":               "w16cid",
+	"http://schemas.microsoft.com/office/word/2018/wordml":                   "w16",
+	"http://schemas.microsoft.com/office/word/2023/wordml/word16du":          "w16du",
+	"http://schemas.microsoft.com/office/word/2020/wordml/sdtdatahash":       "w16sdtdh",
+	"http://schemas.microsoft.com/office/word/2024/wordml/sdtformatlock":     "w16sdtfl",
+	"http://schemas.microsoft.com/office/word/2015/wordml/symex":             "w16se",
+	"http://schemas.microsoft.com/office/word/2010/wordprocessingGroup":      "wpg",
+	"http://schemas.microsoft.com/office/word/2010/wordprocessingInk":        "wpi",
+	"http://schemas.microsoft.com/office/word/2006/wordml":                   "wne",
+	"http://schemas.microsoft.com/office/word/2010/wordprocessingShape":      "wps",
+}
+
 // namespaceURIToPrefix converts a full namespace URI to its prefix
 func namespaceURIToPrefix(uri string) string {
-	prefixMap := map[string]string{
-		"http://schemas.openxmlformats.org/wordprocessingml/2006/main":           "w",
-		"http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing": "wp",
-		"http://schemas.openxmlformats.org/drawingml/2006/main":                  "a",
-		"http://schemas.openxmlformats.org/drawingml/2006/picture":               "pic",
-		"http://schemas.microsoft.com/office/word/2010/wordprocessingDrawing":    "wp14",
-		"http://schemas.microsoft.com/office/drawing/2010/main":                  "a14",
-		"http://schemas.openxmlformats.org/officeDocument/2006/relationships":    "r",
-		"http://www.w3.org/XML/1998/namespace":                                   "xml",
-		"http://schemas.openxmlformats.org/markup-compatibility/2006":            "mc",
-		"http://schemas.microsoft.com/office/word/2010/wordprocessingCanvas":     "wpc",
-		"http://schemas.microsoft.com/office/drawing/2014/chartex":               "cx",
-		"http://schemas.microsoft.com/office/drawing/2015/9/8/chartex":           "cx1",
-		"http://schemas.microsoft.com/office/drawing/2015/10/21/chartex":         "cx2",
-		"http://schemas.microsoft.com/office/drawing/2016/5/9/chartex":           "cx3",
-		"http://schemas.microsoft.com/office/drawing/2016/5/10/chartex":          "cx4",
-		"http://schemas.microsoft.com/office/drawing/2016/5/11/chartex":          "cx5",
-		"http://schemas.microsoft.com/office/drawing/2016/5/12/chartex":          "cx6",
-		"http://schemas.microsoft.com/office/drawing/2016/5/13/chartex":          "cx7",
-		"http://schemas.microsoft.com/office/drawing/2016/5/14/chartex":          "cx8",
-		"http://schemas.microsoft.com/office/drawing/2016/ink":                   "aink",
-		"http://schemas.microsoft.com/office/drawing/2017/model3d":               "am3d",
-		"urn:schemas-microsoft-com:office:office":                                "o",
-		"http://schemas.microsoft.com/office/2019/extlst":                        "oel",
-		"http://schemas.openxmlformats.org/officeDocument/2006/math":             "m",
-		"urn:schemas-microsoft-com:vml":                                          "v",
-		"urn:schemas-microsoft-com:office:word":                                  "w10",
-		"http://schemas.microsoft.com/office/word/2010/wordml":                   "w14",
-		"http://schemas.microsoft.com/office/word/2012/wordml":                   "w15",
-		"http://schemas.microsoft.com/office/word/2018/wordml/cex":               "w16cex",
-		"http://schemas.microsoft.com/office/word/2016/wordml/cid":               "w16cid",
-		"http://schemas.microsoft.com/office/word/2018/wordml":                   "w16",
-		"http://schemas.microsoft.com/office/word/2023/wordml/word16du":          "w16du",
-		"http://schemas.microsoft.com/office/word/2020/wordml/sdtdatahash":       "w16sdtdh",
-		"http://schemas.microsoft.com/office/word/2024/wordml/sdtformatlock":     "w16sdtfl",
-		"http://schemas.microsoft.com/office/word/2015/wordml/symex":             "w16se",
-		"http://schemas.microsoft.com/office/word/2010/wordprocessingGroup":      "wpg",
-		"http://schemas.microsoft.com/office/word/2010/wordprocessingInk":        "wpi",
-		"http://schemas.microsoft.com/office/word/2006/wordml":                   "wne",
-		"http://schemas.microsoft.com/office/word/2010/wordprocessingShape":      "wps",
-	}
-
-	if prefix, ok := prefixMap[uri]; ok {
+	if prefix, ok := knownNamespacePrefixes[uri]; ok {
 		return prefix
 	}
 	// Return the URI as-is if no mapping found (shouldn't happen but safe fallback)
@@ -255,7 +257,7 @@ func prepareParagraphRawXML(para *Paragraph, rawXMLMap map[string][]byte, marker
 	}
 
 	if len(para.Content) > 0 {
-		for _, content := range para.Content {
+		for i, content := range para.Content {
 			switch c := content.(type) {
 			case *Run:
 				prepareRunRawXML(c, rawXMLMap, markerIndex)
@@ -263,6 +265,8 @@ func prepareParagraphRawXML(para *Paragraph, rawXMLMap map[string][]byte, marker
 				for runIdx := range c.Runs {
 					prepareRunRawXML(&c.Runs[runIdx], rawXMLMap, markerIndex)
 				}
+			case *AlternateContent:
+				para.Content[i] = prepareAlternateContentRawXML(c, rawXMLMap, markerIndex)
 			}
 		}
 		return
@@ -278,6 +282,27 @@ func prepareParagraphRawXML(para *Paragraph, rawXMLMap map[string][]byte, marker
 	}
 }
 
+// prepareAlternateContentRawXML returns a copy of alternate whose unchanged
+// branches are written from their original XML. The copy keeps the template
+// element free of markers.
+func prepareAlternateContentRawXML(alternate *AlternateContent, rawXMLMap map[string][]byte, markerIndex *int) *AlternateContent {
+	prepared := *alternate
+	prepared.Branches = append([]AlternateContentBranch(nil), alternate.Branches...)
+	for i := range prepared.Branches {
+		branch := &prepared.Branches[i]
+		if branch.RawXML == nil {
+			branchPara := &Paragraph{Content: branch.Content}
+			prepareParagraphRawXML(branchPara, rawXMLMap, markerIndex)
+			continue
+		}
+		marker := fmt.Sprintf("__RAW_XML_MARKER_%d__", *markerIndex)
+		rawXMLMap[marker] = branch.RawXML
+		branch.RawXMLMarker = marker
+		*markerIndex = *markerIndex + 1
+	}
+	return &prepared
+}
+
 func prepareRunRawXML(run *Run, rawXMLMap map[string][]byte, markerIndex *int) {
 	if run == nil || len(run.RawXML) == 0 {
 		return
@@ -344,7 +369,7 @@ func postProcessXMLChunk(chunk []byte, rawXMLMap map[string][]byte) string {
 		}
 	}
 
-	return xmlChunk
+	return unwrapPermissionRuns(xmlChunk)
 }
 
 func appendRootAttributes(buf *bytes.Buffer, attrs []xml.Attr) {